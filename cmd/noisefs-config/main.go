@@ -58,7 +58,7 @@ func showConfig(path string) {
 		os.Exit(1)
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	data, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to marshal config: %v\n", err)
 		os.Exit(1)
@@ -69,13 +69,7 @@ func showConfig(path string) {
 }
 
 func validateConfig(path string) {
-	cfg, err := config.LoadConfig(path)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
-		os.Exit(1)
-	}
-
-	if err := cfg.Validate(); err != nil {
+	if _, err := config.ValidateFile(path); err != nil {
 		fmt.Fprintf(os.Stderr, "Configuration validation failed: %v\n", err)
 		os.Exit(1)
 	}
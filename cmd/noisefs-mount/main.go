@@ -11,15 +11,20 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/client"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
 	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/metrics"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/telemetry"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
 	"github.com/TheEntropyCollective/noisefs/pkg/tools/bootstrap"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 func main() {
@@ -57,6 +62,17 @@ func main() {
 		directoryKey        = flag.String("directory-key", "", "Encryption key for directory descriptor")
 		subdir              = flag.String("subdir", "", "Subdirectory within directory descriptor to mount")
 		multiDirs           = flag.String("multi-dirs", "", "Mount multiple directories (format: name1:cid1:key1,name2:cid2:key2)")
+
+		// macOS Finder integration
+		volumeIcon    = flag.String("volicon", "", "macOS: .icns file Finder shows for the volume")
+		showInSidebar = flag.Bool("sidebar", false, "macOS: list the volume under Locations in the Finder sidebar")
+		spotlight     = flag.Bool("spotlight", false, "macOS: let Spotlight index the volume (excluded by default)")
+
+		// Monitoring
+		metricsAddress = flag.String("metrics", "", "Serve Prometheus metrics at this address, e.g. 127.0.0.1:9464 (overrides config)")
+
+		// Legal disclaimer
+		acceptTOS = flag.Bool("accept-tos", false, "Accept the legal disclaimer without prompting, for automation")
 	)
 	flag.Parse()
 
@@ -89,8 +105,16 @@ func main() {
 		return
 	}
 
+	// Mounts started by a service manager have no terminal to prompt on and
+	// need -accept-tos or an earlier acceptance
+	if os.Getenv("NOISEFS_SKIP_LEGAL_NOTICE") != "1" {
+		if err := compliance.EnsureDisclaimerAccepted(*acceptTOS, "mount", os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("You must accept the terms to use NoiseFS: %v", err)
+		}
+	}
+
 	// Load configuration
-	cfg, err := loadConfig(*configFile)
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -99,27 +123,38 @@ func main() {
 	if err := logging.InitFromConfig(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output, cfg.Logging.File); err != nil {
 		log.Fatalf("Failed to initialize logging: %v", err)
 	}
+	if err := logging.InitComponentLevelsFromConfig(cfg.Logging.Components, cfg.Logging.SampleInitial, cfg.Logging.SampleThereafter); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
 
 	logger := logging.GetGlobalLogger().WithComponent("noisefs-mount")
+	telemetry.Use(cfg.Telemetry, "mount")
 
-	// Apply command-line overrides
-	if *mountPath != "" {
+	// Apply command-line overrides for flags that were given
+	setFlags := config.ExplicitFlags(flag.CommandLine)
+	if setFlags["mount"] {
 		cfg.FUSE.MountPath = *mountPath
 	}
 	// Note: VolumeName is no longer configurable in simplified config
-	if *ipfsAPI != "" {
+	if setFlags["ipfs"] {
 		cfg.IPFS.APIEndpoint = *ipfsAPI
 	}
-	if *cacheSize != 0 {
+	if setFlags["cache"] {
 		cfg.Cache.BlockCacheSize = *cacheSize
 	}
-	if *indexFile != "" {
+	if setFlags["index"] {
 		cfg.FUSE.IndexPath = *indexFile
 	}
-	// Apply boolean overrides (they override config regardless of value)
-	cfg.FUSE.ReadOnly = *readOnly
+	if setFlags["readonly"] {
+		cfg.FUSE.ReadOnly = *readOnly
+	}
 	// Note: AllowOther is no longer configurable in simplified config
-	cfg.FUSE.Debug = *debug
+	if setFlags["debug"] {
+		cfg.FUSE.Debug = *debug
+	}
+	if setFlags["metrics"] {
+		cfg.FUSE.MetricsAddress = *metricsAddress
+	}
 
 	if cfg.FUSE.MountPath == "" {
 		logger.Error("Mount path is required", nil)
@@ -141,10 +176,21 @@ func main() {
 		handleBootstrap(cfg, *bootstrapData, *bootstrapSize, *bootstrapDir, logger)
 	}
 
+	// Allow log levels to be changed while mounted
+	if cfg.Daemon.ControlSocket != "" {
+		controlServer := control.NewServer(cfg.Daemon.ControlSocket)
+		control.RegisterLogLevelHandlers(controlServer, logging.GetGlobalLogger())
+		if err := controlServer.Start(); err != nil {
+			logger.Warn("Control socket disabled", map[string]interface{}{"error": err.Error()})
+		} else {
+			defer controlServer.Close()
+		}
+	}
+
 	// Mount filesystem
-	mountFS(cfg.FUSE.MountPath, "NoiseFS", cfg.IPFS.APIEndpoint, cfg.Cache.BlockCacheSize,
-		cfg.FUSE.ReadOnly, false, cfg.FUSE.Debug, *daemon, *pidFile, cfg.FUSE.IndexPath,
-		*directoryDescriptor, *directoryKey, *subdir, *multiDirs, logger)
+	mountFS(cfg.FUSE.MountPath, "NoiseFS", cfg.StorageConfig(), cfg.Cache.BlockCacheSize,
+		cfg.FUSE.ReadOnly, false, cfg.FUSE.Debug, *daemon, *pidFile, cfg.FUSE.IndexPath, cfg.Security.IndexPassword,
+		*directoryDescriptor, *directoryKey, *subdir, *multiDirs, *volumeIcon, *showInSidebar, *spotlight, cfg.FUSE.MetricsAddress, logger)
 }
 
 func showHelp() {
@@ -169,6 +215,18 @@ func showHelp() {
 	fmt.Println("  # Mount as daemon with PID file")
 	fmt.Println("  noisefs-mount -mount /mnt/noisefs -daemon -pidfile /var/run/noisefs.pid")
 	fmt.Println()
+	fmt.Println("  # Mount from a service, accepting the legal disclaimer")
+	fmt.Println("  noisefs-mount -mount /mnt/noisefs -daemon -accept-tos")
+	fmt.Println()
+	fmt.Println("  # Mount on macOS with a custom icon, listed in the Finder sidebar")
+	fmt.Println("  noisefs-mount -mount /Volumes/NoiseFS -volicon noisefs.icns -sidebar")
+	fmt.Println()
+	fmt.Println("  # Mount as drive N: on Windows (needs WinFsp)")
+	fmt.Println("  noisefs-mount -mount N:")
+	fmt.Println()
+	fmt.Println("  # Mount with Prometheus metrics at http://127.0.0.1:9464/metrics")
+	fmt.Println("  noisefs-mount -mount /mnt/noisefs -metrics 127.0.0.1:9464")
+	fmt.Println()
 	fmt.Println("  # Unmount filesystem")
 	fmt.Println("  noisefs-mount -unmount -mount /mnt/noisefs")
 	fmt.Println()
@@ -225,31 +283,24 @@ func showHelp() {
 	fmt.Println("  cat /mnt/noisefs/files/file.txt")
 }
 
-// loadConfig loads configuration from file or uses defaults
-func loadConfig(configPath string) (*config.Config, error) {
-	if configPath == "" {
-		// Try default config path
-		defaultPath, err := config.GetDefaultConfigPath()
-		if err == nil {
-			configPath = defaultPath
-		}
+// storageEndpoint describes where blocks are stored, for log messages
+func storageEndpoint(storageConfig *storage.Config) string {
+	backend := storageConfig.Backends[storageConfig.DefaultBackend]
+	if backend == nil || backend.Connection == nil {
+		return storageConfig.DefaultBackend
 	}
-
-	return config.LoadConfig(configPath)
+	return backend.Connection.Endpoint
 }
 
-func mountFS(mountPath, volumeName, ipfsAPI string, cacheSize int, readOnly, allowOther, debug, daemon bool, pidFile, indexFile, directoryDescriptor, directoryKey, subdir, multiDirs string, logger *logging.Logger) {
+func mountFS(mountPath, volumeName string, storageConfig *storage.Config, cacheSize int, readOnly, allowOther, debug, daemon bool, pidFile, indexFile, indexPassword, directoryDescriptor, directoryKey, subdir, multiDirs, volumeIcon string, showInSidebar, spotlight bool, metricsAddress string, logger *logging.Logger) {
 	// Clean mount path
 	mountPath = filepath.Clean(mountPath)
 
 	// Create storage manager
+	ipfsAPI := storageEndpoint(storageConfig)
 	logger.Info("Connecting to storage for mount", map[string]interface{}{
 		"ipfs_api": ipfsAPI,
 	})
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = ipfsAPI
-	}
 
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -284,6 +335,24 @@ func mountFS(mountPath, volumeName, ipfsAPI string, cacheSize int, readOnly, all
 		os.Exit(1)
 	}
 
+	// Serve metrics for as long as the mount runs
+	if metricsAddress != "" {
+		metricsServer, err := metrics.Serve(metricsAddress, metrics.Sources{
+			Client:  client,
+			Storage: storageManager,
+			FUSE:    true,
+		})
+		if err != nil {
+			logger.Warn("Metrics endpoint disabled", map[string]interface{}{
+				"address": metricsAddress,
+				"error":   err.Error(),
+			})
+		} else {
+			defer metricsServer.Close()
+			fmt.Printf("Metrics: http://%s/metrics\n", metricsServer.Addr)
+		}
+	}
+
 	// Parse multi-directory mounts
 	var multiDirMounts []fuse.DirectoryMount
 	if multiDirs != "" {
@@ -312,10 +381,14 @@ func mountFS(mountPath, volumeName, ipfsAPI string, cacheSize int, readOnly, all
 		ReadOnly:            readOnly,
 		AllowOther:          allowOther,
 		Debug:               debug,
+		IndexPassword:       indexPassword,
 		DirectoryDescriptor: directoryDescriptor,
 		DirectoryKey:        directoryKey,
 		Subdir:              subdir,
 		MultiDirs:           multiDirMounts,
+		VolumeIcon:          volumeIcon,
+		ShowInSidebar:       showInSidebar,
+		SpotlightIndexing:   spotlight,
 	}
 
 	fmt.Printf("Mounting NoiseFS at: %s\n", mountPath)
@@ -491,18 +564,22 @@ func handleBootstrap(cfg *config.Config, dataset string, maxSize int64, bootstra
 	}
 
 	// Create bootstrap configuration
+	progressBar := util.NewProgressBar(0, "Downloading dataset", os.Stdout)
 	bootstrapConfig := &bootstrap.Config{
 		OutputDir:         bootstrapDir,
 		Dataset:           dataset,
 		MaxSize:           maxSize,
 		Verbose:           cfg.FUSE.Debug,
 		ParallelDownloads: 4,
+		Progress:          progressBar,
 	}
 
 	// Download bootstrap data
 	fmt.Printf("Downloading bootstrap dataset '%s'...\n", dataset)
 	generator := bootstrap.NewDatasetGenerator(bootstrapConfig)
-	if err := generator.GenerateDataset(); err != nil {
+	err := generator.GenerateDataset()
+	progressBar.Finish()
+	if err != nil {
 		logger.Error("Failed to generate bootstrap dataset", map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -544,10 +621,7 @@ func handleBootstrap(cfg *config.Config, dataset string, maxSize int64, bootstra
 // uploadBootstrapDataDirectly uploads files directly to NoiseFS before mounting
 func uploadBootstrapDataDirectly(srcDir string, cfg *config.Config, logger *logging.Logger) error {
 	// Create storage manager
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
-	}
+	storageConfig := cfg.StorageConfig()
 
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -621,7 +695,7 @@ func uploadBootstrapDataDirectly(srcDir string, cfg *config.Config, logger *logg
 		}
 
 		// Add to index
-		index.AddFile(relPath, descriptorCID, int64(len(content)))
+		index.AddFileWithMetadata(relPath, descriptorCID, int64(len(content)), fuse.NewFileMetadata(relPath, content))
 		fileCount++
 
 		if fileCount%10 == 0 {
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Exit codes of the compare command
+const (
+	compareExitOK         = 0
+	compareExitRegression = 1
+	compareExitUsage      = 2
+)
+
+// CompareThresholds are the largest changes in the worse direction, in
+// percent, that are tolerated before a metric counts as a regression
+type CompareThresholds struct {
+	Latency    float64 // Metrics ending in _ms, where lower is better
+	Throughput float64 // Throughput, operation rates and success rates
+	Alpha      float64 // Significance level when both reports have several runs
+}
+
+// MetricComparison is the change of one metric between two reports
+type MetricComparison struct {
+	Name        string
+	OldMean     float64
+	NewMean     float64
+	DeltaPct    float64 // Change relative to the old mean
+	PValue      float64 // Welch's t-test; NaN when either side has a single run
+	Direction   int     // 1 if higher is better, -1 if lower is better, 0 if informational
+	Significant bool
+	Regression  bool
+}
+
+// runCompare implements "benchmark compare old.json new.json" and returns
+// the process exit code
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	latency := fs.Float64("latency-threshold", 10, "Fail when a latency grows by more than this many percent")
+	throughput := fs.Float64("throughput-threshold", 10, "Fail when throughput or a rate drops by more than this many percent")
+	alpha := fs.Float64("alpha", 0.05, "Significance level for changes across multiple runs")
+	all := fs.Bool("all", false, "Show unchanged and informational metrics")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: benchmark compare [flags] old.json new.json")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Compares two JSON benchmark reports and exits with status 1 if a")
+		fmt.Fprintln(os.Stderr, "latency or throughput metric regressed beyond its threshold.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return compareExitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return compareExitUsage
+	}
+
+	oldReport, err := loadReport(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return compareExitUsage
+	}
+	newReport, err := loadReport(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return compareExitUsage
+	}
+
+	thresholds := CompareThresholds{Latency: *latency, Throughput: *throughput, Alpha: *alpha}
+	comparisons := CompareReports(oldReport, newReport, thresholds)
+	regressions := printComparisons(oldReport, newReport, comparisons, *all)
+	if regressions > 0 {
+		return compareExitRegression
+	}
+	return compareExitOK
+}
+
+// loadReport reads a report written with -format json
+func loadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	if len(report.Runs) == 0 {
+		return nil, fmt.Errorf("report %s has no runs", path)
+	}
+	return &report, nil
+}
+
+// CompareReports compares every metric present in both reports, sorted by name
+func CompareReports(oldReport, newReport *Report, thresholds CompareThresholds) []MetricComparison {
+	oldSamples := metricSamples(oldReport)
+	newSamples := metricSamples(newReport)
+
+	var comparisons []MetricComparison
+	for name, oldValues := range oldSamples {
+		newValues, ok := newSamples[name]
+		if !ok {
+			continue
+		}
+		comparisons = append(comparisons, compareMetric(name, oldValues, newValues, thresholds))
+	}
+
+	sort.Slice(comparisons, func(i, j int) bool {
+		return comparisons[i].Name < comparisons[j].Name
+	})
+	return comparisons
+}
+
+// metricSamples collects the value of each metric in every run
+func metricSamples(report *Report) map[string][]float64 {
+	samples := make(map[string][]float64)
+	for _, run := range report.Runs {
+		for name, value := range run.Metrics {
+			samples[name] = append(samples[name], value)
+		}
+	}
+	return samples
+}
+
+func compareMetric(name string, oldValues, newValues []float64, thresholds CompareThresholds) MetricComparison {
+	oldMean, oldVar := meanVariance(oldValues)
+	newMean, newVar := meanVariance(newValues)
+
+	c := MetricComparison{
+		Name:      name,
+		OldMean:   oldMean,
+		NewMean:   newMean,
+		PValue:    math.NaN(),
+		Direction: metricDirection(name),
+	}
+	switch {
+	case oldMean != 0:
+		c.DeltaPct = (newMean - oldMean) / math.Abs(oldMean) * 100
+	case newMean != 0:
+		c.DeltaPct = math.Inf(int(math.Copysign(1, newMean)))
+	}
+
+	// With a single run on either side there is no variance to test against,
+	// so the threshold alone decides
+	c.Significant = true
+	if len(oldValues) > 1 && len(newValues) > 1 {
+		c.PValue = welchTTest(oldMean, oldVar, len(oldValues), newMean, newVar, len(newValues))
+		c.Significant = c.PValue < thresholds.Alpha
+	}
+
+	threshold := thresholds.Throughput
+	if c.Direction < 0 {
+		threshold = thresholds.Latency
+	}
+	worse := -float64(c.Direction) * c.DeltaPct
+	c.Regression = c.Direction != 0 && c.Significant && worse > threshold
+	return c
+}
+
+// metricDirection reports whether higher (1) or lower (-1) values of a
+// metric are better; counts such as files and operations are informational
+func metricDirection(name string) int {
+	metric := name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		metric = name[i+1:]
+	}
+
+	switch {
+	case strings.HasSuffix(metric, "_ms"), metric == "errors":
+		return -1
+	case metric == "throughput_mbps", metric == "ops_per_sec", metric == "success_rate":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// meanVariance returns the mean and unbiased sample variance
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, squares / float64(len(values)-1)
+}
+
+// welchTTest returns the two-sided p-value of Welch's t-test for a
+// difference in means between two samples with unequal variances
+func welchTTest(mean1, var1 float64, n1 int, mean2, var2 float64, n2 int) float64 {
+	se1 := var1 / float64(n1)
+	se2 := var2 / float64(n2)
+	if se1+se2 == 0 {
+		if mean1 == mean2 {
+			return 1
+		}
+		return 0
+	}
+
+	t := (mean2 - mean1) / math.Sqrt(se1+se2)
+	df := (se1 + se2) * (se1 + se2) /
+		(se1*se1/float64(n1-1) + se2*se2/float64(n2-1))
+
+	// Two-sided tail of Student's t distribution via the incomplete beta function
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// regularizedIncompleteBeta computes I_x(a, b) with Lentz's continued fraction
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly only below the mean
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+	}
+	return front * betaContinuedFraction(a, b, x) / a
+}
+
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	result := d
+
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		for _, numerator := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + numerator*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + numerator/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			result *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return result
+}
+
+// printComparisons writes a table of changed metrics and returns the number of regressions
+func printComparisons(oldReport, newReport *Report, comparisons []MetricComparison, all bool) int {
+	fmt.Printf("Old: %s (%d runs, commit %s)\n", oldReport.Scenario.Name, len(oldReport.Runs), shortCommit(oldReport.Environment.Commit))
+	fmt.Printf("New: %s (%d runs, commit %s)\n", newReport.Scenario.Name, len(newReport.Runs), shortCommit(newReport.Environment.Commit))
+	if oldReport.Scenario.Name != newReport.Scenario.Name {
+		fmt.Println("⚠️  Reports come from different scenarios")
+	}
+	fmt.Println()
+
+	fmt.Printf("%-40s %12s %12s %9s %8s  %s\n", "METRIC", "OLD", "NEW", "DELTA", "P", "")
+	regressions := 0
+	for _, c := range comparisons {
+		if c.Regression {
+			regressions++
+		}
+		if !all && (c.Direction == 0 || c.DeltaPct == 0) {
+			continue
+		}
+
+		pValue := "-"
+		if !math.IsNaN(c.PValue) {
+			pValue = fmt.Sprintf("%.3f", c.PValue)
+		}
+		status := ""
+		switch {
+		case c.Regression:
+			status = "❌ regression"
+		case c.Direction != 0 && c.Significant && float64(c.Direction)*c.DeltaPct > 0:
+			status = "✅ improved"
+		case !c.Significant:
+			status = "~ not significant"
+		}
+
+		fmt.Printf("%-40s %12.3f %12.3f %+8.1f%% %8s  %s\n",
+			c.Name, c.OldMean, c.NewMean, c.DeltaPct, pValue, status)
+	}
+
+	fmt.Println()
+	if regressions > 0 {
+		fmt.Printf("❌ %d metric(s) regressed\n", regressions)
+	} else {
+		fmt.Println("✅ No regressions")
+	}
+	return regressions
+}
+
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "unknown"
+	}
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
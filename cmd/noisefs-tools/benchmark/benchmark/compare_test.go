@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWelchTTest(t *testing.T) {
+	// Equal variances and sizes: t = 2 with 10 degrees of freedom, p ≈ 0.0734
+	p := welchTTest(0, 1.2, 6, 2/math.Sqrt(2.5), 1.2, 6)
+	if math.Abs(p-0.0734) > 0.001 {
+		t.Errorf("Expected p ≈ 0.0734, got %.4f", p)
+	}
+
+	if p := welchTTest(5, 0, 3, 5, 0, 3); p != 1 {
+		t.Errorf("Identical constant samples should have p = 1, got %f", p)
+	}
+}
+
+func reportWithRuns(metric string, values ...float64) *Report {
+	report := &Report{Scenario: &Scenario{Name: "test"}}
+	for i, v := range values {
+		report.Runs = append(report.Runs, RunResult{Run: i + 1, Metrics: map[string]float64{metric: v}})
+	}
+	return report
+}
+
+func TestCompareReports(t *testing.T) {
+	thresholds := CompareThresholds{Latency: 10, Throughput: 10, Alpha: 0.05}
+
+	tests := []struct {
+		name       string
+		metric     string
+		old, new   []float64
+		regression bool
+	}{
+		{"latency up consistently", "single.upload_latency_ms", []float64{100, 101, 99, 100}, []float64{130, 131, 129, 130}, true},
+		{"latency down", "single.upload_latency_ms", []float64{100, 101, 99}, []float64{80, 81, 79}, false},
+		{"latency up within threshold", "single.upload_latency_ms", []float64{100, 101, 99}, []float64{105, 106, 104}, false},
+		{"latency up but noisy", "single.upload_latency_ms", []float64{50, 150, 100}, []float64{60, 200, 130}, false},
+		{"throughput down", "single.throughput_mbps", []float64{50, 51, 49}, []float64{30, 31, 29}, true},
+		{"single runs use threshold only", "fuse_metadata.ops_per_sec", []float64{1000}, []float64{800}, true},
+		{"informational metric", "single.files", []float64{20}, []float64{5}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparisons := CompareReports(reportWithRuns(tt.metric, tt.old...), reportWithRuns(tt.metric, tt.new...), thresholds)
+			if len(comparisons) != 1 {
+				t.Fatalf("Expected one comparison, got %d", len(comparisons))
+			}
+			if comparisons[0].Regression != tt.regression {
+				t.Errorf("Expected regression=%v, got %+v", tt.regression, comparisons[0])
+			}
+		})
+	}
+}
+
+func TestRunCompareExitCode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, report *Report) string {
+		data, _ := json.Marshal(report)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	oldPath := write("old.json", reportWithRuns("single.download_latency_ms", 10, 10.5, 9.5))
+	samePath := write("same.json", reportWithRuns("single.download_latency_ms", 10, 10.2, 9.8))
+	slowPath := write("slow.json", reportWithRuns("single.download_latency_ms", 20, 20.5, 19.5))
+
+	if code := runCompare([]string{oldPath, samePath}); code != compareExitOK {
+		t.Errorf("Expected exit %d for unchanged results, got %d", compareExitOK, code)
+	}
+	if code := runCompare([]string{oldPath, slowPath}); code != compareExitRegression {
+		t.Errorf("Expected exit %d for a regression, got %d", compareExitRegression, code)
+	}
+	if code := runCompare([]string{oldPath}); code != compareExitUsage {
+		t.Errorf("Expected exit %d for missing argument, got %d", compareExitUsage, code)
+	}
+}
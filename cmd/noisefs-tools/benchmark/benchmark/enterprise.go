@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	benchmarks "github.com/TheEntropyCollective/noisefs/tests/benchmarks"
+)
+
+// defaultEnterpriseDuration is used when the scenario has no time limit
+const defaultEnterpriseDuration = 30 * time.Second
+
+// enterpriseOptions holds the settings specific to -enterprise mode
+type enterpriseOptions struct {
+	Type        string // all, basic, sequential, random, fuse or metadata
+	MountPath   string // NoiseFS FUSE mount for fuse and metadata benchmarks
+	BasePath    string // Scratch directory for file system benchmarks
+	Concurrency int
+}
+
+// runEnterpriseBenchmark runs the enterprise-benchmark suites: file system
+// workloads in a scratch directory and, given a mount path, the same
+// workloads through a NoiseFS FUSE mount
+func runEnterpriseBenchmark(cfg *config.Config, scenario *Scenario, opts enterpriseOptions, verbose bool) []RunResult {
+	if err := logging.InitFromConfig(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output, cfg.Logging.File); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+	if err := logging.InitComponentLevelsFromConfig(cfg.Logging.Components, cfg.Logging.SampleInitial, cfg.Logging.SampleThereafter); err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+	logger := logging.GetGlobalLogger().WithComponent("noisefs-benchmark")
+
+	if opts.MountPath == "" {
+		opts.MountPath = cfg.FUSE.MountPath
+	}
+	if (opts.Type == "fuse" || opts.Type == "metadata") && opts.MountPath == "" {
+		log.Fatalf("Mount path required for %s benchmarks (use -mount or fuse.mount_path)", opts.Type)
+	}
+
+	duration := time.Duration(scenario.Duration)
+	if duration == 0 {
+		duration = defaultEnterpriseDuration
+	}
+	benchConfig := &benchmarks.BenchmarkConfig{
+		Duration:    duration,
+		Concurrency: opts.Concurrency,
+		FileSize:    int64(scenario.Mix[0].bytes),
+		BlockSize:   4096,
+		FileCount:   scenario.Files,
+		ReadRatio:   0.7,
+		WarmupTime:  5 * time.Second,
+	}
+
+	fmt.Printf("Benchmark type: %s\n", opts.Type)
+	fmt.Printf("Duration per benchmark: %v\n", duration)
+	fmt.Printf("Concurrency: %d\n", opts.Concurrency)
+	fmt.Printf("File size: %d bytes\n", benchConfig.FileSize)
+	if opts.MountPath != "" {
+		fmt.Printf("FUSE mount: %s\n", opts.MountPath)
+	}
+	fmt.Println()
+
+	if err := os.MkdirAll(opts.BasePath, 0755); err != nil {
+		log.Fatalf("Failed to create base path: %v", err)
+	}
+	defer os.RemoveAll(opts.BasePath)
+
+	var runs []RunResult
+	for run := 1; run <= scenario.Runs; run++ {
+		if scenario.Runs > 1 {
+			fmt.Printf("\n🔁 Run %d/%d\n", run, scenario.Runs)
+		}
+		start := time.Now()
+		results, err := runEnterpriseSuites(opts, benchConfig, logger)
+		if err != nil {
+			log.Fatalf("Benchmark failed: %v", err)
+		}
+
+		printEnterpriseResults(results)
+		runs = append(runs, newEnterpriseRunResult(run, time.Since(start), results))
+	}
+	return runs
+}
+
+// runEnterpriseSuites runs the suites selected by opts.Type
+func runEnterpriseSuites(opts enterpriseOptions, config *benchmarks.BenchmarkConfig, logger *logging.Logger) ([]benchmarks.BenchmarkResult, error) {
+	var results []benchmarks.BenchmarkResult
+
+	fileSuite := func(name string, fn func(*benchmarks.BenchmarkSuite, *benchmarks.BenchmarkConfig) error) error {
+		fmt.Printf("📊 %s\n", name)
+		suite := benchmarks.NewBenchmarkSuite(name, opts.BasePath, logger)
+		if err := fn(suite, config); err != nil {
+			return fmt.Errorf("%s benchmarks failed: %w", strings.ToLower(name), err)
+		}
+		results = append(results, suite.GetResults()...)
+		return nil
+	}
+	fuseSuite := func(name string, fn func(*benchmarks.FUSEBenchmarkSuite, *benchmarks.BenchmarkConfig) error) error {
+		fmt.Printf("📊 %s (%s)\n", name, opts.MountPath)
+		suite := benchmarks.NewFUSEBenchmarkSuite(opts.MountPath, logger)
+		if err := fn(suite, config); err != nil {
+			return fmt.Errorf("%s benchmarks failed: %w", strings.ToLower(name), err)
+		}
+		results = append(results, suite.GetResults()...)
+		return nil
+	}
+
+	var err error
+	switch opts.Type {
+	case "basic":
+		err = fileSuite("Basic File Operations", (*benchmarks.BenchmarkSuite).BenchmarkFileOperations)
+	case "sequential":
+		err = fileSuite("Sequential Operations", (*benchmarks.BenchmarkSuite).BenchmarkSequentialRead)
+	case "random":
+		err = fileSuite("Random Operations", (*benchmarks.BenchmarkSuite).BenchmarkRandomRead)
+	case "fuse":
+		err = fuseSuite("FUSE Operations", (*benchmarks.FUSEBenchmarkSuite).RunFullFUSEBenchmarkSuite)
+	case "metadata":
+		err = fuseSuite("FUSE Metadata", (*benchmarks.FUSEBenchmarkSuite).BenchmarkFUSEMetadata)
+	case "all":
+		if err = fileSuite("Basic File Operations", (*benchmarks.BenchmarkSuite).BenchmarkFileOperations); err != nil {
+			break
+		}
+		if err = fileSuite("Sequential Operations", (*benchmarks.BenchmarkSuite).BenchmarkSequentialRead); err != nil {
+			break
+		}
+		if err = fileSuite("Random Operations", (*benchmarks.BenchmarkSuite).BenchmarkRandomRead); err != nil {
+			break
+		}
+		// A missing or broken mount doesn't invalidate the file system results
+		if opts.MountPath != "" {
+			if fuseErr := fuseSuite("FUSE Operations", (*benchmarks.FUSEBenchmarkSuite).RunFullFUSEBenchmarkSuite); fuseErr != nil {
+				fmt.Printf("  ⚠️  %v\n", fuseErr)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid benchmark type %q (use all, basic, sequential, random, fuse or metadata)", opts.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// newEnterpriseRunResult summarizes suite results. Metric names are
+// "<benchmark>.<metric>", e.g. "fuse_metadata.ops_per_sec".
+func newEnterpriseRunResult(run int, elapsed time.Duration, results []benchmarks.BenchmarkResult) RunResult {
+	result := RunResult{
+		Run:        run,
+		Elapsed:    elapsed,
+		Metrics:    make(map[string]float64),
+		Benchmarks: results,
+	}
+
+	for _, r := range results {
+		prefix := metricName(r.Name)
+		result.Metrics[prefix+".operations"] = float64(r.Operations)
+		result.Metrics[prefix+".ops_per_sec"] = r.OperationsPerSec
+		result.Metrics[prefix+".latency_avg_ms"] = durationMS(r.LatencyAvg)
+		result.Metrics[prefix+".latency_p95_ms"] = durationMS(r.LatencyP95)
+		result.Metrics[prefix+".latency_p99_ms"] = durationMS(r.LatencyP99)
+		result.Metrics[prefix+".errors"] = float64(r.ErrorCount)
+		if r.BytesProcessed > 0 {
+			result.Metrics[prefix+".throughput_mbps"] = r.ThroughputMBps
+		}
+	}
+	return result
+}
+
+// metricName turns a benchmark name like "FUSE Directory Ops" into "fuse_directory_ops"
+func metricName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+func printEnterpriseResults(results []benchmarks.BenchmarkResult) {
+	fmt.Println("\n🎉 Enterprise Benchmark Results")
+	fmt.Println("===============================")
+
+	for _, result := range results {
+		fmt.Printf("%s:\n", result.Name)
+		fmt.Printf("  Operations: %d (%.2f/sec)\n", result.Operations, result.OperationsPerSec)
+		if result.BytesProcessed > 0 {
+			fmt.Printf("  Throughput: %.2f MB/s\n", result.ThroughputMBps)
+		}
+		fmt.Printf("  Latency (avg/p95/p99): %v / %v / %v\n",
+			result.LatencyAvg, result.LatencyP95, result.LatencyP99)
+		if result.ErrorCount > 0 {
+			fmt.Printf("  Errors: %d\n", result.ErrorCount)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/client"
@@ -19,12 +20,27 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+
 	var (
-		nodes     = flag.Int("nodes", 1, "Number of IPFS nodes (1=single-node, 2+=multi-node)")
-		fileSize  = flag.Int("file-size", 65536, "Test file size in bytes")
-		numFiles  = flag.Int("files", 10, "Number of files to test")
-		verbose   = flag.Bool("verbose", false, "Verbose output")
-		help      = flag.Bool("help", false, "Show help")
+		configFile   = flag.String("config", "", "Configuration file path (IPFS endpoint and cache size)")
+		scenarioFile = flag.String("scenario", "", "YAML scenario file (overrides -nodes, -file-size, -files and -runs)")
+		nodes        = flag.Int("nodes", 1, "Number of IPFS nodes (1=single-node, 2+=multi-node)")
+		fileSize     = flag.Int("file-size", 65536, "Test file size in bytes")
+		numFiles     = flag.Int("files", 10, "Number of files to test")
+		runs         = flag.Int("runs", 1, "Number of times to repeat the benchmark")
+		output       = flag.String("output", "", "Write machine-readable results to this file (- for stdout)")
+		format       = flag.String("format", "json", "Output format for -output: json or csv (csv appends)")
+		docker       = flag.Bool("docker", false, "Run against a Docker Compose IPFS network instead of local IPFS")
+		enterprise   = flag.Bool("enterprise", false, "Run the enterprise file system and FUSE benchmark suites")
+		benchType    = flag.String("type", "all", "Enterprise benchmark type: all, basic, sequential, random, fuse, metadata")
+		mountPath    = flag.String("mount", "", "Mount point for enterprise FUSE benchmarks (default: fuse.mount_path)")
+		concurrency  = flag.Int("concurrency", 10, "Concurrent workers for enterprise benchmarks")
+		basePath     = flag.String("base-path", "/tmp/noisefs-benchmark", "Scratch directory for enterprise benchmarks")
+		verbose      = flag.Bool("verbose", false, "Verbose output")
+		help         = flag.Bool("help", false, "Show help")
 	)
 	flag.Parse()
 
@@ -37,33 +53,107 @@ func main() {
 		fmt.Println("  go run cmd/benchmarks/benchmark/main.go                    # Quick single-node test")
 		fmt.Println("  go run cmd/benchmarks/benchmark/main.go -nodes 3 -verbose  # Multi-node cluster test")
 		fmt.Println("  go run cmd/benchmarks/benchmark/main.go -files 50          # Stress test")
+		fmt.Println("  go run cmd/benchmarks/benchmark/main.go -scenario ci.yaml -output results.json")
+		fmt.Println("  go run cmd/benchmarks/benchmark/main.go -docker -nodes 3   # Isolated Docker IPFS cluster")
+		fmt.Println("  go run cmd/benchmarks/benchmark/main.go -enterprise -type fuse -mount /mnt/noisefs")
+		fmt.Println("  go run cmd/benchmarks/benchmark/main.go compare old.json new.json")
 		fmt.Println()
 		flag.PrintDefaults()
 		return
 	}
 
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	var scenario *Scenario
+	if *scenarioFile != "" {
+		scenario, err = LoadScenario(*scenarioFile)
+	} else {
+		scenario, err = scenarioFromFlags(*nodes, *fileSize, *numFiles, *runs)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load scenario: %v", err)
+	}
+	if *output != "" && *format != "json" && *format != "csv" {
+		log.Fatalf("Unknown output format %q (use json or csv)", *format)
+	}
+	if *docker && *enterprise {
+		log.Fatalf("-docker and -enterprise cannot be combined")
+	}
+	if (*docker || *enterprise) && scenario.Faults != nil {
+		fmt.Println("⚠️  Scenario faults only apply to the local IPFS modes and are ignored")
+	}
+
 	fmt.Println("🚀 NoiseFS Performance Benchmark")
 	fmt.Println("=================================")
-	
-	if *nodes == 1 {
+	if *scenarioFile != "" {
+		fmt.Printf("Scenario: %s (%d runs)\n", scenario.Name, scenario.Runs)
+	}
+
+	report := &Report{
+		Scenario:    scenario,
+		Environment: collectEnvironment(cfg.IPFS.APIEndpoint, cfg.Cache.BlockCacheSize),
+	}
+
+	if *docker {
+		fmt.Printf("Mode: Docker multi-node testing (%d nodes)\n", scenario.Nodes)
+		report.Runs = runDockerBenchmark(cfg, scenario, *verbose)
+	} else if *enterprise {
+		fmt.Println("Mode: Enterprise benchmark suites")
+		report.Runs = runEnterpriseBenchmark(cfg, scenario, enterpriseOptions{
+			Type:        *benchType,
+			MountPath:   *mountPath,
+			BasePath:    *basePath,
+			Concurrency: *concurrency,
+		}, *verbose)
+	} else if scenario.Nodes == 1 {
 		fmt.Println("Mode: Single-node testing")
-		runSingleNodeBenchmark(*fileSize, *numFiles, *verbose)
-	} else if *nodes == 2 {
-		fmt.Printf("Mode: Hybrid multi-node testing (existing + %d new nodes)\n", *nodes-1)
-		runHybridMultiNodeBenchmark(*nodes-1, *fileSize, *numFiles, *verbose)
+		report.Runs = runSingleNodeBenchmark(cfg, scenario, *verbose)
+	} else if scenario.Nodes == 2 {
+		fmt.Printf("Mode: Hybrid multi-node testing (existing + %d new nodes)\n", scenario.Nodes-1)
+		report.Runs = runHybridMultiNodeBenchmark(cfg, scenario, *verbose)
 	} else {
-		fmt.Printf("Mode: Full multi-node testing (%d nodes)\n", *nodes)
-		runMultiNodeBenchmark(*nodes, *fileSize, *numFiles, *verbose)
+		fmt.Printf("Mode: Full multi-node testing (%d nodes)\n", scenario.Nodes)
+		report.Runs = runMultiNodeBenchmark(cfg, scenario, *verbose)
+	}
+
+	if *output != "" {
+		if err := report.Write(*output, *format); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+		if *output != "-" {
+			fmt.Printf("\n📄 Results written to %s\n", *output)
+		}
 	}
 }
 
-func runSingleNodeBenchmark(fileSize, numFiles int, verbose bool) {
-	fmt.Printf("File size: %d bytes\n", fileSize)
-	fmt.Printf("Number of files: %d\n", numFiles)
+// printWorkload describes the files a scenario uploads each run
+func printWorkload(scenario *Scenario) {
+	for _, entry := range scenario.Mix {
+		if len(scenario.Mix) == 1 {
+			fmt.Printf("File size: %d bytes\n", entry.bytes)
+		} else {
+			fmt.Printf("File size: %d bytes (weight %d)\n", entry.bytes, entry.Weight)
+		}
+	}
+	fmt.Printf("Number of files: %d\n", scenario.Files)
+	if scenario.Runs > 1 {
+		fmt.Printf("Runs: %d\n", scenario.Runs)
+	}
+	if f := scenario.Faults; f != nil {
+		fmt.Printf("Injected faults: %.1f%% errors, %.1f%% block loss, %.1f%% timeouts, +%v latency\n",
+			f.ErrorRate*100, f.LossRate*100, f.TimeoutRate*100, time.Duration(f.Latency))
+	}
 	fmt.Println()
+}
+
+func runSingleNodeBenchmark(cfg *config.Config, scenario *Scenario, verbose bool) []RunResult {
+	printWorkload(scenario)
 
 	// Check if IPFS is running
-	ipfsAPI := "127.0.0.1:5001"
+	ipfsAPI := cfg.IPFS.APIEndpoint
 	if !isIPFSRunning(ipfsAPI) {
 		fmt.Println("❌ IPFS is not running. Please start IPFS first:")
 		fmt.Println("   ipfs daemon")
@@ -86,30 +176,44 @@ func runSingleNodeBenchmark(fileSize, numFiles int, verbose bool) {
 		log.Fatalf("Failed to start storage manager: %v", err)
 	}
 	defer storageManager.Stop(context.Background())
+	if err := scenario.injectFaults(storageManager); err != nil {
+		log.Fatalf("%v", err)
+	}
 
-	cache := cache.NewMemoryCache(100)
+	cache := cache.NewMemoryCache(cfg.Cache.BlockCacheSize)
 	noiseClient, err := noisefs.NewClient(storageManager, cache)
 	if err != nil {
 		log.Fatalf("Failed to create NoiseFS client: %v", err)
 	}
 
 	fmt.Println("✅ Connected to IPFS")
-	fmt.Printf("📊 Running performance test with %d files...\n", numFiles)
-	
-	// Run tests
-	results := runTests(noiseClient, fileSize, numFiles, verbose, "Node 1")
-	
-	// Print results
-	printSingleNodeResults(results, time.Duration(0))
+
+	var runs []RunResult
+	for run := 1; run <= scenario.Runs; run++ {
+		if scenario.Runs > 1 {
+			fmt.Printf("\n🔁 Run %d/%d\n", run, scenario.Runs)
+		}
+		fmt.Printf("📊 Running performance test with %d files...\n", scenario.Files)
+
+		// Run tests
+		ctx, cancel := scenario.runContext()
+		start := time.Now()
+		results := runTests(ctx, noiseClient, scenario.FileSizes(run), verbose, "Node 1")
+		cancel()
+
+		// Print results
+		printSingleNodeResults(results, time.Duration(0))
+		runs = append(runs, newRunResult(run, time.Since(start), map[string][]TestResult{"single": results}))
+	}
+	return runs
 }
 
-func runHybridMultiNodeBenchmark(newNodeCount, fileSize, numFiles int, verbose bool) {
-	fmt.Printf("File size: %d bytes\n", fileSize)
-	fmt.Printf("Number of files: %d\n", numFiles)
-	fmt.Println()
+func runHybridMultiNodeBenchmark(cfg *config.Config, scenario *Scenario, verbose bool) []RunResult {
+	newNodeCount := scenario.Nodes - 1
+	printWorkload(scenario)
 
 	// Check existing IPFS
-	ipfsAPI := "127.0.0.1:5001"
+	ipfsAPI := cfg.IPFS.APIEndpoint
 	if !isIPFSRunning(ipfsAPI) {
 		fmt.Println("❌ IPFS is not running. Please start IPFS first:")
 		fmt.Println("   ipfs daemon")
@@ -132,8 +236,11 @@ func runHybridMultiNodeBenchmark(newNodeCount, fileSize, numFiles int, verbose b
 		log.Fatalf("Failed to start storage manager: %v", err)
 	}
 	defer storageManager.Stop(context.Background())
+	if err := scenario.injectFaults(storageManager); err != nil {
+		log.Fatalf("%v", err)
+	}
 
-	existingCache := cache.NewMemoryCache(100)
+	existingCache := cache.NewMemoryCache(cfg.Cache.BlockCacheSize)
 	existingClient, err := noisefs.NewClient(storageManager, existingCache)
 	if err != nil {
 		log.Fatalf("Failed to create NoiseFS client: %v", err)
@@ -150,8 +257,7 @@ func runHybridMultiNodeBenchmark(newNodeCount, fileSize, numFiles int, verbose b
 	if err != nil {
 		log.Printf("Warning: Failed to start additional nodes: %v", err)
 		fmt.Println("Falling back to single-node testing...")
-		runSingleNodeBenchmark(fileSize, numFiles, verbose)
-		return
+		return runSingleNodeBenchmark(cfg, scenario, verbose)
 	}
 
 	// Create clients for new nodes
@@ -177,8 +283,11 @@ func runHybridMultiNodeBenchmark(newNodeCount, fileSize, numFiles int, verbose b
 			continue
 		}
 		defer storageManager.Stop(context.Background())
+		if err := scenario.injectFaults(storageManager); err != nil {
+			log.Fatalf("%v", err)
+		}
 
-		nodeCache := cache.NewMemoryCache(100)
+		nodeCache := cache.NewMemoryCache(cfg.Cache.BlockCacheSize)
 		noiseClient, err := noisefs.NewClient(storageManager, nodeCache)
 		if err != nil {
 			fmt.Printf("  ⚠️  Failed to create NoiseFS client for node %d: %v\n", node.ID, err)
@@ -194,24 +303,40 @@ func runHybridMultiNodeBenchmark(newNodeCount, fileSize, numFiles int, verbose b
 	totalNodes := len(clients)
 	fmt.Printf("✅ %d total nodes ready (1 existing + %d new)\n", totalNodes, totalNodes-1)
 
-	// Run tests
-	fmt.Println("\n📊 Phase 1: Single Node Performance")
-	singleResults := runTests(clients[0], fileSize, numFiles, verbose, "Existing Node")
+	setupTime := time.Since(startTime)
 
-	var crossResults []TestResult
-	if len(clients) > 1 {
-		fmt.Println("\n📊 Phase 2: Cross-Node Replication")
-		crossResults = runCrossNodeTests(clients, fileSize, numFiles/2, verbose)
-	}
+	var runs []RunResult
+	for run := 1; run <= scenario.Runs; run++ {
+		if scenario.Runs > 1 {
+			fmt.Printf("\n🔁 Run %d/%d\n", run, scenario.Runs)
+		}
+		ctx, cancel := scenario.runContext()
+		start := time.Now()
+		sizes := scenario.FileSizes(run)
+		phases := make(map[string][]TestResult)
+
+		// Run tests
+		fmt.Println("\n📊 Phase 1: Single Node Performance")
+		singleResults := runTests(ctx, clients[0], sizes, verbose, "Existing Node")
+		phases["single"] = singleResults
+
+		var crossResults []TestResult
+		if len(clients) > 1 {
+			fmt.Println("\n📊 Phase 2: Cross-Node Replication")
+			crossResults = runCrossNodeTests(ctx, clients, sizes[:len(sizes)/2], verbose)
+			phases["cross_node"] = crossResults
+		}
+		cancel()
 
-	setupTime := time.Since(startTime)
-	printMultiNodeResults(singleResults, crossResults, setupTime, totalNodes)
+		printMultiNodeResults(singleResults, crossResults, setupTime, totalNodes)
+		runs = append(runs, newRunResult(run, time.Since(start), phases))
+	}
+	return runs
 }
 
-func runMultiNodeBenchmark(nodeCount, fileSize, numFiles int, verbose bool) {
-	fmt.Printf("File size: %d bytes\n", fileSize)
-	fmt.Printf("Number of files: %d\n", numFiles)
-	fmt.Println()
+func runMultiNodeBenchmark(cfg *config.Config, scenario *Scenario, verbose bool) []RunResult {
+	nodeCount := scenario.Nodes
+	printWorkload(scenario)
 
 	// Create and start multi-node launcher
 	launcher := fixtures.NewMultiNodeLauncher(nodeCount)
@@ -262,8 +387,11 @@ func runMultiNodeBenchmark(nodeCount, fileSize, numFiles int, verbose bool) {
 			continue
 		}
 		defer storageManager.Stop(context.Background())
+		if err := scenario.injectFaults(storageManager); err != nil {
+			log.Fatalf("%v", err)
+		}
 
-		nodeCache := cache.NewMemoryCache(100)
+		nodeCache := cache.NewMemoryCache(cfg.Cache.BlockCacheSize)
 		noiseClient, err := noisefs.NewClient(storageManager, nodeCache)
 		if err != nil {
 			fmt.Printf("  ⚠️  Failed to create NoiseFS client for node %d: %v\n", node.ID, err)
@@ -282,42 +410,65 @@ func runMultiNodeBenchmark(nodeCount, fileSize, numFiles int, verbose bool) {
 
 	fmt.Printf("✅ %d nodes ready\n", len(clients))
 
-	// Run single-node test
-	fmt.Println("\n📊 Phase 1: Single Node Performance")
-	singleResults := runTests(clients[0], fileSize, numFiles, verbose, "Node 1")
+	setupTime := time.Since(startTime)
+
+	var runs []RunResult
+	for run := 1; run <= scenario.Runs; run++ {
+		if scenario.Runs > 1 {
+			fmt.Printf("\n🔁 Run %d/%d\n", run, scenario.Runs)
+		}
+		ctx, cancel := scenario.runContext()
+		start := time.Now()
+		sizes := scenario.FileSizes(run)
+		phases := make(map[string][]TestResult)
+
+		// Run single-node test
+		fmt.Println("\n📊 Phase 1: Single Node Performance")
+		singleResults := runTests(ctx, clients[0], sizes, verbose, "Node 1")
+		phases["single"] = singleResults
+
+		// Run cross-node test if multiple nodes
+		var crossResults []TestResult
+		if len(clients) > 1 {
+			fmt.Println("\n📊 Phase 2: Cross-Node Replication")
+			crossResults = runCrossNodeTests(ctx, clients, sizes[:len(sizes)/2], verbose)
+			phases["cross_node"] = crossResults
+
+			// Run concurrent test
+			fmt.Println("\n📊 Phase 3: Concurrent Multi-Node Testing")
+			concurrentResults := runConcurrentTests(ctx, clients, sizes, verbose)
+			phases["concurrent"] = concurrentResults
+			crossResults = append(crossResults, concurrentResults...)
+		}
+		cancel()
 
-	// Run cross-node test if multiple nodes
-	var crossResults []TestResult
-	if len(clients) > 1 {
-		fmt.Println("\n📊 Phase 2: Cross-Node Replication")
-		crossResults = runCrossNodeTests(clients, fileSize, numFiles/2, verbose)
-		
-		// Run concurrent test
-		fmt.Println("\n📊 Phase 3: Concurrent Multi-Node Testing")
-		concurrentResults := runConcurrentTests(clients, fileSize, numFiles, verbose)
-		crossResults = append(crossResults, concurrentResults...)
+		// Print results
+		printMultiNodeResults(singleResults, crossResults, setupTime, len(clients))
+		runs = append(runs, newRunResult(run, time.Since(start), phases))
 	}
-
-	setupTime := time.Since(startTime)
-	
-	// Print results
-	printMultiNodeResults(singleResults, crossResults, setupTime, len(clients))
+	return runs
 }
 
 type TestResult struct {
-	TestName        string
-	FileSize        int
-	UploadLatency   time.Duration
-	DownloadLatency time.Duration
-	Success         bool
-	CID             string
-	NodeInfo        string
+	TestName        string        `json:"test_name"`
+	FileSize        int           `json:"file_size"`
+	UploadLatency   time.Duration `json:"upload_latency_ns"`
+	DownloadLatency time.Duration `json:"download_latency_ns"`
+	Success         bool          `json:"success"`
+	CID             string        `json:"cid,omitempty"`
+	NodeInfo        string        `json:"node_info,omitempty"`
 }
 
-func runTests(client *noisefs.Client, fileSize, numFiles int, verbose bool, nodeInfo string) []TestResult {
-	results := make([]TestResult, 0, numFiles)
+// runTests uploads and downloads one file per size until ctx expires
+func runTests(ctx context.Context, client *noisefs.Client, sizes []int, verbose bool, nodeInfo string) []TestResult {
+	results := make([]TestResult, 0, len(sizes))
 	
-	for i := 0; i < numFiles; i++ {
+	for i, fileSize := range sizes {
+		if ctx.Err() != nil {
+			fmt.Printf("  ⏱️  Time limit reached after %d/%d files\n", i, len(sizes))
+			break
+		}
+
 		testData := make([]byte, fileSize)
 		rand.Read(testData)
 
@@ -335,7 +486,7 @@ func runTests(client *noisefs.Client, fileSize, numFiles int, verbose bool, node
 
 		// Upload
 		uploadStart := time.Now()
-		cid, err := client.StoreBlockWithCache(context.Background(), block)
+		cid, err := client.StoreBlockWithCache(ctx, block)
 		uploadLatency := time.Since(uploadStart)
 
 		if err != nil {
@@ -348,7 +499,7 @@ func runTests(client *noisefs.Client, fileSize, numFiles int, verbose bool, node
 
 		// Download
 		downloadStart := time.Now()
-		retrievedBlock, err := client.RetrieveBlockWithCache(context.Background(), cid)
+		retrievedBlock, err := client.RetrieveBlockWithCache(ctx, cid)
 		downloadLatency := time.Since(downloadStart)
 
 		if err != nil {
@@ -382,10 +533,16 @@ func runTests(client *noisefs.Client, fileSize, numFiles int, verbose bool, node
 	return results
 }
 
-func runCrossNodeTests(clients []*noisefs.Client, fileSize, numTests int, verbose bool) []TestResult {
-	results := make([]TestResult, 0, numTests)
+// runCrossNodeTests uploads each file to one node and downloads it from the next
+func runCrossNodeTests(ctx context.Context, clients []*noisefs.Client, sizes []int, verbose bool) []TestResult {
+	results := make([]TestResult, 0, len(sizes))
 	
-	for i := 0; i < numTests; i++ {
+	for i, fileSize := range sizes {
+		if ctx.Err() != nil {
+			fmt.Printf("  ⏱️  Time limit reached after %d/%d files\n", i, len(sizes))
+			break
+		}
+
 		sourceIdx := i % len(clients)
 		targetIdx := (i + 1) % len(clients)
 
@@ -406,7 +563,7 @@ func runCrossNodeTests(clients []*noisefs.Client, fileSize, numTests int, verbos
 			continue
 		}
 
-		cid, err := clients[sourceIdx].StoreBlockWithCache(context.Background(), block)
+		cid, err := clients[sourceIdx].StoreBlockWithCache(ctx, block)
 		if err != nil {
 			if verbose {
 				fmt.Printf("    ❌ Upload to source failed: %v\n", err)
@@ -420,7 +577,7 @@ func runCrossNodeTests(clients []*noisefs.Client, fileSize, numTests int, verbos
 
 		// Download from target
 		downloadStart := time.Now()
-		retrievedBlock, err := clients[targetIdx].RetrieveBlockWithCache(context.Background(), cid)
+		retrievedBlock, err := clients[targetIdx].RetrieveBlockWithCache(ctx, cid)
 		downloadLatency := time.Since(downloadStart)
 
 		if err != nil {
@@ -452,15 +609,18 @@ func runCrossNodeTests(clients []*noisefs.Client, fileSize, numTests int, verbos
 	return results
 }
 
-func runConcurrentTests(clients []*noisefs.Client, fileSize, numFiles int, verbose bool) []TestResult {
+// runConcurrentTests uploads and downloads all files at once, spread over the nodes
+func runConcurrentTests(ctx context.Context, clients []*noisefs.Client, sizes []int, verbose bool) []TestResult {
 	fmt.Printf("  Running concurrent operations across %d nodes...\n", len(clients))
 	
+	numFiles := len(sizes)
 	resultChan := make(chan TestResult, numFiles)
-	doneChan := make(chan struct{})
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
 
 	// Launch concurrent uploads
-	for i := 0; i < numFiles; i++ {
-		go func(fileIndex int) {
+	for i, fileSize := range sizes {
+		go func(fileIndex, fileSize int) {
 			defer func() {
 				if r := recover(); r != nil {
 					log.Printf("Goroutine %d panicked: %v", fileIndex, r)
@@ -483,7 +643,7 @@ func runConcurrentTests(clients []*noisefs.Client, fileSize, numFiles int, verbo
 
 			// Upload
 			uploadStart := time.Now()
-			cid, err := clients[clientIdx].StoreBlockWithCache(context.Background(), block)
+			cid, err := clients[clientIdx].StoreBlockWithCache(ctx, block)
 			uploadLatency := time.Since(uploadStart)
 
 			if err != nil {
@@ -493,7 +653,7 @@ func runConcurrentTests(clients []*noisefs.Client, fileSize, numFiles int, verbo
 
 			// Download
 			downloadStart := time.Now()
-			retrievedBlock, err := clients[clientIdx].RetrieveBlockWithCache(context.Background(), cid)
+			retrievedBlock, err := clients[clientIdx].RetrieveBlockWithCache(ctx, cid)
 			downloadLatency := time.Since(downloadStart)
 
 			if err != nil {
@@ -514,15 +674,9 @@ func runConcurrentTests(clients []*noisefs.Client, fileSize, numFiles int, verbo
 				NodeInfo:        nodeInfo,
 			}
 			resultChan <- result
-		}(i)
+		}(i, fileSize)
 	}
 
-	// Timeout goroutine
-	go func() {
-		time.Sleep(2 * time.Minute)
-		close(doneChan)
-	}()
-
 	// Collect results
 	results := make([]TestResult, 0, numFiles)
 	collected := 0
@@ -539,7 +693,7 @@ func runConcurrentTests(clients []*noisefs.Client, fileSize, numFiles int, verbo
 						collected, result.NodeInfo, result.UploadLatency, result.DownloadLatency)
 				}
 			}
-		case <-doneChan:
+		case <-ctx.Done():
 			fmt.Printf("    Timeout reached, collected %d/%d results\n", collected, numFiles)
 			goto done
 		}
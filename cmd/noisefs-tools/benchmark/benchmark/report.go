@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"time"
+
+	benchmarks "github.com/TheEntropyCollective/noisefs/tests/benchmarks"
+)
+
+// Report is the machine-readable result of a benchmark, suitable for
+// tracking performance across commits in CI
+type Report struct {
+	Scenario    *Scenario   `json:"scenario"`
+	Environment Environment `json:"environment"`
+	Runs        []RunResult `json:"runs"`
+}
+
+// Environment records where and on what a benchmark ran
+type Environment struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Hostname     string    `json:"hostname"`
+	OS           string    `json:"os"`
+	Arch         string    `json:"arch"`
+	NumCPU       int       `json:"num_cpu"`
+	GoVersion    string    `json:"go_version"`
+	Commit       string    `json:"commit,omitempty"`
+	Modified     bool      `json:"modified,omitempty"` // Built from a dirty tree
+	IPFSEndpoint string    `json:"ipfs_endpoint"`
+	CacheSize    int       `json:"cache_size"`
+}
+
+// RunResult holds one repetition of a scenario
+type RunResult struct {
+	Run        int                          `json:"run"`
+	Elapsed    time.Duration                `json:"elapsed_ns"`
+	Metrics    map[string]float64           `json:"metrics"`
+	Results    []TestResult                 `json:"results"`
+	Benchmarks []benchmarks.BenchmarkResult `json:"benchmarks,omitempty"` // Enterprise suites
+}
+
+// collectEnvironment describes the current machine and build
+func collectEnvironment(ipfsEndpoint string, cacheSize int) Environment {
+	hostname, _ := os.Hostname()
+	env := Environment{
+		Timestamp:    time.Now().UTC(),
+		Hostname:     hostname,
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		NumCPU:       runtime.NumCPU(),
+		GoVersion:    runtime.Version(),
+		IPFSEndpoint: ipfsEndpoint,
+		CacheSize:    cacheSize,
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				env.Commit = setting.Value
+			case "vcs.modified":
+				env.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return env
+}
+
+// newRunResult summarizes the results of each phase of a run. Metric names
+// are "<phase>.<metric>", e.g. "single.upload_latency_ms".
+func newRunResult(run int, elapsed time.Duration, phases map[string][]TestResult) RunResult {
+	result := RunResult{
+		Run:     run,
+		Elapsed: elapsed,
+		Metrics: make(map[string]float64),
+	}
+
+	for phase, results := range phases {
+		for name, value := range phaseMetrics(results) {
+			result.Metrics[phase+"."+name] = value
+		}
+		result.Results = append(result.Results, results...)
+	}
+	return result
+}
+
+// phaseMetrics computes latency, throughput and success rate of a phase
+func phaseMetrics(results []TestResult) map[string]float64 {
+	metrics := map[string]float64{"files": float64(len(results))}
+	if len(results) == 0 {
+		return metrics
+	}
+
+	var successful, uploads int
+	var uploadTotal, downloadTotal time.Duration
+	var bytes int64
+	for _, r := range results {
+		if !r.Success {
+			continue
+		}
+		successful++
+		bytes += int64(r.FileSize)
+		downloadTotal += r.DownloadLatency
+		if r.UploadLatency > 0 {
+			uploads++
+			uploadTotal += r.UploadLatency
+		}
+	}
+
+	metrics["success_rate"] = float64(successful) / float64(len(results)) * 100
+	if successful == 0 {
+		return metrics
+	}
+	if uploads > 0 {
+		metrics["upload_latency_ms"] = durationMS(uploadTotal / time.Duration(uploads))
+	}
+	metrics["download_latency_ms"] = durationMS(downloadTotal / time.Duration(successful))
+	if total := uploadTotal + downloadTotal; total > 0 {
+		metrics["throughput_mbps"] = float64(bytes) / (1024 * 1024) / total.Seconds()
+	}
+	return metrics
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Write saves the report in the given format ("json" or "csv"). CSV output
+// has one row per metric and run and is appended to an existing file, so a
+// single file accumulates the history of a scenario across CI builds.
+func (r *Report) Write(path, format string) error {
+	switch format {
+	case "json":
+		return r.writeJSON(path)
+	case "csv":
+		return r.writeCSV(path)
+	default:
+		return fmt.Errorf("unknown output format %q (use json or csv)", format)
+	}
+}
+
+func (r *Report) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+var csvHeader = []string{"timestamp", "scenario", "commit", "hostname", "run", "metric", "value"}
+
+func (r *Report) writeCSV(path string) error {
+	out := os.Stdout
+	writeHeader := true
+	if path != "" && path != "-" {
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			writeHeader = false
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open report: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w := csv.NewWriter(out)
+	if writeHeader {
+		w.Write(csvHeader)
+	}
+
+	timestamp := r.Environment.Timestamp.Format(time.RFC3339)
+	for _, run := range r.Runs {
+		names := make([]string, 0, len(run.Metrics))
+		for name := range run.Metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			w.Write([]string{
+				timestamp,
+				r.Scenario.Name,
+				r.Environment.Commit,
+				r.Environment.Hostname,
+				strconv.Itoa(run.Run),
+				name,
+				strconv.FormatFloat(run.Metrics[name], 'f', 3, 64),
+			})
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes a reproducible benchmark: the same scenario file and
+// seed always produce the same sequence of file sizes
+type Scenario struct {
+	Name        string     `yaml:"name" json:"name"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Nodes       int        `yaml:"nodes" json:"nodes"`                 // 1=single-node, 2=hybrid, 3+=multi-node
+	Runs        int        `yaml:"runs" json:"runs"`                   // Repetitions, for comparing runs statistically
+	Files       int        `yaml:"files" json:"files"`                 // Files per run
+	Duration    Duration   `yaml:"duration,omitempty" json:"duration"` // Optional time limit per run
+	Seed        int64      `yaml:"seed" json:"seed"`
+	Mix         []MixEntry `yaml:"mix" json:"mix"`
+	Faults      *FaultSpec `yaml:"faults,omitempty" json:"faults,omitempty"` // Optional failure injection
+}
+
+// FaultSpec injects failures into every storage backend the benchmark
+// uses, to measure how retries and failover hold up
+type FaultSpec struct {
+	Seed          int64    `yaml:"seed,omitempty" json:"seed,omitempty"`
+	ErrorRate     float64  `yaml:"error_rate,omitempty" json:"error_rate,omitempty"`
+	LossRate      float64  `yaml:"loss_rate,omitempty" json:"loss_rate,omitempty"`
+	TimeoutRate   float64  `yaml:"timeout_rate,omitempty" json:"timeout_rate,omitempty"`
+	Timeout       Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Latency       Duration `yaml:"latency,omitempty" json:"latency,omitempty"`
+	LatencyJitter Duration `yaml:"latency_jitter,omitempty" json:"latency_jitter,omitempty"`
+	Operations    []string `yaml:"operations,omitempty" json:"operations,omitempty"` // put, get, has, delete, pin
+}
+
+// faultConfig converts the spec for the storage manager
+func (f *FaultSpec) faultConfig() storage.FaultConfig {
+	return storage.FaultConfig{
+		Seed:          f.Seed,
+		ErrorRate:     f.ErrorRate,
+		LossRate:      f.LossRate,
+		TimeoutRate:   f.TimeoutRate,
+		Timeout:       time.Duration(f.Timeout),
+		Latency:       time.Duration(f.Latency),
+		LatencyJitter: time.Duration(f.LatencyJitter),
+		Operations:    f.Operations,
+	}
+}
+
+// MixEntry is one file size in a scenario's workload and its relative weight
+type MixEntry struct {
+	Size   string `yaml:"size" json:"size"` // e.g. "4KB", "1MB"
+	Weight int    `yaml:"weight" json:"weight"`
+
+	bytes int
+}
+
+// Duration is a time.Duration written as "30s" or "2m" in scenario files
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", value.Value, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s: %w", data, err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// LoadScenario reads and validates a YAML scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	scenario := &Scenario{Nodes: 1, Runs: 1, Seed: 1}
+	if err := yaml.Unmarshal(data, scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return scenario, nil
+}
+
+// scenarioFromFlags builds the single-size scenario described by command-line flags
+func scenarioFromFlags(nodes, fileSize, files, runs int) (*Scenario, error) {
+	scenario := &Scenario{
+		Name:  "flags",
+		Nodes: nodes,
+		Runs:  runs,
+		Files: files,
+		Seed:  1,
+		Mix:   []MixEntry{{Size: fmt.Sprintf("%dB", fileSize), Weight: 1}},
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// Validate checks the scenario and resolves its file sizes
+func (s *Scenario) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Nodes < 1 {
+		return fmt.Errorf("nodes must be at least 1 (current: %d)", s.Nodes)
+	}
+	if s.Runs < 1 {
+		return fmt.Errorf("runs must be at least 1 (current: %d)", s.Runs)
+	}
+	if s.Files < 1 {
+		return fmt.Errorf("files must be at least 1 (current: %d)", s.Files)
+	}
+	if s.Duration < 0 {
+		return fmt.Errorf("duration cannot be negative")
+	}
+	if len(s.Mix) == 0 {
+		return fmt.Errorf("mix needs at least one file size")
+	}
+
+	if f := s.Faults; f != nil {
+		for name, rate := range map[string]float64{"error_rate": f.ErrorRate, "loss_rate": f.LossRate, "timeout_rate": f.TimeoutRate} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("faults: %s must be between 0 and 1 (current: %g)", name, rate)
+			}
+		}
+		for _, op := range f.Operations {
+			switch op {
+			case storage.FaultOpPut, storage.FaultOpGet, storage.FaultOpHas, storage.FaultOpDelete, storage.FaultOpPin:
+			default:
+				return fmt.Errorf("faults: unknown operation %q", op)
+			}
+		}
+	}
+
+	for i := range s.Mix {
+		entry := &s.Mix[i]
+		size, err := util.ParseSize(entry.Size)
+		if err != nil {
+			return fmt.Errorf("mix entry %d: invalid size %q: %w", i+1, entry.Size, err)
+		}
+		if size <= 0 {
+			return fmt.Errorf("mix entry %d: size must be positive", i+1)
+		}
+		if entry.Weight <= 0 {
+			return fmt.Errorf("mix entry %d: weight must be positive", i+1)
+		}
+		entry.bytes = int(size)
+	}
+	return nil
+}
+
+// FileSizes returns the file sizes of one run, drawn from the mix with a
+// generator seeded by the scenario seed and run number
+func (s *Scenario) FileSizes(run int) []int {
+	totalWeight := 0
+	for _, entry := range s.Mix {
+		totalWeight += entry.Weight
+	}
+
+	rng := rand.New(rand.NewSource(s.Seed + int64(run)))
+	sizes := make([]int, s.Files)
+	for i := range sizes {
+		pick := rng.Intn(totalWeight)
+		for _, entry := range s.Mix {
+			if pick < entry.Weight {
+				sizes[i] = entry.bytes
+				break
+			}
+			pick -= entry.Weight
+		}
+	}
+	return sizes
+}
+
+// runContext returns the context bounding one run
+func (s *Scenario) runContext() (context.Context, context.CancelFunc) {
+	if s.Duration > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(s.Duration))
+	}
+	return context.WithCancel(context.Background())
+}
+
+// injectFaults applies the scenario's faults to every backend of a started manager
+func (s *Scenario) injectFaults(manager *storage.Manager) error {
+	if s.Faults == nil {
+		return nil
+	}
+	for name := range manager.GetAvailableBackends() {
+		if _, err := manager.InjectFaults(name, s.Faults.faultConfig()); err != nil {
+			return fmt.Errorf("failed to inject faults into %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	benchmarks "github.com/TheEntropyCollective/noisefs/tests/benchmarks"
+)
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario("../scenarios/ci-smoke.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load scenario: %v", err)
+	}
+	if scenario.Name != "ci-smoke" || scenario.Runs != 5 || time.Duration(scenario.Duration) != 2*time.Minute {
+		t.Errorf("Unexpected scenario: %+v", scenario)
+	}
+
+	// The same run of the same scenario always has the same workload
+	first, again := scenario.FileSizes(1), scenario.FileSizes(1)
+	if len(first) != scenario.Files {
+		t.Fatalf("Expected %d sizes, got %d", scenario.Files, len(first))
+	}
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatal("File sizes are not reproducible")
+		}
+		if first[i] != 4*1024 && first[i] != 128*1024 && first[i] != 1024*1024 {
+			t.Errorf("Size %d is not part of the mix", first[i])
+		}
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yaml")
+	os.WriteFile(invalid, []byte("name: bad\nfiles: 5\nmix:\n  - size: 4XB\n    weight: 1\n"), 0644)
+	if _, err := LoadScenario(invalid); err == nil || !strings.Contains(err.Error(), "mix entry 1") {
+		t.Errorf("Expected invalid size to be reported, got %v", err)
+	}
+}
+
+func TestScenarioFaults(t *testing.T) {
+	scenario, err := LoadScenario("../scenarios/degraded-backend.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load scenario: %v", err)
+	}
+	config := scenario.Faults.faultConfig()
+	if config.LossRate != 0.05 || config.Latency != 50*time.Millisecond || config.Seed != 7 {
+		t.Errorf("Unexpected fault config: %+v", config)
+	}
+
+	scenario.Faults.LossRate = 1.5
+	if err := scenario.Validate(); err == nil || !strings.Contains(err.Error(), "loss_rate") {
+		t.Errorf("Expected loss_rate validation error, got %v", err)
+	}
+	scenario.Faults.LossRate = 0.05
+	scenario.Faults.Operations = []string{"list"}
+	if err := scenario.Validate(); err == nil {
+		t.Error("Expected unknown operation to be rejected")
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	scenario, _ := scenarioFromFlags(1, 1024, 2, 1)
+	report := &Report{
+		Scenario:    scenario,
+		Environment: collectEnvironment("127.0.0.1:5001", 1000),
+		Runs: []RunResult{newRunResult(1, time.Second, map[string][]TestResult{
+			"single": {
+				{TestName: "file_1", FileSize: 1024 * 1024, UploadLatency: 100 * time.Millisecond, DownloadLatency: 300 * time.Millisecond, Success: true},
+				{TestName: "file_2", Success: false},
+			},
+		})},
+	}
+
+	metrics := report.Runs[0].Metrics
+	if metrics["single.success_rate"] != 50 || metrics["single.upload_latency_ms"] != 100 || metrics["single.download_latency_ms"] != 300 {
+		t.Errorf("Unexpected metrics: %v", metrics)
+	}
+	if metrics["single.throughput_mbps"] != 2.5 {
+		t.Errorf("Expected 2.5 MB/s, got %v", metrics["single.throughput_mbps"])
+	}
+
+	// CSV output appends without repeating the header
+	csvPath := filepath.Join(t.TempDir(), "history.csv")
+	for i := 0; i < 2; i++ {
+		if err := report.Write(csvPath, "csv"); err != nil {
+			t.Fatalf("Failed to write CSV: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(csvPath)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if strings.Count(string(data), "timestamp,scenario") != 1 || len(lines) != 1+2*len(metrics) {
+		t.Errorf("Unexpected CSV output:\n%s", data)
+	}
+
+	jsonPath := filepath.Join(t.TempDir(), "results.json")
+	if err := report.Write(jsonPath, "json"); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	if err := report.Write(jsonPath, "xml"); err == nil {
+		t.Error("Expected unknown format to fail")
+	}
+}
+
+func TestEnterpriseRunResult(t *testing.T) {
+	result := newEnterpriseRunResult(1, time.Second, []benchmarks.BenchmarkResult{
+		{Name: "FUSE Directory Ops", Operations: 200, OperationsPerSec: 40, LatencyAvg: 5 * time.Millisecond},
+		{Name: "Sequential Read", Operations: 10, BytesProcessed: 1 << 20, ThroughputMBps: 12.5},
+	})
+
+	if result.Metrics["fuse_directory_ops.ops_per_sec"] != 40 || result.Metrics["fuse_directory_ops.latency_avg_ms"] != 5 {
+		t.Errorf("Unexpected FUSE metrics: %v", result.Metrics)
+	}
+	if _, ok := result.Metrics["fuse_directory_ops.throughput_mbps"]; ok {
+		t.Error("Throughput should be omitted for benchmarks that move no data")
+	}
+	if result.Metrics["sequential_read.throughput_mbps"] != 12.5 {
+		t.Errorf("Unexpected sequential metrics: %v", result.Metrics)
+	}
+	if len(result.Benchmarks) != 2 {
+		t.Errorf("Expected suite results to be kept, got %d", len(result.Benchmarks))
+	}
+}
@@ -15,7 +15,7 @@ import (
 func main() {
 	var (
 		configFile    = flag.String("config", "", "Configuration file path")
-		mountPath     = flag.String("mount", "", "Mount point for FUSE benchmarks")
+		mountPath     = flag.String("mount", "", "Mount point for FUSE benchmarks (default: fuse.mount_path)")
 		outputFormat  = flag.String("format", "text", "Output format: text or json")
 		outputFile    = flag.String("output", "", "Output file (default: stdout)")
 		duration      = flag.Duration("duration", 30*time.Second, "Benchmark duration")
@@ -32,7 +32,7 @@ func main() {
 	flag.Parse()
 
 	// Load configuration
-	cfg, err := loadConfig(*configFile)
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
@@ -43,9 +43,18 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logging: %v\n", err)
 		os.Exit(1)
 	}
+	if err := logging.InitComponentLevelsFromConfig(cfg.Logging.Components, cfg.Logging.SampleInitial, cfg.Logging.SampleThereafter); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logging: %v\n", err)
+		os.Exit(1)
+	}
 
 	logger := logging.GetGlobalLogger().WithComponent("noisefs-benchmark")
 
+	// FUSE benchmarks default to the configured mount point
+	if *mountPath == "" {
+		*mountPath = cfg.FUSE.MountPath
+	}
+
 	// Create benchmark configuration
 	benchConfig := &benchmarks.BenchmarkConfig{
 		Duration:    *duration,
@@ -126,18 +135,6 @@ func main() {
 	})
 }
 
-func loadConfig(configPath string) (*config.Config, error) {
-	if configPath == "" {
-		// Try default config path
-		defaultPath, err := config.GetDefaultConfigPath()
-		if err == nil {
-			configPath = defaultPath
-		}
-	}
-	
-	return config.LoadConfig(configPath)
-}
-
 func runBasicBenchmarks(basePath string, config *benchmarks.BenchmarkConfig, logger *logging.Logger) ([]benchmarks.BenchmarkResult, error) {
 	suite := benchmarks.NewBenchmarkSuite("Basic File Operations", basePath, logger)
 	
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends" // Register the IPFS backend
 	"github.com/TheEntropyCollective/noisefs/pkg/tools/bootstrap"
 )
 
@@ -18,6 +27,10 @@ func main() {
 		listDatasets = flag.Bool("list", false, "List available datasets")
 		preview      = flag.Bool("preview", false, "Preview dataset without downloading")
 		parallel     = flag.Int("parallel", 4, "Number of parallel downloads")
+		pool         = flag.Bool("pool", false, "Split the content into pinned randomizer blocks instead of keeping it as files")
+		blockSize    = flag.Int("block-size", blocks.DefaultBlockSize, "Randomizer block size in bytes for -pool")
+		manifestPath = flag.String("manifest", "", "Pool manifest to create or extend (default: <output>/pool/pool_manifest.json)")
+		skipDownload = flag.Bool("skip-download", false, "Use previously downloaded content")
 	)
 	flag.Parse()
 
@@ -58,10 +71,22 @@ func main() {
 		return
 	}
 
-	fmt.Printf("Downloading dataset '%s'...\n", *dataset)
-	err := generator.GenerateDataset()
-	if err != nil {
-		log.Fatalf("Failed to generate dataset: %v", err)
+	if !*skipDownload {
+		fmt.Printf("Downloading dataset '%s'...\n", *dataset)
+		err := generator.GenerateDataset()
+		if err != nil {
+			log.Fatalf("Failed to generate dataset: %v", err)
+		}
+	}
+
+	if *pool {
+		if *manifestPath == "" {
+			*manifestPath = filepath.Join(*outputDir, "pool", "pool_manifest.json")
+		}
+		if err := populatePool(generator, *configFile, *manifestPath, *dataset, *blockSize, *verbose); err != nil {
+			log.Fatalf("Failed to populate randomizer pool: %v", err)
+		}
+		return
 	}
 
 	fmt.Printf("\nBootstrap data generation completed successfully!\n")
@@ -78,4 +103,50 @@ func main() {
 		fmt.Printf("- File Types: %v\n", summary.FileTypes)
 		fmt.Printf("- Directory Structure: %d directories\n", summary.DirectoryCount)
 	}
-}
\ No newline at end of file
+}
+// populatePool splits the downloaded dataset into randomizer blocks, pins
+// them and records their CIDs in the pool manifest
+func populatePool(generator *bootstrap.DatasetGenerator, configFile, manifestPath, dataset string, blockSize int, verbose bool) error {
+	datasetDir, err := generator.DatasetDir()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	storageConfig := cfg.StorageConfig()
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		return fmt.Errorf("failed to start storage manager: %w", err)
+	}
+	defer storageManager.Stop(context.Background())
+
+	populator, err := bootstrap.NewPoolPopulator(storageManager, manifestPath, dataset, blockSize, verbose)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Printf("\nSplitting %s into %d KB randomizer blocks...\n", datasetDir, blockSize/1024)
+	stats, err := populator.PopulateFromDir(ctx, datasetDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nRandomizer pool populated!\n")
+	fmt.Printf("- Files processed: %d (%d already pooled)\n", stats.Files, stats.SkippedFiles)
+	fmt.Printf("- New blocks pinned: %d (%.2f MB)\n", stats.Blocks, float64(stats.Bytes)/(1024*1024))
+	fmt.Printf("- Duplicate blocks: %d\n", stats.DuplicateBlocks)
+	fmt.Printf("- Short tails discarded: %d\n", stats.DiscardedTails)
+	fmt.Printf("- Pool size: %d blocks\n", len(populator.Manifest().Blocks))
+	fmt.Printf("- Manifest: %s\n", manifestPath)
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint records the progress of an indexing run in a sidecar file next
+// to the index, so an interrupted run can resume without re-uploading
+// files that already have descriptors
+type Checkpoint struct {
+	SourceDir string                     `json:"source_dir"`
+	BlockSize int                        `json:"block_size"`
+	Started   time.Time                  `json:"started"`
+	Updated   time.Time                  `json:"updated"`
+	Completed map[string]CompletedUpload `json:"completed"`
+	Failed    map[string]string          `json:"failed,omitempty"` // Relative path -> last error
+}
+
+// CompletedUpload is a file whose descriptor has been stored
+type CompletedUpload struct {
+	DescriptorCID string    `json:"descriptor_cid"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mod_time"`
+}
+
+// checkpointPath returns the sidecar path used for an index file
+func checkpointPath(indexFile string) string {
+	return indexFile + ".checkpoint"
+}
+
+// NewCheckpoint starts an empty checkpoint for a source directory
+func NewCheckpoint(sourceDir string, blockSize int) *Checkpoint {
+	now := time.Now()
+	return &Checkpoint{
+		SourceDir: absPath(sourceDir),
+		BlockSize: blockSize,
+		Started:   now,
+		Updated:   now,
+		Completed: make(map[string]CompletedUpload),
+		Failed:    make(map[string]string),
+	}
+}
+
+// LoadCheckpoint reads a checkpoint and checks it belongs to the same
+// source directory and block size, since descriptors from a different
+// block size can't be mixed into one index
+func LoadCheckpoint(path, sourceDir string, blockSize int) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if checkpoint.SourceDir != absPath(sourceDir) {
+		return nil, fmt.Errorf("checkpoint %s is for %s, not %s", path, checkpoint.SourceDir, absPath(sourceDir))
+	}
+	if checkpoint.BlockSize != blockSize {
+		return nil, fmt.Errorf("checkpoint %s used %d byte blocks, not %d", path, checkpoint.BlockSize, blockSize)
+	}
+	if checkpoint.Completed == nil {
+		checkpoint.Completed = make(map[string]CompletedUpload)
+	}
+	if checkpoint.Failed == nil {
+		checkpoint.Failed = make(map[string]string)
+	}
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint atomically
+func (c *Checkpoint) Save(path string) error {
+	c.Updated = time.Now()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// IsDone reports whether a file was uploaded by an earlier run and hasn't
+// changed since
+func (c *Checkpoint) IsDone(file FileInfo) (CompletedUpload, bool) {
+	upload, ok := c.Completed[file.RelPath]
+	if !ok || upload.Size != file.Size || !upload.ModTime.Equal(file.ModTime) {
+		return CompletedUpload{}, false
+	}
+	return upload, true
+}
+
+// MarkCompleted records a finished upload
+func (c *Checkpoint) MarkCompleted(file FileInfo, descriptorCID string) {
+	c.Completed[file.RelPath] = CompletedUpload{
+		DescriptorCID: descriptorCID,
+		Size:          file.Size,
+		ModTime:       file.ModTime,
+	}
+	delete(c.Failed, file.RelPath)
+}
+
+// MarkFailed records a failed upload, to be retried on resume
+func (c *Checkpoint) MarkFailed(file FileInfo, err error) {
+	c.Failed[file.RelPath] = err.Error()
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
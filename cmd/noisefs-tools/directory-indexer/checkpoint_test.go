@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	sourceDir := filepath.Join(dir, "photos")
+	path := checkpointPath(filepath.Join(dir, "photos.noisefs"))
+	modTime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	done := FileInfo{RelPath: "a.jpg", Size: 100, ModTime: modTime}
+	broken := FileInfo{RelPath: "b.jpg", Size: 200, ModTime: modTime}
+
+	checkpoint := NewCheckpoint(sourceDir, 4096)
+	checkpoint.MarkCompleted(done, "QmDescriptorA")
+	checkpoint.MarkFailed(broken, errors.New("connection refused"))
+	if err := checkpoint.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadCheckpoint(path, sourceDir, 4096)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if upload, ok := loaded.IsDone(done); !ok || upload.DescriptorCID != "QmDescriptorA" {
+		t.Errorf("Expected a.jpg to be done, got %+v, %v", upload, ok)
+	}
+	if _, ok := loaded.IsDone(broken); ok {
+		t.Error("Failed upload must not count as done")
+	}
+	if loaded.Failed["b.jpg"] != "connection refused" {
+		t.Errorf("Expected failure to be recorded, got %v", loaded.Failed)
+	}
+
+	// A file changed since the checkpoint is uploaded again
+	changed := done
+	changed.ModTime = modTime.Add(time.Minute)
+	if _, ok := loaded.IsDone(changed); ok {
+		t.Error("Modified file must not count as done")
+	}
+	changed = done
+	changed.Size = 101
+	if _, ok := loaded.IsDone(changed); ok {
+		t.Error("Resized file must not count as done")
+	}
+
+	// Completing a retried file clears its failure
+	loaded.MarkCompleted(broken, "QmDescriptorB")
+	if _, failed := loaded.Failed["b.jpg"]; failed {
+		t.Error("Expected failure to be cleared after completion")
+	}
+
+	if _, err := LoadCheckpoint(path, filepath.Join(dir, "music"), 4096); err == nil {
+		t.Error("Expected an error for a different source directory")
+	}
+	if _, err := LoadCheckpoint(path, sourceDir, 8192); err == nil {
+		t.Error("Expected an error for a different block size")
+	}
+	if _, err := LoadCheckpoint(filepath.Join(dir, "missing"), sourceDir, 4096); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
@@ -16,6 +20,7 @@ import (
 	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 )
@@ -35,6 +40,8 @@ func main() {
 		dryRun       = flag.Bool("dry-run", false, "Show what would be uploaded without actually uploading")
 		recursive    = flag.Bool("recursive", true, "Process directories recursively")
 		showProgress = flag.Bool("progress", true, "Show upload progress")
+		workerCount  = flag.Int("workers", 0, "Files uploaded concurrently (default: performance.max_concurrent_ops)")
+		resume       = flag.Bool("resume", false, "Continue an interrupted run from its checkpoint, skipping completed files")
 	)
 	flag.Parse()
 
@@ -59,7 +66,7 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := loadConfig(*configFile)
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
@@ -116,10 +123,7 @@ func main() {
 		"endpoint": cfg.IPFS.APIEndpoint,
 	})
 	// Create storage manager
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
-	}
+	storageConfig := cfg.StorageConfig()
 	
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -139,43 +143,81 @@ func main() {
 		log.Fatalf("Failed to create NoiseFS client: %v", err)
 	}
 
-	// Create index
-	index := fuse.NewFileIndex(*indexFile)
-
-	// Upload files and build index
-	startTime := time.Now()
-	fmt.Printf("🚀 Starting upload process...\n\n")
-
-	for i, file := range files {
-		if *showProgress {
-			fmt.Printf("[%d/%d] Uploading: %s", i+1, len(files), file.RelPath)
+	// Load or start the checkpoint sidecar
+	checkpointFile := checkpointPath(*indexFile)
+	checkpoint := NewCheckpoint(*sourceDir, cfg.Performance.BlockSize)
+	if *resume {
+		loaded, err := LoadCheckpoint(checkpointFile, *sourceDir, cfg.Performance.BlockSize)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			fmt.Printf("No checkpoint found at %s, starting from scratch\n", checkpointFile)
+		case err != nil:
+			log.Fatalf("Failed to load checkpoint: %v", err)
+		default:
+			checkpoint = loaded
 		}
+	} else if _, err := os.Stat(checkpointFile); err == nil {
+		fmt.Printf("⚠️  Discarding checkpoint %s (use -resume to continue it)\n", checkpointFile)
+	}
 
-		// Upload file
-		descriptorCID, err := uploadFile(noisefsClient, file.FullPath, file.RelPath, cfg.Performance.BlockSize, logger)
-		if err != nil {
-			fmt.Printf(" ❌ FAILED\n")
-			logger.Error("Failed to upload file", map[string]interface{}{
-				"file":  file.RelPath,
-				"error": err.Error(),
-			})
+	// Create index, starting with files completed by an earlier run
+	index := fuse.NewFileIndex(*indexFile)
+	var pending []FileInfo
+	for _, file := range files {
+		if upload, done := checkpoint.IsDone(file); done {
+			index.AddFile(file.RelPath, upload.DescriptorCID, file.Size)
 			continue
 		}
+		pending = append(pending, file)
+	}
+	if resumed := len(files) - len(pending); resumed > 0 {
+		fmt.Printf("⏩ Resuming: %d files already uploaded, %d remaining\n", resumed, len(pending))
+	}
 
-		// Add to index
-		index.AddFile(file.RelPath, descriptorCID, file.Size)
-
-		if *showProgress {
-			fmt.Printf(" ✅ %s\n", descriptorCID[:12]+"...")
-		}
+	workersToUse := *workerCount
+	if workersToUse <= 0 {
+		workersToUse = cfg.Performance.MaxConcurrentOps
+	}
+	if workersToUse <= 0 {
+		workersToUse = runtime.NumCPU()
 	}
 
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Upload files and build index
+	startTime := time.Now()
+	fmt.Printf("🚀 Starting upload process with %d workers...\n\n", workersToUse)
+
+	uploaded, failed := uploadFiles(ctx, noisefsClient, pending, index, checkpoint, checkpointFile,
+		cfg.Performance.BlockSize, workersToUse, *showProgress, logger)
+	interrupted := ctx.Err() != nil
+
 	// Save index
 	fmt.Printf("\n💾 Saving index file...\n")
 	if err := index.SaveIndex(); err != nil {
 		log.Fatalf("Failed to save index: %v", err)
 	}
 
+	// Keep the checkpoint while anything is left to do
+	if interrupted || failed > 0 {
+		if err := checkpoint.Save(checkpointFile); err != nil {
+			log.Fatalf("Failed to save checkpoint: %v", err)
+		}
+		fmt.Printf("\n⏸️  %d files uploaded, %d failed, %d not attempted\n", uploaded, failed, len(pending)-uploaded-failed)
+		fmt.Printf("Checkpoint saved to %s; rerun with -resume to continue\n", checkpointFile)
+		if interrupted {
+			os.Exit(130)
+		}
+		os.Exit(1)
+	}
+	if err := os.Remove(checkpointFile); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove checkpoint", map[string]interface{}{
+			"file":  checkpointFile,
+			"error": err.Error(),
+		})
+	}
+
 	// Show summary
 	duration := time.Since(startTime)
 	metrics := noisefsClient.GetMetrics()
@@ -196,6 +238,7 @@ type FileInfo struct {
 	FullPath string
 	RelPath  string
 	Size     int64
+	ModTime  time.Time
 }
 
 func collectFiles(sourceDir, includeExt, excludeExt string, maxFileSize int64, recursive bool, logger *logging.Logger) ([]FileInfo, error) {
@@ -268,6 +311,7 @@ func collectFiles(sourceDir, includeExt, excludeExt string, maxFileSize int64, r
 			FullPath: path,
 			RelPath:  relPath,
 			Size:     info.Size(),
+			ModTime:  info.ModTime(),
 		})
 
 		return nil
@@ -288,7 +332,116 @@ func getTotalSize(files []FileInfo) int64 {
 	return total
 }
 
-func uploadFile(client *noisefs.Client, filePath, relativePath string, blockSize int, logger *logging.Logger) (string, error) {
+// checkpointInterval bounds how often the checkpoint is rewritten; at most
+// this much finished work is re-uploaded after a crash
+const checkpointInterval = time.Second
+
+// uploadTask uploads one file on the worker pool
+type uploadTask struct {
+	ctx       context.Context
+	client    *noisefs.Client
+	file      FileInfo
+	blockSize int
+	logger    *logging.Logger
+}
+
+func (t *uploadTask) ID() string {
+	return t.file.RelPath
+}
+
+func (t *uploadTask) Execute(_ context.Context) (interface{}, error) {
+	return uploadFile(t.ctx, t.client, t.file.FullPath, t.file.RelPath, t.blockSize, t.logger)
+}
+
+// uploadFiles uploads files concurrently, adding each to the index and
+// checkpoint as it completes. It returns the number of uploads that
+// succeeded and failed; files not attempted because ctx was cancelled
+// count as neither.
+func uploadFiles(ctx context.Context, client *noisefs.Client, files []FileInfo, index *fuse.FileIndex, checkpoint *Checkpoint,
+	checkpointFile string, blockSize, workerCount int, showProgress bool, logger *logging.Logger) (int, int) {
+	if len(files) == 0 {
+		return 0, 0
+	}
+
+	pool := workers.NewPool(workers.Config{
+		WorkerCount:     workerCount,
+		BufferSize:      workerCount * 2,
+		ShutdownTimeout: 30 * time.Second,
+	})
+	if err := pool.Start(); err != nil {
+		log.Fatalf("Failed to start worker pool: %v", err)
+	}
+	defer pool.Shutdown()
+
+	byPath := make(map[string]FileInfo, len(files))
+	for _, file := range files {
+		byPath[file.RelPath] = file
+	}
+
+	// Submit from a separate goroutine so results are drained meanwhile
+	submitted := make(chan int, 1)
+	go func() {
+		count := 0
+		for _, file := range files {
+			task := &uploadTask{ctx: ctx, client: client, file: file, blockSize: blockSize, logger: logger}
+			if err := pool.SubmitBlocking(ctx, task); err != nil {
+				break
+			}
+			count++
+		}
+		submitted <- count
+	}()
+
+	total := len(files)
+	uploaded, failed := 0, 0
+	lastSave := time.Now()
+	for received := 0; received < total; {
+		select {
+		case count := <-submitted:
+			total = count
+			continue
+		case result := <-pool.Results():
+			received++
+			file := byPath[result.TaskID]
+
+			if result.Error != nil {
+				// Uploads cut short by an interrupt are retried on resume
+				if ctx.Err() != nil {
+					continue
+				}
+				failed++
+				checkpoint.MarkFailed(file, result.Error)
+				fmt.Printf("[%d/%d] ❌ %s: %v\n", uploaded+failed, len(files), file.RelPath, result.Error)
+				logger.Error("Failed to upload file", map[string]interface{}{
+					"file":  file.RelPath,
+					"error": result.Error.Error(),
+				})
+			} else {
+				uploaded++
+				descriptorCID := result.Value.(string)
+				index.AddFile(file.RelPath, descriptorCID, file.Size)
+				checkpoint.MarkCompleted(file, descriptorCID)
+				if showProgress {
+					fmt.Printf("[%d/%d] ✅ %s %s\n", uploaded+failed, len(files), file.RelPath, descriptorCID[:12]+"...")
+				}
+			}
+
+			if time.Since(lastSave) >= checkpointInterval {
+				if err := checkpoint.Save(checkpointFile); err != nil {
+					logger.Warn("Failed to save checkpoint", map[string]interface{}{
+						"file":  checkpointFile,
+						"error": err.Error(),
+					})
+				}
+				lastSave = time.Now()
+			}
+		}
+	}
+
+	return uploaded, failed
+}
+
+func uploadFile(ctx context.Context, client *noisefs.Client, filePath, relativePath string, blockSize int, logger *logging.Logger) (string, error) {
 	// Read file content
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -313,7 +466,7 @@ func uploadFile(client *noisefs.Client, filePath, relativePath string, blockSize
 	// Process each block with 3-tuple anonymization
 	for _, dataBlock := range fileBlocks {
 		// Select randomizers
-		rand1, cid1, rand2, cid2, _, err := client.SelectRandomizers(context.Background(), dataBlock.Size())
+		rand1, cid1, rand2, cid2, _, err := client.SelectRandomizers(ctx, dataBlock.Size())
 		if err != nil {
 			return "", fmt.Errorf("failed to select randomizers: %w", err)
 		}
@@ -325,7 +478,7 @@ func uploadFile(client *noisefs.Client, filePath, relativePath string, blockSize
 		}
 
 		// Store anonymized block
-		dataCID, err := client.StoreBlockWithCache(context.Background(), anonymizedBlock)
+		dataCID, err := client.StoreBlockWithCache(ctx, anonymizedBlock)
 		if err != nil {
 			return "", fmt.Errorf("failed to store block: %w", err)
 		}
@@ -347,21 +500,10 @@ func uploadFile(client *noisefs.Client, filePath, relativePath string, blockSize
 		return "", fmt.Errorf("failed to create descriptor block: %w", err)
 	}
 
-	descriptorCID, err := client.StoreBlockWithCache(context.Background(), descriptorBlock)
+	descriptorCID, err := client.StoreBlockWithCache(ctx, descriptorBlock)
 	if err != nil {
 		return "", fmt.Errorf("failed to store descriptor: %w", err)
 	}
 
 	return descriptorCID, nil
 }
-
-func loadConfig(configPath string) (*config.Config, error) {
-	if configPath == "" {
-		defaultPath, err := config.GetDefaultConfigPath()
-		if err == nil {
-			configPath = defaultPath
-		}
-	}
-
-	return config.LoadConfig(configPath)
-}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// Descriptor formats as stored
+const (
+	FormatPlain     = "plain"     // Descriptor JSON stored directly
+	FormatEnvelope  = "envelope"  // Version 3.0 envelope holding an unencrypted descriptor
+	FormatEncrypted = "encrypted" // Version 3.0 envelope holding an encrypted descriptor
+)
+
+// Report describes a descriptor and where its blocks are available
+type Report struct {
+	CID          string          `json:"cid"`
+	StoredSize   int             `json:"stored_size"`
+	Format       string          `json:"format"`
+	Encrypted    bool            `json:"encrypted"`
+	Encryption   *EncryptionInfo `json:"encryption,omitempty"`
+	Descriptor   *DescriptorInfo `json:"descriptor,omitempty"`
+	Backends     []string        `json:"backends"`
+	Availability CIDStatus       `json:"descriptor_availability"`
+	Manifest     *CIDStatus      `json:"manifest,omitempty"` // Directory descriptors only
+	Blocks       []TripleReport  `json:"blocks,omitempty"`
+	Summary      Summary         `json:"summary"`
+}
+
+// EncryptionInfo describes an encrypted descriptor without decrypting it
+type EncryptionInfo struct {
+	EnvelopeVersion string `json:"envelope_version"`
+	SaltBytes       int    `json:"salt_bytes"`
+	CiphertextBytes int    `json:"ciphertext_bytes"`
+}
+
+// DescriptorInfo is the readable part of a descriptor
+type DescriptorInfo struct {
+	Version         string    `json:"version"`
+	Type            string    `json:"type"`
+	Filename        string    `json:"filename"`
+	FileSize        int64     `json:"file_size"`
+	PaddedFileSize  int64     `json:"padded_file_size"`
+	BlockSize       int       `json:"block_size"`
+	BlockCount      int       `json:"block_count"`
+	ManifestCID     string    `json:"manifest_cid,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	ValidationError string    `json:"validation_error,omitempty"`
+}
+
+// TripleReport is one row of the block triple table
+type TripleReport struct {
+	Index       int       `json:"index"`
+	Data        CIDStatus `json:"data"`
+	Randomizer1 CIDStatus `json:"randomizer1"`
+	Randomizer2 CIDStatus `json:"randomizer2"`
+}
+
+// CIDStatus records which backends hold a block
+type CIDStatus struct {
+	CID       string            `json:"cid"`
+	Available map[string]bool   `json:"available"`        // Backend name -> has the block
+	Errors    map[string]string `json:"errors,omitempty"` // Backend name -> lookup error
+}
+
+// Reachable reports whether at least one backend has the block
+func (s CIDStatus) Reachable() bool {
+	for _, has := range s.Available {
+		if has {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary totals the availability of a descriptor's blocks
+type Summary struct {
+	UniqueBlocks      int  `json:"unique_blocks"`
+	UniqueRandomizers int  `json:"unique_randomizers"`
+	MissingBlocks     int  `json:"missing_blocks"` // Unique blocks no backend has
+	Recoverable       bool `json:"recoverable"`    // Every block is on some backend
+}
+
+// Inspector builds reports from a storage manager
+type Inspector struct {
+	manager     *storage.Manager
+	concurrency int
+}
+
+// NewInspector creates an inspector that checks up to concurrency blocks
+// at a time
+func NewInspector(manager *storage.Manager, concurrency int) *Inspector {
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	return &Inspector{manager: manager, concurrency: concurrency}
+}
+
+// Inspect loads the descriptor at cid and checks every block it references
+// on every available backend. Encrypted descriptors are described but not
+// decrypted, so their block table is not available.
+func (i *Inspector) Inspect(ctx context.Context, cid string) (*Report, error) {
+	block, err := i.manager.Get(ctx, &storage.BlockAddress{ID: cid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve descriptor: %w", err)
+	}
+
+	report := &Report{CID: cid, StoredSize: len(block.Data)}
+	descriptor, err := parseDescriptor(block.Data, report)
+	if err != nil {
+		return nil, err
+	}
+
+	backends := i.manager.GetAvailableBackends()
+	for name := range backends {
+		report.Backends = append(report.Backends, name)
+	}
+	sort.Strings(report.Backends)
+
+	// Collect every CID once, since randomizers are shared between triples
+	cids := []string{cid}
+	if descriptor != nil {
+		if descriptor.ManifestCID != "" {
+			cids = append(cids, descriptor.ManifestCID)
+		}
+		for _, triple := range descriptor.Blocks {
+			cids = append(cids, triple.DataCID, triple.RandomizerCID1, triple.RandomizerCID2)
+		}
+	}
+	statuses := i.checkAvailability(ctx, backends, cids)
+
+	report.Availability = statuses[cid]
+	if descriptor == nil {
+		return report, nil
+	}
+	if descriptor.ManifestCID != "" {
+		status := statuses[descriptor.ManifestCID]
+		report.Manifest = &status
+	}
+
+	unique := make(map[string]bool)
+	randomizers := make(map[string]bool)
+	for index, triple := range descriptor.Blocks {
+		report.Blocks = append(report.Blocks, TripleReport{
+			Index:       index,
+			Data:        statuses[triple.DataCID],
+			Randomizer1: statuses[triple.RandomizerCID1],
+			Randomizer2: statuses[triple.RandomizerCID2],
+		})
+		unique[triple.DataCID] = true
+		unique[triple.RandomizerCID1] = true
+		unique[triple.RandomizerCID2] = true
+		randomizers[triple.RandomizerCID1] = true
+		randomizers[triple.RandomizerCID2] = true
+	}
+	if descriptor.ManifestCID != "" {
+		unique[descriptor.ManifestCID] = true
+	}
+
+	report.Summary.UniqueBlocks = len(unique)
+	report.Summary.UniqueRandomizers = len(randomizers)
+	for blockCID := range unique {
+		if !statuses[blockCID].Reachable() {
+			report.Summary.MissingBlocks++
+		}
+	}
+	report.Summary.Recoverable = report.Summary.MissingBlocks == 0 && report.Availability.Reachable()
+	return report, nil
+}
+
+// parseDescriptor recognizes the stored descriptor format. It returns nil
+// for encrypted descriptors, whose contents are not inspected.
+func parseDescriptor(data []byte, report *Report) (*descriptors.Descriptor, error) {
+	var envelope descriptors.EncryptedDescriptor
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Version == "3.0" {
+		if envelope.IsEncrypted {
+			report.Format = FormatEncrypted
+			report.Encrypted = true
+			report.Encryption = &EncryptionInfo{
+				EnvelopeVersion: envelope.Version,
+				SaltBytes:       len(envelope.Salt),
+				CiphertextBytes: len(envelope.Ciphertext),
+			}
+			return nil, nil
+		}
+		report.Format = FormatEnvelope
+		data = envelope.Ciphertext
+	} else {
+		report.Format = FormatPlain
+	}
+
+	// Parse without FromJSON's validation so broken descriptors can still
+	// be inspected; the validation error is reported instead
+	var descriptor descriptors.Descriptor
+	if err := json.Unmarshal(data, &descriptor); err != nil {
+		return nil, fmt.Errorf("block is not a NoiseFS descriptor: %w", err)
+	}
+
+	report.Descriptor = &DescriptorInfo{
+		Version:        descriptor.Version,
+		Type:           string(descriptor.Type),
+		Filename:       descriptor.Filename,
+		FileSize:       descriptor.FileSize,
+		PaddedFileSize: descriptor.PaddedFileSize,
+		BlockSize:      descriptor.BlockSize,
+		BlockCount:     len(descriptor.Blocks),
+		ManifestCID:    descriptor.ManifestCID,
+		CreatedAt:      descriptor.CreatedAt,
+	}
+	if err := descriptor.Validate(); err != nil {
+		report.Descriptor.ValidationError = err.Error()
+	}
+	return &descriptor, nil
+}
+
+// checkAvailability asks every backend whether it has each CID
+func (i *Inspector) checkAvailability(ctx context.Context, backends map[string]storage.Backend, cids []string) map[string]CIDStatus {
+	statuses := make(map[string]CIDStatus)
+	var unique []string
+	for _, cid := range cids {
+		if _, seen := statuses[cid]; !seen {
+			statuses[cid] = CIDStatus{CID: cid, Available: make(map[string]bool)}
+			unique = append(unique, cid)
+		}
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, i.concurrency)
+	for _, cid := range unique {
+		for name, backend := range backends {
+			wg.Add(1)
+			go func(cid, name string, backend storage.Backend) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				has, err := backend.Has(ctx, &storage.BlockAddress{ID: cid})
+
+				mutex.Lock()
+				defer mutex.Unlock()
+				status := statuses[cid]
+				status.Available[name] = has
+				if err != nil {
+					if status.Errors == nil {
+						status.Errors = make(map[string]string)
+					}
+					status.Errors[name] = err.Error()
+				}
+				statuses[cid] = status
+			}(cid, name, backend)
+		}
+	}
+
+	wg.Wait()
+	return statuses
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+)
+
+func newTestManager(t *testing.T) *storage.Manager {
+	t.Helper()
+	config := storage.DefaultConfig()
+	config.DefaultBackend = "mock"
+	config.Backends = map[string]*storage.BackendConfig{
+		"mock": {
+			Type:       "mock",
+			Enabled:    true,
+			Priority:   100,
+			Connection: &storage.ConnectionConfig{Endpoint: "mock://test"},
+		},
+	}
+	manager, err := storage.NewManager(config)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { manager.Stop(context.Background()) })
+	return manager
+}
+
+func putBlock(t *testing.T, manager *storage.Manager, fill byte) string {
+	t.Helper()
+	block, err := blocks.NewBlock(bytes.Repeat([]byte{fill}, 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := manager.Put(context.Background(), block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	return address.ID
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager(t)
+
+	// Two triples sharing their randomizers
+	rand1, rand2 := putBlock(t, manager, 1), putBlock(t, manager, 2)
+	data1, data2 := putBlock(t, manager, 3), putBlock(t, manager, 4)
+	descriptor := descriptors.NewDescriptor("report.pdf", 200, 256, 128)
+	descriptor.AddBlockTriple(data1, rand1, rand2)
+	descriptor.AddBlockTriple(data2, rand1, rand2)
+
+	store, err := descriptors.NewEncryptedStoreWithPassword(manager, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := store.SaveUnencrypted(descriptor)
+	if err != nil {
+		t.Fatalf("SaveUnencrypted failed: %v", err)
+	}
+
+	inspector := NewInspector(manager, 4)
+	report, err := inspector.Inspect(ctx, cid)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if report.Encrypted || report.Descriptor == nil || report.Descriptor.Filename != "report.pdf" {
+		t.Fatalf("Unexpected report: %+v", report)
+	}
+	if len(report.Blocks) != 2 || report.Blocks[1].Data.CID != data2 || !report.Blocks[1].Data.Available["mock"] {
+		t.Errorf("Unexpected block table: %+v", report.Blocks)
+	}
+	if report.Summary.UniqueBlocks != 4 || report.Summary.UniqueRandomizers != 2 || !report.Summary.Recoverable {
+		t.Errorf("Unexpected summary: %+v", report.Summary)
+	}
+
+	// A lost randomizer makes both triples unrecoverable
+	if err := manager.Delete(ctx, &storage.BlockAddress{ID: rand2}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	report, err = inspector.Inspect(ctx, cid)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if report.Summary.MissingBlocks != 1 || report.Summary.Recoverable {
+		t.Errorf("Expected one missing block, got %+v", report.Summary)
+	}
+
+	var out strings.Builder
+	printReport(&out, report)
+	if !strings.Contains(out.String(), "1 blocks missing") {
+		t.Errorf("Text report doesn't mention the missing block:\n%s", out.String())
+	}
+}
+
+func TestInspectEncrypted(t *testing.T) {
+	manager := newTestManager(t)
+
+	descriptor := descriptors.NewDescriptor("secret.txt", 10, 128, 128)
+	descriptor.AddBlockTriple(putBlock(t, manager, 5), putBlock(t, manager, 6), putBlock(t, manager, 7))
+
+	store, err := descriptors.NewEncryptedStoreWithPassword(manager, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	report, err := NewInspector(manager, 0).Inspect(context.Background(), cid)
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if !report.Encrypted || report.Format != FormatEncrypted || report.Encryption == nil {
+		t.Fatalf("Expected an encrypted report, got %+v", report)
+	}
+	if report.Descriptor != nil || len(report.Blocks) != 0 {
+		t.Error("Encrypted descriptor contents must not be reported")
+	}
+	if report.Encryption.SaltBytes == 0 || report.Encryption.CiphertextBytes == 0 {
+		t.Errorf("Unexpected encryption info: %+v", report.Encryption)
+	}
+	if !report.Availability.Available["mock"] {
+		t.Error("Expected the descriptor block to be available")
+	}
+}
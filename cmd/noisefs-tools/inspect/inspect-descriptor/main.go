@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends" // Register the IPFS backend
+)
+
+func main() {
+	var (
+		configFile  = flag.String("config", "", "Configuration file path (defaults if empty)")
+		jsonOutput  = flag.Bool("json", false, "Print the report as JSON")
+		concurrency = flag.Int("concurrency", 8, "Blocks to check at a time")
+		timeout     = flag.Duration("timeout", 2*time.Minute, "Timeout for the whole inspection")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <descriptor-cid>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints a descriptor's block triples and where each block is available.\n")
+		fmt.Fprintf(os.Stderr, "Encrypted descriptors are described but never decrypted.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cid := flag.Arg(0)
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	storageConfig := cfg.StorageConfig()
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create storage manager: %v\n", err)
+		os.Exit(1)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start storage manager: %v\n", err)
+		os.Exit(1)
+	}
+	defer storageManager.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := NewInspector(storageManager, *concurrency).Inspect(ctx, cid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Inspection failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else {
+		printReport(os.Stdout, report)
+	}
+
+	// Encrypted descriptors can't be checked further, so only fail when
+	// blocks are known to be missing
+	if !report.Availability.Reachable() || report.Summary.MissingBlocks > 0 {
+		os.Exit(1)
+	}
+}
+
+// printReport writes a human readable report
+func printReport(w io.Writer, report *Report) {
+	fmt.Fprintf(w, "🔍 Descriptor %s\n", report.CID)
+	fmt.Fprintf(w, "=====================================\n")
+	fmt.Fprintf(w, "Stored size: %d bytes\n", report.StoredSize)
+	fmt.Fprintf(w, "Format:      %s\n", report.Format)
+	fmt.Fprintf(w, "Backends:    %v\n", report.Backends)
+	fmt.Fprintf(w, "Available:   %s\n", formatStatus(report.Availability, report.Backends))
+
+	if report.Encrypted {
+		fmt.Fprintf(w, "\n🔒 Encrypted descriptor (not decrypted)\n")
+		fmt.Fprintf(w, "Envelope version: %s\n", report.Encryption.EnvelopeVersion)
+		fmt.Fprintf(w, "Salt:             %d bytes\n", report.Encryption.SaltBytes)
+		fmt.Fprintf(w, "Ciphertext:       %d bytes\n", report.Encryption.CiphertextBytes)
+		fmt.Fprintf(w, "\nThe block table needs the descriptor password and is not shown.\n")
+		return
+	}
+
+	info := report.Descriptor
+	fmt.Fprintf(w, "\nVersion:     %s\n", info.Version)
+	fmt.Fprintf(w, "Type:        %s\n", info.Type)
+	fmt.Fprintf(w, "Filename:    %s\n", info.Filename)
+	fmt.Fprintf(w, "File size:   %d bytes (padded %d)\n", info.FileSize, info.PaddedFileSize)
+	fmt.Fprintf(w, "Block size:  %d bytes\n", info.BlockSize)
+	fmt.Fprintf(w, "Blocks:      %d\n", info.BlockCount)
+	if !info.CreatedAt.IsZero() {
+		fmt.Fprintf(w, "Created:     %s\n", info.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if info.ValidationError != "" {
+		fmt.Fprintf(w, "⚠️  Invalid:  %s\n", info.ValidationError)
+	}
+	if report.Manifest != nil {
+		fmt.Fprintf(w, "Manifest:    %s %s\n", report.Manifest.CID, formatStatus(*report.Manifest, report.Backends))
+	}
+
+	if len(report.Blocks) > 0 {
+		fmt.Fprintf(w, "\n%-6s %-24s %-24s %-24s\n", "Index", "Data", "Randomizer 1", "Randomizer 2")
+		for _, triple := range report.Blocks {
+			fmt.Fprintf(w, "%-6d %-24s %-24s %-24s\n", triple.Index,
+				formatCell(triple.Data, report.Backends),
+				formatCell(triple.Randomizer1, report.Backends),
+				formatCell(triple.Randomizer2, report.Backends))
+		}
+	}
+
+	summary := report.Summary
+	fmt.Fprintf(w, "\nUnique blocks: %d (%d randomizers)\n", summary.UniqueBlocks, summary.UniqueRandomizers)
+	if summary.Recoverable {
+		fmt.Fprintf(w, "✅ All blocks available\n")
+	} else {
+		fmt.Fprintf(w, "❌ %d blocks missing from every backend\n", summary.MissingBlocks)
+	}
+}
+
+// formatCell shows a shortened CID followed by one marker per backend
+func formatCell(status CIDStatus, backends []string) string {
+	cid := status.CID
+	if len(cid) > 12 {
+		cid = cid[:6] + "…" + cid[len(cid)-5:]
+	}
+	return cid + " " + formatStatus(status, backends)
+}
+
+// formatStatus prints ✓ or ✗ for each backend, or ? if the lookup failed
+func formatStatus(status CIDStatus, backends []string) string {
+	markers := "["
+	for _, name := range backends {
+		switch {
+		case status.Errors[name] != "":
+			markers += "?"
+		case status.Available[name]:
+			markers += "✓"
+		default:
+			markers += "✗"
+		}
+	}
+	return markers + "]"
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations the load generator drives against the webui API
+const (
+	OpUpload   = "upload"
+	OpDownload = "download"
+	OpSearch   = "search"
+)
+
+// MixEntry is one operation in a workload and its relative weight
+type MixEntry struct {
+	Op     string `json:"op"`
+	Weight int    `json:"weight"`
+}
+
+// ParseMix parses a mix such as "upload=1,download=4,search=2"
+func ParseMix(spec string) ([]MixEntry, error) {
+	var mix []MixEntry
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op, weightStr, found := strings.Cut(part, "=")
+		op = strings.TrimSpace(op)
+		weight := 1
+		if found {
+			w, err := strconv.Atoi(strings.TrimSpace(weightStr))
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight for %q: %q", op, weightStr)
+			}
+			weight = w
+		}
+		switch op {
+		case OpUpload, OpDownload, OpSearch:
+		default:
+			return nil, fmt.Errorf("unknown operation %q (use upload, download or search)", op)
+		}
+		if seen[op] {
+			return nil, fmt.Errorf("operation %q listed twice", op)
+		}
+		seen[op] = true
+		if weight > 0 {
+			mix = append(mix, MixEntry{Op: op, Weight: weight})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("mix %q has no operation with a positive weight", spec)
+	}
+	return mix, nil
+}
+
+// pickOp chooses an operation from the mix in proportion to its weight
+func pickOp(mix []MixEntry, rng *rand.Rand) string {
+	total := 0
+	for _, entry := range mix {
+		total += entry.Weight
+	}
+	n := rng.Intn(total)
+	for _, entry := range mix {
+		if n < entry.Weight {
+			return entry.Op
+		}
+		n -= entry.Weight
+	}
+	return mix[len(mix)-1].Op
+}
+
+// Options configure a load generation run
+type Options struct {
+	BaseURL     string
+	Concurrency int
+	Duration    time.Duration // Stop after this long (0 = until Requests are issued)
+	Requests    int           // Stop after this many operations (0 = until Duration elapses)
+	Mix         []MixEntry
+	FileSize    int
+	Keywords    []string // Search terms, picked at random per search
+	Topic       string   // When set, uploads are announced under this topic
+	Preload     int      // Uploads made before measuring, so downloads have targets
+	Seed        int64
+	Timeout     time.Duration // Per-request timeout
+	Insecure    bool          // Skip TLS verification (self-signed webui certificates)
+}
+
+// OpResult summarizes the requests of one operation
+type OpResult struct {
+	Op          string  `json:"op"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	RateLimited int     `json:"rate_limited"` // HTTP 429, also counted in Errors
+	ErrorRate   float64 `json:"error_rate"`
+	P50         float64 `json:"p50_ms"`
+	P90         float64 `json:"p90_ms"`
+	P99         float64 `json:"p99_ms"`
+	Max         float64 `json:"max_ms"`
+	Throughput  float64 `json:"ops_per_sec"`
+}
+
+// Summary is the outcome of a load generation run
+type Summary struct {
+	Target     string     `json:"target"`
+	StartedAt  time.Time  `json:"started_at"`
+	ElapsedSec float64    `json:"elapsed_sec"`
+	Options    RunOptions `json:"options"`
+	Total      OpResult   `json:"total"`
+	Ops        []OpResult `json:"operations"`
+	Errors     []string   `json:"sample_errors,omitempty"`
+}
+
+// RunOptions records the settings a summary was produced with
+type RunOptions struct {
+	Concurrency int        `json:"concurrency"`
+	Requests    int        `json:"requests,omitempty"`
+	Duration    string     `json:"duration,omitempty"`
+	Mix         []MixEntry `json:"mix"`
+	FileSize    int        `json:"file_size"`
+	Seed        int64      `json:"seed"`
+}
+
+// maxSampleErrors bounds the distinct error messages kept for the report
+const maxSampleErrors = 10
+
+// sample is the outcome of a single request
+type sample struct {
+	op          string
+	latency     time.Duration
+	err         error
+	rateLimited bool
+}
+
+// Generator drives concurrent requests against a webui instance
+type Generator struct {
+	opts   Options
+	client *http.Client
+
+	mu       sync.Mutex
+	cids     []string
+	samples  []sample
+	errors   []string
+	errorSet map[string]bool
+	uploads  atomic.Int64
+}
+
+// NewGenerator validates the options and creates a generator
+func NewGenerator(opts Options) (*Generator, error) {
+	if _, err := url.Parse(opts.BaseURL); err != nil || opts.BaseURL == "" {
+		return nil, fmt.Errorf("invalid webui URL %q", opts.BaseURL)
+	}
+	if opts.Concurrency <= 0 {
+		return nil, fmt.Errorf("concurrency must be positive")
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, fmt.Errorf("either a duration or a request count is required")
+	}
+	if len(opts.Mix) == 0 {
+		return nil, fmt.Errorf("operation mix is empty")
+	}
+	if opts.FileSize <= 0 {
+		return nil, fmt.Errorf("file size must be positive")
+	}
+	if len(opts.Keywords) == 0 {
+		opts.Keywords = []string{"noisefs"}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.Concurrency
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Generator{
+		opts:     opts,
+		client:   &http.Client{Transport: transport, Timeout: opts.Timeout},
+		errorSet: make(map[string]bool),
+	}, nil
+}
+
+// Preload uploads files before the measured run so downloads have targets
+func (g *Generator) Preload(ctx context.Context) error {
+	rng := rand.New(rand.NewSource(g.opts.Seed))
+	for i := 0; i < g.opts.Preload; i++ {
+		cid, err := g.upload(ctx, rng)
+		if err != nil {
+			return fmt.Errorf("preload upload %d failed: %w", i+1, err)
+		}
+		g.addCID(cid)
+	}
+	return nil
+}
+
+// Run issues requests from Concurrency workers until the request count or
+// duration is reached, or ctx is cancelled
+func (g *Generator) Run(ctx context.Context) *Summary {
+	if g.opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.opts.Duration)
+		defer cancel()
+	}
+
+	var issued atomic.Int64
+	start := time.Now()
+	var wg sync.WaitGroup
+	for worker := 0; worker < g.opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			// Each worker has its own source so a seed reproduces the mix
+			rng := rand.New(rand.NewSource(g.opts.Seed + int64(worker) + 1))
+			for ctx.Err() == nil {
+				if g.opts.Requests > 0 && issued.Add(1) > int64(g.opts.Requests) {
+					return
+				}
+				g.record(g.do(ctx, pickOp(g.opts.Mix, rng), rng))
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	return g.summarize(start, time.Since(start))
+}
+
+// do performs one operation and measures it
+func (g *Generator) do(ctx context.Context, op string, rng *rand.Rand) sample {
+	// Downloads need something to fetch; upload first if nothing exists yet
+	var cid string
+	if op == OpDownload {
+		if cid = g.randomCID(rng); cid == "" {
+			op = OpUpload
+		}
+	}
+
+	start := time.Now()
+	var err error
+	switch op {
+	case OpUpload:
+		cid, err = g.upload(ctx, rng)
+		if err == nil {
+			g.addCID(cid)
+		}
+	case OpDownload:
+		err = g.download(ctx, cid)
+	case OpSearch:
+		err = g.searchAnnouncements(ctx, g.opts.Keywords[rng.Intn(len(g.opts.Keywords))])
+	}
+	latency := time.Since(start)
+
+	// Requests cut short by the end of the run are not failures of the server
+	if err != nil && ctx.Err() != nil {
+		return sample{}
+	}
+	var statusErr *statusError
+	rateLimited := errors.As(err, &statusErr) && statusErr.code == http.StatusTooManyRequests
+	return sample{op: op, latency: latency, err: err, rateLimited: rateLimited}
+}
+
+// record stores a sample, keeping a few distinct error messages
+func (g *Generator) record(s sample) {
+	if s.op == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.samples = append(g.samples, s)
+	if s.err != nil {
+		msg := fmt.Sprintf("%s: %v", s.op, s.err)
+		if !g.errorSet[msg] && len(g.errors) < maxSampleErrors {
+			g.errorSet[msg] = true
+			g.errors = append(g.errors, msg)
+		}
+	}
+}
+
+func (g *Generator) addCID(cid string) {
+	g.mu.Lock()
+	g.cids = append(g.cids, cid)
+	g.mu.Unlock()
+}
+
+func (g *Generator) randomCID(rng *rand.Rand) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.cids) == 0 {
+		return ""
+	}
+	return g.cids[rng.Intn(len(g.cids))]
+}
+
+// statusError is an unexpected HTTP status from the webui
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("HTTP %d: %s", e.code, e.message)
+	}
+	return fmt.Sprintf("HTTP %d", e.code)
+}
+
+// apiResponse covers the fields of the webui's JSON envelopes we inspect
+type apiResponse struct {
+	Success       bool   `json:"success"`
+	DescriptorCID string `json:"descriptor_cid"`
+	Error         string `json:"error"`
+}
+
+// send performs a request and decodes the webui's JSON response
+func (g *Generator) send(req *http.Request) (*apiResponse, error) {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result apiResponse
+	_ = json.Unmarshal(body, &result)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{code: resp.StatusCode, message: result.Error}
+	}
+	return &result, nil
+}
+
+// upload posts a generated text file to /api/upload
+func (g *Generator) upload(ctx context.Context, rng *rand.Rand) (string, error) {
+	n := g.uploads.Add(1)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fmt.Sprintf("loadgen-%d-%d.txt", g.opts.Seed, n))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(generateContent(rng, g.opts.FileSize)); err != nil {
+		return "", err
+	}
+	if g.opts.Topic != "" {
+		form.WriteField("topic", g.opts.Topic)
+		form.WriteField("tags", strings.Join(g.opts.Keywords, ","))
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.opts.BaseURL+"/api/upload", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	result, err := g.send(req)
+	if err != nil {
+		return "", err
+	}
+	if !result.Success || result.DescriptorCID == "" {
+		return "", fmt.Errorf("upload returned no descriptor CID: %s", result.Error)
+	}
+	return result.DescriptorCID, nil
+}
+
+// download fetches a file through /api/download/{cid} and discards it
+func (g *Generator) download(ctx context.Context, cid string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.opts.BaseURL+"/api/download/"+url.PathEscape(cid), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result apiResponse
+		json.NewDecoder(resp.Body).Decode(&result)
+		return &statusError{code: resp.StatusCode, message: result.Error}
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// searchAnnouncements runs a keyword query through /api/announcements/search
+func (g *Generator) searchAnnouncements(ctx context.Context, keyword string) error {
+	query, err := json.Marshal(map[string]interface{}{
+		"Keywords": []string{keyword},
+		"Limit":    20,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.opts.BaseURL+"/api/announcements/search", bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	result, err := g.send(req)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("search failed: %s", result.Error)
+	}
+	return nil
+}
+
+// generateContent returns printable text so uploads pass content policies
+// that reject unknown binary data
+func generateContent(rng *rand.Rand, size int) []byte {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789 \n"
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return data
+}
+
+// summarize computes per-operation and overall statistics
+func (g *Generator) summarize(start time.Time, elapsed time.Duration) *Summary {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	summary := &Summary{
+		Target:     g.opts.BaseURL,
+		StartedAt:  start,
+		ElapsedSec: elapsed.Seconds(),
+		Options: RunOptions{
+			Concurrency: g.opts.Concurrency,
+			Requests:    g.opts.Requests,
+			Mix:         g.opts.Mix,
+			FileSize:    g.opts.FileSize,
+			Seed:        g.opts.Seed,
+		},
+		Errors: append([]string(nil), g.errors...),
+	}
+	if g.opts.Duration > 0 {
+		summary.Options.Duration = g.opts.Duration.String()
+	}
+
+	byOp := make(map[string][]sample)
+	for _, s := range g.samples {
+		byOp[s.op] = append(byOp[s.op], s)
+	}
+	for _, entry := range []string{OpUpload, OpDownload, OpSearch} {
+		if samples, ok := byOp[entry]; ok {
+			summary.Ops = append(summary.Ops, summarizeSamples(entry, samples, elapsed))
+		}
+	}
+	summary.Total = summarizeSamples("total", g.samples, elapsed)
+	return summary
+}
+
+// summarizeSamples computes error rates and latency percentiles
+func summarizeSamples(op string, samples []sample, elapsed time.Duration) OpResult {
+	result := OpResult{Op: op, Requests: len(samples)}
+	if len(samples) == 0 {
+		return result
+	}
+
+	latencies := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		if s.err != nil {
+			result.Errors++
+			if s.rateLimited {
+				result.RateLimited++
+			}
+			continue
+		}
+		latencies = append(latencies, float64(s.latency)/float64(time.Millisecond))
+	}
+	result.ErrorRate = float64(result.Errors) / float64(len(samples))
+	if elapsed > 0 {
+		result.Throughput = float64(len(samples)-result.Errors) / elapsed.Seconds()
+	}
+
+	// Latency percentiles only cover successful requests: a burst of fast
+	// rejections would otherwise make an overloaded server look quick
+	sort.Float64s(latencies)
+	result.P50 = percentile(latencies, 50)
+	result.P90 = percentile(latencies, 90)
+	result.P99 = percentile(latencies, 99)
+	if len(latencies) > 0 {
+		result.Max = latencies[len(latencies)-1]
+	}
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("upload=1, download=4,search")
+	if err != nil {
+		t.Fatalf("ParseMix failed: %v", err)
+	}
+	want := []MixEntry{{OpUpload, 1}, {OpDownload, 4}, {OpSearch, 1}}
+	if fmt.Sprint(mix) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, mix)
+	}
+
+	for _, spec := range []string{"", "upload=0", "stream=1", "upload=x", "upload=1,upload=2"} {
+		if _, err := ParseMix(spec); err == nil {
+			t.Errorf("Expected error for mix %q", spec)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+	for p, want := range map[float64]float64{50: 50, 90: 90, 99: 99, 100: 100} {
+		if got := percentile(values, p); got != want {
+			t.Errorf("p%.0f: expected %.0f, got %.0f", p, want, got)
+		}
+	}
+	if got := percentile(nil, 99); got != 0 {
+		t.Errorf("Expected 0 for no samples, got %f", got)
+	}
+}
+
+// fakeWebUI serves the subset of the webui API the load generator uses
+type fakeWebUI struct {
+	mu        sync.Mutex
+	files     map[string]int
+	uploads   atomic.Int64
+	downloads atomic.Int64
+	searches  atomic.Int64
+	limitEach int64 // Reject every nth search with 429
+}
+
+func (f *fakeWebUI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/upload":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		file.Close()
+		cid := fmt.Sprintf("cid-%d", f.uploads.Add(1))
+		f.mu.Lock()
+		f.files[cid] = int(header.Size)
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "descriptor_cid": cid})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/download/"):
+		f.downloads.Add(1)
+		f.mu.Lock()
+		size, ok := f.files[strings.TrimPrefix(r.URL.Path, "/api/download/")]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "not found"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(make([]byte, size))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/announcements/search":
+		n := f.searches.Add(1)
+		if f.limitEach > 0 && n%f.limitEach == 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "rate limit exceeded"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": []interface{}{}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGeneratorRun(t *testing.T) {
+	fake := &fakeWebUI{files: make(map[string]int), limitEach: 5}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	generator, err := NewGenerator(Options{
+		BaseURL:     server.URL,
+		Concurrency: 4,
+		Requests:    200,
+		Mix:         []MixEntry{{OpUpload, 1}, {OpDownload, 2}, {OpSearch, 2}},
+		FileSize:    512,
+		Preload:     2,
+		Seed:        7,
+		Timeout:     5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator failed: %v", err)
+	}
+	if err := generator.Preload(context.Background()); err != nil {
+		t.Fatalf("Preload failed: %v", err)
+	}
+	summary := generator.Run(context.Background())
+
+	if summary.Total.Requests != 200 {
+		t.Errorf("Expected 200 requests, got %d", summary.Total.Requests)
+	}
+	if len(summary.Ops) != 3 {
+		t.Fatalf("Expected results for 3 operations, got %d", len(summary.Ops))
+	}
+
+	var search OpResult
+	for _, op := range summary.Ops {
+		if op.Op == OpSearch {
+			search = op
+		} else if op.Errors != 0 {
+			t.Errorf("%s: expected no errors, got %d (%v)", op.Op, op.Errors, summary.Errors)
+		}
+	}
+	if search.RateLimited == 0 || search.RateLimited != search.Errors {
+		t.Errorf("Expected rejected searches to be counted as rate limited, got %+v", search)
+	}
+	if summary.Total.ErrorRate <= 0 || summary.Total.ErrorRate >= 0.5 {
+		t.Errorf("Unexpected overall error rate %f", summary.Total.ErrorRate)
+	}
+
+	if violations := checkThresholds(summary, 0.01, 0); len(violations) != 1 {
+		t.Errorf("Expected the error rate threshold to be violated, got %v", violations)
+	}
+	if violations := checkThresholds(summary, 0.5, time.Minute); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+}
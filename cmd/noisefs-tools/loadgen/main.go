@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Exit codes of the load generator
+const (
+	exitOK        = 0
+	exitThreshold = 1 // Error rate or latency exceeded the given limits
+	exitUsage     = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("loadgen", flag.ContinueOnError)
+	var (
+		target       = flags.String("url", "http://localhost:8080", "Base URL of the running webui")
+		concurrency  = flags.Int("concurrency", 8, "Concurrent workers")
+		duration     = flags.Duration("duration", 30*time.Second, "How long to generate load (0 = until -requests are issued)")
+		requests     = flags.Int("requests", 0, "Stop after this many operations (0 = until -duration elapses)")
+		mixSpec      = flags.String("mix", "upload=1,download=4,search=2", "Operation weights: upload, download, search")
+		fileSize     = flags.Int("file-size", 64*1024, "Size of uploaded files in bytes")
+		keywords     = flags.String("keywords", "noisefs", "Comma-separated search terms")
+		topic        = flags.String("topic", "", "Announce uploads under this topic so searches find them")
+		preload      = flags.Int("preload", 5, "Files uploaded before measuring so downloads have targets")
+		seed         = flags.Int64("seed", 1, "Seed for the operation mix and file contents")
+		timeout      = flags.Duration("timeout", 30*time.Second, "Per-request timeout")
+		insecure     = flags.Bool("insecure", false, "Skip TLS certificate verification (self-signed webui)")
+		output       = flags.String("output", "", "Write the summary as JSON to this file (- for stdout)")
+		maxErrorRate = flags.Float64("max-error-rate", -1, "Exit with status 1 if the overall error rate exceeds this fraction")
+		maxP99       = flags.Duration("max-p99", 0, "Exit with status 1 if any operation's p99 latency exceeds this")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "NoiseFS WebUI Load Generator")
+		fmt.Fprintln(flags.Output(), "============================")
+		fmt.Fprintln(flags.Output(), "Drives concurrent uploads, downloads and announcement searches against a")
+		fmt.Fprintln(flags.Output(), "running webui and reports latency percentiles and error rates.")
+		fmt.Fprintln(flags.Output())
+		fmt.Fprintln(flags.Output(), "Examples:")
+		fmt.Fprintln(flags.Output(), "  loadgen -url http://localhost:8080 -duration 1m")
+		fmt.Fprintln(flags.Output(), "  loadgen -url https://noisefs.example.org -insecure -concurrency 32 -mix download=1")
+		fmt.Fprintln(flags.Output(), "  loadgen -requests 500 -max-error-rate 0.01 -max-p99 2s -output loadgen.json")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	mix, err := ParseMix(*mixSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -mix: %v\n", err)
+		return exitUsage
+	}
+
+	var terms []string
+	for _, keyword := range strings.Split(*keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			terms = append(terms, keyword)
+		}
+	}
+
+	opts := Options{
+		BaseURL:     *target,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		Mix:         mix,
+		FileSize:    *fileSize,
+		Keywords:    terms,
+		Topic:       *topic,
+		Preload:     *preload,
+		Seed:        *seed,
+		Timeout:     *timeout,
+		Insecure:    *insecure,
+	}
+	// An explicit request count without an explicit duration runs to completion
+	if *requests > 0 && !flagSet(flags, "duration") {
+		opts.Duration = 0
+	}
+
+	generator, err := NewGenerator(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid options: %v\n", err)
+		return exitUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Println("🚀 NoiseFS WebUI Load Generator")
+	fmt.Println("================================")
+	fmt.Printf("Target: %s\n", opts.BaseURL)
+	fmt.Printf("Workers: %d, mix: %s\n", opts.Concurrency, *mixSpec)
+	if opts.Requests > 0 {
+		fmt.Printf("Requests: %d\n", opts.Requests)
+	}
+	if opts.Duration > 0 {
+		fmt.Printf("Duration: %v\n", opts.Duration)
+	}
+	fmt.Println()
+
+	if opts.Preload > 0 && hasOp(mix, OpDownload) {
+		fmt.Printf("📤 Preloading %d files...\n", opts.Preload)
+		if err := generator.Preload(ctx); err != nil {
+			log.Printf("❌ %v", err)
+			return exitThreshold
+		}
+	}
+
+	fmt.Println("📊 Generating load...")
+	summary := generator.Run(ctx)
+	printSummary(summary)
+
+	if *output != "" {
+		if err := writeSummary(summary, *output); err != nil {
+			log.Printf("Failed to write summary: %v", err)
+			return exitUsage
+		}
+		if *output != "-" {
+			fmt.Printf("\n📄 Summary written to %s\n", *output)
+		}
+	}
+
+	if violations := checkThresholds(summary, *maxErrorRate, *maxP99); len(violations) > 0 {
+		fmt.Println()
+		for _, violation := range violations {
+			fmt.Printf("❌ %s\n", violation)
+		}
+		return exitThreshold
+	}
+	return exitOK
+}
+
+// flagSet reports whether a flag was given on the command line
+func flagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func hasOp(mix []MixEntry, op string) bool {
+	for _, entry := range mix {
+		if entry.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+// checkThresholds lists the limits a summary violates; a negative error
+// rate or zero latency disables the corresponding check
+func checkThresholds(summary *Summary, maxErrorRate float64, maxP99 time.Duration) []string {
+	var violations []string
+	if summary.Total.Requests == 0 {
+		return append(violations, "no requests completed")
+	}
+	if maxErrorRate >= 0 && summary.Total.ErrorRate > maxErrorRate {
+		violations = append(violations, fmt.Sprintf("error rate %.2f%% exceeds %.2f%%",
+			summary.Total.ErrorRate*100, maxErrorRate*100))
+	}
+	if maxP99 > 0 {
+		limit := float64(maxP99) / float64(time.Millisecond)
+		for _, op := range summary.Ops {
+			if op.P99 > limit {
+				violations = append(violations, fmt.Sprintf("%s p99 latency %.1fms exceeds %v", op.Op, op.P99, maxP99))
+			}
+		}
+	}
+	return violations
+}
+
+func printSummary(summary *Summary) {
+	fmt.Printf("\n📈 Results (%.1fs)\n", summary.ElapsedSec)
+	fmt.Printf("%-10s %8s %8s %7s %9s %9s %9s %9s %9s\n",
+		"operation", "requests", "errors", "429s", "p50 ms", "p90 ms", "p99 ms", "max ms", "ops/sec")
+	for _, op := range append(summary.Ops, summary.Total) {
+		fmt.Printf("%-10s %8d %8d %7d %9.1f %9.1f %9.1f %9.1f %9.1f\n",
+			op.Op, op.Requests, op.Errors, op.RateLimited, op.P50, op.P90, op.P99, op.Max, op.Throughput)
+	}
+	fmt.Printf("Error rate: %.2f%%\n", summary.Total.ErrorRate*100)
+
+	if len(summary.Errors) > 0 {
+		fmt.Println("\nSample errors:")
+		for _, msg := range summary.Errors {
+			fmt.Printf("  - %s\n", msg)
+		}
+	}
+}
+
+func writeSummary(summary *Summary, path string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
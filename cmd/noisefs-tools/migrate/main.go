@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+func main() {
+	var (
+		configFile       = flag.String("config", "", "Configuration file path")
+		ipfsAPI          = flag.String("ipfs", "", "IPFS API endpoint (default: from config)")
+		inputFile        = flag.String("input", "", "File with one CID or path per line, in addition to arguments")
+		mappingFile      = flag.String("mapping", "migration-mapping.json", "Mapping file of old CID -> descriptor CID (extended if it exists)")
+		blockSize        = flag.Int("block-size", 0, "Block size in bytes (default: from config)")
+		maxFileSize      = flag.Uint64("max-size", 0, "Skip files larger than this many bytes (0 = no limit)")
+		topic            = flag.String("announce-topic", "", "Announce each migrated file to this topic")
+		tags             = flag.String("announce-tags", "", "Comma-separated tags for announcements")
+		category         = flag.String("announce-category", "", "Announcement category (video, audio, document, data, software, other)")
+		ttl              = flag.Duration("announce-ttl", 24*time.Hour, "Time to live for announcements")
+		announceInterval = flag.Duration("announce-interval", time.Second, "Minimum time between announcements")
+		verbose          = flag.Bool("verbose", false, "Enable verbose output")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <cid|/ipfs/path|/mfs/path>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Downloads plain IPFS content and re-stores it through NoiseFS.\n")
+		fmt.Fprintf(os.Stderr, "Paths not starting with /ipfs/ or /ipns/ are read from MFS.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	refs := flag.Args()
+	if *inputFile != "" {
+		fileRefs, err := readRefs(*inputFile)
+		if err != nil {
+			log.Fatalf("Failed to read input file: %v", err)
+		}
+		refs = append(refs, fileRefs...)
+	}
+	if len(refs) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *ipfsAPI != "" {
+		cfg.IPFS.APIEndpoint = *ipfsAPI
+	}
+	if *blockSize != 0 {
+		cfg.Performance.BlockSize = *blockSize
+	}
+
+	fmt.Printf("🚚 NoiseFS Migration\n")
+	fmt.Printf("====================\n")
+	fmt.Printf("References: %d\n", len(refs))
+	fmt.Printf("IPFS Endpoint: %s\n", cfg.IPFS.APIEndpoint)
+	fmt.Printf("Block Size: %d bytes\n", cfg.Performance.BlockSize)
+	fmt.Printf("Mapping File: %s\n", *mappingFile)
+	if *topic != "" {
+		fmt.Printf("Announce Topic: %s\n", *topic)
+	}
+	fmt.Printf("\n")
+
+	storageConfig := cfg.StorageConfig()
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		log.Fatalf("Failed to create storage manager: %v", err)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer storageManager.Stop(context.Background())
+
+	ipfsShell, err := backends.NewIPFSShell(cfg.IPFSConnection())
+	if err != nil {
+		log.Fatalf("Failed to create IPFS client: %v", err)
+	}
+
+	blockCache := cache.NewMemoryCache(cfg.Cache.BlockCacheSize)
+	client, err := noisefs.NewClient(storageManager, blockCache)
+	if err != nil {
+		log.Fatalf("Failed to create NoiseFS client: %v", err)
+	}
+
+	options := Options{
+		MaxFileSize:      *maxFileSize,
+		AnnounceInterval: *announceInterval,
+		Verbose:          *verbose,
+	}
+	var announcer Announcer
+	if *topic != "" {
+		options.Announce = &announce.CreateOptions{
+			Topic:    *topic,
+			Tags:     splitTags(*tags),
+			Category: *category,
+			TTL:      *ttl,
+		}
+		announcer, err = dht.NewPublisher(dht.PublisherConfig{
+			StorageManager: storageManager,
+			IPFSShell:      ipfsShell,
+			PublishRate:    *announceInterval,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create announcement publisher: %v", err)
+		}
+	}
+
+	uploader := &blockSizeUploader{client: client, blockSize: cfg.Performance.BlockSize}
+	migrator, err := NewMigrator(NewIPFSSource(ipfsShell), uploader, announcer, *mappingFile, options)
+	if err != nil {
+		log.Fatalf("Failed to start migration: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	start := time.Now()
+	stats, err := migrator.Migrate(ctx, refs)
+
+	fmt.Printf("\n📊 Migration Summary\n")
+	fmt.Printf("====================\n")
+	fmt.Printf("Migrated: %d files (%.2f MB)\n", stats.Migrated, float64(stats.Bytes)/(1024*1024))
+	fmt.Printf("Skipped: %d\n", stats.Skipped)
+	fmt.Printf("Failed: %d\n", stats.Failed)
+	if options.Announce != nil {
+		fmt.Printf("Announced: %d\n", stats.Announced)
+	}
+	fmt.Printf("Duration: %v\n", time.Since(start).Round(time.Millisecond))
+	fmt.Printf("Mapping: %s\n", *mappingFile)
+
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Printf("\n⏸️  Interrupted; rerun the same command to continue\n")
+			os.Exit(130)
+		}
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if stats.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// blockSizeUploader uploads with the configured block size
+type blockSizeUploader struct {
+	client    *noisefs.Client
+	blockSize int
+}
+
+func (u *blockSizeUploader) Upload(ctx context.Context, reader io.Reader, filename string) (string, error) {
+	return u.client.UploadWithBlockSize(ctx, reader, filename, u.blockSize)
+}
+
+// readRefs reads one reference per line, ignoring blank lines and comments
+func readRefs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var refs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			refs = append(refs, line)
+		}
+	}
+	return refs, scanner.Err()
+}
+
+func splitTags(tags string) []string {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
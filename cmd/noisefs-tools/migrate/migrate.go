@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// MappingVersion is the format version written to mapping files
+const MappingVersion = "1.0"
+
+// Mapping records which descriptor each migrated IPFS CID became
+type Mapping struct {
+	Version string         `json:"version"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+	Entries []MappingEntry `json:"entries"`
+}
+
+// MappingEntry maps one IPFS file to its NoiseFS descriptor
+type MappingEntry struct {
+	Ref           string    `json:"ref"`
+	Path          string    `json:"path,omitempty"` // Path below Ref for files inside directories
+	OldCID        string    `json:"old_cid"`
+	DescriptorCID string    `json:"descriptor_cid,omitempty"`
+	Filename      string    `json:"filename"`
+	Size          uint64    `json:"size"`
+	Announced     bool      `json:"announced,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	MigratedAt    time.Time `json:"migrated_at"`
+}
+
+// NewMapping creates an empty mapping
+func NewMapping() *Mapping {
+	now := time.Now()
+	return &Mapping{Version: MappingVersion, Created: now, Updated: now}
+}
+
+// LoadMapping reads a mapping written by Save
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping Mapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file %s: %w", path, err)
+	}
+	return &mapping, nil
+}
+
+// Save writes the mapping atomically
+func (m *Mapping) Save(path string) error {
+	m.Updated = time.Now()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Lookup returns the successful entry for an IPFS CID, if any
+func (m *Mapping) Lookup(oldCID string) (MappingEntry, bool) {
+	for _, entry := range m.Entries {
+		if entry.OldCID == oldCID && entry.DescriptorCID != "" {
+			return entry, true
+		}
+	}
+	return MappingEntry{}, false
+}
+
+// record replaces any earlier entry for the same file, so retried failures
+// don't leave stale errors behind
+func (m *Mapping) record(entry MappingEntry) {
+	for i, existing := range m.Entries {
+		if existing.Ref == entry.Ref && existing.Path == entry.Path {
+			m.Entries[i] = entry
+			return
+		}
+	}
+	m.Entries = append(m.Entries, entry)
+}
+
+// forget drops the error recorded for a reference that failed to resolve
+func (m *Mapping) forget(ref string) {
+	for i, entry := range m.Entries {
+		if entry.Ref == ref && entry.Path == "" && entry.OldCID == "" {
+			m.Entries = append(m.Entries[:i], m.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Uploader stores content through NoiseFS anonymization
+type Uploader interface {
+	Upload(ctx context.Context, reader io.Reader, filename string) (string, error)
+}
+
+// Announcer publishes announcements for migrated content
+type Announcer interface {
+	Publish(ctx context.Context, announcement *announce.Announcement) error
+}
+
+// Options controls a migration run
+type Options struct {
+	MaxFileSize      uint64                  // Skip larger files (0 = no limit)
+	Announce         *announce.CreateOptions // Announce each migrated file when set
+	AnnounceInterval time.Duration           // Minimum time between announcements
+	Verbose          bool
+}
+
+// Stats summarizes a migration run
+type Stats struct {
+	Migrated  int
+	Skipped   int // Already in the mapping or over the size limit
+	Failed    int
+	Announced int
+	Bytes     uint64
+}
+
+// Migrator re-stores plain IPFS content through NoiseFS
+type Migrator struct {
+	source       Source
+	uploader     Uploader
+	announcer    Announcer
+	creator      *announce.Creator
+	mapping      *Mapping
+	mappingPath  string
+	options      Options
+	lastAnnounce time.Time
+}
+
+// NewMigrator creates a migrator that extends the mapping at mappingPath,
+// or starts a new one if it doesn't exist yet. announcer may be nil when
+// options.Announce is nil.
+func NewMigrator(source Source, uploader Uploader, announcer Announcer, mappingPath string, options Options) (*Migrator, error) {
+	if options.Announce != nil && announcer == nil {
+		return nil, errors.New("an announcer is required to announce migrated files")
+	}
+
+	mapping, err := LoadMapping(mappingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		mapping = NewMapping()
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		source:      source,
+		uploader:    uploader,
+		announcer:   announcer,
+		creator:     announce.NewCreator(),
+		mapping:     mapping,
+		mappingPath: mappingPath,
+		options:     options,
+	}, nil
+}
+
+// Mapping returns the mapping being built
+func (m *Migrator) Mapping() *Mapping {
+	return m.mapping
+}
+
+// Migrate resolves each reference and migrates the files it contains. A
+// failure to resolve or migrate one file is recorded in the mapping and
+// doesn't stop the run; only cancellation and mapping write errors do.
+func (m *Migrator) Migrate(ctx context.Context, refs []string) (*Stats, error) {
+	stats := &Stats{}
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		files, err := m.source.Resolve(ctx, ref)
+		if err != nil {
+			stats.Failed++
+			fmt.Printf("❌ %s: %v\n", ref, err)
+			m.mapping.record(MappingEntry{Ref: ref, Error: err.Error(), MigratedAt: time.Now()})
+			if err := m.mapping.Save(m.mappingPath); err != nil {
+				return stats, fmt.Errorf("failed to save mapping: %w", err)
+			}
+			continue
+		}
+		m.mapping.forget(ref)
+
+		for _, file := range files {
+			if err := ctx.Err(); err != nil {
+				return stats, err
+			}
+			if err := m.migrateFile(ctx, file, stats); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// migrateFile migrates one file and records the outcome
+func (m *Migrator) migrateFile(ctx context.Context, file SourceFile, stats *Stats) error {
+	label := file.Ref
+	if file.Path != "" {
+		label += "/" + file.Path
+	}
+
+	// The same content may appear under several references
+	if existing, done := m.mapping.Lookup(file.CID); done {
+		stats.Skipped++
+		if m.options.Verbose {
+			fmt.Printf("⏭️  %s already migrated as %s\n", label, existing.DescriptorCID)
+		}
+		changed := existing.Ref != file.Ref || existing.Path != file.Path
+		existing.Ref, existing.Path, existing.Filename = file.Ref, file.Path, file.Name
+
+		// Retry announcements that failed on an earlier run
+		if m.options.Announce != nil && !existing.Announced {
+			if err := m.announce(ctx, existing.DescriptorCID, file.Size); err != nil {
+				existing.Error = fmt.Sprintf("announce: %v", err)
+				fmt.Printf("⚠️  %s not announced: %v\n", label, err)
+			} else {
+				existing.Announced, existing.Error = true, ""
+				stats.Announced++
+			}
+			changed = true
+		}
+		if changed {
+			m.mapping.record(existing)
+			return m.saveMapping()
+		}
+		return nil
+	}
+	if m.options.MaxFileSize > 0 && file.Size > m.options.MaxFileSize {
+		stats.Skipped++
+		fmt.Printf("⏭️  %s is %d bytes, over the size limit\n", label, file.Size)
+		return nil
+	}
+
+	entry := MappingEntry{
+		Ref:        file.Ref,
+		Path:       file.Path,
+		OldCID:     file.CID,
+		Filename:   file.Name,
+		Size:       file.Size,
+		MigratedAt: time.Now(),
+	}
+
+	descriptorCID, err := m.upload(ctx, file)
+	if err != nil {
+		// Interrupted uploads are retried on the next run
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		stats.Failed++
+		entry.Error = err.Error()
+		fmt.Printf("❌ %s: %v\n", label, err)
+		m.mapping.record(entry)
+		return m.saveMapping()
+	}
+	entry.DescriptorCID = descriptorCID
+	stats.Migrated++
+	stats.Bytes += file.Size
+
+	if m.options.Announce != nil {
+		if err := m.announce(ctx, descriptorCID, file.Size); err != nil {
+			// The upload stands; the error tells the user to announce by hand
+			entry.Error = fmt.Sprintf("announce: %v", err)
+			fmt.Printf("⚠️  %s migrated but not announced: %v\n", label, err)
+		} else {
+			entry.Announced = true
+			stats.Announced++
+		}
+	}
+
+	fmt.Printf("✅ %s (%s) -> %s\n", label, file.CID, descriptorCID)
+	m.mapping.record(entry)
+	return m.saveMapping()
+}
+
+// upload streams a file from IPFS into NoiseFS
+func (m *Migrator) upload(ctx context.Context, file SourceFile) (string, error) {
+	reader, err := m.source.Open(ctx, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer reader.Close()
+
+	descriptorCID, err := m.uploader.Upload(ctx, reader, file.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+	return descriptorCID, nil
+}
+
+// announce publishes a descriptor, waiting out the announce interval first
+// since announcements to one topic are rate limited
+func (m *Migrator) announce(ctx context.Context, descriptorCID string, size uint64) error {
+	if wait := time.Until(m.lastAnnounce.Add(m.options.AnnounceInterval)); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	opts := *m.options.Announce
+	opts.Size = int64(size)
+	announcement, err := m.creator.CreateAnnouncement(descriptorCID, opts)
+	if err != nil {
+		return err
+	}
+	m.lastAnnounce = time.Now()
+	return m.announcer.Publish(ctx, announcement)
+}
+
+func (m *Migrator) saveMapping() error {
+	if err := m.mapping.Save(m.mappingPath); err != nil {
+		return fmt.Errorf("failed to save mapping: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// fakeSource serves files from memory, keyed by reference
+type fakeSource struct {
+	refs    map[string][]SourceFile
+	content map[string]string // CID -> content
+}
+
+func (s *fakeSource) Resolve(ctx context.Context, ref string) ([]SourceFile, error) {
+	files, ok := s.refs[ref]
+	if !ok {
+		return nil, fmt.Errorf("no link named %q", ref)
+	}
+	return files, nil
+}
+
+func (s *fakeSource) Open(ctx context.Context, file SourceFile) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(s.content[file.CID])), nil
+}
+
+// fakeUploader derives descriptor CIDs from the content and can fail once
+type fakeUploader struct {
+	uploads int
+	failOn  string
+}
+
+func (u *fakeUploader) Upload(ctx context.Context, reader io.Reader, filename string) (string, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	if filename == u.failOn {
+		u.failOn = ""
+		return "", errors.New("storage unavailable")
+	}
+	u.uploads++
+	return "desc-" + string(data), nil
+}
+
+type fakeAnnouncer struct {
+	published []*announce.Announcement
+}
+
+func (a *fakeAnnouncer) Publish(ctx context.Context, announcement *announce.Announcement) error {
+	a.published = append(a.published, announcement)
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	source := &fakeSource{
+		refs: map[string][]SourceFile{
+			"QmSingle": {{Ref: "QmSingle", CID: "QmSingle", Name: "QmSingle", Size: 5}},
+			"/docs": {
+				{Ref: "/docs", Path: "a.txt", CID: "QmA", Name: "a.txt", Size: 1},
+				{Ref: "/docs", Path: "sub/b.txt", CID: "QmB", Name: "b.txt", Size: 1},
+				// Same content as the single CID
+				{Ref: "/docs", Path: "copy", CID: "QmSingle", Name: "copy", Size: 5},
+			},
+		},
+		content: map[string]string{"QmSingle": "hello", "QmA": "a", "QmB": "b"},
+	}
+	uploader := &fakeUploader{failOn: "b.txt"}
+	announcer := &fakeAnnouncer{}
+	mappingPath := filepath.Join(t.TempDir(), "mapping.json")
+	options := Options{Announce: &announce.CreateOptions{Topic: "archive/docs"}}
+
+	migrator, err := NewMigrator(source, uploader, announcer, mappingPath, options)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	stats, err := migrator.Migrate(context.Background(), []string{"QmSingle", "/docs", "/missing"})
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if stats.Migrated != 2 || stats.Skipped != 1 || stats.Failed != 2 || stats.Announced != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(announcer.published) != 2 || announcer.published[0].Descriptor != "desc-hello" {
+		t.Errorf("Unexpected announcements: %+v", announcer.published)
+	}
+
+	mapping, err := LoadMapping(mappingPath)
+	if err != nil {
+		t.Fatalf("LoadMapping failed: %v", err)
+	}
+	if entry, ok := mapping.Lookup("QmA"); !ok || entry.DescriptorCID != "desc-a" || !entry.Announced {
+		t.Errorf("Expected QmA to map to desc-a, got %+v", entry)
+	}
+	if _, ok := mapping.Lookup("QmB"); ok {
+		t.Error("Failed upload must not be mapped")
+	}
+	copies := 0
+	for _, entry := range mapping.Entries {
+		if entry.OldCID == "QmSingle" && entry.DescriptorCID == "desc-hello" {
+			copies++
+		}
+	}
+	if copies != 2 {
+		t.Errorf("Expected both references to the same content to be mapped, got %d", copies)
+	}
+
+	// A rerun only retries what failed
+	source.refs["/missing"] = []SourceFile{{Ref: "/missing", CID: "QmA", Name: "missing", Size: 1}}
+	migrator, err = NewMigrator(source, uploader, announcer, mappingPath, options)
+	if err != nil {
+		t.Fatalf("NewMigrator failed on existing mapping: %v", err)
+	}
+	stats, err = migrator.Migrate(context.Background(), []string{"QmSingle", "/docs", "/missing"})
+	if err != nil {
+		t.Fatalf("Migrate failed on rerun: %v", err)
+	}
+	if stats.Migrated != 1 || stats.Failed != 0 || uploader.uploads != 3 {
+		t.Errorf("Unexpected rerun stats: %+v, %d uploads", stats, uploader.uploads)
+	}
+	for _, entry := range migrator.Mapping().Entries {
+		if entry.Error != "" {
+			t.Errorf("Expected no errors left after rerun, got %+v", entry)
+		}
+	}
+
+	if _, err := NewMigrator(source, uploader, nil, mappingPath, options); err == nil {
+		t.Error("Expected an error when announcing without an announcer")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	shell "github.com/ipfs/go-ipfs-api"
+)
+
+// SourceFile is one file found under a migration reference
+type SourceFile struct {
+	Ref  string // Reference as given by the user (CID, /ipfs/ path or MFS path)
+	Path string // Path below Ref for files inside directories, empty otherwise
+	CID  string // IPFS CID of the file content
+	Name string // File name used for the NoiseFS descriptor
+	Size uint64
+}
+
+// Source lists and reads plain IPFS content
+type Source interface {
+	// Resolve expands a reference into the files it contains
+	Resolve(ctx context.Context, ref string) ([]SourceFile, error)
+	// Open streams the content of a resolved file
+	Open(ctx context.Context, file SourceFile) (io.ReadCloser, error)
+}
+
+// IPFSSource reads content through the IPFS HTTP API
+type IPFSSource struct {
+	shell *shell.Shell
+}
+
+// NewIPFSSource creates a source reading through an IPFS API client
+func NewIPFSSource(sh *shell.Shell) *IPFSSource {
+	return &IPFSSource{shell: sh}
+}
+
+// Resolve accepts a bare CID, an /ipfs/ or /ipns/ path, or an MFS path.
+// Directories are expanded recursively.
+func (s *IPFSSource) Resolve(ctx context.Context, ref string) ([]SourceFile, error) {
+	statPath := ref
+	if !strings.HasPrefix(ref, "/") {
+		statPath = "/ipfs/" + ref
+	}
+
+	stat, err := s.shell.FilesStat(ctx, statPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", ref, err)
+	}
+
+	if stat.Type != "directory" {
+		return []SourceFile{{
+			Ref:  ref,
+			CID:  stat.Hash,
+			Name: refName(ref, stat.Hash),
+			Size: stat.Size,
+		}}, nil
+	}
+
+	var files []SourceFile
+	if err := s.walk(ctx, ref, stat.Hash, "", &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walk appends the files below the directory dirCID
+func (s *IPFSSource) walk(ctx context.Context, ref, dirCID, prefix string, files *[]SourceFile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	links, err := s.shell.List("/ipfs/" + dirCID)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", path.Join(ref, prefix), err)
+	}
+
+	for _, link := range links {
+		linkPath := path.Join(prefix, link.Name)
+		if link.Type == shell.TDirectory {
+			if err := s.walk(ctx, ref, link.Hash, linkPath, files); err != nil {
+				return err
+			}
+			continue
+		}
+		*files = append(*files, SourceFile{
+			Ref:  ref,
+			Path: linkPath,
+			CID:  link.Hash,
+			Name: link.Name,
+			Size: link.Size,
+		})
+	}
+	return nil
+}
+
+// Open streams a file by its CID
+func (s *IPFSSource) Open(ctx context.Context, file SourceFile) (io.ReadCloser, error) {
+	return s.shell.Cat("/ipfs/" + file.CID)
+}
+
+// refName picks a descriptor file name for a reference that isn't inside a
+// directory: the last path element, or the CID for bare CIDs
+func refName(ref, cid string) string {
+	name := path.Base(strings.TrimSuffix(ref, "/"))
+	if name == "" || name == "." || name == "/" {
+		return cid
+	}
+	return name
+}
@@ -0,0 +1,245 @@
+//go:build js && wasm
+
+// noisefs-wasm exposes the NoiseFS anonymization pipeline to JavaScript, so
+// a browser can anonymize files itself and upload only anonymized blocks.
+// Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o noisefs.wasm ./cmd/noisefs-wasm
+//
+// and load it with wasm_exec.js from $(go env GOROOT)/lib/wasm. It defines
+// a global noisefs object; functions that fail return {error: "..."}.
+//
+//	anonymize(content, filename, blockSize, pool)
+//	    Splits content (a Uint8Array) into blocks and XORs each with two
+//	    randomizers. blockSize 0 uses the default. pool lists stored
+//	    randomizers to reuse as [{cid, data}]. Returns {filename, fileSize,
+//	    blockSize, blocks, tuples, randomizers}: blocks[i] is the anonymized
+//	    block i, tuples[i] the indexes of its randomizers, and randomizers
+//	    [{cid, data}], where those without a cid are new and must be
+//	    uploaded too.
+//	descriptor(file, dataCIDs, randomizerCIDs)
+//	    Returns the descriptor JSON for a file returned by anonymize, given
+//	    the CIDs its blocks and new randomizers were stored under.
+//	split(content, blockSize)
+//	    Returns content split into zero-padded blocks.
+//	xor3(a, b, c)
+//	    Returns a XOR b XOR c, which reconstructs a file block from a
+//	    stored block and its randomizers.
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/anonymize"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+func main() {
+	js.Global().Set("noisefs", js.ValueOf(map[string]interface{}{
+		"version":    "1",
+		"anonymize":  jsFunc(anonymizeFunc),
+		"descriptor": jsFunc(descriptorFunc),
+		"split":      jsFunc(splitFunc),
+		"xor3":       jsFunc(xor3Func),
+	}))
+
+	// Keep the functions available for the life of the page
+	select {}
+}
+
+// jsFunc wraps fn so that errors and panics reach JavaScript as {error}
+func jsFunc(fn func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) (result interface{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = errorValue(fmt.Errorf("%v", r))
+			}
+		}()
+		value, err := fn(args)
+		if err != nil {
+			return errorValue(err)
+		}
+		return value
+	})
+}
+
+func errorValue(err error) interface{} {
+	return map[string]interface{}{"error": err.Error()}
+}
+
+// arg returns args[i], or undefined if it wasn't passed
+func arg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+func anonymizeFunc(args []js.Value) (interface{}, error) {
+	content, err := bytesArg(arg(args, 0), "content")
+	if err != nil {
+		return nil, err
+	}
+	filename := arg(args, 1)
+	if filename.Type() != js.TypeString {
+		return nil, fmt.Errorf("filename must be a string")
+	}
+	blockSize := blockSizeArg(arg(args, 2))
+
+	var pool []anonymize.Randomizer
+	if poolValue := arg(args, 3); poolValue.Truthy() {
+		for i := 0; i < poolValue.Length(); i++ {
+			entry := poolValue.Index(i)
+			data, err := bytesArg(entry.Get("data"), fmt.Sprintf("pool[%d].data", i))
+			if err != nil {
+				return nil, err
+			}
+			pool = append(pool, anonymize.Randomizer{CID: entry.Get("cid").String(), Data: data})
+		}
+	}
+
+	file, err := anonymize.Anonymize(content, filename.String(), blockSize, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	dataBlocks := make([]interface{}, len(file.Tuples))
+	tuples := make([]interface{}, len(file.Tuples))
+	for i, tuple := range file.Tuples {
+		dataBlocks[i] = bytesValue(tuple.Data)
+		tuples[i] = []interface{}{tuple.Randomizers[0], tuple.Randomizers[1]}
+	}
+	randomizers := make([]interface{}, len(file.Randomizers))
+	for i, r := range file.Randomizers {
+		randomizer := map[string]interface{}{"data": bytesValue(r.Data)}
+		if r.CID != "" {
+			randomizer["cid"] = r.CID
+		}
+		randomizers[i] = randomizer
+	}
+
+	return map[string]interface{}{
+		"filename":    file.Filename,
+		"fileSize":    file.FileSize,
+		"blockSize":   file.BlockSize,
+		"blocks":      dataBlocks,
+		"tuples":      tuples,
+		"randomizers": randomizers,
+	}, nil
+}
+
+func descriptorFunc(args []js.Value) (interface{}, error) {
+	value := arg(args, 0)
+	if value.Type() != js.TypeObject {
+		return nil, fmt.Errorf("file must be the result of anonymize")
+	}
+
+	// Only the layout is needed, not the block contents
+	file := &anonymize.File{
+		Filename:  value.Get("filename").String(),
+		FileSize:  int64(value.Get("fileSize").Int()),
+		BlockSize: value.Get("blockSize").Int(),
+	}
+	tuples := value.Get("tuples")
+	for i := 0; i < tuples.Length(); i++ {
+		tuple := tuples.Index(i)
+		file.Tuples = append(file.Tuples, anonymize.Tuple{Randomizers: [2]int{tuple.Index(0).Int(), tuple.Index(1).Int()}})
+	}
+	randomizers := value.Get("randomizers")
+	for i := 0; i < randomizers.Length(); i++ {
+		cid := randomizers.Index(i).Get("cid")
+		r := anonymize.Randomizer{}
+		if cid.Type() == js.TypeString {
+			r.CID = cid.String()
+		}
+		file.Randomizers = append(file.Randomizers, r)
+	}
+
+	descriptor, err := file.Descriptor(stringsArg(arg(args, 1)), stringsArg(arg(args, 2)))
+	if err != nil {
+		return nil, err
+	}
+	data, err := descriptor.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func splitFunc(args []js.Value) (interface{}, error) {
+	content, err := bytesArg(arg(args, 0), "content")
+	if err != nil {
+		return nil, err
+	}
+	splitter, err := blocks.NewSplitter(blockSizeArg(arg(args, 1)))
+	if err != nil {
+		return nil, err
+	}
+	split, err := splitter.SplitBytes(content)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(split))
+	for i, block := range split {
+		result[i] = bytesValue(block.Data)
+	}
+	return result, nil
+}
+
+func xor3Func(args []js.Value) (interface{}, error) {
+	var parts [3][]byte
+	for i := range parts {
+		data, err := bytesArg(arg(args, i), fmt.Sprintf("argument %d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = data
+	}
+	block, err := anonymize.Reconstruct(parts[0], parts[1], parts[2])
+	if err != nil {
+		return nil, err
+	}
+	return bytesValue(block), nil
+}
+
+// blockSizeArg returns the block size passed, or the default for 0 or
+// undefined
+func blockSizeArg(value js.Value) int {
+	if value.Type() != js.TypeNumber || value.Int() == 0 {
+		return blocks.DefaultBlockSize
+	}
+	return value.Int()
+}
+
+// bytesArg copies a Uint8Array into Go
+func bytesArg(value js.Value, name string) ([]byte, error) {
+	if !value.InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, fmt.Errorf("%s must be a Uint8Array", name)
+	}
+	data := make([]byte, value.Length())
+	js.CopyBytesToGo(data, value)
+	return data, nil
+}
+
+// bytesValue copies data into a new Uint8Array
+func bytesValue(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+// stringsArg converts an array of strings, treating missing entries as
+// empty
+func stringsArg(value js.Value) []string {
+	if !value.Truthy() {
+		return nil
+	}
+	result := make([]string, value.Length())
+	for i := range result {
+		if entry := value.Index(i); entry.Type() == js.TypeString {
+			result[i] = entry.String()
+		}
+	}
+	return result
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/gorilla/mux"
+)
+
+// registerAdminRoutes adds the /api/admin endpoints, which require the
+// configured admin token or an admin API token
+func (w *UnifiedWebUI) registerAdminRoutes(api *mux.Router) {
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(w.requireAdmin)
+
+	admin.HandleFunc("/subscriptions", w.handleAdminListSubscriptions).Methods("GET")
+	admin.HandleFunc("/subscriptions", w.handleAdminCreateSubscription).Methods("POST")
+	admin.HandleFunc("/subscriptions/export", w.handleAdminExportSubscriptions).Methods("GET")
+	admin.HandleFunc("/subscriptions/import", w.handleAdminImportSubscriptions).Methods("POST")
+	// Topics contain slashes, so the rest of the path is the topic
+	admin.HandleFunc("/subscriptions/{topic:.+}", w.handleAdminGetSubscription).Methods("GET")
+	admin.HandleFunc("/subscriptions/{topic:.+}", w.handleAdminUpdateSubscription).Methods("PUT")
+	admin.HandleFunc("/subscriptions/{topic:.+}", w.handleAdminDeleteSubscription).Methods("DELETE")
+
+	admin.HandleFunc("/takedowns", w.handleAdminListTakedowns).Methods("GET")
+	admin.HandleFunc("/takedowns", w.handleAdminRecordTakedown).Methods("POST")
+	admin.HandleFunc("/takedowns/{cid}/reinstate", w.handleAdminReinstate).Methods("POST")
+
+	admin.HandleFunc("/tokens", w.handleAdminListTokens).Methods("GET")
+	admin.HandleFunc("/tokens", w.handleAdminCreateToken).Methods("POST")
+	admin.HandleFunc("/tokens/{id}", w.handleAdminRevokeToken).Methods("DELETE")
+
+	admin.HandleFunc("/usage", w.handleAdminUsage).Methods("GET")
+}
+
+// requireAdmin rejects requests without the admin token or an API token
+// with the admin role
+func (w *UnifiedWebUI) requireAdmin(next http.Handler) http.Handler {
+	return w.requireRole(auth.RoleAdmin, next)
+}
+
+func (w *UnifiedWebUI) handleAdminListSubscriptions(wr http.ResponseWriter, r *http.Request) {
+	sendJSON(wr, APIResponse{Success: true, Data: w.allSubscriptions()})
+}
+
+func (w *UnifiedWebUI) handleAdminGetSubscription(wr http.ResponseWriter, r *http.Request) {
+	topic := mux.Vars(r)["topic"]
+	sub, ok := w.findSubscription(topic)
+	if !ok {
+		sendError(wr, fmt.Errorf("not subscribed to %s", topic), http.StatusNotFound)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: sub})
+}
+
+func (w *UnifiedWebUI) handleAdminCreateSubscription(wr http.ResponseWriter, r *http.Request) {
+	var sub config.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		sendError(wr, fmt.Errorf("invalid subscription: %w", err), http.StatusBadRequest)
+		return
+	}
+	sub.Topic = strings.TrimSpace(sub.Topic)
+	if sub.Topic == "" {
+		sendError(wr, fmt.Errorf("topic is required"), http.StatusBadRequest)
+		return
+	}
+	if _, exists := w.findSubscription(sub.Topic); exists {
+		sendError(wr, fmt.Errorf("already subscribed to %s", sub.Topic), http.StatusConflict)
+		return
+	}
+
+	if err := w.setSubscription(r, sub.Topic, true); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	created, _ := w.findSubscription(sub.Topic)
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusCreated)
+	json.NewEncoder(wr).Encode(APIResponse{Success: true, Data: created})
+}
+
+// handleAdminUpdateSubscription pauses or resumes a subscription
+func (w *UnifiedWebUI) handleAdminUpdateSubscription(wr http.ResponseWriter, r *http.Request) {
+	topic := mux.Vars(r)["topic"]
+	var update struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Active == nil {
+		sendError(wr, fmt.Errorf(`body must be {"active": true|false}`), http.StatusBadRequest)
+		return
+	}
+	if _, exists := w.findSubscription(topic); !exists {
+		sendError(wr, fmt.Errorf("not subscribed to %s", topic), http.StatusNotFound)
+		return
+	}
+
+	if err := w.setSubscription(r, topic, *update.Active); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	updated, _ := w.findSubscription(topic)
+	sendJSON(wr, APIResponse{Success: true, Data: updated})
+}
+
+func (w *UnifiedWebUI) handleAdminDeleteSubscription(wr http.ResponseWriter, r *http.Request) {
+	topic := mux.Vars(r)["topic"]
+	if _, exists := w.findSubscription(topic); !exists {
+		sendError(wr, fmt.Errorf("not subscribed to %s", topic), http.StatusNotFound)
+		return
+	}
+	if err := w.removeSubscription(r, topic); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// handleAdminExportSubscriptions returns the subscriptions in the
+// subscriptions.json format accepted by import
+func (w *UnifiedWebUI) handleAdminExportSubscriptions(wr http.ResponseWriter, r *http.Request) {
+	export := config.NewSubscriptions()
+	export.Subscriptions = w.allSubscriptions()
+
+	wr.Header().Set("Content-Disposition", `attachment; filename="subscriptions.json"`)
+	sendJSON(wr, export)
+}
+
+// ImportResult summarizes a bulk subscription import
+type ImportResult struct {
+	Subscribed []string          `json:"subscribed"`
+	Paused     []string          `json:"paused"`
+	Removed    []string          `json:"removed"`
+	Unchanged  int               `json:"unchanged"`
+	Failed     map[string]string `json:"failed,omitempty"`
+}
+
+// handleAdminImportSubscriptions applies exported subscriptions. By default
+// topics missing from the import are kept; with ?mode=replace they are
+// removed, so the node ends up with exactly the imported set.
+func (w *UnifiedWebUI) handleAdminImportSubscriptions(wr http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "merge" && mode != "replace" {
+		sendError(wr, fmt.Errorf("invalid mode %q (use merge or replace)", mode), http.StatusBadRequest)
+		return
+	}
+
+	imported := config.NewSubscriptions()
+	if err := json.NewDecoder(r.Body).Decode(imported); err != nil {
+		sendError(wr, fmt.Errorf("invalid subscriptions: %w", err), http.StatusBadRequest)
+		return
+	}
+	wanted := make(map[string]bool, len(imported.Subscriptions))
+	for _, sub := range imported.Subscriptions {
+		topic := strings.TrimSpace(sub.Topic)
+		if topic == "" {
+			sendError(wr, fmt.Errorf("every subscription needs a topic"), http.StatusBadRequest)
+			return
+		}
+		wanted[topic] = sub.Active
+	}
+
+	result := ImportResult{Subscribed: []string{}, Paused: []string{}, Removed: []string{}}
+	fail := func(topic string, err error) {
+		if result.Failed == nil {
+			result.Failed = make(map[string]string)
+		}
+		result.Failed[topic] = err.Error()
+	}
+
+	for topic, active := range wanted {
+		if current, exists := w.findSubscription(topic); exists && current.Active == active {
+			result.Unchanged++
+			continue
+		}
+		if err := w.setSubscription(r, topic, active); err != nil {
+			fail(topic, err)
+		} else if active {
+			result.Subscribed = append(result.Subscribed, topic)
+		} else {
+			result.Paused = append(result.Paused, topic)
+		}
+	}
+
+	if mode == "replace" {
+		for _, sub := range w.allSubscriptions() {
+			if _, keep := wanted[sub.Topic]; keep {
+				continue
+			}
+			if err := w.removeSubscription(r, sub.Topic); err != nil {
+				fail(sub.Topic, err)
+			} else {
+				result.Removed = append(result.Removed, sub.Topic)
+			}
+		}
+	}
+
+	sendJSON(wr, APIResponse{Success: len(result.Failed) == 0, Data: result})
+}
+
+// allSubscriptions returns a copy of the saved subscriptions
+func (w *UnifiedWebUI) allSubscriptions() []config.Subscription {
+	w.subMutex.RLock()
+	defer w.subMutex.RUnlock()
+	return w.subscriptions.GetAll()
+}
+
+// findSubscription returns the saved subscription for a topic
+func (w *UnifiedWebUI) findSubscription(topic string) (config.Subscription, bool) {
+	for _, sub := range w.allSubscriptions() {
+		if sub.Topic == topic {
+			return sub, true
+		}
+	}
+	return config.Subscription{}, false
+}
+
+// setSubscription subscribes to or pauses a topic and saves its state
+func (w *UnifiedWebUI) setSubscription(r *http.Request, topic string, active bool) error {
+	current, exists := w.findSubscription(topic)
+	details := map[string]string{"via": "admin", "topic_hash": announce.HashTopic(topic)}
+
+	if active {
+		if !exists || !current.Active {
+			err := w.subscribeTopic(topic)
+			w.audit(r, logging.AuditSubscribe, topic, err, details)
+			if err != nil {
+				return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+			}
+		}
+	} else {
+		if exists && current.Active {
+			w.dhtSubscriber.Unsubscribe(topic)
+			w.pubsubSubscriber.Unsubscribe(topic)
+			w.audit(r, logging.AuditUnsubscribe, topic, nil, details)
+		}
+		if !exists {
+			// Paused subscriptions are still recorded
+			w.subMutex.Lock()
+			w.subscriptions.Add(config.Subscription{Topic: topic, TopicHash: announce.HashTopic(topic)})
+			w.subMutex.Unlock()
+		}
+	}
+
+	w.saveSubscription(topic, active)
+	return nil
+}
+
+// removeSubscription unsubscribes from a topic and forgets it
+func (w *UnifiedWebUI) removeSubscription(r *http.Request, topic string) error {
+	current, exists := w.findSubscription(topic)
+	if !exists {
+		return fmt.Errorf("not subscribed to %s", topic)
+	}
+	if current.Active {
+		w.dhtSubscriber.Unsubscribe(topic)
+		w.pubsubSubscriber.Unsubscribe(topic)
+	}
+
+	w.subMutex.Lock()
+	defer w.subMutex.Unlock()
+	if err := w.subscriptions.Remove(topic); err != nil {
+		return err
+	}
+	w.audit(r, logging.AuditUnsubscribe, topic, nil, map[string]string{"via": "admin", "removed": "true"})
+	return config.SaveSubscriptions(config.GetConfigDir()+"/subscriptions.json", w.subscriptions)
+}
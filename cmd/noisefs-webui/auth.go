@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+	"github.com/gorilla/mux"
+)
+
+// routeRoles are the roles API routes need when webui.require_tokens is
+// set, by method and path template. Unlisted routes need a viewer to read
+// and an uploader to change anything; an empty role serves them without a
+// token. The admin API checks its own role.
+var routeRoles = map[string]auth.Role{
+	"GET /api/disclaimer":   "",
+	"GET /api/transparency": "",
+	"GET /api/gateway":      "",
+	"GET /api/session":      "",
+	"POST /api/session":     "",
+	"DELETE /api/session":   "",
+	// Share links are the recipient's credential
+	"POST /api/shares/{id}/redeem":  "",
+	"GET /api/shares/{id}/download": "",
+
+	// Share IDs are bearer credentials and transfers may hold decrypted
+	// files, so listing or reading them needs what creating them does
+	"GET /api/shares":              auth.RoleUploader,
+	"GET /api/transfers":           auth.RoleUploader,
+	"GET /api/transfers/{id}":      auth.RoleUploader,
+	"GET /api/transfers/{id}/file": auth.RoleUploader,
+
+	// Reads that take a body
+	"POST /api/info":                 auth.RoleViewer,
+	"POST /api/announcements/search": auth.RoleViewer,
+
+	// What the node follows and fetches on its own
+	"POST /api/disclaimer/accept":          auth.RoleAdmin,
+	"POST /api/topics/{topic}/subscribe":   auth.RoleAdmin,
+	"POST /api/topics/{topic}/unsubscribe": auth.RoleAdmin,
+	"PUT /api/autofetch":                   auth.RoleAdmin,
+	"POST /api/autofetch/rules":            auth.RoleAdmin,
+	"DELETE /api/autofetch/rules/{name}":   auth.RoleAdmin,
+}
+
+// requiredRole returns the role a request's route needs
+func requiredRole(r *http.Request) auth.Role {
+	var template string
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+	if role, ok := routeRoles[r.Method+" "+template]; ok {
+		return role
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return auth.RoleViewer
+	}
+	return auth.RoleUploader
+}
+
+// tokenContextKey holds the authenticated token in a request's context
+type tokenContextKey struct{}
+
+// requestTokenInfo returns the token a request was authenticated with, or
+// nil when it wasn't or used the admin token
+func requestTokenInfo(r *http.Request) *auth.Token {
+	token, _ := r.Context().Value(tokenContextKey{}).(*auth.Token)
+	return token
+}
+
+// authenticate returns the role of a request's token, sent in a header or
+// through a browser session
+func (w *UnifiedWebUI) authenticate(r *http.Request) (auth.Role, *auth.Token, error) {
+	secret := validation.RequestToken(r)
+	if secret == "" {
+		secret = w.sessionSecret(r)
+	}
+	if secret == "" {
+		return "", nil, errors.New("API token required; sign in at /login")
+	}
+	return w.authenticateSecret(secret)
+}
+
+// authenticateSecret returns the role of a token. The admin token is an
+// admin without a token record.
+func (w *UnifiedWebUI) authenticateSecret(secret string) (auth.Role, *auth.Token, error) {
+	adminToken := w.config.WebUI.AdminToken
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(adminToken)) == 1 {
+		return auth.RoleAdmin, nil, nil
+	}
+	token, err := w.tokens.Authenticate(secret)
+	if err != nil {
+		return "", nil, err
+	}
+	return token.Role, token, nil
+}
+
+// requireRole rejects requests without a token of at least role
+func (w *UnifiedWebUI) requireRole(role auth.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		granted, token, err := w.authenticate(r)
+		if err != nil {
+			w.audit(r, logging.AuditAuthFailure, r.URL.Path, err, nil)
+			wr.Header().Set("WWW-Authenticate", `Bearer realm="noisefs"`)
+			sendError(wr, err, http.StatusUnauthorized)
+			return
+		}
+		if token != nil {
+			r = r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token))
+			r = validation.WithAuthenticatedToken(r, token.ID)
+		} else {
+			r = validation.WithAuthenticatedToken(r, "admin")
+		}
+		if !granted.Allows(role) {
+			err := fmt.Errorf("this endpoint needs the %s role; the token has %s", role, granted)
+			w.audit(r, logging.AuditAccessDenied, r.URL.Path, err, map[string]string{"reason": "role"})
+			sendError(wr, err, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(wr, r)
+	})
+}
+
+// authorize enforces routeRoles on the API
+func (w *UnifiedWebUI) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		role := requiredRole(r)
+		if role == "" {
+			next.ServeHTTP(wr, r)
+			return
+		}
+		w.requireRole(role, next).ServeHTTP(wr, r)
+	})
+}
+
+// TokenRequest creates an API token
+type TokenRequest struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	ExpiresIn string `json:"expires_in,omitempty"` // Such as "720h"; no expiry when empty
+}
+
+// CreatedToken is a new token with its secret, which is only shown once
+type CreatedToken struct {
+	*auth.Token
+	Secret string `json:"secret"`
+}
+
+func (w *UnifiedWebUI) handleAdminListTokens(wr http.ResponseWriter, r *http.Request) {
+	tokens, err := w.tokens.List()
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: tokens})
+}
+
+func (w *UnifiedWebUI) handleAdminCreateToken(wr http.ResponseWriter, r *http.Request) {
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, fmt.Errorf("invalid token request: %w", err), http.StatusBadRequest)
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	var ttl time.Duration
+	if req.ExpiresIn != "" {
+		ttl, err = time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			sendError(wr, fmt.Errorf("invalid expires_in %q", req.ExpiresIn), http.StatusBadRequest)
+			return
+		}
+	}
+
+	token, secret, err := w.tokens.Create(req.Name, role, ttl)
+	w.audit(r, logging.AuditTokenCreate, req.Name, err, map[string]string{"role": string(role)})
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusCreated)
+	json.NewEncoder(wr).Encode(APIResponse{Success: true, Data: CreatedToken{Token: token, Secret: secret}})
+}
+
+func (w *UnifiedWebUI) handleAdminRevokeToken(wr http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	err := w.tokens.Revoke(id)
+	w.audit(r, logging.AuditTokenRevoke, id, err, nil)
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		sendError(wr, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
+	noisefsConfig "github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/gorilla/mux"
+)
+
+func TestAuthorizeSharesAndTransfers(t *testing.T) {
+	cfg := noisefsConfig.DefaultConfig()
+	cfg.WebUI.RequireTokens = true
+	webui := &UnifiedWebUI{config: cfg, tokens: auth.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))}
+	_, viewer, err := webui.tokens.Create("viewer", auth.RoleViewer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, uploader, err := webui.tokens.Create("uploader", auth.RoleUploader, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := func(wr http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(webui.authorize)
+	api.HandleFunc("/shares", ok).Methods("GET")
+	api.HandleFunc("/shares/{id}/redeem", ok).Methods("POST")
+	api.HandleFunc("/transfers", ok).Methods("GET")
+	api.HandleFunc("/transfers/{id}", ok).Methods("GET")
+	api.HandleFunc("/transfers/{id}/file", ok).Methods("GET")
+	api.HandleFunc("/announcements", ok).Methods("GET")
+
+	for _, tc := range []struct {
+		method, path, secret string
+		want                 int
+	}{
+		{"GET", "/api/shares", viewer, http.StatusForbidden},
+		{"GET", "/api/transfers", viewer, http.StatusForbidden},
+		{"GET", "/api/transfers/t1", viewer, http.StatusForbidden},
+		{"GET", "/api/transfers/t1/file", viewer, http.StatusForbidden},
+		{"GET", "/api/shares", uploader, http.StatusOK},
+		{"GET", "/api/transfers/t1/file", uploader, http.StatusOK},
+		{"GET", "/api/announcements", viewer, http.StatusOK},
+		{"POST", "/api/shares/s1/redeem", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.secret != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.secret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: got %d, want %d", tc.method, tc.path, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/gorilla/mux"
+)
+
+// collectionFetchTimeout bounds fetching a collection announced to a
+// subscribed topic
+const collectionFetchTimeout = 30 * time.Second
+
+// CollectionRequest publishes a collection, or a new revision of one this
+// node curates when the name is reused
+type CollectionRequest struct {
+	Name        string                     `json:"name"`
+	Title       string                     `json:"title"`
+	Description string                     `json:"description,omitempty"`
+	Topic       string                     `json:"topic"`
+	Entries     []announce.CollectionEntry `json:"entries"`
+	TTL         int64                      `json:"ttl,omitempty"` // Seconds the announcement lasts
+}
+
+// CollectionView is a collection as the API shows it. Lists leave the
+// entries out.
+type CollectionView struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Title       string                     `json:"title"`
+	Description string                     `json:"description,omitempty"`
+	Topic       string                     `json:"topic,omitempty"`
+	TopicHash   string                     `json:"topicHash"`
+	Curator     string                     `json:"curator"`
+	Own         bool                       `json:"own"` // Curated with this node's key
+	Revision    int64                      `json:"revision"`
+	Updated     time.Time                  `json:"updated"`
+	CID         string                     `json:"cid,omitempty"`
+	Source      string                     `json:"source"`
+	EntryCount  int                        `json:"entryCount"`
+	Entries     []announce.CollectionEntry `json:"entries,omitempty"`
+}
+
+func (w *UnifiedWebUI) collectionToView(stored *store.StoredCollection, withEntries bool) CollectionView {
+	view := CollectionView{
+		ID:          stored.ID,
+		Name:        stored.Name,
+		Title:       stored.Title,
+		Description: stored.Description,
+		Topic:       w.reverseLookupTopic(stored.TopicHash),
+		TopicHash:   stored.TopicHash,
+		Curator:     stored.Curator,
+		Revision:    stored.Revision,
+		Updated:     time.Unix(stored.Timestamp, 0),
+		CID:         stored.CID,
+		Source:      stored.Source,
+		EntryCount:  len(stored.Entries),
+	}
+	if key, _ := w.loadCuratorKey(false); key != nil {
+		view.Own = stored.Curator == hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	}
+	if withEntries {
+		view.Entries = stored.Entries
+	}
+	return view
+}
+
+// loadCuratorKey returns the key this node signs collections and signed
+// announcements with, shared with the CLI. Without create it returns nil
+// until there is one.
+func (w *UnifiedWebUI) loadCuratorKey(create bool) (ed25519.PrivateKey, error) {
+	w.curatorMutex.Lock()
+	defer w.curatorMutex.Unlock()
+	if w.curatorKey != nil {
+		return w.curatorKey, nil
+	}
+	if !create {
+		if _, err := os.Stat(w.curatorKeyPath); err != nil {
+			return nil, nil
+		}
+	}
+	key, err := announce.LoadOrCreateCuratorKey(w.curatorKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	w.curatorKey = key
+	return key, nil
+}
+
+// collectionErrorStatus maps collection store errors to HTTP statuses
+func collectionErrorStatus(err error) int {
+	if errors.Is(err, store.ErrCollectionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func (w *UnifiedWebUI) handleListCollections(wr http.ResponseWriter, r *http.Request) {
+	list, err := w.collections.List()
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	topic := r.URL.Query().Get("topic")
+	views := make([]CollectionView, 0, len(list))
+	for _, stored := range list {
+		if topic != "" && stored.TopicHash != announce.HashTopic(topic) {
+			continue
+		}
+		views = append(views, w.collectionToView(stored, false))
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: views})
+}
+
+func (w *UnifiedWebUI) handleGetCollection(wr http.ResponseWriter, r *http.Request) {
+	stored, err := w.collections.Get(mux.Vars(r)["id"])
+	if err != nil {
+		sendError(wr, err, collectionErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: w.collectionToView(stored, true)})
+}
+
+// handlePublishCollection signs a collection with this node's curator key
+// and announces it to its topic. Publishing under a name used before makes
+// a new revision, which replaces the old one for subscribers.
+func (w *UnifiedWebUI) handlePublishCollection(wr http.ResponseWriter, r *http.Request) {
+	var req CollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Topic) == "" {
+		sendError(wr, fmt.Errorf("topic is required"), http.StatusBadRequest)
+		return
+	}
+	cids := make([]string, len(req.Entries))
+	for i := range req.Entries {
+		descriptorCID, err := w.validator.NormalizeCID(req.Entries[i].Descriptor)
+		if err != nil {
+			sendError(wr, fmt.Errorf("entry %d: %w", i+1, err), http.StatusBadRequest)
+			return
+		}
+		req.Entries[i].Descriptor = descriptorCID
+		cids[i] = descriptorCID
+	}
+	if w.refuseTakenDown(wr, r, cids...) {
+		return
+	}
+
+	key, err := w.loadCuratorKey(true)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	collection := &announce.Collection{
+		Version:     announce.CollectionVersion,
+		Name:        req.Name,
+		Title:       req.Title,
+		Description: req.Description,
+		TopicHash:   announce.HashTopic(req.Topic),
+		Revision:    1,
+		Entries:     req.Entries,
+		Timestamp:   time.Now().Unix(),
+	}
+	curator := hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	if previous, err := w.collections.Get(announce.CollectionID(curator, req.Name)); err == nil {
+		collection.Revision = previous.Revision + 1
+	}
+	if err := collection.Sign(key); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	ann, err := w.dhtPublisher.PublishCollection(ctx, collection, time.Duration(req.TTL)*time.Second)
+	w.audit(r, logging.AuditAnnounce, collection.ID(), err, map[string]string{
+		"collection": collection.Name,
+		"topic":      req.Topic,
+		"revision":   fmt.Sprint(collection.Revision),
+	})
+	if err != nil {
+		sendError(wr, fmt.Errorf("failed to publish collection: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if w.config.Privacy.AnnounceRealtime {
+		if err := w.pubsubPublisher.Publish(ctx, ann); err != nil {
+			requestLog(r).Warnf("Failed to publish to PubSub: %v", err)
+		}
+	}
+
+	if _, err := w.collections.Put(collection, ann.Descriptor, "published"); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	stored, err := w.collections.Get(collection.ID())
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	view := w.collectionToView(stored, true)
+	w.broadcastCollection(view)
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
+
+// handleRemoveCollection forgets a collection on this node. It returns
+// when a new revision is announced to a subscribed topic.
+func (w *UnifiedWebUI) handleRemoveCollection(wr http.ResponseWriter, r *http.Request) {
+	if err := w.collections.Remove(mux.Vars(r)["id"]); err != nil {
+		sendError(wr, err, collectionErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// receiveCollection fetches the collection an announcement to a subscribed
+// topic points to and keeps it if it is a new revision
+func (w *UnifiedWebUI) receiveCollection(ann *announce.Announcement) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectionFetchTimeout)
+	defer cancel()
+	collection, err := dht.FetchCollection(ctx, w.storageManager, ann)
+	if err != nil {
+		log.Printf("Rejected collection announcement %s: %v", ann.Descriptor, err)
+		return
+	}
+	updated, err := w.collections.Put(collection, ann.Descriptor, "subscription")
+	if err != nil {
+		log.Printf("Failed to store collection %s: %v", collection.ID(), err)
+		return
+	}
+	if !updated {
+		return
+	}
+	stored, err := w.collections.Get(collection.ID())
+	if err != nil {
+		return
+	}
+	w.broadcastCollection(w.collectionToView(stored, false))
+}
+
+// broadcastCollection tells WebSocket clients about a new collection or
+// revision
+func (w *UnifiedWebUI) broadcastCollection(view CollectionView) {
+	view.Entries = nil
+	w.broadcast(map[string]interface{}{
+		"type": "collection",
+		"data": view,
+	})
+}
+
+func (w *UnifiedWebUI) handleCollectionsPage(wr http.ResponseWriter, r *http.Request) {
+	http.ServeFile(wr, r, "cmd/noisefs-webui/templates/collections.html")
+}
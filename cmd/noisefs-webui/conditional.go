@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+)
+
+// contentValidators identify the content of a descriptor for conditional
+// requests. A CID always names the same content, so the entity tag is
+// strong and only changes with the descriptor format version.
+type contentValidators struct {
+	etag      string
+	modified  time.Time
+	encrypted bool // Served only with the descriptor's password
+}
+
+func newContentValidators(cid string, descriptor *descriptors.Descriptor, encrypted bool) contentValidators {
+	return contentValidators{
+		etag:      fmt.Sprintf(`"%s-v%s"`, cid, descriptor.Version),
+		modified:  descriptor.CreatedAt.UTC().Truncate(time.Second),
+		encrypted: encrypted,
+	}
+}
+
+// setHeaders sets the validators and caching headers. Caches must
+// revalidate, which is cheap, so taken down content stops being served.
+// Content of password protected descriptors is only cached by the browser.
+func (v contentValidators) setHeaders(wr http.ResponseWriter) {
+	if v.etag == "" {
+		return
+	}
+	wr.Header().Set("ETag", v.etag)
+	if !v.modified.IsZero() {
+		wr.Header().Set("Last-Modified", v.modified.Format(http.TimeFormat))
+	}
+	if v.encrypted {
+		wr.Header().Set("Cache-Control", "private, no-cache")
+		wr.Header().Add("Vary", "X-Descriptor-Password")
+	} else {
+		wr.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// notModified answers a request with 304 Not Modified when the client's
+// copy is current, before any content is fetched. If-None-Match takes
+// precedence over If-Modified-Since.
+func (v contentValidators) notModified(wr http.ResponseWriter, r *http.Request) bool {
+	if v.etag == "" {
+		return false
+	}
+	current := false
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		current = etagListMatches(inm, v.etag, false)
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !v.modified.IsZero() {
+		if since, err := http.ParseTime(ims); err == nil {
+			current = !v.modified.After(since)
+		}
+	}
+	if !current {
+		return false
+	}
+	v.setHeaders(wr)
+	wr.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// rangeApplies reports whether a Range header should be honoured: without
+// If-Range, or when If-Range names the current content. Otherwise the
+// client's partial copy is stale and it gets the whole file.
+func (v contentValidators) rangeApplies(r *http.Request) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagListMatches(ifRange, v.etag, true)
+	}
+	date, err := http.ParseTime(ifRange)
+	return err == nil && !v.modified.IsZero() && date.Equal(v.modified)
+}
+
+// etagListMatches reports whether a list of entity tags matches etag.
+// Strong comparison rejects weak tags.
+func etagListMatches(list, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" && !strong {
+			return true
+		}
+		if weak := strings.TrimPrefix(candidate, "W/"); weak != candidate {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveContent writes content of the given size, or the range of it a
+// request asks for, with the validators. Invalid ranges are ignored and the
+// whole content is sent. The caller sets the other headers.
+func serveContent(wr http.ResponseWriter, r *http.Request, content io.ReadSeeker, size int64, v contentValidators) (int64, error) {
+	v.setHeaders(wr)
+	wr.Header().Set("Accept-Ranges", "bytes")
+
+	start, end := int64(0), size-1
+	status := http.StatusOK
+	if header := r.Header.Get("Range"); header != "" && v.rangeApplies(r) {
+		first, last, err := parseRange(header, size)
+		if errors.Is(err, errUnsatisfiableRange) {
+			wr.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			wr.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return 0, nil
+		}
+		if err == nil {
+			start, end = first, last
+			status = http.StatusPartialContent
+			wr.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		}
+	}
+
+	if _, err := content.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	wr.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	wr.WriteHeader(status)
+	return streamContent(wr, content, end-start+1)
+}
+
+// errUnsatisfiableRange is returned for ranges outside the content
+var errUnsatisfiableRange = errors.New("range not satisfiable")
+
+// parseRange parses a single byte range ("bytes=0-99", "bytes=100-" or
+// "bytes=-100") of content of the given size. Ends past the content are
+// cut to its last byte.
+func parseRange(header string, size int64) (start, end int64, err error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("invalid range header")
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range header")
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid range header")
+		}
+		if size == 0 {
+			return 0, 0, errUnsatisfiableRange
+		}
+		return max(size-n, 0), size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range header")
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid range header")
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, errUnsatisfiableRange
+	}
+	return start, end, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+	"github.com/gorilla/mux"
+)
+
+// processStart approximates when the web UI started, for /debug/runtime
+var processStart = time.Now()
+
+// RuntimeStats is the output of /debug/runtime
+type RuntimeStats struct {
+	Version       string     `json:"version"`
+	GoVersion     string     `json:"go_version"`
+	OS            string     `json:"os"`
+	Arch          string     `json:"arch"`
+	NumCPU        int        `json:"num_cpu"`
+	GOMAXPROCS    int        `json:"gomaxprocs"`
+	Goroutines    int        `json:"goroutines"`
+	UptimeSeconds float64    `json:"uptime_seconds"`
+	HeapAlloc     uint64     `json:"heap_alloc_bytes"`
+	HeapInuse     uint64     `json:"heap_inuse_bytes"`
+	HeapObjects   uint64     `json:"heap_objects"`
+	StackInuse    uint64     `json:"stack_inuse_bytes"`
+	Sys           uint64     `json:"sys_bytes"` // Memory obtained from the OS
+	TotalAlloc    uint64     `json:"total_alloc_bytes"`
+	NumGC         uint32     `json:"num_gc"`
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	GCPauseTotal  float64    `json:"gc_pause_total_ms"`
+	GCCPUFraction float64    `json:"gc_cpu_fraction"`
+	NextGC        uint64     `json:"next_gc_bytes"`
+}
+
+// handleDebugHistory returns the client's metrics history, limited to the
+// last ?minutes= when given
+func (w *UnifiedWebUI) handleDebugHistory(wr http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("minutes"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			sendError(wr, fmt.Errorf("invalid number of minutes %q", value), http.StatusBadRequest)
+			return
+		}
+		since = time.Now().Add(-time.Duration(minutes) * time.Minute)
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: w.noisefsClient.MetricsHistory().Report(since)})
+}
+
+// registerProfilingRoutes serves net/http/pprof and runtime statistics on
+// the debug router. Profiles expose memory contents and stack traces, so
+// only admins may read them whether or not the API requires tokens.
+func (w *UnifiedWebUI) registerProfilingRoutes(debug *mux.Router) {
+	profiling := debug.NewRoute().Subrouter()
+	profiling.Use(w.requireAdmin)
+
+	profiling.HandleFunc("/runtime", w.handleDebugRuntime).Methods("GET")
+	profiling.HandleFunc("/pprof/cmdline", pprof.Cmdline).Methods("GET")
+	profiling.HandleFunc("/pprof/profile", pprof.Profile).Methods("GET")
+	profiling.HandleFunc("/pprof/symbol", pprof.Symbol).Methods("GET", "POST")
+	profiling.HandleFunc("/pprof/trace", pprof.Trace).Methods("GET")
+	// The index, and named profiles such as heap and goroutine
+	profiling.PathPrefix("/pprof/").HandlerFunc(pprof.Index).Methods("GET")
+}
+
+// handleDebugRuntime returns goroutine, memory and GC statistics
+func (w *UnifiedWebUI) handleDebugRuntime(wr http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := RuntimeStats{
+		Version:       version.Build(),
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Goroutines:    runtime.NumGoroutine(),
+		UptimeSeconds: time.Since(processStart).Seconds(),
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		StackInuse:    mem.StackInuse,
+		Sys:           mem.Sys,
+		TotalAlloc:    mem.TotalAlloc,
+		NumGC:         mem.NumGC,
+		GCPauseTotal:  float64(mem.PauseTotalNs) / float64(time.Millisecond),
+		GCCPUFraction: mem.GCCPUFraction,
+		NextGC:        mem.NextGC,
+	}
+	if mem.LastGC > 0 {
+		lastGC := time.Unix(0, int64(mem.LastGC))
+		stats.LastGC = &lastGC
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: stats})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// DisclaimerStatus describes the legal disclaimer and whether this node's
+// operator has accepted it
+type DisclaimerStatus struct {
+	Version         string                      `json:"version"`
+	Accepted        bool                        `json:"accepted"`
+	Acknowledgement *compliance.Acknowledgement `json:"acknowledgement,omitempty"`
+	Terms           []string                    `json:"terms"`
+}
+
+// disclaimerExempt lists the API paths served before the disclaimer is
+// accepted. Sessions are among them so an admin can sign in to accept it.
+var disclaimerExempt = []string{"/api/disclaimer", "/api/transparency", "/api/session"}
+
+// requireDisclaimer rejects API requests until the legal disclaimer has
+// been accepted, here or with the CLI or mount
+func (w *UnifiedWebUI) requireDisclaimer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		for _, prefix := range disclaimerExempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(wr, r)
+				return
+			}
+		}
+		if !w.disclaimerAccepted() {
+			sendError(wr, fmt.Errorf("the legal disclaimer must be accepted first; see /disclaimer"), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(wr, r)
+	})
+}
+
+// disclaimerAccepted checks the acknowledgement file until it records the
+// current version, then remembers that
+func (w *UnifiedWebUI) disclaimerAccepted() bool {
+	if w.disclaimerOK.Load() {
+		return true
+	}
+	if compliance.DisclaimerAccepted(w.disclaimerPath) {
+		w.disclaimerOK.Store(true)
+		return true
+	}
+	return false
+}
+
+func (w *UnifiedWebUI) handleGetDisclaimer(wr http.ResponseWriter, r *http.Request) {
+	ack, err := compliance.LoadAcknowledgement(w.disclaimerPath)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: DisclaimerStatus{
+		Version:         compliance.DisclaimerVersion,
+		Accepted:        ack != nil && ack.Version == compliance.DisclaimerVersion,
+		Acknowledgement: ack,
+		Terms:           compliance.DisclaimerTerms,
+	}})
+}
+
+// handleAcceptDisclaimer records acceptance of the disclaimer version in
+// the request, which must be the current one
+func (w *UnifiedWebUI) handleAcceptDisclaimer(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, fmt.Errorf("invalid request: %w", err), http.StatusBadRequest)
+		return
+	}
+	if req.Version != compliance.DisclaimerVersion {
+		sendError(wr, fmt.Errorf("disclaimer version %q is not current (current: %s)", req.Version, compliance.DisclaimerVersion), http.StatusConflict)
+		return
+	}
+
+	ack, err := compliance.AcceptDisclaimer(w.disclaimerPath, "webui")
+	w.audit(r, logging.AuditDisclaimerAccept, compliance.DisclaimerVersion, err, nil)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	w.disclaimerOK.Store(true)
+	sendJSON(wr, APIResponse{Success: true, Data: ack})
+}
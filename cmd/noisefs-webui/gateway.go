@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	noisefsConfig "github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+// GatewayView tells pages whether the web UI is a download-only gateway,
+// and how much of its download quota the client has left
+type GatewayView struct {
+	Enabled bool                    `json:"enabled"`
+	Quota   *validation.QuotaStatus `json:"quota,omitempty"`
+}
+
+// newDownloadQuota returns the per-IP download quota of gateway mode, or
+// nil when there is none
+func newDownloadQuota(cfg noisefsConfig.GatewayConfig, backend validation.RateLimitBackend) (*validation.DownloadQuota, error) {
+	if !cfg.Enabled || cfg.DownloadQuotaMB == 0 {
+		return nil, nil
+	}
+	proxies, err := validation.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return validation.NewDownloadQuota(backend, int64(cfg.DownloadQuotaMB)*1024*1024, time.Duration(cfg.QuotaWindowHours)*time.Hour, proxies), nil
+}
+
+func (w *UnifiedWebUI) handleGateway(wr http.ResponseWriter, r *http.Request) {
+	view := GatewayView{Enabled: w.config.WebUI.Gateway.Enabled}
+	if w.quota != nil {
+		status, err := w.quota.Status(r)
+		if err != nil {
+			sendError(wr, err, http.StatusInternalServerError)
+			return
+		}
+		view.Quota = &status
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
+
+// limitDownloads refuses downloads to clients that used up their quota.
+// Each download reserves its Content-Length before the body is sent, so
+// parallel downloads can't all pass while some quota is left, and is
+// charged what was actually sent once it ends. A download in progress is
+// never cut off, so the one that crosses the limit may overdraw it.
+func (w *UnifiedWebUI) limitDownloads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		if w.quota == nil {
+			next.ServeHTTP(wr, r)
+			return
+		}
+		status, err := w.quota.Status(r)
+		if err != nil {
+			sendError(wr, err, http.StatusInternalServerError)
+			return
+		}
+		if status.Remaining == 0 {
+			w.refuseOverQuota(wr, r, status)
+			return
+		}
+
+		limited := &quotaWriter{ResponseWriter: wr, webui: w, r: r}
+		next.ServeHTTP(limited, r)
+		var settleErr error
+		if limited.reservation != nil {
+			settleErr = limited.reservation.Settle(limited.written)
+		} else if !limited.refused {
+			// Responses without a length, such as errors, are charged as sent
+			settleErr = w.quota.Charge(r, limited.written)
+		}
+		if settleErr != nil {
+			requestLog(r).Warnf("Failed to charge download quota: %v", settleErr)
+		}
+	})
+}
+
+// refuseOverQuota answers a client that used up its download quota
+func (w *UnifiedWebUI) refuseOverQuota(wr http.ResponseWriter, r *http.Request, status validation.QuotaStatus) {
+	err := fmt.Errorf("download quota of %d MB used up; it resets at %s", status.Limit/(1024*1024), status.ResetsAt.UTC().Format(time.RFC3339))
+	w.audit(r, logging.AuditAccessDenied, r.URL.Path, err, map[string]string{"reason": "download_quota"})
+	wr.Header().Set("Retry-After", strconv.Itoa(int(time.Until(status.ResetsAt).Seconds())+1))
+	sendError(wr, err, http.StatusTooManyRequests)
+}
+
+// quotaWriter reserves a download's Content-Length when its headers are
+// written, answering 429 instead when the quota is used up by then, and
+// counts the bytes of the body
+type quotaWriter struct {
+	http.ResponseWriter
+	webui       *UnifiedWebUI
+	r           *http.Request
+	reservation *validation.Reservation
+	wroteHeader bool
+	refused     bool
+	written     int64
+}
+
+func (q *quotaWriter) WriteHeader(status int) {
+	if q.wroteHeader {
+		return
+	}
+	q.wroteHeader = true
+	length, err := strconv.ParseInt(q.Header().Get("Content-Length"), 10, 64)
+	if status >= 300 || err != nil {
+		q.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	reservation, quotaStatus, err := q.webui.quota.Reserve(q.r, length)
+	switch {
+	case errors.Is(err, validation.ErrQuotaExceeded):
+		// A parallel download used up the quota since it was checked
+		q.refused = true
+		for _, header := range []string{"Content-Length", "Content-Range", "Content-Disposition", "ETag", "Last-Modified"} {
+			q.Header().Del(header)
+		}
+		q.webui.refuseOverQuota(q.ResponseWriter, q.r, quotaStatus)
+	case err != nil:
+		q.refused = true
+		q.Header().Del("Content-Length")
+		sendError(q.ResponseWriter, err, http.StatusInternalServerError)
+	default:
+		q.reservation = reservation
+		q.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (q *quotaWriter) Write(p []byte) (int, error) {
+	if !q.wroteHeader {
+		q.WriteHeader(http.StatusOK)
+	}
+	if q.refused {
+		return 0, validation.ErrQuotaExceeded
+	}
+	n, err := q.ResponseWriter.Write(p)
+	q.written += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController flush the underlying writer
+func (q *quotaWriter) Unwrap() http.ResponseWriter {
+	return q.ResponseWriter
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+func TestLimitDownloadsReserves(t *testing.T) {
+	quota := validation.NewDownloadQuota(validation.NewMemoryRateLimitBackend(), 100, time.Hour, nil)
+	webui := &UnifiedWebUI{quota: quota}
+
+	// parallel simulates another download of the client finishing while
+	// this one is prepared
+	var parallel int64
+	body := make([]byte, 60)
+	handler := webui.limitDownloads(http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		quota.Charge(r, parallel)
+		wr.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		wr.WriteHeader(http.StatusOK)
+		wr.Write(body)
+	}))
+	download := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/download/cid", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := download(); rec.Code != http.StatusOK || rec.Body.Len() != 60 {
+		t.Fatalf("First download: %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// Quota was left when the download started, but not when it was sent
+	parallel = 40
+	rec := download()
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("Expected 429 once a parallel download used up the quota, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	if status, _ := quota.Status(req); status.Used != 100 {
+		t.Errorf("Expected the refused download to reserve nothing, got %+v", status)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+const (
+	// infoCacheControl lets browsers keep descriptor info: a CID always
+	// names the same descriptor. It is private so a takedown isn't
+	// outlived by a shared cache.
+	infoCacheControl = "private, max-age=31536000, immutable"
+
+	// maxCachedInfo bounds the descriptor info kept in memory
+	maxCachedInfo = 1000
+
+	// maxBulkInfo is the number of CIDs one bulk info request may ask for
+	maxBulkInfo = 100
+
+	// bulkInfoWorkers load the descriptors of a bulk info request
+	bulkInfoWorkers = 8
+)
+
+// Verification statuses of descriptor info. Downloads are checked against
+// the descriptor's content hash and refused on a mismatch.
+const (
+	verificationUnavailable = "unavailable" // The descriptor has no content hash
+	verificationPending     = "pending"     // Not downloaded since the web UI started
+	verificationVerified    = "verified"    // The last download matched the hash
+	verificationFailed      = "failed"      // The last download didn't match and was refused
+)
+
+// cachedInfo is the info of an unencrypted descriptor
+type cachedInfo struct {
+	info       DownloadInfo
+	validators contentValidators
+}
+
+// descriptorInfo returns the info of a NoiseFS descriptor, with the local
+// file index's details added. As with LoadDescriptor, the info's Encrypted
+// field is set on errors caused by a missing or wrong password.
+// Unencrypted descriptors are immutable, so their info is cached by CID.
+func (w *UnifiedWebUI) descriptorInfo(descriptorCID, password string) (DownloadInfo, contentValidators, error) {
+	w.infoMutex.Lock()
+	cached, ok := w.infoCache[descriptorCID]
+	w.infoMutex.Unlock()
+	if !ok {
+		descriptor, encrypted, err := w.noisefsClient.LoadDescriptor(descriptorCID, password)
+		if err != nil {
+			return DownloadInfo{Encrypted: encrypted}, contentValidators{}, err
+		}
+		cached = cachedInfo{
+			info: DownloadInfo{
+				Filename:      descriptor.Filename,
+				Size:          descriptor.FileSize,
+				ContentType:   validation.ContentTypeForFilename(descriptor.Filename),
+				DescriptorCID: descriptorCID,
+				Encrypted:     encrypted,
+				Verification:  verificationUnavailable,
+				Files:         packedFiles(descriptor),
+			},
+			validators: newContentValidators(descriptorCID, descriptor, encrypted),
+		}
+		if descriptor.Verifiable() {
+			cached.info.Verification = verificationPending
+		}
+		if !encrypted {
+			w.infoMutex.Lock()
+			if len(w.infoCache) >= maxCachedInfo {
+				w.infoCache = make(map[string]cachedInfo)
+			}
+			w.infoCache[descriptorCID] = cached
+			w.infoMutex.Unlock()
+		}
+	}
+
+	info := cached.info
+	w.addIndexInfo(&info)
+	if info.Verification == verificationPending {
+		w.infoMutex.Lock()
+		if status, ok := w.verifications[descriptorCID]; ok {
+			info.Verification = status
+		}
+		w.infoMutex.Unlock()
+	}
+	return info, cached.validators, nil
+}
+
+// recordVerification remembers how a download compared with its
+// descriptor's content hash, for the descriptor's info
+func (w *UnifiedWebUI) recordVerification(descriptorCID string, file *noisefs.DownloadedFile, err error) {
+	var status string
+	switch {
+	case errors.Is(err, descriptors.ErrContentMismatch):
+		status = verificationFailed
+	case err == nil && file.Verified:
+		status = verificationVerified
+	default:
+		return
+	}
+
+	w.infoMutex.Lock()
+	defer w.infoMutex.Unlock()
+	if len(w.verifications) >= maxCachedInfo {
+		w.verifications = make(map[string]string)
+	}
+	w.verifications[descriptorCID] = status
+}
+
+// setInfoHeaders sets the caching headers of descriptor info and reports
+// whether the client's copy is current. Details from the local file index
+// can change, so info with them is always revalidated in full. The
+// verification status changes with downloads, so info with one is
+// revalidated against an ETag that includes it.
+func setInfoHeaders(wr http.ResponseWriter, r *http.Request, info DownloadInfo, v contentValidators) bool {
+	if len(info.Tags) > 0 || info.ContentHash != "" {
+		wr.Header().Set("Cache-Control", "no-cache")
+		return false
+	}
+	etag, cacheControl := v.etag, infoCacheControl
+	if info.Verification != verificationUnavailable {
+		etag = strings.TrimSuffix(etag, `"`) + "-" + info.Verification + `"`
+		cacheControl = "private, no-cache"
+	}
+	wr.Header().Set("ETag", etag)
+	wr.Header().Set("Cache-Control", cacheControl)
+	if v.encrypted {
+		wr.Header().Add("Vary", "X-Descriptor-Password")
+	}
+	return etagListMatches(r.Header.Get("If-None-Match"), etag, false)
+}
+
+// BulkInfoView is the info of several descriptors, by the CIDs they were
+// requested with
+type BulkInfoView struct {
+	Infos  map[string]DownloadInfo `json:"infos"`
+	Errors map[string]string       `json:"errors,omitempty"`
+}
+
+// handleBulkInfo returns the info of up to maxBulkInfo descriptors, so a
+// page listing announcements needs one request for all of them. Unlike
+// GET /api/info/{cid} it doesn't fall back to raw IPFS content; CIDs that
+// can't be loaded as descriptors are listed with their error.
+func (w *UnifiedWebUI) handleBulkInfo(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CIDs []string `json:"cids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, fmt.Errorf("invalid request body: %w", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.CIDs) == 0 {
+		sendError(wr, fmt.Errorf("no CIDs requested"), http.StatusBadRequest)
+		return
+	}
+	if len(req.CIDs) > maxBulkInfo {
+		sendError(wr, fmt.Errorf("too many CIDs: %d (at most %d per request)", len(req.CIDs), maxBulkInfo), http.StatusBadRequest)
+		return
+	}
+
+	view := BulkInfoView{
+		Infos:  make(map[string]DownloadInfo),
+		Errors: make(map[string]string),
+	}
+	seen := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, bulkInfoWorkers)
+	password := descriptorPassword(r)
+	for _, requested := range req.CIDs {
+		if seen[requested] {
+			continue
+		}
+		seen[requested] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(requested string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			info, err := w.bulkInfo(r, requested, password)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				view.Errors[requested] = err.Error()
+				return
+			}
+			view.Infos[requested] = info
+		}(requested)
+	}
+	wg.Wait()
+
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
+
+// bulkInfo returns the info of one descriptor of a bulk request
+func (w *UnifiedWebUI) bulkInfo(r *http.Request, requested, password string) (DownloadInfo, error) {
+	descriptorCID, err := w.validator.ResolveCID(r.Context(), requested)
+	if err != nil {
+		return DownloadInfo{}, err
+	}
+	blocked, err := w.takedowns.IsBlocked(descriptorCID)
+	if err != nil {
+		return DownloadInfo{}, fmt.Errorf("failed to check takedowns: %w", err)
+	}
+	if blocked {
+		w.audit(r, logging.AuditAccessDenied, descriptorCID, compliance.ErrAlreadyTakenDown, map[string]string{"reason": "takedown"})
+		return DownloadInfo{}, fmt.Errorf("%s is unavailable following a takedown notice", descriptorCID)
+	}
+	info, _, err := w.descriptorInfo(descriptorCID, password)
+	return info, err
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
@@ -9,8 +10,10 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/big"
@@ -18,22 +21,42 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	webuitls "github.com/TheEntropyCollective/noisefs/cmd/webui/tls"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/autofetch"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/pubsub"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/security"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
 	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/transfers"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
 	noisefsConfig "github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/health"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/metrics"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/webhooks"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/telemetry"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/TheEntropyCollective/noisefs/pkg/privacy/cover"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/TheEntropyCollective/noisefs/pkg/web/middleware"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	shell "github.com/ipfs/go-ipfs-api"
@@ -47,7 +70,6 @@ type UnifiedWebUI struct {
 	cache          cache.Cache
 	config         *noisefsConfig.Config
 	validator      *validation.Validator
-	rateLimiter    *validation.RateLimiter
 	
 	// Announcement components
 	store            *store.Store
@@ -58,15 +80,69 @@ type UnifiedWebUI struct {
 	hierarchy        *announce.TopicHierarchy
 	search           *announce.SearchEngine
 	securityMgr      *security.Manager
+	ipfsShell        *shell.Shell // Direct IPFS API access for non-NoiseFS content
 	
 	// WebSocket management
 	wsUpgrader websocket.Upgrader
-	wsClients  map[*websocket.Conn]chan interface{}
-	wsMutex    sync.RWMutex
+	ws         *wsHub
 	
 	// Subscriptions
 	subscriptions *config.Subscriptions
 	subMutex      sync.RWMutex
+
+	// Live stats for the dashboard
+	stats *statsRecorder
+
+	// Auto-fetch of announced files
+	autoFetch      *autofetch.Engine
+	autoFetchPath  string
+	autoFetchMutex sync.Mutex // Serializes rule changes
+
+	// Local file index, shared with auto-fetch
+	fileIndex *fuse.FileIndex
+
+	// Limited shares this instance holds the keys of
+	shares *descriptors.ShareStore
+
+	// Takedown notices, whose descriptors are not served
+	takedowns *compliance.TakedownStore
+
+	// Legal disclaimer acknowledgement gating the API
+	disclaimerPath string
+	disclaimerOK   atomic.Bool
+
+	// Cover traffic, nil when disabled
+	coverTraffic *cover.Generator
+
+	// Outbound event webhooks, nil when none are configured
+	webhooks *webhooks.Dispatcher
+
+	// Queued uploads and downloads
+	transfers *transfers.Manager
+
+	// Curated collections, and the key this node signs its own and signed
+	// announcements with, loaded when first needed
+	collections    *store.CollectionStore
+	curatorKeyPath string
+	curatorKey     ed25519.PrivateKey
+	curatorMutex   sync.Mutex
+
+	// Metadata records fetched for announcements, by CID
+	metadataCache map[string]*announce.Metadata
+	metadataMutex sync.Mutex
+
+	// Info of unencrypted descriptors and the verification status of
+	// their downloads, by CID
+	infoCache     map[string]cachedInfo
+	verifications map[string]string
+	infoMutex     sync.Mutex
+
+	// Per-IP download quota in gateway mode, nil without one
+	quota *validation.DownloadQuota
+
+	// API tokens and their roles, and browsers signed in with them
+	tokens   *auth.TokenStore
+	sessions *sessionStore
 }
 
 // Response types
@@ -80,16 +156,24 @@ type UploadResponse struct {
 }
 
 type DownloadInfo struct {
-	Filename      string `json:"filename"`
-	Size          int64  `json:"size"`
-	ContentType   string `json:"content_type"`
-	DescriptorCID string `json:"descriptor_cid"`
+	Filename      string       `json:"filename"`
+	Size          int64        `json:"size"`
+	ContentType   string       `json:"content_type"`
+	DescriptorCID string       `json:"descriptor_cid"`
+	Encrypted     bool         `json:"encrypted"`
+	Verification  string       `json:"verification,omitempty"` // Whether downloads are checked against the descriptor's content hash, and how the last one went
+	Files         []PackedFile `json:"files,omitempty"`        // The files of a pack
+
+	// From the local file index, when the descriptor is in it
+	Tags        []string `json:"tags,omitempty"`
+	ContentHash string   `json:"content_hash,omitempty"`
 }
 
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"` // On errors, to find the request in the logs
 }
 
 // Announcement-related types
@@ -105,8 +189,39 @@ type AnnouncementView struct {
 	TTL         int64     `json:"ttl"`
 	Expiry      time.Time `json:"expiry"`
 	Source      string    `json:"source"`
+	Metadata    string    `json:"metadata,omitempty"` // CID of the descriptor's metadata record
+	Publisher   string    `json:"publisher,omitempty"` // Key of the publisher of a signed announcement
+
+	// Why a search result ranked where it did
+	Explanation *announce.ScoreExplanation `json:"explanation,omitempty"`
+}
+
+// AnnouncementPage is one page of announcements from a paginated query
+type AnnouncementPage struct {
+	Announcements []AnnouncementView `json:"announcements"`
+	Total         int                `json:"total"`
+	Offset        int                `json:"offset"`
+	Limit         int                `json:"limit"`
+}
+
+// FileSearchPage is one page of local file index search results
+type FileSearchPage struct {
+	Files  []fuse.IndexMatch `json:"files"`
+	Total  int               `json:"total"`
+	Offset int               `json:"offset"`
+	Limit  int               `json:"limit"`
 }
 
+const (
+	// defaultPageSize and maxPageSize bound announcement pages
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	// maxRequestBytes caps request bodies: the 100MB upload limit plus
+	// room for the multipart encoding and form fields
+	maxRequestBytes = 101 << 20
+)
+
 type TopicView struct {
 	Path              string            `json:"path"`
 	Name              string            `json:"name"`
@@ -126,6 +241,16 @@ type StatsView struct {
 	RecentCount       int            `json:"recentCount"`
 	ExpiredCount      int            `json:"expiredCount"`
 	ActiveSubs        int            `json:"activeSubscriptions"`
+	WebSocket         WebSocketView  `json:"websocket"`
+}
+
+// WebSocketView reports the live update clients and the messages they
+// were sent or missed
+type WebSocketView struct {
+	Clients int   `json:"clients"`
+	Sent    int64 `json:"sent"`
+	Dropped int64 `json:"dropped"` // Missed by clients too slow to keep up
+	Evicted int64 `json:"evicted"` // Clients disconnected for being too slow
 }
 
 // storeAdapter adapts store.Store to announce.AnnouncementStore interface
@@ -199,33 +324,89 @@ func (sa *storeAdapter) GetRecent(since time.Time, limit int) ([]*announce.Annou
 	return anns, nil
 }
 
+func (sa *storeAdapter) Query(query announce.StoreQuery) ([]*announce.Announcement, int, error) {
+	storedAnns, total, err := sa.store.Query(query)
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	anns := make([]*announce.Announcement, len(storedAnns))
+	for i, stored := range storedAnns {
+		anns[i] = stored.Announcement
+	}
+	
+	return anns, total, nil
+}
+
 func main() {
 	// Parse command line flags
 	var (
-		configFile   = flag.String("config", "", "Path to NoiseFS configuration file")
-		addr         = flag.String("addr", ":8080", "HTTP server address")
-		ipfsAPI      = flag.String("ipfs", "http://127.0.0.1:5001", "IPFS API endpoint")
-		dataDir      = flag.String("data", "./webui-data", "Data directory")
-		pollInterval = flag.Duration("poll", 30*time.Second, "DHT poll interval")
-		enableTLS    = flag.Bool("tls", false, "Enable HTTPS with self-signed certificate")
-		certFile     = flag.String("cert", "", "TLS certificate file (optional)")
-		keyFile      = flag.String("key", "", "TLS key file (optional)")
+		configFile   = flag.String("config", "", "Path to NoiseFS configuration file (default: ~/.noisefs/config.json)")
+		addr         = flag.String("addr", "", "HTTP server address (overrides webui.address)")
+		ipfsAPI      = flag.String("ipfs", "", "IPFS API endpoint (overrides ipfs.api_endpoint)")
+		dataDir      = flag.String("data", "", "Data directory (overrides webui.data_dir)")
+		pollInterval = flag.Duration("poll", 0, "DHT poll interval (overrides webui.poll_interval_seconds)")
+		enableTLS    = flag.Bool("tls", false, "Enable HTTPS, self-signed unless a certificate is given (overrides webui.tls)")
+		certFile     = flag.String("cert", "", "TLS certificate file (overrides webui.cert_file)")
+		keyFile      = flag.String("key", "", "TLS key file (overrides webui.key_file)")
+		acmeDomains  = flag.String("acme-domains", "", "Comma-separated domains to obtain Let's Encrypt certificates for (enables webui.acme)")
+		acceptTOS    = flag.Bool("accept-tos", false, "Accept the legal disclaimer without prompting, for automation")
 	)
 	flag.Parse()
 
-	// Load configuration
-	cfg, err := noisefsConfig.LoadConfig(*configFile)
+	// Load the shared configuration; flags only override what they set
+	configPath := noisefsConfig.ResolveConfigPath(*configFile)
+	cfg, err := noisefsConfig.LoadConfig(configPath)
 	if err != nil {
-		log.Printf("Using default configuration: %v", err)
-		cfg = noisefsConfig.DefaultConfig()
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	fileConfig := *cfg
+
+	setFlags := noisefsConfig.ExplicitFlags(flag.CommandLine)
+	if setFlags["addr"] {
+		cfg.WebUI.Address = *addr
+	}
+	if setFlags["ipfs"] {
 		cfg.IPFS.APIEndpoint = *ipfsAPI
 	}
+	if setFlags["data"] {
+		cfg.WebUI.DataDir = *dataDir
+	}
+	if setFlags["poll"] {
+		cfg.WebUI.PollInterval = int(pollInterval.Seconds())
+	}
+	if setFlags["tls"] {
+		cfg.WebUI.TLS = *enableTLS
+	}
+	if setFlags["cert"] {
+		cfg.WebUI.CertFile = *certFile
+	}
+	if setFlags["key"] {
+		cfg.WebUI.KeyFile = *keyFile
+	}
+	if setFlags["acme-domains"] {
+		cfg.WebUI.ACME = *acmeDomains != ""
+		cfg.WebUI.ACMEDomains = strings.Split(*acmeDomains, ",")
+	}
+	if len(setFlags) > 0 {
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("Invalid command-line overrides: %v", err)
+		}
+	}
 
-	// Create storage manager
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
+	// Open the audit log
+	if err := logging.InitAuditFromConfig(cfg.Security.AuditLog, cfg.Security.AuditLogFile, cfg.Security.AuditLogMaxSizeMB, cfg.Security.AuditLogMaxFiles); err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
 	}
+	defer logging.CloseGlobalAuditLogger()
+
+	// Count the daemon's use and send usage reports as they become due,
+	// when the user opted in
+	telemetry.Use(cfg.Telemetry, "webui")
+	go telemetry.Run(context.Background(), cfg.Telemetry)
+
+	// Create storage manager
+	storageConfig := cfg.StorageConfig()
 	
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -244,10 +425,92 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create NoiseFS client: %v", err)
 	}
+	blockSizePolicy, err := cfg.Blocks.SizePolicy()
+	if err != nil {
+		log.Fatalf("Invalid block size configuration: %v", err)
+	}
+	noisefsClient.SetBlockSizePolicy(blockSizePolicy)
+	if cfg.Dedup.Enabled {
+		uploads, err := noisefs.OpenUploadIndex(cfg.Dedup.IndexPath, cfg.Dedup.Bloom)
+		if err != nil {
+			log.Fatalf("Failed to open upload index: %v", err)
+		}
+		noisefsClient.SetUploadIndex(uploads)
+	}
+	if cfg.Dedup.Blocks {
+		blockIndex, err := noisefs.OpenBlockIndex(cfg.Dedup.BlockIndexPath)
+		if err != nil {
+			log.Fatalf("Failed to open block index: %v", err)
+		}
+		noisefsClient.SetBlockIndex(blockIndex)
+	}
+	if cfg.RetrievalMixing.Enabled {
+		mixingConfig, err := cfg.RetrievalMixing.SchedulerConfig()
+		if err == nil {
+			err = noisefsClient.SetRetrievalMixing(&mixingConfig)
+		}
+		if err != nil {
+			log.Fatalf("Invalid retrieval mixing configuration: %v", err)
+		}
+	}
+	speculativeDelay, err := cfg.Network.SpeculativeFetchDelayDuration()
+	if err != nil {
+		log.Fatalf("Invalid speculative fetch delay: %v", err)
+	}
+	noisefsClient.SetSpeculativeFetch(speculativeDelay)
+	// Includes the ClamAV scan of downloads when webui.clamd_address is set
+	processorPipeline, err := cfg.WebUIProcessorPipeline()
+	if err != nil {
+		log.Fatalf("Invalid content processor configuration: %v", err)
+	}
+	noisefsClient.SetProcessors(processorPipeline)
+
+	// Apply reloadable settings now and again on SIGHUP or config file changes
+	if err := applyReloadableSettings(cfg, storageManager, blockCache); err != nil {
+		log.Fatalf("Failed to apply configuration: %v", err)
+	}
+	reloader := noisefsConfig.NewReloader(configPath, &fileConfig)
+	reloader.OnReload(func(updated *noisefsConfig.Config) error {
+		return applyReloadableSettings(updated, storageManager, blockCache)
+	})
+	go func() {
+		if err := reloader.Watch(context.Background(), reportReload); err != nil {
+			log.Printf("Configuration reload disabled: %v", err)
+		}
+	}()
+
+	// Liveness and readiness for probes; not ready until the server listens
+	var serving atomic.Bool
+	checker := health.NewChecker()
+	checker.AddLivenessCheck("cache", func(ctx context.Context) error {
+		// Size takes the cache lock, so a deadlocked cache fails by timeout
+		blockCache.Size()
+		return nil
+	})
+	checker.AddReadinessCheck("storage", storageManager.CheckReady)
+	checker.AddReadinessCheck("server", func(ctx context.Context) error {
+		if !serving.Load() {
+			return fmt.Errorf("not listening yet")
+		}
+		return nil
+	})
+
+	// Allow log levels to be changed and health queried via the control socket
+	var controlServer *control.Server
+	if cfg.Daemon.ControlSocket != "" {
+		controlServer = control.NewServer(cfg.Daemon.ControlSocket)
+		control.RegisterLogLevelHandlers(controlServer, logging.GetGlobalLogger())
+		control.RegisterHealthHandlers(controlServer, checker)
+		if err := controlServer.Start(); err != nil {
+			log.Printf("Control socket disabled: %v", err)
+		} else {
+			defer controlServer.Close()
+		}
+	}
 
 	// Create announcement store
 	announcementStore, err := store.NewStore(store.StoreConfig{
-		DataDir:         *dataDir,
+		DataDir:         cfg.WebUI.DataDir,
 		MaxAge:          7 * 24 * time.Hour,
 		MaxSize:         10000,
 		CleanupInterval: 1 * time.Hour,
@@ -277,15 +540,19 @@ func main() {
 		SpamThreshold:     70,
 		TrustRequired:     false,
 	})
+	searchEngine.SetReputation(securityMgr.Reputation())
 
 	// Create IPFS shell
-	ipfsShell := shell.NewShell(*ipfsAPI)
+	ipfsShell, err := backends.NewIPFSShell(cfg.IPFSConnection())
+	if err != nil {
+		log.Fatalf("Failed to create IPFS client: %v", err)
+	}
 
 	// Create subscribers
 	dhtSubscriber, err := dht.NewSubscriber(dht.SubscriberConfig{
 		StorageManager: storageManager,
 		IPFSShell:      ipfsShell,
-		PollInterval:   *pollInterval,
+		PollInterval:   time.Duration(cfg.WebUI.PollInterval) * time.Second,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create DHT subscriber: %v", err)
@@ -314,10 +581,163 @@ func main() {
 	// Create input validator
 	validator := validation.NewValidator()
 	validator.SetMaxFileSize(100 * 1024 * 1024) // 100MB limit
+	validator.SetNameResolver(storageManager)
+	if cfg.Security.ContentPolicyFile != "" {
+		policy, err := validation.LoadContentPolicy(cfg.Security.ContentPolicyFile)
+		if err != nil {
+			log.Fatalf("Failed to load content policy: %v", err)
+		}
+		validator.SetContentPolicy(policy)
+	}
 	
 	// Create rate limiter
 	rateLimitConfig := validation.DefaultRateLimitConfig()
-	rateLimiter := validation.NewRateLimiter(rateLimitConfig)
+	rateLimitConfig.KeyByToken = cfg.Security.RateLimitByToken
+	rateLimitBackend, err := validation.NewRateLimitBackend(cfg.Security.RateLimitStore)
+	if err != nil {
+		log.Fatalf("Failed to create rate limit backend: %v", err)
+	}
+	rateLimiter := validation.NewRateLimiterWithBackend(rateLimitConfig, rateLimitBackend)
+
+	// Create auto-fetch engine with the same FUSE index as noisefs-mount
+	autoFetchPath := autofetch.DefaultConfigPath()
+	autoFetchConfig, err := autofetch.LoadConfig(autoFetchPath)
+	if err != nil {
+		log.Fatalf("Failed to load auto-fetch rules: %v", err)
+	}
+	indexPath, err := fuse.GetDefaultIndexPath()
+	if err != nil {
+		log.Fatalf("Failed to locate file index: %v", err)
+	}
+	fileIndex := fuse.NewFileIndex(indexPath)
+	if err := fileIndex.LoadIndex(); err != nil {
+		log.Fatalf("Failed to load file index: %v", err)
+	}
+	autoFetch, err := autofetch.NewEngine(autoFetchConfig, &autofetch.ClientFetcher{Client: noisefsClient}, fileIndex)
+	if err != nil {
+		log.Fatalf("Failed to create auto-fetch engine: %v", err)
+	}
+	autoFetch.Start()
+	defer autoFetch.Stop()
+
+	// Cover traffic draws on popular cached blocks and announced files
+	var coverTraffic *cover.Generator
+	if cfg.CoverTraffic.Enabled {
+		coverConfig, err := cfg.CoverTraffic.GeneratorConfig()
+		if err != nil {
+			log.Fatalf("Invalid cover traffic settings: %v", err)
+		}
+		announced := cover.AnnouncedSource(storageManager, func(n int) ([]string, error) {
+			recent, err := announcementStore.GetRecent(time.Now().Add(-7*24*time.Hour), n)
+			if err != nil {
+				return nil, err
+			}
+			cids := make([]string, 0, len(recent))
+			for _, ann := range recent {
+				cids = append(cids, ann.Descriptor)
+			}
+			return cids, nil
+		})
+		coverTraffic, err = cover.NewGenerator(storageManager, coverConfig, cover.CacheSource(blockCache), announced)
+		if err != nil {
+			log.Fatalf("Failed to create cover traffic generator: %v", err)
+		}
+		coverTraffic.Start()
+		defer coverTraffic.Stop()
+		log.Printf("Cover traffic enabled: %d blocks about every %s, up to %s/s", coverConfig.BlocksPerRound, coverConfig.Interval, cfg.CoverTraffic.Bandwidth)
+	}
+
+	webhookDispatcher := webhooks.New(cfg.Webhooks)
+	defer webhookDispatcher.Close(5 * time.Second)
+
+	// Keep descriptors pinned with noisefs pin add available
+	pinInterval, err := cfg.Daemon.PinMaintenanceIntervalDuration()
+	if err != nil {
+		log.Fatalf("Invalid pin maintenance interval: %v", err)
+	}
+	if pinInterval > 0 {
+		pinRegistryPath, err := storage.DefaultPinRegistryPath()
+		if err != nil {
+			log.Fatalf("Failed to locate pins: %v", err)
+		}
+		pinConfig := storage.PinMaintenanceConfig{
+			Interval:   pinInterval,
+			SampleSize: cfg.Daemon.PinSampleSize,
+			Threshold:  cfg.Daemon.PinAvailabilityThreshold,
+		}
+		if pinConfig.SampleSize == 0 {
+			pinConfig.SampleSize = storage.DefaultPinSampleSize
+		}
+		if pinConfig.Threshold == 0 {
+			pinConfig.Threshold = storage.DefaultPinAvailabilityThreshold
+		}
+		pinMaintainer := storage.NewPinMaintainer(storageManager, pinRegistryPath, pinConfig, func(pin storage.PinAvailability) {
+			webhookDispatcher.PinDegraded(webhooks.PinAvailability{
+				DescriptorCID: pin.DescriptorCID,
+				Name:          pin.Name,
+				Backends:      pin.Backends,
+				Availability:  pin.Availability,
+				Threshold:     pinConfig.Threshold,
+				Sampled:       pin.Sampled,
+				Missing:       pin.Missing,
+			})
+		})
+		if err := pinMaintainer.Start(context.Background()); err != nil {
+			log.Fatalf("Failed to start pin maintenance: %v", err)
+		}
+		defer pinMaintainer.Stop()
+		log.Printf("Pin maintenance enabled: every %s, %d blocks sampled per pin", pinInterval, pinConfig.SampleSize)
+	}
+
+	// Queued uploads and downloads, run in the background and saved across
+	// restarts; noisefs transfers manages them through the control socket
+	transferBandwidth, err := cfg.WebUI.TransferBandwidthBytes()
+	if err != nil {
+		log.Fatalf("Invalid transfer bandwidth: %v", err)
+	}
+	transferManager, err := transfers.NewManager(&transfers.ClientRunner{Client: noisefsClient}, transfers.Config{
+		StatePath:   transferPath(cfg.WebUI.DataDir, "queue.json"),
+		DownloadDir: transferPath(cfg.WebUI.DataDir, "downloads"),
+		Concurrency: cfg.WebUI.TransferConcurrency,
+		Bandwidth:   transferBandwidth,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load transfer queue: %v", err)
+	}
+
+	sharesPath, err := descriptors.DefaultShareStorePath()
+	if err != nil {
+		log.Fatalf("Failed to locate shares: %v", err)
+	}
+	takedownsPath, err := compliance.DefaultTakedownStorePath()
+	if err != nil {
+		log.Fatalf("Failed to locate takedowns: %v", err)
+	}
+	collectionsPath, err := store.DefaultCollectionStorePath()
+	if err != nil {
+		log.Fatalf("Failed to locate collections: %v", err)
+	}
+	curatorKeyPath, err := announce.DefaultCuratorKeyPath()
+	if err != nil {
+		log.Fatalf("Failed to locate the curator key: %v", err)
+	}
+	tokensPath, err := auth.DefaultTokenStorePath()
+	if err != nil {
+		log.Fatalf("Failed to locate API tokens: %v", err)
+	}
+	downloadQuota, err := newDownloadQuota(cfg.WebUI.Gateway, rateLimitBackend)
+	if err != nil {
+		log.Fatalf("Failed to set up the download quota: %v", err)
+	}
+	disclaimerPath, err := compliance.DefaultAcknowledgementPath()
+	if err != nil {
+		log.Fatalf("Failed to locate the legal acknowledgement: %v", err)
+	}
+	if *acceptTOS && !compliance.DisclaimerAccepted(disclaimerPath) {
+		if _, err := compliance.AcceptDisclaimer(disclaimerPath, "webui"); err != nil {
+			log.Fatalf("Failed to accept the legal disclaimer: %v", err)
+		}
+	}
 
 	// Create unified web UI
 	webui := &UnifiedWebUI{
@@ -327,7 +747,6 @@ func main() {
 		cache:         blockCache,
 		config:        cfg,
 		validator:     validator,
-		rateLimiter:   rateLimiter,
 		
 		// Announcements
 		store:            announcementStore,
@@ -338,16 +757,69 @@ func main() {
 		hierarchy:        hierarchy,
 		search:           searchEngine,
 		securityMgr:      securityMgr,
+		ipfsShell:        ipfsShell,
 		
-		// WebSocket
-		wsUpgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for development
-			},
-		},
-		wsClients:     make(map[*websocket.Conn]chan interface{}),
+		// WebSocket; the upgrader's default refuses cross-origin pages
+		wsUpgrader: websocket.Upgrader{},
+		ws:            newWSHub(),
 		subscriptions: config.NewSubscriptions(),
+
+		// Live stats
+		stats: newStatsRecorder(blockCache),
+
+		// Auto-fetch
+		autoFetch:     autoFetch,
+		autoFetchPath: autoFetchPath,
+		fileIndex:     fileIndex,
+
+		// Limited shares
+		shares: descriptors.NewShareStore(sharesPath),
+
+		// Takedowns
+		takedowns: compliance.NewTakedownStore(takedownsPath),
+
+		// Legal disclaimer
+		disclaimerPath: disclaimerPath,
+
+		// Cover traffic
+		coverTraffic: coverTraffic,
+
+		// Webhooks
+		webhooks: webhookDispatcher,
+
+		// Transfers
+		transfers: transferManager,
+
+		// Collections
+		collections:    store.NewCollectionStore(collectionsPath),
+		curatorKeyPath: curatorKeyPath,
+
+		// Metadata
+		metadataCache: make(map[string]*announce.Metadata),
+
+		// Descriptor info
+		infoCache:     make(map[string]cachedInfo),
+		verifications: make(map[string]string),
+
+		// Gateway mode
+		quota: downloadQuota,
+
+		// API tokens
+		tokens:   auth.NewTokenStore(tokensPath),
+		sessions: newSessionStore(),
+	}
+	defer webui.ws.close()
+	transferManager.OnChange(webui.transferChanged)
+	transferManager.Start()
+	defer transferManager.Stop()
+	noisefsClient.SetMetricsHistory(noisefs.NewMetricsHistory(cfg.Daemon.MetricsHistoryWindow(), noisefs.HistoryInterval))
+	go noisefsClient.RecordMetricsHistory(context.Background())
+	if controlServer != nil {
+		control.RegisterTransferHandlers(controlServer, transferManager)
+		control.RegisterHistoryHandlers(controlServer, noisefsClient.MetricsHistory())
 	}
+	go webui.broadcastStats()
+	go webui.broadcastStorageEvents()
 
 	// Load saved subscriptions
 	if err := webui.loadSubscriptions(); err != nil {
@@ -357,43 +829,166 @@ func main() {
 	// Start subscribers
 	dhtSubscriber.Start()
 	defer dhtSubscriber.Stop()
+	checker.AddReadinessCheck("dht_subscriber", func(ctx context.Context) error {
+		if !dhtSubscriber.Running() {
+			return fmt.Errorf("DHT subscriber is not running")
+		}
+		return nil
+	})
+	checker.AddReadinessCheck("pubsub_subscriber", func(ctx context.Context) error {
+		if !pubsubSubscriber.Running() {
+			return fmt.Errorf("PubSub subscriber is not running")
+		}
+		return nil
+	})
 
 	// Setup routes
 	router := mux.NewRouter()
+	httpLogger := logging.GetGlobalLogger().WithComponent("webui")
+	router.Use(
+		middleware.RequestID(),
+		middleware.Recover(httpLogger),
+		middleware.Logging(httpLogger),
+		middleware.SecurityHeaders(""),
+		middleware.MaxBodySize(maxRequestBytes),
+		middleware.CSRF(),
+		announceProtocol,
+	)
 
 	// Static files
 	router.PathPrefix("/static/").Handler(
 		http.StripPrefix("/static/", http.FileServer(http.Dir("cmd/noisefs-webui/static"))),
 	)
 
-	// Page routes
+	// Health probes
+	router.Handle("/healthz", checker.LivenessHandler()).Methods("GET", "HEAD")
+	router.Handle("/readyz", checker.ReadinessHandler()).Methods("GET", "HEAD")
+
+	// Prometheus metrics, for scraping like the health probes
+	if cfg.WebUI.Metrics {
+		router.Handle("/metrics", metrics.Handler(metrics.Sources{
+			Client:                noisefsClient,
+			Storage:               storageManager,
+			Announcements:         announcementStore,
+			AnnouncementsReceived: webui.stats.announcements.Load,
+			WebSockets:            webui.ws,
+		})).Methods("GET")
+	}
+
+	// Debug endpoints, authorized like the API
+	debug := router.PathPrefix("/debug").Subrouter()
+	if cfg.WebUI.RequireTokens {
+		debug.Use(webui.authorize)
+	}
+	debug.HandleFunc("/history", webui.handleDebugHistory).Methods("GET")
+	if cfg.WebUI.Profiling {
+		webui.registerProfilingRoutes(debug)
+		if cfg.WebUI.AdminToken == "" {
+			log.Printf("Profiling enabled: /debug/pprof and /debug/runtime need an API token with the admin role")
+		} else {
+			log.Printf("Profiling enabled: /debug/pprof and /debug/runtime need the admin token")
+		}
+	}
+
+	// Page routes. A gateway is download-only, so it has no upload page.
+	gateway := cfg.WebUI.Gateway.Enabled
 	router.HandleFunc("/", webui.handleIndex).Methods("GET")
 	router.HandleFunc("/disclaimer", webui.handleDisclaimer).Methods("GET")
-	router.HandleFunc("/upload", webui.handleUploadPage).Methods("GET")
+	router.HandleFunc("/login", webui.handleLoginPage).Methods("GET")
+	if !gateway {
+		router.HandleFunc("/upload", webui.handleUploadPage).Methods("GET")
+	}
 	router.HandleFunc("/download", webui.handleDownloadPage).Methods("GET")
 	router.HandleFunc("/browse", webui.handleBrowsePage).Methods("GET")
 	router.HandleFunc("/dashboard", webui.handleDashboard).Methods("GET")
 	router.HandleFunc("/topics", webui.handleTopicsPage).Methods("GET")
 	router.HandleFunc("/search", webui.handleSearchPage).Methods("GET")
+	router.HandleFunc("/collections", webui.handleCollectionsPage).Methods("GET")
+	router.HandleFunc("/collections/{id}", webui.handleCollectionsPage).Methods("GET")
 
 	// File API routes
 	api := router.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/upload", webui.handleUpload).Methods("POST")
-	api.HandleFunc("/download/{cid}", webui.handleDownload).Methods("GET")
-	api.HandleFunc("/stream/{cid}", webui.handleStream).Methods("GET")
+	if cfg.WebUI.RequireTokens {
+		api.Use(webui.authorize)
+	}
+	api.Use(webui.requireDisclaimer)
+	api.HandleFunc("/disclaimer", webui.handleGetDisclaimer).Methods("GET")
+	api.HandleFunc("/gateway", webui.handleGateway).Methods("GET")
+	api.HandleFunc("/version", webui.handleVersion).Methods("GET")
+	api.HandleFunc("/session", webui.handleGetSession).Methods("GET")
+	api.HandleFunc("/session", webui.handleCreateSession).Methods("POST")
+	api.HandleFunc("/session", webui.handleDeleteSession).Methods("DELETE")
+	if cfg.WebUI.AdminToken != "" || cfg.WebUI.RequireTokens {
+		// The operator accepts for the node, so don't let visitors do it
+		api.Handle("/disclaimer/accept", webui.requireAdmin(http.HandlerFunc(webui.handleAcceptDisclaimer))).Methods("POST")
+	} else if !gateway {
+		api.HandleFunc("/disclaimer/accept", webui.handleAcceptDisclaimer).Methods("POST")
+	}
+	uploadLimit := middleware.RateLimit(rateLimiter, func(r *http.Request, err error) {
+		webui.audit(r, logging.AuditAccessDenied, r.URL.Path, err, map[string]string{"reason": "rate_limit"})
+	})
+	api.Handle("/download/{cid}", webui.limitDownloads(http.HandlerFunc(webui.handleDownload))).Methods("GET")
+	api.Handle("/stream/{cid}", webui.limitDownloads(http.HandlerFunc(webui.handleStream))).Methods("GET")
+	api.HandleFunc("/info", webui.handleBulkInfo).Methods("POST")
 	api.HandleFunc("/info/{cid}", webui.handleInfo).Methods("GET")
-	api.HandleFunc("/announce", webui.handleAnnounce).Methods("POST")
-	
+	api.HandleFunc("/shares/{id}/redeem", webui.handleRedeemShare).Methods("POST")
+	api.Handle("/shares/{id}/download", webui.limitDownloads(http.HandlerFunc(webui.handleShareDownload))).Methods("GET")
+	api.HandleFunc("/transparency", webui.handleTransparency).Methods("GET")
+	if !gateway {
+		// Uploading, announcing and managing the node's own files
+		api.Handle("/upload", uploadLimit(http.HandlerFunc(webui.handleUpload))).Methods("POST")
+		api.HandleFunc("/files/search", webui.handleFileSearch).Methods("GET")
+		api.HandleFunc("/shares", webui.handleCreateShare).Methods("POST")
+		api.HandleFunc("/shares", webui.handleListShares).Methods("GET")
+		api.HandleFunc("/shares/{id}", webui.handleRevokeShare).Methods("DELETE")
+		api.HandleFunc("/transfers", webui.handleListTransfers).Methods("GET")
+		api.Handle("/transfers", uploadLimit(http.HandlerFunc(webui.handleAddTransfer))).Methods("POST")
+		api.HandleFunc("/transfers/{id}", webui.handleGetTransfer).Methods("GET")
+		api.HandleFunc("/transfers/{id}", webui.handleRemoveTransfer).Methods("DELETE")
+		api.HandleFunc("/transfers/{id}/priority", webui.handleSetTransferPriority).Methods("PUT")
+		api.HandleFunc("/transfers/{id}/bandwidth", webui.handleSetTransferBandwidth).Methods("PUT")
+		api.HandleFunc("/transfers/{id}/schedule", webui.handleScheduleTransfer).Methods("PUT")
+		api.HandleFunc("/transfers/{id}/file", webui.handleTransferFile).Methods("GET")
+		api.HandleFunc("/transfers/{id}/{action}", webui.handleTransferAction).Methods("POST")
+		api.HandleFunc("/announce", webui.handleAnnounce).Methods("POST")
+	}
+
 	// Announcement API routes
 	api.HandleFunc("/announcements", webui.handleGetAnnouncements).Methods("GET")
 	api.HandleFunc("/announcements/search", webui.handleSearchAnnouncements).Methods("POST")
+	api.HandleFunc("/announcements/{descriptor}/metadata", webui.handleGetAnnouncementMetadata).Methods("GET")
+	api.HandleFunc("/collections", webui.handleListCollections).Methods("GET")
+	api.HandleFunc("/collections/{id}", webui.handleGetCollection).Methods("GET")
 	api.HandleFunc("/topics", webui.handleGetTopics).Methods("GET")
-	api.HandleFunc("/topics/{topic}/subscribe", webui.handleSubscribe).Methods("POST")
-	api.HandleFunc("/topics/{topic}/unsubscribe", webui.handleUnsubscribe).Methods("POST")
+	api.HandleFunc("/topics/{topic:.+}/stats", webui.handleTopicStats).Methods("GET")
 	api.HandleFunc("/subscriptions", webui.handleGetSubscriptions).Methods("GET")
+	if !gateway {
+		// Changing what the node follows, fetches and publishes
+		api.HandleFunc("/searches", webui.handleListSavedSearches).Methods("GET")
+		api.HandleFunc("/searches", webui.handleCreateSavedSearch).Methods("POST")
+		api.HandleFunc("/searches/{id}", webui.handleDeleteSavedSearch).Methods("DELETE")
+		api.HandleFunc("/searches/{id}/results", webui.handleSavedSearchResults).Methods("GET")
+		api.HandleFunc("/collections", webui.handlePublishCollection).Methods("POST")
+		api.HandleFunc("/collections/{id}", webui.handleRemoveCollection).Methods("DELETE")
+		api.HandleFunc("/topics/{topic}/subscribe", webui.handleSubscribe).Methods("POST")
+		api.HandleFunc("/topics/{topic}/unsubscribe", webui.handleUnsubscribe).Methods("POST")
+		api.HandleFunc("/autofetch", webui.handleGetAutoFetch).Methods("GET")
+		api.HandleFunc("/autofetch", webui.handleSetAutoFetch).Methods("PUT")
+		api.HandleFunc("/autofetch/rules", webui.handleSetAutoFetchRule).Methods("POST")
+		api.HandleFunc("/autofetch/rules/{name}", webui.handleDeleteAutoFetchRule).Methods("DELETE")
+		api.HandleFunc("/autofetch/history", webui.handleAutoFetchHistory).Methods("GET")
+		api.HandleFunc("/storage/peers", webui.handlePeerStats).Methods("GET")
+	}
 	api.HandleFunc("/stats", webui.handleGetStats).Methods("GET")
+	api.HandleFunc("/stats/history", webui.handleStatsHistory).Methods("GET")
 	api.HandleFunc("/metrics", webui.handleMetrics).Methods("GET")
+	api.HandleFunc("/cover", webui.handleCoverStats).Methods("GET")
 	api.HandleFunc("/ws", webui.handleWebSocket)
+	if gateway {
+		log.Printf("Gateway mode: uploads, announcing, transfers and the admin API are disabled")
+	} else {
+		webui.registerAdminRoutes(api)
+	}
 
 	// Add disclaimer notice
 	fmt.Printf("\n========================================\n")
@@ -401,16 +996,41 @@ func main() {
 	fmt.Printf("   By using NoiseFS, you agree to comply with all applicable laws.\n")
 	fmt.Printf("   See /disclaimer for full terms of use.\n")
 	fmt.Printf("========================================\n\n")
+	if !webui.disclaimerAccepted() {
+		log.Printf("The API is disabled until the legal disclaimer is accepted at /disclaimer, with -accept-tos or with the CLI")
+	}
 	
 	// Start server
-	fmt.Printf("NoiseFS Unified Web UI running at http://localhost%s\n", *addr)
+	fmt.Printf("NoiseFS Unified Web UI running at http://localhost%s\n", cfg.WebUI.Address)
 	
-	if *enableTLS {
+	if cfg.WebUI.TLS || cfg.WebUI.ACME {
 		var tlsConfig *tls.Config
 		
-		if *certFile != "" && *keyFile != "" {
+		if cfg.WebUI.ACME {
+			// Obtain and renew certificates from the ACME CA
+			acmeManager, err := webuitls.NewACMEManager(webuitls.ACMEConfig{
+				Domains:      cfg.WebUI.ACMEDomains,
+				Email:        cfg.WebUI.ACMEEmail,
+				CacheDir:     cfg.WebUI.ACMECacheDir,
+				DirectoryURL: cfg.WebUI.ACMEDirectoryURL,
+			})
+			if err != nil {
+				log.Fatalf("Failed to set up ACME: %v", err)
+			}
+			tlsConfig = acmeManager.TLSConfig()
+			
+			if cfg.WebUI.ACMEHTTPAddress != "" {
+				go func() {
+					log.Printf("Serving ACME HTTP-01 challenges on %s", cfg.WebUI.ACMEHTTPAddress)
+					if err := http.ListenAndServe(cfg.WebUI.ACMEHTTPAddress, acmeManager.HTTPHandler()); err != nil {
+						log.Printf("ACME HTTP-01 listener stopped: %v", err)
+					}
+				}()
+			}
+			fmt.Printf("ACME certificates for: %s\n", strings.Join(cfg.WebUI.ACMEDomains, ", "))
+		} else if cfg.WebUI.CertFile != "" && cfg.WebUI.KeyFile != "" {
 			// Use provided certificate
-			cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+			cert, err := loadTLSCertificate(cfg.WebUI.CertFile, cfg.WebUI.KeyFile)
 			if err != nil {
 				log.Fatalf("Failed to load TLS certificates: %v", err)
 			}
@@ -424,16 +1044,26 @@ func main() {
 			tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
 		}
 		
-		server := &http.Server{
-			Addr:      *addr,
-			Handler:   router,
-			TLSConfig: tlsConfig,
-		}
+		server := newHTTPServer(cfg.WebUI, router, tlsConfig)
 		
-		fmt.Printf("HTTPS enabled (visit https://localhost%s)\n", *addr)
-		log.Fatal(server.ListenAndServeTLS("", ""))
+		fmt.Printf("HTTPS enabled (visit https://localhost%s)\n", cfg.WebUI.Address)
+		listener, err := listen(cfg.WebUI)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", cfg.WebUI.Address, err)
+		}
+		serving.Store(true)
+		log.Fatal(server.ServeTLS(listener, "", ""))
 	} else {
-		log.Fatal(http.ListenAndServe(*addr, router))
+		server := newHTTPServer(cfg.WebUI, router, nil)
+		if cfg.WebUI.H2C {
+			fmt.Printf("Cleartext HTTP/2 (h2c) enabled\n")
+		}
+		listener, err := listen(cfg.WebUI)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", cfg.WebUI.Address, err)
+		}
+		serving.Store(true)
+		log.Fatal(server.Serve(listener))
 	}
 }
 
@@ -471,16 +1101,130 @@ func (w *UnifiedWebUI) handleSearchPage(wr http.ResponseWriter, r *http.Request)
 	http.ServeFile(wr, r, "cmd/noisefs-webui/templates/search.html")
 }
 
-// File management handlers
+// loadTLSCertificate loads a certificate and key given as file paths or, when
+// resolved from a secret:// reference, as inline PEM
+func loadTLSCertificate(cert, key string) (tls.Certificate, error) {
+	certPEM, err := readPEM(cert)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := readPEM(key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read key: %w", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
 
-func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
-	// Check rate limit
-	if err := w.rateLimiter.CheckLimit(r); err != nil {
-		sendError(wr, err, http.StatusTooManyRequests)
-		return
+// readPEM returns inline PEM data as-is and reads anything else as a path
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
+
+// applyReloadableSettings applies the settings listed in config.ReloadableKeys
+func applyReloadableSettings(cfg *noisefsConfig.Config, storageManager *storage.Manager, blockCache *cache.MemoryCache) error {
+	level, err := logging.ParseLogLevel(cfg.Logging.Level)
+	if err != nil {
+		return err
+	}
+	logging.GetGlobalLogger().SetLevel(level)
+	if err := logging.InitComponentLevelsFromConfig(cfg.Logging.Components, cfg.Logging.SampleInitial, cfg.Logging.SampleThereafter); err != nil {
+		return err
+	}
+
+	blockCache.SetCapacity(cfg.Cache.BlockCacheSize)
+
+	bandwidth, err := cfg.Network.MaxBandwidthBytes()
+	if err != nil {
+		return fmt.Errorf("invalid max bandwidth: %w", err)
+	}
+	if bandwidth > 0 {
+		storageManager.SetTransferLimiter(workers.NewTransferLimiter(bandwidth, 0))
+	} else {
+		storageManager.SetTransferLimiter(nil)
+	}
+
+	return nil
+}
+
+// reportReload logs and audits the outcome of a configuration reload
+func reportReload(result *noisefsConfig.ReloadResult, err error) {
+	event := logging.AuditEvent{
+		Type:    logging.AuditConfigurationChange,
+		Actor:   "system",
+		Outcome: logging.AuditSuccess,
+		Details: make(map[string]string),
+	}
+
+	if err != nil {
+		log.Printf("Configuration reload failed: %v", err)
+		event.Outcome = logging.AuditFailure
+		event.Details["error"] = err.Error()
+	}
+	if result != nil {
+		if len(result.Applied) > 0 {
+			log.Printf("Configuration reloaded: %s", strings.Join(result.Applied, ", "))
+			event.Details["applied"] = strings.Join(result.Applied, ",")
+		}
+		if len(result.RestartRequired) > 0 {
+			log.Printf("Configuration changes require a restart: %s", strings.Join(result.RestartRequired, ", "))
+			event.Details["restart_required"] = strings.Join(result.RestartRequired, ",")
+		}
+		if len(event.Details) == 0 {
+			return
+		}
+	}
+
+	if err := logging.Audit(event); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// audit records a security-relevant event attributed to the requesting client
+func (w *UnifiedWebUI) audit(r *http.Request, eventType logging.AuditEventType, resource string, opErr error, details map[string]string) {
+	event := logging.AuditEvent{
+		Type:     eventType,
+		Actor:    validation.ClientIP(r),
+		Resource: resource,
+		Outcome:  logging.AuditSuccess,
+		Details:  details,
+	}
+	if opErr != nil {
+		event.Outcome = logging.AuditFailure
+		if event.Details == nil {
+			event.Details = make(map[string]string)
+		}
+		event.Details["error"] = opErr.Error()
+	}
+	if token := requestTokenInfo(r); token != nil {
+		if event.Details == nil {
+			event.Details = make(map[string]string)
+		}
+		event.Details["token"] = token.ID
 	}
-	defer w.rateLimiter.ReleaseRequest(r)
+	if id := logging.RequestIDFromContext(r.Context()); id != "" {
+		if event.Details == nil {
+			event.Details = make(map[string]string)
+		}
+		event.Details["request_id"] = id
+	}
+
+	if err := logging.Audit(event); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// requestLog returns the web UI logger with a request's ID, for log lines
+// about the request
+func requestLog(r *http.Request) *logging.FieldLogger {
+	return logging.GetGlobalLogger().WithComponent("webui").WithContext(r.Context())
+}
+
+// File management handlers
 
+func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
 	// Parse multipart form
 	err := r.ParseMultipartForm(100 << 20) // 100MB max
 	if err != nil {
@@ -505,6 +1249,15 @@ func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sniff the real content type and apply the upload policy
+	contentType, content, err := w.validator.ValidateUploadContent(header.Filename, header.Size, file)
+	if err != nil {
+		w.audit(r, logging.AuditUpload, header.Filename, err, map[string]string{"reason": "content_policy"})
+		sendError(wr, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	requestLog(r).Debugf("Upload - Detected content type: %s for %s", contentType, header.Filename)
+
 	// Get optional metadata
 	topic := r.FormValue("topic")
 	tagsStr := r.FormValue("tags")
@@ -519,31 +1272,30 @@ func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create a progress tracker
-	progressUpdates := make(chan string, 10)
-	go func() {
-		for update := range progressUpdates {
-			log.Printf("Upload progress: %s", update)
-		}
-	}()
-	
+	// Upload the multipart file itself when it can be rewound, so the client
+	// can recognise files uploaded before
+	var upload io.Reader = content
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		upload = file
+	}
+
 	// Upload file using the client's proper implementation with progress
-	descriptorCID, err := w.noisefsClient.UploadWithProgress(context.Background(), file, header.Filename, func(stage string, current, total int) {
-		percent := 0
-		if total > 0 {
-			percent = (current * 100) / total
-		}
-		select {
-		case progressUpdates <- fmt.Sprintf("%s: %d%%", stage, percent):
-		default:
-		}
-	})
-	close(progressUpdates)
+	progress, stopProgress := logProgress("Upload")
+	descriptorCID, err := w.noisefsClient.UploadWithProgress(context.WithoutCancel(r.Context()), upload, header.Filename, progress)
+	stopProgress()
 	
+	w.audit(r, logging.AuditUpload, descriptorCID, err, map[string]string{"filename": header.Filename, "content_type": contentType})
 	if err != nil {
-		sendError(wr, err, http.StatusInternalServerError)
+		sendError(wr, err, processorStatus(err, http.StatusInternalServerError))
 		return
 	}
+	w.stats.uploads.Add(1)
+	w.webhooks.UploadCompleted(webhooks.Upload{
+		DescriptorCID: descriptorCID,
+		Filename:      header.Filename,
+		Size:          header.Size,
+		Source:        "webui",
+	})
 
 	// Optionally announce the file
 	if topic != "" {
@@ -569,12 +1321,16 @@ func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
 		}
 		
 		// Publish announcement
-		ctx := context.Background()
-		if err := w.dhtPublisher.Publish(ctx, announcement); err != nil {
-			log.Printf("Failed to publish to DHT: %v", err)
+		ctx := context.WithoutCancel(r.Context())
+		err := w.dhtPublisher.Publish(ctx, announcement)
+		w.audit(r, logging.AuditAnnounce, descriptorCID, err, map[string]string{"topic": topic})
+		if err != nil {
+			requestLog(r).Warnf("Failed to publish to DHT: %v", err)
 		}
-		if err := w.pubsubPublisher.Publish(ctx, announcement); err != nil {
-			log.Printf("Failed to publish to PubSub: %v", err)
+		if w.config.Privacy.AnnounceRealtime {
+			if err := w.pubsubPublisher.Publish(ctx, announcement); err != nil {
+				requestLog(r).Warnf("Failed to publish to PubSub: %v", err)
+			}
 		}
 		
 		// Store locally
@@ -597,62 +1353,76 @@ func (w *UnifiedWebUI) handleUpload(wr http.ResponseWriter, r *http.Request) {
 
 func (w *UnifiedWebUI) handleDownload(wr http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	descriptorCID := vars["cid"]
-
-	if err := w.validator.ValidateCID(descriptorCID); err != nil {
+	// Accept any multibase encoding or an IPNS name and work with the canonical CID
+	descriptorCID, err := w.validator.ResolveCID(r.Context(), vars["cid"])
+	if err != nil {
 		sendError(wr, err, http.StatusBadRequest)
 		return
 	}
+	if w.refuseTakenDown(wr, r, descriptorCID) {
+		return
+	}
 
 	// First, try to load as a NoiseFS descriptor
-	_, err := w.loadDescriptor(descriptorCID)
+	password := descriptorPassword(r)
+	descriptor, encrypted, err := w.noisefsClient.LoadDescriptor(descriptorCID, password)
+	if err != nil && encrypted {
+		// Missing or wrong password; don't serve the encrypted descriptor itself
+		sendError(wr, err, http.StatusUnauthorized)
+		return
+	}
 	if err == nil {
+		// One file of a pack
+		if name := r.URL.Query().Get("path"); name != "" {
+			w.servePackedFile(wr, r, descriptorCID, descriptor, encrypted, name)
+			return
+		}
+
+		// Answer from the client's copy when it is current, without
+		// downloading the file
+		validators := newContentValidators(descriptorCID, descriptor, encrypted)
+		if validators.notModified(wr, r) {
+			return
+		}
+
 		// It's a valid NoiseFS descriptor, proceed with normal download
-		// Create a progress tracker
-		progressUpdates := make(chan string, 10)
-		go func() {
-			for update := range progressUpdates {
-				log.Printf("Download progress: %s", update)
-			}
-		}()
-		
 		// Download file using the client's proper implementation with progress
-		data, filename, err := w.noisefsClient.DownloadWithMetadataAndProgress(context.Background(), descriptorCID, func(stage string, current, total int) {
-			percent := 0
-			if total > 0 {
-				percent = (current * 100) / total
-			}
-			select {
-			case progressUpdates <- fmt.Sprintf("%s: %d%%", stage, percent):
-			default:
-			}
-		})
-		close(progressUpdates)
+		progress, stopProgress := logProgress("Download")
+		file, err := w.noisefsClient.DownloadFile(context.WithoutCancel(r.Context()), descriptorCID, password, progress)
+		stopProgress()
+		w.recordVerification(descriptorCID, file, err)
 		
+		filename := ""
+		if file != nil {
+			filename = file.Filename
+		}
+		w.audit(r, logging.AuditDownload, descriptorCID, err, map[string]string{"filename": filename})
 		if err != nil {
-			sendError(wr, err, http.StatusNotFound)
+			sendError(wr, err, processorStatus(err, http.StatusNotFound))
 			return
 		}
+		w.stats.downloads.Add(1)
 
 		// Set headers
 		wr.Header().Set("Content-Type", "application/octet-stream")
-		wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-		wr.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.Filename))
 
-		// Write data
-		if _, err := wr.Write(data); err != nil {
-			log.Printf("Download error: %v", err)
+		// Write data, or the range a resumed download asks for
+		if _, err := serveContent(wr, r, file.Reader, file.Size, validators); err != nil {
+			requestLog(r).Warnf("Download error: %v", err)
 		}
 	} else {
 		// Not a NoiseFS descriptor, try direct IPFS download
-		log.Printf("Not a NoiseFS descriptor, attempting direct IPFS download: %v", err)
+		requestLog(r).Debugf("Not a NoiseFS descriptor, attempting direct IPFS download: %v", err)
 		
 		// Download directly from IPFS using shell
-		reader, err := shell.NewShell(w.config.IPFS.APIEndpoint).Cat(descriptorCID)
+		reader, err := w.ipfsShell.Cat(descriptorCID)
+		w.audit(r, logging.AuditDownload, descriptorCID, err, map[string]string{"source": "ipfs"})
 		if err != nil {
 			sendError(wr, fmt.Errorf("failed to download file: %w", err), http.StatusNotFound)
 			return
 		}
+		w.stats.downloads.Add(1)
 		defer reader.Close()
 
 		// Read data from reader
@@ -662,47 +1432,11 @@ func (w *UnifiedWebUI) handleDownload(wr http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Generate filename based on CID and detected content type
-		filename := fmt.Sprintf("file_%s", descriptorCID[:8])
-		contentType := "application/octet-stream"
-		
-		// Try to detect content type from data
-		if len(data) > 512 {
-			detectedType := http.DetectContentType(data[:512])
-			log.Printf("Download - Detected content type: %s for CID: %s", detectedType, descriptorCID)
-			if detectedType != "application/octet-stream" {
-				contentType = detectedType
-			}
-			
-			// Also check for magic bytes for common formats
-			if len(data) >= 12 {
-				// Check for QuickTime/MOV format
-				if string(data[4:12]) == "ftypqt  " || string(data[4:8]) == "ftyp" {
-					contentType = "video/quicktime"
-					filename += ".mov"
-				} else if string(data[4:11]) == "ftypmp4" {
-					contentType = "video/mp4"
-					filename += ".mp4"
-				}
-			} else {
-				// Fall back to content type based extensions
-				switch contentType {
-				case "video/mp4":
-					filename += ".mp4"
-				case "video/quicktime":
-					filename += ".mov"
-				case "image/jpeg":
-					filename += ".jpg"
-				case "image/png":
-					filename += ".png"
-				case "application/pdf":
-					filename += ".pdf"
-				case "text/plain":
-					filename += ".txt"
-				}
-			}
-		}
-
+		// Generate filename based on CID and the sniffed content type
+		contentType := validation.DetectContentType(data)
+		filename := fmt.Sprintf("file_%s", descriptorCID[:8]) + validation.ExtensionForContentType(contentType)
+		requestLog(r).Debugf("Download - Detected content type: %s for CID: %s", contentType, descriptorCID)
+
 		// Set headers
 		wr.Header().Set("Content-Type", contentType)
 		wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
@@ -710,106 +1444,119 @@ func (w *UnifiedWebUI) handleDownload(wr http.ResponseWriter, r *http.Request) {
 
 		// Write data
 		if _, err := wr.Write(data); err != nil {
-			log.Printf("Download error: %v", err)
+			requestLog(r).Warnf("Download error: %v", err)
 		}
 	}
 }
 
 func (w *UnifiedWebUI) handleStream(wr http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	cid := vars["cid"]
-
-	if err := w.validator.ValidateCID(cid); err != nil {
+	// Accept any multibase encoding or an IPNS name and work with the canonical CID
+	cid, err := w.validator.ResolveCID(r.Context(), vars["cid"])
+	if err != nil {
 		sendError(wr, err, http.StatusBadRequest)
 		return
 	}
-
-	// Download file data  
-	data, err := w.noisefsClient.Download(context.Background(), cid)
-	if err != nil {
-		sendError(wr, err, http.StatusNotFound)
+	if w.refuseTakenDown(wr, r, cid) {
 		return
 	}
 
-	// Parse range header
-	rangeHeader := r.Header.Get("Range")
-	var start, end int64
-	fileSize := int64(len(data))
-	
-	if rangeHeader != "" {
-		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); err != nil {
-			// Try parsing single value range
-			if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
-				sendError(wr, fmt.Errorf("invalid range header"), http.StatusBadRequest)
-				return
-			}
-			end = fileSize - 1
+	// Answer from the player's copy when it is current, without
+	// downloading the file. Errors are left to the download.
+	password := descriptorPassword(r)
+	var validators contentValidators
+	if descriptor, encrypted, err := w.noisefsClient.LoadDescriptor(cid, password); err == nil {
+		validators = newContentValidators(cid, descriptor, encrypted)
+		if validators.notModified(wr, r) {
+			return
 		}
-	} else {
-		start = 0
-		end = fileSize - 1
 	}
 
-	// Validate range
-	if start < 0 || end >= fileSize || start > end {
-		wr.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	// Download file data  
+	file, err := w.noisefsClient.DownloadFile(context.WithoutCancel(r.Context()), cid, password, nil)
+	w.recordVerification(cid, file, err)
+
+	// Players issue many range requests per file; only audit the first one
+	if rangeHeader := r.Header.Get("Range"); rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") || err != nil {
+		w.audit(r, logging.AuditDownload, cid, err, map[string]string{"mode": "stream"})
+	}
+	if errors.Is(err, descriptors.ErrPasswordRequired) {
+		sendError(wr, err, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		sendError(wr, err, processorStatus(err, http.StatusNotFound))
 		return
 	}
 
-	// Set headers for partial content
-	contentType := "application/octet-stream"
+	// Only media types are served inline; anything else could be rendered
+	// by the browser in the UI's origin.
+	header := make([]byte, validation.SniffLen)
+	n, _ := io.ReadFull(file.Reader, header)
+	contentType := validation.DetectContentType(header[:n])
+	if !strings.HasPrefix(contentType, "video/") && !strings.HasPrefix(contentType, "audio/") && !strings.HasPrefix(contentType, "image/") {
+		contentType = "application/octet-stream"
+	}
 	wr.Header().Set("Content-Type", contentType)
-	wr.Header().Set("Accept-Ranges", "bytes")
-	wr.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
-	wr.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
-	wr.WriteHeader(http.StatusPartialContent)
 
-	// Write requested range
-	if _, err := wr.Write(data[start:end+1]); err != nil {
-		log.Printf("Streaming error: %v", err)
+	// Write the requested range, or the whole file
+	if _, err := serveContent(wr, r, file.Reader, file.Size, validators); err != nil {
+		requestLog(r).Warnf("Streaming error: %v", err)
+	}
+}
+
+// addIndexInfo adds what the local file index knows about a descriptor.
+// If several paths share it, the first by name is used.
+func (w *UnifiedWebUI) addIndexInfo(info *DownloadInfo) {
+	if w.fileIndex == nil {
+		return
+	}
+	entries := w.fileIndex.FindByDescriptor(info.DescriptorCID)
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return
+	}
+	sort.Strings(paths)
+
+	entry := entries[paths[0]]
+	if entry.MimeType != "" {
+		info.ContentType = entry.MimeType
 	}
+	info.Tags = entry.Tags
+	info.ContentHash = entry.ContentHash
 }
 
 func (w *UnifiedWebUI) handleInfo(wr http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	descriptorCID := vars["cid"]
-
-	if err := w.validator.ValidateCID(descriptorCID); err != nil {
+	// Accept any multibase encoding or an IPNS name and work with the canonical CID
+	descriptorCID, err := w.validator.ResolveCID(r.Context(), vars["cid"])
+	if err != nil {
 		sendError(wr, err, http.StatusBadRequest)
 		return
 	}
+	if w.refuseTakenDown(wr, r, descriptorCID) {
+		return
+	}
 
 	// First, try to load as a NoiseFS descriptor
-	descriptor, err := w.loadDescriptor(descriptorCID)
+	info, validators, err := w.descriptorInfo(descriptorCID, descriptorPassword(r))
+	if err != nil && info.Encrypted {
+		sendError(wr, err, http.StatusUnauthorized)
+		return
+	}
 	if err == nil {
-		// It's a valid NoiseFS descriptor
-		// Determine content type from filename
-		contentType := "application/octet-stream"
-		if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".txt") {
-			contentType = "text/plain"
-		} else if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".pdf") {
-			contentType = "application/pdf"
-		} else if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".jpg") || strings.HasSuffix(strings.ToLower(descriptor.Filename), ".jpeg") {
-			contentType = "image/jpeg"
-		} else if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".png") {
-			contentType = "image/png"
-		} else if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".mp4") {
-			contentType = "video/mp4"
-		} else if strings.HasSuffix(strings.ToLower(descriptor.Filename), ".mp3") {
-			contentType = "audio/mpeg"
-		}
-
-		info := DownloadInfo{
-			Filename:      descriptor.Filename,
-			Size:          descriptor.FileSize,
-			ContentType:   contentType,
-			DescriptorCID: descriptorCID,
+		// It's a valid NoiseFS descriptor, whose info never changes
+		if setInfoHeaders(wr, r, info, validators) {
+			wr.WriteHeader(http.StatusNotModified)
+			return
 		}
-
 		sendJSON(wr, APIResponse{Success: true, Data: info})
 	} else {
 		// Not a NoiseFS descriptor, get info about the raw IPFS file
-		log.Printf("Not a NoiseFS descriptor, getting IPFS file info: %v", err)
+		requestLog(r).Debugf("Not a NoiseFS descriptor, getting IPFS file info: %v", err)
 		
 		// Generate filename based on CID
 		filename := fmt.Sprintf("file_%s", descriptorCID[:8])
@@ -817,47 +1564,17 @@ func (w *UnifiedWebUI) handleInfo(wr http.ResponseWriter, r *http.Request) {
 		fileSize := int64(0)
 		
 		// Try to get file size and detect content type by downloading first 512 bytes
-		reader, err := shell.NewShell(w.config.IPFS.APIEndpoint).Cat(descriptorCID)
+		reader, err := w.ipfsShell.Cat(descriptorCID)
 		if err == nil {
 			defer reader.Close()
 			
-			// Read first 512 bytes for content type detection
-			header := make([]byte, 512)
-			n, err := reader.Read(header)
-			if err == nil && n > 0 {
-				detectedType := http.DetectContentType(header[:n])
-				log.Printf("Detected content type: %s for CID: %s", detectedType, descriptorCID)
-				if detectedType != "application/octet-stream" {
-					contentType = detectedType
-				}
-				
-				// Also check for magic bytes for common formats
-				if n >= 12 {
-					// Check for QuickTime/MOV format
-					if string(header[4:12]) == "ftypqt  " || string(header[4:8]) == "ftyp" {
-						contentType = "video/quicktime"
-						filename += ".mov"
-					} else if string(header[4:11]) == "ftypmp4" {
-						contentType = "video/mp4" 
-						filename += ".mp4"
-					}
-				} else {
-					// Fall back to content type based extensions
-					switch contentType {
-					case "video/mp4":
-						filename += ".mp4"
-					case "video/quicktime":
-						filename += ".mov"
-					case "image/jpeg":
-						filename += ".jpg"
-					case "image/png":
-						filename += ".png"
-					case "application/pdf":
-						filename += ".pdf"
-					case "text/plain":
-						filename += ".txt"
-					}
-				}
+			// Read the leading bytes for content type detection
+			header := make([]byte, validation.SniffLen)
+			n, err := io.ReadFull(reader, header)
+			if n > 0 && (err == nil || err == io.ErrUnexpectedEOF) {
+				contentType = validation.DetectContentType(header[:n])
+				filename += validation.ExtensionForContentType(contentType)
+				requestLog(r).Debugf("Detected content type: %s for CID: %s", contentType, descriptorCID)
 			}
 			
 			// Try to estimate file size (this is not exact for streaming)
@@ -882,6 +1599,9 @@ func (w *UnifiedWebUI) handleAnnounce(wr http.ResponseWriter, r *http.Request) {
 		Topic         string   `json:"topic"`
 		Tags          []string `json:"tags"`
 		TTL           int64    `json:"ttl"`
+
+		Metadata *MetadataRequest `json:"metadata,omitempty"`
+		Sign     bool             `json:"sign,omitempty"` // Sign with this node's publisher key
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -889,11 +1609,16 @@ func (w *UnifiedWebUI) handleAnnounce(wr http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate CID
-	if err := w.validator.ValidateCID(req.DescriptorCID); err != nil {
+	// Validate CID and announce its canonical form
+	descriptorCID, err := w.validator.NormalizeCID(req.DescriptorCID)
+	if err != nil {
 		sendError(wr, err, http.StatusBadRequest)
 		return
 	}
+	req.DescriptorCID = descriptorCID
+	if w.refuseTakenDown(wr, r, descriptorCID) {
+		return
+	}
 
 	// Create announcement
 	topicHash := announce.HashTopic(req.Topic)
@@ -914,15 +1639,44 @@ func (w *UnifiedWebUI) handleAnnounce(wr http.ResponseWriter, r *http.Request) {
 		announcement.TagBloom = bloom.Encode()
 	}
 
+	ctx := context.WithoutCancel(r.Context())
+	if req.Metadata != nil {
+		metadata, err := w.newMetadata(descriptorCID, req.Metadata)
+		if err != nil {
+			sendError(wr, err, http.StatusBadRequest)
+			return
+		}
+		if announcement.Metadata, err = w.dhtPublisher.StoreMetadata(ctx, metadata); err != nil {
+			sendError(wr, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Signing comes last, as it covers everything else
+	if req.Sign {
+		key, err := w.loadCuratorKey(true)
+		if err != nil {
+			sendError(wr, err, http.StatusInternalServerError)
+			return
+		}
+		if err := announcement.Sign(key); err != nil {
+			sendError(wr, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Publish announcement
-	ctx := context.Background()
-	if err := w.dhtPublisher.Publish(ctx, announcement); err != nil {
+	err = w.dhtPublisher.Publish(ctx, announcement)
+	w.audit(r, logging.AuditAnnounce, req.DescriptorCID, err, map[string]string{"topic": req.Topic})
+	if err != nil {
 		sendError(wr, fmt.Errorf("failed to publish to DHT: %w", err), http.StatusInternalServerError)
 		return
 	}
 	
-	if err := w.pubsubPublisher.Publish(ctx, announcement); err != nil {
-		log.Printf("Failed to publish to PubSub: %v", err)
+	if w.config.Privacy.AnnounceRealtime {
+		if err := w.pubsubPublisher.Publish(ctx, announcement); err != nil {
+			requestLog(r).Warnf("Failed to publish to PubSub: %v", err)
+		}
 	}
 
 	// Store locally
@@ -937,19 +1691,34 @@ func (w *UnifiedWebUI) handleAnnounce(wr http.ResponseWriter, r *http.Request) {
 // Announcement handlers
 
 func (w *UnifiedWebUI) handleGetAnnouncements(wr http.ResponseWriter, r *http.Request) {
-	topic := r.URL.Query().Get("topic")
-	limit := 100
-	
-	var storedAnnouncements []*store.StoredAnnouncement
-	var err error
+	params := r.URL.Query()
+	offset, limit, err := parsePage(params.Get("offset"), params.Get("limit"))
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
 	
-	if topic != "" {
-		topicHash := announce.HashTopic(topic)
-		storedAnnouncements, err = w.store.GetByTopic(topicHash)
-	} else {
-		storedAnnouncements, err = w.store.GetRecent(time.Now().Add(-24*time.Hour), limit)
+	query := announce.StoreQuery{
+		Categories:  splitParam(params.Get("category")),
+		SizeClasses: splitParam(params.Get("size")),
+		SortBy:      announce.SortField(params.Get("sort")),
+		SortOrder:   announce.SortOrder(params.Get("order")),
+		Offset:      offset,
+		Limit:       limit,
+	}
+	if topic := params.Get("topic"); topic != "" {
+		query.TopicHashes = []string{announce.HashTopic(topic)}
+	}
+	if query.SortBy != "" && query.SortBy != announce.SortByTime && query.SortBy != announce.SortBySize {
+		sendError(wr, fmt.Errorf("invalid sort %q (use time or size)", query.SortBy), http.StatusBadRequest)
+		return
+	}
+	if query.SortOrder != "" && query.SortOrder != announce.SortAsc && query.SortOrder != announce.SortDesc {
+		sendError(wr, fmt.Errorf("invalid order %q (use asc or desc)", query.SortOrder), http.StatusBadRequest)
+		return
 	}
 	
+	storedAnnouncements, total, err := w.store.Query(query)
 	if err != nil {
 		sendError(wr, err, http.StatusInternalServerError)
 		return
@@ -963,7 +1732,12 @@ func (w *UnifiedWebUI) handleGetAnnouncements(wr http.ResponseWriter, r *http.Re
 		views = append(views, view)
 	}
 	
-	sendJSON(wr, APIResponse{Success: true, Data: views})
+	sendJSON(wr, APIResponse{Success: true, Data: AnnouncementPage{
+		Announcements: views,
+		Total:         total,
+		Offset:        offset,
+		Limit:         limit,
+	}})
 }
 
 func (w *UnifiedWebUI) handleSearchAnnouncements(wr http.ResponseWriter, r *http.Request) {
@@ -972,8 +1746,15 @@ func (w *UnifiedWebUI) handleSearchAnnouncements(wr http.ResponseWriter, r *http
 		sendError(wr, err, http.StatusBadRequest)
 		return
 	}
+	if query.Offset < 0 || query.Limit < 0 {
+		sendError(wr, fmt.Errorf("offset and limit cannot be negative"), http.StatusBadRequest)
+		return
+	}
+	if query.Limit == 0 || query.Limit > maxPageSize {
+		query.Limit = defaultPageSize
+	}
 	
-	results, err := w.search.Search(query)
+	results, total, err := w.search.SearchPage(query)
 	if err != nil {
 		sendError(wr, err, http.StatusInternalServerError)
 		return
@@ -983,11 +1764,127 @@ func (w *UnifiedWebUI) handleSearchAnnouncements(wr http.ResponseWriter, r *http
 	views := make([]AnnouncementView, 0, len(results))
 	for _, result := range results {
 		view := w.announcementToView(result.Announcement)
-		view.Tags = extractHighlightedTags(result.Highlights)
+		if tags := extractHighlightedTags(result.Highlights); len(tags) > 0 {
+			view.Tags = tags
+		}
+		view.Explanation = result.Explanation
 		views = append(views, view)
 	}
 	
-	sendJSON(wr, APIResponse{Success: true, Data: views})
+	sendJSON(wr, APIResponse{Success: true, Data: AnnouncementPage{
+		Announcements: views,
+		Total:         total,
+		Offset:        query.Offset,
+		Limit:         query.Limit,
+	}})
+}
+
+// handleFileSearch searches the local file index, the files this node has
+// stored, as opposed to announcements from the network
+func (w *UnifiedWebUI) handleFileSearch(wr http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	offset, limit, err := parsePage(params.Get("offset"), params.Get("limit"))
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+
+	query := fuse.IndexQuery{
+		Text:       params.Get("q"),
+		Name:       params.Get("name"),
+		Extensions: splitParam(params.Get("ext")),
+		Tags:       splitParam(params.Get("tag")),
+		Directory:  params.Get("dir"),
+		Type:       fuse.EntryType(params.Get("type")),
+		SortBy:     params.Get("sort"),
+		Offset:     offset,
+		Limit:      limit,
+	}
+	if query.Type != "" && query.Type != fuse.FileEntryType && query.Type != fuse.DirectoryEntryType {
+		sendError(wr, fmt.Errorf("invalid type %q (use file or directory)", query.Type), http.StatusBadRequest)
+		return
+	}
+	switch order := params.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		sendError(wr, fmt.Errorf("invalid order %q (use asc or desc)", order), http.StatusBadRequest)
+		return
+	}
+	if value := params.Get("min_size"); value != "" {
+		if query.MinSize, err = util.ParseSize(value); err != nil {
+			sendError(wr, fmt.Errorf("invalid min_size %q: %w", value, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("max_size"); value != "" {
+		if query.MaxSize, err = util.ParseSize(value); err != nil {
+			sendError(wr, fmt.Errorf("invalid max_size %q: %w", value, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("modified_after"); value != "" {
+		if query.ModifiedAfter, err = parseDateParam(value); err != nil {
+			sendError(wr, fmt.Errorf("invalid modified_after %q: %w", value, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := params.Get("modified_before"); value != "" {
+		if query.ModifiedBefore, err = parseDateParam(value); err != nil {
+			sendError(wr, fmt.Errorf("invalid modified_before %q: %w", value, err), http.StatusBadRequest)
+			return
+		}
+		if len(value) == len(time.DateOnly) {
+			query.ModifiedBefore = query.ModifiedBefore.AddDate(0, 0, 1) // Include the whole day
+		}
+	}
+
+	page := FileSearchPage{Files: []fuse.IndexMatch{}, Offset: offset, Limit: limit}
+	if w.fileIndex != nil {
+		if page.Files, page.Total, err = w.fileIndex.Search(query); err != nil {
+			sendError(wr, err, http.StatusBadRequest)
+			return
+		}
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: page})
+}
+
+// parseDateParam parses a date (2006-01-02) or RFC 3339 timestamp
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parsePage parses offset and limit query parameters, applying the
+// default and maximum page sizes
+func parsePage(offsetParam, limitParam string) (int, int, error) {
+	offset, limit := 0, defaultPageSize
+	var err error
+	if offsetParam != "" {
+		if offset, err = strconv.Atoi(offsetParam); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", offsetParam)
+		}
+	}
+	if limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", limitParam)
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+	return offset, limit, nil
+}
+
+// splitParam splits a comma-separated query parameter
+func splitParam(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
 }
 
 func (w *UnifiedWebUI) handleGetTopics(wr http.ResponseWriter, r *http.Request) {
@@ -1025,42 +1922,59 @@ func (w *UnifiedWebUI) handleSubscribe(wr http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	topic := vars["topic"]
 	
-	// Create announcement handler
-	handler := func(ann *announce.Announcement) error {
-		// Validate with security manager
-		if err := w.securityMgr.CheckAnnouncement(ann, "webui"); err != nil {
-			log.Printf("Rejected announcement: %v", err)
-			return nil // Don't propagate error
-		}
-		
-		// Store announcement
-		if err := w.store.Add(ann, "subscription"); err != nil {
-			return err
-		}
-		
-		// Broadcast to WebSocket clients
-		w.broadcastAnnouncement(ann)
-		
-		return nil
-	}
-	
-	// Subscribe to both DHT and PubSub
-	if err := w.dhtSubscriber.Subscribe(topic, handler); err != nil {
+	if err := w.subscribeTopic(topic); err != nil {
 		sendError(wr, err, http.StatusInternalServerError)
 		return
 	}
 	
-	if err := w.pubsubSubscriber.Subscribe(topic, handler); err != nil {
+	// Save subscription
+	w.saveSubscription(topic, true)
+	w.audit(r, logging.AuditSubscribe, topic, nil, nil)
+	
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// subscribeTopic subscribes to a topic over both DHT and PubSub
+func (w *UnifiedWebUI) subscribeTopic(topic string) error {
+	if err := w.dhtSubscriber.Subscribe(topic, w.handleIncomingAnnouncement); err != nil {
+		return err
+	}
+	
+	if err := w.pubsubSubscriber.Subscribe(topic, w.handleIncomingAnnouncement); err != nil {
 		// Rollback DHT subscription
 		w.dhtSubscriber.Unsubscribe(topic)
-		sendError(wr, err, http.StatusInternalServerError)
-		return
+		return err
+	}
+	return nil
+}
+
+// handleIncomingAnnouncement checks, stores and broadcasts an announcement
+// received for a subscribed topic, and passes it on to auto-fetch
+func (w *UnifiedWebUI) handleIncomingAnnouncement(ann *announce.Announcement) error {
+	// Validate with security manager
+	if err := w.securityMgr.CheckAnnouncement(ann, announce.SourceID(ann, "webui")); err != nil {
+		log.Printf("Rejected announcement: %v", err)
+		return nil // Don't propagate error
 	}
 	
-	// Save subscription
-	w.saveSubscription(topic, true)
+	// Collections are kept apart from file announcements
+	if ann.Kind == announce.KindCollection {
+		w.receiveCollection(ann)
+		return nil
+	}
 	
-	sendJSON(wr, APIResponse{Success: true})
+	// Store announcement
+	if err := w.store.Add(ann, "subscription"); err != nil {
+		return err
+	}
+	
+	// Broadcast to WebSocket clients
+	w.broadcastAnnouncement(ann)
+	w.autoFetch.HandleAnnouncement(ann)
+	w.webhooks.AnnouncementReceived(ann)
+	w.runSavedSearches(ann)
+	
+	return nil
 }
 
 func (w *UnifiedWebUI) handleUnsubscribe(wr http.ResponseWriter, r *http.Request) {
@@ -1073,6 +1987,7 @@ func (w *UnifiedWebUI) handleUnsubscribe(wr http.ResponseWriter, r *http.Request
 	
 	// Save subscription state
 	w.saveSubscription(topic, false)
+	w.audit(r, logging.AuditUnsubscribe, topic, nil, nil)
 	
 	sendJSON(wr, APIResponse{Success: true})
 }
@@ -1091,6 +2006,85 @@ func (w *UnifiedWebUI) handleGetSubscriptions(wr http.ResponseWriter, r *http.Re
 	sendJSON(wr, APIResponse{Success: true, Data: activeSubs})
 }
 
+// AutoFetchView is the auto-fetch configuration with the current day's usage
+type AutoFetchView struct {
+	*autofetch.Config
+	FilesToday int   `json:"files_today"`
+	BytesToday int64 `json:"bytes_today"`
+}
+
+func (w *UnifiedWebUI) handleGetAutoFetch(wr http.ResponseWriter, r *http.Request) {
+	files, bytes := w.autoFetch.Usage()
+	sendJSON(wr, APIResponse{Success: true, Data: AutoFetchView{
+		Config:     w.autoFetch.Config(),
+		FilesToday: files,
+		BytesToday: bytes,
+	}})
+}
+
+// handleSetAutoFetch replaces all rules, the quota and the dry-run setting
+func (w *UnifiedWebUI) handleSetAutoFetch(wr http.ResponseWriter, r *http.Request) {
+	cfg := autofetch.NewConfig()
+	if err := json.NewDecoder(r.Body).Decode(cfg); err != nil {
+		sendError(wr, fmt.Errorf("invalid auto-fetch configuration: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	w.autoFetchMutex.Lock()
+	defer w.autoFetchMutex.Unlock()
+	w.updateAutoFetch(wr, r, cfg)
+}
+
+func (w *UnifiedWebUI) handleSetAutoFetchRule(wr http.ResponseWriter, r *http.Request) {
+	var rule autofetch.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		sendError(wr, fmt.Errorf("invalid rule: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	w.autoFetchMutex.Lock()
+	defer w.autoFetchMutex.Unlock()
+	cfg := w.autoFetch.Config()
+	if err := cfg.SetRule(rule); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	w.updateAutoFetch(wr, r, cfg)
+}
+
+func (w *UnifiedWebUI) handleDeleteAutoFetchRule(wr http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	w.autoFetchMutex.Lock()
+	defer w.autoFetchMutex.Unlock()
+	cfg := w.autoFetch.Config()
+	if err := cfg.RemoveRule(name); err != nil {
+		sendError(wr, err, http.StatusNotFound)
+		return
+	}
+	w.updateAutoFetch(wr, r, cfg)
+}
+
+func (w *UnifiedWebUI) handleAutoFetchHistory(wr http.ResponseWriter, r *http.Request) {
+	sendJSON(wr, APIResponse{Success: true, Data: w.autoFetch.History()})
+}
+
+// updateAutoFetch applies and saves new auto-fetch rules. The caller must
+// hold autoFetchMutex.
+func (w *UnifiedWebUI) updateAutoFetch(wr http.ResponseWriter, r *http.Request, cfg *autofetch.Config) {
+	if err := w.autoFetch.SetConfig(cfg); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	err := autofetch.SaveConfig(w.autoFetchPath, cfg)
+	w.audit(r, logging.AuditConfigurationChange, "autofetch", err, map[string]string{"rules": strconv.Itoa(len(cfg.Rules))})
+	if err != nil {
+		sendError(wr, fmt.Errorf("failed to save auto-fetch rules: %w", err), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: cfg})
+}
+
 func (w *UnifiedWebUI) handleGetStats(wr http.ResponseWriter, r *http.Request) {
 	total, byTopic, expired := w.store.GetStats()
 	
@@ -1116,47 +2110,47 @@ func (w *UnifiedWebUI) handleGetStats(wr http.ResponseWriter, r *http.Request) {
 		ExpiredCount:      expired,
 		ActiveSubs:        len(w.dhtSubscriber.GetSubscriptions()),
 	}
+	clients, sent, dropped, evicted := w.ws.WebSocketStats()
+	stats.WebSocket = WebSocketView{Clients: clients, Sent: sent, Dropped: dropped, Evicted: evicted}
 	
 	sendJSON(wr, APIResponse{Success: true, Data: stats})
 }
 
+// handleCoverStats reports the cover traffic generated since startup
+func (w *UnifiedWebUI) handleCoverStats(wr http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{"enabled": w.coverTraffic != nil}
+	if w.coverTraffic != nil {
+		status["stats"] = w.coverTraffic.Stats()
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: status})
+}
+
+// handleStatsHistory returns the recent stats samples, oldest first, for
+// the dashboard to draw before live samples arrive
+func (w *UnifiedWebUI) handleStatsHistory(wr http.ResponseWriter, r *http.Request) {
+	sendJSON(wr, APIResponse{Success: true, Data: map[string]interface{}{
+		"intervalSeconds": int(statsInterval.Seconds()),
+		"samples":         w.stats.history(),
+	}})
+}
+
 // WebSocket handling
 
 func (w *UnifiedWebUI) handleWebSocket(wr http.ResponseWriter, r *http.Request) {
 	conn, err := w.wsUpgrader.Upgrade(wr, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		requestLog(r).Warnf("WebSocket upgrade error: %v", err)
 		return
 	}
 	
-	// Create client channel
-	clientChan := make(chan interface{}, 100)
-	
-	w.wsMutex.Lock()
-	w.wsClients[conn] = clientChan
-	w.wsMutex.Unlock()
-	
-	defer func() {
-		w.wsMutex.Lock()
-		delete(w.wsClients, conn)
-		w.wsMutex.Unlock()
-		close(clientChan)
-		conn.Close()
-	}()
+	client := w.ws.add(conn)
+	defer w.ws.remove(client)
 	
 	// Send initial stats
-	w.sendWebSocketStats(conn)
-	
-	// Handle outgoing messages
-	go func() {
-		for msg := range clientChan {
-			if err := conn.WriteJSON(msg); err != nil {
-				return
-			}
-		}
-	}()
+	w.sendWebSocketStats(client)
 	
-	// Handle incoming messages (ping/pong)
+	// Handle incoming messages (ping/pong) until the client goes away or
+	// is disconnected
 	for {
 		_, _, err := conn.ReadMessage()
 		if err != nil {
@@ -1221,33 +2215,76 @@ func sendError(w http.ResponseWriter, err error, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(APIResponse{
-		Success: false,
-		Error:   err.Error(),
+		Success:   false,
+		Error:     err.Error(),
+		RequestID: w.Header().Get(middleware.RequestIDHeader),
 	})
 }
 
+// processorStatus is the status of a failed upload or download: 422 if a
+// content processor refused the file, 502 if the reassembled file didn't
+// match its descriptor's hash, fallback otherwise
+func processorStatus(err error, fallback int) int {
+	var rejected *processors.RejectedError
+	if errors.As(err, &rejected) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, descriptors.ErrContentMismatch) {
+		return http.StatusBadGateway
+	}
+	return fallback
+}
+
 // Additional helper functions
 
 func (w *UnifiedWebUI) broadcastAnnouncement(ann *announce.Announcement) {
+	w.stats.announcements.Add(1)
 	view := w.announcementToView(ann)
-	message := map[string]interface{}{
+	w.broadcast(map[string]interface{}{
 		"type": "announcement",
 		"data": view,
+	})
+}
+
+// broadcastStats samples activity every statsInterval and sends each
+// sample to WebSocket clients, so the dashboard needn't poll
+func (w *UnifiedWebUI) broadcastStats() {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+	
+	for now := range ticker.C {
+		sample := w.stats.sample(now)
+		w.broadcast(map[string]interface{}{
+			"type": "stats_sample",
+			"data": sample,
+		})
 	}
-	
-	w.wsMutex.RLock()
-	defer w.wsMutex.RUnlock()
-	
-	for _, clientChan := range w.wsClients {
-		select {
-		case clientChan <- message:
-		default:
-			// Client channel full, skip
+}
+
+// broadcastStorageEvents logs storage backend state changes, such as the
+// IPFS daemon restarting, and forwards them to WebSocket clients
+func (w *UnifiedWebUI) broadcastStorageEvents() {
+	events, _ := w.storageManager.Events().Subscribe(16)
+	for event := range events {
+		if event.Error != "" {
+			log.Printf("Storage backend %s is %s: %s", event.Backend, event.State, event.Error)
+		} else {
+			log.Printf("Storage backend %s is %s", event.Backend, event.State)
 		}
+		w.broadcast(map[string]interface{}{
+			"type": "storage_state",
+			"data": event,
+		})
 	}
 }
 
-func (w *UnifiedWebUI) sendWebSocketStats(conn *websocket.Conn) {
+// broadcast sends a message to every WebSocket client. Clients that aren't
+// keeping up miss messages, and are disconnected if they keep missing them.
+func (w *UnifiedWebUI) broadcast(message interface{}) {
+	w.ws.broadcast(message)
+}
+
+func (w *UnifiedWebUI) sendWebSocketStats(client *wsClient) {
 	total, _, _ := w.store.GetStats()
 	
 	message := map[string]interface{}{
@@ -1258,7 +2295,7 @@ func (w *UnifiedWebUI) sendWebSocketStats(conn *websocket.Conn) {
 		},
 	}
 	
-	conn.WriteJSON(message)
+	w.ws.sendTo(client, message)
 }
 
 func (w *UnifiedWebUI) announcementToView(ann *announce.Announcement) AnnouncementView {
@@ -1280,6 +2317,8 @@ func (w *UnifiedWebUI) announcementToView(ann *announce.Announcement) Announceme
 		TTL:        ann.TTL,
 		Expiry:     time.Unix(ann.Timestamp, 0).Add(time.Duration(ann.TTL) * time.Second),
 		Source:     "network",
+		Metadata:   ann.Metadata,
+		Publisher:  ann.Publisher,
 	}
 }
 
@@ -1396,19 +2435,9 @@ func (w *UnifiedWebUI) loadSubscriptions() error {
 	// Activate subscriptions
 	for _, sub := range subs.Subscriptions {
 		if sub.Active {
-			handler := func(ann *announce.Announcement) error {
-				if err := w.securityMgr.CheckAnnouncement(ann, "webui"); err != nil {
-					return nil
-				}
-				if err := w.store.Add(ann, "subscription"); err != nil {
-					return err
-				}
-				w.broadcastAnnouncement(ann)
-				return nil
+			if err := w.subscribeTopic(sub.Topic); err != nil {
+				log.Printf("Failed to subscribe to %s: %v", sub.Topic, err)
 			}
-			
-			w.dhtSubscriber.Subscribe(sub.Topic, handler)
-			w.pubsubSubscriber.Subscribe(sub.Topic, handler)
 		}
 	}
 	
@@ -1438,8 +2467,9 @@ func (w *UnifiedWebUI) saveSubscription(topic string, active bool) {
 	}
 	
 	// Save to disk
-	configDir := config.GetConfigDir()
-	config.SaveSubscriptions(configDir+"/subscriptions.json", w.subscriptions)
+	if err := w.persistSubscriptions(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 }
 
 // TopicConfig represents the structure of topics.json
@@ -1509,16 +2539,32 @@ func (w *UnifiedWebUI) handleMetrics(wr http.ResponseWriter, r *http.Request) {
 	metrics := w.noisefsClient.GetMetrics()
 	
 	response := struct {
-		Metrics   interface{} `json:"metrics"`
-		Timestamp time.Time   `json:"timestamp"`
+		Metrics   interface{}                      `json:"metrics"`
+		Network   map[string]*storage.NetworkStats `json:"network"`
+		Timestamp time.Time                        `json:"timestamp"`
 	}{
 		Metrics:   metrics,
+		Network:   w.storageManager.NetworkStats(r.Context()),
 		Timestamp: time.Now(),
 	}
 	
 	sendJSON(wr, response)
 }
 
+// handlePeerStats reports how well each peer has served block requests,
+// per storage backend
+func (w *UnifiedWebUI) handlePeerStats(wr http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Backends  map[string][]storage.PeerStats `json:"backends"`
+		Timestamp time.Time                      `json:"timestamp"`
+	}{
+		Backends:  w.storageManager.PeerStats(),
+		Timestamp: time.Now(),
+	}
+
+	sendJSON(wr, response)
+}
+
 // generateSelfSignedCert generates a self-signed certificate for HTTPS
 func generateSelfSignedCert() (tls.Certificate, error) {
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -1551,20 +2597,34 @@ func generateSelfSignedCert() (tls.Certificate, error) {
 	return tls.X509KeyPair(certPEM, keyPEM)
 }
 
-// loadDescriptor loads a descriptor without downloading the file
-func (w *UnifiedWebUI) loadDescriptor(descriptorCID string) (*descriptors.Descriptor, error) {
-	// Create descriptor store
-	descriptorStore, err := descriptors.NewStore(w.storageManager)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create descriptor store: %w", err)
-	}
-	
-	// Load descriptor
-	descriptor, err := descriptorStore.Load(descriptorCID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load descriptor: %w", err)
+// logProgress returns a reporter that logs an operation's progress without
+// blocking it, and a function to call once the operation is done
+func logProgress(operation string) (util.ProgressReporter, func()) {
+	updates := make(chan util.Progress, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for update := range updates {
+			log.Printf("%s progress: %s %.0f%%", operation, update.Stage, update.Percent())
+		}
+	}()
+
+	reporter := util.ProgressFunc(func(update util.Progress) {
+		select {
+		case updates <- update:
+		default:
+		}
+	})
+	return reporter, func() {
+		close(updates)
+		<-done
 	}
-	
-	return descriptor, nil
+}
+
+// descriptorPassword returns the password sent with a request for
+// encrypted descriptors. It's only accepted as a header so it doesn't end
+// up in URLs and access logs.
+func descriptorPassword(r *http.Request) string {
+	return r.Header.Get("X-Descriptor-Password")
 }
 
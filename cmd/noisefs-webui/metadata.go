@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// metadataFetchTimeout bounds fetching the metadata record of an
+	// announcement
+	metadataFetchTimeout = 30 * time.Second
+
+	// maxCachedMetadata bounds the metadata records kept in memory
+	maxCachedMetadata = 1000
+)
+
+// MetadataRequest describes an announced descriptor. It is published as a
+// separate metadata record, so none of it ends up in the descriptor.
+type MetadataRequest struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	License     string `json:"license,omitempty"`
+	Checksum    string `json:"checksum,omitempty"` // "sha256:<hex>" of the file content
+	Preview     string `json:"preview,omitempty"`  // Descriptor CID of a preview
+}
+
+// newMetadata builds and validates the metadata record for a descriptor
+func (w *UnifiedWebUI) newMetadata(descriptorCID string, req *MetadataRequest) (*announce.Metadata, error) {
+	metadata := announce.NewMetadata(descriptorCID)
+	metadata.Title = strings.TrimSpace(req.Title)
+	metadata.Description = strings.TrimSpace(req.Description)
+	metadata.License = strings.TrimSpace(req.License)
+	metadata.Checksum = strings.ToLower(strings.TrimSpace(req.Checksum))
+	if req.Preview != "" {
+		preview, err := w.validator.NormalizeCID(req.Preview)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preview: %w", err)
+		}
+		metadata.Preview = preview
+	}
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// handleGetAnnouncementMetadata returns the metadata record referenced by
+// the newest announcement of a descriptor that has one
+func (w *UnifiedWebUI) handleGetAnnouncementMetadata(wr http.ResponseWriter, r *http.Request) {
+	descriptor := mux.Vars(r)["descriptor"]
+	stored, err := w.store.GetByDescriptor(descriptor)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	var ann *announce.Announcement
+	for _, s := range stored {
+		if s.Metadata != "" && (ann == nil || s.Timestamp > ann.Timestamp) {
+			ann = s.Announcement
+		}
+	}
+	if ann == nil {
+		sendError(wr, fmt.Errorf("no metadata announced for %s", descriptor), http.StatusNotFound)
+		return
+	}
+
+	metadata, err := w.fetchMetadata(r.Context(), ann)
+	if err != nil {
+		sendError(wr, err, http.StatusBadGateway)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: metadata})
+}
+
+// fetchMetadata returns the metadata record an announcement references.
+// Records are immutable, so they are cached by CID.
+func (w *UnifiedWebUI) fetchMetadata(ctx context.Context, ann *announce.Announcement) (*announce.Metadata, error) {
+	w.metadataMutex.Lock()
+	metadata, ok := w.metadataCache[ann.Metadata]
+	w.metadataMutex.Unlock()
+	if ok {
+		if metadata.Descriptor != ann.Descriptor {
+			return nil, fmt.Errorf("metadata %s describes %s, not %s", ann.Metadata, metadata.Descriptor, ann.Descriptor)
+		}
+		return metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataFetchTimeout)
+	defer cancel()
+	metadata, err := dht.FetchMetadata(ctx, w.storageManager, ann)
+	if err != nil {
+		return nil, err
+	}
+
+	w.metadataMutex.Lock()
+	if len(w.metadataCache) >= maxCachedMetadata {
+		w.metadataCache = make(map[string]*announce.Metadata)
+	}
+	w.metadataCache[ann.Metadata] = metadata
+	w.metadataMutex.Unlock()
+	return metadata, nil
+}
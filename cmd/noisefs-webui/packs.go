@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+// PackedFile is one file of a pack, which GET /api/download/{cid}?path=
+// serves on its own
+type PackedFile struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+}
+
+// packedFiles lists the files of a pack descriptor, or nil for any other
+// descriptor
+func packedFiles(descriptor *descriptors.Descriptor) []PackedFile {
+	if !descriptor.IsPack() {
+		return nil
+	}
+	files := make([]PackedFile, 0, len(descriptor.Entries))
+	for _, entry := range descriptor.Entries {
+		files = append(files, PackedFile{
+			Name:        entry.Filename,
+			Size:        entry.Size,
+			ContentType: validation.ContentTypeForFilename(entry.Filename),
+		})
+	}
+	return files
+}
+
+// servePackedFile serves one file of a pack, reconstructing only the
+// blocks that hold it. Each file has its own ETag, so resumed downloads of
+// different files of a pack don't mix.
+func (w *UnifiedWebUI) servePackedFile(wr http.ResponseWriter, r *http.Request, descriptorCID string, descriptor *descriptors.Descriptor, encrypted bool, name string) {
+	if !descriptor.IsPack() {
+		sendError(wr, fmt.Errorf("path selects a file of a pack; %s is a %s descriptor", descriptorCID, descriptor.Type), http.StatusBadRequest)
+		return
+	}
+	index := -1
+	for i, entry := range descriptor.Entries {
+		if entry.Filename == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		sendError(wr, fmt.Errorf("pack %s does not contain %s", descriptorCID, name), http.StatusNotFound)
+		return
+	}
+
+	validators := newContentValidators(descriptorCID, descriptor, encrypted)
+	validators.etag = fmt.Sprintf(`%s-%d"`, strings.TrimSuffix(validators.etag, `"`), index)
+	if validators.notModified(wr, r) {
+		return
+	}
+
+	data, err := w.noisefsClient.DownloadPackedFile(context.WithoutCancel(r.Context()), descriptorCID, name)
+	w.audit(r, logging.AuditDownload, descriptorCID, err, map[string]string{"filename": name, "kind": "pack"})
+	if err != nil {
+		sendError(wr, err, processorStatus(err, http.StatusNotFound))
+		return
+	}
+	w.stats.downloads.Add(1)
+
+	wr.Header().Set("Content-Type", "application/octet-stream")
+	wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+	if _, err := serveContent(wr, r, bytes.NewReader(data), int64(len(data)), validators); err != nil {
+		requestLog(r).Warnf("Download error: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
+	"github.com/gorilla/mux"
+)
+
+// SavedSearchRequest saves a search that runs against every incoming
+// announcement
+type SavedSearchRequest struct {
+	Name         string   `json:"name"`
+	Keywords     []string `json:"keywords"`
+	Tags         []string `json:"tags"`
+	MatchAllTags bool     `json:"match_all_tags"`
+	Topics       []string `json:"topics"` // Topic names
+	Categories   []string `json:"categories"`
+	SizeClasses  []string `json:"size_classes"`
+	Notify       []string `json:"notify"` // websocket, webhook, desktop
+}
+
+// SearchMatchView is pushed to WebSocket clients when an incoming
+// announcement matches a saved search
+type SearchMatchView struct {
+	SearchID     string           `json:"search_id"`
+	SearchName   string           `json:"search_name"`
+	Desktop      bool             `json:"desktop"` // Show a desktop notification
+	Announcement AnnouncementView `json:"announcement"`
+}
+
+func (w *UnifiedWebUI) handleListSavedSearches(wr http.ResponseWriter, r *http.Request) {
+	w.subMutex.RLock()
+	searches := w.subscriptions.GetSavedSearches()
+	w.subMutex.RUnlock()
+	sendJSON(wr, APIResponse{Success: true, Data: searches})
+}
+
+func (w *UnifiedWebUI) handleCreateSavedSearch(wr http.ResponseWriter, r *http.Request) {
+	var req SavedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	search := config.SavedSearch{
+		Name:         strings.TrimSpace(req.Name),
+		Keywords:     trimAll(req.Keywords),
+		Tags:         trimAll(req.Tags),
+		MatchAllTags: req.MatchAllTags,
+		Topics:       trimAll(req.Topics),
+		Categories:   trimAll(req.Categories),
+		SizeClasses:  trimAll(req.SizeClasses),
+		Notify:       trimAll(req.Notify),
+	}
+	if search.Notify == nil {
+		search.Notify = []string{config.NotifyWebSocket}
+	}
+
+	w.subMutex.Lock()
+	defer w.subMutex.Unlock()
+	saved, err := w.subscriptions.AddSavedSearch(search)
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	if err := w.persistSubscriptions(); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: saved})
+}
+
+func (w *UnifiedWebUI) handleDeleteSavedSearch(wr http.ResponseWriter, r *http.Request) {
+	w.subMutex.Lock()
+	defer w.subMutex.Unlock()
+	if err := w.subscriptions.RemoveSavedSearch(mux.Vars(r)["id"]); err != nil {
+		sendError(wr, err, http.StatusNotFound)
+		return
+	}
+	if err := w.persistSubscriptions(); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// handleSavedSearchResults runs a saved search against the announcements
+// already stored
+func (w *UnifiedWebUI) handleSavedSearchResults(wr http.ResponseWriter, r *http.Request) {
+	w.subMutex.RLock()
+	search, ok := w.subscriptions.GetSavedSearch(mux.Vars(r)["id"])
+	w.subMutex.RUnlock()
+	if !ok {
+		sendError(wr, fmt.Errorf("no saved search %s", mux.Vars(r)["id"]), http.StatusNotFound)
+		return
+	}
+
+	query := search.Query()
+	query.Limit = defaultPageSize
+	results, total, err := w.search.SearchPage(query)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	views := make([]AnnouncementView, 0, len(results))
+	for _, result := range results {
+		view := w.announcementToView(result.Announcement)
+		view.Explanation = result.Explanation
+		views = append(views, view)
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: AnnouncementPage{
+		Announcements: views,
+		Total:         total,
+		Limit:         query.Limit,
+	}})
+}
+
+// runSavedSearches notifies of the saved searches an incoming announcement
+// matches
+func (w *UnifiedWebUI) runSavedSearches(ann *announce.Announcement) {
+	w.subMutex.RLock()
+	searches := w.subscriptions.GetSavedSearches()
+	w.subMutex.RUnlock()
+
+	matched := false
+	for _, search := range searches {
+		result, ok := w.search.Match(ann, search.Query())
+		if !ok {
+			continue
+		}
+		matched = true
+		w.subMutex.Lock()
+		w.subscriptions.RecordSavedSearchMatch(search.ID, time.Now())
+		w.subMutex.Unlock()
+
+		if search.Notifies(config.NotifyWebSocket) || search.Notifies(config.NotifyDesktop) {
+			view := w.announcementToView(ann)
+			view.Explanation = result.Explanation
+			w.broadcast(map[string]interface{}{
+				"type": "search_match",
+				"data": SearchMatchView{
+					SearchID:     search.ID,
+					SearchName:   search.Name,
+					Desktop:      search.Notifies(config.NotifyDesktop),
+					Announcement: view,
+				},
+			})
+		}
+		if search.Notifies(config.NotifyWebhook) {
+			w.webhooks.SearchMatched(search.ID, search.Name, result)
+		}
+	}
+
+	if matched {
+		w.subMutex.Lock()
+		if err := w.persistSubscriptions(); err != nil {
+			log.Printf("Failed to record saved search matches: %v", err)
+		}
+		w.subMutex.Unlock()
+	}
+}
+
+// persistSubscriptions saves the subscriptions and saved searches. The
+// caller must hold subMutex.
+func (w *UnifiedWebUI) persistSubscriptions() error {
+	path := filepath.Join(config.GetConfigDir(), "subscriptions.json")
+	if err := config.SaveSubscriptions(path, w.subscriptions); err != nil {
+		return fmt.Errorf("failed to save subscriptions: %w", err)
+	}
+	return nil
+}
+
+// trimAll trims values and drops empty ones
+func trimAll(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
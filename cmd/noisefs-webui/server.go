@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	noisefsConfig "github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+)
+
+const (
+	// defaultIdleTimeout closes keep-alive connections nobody uses
+	defaultIdleTimeout = 120 * time.Second
+
+	// readHeaderTimeout bounds slow clients sending request headers. There
+	// is no write timeout, as media streams run for as long as playback.
+	readHeaderTimeout = 10 * time.Second
+
+	// TCP keep-alive probes notice peers that went away, such as a
+	// suspended laptop, so their streams are released
+	tcpKeepAliveIdle     = 30 * time.Second
+	tcpKeepAliveInterval = 15 * time.Second
+	tcpKeepAliveCount    = 4
+
+	// streamChunkSize is how much streamed content is written between
+	// flushes: one block, so playback starts as soon as a block is ready
+	streamChunkSize = blocks.DefaultBlockSize
+)
+
+// newHTTPServer returns the web UI's server. HTTP/2 is negotiated over
+// TLS unless disabled, and served in cleartext (h2c) when configured for a
+// reverse proxy in front of the web UI.
+func newHTTPServer(cfg noisefsConfig.WebUIConfig, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	idleTimeout := defaultIdleTimeout
+	if cfg.IdleTimeout > 0 {
+		idleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.HTTP2 && tlsConfig != nil)
+	protocols.SetUnencryptedHTTP2(cfg.H2C && tlsConfig == nil)
+
+	return &http.Server{
+		Addr:              cfg.Address,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		Protocols:         protocols,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+		HTTP2: &http.HTTP2Config{
+			// Ping idle HTTP/2 connections, so dead ones are closed
+			// before the idle timeout
+			SendPingTimeout: idleTimeout / 2,
+			PingTimeout:     15 * time.Second,
+		},
+	}
+}
+
+// listen opens the web UI's listener with TCP keep-alives and the
+// configured socket send buffer
+func listen(cfg noisefsConfig.WebUIConfig) (net.Listener, error) {
+	writeBuffer, err := cfg.WriteBufferBytes()
+	if err != nil {
+		return nil, err
+	}
+	lc := net.ListenConfig{
+		KeepAliveConfig: net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     tcpKeepAliveIdle,
+			Interval: tcpKeepAliveInterval,
+			Count:    tcpKeepAliveCount,
+		},
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if writeBuffer > 0 {
+		listener = &bufferedListener{Listener: listener, writeBuffer: int(writeBuffer)}
+	}
+	return listener, nil
+}
+
+// bufferedListener sets the send buffer of accepted connections
+type bufferedListener struct {
+	net.Listener
+	writeBuffer int
+}
+
+func (l *bufferedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetWriteBuffer(l.writeBuffer)
+	}
+	return conn, nil
+}
+
+// streamContent copies n bytes of content to a response (all of it if n is
+// negative), flushing after each block so neither the server nor a TLS or
+// HTTP/2 layer holds content back
+func streamContent(wr http.ResponseWriter, content io.Reader, n int64) (int64, error) {
+	if n >= 0 {
+		content = io.LimitReader(content, n)
+	}
+	flusher := http.NewResponseController(wr)
+	buf := make([]byte, streamChunkSize)
+	var written int64
+	for {
+		read, err := io.ReadFull(content, buf)
+		if read > 0 {
+			if _, werr := wr.Write(buf[:read]); werr != nil {
+				return written, werr
+			}
+			written += int64(read)
+			if ferr := flusher.Flush(); ferr != nil && !errors.Is(ferr, http.ErrNotSupported) {
+				return written, ferr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			if n >= 0 && written < n {
+				return written, io.ErrUnexpectedEOF
+			}
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
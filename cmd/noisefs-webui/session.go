@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/web/middleware"
+)
+
+const (
+	// sessionCookie holds the ID of a browser session
+	sessionCookie = "noisefs_session"
+
+	// sessionLifetime bounds a session; it ends earlier with its token
+	sessionLifetime = 12 * time.Hour
+)
+
+// session is a browser signed in with an API token. The token is checked
+// again on every request, so revoking it ends its sessions.
+type session struct {
+	secret    string
+	expiresAt time.Time
+}
+
+// sessionStore keeps browser sessions in memory; they end when the web UI
+// restarts
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]session)}
+}
+
+// create starts a session for secret and returns its ID
+func (s *sessionStore) create(secret string, expiresAt time.Time) (string, error) {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for existing, sess := range s.sessions {
+		if !now.Before(sess.expiresAt) {
+			delete(s.sessions, existing)
+		}
+	}
+	s.sessions[hex.EncodeToString(id)] = session{secret: secret, expiresAt: expiresAt}
+	return hex.EncodeToString(id), nil
+}
+
+// secret returns the token secret of an unexpired session
+func (s *sessionStore) secret(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || !time.Now().Before(sess.expiresAt) {
+		return "", false
+	}
+	return sess.secret, true
+}
+
+func (s *sessionStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// sessionSecret returns the token secret of a request's session cookie
+func (w *UnifiedWebUI) sessionSecret(r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	secret, _ := w.sessions.secret(cookie.Value)
+	return secret
+}
+
+// SessionView describes the browser's session
+type SessionView struct {
+	Authenticated bool       `json:"authenticated"`
+	Required      bool       `json:"required"` // Whether the API needs a token
+	Role          auth.Role  `json:"role,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+func (w *UnifiedWebUI) handleGetSession(wr http.ResponseWriter, r *http.Request) {
+	view := SessionView{Required: w.config.WebUI.RequireTokens}
+	if role, token, err := w.authenticate(r); err == nil {
+		view.Authenticated = true
+		view.Role = role
+		if token != nil {
+			view.Name = token.Name
+		}
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
+
+// handleCreateSession signs a browser in with an API token. The session
+// cookie is HttpOnly, SameSite=Strict and, over HTTPS, Secure.
+func (w *UnifiedWebUI) handleCreateSession(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		sendError(wr, fmt.Errorf(`body must be {"token": "..."}`), http.StatusBadRequest)
+		return
+	}
+
+	role, token, err := w.authenticateSecret(req.Token)
+	if err != nil {
+		w.audit(r, logging.AuditAuthFailure, "session", err, nil)
+		sendError(wr, err, http.StatusUnauthorized)
+		return
+	}
+
+	expiresAt := time.Now().Add(sessionLifetime)
+	if token != nil && token.ExpiresAt != nil && token.ExpiresAt.Before(expiresAt) {
+		expiresAt = *token.ExpiresAt
+	}
+	id, err := w.sessions.create(req.Token, expiresAt)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(wr, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	view := SessionView{Authenticated: true, Required: w.config.WebUI.RequireTokens, Role: role, ExpiresAt: &expiresAt}
+	if token != nil {
+		view.Name = token.Name
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
+
+func (w *UnifiedWebUI) handleDeleteSession(wr http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		w.sessions.remove(cookie.Value)
+	}
+	http.SetCookie(wr, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+func (w *UnifiedWebUI) handleLoginPage(wr http.ResponseWriter, r *http.Request) {
+	http.ServeFile(wr, r, "cmd/noisefs-webui/templates/login.html")
+}
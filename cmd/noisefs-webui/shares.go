@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/gorilla/mux"
+)
+
+// ShareRequest creates a limited share of a descriptor
+type ShareRequest struct {
+	DescriptorCID string    `json:"descriptor_cid"`
+	ExpiresIn     string    `json:"expires_in,omitempty"` // Duration such as "24h"
+	ExpiresAt     time.Time `json:"expires_at,omitempty"`
+	MaxDownloads  int       `json:"max_downloads,omitempty"`
+	KeyService    string    `json:"key_service,omitempty"` // Defaults to this web UI
+}
+
+// shareErrorStatus maps share errors to HTTP statuses
+func shareErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, descriptors.ErrShareNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, descriptors.ErrShareExpired),
+		errors.Is(err, descriptors.ErrShareExhausted),
+		errors.Is(err, descriptors.ErrShareRevoked):
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
+
+func (w *UnifiedWebUI) handleCreateShare(wr http.ResponseWriter, r *http.Request) {
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	descriptorCID, err := w.validator.ResolveCID(r.Context(), req.DescriptorCID)
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn != "" {
+		expiresIn, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || expiresIn <= 0 {
+			sendError(wr, fmt.Errorf("invalid expires_in %q", req.ExpiresIn), http.StatusBadRequest)
+			return
+		}
+		expiresAt = time.Now().Add(expiresIn)
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		sendError(wr, fmt.Errorf("expiry must be in the future"), http.StatusBadRequest)
+		return
+	}
+
+	keyService := req.KeyService
+	if keyService == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		keyService = scheme + "://" + r.Host
+	}
+
+	descriptor, encrypted, err := w.noisefsClient.LoadDescriptor(descriptorCID, descriptorPassword(r))
+	if err != nil {
+		status := http.StatusNotFound
+		if encrypted {
+			status = http.StatusUnauthorized
+		}
+		sendError(wr, err, status)
+		return
+	}
+
+	record, err := descriptors.CreateShare(w.storageManager, descriptor, descriptors.ShareOptions{
+		KeyService:   keyService,
+		ExpiresAt:    expiresAt.UTC(),
+		MaxDownloads: req.MaxDownloads,
+	})
+	if err == nil {
+		err = w.shares.Add(record)
+	}
+	w.audit(r, logging.AuditShare, descriptorCID, err, map[string]string{
+		"max_downloads": strconv.Itoa(req.MaxDownloads),
+		"expires_at":    expiresAt.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+
+	record.Key = ""
+	sendJSON(wr, APIResponse{Success: true, Data: record})
+}
+
+func (w *UnifiedWebUI) handleListShares(wr http.ResponseWriter, r *http.Request) {
+	shares, err := w.shares.List()
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: shares})
+}
+
+func (w *UnifiedWebUI) handleRevokeShare(wr http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	err := w.shares.Revoke(id)
+	w.audit(r, logging.AuditShareRevoke, id, err, nil)
+	if err != nil {
+		sendError(wr, err, shareErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// handleRedeemShare releases a share's key to a recipient, wrapped for the
+// public key in the request, and counts the download
+func (w *UnifiedWebUI) handleRedeemShare(wr http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	var req descriptors.ShareRedeemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PublicKey == "" {
+		sendError(wr, fmt.Errorf("a public key is required"), http.StatusBadRequest)
+		return
+	}
+
+	if w.refuseTakenShare(wr, r, id) {
+		return
+	}
+	record, err := w.shares.Redeem(id)
+	if err != nil {
+		w.audit(r, logging.AuditAccessDenied, id, err, map[string]string{"share": id})
+		sendError(wr, err, shareErrorStatus(err))
+		return
+	}
+	response, err := descriptors.WrapShareKey(record, req)
+	w.audit(r, logging.AuditDownload, record.DescriptorCID, err, map[string]string{
+		"share":     id,
+		"downloads": strconv.Itoa(record.Downloads),
+	})
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: response})
+}
+
+// handleShareDownload redeems a share and serves its file, for recipients
+// without the CLI
+func (w *UnifiedWebUI) handleShareDownload(wr http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if w.refuseTakenShare(wr, r, id) {
+		return
+	}
+	record, err := w.shares.Redeem(id)
+	if err != nil {
+		w.audit(r, logging.AuditAccessDenied, id, err, map[string]string{"share": id})
+		sendError(wr, err, shareErrorStatus(err))
+		return
+	}
+
+	progress, stopProgress := logProgress("Download")
+	file, err := w.noisefsClient.DownloadFile(context.WithoutCancel(r.Context()), record.DescriptorCID, record.Key, progress)
+	stopProgress()
+	w.recordVerification(record.DescriptorCID, file, err)
+	w.audit(r, logging.AuditDownload, record.DescriptorCID, err, map[string]string{
+		"share":     id,
+		"filename":  record.Filename,
+		"downloads": strconv.Itoa(record.Downloads),
+	})
+	if err != nil {
+		sendError(wr, err, processorStatus(err, http.StatusNotFound))
+		return
+	}
+	w.stats.downloads.Add(1)
+
+	wr.Header().Set("Content-Type", "application/octet-stream")
+	wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.Filename))
+	wr.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+	if _, err := streamContent(wr, file.Reader, -1); err != nil {
+		requestLog(r).Warnf("Download error: %v", err)
+	}
+}
+
+// refuseTakenShare refuses a share whose file has been taken down, before
+// the request counts as a download
+func (w *UnifiedWebUI) refuseTakenShare(wr http.ResponseWriter, r *http.Request, id string) bool {
+	record, err := w.shares.Get(id)
+	if err != nil {
+		// Redeem reports unknown shares
+		return false
+	}
+	return w.refuseTakenDown(wr, r, record.ShareCID, record.DescriptorCID)
+}
@@ -0,0 +1,93 @@
+// Shared by every page: sends the CSRF token with requests that change
+// something, sends visitors to the login page when the API needs a token,
+// hides uploading on download-only gateways and asks for a reload when the
+// server was replaced by an incompatible version.
+(function () {
+    // The API protocol these pages were written for; see
+    // pkg/infrastructure/version
+    const PROTOCOL = 1;
+    let protocolWarned = false;
+
+    function checkProtocol(response) {
+        const served = parseInt(response.headers.get('X-NoiseFS-Protocol'), 10);
+        if (isNaN(served) || served === PROTOCOL || protocolWarned) {
+            return;
+        }
+        protocolWarned = true;
+        const banner = document.createElement('div');
+        banner.setAttribute('role', 'alert');
+        banner.style.cssText = 'position:fixed;top:0;left:0;right:0;z-index:10000;padding:10px;background:#fff3cd;color:#664d03;text-align:center;';
+        banner.textContent = 'The NoiseFS server was upgraded or downgraded since this page was loaded (protocol ' +
+            served + ', page ' + PROTOCOL + '). Reload the page before continuing.';
+        const show = () => document.body.prepend(banner);
+        document.body ? show() : document.addEventListener('DOMContentLoaded', show);
+    }
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)noisefs_csrf=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    function changesSomething(method) {
+        return !['GET', 'HEAD', 'OPTIONS'].includes((method || 'GET').toUpperCase());
+    }
+
+    function signIn() {
+        if (window.location.pathname !== '/login') {
+            const next = window.location.pathname + window.location.search;
+            window.location.href = '/login?next=' + encodeURIComponent(next);
+        }
+    }
+
+    const originalFetch = window.fetch;
+    window.fetch = function (input, init) {
+        init = init || {};
+        const method = init.method || (input instanceof Request ? input.method : 'GET');
+        if (changesSomething(method)) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            headers.set('X-CSRF-Token', csrfToken());
+            init = Object.assign({}, init, {headers: headers});
+        }
+        return originalFetch.call(this, input, init).then(response => {
+            checkProtocol(response);
+            if (response.status === 401 && !response.url.endsWith('/api/session')) {
+                signIn();
+            }
+            return response;
+        });
+    };
+
+    const originalOpen = XMLHttpRequest.prototype.open;
+    const originalSend = XMLHttpRequest.prototype.send;
+    XMLHttpRequest.prototype.open = function (method) {
+        this.noisefsMethod = method;
+        return originalOpen.apply(this, arguments);
+    };
+    XMLHttpRequest.prototype.send = function () {
+        if (changesSomething(this.noisefsMethod)) {
+            this.setRequestHeader('X-CSRF-Token', csrfToken());
+        }
+        this.addEventListener('load', () => {
+            checkProtocol({headers: {get: name => this.getResponseHeader(name)}});
+            if (this.status === 401) {
+                signIn();
+            }
+        });
+        return originalSend.apply(this, arguments);
+    };
+
+    // Public gateways are download-only
+    document.addEventListener('DOMContentLoaded', () => {
+        originalFetch('/api/gateway')
+            .then(response => {
+                checkProtocol(response);
+                return response.json();
+            })
+            .then(data => {
+                if (data.success && data.data.enabled) {
+                    document.querySelectorAll('a[href="/upload"]').forEach(link => link.remove());
+                }
+            })
+            .catch(() => {});
+    });
+})();
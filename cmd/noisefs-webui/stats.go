@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+const (
+	// statsInterval is how often a stats sample is taken and broadcast
+	statsInterval = 10 * time.Second
+
+	// statsHistorySize is the number of samples kept, one hour at the
+	// default interval
+	statsHistorySize = 360
+)
+
+// StatsSample is one point of the dashboard's live time series. Rates are
+// per minute over the sample's interval.
+type StatsSample struct {
+	Time                time.Time `json:"time"`
+	UploadsPerMin       float64   `json:"uploadsPerMin"`
+	DownloadsPerMin     float64   `json:"downloadsPerMin"`
+	AnnouncementsPerMin float64   `json:"announcementsPerMin"`
+	CacheHitRate        float64   `json:"cacheHitRate"` // Over the interval, or overall when idle
+}
+
+// statsRecorder counts web UI activity and keeps a ring buffer of samples
+type statsRecorder struct {
+	uploads       atomic.Int64
+	downloads     atomic.Int64
+	announcements atomic.Int64
+
+	cache cache.Cache
+
+	mu       sync.RWMutex
+	samples  []StatsSample // Ring buffer, oldest at next once full
+	next     int
+	full     bool
+	last     time.Time
+	lastSeen [3]int64 // Counters at the last sample
+	lastHits int64
+	lastMiss int64
+}
+
+func newStatsRecorder(blockCache cache.Cache) *statsRecorder {
+	return &statsRecorder{
+		cache:   blockCache,
+		samples: make([]StatsSample, statsHistorySize),
+		last:    time.Now(),
+	}
+}
+
+// sample records a sample of activity since the previous one
+func (s *statsRecorder) sample(now time.Time) StatsSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := [3]int64{s.uploads.Load(), s.downloads.Load(), s.announcements.Load()}
+	minutes := now.Sub(s.last).Minutes()
+	rate := func(i int) float64 {
+		if minutes <= 0 {
+			return 0
+		}
+		return float64(counts[i]-s.lastSeen[i]) / minutes
+	}
+
+	sample := StatsSample{
+		Time:                now,
+		UploadsPerMin:       rate(0),
+		DownloadsPerMin:     rate(1),
+		AnnouncementsPerMin: rate(2),
+	}
+	if stats := s.cache.GetStats(); stats != nil {
+		hits, misses := stats.Hits-s.lastHits, stats.Misses-s.lastMiss
+		if hits+misses > 0 {
+			sample.CacheHitRate = float64(hits) / float64(hits+misses)
+		} else {
+			sample.CacheHitRate = stats.HitRate
+		}
+		s.lastHits, s.lastMiss = stats.Hits, stats.Misses
+	}
+	s.last = now
+	s.lastSeen = counts
+
+	s.samples[s.next] = sample
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+	return sample
+}
+
+// history returns the recorded samples, oldest first
+func (s *statsRecorder) history() []StatsSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.full {
+		return append([]StatsSample(nil), s.samples[:s.next]...)
+	}
+	history := make([]StatsSample, 0, len(s.samples))
+	history = append(history, s.samples[s.next:]...)
+	return append(history, s.samples[:s.next]...)
+}
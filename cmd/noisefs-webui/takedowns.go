@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/gorilla/mux"
+)
+
+// refuseTakenDown answers 451 Unavailable For Legal Reasons, and returns
+// true, when any of cids has an active takedown. The store is read on every
+// request so takedowns recorded with the CLI apply at once.
+func (w *UnifiedWebUI) refuseTakenDown(wr http.ResponseWriter, r *http.Request, cids ...string) bool {
+	for _, cid := range cids {
+		if cid == "" {
+			continue
+		}
+		blocked, err := w.takedowns.IsBlocked(cid)
+		if err != nil {
+			// Serving taken-down content is worse than failing a request
+			sendError(wr, fmt.Errorf("failed to check takedowns: %w", err), http.StatusInternalServerError)
+			return true
+		}
+		if blocked {
+			w.audit(r, logging.AuditAccessDenied, cid, compliance.ErrAlreadyTakenDown, map[string]string{"reason": "takedown"})
+			sendError(wr, fmt.Errorf("%s is unavailable following a takedown notice", cid), http.StatusUnavailableForLegalReasons)
+			return true
+		}
+	}
+	return false
+}
+
+// handleAdminRecordTakedown records a takedown notice, blocklists the
+// descriptor and, unless unpin is false, unpins its blocks
+func (w *UnifiedWebUI) handleAdminRecordTakedown(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		compliance.TakedownNotice
+		Unpin *bool `json:"unpin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, fmt.Errorf("invalid takedown notice: %w", err), http.StatusBadRequest)
+		return
+	}
+	descriptorCID, err := w.validator.NormalizeCID(req.DescriptorCID)
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	req.DescriptorCID = descriptorCID
+
+	record, err := w.takedowns.Record(&req.TakedownNotice)
+	w.audit(r, logging.AuditTakedown, descriptorCID, err, map[string]string{"legal_basis": req.LegalBasis})
+	switch {
+	case errors.Is(err, compliance.ErrAlreadyTakenDown):
+		sendError(wr, err, http.StatusConflict)
+		return
+	case err != nil:
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+
+	result := map[string]interface{}{"record": record, "unpinned": []string{}}
+	if req.Unpin == nil || *req.Unpin {
+		unpinned, err := compliance.UnpinDescriptor(r.Context(), w.storageManager, descriptorCID)
+		result["unpinned"] = unpinned
+		if err != nil {
+			// The takedown stands; report what couldn't be unpinned
+			result["unpin_error"] = err.Error()
+		}
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	wr.WriteHeader(http.StatusCreated)
+	json.NewEncoder(wr).Encode(APIResponse{Success: true, Data: result})
+}
+
+func (w *UnifiedWebUI) handleAdminListTakedowns(wr http.ResponseWriter, r *http.Request) {
+	records, err := w.takedowns.List()
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: records})
+}
+
+// handleAdminReinstate lifts a takedown, for example after a counter-notice
+func (w *UnifiedWebUI) handleAdminReinstate(wr http.ResponseWriter, r *http.Request) {
+	descriptorCID := mux.Vars(r)["cid"]
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(wr, fmt.Errorf("invalid request: %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := w.takedowns.Reinstate(descriptorCID, req.Reason)
+	w.audit(r, logging.AuditReinstate, descriptorCID, err, map[string]string{"reason": req.Reason})
+	switch {
+	case errors.Is(err, compliance.ErrTakedownNotFound):
+		sendError(wr, err, http.StatusNotFound)
+		return
+	case err != nil:
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: map[string]string{"reinstated": descriptorCID}})
+}
+
+// handleTransparency publishes the transparency report for the days given
+// by from and to (YYYY-MM-DD, default the year to date). It is public: the
+// report holds no requestor contact details or notice texts.
+func (w *UnifiedWebUI) handleTransparency(wr http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to, err := compliance.ReportPeriod(query.Get("from"), query.Get("to"), time.Now().UTC())
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	report, err := w.takedowns.TransparencyReport(from, to)
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: report})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
+	"github.com/gorilla/mux"
+)
+
+// Default and maximum number of intervals in a topic's statistics. The
+// maximums are what the store keeps.
+var topicStatsPoints = map[store.StatsInterval]struct{ defaults, max int }{
+	store.StatsHourly: {defaults: 48, max: 7 * 24},
+	store.StatsDaily:  {defaults: 30, max: 90},
+}
+
+// TopicStatsView is a topic's announcement activity over time
+type TopicStatsView struct {
+	Topic    string                  `json:"topic"`
+	Hash     string                  `json:"hash"`
+	Interval store.StatsInterval     `json:"interval"`
+	Points   []store.TopicStatsPoint `json:"points"`
+	Total    int                     `json:"total"` // Announcements over all points
+}
+
+// handleTopicStats returns the announcements received for a topic per hour
+// or day
+func (w *UnifiedWebUI) handleTopicStats(wr http.ResponseWriter, r *http.Request) {
+	topic := mux.Vars(r)["topic"]
+	interval := store.StatsInterval(r.URL.Query().Get("interval"))
+	if interval == "" {
+		interval = store.StatsHourly
+	}
+	limits, ok := topicStatsPoints[interval]
+	if !ok {
+		sendError(wr, fmt.Errorf("invalid interval %q (use %s or %s)", interval, store.StatsHourly, store.StatsDaily), http.StatusBadRequest)
+		return
+	}
+
+	points := limits.defaults
+	if value := r.URL.Query().Get("points"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			sendError(wr, fmt.Errorf("invalid points %q", value), http.StatusBadRequest)
+			return
+		}
+		points = min(n, limits.max)
+	}
+
+	length := time.Hour
+	if interval == store.StatsDaily {
+		length = 24 * time.Hour
+	}
+	hash := announce.HashTopic(topic)
+	series, err := w.store.TopicStats(hash, interval, time.Now().Add(-time.Duration(points-1)*length))
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+
+	view := TopicStatsView{Topic: topic, Hash: hash, Interval: interval, Points: series}
+	for _, point := range series {
+		view.Total += point.Count
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: view})
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/transfers"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/webhooks"
+	"github.com/gorilla/mux"
+)
+
+// TransferRequest queues a download from the web UI. Uploads are queued by
+// posting the file as multipart form data instead, with the same fields as
+// form values.
+type TransferRequest struct {
+	DescriptorCID  string    `json:"descriptor_cid"`
+	Priority       int       `json:"priority,omitempty"`
+	BandwidthLimit int64     `json:"bandwidth_limit,omitempty"` // Bytes per second
+	StartAt        time.Time `json:"start_at,omitempty"`        // Hold the transfer back until then
+}
+
+// transferErrorStatus maps transfer errors to HTTP statuses
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, transfers.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, transfers.ErrInvalidState):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// transferChanged updates the stats and sends webhooks once a queued
+// transfer completes, and pushes every change to WebSocket clients for the
+// dashboard
+func (w *UnifiedWebUI) transferChanged(transfer transfers.Transfer) {
+	if transfer.State == transfers.StateCompleted {
+		switch transfer.Kind {
+		case transfers.KindUpload:
+			w.stats.uploads.Add(1)
+			w.webhooks.UploadCompleted(webhooks.Upload{
+				DescriptorCID: transfer.DescriptorCID,
+				Filename:      transfer.Name,
+				Size:          transfer.Size,
+				Source:        "webui",
+			})
+		case transfers.KindDownload:
+			w.stats.downloads.Add(1)
+		}
+	}
+	w.broadcast(map[string]interface{}{
+		"type": "transfer",
+		"data": transfer,
+	})
+}
+
+func (w *UnifiedWebUI) handleListTransfers(wr http.ResponseWriter, r *http.Request) {
+	sendJSON(wr, APIResponse{Success: true, Data: w.transfers.List()})
+}
+
+// handleAddTransfer queues a download of a descriptor, sent as JSON, or an
+// upload of a file, sent as multipart form data. Downloads are saved in the
+// web UI's data directory and fetched from /api/transfers/{id}/file.
+func (w *UnifiedWebUI) handleAddTransfer(wr http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		w.handleAddUploadTransfer(wr, r)
+		return
+	}
+
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	descriptorCID, err := w.validator.ResolveCID(r.Context(), req.DescriptorCID)
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	if w.refuseTakenDown(wr, r, descriptorCID) {
+		return
+	}
+
+	transfer, err := w.transfers.Add(transfers.Request{
+		Kind:           transfers.KindDownload,
+		DescriptorCID:  descriptorCID,
+		Password:       descriptorPassword(r),
+		Priority:       req.Priority,
+		BandwidthLimit: req.BandwidthLimit,
+		StartAt:        req.StartAt,
+	})
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+// handleAddUploadTransfer stages an uploaded file in the data directory
+// and queues its upload, which removes it once done
+func (w *UnifiedWebUI) handleAddUploadTransfer(wr http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(100 << 20); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if err := w.validator.ValidateFilename(header.Filename); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	if err := w.validator.ValidateFileSize(header.Size); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	_, content, err := w.validator.ValidateUploadContent(header.Filename, header.Size, file)
+	if err != nil {
+		w.audit(r, logging.AuditUpload, header.Filename, err, map[string]string{"reason": "content_policy"})
+		sendError(wr, err, http.StatusUnsupportedMediaType)
+		return
+	}
+	var req TransferRequest
+	if value := r.FormValue("priority"); value != "" {
+		if req.Priority, err = strconv.Atoi(value); err != nil {
+			sendError(wr, fmt.Errorf("invalid priority %q", value), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.FormValue("bandwidth_limit"); value != "" {
+		if req.BandwidthLimit, err = strconv.ParseInt(value, 10, 64); err != nil {
+			sendError(wr, fmt.Errorf("invalid bandwidth_limit %q: use bytes per second", value), http.StatusBadRequest)
+			return
+		}
+	}
+	if value := r.FormValue("start_at"); value != "" {
+		if req.StartAt, err = time.Parse(time.RFC3339, value); err != nil {
+			sendError(wr, fmt.Errorf("invalid start_at %q: use an RFC 3339 time", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	stagingDir := transferPath(w.config.WebUI.DataDir, "staged")
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	staged, err := os.CreateTemp(stagingDir, "upload-")
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	if _, err := io.Copy(staged, content); err != nil {
+		staged.Close()
+		os.Remove(staged.Name())
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	if err := staged.Close(); err != nil {
+		os.Remove(staged.Name())
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+
+	transfer, err := w.transfers.Add(transfers.Request{
+		Kind:           transfers.KindUpload,
+		Path:           staged.Name(),
+		Name:           header.Filename,
+		Priority:       req.Priority,
+		BandwidthLimit: req.BandwidthLimit,
+		StartAt:        req.StartAt,
+		RemoveSource:   true,
+	})
+	if err != nil {
+		os.Remove(staged.Name())
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+func (w *UnifiedWebUI) handleGetTransfer(wr http.ResponseWriter, r *http.Request) {
+	transfer, err := w.transfers.Get(mux.Vars(r)["id"])
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+// handleTransferAction pauses, resumes or cancels a transfer
+func (w *UnifiedWebUI) handleTransferAction(wr http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var transfer transfers.Transfer
+	var err error
+	switch vars["action"] {
+	case "pause":
+		transfer, err = w.transfers.Pause(vars["id"])
+	case "resume":
+		transfer, err = w.transfers.Resume(vars["id"])
+	case "cancel":
+		transfer, err = w.transfers.Cancel(vars["id"])
+	default:
+		sendError(wr, fmt.Errorf("unknown action %q. Valid actions: pause, resume, cancel", vars["action"]), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+func (w *UnifiedWebUI) handleSetTransferPriority(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Priority int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	transfer, err := w.transfers.SetPriority(mux.Vars(r)["id"], req.Priority)
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+// handleSetTransferBandwidth caps a transfer in bytes per second, 0 for
+// no cap beyond the queue's
+func (w *UnifiedWebUI) handleSetTransferBandwidth(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		BandwidthLimit int64 `json:"bandwidth_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	if req.BandwidthLimit < 0 {
+		sendError(wr, fmt.Errorf("bandwidth_limit cannot be negative"), http.StatusBadRequest)
+		return
+	}
+	transfer, err := w.transfers.SetBandwidthLimit(mux.Vars(r)["id"], req.BandwidthLimit)
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+// handleScheduleTransfer holds a transfer back until start_at, or starts
+// it as soon as there is room when start_at is left out
+func (w *UnifiedWebUI) handleScheduleTransfer(wr http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartAt time.Time `json:"start_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(wr, err, http.StatusBadRequest)
+		return
+	}
+	transfer, err := w.transfers.Schedule(mux.Vars(r)["id"], req.StartAt)
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: transfer})
+}
+
+func (w *UnifiedWebUI) handleRemoveTransfer(wr http.ResponseWriter, r *http.Request) {
+	if err := w.transfers.Remove(mux.Vars(r)["id"]); err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true})
+}
+
+// handleTransferFile serves the file of a completed download. Only files
+// in the web UI's own download directory are served, not the destinations
+// of downloads queued through the control socket.
+func (w *UnifiedWebUI) handleTransferFile(wr http.ResponseWriter, r *http.Request) {
+	transfer, err := w.transfers.Get(mux.Vars(r)["id"])
+	if err != nil {
+		sendError(wr, err, transferErrorStatus(err))
+		return
+	}
+	if transfer.Kind != transfers.KindDownload || transfer.State != transfers.StateCompleted {
+		sendError(wr, fmt.Errorf("transfer %s is not a completed download", transfer.ID), http.StatusConflict)
+		return
+	}
+	downloadDir, err := filepath.Abs(transferPath(w.config.WebUI.DataDir, "downloads"))
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	output, err := filepath.Abs(transfer.Output)
+	if err != nil || !strings.HasPrefix(output, downloadDir+string(filepath.Separator)) {
+		sendError(wr, fmt.Errorf("transfer %s was saved outside the web UI", transfer.ID), http.StatusForbidden)
+		return
+	}
+	if w.refuseTakenDown(wr, r, transfer.DescriptorCID) {
+		return
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		sendError(wr, err, http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	w.audit(r, logging.AuditDownload, transfer.DescriptorCID, nil, map[string]string{"filename": transfer.Name, "transfer": transfer.ID})
+	wr.Header().Set("Content-Type", "application/octet-stream")
+	wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", transfer.Name))
+	http.ServeContent(wr, r, transfer.Name, info.ModTime(), file)
+}
+
+// transferPath locates the transfer queue's files in the data directory:
+// its state, staged uploads and downloads queued from the web UI
+func transferPath(dataDir, name string) string {
+	return filepath.Join(dataDir, "transfers", name)
+}
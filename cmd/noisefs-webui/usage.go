@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+)
+
+// handleAdminUsage reports the storage the descriptors given with
+// ?descriptor= take up, or every descriptor in the file index, counting
+// blocks they share once
+func (w *UnifiedWebUI) handleAdminUsage(wr http.ResponseWriter, r *http.Request) {
+	cids := r.URL.Query()["descriptor"]
+	if len(cids) == 0 && w.fileIndex != nil {
+		seen := make(map[string]bool)
+		for _, entry := range w.fileIndex.ListFiles() {
+			for _, cid := range []string{entry.DescriptorCID, entry.DirectoryDescriptorCID} {
+				if cid != "" && !seen[cid] {
+					seen[cid] = true
+					cids = append(cids, cid)
+				}
+			}
+		}
+		sort.Strings(cids)
+	}
+
+	usage, err := descriptors.MeasureUsage(r.Context(), w.storageManager, cids, "")
+	if err != nil {
+		sendError(wr, err, http.StatusInternalServerError)
+		return
+	}
+	sendJSON(wr, APIResponse{Success: true, Data: usage})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+)
+
+// protocolHeader carries the API protocol on every response, so pages
+// loaded from an older or newer web UI notice the server was replaced
+const protocolHeader = "X-NoiseFS-Protocol"
+
+// announceProtocol sets the protocol header on every response
+func announceProtocol(next http.Handler) http.Handler {
+	protocol := strconv.Itoa(version.Protocol)
+	return http.HandlerFunc(func(wr http.ResponseWriter, r *http.Request) {
+		wr.Header().Set(protocolHeader, protocol)
+		next.ServeHTTP(wr, r)
+	})
+}
+
+// handleVersion returns the NoiseFS build and the protocols it speaks
+func (w *UnifiedWebUI) handleVersion(wr http.ResponseWriter, r *http.Request) {
+	sendJSON(wr, APIResponse{Success: true, Data: version.Current()})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsSendBuffer is the number of messages queued for each client
+	wsSendBuffer = 100
+
+	// wsEnqueueTimeout is how long a message waits for room in a full
+	// client queue before it is dropped
+	wsEnqueueTimeout = 250 * time.Millisecond
+
+	// wsWriteTimeout is the deadline of each write to a client; a client
+	// that misses it is disconnected
+	wsWriteTimeout = 10 * time.Second
+
+	// wsEvictAfterDrops is the number of messages in a row a client may
+	// miss before it is disconnected as persistently slow
+	wsEvictAfterDrops = 20
+
+	// wsFanOutWorkers wait for room in full client queues, so slow clients
+	// don't hold up a broadcast to the others
+	wsFanOutWorkers = 4
+)
+
+// wsClient is a WebSocket connection with its queue of outgoing messages
+type wsClient struct {
+	conn    *websocket.Conn
+	send    chan *websocket.PreparedMessage
+	done    chan struct{} // Closed when the client is removed
+	waiting atomic.Bool   // A message is waiting for room in send
+	drops   atomic.Int64  // Messages missed in a row
+	once    sync.Once
+}
+
+// wsHub fans messages out to WebSocket clients. Each client has a writer
+// goroutine draining its queue with a write deadline. Messages to a full
+// queue wait briefly on the fan-out pool and are dropped after that;
+// clients that keep missing messages are disconnected.
+type wsHub struct {
+	mu      sync.RWMutex
+	clients map[*wsClient]struct{}
+	pool    *workers.Pool
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	evicted atomic.Int64
+}
+
+func newWSHub() *wsHub {
+	pool := workers.NewPool(workers.Config{
+		WorkerCount: wsFanOutWorkers,
+		BufferSize:  wsFanOutWorkers * 16,
+		Name:        "websocket",
+	})
+	pool.Start()
+	go func() {
+		for range pool.Results() {
+			// Deliveries report nothing
+		}
+	}()
+	return &wsHub{
+		clients: make(map[*wsClient]struct{}),
+		pool:    pool,
+	}
+}
+
+// add registers a connection and starts writing its messages
+func (h *wsHub) add(conn *websocket.Conn) *wsClient {
+	client := &wsClient{
+		conn: conn,
+		send: make(chan *websocket.PreparedMessage, wsSendBuffer),
+		done: make(chan struct{}),
+	}
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	go h.write(client)
+	return client
+}
+
+// remove unregisters a client and closes its connection. It reports
+// whether the client was still registered.
+func (h *wsHub) remove(client *wsClient) bool {
+	removed := false
+	client.once.Do(func() {
+		h.mu.Lock()
+		delete(h.clients, client)
+		h.mu.Unlock()
+		close(client.done)
+		client.conn.Close()
+		removed = true
+	})
+	return removed
+}
+
+// write sends a client's queued messages until it is removed or a write
+// fails
+func (h *wsHub) write(client *wsClient) {
+	for {
+		select {
+		case message := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := client.conn.WritePreparedMessage(message); err != nil {
+				h.remove(client)
+				return
+			}
+			h.sent.Add(1)
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// broadcast sends a message to every client. It is encoded once for all
+// of them.
+func (h *wsHub) broadcast(message interface{}) {
+	prepared, err := prepareMessage(message)
+	if err != nil {
+		log.Printf("Failed to encode WebSocket message: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.enqueue(client, prepared)
+	}
+}
+
+// sendTo sends a message to one client
+func (h *wsHub) sendTo(client *wsClient, message interface{}) {
+	prepared, err := prepareMessage(message)
+	if err != nil {
+		log.Printf("Failed to encode WebSocket message: %v", err)
+		return
+	}
+	h.enqueue(client, prepared)
+}
+
+// enqueue queues a message for a client. When the client's queue is full
+// the message waits for room on the fan-out pool. Messages to a client
+// that already has one waiting are dropped, so the ones it gets stay in
+// order.
+func (h *wsHub) enqueue(client *wsClient, message *websocket.PreparedMessage) {
+	if client.waiting.Load() {
+		h.drop(client)
+		return
+	}
+	select {
+	case client.send <- message:
+		client.drops.Store(0)
+		return
+	case <-client.done:
+		return
+	default:
+	}
+
+	if !client.waiting.CompareAndSwap(false, true) {
+		h.drop(client)
+		return
+	}
+	if err := h.pool.Submit(&wsDelivery{hub: h, client: client, message: message}); err != nil {
+		client.waiting.Store(false)
+		h.drop(client)
+	}
+}
+
+// drop counts a message a client missed, and disconnects the client once it
+// has missed too many in a row
+func (h *wsHub) drop(client *wsClient) {
+	h.dropped.Add(1)
+	if client.drops.Add(1) < wsEvictAfterDrops {
+		return
+	}
+	if h.remove(client) {
+		h.evicted.Add(1)
+		log.Printf("Disconnected WebSocket client %s: missed %d messages in a row", client.conn.RemoteAddr(), wsEvictAfterDrops)
+	}
+}
+
+// WebSocketStats implements metrics.WebSocketStats
+func (h *wsHub) WebSocketStats() (clients int, sent, dropped, evicted int64) {
+	h.mu.RLock()
+	clients = len(h.clients)
+	h.mu.RUnlock()
+	return clients, h.sent.Load(), h.dropped.Load(), h.evicted.Load()
+}
+
+// close disconnects every client and stops the fan-out pool
+func (h *wsHub) close() {
+	h.mu.RLock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.remove(client)
+	}
+	h.pool.Shutdown()
+}
+
+func prepareMessage(message interface{}) (*websocket.PreparedMessage, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	return websocket.NewPreparedMessage(websocket.TextMessage, data)
+}
+
+// wsDelivery waits for room in a full client queue
+type wsDelivery struct {
+	hub     *wsHub
+	client  *wsClient
+	message *websocket.PreparedMessage
+}
+
+func (d *wsDelivery) Execute(ctx context.Context) (interface{}, error) {
+	defer d.client.waiting.Store(false)
+
+	timer := time.NewTimer(wsEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case d.client.send <- d.message:
+		d.client.drops.Store(0)
+	case <-d.client.done:
+	case <-timer.C:
+		d.hub.drop(d.client)
+	case <-ctx.Done():
+	}
+	return nil, nil
+}
+
+func (d *wsDelivery) ID() string {
+	return "websocket-delivery"
+}
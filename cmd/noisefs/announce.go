@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -11,55 +14,209 @@ import (
 	"github.com/TheEntropyCollective/noisefs/pkg/announce"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/pubsub"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/util"
 	shell "github.com/ipfs/go-ipfs-api"
 )
 
-// announceCommand handles the announce subcommand
-func announceCommand(args []string, storageManager *storage.Manager, shell *shell.Shell, quiet bool, jsonOutput bool) error {
+// announceTarget is a descriptor to announce, what is known about the file
+// it describes and the settings to announce it with
+type announceTarget struct {
+	descriptor string
+	filename   string
+	size       int64
+	checksum   string // "sha256:<hex>" of the content, empty when unknown
+	filePath   string // Local file the automatic tags are taken from, if any
+	settings   announce.Template
+	title      string
+	preview    string
+}
+
+// AnnouncePlan is an announcement ready to be published, and the metadata
+// record published before it
+type AnnouncePlan struct {
+	Topic        string                 `json:"topic"`
+	Announcement *announce.Announcement `json:"announcement"`
+	Metadata     *announce.Metadata     `json:"metadata,omitempty"`
+	Realtime     bool                   `json:"realtime"`
+	Sign         bool                   `json:"sign"`
+}
+
+// BatchAnnounceResult is the outcome of announcing one batch item
+type BatchAnnounceResult struct {
+	Descriptor string `json:"descriptor"`
+	Topic      string `json:"topic,omitempty"`
+	TopicHash  string `json:"topic_hash,omitempty"`
+	Metadata   string `json:"metadata,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// announceCommand handles the announce subcommand. The privacy settings set
+// the defaults of -auto-tags and -realtime.
+func announceCommand(args []string, storageManager *storage.Manager, shell *shell.Shell, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	privacy := cfg.Privacy
+
 	// Create flag set for announce command
 	flagSet := flag.NewFlagSet("announce", flag.ExitOnError)
 
 	var (
 		topic    = flagSet.String("topic", "", "Topic for the announcement (required)")
 		tags     = flagSet.String("tags", "", "Comma-separated tags for discovery")
+		category = flagSet.String("category", "", "Content category (detected from the file name if empty)")
 		ttl      = flagSet.Duration("ttl", 24*time.Hour, "Time to live for announcement")
-		autoTags = flagSet.Bool("auto-tags", true, "Automatically extract tags from file")
-		realtime = flagSet.Bool("realtime", true, "Also publish to PubSub for real-time delivery")
-		help     = flagSet.Bool("help", false, "Show help for announce command")
+		autoTags = flagSet.Bool("auto-tags", privacy.AnnounceAutoTags, "Automatically extract tags from file")
+		realtime = flagSet.Bool("realtime", privacy.AnnounceRealtime, "Also publish to PubSub for real-time delivery")
+		sign     = flagSet.Bool("sign", false, "Sign with your publisher key, so subscribers rank your announcements by reputation")
+
+		// Metadata record, published beside the descriptor
+		title       = flagSet.String("title", "", "Human-friendly title for the file")
+		description = flagSet.String("description", "", "Description of the file")
+		license     = flagSet.String("license", "", "License of the file, such as an SPDX identifier")
+		preview     = flagSet.String("preview", "", "Descriptor CID of a preview, such as a thumbnail")
+
+		// Descriptors already stored, and batches of them
+		descriptorCID = flagSet.String("descriptor", "", "Announce this stored descriptor instead of uploading a file")
+		batchPath     = flagSet.String("batch", "", "Announce the descriptors listed in this JSON or JSON Lines file")
+		interval      = flagSet.Duration("interval", time.Minute, "Wait between announcements to the same topic in a batch")
+		dryRun        = flagSet.Bool("dry-run", false, "Print the announcements as JSON without publishing them")
+
+		// Templates
+		templateName   = flagSet.String("template", "", "Apply a saved template; other options override it")
+		saveTemplate   = flagSet.String("save-template", "", "Save the template and options given under this name")
+		listTemplates  = flagSet.Bool("templates", false, "List saved templates")
+		removeTemplate = flagSet.String("remove-template", "", "Delete a saved template")
+
+		help = flagSet.Bool("help", false, "Show help for announce command")
 	)
 
 	// Custom usage
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: noisefs announce <file> [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: noisefs announce <file> [options]\n")
+		fmt.Fprintf(os.Stderr, "       noisefs announce -descriptor CID [options]\n")
+		fmt.Fprintf(os.Stderr, "       noisefs announce -batch FILE [options]\n\n")
 		fmt.Fprintf(os.Stderr, "Announce a file to a topic for discovery by others.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flagSet.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  noisefs announce myfile.pdf --topic \"documents/research\"\n")
 		fmt.Fprintf(os.Stderr, "  noisefs announce video.mp4 --topic \"movies/scifi\" --tags \"4k,remastered\"\n")
+		fmt.Fprintf(os.Stderr, "  noisefs announce paper.pdf --topic \"documents/research\" --title \"Onion Routing\" --license CC-BY-4.0\n")
+		fmt.Fprintf(os.Stderr, "  noisefs announce --save-template papers --topic \"documents/research\" --license CC-BY-4.0 --sign\n")
+		fmt.Fprintf(os.Stderr, "  noisefs announce --batch catalog.jsonl --template papers --dry-run\n")
+		fmt.Fprintf(os.Stderr, "\nA title, description, license or preview is published as a metadata record\n")
+		fmt.Fprintf(os.Stderr, "with the file's checksum, separate from the descriptor.\n")
+		fmt.Fprintf(os.Stderr, "\nBatch files hold one object per descriptor, as a JSON array or one per line:\n")
+		fmt.Fprintf(os.Stderr, "  {\"descriptor\": \"Qm...\", \"topic\": \"documents/research\", \"tags\": [\"format:pdf\"], \"title\": \"...\"}\n")
+		fmt.Fprintf(os.Stderr, "Items may also set template, category, ttl, description, license and preview.\n")
+		fmt.Fprintf(os.Stderr, "Encrypted descriptors are read with the password in $%s.\n", bundlePasswordEnv)
 	}
 
 	if err := flagSet.Parse(args); err != nil {
 		return err
 	}
 
-	if *help || flagSet.NArg() == 0 {
+	set := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	templatesPath, err := store.DefaultTemplateStorePath()
+	if err != nil {
+		return err
+	}
+	templates := store.NewTemplateStore(templatesPath)
+
+	switch {
+	case *help:
+		flagSet.Usage()
+		return nil
+	case *listTemplates:
+		return listAnnounceTemplates(templates, quiet, jsonOutput)
+	case *removeTemplate != "":
+		if err := templates.Remove(*removeTemplate); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Printf("Removed template %s\n", *removeTemplate)
+		}
+		return nil
+	}
+
+	// Settings from the template, with the options given on top
+	var settings announce.Template
+	if *templateName != "" {
+		if settings, err = templates.Get(*templateName); err != nil {
+			return err
+		}
+	}
+	given := announce.Template{Topic: *topic, Tags: splitTags(*tags), Category: *category, Description: *description, License: *license}
+	if set["ttl"] {
+		given.TTL = ttl.String()
+	}
+	settings = settings.Merge(given)
+	switches := map[string]*bool{"auto-tags": &settings.AutoTags, "realtime": &settings.Realtime, "sign": &settings.Sign}
+	values := map[string]bool{"auto-tags": *autoTags, "realtime": *realtime, "sign": *sign}
+	for name, value := range switches {
+		if set[name] {
+			*value = values[name]
+		}
+	}
+
+	if *saveTemplate != "" {
+		if err := templates.Put(*saveTemplate, settings); err != nil {
+			return err
+		}
+		if !quiet && !jsonOutput {
+			fmt.Printf("Saved template %s\n", *saveTemplate)
+		}
+		if flagSet.NArg() == 0 && *descriptorCID == "" && *batchPath == "" {
+			if jsonOutput {
+				util.PrintJSONSuccess(settings)
+			}
+			return nil
+		}
+	}
+
+	if flagSet.NArg() == 0 && *descriptorCID == "" && *batchPath == "" {
 		flagSet.Usage()
 		return nil
 	}
+	if (flagSet.NArg() > 0 && *descriptorCID != "") || (*batchPath != "" && (flagSet.NArg() > 0 || *descriptorCID != "")) {
+		return fmt.Errorf("give a file, -descriptor or -batch, not more than one")
+	}
+
+	// Switches not given anywhere follow the privacy settings
+	defaults := announce.Template{AutoTags: privacy.AnnounceAutoTags && !set["auto-tags"], Realtime: privacy.AnnounceRealtime && !set["realtime"]}
+	settings = defaults.Merge(settings)
+
+	if *batchPath != "" {
+		return announceBatch(*batchPath, storageManager, shell, cfg, templates, settings, *interval, *dryRun, quiet, jsonOutput)
+	}
+	if *descriptorCID != "" {
+		target, err := loadAnnounceTarget(storageManager, cfg, *descriptorCID)
+		if err != nil {
+			return err
+		}
+		target.settings, target.title, target.preview = settings, *title, *preview
+		return announceOne(target, storageManager, shell, *dryRun, quiet, jsonOutput)
+	}
+	if *dryRun {
+		return fmt.Errorf("-dry-run needs -descriptor or -batch: a file has no descriptor CID until it is uploaded")
+	}
 
 	// Get file path
 	filePath := flagSet.Arg(0)
 
 	// Validate inputs
-	if *topic == "" {
+	if settings.Topic == "" {
 		return fmt.Errorf("topic is required")
 	}
+	if err := settings.Validate(); err != nil {
+		return err
+	}
 
 	// Check if file exists
 	fileInfo, err := os.Stat(filePath)
@@ -67,8 +224,6 @@ func announceCommand(args []string, storageManager *storage.Manager, shell *shel
 		return fmt.Errorf("failed to access file: %w", err)
 	}
 
-	logger := logging.GetGlobalLogger().WithComponent("announce")
-
 	// First, upload the file to get descriptor
 	if !quiet {
 		fmt.Printf("Uploading %s to NoiseFS...\n", filePath)
@@ -80,13 +235,6 @@ func announceCommand(args []string, storageManager *storage.Manager, shell *shel
 		return fmt.Errorf("failed to create descriptor store: %w", err)
 	}
 
-	// Upload file (simplified - in real implementation would use full upload flow)
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
 	// Store file using storage manager (simplified)
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -109,103 +257,421 @@ func announceCommand(args []string, storageManager *storage.Manager, shell *shel
 	descriptor.AddBlockTriple(cid, cid+"_rand1", cid+"_rand2") // Simplified for demo
 
 	// Save descriptor
-	descriptorCID, err := descStore.Save(descriptor)
+	uploadedCID, err := descStore.Save(descriptor)
 	if err != nil {
 		return fmt.Errorf("failed to save descriptor: %w", err)
 	}
 
 	if !quiet {
-		fmt.Printf("Created descriptor: %s\n", descriptorCID)
+		fmt.Printf("Created descriptor: %s\n", uploadedCID)
 	}
 
-	// Create announcement
-	creator := announce.NewCreator()
+	target := &announceTarget{
+		descriptor: uploadedCID,
+		filename:   fileInfo.Name(),
+		size:       fileInfo.Size(),
+		filePath:   filePath,
+		settings:   settings,
+		title:      *title,
+		preview:    *preview,
+	}
+	if target.checksum, err = announce.Checksum(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	return announceOne(target, storageManager, shell, false, quiet, jsonOutput)
+}
 
-	// Parse tags
-	var tagList []string
-	if *tags != "" {
-		tagList = strings.Split(*tags, ",")
-		for i, tag := range tagList {
-			tagList[i] = strings.TrimSpace(tag)
+// listAnnounceTemplates prints the saved templates
+func listAnnounceTemplates(templates *store.TemplateStore, quiet bool, jsonOutput bool) error {
+	names, err := templates.Names()
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		saved := make(map[string]announce.Template, len(names))
+		for _, name := range names {
+			if saved[name], err = templates.Get(name); err != nil {
+				return err
+			}
+		}
+		util.PrintJSONSuccess(saved)
+		return nil
+	}
+	if len(names) == 0 && !quiet {
+		fmt.Println("No saved templates")
+		return nil
+	}
+	for _, name := range names {
+		if quiet {
+			fmt.Println(name)
+			continue
+		}
+		template, err := templates.Get(name)
+		if err != nil {
+			return err
 		}
+		data, _ := json.Marshal(template)
+		fmt.Printf("%s  %s\n", name, data)
 	}
+	return nil
+}
 
-	// Create announcement options
-	opts := announce.CreateOptions{
-		Topic:    *topic,
-		Tags:     tagList,
-		TTL:      *ttl,
-		AutoTags: *autoTags,
+// loadAnnounceTarget reads what an announcement needs from a stored
+// descriptor
+func loadAnnounceTarget(storageManager *storage.Manager, cfg *config.Config, descriptorCID string) (*announceTarget, error) {
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, _, err := client.LoadDescriptor(descriptorCID, os.Getenv(bundlePasswordEnv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load descriptor %s: %w", descriptorCID, err)
 	}
 
-	// Create announcement with file metadata
-	announcement, err := creator.CreateFromFile(descriptorCID, filePath, opts)
+	target := &announceTarget{descriptor: descriptorCID, filename: descriptor.Filename, size: descriptor.FileSize}
+	if descriptor.ContentHash != "" {
+		target.checksum = "sha256:" + descriptor.ContentHash
+	}
+	return target, nil
+}
+
+// planAnnouncement creates the announcement and metadata record for a
+// target without publishing anything
+func planAnnouncement(creator *announce.Creator, target *announceTarget) (*AnnouncePlan, error) {
+	opts, err := target.settings.Options()
 	if err != nil {
-		return fmt.Errorf("failed to create announcement: %w", err)
+		return nil, err
 	}
 
-	// Publish to DHT
-	if !quiet {
-		fmt.Printf("Publishing announcement to topic: %s\n", *topic)
-		fmt.Printf("Topic hash: %s\n", announcement.TopicHash)
+	var announcement *announce.Announcement
+	if target.filePath != "" {
+		announcement, err = creator.CreateFromFile(target.descriptor, target.filePath, opts)
+	} else {
+		announcement, err = creator.CreateForDescriptor(target.descriptor, target.filename, target.size, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create announcement: %w", err)
 	}
 
+	plan := &AnnouncePlan{Topic: opts.Topic, Announcement: announcement, Realtime: target.settings.Realtime, Sign: target.settings.Sign}
+	if target.title != "" || target.settings.Description != "" || target.settings.License != "" || target.preview != "" {
+		metadata := announce.NewMetadata(target.descriptor)
+		metadata.Title = target.title
+		metadata.Description = target.settings.Description
+		metadata.License = target.settings.License
+		metadata.Preview = target.preview
+		metadata.Checksum = target.checksum
+		if err := metadata.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		plan.Metadata = metadata
+	}
+	return plan, nil
+}
+
+// announcePublishers publishes plans to the DHT and, for plans that ask
+// for it, to PubSub
+type announcePublishers struct {
+	shell    *shell.Shell
+	dht      *dht.Publisher
+	realtime *pubsub.RealtimePublisher
+	key      ed25519.PrivateKey
+	logger   *logging.Logger
+}
+
+func newAnnouncePublishers(storageManager *storage.Manager, sh *shell.Shell, publishRate time.Duration) (*announcePublishers, error) {
 	// Create DHT publisher
-	pubConfig := dht.PublisherConfig{
+	publisher, err := dht.NewPublisher(dht.PublisherConfig{
 		StorageManager: storageManager,
-		IPFSShell:      shell,
-		PublishRate:    1 * time.Minute,
+		IPFSShell:      sh,
+		PublishRate:    publishRate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create publisher: %w", err)
 	}
+	return &announcePublishers{shell: sh, dht: publisher, logger: logging.GetGlobalLogger().WithComponent("announce")}, nil
+}
 
-	publisher, err := dht.NewPublisher(pubConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create publisher: %w", err)
+// publish stores the plan's metadata record, so the announcement can refer
+// to it, signs the announcement if asked and publishes it
+func (p *announcePublishers) publish(ctx context.Context, plan *AnnouncePlan, quiet bool) error {
+	var err error
+	if plan.Metadata != nil {
+		if plan.Announcement.Metadata, err = p.dht.StoreMetadata(ctx, plan.Metadata); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Printf("Stored metadata: %s\n", plan.Announcement.Metadata)
+		}
 	}
 
-	ctx := context.Background()
-	if err := publisher.Publish(ctx, announcement); err != nil {
+	// Signing comes last, as it covers everything else
+	if plan.Sign {
+		if p.key == nil {
+			keyPath, err := announce.DefaultCuratorKeyPath()
+			if err != nil {
+				return err
+			}
+			if p.key, err = announce.LoadOrCreateCuratorKey(keyPath); err != nil {
+				return err
+			}
+		}
+		if err := plan.Announcement.Sign(p.key); err != nil {
+			return fmt.Errorf("failed to sign announcement: %w", err)
+		}
+	}
+
+	if err := p.dht.Publish(ctx, plan.Announcement); err != nil {
 		return fmt.Errorf("failed to publish announcement: %w", err)
 	}
 
 	// Also publish to PubSub if requested
-	if *realtime {
-		rtPublisher, err := pubsub.NewRealtimePublisher(shell)
-		if err != nil {
-			logger.Warn("Failed to create realtime publisher", map[string]interface{}{
-				"error": err.Error(),
-			})
-		} else {
-			if err := rtPublisher.Publish(ctx, announcement); err != nil {
-				logger.Warn("Failed to publish to PubSub", map[string]interface{}{
+	if plan.Realtime {
+		if p.realtime == nil {
+			if p.realtime, err = pubsub.NewRealtimePublisher(p.shell); err != nil {
+				p.logger.Warn("Failed to create realtime publisher", map[string]interface{}{
 					"error": err.Error(),
 				})
-			} else if !quiet {
-				fmt.Println("Published to real-time PubSub channel")
+				return nil
 			}
 		}
+		if err := p.realtime.Publish(ctx, plan.Announcement); err != nil {
+			p.logger.Warn("Failed to publish to PubSub", map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if !quiet {
+			fmt.Println("Published to real-time PubSub channel")
+		}
+	}
+	return nil
+}
+
+// announceOne announces a single descriptor, or prints its plan
+func announceOne(target *announceTarget, storageManager *storage.Manager, sh *shell.Shell, dryRun bool, quiet bool, jsonOutput bool) error {
+	plan, err := planAnnouncement(announce.NewCreator(), target)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return printAnnouncePlans([]*AnnouncePlan{plan}, jsonOutput)
+	}
+
+	publishers, err := newAnnouncePublishers(storageManager, sh, time.Minute)
+	if err != nil {
+		return err
+	}
+
+	// Publish to DHT
+	if !quiet {
+		fmt.Printf("Publishing announcement to topic: %s\n", plan.Topic)
+		fmt.Printf("Topic hash: %s\n", plan.Announcement.TopicHash)
+	}
+	err = publishers.publish(context.Background(), plan, quiet)
+	details := map[string]string{"topic": plan.Topic}
+	if target.filePath != "" {
+		details["file"] = target.filePath
+	}
+	recordAudit(logging.AuditAnnounce, target.descriptor, err, details)
+	if err != nil {
+		return err
 	}
 
 	// Output results
+	announcement := plan.Announcement
 	if jsonOutput {
 		result := map[string]interface{}{
 			"success":    true,
-			"descriptor": descriptorCID,
-			"topic":      *topic,
+			"descriptor": target.descriptor,
+			"topic":      plan.Topic,
 			"topic_hash": announcement.TopicHash,
 			"tags":       announcement.TagBloom != "",
 			"ttl":        announcement.TTL,
-			"realtime":   *realtime,
+			"realtime":   plan.Realtime,
+			"metadata":   announcement.Metadata,
+			"publisher":  announcement.Publisher,
 		}
 		util.PrintJSON(result)
 	} else if !quiet {
 		fmt.Println("\n✓ Announcement published successfully!")
-		fmt.Printf("Descriptor: %s\n", descriptorCID)
-		fmt.Printf("Topic: %s (hash: %s...)\n", *topic, announcement.TopicHash[:16])
-		if len(tagList) > 0 {
-			fmt.Printf("Tags: %s\n", strings.Join(tagList, ", "))
+		fmt.Printf("Descriptor: %s\n", target.descriptor)
+		fmt.Printf("Topic: %s (hash: %s...)\n", plan.Topic, announcement.TopicHash[:16])
+		if len(target.settings.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(target.settings.Tags, ", "))
+		}
+		if announcement.Metadata != "" {
+			fmt.Printf("Metadata: %s\n", announcement.Metadata)
+		}
+		if announcement.Publisher != "" {
+			fmt.Printf("Signed by: %s\n", announcement.Publisher)
+		}
+		fmt.Printf("Expires in: %v\n", time.Duration(announcement.TTL)*time.Second)
+	}
+
+	return nil
+}
+
+// announceBatch announces every descriptor of a batch file. Items that fail
+// are reported and skipped; announcements to a topic used within the
+// interval wait for it, since publishers rate limit each topic.
+func announceBatch(path string, storageManager *storage.Manager, sh *shell.Shell, cfg *config.Config, templates *store.TemplateStore, settings announce.Template, interval time.Duration, dryRun bool, quiet bool, jsonOutput bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open batch file: %w", err)
+	}
+	items, err := announce.ParseBatch(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	// Plan everything first, so that mistakes show before anything is published
+	creator := announce.NewCreator()
+	plans := make([]*AnnouncePlan, len(items))
+	results := make([]BatchAnnounceResult, len(items))
+	failed := 0
+	for i, item := range items {
+		results[i].Descriptor = item.Descriptor
+		plans[i], err = planBatchItem(creator, storageManager, cfg, templates, settings, item)
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			if !quiet && !jsonOutput {
+				fmt.Printf("✗ %s: %v\n", item.Descriptor, err)
+			}
+			continue
+		}
+		results[i].Topic = plans[i].Topic
+		results[i].TopicHash = plans[i].Announcement.TopicHash
+	}
+
+	if dryRun {
+		var valid []*AnnouncePlan
+		for _, plan := range plans {
+			if plan != nil {
+				valid = append(valid, plan)
+			}
+		}
+		if err := printAnnouncePlans(valid, jsonOutput); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d batch items are invalid", failed, len(items))
+		}
+		return nil
+	}
+
+	if interval < time.Second {
+		// Announcement keys carry a timestamp in seconds
+		interval = time.Second
+	}
+	publishers, err := newAnnouncePublishers(storageManager, sh, interval)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	lastPublish := make(map[string]time.Time)
+	for i, plan := range plans {
+		if plan == nil {
+			continue
+		}
+		if wait := time.Until(lastPublish[plan.Announcement.TopicHash].Add(interval)); wait > 0 {
+			if !quiet && !jsonOutput {
+				fmt.Printf("Waiting %s before announcing to %s again...\n", wait.Round(time.Second), plan.Topic)
+			}
+			time.Sleep(wait)
+		}
+
+		// Timestamps were set when planning, which may be a while ago
+		plan.Announcement.Timestamp = time.Now().Unix()
+		err := publishers.publish(ctx, plan, true)
+		lastPublish[plan.Announcement.TopicHash] = time.Now()
+		recordAudit(logging.AuditAnnounce, plan.Announcement.Descriptor, err, map[string]string{"topic": plan.Topic, "batch": path})
+		if err != nil {
+			results[i].Error = err.Error()
+			failed++
+			if !quiet && !jsonOutput {
+				fmt.Printf("✗ %s: %v\n", plan.Announcement.Descriptor, err)
+			}
+			continue
+		}
+		results[i].Metadata = plan.Announcement.Metadata
+		if !quiet && !jsonOutput {
+			fmt.Printf("✓ %s → %s\n", plan.Announcement.Descriptor, plan.Topic)
 		}
-		fmt.Printf("Expires in: %v\n", *ttl)
 	}
 
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{
+			"announced": len(items) - failed,
+			"failed":    failed,
+			"items":     results,
+		})
+	} else if !quiet {
+		fmt.Printf("\nAnnounced %d of %d descriptors\n", len(items)-failed, len(items))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d announcements failed", failed, len(items))
+	}
 	return nil
 }
+
+// planBatchItem resolves the settings of a batch item and plans its
+// announcement
+func planBatchItem(creator *announce.Creator, storageManager *storage.Manager, cfg *config.Config, templates *store.TemplateStore, settings announce.Template, item announce.BatchItem) (*AnnouncePlan, error) {
+	if item.Base != "" {
+		base, err := templates.Get(item.Base)
+		if err != nil {
+			return nil, err
+		}
+		settings = settings.Merge(base)
+	}
+	target, err := loadAnnounceTarget(storageManager, cfg, item.Descriptor)
+	if err != nil {
+		return nil, err
+	}
+	target.settings = settings.Merge(item.Template)
+	target.title, target.preview = item.Title, item.Preview
+	return planAnnouncement(creator, target)
+}
+
+// printAnnouncePlans shows the announcements a dry run would publish
+func printAnnouncePlans(plans []*AnnouncePlan, jsonOutput bool) error {
+	if jsonOutput {
+		util.PrintJSONSuccess(plans)
+		return nil
+	}
+	for _, plan := range plans {
+		fmt.Printf("# %s → %s\n", plan.Announcement.Descriptor, plan.Topic)
+		data, err := json.MarshalIndent(plan.Announcement, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		if plan.Metadata != nil {
+			data, err := json.MarshalIndent(plan.Metadata, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("# Metadata record, whose CID is set as \"m\" when it is stored\n%s\n", data)
+		}
+		if plan.Sign {
+			fmt.Println("# Signed with your publisher key when published")
+		}
+		if plan.Realtime {
+			fmt.Println("# Also published to PubSub")
+		}
+	}
+	return nil
+}
+
+// splitTags parses a comma-separated list of tags
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// cliActor identifies the local user in audit events
+func cliActor() string {
+	if current, err := user.Current(); err == nil {
+		return "cli:" + current.Username
+	}
+	return "cli"
+}
+
+// recordAudit records the outcome of a CLI operation in the audit log
+func recordAudit(eventType logging.AuditEventType, resource string, opErr error, details map[string]string) {
+	event := logging.AuditEvent{
+		Type:     eventType,
+		Actor:    cliActor(),
+		Resource: resource,
+		Outcome:  logging.AuditSuccess,
+		Details:  details,
+	}
+	if opErr != nil {
+		event.Outcome = logging.AuditFailure
+		if event.Details == nil {
+			event.Details = make(map[string]string)
+		}
+		event.Details["error"] = opErr.Error()
+	}
+
+	if err := logging.Audit(event); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", err)
+	}
+}
+
+// AuditQueryResult is the output of the audit command
+type AuditQueryResult struct {
+	File         string                     `json:"file"`
+	Events       []logging.AuditEvent       `json:"events,omitempty"`
+	Verification *logging.AuditVerification `json:"verification,omitempty"`
+}
+
+// auditCommand queries or verifies the audit log
+func auditCommand(args []string, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("audit", flag.ContinueOnError)
+	file := flagSet.String("file", cfg.Security.AuditLogFile, "Audit log file")
+	types := flagSet.String("type", "", "Comma-separated event types (upload, download, announce, subscribe, unsubscribe, auth_failure, access_denied, config_change)")
+	actor := flagSet.String("actor", "", "Only events by this actor (client IP or cli:<user>)")
+	resource := flagSet.String("resource", "", "Only events whose resource contains this text")
+	outcome := flagSet.String("outcome", "", "Only events with this outcome (success or failure)")
+	since := flagSet.String("since", "", "Only events newer than a duration (e.g. 24h) or RFC 3339 time")
+	until := flagSet.String("until", "", "Only events older than a duration or RFC 3339 time")
+	limit := flagSet.Int("limit", 50, "Show at most the N most recent events (0 = all)")
+	verify := flagSet.Bool("verify", false, "Verify the hash chain instead of listing events")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("no audit log file configured; set security.audit_log_file or pass -file")
+	}
+
+	result := AuditQueryResult{File: *file}
+
+	if *verify {
+		verification, err := logging.VerifyAuditLog(*file, []byte(os.Getenv(logging.AuditKeyEnv)))
+		if err != nil {
+			return fmt.Errorf("failed to verify audit log: %w", err)
+		}
+		result.Verification = verification
+
+		if jsonOutput {
+			util.PrintJSONSuccess(result)
+		} else if quiet {
+			fmt.Println(verification.Valid)
+		} else {
+			fmt.Printf("Audit log: %s (%d files, %d entries)\n", *file, verification.Files, verification.Entries)
+			if verification.Entries > 0 {
+				fmt.Printf("Sequence: %d-%d\n", verification.FirstSeq, verification.LastSeq)
+			}
+			if verification.Valid {
+				fmt.Println("Hash chain: OK")
+			} else {
+				fmt.Printf("Hash chain: BROKEN\n  %s\n", verification.Problem)
+			}
+		}
+
+		if !verification.Valid {
+			return fmt.Errorf("audit log failed verification")
+		}
+		return nil
+	}
+
+	query := logging.AuditQuery{
+		Actor:    *actor,
+		Resource: *resource,
+		Outcome:  *outcome,
+		Limit:    *limit,
+	}
+	if *types != "" {
+		for _, t := range strings.Split(*types, ",") {
+			query.Types = append(query.Types, logging.AuditEventType(strings.TrimSpace(t)))
+		}
+	}
+
+	var err error
+	if query.Since, err = parseAuditTime(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if query.Until, err = parseAuditTime(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	result.Events, err = logging.QueryAuditLog(*file, query)
+	if err != nil {
+		return fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+
+	if len(result.Events) == 0 {
+		if !quiet {
+			fmt.Println("No matching audit events")
+		}
+		return nil
+	}
+
+	for _, event := range result.Events {
+		if quiet {
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", event.Seq, event.Timestamp.Format(time.RFC3339), event.Type, event.Actor, event.Resource, event.Outcome)
+			continue
+		}
+
+		fmt.Printf("#%d  %s  %-13s %-7s %s", event.Seq, event.Timestamp.Local().Format("2006-01-02 15:04:05"), event.Type, event.Outcome, event.Actor)
+		if event.Resource != "" {
+			fmt.Printf("  %s", event.Resource)
+		}
+		fmt.Println()
+		for key, value := range event.Details {
+			fmt.Printf("      %s: %s\n", key, value)
+		}
+	}
+
+	return nil
+}
+
+// parseAuditTime parses a relative duration ("24h") or an RFC 3339 timestamp
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
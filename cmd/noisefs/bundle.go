@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// bundlePasswordEnv holds the password used to read encrypted descriptors
+// during export, so it doesn't end up in shell history
+const bundlePasswordEnv = "NOISEFS_DESCRIPTOR_PASSWORD"
+
+// exportBundleCommand writes descriptors and their blocks to a bundle file
+// for offline transfer
+func exportBundleCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("export-bundle", flag.ContinueOnError)
+	output := flagSet.String("o", "", "Bundle file to write (required)")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs export-bundle -o bundle.zip <descriptor-cid>...")
+		fmt.Fprintf(flagSet.Output(), "Encrypted descriptors are read with the password in $%s.\n", bundlePasswordEnv)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" || flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return fmt.Errorf("an output file and at least one descriptor CID are required")
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+
+	manifest, err := descriptors.ExportBundle(context.Background(), storageManager, file, flagSet.Args(), os.Getenv(bundlePasswordEnv))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Don't leave a partial bundle that looks usable
+		os.Remove(*output)
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{
+			"bundle":   *output,
+			"manifest": manifest,
+		})
+		return nil
+	}
+	if quiet {
+		fmt.Println(*output)
+		return nil
+	}
+
+	fmt.Printf("Exported %d descriptors to %s\n", len(manifest.Descriptors), *output)
+	for _, descriptor := range manifest.Descriptors {
+		fmt.Printf("  %s  %s (%s, %d blocks)\n", descriptor.CID, descriptor.Filename, util.FormatSize(descriptor.FileSize), descriptor.Blocks)
+	}
+	fmt.Printf("Blocks: %d (%s)\n", manifest.BlockCount, util.FormatSize(manifest.TotalBytes))
+	return nil
+}
+
+// importBundleCommand stores the blocks of a bundle in the local backend
+func importBundleCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("import-bundle", flag.ContinueOnError)
+	pin := flagSet.Bool("pin", true, "Pin imported blocks so they aren't garbage collected")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs import-bundle [-pin=false] <bundle.zip>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one bundle file is required")
+	}
+
+	file, err := os.Open(flagSet.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	result, err := descriptors.ImportBundle(context.Background(), storageManager, file, info.Size(), *pin)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+	if quiet {
+		for _, descriptor := range result.Descriptors {
+			fmt.Println(descriptor.CID)
+		}
+		return nil
+	}
+
+	fmt.Printf("Imported %d blocks (%s), %d already present\n", result.Imported, util.FormatSize(result.Bytes), result.Existing)
+	fmt.Printf("Descriptors now available:\n")
+	for _, descriptor := range result.Descriptors {
+		fmt.Printf("  %s  %s (%s)\n", descriptor.CID, descriptor.Filename, util.FormatSize(descriptor.FileSize))
+	}
+	return nil
+}
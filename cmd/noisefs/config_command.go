@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// ConfigValidateResult is the output of config validate
+type ConfigValidateResult struct {
+	File           string   `json:"file"`
+	Valid          bool     `json:"valid"`
+	ReloadableKeys []string `json:"reloadable_keys"`
+}
+
+// configCommand handles configuration subcommands
+func configCommand(args []string, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("usage: config validate [-config file] [file]")
+	}
+
+	flagSet := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	configFile := flagSet.String("config", "", "Configuration file to validate")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	path := *configFile
+	if flagSet.NArg() > 0 {
+		path = flagSet.Arg(0)
+	}
+	if path == "" {
+		defaultPath, err := config.GetDefaultConfigPath()
+		if err != nil {
+			return fmt.Errorf("failed to determine default config path: %w", err)
+		}
+		path = defaultPath
+	}
+
+	if _, err := config.ValidateFile(path); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	result := ConfigValidateResult{
+		File:           path,
+		Valid:          true,
+		ReloadableKeys: config.ReloadableKeys,
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+	} else if quiet {
+		fmt.Println(result.Valid)
+	} else {
+		fmt.Printf("Configuration OK: %s\n", path)
+		fmt.Printf("Keys applied on reload (SIGHUP or file change): %v\n", result.ReloadableKeys)
+	}
+
+	return nil
+}
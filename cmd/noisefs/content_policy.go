@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+// loadContentPolicy returns the configured upload policy, or a permissive
+// policy when no policy file is configured
+func loadContentPolicy(cfg *config.Config) (*validation.ContentPolicy, error) {
+	if cfg == nil || cfg.Security.ContentPolicyFile == "" {
+		return validation.DefaultContentPolicy(), nil
+	}
+
+	policy, err := validation.LoadContentPolicy(cfg.Security.ContentPolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content policy: %w", err)
+	}
+	return policy, nil
+}
+
+// enforceContentPolicy sniffs a file and rejects it if the policy forbids it
+func enforceContentPolicy(cfg *config.Config, filePath string) error {
+	policy, err := loadContentPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := policy.CheckFile(filePath); err != nil {
+		return fmt.Errorf("upload rejected by content policy: %w", err)
+	}
+	return nil
+}
+
+// contentPolicyFilter returns a directory processor filter that skips files
+// rejected by the policy, logging each skipped file
+func contentPolicyFilter(policy *validation.ContentPolicy, quiet bool, logger *logging.Logger) func(path string) bool {
+	return func(path string) bool {
+		contentType, err := policy.CheckFile(path)
+		if err == nil {
+			return true
+		}
+
+		logger.Warn("Skipping file rejected by content policy", map[string]interface{}{
+			"path":         path,
+			"content_type": contentType,
+			"reason":       err.Error(),
+		})
+		if !quiet {
+			fmt.Printf("Skipping %s: %v\n", path, err)
+		}
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// configureDeduplication makes the client skip uploads of files uploaded
+// before and reuse the stored blocks of earlier uploads, as configured
+func configureDeduplication(client *noisefs.Client, cfg *config.Config) error {
+	if cfg.Dedup.Enabled {
+		uploads, err := noisefs.OpenUploadIndex(cfg.Dedup.IndexPath, cfg.Dedup.Bloom)
+		if err != nil {
+			return fmt.Errorf("failed to open upload index: %w", err)
+		}
+		client.SetUploadIndex(uploads)
+	}
+	if cfg.Dedup.Blocks {
+		blockIndex, err := noisefs.OpenBlockIndex(cfg.Dedup.BlockIndexPath)
+		if err != nil {
+			return fmt.Errorf("failed to open block index: %w", err)
+		}
+		client.SetBlockIndex(blockIndex)
+	}
+	return nil
+}
+
+// uploadKey hashes the processed file at path, uploaded as filename, for
+// deduplication. It reports false when deduplication is disabled or the
+// file can't be read, in which case the upload goes ahead and reports the
+// error itself.
+func uploadKey(cfg *config.Config, path string, filename string, blockSize int) (noisefs.UploadKey, bool) {
+	if !cfg.Dedup.Enabled {
+		return noisefs.UploadKey{}, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return noisefs.UploadKey{}, false
+	}
+	defer file.Close()
+	key, err := noisefs.NewUploadKey(file, filename, blockSize)
+	if err != nil {
+		return noisefs.UploadKey{}, false
+	}
+	return key, true
+}
+
+// printDeduplicatedUpload reports an upload answered with the descriptor
+// of an earlier upload of the file
+func printDeduplicatedUpload(key noisefs.UploadKey, descriptorCID string, quiet bool, jsonOutput bool) {
+	if jsonOutput {
+		util.PrintJSONSuccess(util.UploadResult{
+			DescriptorCID: descriptorCID,
+			Filename:      key.Filename,
+			FileSize:      key.Size,
+			BlockCount:    int((key.Size + int64(key.BlockSize) - 1) / int64(key.BlockSize)),
+			BlockSize:     key.BlockSize,
+			Deduplicated:  true,
+		})
+	} else if quiet {
+		fmt.Println(descriptorCID)
+	} else {
+		fmt.Println("\nAlready uploaded; nothing was stored.")
+		fmt.Printf("Descriptor CID: %s\n", descriptorCID)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,16 +31,25 @@ func discoverCommand(args []string, quiet bool, jsonOutput bool) error {
 		category = flagSet.String("category", "", "Filter by category (video/audio/document/etc)")
 		expand   = flagSet.Bool("expand", true, "Expand query tags to include related tags")
 		ranked   = flagSet.Bool("ranked", false, "Rank results by tag match score")
+		recent   = flagSet.Bool("recent", false, "List recent announcements instead of the topic tree")
+		all      = flagSet.Bool("all", false, "Show known topics without announcements in the tree")
+		noBrowse = flagSet.Bool("no-browse", false, "Print the topic tree instead of browsing it on a terminal")
 		help     = flagSet.Bool("help", false, "Show help for discover command")
 	)
 
 	flagSet.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: noisefs discover [options]\n\n")
-		fmt.Fprintf(os.Stderr, "Discover announcements from subscribed topics.\n\n")
+		fmt.Fprintf(os.Stderr, "Browse the topic tree with the announcements received for each topic, or\n")
+		fmt.Fprintf(os.Stderr, "list announcements from subscribed topics. On a terminal the tree can be\n")
+		fmt.Fprintf(os.Stderr, "explored interactively, down to the announcements of a topic.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flagSet.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
-		fmt.Fprintf(os.Stderr, "  noisefs discover                          # Show recent announcements\n")
+		fmt.Fprintf(os.Stderr, "  noisefs discover                          # Browse topics with announcements in the last day\n")
+		fmt.Fprintf(os.Stderr, "  noisefs discover --since 168h --all       # Every known topic, counting the last week\n")
+		fmt.Fprintf(os.Stderr, "  noisefs discover --topic media/movies     # Subtopics and announcements of a topic\n")
+		fmt.Fprintf(os.Stderr, "  noisefs discover --recent                 # List recent announcements\n")
+		fmt.Fprintf(os.Stderr, "  noisefs discover --json                   # Topic tree as JSON\n")
 		fmt.Fprintf(os.Stderr, "  noisefs discover --tags \"res:4k,genre:scifi\"  # Filter by tags\n")
 		fmt.Fprintf(os.Stderr, "  noisefs discover --tags \"4k,scifi\" --ranked    # Ranked search\n")
 		fmt.Fprintf(os.Stderr, "  noisefs discover --category video         # Show only videos\n")
@@ -60,6 +73,75 @@ func discoverCommand(args []string, quiet bool, jsonOutput bool) error {
 	}
 	defer annStore.Close()
 
+	// Load subscriptions to show topic names
+	configPath := filepath.Join(config.GetConfigDir(), "subscriptions.json")
+	subConfig, _ := config.LoadSubscriptions(configPath)
+
+	// Create topic map
+	topicMap := make(map[string]string)
+	var subscribed []string
+	if subConfig != nil {
+		for _, sub := range subConfig.GetAll() {
+			topicMap[sub.TopicHash] = sub.Topic
+			subscribed = append(subscribed, sub.Topic)
+		}
+	}
+
+	// Without filters, show the topic tree
+	if *tags == "" && *category == "" && !*recent {
+		tree := annStore.TopicTree(subscribed, time.Now().Add(-*since))
+		if !*all {
+			tree.Prune(subscribed)
+		}
+		browser := &topicBrowser{
+			tree:       tree,
+			store:      annStore,
+			topicMap:   topicMap,
+			subscribed: make(map[string]bool),
+			limit:      *limit,
+			since:      *since,
+		}
+		for _, topic := range subscribed {
+			browser.subscribed[announce.HashTopic(topic)] = true
+		}
+		nameTopics(tree.Root, topicMap)
+
+		node := tree.Root
+		if *topic != "" {
+			if node = tree.Find(*topic); node == nil {
+				// A topic outside the common hierarchy that isn't subscribed
+				// still has its announcements
+				node = &store.TopicTreeNode{Name: *topic, Path: *topic, Hash: announce.HashTopic(*topic)}
+			}
+		}
+
+		if jsonOutput {
+			if *topic == "" {
+				util.PrintJSON(tree)
+				return nil
+			}
+			announcements := browser.announcements(node)
+			util.PrintJSON(map[string]interface{}{
+				"topic":         node,
+				"announcements": announcementsJSON(announcements, topicMap),
+				"count":         len(announcements),
+			})
+			return nil
+		}
+
+		if !*noBrowse && !quiet && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+			return browser.browse(node, os.Stdin, os.Stdout)
+		}
+		if node == tree.Root {
+			browser.printTree(os.Stdout)
+			return nil
+		}
+		browser.printTopic(os.Stdout, node)
+		fmt.Println()
+		printAnnouncements(os.Stdout, browser.announcements(node), topicMap)
+		return nil
+	}
+
 	// Get announcements based on filters
 	var announcements []*store.StoredAnnouncement
 
@@ -108,93 +190,95 @@ func discoverCommand(args []string, quiet bool, jsonOutput bool) error {
 		announcements = filtered
 	}
 
-	// Load subscriptions to show topic names
-	configPath := filepath.Join(config.GetConfigDir(), "subscriptions.json")
-	subConfig, _ := config.LoadSubscriptions(configPath)
-
-	// Create topic map
-	topicMap := make(map[string]string)
-	if subConfig != nil {
-		for _, sub := range subConfig.GetAll() {
-			topicMap[sub.TopicHash] = sub.Topic
-		}
-	}
-
 	// Output results
 	if jsonOutput {
-		results := []map[string]interface{}{}
-		for _, ann := range announcements {
-			result := map[string]interface{}{
-				"descriptor":  ann.Descriptor,
-				"topic_hash":  ann.TopicHash,
-				"category":    ann.Category,
-				"size_class":  ann.SizeClass,
-				"timestamp":   ann.Timestamp,
-				"received_at": ann.ReceivedAt,
-				"source":      ann.Source,
-			}
-			if topic, ok := topicMap[ann.TopicHash]; ok {
-				result["topic"] = topic
-			}
-			results = append(results, result)
-		}
 		util.PrintJSON(map[string]interface{}{
-			"announcements": results,
-			"count":         len(results),
+			"announcements": announcementsJSON(announcements, topicMap),
+			"count":         len(announcements),
 		})
 		return nil
 	}
 
 	// Text output
-	if len(announcements) == 0 {
-		fmt.Println("No announcements found")
+	if !printAnnouncements(os.Stdout, announcements, topicMap) {
 		return nil
 	}
 
-	fmt.Printf("Found %d announcements:\n\n", len(announcements))
+	// Show stats if not quiet
+	if !quiet {
+		total, byTopic, expired := annStore.GetStats()
+		fmt.Printf("Store stats: %d total, %d expired, %d topics\n", total, expired, len(byTopic))
+	}
+
+	return nil
+}
+
+// announcementsJSON converts announcements for JSON output
+func announcementsJSON(announcements []*store.StoredAnnouncement, topicMap map[string]string) []map[string]interface{} {
+	results := []map[string]interface{}{}
+	for _, ann := range announcements {
+		result := map[string]interface{}{
+			"descriptor":  ann.Descriptor,
+			"topic_hash":  ann.TopicHash,
+			"category":    ann.Category,
+			"size_class":  ann.SizeClass,
+			"timestamp":   ann.Timestamp,
+			"received_at": ann.ReceivedAt,
+			"source":      ann.Source,
+		}
+		if topic, ok := topicMap[ann.TopicHash]; ok {
+			result["topic"] = topic
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// printAnnouncements prints a numbered list of announcements, and reports
+// whether there were any
+func printAnnouncements(w io.Writer, announcements []*store.StoredAnnouncement, topicMap map[string]string) bool {
+	if len(announcements) == 0 {
+		fmt.Fprintln(w, "No announcements found")
+		return false
+	}
+
+	fmt.Fprintf(w, "Found %d announcements:\n\n", len(announcements))
 
 	for i, ann := range announcements {
-		fmt.Printf("%d. Descriptor: %s\n", i+1, ann.Descriptor)
+		fmt.Fprintf(w, "%d. Descriptor: %s\n", i+1, ann.Descriptor)
 
 		// Show topic if known
 		if topic, ok := topicMap[ann.TopicHash]; ok {
-			fmt.Printf("   Topic: %s\n", topic)
+			fmt.Fprintf(w, "   Topic: %s\n", topic)
 		} else {
-			fmt.Printf("   Topic hash: %s...\n", ann.TopicHash[:16])
+			fmt.Fprintf(w, "   Topic hash: %s...\n", ann.TopicHash[:16])
 		}
 
-		fmt.Printf("   Category: %s, Size: %s\n", ann.Category, ann.SizeClass)
+		fmt.Fprintf(w, "   Category: %s, Size: %s\n", ann.Category, ann.SizeClass)
 
 		// Show age
 		age := time.Since(ann.ReceivedAt)
-		fmt.Printf("   Received: %s ago", formatDuration(age))
+		fmt.Fprintf(w, "   Received: %s ago", formatDuration(age))
 
 		// Show source
 		if ann.Source != "" {
-			fmt.Printf(" (via %s)", ann.Source)
+			fmt.Fprintf(w, " (via %s)", ann.Source)
 		}
-		fmt.Println()
+		fmt.Fprintln(w)
 
 		// Check if expired
 		if ann.IsExpired() {
-			fmt.Println("   Status: Expired")
+			fmt.Fprintln(w, "   Status: Expired")
 		} else {
 			// Calculate time until expiration
 			expiryTime := time.Unix(ann.Timestamp, 0).Add(time.Duration(ann.TTL) * time.Second)
 			remaining := time.Until(expiryTime)
-			fmt.Printf("   Expires in: %s\n", formatDuration(remaining))
+			fmt.Fprintf(w, "   Expires in: %s\n", formatDuration(remaining))
 		}
 
-		fmt.Println()
-	}
-
-	// Show stats if not quiet
-	if !quiet {
-		total, byTopic, expired := annStore.GetStats()
-		fmt.Printf("Store stats: %d total, %d expired, %d topics\n", total, expired, len(byTopic))
+		fmt.Fprintln(w)
 	}
-
-	return nil
+	return true
 }
 
 // formatDuration formats a duration in a human-readable way
@@ -219,3 +303,168 @@ func formatDuration(d time.Duration) string {
 func expandQueryTags(queryTags []string) []string {
 	return tags.ExpandQuery(queryTags)
 }
+
+// topicBrowser shows the topic tree and the announcements of its topics
+type topicBrowser struct {
+	tree       *store.TopicTree
+	store      *store.Store
+	topicMap   map[string]string
+	subscribed map[string]bool // Topic hashes
+	limit      int
+	since      time.Duration
+}
+
+// announcements returns the newest stored announcements of a topic
+func (b *topicBrowser) announcements(node *store.TopicTreeNode) []*store.StoredAnnouncement {
+	announcements, err := b.store.GetByTopic(node.Hash)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(announcements, func(i, j int) bool {
+		return announcements[i].ReceivedAt.After(announcements[j].ReceivedAt)
+	})
+	if b.limit > 0 && len(announcements) > b.limit {
+		announcements = announcements[:b.limit]
+	}
+	return announcements
+}
+
+// label describes a topic and its announcement counts on one line
+func (b *topicBrowser) label(node *store.TopicTreeNode) string {
+	name := node.Name
+	if node.Path == "" {
+		name = "#" + node.Hash[:16]
+	}
+	return name + " " + b.counts(node)
+}
+
+// counts describes a topic's announcement counts
+func (b *topicBrowser) counts(node *store.TopicTreeNode) string {
+	counts := fmt.Sprintf("%d recent", node.Total)
+	if node.Recent > 0 && node.Total != node.Recent {
+		counts = fmt.Sprintf("%d recent, %d here", node.Total, node.Recent)
+	}
+	if node.Stored > 0 {
+		counts += fmt.Sprintf(", %d stored", node.Stored)
+	}
+	counts = "(" + counts + ")"
+	if b.subscribed[node.Hash] {
+		counts += " [subscribed]"
+	}
+	return counts
+}
+
+// printTree prints the whole tree, with topics the store has seen only by
+// hash at the end
+func (b *topicBrowser) printTree(w io.Writer) {
+	fmt.Fprintf(w, "Topics with announcements in the last %s:\n\n", formatDuration(b.since))
+	if len(b.tree.Root.Children) == 0 && len(b.tree.Unnamed) == 0 {
+		fmt.Fprintln(w, "No announcements found")
+		return
+	}
+	b.printChildren(w, b.tree.Root, "")
+	if len(b.tree.Unnamed) > 0 {
+		fmt.Fprintf(w, "\nTopics not subscribed to, by hash:\n")
+		for _, node := range b.tree.Unnamed {
+			fmt.Fprintf(w, "  %s\n", b.label(node))
+		}
+	}
+}
+
+// printTopic prints a topic and its subtopics
+func (b *topicBrowser) printTopic(w io.Writer, node *store.TopicTreeNode) {
+	fmt.Fprintln(w, b.label(node))
+	b.printChildren(w, node, "")
+}
+
+func (b *topicBrowser) printChildren(w io.Writer, node *store.TopicTreeNode, prefix string) {
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, b.label(child))
+		b.printChildren(w, child, prefix+indent)
+	}
+}
+
+// browse lets the user walk the tree from a topic, one level at a time,
+// and list the announcements of any topic
+func (b *topicBrowser) browse(start *store.TopicTreeNode, in io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(in)
+	path := []*store.TopicTreeNode{start}
+	for {
+		node := path[len(path)-1]
+		entries := node.Children
+		if node == b.tree.Root {
+			entries = append(append([]*store.TopicTreeNode{}, entries...), b.tree.Unnamed...)
+		}
+
+		fmt.Fprintln(w)
+		if node == b.tree.Root {
+			fmt.Fprintf(w, "All topics, announcements in the last %s\n", formatDuration(b.since))
+		} else {
+			fmt.Fprintln(w, node.Path+" "+b.counts(node))
+		}
+		for i, entry := range entries {
+			fmt.Fprintf(w, "  %2d. %s\n", i+1, b.label(entry))
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(w, "  No subtopics")
+		}
+
+		fmt.Fprint(w, "\nNumber to open, a to list announcements, .. to go up, q to quit: ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(w)
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch choice := strings.TrimSpace(line); choice {
+		case "q", "quit", "exit":
+			return nil
+		case "..", "u", "up":
+			if len(path) > 1 {
+				path = path[:len(path)-1]
+			}
+		case "a":
+			fmt.Fprintln(w)
+			printAnnouncements(w, b.announcements(node), b.topicMap)
+		case "":
+		default:
+			n, err := strconv.Atoi(choice)
+			if err != nil || n < 1 || n > len(entries) {
+				fmt.Fprintf(w, "No topic %q\n", choice)
+				continue
+			}
+			entry := entries[n-1]
+			if len(entry.Children) == 0 {
+				// Leaves have nothing to open but their announcements
+				fmt.Fprintf(w, "\n%s\n", b.label(entry))
+				printAnnouncements(w, b.announcements(entry), b.topicMap)
+				continue
+			}
+			path = append(path, entry)
+		}
+	}
+}
+
+// nameTopics adds the paths of the tree's topics to topicMap
+func nameTopics(node *store.TopicTreeNode, topicMap map[string]string) {
+	if node.Path != "" {
+		topicMap[node.Hash] = node.Path
+	}
+	for _, child := range node.Children {
+		nameTopics(child, topicMap)
+	}
+}
+
+// isTerminal reports whether a file is a terminal rather than a pipe or
+// regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
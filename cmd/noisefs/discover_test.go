@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
+)
+
+func TestTopicBrowser(t *testing.T) {
+	annStore, err := store.NewStore(store.DefaultStoreConfig(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer annStore.Close()
+	ann := &announce.Announcement{
+		Version:    "1.0",
+		Descriptor: "QmScifiDescriptor",
+		TopicHash:  announce.HashTopic("media/movies/scifi"),
+		Category:   announce.CategoryVideo,
+		SizeClass:  announce.SizeClassLarge,
+		Timestamp:  time.Now().Unix(),
+		TTL:        3600,
+		Nonce:      "nonce",
+	}
+	if err := annStore.Add(ann, "dht"); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := annStore.TopicTree(nil, time.Now().Add(-time.Hour))
+	tree.Prune(nil)
+	topicMap := make(map[string]string)
+	nameTopics(tree.Root, topicMap)
+	browser := &topicBrowser{tree: tree, store: annStore, topicMap: topicMap, subscribed: map[string]bool{}, limit: 10, since: time.Hour}
+
+	var printed bytes.Buffer
+	browser.printTree(&printed)
+	want := "└── media (1 recent)\n    └── movies (1 recent)\n        └── scifi (1 recent, 1 stored)\n"
+	if !strings.Contains(printed.String(), want) {
+		t.Errorf("Expected the tree to contain\n%s\ngot\n%s", want, printed.String())
+	}
+
+	// Open media, then movies, then the scifi leaf, go back up and quit
+	var out bytes.Buffer
+	if err := browser.browse(tree.Root, strings.NewReader("1\n1\n1\n..\n7\nq\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	output := out.String()
+	for _, expected := range []string{
+		"media/movies (1 recent)",
+		"1. Descriptor: QmScifiDescriptor",
+		"Topic: media/movies/scifi",
+		`No topic "7"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in\n%s", expected, output)
+		}
+	}
+
+	// Input ending without q quits as well
+	if err := browser.browse(tree.Root, strings.NewReader("a\n"), &bytes.Buffer{}); err != nil {
+		t.Errorf("Expected the end of input to quit, got %v", err)
+	}
+}
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+	"github.com/TheEntropyCollective/noisefs/pkg/network/proxy"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// Doctor check outcomes
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+const (
+	// minIPFSVersion is the oldest Kubo release with the routing commands
+	// the IPFS backend uses
+	minIPFSVersion = "0.14.0"
+
+	// Clock skew beyond these breaks announcement expiry and TLS
+	// certificate checks
+	warnClockSkew = time.Minute
+	failClockSkew = 5 * time.Minute
+
+	// Free space needed next to the index, web UI data and embedded repo
+	warnDiskSpace = 1 << 30
+	failDiskSpace = 100 << 20
+)
+
+// DoctorCheck is the outcome of one diagnostic check
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// DoctorReport is the output of noisefs doctor
+type DoctorReport struct {
+	Checks   []DoctorCheck `json:"checks"`
+	Failed   int           `json:"failed"`
+	Warnings int           `json:"warnings"`
+}
+
+func (r *DoctorReport) add(checks ...DoctorCheck) {
+	for _, check := range checks {
+		switch check.Status {
+		case checkFail:
+			r.Failed++
+		case checkWarn:
+			r.Warnings++
+		}
+		r.Checks = append(r.Checks, check)
+	}
+}
+
+// doctorCommand checks the local setup and prints what is wrong and how to
+// fix it. It reports whether every check passed.
+func doctorCommand(args []string, quiet bool, jsonOutput bool) bool {
+	flagSet := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configFile := flagSet.String("config", "", "Configuration file path")
+	ipfsAPI := flagSet.String("api", "", "IPFS API endpoint (overrides config)")
+	timeout := flagSet.Duration("timeout", 10*time.Second, "Timeout for network checks")
+	timeURL := flagSet.String("time-url", "", "HTTPS server whose clock to compare with (default: the IPFS API if remote)")
+	flagSet.Bool("quiet", false, "Only show problems")
+	flagSet.Bool("json", false, "Output the report in JSON format")
+	if err := flagSet.Parse(args); err != nil {
+		if jsonOutput {
+			util.PrintJSONError(err)
+		}
+		return false
+	}
+
+	report := &DoctorReport{}
+	cfg, check := checkConfig(*configFile)
+	report.add(check)
+	if *ipfsAPI != "" {
+		cfg.IPFS.APIEndpoint = *ipfsAPI
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var remoteDate time.Time
+	if cfg.IPFS.Mode == config.IPFSModeEmbedded {
+		report.add(DoctorCheck{
+			Name:    "IPFS connectivity",
+			Status:  checkOK,
+			Message: "using the embedded IPFS node, no daemon needed",
+		})
+	} else {
+		var checks []DoctorCheck
+		checks, remoteDate = checkIPFS(ctx, cfg)
+		report.add(checks...)
+	}
+	report.add(checkClock(ctx, cfg, *timeURL, remoteDate))
+	report.add(checkDaemonVersion(cfg))
+	report.add(checkFUSE())
+	report.add(checkDiskSpace(cfg)...)
+	report.add(checkPorts(cfg)...)
+
+	if jsonOutput {
+		util.PrintJSONSuccess(report)
+	} else {
+		printDoctorReport(report, quiet)
+	}
+	return report.Failed == 0
+}
+
+func printDoctorReport(report *DoctorReport, quiet bool) {
+	labels := map[string]string{checkOK: "[ OK ]", checkWarn: "[WARN]", checkFail: "[FAIL]", checkSkip: "[SKIP]"}
+	for _, check := range report.Checks {
+		if quiet && (check.Status == checkOK || check.Status == checkSkip) {
+			continue
+		}
+		fmt.Printf("%s %s: %s\n", labels[check.Status], check.Name, check.Message)
+		if check.Fix != "" {
+			fmt.Printf("       Fix: %s\n", check.Fix)
+		}
+	}
+	if quiet {
+		return
+	}
+
+	switch {
+	case report.Failed > 0:
+		fmt.Printf("\n%d problem(s) and %d warning(s) found\n", report.Failed, report.Warnings)
+	case report.Warnings > 0:
+		fmt.Printf("\nNo problems, %d warning(s)\n", report.Warnings)
+	default:
+		fmt.Println("\nEverything looks good")
+	}
+}
+
+// checkConfig validates the configuration file strictly and loads it with
+// environment overrides. Later checks use the defaults if it is invalid.
+func checkConfig(configFile string) (*config.Config, DoctorCheck) {
+	check := DoctorCheck{Name: "Configuration"}
+	path := config.ResolveConfigPath(configFile)
+
+	if _, err := os.Stat(path); err == nil {
+		if _, err := config.ValidateFile(path); err != nil {
+			check.Status = checkFail
+			check.Message = fmt.Sprintf("%s: %v", path, err)
+			check.Fix = "Correct the file; `noisefs config validate` rechecks it. Later checks use the defaults."
+			return config.DefaultConfig(), check
+		}
+	} else if configFile != "" {
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%s: %v", path, err)
+		check.Fix = "Check the -config path. Later checks use the defaults."
+		return config.DefaultConfig(), check
+	}
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		check.Status = checkFail
+		check.Message = err.Error()
+		check.Fix = "Check NOISEFS_* environment variables and secret references. Later checks use the defaults."
+		return config.DefaultConfig(), check
+	}
+
+	check.Status = checkOK
+	if _, err := os.Stat(path); err != nil {
+		check.Message = fmt.Sprintf("no file at %s, using defaults", path)
+	} else {
+		check.Message = fmt.Sprintf("%s is valid", path)
+	}
+	return cfg, check
+}
+
+// checkIPFS checks that the daemon answers, that its version is supported
+// and that peers can reach it. It returns the time the daemon reported, to
+// compare clocks when it runs elsewhere.
+func checkIPFS(ctx context.Context, cfg *config.Config) ([]DoctorCheck, time.Time) {
+	connectivity := DoctorCheck{Name: "IPFS connectivity"}
+	version := DoctorCheck{Name: "IPFS version", Status: checkSkip, Message: "daemon not reachable"}
+	reachability := DoctorCheck{Name: "Swarm reachability", Status: checkSkip, Message: "daemon not reachable"}
+	checks := func() []DoctorCheck { return []DoctorCheck{connectivity, version, reachability} }
+
+	conn := cfg.IPFSConnection()
+	client, endpoint, err := backends.NewIPFSHTTPClient(conn)
+	if err != nil {
+		connectivity.Status = checkFail
+		connectivity.Message = err.Error()
+		connectivity.Fix = "Check the ipfs TLS and authentication settings"
+		return checks(), time.Time{}
+	}
+	apiURL, err := httpURL(endpoint)
+	if err != nil {
+		connectivity.Status = checkFail
+		connectivity.Message = err.Error()
+		connectivity.Fix = "Set ipfs.api_endpoint to host:port or an http(s) URL"
+		return checks(), time.Time{}
+	}
+
+	var info struct{ Version string }
+	date, err := ipfsRequest(ctx, client, apiURL, "version", &info)
+	if err != nil {
+		connectivity.Status = checkFail
+		connectivity.Message = fmt.Sprintf("%s: %v", apiURL, err)
+		connectivity.Fix = "Start the daemon with `ipfs daemon`, point ipfs.api_endpoint (or -api) at it, or set ipfs.mode to \"embedded\""
+		return checks(), time.Time{}
+	}
+	connectivity.Status = checkOK
+	connectivity.Message = fmt.Sprintf("daemon answering at %s", apiURL)
+
+	version.Status, version.Message = checkOK, fmt.Sprintf("Kubo %s", info.Version)
+	if older, ok := versionOlder(info.Version, minIPFSVersion); !ok {
+		version.Status = checkWarn
+		version.Message = fmt.Sprintf("cannot parse version %q", info.Version)
+	} else if older {
+		version.Status = checkFail
+		version.Message = fmt.Sprintf("Kubo %s is older than %s", info.Version, minIPFSVersion)
+		version.Fix = "Upgrade the IPFS daemon; provider lookups and statistics need newer API commands"
+	}
+
+	var id struct{ Addresses []string }
+	if _, err := ipfsRequest(ctx, client, apiURL, "id", &id); err != nil {
+		reachability.Status = checkWarn
+		reachability.Message = fmt.Sprintf("cannot read the daemon's addresses: %v", err)
+	} else {
+		reachability = checkSwarmAddresses(id.Addresses)
+	}
+
+	return checks(), date
+}
+
+// ipfsRequest calls an IPFS API command and returns the time reported in
+// the response's Date header
+func ipfsRequest(ctx context.Context, client *http.Client, apiURL string, command string, out interface{}) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/api/v0/"+command, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("API returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return time.Time{}, fmt.Errorf("unexpected response: %w", err)
+	}
+	date, _ := http.ParseTime(resp.Header.Get("Date"))
+	return date, nil
+}
+
+// httpURL turns an API endpoint (host:port, URL or TCP multiaddr) into a
+// base URL
+func httpURL(endpoint string) (string, error) {
+	if strings.HasPrefix(endpoint, "/") {
+		addr, err := ma.NewMultiaddr(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid API multiaddr %s: %w", endpoint, err)
+		}
+		netAddr, err := manet.ToNetAddr(addr)
+		if err != nil {
+			return "", fmt.Errorf("unsupported API multiaddr %s: %w", endpoint, err)
+		}
+		endpoint = netAddr.String()
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/"), nil
+}
+
+// checkSwarmAddresses looks for an address peers outside the local network
+// can dial
+func checkSwarmAddresses(addresses []string) DoctorCheck {
+	check := DoctorCheck{Name: "Swarm reachability"}
+	var relayed bool
+	for _, s := range addresses {
+		addr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			continue
+		}
+		if _, err := addr.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+			relayed = true
+			continue
+		}
+		if manet.IsPublicAddr(addr) {
+			check.Status = checkOK
+			check.Message = fmt.Sprintf("daemon announces public address %s", s)
+			return check
+		}
+	}
+
+	if relayed {
+		check.Status = checkOK
+		check.Message = "daemon is reachable through a relay"
+		return check
+	}
+	check.Status = checkWarn
+	check.Message = "daemon only announces private addresses; peers outside this network cannot connect to it"
+	check.Fix = "Forward the swarm port (4001 TCP and UDP) to this machine or enable the relay client in the daemon's Swarm settings"
+	return check
+}
+
+// versionOlder reports whether version is older than minimum. The second
+// result is false if version cannot be parsed.
+func versionOlder(version, minimum string) (bool, bool) {
+	parse := func(v string) ([3]int, bool) {
+		var parts [3]int
+		v = strings.TrimPrefix(v, "v")
+		if i := strings.IndexAny(v, "-+"); i >= 0 {
+			v = v[:i]
+		}
+		fields := strings.Split(v, ".")
+		if len(fields) < 2 || len(fields) > 3 {
+			return parts, false
+		}
+		for i, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				return parts, false
+			}
+			parts[i] = n
+		}
+		return parts, true
+	}
+
+	have, ok := parse(version)
+	want, _ := parse(minimum)
+	if !ok {
+		return false, false
+	}
+	for i := range have {
+		if have[i] != want[i] {
+			return have[i] < want[i], true
+		}
+	}
+	return false, true
+}
+
+// checkClock compares the local clock with a remote server: the time URL
+// if given, otherwise the IPFS daemon when it runs on another machine
+func checkClock(ctx context.Context, cfg *config.Config, timeURL string, daemonDate time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "Clock skew"}
+	source := "the IPFS daemon"
+	remote := daemonDate
+
+	if timeURL != "" {
+		source = timeURL
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, timeURL, nil)
+		var client *http.Client
+		if err == nil {
+			client, err = proxy.NewHTTPClient(cfg.Network.Proxy, 0)
+		}
+		if err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				resp.Body.Close()
+				remote, _ = http.ParseTime(resp.Header.Get("Date"))
+			}
+		}
+		if err != nil {
+			check.Status = checkWarn
+			check.Message = fmt.Sprintf("cannot reach %s: %v", timeURL, err)
+			return check
+		}
+	} else if cfg.IPFS.Mode == config.IPFSModeEmbedded || isLocalEndpoint(cfg.IPFS.APIEndpoint) {
+		check.Status = checkSkip
+		check.Message = "no remote clock to compare with"
+		check.Fix = "Pass -time-url https://example.com to compare with a trusted server"
+		return check
+	}
+
+	if remote.IsZero() {
+		check.Status = checkSkip
+		check.Message = fmt.Sprintf("%s did not report its time", source)
+		return check
+	}
+
+	skew := time.Since(remote).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	check.Status = checkOK
+	check.Message = fmt.Sprintf("%s off from %s", skew, source)
+	if skew >= warnClockSkew {
+		check.Status = checkWarn
+		if skew >= failClockSkew {
+			check.Status = checkFail
+		}
+		check.Fix = "Enable time synchronization (NTP), e.g. `timedatectl set-ntp true` on Linux"
+	}
+	return check
+}
+
+// isLocalEndpoint reports whether an API endpoint is on this machine
+func isLocalEndpoint(endpoint string) bool {
+	apiURL, err := httpURL(endpoint)
+	if err != nil {
+		return true
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(apiURL, "http://"), "https://")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkDaemonVersion checks that the web UI or mount process answering on
+// the control socket speaks a protocol this CLI understands
+func checkDaemonVersion(cfg *config.Config) DoctorCheck {
+	check := DoctorCheck{Name: "Daemon version"}
+	socket := cfg.Daemon.ControlSocket
+	if socket == "" {
+		check.Status = checkSkip
+		check.Message = "no daemon.control_socket configured"
+		return check
+	}
+	if _, err := os.Stat(socket); err != nil {
+		check.Status = checkSkip
+		check.Message = fmt.Sprintf("no daemon running at %s", socket)
+		return check
+	}
+
+	var daemon version.Info
+	err := control.Call(socket, "version", nil, &daemon)
+	var incompatible *version.IncompatibleError
+	switch {
+	case errors.As(err, &incompatible):
+		check.Status = checkFail
+		check.Message = err.Error()
+		check.Fix = "Install the same NoiseFS version for the CLI, noisefs-webui and noisefs-mount, then restart the daemon"
+		return check
+	case err != nil && strings.Contains(err.Error(), `unknown command "version"`):
+		// Daemons from before version checks still speak protocol 1
+		check.Status = checkWarn
+		check.Message = "the daemon predates version checks"
+		check.Fix = "Restart the daemon with the current NoiseFS version"
+		return check
+	case err != nil:
+		check.Status = checkWarn
+		check.Message = err.Error()
+		check.Fix = "Check that the daemon is running; remove the socket if it exited uncleanly"
+		return check
+	}
+
+	current := version.Current()
+	if daemon.Version != current.Version {
+		check.Status = checkWarn
+		check.Message = fmt.Sprintf("daemon runs NoiseFS %s, this CLI is %s (both speak protocol %d)", daemon.Version, current.Version, current.Protocol)
+		check.Fix = "Restart the daemon after upgrading so both run the same version"
+		return check
+	}
+	check.Status = checkOK
+	check.Message = fmt.Sprintf("daemon runs NoiseFS %s, protocol %d", daemon.Version, daemon.Protocol)
+	return check
+}
+
+// checkFUSE checks that the kernel module and mount helper noisefs-mount
+// relies on are installed
+func checkFUSE() DoctorCheck {
+	check := DoctorCheck{Name: "FUSE"}
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := os.Stat("/dev/fuse"); err != nil {
+			check.Status = checkWarn
+			check.Message = "/dev/fuse not found; noisefs-mount will not work"
+			check.Fix = "Install FUSE (e.g. `sudo apt-get install fuse3`) and load the module with `sudo modprobe fuse`"
+			return check
+		}
+		for _, helper := range []string{"fusermount3", "fusermount"} {
+			if path, err := exec.LookPath(helper); err == nil {
+				check.Status = checkOK
+				check.Message = fmt.Sprintf("/dev/fuse and %s available", path)
+				return check
+			}
+		}
+		check.Status = checkWarn
+		check.Message = "fusermount not found; unprivileged mounts will fail"
+		check.Fix = "Install the FUSE userspace tools (e.g. `sudo apt-get install fuse3`)"
+	case "darwin":
+		for _, path := range []string{"/Library/Filesystems/macfuse.fs", "/Library/Filesystems/osxfuse.fs"} {
+			if _, err := os.Stat(path); err == nil {
+				check.Status = checkOK
+				check.Message = fmt.Sprintf("%s installed", path)
+				return check
+			}
+		}
+		check.Status = checkWarn
+		check.Message = "macFUSE not found; noisefs-mount will not work"
+		check.Fix = "Install macFUSE with `brew install --cask macfuse`"
+	default:
+		check.Status = checkSkip
+		check.Message = fmt.Sprintf("FUSE mounts are not supported on %s", runtime.GOOS)
+	}
+	return check
+}
+
+// checkDiskSpace checks the free space where NoiseFS writes its index, web
+// UI data and embedded IPFS blocks
+func checkDiskSpace(cfg *config.Config) []DoctorCheck {
+	var indexDir string
+	if cfg.FUSE.IndexPath != "" {
+		indexDir = filepath.Dir(cfg.FUSE.IndexPath)
+	}
+	paths := []struct{ name, path string }{
+		{"index", indexDir},
+		{"web UI data", cfg.WebUI.DataDir},
+	}
+	if cfg.IPFS.Mode == config.IPFSModeEmbedded {
+		repo := cfg.IPFS.Embedded.RepoPath
+		if repo == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				repo = filepath.Join(home, ".noisefs", "embedded")
+			}
+		}
+		paths = append(paths, struct{ name, path string }{"embedded IPFS repo", repo})
+	}
+
+	var checks []DoctorCheck
+	for _, p := range paths {
+		check := DoctorCheck{Name: "Disk space (" + p.name + ")"}
+		if p.path == "" {
+			check.Status = checkSkip
+			check.Message = "no path configured"
+			checks = append(checks, check)
+			continue
+		}
+
+		free, err := diskFree(existingParent(p.path))
+		if err != nil {
+			check.Status = checkSkip
+			check.Message = fmt.Sprintf("%s: %v", p.path, err)
+			checks = append(checks, check)
+			continue
+		}
+
+		check.Status = checkOK
+		check.Message = fmt.Sprintf("%s free at %s", formatBytes(int64(free)), p.path)
+		if free < warnDiskSpace {
+			check.Status = checkWarn
+			if free < failDiskSpace {
+				check.Status = checkFail
+			}
+			check.Fix = "Free up space or move the path to a larger disk"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// existingParent returns path or its closest ancestor that exists, since
+// directories are created on first use
+func existingParent(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// checkPorts checks that the web UI and embedded node can listen on their
+// configured addresses
+func checkPorts(cfg *config.Config) []DoctorCheck {
+	var checks []DoctorCheck
+	if cfg.WebUI.Address != "" {
+		check := checkListen("Web UI port", cfg.WebUI.Address)
+		if check.Status == checkWarn {
+			check.Fix = "Expected if noisefs-webui is running; otherwise stop the other program or change webui.address"
+		}
+		checks = append(checks, check)
+	}
+
+	if cfg.IPFS.Mode == config.IPFSModeEmbedded {
+		listenAddrs := cfg.IPFS.Embedded.ListenAddrs
+		if len(listenAddrs) == 0 {
+			listenAddrs = backends.DefaultEmbeddedListenAddrs
+		}
+		for _, s := range listenAddrs {
+			addr, err := ma.NewMultiaddr(s)
+			if err != nil {
+				checks = append(checks, DoctorCheck{Name: "Embedded node port", Status: checkFail, Message: fmt.Sprintf("invalid listen address %s: %v", s, err), Fix: "Correct ipfs.embedded.listen_addrs"})
+				continue
+			}
+			port, err := addr.ValueForProtocol(ma.P_TCP)
+			if err != nil || port == "0" {
+				continue // QUIC and random ports cannot be checked this way
+			}
+			netAddr, err := manet.ToNetAddr(addr)
+			if err != nil {
+				continue
+			}
+			check := checkListen("Embedded node port", netAddr.String())
+			if check.Status == checkWarn {
+				check.Fix = "Expected if a NoiseFS process with the embedded node is running; otherwise change ipfs.embedded.listen_addrs"
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+// checkListen tries to listen on a TCP address
+func checkListen(name, address string) DoctorCheck {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		status := checkFail
+		if errors.Is(err, errAddrInUse) {
+			status = checkWarn
+		}
+		return DoctorCheck{Name: name, Status: status, Message: fmt.Sprintf("cannot listen on %s: %v", address, err)}
+	}
+	listener.Close()
+	return DoctorCheck{Name: name, Status: checkOK, Message: fmt.Sprintf("%s is free", address)}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// errAddrInUse is not detected on this platform, so busy ports report as
+// failures
+var errAddrInUse = errors.New("address in use")
+
+// diskFree is not implemented on this platform
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("free space check not supported on this platform")
+}
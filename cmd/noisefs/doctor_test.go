@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+)
+
+func TestVersionOlder(t *testing.T) {
+	tests := []struct {
+		version string
+		older   bool
+		ok      bool
+	}{
+		{"0.29.0", false, true},
+		{"0.14.0", false, true},
+		{"0.13.1", true, true},
+		{"v0.30.0-rc1", false, true},
+		{"0.9", true, true},
+		{"1.0.0", false, true},
+		{"kubo", false, false},
+	}
+	for _, tt := range tests {
+		older, ok := versionOlder(tt.version, minIPFSVersion)
+		if older != tt.older || ok != tt.ok {
+			t.Errorf("versionOlder(%q) = %v, %v; want %v, %v", tt.version, older, ok, tt.older, tt.ok)
+		}
+	}
+}
+
+func TestCheckIPFS(t *testing.T) {
+	skewed := time.Now().Add(-10 * time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", skewed.UTC().Format(http.TimeFormat))
+		switch r.URL.Path {
+		case "/api/v0/version":
+			json.NewEncoder(w).Encode(map[string]string{"Version": "0.12.2"})
+		case "/api/v0/id":
+			json.NewEncoder(w).Encode(map[string][]string{"Addresses": {
+				"/ip4/127.0.0.1/tcp/4001/p2p/12D3KooWEvHoLMg1HHVwLwxnZsbk7PzAZf3rZ5AqPwLbL1x7LZsN",
+				"/ip4/192.168.1.10/tcp/4001/p2p/12D3KooWEvHoLMg1HHVwLwxnZsbk7PzAZf3rZ5AqPwLbL1x7LZsN",
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.IPFS.APIEndpoint = server.URL
+	checks, date := checkIPFS(context.Background(), cfg)
+
+	want := map[string]string{
+		"IPFS connectivity":  checkOK,
+		"IPFS version":       checkFail,
+		"Swarm reachability": checkWarn,
+	}
+	for _, check := range checks {
+		if check.Status != want[check.Name] {
+			t.Errorf("%s: status %s (%s), want %s", check.Name, check.Status, check.Message, want[check.Name])
+		}
+	}
+
+	// The daemon is local, so its clock is only compared when remote
+	if check := checkClock(context.Background(), cfg, "", date); check.Status != checkSkip {
+		t.Errorf("clock check against a local daemon: status %s, want %s", check.Status, checkSkip)
+	}
+	cfg.IPFS.APIEndpoint = "ipfs.example.com:5001"
+	if check := checkClock(context.Background(), cfg, "", date); check.Status != checkFail {
+		t.Errorf("clock check with 10 minutes skew: status %s (%s), want %s", check.Status, check.Message, checkFail)
+	}
+}
+
+func TestCheckIPFSUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.IPFS.APIEndpoint = server.URL
+	checks, _ := checkIPFS(context.Background(), cfg)
+	if checks[0].Status != checkFail || checks[0].Fix == "" {
+		t.Errorf("connectivity check = %+v, want a failure with a fix", checks[0])
+	}
+	if checks[1].Status != checkSkip {
+		t.Errorf("version check status %s, want %s", checks[1].Status, checkSkip)
+	}
+}
+
+func TestCheckSwarmAddresses(t *testing.T) {
+	check := checkSwarmAddresses([]string{"/ip4/10.0.0.2/tcp/4001", "/ip4/8.8.8.8/udp/4001/quic-v1"})
+	if check.Status != checkOK {
+		t.Errorf("public address: status %s, want %s", check.Status, checkOK)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"ipfs": {"api_endpoint": 5001}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, check := checkConfig(path)
+	if check.Status != checkFail || check.Fix == "" {
+		t.Errorf("invalid config: %+v, want a failure with a fix", check)
+	}
+	if cfg == nil {
+		t.Fatal("invalid config should fall back to the defaults")
+	}
+
+	if _, check := checkConfig(filepath.Join(dir, "missing.json")); check.Status != checkFail {
+		t.Errorf("missing -config file: status %s, want %s", check.Status, checkFail)
+	}
+}
+
+func TestCheckDaemonVersion(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.ControlSocket = filepath.Join(t.TempDir(), "control.sock")
+	if check := checkDaemonVersion(cfg); check.Status != checkSkip {
+		t.Errorf("no daemon: status %s, want %s", check.Status, checkSkip)
+	}
+
+	server := control.NewServer(cfg.Daemon.ControlSocket)
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	if check := checkDaemonVersion(cfg); check.Status != checkOK {
+		t.Errorf("same build: %+v, want ok", check)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.FUSE.IndexPath = filepath.Join(t.TempDir(), "not", "created", "index.json")
+	cfg.WebUI.DataDir = t.TempDir()
+
+	for _, check := range checkDiskSpace(cfg) {
+		if check.Status == checkSkip {
+			t.Errorf("%s skipped: %s", check.Name, check.Message)
+		}
+	}
+}
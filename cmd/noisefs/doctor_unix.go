@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// errAddrInUse is the error for a port another program listens on
+var errAddrInUse error = syscall.EADDRINUSE
+
+// diskFree returns the space available to unprivileged users on the file
+// system holding path
+func diskFree(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// IdentityResult is the output of the identity command
+type IdentityResult struct {
+	PublicKey    string `json:"public_key"`
+	MemberID     string `json:"member_id"`
+	IdentityFile string `json:"identity_file"`
+	Created      bool   `json:"created"`
+}
+
+// GroupShareResult is the output of share-directory for private groups
+type GroupShareResult struct {
+	KeyEnvelopeCID   string    `json:"key_envelope_cid"`
+	SnapshotCID      string    `json:"snapshot_cid"`
+	SnapshotName     string    `json:"snapshot_name,omitempty"`
+	OriginalCID      string    `json:"original_cid,omitempty"`
+	Epoch            uint64    `json:"epoch"`
+	PreviousEnvelope string    `json:"previous_envelope,omitempty"`
+	Members          []string  `json:"members"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// memberList collects repeated --member flags
+type memberList []string
+
+func (m *memberList) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *memberList) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// publicKeys parses every member public key
+func (m memberList) publicKeys() ([][]byte, error) {
+	keys := make([][]byte, 0, len(m))
+	for _, member := range m {
+		key, err := crypto.ParseGroupPublicKey(member)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --member %q: %w", member, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// defaultIdentityPath returns the location of the local group identity
+func defaultIdentityPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "identity.json"), nil
+}
+
+// resolveIdentityPath returns path or the default identity location when path is empty
+func resolveIdentityPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return defaultIdentityPath()
+}
+
+// loadGroupIdentity reads an identity key pair from disk
+func loadGroupIdentity(path string) (*crypto.GroupIdentity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no identity found at %s (run 'noisefs identity init' first)", path)
+		}
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	var identity crypto.GroupIdentity
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, fmt.Errorf("failed to parse identity file: %w", err)
+	}
+	if len(identity.PublicKey) == 0 || len(identity.PrivateKey) == 0 {
+		return nil, fmt.Errorf("identity file %s is incomplete", path)
+	}
+
+	return &identity, nil
+}
+
+// saveGroupIdentity writes an identity key pair readable only by the current user
+func saveGroupIdentity(path string, identity *crypto.GroupIdentity) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create identity directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal identity: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+
+	return nil
+}
+
+// identityCommand creates or shows the key pair used to join private groups
+func identityCommand(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("identity", flag.ContinueOnError)
+	identityFile := flagSet.String("identity", "", "Identity file (default ~/.noisefs/identity.json)")
+	force := flagSet.Bool("force", false, "Overwrite an existing identity")
+
+	action := "show"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action = args[0]
+		args = args[1:]
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	path, err := resolveIdentityPath(*identityFile)
+	if err != nil {
+		return err
+	}
+
+	var identity *crypto.GroupIdentity
+	created := false
+
+	switch action {
+	case "init":
+		if _, err := os.Stat(path); err == nil && !*force {
+			return fmt.Errorf("identity already exists at %s (use --force to replace it)", path)
+		}
+		identity, err = crypto.GenerateGroupIdentity()
+		if err != nil {
+			return err
+		}
+		if err := saveGroupIdentity(path, identity); err != nil {
+			return err
+		}
+		created = true
+	case "show":
+		identity, err = loadGroupIdentity(path)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: identity [init|show] [--identity file] [--force]")
+	}
+
+	result := IdentityResult{
+		PublicKey:    identity.PublicKeyString(),
+		MemberID:     identity.MemberID(),
+		IdentityFile: path,
+		Created:      created,
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+	} else if quiet {
+		fmt.Println(result.PublicKey)
+	} else {
+		if created {
+			fmt.Printf("Identity created: %s\n", path)
+		}
+		fmt.Printf("Public Key: %s\n", result.PublicKey)
+		fmt.Printf("Member ID:  %s\n", result.MemberID)
+		fmt.Printf("\nShare the public key with group owners so they can add you with --member.\n")
+	}
+
+	return nil
+}
+
+// shareGroupDirectory creates or rotates a snapshot shared with a private group.
+// The local identity, when present, is always included so the sharer can rotate later.
+func shareGroupDirectory(directoryManager *storage.DirectoryManager, args []string, members memberList, identityFile, rotateEnvelope string, quiet bool, jsonOutput bool) error {
+	memberKeys, err := members.publicKeys()
+	if err != nil {
+		return err
+	}
+
+	identityPath, err := resolveIdentityPath(identityFile)
+	if err != nil {
+		return err
+	}
+	identity, identityErr := loadGroupIdentity(identityPath)
+	if identityErr == nil {
+		memberKeys = append(memberKeys, identity.PublicKey)
+	}
+
+	ctx := context.Background()
+	result := GroupShareResult{CreatedAt: time.Now()}
+	var shared *storage.GroupSnapshot
+
+	if rotateEnvelope != "" && len(args) == 0 {
+		// Re-key the existing group snapshot for the new member list
+		if identityErr != nil {
+			return identityErr
+		}
+		shared, err = directoryManager.RotateGroupSnapshot(ctx, rotateEnvelope, identity, memberKeys)
+		if err != nil {
+			return fmt.Errorf("failed to rotate group snapshot: %w", err)
+		}
+	} else {
+		if len(args) < 3 {
+			return fmt.Errorf("usage: share-directory --member <public-key> [--member ...] <directory-cid> <directory-key> <snapshot-name> [description]")
+		}
+		directoryKey, err := crypto.ParseKeyFromString(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to parse directory key: %w", err)
+		}
+		description := ""
+		if len(args) > 3 {
+			description = args[3]
+		}
+
+		shared, err = directoryManager.CreateGroupDirectorySnapshot(ctx, args[0], directoryKey, args[2], description, memberKeys, rotateEnvelope)
+		if err != nil {
+			return fmt.Errorf("failed to create group directory snapshot: %w", err)
+		}
+	}
+
+	// Track the snapshot locally so retention can prune earlier epochs
+	recordSnapshot(storage.SnapshotRecord{
+		SnapshotCID:    shared.SnapshotCID,
+		OriginalCID:    shared.OriginalCID,
+		SnapshotName:   shared.Name,
+		KeyEnvelopeCID: shared.EnvelopeCID,
+		CreatedAt:      result.CreatedAt,
+		References:     shared.References,
+	}, quiet)
+
+	result.KeyEnvelopeCID = shared.EnvelopeCID
+	result.SnapshotCID = shared.SnapshotCID
+	result.OriginalCID = shared.OriginalCID
+	result.SnapshotName = shared.Name
+	result.Epoch = shared.Epoch
+	result.PreviousEnvelope = shared.Envelope.PreviousEnvelope
+	result.Members = shared.Members
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+	} else if quiet {
+		fmt.Printf("%s\t%d\n", result.KeyEnvelopeCID, result.Epoch)
+	} else {
+		fmt.Printf("Group snapshot created successfully!\n")
+		fmt.Printf("Key Envelope CID: %s\n", result.KeyEnvelopeCID)
+		fmt.Printf("Snapshot CID: %s\n", result.SnapshotCID)
+		fmt.Printf("Epoch: %d\n", result.Epoch)
+		if result.PreviousEnvelope != "" {
+			fmt.Printf("Previous Envelope: %s\n", result.PreviousEnvelope)
+		}
+		fmt.Printf("Members: %d\n", len(result.Members))
+		for _, member := range result.Members {
+			fmt.Printf("  %s\n", member)
+		}
+		if result.PreviousEnvelope != "" {
+			fmt.Printf("\nNote: files are not re-encrypted. Removed members can no longer list the snapshot, but can still download files whose CIDs they saved.\n")
+		}
+		if identityErr != nil {
+			fmt.Printf("\nNote: no local identity found, so you cannot rotate this group's key yourself.\n")
+		}
+		fmt.Printf("\nShare the key envelope CID with members; they open it with 'noisefs receive-directory <envelope-cid>'.\n")
+	}
+
+	return nil
+}
+
+// openGroupSnapshot resolves a key envelope to its snapshot CID and key using the local identity
+func openGroupSnapshot(directoryManager *storage.DirectoryManager, envelopeCID, identityFile string) (*storage.OpenedGroupSnapshot, error) {
+	identityPath, err := resolveIdentityPath(identityFile)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := loadGroupIdentity(identityPath)
+	if err != nil {
+		return nil, err
+	}
+
+	opened, err := directoryManager.OpenGroupSnapshot(context.Background(), envelopeCID, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open group snapshot: %w", err)
+	}
+	return opened, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// showHistory prints the metrics history kept by the running web UI,
+// read over its control socket
+func showHistory(cfg *config.Config, minutes int, quiet bool, jsonOutput bool) error {
+	if cfg.Daemon.ControlSocket == "" {
+		return fmt.Errorf("no control socket configured; set daemon.control_socket and restart the web UI")
+	}
+	var args []string
+	if minutes > 0 {
+		args = []string{strconv.Itoa(minutes)}
+	}
+	var report noisefs.HistoryReport
+	if err := control.Call(cfg.Daemon.ControlSocket, "history", args, &report); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(report)
+		return nil
+	}
+	if !quiet {
+		fmt.Printf("Metrics history: %d samples every %ds, keeping %d minutes\n\n", len(report.Samples), report.IntervalSeconds, report.WindowMinutes)
+		fmt.Printf("%-8s  %7s  %9s  %10s  %9s  %9s  %9s\n", "TIME", "UPLOADS", "DOWNLOADS", "UPLOADED", "CACHE HIT", "UPLOAD", "DOWNLOAD")
+	}
+
+	var total noisefs.MetricsSample
+	for _, sample := range report.Samples {
+		fmt.Printf("%-8s  %7d  %9d  %10s  %9s  %9s  %9s\n",
+			sample.Time.Local().Format("15:04:05"),
+			sample.Uploads,
+			sample.Downloads,
+			util.FormatBytes(sample.BytesUploaded),
+			hitRate(sample.CacheHits, sample.CacheMisses),
+			meanDuration(sample.UploadSeconds),
+			meanDuration(sample.DownloadSeconds))
+		total.Uploads += sample.Uploads
+		total.Downloads += sample.Downloads
+		total.BytesUploaded += sample.BytesUploaded
+		total.CacheHits += sample.CacheHits
+		total.CacheMisses += sample.CacheMisses
+	}
+	if !quiet && len(report.Samples) > 0 {
+		fmt.Printf("\nTotal: %d uploads (%s), %d downloads, cache hit rate %s\n",
+			total.Uploads, util.FormatBytes(total.BytesUploaded), total.Downloads, hitRate(total.CacheHits, total.CacheMisses))
+	}
+	return nil
+}
+
+// hitRate formats a cache hit rate, or "-" without lookups
+func hitRate(hits, misses int64) string {
+	if hits+misses == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hits)/float64(hits+misses))
+}
+
+// meanDuration formats a mean duration in seconds, or "-" for none
+func meanDuration(seconds float64) string {
+	if seconds == 0 {
+		return "-"
+	}
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Millisecond).String()
+}
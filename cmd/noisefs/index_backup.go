@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+	shell "github.com/ipfs/go-ipfs-api"
+)
+
+// backupPasswordEnv holds the password of index backups, so it doesn't
+// end up in shell history
+const backupPasswordEnv = "NOISEFS_BACKUP_PASSWORD"
+
+// IndexExportResult is the output of index export
+type IndexExportResult struct {
+	File      string `json:"file,omitempty"`
+	CID       string `json:"cid,omitempty"`
+	Entries   int    `json:"entries"`
+	Encrypted bool   `json:"encrypted"`
+	Bytes     int    `json:"bytes"`
+}
+
+// IndexImportResult is the output of index import
+type IndexImportResult struct {
+	Source string `json:"source"`
+	fuse.IndexRestoreResult
+}
+
+// indexCommand backs up, restores and syncs the local file index
+func indexCommand(args []string, storageManager *storage.Manager, ipfsShell *shell.Shell, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: index export|import|sync [options]")
+	}
+	switch args[0] {
+	case "export":
+		return indexExportCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	case "import":
+		return indexImportCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	case "sync":
+		return indexSyncCommand(args[1:], ipfsShell, cfg, quiet, jsonOutput)
+	default:
+		return fmt.Errorf("unknown index command %q (use export, import or sync)", args[0])
+	}
+}
+
+// openConfiguredIndex loads the file index named by the configuration,
+// decrypting it with the configured index password if there is one
+func openConfiguredIndex(cfg *config.Config) (*fuse.EncryptedFileIndex, error) {
+	indexPath := cfg.FUSE.IndexPath
+	if indexPath == "" {
+		var err error
+		if indexPath, err = fuse.GetDefaultIndexPath(); err != nil {
+			return nil, err
+		}
+	}
+	index, err := fuse.NewEncryptedFileIndex(indexPath, cfg.Security.IndexPassword)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.LoadIndex(); err != nil {
+		return nil, fmt.Errorf("failed to load index %s: %w", indexPath, err)
+	}
+	return index, nil
+}
+
+// indexExportCommand writes a backup of the file index to a file, IPFS or
+// both
+func indexExportCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("index export", flag.ContinueOnError)
+	output := flagSet.String("o", "", "Backup file to write")
+	encrypt := flagSet.Bool("encrypt", false, fmt.Sprintf("Encrypt the backup with the password in $%s", backupPasswordEnv))
+	pin := flagSet.Bool("pin", false, "Store the backup in IPFS and pin it (requires -encrypt)")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs index export [-encrypt] [-pin] [-o index-backup.json]")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" && !*pin {
+		flagSet.Usage()
+		return fmt.Errorf("an output file or -pin is required")
+	}
+	if *pin && !*encrypt {
+		// Anyone can fetch a block from IPFS, and the index names every file
+		return fmt.Errorf("-pin requires -encrypt; an unencrypted index must not be published")
+	}
+
+	password := ""
+	if *encrypt {
+		if password = os.Getenv(backupPasswordEnv); password == "" {
+			return fmt.Errorf("-encrypt needs the backup password in $%s", backupPasswordEnv)
+		}
+	}
+
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return err
+	}
+	defer index.Cleanup()
+
+	data, err := index.ExportBackup(password)
+	if err != nil {
+		return err
+	}
+	result := IndexExportResult{
+		File:      *output,
+		Entries:   index.GetSize(),
+		Encrypted: *encrypt,
+		Bytes:     len(data),
+	}
+
+	if *output != "" {
+		if err := os.WriteFile(*output, data, 0600); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	if *pin {
+		block, err := blocks.NewBlock(data)
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		address, err := storageManager.Put(ctx, block)
+		if err != nil {
+			return fmt.Errorf("failed to store backup: %w", err)
+		}
+		if err := storageManager.Pin(ctx, address); err != nil {
+			return fmt.Errorf("failed to pin backup %s: %w", address.ID, err)
+		}
+		result.CID = address.ID
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+	if quiet {
+		if result.CID != "" {
+			fmt.Println(result.CID)
+		} else {
+			fmt.Println(result.File)
+		}
+		return nil
+	}
+
+	fmt.Printf("Exported %d index entries (%s)\n", result.Entries, util.FormatSize(int64(result.Bytes)))
+	if result.File != "" {
+		fmt.Printf("Backup file: %s\n", result.File)
+	}
+	if result.CID != "" {
+		fmt.Printf("Backup CID: %s (pinned)\n", result.CID)
+		fmt.Println("Keep the CID and password; both are needed to restore the index.")
+	}
+	if !result.Encrypted {
+		fmt.Println("The backup is not encrypted and lists every file in the index; store it safely.")
+	}
+	return nil
+}
+
+// indexImportCommand restores a backup from a file or CID into the file
+// index
+func indexImportCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("index import", flag.ContinueOnError)
+	overwrite := flagSet.Bool("overwrite", false, "Replace entries already in the index with those in the backup")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs index import [-overwrite] <backup-file|backup-cid>")
+		fmt.Fprintf(flagSet.Output(), "Encrypted backups are opened with the password in $%s.\n", backupPasswordEnv)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one backup file or CID is required")
+	}
+
+	source := flagSet.Arg(0)
+	data, err := os.ReadFile(source)
+	if os.IsNotExist(err) && !strings.ContainsAny(source, `/\.`) {
+		// Not a file; a backup pinned with index export -pin
+		var block *blocks.Block
+		if block, err = storageManager.Get(context.Background(), &storage.BlockAddress{ID: source}); err == nil {
+			data = block.Data
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", source, err)
+	}
+
+	backup, err := fuse.ReadIndexBackup(data, os.Getenv(backupPasswordEnv))
+	if err != nil {
+		return err
+	}
+
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return err
+	}
+	defer index.Cleanup()
+
+	result := IndexImportResult{
+		Source:             source,
+		IndexRestoreResult: index.RestoreBackup(backup, *overwrite),
+	}
+	if err := index.SaveIndex(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+	if quiet {
+		fmt.Println(result.Added + result.Replaced)
+		return nil
+	}
+
+	fmt.Printf("Restored %d entries from %s (%d added, %d replaced)\n", result.Added+result.Replaced, source, result.Added, result.Replaced)
+	if result.Skipped > 0 {
+		fmt.Printf("Kept %d entries already in the index; use -overwrite to replace them\n", result.Skipped)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	shell "github.com/ipfs/go-ipfs-api"
+)
+
+// syncPassphraseEnv holds the passphrase shared by the devices that sync
+// an index
+const syncPassphraseEnv = "NOISEFS_SYNC_PASSPHRASE"
+
+// pubsubSyncTransport carries index sync messages over an IPFS PubSub
+// topic
+type pubsubSyncTransport struct {
+	shell *shell.Shell
+	topic string
+}
+
+func (t *pubsubSyncTransport) Publish(data []byte) error {
+	return t.shell.PubSubPublish(t.topic, string(data))
+}
+
+func (t *pubsubSyncTransport) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	sub, err := t.shell.PubSubSubscribe(t.topic)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		sub.Cancel()
+	}()
+
+	messages := make(chan []byte)
+	go func() {
+		defer close(messages)
+		for {
+			msg, err := sub.Next()
+			if err != nil {
+				return
+			}
+			select {
+			case messages <- msg.Data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
+
+// indexSyncCommand keeps the file index in sync with the user's other
+// devices until interrupted
+func indexSyncCommand(args []string, ipfsShell *shell.Shell, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("index sync", flag.ContinueOnError)
+	interval := flagSet.Duration("interval", 30*time.Second, "How often to look for local changes")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs index sync [-interval 30s]")
+		fmt.Fprintf(flagSet.Output(), "Devices with the same passphrase in $%s share their file index.\n", syncPassphraseEnv)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+	passphrase := os.Getenv(syncPassphraseEnv)
+	if passphrase == "" {
+		return fmt.Errorf("set the passphrase shared by your devices in $%s", syncPassphraseEnv)
+	}
+
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return err
+	}
+	defer index.Cleanup()
+
+	topic, err := fuse.SyncTopic(passphrase)
+	if err != nil {
+		return err
+	}
+	syncer, err := fuse.NewIndexSyncer(index.FileIndex, &pubsubSyncTransport{shell: ipfsShell, topic: topic}, fuse.SyncOptions{
+		Passphrase: passphrase,
+		// Pick up files added by uploads and mounts while syncing
+		Reload: index.LoadIndex,
+		Save:   index.SaveIndex,
+		OnConflict: func(path, conflictPath string) {
+			if !quiet && !jsonOutput {
+				fmt.Printf("Conflict: %s was changed on two devices; the other version is %s\n", path, conflictPath)
+			}
+		},
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "Sync error: %s\n", err)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if !quiet && !jsonOutput {
+		fmt.Printf("Syncing %s as device %s (Ctrl+C to stop)\n", index.GetIndexPath(), syncer.Device())
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return syncer.Run(ctx, *interval)
+}
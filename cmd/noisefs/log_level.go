@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// logLevelCommand shows or changes the log levels of a running web UI or
+// mount through its control socket
+func logLevelCommand(args []string, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("log-level", flag.ContinueOnError)
+	socket := flagSet.String("socket", cfg.Daemon.ControlSocket, "Control socket of the running process")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs log-level [-socket path] [level] [component=level|reset ...]")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if *socket == "" {
+		return fmt.Errorf("no control socket configured; set daemon.control_socket or pass -socket")
+	}
+
+	var levels control.LogLevels
+	if err := control.Call(*socket, "log-level", flagSet.Args(), &levels); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(levels)
+		return nil
+	}
+	if quiet {
+		fmt.Println(levels.Level)
+		return nil
+	}
+
+	fmt.Printf("Level: %s\n", levels.Level)
+	components := make([]string, 0, len(levels.Components))
+	for component := range levels.Components {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		fmt.Printf("  %s: %s\n", component, levels.Components[component])
+	}
+	if levels.Sampled > 0 {
+		fmt.Printf("Messages dropped by sampling: %d\n", levels.Sampled)
+	}
+	return nil
+}
@@ -5,31 +5,39 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
 	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
-	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends" // Import to register backends
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
 	"github.com/TheEntropyCollective/noisefs/pkg/util"
-	shell "github.com/ipfs/go-ipfs-api"
 )
 
 func main() {
-	// Show legal disclaimer on first run or if not accepted recently
+	// Show legal disclaimer until the current version is accepted
 	// Skip if running in test mode
-	if os.Getenv("NOISEFS_SKIP_LEGAL_NOTICE") != "1" && !checkLegalDisclaimerAccepted() {
-		showLegalDisclaimer()
+	acceptTOS := takeAcceptTOSFlag()
+	if os.Getenv("NOISEFS_SKIP_LEGAL_NOTICE") != "1" {
+		if err := compliance.EnsureDisclaimerAccepted(acceptTOS, "cli", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "\nYou must accept the terms to use NoiseFS: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	var (
@@ -41,11 +49,17 @@ func main() {
 		recursive  = flag.Bool("r", false, "Recursively upload/download directories")
 		exclude    = flag.String("exclude", "", "Comma-separated list of file patterns to exclude from directory upload")
 		stats      = flag.Bool("stats", false, "Show NoiseFS statistics")
+		detail     = flag.Bool("detail", false, "With -stats, report storage usage of the given descriptor CIDs, or of every indexed descriptor")
 		quiet      = flag.Bool("quiet", false, "Minimal output (only show errors and results)")
 		jsonOutput = flag.Bool("json", false, "Output results in JSON format")
 		blockSize  = flag.Int("block-size", 0, "Block size in bytes (overrides config)")
 		cacheSize  = flag.Int("cache-size", 0, "Number of blocks to cache in memory (overrides config)")
 		workers    = flag.Int("workers", 0, "Number of parallel workers for upload/download (overrides config)")
+		// Metrics history of the running web UI
+		history        = flag.Bool("history", false, "With -stats, show the metrics history kept by the running web UI")
+		historyMinutes = flag.Int("history-minutes", 0, "With -stats -history, only show the last N minutes")
+		// Upload content policy
+		contentPolicy = flag.String("content-policy", "", "JSON content policy file for uploads (overrides config)")
 		// Altruistic cache flags
 		minPersonalCacheMB    = flag.Int("min-personal-cache", 0, "Minimum personal cache size in MB (overrides config)")
 		disableAltruistic     = flag.Bool("disable-altruistic", false, "Disable altruistic caching")
@@ -60,16 +74,20 @@ func main() {
 	// Check for subcommands first
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "announce", "subscribe", "discover", "ls", "search", "sync", "share-directory", "receive-directory", "list-snapshots":
+		case "announce", "subscribe", "discover", "ls", "search", "sync", "share-directory", "receive-directory", "list-snapshots", "prune-snapshots", "export-bundle", "import-bundle", "pack", "unpack", "index", "share", "takedown", "tokens", "identity", "audit", "config", "log-level", "service", "doctor", "s3-gateway", "remote", "process", "transfers", "pin", "telemetry", "selfupdate":
 			handleSubcommand(os.Args[1], os.Args[2:])
 			return
 		}
 	}
 
+	// Handled by takeAcceptTOSFlag; defined so it shows in the usage
+	flag.Bool("accept-tos", false, "Accept the legal disclaimer without prompting, for automation")
+	var paths pathPatterns
+	flag.Var(&paths, "path", "Download only the files of a pack or directory matching this glob, such as docs/** (repeatable)")
 	flag.Parse()
 
 	// Load configuration
-	cfg, err := loadConfig(*configFile)
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		if *jsonOutput {
 			util.PrintJSONError(err)
@@ -80,7 +98,20 @@ func main() {
 	}
 
 	// Initialize logging
-	if err := logging.InitFromConfig(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output, cfg.Logging.File); err != nil {
+	err = logging.InitFromConfig(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output, cfg.Logging.File)
+	if err == nil {
+		err = logging.InitComponentLevelsFromConfig(cfg.Logging.Components, cfg.Logging.SampleInitial, cfg.Logging.SampleThereafter)
+	}
+	if err != nil {
+		if *jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+		}
+		os.Exit(1)
+	}
+
+	if err := logging.InitAuditFromConfig(cfg.Security.AuditLog, cfg.Security.AuditLogFile, cfg.Security.AuditLogMaxSizeMB, cfg.Security.AuditLogMaxFiles); err != nil {
 		if *jsonOutput {
 			util.PrintJSONError(err)
 		} else {
@@ -88,6 +119,7 @@ func main() {
 		}
 		os.Exit(1)
 	}
+	defer logging.CloseGlobalAuditLogger()
 
 	logger := logging.GetGlobalLogger().WithComponent("noisefs")
 
@@ -114,6 +146,9 @@ func main() {
 	if *workers > 0 {
 		cfg.Performance.MaxConcurrentOps = *workers
 	}
+	if *contentPolicy != "" {
+		cfg.Security.ContentPolicyFile = *contentPolicy
+	}
 	// Apply streaming overrides
 	if *memoryLimitMB > 0 {
 		cfg.Performance.MemoryLimit = *memoryLimitMB
@@ -125,6 +160,21 @@ func main() {
 		cfg.Performance.EnableMemoryMonitoring = true
 	}
 
+	// The metrics history is kept by the running web UI, so storage isn't
+	// needed to show it
+	if *stats && *history {
+		recordUsage(&cfg.Telemetry, "stats")
+		if err := showHistory(cfg, *historyMinutes, *quiet, *jsonOutput); err != nil {
+			if *jsonOutput {
+				util.PrintJSONError(err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create storage backend (IPFS with abstraction layer)
 	logger.Info("Connecting to storage backend", map[string]interface{}{
 		"backend":  "ipfs",
@@ -132,10 +182,7 @@ func main() {
 	})
 
 	// Create storage manager with IPFS backend
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
-	}
+	storageConfig := cfg.StorageConfig()
 
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -166,6 +213,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer storageManager.Stop(context.Background())
+	flushOfflineSpool(storageManager, logger)
 
 	// Create cache
 	logger.Debug("Initializing block cache", map[string]interface{}{
@@ -214,6 +262,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Choose block sizes per file unless -block-size fixes one
+	var blockSizePolicy blocks.BlockSizePolicy = blocks.FixedBlockSize(*blockSize)
+	if *blockSize == 0 {
+		if blockSizePolicy, err = cfg.Blocks.SizePolicy(); err != nil {
+			if *jsonOutput {
+				util.PrintJSONError(err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+			}
+			os.Exit(1)
+		}
+	}
+	client.SetBlockSizePolicy(blockSizePolicy)
+	if err := configureRetrieval(client, cfg); err != nil {
+		if *jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+		}
+		os.Exit(1)
+	}
+	if err := configureProcessors(client, cfg); err != nil {
+		if *jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+		}
+		os.Exit(1)
+	}
+	if err := configureDeduplication(client, cfg); err != nil {
+		if *jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s\n", util.FormatError(err))
+		}
+		os.Exit(1)
+	}
+
+	switch {
+	case *upload != "":
+		recordUsage(&cfg.Telemetry, "upload")
+	case *download != "":
+		recordUsage(&cfg.Telemetry, "download")
+	case *stats:
+		recordUsage(&cfg.Telemetry, "stats")
+	}
+
 	if *upload != "" {
 		// Check if the path is a directory
 		fileInfo, err := os.Stat(*upload)
@@ -258,6 +353,7 @@ func main() {
 			} else {
 				err = uploadDirectory(storageManager, client, *upload, cfg.Performance.BlockSize, *exclude, *quiet, *jsonOutput, cfg, logger)
 			}
+			recordAudit(logging.AuditUpload, *upload, err, map[string]string{"kind": "directory"})
 			if err != nil {
 				logger.Error("Directory upload failed", map[string]interface{}{
 					"directory": *upload,
@@ -270,17 +366,19 @@ func main() {
 			}
 		} else {
 			// File upload
+			fileBlockSize := client.BlockSizeFor(filepath.Base(*upload), fileInfo.Size())
 			logger.Info("Starting file upload", map[string]interface{}{
 				"file":       *upload,
-				"block_size": cfg.Performance.BlockSize,
+				"block_size": fileBlockSize,
 				"streaming":  *streaming,
 			})
 			var err error
 			if *streaming {
-				err = streamingUploadFile(storageManager, client, *upload, cfg.Performance.BlockSize, *quiet, *jsonOutput, cfg, logger)
+				err = streamingUploadFile(storageManager, client, *upload, fileBlockSize, *quiet, *jsonOutput, cfg, logger)
 			} else {
-				err = uploadFile(storageManager, client, *upload, cfg.Performance.BlockSize, *quiet, *jsonOutput, cfg, logger)
+				err = uploadFile(storageManager, client, *upload, fileBlockSize, *quiet, *jsonOutput, cfg, logger)
 			}
+			recordAudit(logging.AuditUpload, *upload, err, map[string]string{"kind": "file"})
 			if err != nil {
 				logger.Error("Upload failed", map[string]interface{}{
 					"file":  *upload,
@@ -308,6 +406,16 @@ func main() {
 			os.Exit(1)
 		}
 
+		pathFilter, err := descriptors.NewPathFilter(paths)
+		if err != nil {
+			if *jsonOutput {
+				util.PrintJSONError(err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			}
+			os.Exit(1)
+		}
+
 		// Try to detect if the CID is a directory descriptor
 		isDirectory, err := detectDirectoryDescriptor(storageManager, *download)
 		if err != nil {
@@ -321,7 +429,24 @@ func main() {
 			os.Exit(1)
 		}
 
-		if isDirectory {
+		if pathFilter != nil && !isDirectory {
+			// Only packs and directories hold files to pick from
+			err := downloadPackFiles(client, *download, *output, pathFilter, *quiet, *jsonOutput)
+			recordAudit(logging.AuditDownload, *download, err, map[string]string{"kind": "pack", "output": *output, "path": strings.Join(paths, ",")})
+			if err != nil {
+				logger.Error("Pack download failed", map[string]interface{}{
+					"descriptor_cid": *download,
+					"output_dir":     *output,
+					"error":          err.Error(),
+				})
+				if *jsonOutput {
+					util.PrintJSONError(err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+				}
+				os.Exit(1)
+			}
+		} else if isDirectory {
 			// Directory download
 			logger.Info("Starting directory download", map[string]interface{}{
 				"descriptor_cid": *download,
@@ -332,10 +457,11 @@ func main() {
 
 			var err error
 			if *streaming {
-				err = streamingDownloadDirectory(storageManager, client, *download, *output, *quiet, *jsonOutput, cfg, logger)
+				err = streamingDownloadDirectory(storageManager, client, *download, *output, pathFilter, *quiet, *jsonOutput, cfg, logger)
 			} else {
-				err = downloadDirectory(storageManager, client, *download, *output, *quiet, *jsonOutput, cfg, logger)
+				err = downloadDirectory(storageManager, client, *download, *output, pathFilter, "", *quiet, *jsonOutput, cfg, logger)
 			}
+			recordAudit(logging.AuditDownload, *download, err, map[string]string{"kind": "directory", "output": *output})
 			if err != nil {
 				logger.Error("Directory download failed", map[string]interface{}{
 					"descriptor_cid": *download,
@@ -360,6 +486,7 @@ func main() {
 			} else {
 				err = downloadFile(storageManager, client, *download, *output, *quiet, *jsonOutput, logger)
 			}
+			recordAudit(logging.AuditDownload, *download, err, map[string]string{"kind": "file", "output": *output})
 			if err != nil {
 				logger.Error("Download failed", map[string]interface{}{
 					"descriptor_cid": *download,
@@ -377,32 +504,53 @@ func main() {
 			showMetrics(client, logger)
 		}
 	} else if *stats {
-		// Show statistics
-		showSystemStats(storageManager, client, blockCache, *jsonOutput, logger)
+		// Show statistics, with the storage usage of descriptors if asked
+		var usage *descriptors.StorageUsage
+		if *detail {
+			var err error
+			if usage, err = measureStorageUsage(storageManager, flag.Args()); err != nil {
+				if *jsonOutput {
+					util.PrintJSONError(err)
+				} else {
+					fmt.Fprintf(os.Stderr, "Error measuring storage usage: %s\n", err)
+				}
+				os.Exit(1)
+			}
+		}
+		showSystemStats(storageManager, client, blockCache, usage, *jsonOutput, logger)
 	} else {
 		flag.Usage()
 	}
 }
 
-// loadConfig loads configuration from file or uses defaults
-func loadConfig(configPath string) (*config.Config, error) {
-	if configPath == "" {
-		// Try default config path
-		defaultPath, err := config.GetDefaultConfigPath()
-		if err == nil {
-			configPath = defaultPath
-		}
-	}
-
-	return config.LoadConfig(configPath)
-}
-
 func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePath string, blockSize int, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
 	// Track overall upload time
 	uploadStartTime := time.Now()
 
+	// Reject content forbidden by the upload policy before doing any work
+	if err := enforceContentPolicy(cfg, filePath); err != nil {
+		return err
+	}
+
+	// Upload what the content processors make of the file
+	processed, err := client.Processors().Process(context.Background(), processors.StageUpload, filepath.Base(filePath), filePath)
+	if err != nil {
+		return err
+	}
+	defer processed.Close()
+
+	// A file uploaded before isn't split and stored again, once the
+	// processors have accepted it
+	key, dedup := uploadKey(cfg, processed.Path, filepath.Base(filePath), blockSize)
+	if dedup {
+		if descriptorCID, ok := client.FindUpload(key); ok {
+			printDeduplicatedUpload(key, descriptorCID, quiet, jsonOutput)
+			return nil
+		}
+	}
+
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := os.Open(processed.Path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
@@ -451,14 +599,33 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 		blockSize,
 	)
 
+	// Blocks stored by earlier uploads reuse their triples; only the rest
+	// are anonymized
+	triples := make([]noisefs.BlockTriple, len(fileBlocks))
+	var pending []int
+	var pendingBlocks []*blocks.Block
+	for i, block := range fileBlocks {
+		if triple, ok := client.ReuseBlock(context.Background(), block.Data); ok {
+			triples[i] = triple
+			continue
+		}
+		pending = append(pending, i)
+		pendingBlocks = append(pendingBlocks, block)
+	}
+	if reused := len(fileBlocks) - len(pendingBlocks); reused > 0 {
+		logger.Info("Reusing blocks of earlier uploads", map[string]interface{}{
+			"reused_blocks": reused,
+		})
+	}
+
 	// Generate or select randomizer blocks (using 3-tuple format)
-	randomizer1Blocks := make([]*blocks.Block, len(fileBlocks))
-	randomizer1CIDs := make([]string, len(fileBlocks))
-	randomizer2Blocks := make([]*blocks.Block, len(fileBlocks))
-	randomizer2CIDs := make([]string, len(fileBlocks))
+	randomizer1Blocks := make([]*blocks.Block, len(pendingBlocks))
+	randomizer1CIDs := make([]string, len(pendingBlocks))
+	randomizer2Blocks := make([]*blocks.Block, len(pendingBlocks))
+	randomizer2CIDs := make([]string, len(pendingBlocks))
 
-	for i := range fileBlocks {
-		randBlock1, cid1, randBlock2, cid2, _, err := client.SelectRandomizers(context.Background(), fileBlocks[i].Size())
+	for i := range pendingBlocks {
+		randBlock1, cid1, randBlock2, cid2, _, err := client.SelectRandomizers(context.Background(), pendingBlocks[i].Size())
 		if err != nil {
 			return fmt.Errorf("failed to select randomizer blocks: %w", err)
 		}
@@ -493,11 +660,11 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 
 	// Parallel XOR blocks with randomizers (3-tuple: data XOR randomizer1 XOR randomizer2)
 	logger.Info("Performing parallel XOR operations", map[string]interface{}{
-		"block_count":  len(fileBlocks),
+		"block_count":  len(pendingBlocks),
 		"worker_count": workerCount,
 	})
 
-	anonymizedBlocks, err := blockOps.ParallelXOR(context.Background(), fileBlocks, randomizer1Blocks, randomizer2Blocks)
+	anonymizedBlocks, err := blockOps.ParallelXOR(context.Background(), pendingBlocks, randomizer1Blocks, randomizer2Blocks)
 	if err != nil {
 		return fmt.Errorf("failed to perform parallel XOR: %w", err)
 	}
@@ -505,7 +672,7 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 	xorDuration := time.Since(xorStartTime)
 	logger.Info("XOR operations completed", map[string]interface{}{
 		"duration_ms":       xorDuration.Milliseconds(),
-		"blocks_per_second": float64(len(fileBlocks)) / xorDuration.Seconds(),
+		"blocks_per_second": float64(len(pendingBlocks)) / xorDuration.Seconds(),
 	})
 
 	// Store anonymized blocks in IPFS with caching (parallel)
@@ -545,9 +712,15 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 		uploadProgress.Finish()
 	}
 
+	for j, i := range pending {
+		triples[i] = noisefs.BlockTriple{DataCID: dataCIDs[j], Randomizer1CID: randomizer1CIDs[j], Randomizer2CID: randomizer2CIDs[j]}
+		// A failure only means the block isn't reused later
+		client.RememberBlock(fileBlocks[i].Data, triples[i])
+	}
+
 	// Add block triples to descriptor (3-tuple format)
-	for i := range dataCIDs {
-		if err := descriptor.AddBlockTriple(dataCIDs[i], randomizer1CIDs[i], randomizer2CIDs[i]); err != nil {
+	for _, triple := range triples {
+		if err := descriptor.AddBlockTriple(triple.DataCID, triple.Randomizer1CID, triple.Randomizer2CID); err != nil {
 			return fmt.Errorf("failed to add block triple to descriptor: %w", err)
 		}
 	}
@@ -598,7 +771,7 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 			totalUploadDuration.Seconds())
 		fmt.Printf("  - XOR operations: %.2fs (%d blocks/s)\n",
 			xorDuration.Seconds(),
-			int(float64(len(fileBlocks))/xorDuration.Seconds()))
+			int(float64(len(pendingBlocks))/xorDuration.Seconds()))
 		fmt.Printf("  - Storage operations: %.2fs (%d blocks/s)\n",
 			storageDuration.Seconds(),
 			int(float64(len(anonymizedBlocks))/storageDuration.Seconds()))
@@ -613,6 +786,13 @@ func uploadFile(storageManager *storage.Manager, client *noisefs.Client, filePat
 	// For 3-tuple: data + randomizer1 + randomizer2 = 3x the data size
 	client.RecordUpload(fileInfo.Size(), totalStoredBytes*3) // *3 for data + 2 randomizer blocks
 
+	if dedup {
+		if err := client.RememberUpload(key, descriptorCID); err != nil {
+			logger.Warn("Failed to record upload for deduplication", map[string]interface{}{"error": err.Error()})
+		}
+	}
+
+	notifyUpload(cfg, descriptorCID, filepath.Base(filePath), fileInfo.Size())
 	return nil
 }
 
@@ -635,6 +815,11 @@ func uploadDirectory(storageManager *storage.Manager, client *noisefs.Client, di
 		return fmt.Errorf("failed to generate encryption key: %w", err)
 	}
 
+	policy, err := loadContentPolicy(cfg)
+	if err != nil {
+		return err
+	}
+
 	// Create directory processor
 	processorConfig := &blocks.ProcessorConfig{
 		BlockSize:         blockSize,
@@ -647,6 +832,7 @@ func uploadDirectory(storageManager *storage.Manager, client *noisefs.Client, di
 		MaxFileSize:       0, // No limit
 		AllowedExtensions: nil,
 		BlockedExtensions: excludes,
+		FileFilter:        contentPolicyFilter(policy, quiet || jsonOutput, logger),
 	}
 
 	var progressBar *util.ProgressBar
@@ -833,13 +1019,6 @@ func downloadFile(storageManager *storage.Manager, client *noisefs.Client, descr
 		ShutdownTimeout: 30 * time.Second,
 	}
 
-	if !quiet {
-		// Add progress reporter
-		poolConfig.ProgressReporter = func(completed, total int64) {
-			// Progress is tracked via progress bars below
-		}
-	}
-
 	pool := workers.NewPool(poolConfig)
 	if err := pool.Start(); err != nil {
 		return fmt.Errorf("failed to start worker pool: %w", err)
@@ -972,6 +1151,18 @@ func downloadFile(storageManager *storage.Manager, client *noisefs.Client, descr
 	}
 	assembleDuration := time.Since(assembleStartTime)
 
+	// Check the reassembled file against the descriptor's content hash
+	outputFile.Close()
+	if err := verifyDownloaded(descriptor, outputPath); err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+
+	// Run the download processors on the written file
+	if err := processDownloaded(client, descriptor.Filename, outputPath); err != nil {
+		return err
+	}
+
 	// Calculate total download duration
 	totalDownloadDuration := time.Since(downloadStartTime)
 
@@ -1063,7 +1254,7 @@ func showMetrics(client *noisefs.Client, logger *logging.Logger) {
 }
 
 // showSystemStats displays comprehensive system statistics
-func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, blockCache cache.Cache, jsonOutput bool, logger *logging.Logger) {
+func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, blockCache cache.Cache, usage *descriptors.StorageUsage, jsonOutput bool, logger *logging.Logger) {
 	// Gather all statistics
 	var ipfsConnected bool
 	var peerCount int
@@ -1075,6 +1266,14 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 		peerCount = storageManager.GetConnectedPeerCount()
 	}
 
+	// Get Bitswap and DHT stats, which may take a DHT lookup
+	var networkStats []util.NetworkStats
+	if ipfsConnected {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		networkStats = collectNetworkStats(storageManager.NetworkStats(ctx))
+		cancel()
+	}
+
 	// Get cache stats
 	cacheStats := blockCache.GetStats()
 	var cacheHitRate float64
@@ -1113,6 +1312,7 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 				Uploads:   metrics.TotalUploads,
 				Downloads: metrics.TotalDownloads,
 			},
+			Network: networkStats,
 		}
 
 		// Add altruistic cache stats if available
@@ -1162,7 +1362,7 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 				MinPersonalCacheMB: minPersonalCacheMB,
 			}
 		}
-		util.PrintJSONSuccess(result)
+		util.PrintJSONSuccess(detailedStats{StatsResult: result, Usage: usage})
 		return
 	}
 
@@ -1180,6 +1380,20 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 		fmt.Println("IPFS Status: Disconnected")
 	}
 
+	// Network Statistics
+	for _, network := range networkStats {
+		fmt.Printf("\n--- Network (%s) ---\n", network.Backend)
+		fmt.Printf("Peers: %d\n", network.Peers)
+		fmt.Printf("Bitswap Blocks Received: %d (%s, %d duplicate)\n",
+			network.BlocksReceived, formatBytes(int64(network.DataReceived)), network.DupBlocksReceived)
+		fmt.Printf("Bitswap Blocks Sent: %d (%s)\n",
+			network.BlocksSent, formatBytes(int64(network.DataSent)))
+		fmt.Printf("DHT Queries: %d (%d failed)\n", network.DHTQueries, network.DHTFailures)
+		if network.DHTQueries > network.DHTFailures {
+			fmt.Printf("DHT Query Latency: %.0f ms\n", network.DHTLatencyMs)
+		}
+	}
+
 	// Cache Statistics
 	fmt.Println("\n--- Cache Statistics ---")
 	fmt.Printf("Cache Size: %d blocks\n", cacheStats.Size)
@@ -1269,6 +1483,10 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 	fmt.Printf("Total Uploads: %d\n", metrics.TotalUploads)
 	fmt.Printf("Total Downloads: %d\n", metrics.TotalDownloads)
 
+	if usage != nil {
+		printStorageUsage(usage)
+	}
+
 	// Log the stats for debugging
 	logger.Info("System statistics displayed", map[string]interface{}{
 		"cache_size":       cacheStats.Size,
@@ -1278,6 +1496,28 @@ func showSystemStats(storageManager *storage.Manager, client *noisefs.Client, bl
 	})
 }
 
+// collectNetworkStats flattens per-backend network statistics, sorted by
+// backend name
+func collectNetworkStats(stats map[string]*storage.NetworkStats) []util.NetworkStats {
+	result := make([]util.NetworkStats, 0, len(stats))
+	for name, network := range stats {
+		result = append(result, util.NetworkStats{
+			Backend:           name,
+			Peers:             network.Peers,
+			BlocksReceived:    network.Bitswap.BlocksReceived,
+			BlocksSent:        network.Bitswap.BlocksSent,
+			DataReceived:      network.Bitswap.DataReceived,
+			DataSent:          network.Bitswap.DataSent,
+			DupBlocksReceived: network.Bitswap.DupBlocksReceived,
+			DHTQueries:        network.DHT.Queries,
+			DHTFailures:       network.DHT.Failures,
+			DHTLatencyMs:      float64(network.DHT.AverageLatency) / float64(time.Millisecond),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Backend < result[j].Backend })
+	return result
+}
+
 // formatBytes converts bytes to human-readable format
 func formatBytes(bytes int64) string {
 	const (
@@ -1328,9 +1568,29 @@ func handleSubcommand(cmd string, args []string) {
 		}
 	}
 
-	// Special case for discover - doesn't need IPFS connection
-	if cmd == "discover" {
-		if err := discoverCommand(args, quiet, jsonOutput); err != nil {
+	// The doctor checks the configuration and IPFS connection itself and
+	// exits with an error if anything is wrong
+	if cmd == "doctor" {
+		if !doctorCommand(args, quiet, jsonOutput) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Special case for discover, identity, tokens and config - don't need IPFS connection
+	if cmd == "discover" || cmd == "identity" || cmd == "tokens" || cmd == "config" {
+		var err error
+		switch cmd {
+		case "discover":
+			err = discoverCommand(args, quiet, jsonOutput)
+		case "identity":
+			err = identityCommand(args, quiet, jsonOutput)
+		case "tokens":
+			err = tokensCommand(args, quiet, jsonOutput)
+		default:
+			err = configCommand(args, quiet, jsonOutput)
+		}
+		if err != nil {
 			if jsonOutput {
 				util.PrintJSONError(err)
 			} else {
@@ -1342,7 +1602,7 @@ func handleSubcommand(cmd string, args []string) {
 	}
 
 	// Load configuration for commands that need IPFS
-	cfg, err := loadConfig(configFile)
+	cfg, err := config.Load(configFile)
 	if err != nil {
 		if jsonOutput {
 			util.PrintJSONError(err)
@@ -1352,16 +1612,57 @@ func handleSubcommand(cmd string, args []string) {
 		os.Exit(1)
 	}
 
+	// The audit, log-level, service, process, transfers, telemetry and
+	// selfupdate commands, and remote commands other than serve, only need
+	// the configuration
+	if cmd == "audit" || cmd == "log-level" || cmd == "service" || cmd == "process" || cmd == "transfers" || cmd == "telemetry" || cmd == "selfupdate" || (cmd == "remote" && !remoteNeedsStorage(args)) {
+		recordUsage(&cfg.Telemetry, cmd)
+		switch cmd {
+		case "audit":
+			err = auditCommand(args, cfg, quiet, jsonOutput)
+		case "process":
+			err = processCommand(args, cfg, quiet, jsonOutput)
+		case "log-level":
+			err = logLevelCommand(args, cfg, quiet, jsonOutput)
+		case "transfers":
+			err = transfersCommand(args, cfg, quiet, jsonOutput)
+		case "remote":
+			err = remoteCommand(args, nil, cfg, quiet, jsonOutput)
+		case "telemetry":
+			err = telemetryCommand(args, cfg, config.ResolveConfigPath(configFile), quiet, jsonOutput)
+		case "selfupdate":
+			err = selfUpdateCommand(args, cfg, quiet, jsonOutput)
+		default:
+			err = serviceCommand(args, cfg, config.ResolveConfigPath(configFile), quiet, jsonOutput)
+		}
+		if err != nil {
+			if jsonOutput {
+				util.PrintJSONError(err)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := logging.InitAuditFromConfig(cfg.Security.AuditLog, cfg.Security.AuditLogFile, cfg.Security.AuditLogMaxSizeMB, cfg.Security.AuditLogMaxFiles); err != nil {
+		if jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error opening audit log: %s\n", err)
+		}
+		os.Exit(1)
+	}
+	defer logging.CloseGlobalAuditLogger()
+
 	// Apply command-line override
 	if ipfsAPI != "" {
 		cfg.IPFS.APIEndpoint = ipfsAPI
 	}
 
 	// Create storage manager for subcommands
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
-	}
+	storageConfig := cfg.StorageConfig()
 
 	storageManager, err := storage.NewManager(storageConfig)
 	if err != nil {
@@ -1385,14 +1686,24 @@ func handleSubcommand(cmd string, args []string) {
 	defer storageManager.Stop(context.Background())
 
 	// Create shell for PubSub
-	ipfsShell := shell.NewShell(cfg.IPFS.APIEndpoint)
+	ipfsShell, err := backends.NewIPFSShell(cfg.IPFSConnection())
+	if err != nil {
+		if jsonOutput {
+			util.PrintJSONError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error creating IPFS client: %s\n", err)
+		}
+		os.Exit(1)
+	}
+
+	recordUsage(&cfg.Telemetry, cmd)
 
 	// Handle subcommands
 	switch cmd {
 	case "announce":
-		err = announceCommand(args, storageManager, ipfsShell, quiet, jsonOutput)
+		err = announceCommand(args, storageManager, ipfsShell, cfg, quiet, jsonOutput)
 	case "subscribe":
-		err = subscribeCommand(args, storageManager, ipfsShell, quiet, jsonOutput)
+		err = subscribeCommand(args, storageManager, ipfsShell, cfg, quiet, jsonOutput)
 	case "ls":
 		err = lsCommand(args, storageManager, quiet, jsonOutput)
 	case "search":
@@ -1400,13 +1711,35 @@ func handleSubcommand(cmd string, args []string) {
 		handleSearchCommand(args)
 		return
 	case "sync":
-		err = handleSyncCommand(args, storageManager, quiet, jsonOutput)
+		err = handleSyncCommand(args, storageManager, cfg.Webhooks, quiet, jsonOutput)
 	case "share-directory":
 		err = shareDirectoryCommand(args, storageManager, quiet, jsonOutput)
 	case "receive-directory":
 		err = receiveDirectoryCommand(args, storageManager, quiet, jsonOutput)
 	case "list-snapshots":
-		err = listSnapshotsCommand(args, storageManager, quiet, jsonOutput)
+		err = listSnapshotsCommand(args, storageManager, cfg.Snapshots, quiet, jsonOutput)
+	case "prune-snapshots":
+		err = pruneSnapshotsCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "export-bundle":
+		err = exportBundleCommand(args, storageManager, quiet, jsonOutput)
+	case "import-bundle":
+		err = importBundleCommand(args, storageManager, quiet, jsonOutput)
+	case "pack":
+		err = packCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "unpack":
+		err = unpackCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "index":
+		err = indexCommand(args, storageManager, ipfsShell, cfg, quiet, jsonOutput)
+	case "share":
+		err = shareCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "takedown":
+		err = takedownCommand(args, storageManager, quiet, jsonOutput)
+	case "pin":
+		err = pinCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "s3-gateway":
+		err = s3GatewayCommand(args, storageManager, cfg, quiet, jsonOutput)
+	case "remote":
+		err = remoteCommand(args, storageManager, cfg, quiet, jsonOutput)
 	default:
 		err = fmt.Errorf("unknown command: %s", cmd)
 	}
@@ -1421,73 +1754,20 @@ func handleSubcommand(cmd string, args []string) {
 	}
 }
 
-// checkLegalDisclaimerAccepted checks if the user has accepted the legal disclaimer
-func checkLegalDisclaimerAccepted() bool {
-	// Get config directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return false
-	}
-	configDir := filepath.Join(homeDir, ".noisefs")
-
-	disclaimerFile := filepath.Join(configDir, ".noisefs_legal_accepted")
-	info, err := os.Stat(disclaimerFile)
-	if err != nil {
-		return false
-	}
-
-	// Check if disclaimer was accepted within the last 30 days
-	return time.Since(info.ModTime()) < 30*24*time.Hour
-}
-
-// showLegalDisclaimer displays the legal disclaimer and asks for acceptance
-func showLegalDisclaimer() {
-	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Println("                          ⚠️  LEGAL NOTICE & TERMS OF USE")
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println()
-	fmt.Println("IMPORTANT: This software is provided for LEGITIMATE PURPOSES ONLY.")
-	fmt.Println()
-	fmt.Println("By using NoiseFS, you acknowledge and agree that:")
-	fmt.Println()
-	fmt.Println("  • You will NOT use this software to share copyrighted material without authorization")
-	fmt.Println("  • You will NOT use this software for any illegal activities")
-	fmt.Println("  • You are solely responsible for all content you upload, share, or download")
-	fmt.Println("  • You understand that all actions may be logged and you may be held accountable")
-	fmt.Println("  • The developers are NOT responsible for how you use this software")
-	fmt.Println()
-	fmt.Println("Legitimate use cases include:")
-	fmt.Println("  • Open source software distribution")
-	fmt.Println("  • Academic and research data sharing")
-	fmt.Println("  • Public domain content distribution")
-	fmt.Println("  • Personal backup and file synchronization")
-	fmt.Println("  • Creative Commons licensed content")
-	fmt.Println()
-	fmt.Println("For detailed information, see: docs/LEGITIMATE_USE_CASES.md")
-	fmt.Println()
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Printf("\nDo you understand and accept these terms? (yes/no): ")
-
-	var response string
-	fmt.Scanln(&response)
-
-	if strings.ToLower(response) != "yes" {
-		fmt.Println("\nYou must accept the terms to use NoiseFS. Exiting.")
-		os.Exit(1)
-	}
-
-	// Save acceptance
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		configDir := filepath.Join(homeDir, ".noisefs")
-		os.MkdirAll(configDir, 0700)
-		disclaimerFile := filepath.Join(configDir, ".noisefs_legal_accepted")
-		os.WriteFile(disclaimerFile, []byte(time.Now().Format(time.RFC3339)), 0600)
+// takeAcceptTOSFlag removes -accept-tos from the arguments, wherever it
+// appears, so automation can accept the legal disclaimer for any command
+func takeAcceptTOSFlag() bool {
+	accepted := false
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == "-accept-tos" || arg == "--accept-tos" {
+			accepted = true
+			continue
+		}
+		args = append(args, arg)
 	}
-
-	fmt.Println("\nThank you for accepting the terms. Remember to use NoiseFS responsibly.")
-	fmt.Println(strings.Repeat("=", 80))
-	fmt.Println()
+	os.Args = args
+	return accepted
 }
 
 // lsCommand implements directory listing functionality
@@ -1515,6 +1795,10 @@ func lsCommand(args []string, storageManager *storage.Manager, quiet bool, jsonO
 		return fmt.Errorf("failed to retrieve directory manifest: %w", err)
 	}
 
+	// Files we uploaded or indexed ourselves are described by the local
+	// index, without fetching their descriptors
+	indexed := indexedDescriptors()
+
 	// Process directory entries
 	entries := make([]DirectoryListEntry, 0, len(manifest.Entries))
 	for _, entry := range manifest.Entries {
@@ -1527,6 +1811,13 @@ func lsCommand(args []string, storageManager *storage.Manager, quiet bool, jsonO
 			Size:       entry.Size,
 			ModifiedAt: entry.ModifiedAt,
 		}
+		if indexEntry, ok := indexed[entry.CID]; ok {
+			listEntry.Name = indexEntry.Filename
+			listEntry.MimeType = indexEntry.MimeType
+			listEntry.Tags = indexEntry.Tags
+			listEntry.ContentHash = indexEntry.ContentHash
+			listEntry.Encrypted = indexEntry.Encrypted
+		}
 		entries = append(entries, listEntry)
 	}
 
@@ -1556,11 +1847,18 @@ func lsCommand(args []string, storageManager *storage.Manager, quiet bool, jsonO
 				typeStr = "DIR"
 			}
 
-			fmt.Printf("%-4s  %-8s  %s  %s\n",
+			fmt.Printf("%-4s  %-8s  %s  %s",
 				typeStr,
 				formatBytes(entry.Size),
 				entry.ModifiedAt.Format("2006-01-02 15:04:05"),
 				entry.Name)
+			if entry.MimeType != "" && entry.MimeType != "application/octet-stream" {
+				fmt.Printf("  %s", entry.MimeType)
+			}
+			if len(entry.Tags) > 0 {
+				fmt.Printf("  [%s]", strings.Join(entry.Tags, ", "))
+			}
+			fmt.Println()
 		}
 	}
 
@@ -1574,6 +1872,35 @@ type DirectoryListEntry struct {
 	Type       blocks.DescriptorType `json:"type"`
 	Size       int64                 `json:"size"`
 	ModifiedAt time.Time             `json:"modified_at"`
+
+	// From the local file index, when the entry is in it
+	MimeType    string   `json:"mime_type,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ContentHash string   `json:"content_hash,omitempty"`
+	Encrypted   bool     `json:"encrypted,omitempty"`
+}
+
+// indexedDescriptors returns the local file index entries by descriptor
+// CID. Listings work without the index, so failures to load it are ignored.
+func indexedDescriptors() map[string]*fuse.IndexEntry {
+	indexed := make(map[string]*fuse.IndexEntry)
+	indexPath, err := fuse.GetDefaultIndexPath()
+	if err != nil {
+		return indexed
+	}
+	index := fuse.NewFileIndex(indexPath)
+	if err := index.LoadIndex(); err != nil {
+		return indexed
+	}
+	for _, entry := range index.ListFiles() {
+		if entry.DescriptorCID != "" {
+			indexed[entry.DescriptorCID] = entry
+		}
+		if entry.DirectoryDescriptorCID != "" {
+			indexed[entry.DirectoryDescriptorCID] = entry
+		}
+	}
+	return indexed
 }
 
 // DirectoryListResult represents the result of directory listing
@@ -1617,8 +1944,11 @@ func detectDirectoryDescriptor(storageManager *storage.Manager, cid string) (boo
 	return false, fmt.Errorf("unable to determine descriptor type")
 }
 
-// downloadDirectory downloads a directory recursively
-func downloadDirectory(storageManager *storage.Manager, client *noisefs.Client, directoryCID string, outputDir string, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
+// downloadDirectory downloads a directory recursively. With a filter, only
+// the files it selects are downloaded; relDir is the path of directoryCID
+// within the directory being downloaded, which filter patterns are matched
+// against.
+func downloadDirectory(storageManager *storage.Manager, client *noisefs.Client, directoryCID string, outputDir string, filter *descriptors.PathFilter, relDir string, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
 	downloadStartTime := time.Now()
 
 	// Create output directory
@@ -1654,6 +1984,15 @@ func downloadDirectory(storageManager *storage.Manager, client *noisefs.Client,
 	totalSize := int64(0)
 
 	for _, entry := range result.Entries {
+		entryPath := path.Join(relDir, entry.DecryptedName)
+		if (entry.Type == blocks.FileType && !filter.Match(entryPath)) ||
+			(entry.Type == blocks.DirectoryType && !filter.MayContain(entryPath)) {
+			if progressBar != nil {
+				progressBar.Add(1)
+			}
+			continue
+		}
+
 		if entry.Type == blocks.FileType {
 			// Download file
 			filePath := filepath.Join(outputDir, entry.DecryptedName)
@@ -1676,7 +2015,7 @@ func downloadDirectory(storageManager *storage.Manager, client *noisefs.Client,
 		} else if entry.Type == blocks.DirectoryType {
 			// Recursively download subdirectory
 			subdirPath := filepath.Join(outputDir, entry.DecryptedName)
-			if err := downloadDirectory(storageManager, client, entry.CID, subdirPath, true, false, cfg, logger); err != nil {
+			if err := downloadDirectory(storageManager, client, entry.CID, subdirPath, filter, entryPath, true, false, cfg, logger); err != nil {
 				logger.Error("Failed to download subdirectory", map[string]interface{}{
 					"subdir_cid":  entry.CID,
 					"subdir_path": subdirPath,
@@ -1731,15 +2070,58 @@ func downloadDirectory(storageManager *storage.Manager, client *noisefs.Client,
 }
 
 // streamingDownloadDirectory downloads a directory with streaming support
-func streamingDownloadDirectory(storageManager *storage.Manager, client *noisefs.Client, directoryCID string, outputDir string, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
+func streamingDownloadDirectory(storageManager *storage.Manager, client *noisefs.Client, directoryCID string, outputDir string, filter *descriptors.PathFilter, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
 	// For now, delegate to regular download - streaming directory download would need more complex implementation
-	return downloadDirectory(storageManager, client, directoryCID, outputDir, quiet, jsonOutput, cfg, logger)
+	return downloadDirectory(storageManager, client, directoryCID, outputDir, filter, "", quiet, jsonOutput, cfg, logger)
 }
 
 // shareDirectoryCommand creates an immutable snapshot of a directory for sharing
 func shareDirectoryCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
-	if len(args) < 3 {
-		return fmt.Errorf("usage: share-directory <directory-cid> <directory-key> <snapshot-name> [description]")
+	flagSet := flag.NewFlagSet("share-directory", flag.ContinueOnError)
+	// A snapshot reuses the directory's file CIDs and stores only new
+	// manifests, so no files are transferred and --max-parallel-files
+	// has nothing to bound
+	limits := registerTransferLimitFlags(flagSet, false)
+	var members memberList
+	flagSet.Var(&members, "member", "Member public key for a private group snapshot (repeatable)")
+	rotateEnvelope := flagSet.String("rotate", "", "Key envelope CID of the group snapshot to re-key for the new member list; only the manifest is re-encrypted, so removed members who saved file CIDs can still download those files")
+	identityFile := flagSet.String("identity", "", "Identity file (default ~/.noisefs/identity.json)")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	args = flagSet.Args()
+
+	groupMode := len(members) > 0 || *rotateEnvelope != ""
+	if len(args) < 3 && !groupMode {
+		return fmt.Errorf("usage: share-directory [--max-bandwidth N] [--max-parallel-blocks N] [--member KEY ...] [--rotate ENVELOPE-CID] <directory-cid> <directory-key> <snapshot-name> [description]")
+	}
+
+	// Apply transfer limits to all block traffic for this command
+	bandwidth, err := limits.bandwidth()
+	if err != nil {
+		return err
+	}
+	if *limits.maxParallelBlocks < 0 {
+		return fmt.Errorf("invalid --max-parallel-blocks: must not be negative")
+	}
+	if bandwidth > 0 || *limits.maxParallelBlocks > 0 {
+		storageManager.SetTransferLimiter(workers.NewTransferLimiter(bandwidth, *limits.maxParallelBlocks))
+	}
+
+	// Create directory manager
+	tempKey, err := crypto.GenerateKey("temp-key")
+	if err != nil {
+		return fmt.Errorf("failed to generate temp key: %w", err)
+	}
+
+	directoryManager, err := storage.NewDirectoryManager(storageManager, tempKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create directory manager: %w", err)
+	}
+
+	// Private groups get a per-member wrapped key instead of a shareable key
+	if groupMode {
+		return shareGroupDirectory(directoryManager, args, members, *identityFile, *rotateEnvelope, quiet, jsonOutput)
 	}
 
 	directoryCID := args[0]
@@ -1756,17 +2138,6 @@ func shareDirectoryCommand(args []string, storageManager *storage.Manager, quiet
 		return fmt.Errorf("failed to parse directory key: %w", err)
 	}
 
-	// Create directory manager
-	tempKey, err := crypto.GenerateKey("temp-key")
-	if err != nil {
-		return fmt.Errorf("failed to generate temp key: %w", err)
-	}
-
-	directoryManager, err := storage.NewDirectoryManager(storageManager, tempKey, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create directory manager: %w", err)
-	}
-
 	// Create snapshot
 	snapshotCID, snapshotKey, err := directoryManager.CreateDirectorySnapshot(
 		context.Background(),
@@ -1779,6 +2150,19 @@ func shareDirectoryCommand(args []string, storageManager *storage.Manager, quiet
 		return fmt.Errorf("failed to create directory snapshot: %w", err)
 	}
 
+	// Track the snapshot locally so retention can prune it later
+	record := storage.SnapshotRecord{
+		SnapshotCID:  snapshotCID,
+		OriginalCID:  directoryCID,
+		SnapshotName: snapshotName,
+		Description:  description,
+		CreatedAt:    time.Now(),
+	}
+	if manifest, err := directoryManager.RetrieveDirectoryManifestWithKey(context.Background(), snapshotCID, snapshotKey); err == nil {
+		record.References = storage.ManifestReferences(manifest)
+	}
+	recordSnapshot(record, quiet)
+
 	// Prepare result
 	result := ShareDirectoryResult{
 		SnapshotCID:  snapshotCID,
@@ -1811,17 +2195,15 @@ func shareDirectoryCommand(args []string, storageManager *storage.Manager, quiet
 
 // receiveDirectoryCommand accesses a shared directory snapshot
 func receiveDirectoryCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: receive-directory <snapshot-cid> <snapshot-key>")
+	flagSet := flag.NewFlagSet("receive-directory", flag.ContinueOnError)
+	identityFile := flagSet.String("identity", "", "Identity file used to open private group snapshots (default ~/.noisefs/identity.json)")
+	if err := flagSet.Parse(args); err != nil {
+		return err
 	}
+	args = flagSet.Args()
 
-	snapshotCID := args[0]
-	snapshotKeyStr := args[1]
-
-	// Parse the snapshot key
-	snapshotKey, err := crypto.ParseKeyFromString(snapshotKeyStr)
-	if err != nil {
-		return fmt.Errorf("failed to parse snapshot key: %w", err)
+	if len(args) < 1 {
+		return fmt.Errorf("usage: receive-directory <snapshot-cid> <snapshot-key> | receive-directory [--identity file] <key-envelope-cid>")
 	}
 
 	// Create directory manager
@@ -1835,6 +2217,30 @@ func receiveDirectoryCommand(args []string, storageManager *storage.Manager, qui
 		return fmt.Errorf("failed to create directory manager: %w", err)
 	}
 
+	var snapshotCID, keyEnvelopeCID string
+	var snapshotKey *crypto.EncryptionKey
+	var epoch uint64
+
+	if len(args) == 1 {
+		// A single argument is a private group key envelope
+		keyEnvelopeCID = args[0]
+		opened, err := openGroupSnapshot(directoryManager, keyEnvelopeCID, *identityFile)
+		if err != nil {
+			return err
+		}
+		snapshotCID = opened.SnapshotCID
+		snapshotKey = opened.Key
+		epoch = opened.Envelope.Epoch
+	} else {
+		snapshotCID = args[0]
+
+		// Parse the snapshot key
+		snapshotKey, err = crypto.ParseKeyFromString(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to parse snapshot key: %w", err)
+		}
+	}
+
 	// Retrieve snapshot manifest
 	manifest, err := directoryManager.RetrieveDirectoryManifestWithKey(
 		context.Background(),
@@ -1871,14 +2277,16 @@ func receiveDirectoryCommand(args []string, storageManager *storage.Manager, qui
 
 	// Prepare result
 	result := ReceiveDirectoryResult{
-		SnapshotCID:  snapshotCID,
-		SnapshotName: snapshotInfo.SnapshotName,
-		Description:  snapshotInfo.Description,
-		OriginalCID:  snapshotInfo.OriginalCID,
-		CreatedAt:    snapshotInfo.CreationTime,
-		Entries:      entries,
-		TotalEntries: len(entries),
-		IsSnapshot:   true,
+		SnapshotCID:    snapshotCID,
+		SnapshotName:   snapshotInfo.SnapshotName,
+		Description:    snapshotInfo.Description,
+		OriginalCID:    snapshotInfo.OriginalCID,
+		CreatedAt:      snapshotInfo.CreationTime,
+		Entries:        entries,
+		TotalEntries:   len(entries),
+		IsSnapshot:     true,
+		KeyEnvelopeCID: keyEnvelopeCID,
+		Epoch:          epoch,
 	}
 
 	// Output result
@@ -1919,71 +2327,107 @@ func receiveDirectoryCommand(args []string, storageManager *storage.Manager, qui
 	return nil
 }
 
-// listSnapshotsCommand lists all snapshots associated with a directory
-func listSnapshotsCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+// listSnapshotsCommand lists the snapshots of a directory and their retention status
+func listSnapshotsCommand(args []string, storageManager *storage.Manager, defaults config.SnapshotConfig, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("list-snapshots", flag.ContinueOnError)
+	retention := registerRetentionFlags(flagSet, defaults)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	args = flagSet.Args()
+
 	if len(args) < 1 {
-		return fmt.Errorf("usage: list-snapshots <directory-cid> [directory-key]")
+		return fmt.Errorf("usage: list-snapshots [--keep-last N] [--keep-daily N] [--keep-weekly N] <directory-cid> [directory-key]")
+	}
+
+	policy, err := retention.policy()
+	if err != nil {
+		return err
 	}
 
 	directoryCID := args[0]
 	var directoryKey *crypto.EncryptionKey
 
 	if len(args) > 1 {
-		var err error
 		directoryKey, err = crypto.ParseKeyFromString(args[1])
 		if err != nil {
 			return fmt.Errorf("failed to parse directory key: %w", err)
 		}
 	}
 
-	// Create directory manager
-	tempKey, err := crypto.GenerateKey("temp-key")
+	// Snapshots created on this device are tracked in the local registry
+	registry, err := openSnapshotRegistry()
 	if err != nil {
-		return fmt.Errorf("failed to generate temp key: %w", err)
+		return err
 	}
 
-	directoryManager, err := storage.NewDirectoryManager(storageManager, tempKey, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create directory manager: %w", err)
+	snapshots := make([]SnapshotInfo, 0)
+	for _, decision := range policy.Apply(registry.ForDirectory(directoryCID)) {
+		snapshots = append(snapshots, SnapshotInfo{
+			SnapshotCID:    decision.Record.SnapshotCID,
+			SnapshotName:   decision.Record.SnapshotName,
+			Description:    decision.Record.Description,
+			OriginalCID:    decision.Record.OriginalCID,
+			CreatedAt:      decision.Record.CreatedAt,
+			IsSnapshot:     true,
+			KeyEnvelopeCID: decision.Record.KeyEnvelopeCID,
+			Expired:        !decision.Keep,
+			KeepReasons:    decision.Reasons,
+		})
 	}
 
-	// For now, we can only list the original directory info
-	// In a full implementation, we would need a snapshot index or registry
-	var manifest *blocks.DirectoryManifest
-	if directoryKey != nil {
-		manifest, err = directoryManager.RetrieveDirectoryManifestWithKey(
-			context.Background(),
-			directoryCID,
-			directoryKey,
-		)
-	} else {
-		// Use default key (this is a limitation - in practice, the key would be required)
-		manifest, err = directoryManager.RetrieveDirectoryManifest(
-			context.Background(),
-			"",
-			directoryCID,
-		)
-	}
+	// Without registered snapshots, check whether the CID itself is a snapshot
+	if len(snapshots) == 0 {
+		// Create directory manager
+		tempKey, err := crypto.GenerateKey("temp-key")
+		if err != nil {
+			return fmt.Errorf("failed to generate temp key: %w", err)
+		}
 
-	if err != nil {
-		return fmt.Errorf("failed to retrieve directory manifest: %w", err)
-	}
+		directoryManager, err := storage.NewDirectoryManager(storageManager, tempKey, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create directory manager: %w", err)
+		}
 
-	// Prepare result
-	snapshots := make([]SnapshotInfo, 0)
+		var manifest *blocks.DirectoryManifest
+		if directoryKey != nil {
+			manifest, err = directoryManager.RetrieveDirectoryManifestWithKey(
+				context.Background(),
+				directoryCID,
+				directoryKey,
+			)
+		} else {
+			// Use default key (this is a limitation - in practice, the key would be required)
+			manifest, err = directoryManager.RetrieveDirectoryManifest(
+				context.Background(),
+				"",
+				directoryCID,
+			)
+		}
 
-	// If this is a snapshot, include it in the list
-	if manifest.IsSnapshot() {
-		snapshotInfo := manifest.GetSnapshotInfo()
-		if snapshotInfo != nil {
-			snapshots = append(snapshots, SnapshotInfo{
-				SnapshotCID:  directoryCID,
-				SnapshotName: snapshotInfo.SnapshotName,
-				Description:  snapshotInfo.Description,
-				OriginalCID:  snapshotInfo.OriginalCID,
-				CreatedAt:    snapshotInfo.CreationTime,
-				IsSnapshot:   true,
-			})
+		if err != nil {
+			return fmt.Errorf("failed to retrieve directory manifest: %w", err)
+		}
+
+		if manifest.IsSnapshot() {
+			snapshotInfo := manifest.GetSnapshotInfo()
+			if snapshotInfo != nil {
+				snapshots = append(snapshots, SnapshotInfo{
+					SnapshotCID:  directoryCID,
+					SnapshotName: snapshotInfo.SnapshotName,
+					Description:  snapshotInfo.Description,
+					OriginalCID:  snapshotInfo.OriginalCID,
+					CreatedAt:    snapshotInfo.CreationTime,
+					IsSnapshot:   true,
+				})
+			}
+		}
+	}
+
+	expired := 0
+	for _, snapshot := range snapshots {
+		if snapshot.Expired {
+			expired++
 		}
 	}
 
@@ -1991,6 +2435,8 @@ func listSnapshotsCommand(args []string, storageManager *storage.Manager, quiet
 		DirectoryCID:   directoryCID,
 		Snapshots:      snapshots,
 		TotalSnapshots: len(snapshots),
+		Expired:        expired,
+		Policy:         policy,
 	}
 
 	// Output result
@@ -1998,16 +2444,20 @@ func listSnapshotsCommand(args []string, storageManager *storage.Manager, quiet
 		util.PrintJSONSuccess(result)
 	} else if quiet {
 		for _, snapshot := range snapshots {
-			fmt.Printf("%s\t%s\t%s\n", snapshot.SnapshotCID, snapshot.SnapshotName, snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
+			status := "keep"
+			if snapshot.Expired {
+				status = "expired"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", snapshot.SnapshotCID, snapshot.SnapshotName, snapshot.CreatedAt.Format("2006-01-02 15:04:05"), status)
 		}
 	} else {
 		fmt.Printf("Directory: %s\n", directoryCID)
-		fmt.Printf("Snapshots: %d\n\n", len(snapshots))
+		fmt.Printf("Snapshots: %d (%d expired)\n", len(snapshots), expired)
+		fmt.Printf("Retention: keep-last %d, keep-daily %d, keep-weekly %d\n\n", policy.KeepLast, policy.KeepDaily, policy.KeepWeekly)
 
 		if len(snapshots) == 0 {
 			fmt.Printf("No snapshots found.\n")
-			fmt.Printf("Note: This command currently only detects if the provided CID is itself a snapshot.\n")
-			fmt.Printf("A full snapshot registry would be needed to list all snapshots of a directory.\n")
+			fmt.Printf("Note: only snapshots created with share-directory on this device are tracked.\n")
 		} else {
 			for _, snapshot := range snapshots {
 				fmt.Printf("Snapshot: %s\n", snapshot.SnapshotCID)
@@ -2015,8 +2465,16 @@ func listSnapshotsCommand(args []string, storageManager *storage.Manager, quiet
 				if snapshot.Description != "" {
 					fmt.Printf("  Description: %s\n", snapshot.Description)
 				}
+				if snapshot.KeyEnvelopeCID != "" {
+					fmt.Printf("  Key Envelope: %s\n", snapshot.KeyEnvelopeCID)
+				}
 				fmt.Printf("  Original CID: %s\n", snapshot.OriginalCID)
 				fmt.Printf("  Created: %s\n", snapshot.CreatedAt.Format("2006-01-02 15:04:05"))
+				if snapshot.Expired {
+					fmt.Printf("  Retention: expired (removed by prune-snapshots)\n")
+				} else if len(snapshot.KeepReasons) > 0 {
+					fmt.Printf("  Retention: kept (%s)\n", strings.Join(snapshot.KeepReasons, ", "))
+				}
 				fmt.Printf("\n")
 			}
 		}
@@ -2044,6 +2502,9 @@ type ReceiveDirectoryResult struct {
 	Entries      []DirectoryListEntry `json:"entries"`
 	TotalEntries int                  `json:"total_entries"`
 	IsSnapshot   bool                 `json:"is_snapshot"`
+	// Set when the snapshot was opened through a private group key envelope
+	KeyEnvelopeCID string `json:"key_envelope_cid,omitempty"`
+	Epoch          uint64 `json:"epoch,omitempty"`
 }
 
 type SnapshotInfo struct {
@@ -2053,10 +2514,16 @@ type SnapshotInfo struct {
 	OriginalCID  string    `json:"original_cid"`
 	CreatedAt    time.Time `json:"created_at"`
 	IsSnapshot   bool      `json:"is_snapshot"`
+	// Retention status for snapshots tracked in the local registry
+	KeyEnvelopeCID string   `json:"key_envelope_cid,omitempty"`
+	Expired        bool     `json:"expired"`
+	KeepReasons    []string `json:"keep_reasons,omitempty"`
 }
 
 type ListSnapshotsResult struct {
-	DirectoryCID   string         `json:"directory_cid"`
-	Snapshots      []SnapshotInfo `json:"snapshots"`
-	TotalSnapshots int            `json:"total_snapshots"`
+	DirectoryCID   string                  `json:"directory_cid"`
+	Snapshots      []SnapshotInfo          `json:"snapshots"`
+	TotalSnapshots int                     `json:"total_snapshots"`
+	Expired        int                     `json:"expired"`
+	Policy         storage.RetentionPolicy `json:"policy"`
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// flushOfflineSpool stores the blocks spooled while storage was
+// unreachable before this run goes on, as the manager otherwise flushes
+// them in the background and the process may exit first
+func flushOfflineSpool(manager *storage.Manager, logger *logging.Logger) {
+	status, ok := manager.OfflineStatus()
+	if !ok {
+		return
+	}
+	if len(manager.GetAvailableBackends()) == 0 {
+		logger.Warn("No storage backend reachable; uploads are spooled until it is", map[string]interface{}{
+			"spooled_blocks": status.Pending,
+		})
+		return
+	}
+	if status.Pending == 0 {
+		return
+	}
+
+	flushed, err := manager.FlushOffline(context.Background())
+	if err != nil {
+		logger.Warn("Failed to store spooled blocks", map[string]interface{}{
+			"stored": flushed,
+			"error":  err.Error(),
+		})
+		return
+	}
+	logger.Info("Stored blocks spooled while offline", map[string]interface{}{
+		"stored": flushed,
+	})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// newPackClient creates a client using the configured block size policy,
+// retrieval mixing and content processors
+func newPackClient(storageManager *storage.Manager, cfg *config.Config) (*noisefs.Client, error) {
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(cfg.Cache.BlockCacheSize))
+	if err != nil {
+		return nil, err
+	}
+	policy, err := cfg.Blocks.SizePolicy()
+	if err != nil {
+		return nil, err
+	}
+	client.SetBlockSizePolicy(policy)
+	if err := configureRetrieval(client, cfg); err != nil {
+		return nil, err
+	}
+	if err := configureProcessors(client, cfg); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// configureRetrieval sets how the client fetches download blocks: through
+// retrieval mixing and with speculative fetches from a second backend, when
+// the configuration enables them
+func configureRetrieval(client *noisefs.Client, cfg *config.Config) error {
+	delay, err := cfg.Network.SpeculativeFetchDelayDuration()
+	if err != nil {
+		return fmt.Errorf("invalid speculative fetch delay: %w", err)
+	}
+	client.SetSpeculativeFetch(delay)
+
+	if !cfg.RetrievalMixing.Enabled {
+		return nil
+	}
+	schedulerConfig, err := cfg.RetrievalMixing.SchedulerConfig()
+	if err != nil {
+		return err
+	}
+	return client.SetRetrievalMixing(&schedulerConfig)
+}
+
+// packCommand uploads many small files into shared blocks
+func packCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("pack", flag.ContinueOnError)
+	name := flagSet.String("name", "files.pack", "Name of the pack")
+	maxFileSize := flagSet.String("max-file-size", "256KB", "Largest file to pack; upload bigger files with -upload")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs pack [-name notes.pack] <file|directory>...")
+		fmt.Fprintln(flagSet.Output(), "Directories contribute the regular files directly inside them.")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return fmt.Errorf("at least one file is required")
+	}
+
+	limit, err := util.ParseSize(*maxFileSize)
+	if err != nil {
+		return fmt.Errorf("invalid -max-file-size: %w", err)
+	}
+
+	paths, err := packPaths(flagSet.Args())
+	if err != nil {
+		return err
+	}
+
+	files := make([]noisefs.PackFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Size() > limit {
+			return fmt.Errorf("%s is %s, over the %s pack limit; upload it with -upload", path, util.FormatSize(info.Size()), util.FormatSize(limit))
+		}
+		if err := enforceContentPolicy(cfg, path); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		files = append(files, noisefs.PackFile{Name: filepath.Base(path), Data: data})
+	}
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	packCID, err := client.UploadPack(ctx, *name, files)
+	if err != nil {
+		return err
+	}
+	pack, err := client.LoadPack(ctx, packCID)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{
+			"pack_cid":   packCID,
+			"name":       pack.Filename,
+			"block_size": pack.BlockSize,
+			"blocks":     len(pack.Blocks),
+			"entries":    pack.Entries,
+		})
+		return nil
+	}
+	if quiet {
+		fmt.Println(packCID)
+		return nil
+	}
+
+	fmt.Printf("Packed %d files (%s) into %d blocks of %s\n", len(pack.Entries), util.FormatSize(pack.FileSize), len(pack.Blocks), util.FormatSize(int64(pack.BlockSize)))
+	fmt.Printf("Pack CID: %s\n", packCID)
+	return nil
+}
+
+// packPaths expands directories to the regular files directly inside them
+// and rejects names that would collide inside the pack
+func packPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				paths = append(paths, filepath.Join(arg, entry.Name()))
+			}
+		}
+	}
+
+	seen := make(map[string]string, len(paths))
+	for _, path := range paths {
+		base := filepath.Base(path)
+		if previous, exists := seen[base]; exists {
+			return nil, fmt.Errorf("%s and %s have the same name; a pack holds one file per name", previous, path)
+		}
+		seen[base] = path
+	}
+	return paths, nil
+}
+
+// unpackCommand lists or extracts the files of a pack
+func unpackCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("unpack", flag.ContinueOnError)
+	outputDir := flagSet.String("o", ".", "Directory to extract into")
+	only := flagSet.String("file", "", "Extract only this file")
+	list := flagSet.Bool("list", false, "List the files without extracting")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs unpack [-o dir] [-file name] [-list] <pack-cid>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one pack CID is required")
+	}
+	packCID := flagSet.Arg(0)
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	pack, err := client.LoadPack(ctx, packCID)
+	if err != nil {
+		return err
+	}
+
+	entries := pack.Entries
+	if *only != "" {
+		entry, ok := pack.FindPackEntry(*only)
+		if !ok {
+			return fmt.Errorf("pack %s does not contain %s", packCID, *only)
+		}
+		entries = []descriptors.PackEntry{entry}
+	}
+
+	if *list {
+		if jsonOutput {
+			util.PrintJSONSuccess(map[string]interface{}{"pack_cid": packCID, "name": pack.Filename, "entries": entries})
+			return nil
+		}
+		for _, entry := range entries {
+			if quiet {
+				fmt.Println(entry.Filename)
+			} else {
+				fmt.Printf("%10s  %s\n", util.FormatSize(entry.Size), entry.Filename)
+			}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	var extracted []string
+	for _, entry := range entries {
+		data, err := client.DownloadPackedFile(ctx, packCID, entry.Filename)
+		if err != nil {
+			return err
+		}
+		path, err := writePackedFile(*outputDir, entry.Filename, data)
+		if err != nil {
+			return err
+		}
+		extracted = append(extracted, path)
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{"pack_cid": packCID, "extracted": extracted})
+		return nil
+	}
+	if !quiet {
+		fmt.Printf("Extracted %d files from %s to %s\n", len(extracted), pack.Filename, *outputDir)
+	}
+	return nil
+}
+
+// writePackedFile writes a packed file into dir and returns its path
+func writePackedFile(dir, name string, data []byte) (string, error) {
+	// Names come from the descriptor, so don't let one escape the output directory
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("refusing to extract unsafe filename %q", name)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// pathPatterns collects repeated -path flags
+type pathPatterns []string
+
+func (p *pathPatterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pathPatterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// downloadPackFiles extracts the files of a pack that filter selects into
+// outputDir, for "noisefs -download <cid> -path <glob>". Only the blocks
+// holding those files are retrieved.
+func downloadPackFiles(client *noisefs.Client, packCID string, outputDir string, filter *descriptors.PathFilter, quiet bool, jsonOutput bool) error {
+	ctx := context.Background()
+	if _, err := client.LoadPack(ctx, packCID); err != nil {
+		return fmt.Errorf("-path needs a pack or directory descriptor: %w", err)
+	}
+	files, err := client.DownloadPackedFiles(ctx, packCID, filter)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	extracted := make([]string, 0, len(files))
+	var total int64
+	for _, file := range files {
+		path, err := writePackedFile(outputDir, file.Name, file.Data)
+		if err != nil {
+			return err
+		}
+		extracted = append(extracted, path)
+		total += int64(len(file.Data))
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{"pack_cid": packCID, "extracted": extracted, "total_size": total})
+		return nil
+	}
+	if quiet {
+		fmt.Printf("Downloaded %d files to %s\n", len(extracted), outputDir)
+		return nil
+	}
+	for _, path := range extracted {
+		fmt.Printf("  %s\n", path)
+	}
+	fmt.Printf("Downloaded %d files (%s) from pack %s to %s\n", len(extracted), util.FormatSize(total), packCID, outputDir)
+	return nil
+}
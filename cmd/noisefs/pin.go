@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// BlockReplicas is the replica status of one block of a pinned descriptor
+type BlockReplicas struct {
+	CID      string   `json:"cid"`
+	Role     string   `json:"role"`
+	Replicas int      `json:"replicas"`
+	Backends []string `json:"backends"`
+}
+
+// PinStatus is the output of pin ls for one descriptor
+type PinStatus struct {
+	DescriptorCID   string             `json:"descriptor_cid"`
+	Pin             *storage.PinRecord `json:"pin,omitempty"`
+	Target          int                `json:"target"`
+	UnderReplicated int                `json:"under_replicated"`
+	Blocks          []BlockReplicas    `json:"blocks"`
+}
+
+// pinCommand pins descriptors and their blocks across backends, unpins
+// them and reports how well they are replicated
+func pinCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pin add|rm|ls [options]")
+	}
+	switch args[0] {
+	case "add":
+		return pinAddCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	case "rm":
+		return pinRemoveCommand(args[1:], storageManager, quiet, jsonOutput)
+	case "ls":
+		return pinListCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	default:
+		return fmt.Errorf("unknown pin command %q (use add, rm or ls)", args[0])
+	}
+}
+
+// openPinRegistry opens the local registry of pinned descriptors
+func openPinRegistry() (*storage.PinRegistry, error) {
+	path, err := storage.DefaultPinRegistryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewPinRegistry(path)
+}
+
+// loadPinnedDescriptor loads a descriptor to pin or inspect, reading
+// encrypted descriptors with the bundle password
+func loadPinnedDescriptor(storageManager *storage.Manager, cfg *config.Config, descriptorCID string) (*descriptors.Descriptor, error) {
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, _, err := client.LoadDescriptor(descriptorCID, os.Getenv(bundlePasswordEnv))
+	return descriptor, err
+}
+
+// splitBackendList parses a comma-separated list of backend names
+func splitBackendList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// pinAddCommand pins a descriptor and the blocks it references on the
+// backends the policy selects
+func pinAddCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("pin add", flag.ContinueOnError)
+	descriptorCID := flagSet.String("descriptor", "", "Descriptor CID to pin (required)")
+	replicas := flagSet.Int("replicas", 0, "Number of backends to pin on, highest priority first (0 pins on every connected backend)")
+	backendList := flagSet.String("backends", "", "Comma-separated backends to pin on instead of choosing by priority")
+	randomizers := flagSet.Bool("randomizers", true, "Also pin the randomizer blocks")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs pin add -descriptor CID [-replicas N] [-backends a,b] [-randomizers=false]")
+		fmt.Fprintf(flagSet.Output(), "Encrypted descriptors are read with the password in $%s.\n", bundlePasswordEnv)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *descriptorCID == "" || flagSet.NArg() != 0 {
+		flagSet.Usage()
+		return fmt.Errorf("-descriptor is required")
+	}
+
+	policy := storage.PinPolicy{Replicas: *replicas, Backends: splitBackendList(*backendList), Randomizers: *randomizers}
+	targets, err := storageManager.PinTargets(policy)
+	if err != nil {
+		return err
+	}
+	descriptor, err := loadPinnedDescriptor(storageManager, cfg, *descriptorCID)
+	if err != nil {
+		return err
+	}
+
+	record := storage.PinRecord{
+		DescriptorCID: *descriptorCID,
+		Name:          descriptor.Filename,
+		Policy:        policy,
+		Backends:      targets,
+		Blocks:        []string{*descriptorCID},
+		PinnedAt:      time.Now().UTC(),
+	}
+	for _, reference := range descriptor.BlockReferences() {
+		if reference.Role != descriptors.BlockRoleRandomizer {
+			record.Blocks = append(record.Blocks, reference.CID)
+		} else if policy.Randomizers {
+			record.Randomizers = append(record.Randomizers, reference.CID)
+		}
+	}
+
+	ctx := context.Background()
+	var failures []string
+	for _, backend := range targets {
+		for _, cid := range append(append([]string{}, record.Blocks...), record.Randomizers...) {
+			if err := storageManager.PinIn(ctx, backend, &storage.BlockAddress{ID: cid}); err != nil {
+				failures = append(failures, fmt.Sprintf("%s on %s: %v", cid, backend, err))
+			}
+		}
+	}
+	var pinErr error
+	if len(failures) > 0 {
+		pinErr = fmt.Errorf("failed to pin %d blocks: %s", len(failures), strings.Join(failures, "; "))
+	}
+
+	// The pin is recorded even when some blocks failed, so that rm can
+	// release the ones that were pinned
+	registry, err := openPinRegistry()
+	if err == nil {
+		err = registry.Add(record)
+	}
+	if err != nil {
+		return err
+	}
+	recordAudit(logging.AuditPin, *descriptorCID, pinErr, map[string]string{
+		"backends": strings.Join(targets, ","),
+		"blocks":   strconv.Itoa(len(record.Blocks) + len(record.Randomizers)),
+	})
+
+	if jsonOutput {
+		util.PrintJSONSuccess(record)
+		return pinErr
+	}
+	if !quiet {
+		fmt.Printf("Pinned %s (%d blocks, %d randomizers) on %s\n", *descriptorCID, len(record.Blocks), len(record.Randomizers), strings.Join(targets, ", "))
+	}
+	return pinErr
+}
+
+// pinRemoveCommand unpins a descriptor and the blocks no other pin holds
+func pinRemoveCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("pin rm", flag.ContinueOnError)
+	descriptorCID := flagSet.String("descriptor", "", "Descriptor CID to unpin (required)")
+	randomizers := flagSet.Bool("randomizers", false, "Also unpin the randomizer blocks, which other files may share")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs pin rm -descriptor CID [-randomizers]")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *descriptorCID == "" || flagSet.NArg() != 0 {
+		flagSet.Usage()
+		return fmt.Errorf("-descriptor is required")
+	}
+
+	registry, err := openPinRegistry()
+	if err != nil {
+		return err
+	}
+	record, ok := registry.Get(*descriptorCID)
+	if !ok {
+		return fmt.Errorf("descriptor %s is not pinned", *descriptorCID)
+	}
+	release, releaseRandomizers, err := registry.Releasable(*descriptorCID)
+	if err != nil {
+		return err
+	}
+	if *randomizers {
+		release = append(release, releaseRandomizers...)
+	}
+
+	ctx := context.Background()
+	var failures []string
+	for _, backend := range record.Backends {
+		for _, cid := range release {
+			if err := storageManager.UnpinIn(ctx, backend, &storage.BlockAddress{ID: cid}); err != nil {
+				failures = append(failures, fmt.Sprintf("%s on %s: %v", cid, backend, err))
+			}
+		}
+	}
+
+	// The record goes even if some blocks couldn't be unpinned, since
+	// unpinning them again would fail on the backends that did release
+	// them; pin add and rm again to retry
+	if err = registry.Remove(*descriptorCID); err == nil && len(failures) > 0 {
+		err = fmt.Errorf("failed to unpin %d blocks: %s", len(failures), strings.Join(failures, "; "))
+	}
+	recordAudit(logging.AuditUnpin, *descriptorCID, err, map[string]string{
+		"backends": strings.Join(record.Backends, ","),
+		"blocks":   strconv.Itoa(len(release)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{"descriptor_cid": *descriptorCID, "unpinned": release})
+	} else if !quiet {
+		fmt.Printf("Unpinned %s: released %d blocks; run 'ipfs repo gc' to reclaim their space.\n", *descriptorCID, len(release))
+		if kept := len(record.Blocks) + len(record.Randomizers) - len(release); kept > 0 {
+			fmt.Printf("%d blocks stay pinned because other pins or files share them.\n", kept)
+		}
+	}
+	return nil
+}
+
+// pinListCommand lists pinned descriptors, or shows how many backends hold
+// each block of one descriptor
+func pinListCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("pin ls", flag.ContinueOnError)
+	descriptorCID := flagSet.String("descriptor", "", "Show the replica count of each block of this descriptor")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs pin ls [-descriptor CID]")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	registry, err := openPinRegistry()
+	if err != nil {
+		return err
+	}
+	if *descriptorCID != "" {
+		return pinStatusCommand(registry, storageManager, cfg, *descriptorCID, quiet, jsonOutput)
+	}
+
+	records := registry.All()
+	if jsonOutput {
+		util.PrintJSONSuccess(records)
+		return nil
+	}
+	if len(records) == 0 && !quiet {
+		fmt.Println("No pinned descriptors")
+		return nil
+	}
+	for _, record := range records {
+		if quiet {
+			fmt.Println(record.DescriptorCID)
+			continue
+		}
+		fmt.Printf("%s  %s\n", record.DescriptorCID, record.Name)
+		fmt.Printf("  Pinned: %s on %s\n", record.PinnedAt.Local().Format(time.RFC1123), strings.Join(record.Backends, ", "))
+		fmt.Printf("  Blocks: %d, randomizers: %d\n", len(record.Blocks), len(record.Randomizers))
+	}
+	return nil
+}
+
+// pinStatusCommand reports the replicas of each block of a descriptor,
+// pinned or not. The target is the number of backends the pin was placed
+// on, or every connected backend for descriptors that aren't pinned.
+func pinStatusCommand(registry *storage.PinRegistry, storageManager *storage.Manager, cfg *config.Config, descriptorCID string, quiet bool, jsonOutput bool) error {
+	descriptor, err := loadPinnedDescriptor(storageManager, cfg, descriptorCID)
+	if err != nil {
+		return err
+	}
+
+	status := &PinStatus{DescriptorCID: descriptorCID, Target: len(storageManager.GetAvailableBackends())}
+	if record, ok := registry.Get(descriptorCID); ok {
+		status.Pin = &record
+		status.Target = len(record.Backends)
+	}
+
+	ctx := context.Background()
+	references := append([]descriptors.BlockReference{{CID: descriptorCID, Role: descriptors.BlockRoleDescriptor}}, descriptor.BlockReferences()...)
+	for _, reference := range references {
+		backends := storageManager.Replicas(ctx, &storage.BlockAddress{ID: reference.CID})
+		if backends == nil {
+			backends = []string{}
+		}
+		if len(backends) < status.Target {
+			status.UnderReplicated++
+		}
+		status.Blocks = append(status.Blocks, BlockReplicas{
+			CID:      reference.CID,
+			Role:     reference.Role,
+			Replicas: len(backends),
+			Backends: backends,
+		})
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(status)
+		return nil
+	}
+	if quiet {
+		fmt.Println(status.UnderReplicated)
+		return nil
+	}
+
+	if status.Pin != nil {
+		fmt.Printf("%s  %s, pinned on %s\n", descriptorCID, descriptor.Filename, strings.Join(status.Pin.Backends, ", "))
+	} else {
+		fmt.Printf("%s  %s, not pinned\n", descriptorCID, descriptor.Filename)
+	}
+	fmt.Printf("%-10s  %-8s  %s\n", "ROLE", "REPLICAS", "CID")
+	for _, block := range status.Blocks {
+		fmt.Printf("%-10s  %-8s  %s\n", block.Role, fmt.Sprintf("%d/%d", block.Replicas, status.Target), block.CID)
+	}
+	fmt.Printf("%d of %d blocks have fewer than %d replicas\n", status.UnderReplicated, len(status.Blocks), status.Target)
+	return nil
+}
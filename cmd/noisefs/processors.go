@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// configureProcessors runs the configured content processors on the
+// client's uploads and downloads
+func configureProcessors(client *noisefs.Client, cfg *config.Config) error {
+	pipeline, err := cfg.ProcessorPipeline()
+	if err != nil {
+		return err
+	}
+	client.SetProcessors(pipeline)
+	return nil
+}
+
+// processDownloaded runs the download processors on a downloaded file,
+// replacing it with what they produce. A file that can't be processed is
+// removed, so a refused download leaves nothing behind.
+func processDownloaded(client *noisefs.Client, filename, outputPath string) error {
+	output, err := client.Processors().Process(context.Background(), processors.StageDownload, filename, outputPath)
+	if err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+	defer output.Close()
+	if !output.Replaced {
+		return nil
+	}
+	return copyFile(output.Path, outputPath)
+}
+
+// copyFile replaces dst with the content of src
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// processResult is the JSON output of the process command
+type processResult struct {
+	Stage    string            `json:"stage"`
+	Filename string            `json:"filename"`
+	Replaced bool              `json:"replaced"`
+	Output   string            `json:"output,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// processCommand runs the configured content processors on a local file,
+// so a pipeline can be tried out before files go through it
+func processCommand(args []string, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("process", flag.ContinueOnError)
+	stage := flagSet.String("stage", string(processors.StageUpload), "Stage to run: upload or download")
+	name := flagSet.String("name", "", "Name to process the file under (default: its base name)")
+	outputPath := flagSet.String("output", "", "Write the processed content here when a processor replaces it")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs process [-stage upload|download] [-name song.mp3] [-output path] <file>")
+		fmt.Fprintln(flagSet.Output(), "Runs the configured processors on a file without uploading or downloading it.")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("process takes one file")
+	}
+	if *stage != string(processors.StageUpload) && *stage != string(processors.StageDownload) {
+		return fmt.Errorf("invalid stage '%s'. Valid stages: upload, download", *stage)
+	}
+
+	path := flagSet.Arg(0)
+	if *name == "" {
+		*name = filepath.Base(path)
+	}
+	pipeline, err := cfg.ProcessorPipeline()
+	if err != nil {
+		return err
+	}
+	if !pipeline.Applies(processors.Stage(*stage), *name) && !quiet && !jsonOutput {
+		fmt.Printf("No processor runs on %s at %s\n", *name, *stage)
+	}
+
+	output, err := pipeline.Process(context.Background(), processors.Stage(*stage), *name, path)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	result := processResult{Stage: *stage, Filename: *name, Replaced: output.Replaced, Metadata: output.Metadata}
+	if output.Replaced && *outputPath != "" {
+		if err := copyFile(output.Path, *outputPath); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		result.Output = *outputPath
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+	if quiet {
+		return nil
+	}
+	fmt.Printf("Accepted %s\n", *name)
+	if output.Replaced {
+		if result.Output != "" {
+			fmt.Printf("Content replaced, written to %s\n", result.Output)
+		} else {
+			fmt.Println("Content replaced; pass -output to keep it")
+		}
+	}
+	keys := make([]string, 0, len(output.Metadata))
+	for key := range output.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s: %s\n", key, output.Metadata[key])
+	}
+	return nil
+}
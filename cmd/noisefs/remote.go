@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/gateway/remote"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// RemoteConfigResult is the output of remote config
+type RemoteConfigResult struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options"`
+}
+
+// remoteNeedsStorage reports whether a remote command stores or retrieves
+// files; the others only need the configuration
+func remoteNeedsStorage(args []string) bool {
+	return len(args) > 0 && args[0] == "serve"
+}
+
+// remoteCommand serves the remote protocol for sync tool backends, and
+// prints its specification and the settings of a remote pointing at this
+// node. storageManager is only used by serve.
+func remoteCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: remote serve|spec|config [options]")
+	}
+	switch args[0] {
+	case "serve":
+		return remoteServe(args[1:], storageManager, cfg, quiet, jsonOutput)
+	case "spec":
+		return remoteSpec(args[1:], quiet, jsonOutput)
+	case "config":
+		return remoteConfig(args[1:], cfg, jsonOutput)
+	default:
+		return fmt.Errorf("unknown remote command %q (use serve, spec or config)", args[0])
+	}
+}
+
+// remoteFlagSet returns a flag set accepting the global flags
+func remoteFlagSet(action, usage string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet("remote "+action, flag.ContinueOnError)
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs remote "+action+" "+usage)
+		flagSet.PrintDefaults()
+	}
+	return flagSet
+}
+
+func remoteServe(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := remoteFlagSet("serve", "[-address 127.0.0.1:9001]")
+	address := flagSet.String("address", cfg.Remote.Address, "Listen address")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Remote.Token == "" && !isLoopbackAddress(*address) {
+		// Anyone reaching the server could read and replace every file
+		return fmt.Errorf("the remote server only listens on %s with a token; set remote.token or listen on 127.0.0.1", *address)
+	}
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return err
+	}
+	defer index.Cleanup()
+
+	server, err := remote.New(client, index, cfg.Remote.Token)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if cfg.Remote.CertFile != "" {
+		scheme = "https"
+	}
+	if !quiet && !jsonOutput {
+		fmt.Printf("Serving %s at %s://%s (Ctrl+C to stop)\n", index.GetIndexPath(), scheme, listener.Addr())
+	}
+	return serveUntilInterrupted(listener, server, cfg.Remote.CertFile, cfg.Remote.KeyFile)
+}
+
+func remoteSpec(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := remoteFlagSet("spec", "[-format markdown|openapi] [-o file]")
+	format := flagSet.String("format", "markdown", "Output format: markdown or openapi")
+	output := flagSet.String("o", "", "Write to this file instead of standard output")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	var spec []byte
+	switch *format {
+	case "markdown":
+		spec = remote.Markdown()
+	case "openapi":
+		var err error
+		if spec, err = remote.OpenAPI(); err != nil {
+			return err
+		}
+		spec = append(spec, '\n')
+	default:
+		return fmt.Errorf("unknown format %q (use markdown or openapi)", *format)
+	}
+
+	if *output == "" {
+		_, err := os.Stdout.Write(spec)
+		return err
+	}
+	if err := os.WriteFile(*output, spec, 0644); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return util.PrintJSON(map[string]string{"path": *output, "format": *format})
+	}
+	if !quiet {
+		fmt.Printf("Wrote %s\n", *output)
+	}
+	return nil
+}
+
+// remoteConfig prints an rclone-style remote pointing at this node's
+// remote server
+func remoteConfig(args []string, cfg *config.Config, jsonOutput bool) error {
+	flagSet := remoteFlagSet("config", "[-name noisefs] [-show-token]")
+	name := flagSet.String("name", "noisefs", "Name of the remote")
+	showToken := flagSet.Bool("show-token", false, "Include remote.token instead of a placeholder")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	options := map[string]string{"url": remoteURL(cfg.Remote)}
+	token := ""
+	if cfg.Remote.Token != "" {
+		token = "<remote.token>"
+		if *showToken {
+			token = cfg.Remote.Token
+		}
+		options["token"] = token
+	}
+
+	if jsonOutput {
+		return util.PrintJSON(RemoteConfigResult{Name: *name, Type: "noisefs", Options: options})
+	}
+	fmt.Printf("[%s]\n", *name)
+	fmt.Println("type = noisefs")
+	fmt.Printf("url = %s\n", options["url"])
+	if token != "" {
+		fmt.Printf("token = %s\n", token)
+	}
+	return nil
+}
+
+// remoteURL is the URL clients on this host reach the remote server at
+func remoteURL(remoteConfig config.RemoteConfig) string {
+	scheme := "http"
+	if remoteConfig.CertFile != "" {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(remoteConfig.Address)
+	if err != nil {
+		return scheme + "://" + remoteConfig.Address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/gateway/s3"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// s3GatewayCommand serves the file index as an S3 bucket until interrupted
+func s3GatewayCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("s3-gateway", flag.ContinueOnError)
+	address := flagSet.String("address", cfg.S3Gateway.Address, "Listen address")
+	bucket := flagSet.String("bucket", cfg.S3Gateway.Bucket, "Bucket name the file index is served as")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs s3-gateway [-address 127.0.0.1:9000] [-bucket noisefs]")
+		fmt.Fprintln(flagSet.Output(), "Set s3_gateway.access_key and secret_key to accept signed requests from other hosts.")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if cfg.S3Gateway.AccessKey == "" && !isLoopbackAddress(*address) {
+		// Anyone reaching the gateway could read and replace every file
+		return fmt.Errorf("the S3 gateway only listens on %s without credentials; set s3_gateway.access_key and secret_key or listen on 127.0.0.1", *address)
+	}
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return err
+	}
+	defer index.Cleanup()
+
+	gateway, err := s3.New(client, index, s3.Config{
+		Bucket:    *bucket,
+		Region:    cfg.S3Gateway.Region,
+		AccessKey: cfg.S3Gateway.AccessKey,
+		SecretKey: cfg.S3Gateway.SecretKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		return err
+	}
+
+	scheme := "http"
+	if cfg.S3Gateway.CertFile != "" {
+		scheme = "https"
+	}
+	if !quiet && !jsonOutput {
+		fmt.Printf("Serving %s as bucket %q at %s://%s (Ctrl+C to stop)\n", index.GetIndexPath(), *bucket, scheme, listener.Addr())
+	}
+	return serveUntilInterrupted(listener, gateway, cfg.S3Gateway.CertFile, cfg.S3Gateway.KeyFile)
+}
@@ -36,11 +36,7 @@ func (a *fileIndexAdapter) ListFiles() map[string]interface{} {
 	entries := a.fileIndex.ListFiles()
 	result := make(map[string]interface{})
 	for k, v := range entries {
-		result[k] = map[string]interface{}{
-			"DescriptorCID": v.DescriptorCID,
-			"FileSize":      v.FileSize,
-			"ModifiedAt":    v.ModifiedAt,
-		}
+		result[k] = entryMap(v)
 	}
 	return result
 }
@@ -50,11 +46,20 @@ func (a *fileIndexAdapter) GetFile(path string) (interface{}, bool) {
 	if !exists {
 		return nil, false
 	}
+	return entryMap(entry), true
+}
+
+// entryMap converts an index entry to the fields the search package reads
+func entryMap(entry *fuse.IndexEntry) map[string]interface{} {
 	return map[string]interface{}{
 		"DescriptorCID": entry.DescriptorCID,
 		"FileSize":      entry.FileSize,
 		"ModifiedAt":    entry.ModifiedAt,
-	}, true
+		"MimeType":      entry.MimeType,
+		"Tags":          entry.Tags,
+		"ContentHash":   entry.ContentHash,
+		"Encrypted":     entry.Encrypted,
+	}
 }
 
 func (a *fileIndexAdapter) GetDirectory(path string) ([]interface{}, bool) {
@@ -83,6 +88,8 @@ func handleSearchCommand(args []string) {
 	pathPattern := searchCmd.String("path", "", "Search by path pattern")
 	directory := searchCmd.String("dir", "", "Search within specific directory")
 	recursive := searchCmd.Bool("r", true, "Search recursively in directories")
+	local := searchCmd.Bool("local", false, "Search the local file index directly, without the search index")
+	tags := searchCmd.String("tag", "", "Files with all of these tags (comma-separated, with -local)")
 
 	// Filter options
 	fileTypes := searchCmd.String("type", "", "File types to search (comma-separated)")
@@ -116,7 +123,8 @@ func handleSearchCommand(args []string) {
 		fmt.Fprintf(os.Stderr, "  noisefs search --name \"*.pdf\"           # Search by filename\n")
 		fmt.Fprintf(os.Stderr, "  noisefs search --type pdf,docx          # Search by file type\n")
 		fmt.Fprintf(os.Stderr, "  noisefs search --min-size 1MB           # Search by size\n")
-		fmt.Fprintf(os.Stderr, "  noisefs search --stats                  # Show index statistics\n\n")
+		fmt.Fprintf(os.Stderr, "  noisefs search --stats                  # Show index statistics\n")
+		fmt.Fprintf(os.Stderr, "  noisefs search --local --tag work report # Search your own files by tag and name\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		searchCmd.PrintDefaults()
 	}
@@ -147,47 +155,6 @@ func handleSearchCommand(args []string) {
 		os.Exit(1)
 	}
 
-	// For search operations that only use indexed metadata, we don't need a real storage manager
-	// This avoids IPFS dependencies and health check issues
-	var storageManager *storage.Manager = nil
-
-	// Initialize search manager
-	searchConfig := search.DefaultSearchConfig()
-	if *searchIndexPath != "" {
-		searchConfig.IndexPath = *searchIndexPath
-	}
-
-	// Create adapter for file index
-	indexAdapter := &fileIndexAdapter{fileIndex: fileIndex}
-
-	searchManager, err := search.NewSearchManager(searchConfig, indexAdapter, storageManager)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating search manager: %v\n", err)
-		os.Exit(1)
-	}
-
-	if err := searchManager.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting search manager: %v\n", err)
-		os.Exit(1)
-	}
-	defer searchManager.Stop()
-
-	// Handle special operations
-	if *rebuildIndex {
-		fmt.Println("Rebuilding search index...")
-		if err := searchManager.RebuildIndex(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error rebuilding index: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println("Index rebuild queued successfully")
-		return
-	}
-
-	if *indexStats {
-		showIndexStats(searchManager, *jsonOutput)
-		return
-	}
-
 	// Build search options
 	options := search.SearchOptions{
 		MaxResults: *maxResults,
@@ -267,6 +234,52 @@ func handleSearchCommand(args []string) {
 		}
 	}
 
+	if *local {
+		localSearch(fileIndex, *query, *namePattern, *tags, options, *jsonOutput, *showScore)
+		return
+	}
+
+	// For search operations that only use indexed metadata, we don't need a real storage manager
+	// This avoids IPFS dependencies and health check issues
+	var storageManager *storage.Manager = nil
+
+	// Initialize search manager
+	searchConfig := search.DefaultSearchConfig()
+	if *searchIndexPath != "" {
+		searchConfig.IndexPath = *searchIndexPath
+	}
+
+	// Create adapter for file index
+	indexAdapter := &fileIndexAdapter{fileIndex: fileIndex}
+
+	searchManager, err := search.NewSearchManager(searchConfig, indexAdapter, storageManager)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating search manager: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := searchManager.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting search manager: %v\n", err)
+		os.Exit(1)
+	}
+	defer searchManager.Stop()
+
+	// Handle special operations
+	if *rebuildIndex {
+		fmt.Println("Rebuilding search index...")
+		if err := searchManager.RebuildIndex(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error rebuilding index: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Index rebuild queued successfully")
+		return
+	}
+
+	if *indexStats {
+		showIndexStats(searchManager, *jsonOutput)
+		return
+	}
+
 	// Perform search
 	var results *search.SearchResults
 	if *query != "" {
@@ -303,6 +316,80 @@ func handleSearchCommand(args []string) {
 	}
 }
 
+// localSearch searches the file index directly and prints the matches like
+// search index results
+func localSearch(fileIndex *fuse.FileIndex, text, name, tags string, options search.SearchOptions, jsonOutput, showScore bool) {
+	start := time.Now()
+	query := fuse.IndexQuery{
+		Text:       text,
+		Name:       name,
+		Extensions: options.FileTypes,
+		Tags:       splitList(tags),
+		Directory:  options.Directory,
+		SortBy:     string(options.SortBy),
+		Descending: options.SortOrder == search.SortDesc,
+		Offset:     options.Offset,
+		Limit:      options.MaxResults,
+	}
+	if options.SortBy == search.SortByScore {
+		// Index entries have no relevance score; list them by path
+		query.SortBy = fuse.SortByPath
+		query.Descending = false
+	}
+	if options.SizeRange != nil {
+		query.MinSize = options.SizeRange.Min
+		query.MaxSize = options.SizeRange.Max
+	}
+	if options.TimeRange != nil {
+		query.ModifiedAfter = options.TimeRange.Start
+		query.ModifiedBefore = options.TimeRange.End
+	}
+
+	matches, total, err := fileIndex.Search(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Search failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	results := &search.SearchResults{
+		Query:     text,
+		QueryType: "local",
+		Options:   options,
+		Results:   make([]search.SearchResult, 0, len(matches)),
+		Total:     total,
+		Offset:    options.Offset,
+		Limit:     options.MaxResults,
+		HasMore:   options.Offset+len(matches) < total,
+	}
+	for _, match := range matches {
+		cid := match.DescriptorCID
+		if match.Type == fuse.DirectoryEntryType {
+			cid = match.DirectoryDescriptorCID
+		}
+		results.Results = append(results.Results, search.SearchResult{
+			Path:          match.Path,
+			DescriptorCID: cid,
+			Size:          match.FileSize,
+			ModifiedAt:    match.ModifiedAt,
+			CreatedAt:     match.CreatedAt,
+			MimeType:      match.MimeType,
+			IsEncrypted:   match.Encrypted,
+			Tags:          match.Tags,
+			Directory:     match.Directory,
+			IsDirectory:   match.Type == fuse.DirectoryEntryType,
+			ContentHash:   match.ContentHash,
+		})
+	}
+	results.TimeTaken = time.Since(start)
+	results.TimeTakenMS = results.TimeTaken.Milliseconds()
+
+	if jsonOutput {
+		displayJSONResults(results)
+	} else {
+		displayTextResults(results, showScore)
+	}
+}
+
 func showIndexStats(searchManager *search.SearchManager, jsonOutput bool) {
 	stats, err := searchManager.GetIndexStats()
 	if err != nil {
@@ -363,6 +450,14 @@ func displayTextResults(results *search.SearchResults, showScore bool) {
 			fmt.Printf(" | Type: %s", result.MimeType)
 		}
 
+		if result.IsEncrypted {
+			fmt.Print(" | Encrypted")
+		}
+
+		if len(result.Tags) > 0 {
+			fmt.Printf(" | Tags: %s", strings.Join(result.Tags, ", "))
+		}
+
 		fmt.Println()
 
 		if result.Preview != "" {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/update"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// SelfUpdateResult is the output of noisefs selfupdate
+type SelfUpdateResult struct {
+	Channel   string `json:"channel"`
+	Current   string `json:"current"`
+	Latest    string `json:"latest"`
+	Available bool   `json:"available"`
+	Installed bool   `json:"installed"`
+	Path      string `json:"path,omitempty"`
+	Notes     string `json:"notes,omitempty"`
+}
+
+// selfUpdateCommand replaces this binary with the latest release of the
+// configured channel, after checking the release key's signature
+func selfUpdateCommand(args []string, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("selfupdate", flag.ContinueOnError)
+	check := flagSet.Bool("check", false, "Only report whether an update is available")
+	channel := flagSet.String("channel", cfg.Update.Channel, "Release channel to update from: stable or beta (overrides update.channel)")
+	force := flagSet.Bool("force", false, "Install the channel's release even if it isn't newer, e.g. to leave the beta channel")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: noisefs selfupdate [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Downloads the latest release of the update.channel and replaces this binary\n")
+		fmt.Fprintf(os.Stderr, "with it. The release manifest must be signed with update.public_key, and the\n")
+		fmt.Fprintf(os.Stderr, "binary must match the hash it lists, or nothing is replaced.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flagSet.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  noisefs selfupdate -check\n")
+		fmt.Fprintf(os.Stderr, "  noisefs selfupdate\n")
+		fmt.Fprintf(os.Stderr, "  noisefs selfupdate -channel beta\n")
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *channel != config.UpdateChannelStable && *channel != config.UpdateChannelBeta {
+		return fmt.Errorf("unknown channel %q; use %s or %s", *channel, config.UpdateChannelStable, config.UpdateChannelBeta)
+	}
+
+	updateCfg := cfg.Update
+	updateCfg.Channel = *channel
+	updater, err := update.New(updateCfg)
+	if err != nil {
+		return err
+	}
+	// Interrupting leaves the binary untouched
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	manifest, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	result := SelfUpdateResult{Channel: *channel, Current: version.Build(), Latest: manifest.Version, Notes: manifest.Notes}
+	upToDate := update.Check(manifest, result.Current)
+	if upToDate != nil && !errors.Is(upToDate, update.ErrUpToDate) {
+		return upToDate
+	}
+	result.Available = upToDate == nil
+
+	if *check || (!result.Available && !*force) {
+		if jsonOutput {
+			util.PrintJSONSuccess(result)
+		} else if quiet {
+			fmt.Println(result.Available)
+		} else if result.Available {
+			fmt.Printf("NoiseFS %s is available on the %s channel (running %s)\n", result.Latest, result.Channel, result.Current)
+			if result.Notes != "" {
+				fmt.Printf("Release notes: %s\n", result.Notes)
+			}
+			if *check {
+				fmt.Println("Install it with: noisefs selfupdate")
+			}
+		} else {
+			fmt.Printf("NoiseFS %s is up to date (latest %s release: %s)\n", result.Current, result.Channel, result.Latest)
+		}
+		return nil
+	}
+
+	path, err := executablePath()
+	if err != nil {
+		return err
+	}
+	if !quiet && !jsonOutput {
+		fmt.Printf("Installing NoiseFS %s from the %s channel to %s...\n", result.Latest, result.Channel, path)
+	}
+	if err := updater.Install(ctx, manifest, path); err != nil {
+		return err
+	}
+	result.Installed, result.Path = true, path
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+	} else if !quiet {
+		fmt.Printf("✓ Updated %s to NoiseFS %s\n", path, result.Latest)
+		fmt.Println("Restart noisefs-webui and noisefs-mount so they run the same version; noisefs doctor checks this.")
+	}
+	return nil
+}
+
+// executablePath returns the file of the running binary, following
+// symlinks so the link itself is kept
+func executablePath() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the running binary: %w", err)
+	}
+	return filepath.EvalSymlinks(path)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/sinks"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/service"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// Services noisefs service manages
+const (
+	serviceDaemon = "daemon" // The web UI, which also polls the DHT
+	serviceMount  = "mount"  // noisefs-mount at the configured mount point
+	serviceWatch  = "watch"  // noisefs subscribe -watch, writing announcements to the sinks
+)
+
+// ServiceInstallResult is the output of service install for one service
+type ServiceInstallResult struct {
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+	Path string `json:"path"`
+}
+
+// serviceCommand installs and supervises the daemon, mount and
+// subscription watcher as systemd or launchd services
+func serviceCommand(args []string, cfg *config.Config, configPath string, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: service install|uninstall|status|restart [options]")
+	}
+	action := args[0]
+	switch action {
+	case "install", "uninstall", "status", "restart":
+	default:
+		return fmt.Errorf("unknown service command %q (use install, uninstall, status or restart)", action)
+	}
+
+	flagSet := flag.NewFlagSet("service "+action, flag.ContinueOnError)
+	system := flagSet.Bool("system", false, "Manage system-wide services instead of the current user's (needs root)")
+	only := flagSet.String("only", "", "Only this service: daemon, mount or watch (default: all)")
+	mountPath := flagSet.String("mount", cfg.FUSE.MountPath, "Mount point for the mount service (install)")
+	runAs := flagSet.String("user", os.Getenv("SUDO_USER"), "Account system services run as (install -system)")
+	dryRun := flagSet.Bool("dry-run", false, "Print the unit files instead of installing them (install)")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: noisefs service %s [options]\n", action)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	names := []string{serviceDaemon, serviceMount, serviceWatch}
+	switch *only {
+	case "":
+	case serviceDaemon, serviceMount, serviceWatch:
+		names = []string{*only}
+	default:
+		return fmt.Errorf("unknown service %q (use daemon, mount or watch)", *only)
+	}
+
+	manager, err := service.NewManager(*system)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "install":
+		specs, err := serviceSpecs(names, configPath, *mountPath, *runAs)
+		if err != nil {
+			return err
+		}
+		return serviceInstall(manager, specs, *dryRun, quiet, jsonOutput)
+	case "status":
+		statuses := make([]service.Status, 0, len(names))
+		for _, name := range names {
+			statuses = append(statuses, manager.Status(name))
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(statuses)
+			return nil
+		}
+		for _, status := range statuses {
+			fmt.Printf("%-7s %-30s %s\n", status.Name, status.Unit, status.State)
+		}
+		return nil
+	}
+
+	// uninstall and restart act on the services that are installed
+	done := []string{}
+	for _, name := range names {
+		if *only == "" && !manager.Status(name).Installed {
+			continue
+		}
+		if action == "uninstall" {
+			err = manager.Uninstall(name)
+		} else {
+			err = manager.Restart(name)
+		}
+		if err != nil {
+			return err
+		}
+		done = append(done, name)
+	}
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]interface{}{action: done})
+		return nil
+	}
+	if len(done) == 0 {
+		fmt.Println("No NoiseFS services are installed")
+		return nil
+	}
+	if !quiet {
+		verb := "restarted"
+		if action == "uninstall" {
+			verb = "uninstalled"
+		}
+		for _, name := range done {
+			fmt.Printf("%s: %s\n", manager.Unit(name), verb)
+		}
+	}
+	return nil
+}
+
+// serviceSpecs describes the named services for the current configuration
+func serviceSpecs(names []string, configPath, mountPath, runAs string) ([]service.Spec, error) {
+	if configPath == "" {
+		return nil, fmt.Errorf("no configuration file; pass -config")
+	}
+	configPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The disclaimer was accepted before this command ran; services have
+	// no terminal to accept it on
+	var specs []service.Spec
+	for _, name := range names {
+		switch name {
+		case serviceDaemon:
+			program, err := findNoiseFSBinary("noisefs-webui")
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, service.Spec{
+				Name:        serviceDaemon,
+				Description: "NoiseFS daemon and web UI",
+				Program:     program,
+				Args:        []string{"-config", configPath, "-accept-tos"},
+				User:        runAs,
+			})
+		case serviceMount:
+			if mountPath == "" {
+				if len(names) > 1 {
+					continue // Nothing to mount; install the daemon only
+				}
+				return nil, fmt.Errorf("no mount point; set fuse.mount_path or pass -mount")
+			}
+			program, err := findNoiseFSBinary("noisefs-mount")
+			if err != nil {
+				return nil, err
+			}
+			mountPath, err = filepath.Abs(mountPath)
+			if err != nil {
+				return nil, err
+			}
+			spec := service.Spec{
+				Name:        serviceMount,
+				Description: "NoiseFS mount at " + mountPath,
+				Program:     program,
+				Args:        []string{"-config", configPath, "-mount", mountPath, "-accept-tos"},
+				User:        runAs,
+			}
+			// Clear a mount left behind by a crash before remounting
+			if runtime.GOOS == "linux" {
+				for _, fusermount := range []string{"fusermount3", "fusermount"} {
+					if path, err := exec.LookPath(fusermount); err == nil {
+						spec.Cleanup = []string{path, "-uz", mountPath}
+						break
+					}
+				}
+			}
+			specs = append(specs, spec)
+		case serviceWatch:
+			sinkConfig, err := sinks.LoadConfig(sinks.DefaultConfigPath())
+			if err != nil {
+				return nil, err
+			}
+			if len(sinkConfig.Sinks) == 0 {
+				if len(names) > 1 {
+					continue // Nothing to write announcements to
+				}
+				return nil, fmt.Errorf("no sinks; add one with noisefs subscribe -sink")
+			}
+			program, err := findNoiseFSBinary("noisefs")
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, service.Spec{
+				Name:        serviceWatch,
+				Description: "NoiseFS subscription watcher",
+				Program:     program,
+				Args:        []string{"subscribe", "-watch", "-config", configPath, "-accept-tos"},
+				User:        runAs,
+			})
+		}
+	}
+	return specs, nil
+}
+
+// findNoiseFSBinary looks for a NoiseFS program next to this one, then on
+// the PATH, and returns its absolute path
+func findNoiseFSBinary(name string) (string, error) {
+	if self, err := os.Executable(); err == nil {
+		candidate := filepath.Join(filepath.Dir(self), name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found next to noisefs or on the PATH", name)
+	}
+	return filepath.Abs(path)
+}
+
+// serviceInstall installs specs, or prints their unit files for a dry run
+func serviceInstall(manager *service.Manager, specs []service.Spec, dryRun bool, quiet bool, jsonOutput bool) error {
+	if dryRun {
+		for _, spec := range specs {
+			fmt.Printf("# %s\n%s\n", manager.Path(spec.Name), manager.Render(spec))
+		}
+		return nil
+	}
+
+	results := make([]ServiceInstallResult, 0, len(specs))
+	for _, spec := range specs {
+		path, err := manager.Install(spec)
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", spec.Name, err)
+		}
+		results = append(results, ServiceInstallResult{Name: spec.Name, Unit: manager.Unit(spec.Name), Path: path})
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(results)
+		return nil
+	}
+	if quiet {
+		return nil
+	}
+	for _, result := range results {
+		fmt.Printf("Installed and started %s (%s)\n", result.Unit, result.Path)
+	}
+	if manager.Init == service.Systemd && !manager.System {
+		fmt.Println("User services stop when you log out; run 'loginctl enable-linger' to keep them running")
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/network/proxy"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// ShareOpenResult is the output of share open
+type ShareOpenResult struct {
+	ShareCID string `json:"share_cid"`
+	File     string `json:"file"`
+	Size     int64  `json:"size"`
+}
+
+// shareCommand creates, lists, revokes and opens limited shares
+func shareCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: share create|list|revoke|open [options]")
+	}
+	switch args[0] {
+	case "create":
+		return shareCreateCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	case "list":
+		return shareListCommand(args[1:], quiet, jsonOutput)
+	case "revoke":
+		return shareRevokeCommand(args[1:], quiet, jsonOutput)
+	case "open":
+		return shareOpenCommand(args[1:], storageManager, cfg, quiet, jsonOutput)
+	default:
+		return fmt.Errorf("unknown share command %q (use create, list, revoke or open)", args[0])
+	}
+}
+
+// openShareStore opens the shares kept for the local web UI's key service
+func openShareStore() (*descriptors.ShareStore, error) {
+	path, err := descriptors.DefaultShareStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return descriptors.NewShareStore(path), nil
+}
+
+// shareCreateCommand shares a descriptor until it expires or has been
+// downloaded enough times
+func shareCreateCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("share create", flag.ContinueOnError)
+	service := flagSet.String("service", "", "URL recipients use to reach this node's web UI, which holds the key")
+	expires := flagSet.Duration("expires", 0, "How long the share lasts, e.g. 24h (default: no expiry)")
+	maxDownloads := flagSet.Int("max-downloads", 0, "Downloads allowed before the share ends (default: no limit)")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs share create [-service URL] [-expires 24h] [-max-downloads N] <descriptor-cid>")
+		fmt.Fprintf(flagSet.Output(), "Encrypted descriptors are read with the password in $%s.\n", bundlePasswordEnv)
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one descriptor CID is required")
+	}
+	if *expires < 0 || *maxDownloads < 0 {
+		return fmt.Errorf("-expires and -max-downloads cannot be negative")
+	}
+	if *expires == 0 && *maxDownloads == 0 {
+		return fmt.Errorf("a share needs -expires, -max-downloads or both")
+	}
+	keyService := *service
+	if keyService == "" && len(cfg.WebUI.ACMEDomains) > 0 {
+		keyService = "https://" + cfg.WebUI.ACMEDomains[0]
+	}
+	if keyService == "" {
+		return fmt.Errorf("-service is required unless the web UI has an ACME domain")
+	}
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	descriptor, _, err := client.LoadDescriptor(flagSet.Arg(0), os.Getenv(bundlePasswordEnv))
+	if err != nil {
+		return err
+	}
+
+	options := descriptors.ShareOptions{KeyService: keyService, MaxDownloads: *maxDownloads}
+	if *expires > 0 {
+		options.ExpiresAt = time.Now().Add(*expires).UTC()
+	}
+	record, err := descriptors.CreateShare(storageManager, descriptor, options)
+	if err != nil {
+		return err
+	}
+	shares, err := openShareStore()
+	if err != nil {
+		return err
+	}
+	if err := shares.Add(record); err != nil {
+		return err
+	}
+	record.Key = ""
+
+	if jsonOutput {
+		util.PrintJSONSuccess(record)
+		return nil
+	}
+	if quiet {
+		fmt.Println(record.ShareCID)
+		return nil
+	}
+
+	fmt.Printf("Shared %s\n", record.Filename)
+	fmt.Printf("Share CID: %s\n", record.ShareCID)
+	fmt.Printf("Share ID: %s\n", record.ID)
+	fmt.Printf("Limits: %s\n", shareLimits(record))
+	fmt.Printf("Recipients open it with: noisefs share open %s\n", record.ShareCID)
+	fmt.Printf("The web UI at %s must be running for the share to be opened.\n", keyService)
+	return nil
+}
+
+// shareLimits describes when a share ends
+func shareLimits(record *descriptors.ShareRecord) string {
+	limits := "none"
+	if !record.ExpiresAt.IsZero() {
+		limits = "expires " + record.ExpiresAt.Local().Format(time.RFC1123)
+	}
+	if record.MaxDownloads > 0 {
+		downloads := fmt.Sprintf("%d of %d downloads", record.Downloads, record.MaxDownloads)
+		if limits == "none" {
+			limits = downloads
+		} else {
+			limits += ", " + downloads
+		}
+	}
+	return limits
+}
+
+func shareListCommand(args []string, quiet bool, jsonOutput bool) error {
+	shares, err := openShareStore()
+	if err != nil {
+		return err
+	}
+	list, err := shares.List()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(list)
+		return nil
+	}
+	if len(list) == 0 && !quiet {
+		fmt.Println("No shares")
+		return nil
+	}
+	for _, record := range list {
+		if quiet {
+			fmt.Println(record.ID)
+			continue
+		}
+		status := "active"
+		if err := record.Status(time.Now()); err != nil {
+			status = err.Error()
+		}
+		fmt.Printf("%s  %s\n", record.ID, record.Filename)
+		fmt.Printf("  CID: %s\n", record.ShareCID)
+		fmt.Printf("  Limits: %s (%s)\n", shareLimits(record), status)
+	}
+	return nil
+}
+
+func shareRevokeCommand(args []string, quiet bool, jsonOutput bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: share revoke <share-id>")
+	}
+	shares, err := openShareStore()
+	if err != nil {
+		return err
+	}
+	if err := shares.Revoke(args[0]); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]string{"revoked": args[0]})
+	} else if !quiet {
+		fmt.Printf("Revoked share %s; its key has been destroyed\n", args[0])
+	}
+	return nil
+}
+
+// shareOpenCommand redeems a share with its key service and downloads the
+// file
+func shareOpenCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("share open", flag.ContinueOnError)
+	output := flagSet.String("o", "", "File to write (default: the shared file's name)")
+	insecure := flagSet.Bool("insecure", false, "Accept a self-signed certificate from the key service")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs share open [-o file] [-insecure] <share-cid>")
+		fmt.Fprintln(flagSet.Output(), "Each open counts as one download of the share.")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one share CID is required")
+	}
+	shareCID := flagSet.Arg(0)
+
+	ctx := context.Background()
+	share, err := descriptors.LoadShareDescriptor(ctx, storageManager, shareCID)
+	if err != nil {
+		return err
+	}
+	if !share.ExpiresAt.IsZero() && time.Now().After(share.ExpiresAt) {
+		return descriptors.ErrShareExpired
+	}
+
+	httpClient, err := proxy.NewHTTPClient(cfg.Network.Proxy, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	if *insecure {
+		httpClient.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	password, err := descriptors.RedeemShare(ctx, httpClient, share)
+	if err != nil {
+		return err
+	}
+
+	client, err := newPackClient(storageManager, cfg)
+	if err != nil {
+		return err
+	}
+	file, err := client.DownloadFile(ctx, share.DescriptorCID, password, nil)
+	if err != nil {
+		return err
+	}
+
+	path := *output
+	if path == "" {
+		// The name comes from the descriptor, so keep it in the current directory
+		path = filepath.Base(file.Filename)
+		if path == "." || path == ".." || path == string(filepath.Separator) {
+			return fmt.Errorf("refusing to write unsafe filename %q; use -o", file.Filename)
+		}
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, file.Reader); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	result := ShareOpenResult{ShareCID: shareCID, File: path, Size: file.Size}
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return nil
+	}
+	if quiet {
+		fmt.Println(path)
+		return nil
+	}
+	fmt.Printf("Downloaded %s (%s)\n", path, util.FormatSize(file.Size))
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// retentionFlags holds the retention overrides shared by list-snapshots and prune-snapshots
+type retentionFlags struct {
+	keepLast   *int
+	keepDaily  *int
+	keepWeekly *int
+}
+
+// registerRetentionFlags adds retention flags defaulting to the configured policy
+func registerRetentionFlags(flagSet *flag.FlagSet, defaults config.SnapshotConfig) *retentionFlags {
+	return &retentionFlags{
+		keepLast:   flagSet.Int("keep-last", defaults.KeepLast, "Keep the N most recent snapshots (0 disables)"),
+		keepDaily:  flagSet.Int("keep-daily", defaults.KeepDaily, "Keep the newest snapshot for each of the last N days (0 disables)"),
+		keepWeekly: flagSet.Int("keep-weekly", defaults.KeepWeekly, "Keep the newest snapshot for each of the last N weeks (0 disables)"),
+	}
+}
+
+// policy returns the retention policy described by the flags
+func (f *retentionFlags) policy() (storage.RetentionPolicy, error) {
+	if *f.keepLast < 0 || *f.keepDaily < 0 || *f.keepWeekly < 0 {
+		return storage.RetentionPolicy{}, fmt.Errorf("retention values cannot be negative")
+	}
+	return storage.RetentionPolicy{
+		KeepLast:   *f.keepLast,
+		KeepDaily:  *f.keepDaily,
+		KeepWeekly: *f.keepWeekly,
+	}, nil
+}
+
+// openSnapshotRegistry opens the local registry of created snapshots
+func openSnapshotRegistry() (*storage.SnapshotRegistry, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	return storage.NewSnapshotRegistry(filepath.Join(homeDir, ".noisefs", "snapshots.json"))
+}
+
+// recordSnapshot adds a snapshot to the local registry. Failures are reported
+// but do not fail the share, since the snapshot itself was created.
+func recordSnapshot(record storage.SnapshotRecord, quiet bool) {
+	registry, err := openSnapshotRegistry()
+	if err == nil {
+		err = registry.Add(record)
+	}
+	if err != nil && !quiet {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record snapshot for retention: %v\n", err)
+	}
+}
+
+// retainedDescriptors returns the descriptors used outside snapshots: the
+// files and directories of the local index and pinned descriptors. Unlike
+// listings, pruning fails when the index can't be read, since its files
+// would otherwise be unpinned.
+func retainedDescriptors(cfg *config.Config) (map[string]bool, error) {
+	index, err := openConfiguredIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+	retained := make(map[string]bool)
+	for _, entry := range index.ListFiles() {
+		if entry.DescriptorCID != "" {
+			retained[entry.DescriptorCID] = true
+		}
+		if entry.DirectoryDescriptorCID != "" {
+			retained[entry.DirectoryDescriptorCID] = true
+		}
+	}
+	pins, err := openPinRegistry()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range pins.All() {
+		retained[record.DescriptorCID] = true
+	}
+	return retained, nil
+}
+
+// PruneSnapshotsResult is the output of prune-snapshots
+type PruneSnapshotsResult struct {
+	DirectoryCID string                  `json:"directory_cid"`
+	Policy       storage.RetentionPolicy `json:"policy"`
+	*storage.PruneResult
+}
+
+// pruneSnapshotsCommand removes snapshots that fall outside the retention policy
+func pruneSnapshotsCommand(args []string, storageManager *storage.Manager, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("prune-snapshots", flag.ContinueOnError)
+	retention := registerRetentionFlags(flagSet, cfg.Snapshots)
+	dryRun := flagSet.Bool("dry-run", false, "Show what would be pruned without unpinning anything")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	args = flagSet.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("usage: prune-snapshots [--keep-last N] [--keep-daily N] [--keep-weekly N] [--dry-run] <directory-cid> [directory-key]")
+	}
+
+	policy, err := retention.policy()
+	if err != nil {
+		return err
+	}
+
+	directoryCID := args[0]
+
+	// Create directory manager
+	tempKey, err := crypto.GenerateKey("temp-key")
+	if err != nil {
+		return fmt.Errorf("failed to generate temp key: %w", err)
+	}
+
+	directoryManager, err := storage.NewDirectoryManager(storageManager, tempKey, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create directory manager: %w", err)
+	}
+
+	opts := storage.PruneOptions{
+		Policy: policy,
+		DryRun: *dryRun,
+	}
+
+	// With the directory key the live entries are known, so file CIDs that only
+	// pruned snapshots reference can be unpinned as well
+	if len(args) > 1 {
+		directoryKey, err := crypto.ParseKeyFromString(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to parse directory key: %w", err)
+		}
+
+		manifest, err := directoryManager.RetrieveDirectoryManifestWithKey(context.Background(), directoryCID, directoryKey)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve directory manifest: %w", err)
+		}
+
+		opts.LiveReferences = make(map[string]bool)
+		for _, cid := range storage.ManifestReferences(manifest) {
+			opts.LiveReferences[cid] = true
+		}
+
+		// Files still indexed or pinned stay, and the data and randomizer
+		// blocks of the others are freed unless a kept file uses them
+		opts.Retained, err = retainedDescriptors(cfg)
+		if err != nil {
+			return err
+		}
+		client, err := newPackClient(storageManager, cfg)
+		if err != nil {
+			return err
+		}
+		opts.DescriptorBlocks = func(ctx context.Context, descriptorCID string) ([]string, error) {
+			descriptor, _, err := client.LoadDescriptor(descriptorCID, os.Getenv(bundlePasswordEnv))
+			if err != nil {
+				return nil, err
+			}
+			references := descriptor.BlockReferences()
+			blocks := make([]string, 0, len(references))
+			for _, reference := range references {
+				blocks = append(blocks, reference.CID)
+			}
+			return blocks, nil
+		}
+	}
+
+	registry, err := openSnapshotRegistry()
+	if err != nil {
+		return err
+	}
+
+	pruned, err := directoryManager.PruneSnapshots(context.Background(), registry, directoryCID, opts)
+	if err != nil {
+		return fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+
+	result := PruneSnapshotsResult{
+		DirectoryCID: directoryCID,
+		Policy:       policy,
+		PruneResult:  pruned,
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+	} else if quiet {
+		for _, record := range pruned.Pruned {
+			fmt.Printf("%s\t%s\n", record.SnapshotCID, record.SnapshotName)
+		}
+	} else {
+		action := "Pruned"
+		if pruned.DryRun {
+			action = "Would prune"
+		}
+		fmt.Printf("Directory: %s\n", directoryCID)
+		fmt.Printf("Policy: keep-last %d, keep-daily %d, keep-weekly %d\n", policy.KeepLast, policy.KeepDaily, policy.KeepWeekly)
+		fmt.Printf("Kept: %d\n", len(pruned.Kept))
+		fmt.Printf("%s: %d\n", action, len(pruned.Pruned))
+		for _, record := range pruned.Pruned {
+			fmt.Printf("  %s  %s  %s\n", record.CreatedAt.Format("2006-01-02 15:04:05"), record.SnapshotCID, record.SnapshotName)
+		}
+		if pruned.DryRun {
+			fmt.Printf("Blocks to unpin: %d\n", len(pruned.Unpinned))
+		} else {
+			fmt.Printf("Blocks unpinned: %d\n", len(pruned.Unpinned))
+		}
+		if len(pruned.Errors) > 0 {
+			fmt.Printf("Unpin errors:\n  %s\n", strings.Join(pruned.Errors, "\n  "))
+		}
+		if opts.LiveReferences == nil && len(pruned.Pruned) > 0 {
+			fmt.Printf("\nNote: pass the directory key to also unpin files referenced only by pruned snapshots, and their blocks.\n")
+		}
+	}
+
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
 	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
@@ -358,8 +359,20 @@ func streamingUploadFile(storageManager *storage.Manager, client *noisefs.Client
 	// Track overall upload time
 	uploadStartTime := time.Now()
 
+	// Reject content forbidden by the upload policy before doing any work
+	if err := enforceContentPolicy(cfg, filePath); err != nil {
+		return err
+	}
+
+	// Upload what the content processors make of the file
+	processed, err := client.Processors().Process(context.Background(), processors.StageUpload, filepath.Base(filePath), filePath)
+	if err != nil {
+		return err
+	}
+	defer processed.Close()
+
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := os.Open(processed.Path)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
@@ -500,5 +513,6 @@ func streamingUploadFile(storageManager *storage.Manager, client *noisefs.Client
 	// Record upload metrics
 	client.RecordUpload(fileInfo.Size(), bytesProcessed*3) // *3 for data + 2 randomizer blocks
 
+	notifyUpload(cfg, descriptorCID, filepath.Base(filePath), fileInfo.Size())
 	return nil
 }
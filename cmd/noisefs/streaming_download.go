@@ -338,6 +338,20 @@ func (p *StreamingDownloadProcessor) GetStats() (blocksProcessed int, bytesWritt
 	return p.blocksProcessed, p.bytesWritten
 }
 
+// verifyDownloaded checks the file's contents, up to the descriptor's file
+// size, against the descriptor's content hash
+func verifyDownloaded(descriptor *descriptors.Descriptor, path string) error {
+	if !descriptor.Verifiable() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open downloaded file: %w", err)
+	}
+	defer file.Close()
+	return descriptor.VerifyContent(io.LimitReader(file, descriptor.GetOriginalFileSize()))
+}
+
 // streamingDownloadFile downloads a file using streaming with bounded memory
 func streamingDownloadFile(storageManager *storage.Manager, client *noisefs.Client, descriptorCID string, outputPath string, quiet bool, jsonOutput bool, cfg *config.Config, logger *logging.Logger) error {
 	// Track download start time
@@ -417,6 +431,18 @@ func streamingDownloadFile(storageManager *storage.Manager, client *noisefs.Clie
 		progress.Finish()
 	}
 
+	// Check the reassembled file against the descriptor's content hash
+	outputFile.Close()
+	if err := verifyDownloaded(descriptor, outputPath); err != nil {
+		os.Remove(outputPath)
+		return err
+	}
+
+	// Run the download processors on the written file
+	if err := processDownloaded(client, descriptor.Filename, outputPath); err != nil {
+		return err
+	}
+
 	// Get final statistics
 	blocksProcessed, bytesWritten := processor.GetStats()
 
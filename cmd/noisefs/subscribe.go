@@ -1,26 +1,37 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/autofetch"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/dht"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/pubsub"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/security"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/sinks"
 	"github.com/TheEntropyCollective/noisefs/pkg/announce/store"
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	noisefsConfig "github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
 	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/webhooks"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
 	"github.com/TheEntropyCollective/noisefs/pkg/util"
 	shell "github.com/ipfs/go-ipfs-api"
 )
 
 // subscribeCommand handles the subscribe subcommand
-func subscribeCommand(args []string, storageManager *storage.Manager, shell *shell.Shell, quiet bool, jsonOutput bool) error {
+func subscribeCommand(args []string, storageManager *storage.Manager, shell *shell.Shell, cfg *noisefsConfig.Config, quiet bool, jsonOutput bool) error {
 	flagSet := flag.NewFlagSet("subscribe", flag.ExitOnError)
 
 	var (
@@ -28,7 +39,27 @@ func subscribeCommand(args []string, storageManager *storage.Manager, shell *she
 		remove  = flagSet.Bool("remove", false, "Remove subscription")
 		monitor = flagSet.Bool("monitor", false, "Start monitoring subscriptions")
 		help    = flagSet.Bool("help", false, "Show help for subscribe command")
+
+		autoFetch  = flagSet.Bool("auto-fetch", false, "Automatically fetch files announced under the topic")
+		action     = flagSet.String("action", "download", "Auto-fetch action: download, or index to add files to the FUSE index")
+		dir        = flagSet.String("dir", "", "Auto-fetch target directory (FUSE index directory for -action index)")
+		tags       = flagSet.String("tags", "", "Only auto-fetch files with all of these tags (comma-separated)")
+		categories = flagSet.String("categories", "", "Only auto-fetch files in these categories (comma-separated)")
+		minSize    = flagSet.String("min-size", "", "Smallest size class to auto-fetch (tiny, small, medium, large, huge)")
+		maxSize    = flagSet.String("max-size", "", "Largest size class to auto-fetch")
+		dailyFiles = flagSet.Int("daily-files", -1, "Most files auto-fetched per day (0 for unlimited)")
+		dailySize  = flagSet.String("daily-size", "", "Most data auto-fetched per day, e.g. 10GB (0 for unlimited)")
+		dryRun     = flagSet.Bool("dry-run", false, "With -monitor, log what auto-fetch would do without fetching")
+
+		// Sinks the monitor writes matching announcements to
+		watch      = flagSet.Bool("watch", false, "Monitor without console output, writing announcements to the sinks only")
+		sinkName   = flagSet.String("sink", "", "Add or replace the sink with this name, filtered by -tags, -categories, -min-size, -max-size and the topic")
+		sinkType   = flagSet.String("sink-type", string(sinks.TypeJSONL), "Sink type: jsonl, sqlite, or stdout for pipes")
+		sinkPath   = flagSet.String("sink-path", "", "File of a jsonl or sqlite sink")
+		listSinks  = flagSet.Bool("sinks", false, "List sinks")
+		removeSink = flagSet.String("remove-sink", "", "Remove the sink with this name")
 	)
+	flagSet.String("config", "", "Configuration file path")
 
 	flagSet.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: noisefs subscribe [topic-pattern] [options]\n\n")
@@ -40,6 +71,13 @@ func subscribeCommand(args []string, storageManager *storage.Manager, shell *she
 		fmt.Fprintf(os.Stderr, "  noisefs subscribe \"documents/*\"           # Subscribe to all documents\n")
 		fmt.Fprintf(os.Stderr, "  noisefs subscribe --list                  # List subscriptions\n")
 		fmt.Fprintf(os.Stderr, "  noisefs subscribe --monitor               # Start monitoring\n")
+		fmt.Fprintf(os.Stderr, "  noisefs subscribe --auto-fetch --dir ~/Downloads --tags res:1080p \"movies/scifi\"\n")
+		fmt.Fprintf(os.Stderr, "  noisefs subscribe --monitor --dry-run     # Show what auto-fetch would do\n")
+		fmt.Fprintf(os.Stderr, "  noisefs subscribe --sink papers --sink-path ~/papers.jsonl --tags format:pdf \"documents/research\"\n")
+		fmt.Fprintf(os.Stderr, "  noisefs subscribe --sink all --sink-type sqlite --sink-path ~/announcements.db\n")
+		fmt.Fprintf(os.Stderr, "  noisefs subscribe --sink pipe --sink-type stdout && noisefs subscribe --watch | jq .\n")
+		fmt.Fprintf(os.Stderr, "\nThe monitor writes matching announcements to every sink. Install it as a\n")
+		fmt.Fprintf(os.Stderr, "service with 'noisefs service install -only watch' to keep it running.\n")
 	}
 
 	if err := flagSet.Parse(args); err != nil {
@@ -59,14 +97,113 @@ func subscribeCommand(args []string, storageManager *storage.Manager, shell *she
 		subConfig = config.NewSubscriptions()
 	}
 
+	fetchConfigPath := autofetch.DefaultConfigPath()
+	fetchConfig, err := autofetch.LoadConfig(fetchConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load auto-fetch rules: %w", err)
+	}
+
+	// Quota flags apply to all rules
+	if *dailyFiles >= 0 || *dailySize != "" {
+		if *dailyFiles >= 0 {
+			fetchConfig.Quota.MaxFiles = *dailyFiles
+		}
+		if *dailySize != "" {
+			if fetchConfig.Quota.MaxBytes, err = util.ParseSize(*dailySize); err != nil {
+				return fmt.Errorf("invalid daily size: %w", err)
+			}
+		}
+		if err := autofetch.SaveConfig(fetchConfigPath, fetchConfig); err != nil {
+			return fmt.Errorf("failed to save auto-fetch rules: %w", err)
+		}
+		if flagSet.NArg() == 0 && !*list && !*monitor && !*watch {
+			if jsonOutput {
+				util.PrintJSONSuccess(map[string]interface{}{"quota": fetchConfig.Quota})
+			} else if !quiet {
+				fmt.Println("✓ Auto-fetch quota updated")
+			}
+			return nil
+		}
+	}
+
+	sinksPath := sinks.DefaultConfigPath()
+	sinkConfig, err := sinks.LoadConfig(sinksPath)
+	if err != nil {
+		return fmt.Errorf("failed to load sinks: %w", err)
+	}
+
 	// Handle list command
 	if *list {
-		return listSubscriptions(subConfig, quiet, jsonOutput)
+		return listSubscriptions(subConfig, fetchConfig, quiet, jsonOutput)
+	}
+	if *listSinks {
+		return listSinkConfig(sinkConfig, quiet, jsonOutput)
+	}
+	if *removeSink != "" {
+		if err := sinkConfig.RemoveSink(*removeSink); err != nil {
+			return err
+		}
+		if err := sinks.SaveConfig(sinksPath, sinkConfig); err != nil {
+			return fmt.Errorf("failed to save sinks: %w", err)
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(map[string]interface{}{"removed": *removeSink})
+		} else if !quiet {
+			fmt.Printf("✓ Removed sink: %s\n", *removeSink)
+		}
+		return nil
 	}
 
 	// Handle monitor command
-	if *monitor {
-		return monitorSubscriptions(subConfig, storageManager, shell, quiet)
+	if *monitor || *watch {
+		fetchConfig.DryRun = *dryRun
+		return monitorSubscriptions(subConfig, fetchConfig, sinkConfig, storageManager, shell, cfg, quiet || *watch)
+	}
+
+	if *sinkName != "" {
+		sink := sinks.Sink{
+			Name:         *sinkName,
+			Type:         sinks.Type(*sinkType),
+			Path:         *sinkPath,
+			Tags:         splitList(*tags),
+			Categories:   splitList(*categories),
+			MinSizeClass: *minSize,
+			MaxSizeClass: *maxSize,
+			Enabled:      true,
+		}
+		if sink.Path != "" {
+			if sink.Path, err = filepath.Abs(sink.Path); err != nil {
+				return err
+			}
+		}
+		if flagSet.NArg() > 0 {
+			sink.Topics = []string{flagSet.Arg(0)}
+		}
+		if err := sinkConfig.SetSink(sink); err != nil {
+			return err
+		}
+		if err := sinks.SaveConfig(sinksPath, sinkConfig); err != nil {
+			return fmt.Errorf("failed to save sinks: %w", err)
+		}
+		if !quiet && !jsonOutput {
+			fmt.Printf("✓ Sink %s: %s %s\n", sink.Name, sink.Type, sink.Path)
+		}
+
+		// A sink without a topic takes announcements of every subscription
+		if flagSet.NArg() == 0 {
+			if jsonOutput {
+				util.PrintJSONSuccess(map[string]interface{}{"sink": sink})
+			}
+			return nil
+		}
+		for _, sub := range subConfig.GetAll() {
+			if sub.Topic == flagSet.Arg(0) {
+				if jsonOutput {
+					util.PrintJSONSuccess(map[string]interface{}{"topic": sub.Topic, "topic_hash": sub.TopicHash, "sink": sink})
+				}
+				return nil
+			}
+		}
 	}
 
 	// Get topic pattern
@@ -79,19 +216,116 @@ func subscribeCommand(args []string, storageManager *storage.Manager, shell *she
 
 	// Handle remove
 	if *remove {
+		// The topic's auto-fetch rule goes with the subscription
+		if fetchConfig.RemoveRule(topic) == nil {
+			if err := autofetch.SaveConfig(fetchConfigPath, fetchConfig); err != nil {
+				return fmt.Errorf("failed to save auto-fetch rules: %w", err)
+			}
+		}
 		return removeSubscription(subConfig, topic, configPath, quiet, jsonOutput)
 	}
 
+	if *autoFetch {
+		rule := autofetch.Rule{
+			Name:         topic,
+			Topic:        topic,
+			Tags:         splitList(*tags),
+			Categories:   splitList(*categories),
+			MinSizeClass: *minSize,
+			MaxSizeClass: *maxSize,
+			Action:       autofetch.Action(*action),
+			TargetDir:    *dir,
+			Enabled:      true,
+		}
+		if err := fetchConfig.SetRule(rule); err != nil {
+			return err
+		}
+		if err := autofetch.SaveConfig(fetchConfigPath, fetchConfig); err != nil {
+			return fmt.Errorf("failed to save auto-fetch rules: %w", err)
+		}
+		if !quiet && !jsonOutput {
+			fmt.Printf("✓ Auto-fetch: %s to %s\n", rule.Action, rule.TargetDir)
+		}
+
+		// Rules can be changed for topics already subscribed to
+		for _, sub := range subConfig.GetAll() {
+			if sub.Topic == topic {
+				if jsonOutput {
+					util.PrintJSON(map[string]interface{}{
+						"success":    true,
+						"topic":      topic,
+						"topic_hash": sub.TopicHash,
+						"auto_fetch": rule,
+					})
+				}
+				return nil
+			}
+		}
+	}
+
 	// Add subscription
 	return addSubscription(subConfig, topic, configPath, quiet, jsonOutput)
 }
 
-func listSubscriptions(subConfig *config.Subscriptions, _ bool, jsonOutput bool) error {
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// listSinkConfig prints the saved sinks
+func listSinkConfig(sinkConfig *sinks.Config, quiet bool, jsonOutput bool) error {
+	if jsonOutput {
+		util.PrintJSONSuccess(sinkConfig.Sinks)
+		return nil
+	}
+	if len(sinkConfig.Sinks) == 0 {
+		if !quiet {
+			fmt.Println("No sinks")
+		}
+		return nil
+	}
+	for _, sink := range sinkConfig.Sinks {
+		if quiet {
+			fmt.Println(sink.Name)
+			continue
+		}
+		fmt.Printf("  %s: %s", sink.Name, sink.Type)
+		if sink.Path != "" {
+			fmt.Printf(" %s", sink.Path)
+		}
+		if len(sink.Topics) > 0 {
+			fmt.Printf(", topics %s", strings.Join(sink.Topics, ","))
+		}
+		if len(sink.Tags) > 0 {
+			fmt.Printf(", tags %s", strings.Join(sink.Tags, ","))
+		}
+		if len(sink.Categories) > 0 {
+			fmt.Printf(", categories %s", strings.Join(sink.Categories, ","))
+		}
+		if sink.MinSizeClass != "" || sink.MaxSizeClass != "" {
+			fmt.Printf(", size %s-%s", sink.MinSizeClass, sink.MaxSizeClass)
+		}
+		if !sink.Enabled {
+			fmt.Print(" (disabled)")
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func listSubscriptions(subConfig *config.Subscriptions, fetchConfig *autofetch.Config, _ bool, jsonOutput bool) error {
 	subs := subConfig.GetAll()
 
 	if jsonOutput {
 		result := map[string]interface{}{
 			"subscriptions": subs,
+			"auto_fetch":    fetchConfig,
 		}
 		util.PrintJSON(result)
 		return nil
@@ -111,6 +345,30 @@ func listSubscriptions(subConfig *config.Subscriptions, _ bool, jsonOutput bool)
 		fmt.Println()
 	}
 
+	if len(fetchConfig.Rules) > 0 {
+		fmt.Println("\nAuto-fetch rules:")
+		for _, rule := range fetchConfig.Rules {
+			fmt.Printf("  %s: %s to %s", rule.Name, rule.Action, rule.TargetDir)
+			if len(rule.Tags) > 0 {
+				fmt.Printf(", tags %s", strings.Join(rule.Tags, ","))
+			}
+			if len(rule.Categories) > 0 {
+				fmt.Printf(", categories %s", strings.Join(rule.Categories, ","))
+			}
+			if rule.MinSizeClass != "" || rule.MaxSizeClass != "" {
+				fmt.Printf(", size %s-%s", rule.MinSizeClass, rule.MaxSizeClass)
+			}
+			if !rule.Enabled {
+				fmt.Print(" (disabled)")
+			}
+			fmt.Println()
+		}
+		quota := fetchConfig.Quota
+		if quota.MaxFiles > 0 || quota.MaxBytes > 0 {
+			fmt.Printf("  Daily quota: %d files, %s\n", quota.MaxFiles, util.FormatSize(quota.MaxBytes))
+		}
+	}
+
 	return nil
 }
 
@@ -131,6 +389,7 @@ func addSubscription(subConfig *config.Subscriptions, topic string, configPath s
 	if err := config.SaveSubscriptions(configPath, subConfig); err != nil {
 		return fmt.Errorf("failed to save subscriptions: %w", err)
 	}
+	recordAudit(logging.AuditSubscribe, topic, nil, map[string]string{"topic_hash": topicHash})
 
 	if jsonOutput {
 		result := map[string]interface{}{
@@ -156,6 +415,7 @@ func removeSubscription(subConfig *config.Subscriptions, topic string, configPat
 	if err := config.SaveSubscriptions(configPath, subConfig); err != nil {
 		return fmt.Errorf("failed to save subscriptions: %w", err)
 	}
+	recordAudit(logging.AuditUnsubscribe, topic, nil, nil)
 
 	if jsonOutput {
 		result := map[string]interface{}{
@@ -170,7 +430,7 @@ func removeSubscription(subConfig *config.Subscriptions, topic string, configPat
 	return nil
 }
 
-func monitorSubscriptions(subConfig *config.Subscriptions, storageManager *storage.Manager, sh *shell.Shell, quiet bool) error {
+func monitorSubscriptions(subConfig *config.Subscriptions, fetchConfig *autofetch.Config, sinkConfig *sinks.Config, storageManager *storage.Manager, sh *shell.Shell, cfg *noisefsConfig.Config, quiet bool) error {
 	// Create announcement store
 	storeConfig := store.DefaultStoreConfig(filepath.Join(config.GetConfigDir(), "announcements"))
 	annStore, err := store.NewStore(storeConfig)
@@ -200,10 +460,50 @@ func monitorSubscriptions(subConfig *config.Subscriptions, storageManager *stora
 	securityManager := security.NewManager(nil)
 	defer securityManager.Close()
 
+	// Create auto-fetch engine
+	var fetchEngine *autofetch.Engine
+	if len(fetchConfig.Rules) > 0 {
+		fetchEngine, err = newFetchEngine(fetchConfig, storageManager, cfg)
+		if err != nil {
+			return err
+		}
+		fetchEngine.Start()
+		defer fetchEngine.Stop()
+	}
+
+	collectionsPath, err := store.DefaultCollectionStorePath()
+	if err != nil {
+		return err
+	}
+	collections := store.NewCollectionStore(collectionsPath)
+
+	// Sinks for received announcements. Console output would mix with the
+	// records of a standard output sink.
+	sinkSet, err := sinks.OpenSet(sinkConfig, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer sinkSet.Close()
+	for _, sink := range sinkConfig.Sinks {
+		if sink.Enabled && sink.Type == sinks.TypeStdout {
+			quiet = true
+		}
+	}
+
+	// Webhooks for received announcements
+	dispatcher := webhooks.New(cfg.Webhooks)
+	defer dispatcher.Close(webhookFlushTimeout)
+
+	// Saved searches run against every stored announcement. Match counts
+	// are kept by the web UI, which owns the saved searches.
+	searchEngine := announce.NewSearchEngine(nil, announce.NewTopicHierarchy())
+	searchEngine.SetReputation(securityManager.Reputation())
+	savedSearches := subConfig.GetSavedSearches()
+
 	// Create handler with security checks
 	handler := func(ann *announce.Announcement) error {
 		// Perform security checks
-		sourceID := ann.TopicHash + ":" + ann.Nonce // Use topic+nonce as source ID
+		sourceID := announce.SourceID(ann, ann.TopicHash+":"+ann.Nonce) // Publisher if signed, else topic+nonce
 		if err := securityManager.CheckAnnouncement(ann, sourceID); err != nil {
 			if !quiet {
 				fmt.Printf("\n[%s] Announcement rejected: %s\n", time.Now().Format("15:04:05"), err)
@@ -211,23 +511,67 @@ func monitorSubscriptions(subConfig *config.Subscriptions, storageManager *stora
 			return nil // Don't propagate security errors
 		}
 
+		if ann.Kind == announce.KindCollection {
+			receiveCollection(ann, storageManager, collections, quiet)
+			return nil
+		}
+
 		// Store announcement
 		if err := annStore.Add(ann, "monitor"); err != nil {
 			return err
 		}
 
+		// Find matching subscription
+		var topic string
+		for _, sub := range subConfig.GetAll() {
+			if sub.TopicHash == ann.TopicHash {
+				topic = sub.Topic
+				break
+			}
+		}
+
 		if !quiet {
 			fmt.Printf("\n[%s] New announcement:\n", time.Now().Format("15:04:05"))
 			fmt.Printf("  Descriptor: %s\n", ann.Descriptor)
 			fmt.Printf("  Topic hash: %s...\n", ann.TopicHash[:16])
 			fmt.Printf("  Category: %s, Size: %s\n", ann.Category, ann.SizeClass)
+			if ann.Metadata != "" {
+				fmt.Printf("  Metadata: %s\n", ann.Metadata)
+			}
+			if topic != "" {
+				fmt.Printf("  Matched topic: %s\n", topic)
+			}
+		}
 
-			// Find matching subscription
-			for _, sub := range subConfig.GetAll() {
-				if sub.TopicHash == ann.TopicHash {
-					fmt.Printf("  Matched topic: %s\n", sub.Topic)
-					break
-				}
+		written, err := sinkSet.Handle(ann, topic)
+		if err != nil {
+			logging.GetGlobalLogger().Warn("Failed to write announcement to sinks", map[string]interface{}{
+				"descriptor": ann.Descriptor,
+				"error":      err.Error(),
+			})
+		}
+		if len(written) > 0 && !quiet {
+			fmt.Printf("  Written to sinks: %s\n", strings.Join(written, ", "))
+		}
+
+		dispatcher.AnnouncementReceived(ann)
+
+		for _, search := range savedSearches {
+			result, ok := searchEngine.Match(ann, search.Query())
+			if !ok {
+				continue
+			}
+			if !quiet {
+				fmt.Printf("  Saved search matched: %s\n", search.Name)
+			}
+			if search.Notifies(config.NotifyWebhook) {
+				dispatcher.SearchMatched(search.ID, search.Name, result)
+			}
+		}
+
+		if fetchEngine != nil {
+			if decision, ok := fetchEngine.HandleAnnouncement(ann); ok && !quiet {
+				fmt.Printf("  Auto-fetch (%s): %s to %s [%s]\n", decision.Rule, decision.Action, decision.Target, decision.Status)
 			}
 		}
 
@@ -267,11 +611,84 @@ func monitorSubscriptions(subConfig *config.Subscriptions, storageManager *stora
 	}
 
 	if !quiet {
+		if fetchConfig.DryRun {
+			fmt.Println("\nAuto-fetch dry run: matching files will be logged, not fetched")
+		}
+		if sinkSet.Len() > 0 {
+			fmt.Printf("Writing matching announcements to %d sinks\n", sinkSet.Len())
+		}
 		fmt.Println("\nMonitoring for announcements... (Press Ctrl+C to stop)")
 	}
 
-	// Wait for interrupt
-	<-make(chan struct{})
+	// Wait for interrupt; the sinks are flushed and closed on the way out
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	dhtSubscriber.Stop()
 
 	return nil
 }
+
+// newFetchEngine creates an auto-fetch engine downloading with a new client.
+// The FUSE index is only opened if a rule adds files to it.
+func newFetchEngine(fetchConfig *autofetch.Config, storageManager *storage.Manager, cfg *noisefsConfig.Config) (*autofetch.Engine, error) {
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(cfg.Cache.BlockCacheSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	if err := configureRetrieval(client, cfg); err != nil {
+		return nil, err
+	}
+	if err := configureProcessors(client, cfg); err != nil {
+		return nil, err
+	}
+
+	var indexer autofetch.Indexer
+	for _, rule := range fetchConfig.Rules {
+		if rule.Action != autofetch.ActionIndex {
+			continue
+		}
+		indexPath, err := fuse.GetDefaultIndexPath()
+		if err != nil {
+			return nil, err
+		}
+		index := fuse.NewFileIndex(indexPath)
+		if err := index.LoadIndex(); err != nil {
+			return nil, fmt.Errorf("failed to load file index: %w", err)
+		}
+		indexer = index
+		break
+	}
+
+	engine, err := autofetch.NewEngine(fetchConfig, &autofetch.ClientFetcher{Client: client}, indexer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auto-fetch engine: %w", err)
+	}
+	return engine, nil
+}
+
+// receiveCollection fetches the collection an announcement points to and
+// keeps it if it is a new revision
+func receiveCollection(ann *announce.Announcement, storageManager *storage.Manager, collections *store.CollectionStore, quiet bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	collection, err := dht.FetchCollection(ctx, storageManager, ann)
+	if err != nil {
+		if !quiet {
+			fmt.Printf("\n[%s] Collection rejected: %s\n", time.Now().Format("15:04:05"), err)
+		}
+		return
+	}
+	updated, err := collections.Put(collection, ann.Descriptor, "subscription")
+	if err != nil {
+		logging.GetGlobalLogger().Warn("Failed to store collection", map[string]interface{}{
+			"collection": collection.ID(),
+			"error":      err.Error(),
+		})
+		return
+	}
+	if updated && !quiet {
+		fmt.Printf("\n[%s] Collection updated: %s (revision %d, %d files)\n", time.Now().Format("15:04:05"), collection.Title, collection.Revision, len(collection.Entries))
+		fmt.Printf("  ID: %s\n", collection.ID())
+	}
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/webhooks"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/sync"
 	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // handleSyncCommand handles all sync-related subcommands
-func handleSyncCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+func handleSyncCommand(args []string, storageManager *storage.Manager, webhookConfigs []config.WebhookConfig, quiet bool, jsonOutput bool) error {
 	if len(args) == 0 {
 		return showSyncUsage()
 	}
@@ -23,7 +26,7 @@ func handleSyncCommand(args []string, storageManager *storage.Manager, quiet boo
 
 	switch subcommand {
 	case "start":
-		return handleSyncStart(subArgs, storageManager, quiet, jsonOutput)
+		return handleSyncStart(subArgs, storageManager, webhookConfigs, quiet, jsonOutput)
 	case "stop":
 		return handleSyncStop(subArgs, storageManager, quiet, jsonOutput)
 	case "status":
@@ -34,6 +37,8 @@ func handleSyncCommand(args []string, storageManager *storage.Manager, quiet boo
 		return handleSyncPause(subArgs, storageManager, quiet, jsonOutput)
 	case "resume":
 		return handleSyncResume(subArgs, storageManager, quiet, jsonOutput)
+	case "rules":
+		return handleSyncRules(subArgs, quiet, jsonOutput)
 	default:
 		return fmt.Errorf("unknown sync subcommand: %s", subcommand)
 	}
@@ -44,26 +49,100 @@ func showSyncUsage() error {
 	fmt.Println("Usage: noisefs sync <subcommand> [options]")
 	fmt.Println()
 	fmt.Println("Subcommands:")
-	fmt.Println("  start <sync-id> <local-path> <remote-path> [manifest-cid]  Start a new sync session")
+	fmt.Println("  start [options] <sync-id> <local-path> <remote-path> [manifest-cid]")
+	fmt.Println("                                                             Start a new sync session")
 	fmt.Println("  stop <sync-id>                                             Stop a sync session")
 	fmt.Println("  status [sync-id]                                           Show sync status")
 	fmt.Println("  list                                                       List all active syncs")
 	fmt.Println("  pause <sync-id>                                            Pause a sync session")
 	fmt.Println("  resume <sync-id>                                           Resume a sync session")
+	fmt.Println("  rules <sync-id> [show|add|remove|clear] [pattern...]       Manage include/exclude rules")
+	fmt.Println()
+	fmt.Println("Start options:")
+	fmt.Println("  --max-bandwidth <rate>      Limit transfer rate per second (e.g. 512KB, 2MB)")
+	fmt.Println("  --max-parallel-files <n>    Files synced concurrently (default 1)")
+	fmt.Println("  --max-parallel-blocks <n>   Concurrent block transfers (default unlimited)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  noisefs sync start myproject /local/project /remote/project")
+	fmt.Println("  noisefs sync start --max-bandwidth 1MB --max-parallel-files 2 myproject /local/project /remote/project")
 	fmt.Println("  noisefs sync status myproject")
 	fmt.Println("  noisefs sync list")
 	fmt.Println("  noisefs sync stop myproject")
+	fmt.Println("  noisefs sync rules myproject add node_modules/ '*.mp4' '!keep.mp4'")
+	fmt.Println()
+	fmt.Println("Rules use gitignore syntax and are stored per sync pair in ~/.noisefs/sync/<sync-id>.syncignore")
 	fmt.Println()
 	return nil
 }
 
+// transferLimitFlags holds the bandwidth and concurrency flags shared by sync and share-directory
+type transferLimitFlags struct {
+	maxBandwidth      *string
+	maxParallelFiles  *int
+	maxParallelBlocks *int
+}
+
+// registerTransferLimitFlags adds the transfer limit flags to a flag set.
+// The parallel-files flag is only registered when withFiles is set, for
+// commands that transfer whole files.
+func registerTransferLimitFlags(flagSet *flag.FlagSet, withFiles bool) *transferLimitFlags {
+	limits := &transferLimitFlags{
+		maxBandwidth:      flagSet.String("max-bandwidth", "", "Maximum transfer rate per second (e.g. 512KB, 2MB); unlimited if empty"),
+		maxParallelBlocks: flagSet.Int("max-parallel-blocks", 0, "Maximum concurrent block transfers (0 = unlimited)"),
+	}
+	if withFiles {
+		limits.maxParallelFiles = flagSet.Int("max-parallel-files", 1, "Maximum files synced concurrently")
+	}
+	return limits
+}
+
+// bandwidth returns the parsed --max-bandwidth value in bytes per second
+func (l *transferLimitFlags) bandwidth() (int64, error) {
+	if *l.maxBandwidth == "" {
+		return 0, nil
+	}
+	bytesPerSecond, err := util.ParseSize(*l.maxBandwidth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --max-bandwidth: %w", err)
+	}
+	if bytesPerSecond < 0 {
+		return 0, fmt.Errorf("invalid --max-bandwidth: must not be negative")
+	}
+	return bytesPerSecond, nil
+}
+
+// apply validates the flags and copies them into a sync configuration
+func (l *transferLimitFlags) apply(syncConfig *sync.SyncConfig) error {
+	bandwidth, err := l.bandwidth()
+	if err != nil {
+		return err
+	}
+	if *l.maxParallelBlocks < 0 {
+		return fmt.Errorf("invalid --max-parallel-blocks: must not be negative")
+	}
+	syncConfig.MaxBandwidth = bandwidth
+	syncConfig.MaxParallelBlocks = *l.maxParallelBlocks
+	if l.maxParallelFiles != nil {
+		if *l.maxParallelFiles < 1 {
+			return fmt.Errorf("invalid --max-parallel-files: must be at least 1")
+		}
+		syncConfig.MaxParallelFiles = *l.maxParallelFiles
+	}
+	return nil
+}
+
 // handleSyncStart starts a new sync session
-func handleSyncStart(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+func handleSyncStart(args []string, storageManager *storage.Manager, webhookConfigs []config.WebhookConfig, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("sync start", flag.ContinueOnError)
+	limits := registerTransferLimitFlags(flagSet, true)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	args = flagSet.Args()
+
 	if len(args) < 3 {
-		return fmt.Errorf("usage: noisefs sync start <sync-id> <local-path> <remote-path> [manifest-cid]")
+		return fmt.Errorf("usage: noisefs sync start [--max-bandwidth N] [--max-parallel-files N] [--max-parallel-blocks N] <sync-id> <local-path> <remote-path> [manifest-cid]")
 	}
 
 	syncID := args[0]
@@ -85,8 +164,29 @@ func handleSyncStart(args []string, storageManager *storage.Manager, quiet bool,
 		return fmt.Errorf("local path does not exist: %s", localPath)
 	}
 
-	// Create sync engine
-	syncEngine, err := createSyncEngine(storageManager)
+	// Create sync engine with the requested transfer limits
+	syncConfig := defaultSyncConfig()
+	if err := limits.apply(syncConfig); err != nil {
+		return err
+	}
+
+	// Report finished syncs to webhooks, delivering them before exiting
+	if dispatcher := webhooks.New(webhookConfigs); dispatcher != nil {
+		defer dispatcher.Close(webhookFlushTimeout)
+		syncConfig.OnComplete = func(summary sync.SyncSummary) {
+			dispatcher.SyncCompleted(webhooks.Sync{
+				SyncID:              summary.SyncID,
+				LocalPath:           summary.LocalPath,
+				RemotePath:          summary.RemotePath,
+				CompletedOperations: summary.Progress.CompletedOperations,
+				FailedOperations:    summary.Progress.FailedOperations,
+				BytesTransferred:    summary.Progress.BytesTransferred,
+				DurationSeconds:     time.Since(summary.Progress.StartTime).Seconds(),
+			})
+		}
+	}
+
+	syncEngine, err := createSyncEngineWithConfig(storageManager, syncConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create sync engine: %w", err)
 	}
@@ -391,8 +491,91 @@ func handleSyncResume(args []string, storageManager *storage.Manager, quiet bool
 	return nil
 }
 
-// createSyncEngine creates a configured sync engine
-func createSyncEngine(storageManager *storage.Manager) (*sync.SyncEngine, error) {
+// handleSyncRules shows or edits the include/exclude rules of a sync pair
+func handleSyncRules(args []string, quiet bool, jsonOutput bool) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: noisefs sync rules <sync-id> [show|add|remove|clear] [pattern...]")
+	}
+
+	syncID := args[0]
+	action := "show"
+	var patterns []string
+	if len(args) > 1 {
+		action = args[1]
+		patterns = args[2:]
+	}
+
+	stateStore, err := openSyncStateStore()
+	if err != nil {
+		return err
+	}
+
+	rules, err := stateStore.LoadRules(syncID)
+	if err != nil {
+		return fmt.Errorf("failed to load sync rules: %w", err)
+	}
+
+	switch action {
+	case "show":
+	case "add":
+		if len(patterns) == 0 {
+			return fmt.Errorf("usage: noisefs sync rules <sync-id> add <pattern>...")
+		}
+		for _, pattern := range patterns {
+			if err := rules.Add(pattern); err != nil {
+				return err
+			}
+		}
+	case "remove":
+		if len(patterns) == 0 {
+			return fmt.Errorf("usage: noisefs sync rules <sync-id> remove <pattern>...")
+		}
+		for _, pattern := range patterns {
+			if !rules.Remove(pattern) {
+				return fmt.Errorf("rule not found: %s", pattern)
+			}
+		}
+	case "clear":
+		rules.Rules = rules.Rules[:0]
+	default:
+		return fmt.Errorf("unknown rules action: %s", action)
+	}
+
+	if action != "show" {
+		if err := stateStore.SaveRules(syncID, rules); err != nil {
+			return fmt.Errorf("failed to save sync rules: %w", err)
+		}
+	}
+
+	// Display results
+	lines := rules.Lines()
+	if jsonOutput {
+		util.PrintJSONSuccess(SyncRulesResult{
+			SyncID: syncID,
+			Rules:  lines,
+		})
+	} else if quiet {
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	} else {
+		fmt.Printf("Sync Rules: %s\n", syncID)
+		if len(lines) == 0 {
+			fmt.Println("No rules configured (all files are synced)")
+		}
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
+		}
+		if action != "show" {
+			fmt.Println("\nRules take effect the next time the sync is started.")
+		}
+	}
+
+	return nil
+}
+
+// openSyncStateStore opens the sync state store in the user's config directory
+func openSyncStateStore() (*sync.SyncStateStore, error) {
 	// Get user config directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -411,12 +594,30 @@ func createSyncEngine(storageManager *storage.Manager) (*sync.SyncEngine, error)
 		return nil, fmt.Errorf("failed to create sync state store: %w", err)
 	}
 
-	// Create sync configuration
-	syncConfig := &sync.SyncConfig{
+	return stateStore, nil
+}
+
+// defaultSyncConfig returns the sync configuration used by the CLI
+func defaultSyncConfig() *sync.SyncConfig {
+	return &sync.SyncConfig{
 		SyncInterval:       time.Minute,
 		ConflictResolution: sync.ConflictResolvePrompt,
 		MaxRetries:         3,
 		WatchMode:          true,
+		MaxParallelFiles:   1,
+	}
+}
+
+// createSyncEngine creates a configured sync engine
+func createSyncEngine(storageManager *storage.Manager) (*sync.SyncEngine, error) {
+	return createSyncEngineWithConfig(storageManager, defaultSyncConfig())
+}
+
+// createSyncEngineWithConfig creates a sync engine using the given configuration
+func createSyncEngineWithConfig(storageManager *storage.Manager, syncConfig *sync.SyncConfig) (*sync.SyncEngine, error) {
+	stateStore, err := openSyncStateStore()
+	if err != nil {
+		return nil, err
 	}
 
 	// Create file watcher
@@ -443,7 +644,7 @@ func createSyncEngine(storageManager *storage.Manager) (*sync.SyncEngine, error)
 	}
 
 	// Create sync engine
-	syncEngine, err := sync.NewSyncEngine(stateStore, fileWatcher, remoteMonitor, directoryManager, syncConfig)
+	syncEngine, err := sync.NewSyncEngine(stateStore, fileWatcher, remoteMonitor, directoryManager, encryptionKey, syncConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sync engine: %w", err)
 	}
@@ -488,6 +689,11 @@ type SyncListResponse struct {
 	Stats    *sync.SyncEngineStats `json:"stats"`
 }
 
+type SyncRulesResult struct {
+	SyncID string   `json:"sync_id"`
+	Rules  []string `json:"rules"`
+}
+
 type SyncActionResult struct {
 	SyncID    string    `json:"sync_id"`
 	Action    string    `json:"action"`
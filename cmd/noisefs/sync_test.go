@@ -176,32 +176,32 @@ func TestSyncCommandArguments(t *testing.T) {
 	}
 
 	// Test handleSyncCommand with no args
-	err = handleSyncCommand([]string{}, storageManager, false, false)
+	err = handleSyncCommand([]string{}, storageManager, nil, false, false)
 	if err != nil {
 		// This should show usage, not error
 		t.Logf("handleSyncCommand with no args returned: %v", err)
 	}
 
 	// Test handleSyncCommand with unknown subcommand
-	err = handleSyncCommand([]string{"unknown"}, storageManager, false, false)
+	err = handleSyncCommand([]string{"unknown"}, storageManager, nil, false, false)
 	if err == nil {
 		t.Error("Expected error for unknown subcommand")
 	}
 
 	// Test handleSyncStart with insufficient args
-	err = handleSyncStart([]string{"sync1"}, storageManager, false, false)
+	err = handleSyncStart([]string{"sync1"}, storageManager, nil, false, false)
 	if err == nil {
 		t.Error("Expected error for insufficient args")
 	}
 
 	// Test handleSyncStart with non-absolute path
-	err = handleSyncStart([]string{"sync1", "relative/path", "/remote/path"}, storageManager, false, false)
+	err = handleSyncStart([]string{"sync1", "relative/path", "/remote/path"}, storageManager, nil, false, false)
 	if err == nil {
 		t.Error("Expected error for non-absolute path")
 	}
 
 	// Test handleSyncStart with non-existent path
-	err = handleSyncStart([]string{"sync1", "/non/existent/path", "/remote/path"}, storageManager, false, false)
+	err = handleSyncStart([]string{"sync1", "/non/existent/path", "/remote/path"}, storageManager, nil, false, false)
 	if err == nil {
 		t.Error("Expected error for non-existent path")
 	}
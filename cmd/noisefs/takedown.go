@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/compliance"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// TakedownAddResult is the output of takedown add
+type TakedownAddResult struct {
+	Record   *compliance.TakedownRecord `json:"record"`
+	Unpinned []string                   `json:"unpinned"`
+}
+
+// takedownCommand records takedown notices, lifts them and reports on them
+func takedownCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: takedown add|list|reinstate|report [options]")
+	}
+	switch args[0] {
+	case "add":
+		return takedownAddCommand(args[1:], storageManager, quiet, jsonOutput)
+	case "list":
+		return takedownListCommand(args[1:], quiet, jsonOutput)
+	case "reinstate":
+		return takedownReinstateCommand(args[1:], quiet, jsonOutput)
+	case "report":
+		return takedownReportCommand(args[1:], quiet, jsonOutput)
+	default:
+		return fmt.Errorf("unknown takedown command %q (use add, list, reinstate or report)", args[0])
+	}
+}
+
+// openTakedownStore opens the compliance store the web UI enforces
+func openTakedownStore() (*compliance.TakedownStore, error) {
+	path, err := compliance.DefaultTakedownStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return compliance.NewTakedownStore(path), nil
+}
+
+// takedownAddCommand records a notice, blocklists the descriptor and
+// unpins its blocks
+func takedownAddCommand(args []string, storageManager *storage.Manager, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("takedown add", flag.ContinueOnError)
+	requestor := flagSet.String("requestor", "", "Who sent the notice (required)")
+	email := flagSet.String("email", "", "Requestor's contact address")
+	basis := flagSet.String("basis", "", "Legal basis, e.g. \"DMCA 512(c)\"")
+	work := flagSet.String("work", "", "The work or content the notice concerns")
+	noticeFile := flagSet.String("notice-file", "", "File holding the original notice, kept for the record")
+	notes := flagSet.String("notes", "", "Processing notes")
+	noUnpin := flagSet.Bool("no-unpin", false, "Only blocklist the descriptor; leave its blocks pinned")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs takedown add -requestor NAME [options] <descriptor-cid>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one descriptor CID is required")
+	}
+
+	notice := &compliance.TakedownNotice{
+		DescriptorCID:  flagSet.Arg(0),
+		RequestorName:  *requestor,
+		RequestorEmail: *email,
+		Work:           *work,
+		LegalBasis:     *basis,
+		Notes:          *notes,
+	}
+	if *noticeFile != "" {
+		data, err := os.ReadFile(*noticeFile)
+		if err != nil {
+			return fmt.Errorf("failed to read notice: %w", err)
+		}
+		notice.Notice = string(data)
+	}
+
+	takedowns, err := openTakedownStore()
+	if err != nil {
+		return err
+	}
+	record, err := takedowns.Record(notice)
+	if err != nil {
+		return err
+	}
+
+	result := &TakedownAddResult{Record: record, Unpinned: []string{}}
+	var unpinErr error
+	if !*noUnpin {
+		result.Unpinned, unpinErr = compliance.UnpinDescriptor(context.Background(), storageManager, record.DescriptorCID)
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(result)
+		return unpinErr
+	}
+	if quiet {
+		fmt.Println(record.TakedownID)
+		return unpinErr
+	}
+
+	fmt.Printf("Recorded takedown %s for %s\n", record.TakedownID, record.DescriptorCID)
+	fmt.Println("The web UI now refuses to serve this descriptor.")
+	if !*noUnpin {
+		fmt.Printf("Unpinned %d blocks; run 'ipfs repo gc' to reclaim their space.\n", len(result.Unpinned))
+	}
+	return unpinErr
+}
+
+func takedownListCommand(args []string, quiet bool, jsonOutput bool) error {
+	takedowns, err := openTakedownStore()
+	if err != nil {
+		return err
+	}
+	records, err := takedowns.List()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(records)
+		return nil
+	}
+	if len(records) == 0 && !quiet {
+		fmt.Println("No takedowns")
+		return nil
+	}
+	for _, record := range records {
+		if quiet {
+			fmt.Println(record.DescriptorCID)
+			continue
+		}
+		fmt.Printf("%s  %s  %s\n", record.TakedownID, record.DescriptorCID, record.Status)
+		fmt.Printf("  Received: %s from %s\n", record.TakedownDate.Local().Format(time.RFC1123), record.RequestorName)
+		fmt.Printf("  Legal basis: %s\n", record.LegalBasis)
+		if record.CopyrightWork != "" {
+			fmt.Printf("  Work: %s\n", record.CopyrightWork)
+		}
+		if record.ReinstatementDate != nil {
+			fmt.Printf("  Reinstated: %s\n", record.ReinstatementDate.Local().Format(time.RFC1123))
+		}
+	}
+	return nil
+}
+
+func takedownReinstateCommand(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("takedown reinstate", flag.ContinueOnError)
+	reason := flagSet.String("reason", "", "Why the takedown is lifted, e.g. an accepted counter-notice")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs takedown reinstate [-reason TEXT] <descriptor-cid>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one descriptor CID is required")
+	}
+
+	takedowns, err := openTakedownStore()
+	if err != nil {
+		return err
+	}
+	if err := takedowns.Reinstate(flagSet.Arg(0), *reason); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]string{"reinstated": flagSet.Arg(0)})
+	} else if !quiet {
+		fmt.Printf("Reinstated %s; the web UI serves it again\n", flagSet.Arg(0))
+		fmt.Println("Blocks unpinned by the takedown are not pinned again.")
+	}
+	return nil
+}
+
+// takedownReportCommand writes a transparency report suitable for
+// publication
+func takedownReportCommand(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("takedown report", flag.ContinueOnError)
+	from := flagSet.String("from", "", "First day of the report, YYYY-MM-DD (default: start of this year)")
+	to := flagSet.String("to", "", "Last day of the report, YYYY-MM-DD (default: today)")
+	output := flagSet.String("o", "", "Write the report as JSON to this file")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.String("api", "", "IPFS API endpoint")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs takedown report [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-o report.json]")
+		fmt.Fprintln(flagSet.Output(), "The report leaves out requestors' contact details and notice texts.")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	start, end, err := compliance.ReportPeriod(*from, *to, time.Now())
+	if err != nil {
+		return err
+	}
+	takedowns, err := openTakedownStore()
+	if err != nil {
+		return err
+	}
+	report, err := takedowns.TransparencyReport(start, end)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(report)
+		return nil
+	}
+	if quiet {
+		return nil
+	}
+
+	fmt.Printf("Transparency report %s to %s\n", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Printf("  Notices received: %d\n", report.Notices)
+	fmt.Printf("  Takedowns reinstated: %d\n", report.Reinstated)
+	fmt.Printf("  Takedowns in force: %d\n", report.Active)
+	bases := make([]string, 0, len(report.ByLegalBasis))
+	for basis := range report.ByLegalBasis {
+		bases = append(bases, basis)
+	}
+	sort.Strings(bases)
+	for _, basis := range bases {
+		fmt.Printf("    %s: %d\n", basis, report.ByLegalBasis[basis])
+	}
+	if *output != "" {
+		fmt.Printf("Report written to %s\n", *output)
+	}
+	return nil
+}
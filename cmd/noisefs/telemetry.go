@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/telemetry"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// TelemetryStatus is the output of telemetry status and preview
+type TelemetryStatus struct {
+	Enabled  bool              `json:"enabled"`
+	Endpoint string            `json:"endpoint"`
+	NextSend time.Time         `json:"next_send"`
+	Report   *telemetry.Report `json:"report"` // Exactly what the next report sends
+}
+
+// telemetryCommand shows, enables and disables anonymous usage reports
+func telemetryCommand(args []string, cfg *config.Config, configPath string, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	action := args[0]
+
+	flagSet := flag.NewFlagSet("telemetry "+action, flag.ContinueOnError)
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: noisefs telemetry status|preview|enable|disable|send\n\n")
+		fmt.Fprintf(os.Stderr, "Anonymous usage reports are off unless enabled. A report holds the NoiseFS\n")
+		fmt.Fprintf(os.Stderr, "version, the operating system and how often each feature was used, in\n")
+		fmt.Fprintf(os.Stderr, "buckets such as 2-5, and is sent at most once per telemetry.interval_hours.\n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		fmt.Fprintf(os.Stderr, "  status    Whether reports are enabled and when the next one is due\n")
+		fmt.Fprintf(os.Stderr, "  preview   The next report, exactly as it would be sent\n")
+		fmt.Fprintf(os.Stderr, "  enable    Opt in, setting telemetry.enabled in the configuration file\n")
+		fmt.Fprintf(os.Stderr, "  disable   Opt out and delete the counts kept so far\n")
+		fmt.Fprintf(os.Stderr, "  send      Send the report now instead of when it is due\n")
+	}
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	tel := telemetry.New(cfg.Telemetry)
+	switch action {
+	case "status", "preview":
+		report, err := tel.Preview()
+		if err != nil {
+			return err
+		}
+		next, err := tel.NextSend()
+		if err != nil {
+			return err
+		}
+		status := TelemetryStatus{Enabled: tel.Enabled(), Endpoint: cfg.Telemetry.Endpoint, NextSend: next, Report: report}
+		if jsonOutput {
+			util.PrintJSONSuccess(status)
+			return nil
+		}
+		if action == "preview" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			if !quiet && !status.Enabled {
+				fmt.Println("\nTelemetry is disabled: nothing is counted or sent.")
+			}
+			return nil
+		}
+		if quiet {
+			fmt.Println(status.Enabled)
+			return nil
+		}
+		if !status.Enabled {
+			fmt.Println("Telemetry: disabled")
+			fmt.Println("Enable anonymous usage reports with: noisefs telemetry enable")
+			return nil
+		}
+		fmt.Println("Telemetry: enabled")
+		fmt.Printf("Endpoint: %s\n", status.Endpoint)
+		fmt.Printf("Next report: %s\n", status.NextSend.Local().Format(time.RFC1123))
+		fmt.Printf("Features used so far: %d (see noisefs telemetry preview)\n", len(report.Features))
+		return nil
+
+	case "enable", "disable":
+		enabled := action == "enable"
+		if err := setConfigValue(configPath, "telemetry", "enabled", enabled); err != nil {
+			return err
+		}
+		if !enabled {
+			// Counts kept while enabled are never sent now
+			if err := os.Remove(cfg.Telemetry.StatePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(map[string]interface{}{"enabled": enabled, "config": configPath})
+		} else if !quiet {
+			if enabled {
+				fmt.Printf("✓ Telemetry enabled in %s\n", configPath)
+				fmt.Println("See what is sent with: noisefs telemetry preview")
+			} else {
+				fmt.Printf("✓ Telemetry disabled in %s\n", configPath)
+			}
+		}
+		return nil
+
+	case "send":
+		report, err := tel.Send(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(report)
+		} else if !quiet {
+			fmt.Printf("✓ Report sent to %s\n", cfg.Telemetry.Endpoint)
+		}
+		return nil
+	}
+
+	flagSet.Usage()
+	return fmt.Errorf("unknown telemetry command %q", action)
+}
+
+// setConfigValue sets one key of a section in the configuration file,
+// leaving the rest of the file as written rather than saving defaults
+func setConfigValue(path, section, key string, value interface{}) error {
+	if path == "" {
+		return fmt.Errorf("no configuration file; pass -config")
+	}
+	settings := make(map[string]interface{})
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	values, _ := settings[section].(map[string]interface{})
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	values[key] = value
+	settings[section] = values
+
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// telemetryFeatures maps commands to the feature they are counted under
+var telemetryFeatures = map[string]string{
+	"export-bundle":     "bundle",
+	"import-bundle":     "bundle",
+	"share-directory":   "directory_share",
+	"receive-directory": "directory_share",
+	"list-snapshots":    "snapshots",
+	"prune-snapshots":   "snapshots",
+	"unpack":            "pack",
+	"s3-gateway":        "s3_gateway",
+}
+
+// recordUsage counts a use of a command when telemetry is enabled
+func recordUsage(cfg *config.TelemetryConfig, command string) {
+	feature := command
+	if mapped, ok := telemetryFeatures[command]; ok {
+		feature = mapped
+	}
+	// Commands without a feature of their own aren't counted
+	if telemetry.IsFeature(feature) {
+		telemetry.Use(*cfg, feature)
+	}
+}
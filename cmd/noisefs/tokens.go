@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/auth"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// CreatedTokenResult is the output of tokens create
+type CreatedTokenResult struct {
+	*auth.Token
+	Secret string `json:"secret"`
+}
+
+// tokensCommand manages the API tokens the web UI accepts
+func tokensCommand(args []string, quiet bool, jsonOutput bool) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: tokens create|list|revoke [options]")
+	}
+	switch args[0] {
+	case "create":
+		return tokensCreateCommand(args[1:], quiet, jsonOutput)
+	case "list":
+		return tokensListCommand(quiet, jsonOutput)
+	case "revoke":
+		return tokensRevokeCommand(args[1:], quiet, jsonOutput)
+	default:
+		return fmt.Errorf("unknown tokens command %q (use create, list or revoke)", args[0])
+	}
+}
+
+// openTokenStore opens the token store the web UI checks
+func openTokenStore() (*auth.TokenStore, error) {
+	path, err := auth.DefaultTokenStorePath()
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewTokenStore(path), nil
+}
+
+func tokensCreateCommand(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("tokens create", flag.ContinueOnError)
+	roleName := flagSet.String("role", string(auth.RoleViewer), "Role of the token: viewer, uploader or admin")
+	expires := flagSet.Duration("expires", 0, "How long the token lasts, e.g. 720h (default: no expiry)")
+	flagSet.Bool("quiet", false, "Print only the token")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs tokens create [-role viewer|uploader|admin] [-expires 720h] <name>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one token name is required")
+	}
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+	if *expires < 0 {
+		return fmt.Errorf("-expires cannot be negative")
+	}
+
+	tokens, err := openTokenStore()
+	if err != nil {
+		return err
+	}
+	token, secret, err := tokens.Create(flagSet.Arg(0), role, *expires)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(CreatedTokenResult{Token: token, Secret: secret})
+		return nil
+	}
+	if quiet {
+		fmt.Println(secret)
+		return nil
+	}
+	fmt.Printf("Created %s token %s (%s)\n", token.Role, token.ID, token.Name)
+	if token.ExpiresAt != nil {
+		fmt.Printf("Expires: %s\n", token.ExpiresAt.Local().Format(time.RFC1123))
+	}
+	fmt.Printf("\n%s\n\n", secret)
+	fmt.Println("Store it now; it can't be shown again. Send it as 'Authorization: Bearer <token>'.")
+	return nil
+}
+
+func tokensListCommand(quiet bool, jsonOutput bool) error {
+	tokens, err := openTokenStore()
+	if err != nil {
+		return err
+	}
+	list, err := tokens.List()
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(list)
+		return nil
+	}
+	if len(list) == 0 && !quiet {
+		fmt.Println("No tokens")
+		return nil
+	}
+	now := time.Now()
+	for _, token := range list {
+		if quiet {
+			fmt.Println(token.ID)
+			continue
+		}
+		expiry := "never expires"
+		if token.Expired(now) {
+			expiry = "expired"
+		} else if token.ExpiresAt != nil {
+			expiry = "expires " + token.ExpiresAt.Local().Format(time.RFC1123)
+		}
+		fmt.Printf("%s  %-8s  %s  (%s)\n", token.ID, token.Role, token.Name, expiry)
+	}
+	return nil
+}
+
+func tokensRevokeCommand(args []string, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("tokens revoke", flag.ContinueOnError)
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		fmt.Fprintln(flagSet.Output(), "Usage: noisefs tokens revoke <token-id>")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return fmt.Errorf("exactly one token ID is required")
+	}
+
+	tokens, err := openTokenStore()
+	if err != nil {
+		return err
+	}
+	if err := tokens.Revoke(flagSet.Arg(0)); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		util.PrintJSONSuccess(map[string]string{"revoked": flagSet.Arg(0)})
+	} else if !quiet {
+		fmt.Printf("Revoked token %s; the web UI no longer accepts it\n", flagSet.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/transfers"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/control"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// transfersCommand manages the transfer queue of a running web UI through
+// its control socket, so long uploads and downloads can be queued and
+// left to run in the background
+func transfersCommand(args []string, cfg *config.Config, quiet bool, jsonOutput bool) error {
+	flagSet := flag.NewFlagSet("transfers", flag.ContinueOnError)
+	socket := flagSet.String("socket", cfg.Daemon.ControlSocket, "Control socket of the running web UI")
+	priority := flagSet.Int("priority", 0, "Priority of a new transfer; higher runs first")
+	name := flagSet.String("name", "", "Name to upload the file under (default: its base name)")
+	password := flagSet.String("password", "", "Password of a protected descriptor to download")
+	bandwidth := flagSet.String("bandwidth", "", "Per-second limit of a new transfer (e.g. 1MB)")
+	at := flagSet.String("at", "", "Start a new transfer at this local time (HH:MM) or RFC 3339 time")
+	flagSet.Bool("quiet", false, "Minimal output")
+	flagSet.Bool("json", false, "Output results in JSON format")
+	flagSet.String("config", "", "Configuration file path")
+	flagSet.Usage = func() {
+		out := flagSet.Output()
+		fmt.Fprintln(out, "Usage: noisefs transfers [options] [command]")
+		fmt.Fprintln(out, "Commands:")
+		fmt.Fprintln(out, "  list                          Show the queue (default)")
+		fmt.Fprintln(out, "  download <cid> <path>         Queue a download to a file or directory")
+		fmt.Fprintln(out, "  upload <file>                 Queue an upload")
+		fmt.Fprintln(out, "  pause|resume|cancel|remove <id>")
+		fmt.Fprintln(out, "  priority <id> <priority>")
+		fmt.Fprintln(out, "  bandwidth <id> <size>         Per-second limit, 0 for none")
+		fmt.Fprintln(out, "  schedule <id> <time|now>      Start at HH:MM or an RFC 3339 time")
+		flagSet.PrintDefaults()
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if *socket == "" {
+		return fmt.Errorf("no control socket configured; set daemon.control_socket or pass -socket")
+	}
+
+	// Options of new transfers
+	var options []string
+	if *bandwidth != "" {
+		options = append(options, "bandwidth="+*bandwidth)
+	}
+	if *at != "" {
+		start, err := parseStartTime(*at, time.Now())
+		if err != nil {
+			return err
+		}
+		options = append(options, "start="+start.Format(time.RFC3339))
+	}
+
+	request := flagSet.Args()
+	action := "list"
+	if len(request) > 0 {
+		action = request[0]
+	}
+	switch action {
+	case "download":
+		if len(request) != 3 {
+			flagSet.Usage()
+			return fmt.Errorf("download takes a descriptor CID and a destination")
+		}
+		// The web UI resolves paths from its own working directory
+		path, err := filepath.Abs(request[2])
+		if err != nil {
+			return err
+		}
+		request = []string{"add", "kind=download", "cid=" + request[1], "path=" + path, "priority=" + strconv.Itoa(*priority)}
+		if *password != "" {
+			request = append(request, "password="+*password)
+		}
+		request = append(request, options...)
+	case "upload":
+		if len(request) != 2 {
+			flagSet.Usage()
+			return fmt.Errorf("upload takes one file")
+		}
+		path, err := filepath.Abs(request[1])
+		if err != nil {
+			return err
+		}
+		request = []string{"add", "kind=upload", "path=" + path, "priority=" + strconv.Itoa(*priority)}
+		if *name != "" {
+			request = append(request, "name="+*name)
+		}
+		request = append(request, options...)
+	case "schedule":
+		if len(request) == 3 && request[2] != "now" {
+			start, err := parseStartTime(request[2], time.Now())
+			if err != nil {
+				return err
+			}
+			request[2] = start.Format(time.RFC3339)
+		}
+	}
+
+	if action == "list" {
+		var list []transfers.Transfer
+		if err := control.Call(*socket, "transfers", []string{"list"}, &list); err != nil {
+			return err
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(list)
+			return nil
+		}
+		if len(list) == 0 {
+			if !quiet {
+				fmt.Println("No transfers")
+			}
+			return nil
+		}
+		for _, transfer := range list {
+			printTransfer(transfer, quiet)
+		}
+		return nil
+	}
+
+	if action == "remove" {
+		if err := control.Call(*socket, "transfers", request, nil); err != nil {
+			return err
+		}
+		if jsonOutput {
+			util.PrintJSONSuccess(map[string]string{"removed": request[len(request)-1]})
+		} else if !quiet {
+			fmt.Println("Removed", request[len(request)-1])
+		}
+		return nil
+	}
+
+	var transfer transfers.Transfer
+	if err := control.Call(*socket, "transfers", request, &transfer); err != nil {
+		return err
+	}
+	if jsonOutput {
+		util.PrintJSONSuccess(transfer)
+		return nil
+	}
+	printTransfer(transfer, quiet)
+	return nil
+}
+
+// parseStartTime reads a start time given as HH:MM, the next time the
+// clock shows it, or as an RFC 3339 time
+func parseStartTime(value string, now time.Time) (time.Time, error) {
+	if clock, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		start := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !start.After(now) {
+			start = start.AddDate(0, 0, 1)
+		}
+		return start, nil
+	}
+	start, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start time %q: use HH:MM or an RFC 3339 time such as 2006-01-02T15:04:05Z", value)
+	}
+	return start, nil
+}
+
+// printTransfer prints a line about a transfer, or only its ID when quiet
+func printTransfer(transfer transfers.Transfer, quiet bool) {
+	if quiet {
+		fmt.Println(transfer.ID)
+		return
+	}
+	subject := transfer.Name
+	if subject == "" {
+		subject = transfer.DescriptorCID
+	}
+	progress := ""
+	switch {
+	case transfer.State == transfers.StateRunning && transfer.Total > 0:
+		progress = fmt.Sprintf(" %s %d/%d", transfer.Stage, transfer.Current, transfer.Total)
+	case transfer.State == transfers.StateCompleted && transfer.Kind == transfers.KindUpload:
+		progress = " -> " + transfer.DescriptorCID
+	case transfer.State == transfers.StateCompleted:
+		progress = " -> " + transfer.Output
+	case transfer.Error != "":
+		progress = ": " + transfer.Error
+	case transfer.State == transfers.StateQueued && !transfer.Due(time.Now()):
+		progress = " at " + transfer.StartAt.Local().Format("2006-01-02 15:04")
+	}
+	if transfer.BandwidthLimit > 0 && !transfer.State.Finished() {
+		progress += fmt.Sprintf(" (max %s/s)", util.FormatSize(transfer.BandwidthLimit))
+	}
+	fmt.Printf("%s  %-8s %-9s p%-3d %s%s\n", transfer.ID, transfer.Kind, transfer.State, transfer.Priority, subject, progress)
+}
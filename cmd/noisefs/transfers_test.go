@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStartTime(t *testing.T) {
+	now := time.Date(2026, 3, 10, 14, 30, 0, 0, time.Local)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"23:00", time.Date(2026, 3, 10, 23, 0, 0, 0, time.Local)},
+		{"02:00", time.Date(2026, 3, 11, 2, 0, 0, 0, time.Local)},   // Already past today
+		{"14:30", time.Date(2026, 3, 11, 14, 30, 0, 0, time.Local)}, // Now is not later
+		{"2026-04-01T01:00:00Z", time.Date(2026, 4, 1, 1, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseStartTime(tt.value, now)
+		if err != nil {
+			t.Errorf("parseStartTime(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseStartTime(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"tonight", "25:00", "2026-04-01"} {
+		if _, err := parseStartTime(value, now); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
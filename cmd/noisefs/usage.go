@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// detailedStats is the -stats -detail JSON output
+type detailedStats struct {
+	util.StatsResult
+	Usage *descriptors.StorageUsage `json:"usage,omitempty"`
+}
+
+// measureStorageUsage reports the storage the given descriptors take up,
+// or every descriptor in the local file index when none are given
+func measureStorageUsage(storageManager *storage.Manager, cids []string) (*descriptors.StorageUsage, error) {
+	if len(cids) == 0 {
+		for cid := range indexedDescriptors() {
+			cids = append(cids, cid)
+		}
+		sort.Strings(cids)
+	}
+	return descriptors.MeasureUsage(context.Background(), storageManager, cids, os.Getenv(bundlePasswordEnv))
+}
+
+// printStorageUsage prints a usage report, with a line per descriptor
+func printStorageUsage(usage *descriptors.StorageUsage) {
+	fmt.Println("\n--- Storage Usage ---")
+	fmt.Printf("Descriptors: %d", usage.Descriptors)
+	if usage.Unreadable > 0 {
+		fmt.Printf(" (%d unreadable, not counted)", usage.Unreadable)
+	}
+	fmt.Println()
+	fmt.Printf("Unique Blocks: %d (%d shared)\n", usage.UniqueBlocks, usage.SharedBlocks)
+	fmt.Printf("Logical Data: %s\n", formatBytes(usage.LogicalBytes))
+	fmt.Printf("Stored Data: %s (%s without sharing)\n", formatBytes(usage.StoredBytes), formatBytes(usage.ReferencedBytes))
+	fmt.Printf("Overhead: %.1f%% (%.1f%% without sharing)\n", usage.EffectiveOverhead, usage.Overhead)
+
+	if len(usage.Files) == 0 {
+		return
+	}
+	fmt.Printf("\n%-10s  %-10s  %-7s  %-7s  %-9s  %s\n", "LOGICAL", "STORED", "BLOCKS", "SHARED", "OVERHEAD", "FILE")
+	for _, file := range usage.Files {
+		name := file.Filename
+		if name == "" {
+			name = file.CID
+		}
+		fmt.Printf("%-10s  %-10s  %-7d  %-7d  %-9s  %s\n",
+			formatBytes(file.LogicalBytes), formatBytes(file.StoredBytes), file.UniqueBlocks,
+			file.SharedWithOthers, fmt.Sprintf("%.1f%%", file.EffectiveOverhead), name)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/webhooks"
+)
+
+// webhookFlushTimeout bounds how long a command waits on exit for its
+// webhook events to be delivered
+const webhookFlushTimeout = 15 * time.Second
+
+// notifyUpload sends the upload.completed event for a file uploaded from
+// the command line
+func notifyUpload(cfg *config.Config, descriptorCID, filename string, size int64) {
+	dispatcher := webhooks.New(cfg.Webhooks)
+	dispatcher.UploadCompleted(webhooks.Upload{
+		DescriptorCID: descriptorCID,
+		Filename:      filename,
+		Size:          size,
+		Source:        "cli",
+	})
+	dispatcher.Close(webhookFlushTimeout)
+}
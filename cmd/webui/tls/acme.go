@@ -0,0 +1,86 @@
+package tls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEConfig holds settings for certificates issued by an ACME CA
+type ACMEConfig struct {
+	Domains      []string // Names the certificate is issued for; other SNI names are refused
+	Email        string   // Contact for expiry and revocation notices
+	CacheDir     string   // Account key and certificates; default ~/.noisefs/acme
+	DirectoryURL string   // ACME directory; default Let's Encrypt production
+	RenewBefore  time.Duration
+}
+
+// ACMEManager obtains and renews certificates on demand. Certificates are
+// requested on the first TLS handshake for a configured domain and renewed
+// in the background before they expire.
+type ACMEManager struct {
+	manager *autocert.Manager
+}
+
+// NewACMEManager creates a manager that accepts the CA's terms of service
+// on the operator's behalf
+func NewACMEManager(config ACMEConfig) (*ACMEManager, error) {
+	if len(config.Domains) == 0 {
+		return nil, fmt.Errorf("at least one ACME domain is required")
+	}
+
+	cacheDir := config.CacheDir
+	if cacheDir == "" {
+		defaultDir, err := GetDefaultACMECacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cacheDir = defaultDir
+	}
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+	}
+
+	manager := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cacheDir),
+		HostPolicy:  autocert.HostWhitelist(config.Domains...),
+		Email:       config.Email,
+		RenewBefore: config.RenewBefore,
+	}
+	if config.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: config.DirectoryURL}
+	}
+
+	return &ACMEManager{manager: manager}, nil
+}
+
+// TLSConfig returns a server configuration that serves managed certificates
+// and answers TLS-ALPN-01 challenges
+func (m *ACMEManager) TLSConfig() *tls.Config {
+	config := m.manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config
+}
+
+// HTTPHandler answers HTTP-01 challenges and redirects every other request
+// to HTTPS. It must be served on port 80 for HTTP-01 validation to succeed.
+func (m *ACMEManager) HTTPHandler() http.Handler {
+	return m.manager.HTTPHandler(nil)
+}
+
+// GetDefaultACMECacheDir returns the default directory for ACME state
+func GetDefaultACMECacheDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, ".noisefs", "acme"), nil
+}
@@ -0,0 +1,27 @@
+package autofetch
+
+import (
+	"context"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+)
+
+// ClientFetcher fetches announced files with a NoiseFS client. Announced
+// descriptors are public, so no password is used.
+type ClientFetcher struct {
+	Client *noisefs.Client
+}
+
+// Stat loads a file's descriptor
+func (f *ClientFetcher) Stat(_ context.Context, descriptorCID string) (string, int64, error) {
+	descriptor, _, err := f.Client.LoadDescriptor(descriptorCID, "")
+	if err != nil {
+		return "", 0, err
+	}
+	return descriptor.Filename, descriptor.FileSize, nil
+}
+
+// Download retrieves a file's contents
+func (f *ClientFetcher) Download(ctx context.Context, descriptorCID string) ([]byte, string, error) {
+	return f.Client.DownloadWithMetadata(ctx, descriptorCID)
+}
@@ -0,0 +1,360 @@
+package autofetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+const (
+	// quotaWindow is the period quotas apply to
+	quotaWindow = 24 * time.Hour
+
+	// historySize is the number of decisions kept for History
+	historySize = 100
+
+	// queueSize bounds fetches waiting for the worker
+	queueSize = 64
+)
+
+// Decision statuses
+const (
+	StatusQueued  = "queued"
+	StatusDryRun  = "dry-run"
+	StatusDone    = "done"
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+)
+
+// ErrQuotaExceeded is recorded when a fetch would exceed the daily quota
+var ErrQuotaExceeded = errors.New("auto-fetch quota exceeded")
+
+// Fetcher retrieves announced files; the NoiseFS client satisfies it
+// through ClientFetcher
+type Fetcher interface {
+	// Stat returns the filename and size recorded in a descriptor
+	Stat(ctx context.Context, descriptorCID string) (string, int64, error)
+	// Download returns a file's contents and filename
+	Download(ctx context.Context, descriptorCID string) ([]byte, string, error)
+}
+
+// Indexer records files in the FUSE index; *fuse.FileIndex satisfies it
+type Indexer interface {
+	AddFile(path, descriptorCID string, fileSize int64)
+	SaveIndex() error
+}
+
+// Decision records what the engine did with an announcement
+type Decision struct {
+	Time       time.Time `json:"time"`
+	Descriptor string    `json:"descriptor"`
+	Rule       string    `json:"rule"`
+	Action     Action    `json:"action"`
+	Target     string    `json:"target,omitempty"` // File written or index path
+	Size       int64     `json:"size,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Engine matches announcements against rules and fetches the matching
+// files in the background, one at a time, within the daily quota. Each
+// descriptor is acted on once, however often it is announced.
+type Engine struct {
+	fetcher Fetcher
+	indexer Indexer
+
+	mu          sync.Mutex
+	config      *Config
+	seen        map[string]bool
+	windowStart time.Time
+	filesUsed   int
+	bytesUsed   int64
+	history     []Decision
+
+	queue  chan Decision
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewEngine creates an engine for the given rules. indexer may be nil if
+// no rule uses ActionIndex.
+func NewEngine(cfg *Config, fetcher Fetcher, indexer Indexer) (*Engine, error) {
+	if fetcher == nil {
+		return nil, errors.New("fetcher cannot be nil")
+	}
+	e := &Engine{
+		fetcher:     fetcher,
+		indexer:     indexer,
+		seen:        make(map[string]bool),
+		windowStart: time.Now(),
+		queue:       make(chan Decision, queueSize),
+	}
+	if err := e.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SetConfig replaces the engine's rules, quota and dry-run setting
+func (e *Engine) SetConfig(cfg *Config) error {
+	if cfg == nil {
+		return errors.New("config cannot be nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if e.indexer == nil {
+		for _, rule := range cfg.Rules {
+			if rule.Action == ActionIndex {
+				return fmt.Errorf("rule %s adds to the FUSE index, but no index is available", rule.Name)
+			}
+		}
+	}
+
+	copied := *cfg
+	copied.Rules = append([]Rule(nil), cfg.Rules...)
+	e.mu.Lock()
+	e.config = &copied
+	e.mu.Unlock()
+	return nil
+}
+
+// Config returns a copy of the engine's configuration
+func (e *Engine) Config() *Config {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	copied := *e.config
+	copied.Rules = append([]Rule(nil), e.config.Rules...)
+	return &copied
+}
+
+// Start starts the background worker that performs queued fetches
+func (e *Engine) Start() {
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.wg.Add(1)
+	go e.worker()
+}
+
+// Stop stops the worker, abandoning fetches still queued
+func (e *Engine) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+}
+
+// HandleAnnouncement applies the first matching rule to an announcement.
+// Matches are queued for the worker, or only logged in dry-run mode. It
+// returns the decision, or false if no rule matched.
+func (e *Engine) HandleAnnouncement(ann *announce.Announcement) (Decision, bool) {
+	// Only files are fetched, not collections
+	if ann == nil || ann.IsExpired() || ann.Kind != "" {
+		return Decision{}, false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.seen[ann.Descriptor] {
+		return Decision{}, false
+	}
+
+	for i := range e.config.Rules {
+		rule := &e.config.Rules[i]
+		if !rule.Matches(ann) {
+			continue
+		}
+
+		decision := Decision{
+			Time:       time.Now(),
+			Descriptor: ann.Descriptor,
+			Rule:       rule.Name,
+			Action:     rule.Action,
+			Target:     rule.TargetDir,
+			Status:     StatusQueued,
+		}
+		if e.config.DryRun {
+			// Not marked as seen so the file is fetched once dry-run is off
+			decision.Status = StatusDryRun
+			e.record(decision)
+			return decision, true
+		}
+
+		select {
+		case e.queue <- decision:
+			e.seen[ann.Descriptor] = true
+		default:
+			decision.Status = StatusSkipped
+			decision.Error = "fetch queue is full"
+		}
+		e.record(decision)
+		return decision, true
+	}
+	return Decision{}, false
+}
+
+// History returns recent decisions, newest last
+func (e *Engine) History() []Decision {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Decision(nil), e.history...)
+}
+
+// Usage returns the files and bytes fetched in the current quota window
+func (e *Engine) Usage() (int, int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollWindow()
+	return e.filesUsed, e.bytesUsed
+}
+
+func (e *Engine) worker() {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case decision := <-e.queue:
+			decision = e.fetch(e.ctx, decision)
+			e.mu.Lock()
+			e.record(decision)
+			e.mu.Unlock()
+		}
+	}
+}
+
+// fetch performs a queued decision and returns it with its outcome
+func (e *Engine) fetch(ctx context.Context, decision Decision) Decision {
+	decision.Time = time.Now()
+	fail := func(err error) Decision {
+		decision.Status = StatusFailed
+		if errors.Is(err, ErrQuotaExceeded) {
+			decision.Status = StatusSkipped
+		}
+		decision.Error = err.Error()
+		return decision
+	}
+
+	filename, size, err := e.fetcher.Stat(ctx, decision.Descriptor)
+	if err != nil {
+		return fail(err)
+	}
+	decision.Size = size
+	name, err := safeFilename(filename)
+	if err != nil {
+		return fail(err)
+	}
+	if err := e.reserve(size); err != nil {
+		return fail(err)
+	}
+
+	switch decision.Action {
+	case ActionIndex:
+		decision.Target = path.Join("/", filepath.ToSlash(decision.Target), name)[1:]
+		e.indexer.AddFile(decision.Target, decision.Descriptor, size)
+		if err := e.indexer.SaveIndex(); err != nil {
+			e.release(size)
+			return fail(fmt.Errorf("failed to save index: %w", err))
+		}
+	case ActionDownload:
+		target, err := e.download(ctx, decision.Descriptor, decision.Target, name)
+		if err != nil {
+			e.release(size)
+			return fail(err)
+		}
+		decision.Target = target
+	}
+
+	decision.Status = StatusDone
+	return decision
+}
+
+// download writes a file into dir without overwriting existing files
+func (e *Engine) download(ctx context.Context, descriptorCID, dir, name string) (string, error) {
+	data, _, err := e.fetcher.Download(ctx, descriptorCID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	target := filepath.Join(dir, name)
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		// Keep both; the CID prefix tells same-named files apart
+		target = filepath.Join(dir, descriptorCID[:min(len(descriptorCID), 12)]+"-"+name)
+		file, err = os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(target)
+		return "", fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return target, file.Close()
+}
+
+// reserve counts a fetch against the quota, failing if it doesn't fit
+func (e *Engine) reserve(size int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollWindow()
+
+	quota := e.config.Quota
+	if quota.MaxFiles > 0 && e.filesUsed+1 > quota.MaxFiles {
+		return fmt.Errorf("%w: %d files per day", ErrQuotaExceeded, quota.MaxFiles)
+	}
+	if quota.MaxBytes > 0 && e.bytesUsed+size > quota.MaxBytes {
+		return fmt.Errorf("%w: %d bytes per day", ErrQuotaExceeded, quota.MaxBytes)
+	}
+	e.filesUsed++
+	e.bytesUsed += size
+	return nil
+}
+
+// release returns a failed fetch's reservation
+func (e *Engine) release(size int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.filesUsed--
+	e.bytesUsed -= size
+}
+
+// rollWindow starts a new quota window once the current one has passed.
+// The caller must hold e.mu.
+func (e *Engine) rollWindow() {
+	if time.Since(e.windowStart) >= quotaWindow {
+		e.windowStart = time.Now()
+		e.filesUsed = 0
+		e.bytesUsed = 0
+	}
+}
+
+// record appends to the bounded history. The caller must hold e.mu.
+func (e *Engine) record(decision Decision) {
+	e.history = append(e.history, decision)
+	if len(e.history) > historySize {
+		e.history = e.history[len(e.history)-historySize:]
+	}
+}
+
+// safeFilename rejects descriptor filenames that would escape the target
+// directory
+func safeFilename(filename string) (string, error) {
+	name := filepath.Base(filename)
+	if filename == "" || name != filename || name == "." || name == ".." {
+		return "", fmt.Errorf("refusing unsafe filename %q", filename)
+	}
+	return name, nil
+}
@@ -0,0 +1,313 @@
+package autofetch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+type fakeFetcher struct {
+	mu        sync.Mutex
+	files     map[string]testFile
+	downloads int
+}
+
+func (f *fakeFetcher) Stat(_ context.Context, cid string) (string, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[cid]
+	if !ok {
+		return "", 0, errors.New("not found")
+	}
+	return file.name, int64(len(file.data)), nil
+}
+
+func (f *fakeFetcher) Download(_ context.Context, cid string) ([]byte, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downloads++
+	file := f.files[cid]
+	return file.data, file.name, nil
+}
+
+type fakeIndexer struct {
+	mu    sync.Mutex
+	files map[string]string
+	saves int
+}
+
+func (i *fakeIndexer) AddFile(path, cid string, _ int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.files[path] = cid
+}
+
+func (i *fakeIndexer) SaveIndex() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.saves++
+	return nil
+}
+
+func newTestAnnouncement(cid, topic, category, sizeClass string, tags ...string) *announce.Announcement {
+	ann := &announce.Announcement{
+		Version:    "1.0",
+		Descriptor: cid,
+		TopicHash:  announce.HashTopic(topic),
+		Category:   category,
+		SizeClass:  sizeClass,
+		Timestamp:  time.Now().Unix(),
+		TTL:        3600,
+		Nonce:      "nonce",
+	}
+	if len(tags) > 0 {
+		ann.TagBloom = announce.CreateTagBloom(tags).Encode()
+	}
+	return ann
+}
+
+// waitForDecisions waits until the engine has recorded n decisions
+func waitForDecisions(t *testing.T, engine *Engine, n int) []Decision {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if history := engine.History(); len(history) >= n {
+			return history
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d decisions, have %+v", n, engine.History())
+	return nil
+}
+
+func TestRuleMatches(t *testing.T) {
+	rule := Rule{
+		Name:         "scifi",
+		Topic:        "movies/scifi",
+		Tags:         []string{"res:1080p"},
+		Categories:   []string{"video"},
+		MinSizeClass: announce.SizeClassSmall,
+		MaxSizeClass: announce.SizeClassLarge,
+		Action:       ActionIndex,
+		Enabled:      true,
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ann  *announce.Announcement
+		want bool
+	}{
+		{"all filters match", newTestAnnouncement("a", "movies/scifi", "video", "medium", "res:1080p", "lang:en"), true},
+		{"other topic", newTestAnnouncement("a", "movies/drama", "video", "medium", "res:1080p"), false},
+		{"other category", newTestAnnouncement("a", "movies/scifi", "audio", "medium", "res:1080p"), false},
+		{"too small", newTestAnnouncement("a", "movies/scifi", "video", "tiny", "res:1080p"), false},
+		{"too large", newTestAnnouncement("a", "movies/scifi", "video", "huge", "res:1080p"), false},
+		{"missing tag", newTestAnnouncement("a", "movies/scifi", "video", "medium", "res:720p"), false},
+		{"no tags", newTestAnnouncement("a", "movies/scifi", "video", "medium"), false},
+	}
+	for _, tt := range tests {
+		if got := rule.Matches(tt.ann); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	rule.Enabled = false
+	if rule.Matches(tests[0].ann) {
+		t.Error("disabled rule should not match")
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	cfg := NewConfig()
+	if err := cfg.SetRule(Rule{Name: "a", Action: ActionDownload}); err == nil {
+		t.Error("expected download rule without target directory to fail")
+	}
+	if err := cfg.SetRule(Rule{Name: "a", Action: ActionIndex, MinSizeClass: "large", MaxSizeClass: "small"}); err == nil {
+		t.Error("expected inverted size range to fail")
+	}
+	if err := cfg.SetRule(Rule{Name: "a", Action: ActionIndex, MinSizeClass: "enormous"}); err == nil {
+		t.Error("expected unknown size class to fail")
+	}
+
+	if err := cfg.SetRule(Rule{Name: "a", Action: ActionIndex}); err != nil {
+		t.Fatalf("SetRule failed: %v", err)
+	}
+	if err := cfg.SetRule(Rule{Name: "a", Action: ActionIndex, TargetDir: "shared"}); err != nil {
+		t.Fatalf("SetRule failed: %v", err)
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].TargetDir != "shared" {
+		t.Errorf("expected rule to be replaced, got %+v", cfg.Rules)
+	}
+
+	path := filepath.Join(t.TempDir(), "autofetch.json")
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(loaded.Rules) != 1 || loaded.Rules[0].Name != "a" {
+		t.Errorf("unexpected loaded rules: %+v", loaded.Rules)
+	}
+
+	if err := cfg.RemoveRule("a"); err != nil {
+		t.Errorf("RemoveRule failed: %v", err)
+	}
+	if err := cfg.RemoveRule("a"); err == nil {
+		t.Error("expected removing a missing rule to fail")
+	}
+}
+
+func TestEngineActions(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := &fakeFetcher{files: map[string]testFile{
+		"QmDownload": {"report.pdf", []byte("report")},
+		"QmIndex":    {"film.mkv", []byte("film")},
+	}}
+	indexer := &fakeIndexer{files: make(map[string]string)}
+
+	cfg := NewConfig()
+	cfg.Rules = []Rule{
+		{Name: "docs", Topic: "documents", Action: ActionDownload, TargetDir: dir, Enabled: true},
+		{Name: "films", Topic: "movies", Action: ActionIndex, TargetDir: "films", Enabled: true},
+	}
+	engine, err := NewEngine(cfg, fetcher, indexer)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.Start()
+	defer engine.Stop()
+
+	if _, ok := engine.HandleAnnouncement(newTestAnnouncement("QmOther", "music", "audio", "small")); ok {
+		t.Error("expected no rule to match")
+	}
+	if decision, ok := engine.HandleAnnouncement(newTestAnnouncement("QmDownload", "documents", "document", "tiny")); !ok || decision.Rule != "docs" {
+		t.Fatalf("expected docs rule to match, got %+v", decision)
+	}
+	if decision, ok := engine.HandleAnnouncement(newTestAnnouncement("QmIndex", "movies", "video", "large")); !ok || decision.Rule != "films" {
+		t.Fatalf("expected films rule to match, got %+v", decision)
+	}
+
+	// Repeated announcements are ignored
+	if _, ok := engine.HandleAnnouncement(newTestAnnouncement("QmDownload", "documents", "document", "tiny")); ok {
+		t.Error("expected repeated announcement to be ignored")
+	}
+
+	history := waitForDecisions(t, engine, 4)
+	for _, decision := range history[2:] {
+		if decision.Status != StatusDone {
+			t.Errorf("expected %s to be done, got %+v", decision.Descriptor, decision)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "report.pdf"))
+	if err != nil || string(data) != "report" {
+		t.Errorf("expected downloaded report, got %q, %v", data, err)
+	}
+	if indexer.files["films/film.mkv"] != "QmIndex" || indexer.saves != 1 {
+		t.Errorf("expected film in index, got %+v (%d saves)", indexer.files, indexer.saves)
+	}
+
+	files, bytes := engine.Usage()
+	if files != 2 || bytes != int64(len("report")+len("film")) {
+		t.Errorf("unexpected usage: %d files, %d bytes", files, bytes)
+	}
+}
+
+func TestEngineDryRun(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := &fakeFetcher{files: map[string]testFile{"QmFile": {"file.txt", []byte("data")}}}
+
+	cfg := NewConfig()
+	cfg.DryRun = true
+	cfg.Rules = []Rule{{Name: "all", Action: ActionDownload, TargetDir: dir, Enabled: true}}
+	engine, err := NewEngine(cfg, fetcher, nil)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.Start()
+	defer engine.Stop()
+
+	decision, ok := engine.HandleAnnouncement(newTestAnnouncement("QmFile", "any", "document", "tiny"))
+	if !ok || decision.Status != StatusDryRun {
+		t.Fatalf("expected dry-run decision, got %+v", decision)
+	}
+	if fetcher.downloads != 0 {
+		t.Error("dry run should not download")
+	}
+
+	// Turning dry-run off fetches announcements seen during the dry run
+	cfg.DryRun = false
+	if err := engine.SetConfig(cfg); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	engine.HandleAnnouncement(newTestAnnouncement("QmFile", "any", "document", "tiny"))
+	history := waitForDecisions(t, engine, 3)
+	if history[2].Status != StatusDone {
+		t.Errorf("expected fetch after dry run, got %+v", history[2])
+	}
+}
+
+func TestEngineQuota(t *testing.T) {
+	dir := t.TempDir()
+	fetcher := &fakeFetcher{files: map[string]testFile{
+		"QmOne":   {"one.txt", []byte("1234")},
+		"QmTwo":   {"two.txt", []byte("5678")},
+		"QmThree": {"../three.txt", []byte("9")},
+	}}
+
+	cfg := NewConfig()
+	cfg.Quota = Quota{MaxBytes: 6}
+	cfg.Rules = []Rule{{Name: "all", Action: ActionDownload, TargetDir: dir, Enabled: true}}
+	engine, err := NewEngine(cfg, fetcher, nil)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.Start()
+	defer engine.Stop()
+
+	for _, cid := range []string{"QmOne", "QmTwo", "QmThree"} {
+		engine.HandleAnnouncement(newTestAnnouncement(cid, "any", "document", "tiny"))
+	}
+	history := waitForDecisions(t, engine, 6)
+
+	statuses := make(map[string]string)
+	for _, decision := range history[3:] {
+		statuses[decision.Descriptor] = decision.Status
+	}
+	if statuses["QmOne"] != StatusDone {
+		t.Errorf("expected first file within quota, got %s", statuses["QmOne"])
+	}
+	if statuses["QmTwo"] != StatusSkipped {
+		t.Errorf("expected second file over quota, got %s", statuses["QmTwo"])
+	}
+	if statuses["QmThree"] != StatusFailed {
+		t.Errorf("expected unsafe filename to fail, got %s", statuses["QmThree"])
+	}
+	if files, bytes := engine.Usage(); files != 1 || bytes != 4 {
+		t.Errorf("unexpected usage: %d files, %d bytes", files, bytes)
+	}
+}
+
+func TestNewEngineRequiresIndexer(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Rules = []Rule{{Name: "films", Action: ActionIndex, Enabled: true}}
+	if _, err := NewEngine(cfg, &fakeFetcher{}, nil); err == nil {
+		t.Error("expected index rule without an indexer to fail")
+	}
+}
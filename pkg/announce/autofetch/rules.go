@@ -0,0 +1,216 @@
+// Package autofetch acts on incoming announcements that match user rules,
+// downloading the announced files or adding them to the FUSE index.
+package autofetch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
+)
+
+// Action is what a rule does with a matching announcement
+type Action string
+
+const (
+	// ActionDownload downloads the file into the rule's target directory
+	ActionDownload Action = "download"
+	// ActionIndex adds the file to the FUSE index under the rule's target
+	// directory, so it is fetched when first read from the mount
+	ActionIndex Action = "index"
+)
+
+// sizeClassOrder ranks announcement size classes for the size filters
+var sizeClassOrder = map[string]int{
+	announce.SizeClassTiny:   1,
+	announce.SizeClassSmall:  2,
+	announce.SizeClassMedium: 3,
+	announce.SizeClassLarge:  4,
+	announce.SizeClassHuge:   5,
+}
+
+// Rule selects announcements and says what to do with them. Every filter
+// that is set must match; an empty filter matches everything.
+type Rule struct {
+	Name         string   `json:"name"`
+	Topic        string   `json:"topic,omitempty"`          // Topic the file was announced under
+	Tags         []string `json:"tags,omitempty"`           // All must be in the announcement's tags
+	Categories   []string `json:"categories,omitempty"`     // Any of these categories
+	MinSizeClass string   `json:"min_size_class,omitempty"` // Smallest size class, e.g. "small"
+	MaxSizeClass string   `json:"max_size_class,omitempty"` // Largest size class, e.g. "medium"
+	Action       Action   `json:"action"`
+	TargetDir    string   `json:"target_dir"` // Local directory, or FUSE index directory for ActionIndex
+	Enabled      bool     `json:"enabled"`
+}
+
+// Validate checks a rule's fields
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+	switch r.Action {
+	case ActionDownload:
+		if r.TargetDir == "" {
+			return fmt.Errorf("rule %s: download rules need a target directory", r.Name)
+		}
+	case ActionIndex:
+	default:
+		return fmt.Errorf("rule %s: unknown action %q (use %s or %s)", r.Name, r.Action, ActionDownload, ActionIndex)
+	}
+	for _, class := range []string{r.MinSizeClass, r.MaxSizeClass} {
+		if class != "" && sizeClassOrder[class] == 0 {
+			return fmt.Errorf("rule %s: unknown size class %q (use tiny, small, medium, large or huge)", r.Name, class)
+		}
+	}
+	if r.MinSizeClass != "" && r.MaxSizeClass != "" && sizeClassOrder[r.MinSizeClass] > sizeClassOrder[r.MaxSizeClass] {
+		return fmt.Errorf("rule %s: min size class %s is larger than max size class %s", r.Name, r.MinSizeClass, r.MaxSizeClass)
+	}
+	return nil
+}
+
+// Matches reports whether an announcement passes all of the rule's filters
+func (r *Rule) Matches(ann *announce.Announcement) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.Topic != "" && announce.HashTopic(r.Topic) != ann.TopicHash {
+		return false
+	}
+	if len(r.Categories) > 0 {
+		found := false
+		for _, category := range r.Categories {
+			if category == ann.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	size := sizeClassOrder[ann.SizeClass]
+	if r.MinSizeClass != "" && size < sizeClassOrder[r.MinSizeClass] {
+		return false
+	}
+	if r.MaxSizeClass != "" && (size == 0 || size > sizeClassOrder[r.MaxSizeClass]) {
+		return false
+	}
+
+	if len(r.Tags) > 0 && !announce.HasAllTags(ann.TagBloom, r.Tags) {
+		return false
+	}
+	return true
+}
+
+// Quota limits what rules may fetch per day. Zero means unlimited.
+type Quota struct {
+	MaxFiles int   `json:"max_files,omitempty"`
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// Config is the saved set of auto-fetch rules
+type Config struct {
+	Version string `json:"version"`
+	Rules   []Rule `json:"rules"`
+	Quota   Quota  `json:"quota"`
+	DryRun  bool   `json:"dry_run"` // Log what would be fetched without fetching
+}
+
+// NewConfig creates an empty configuration
+func NewConfig() *Config {
+	return &Config{
+		Version: "1.0",
+		Rules:   []Rule{},
+	}
+}
+
+// Validate checks every rule and that rule names are unique
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Rules))
+	for i := range c.Rules {
+		if err := c.Rules[i].Validate(); err != nil {
+			return err
+		}
+		if names[c.Rules[i].Name] {
+			return fmt.Errorf("duplicate rule name %s", c.Rules[i].Name)
+		}
+		names[c.Rules[i].Name] = true
+	}
+	if c.Quota.MaxFiles < 0 || c.Quota.MaxBytes < 0 {
+		return errors.New("quota cannot be negative")
+	}
+	return nil
+}
+
+// SetRule adds a rule, replacing any rule with the same name
+func (c *Config) SetRule(rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	for i := range c.Rules {
+		if c.Rules[i].Name == rule.Name {
+			c.Rules[i] = rule
+			return nil
+		}
+	}
+	c.Rules = append(c.Rules, rule)
+	return nil
+}
+
+// RemoveRule removes a rule by name
+func (c *Config) RemoveRule(name string) error {
+	for i := range c.Rules {
+		if c.Rules[i].Name == name {
+			c.Rules = append(c.Rules[:i], c.Rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no auto-fetch rule named %s", name)
+}
+
+// DefaultConfigPath returns the location of the auto-fetch rules
+func DefaultConfigPath() string {
+	return filepath.Join(config.GetConfigDir(), "autofetch.json")
+}
+
+// LoadConfig loads rules from a file, returning an empty configuration if
+// it doesn't exist
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := NewConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auto-fetch rules in %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig saves rules to a file
+func SaveConfig(path string, cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -162,6 +162,15 @@ func MatchesTags(bloomEncoded string, userTags []string) (bool, []string, error)
 	return len(matches) > 0, matches, nil
 }
 
+// HasAllTags reports whether a tag bloom filter may hold every one of
+// tags. Tags are only published as a bloom filter, so false positives are
+// possible but a missing tag is always detected. Undecodable filters match
+// nothing.
+func HasAllTags(bloomEncoded string, tags []string) bool {
+	_, matched, err := MatchesTags(bloomEncoded, tags)
+	return err == nil && len(matched) == len(tags)
+}
+
 // Helper functions
 
 // hash generates the i-th hash for an item
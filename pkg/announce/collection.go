@@ -0,0 +1,267 @@
+package announce
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CollectionVersion is the version of the collection format
+const CollectionVersion = "1.0"
+
+// KindCollection marks announcements of a collection rather than a file.
+// Their Descriptor is the CID of the block holding the signed collection.
+const KindCollection = "collection"
+
+const (
+	// MaxCollectionEntries bounds the descriptors in one collection
+	MaxCollectionEntries = 1000
+
+	// MaxCollectionSize bounds a serialized collection, so fetching one
+	// announced by a stranger stays cheap
+	MaxCollectionSize = 1 << 20
+
+	maxCollectionTitle       = 200
+	maxCollectionDescription = 2000
+	maxCollectionEntryTags   = 20
+	maxCollectionTagLength   = 64
+)
+
+// collectionSigningPrefix separates collection signatures from anything
+// else a curator key might sign
+const collectionSigningPrefix = "noisefs collection v1\n"
+
+// collectionNamePattern is what curators may name collections
+var collectionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+var (
+	// ErrInvalidSignature is returned for collections not signed by the
+	// curator they name
+	ErrInvalidSignature = errors.New("invalid collection signature")
+)
+
+// Collection is a curated list of descriptors, signed by its curator and
+// published to a topic. Curators publish new revisions under the same name
+// to update it; subscribers keep the latest revision of each collection,
+// which is identified by its curator and name.
+type Collection struct {
+	Version     string            `json:"v"`
+	Name        string            `json:"name"` // Stable across revisions, e.g. "scifi-classics"
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	TopicHash   string            `json:"t"`
+	Revision    int64             `json:"rev"` // Increases with every publication
+	Entries     []CollectionEntry `json:"entries"`
+	Timestamp   int64             `json:"ts"`      // Unix time of the revision
+	Curator     string            `json:"curator"` // Hex ed25519 public key
+	Signature   string            `json:"sig"`     // Hex signature over the rest
+}
+
+// CollectionEntry is a descriptor in a collection
+type CollectionEntry struct {
+	Descriptor string   `json:"d"`
+	Title      string   `json:"title"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// ID identifies a collection across its revisions: a hash of its curator
+// and name, so nobody else can publish revisions of it
+func (c *Collection) ID() string {
+	return CollectionID(c.Curator, c.Name)
+}
+
+// CollectionID returns the ID of the collection a curator publishes under
+// name
+func CollectionID(curator, name string) string {
+	sum := sha256.Sum256([]byte(curator + "/" + name))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Validate checks the collection's fields, but not its signature
+func (c *Collection) Validate() error {
+	if c.Version != CollectionVersion {
+		return fmt.Errorf("unsupported collection version: %s", c.Version)
+	}
+	if !collectionNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("invalid collection name %q: use up to 64 lowercase letters, digits, '.', '_' and '-'", c.Name)
+	}
+	if strings.TrimSpace(c.Title) == "" || len(c.Title) > maxCollectionTitle {
+		return fmt.Errorf("collection title must be 1-%d characters", maxCollectionTitle)
+	}
+	if len(c.Description) > maxCollectionDescription {
+		return fmt.Errorf("collection description too long: %d > %d", len(c.Description), maxCollectionDescription)
+	}
+
+	validator := NewValidator(nil)
+	if err := validator.validateTopicHash(c.TopicHash); err != nil {
+		return fmt.Errorf("invalid topic hash: %w", err)
+	}
+	if c.Revision <= 0 {
+		return errors.New("collection revision must be positive")
+	}
+	if c.Timestamp <= 0 {
+		return errors.New("invalid timestamp")
+	}
+	if len(c.Entries) > MaxCollectionEntries {
+		return fmt.Errorf("too many entries: %d > %d", len(c.Entries), MaxCollectionEntries)
+	}
+	for i, entry := range c.Entries {
+		if err := validator.validateDescriptor(entry.Descriptor); err != nil {
+			return fmt.Errorf("entry %d: invalid descriptor: %w", i+1, err)
+		}
+		if len(entry.Title) > maxCollectionTitle {
+			return fmt.Errorf("entry %d: title too long", i+1)
+		}
+		if len(entry.Tags) > maxCollectionEntryTags {
+			return fmt.Errorf("entry %d: too many tags: %d > %d", i+1, len(entry.Tags), maxCollectionEntryTags)
+		}
+		for _, tag := range entry.Tags {
+			if tag == "" || len(tag) > maxCollectionTagLength {
+				return fmt.Errorf("entry %d: tags must be 1-%d characters", i+1, maxCollectionTagLength)
+			}
+		}
+	}
+	return nil
+}
+
+// Sign validates the collection and signs it as curator key
+func (c *Collection) Sign(key ed25519.PrivateKey) error {
+	c.Curator = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	message, err := c.signedMessage()
+	if err != nil {
+		return err
+	}
+	c.Signature = hex.EncodeToString(ed25519.Sign(key, message))
+	return nil
+}
+
+// Verify validates the collection and checks it was signed by its curator
+func (c *Collection) Verify() error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	publicKey, err := hex.DecodeString(c.Curator)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid curator key %q", c.Curator)
+	}
+	signature, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	message, err := c.signedMessage()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signedMessage is what the signature covers: the collection without its
+// signature
+func (c *Collection) signedMessage() ([]byte, error) {
+	unsigned := *c
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize collection: %w", err)
+	}
+	return append([]byte(collectionSigningPrefix), data...), nil
+}
+
+// CollectionFromJSON parses a collection and checks its signature
+func CollectionFromJSON(data []byte) (*Collection, error) {
+	if len(data) > MaxCollectionSize {
+		return nil, fmt.Errorf("collection too large: %d bytes (max %d)", len(data), MaxCollectionSize)
+	}
+	var c Collection
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse collection: %w", err)
+	}
+	if err := c.Verify(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// NewCollectionAnnouncement creates the announcement of a collection
+// stored in the block collectionCID
+func NewCollectionAnnouncement(collectionCID string, collection *Collection, ttl time.Duration) (*Announcement, error) {
+	ann := NewAnnouncement(collectionCID, collection.TopicHash)
+	ann.Kind = KindCollection
+	ann.Category = CategoryOther
+	ann.SizeClass = SizeClassTiny
+	if ttl > 0 {
+		ann.TTL = int64(ttl.Seconds())
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	ann.Nonce = hex.EncodeToString(nonce)
+	if err := ann.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid announcement: %w", err)
+	}
+	return ann, nil
+}
+
+// DefaultCuratorKeyPath returns ~/.noisefs/curator.key
+func DefaultCuratorKeyPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "curator.key"), nil
+}
+
+// LoadOrCreateCuratorKey reads the key collections are signed with from
+// path, generating one on first use. The file holds the hex seed of an
+// ed25519 key and is readable only by its owner.
+func LoadOrCreateCuratorKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid curator key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read curator key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate curator key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	// O_EXCL so two processes creating the key at once don't end up
+	// signing with different keys
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return LoadOrCreateCuratorKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write curator key: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(key.Seed()) + "\n"); err != nil {
+		return nil, fmt.Errorf("failed to write curator key: %w", err)
+	}
+	return key, nil
+}
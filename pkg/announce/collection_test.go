@@ -0,0 +1,130 @@
+package announce
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCollection() *Collection {
+	return &Collection{
+		Version:   CollectionVersion,
+		Name:      "scifi-classics",
+		Title:     "Science Fiction Classics",
+		TopicHash: HashTopic("movies/scifi"),
+		Revision:  1,
+		Timestamp: time.Now().Unix(),
+		Entries: []CollectionEntry{
+			{Descriptor: "QmMetrop1s", Title: "Metropolis", Tags: []string{"year:1927"}},
+			{Descriptor: "QmSoar1s", Title: "Solaris"},
+		},
+	}
+}
+
+func TestCollection_SignVerify(t *testing.T) {
+	key, err := LoadOrCreateCuratorKey(filepath.Join(t.TempDir(), "curator.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection := testCollection()
+	if err := collection.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	data, err := json.Marshal(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := CollectionFromJSON(data)
+	if err != nil {
+		t.Fatalf("A signed collection should verify: %v", err)
+	}
+	if parsed.ID() != collection.ID() || len(parsed.Entries) != 2 {
+		t.Errorf("Unexpected collection: %+v", parsed)
+	}
+
+	// Any change breaks the signature
+	tampered := *parsed
+	tampered.Entries = append([]CollectionEntry{{Descriptor: "QmMaware", Title: "Free"}}, tampered.Entries...)
+	if err := tampered.Verify(); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected an added entry to break the signature, got %v", err)
+	}
+	tampered = *parsed
+	tampered.Revision++
+	if err := tampered.Verify(); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a new revision to need a new signature, got %v", err)
+	}
+
+	// Another curator's revision is another collection
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	other := testCollection()
+	if err := other.Sign(otherKey); err != nil {
+		t.Fatal(err)
+	}
+	if other.ID() == collection.ID() {
+		t.Error("Collections of different curators should have different IDs")
+	}
+}
+
+func TestCollection_Validate(t *testing.T) {
+	tests := map[string]func(c *Collection){
+		"name":       func(c *Collection) { c.Name = "Sci Fi" },
+		"title":      func(c *Collection) { c.Title = " " },
+		"topic":      func(c *Collection) { c.TopicHash = "movies" },
+		"revision":   func(c *Collection) { c.Revision = 0 },
+		"descriptor": func(c *Collection) { c.Entries[0].Descriptor = "not-a-cid" },
+		"tag":        func(c *Collection) { c.Entries[0].Tags = []string{""} },
+		"entries":    func(c *Collection) { c.Entries = make([]CollectionEntry, MaxCollectionEntries+1) },
+	}
+	for name, mutate := range tests {
+		collection := testCollection()
+		mutate(collection)
+		if err := collection.Validate(); err == nil {
+			t.Errorf("Expected an invalid %s to be refused", name)
+		}
+	}
+}
+
+func TestLoadOrCreateCuratorKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "curator.key")
+	key, err := LoadOrCreateCuratorKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("The key should be readable by its owner only: %v, %v", info, err)
+	}
+	again, err := LoadOrCreateCuratorKey(path)
+	if err != nil || !key.Equal(again) {
+		t.Errorf("Expected the saved key to be loaded, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOrCreateCuratorKey(path); err == nil {
+		t.Error("Expected an invalid key file to be refused")
+	}
+}
+
+func TestNewCollectionAnnouncement(t *testing.T) {
+	collection := testCollection()
+	ann, err := NewCollectionAnnouncement("QmCoectionBock", collection, 48*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.Kind != KindCollection || ann.TopicHash != collection.TopicHash || ann.TTL != 48*3600 {
+		t.Errorf("Unexpected announcement: %+v", ann)
+	}
+	if err := NewValidator(nil).ValidateAnnouncement(ann); err != nil {
+		t.Errorf("Collection announcements should pass validation: %v", err)
+	}
+	ann.Kind = "playlist"
+	if err := ann.Validate(); err == nil {
+		t.Error("Expected an unknown kind to be refused")
+	}
+}
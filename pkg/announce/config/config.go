@@ -19,6 +19,7 @@ type Subscription struct {
 type Subscriptions struct {
 	Version       string         `json:"version"`
 	Subscriptions []Subscription `json:"subscriptions"`
+	SavedSearches []SavedSearch  `json:"saved_searches,omitempty"`
 	mu            sync.RWMutex
 }
 
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// Ways a saved search notifies of matching announcements
+const (
+	NotifyWebSocket = "websocket" // Pushed to open web UI pages
+	NotifyWebhook   = "webhook"   // Sent to webhooks as search.matched events
+	NotifyDesktop   = "desktop"   // Shown as a desktop notification by the web UI
+)
+
+// SavedSearch is a search run against every incoming announcement, which
+// notifies when one matches
+type SavedSearch struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Keywords     []string   `json:"keywords,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	MatchAllTags bool       `json:"match_all_tags,omitempty"` // Otherwise any tag matches
+	Topics       []string   `json:"topics,omitempty"`         // Topic names
+	Categories   []string   `json:"categories,omitempty"`
+	SizeClasses  []string   `json:"size_classes,omitempty"`
+	Notify       []string   `json:"notify"`
+	Created      time.Time  `json:"created"`
+	LastMatch    *time.Time `json:"last_match,omitempty"`
+	Matches      int        `json:"matches"`
+}
+
+// Validate checks a saved search can match and notify
+func (s *SavedSearch) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("saved search name is required")
+	}
+	if len(s.Keywords) == 0 && len(s.Tags) == 0 && len(s.Topics) == 0 && len(s.Categories) == 0 && len(s.SizeClasses) == 0 {
+		return errors.New("saved search needs keywords, tags, topics, categories or size classes")
+	}
+	for _, channel := range s.Notify {
+		switch channel {
+		case NotifyWebSocket, NotifyWebhook, NotifyDesktop:
+		default:
+			return fmt.Errorf("unknown notification %q (use %s, %s or %s)", channel, NotifyWebSocket, NotifyWebhook, NotifyDesktop)
+		}
+	}
+	return nil
+}
+
+// Notifies reports whether the search notifies through channel
+func (s *SavedSearch) Notifies(channel string) bool {
+	for _, c := range s.Notify {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the search query the saved search runs
+func (s *SavedSearch) Query() announce.SearchQuery {
+	query := announce.SearchQuery{
+		Keywords:    s.Keywords,
+		IncludeTags: s.Tags,
+		TagMode:     announce.TagMatchAny,
+		Categories:  s.Categories,
+		SizeClasses: s.SizeClasses,
+		SortBy:      announce.SortByRelevance,
+		SortOrder:   announce.SortDesc,
+	}
+	if s.MatchAllTags {
+		query.TagMode = announce.TagMatchAll
+	}
+	for _, topic := range s.Topics {
+		query.Topics = append(query.Topics, announce.HashTopic(topic))
+	}
+	return query
+}
+
+// AddSavedSearch validates a saved search and adds it, assigning its ID
+func (s *Subscriptions) AddSavedSearch(search SavedSearch) (SavedSearch, error) {
+	if err := search.Validate(); err != nil {
+		return SavedSearch{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return SavedSearch{}, fmt.Errorf("failed to generate ID: %w", err)
+	}
+	search.ID = hex.EncodeToString(id)
+	search.Created = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SavedSearches = append(s.SavedSearches, search)
+	return search, nil
+}
+
+// RemoveSavedSearch removes a saved search by ID
+func (s *Subscriptions) RemoveSavedSearch(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, search := range s.SavedSearches {
+		if search.ID == id {
+			s.SavedSearches = append(s.SavedSearches[:i], s.SavedSearches[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no saved search %s", id)
+}
+
+// GetSavedSearch returns a saved search by ID
+func (s *Subscriptions) GetSavedSearch(id string) (SavedSearch, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, search := range s.SavedSearches {
+		if search.ID == id {
+			return search, true
+		}
+	}
+	return SavedSearch{}, false
+}
+
+// GetSavedSearches returns all saved searches
+func (s *Subscriptions) GetSavedSearches() []SavedSearch {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	searches := make([]SavedSearch, len(s.SavedSearches))
+	copy(searches, s.SavedSearches)
+	return searches
+}
+
+// RecordSavedSearchMatch counts a match of a saved search
+func (s *Subscriptions) RecordSavedSearchMatch(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.SavedSearches {
+		if s.SavedSearches[i].ID == id {
+			s.SavedSearches[i].Matches++
+			s.SavedSearches[i].LastMatch = &at
+			return
+		}
+	}
+}
@@ -30,6 +30,8 @@ type CreateOptions struct {
 	Category   string        // Content category (auto-detected if empty)
 	TTL        time.Duration // Time to live (default 24h)
 	AutoTags   bool          // Auto-extract tags from file
+	Size       int64         // Content size in bytes, used for the size class
+	Metadata   string        // CID of a metadata record for the descriptor
 }
 
 // CreateAnnouncement creates a new announcement for a descriptor
@@ -59,6 +61,9 @@ func (c *Creator) CreateAnnouncement(descriptor string, opts CreateOptions) (*An
 		ann.Category = CategoryOther
 	}
 	
+	ann.SizeClass = GetSizeClass(opts.Size)
+	ann.Metadata = opts.Metadata
+	
 	// Generate nonce for uniqueness
 	nonce := make([]byte, 8)
 	if _, err := rand.Read(nonce); err != nil {
@@ -94,7 +99,8 @@ func (c *Creator) CreateFromFile(descriptor string, filePath string, opts Create
 	}
 	
 	// Set size class
-	sizeClass := GetSizeClass(fileInfo.Size())
+	opts.Size = fileInfo.Size()
+	sizeClass := GetSizeClass(opts.Size)
 	
 	// Auto-extract tags if requested
 	allTags := opts.Tags
@@ -112,9 +118,6 @@ func (c *Creator) CreateFromFile(descriptor string, filePath string, opts Create
 		return nil, err
 	}
 	
-	// Set size class
-	ann.SizeClass = sizeClass
-	
 	// Update bloom filter with all tags
 	if len(allTags) > 0 {
 		bloom := CreateTagBloom(allTags)
@@ -124,6 +127,29 @@ func (c *Creator) CreateFromFile(descriptor string, filePath string, opts Create
 	return ann, nil
 }
 
+// CreateForDescriptor creates an announcement for a descriptor that is
+// already stored, taking the category and automatic tags from the name and
+// size of the file it describes
+func (c *Creator) CreateForDescriptor(descriptor string, filename string, size int64, opts CreateOptions) (*Announcement, error) {
+	if opts.Category == "" {
+		opts.Category = detectCategory(filename)
+	}
+	opts.Size = size
+
+	allTags := opts.Tags
+	if opts.AutoTags {
+		allTags = append(allTags, extractNameTags(filename)...)
+	}
+	allTags = append(allTags, "size:"+GetSizeClass(size))
+
+	ann, err := c.CreateAnnouncement(descriptor, opts)
+	if err != nil {
+		return nil, err
+	}
+	ann.TagBloom = CreateTagBloom(allTags).Encode()
+	return ann, nil
+}
+
 // BatchCreate creates multiple announcements
 func (c *Creator) BatchCreate(descriptors map[string]CreateOptions) ([]*Announcement, error) {
 	announcements := make([]*Announcement, 0, len(descriptors))
@@ -173,19 +199,27 @@ func detectCategory(filePath string) string {
 
 // extractAutoTags extracts tags from file metadata
 func extractAutoTags(filePath string, fileInfo os.FileInfo) []string {
-	tags := []string{}
-	
-	// Add extension tag
-	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
-	if ext != "" {
-		tags = append(tags, "ext:"+ext)
-	}
+	tags := extractNameTags(filePath)
 	
 	// Add modification time tags
 	modTime := fileInfo.ModTime()
 	tags = append(tags, fmt.Sprintf("year:%d", modTime.Year()))
 	tags = append(tags, fmt.Sprintf("month:%02d", modTime.Month()))
 	
+	return tags
+}
+
+// extractNameTags extracts the extension, type and format tags of a file
+// from its name
+func extractNameTags(filePath string) []string {
+	tags := []string{}
+
+	// Add extension tag
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filePath), "."))
+	if ext != "" {
+		tags = append(tags, "ext:"+ext)
+	}
+
 	// Add file type tags based on extension
 	category := detectCategory(filePath)
 	tags = append(tags, "type:"+category)
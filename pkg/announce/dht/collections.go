@@ -0,0 +1,68 @@
+package dht
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// PublishCollection stores a signed collection in a block and announces
+// it to the collection's topic, returning the announcement. Its Descriptor
+// is the CID of the block.
+func (p *Publisher) PublishCollection(ctx context.Context, collection *announce.Collection, ttl time.Duration) (*announce.Announcement, error) {
+	if err := collection.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid collection: %w", err)
+	}
+	data, err := json.Marshal(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize collection: %w", err)
+	}
+	if len(data) > announce.MaxCollectionSize {
+		return nil, fmt.Errorf("collection too large: %d bytes (max %d)", len(data), announce.MaxCollectionSize)
+	}
+
+	block, err := blocks.NewBlock(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block: %w", err)
+	}
+	storeCtx, cancel := context.WithTimeout(ctx, defaultPublishTimeout)
+	address, err := p.storageManager.Put(storeCtx, block)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to store collection: %w", err)
+	}
+
+	ann, err := announce.NewCollectionAnnouncement(address.ID, collection, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Publish(ctx, ann); err != nil {
+		return nil, err
+	}
+	return ann, nil
+}
+
+// FetchCollection retrieves the collection a collection announcement
+// points to and checks its signature and topic
+func FetchCollection(ctx context.Context, storageManager *storage.Manager, ann *announce.Announcement) (*announce.Collection, error) {
+	if ann.Kind != announce.KindCollection {
+		return nil, fmt.Errorf("announcement of a %q is not a collection", ann.Kind)
+	}
+	block, err := storageManager.Get(ctx, &storage.BlockAddress{ID: ann.Descriptor})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch collection: %w", err)
+	}
+	collection, err := announce.CollectionFromJSON(block.Data)
+	if err != nil {
+		return nil, err
+	}
+	if collection.TopicHash != ann.TopicHash {
+		return nil, fmt.Errorf("collection %s belongs to another topic than its announcement", collection.ID())
+	}
+	return collection, nil
+}
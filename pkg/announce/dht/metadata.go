@@ -0,0 +1,50 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// StoreMetadata stores a metadata record in a block and returns its CID,
+// for announcements of the record's descriptor to reference
+func (p *Publisher) StoreMetadata(ctx context.Context, metadata *announce.Metadata) (string, error) {
+	data, err := metadata.ToJSON()
+	if err != nil {
+		return "", fmt.Errorf("invalid metadata: %w", err)
+	}
+	block, err := blocks.NewBlock(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to create block: %w", err)
+	}
+	storeCtx, cancel := context.WithTimeout(ctx, defaultPublishTimeout)
+	defer cancel()
+	address, err := p.storageManager.Put(storeCtx, block)
+	if err != nil {
+		return "", fmt.Errorf("failed to store metadata: %w", err)
+	}
+	return address.ID, nil
+}
+
+// FetchMetadata retrieves the metadata record an announcement references
+// and checks it describes the announced descriptor
+func FetchMetadata(ctx context.Context, storageManager *storage.Manager, ann *announce.Announcement) (*announce.Metadata, error) {
+	if ann.Metadata == "" {
+		return nil, fmt.Errorf("announcement of %s has no metadata", ann.Descriptor)
+	}
+	block, err := storageManager.Get(ctx, &storage.BlockAddress{ID: ann.Metadata})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	metadata, err := announce.MetadataFromJSON(block.Data)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Descriptor != ann.Descriptor {
+		return nil, fmt.Errorf("metadata %s describes %s, not %s", ann.Metadata, metadata.Descriptor, ann.Descriptor)
+	}
+	return metadata, nil
+}
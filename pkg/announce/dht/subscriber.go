@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/announce"
@@ -35,9 +36,10 @@ type Subscriber struct {
 	pollInterval time.Duration
 	
 	// Control
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running atomic.Bool
 }
 
 // subscription represents a topic subscription
@@ -156,16 +158,23 @@ func (s *Subscriber) Start() error {
 	s.wg.Add(2)
 	go s.pollLoop()
 	go s.cleanupLoop()
+	s.running.Store(true)
 	return nil
 }
 
 // Stop stops the subscriber
 func (s *Subscriber) Stop() error {
+	s.running.Store(false)
 	s.cancel()
 	s.wg.Wait()
 	return nil
 }
 
+// Running reports whether the subscriber has been started and not stopped
+func (s *Subscriber) Running() bool {
+	return s.running.Load() && s.ctx.Err() == nil
+}
+
 // GetSubscriptions returns current subscriptions
 func (s *Subscriber) GetSubscriptions() []string {
 	s.subMutex.RLock()
@@ -0,0 +1,145 @@
+package announce
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MetadataVersion is the version of the metadata record format
+const MetadataVersion = "1.0"
+
+const (
+	// MaxMetadataSize bounds a serialized metadata record, so fetching one
+	// for an announcement stays cheap
+	MaxMetadataSize = 64 << 10
+
+	maxMetadataTitle       = 200
+	maxMetadataDescription = 4000
+	maxMetadataLicense     = 100
+)
+
+// checksumPrefix is the only checksum algorithm records use
+const checksumPrefix = "sha256:"
+
+// Metadata is a sidecar record with human-friendly information about a
+// descriptor. It is stored in its own block and referenced from
+// announcements, so the descriptor itself stays free of titles and
+// descriptions that would make its blocks recognizable.
+type Metadata struct {
+	Version     string `json:"v"`
+	Descriptor  string `json:"d"` // The descriptor described
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	License     string `json:"license,omitempty"`  // SPDX identifier or free text
+	Checksum    string `json:"checksum,omitempty"` // "sha256:<hex>" of the file content
+	Preview     string `json:"preview,omitempty"`  // Descriptor CID of a preview, such as a thumbnail
+	Timestamp   int64  `json:"ts"`
+}
+
+// NewMetadata creates an empty metadata record for a descriptor
+func NewMetadata(descriptor string) *Metadata {
+	return &Metadata{
+		Version:    MetadataVersion,
+		Descriptor: descriptor,
+		Timestamp:  time.Now().Unix(),
+	}
+}
+
+// IsEmpty reports whether the record says nothing about its descriptor
+func (m *Metadata) IsEmpty() bool {
+	return m.Title == "" && m.Description == "" && m.License == "" && m.Checksum == "" && m.Preview == ""
+}
+
+// Validate checks the record's fields
+func (m *Metadata) Validate() error {
+	if m.Version != MetadataVersion {
+		return fmt.Errorf("unsupported metadata version: %s", m.Version)
+	}
+	validator := NewValidator(nil)
+	if err := validator.validateDescriptor(m.Descriptor); err != nil {
+		return fmt.Errorf("invalid descriptor: %w", err)
+	}
+	if m.IsEmpty() {
+		return errors.New("metadata record is empty")
+	}
+	if len(m.Title) > maxMetadataTitle {
+		return fmt.Errorf("title too long: %d > %d", len(m.Title), maxMetadataTitle)
+	}
+	if len(m.Description) > maxMetadataDescription {
+		return fmt.Errorf("description too long: %d > %d", len(m.Description), maxMetadataDescription)
+	}
+	if len(m.License) > maxMetadataLicense {
+		return fmt.Errorf("license too long: %d > %d", len(m.License), maxMetadataLicense)
+	}
+	if m.Checksum != "" {
+		if err := validateChecksum(m.Checksum); err != nil {
+			return err
+		}
+	}
+	if m.Preview != "" {
+		if err := validator.validateDescriptor(m.Preview); err != nil {
+			return fmt.Errorf("invalid preview: %w", err)
+		}
+	}
+	if m.Timestamp <= 0 {
+		return errors.New("invalid timestamp")
+	}
+	return nil
+}
+
+// validateChecksum checks a checksum is a sha256 digest in hex
+func validateChecksum(checksum string) error {
+	digest, ok := strings.CutPrefix(checksum, checksumPrefix)
+	if !ok {
+		return fmt.Errorf("invalid checksum %q: must start with %s", checksum, checksumPrefix)
+	}
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("invalid checksum %q: expected %d hex characters", checksum, sha256.Size*2)
+	}
+	return nil
+}
+
+// ToJSON serializes the record after validating it
+func (m *Metadata) ToJSON() ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize metadata: %w", err)
+	}
+	if len(data) > MaxMetadataSize {
+		return nil, fmt.Errorf("metadata too large: %d bytes (max %d)", len(data), MaxMetadataSize)
+	}
+	return data, nil
+}
+
+// MetadataFromJSON parses and validates a metadata record
+func MetadataFromJSON(data []byte) (*Metadata, error) {
+	if len(data) > MaxMetadataSize {
+		return nil, fmt.Errorf("metadata too large: %d bytes (max %d)", len(data), MaxMetadataSize)
+	}
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Checksum returns the checksum of content in the form metadata records use
+func Checksum(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", fmt.Errorf("failed to hash content: %w", err)
+	}
+	return checksumPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,80 @@
+package announce
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetadata_RoundTrip(t *testing.T) {
+	checksum, err := Checksum(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum != "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Unexpected checksum %s", checksum)
+	}
+
+	metadata := NewMetadata("QmDescr1ptor")
+	metadata.Title = "Metropolis"
+	metadata.License = "CC0-1.0"
+	metadata.Checksum = checksum
+	metadata.Preview = "QmPrev1ew"
+	data, err := metadata.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	parsed, err := MetadataFromJSON(data)
+	if err != nil {
+		t.Fatalf("MetadataFromJSON failed: %v", err)
+	}
+	if *parsed != *metadata {
+		t.Errorf("Round trip changed the record: %+v != %+v", parsed, metadata)
+	}
+}
+
+func TestMetadata_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(m *Metadata)
+	}{
+		{"empty", func(m *Metadata) { m.Title = "" }},
+		{"bad descriptor", func(m *Metadata) { m.Descriptor = "not-a-cid" }},
+		{"bad checksum algorithm", func(m *Metadata) { m.Checksum = "md5:d41d8cd98f00b204e9800998ecf8427e" }},
+		{"short checksum", func(m *Metadata) { m.Checksum = "sha256:abcd" }},
+		{"bad preview", func(m *Metadata) { m.Preview = "preview.png" }},
+		{"long title", func(m *Metadata) { m.Title = strings.Repeat("x", maxMetadataTitle+1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := NewMetadata("QmDescr1ptor")
+			metadata.Title = "Metropolis"
+			tt.modify(metadata)
+			if err := metadata.Validate(); err == nil {
+				t.Error("Expected validation to fail")
+			}
+		})
+	}
+}
+
+func TestAnnouncement_Metadata(t *testing.T) {
+	ann, err := NewCreator().CreateAnnouncement("QmDescr1ptor", CreateOptions{Topic: "movies", Metadata: "QmMetadata"})
+	if err != nil {
+		t.Fatalf("CreateAnnouncement failed: %v", err)
+	}
+	if ann.Metadata != "QmMetadata" {
+		t.Errorf("Expected the metadata CID to be announced, got %q", ann.Metadata)
+	}
+	if err := NewValidator(nil).ValidateAnnouncement(ann); err != nil {
+		t.Errorf("Expected the announcement to validate: %v", err)
+	}
+
+	ann.Metadata = "metadata.json"
+	if err := NewValidator(nil).ValidateAnnouncement(ann); err == nil {
+		t.Error("Expected an invalid metadata CID to be rejected")
+	}
+	ann.Metadata = "QmMetadata"
+	ann.Kind = KindCollection
+	if err := ann.Validate(); err == nil {
+		t.Error("Expected metadata on a collection announcement to be rejected")
+	}
+}
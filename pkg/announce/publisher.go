@@ -0,0 +1,81 @@
+package announce
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// announcementSigningPrefix separates announcement signatures from
+// collection signatures made with the same key
+const announcementSigningPrefix = "noisefs announcement v1\n"
+
+// ErrInvalidPublisherSignature is returned for announcements not signed by
+// the publisher they name
+var ErrInvalidPublisherSignature = errors.New("invalid publisher signature")
+
+// Sign signs the announcement as the publisher key, so its publisher can
+// earn a reputation. It must be the last change to the announcement.
+// Signed announcements are linkable to each other, so signing is a choice
+// of the publisher.
+func (a *Announcement) Sign(key ed25519.PrivateKey) error {
+	a.Publisher = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	message, err := a.signedMessage()
+	if err != nil {
+		return err
+	}
+	a.Signature = hex.EncodeToString(ed25519.Sign(key, message))
+	return nil
+}
+
+// VerifyPublisher checks a signed announcement was signed by its
+// publisher. Unsigned announcements pass.
+func (a *Announcement) VerifyPublisher() error {
+	if a.Publisher == "" {
+		return nil
+	}
+	publicKey, err := hex.DecodeString(a.Publisher)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid publisher key %q", a.Publisher)
+	}
+	signature, err := hex.DecodeString(a.Signature)
+	if err != nil {
+		return ErrInvalidPublisherSignature
+	}
+	message, err := a.signedMessage()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, message, signature) {
+		return ErrInvalidPublisherSignature
+	}
+	return nil
+}
+
+// signedMessage is what the signature covers: the announcement without its
+// signature
+func (a *Announcement) signedMessage() ([]byte, error) {
+	unsigned := *a
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize announcement: %w", err)
+	}
+	return append([]byte(announcementSigningPrefix), data...), nil
+}
+
+// PublisherSourceID is the reputation source ID of a publisher key
+func PublisherSourceID(publisher string) string {
+	return "publisher:" + publisher
+}
+
+// SourceID returns the reputation source ID for an announcement: its
+// publisher when signed, fallback otherwise
+func SourceID(ann *Announcement, fallback string) string {
+	if ann.Publisher != "" {
+		return PublisherSourceID(ann.Publisher)
+	}
+	return fallback
+}
@@ -0,0 +1,51 @@
+package announce
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestAnnouncement_Sign(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := NewCreator().CreateAnnouncement("QmDescr1ptor", CreateOptions{Topic: "movies", Tags: []string{"genre:scifi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ann.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	data, err := ann.ToJSON()
+	if err != nil {
+		t.Fatalf("A signed announcement should validate: %v", err)
+	}
+	parsed, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if SourceID(parsed, "fallback") != PublisherSourceID(parsed.Publisher) {
+		t.Errorf("Expected a signed announcement to be attributed to its publisher")
+	}
+
+	tampered := *parsed
+	tampered.TopicHash = HashTopic("software")
+	if err := tampered.Validate(); !errors.Is(err, ErrInvalidPublisherSignature) {
+		t.Errorf("Expected a changed topic to break the signature, got %v", err)
+	}
+	if err := NewValidator(nil).ValidateAnnouncement(&tampered); !errors.Is(err, ErrInvalidPublisherSignature) {
+		t.Errorf("Expected the validator to check the signature, got %v", err)
+	}
+
+	unsigned := *parsed
+	unsigned.Publisher, unsigned.Signature = "", ""
+	if err := unsigned.Validate(); err != nil {
+		t.Errorf("Unsigned announcements should stay valid: %v", err)
+	}
+	if SourceID(&unsigned, "fallback") != "fallback" {
+		t.Errorf("Expected unsigned announcements to use the fallback source")
+	}
+}
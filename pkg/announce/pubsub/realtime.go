@@ -194,6 +194,11 @@ func (s *RealtimeSubscriber) Stop() error {
 	return nil
 }
 
+// Running reports whether the subscriber has not been stopped
+func (s *RealtimeSubscriber) Running() bool {
+	return s.ctx.Err() == nil
+}
+
 // GetSubscriptions returns active subscriptions
 func (s *RealtimeSubscriber) GetSubscriptions() []string {
 	s.subMutex.RLock()
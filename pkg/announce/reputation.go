@@ -167,6 +167,16 @@ func (rs *ReputationSystem) GetScore(sourceID string) float64 {
 	return rep.Score
 }
 
+// PublisherScore returns the reputation of a publisher key as a share of
+// the maximum score, and whether anything is known about the publisher
+func (rs *ReputationSystem) PublisherScore(publisher string) (float64, bool) {
+	rep, exists := rs.GetReputation(PublisherSourceID(publisher))
+	if !exists || rs.maxScore <= rs.minScore {
+		return 0, false
+	}
+	return (rep.Score - rs.minScore) / (rs.maxScore - rs.minScore), true
+}
+
 // IsTrusted checks if a source is trusted
 func (rs *ReputationSystem) IsTrusted(sourceID string) bool {
 	rep, exists := rs.GetReputation(sourceID)
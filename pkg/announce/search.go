@@ -1,6 +1,8 @@
 package announce
 
 import (
+	"fmt"
+	"math"
 	"sort"
 	"strings"
 	"sync"
@@ -21,10 +23,28 @@ type SearchEngine struct {
 	
 	// Configuration
 	maxResults  int
+	reputation  PublisherReputation
 	
 	mu          sync.RWMutex
 }
 
+// PublisherReputation rates the publishers of signed announcements
+type PublisherReputation interface {
+	// PublisherScore returns the reputation of a publisher key from 0 to
+	// 1, and whether anything is known about the publisher
+	PublisherScore(publisher string) (float64, bool)
+}
+
+const (
+	// FreshnessHalfLife is how long it takes the freshness boost of an
+	// announcement to halve
+	FreshnessHalfLife = 48 * time.Hour
+
+	// neutralReputation is the reputation of unsigned announcements and
+	// unknown publishers
+	neutralReputation = 0.5
+)
+
 // AnnouncementStore interface for announcement storage
 type AnnouncementStore interface {
 	GetByID(id string) (*Announcement, error)
@@ -33,6 +53,29 @@ type AnnouncementStore interface {
 	GetRecent(since time.Time, limit int) ([]*Announcement, error)
 }
 
+// StoreQuery selects a sorted page of unexpired announcements
+type StoreQuery struct {
+	TopicHashes []string   // Any of these topics
+	Categories  []string   // Any of these categories
+	SizeClasses []string   // Any of these size classes
+	Since       *time.Time // Announced at or after
+	Until       *time.Time // Announced at or before
+	SortBy      SortField  // SortByTime (default) or SortBySize
+	SortOrder   SortOrder  // SortDesc (default) or SortAsc
+	Offset      int
+	Limit       int // Zero for all
+}
+
+// QueryableStore is an AnnouncementStore that can filter, sort and page
+// announcements itself, so searches that don't rank by tags or keywords
+// don't load the whole store
+type QueryableStore interface {
+	AnnouncementStore
+	// Query returns a page of matching announcements and the total number
+	// that match
+	Query(query StoreQuery) ([]*Announcement, int, error)
+}
+
 // TimeIndex provides time-based indexing
 type TimeIndex struct {
 	buckets map[string][]string // time bucket -> announcement IDs
@@ -101,6 +144,20 @@ type SearchResult struct {
 	Announcement *Announcement
 	Score        float64
 	Highlights   map[string][]string // field -> highlighted snippets
+	Explanation  *ScoreExplanation   // Why the result scored as it did; nil when not ranked
+}
+
+// ScoreExplanation breaks a result's score into the factors it is the
+// product of: (1 + TagMatch) * (1 + KeywordMatch) * (1 + Freshness/2) *
+// (0.5 + Reputation)
+type ScoreExplanation struct {
+	TagMatch     float64  `json:"tag_match"`     // Share of the queried tags matched
+	MatchedTags  []string `json:"matched_tags,omitempty"`
+	KeywordMatch float64  `json:"keyword_match"` // Share of the keywords matched
+	Freshness    float64  `json:"freshness"`     // 1 when new, halving every FreshnessHalfLife
+	Reputation   float64  `json:"reputation"`    // Publisher reputation from 0 to 1
+	Publisher    string   `json:"publisher,omitempty"`
+	Reasons      []string `json:"reasons"` // The factors in words
 }
 
 // NewSearchEngine creates a new search engine
@@ -126,15 +183,35 @@ func NewSearchEngine(store AnnouncementStore, hierarchy *TopicHierarchy) *Search
 	}
 }
 
+// SetReputation makes searches rank results by the reputation of their
+// publishers. Without it every publisher is treated as unknown.
+func (se *SearchEngine) SetReputation(reputation PublisherReputation) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.reputation = reputation
+}
+
 // Search performs a search based on the query
 func (se *SearchEngine) Search(query SearchQuery) ([]*SearchResult, error) {
+	results, _, err := se.SearchPage(query)
+	return results, err
+}
+
+// SearchPage performs a search and also returns the total number of
+// results, ignoring Offset and Limit, for paging through them
+func (se *SearchEngine) SearchPage(query SearchQuery) ([]*SearchResult, int, error) {
 	se.mu.RLock()
 	defer se.mu.RUnlock()
 	
+	// Queries without ranking are answered by the store when it can
+	if queryable, ok := se.store.(QueryableStore); ok && !needsScoring(query) {
+		return se.searchStore(queryable, query)
+	}
+	
 	// Get candidate announcements
 	candidates, err := se.getCandidates(query)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	
 	// Score and filter
@@ -147,7 +224,7 @@ func (se *SearchEngine) Search(query SearchQuery) ([]*SearchResult, error) {
 		}
 		
 		// Calculate score
-		score := se.calculateScore(ann, query)
+		score, explanation := se.calculateScore(ann, query)
 		if score <= 0 {
 			continue
 		}
@@ -159,6 +236,7 @@ func (se *SearchEngine) Search(query SearchQuery) ([]*SearchResult, error) {
 			Announcement: ann,
 			Score:        score,
 			Highlights:   highlights,
+			Explanation:  explanation,
 		}
 		
 		results = append(results, result)
@@ -175,10 +253,82 @@ func (se *SearchEngine) Search(query SearchQuery) ([]*SearchResult, error) {
 	}
 	
 	if start >= len(results) {
-		return []*SearchResult{}, nil
+		return []*SearchResult{}, len(results), nil
+	}
+	
+	return results[start:end], len(results), nil
+}
+
+// Match runs a query against a single announcement, such as one just
+// received, returning the scored result if it matches. Tags and keywords
+// must match at least partly, rather than only rank.
+func (se *SearchEngine) Match(ann *Announcement, query SearchQuery) (*SearchResult, bool) {
+	se.mu.RLock()
+	defer se.mu.RUnlock()
+	
+	if len(query.Topics) > 0 && !contains(query.Topics, ann.TopicHash) {
+		return nil, false
+	}
+	if !se.matchesFilters(ann, query) {
+		return nil, false
+	}
+	if len(query.IncludeTags) > 0 && (ann.TagBloom == "" || se.calculateTagScore(ann, query.IncludeTags, query.TagMode) == 0) {
+		return nil, false
+	}
+	if len(query.Keywords) > 0 && se.calculateKeywordScore(ann, query.Keywords) == 0 {
+		return nil, false
+	}
+	
+	score, explanation := se.calculateScore(ann, query)
+	if score <= 0 {
+		return nil, false
+	}
+	return &SearchResult{
+		Announcement: ann,
+		Score:        score,
+		Highlights:   se.generateHighlights(ann, query),
+		Explanation:  explanation,
+	}, true
+}
+
+// needsScoring reports whether a query ranks or filters by tags or
+// keywords, which only the search engine can do
+func needsScoring(query SearchQuery) bool {
+	return len(query.Keywords) > 0 || len(query.IncludeTags) > 0 || len(query.ExcludeTags) > 0
+}
+
+// searchStore answers a query with the store's own filtering and paging
+func (se *SearchEngine) searchStore(store QueryableStore, query SearchQuery) ([]*SearchResult, int, error) {
+	// Relevance without tags or keywords only favours recent announcements
+	sortBy, sortOrder := query.SortBy, query.SortOrder
+	if sortBy != SortBySize && sortBy != SortByTime {
+		sortBy, sortOrder = SortByTime, SortDesc
+	}
+	
+	announcements, total, err := store.Query(StoreQuery{
+		TopicHashes: query.Topics,
+		Categories:  query.Categories,
+		SizeClasses: query.SizeClasses,
+		Since:       query.Since,
+		Until:       query.Until,
+		SortBy:      sortBy,
+		SortOrder:   sortOrder,
+		Offset:      query.Offset,
+		Limit:       query.Limit,
+	})
+	if err != nil {
+		return nil, 0, err
 	}
 	
-	return results[start:end], nil
+	results := make([]*SearchResult, 0, len(announcements))
+	for _, ann := range announcements {
+		results = append(results, &SearchResult{
+			Announcement: ann,
+			Score:        1.0,
+			Highlights:   map[string][]string{},
+		})
+	}
+	return results, total, nil
 }
 
 // SearchSimilar finds similar announcements
@@ -412,33 +562,74 @@ func (se *SearchEngine) matchesFilters(ann *Announcement, query SearchQuery) boo
 	return true
 }
 
-func (se *SearchEngine) calculateScore(ann *Announcement, query SearchQuery) float64 {
+// calculateScore ranks an announcement by how well its tags and keywords
+// match, how fresh it is and the reputation of its publisher
+func (se *SearchEngine) calculateScore(ann *Announcement, query SearchQuery) (float64, *ScoreExplanation) {
+	explanation := &ScoreExplanation{Reasons: []string{}}
 	score := 1.0
 	
 	// Tag matching
 	if len(query.IncludeTags) > 0 && ann.TagBloom != "" {
 		tagScore := se.calculateTagScore(ann, query.IncludeTags, query.TagMode)
 		if query.TagMode == TagMatchAll && tagScore < 1.0 {
-			return 0 // Must match all tags
+			return 0, nil // Must match all tags
 		}
 		score *= (1.0 + tagScore)
+		explanation.TagMatch = tagScore
+		explanation.MatchedTags = se.generateHighlights(ann, query)["tags"]
+		explanation.Reasons = append(explanation.Reasons, fmt.Sprintf("matches %d of %d tags", len(explanation.MatchedTags), len(query.IncludeTags)))
 	}
 	
 	// Keyword matching (simplified)
 	if len(query.Keywords) > 0 {
 		keywordScore := se.calculateKeywordScore(ann, query.Keywords)
 		score *= (1.0 + keywordScore)
+		explanation.KeywordMatch = keywordScore
+		explanation.Reasons = append(explanation.Reasons, fmt.Sprintf("matches %.0f%% of keywords", keywordScore*100))
 	}
 	
-	// Recency boost
+	// Freshness boost, up to 1.5 for new announcements
 	age := time.Since(time.Unix(ann.Timestamp, 0))
-	if age < 24*time.Hour {
-		score *= 1.5
-	} else if age < 7*24*time.Hour {
-		score *= 1.2
+	if age < 0 {
+		age = 0
+	}
+	explanation.Freshness = math.Pow(0.5, float64(age)/float64(FreshnessHalfLife))
+	score *= 1.0 + explanation.Freshness/2
+	explanation.Reasons = append(explanation.Reasons, "announced "+formatAge(age))
+	
+	// Publisher reputation, from 0.5 to 1.5
+	explanation.Reputation = neutralReputation
+	explanation.Publisher = ann.Publisher
+	switch {
+	case ann.Publisher == "":
+		explanation.Reasons = append(explanation.Reasons, "unsigned, so the publisher is unknown")
+	case se.reputation == nil:
+		explanation.Reasons = append(explanation.Reasons, "signed by a publisher without a reputation")
+	default:
+		if reputation, known := se.reputation.PublisherScore(ann.Publisher); known {
+			explanation.Reputation = reputation
+			explanation.Reasons = append(explanation.Reasons, fmt.Sprintf("publisher reputation %.0f%%", reputation*100))
+		} else {
+			explanation.Reasons = append(explanation.Reasons, "signed by a publisher without a reputation")
+		}
 	}
+	score *= 0.5 + explanation.Reputation
 	
-	return score
+	return score, explanation
+}
+
+// formatAge describes how long ago something happened
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return "within the hour"
+	case age < 2*time.Hour:
+		return "an hour ago"
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%d hours ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%d days ago", int(age.Hours()/24))
+	}
 }
 
 func (se *SearchEngine) calculateTagScore(ann *Announcement, tags []string, mode TagMatchMode) float64 {
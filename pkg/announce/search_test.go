@@ -0,0 +1,156 @@
+package announce
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memoryStore is an AnnouncementStore that can't query, so every search is
+// scored
+type memoryStore []*Announcement
+
+func (s memoryStore) GetByID(id string) (*Announcement, error) {
+	for _, ann := range s {
+		if ann.Descriptor == id {
+			return ann, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (s memoryStore) GetAll() ([]*Announcement, error) { return s, nil }
+
+func (s memoryStore) GetByTopic(topicHash string) ([]*Announcement, error) {
+	var matches []*Announcement
+	for _, ann := range s {
+		if ann.TopicHash == topicHash {
+			matches = append(matches, ann)
+		}
+	}
+	return matches, nil
+}
+
+func (s memoryStore) GetRecent(since time.Time, limit int) ([]*Announcement, error) {
+	return s, nil
+}
+
+// fixedReputation scores publishers from a map
+type fixedReputation map[string]float64
+
+func (r fixedReputation) PublisherScore(publisher string) (float64, bool) {
+	score, ok := r[publisher]
+	return score, ok
+}
+
+func testSearchAnnouncement(t *testing.T, descriptor string, age time.Duration, tags []string, key ed25519.PrivateKey) *Announcement {
+	t.Helper()
+	ann, err := NewCreator().CreateAnnouncement(descriptor, CreateOptions{Topic: "movies", Tags: tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann.Timestamp = time.Now().Add(-age).Unix()
+	if key != nil {
+		if err := ann.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ann
+}
+
+func TestSearchEngine_Ranking(t *testing.T) {
+	_, trusted, _ := ed25519.GenerateKey(rand.Reader)
+	_, spammer, _ := ed25519.GenerateKey(rand.Reader)
+	tags := []string{"genre:scifi", "res:4k"}
+
+	store := memoryStore{
+		testSearchAnnouncement(t, "QmSpammer", time.Hour, tags, spammer),
+		testSearchAnnouncement(t, "QmUnsigned", time.Hour, tags, nil),
+		testSearchAnnouncement(t, "QmTrusted", time.Hour, tags, trusted),
+		testSearchAnnouncement(t, "QmStae", 10*24*time.Hour, tags, nil),
+		testSearchAnnouncement(t, "QmPartia", time.Hour, tags[:1], nil),
+	}
+	engine := NewSearchEngine(store, NewTopicHierarchy())
+	engine.SetReputation(fixedReputation{
+		store[0].Publisher: 0.1,
+		store[2].Publisher: 0.9,
+	})
+
+	results, err := engine.Search(SearchQuery{IncludeTags: tags, SortBy: SortByRelevance, SortOrder: SortDesc})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var order []string
+	for _, result := range results {
+		order = append(order, result.Announcement.Descriptor)
+		if result.Explanation == nil || len(result.Explanation.Reasons) == 0 {
+			t.Errorf("Expected %s to explain its score", result.Announcement.Descriptor)
+		}
+	}
+	want := []string{"QmTrusted", "QmUnsigned", "QmPartia", "QmStae", "QmSpammer"}
+	if len(order) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, order)
+		}
+	}
+
+	top := results[0].Explanation
+	if top.Reputation != 0.9 || top.TagMatch != 1 || len(top.MatchedTags) != 2 {
+		t.Errorf("Unexpected explanation: %+v", top)
+	}
+	if top.Freshness <= 0.9 || top.Freshness > 1 {
+		t.Errorf("Expected an hour old announcement to be fresh, got %f", top.Freshness)
+	}
+	if unsigned := results[1].Explanation; unsigned.Reputation != neutralReputation || unsigned.Publisher != "" {
+		t.Errorf("Expected unsigned announcements to have a neutral reputation: %+v", unsigned)
+	}
+}
+
+func TestSearchEngine_Match(t *testing.T) {
+	engine := NewSearchEngine(nil, NewTopicHierarchy())
+	ann := testSearchAnnouncement(t, "QmMatch", time.Hour, []string{"genre:scifi", "res:4k"}, nil)
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  bool
+	}{
+		{"any tag", SearchQuery{IncludeTags: []string{"genre:scifi", "genre:drama"}, TagMode: TagMatchAny}, true},
+		{"all tags", SearchQuery{IncludeTags: []string{"genre:scifi", "genre:drama"}, TagMode: TagMatchAll}, false},
+		{"topic", SearchQuery{Topics: []string{HashTopic("movies")}}, true},
+		{"other topic", SearchQuery{Topics: []string{HashTopic("music")}}, false},
+		{"category", SearchQuery{Categories: []string{ann.Category}}, true},
+		{"other category", SearchQuery{Categories: []string{"no-such-category"}}, false},
+		{"unmatched tag", SearchQuery{IncludeTags: []string{"genre:drama"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := engine.Match(ann, tt.query)
+			if ok != tt.want {
+				t.Fatalf("Expected match %v, got %v", tt.want, ok)
+			}
+			if ok && (result.Announcement != ann || result.Explanation == nil) {
+				t.Errorf("Unexpected result %+v", result)
+			}
+		})
+	}
+}
+
+func TestReputationSystem_PublisherScore(t *testing.T) {
+	system := NewReputationSystem(nil)
+	defer system.Close()
+
+	if _, known := system.PublisherScore("abcd"); known {
+		t.Error("Expected an unseen publisher to be unknown")
+	}
+	system.RecordPositive(PublisherSourceID("abcd"), "valid_announcement")
+	score, known := system.PublisherScore("abcd")
+	if !known || score <= 0.5 || score > 1 {
+		t.Errorf("Expected a share above neutral after a good announcement, got %f (known %v)", score, known)
+	}
+}
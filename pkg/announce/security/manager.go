@@ -134,6 +134,12 @@ func (m *Manager) CheckAnnouncement(ann *announce.Announcement, sourceID string)
 	return nil
 }
 
+// Reputation returns the reputation system sources are scored by, for
+// ranking search results by publisher
+func (m *Manager) Reputation() *announce.ReputationSystem {
+	return m.reputation
+}
+
 // GetSourceInfo returns security information about a source
 func (m *Manager) GetSourceInfo(sourceID string) SourceInfo {
 	info := SourceInfo{
@@ -41,6 +41,19 @@ func TestValidator(t *testing.T) {
 			wantErr: true,
 			errMsg:  "missing version",
 		},
+		{
+			name: "newer version",
+			ann: &Announcement{
+				Version:    "2.0",
+				Descriptor: "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+				TopicHash:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				Timestamp:  time.Now().Unix(),
+				TTL:        3600,
+				Nonce:      "abc123def456",
+			},
+			wantErr: true,
+			errMsg:  "upgrade NoiseFS",
+		},
 		{
 			name: "invalid descriptor",
 			ann: &Announcement{
@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// JSONWriter writes each record as one line of JSON
+type JSONWriter struct {
+	encoder *json.Encoder
+	file    *os.File // Closed and synced when the writer owns it
+}
+
+// NewJSONWriter writes records to w, which the caller closes
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{encoder: json.NewEncoder(w)}
+}
+
+// OpenJSONL appends records to the file at path, creating it if needed.
+// Records are written whole with a single write, so a reader following the
+// file never sees half a line that isn't finished later.
+func OpenJSONL(path string) (*JSONWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	writer := NewJSONWriter(file)
+	writer.file = file
+	return writer, nil
+}
+
+// Write writes a record
+func (w *JSONWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+// Close syncs and closes the file the writer opened
+func (w *JSONWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,336 @@
+// Package sinks writes the announcements subscriptions receive to JSON
+// Lines files, SQLite databases or standard output, so headless servers can
+// consume discovery data without the web UI.
+package sinks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/config"
+)
+
+// Type is where a sink writes announcements
+type Type string
+
+const (
+	// TypeJSONL appends one JSON record per line to a file
+	TypeJSONL Type = "jsonl"
+	// TypeSQLite inserts a row per announcement into a SQLite database
+	TypeSQLite Type = "sqlite"
+	// TypeStdout writes JSON records to standard output, for pipes
+	TypeStdout Type = "stdout"
+)
+
+// sizeClassOrder ranks announcement size classes for the size filters
+var sizeClassOrder = map[string]int{
+	announce.SizeClassTiny:   1,
+	announce.SizeClassSmall:  2,
+	announce.SizeClassMedium: 3,
+	announce.SizeClassLarge:  4,
+	announce.SizeClassHuge:   5,
+}
+
+// Sink is a destination for announcements and the filters that select
+// them. Every filter that is set must match; an empty filter matches
+// everything.
+type Sink struct {
+	Name         string   `json:"name"`
+	Type         Type     `json:"type"`
+	Path         string   `json:"path,omitempty"`           // File for jsonl and sqlite sinks
+	Topics       []string `json:"topics,omitempty"`         // Any of these subscribed topics
+	Tags         []string `json:"tags,omitempty"`           // All must be in the announcement's tags
+	Categories   []string `json:"categories,omitempty"`     // Any of these categories
+	MinSizeClass string   `json:"min_size_class,omitempty"` // Smallest size class, e.g. "small"
+	MaxSizeClass string   `json:"max_size_class,omitempty"` // Largest size class, e.g. "medium"
+	Enabled      bool     `json:"enabled"`
+}
+
+// Validate checks a sink's fields
+func (s *Sink) Validate() error {
+	if s.Name == "" {
+		return errors.New("sink name is required")
+	}
+	switch s.Type {
+	case TypeJSONL, TypeSQLite:
+		if s.Path == "" {
+			return fmt.Errorf("sink %s: %s sinks need a path", s.Name, s.Type)
+		}
+	case TypeStdout:
+	default:
+		return fmt.Errorf("sink %s: unknown type %q (use %s, %s or %s)", s.Name, s.Type, TypeJSONL, TypeSQLite, TypeStdout)
+	}
+	for _, class := range []string{s.MinSizeClass, s.MaxSizeClass} {
+		if class != "" && sizeClassOrder[class] == 0 {
+			return fmt.Errorf("sink %s: unknown size class %q (use tiny, small, medium, large or huge)", s.Name, class)
+		}
+	}
+	if s.MinSizeClass != "" && s.MaxSizeClass != "" && sizeClassOrder[s.MinSizeClass] > sizeClassOrder[s.MaxSizeClass] {
+		return fmt.Errorf("sink %s: min size class %s is larger than max size class %s", s.Name, s.MinSizeClass, s.MaxSizeClass)
+	}
+	return nil
+}
+
+// Matches reports whether an announcement passes all of the sink's filters
+func (s *Sink) Matches(ann *announce.Announcement) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Topics) > 0 {
+		found := false
+		for _, topic := range s.Topics {
+			if announce.HashTopic(topic) == ann.TopicHash {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(s.Categories) > 0 {
+		found := false
+		for _, category := range s.Categories {
+			if category == ann.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	size := sizeClassOrder[ann.SizeClass]
+	if s.MinSizeClass != "" && size < sizeClassOrder[s.MinSizeClass] {
+		return false
+	}
+	if s.MaxSizeClass != "" && (size == 0 || size > sizeClassOrder[s.MaxSizeClass]) {
+		return false
+	}
+
+	if len(s.Tags) > 0 && !announce.HasAllTags(ann.TagBloom, s.Tags) {
+		return false
+	}
+	return true
+}
+
+// Config is the saved set of sinks
+type Config struct {
+	Version string `json:"version"`
+	Sinks   []Sink `json:"sinks"`
+}
+
+// NewConfig creates an empty configuration
+func NewConfig() *Config {
+	return &Config{
+		Version: "1.0",
+		Sinks:   []Sink{},
+	}
+}
+
+// Validate checks every sink, that sink names are unique and that at most
+// one sink writes to standard output
+func (c *Config) Validate() error {
+	names := make(map[string]bool, len(c.Sinks))
+	stdout := 0
+	for i := range c.Sinks {
+		if err := c.Sinks[i].Validate(); err != nil {
+			return err
+		}
+		if names[c.Sinks[i].Name] {
+			return fmt.Errorf("duplicate sink name %s", c.Sinks[i].Name)
+		}
+		names[c.Sinks[i].Name] = true
+		if c.Sinks[i].Type == TypeStdout {
+			stdout++
+		}
+	}
+	if stdout > 1 {
+		return errors.New("only one sink can write to standard output")
+	}
+	return nil
+}
+
+// SetSink adds a sink, replacing any sink with the same name
+func (c *Config) SetSink(sink Sink) error {
+	if err := sink.Validate(); err != nil {
+		return err
+	}
+	for i := range c.Sinks {
+		if c.Sinks[i].Name == sink.Name {
+			c.Sinks[i] = sink
+			return nil
+		}
+	}
+	c.Sinks = append(c.Sinks, sink)
+	return nil
+}
+
+// RemoveSink removes a sink by name
+func (c *Config) RemoveSink(name string) error {
+	for i := range c.Sinks {
+		if c.Sinks[i].Name == name {
+			c.Sinks = append(c.Sinks[:i], c.Sinks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no sink named %s", name)
+}
+
+// DefaultConfigPath returns the location of the saved sinks
+func DefaultConfigPath() string {
+	return filepath.Join(config.GetConfigDir(), "sinks.json")
+}
+
+// LoadConfig loads sinks from a file, returning an empty configuration if
+// it doesn't exist
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := NewConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid sinks in %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig saves sinks to a file
+func SaveConfig(path string, cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Record is an announcement as sinks write it
+type Record struct {
+	ReceivedAt   time.Time              `json:"received_at"`
+	Topic        string                 `json:"topic,omitempty"` // Subscribed topic, when known
+	Announcement *announce.Announcement `json:"announcement"`
+}
+
+// Writer writes records to one sink
+type Writer interface {
+	Write(record Record) error
+	Close() error
+}
+
+// Open opens the writer of a sink. Standard output sinks write to stdout.
+func Open(sink Sink, stdout io.Writer) (Writer, error) {
+	switch sink.Type {
+	case TypeJSONL:
+		return OpenJSONL(sink.Path)
+	case TypeSQLite:
+		return OpenSQLite(sink.Path)
+	case TypeStdout:
+		return NewJSONWriter(stdout), nil
+	}
+	return nil, fmt.Errorf("sink %s: unknown type %q", sink.Name, sink.Type)
+}
+
+// maxSeen bounds the announcements a Set remembers to drop duplicates
+const maxSeen = 10000
+
+// Set writes announcements to every enabled sink whose filters they pass.
+// The same announcement often arrives over both the DHT and PubSub; a Set
+// writes it once.
+type Set struct {
+	mu      sync.Mutex
+	sinks   []Sink
+	writers []Writer
+	seen    map[string]bool
+}
+
+// OpenSet opens the enabled sinks of a configuration
+func OpenSet(cfg *Config, stdout io.Writer) (*Set, error) {
+	set := &Set{seen: make(map[string]bool)}
+	for _, sink := range cfg.Sinks {
+		if !sink.Enabled {
+			continue
+		}
+		writer, err := Open(sink, stdout)
+		if err != nil {
+			set.Close()
+			return nil, fmt.Errorf("failed to open sink %s: %w", sink.Name, err)
+		}
+		set.sinks = append(set.sinks, sink)
+		set.writers = append(set.writers, writer)
+	}
+	return set, nil
+}
+
+// Len returns the number of open sinks
+func (s *Set) Len() int {
+	return len(s.sinks)
+}
+
+// Handle writes an announcement received for topic to the sinks it
+// matches, and returns the names of the sinks written. A failing sink
+// doesn't keep the others from being written.
+func (s *Set) Handle(ann *announce.Announcement, topic string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%s|%s|%d|%s", ann.TopicHash, ann.Descriptor, ann.Timestamp, ann.Nonce)
+	if s.seen[key] {
+		return nil, nil
+	}
+	if len(s.seen) >= maxSeen {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[key] = true
+
+	record := Record{ReceivedAt: time.Now().UTC(), Topic: topic, Announcement: ann}
+	var written []string
+	var errs []error
+	for i, sink := range s.sinks {
+		if !sink.Matches(ann) {
+			continue
+		}
+		if err := s.writers[i].Write(record); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+			continue
+		}
+		written = append(written, sink.Name)
+	}
+	return written, errors.Join(errs...)
+}
+
+// Close closes every sink
+func (s *Set) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for i, writer := range s.writers {
+		if err := writer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.sinks[i].Name, err))
+		}
+	}
+	s.writers, s.sinks = nil, nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,223 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+func testAnnouncement(descriptor, topic, category, sizeClass string, tags ...string) *announce.Announcement {
+	return &announce.Announcement{
+		Version:    "1.0",
+		Descriptor: descriptor,
+		TopicHash:  announce.HashTopic(topic),
+		TagBloom:   announce.CreateTagBloom(tags).Encode(),
+		Category:   category,
+		SizeClass:  sizeClass,
+		Timestamp:  time.Now().Unix(),
+		TTL:        3600,
+		Nonce:      descriptor,
+	}
+}
+
+func TestSinkMatches(t *testing.T) {
+	pdf := testAnnouncement("QmPaper", "documents/research", announce.CategoryDocument, announce.SizeClassSmall, "format:pdf", "subject:physics")
+	movie := testAnnouncement("QmMovie", "movies/scifi", announce.CategoryVideo, announce.SizeClassHuge, "format:mkv")
+
+	tests := []struct {
+		name string
+		sink Sink
+		want map[string]bool
+	}{
+		{"no filters", Sink{}, map[string]bool{"QmPaper": true, "QmMovie": true}},
+		{"topic", Sink{Topics: []string{"movies/scifi"}}, map[string]bool{"QmMovie": true}},
+		{"all tags", Sink{Tags: []string{"format:pdf", "subject:physics"}}, map[string]bool{"QmPaper": true}},
+		{"missing tag", Sink{Tags: []string{"format:pdf", "subject:biology"}}, map[string]bool{}},
+		{"category", Sink{Categories: []string{announce.CategoryVideo, announce.CategoryAudio}}, map[string]bool{"QmMovie": true}},
+		{"max size", Sink{MaxSizeClass: announce.SizeClassMedium}, map[string]bool{"QmPaper": true}},
+		{"min size", Sink{MinSizeClass: announce.SizeClassLarge}, map[string]bool{"QmMovie": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sink.Enabled = true
+			for _, ann := range []*announce.Announcement{pdf, movie} {
+				if got := tt.sink.Matches(ann); got != tt.want[ann.Descriptor] {
+					t.Errorf("Matches(%s) = %v, want %v", ann.Descriptor, got, !got)
+				}
+			}
+		})
+	}
+
+	if (&Sink{}).Matches(pdf) {
+		t.Error("Expected a disabled sink to match nothing")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cfg := NewConfig()
+	if err := cfg.SetSink(Sink{Name: "papers", Type: TypeJSONL}); err == nil {
+		t.Error("Expected a jsonl sink without a path to be rejected")
+	}
+	if err := cfg.SetSink(Sink{Name: "any", Type: "csv"}); err == nil {
+		t.Error("Expected an unknown type to be rejected")
+	}
+	if err := cfg.SetSink(Sink{Name: "sizes", Type: TypeStdout, MinSizeClass: "huge", MaxSizeClass: "tiny"}); err == nil {
+		t.Error("Expected a min size class above the max to be rejected")
+	}
+
+	if err := cfg.SetSink(Sink{Name: "papers", Type: TypeJSONL, Path: "papers.jsonl", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSink(Sink{Name: "pipe", Type: TypeStdout, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SetSink(Sink{Name: "papers", Type: TypeSQLite, Path: "papers.db", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Sinks) != 2 || cfg.Sinks[0].Type != TypeSQLite {
+		t.Errorf("Expected SetSink to replace the sink with the same name, got %+v", cfg.Sinks)
+	}
+
+	cfg.Sinks = append(cfg.Sinks, Sink{Name: "pipe2", Type: TypeStdout})
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected two standard output sinks to be rejected")
+	}
+	if err := cfg.RemoveSink("pipe2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.RemoveSink("pipe2"); err == nil {
+		t.Error("Expected removing an unknown sink to fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "sinks.json")
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Sinks) != 2 || loaded.Sinks[1].Name != "pipe" {
+		t.Errorf("Loaded %+v", loaded.Sinks)
+	}
+	if empty, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(empty.Sinks) != 0 {
+		t.Errorf("LoadConfig(missing) = %+v, %v", empty, err)
+	}
+}
+
+func TestSetWritesMatchingSinks(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewConfig()
+	for _, sink := range []Sink{
+		{Name: "papers", Type: TypeJSONL, Path: filepath.Join(dir, "papers.jsonl"), Tags: []string{"format:pdf"}, Enabled: true},
+		{Name: "pipe", Type: TypeStdout, Topics: []string{"movies/scifi"}, Enabled: true},
+		{Name: "off", Type: TypeJSONL, Path: filepath.Join(dir, "off.jsonl")},
+	} {
+		if err := cfg.SetSink(sink); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	set, err := OpenSet(cfg, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 2 {
+		t.Errorf("Expected the disabled sink not to be opened, got %d sinks", set.Len())
+	}
+
+	pdf := testAnnouncement("QmPaper", "documents/research", announce.CategoryDocument, announce.SizeClassSmall, "format:pdf")
+	movie := testAnnouncement("QmMovie", "movies/scifi", announce.CategoryVideo, announce.SizeClassHuge, "format:mkv")
+	if written, err := set.Handle(pdf, "documents/research"); err != nil || len(written) != 1 || written[0] != "papers" {
+		t.Errorf("Handle(pdf) = %v, %v", written, err)
+	}
+	if written, err := set.Handle(movie, "movies/scifi"); err != nil || len(written) != 1 || written[0] != "pipe" {
+		t.Errorf("Handle(movie) = %v, %v", written, err)
+	}
+	// The same announcement again, as if it came over PubSub as well
+	if written, err := set.Handle(pdf, "documents/research"); err != nil || len(written) != 0 {
+		t.Errorf("Expected a duplicate to be dropped, got %v, %v", written, err)
+	}
+	if err := set.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "papers.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 || records[0].Topic != "documents/research" || records[0].Announcement.Descriptor != "QmPaper" {
+		t.Errorf("papers.jsonl has %+v", records)
+	}
+
+	var record Record
+	if err := json.Unmarshal(stdout.Bytes(), &record); err != nil || record.Announcement.Descriptor != "QmMovie" {
+		t.Errorf("stdout has %q, %v", stdout.String(), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "off.jsonl")); !os.IsNotExist(err) {
+		t.Error("Expected the disabled sink's file not to be created")
+	}
+}
+
+func TestSQLiteSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "announcements.db")
+	writer, err := OpenSQLite(path)
+	if err != nil {
+		t.Skipf("SQLite unavailable: %v", err)
+	}
+
+	ann := testAnnouncement("QmPaper", "documents/research", announce.CategoryDocument, announce.SizeClassSmall, "format:pdf")
+	record := Record{ReceivedAt: time.Now().UTC(), Topic: "documents/research", Announcement: ann}
+	for i := 0; i < 2; i++ {
+		if err := writer.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening keeps the rows and still skips duplicates
+	writer, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var count int
+	var descriptor, topic string
+	if err := db.QueryRow("SELECT COUNT(*), MAX(descriptor), MAX(topic) FROM announcements").Scan(&count, &descriptor, &topic); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || descriptor != "QmPaper" || topic != "documents/research" {
+		t.Errorf("announcements has %d rows, descriptor %s, topic %s", count, descriptor, topic)
+	}
+}
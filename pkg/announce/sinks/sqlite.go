@@ -0,0 +1,87 @@
+package sinks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // Registers the sqlite3 driver
+)
+
+// sqliteSchema is the table announcements are kept in. An announcement
+// received again, such as after a restart, keeps its first row.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS announcements (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	received_at  TEXT    NOT NULL,
+	topic        TEXT,
+	topic_hash   TEXT    NOT NULL,
+	descriptor   TEXT    NOT NULL,
+	category     TEXT,
+	size_class   TEXT,
+	timestamp    INTEGER NOT NULL,
+	ttl          INTEGER NOT NULL,
+	nonce        TEXT    NOT NULL DEFAULT '',
+	metadata     TEXT,
+	publisher    TEXT,
+	announcement TEXT    NOT NULL,
+	UNIQUE (topic_hash, descriptor, timestamp, nonce)
+);
+CREATE INDEX IF NOT EXISTS announcements_topic ON announcements (topic_hash, timestamp);
+CREATE INDEX IF NOT EXISTS announcements_descriptor ON announcements (descriptor);
+`
+
+// SQLiteWriter inserts records into a SQLite database
+type SQLiteWriter struct {
+	db     *sql.DB
+	insert *sql.Stmt
+}
+
+// OpenSQLite opens or creates the database at path. Builds without cgo
+// can't open SQLite databases.
+func OpenSQLite(path string) (*SQLiteWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// One connection keeps writes ordered and the database unlocked for
+	// readers between them
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create announcements table: %w", err)
+	}
+	insert, err := db.Prepare(`INSERT OR IGNORE INTO announcements
+		(received_at, topic, topic_hash, descriptor, category, size_class, timestamp, ttl, nonce, metadata, publisher, announcement)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteWriter{db: db, insert: insert}, nil
+}
+
+// Write inserts a record
+func (w *SQLiteWriter) Write(record Record) error {
+	ann := record.Announcement
+	data, err := json.Marshal(ann)
+	if err != nil {
+		return err
+	}
+	_, err = w.insert.Exec(
+		record.ReceivedAt.Format(time.RFC3339Nano), record.Topic, ann.TopicHash, ann.Descriptor,
+		ann.Category, ann.SizeClass, ann.Timestamp, ann.TTL, ann.Nonce, ann.Metadata, ann.Publisher, string(data))
+	return err
+}
+
+// Close closes the database
+func (w *SQLiteWriter) Close() error {
+	w.insert.Close()
+	return w.db.Close()
+}
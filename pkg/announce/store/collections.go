@@ -0,0 +1,164 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// ErrCollectionNotFound is returned for unknown collection IDs
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// StoredCollection is the latest revision of a collection known locally
+type StoredCollection struct {
+	*announce.Collection
+	ID         string    `json:"id"`
+	CID        string    `json:"cid,omitempty"` // Block the revision was published in
+	ReceivedAt time.Time `json:"received_at"`
+	Source     string    `json:"source"` // "published" or "subscription"
+}
+
+// CollectionStore is the file of collections published or received on this
+// device, keeping the latest revision of each. Every call re-reads the
+// file, so collections received by a running web UI are seen by the CLI.
+type CollectionStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultCollectionStorePath returns ~/.noisefs/collections.json
+func DefaultCollectionStorePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "collections.json"), nil
+}
+
+// NewCollectionStore returns the store kept at path
+func NewCollectionStore(path string) *CollectionStore {
+	return &CollectionStore{path: path}
+}
+
+// Put records a revision of a collection after checking its signature. It
+// returns false, and keeps what it has, when the revision is no newer than
+// the one stored.
+func (s *CollectionStore) Put(collection *announce.Collection, cid, source string) (bool, error) {
+	if err := collection.Verify(); err != nil {
+		return false, err
+	}
+	updated := false
+	err := s.update(func(collections map[string]*StoredCollection) error {
+		id := collection.ID()
+		if existing, ok := collections[id]; ok && existing.Revision >= collection.Revision {
+			return nil
+		}
+		collectionCopy := *collection
+		collections[id] = &StoredCollection{
+			Collection: &collectionCopy,
+			ID:         id,
+			CID:        cid,
+			ReceivedAt: time.Now(),
+			Source:     source,
+		}
+		updated = true
+		return nil
+	})
+	return updated, err
+}
+
+// Get returns a collection
+func (s *CollectionStore) Get(id string) (*StoredCollection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collections, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	stored, ok := collections[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, id)
+	}
+	return stored, nil
+}
+
+// List returns every collection, most recently revised first
+func (s *CollectionStore) List() ([]*StoredCollection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collections, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*StoredCollection, 0, len(collections))
+	for _, stored := range collections {
+		list = append(list, stored)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Timestamp > list[j].Timestamp })
+	return list, nil
+}
+
+// Remove forgets a collection, until a revision of it is received again
+func (s *CollectionStore) Remove(id string) error {
+	return s.update(func(collections map[string]*StoredCollection) error {
+		if _, ok := collections[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrCollectionNotFound, id)
+		}
+		delete(collections, id)
+		return nil
+	})
+}
+
+// update loads the collections, applies fn and saves them if it succeeds
+func (s *CollectionStore) update(fn func(collections map[string]*StoredCollection) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collections, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(collections); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(collections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize collections: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write collections: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write collections: %w", err)
+	}
+	return nil
+}
+
+// load reads the collections by ID. The caller must hold s.mu.
+func (s *CollectionStore) load() (map[string]*StoredCollection, error) {
+	collections := make(map[string]*StoredCollection)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return collections, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collections: %w", err)
+	}
+	if err := json.Unmarshal(data, &collections); err != nil {
+		return nil, fmt.Errorf("failed to parse collections: %w", err)
+	}
+	return collections, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+func signedCollection(t *testing.T, key ed25519.PrivateKey, revision int64, entries ...string) *announce.Collection {
+	t.Helper()
+	collection := &announce.Collection{
+		Version:   announce.CollectionVersion,
+		Name:      "reading-list",
+		Title:     "Reading List",
+		TopicHash: announce.HashTopic("books"),
+		Revision:  revision,
+		Timestamp: time.Now().Unix(),
+	}
+	for _, descriptor := range entries {
+		collection.Entries = append(collection.Entries, announce.CollectionEntry{Descriptor: descriptor, Title: descriptor})
+	}
+	if err := collection.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	return collection
+}
+
+func TestCollectionStore(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	s := NewCollectionStore(filepath.Join(t.TempDir(), "collections.json"))
+
+	first := signedCollection(t, key, 1, "QmFirst")
+	if updated, err := s.Put(first, "QmBlock1", "subscription"); err != nil || !updated {
+		t.Fatalf("Put failed: %v, %v", updated, err)
+	}
+
+	// Newer revisions replace older ones, which are ignored afterwards
+	second := signedCollection(t, key, 2, "QmFirst", "QmSecond")
+	if updated, err := s.Put(second, "QmBlock2", "subscription"); err != nil || !updated {
+		t.Fatalf("Put of a newer revision failed: %v, %v", updated, err)
+	}
+	if updated, err := s.Put(first, "QmBlock1", "subscription"); err != nil || updated {
+		t.Errorf("An older revision should be ignored: %v, %v", updated, err)
+	}
+	stored, err := s.Get(first.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Revision != 2 || stored.CID != "QmBlock2" || len(stored.Entries) != 2 {
+		t.Errorf("Expected the second revision, got %+v", stored)
+	}
+
+	// Tampered collections are refused
+	forged := *second
+	forged.Revision = 3
+	if _, err := s.Put(&forged, "QmForged", "subscription"); !errors.Is(err, announce.ErrInvalidSignature) {
+		t.Errorf("Expected a forged revision to be refused, got %v", err)
+	}
+
+	if list, err := s.List(); err != nil || len(list) != 1 {
+		t.Errorf("Unexpected list: %v, %v", list, err)
+	}
+	if err := s.Remove(first.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(first.ID()); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected a removed collection to be gone, got %v", err)
+	}
+}
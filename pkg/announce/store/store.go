@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -20,6 +21,9 @@ type Store struct {
 	byDescriptor map[string][]*StoredAnnouncement
 	byTimestamp  []*StoredAnnouncement
 	
+	// Announcements received per topic over time
+	stats *topicStats
+	
 	// Synchronization
 	mu sync.RWMutex
 	
@@ -80,6 +84,9 @@ func NewStore(config StoreConfig) (*Store, error) {
 	if err := store.loadFromDisk(); err != nil {
 		return nil, fmt.Errorf("failed to load announcements: %w", err)
 	}
+	if err := store.loadStats(); err != nil {
+		return nil, fmt.Errorf("failed to load topic stats: %w", err)
+	}
 	
 	// Start cleanup routine
 	store.wg.Add(1)
@@ -109,6 +116,7 @@ func (s *Store) Add(announcement *announce.Announcement, source string) error {
 	s.byTopic[announcement.TopicHash] = append(s.byTopic[announcement.TopicHash], stored)
 	s.byDescriptor[announcement.Descriptor] = append(s.byDescriptor[announcement.Descriptor], stored)
 	s.byTimestamp = append(s.byTimestamp, stored)
+	s.stats.record(announcement.TopicHash, stored.ReceivedAt)
 	
 	// Check size limit
 	if len(s.byTimestamp) > s.maxSize {
@@ -275,11 +283,53 @@ func (s *Store) GetAll() ([]*StoredAnnouncement, error) {
 	return all, nil
 }
 
+// Query returns a sorted page of unexpired announcements matching the
+// query, along with the total number that match
+func (s *Store) Query(query announce.StoreQuery) ([]*StoredAnnouncement, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	
+	// Narrow to the topic indices when filtering by topic
+	candidates := s.byTimestamp
+	if len(query.TopicHashes) > 0 {
+		candidates = nil
+		seen := make(map[string]bool, len(query.TopicHashes))
+		for _, topicHash := range query.TopicHashes {
+			if !seen[topicHash] {
+				seen[topicHash] = true
+				candidates = append(candidates, s.byTopic[topicHash]...)
+			}
+		}
+	}
+	
+	matches := make([]*StoredAnnouncement, 0)
+	for _, ann := range candidates {
+		if !ann.IsExpired() && matchesQuery(ann.Announcement, query) {
+			matches = append(matches, ann)
+		}
+	}
+	
+	sortAnnouncements(matches, query.SortBy, query.SortOrder)
+	
+	total := len(matches)
+	if query.Offset >= total {
+		return []*StoredAnnouncement{}, total, nil
+	}
+	end := total
+	if query.Limit > 0 && query.Offset+query.Limit < total {
+		end = query.Offset + query.Limit
+	}
+	return matches[query.Offset:end], total, nil
+}
+
 // Close closes the store
 func (s *Store) Close() error {
 	close(s.stopCleanup)
 	s.wg.Wait()
-	return nil
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveStats()
 }
 
 // Helper methods
@@ -295,6 +345,64 @@ func (s *Store) hasAnnouncement(ann *announce.Announcement) bool {
 	return false
 }
 
+// matchesQuery applies a query's category, size and time filters
+func matchesQuery(ann *announce.Announcement, query announce.StoreQuery) bool {
+	if len(query.Categories) > 0 && !contains(query.Categories, ann.Category) {
+		return false
+	}
+	if len(query.SizeClasses) > 0 && !contains(query.SizeClasses, ann.SizeClass) {
+		return false
+	}
+	if query.Since != nil && ann.Timestamp < query.Since.Unix() {
+		return false
+	}
+	if query.Until != nil && ann.Timestamp > query.Until.Unix() {
+		return false
+	}
+	return true
+}
+
+// sizeClassOrder ranks size classes for sorting by size
+var sizeClassOrder = map[string]int{
+	announce.SizeClassTiny:   1,
+	announce.SizeClassSmall:  2,
+	announce.SizeClassMedium: 3,
+	announce.SizeClassLarge:  4,
+	announce.SizeClassHuge:   5,
+}
+
+// sortAnnouncements sorts by announcement time or size class, newest or
+// largest first unless the order is ascending. Ties keep the order in
+// which announcements were received, newest first.
+func sortAnnouncements(anns []*StoredAnnouncement, sortBy announce.SortField, order announce.SortOrder) {
+	ascending := order == announce.SortAsc
+	sort.SliceStable(anns, func(i, j int) bool {
+		a, b := anns[i], anns[j]
+		var ka, kb int64
+		if sortBy == announce.SortBySize {
+			ka, kb = int64(sizeClassOrder[a.SizeClass]), int64(sizeClassOrder[b.SizeClass])
+		} else {
+			ka, kb = a.Timestamp, b.Timestamp
+		}
+		if ka == kb {
+			return a.ReceivedAt.After(b.ReceivedAt)
+		}
+		if ascending {
+			return ka < kb
+		}
+		return ka > kb
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // removeFromIndices removes an announcement from all indices
 func (s *Store) removeFromIndices(stored *StoredAnnouncement) {
 	// Remove from topic index
@@ -354,6 +462,9 @@ func (s *Store) cleanup() {
 	}
 	
 	s.byTimestamp = kept
+	
+	s.stats.prune(time.Now())
+	s.saveStats() // Saved again on close
 }
 
 // Persistence methods
@@ -416,7 +527,7 @@ func (s *Store) deleteFromDisk(stored *StoredAnnouncement) {
 
 // isAnnouncementFile checks if a filename is an announcement file
 func isAnnouncementFile(name string) bool {
-	return filepath.Ext(name) == ".json"
+	return filepath.Ext(name) == ".json" && name != topicStatsFile
 }
 
 // GetStats returns store statistics
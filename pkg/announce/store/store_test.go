@@ -0,0 +1,200 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+func TestStoreQuery(t *testing.T) {
+	s, err := NewStore(DefaultStoreConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now().Unix()
+	sizes := []string{announce.SizeClassTiny, announce.SizeClassMedium, announce.SizeClassHuge}
+	for i := 0; i < 30; i++ {
+		topic := "movies"
+		if i%2 == 1 {
+			topic = "books"
+		}
+		ann := &announce.Announcement{
+			Version:    "1.0",
+			Descriptor: fmt.Sprintf("QmDescriptor%04d", i),
+			TopicHash:  announce.HashTopic(topic),
+			Category:   "video",
+			SizeClass:  sizes[i%3],
+			Timestamp:  now - int64(30-i), // Later announcements are newer
+			TTL:        3600,
+			Nonce:      fmt.Sprintf("nonce%d", i),
+		}
+		if err := s.Add(ann, "test"); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	// Newest first by default, paged
+	page, total, err := s.Query(announce.StoreQuery{Offset: 10, Limit: 5})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if total != 30 || len(page) != 5 {
+		t.Fatalf("expected 5 of 30, got %d of %d", len(page), total)
+	}
+	if page[0].Descriptor != "QmDescriptor0019" || page[4].Descriptor != "QmDescriptor0015" {
+		t.Errorf("unexpected page: %s..%s", page[0].Descriptor, page[4].Descriptor)
+	}
+
+	// Topic and size filters with ascending order
+	page, total, err = s.Query(announce.StoreQuery{
+		TopicHashes: []string{announce.HashTopic("movies")},
+		SizeClasses: []string{announce.SizeClassTiny},
+		SortOrder:   announce.SortAsc,
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	// Even indices divisible by 3: 0, 6, 12, 18, 24
+	if total != 5 || len(page) != 5 || page[0].Descriptor != "QmDescriptor0000" {
+		t.Errorf("unexpected filtered results: %d, first %s", total, page[0].Descriptor)
+	}
+
+	// Size sort puts huge announcements first
+	page, _, err = s.Query(announce.StoreQuery{SortBy: announce.SortBySize, Limit: 10})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for _, ann := range page {
+		if ann.SizeClass != announce.SizeClassHuge {
+			t.Errorf("expected huge announcements first, got %s", ann.SizeClass)
+		}
+	}
+
+	// Offsets past the end return an empty page with the total
+	page, total, err = s.Query(announce.StoreQuery{Offset: 100, Limit: 10})
+	if err != nil || len(page) != 0 || total != 30 {
+		t.Errorf("expected empty page of 30, got %d of %d (%v)", len(page), total, err)
+	}
+}
+
+func TestStoreTopicStats(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewStore(DefaultStoreConfig(dir))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	movies := announce.HashTopic("movies")
+	for i := 0; i < 3; i++ {
+		ann := &announce.Announcement{
+			Version:    "1.0",
+			Descriptor: fmt.Sprintf("QmDescriptor%04d", i),
+			TopicHash:  movies,
+			Category:   "video",
+			SizeClass:  announce.SizeClassTiny,
+			Timestamp:  time.Now().Unix(),
+			TTL:        3600,
+			Nonce:      fmt.Sprintf("nonce%d", i),
+		}
+		if err := s.Add(ann, "test"); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	since := time.Now().Add(-3 * time.Hour)
+	points, err := s.TopicStats(movies, StatsHourly, since)
+	if err != nil {
+		t.Fatalf("TopicStats failed: %v", err)
+	}
+	if len(points) != 4 || points[3].Count != 3 || points[0].Count != 0 {
+		t.Fatalf("expected 3 announcements in the last of 4 hours, got %+v", points)
+	}
+	if _, err := s.TopicStats(movies, "week", since); err == nil {
+		t.Error("expected an unknown interval to fail")
+	}
+	s.Close()
+
+	// Statistics survive a restart without being loaded as announcements
+	s, err = NewStore(DefaultStoreConfig(dir))
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer s.Close()
+	if total, _, _ := s.GetStats(); total != 3 {
+		t.Errorf("expected 3 announcements after reopening, got %d", total)
+	}
+	points, err = s.TopicStats(movies, StatsDaily, time.Now())
+	if err != nil {
+		t.Fatalf("TopicStats failed: %v", err)
+	}
+	if len(points) != 1 || points[0].Count != 3 {
+		t.Errorf("expected 3 announcements today, got %+v", points)
+	}
+}
+
+func TestStoreTopicTree(t *testing.T) {
+	s, err := NewStore(DefaultStoreConfig(t.TempDir()))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	defer s.Close()
+
+	topics := map[string]int{"media/movies/scifi": 3, "media/tv": 1, "local/club": 2, "secret": 1}
+	i := 0
+	for topic, count := range topics {
+		for j := 0; j < count; j++ {
+			ann := &announce.Announcement{
+				Version:    "1.0",
+				Descriptor: fmt.Sprintf("QmDescriptor%04d", i),
+				TopicHash:  announce.HashTopic(topic),
+				Category:   "video",
+				SizeClass:  announce.SizeClassTiny,
+				Timestamp:  time.Now().Unix(),
+				TTL:        3600,
+				Nonce:      fmt.Sprintf("nonce%d", i),
+			}
+			if err := s.Add(ann, "test"); err != nil {
+				t.Fatalf("Add failed: %v", err)
+			}
+			i++
+		}
+	}
+
+	// local/club is subscribed; secret is only known by its hash
+	tree := s.TopicTree([]string{"local/club", "local/empty"}, time.Now().Add(-time.Hour))
+	media := tree.Find("Media")
+	if media == nil || media.Recent != 0 || media.Total != 4 {
+		t.Fatalf("expected 4 recent announcements under media, got %+v", media)
+	}
+	if scifi := tree.Find("media/movies/scifi"); scifi == nil || scifi.Recent != 3 || scifi.Stored != 3 {
+		t.Errorf("expected 3 announcements in media/movies/scifi, got %+v", scifi)
+	}
+	if media.Children[0].Name != "movies" {
+		t.Errorf("expected the busiest subtopic first, got %s", media.Children[0].Name)
+	}
+	if tree.Root.Total != 6 {
+		t.Errorf("expected 6 recent announcements under named topics, got %d", tree.Root.Total)
+	}
+	if len(tree.Unnamed) != 1 || tree.Unnamed[0].Hash != announce.HashTopic("secret") || tree.Unnamed[0].Recent != 1 {
+		t.Errorf("expected secret as the only unnamed topic, got %+v", tree.Unnamed)
+	}
+
+	// Pruning keeps busy and subscribed topics only
+	tree.Prune([]string{"local/empty"})
+	if tree.Find("documents") != nil || tree.Find("media/music") != nil {
+		t.Error("expected topics without announcements to be pruned")
+	}
+	if tree.Find("local/empty") == nil || tree.Find("media/movies/scifi") == nil {
+		t.Error("expected busy and kept topics to stay")
+	}
+
+	// Counts older than the hourly statistics come from the daily ones
+	tree = s.TopicTree(nil, time.Now().Add(-30*24*time.Hour))
+	if tree.Root.Total != 4 {
+		t.Errorf("expected 4 announcements this month under common topics, got %d", tree.Root.Total)
+	}
+}
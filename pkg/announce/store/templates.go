@@ -0,0 +1,139 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// ErrTemplateNotFound is returned for unknown template names
+var ErrTemplateNotFound = errors.New("announcement template not found")
+
+// TemplateStore is the file of announcement templates saved on this device
+type TemplateStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultTemplateStorePath returns ~/.noisefs/announce_templates.json
+func DefaultTemplateStorePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "announce_templates.json"), nil
+}
+
+// NewTemplateStore returns the store kept at path
+func NewTemplateStore(path string) *TemplateStore {
+	return &TemplateStore{path: path}
+}
+
+// Put saves a template under name, replacing any with the same name
+func (s *TemplateStore) Put(name string, template announce.Template) error {
+	if name == "" {
+		return errors.New("template name cannot be empty")
+	}
+	if err := template.Validate(); err != nil {
+		return err
+	}
+	return s.update(func(templates map[string]announce.Template) error {
+		templates[name] = template
+		return nil
+	})
+}
+
+// Get returns the template saved under name
+func (s *TemplateStore) Get(name string) (announce.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	templates, err := s.load()
+	if err != nil {
+		return announce.Template{}, err
+	}
+	template, ok := templates[name]
+	if !ok {
+		return announce.Template{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return template, nil
+}
+
+// Names returns the names of the saved templates, sorted
+func (s *TemplateStore) Names() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	templates, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Remove deletes a template
+func (s *TemplateStore) Remove(name string) error {
+	return s.update(func(templates map[string]announce.Template) error {
+		if _, ok := templates[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+		}
+		delete(templates, name)
+		return nil
+	})
+}
+
+// update loads the templates, applies fn and saves them if it succeeds
+func (s *TemplateStore) update(fn func(templates map[string]announce.Template) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	templates, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(templates); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize templates: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write templates: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write templates: %w", err)
+	}
+	return nil
+}
+
+// load reads the templates by name. The caller must hold s.mu.
+func (s *TemplateStore) load() (map[string]announce.Template, error) {
+	templates := make(map[string]announce.Template)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return templates, nil
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+func TestTemplateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "announce_templates.json")
+	templates := NewTemplateStore(path)
+
+	papers := announce.Template{Topic: "documents/research", Tags: []string{"format:pdf"}, License: "CC-BY-4.0", Sign: true}
+	if err := templates.Put("papers", papers); err != nil {
+		t.Fatal(err)
+	}
+	if err := templates.Put("talks", announce.Template{Topic: "videos/talks", TTL: "168h"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := templates.Put("broken", announce.Template{TTL: "soon"}); err == nil {
+		t.Error("Expected an invalid template to be rejected")
+	}
+	if err := templates.Put("", papers); err == nil {
+		t.Error("Expected an unnamed template to be rejected")
+	}
+
+	// A new store reads what the first one saved
+	reopened := NewTemplateStore(path)
+	if got, err := reopened.Get("papers"); err != nil || !reflect.DeepEqual(got, papers) {
+		t.Errorf("Get(papers) = %+v, %v", got, err)
+	}
+	if names, err := reopened.Names(); err != nil || !reflect.DeepEqual(names, []string{"papers", "talks"}) {
+		t.Errorf("Names() = %v, %v", names, err)
+	}
+
+	if err := reopened.Remove("talks"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templates.Get("talks"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected the removed template to be gone, got %v", err)
+	}
+	if err := templates.Remove("talks"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected removing an unknown template to fail, got %v", err)
+	}
+}
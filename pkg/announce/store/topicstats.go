@@ -0,0 +1,155 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StatsInterval is the bucket size of topic statistics
+type StatsInterval string
+
+const (
+	StatsHourly StatsInterval = "hour"
+	StatsDaily  StatsInterval = "day"
+)
+
+// Statistics outlive the announcements they count, so topics show trends
+// beyond the store's retention
+const (
+	hourlyStatsRetention = 7 * 24 * time.Hour
+	dailyStatsRetention  = 90 * 24 * time.Hour
+)
+
+// topicStatsFile holds the statistics next to the announcements
+const topicStatsFile = "topic-stats.json"
+
+// TopicStatsPoint is the number of announcements received for a topic in
+// one interval
+type TopicStatsPoint struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+}
+
+// topicStats counts announcements per topic hash and interval start (Unix
+// seconds, UTC)
+type topicStats struct {
+	Hourly map[string]map[int64]int `json:"hourly"`
+	Daily  map[string]map[int64]int `json:"daily"`
+}
+
+func newTopicStats() *topicStats {
+	return &topicStats{
+		Hourly: make(map[string]map[int64]int),
+		Daily:  make(map[string]map[int64]int),
+	}
+}
+
+// duration returns the length of an interval
+func (i StatsInterval) duration() (time.Duration, error) {
+	switch i {
+	case StatsHourly:
+		return time.Hour, nil
+	case StatsDaily:
+		return 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown stats interval %q (use %s or %s)", i, StatsHourly, StatsDaily)
+	}
+}
+
+// record counts an announcement received at the given time
+func (ts *topicStats) record(topicHash string, at time.Time) {
+	increment(ts.Hourly, topicHash, at.Truncate(time.Hour).Unix())
+	increment(ts.Daily, topicHash, at.Truncate(24*time.Hour).Unix())
+}
+
+func increment(buckets map[string]map[int64]int, topicHash string, start int64) {
+	if buckets[topicHash] == nil {
+		buckets[topicHash] = make(map[int64]int)
+	}
+	buckets[topicHash][start]++
+}
+
+// prune drops intervals older than their retention
+func (ts *topicStats) prune(now time.Time) {
+	pruneBuckets(ts.Hourly, now.Add(-hourlyStatsRetention).Unix())
+	pruneBuckets(ts.Daily, now.Add(-dailyStatsRetention).Unix())
+}
+
+func pruneBuckets(buckets map[string]map[int64]int, cutoff int64) {
+	for topicHash, counts := range buckets {
+		for start := range counts {
+			if start < cutoff {
+				delete(counts, start)
+			}
+		}
+		if len(counts) == 0 {
+			delete(buckets, topicHash)
+		}
+	}
+}
+
+// TopicStats returns the announcements received for a topic hash per
+// interval since the given time, oldest first. Intervals without
+// announcements are included with a count of zero.
+func (s *Store) TopicStats(topicHash string, interval StatsInterval, since time.Time) ([]TopicStatsPoint, error) {
+	length, err := interval.duration()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := s.stats.Hourly[topicHash]
+	if interval == StatsDaily {
+		counts = s.stats.Daily[topicHash]
+	}
+
+	now := time.Now()
+	var points []TopicStatsPoint
+	for start := since.Truncate(length); !start.After(now); start = start.Add(length) {
+		points = append(points, TopicStatsPoint{
+			Start: start.UTC(),
+			Count: counts[start.Unix()],
+		})
+	}
+	return points, nil
+}
+
+// loadStats loads the topic statistics, or rebuilds them from the loaded
+// announcements when there are none yet
+func (s *Store) loadStats() error {
+	s.stats = newTopicStats()
+	data, err := os.ReadFile(filepath.Join(s.dataDir, topicStatsFile))
+	if os.IsNotExist(err) {
+		for _, stored := range s.byTimestamp {
+			s.stats.record(stored.TopicHash, stored.ReceivedAt)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, s.stats); err != nil {
+		return fmt.Errorf("failed to parse topic stats: %w", err)
+	}
+	if s.stats.Hourly == nil {
+		s.stats.Hourly = make(map[string]map[int64]int)
+	}
+	if s.stats.Daily == nil {
+		s.stats.Daily = make(map[string]map[int64]int)
+	}
+	return nil
+}
+
+// saveStats saves the topic statistics. The caller must hold mu.
+func (s *Store) saveStats() error {
+	data, err := json.Marshal(s.stats)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dataDir, topicStatsFile), data, 0644)
+}
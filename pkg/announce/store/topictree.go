@@ -0,0 +1,166 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+)
+
+// TopicTreeNode is a topic with the announcements received for it. Topics
+// are only published as hashes, so the tree holds the topics whose names
+// are known: the common hierarchy and the given topics.
+type TopicTreeNode struct {
+	Name     string           `json:"name"`
+	Path     string           `json:"path"`
+	Hash     string           `json:"hash"`
+	Recent   int              `json:"recent"` // Announcements received for this topic since the cutoff
+	Total    int              `json:"total"`  // Recent announcements including subtopics
+	Stored   int              `json:"stored"` // Announcements of this topic in the store
+	Children []*TopicTreeNode `json:"children,omitempty"`
+}
+
+// TopicTree is the topic hierarchy with recent announcement counts
+type TopicTree struct {
+	Since   time.Time        `json:"since"`
+	Root    *TopicTreeNode   `json:"root"`
+	Unnamed []*TopicTreeNode `json:"unnamed,omitempty"` // Topic hashes with announcements but no known name
+}
+
+// TopicTree builds the topic hierarchy from the common topics and the
+// given ones, such as subscriptions, with the announcements received for
+// each since the given time. Recent counts come from the topic statistics,
+// so they include announcements that have since expired.
+func (s *Store) TopicTree(topics []string, since time.Time) *TopicTree {
+	hierarchy := announce.BuildCommonHierarchy()
+	for _, topic := range topics {
+		hierarchy.AddTopic(topic, nil)
+	}
+	root, _ := hierarchy.GetTopic("")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	named := make(map[string]bool)
+	tree := &TopicTree{Since: since, Root: s.topicTreeNode(root, since, named)}
+	tree.Root.Name = ""
+
+	// Topics the store has seen under names it doesn't know
+	unnamed := make(map[string]bool)
+	for hash := range s.byTopic {
+		unnamed[hash] = true
+	}
+	for hash := range s.stats.Hourly {
+		unnamed[hash] = true
+	}
+	for hash := range unnamed {
+		if named[hash] {
+			continue
+		}
+		node := &TopicTreeNode{Hash: hash, Recent: s.recentCount(hash, since), Stored: len(s.byTopic[hash])}
+		node.Total = node.Recent
+		if node.Recent > 0 || node.Stored > 0 {
+			tree.Unnamed = append(tree.Unnamed, node)
+		}
+	}
+	sortTopicTreeNodes(tree.Unnamed)
+	return tree
+}
+
+// topicTreeNode converts a hierarchy node and its subtopics. The caller
+// must hold mu.
+func (s *Store) topicTreeNode(topic *announce.TopicNode, since time.Time, named map[string]bool) *TopicTreeNode {
+	named[topic.Hash] = true
+	node := &TopicTreeNode{
+		Name:   topic.Name,
+		Path:   topic.Path,
+		Hash:   topic.Hash,
+		Recent: s.recentCount(topic.Hash, since),
+		Stored: len(s.byTopic[topic.Hash]),
+	}
+	node.Total = node.Recent
+	for _, child := range topic.Children {
+		childNode := s.topicTreeNode(child, since, named)
+		node.Total += childNode.Total
+		node.Children = append(node.Children, childNode)
+	}
+	sortTopicTreeNodes(node.Children)
+	return node
+}
+
+// recentCount sums a topic's statistics since the given time, using daily
+// intervals once hourly ones have been pruned. The caller must hold mu.
+func (s *Store) recentCount(topicHash string, since time.Time) int {
+	counts, length := s.stats.Hourly[topicHash], time.Hour
+	if time.Since(since) > hourlyStatsRetention {
+		counts, length = s.stats.Daily[topicHash], 24*time.Hour
+	}
+	cutoff := since.Truncate(length).Unix()
+	total := 0
+	for start, count := range counts {
+		if start >= cutoff {
+			total += count
+		}
+	}
+	return total
+}
+
+// sortTopicTreeNodes puts the busiest topics first, then sorts by name
+func sortTopicTreeNodes(nodes []*TopicTreeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Total != nodes[j].Total {
+			return nodes[i].Total > nodes[j].Total
+		}
+		if nodes[i].Stored != nodes[j].Stored {
+			return nodes[i].Stored > nodes[j].Stored
+		}
+		return strings.Compare(nodes[i].Path+nodes[i].Hash, nodes[j].Path+nodes[j].Hash) < 0
+	})
+}
+
+// Find returns the node of a topic path in the tree, or nil. Paths are
+// matched as topics are hashed, ignoring case and extra slashes.
+func (t *TopicTree) Find(path string) *TopicTreeNode {
+	node := t.Root
+	for _, name := range strings.Split(strings.ToLower(path), "/") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		var next *TopicTreeNode
+		for _, child := range node.Children {
+			if child.Name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// Prune drops subtopics without recent or stored announcements, except
+// the topics to keep, such as subscriptions, and their parents
+func (t *TopicTree) Prune(keep []string) {
+	kept := make(map[string]bool, len(keep))
+	for _, topic := range keep {
+		kept[announce.HashTopic(topic)] = true
+	}
+	pruneTopicTreeNode(t.Root, kept)
+}
+
+// pruneTopicTreeNode prunes a node's subtopics and reports whether the
+// node has anything worth showing
+func pruneTopicTreeNode(node *TopicTreeNode, kept map[string]bool) bool {
+	children := node.Children[:0]
+	for _, child := range node.Children {
+		if pruneTopicTreeNode(child, kept) {
+			children = append(children, child)
+		}
+	}
+	node.Children = children
+	return len(children) > 0 || node.Total > 0 || node.Stored > 0 || kept[node.Hash]
+}
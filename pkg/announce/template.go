@@ -0,0 +1,183 @@
+package announce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Template is a reusable set of announcement settings, for publishers who
+// announce many files the same way. Empty fields leave a setting to
+// whatever the template is merged over.
+type Template struct {
+	Topic       string   `json:"topic,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	TTL         string   `json:"ttl,omitempty"` // Duration such as "72h"
+	AutoTags    bool     `json:"auto_tags,omitempty"`
+	Realtime    bool     `json:"realtime,omitempty"`
+	Sign        bool     `json:"sign,omitempty"`
+	Description string   `json:"description,omitempty"`
+	License     string   `json:"license,omitempty"`
+}
+
+// Merge returns t with the settings of over applied on top. Tags are
+// combined; switches stay on if either turns them on.
+func (t Template) Merge(over Template) Template {
+	merged := t
+	if over.Topic != "" {
+		merged.Topic = over.Topic
+	}
+	if len(over.Tags) > 0 {
+		merged.Tags = DeduplicateTags(append(append([]string{}, t.Tags...), over.Tags...))
+	}
+	if over.Category != "" {
+		merged.Category = over.Category
+	}
+	if over.TTL != "" {
+		merged.TTL = over.TTL
+	}
+	merged.AutoTags = t.AutoTags || over.AutoTags
+	merged.Realtime = t.Realtime || over.Realtime
+	merged.Sign = t.Sign || over.Sign
+	if over.Description != "" {
+		merged.Description = over.Description
+	}
+	if over.License != "" {
+		merged.License = over.License
+	}
+	return merged
+}
+
+// Validate checks the settings that are set
+func (t Template) Validate() error {
+	if t.Category != "" && !isValidCategory(t.Category) {
+		return fmt.Errorf("invalid category %q", t.Category)
+	}
+	if _, err := t.ttl(); err != nil {
+		return err
+	}
+	if len(t.Description) > maxMetadataDescription {
+		return fmt.Errorf("description too long: %d > %d", len(t.Description), maxMetadataDescription)
+	}
+	if len(t.License) > maxMetadataLicense {
+		return fmt.Errorf("license too long: %d > %d", len(t.License), maxMetadataLicense)
+	}
+	return nil
+}
+
+// Options returns the options to create an announcement with. The topic
+// must be set.
+func (t Template) Options() (CreateOptions, error) {
+	if err := t.Validate(); err != nil {
+		return CreateOptions{}, err
+	}
+	if t.Topic == "" {
+		return CreateOptions{}, errors.New("topic is required")
+	}
+	ttl, _ := t.ttl()
+	return CreateOptions{
+		Topic:    t.Topic,
+		Tags:     t.Tags,
+		Category: t.Category,
+		TTL:      ttl,
+		AutoTags: t.AutoTags,
+	}, nil
+}
+
+// ttl parses the TTL, which is 0 when not set
+func (t Template) ttl() (time.Duration, error) {
+	if t.TTL == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(t.TTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q: %w", t.TTL, err)
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q: must be positive", t.TTL)
+	}
+	return ttl, nil
+}
+
+// BatchItem is one descriptor of a batch announcement. Its settings are
+// merged over the saved template it names, which is merged over the
+// settings the whole batch is announced with.
+type BatchItem struct {
+	Template
+	Descriptor string `json:"descriptor"`
+	Base       string `json:"template,omitempty"` // Name of a saved template
+	Title      string `json:"title,omitempty"`
+	Preview    string `json:"preview,omitempty"` // Descriptor CID of a preview
+}
+
+// ParseBatch reads the items of a batch file, either a JSON array or one
+// JSON object per line
+func ParseBatch(r io.Reader) ([]BatchItem, error) {
+	reader := bufio.NewReader(r)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil, errors.New("batch file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to parse batch: %w", err)
+		}
+	} else {
+		for {
+			var item json.RawMessage
+			if err := decoder.Decode(&item); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to parse batch item %d: %w", len(raw)+1, err)
+			}
+			raw = append(raw, item)
+		}
+	}
+
+	items := make([]BatchItem, 0, len(raw))
+	for i, data := range raw {
+		var item BatchItem
+		itemDecoder := json.NewDecoder(bytes.NewReader(data))
+		itemDecoder.DisallowUnknownFields()
+		if err := itemDecoder.Decode(&item); err != nil {
+			return nil, fmt.Errorf("failed to parse batch item %d: %w", i+1, err)
+		}
+		if item.Descriptor == "" {
+			return nil, fmt.Errorf("batch item %d has no descriptor", i+1)
+		}
+		if err := item.Validate(); err != nil {
+			return nil, fmt.Errorf("batch item %d: %w", i+1, err)
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("batch file has no items")
+	}
+	return items, nil
+}
+
+// firstNonSpace peeks at the first byte that isn't white space
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, reader.UnreadByte()
+	}
+}
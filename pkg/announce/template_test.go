@@ -0,0 +1,102 @@
+package announce
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemplateMerge(t *testing.T) {
+	base := Template{Topic: "documents/research", Tags: []string{"format:pdf"}, TTL: "72h", License: "CC-BY-4.0", Sign: true}
+	merged := base.Merge(Template{Topic: "documents/papers", Tags: []string{"year:2024", "format:pdf"}, Realtime: true})
+
+	want := Template{
+		Topic:    "documents/papers",
+		Tags:     []string{"format:pdf", "year:2024"},
+		TTL:      "72h",
+		License:  "CC-BY-4.0",
+		Realtime: true,
+		Sign:     true,
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge() = %+v, want %+v", merged, want)
+	}
+	if len(base.Tags) != 1 {
+		t.Errorf("Merge changed the template it was called on: %v", base.Tags)
+	}
+
+	opts, err := merged.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Topic != "documents/papers" || opts.TTL != 72*time.Hour || len(opts.Tags) != 2 {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	if _, err := (Template{Tags: []string{"a"}}).Options(); err == nil {
+		t.Error("Expected options without a topic to fail")
+	}
+	for _, invalid := range []Template{{TTL: "soon"}, {TTL: "-1h"}, {Category: "games"}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to fail validation", invalid)
+		}
+	}
+}
+
+func TestParseBatch(t *testing.T) {
+	lines := `{"descriptor": "QmFirst", "topic": "books", "tags": ["epub"], "title": "First"}
+
+{"descriptor": "QmSecond", "template": "papers", "ttl": "48h"}
+`
+	items, err := ParseBatch(strings.NewReader(lines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(items))
+	}
+	if items[0].Descriptor != "QmFirst" || items[0].Topic != "books" || items[0].Title != "First" || !reflect.DeepEqual(items[0].Tags, []string{"epub"}) {
+		t.Errorf("Unexpected first item: %+v", items[0])
+	}
+	if items[1].Base != "papers" || items[1].TTL != "48h" {
+		t.Errorf("Unexpected second item: %+v", items[1])
+	}
+
+	array := `  [{"descriptor": "QmFirst"}, {"descriptor": "QmSecond", "license": "MIT"}]`
+	if items, err := ParseBatch(strings.NewReader(array)); err != nil || len(items) != 2 || items[1].License != "MIT" {
+		t.Errorf("ParseBatch(array) = %+v, %v", items, err)
+	}
+
+	for name, invalid := range map[string]string{
+		"empty":         "  \n",
+		"no items":      "[]",
+		"no descriptor": `{"topic": "books"}`,
+		"unknown field": `{"descriptor": "QmFirst", "topics": ["books"]}`,
+		"invalid ttl":   `{"descriptor": "QmFirst", "ttl": "soon"}`,
+		"malformed":     `{"descriptor": `,
+	} {
+		if _, err := ParseBatch(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected the %s batch to fail", name)
+		}
+	}
+}
+
+func TestCreateForDescriptor(t *testing.T) {
+	ann, err := NewCreator().CreateForDescriptor("QmDescriptor", "talk.mp4", 50<<20, CreateOptions{Topic: "videos/talks", AutoTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.Category != CategoryVideo || ann.SizeClass != SizeClassMedium {
+		t.Errorf("Expected a medium video, got %s/%s", ann.Category, ann.SizeClass)
+	}
+	bloom, err := DecodeBloom(ann.TagBloom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"ext:mp4", "type:video", "size:medium"} {
+		if !bloom.Test(normalizeTag(tag)) {
+			t.Errorf("Expected tag %s in the bloom filter", tag)
+		}
+	}
+}
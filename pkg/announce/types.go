@@ -3,12 +3,19 @@ package announce
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
 )
 
 // Version of the announcement protocol
 const Version = "1.0"
 
+// ErrNewerVersion is returned for announcements published by a newer
+// version of NoiseFS than this build can read
+var ErrNewerVersion = errors.New("announcement was published by a newer version of NoiseFS; upgrade NoiseFS to read it")
+
 // Category constants for broad content classification
 const (
 	CategoryVideo    = "video"
@@ -39,7 +46,10 @@ type Announcement struct {
 	Timestamp  int64  `json:"ts"`             // Unix timestamp
 	TTL        int64  `json:"ttl"`            // Time to live in seconds
 	Nonce      string `json:"n,omitempty"`    // Random nonce for uniqueness
-	Signature  string `json:"sig,omitempty"`  // Optional IPNS signature
+	Signature  string `json:"sig,omitempty"`  // Publisher's signature over the rest, when signed
+	Kind       string `json:"k,omitempty"`    // What Descriptor points to: a file if empty, or KindCollection
+	Metadata   string `json:"m,omitempty"`    // CID of a Metadata record for the descriptor
+	Publisher  string `json:"p,omitempty"`    // Hex ed25519 key of the publisher, when signed
 }
 
 // NewAnnouncement creates a new announcement with defaults
@@ -55,6 +65,9 @@ func NewAnnouncement(descriptor, topicHash string) *Announcement {
 
 // Validate checks if the announcement is valid
 func (a *Announcement) Validate() error {
+	if version.Newer(a.Version, Version) {
+		return fmt.Errorf("%w (announcement version %s, this build reads %s)", ErrNewerVersion, a.Version, Version)
+	}
 	if a.Version != Version {
 		return errors.New("unsupported announcement version")
 	}
@@ -83,6 +96,18 @@ func (a *Announcement) Validate() error {
 		return errors.New("TTL must be positive")
 	}
 	
+	if a.Kind != "" && a.Kind != KindCollection {
+		return errors.New("invalid kind")
+	}
+	
+	if a.Metadata != "" && a.Kind != "" {
+		return errors.New("only file announcements carry metadata")
+	}
+	
+	if err := a.VerifyPublisher(); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -104,6 +129,14 @@ func (a *Announcement) ToJSON() ([]byte, error) {
 func FromJSON(data []byte) (*Announcement, error) {
 	var ann Announcement
 	if err := json.Unmarshal(data, &ann); err != nil {
+		// A newer format may change field types; say so rather than
+		// reporting the parse error
+		var header struct {
+			Version string `json:"v"`
+		}
+		if json.Unmarshal(data, &header) == nil && version.Newer(header.Version, Version) {
+			return nil, fmt.Errorf("%w (announcement version %s, this build reads %s)", ErrNewerVersion, header.Version, Version)
+		}
 		return nil, err
 	}
 	
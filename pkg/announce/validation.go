@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
 )
 
 // ValidationConfig holds configuration for announcement validation
@@ -50,7 +52,10 @@ func (v *Validator) ValidateAnnouncement(ann *Announcement) error {
 	if ann.Version == "" {
 		return fmt.Errorf("missing version")
 	}
-	if ann.Version != "1.0" {
+	if version.Newer(ann.Version, Version) {
+		return fmt.Errorf("%w (announcement version %s, this build reads %s)", ErrNewerVersion, ann.Version, Version)
+	}
+	if ann.Version != Version {
 		return fmt.Errorf("unsupported version: %s", ann.Version)
 	}
 	
@@ -95,6 +100,23 @@ func (v *Validator) ValidateAnnouncement(ann *Announcement) error {
 		}
 	}
 	
+	if ann.Kind != "" && ann.Kind != KindCollection {
+		return fmt.Errorf("unsupported kind: %s", ann.Kind)
+	}
+	
+	if ann.Metadata != "" {
+		if ann.Kind != "" {
+			return fmt.Errorf("only file announcements carry metadata")
+		}
+		if err := v.validateDescriptor(ann.Metadata); err != nil {
+			return fmt.Errorf("invalid metadata CID: %w", err)
+		}
+	}
+	
+	if err := ann.VerifyPublisher(); err != nil {
+		return err
+	}
+	
 	// Validate nonce
 	if ann.Nonce == "" {
 		return fmt.Errorf("missing nonce")
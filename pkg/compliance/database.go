@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
@@ -280,8 +282,12 @@ func (db *ComplianceDatabase) GetUserViolations(userID string) []*ViolationRecor
 
 // IsRepeatInfringer checks if a user qualifies as a repeat infringer
 func (db *ComplianceDatabase) IsRepeatInfringer(userID string) bool {
-	violations := db.GetUserViolations(userID)
-	
+	return isRepeatInfringer(db.GetUserViolations(userID))
+}
+
+// isRepeatInfringer applies the repeat infringer rule to a user's
+// violations; it doesn't lock, so updateMetrics can use it
+func isRepeatInfringer(violations []*ViolationRecord) bool {
 	// Count major violations in the last 6 months
 	cutoff := time.Now().Add(-6 * 30 * 24 * time.Hour)
 	count := 0
@@ -462,8 +468,8 @@ func (db *ComplianceDatabase) updateMetrics() {
 	
 	// Count repeat infringers
 	repeatInfringers := int64(0)
-	for userID := range db.UserViolations {
-		if db.IsRepeatInfringer(userID) {
+	for _, violations := range db.UserViolations {
+		if isRepeatInfringer(violations) {
 			repeatInfringers++
 		}
 	}
@@ -479,39 +485,61 @@ func (db *ComplianceDatabase) updateMetrics() {
 	db.ComplianceMetrics.LastUpdated = time.Now()
 }
 
-// SaveToFile saves the compliance database to a JSON file
+// SaveToFile saves the compliance database to a JSON file, readable only
+// by its owner since notices contain personal data
 func (db *ComplianceDatabase) SaveToFile(filename string) error {
 	db.mutex.RLock()
-	defer db.mutex.RUnlock()
-	
 	data, err := json.MarshalIndent(db, "", "  ")
+	db.mutex.RUnlock()
 	if err != nil {
 		return fmt.Errorf("failed to marshal compliance database: %w", err)
 	}
-	
-	// In a real implementation, this would write to a file
-	// For now, we'll just validate the JSON is correct
-	var test ComplianceDatabase
-	if err := json.Unmarshal(data, &test); err != nil {
-		return fmt.Errorf("failed to validate JSON: %w", err)
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return fmt.Errorf("failed to create compliance directory: %w", err)
+	}
+	// Write a temporary file and rename it, so a crash never leaves a
+	// truncated database
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write compliance database: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write compliance database: %w", err)
 	}
-	
 	return nil
 }
 
-// LoadFromFile loads the compliance database from a JSON file
+// LoadFromFile loads the compliance database from a JSON file. A missing
+// file loads an empty database.
 func (db *ComplianceDatabase) LoadFromFile(filename string) error {
-	// In a real implementation, this would read from a file
-	// For now, we'll just initialize with empty data
+	loaded := NewComplianceDatabase()
+	data, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read compliance database: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, loaded); err != nil {
+			return fmt.Errorf("failed to parse compliance database %s: %w", filename, err)
+		}
+	}
+
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
-	
-	db.BlacklistedDescriptors = make(map[string]*TakedownRecord)
-	db.TakedownHistory = make([]*TakedownEvent, 0)
-	db.UserViolations = make(map[string][]*ViolationRecord)
-	db.ComplianceMetrics = &ComplianceMetrics{
-		LastUpdated: time.Now(),
+
+	db.BlacklistedDescriptors = loaded.BlacklistedDescriptors
+	db.TakedownHistory = loaded.TakedownHistory
+	db.UserViolations = loaded.UserViolations
+	db.ComplianceMetrics = loaded.ComplianceMetrics
+	if db.BlacklistedDescriptors == nil {
+		db.BlacklistedDescriptors = make(map[string]*TakedownRecord)
+	}
+	if db.UserViolations == nil {
+		db.UserViolations = make(map[string][]*ViolationRecord)
+	}
+	if db.ComplianceMetrics == nil {
+		db.ComplianceMetrics = &ComplianceMetrics{LastUpdated: time.Now()}
 	}
-	
 	return nil
-}
\ No newline at end of file
+}
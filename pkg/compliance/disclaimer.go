@@ -0,0 +1,150 @@
+package compliance
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DisclaimerVersion identifies the current legal disclaimer. Bump it when
+// the terms change so everyone has to accept them again.
+const DisclaimerVersion = "2024-1"
+
+// ErrDisclaimerNotAccepted is returned when the current disclaimer has not
+// been accepted
+var ErrDisclaimerNotAccepted = errors.New("the NoiseFS legal disclaimer has not been accepted; run noisefs interactively or pass -accept-tos")
+
+// DisclaimerTerms are the terms a user accepts, in order
+var DisclaimerTerms = []string{
+	"You will NOT use this software to share copyrighted material without authorization",
+	"You will NOT use this software for any illegal activities",
+	"You are solely responsible for all content you upload, share, or download",
+	"You understand that all actions may be logged and you may be held accountable",
+	"The developers are NOT responsible for how you use this software",
+}
+
+// Acknowledgement records who accepted which version of the disclaimer
+type Acknowledgement struct {
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	Via        string    `json:"via"` // Where it was accepted: "cli", "mount" or "webui"
+}
+
+// DefaultAcknowledgementPath returns ~/.noisefs/legal_acknowledgement.json
+func DefaultAcknowledgementPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "legal_acknowledgement.json"), nil
+}
+
+// LoadAcknowledgement reads the acknowledgement at path, returning nil if
+// the disclaimer was never accepted
+func LoadAcknowledgement(path string) (*Acknowledgement, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read acknowledgement: %w", err)
+	}
+	var ack Acknowledgement
+	if err := json.Unmarshal(data, &ack); err != nil {
+		return nil, fmt.Errorf("failed to parse acknowledgement: %w", err)
+	}
+	return &ack, nil
+}
+
+// DisclaimerAccepted reports whether the current disclaimer version has
+// been accepted
+func DisclaimerAccepted(path string) bool {
+	ack, err := LoadAcknowledgement(path)
+	return err == nil && ack != nil && ack.Version == DisclaimerVersion
+}
+
+// AcceptDisclaimer records acceptance of the current disclaimer version
+func AcceptDisclaimer(path string, via string) (*Acknowledgement, error) {
+	ack := &Acknowledgement{
+		Version:    DisclaimerVersion,
+		AcceptedAt: time.Now().UTC(),
+		Via:        via,
+	}
+	data, err := json.MarshalIndent(ack, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save acknowledgement: %w", err)
+	}
+	return ack, nil
+}
+
+// PromptDisclaimer shows the disclaimer on out and records acceptance if
+// the user answers "yes" on in
+func PromptDisclaimer(path string, via string, in io.Reader, out io.Writer) error {
+	rule := strings.Repeat("=", 80)
+	fmt.Fprintln(out, "\n"+rule)
+	fmt.Fprintln(out, "                          ⚠️  LEGAL NOTICE & TERMS OF USE")
+	fmt.Fprintln(out, rule)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "IMPORTANT: This software is provided for LEGITIMATE PURPOSES ONLY.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "By using NoiseFS, you acknowledge and agree that:")
+	fmt.Fprintln(out)
+	for _, term := range DisclaimerTerms {
+		fmt.Fprintf(out, "  • %s\n", term)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Legitimate use cases include:")
+	fmt.Fprintln(out, "  • Open source software distribution")
+	fmt.Fprintln(out, "  • Academic and research data sharing")
+	fmt.Fprintln(out, "  • Public domain content distribution")
+	fmt.Fprintln(out, "  • Personal backup and file synchronization")
+	fmt.Fprintln(out, "  • Creative Commons licensed content")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "For detailed information, see: docs/LEGITIMATE_USE_CASES.md")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, strings.Repeat("-", 80))
+	fmt.Fprintf(out, "\nDo you understand and accept these terms? (yes/no): ")
+
+	response, _ := bufio.NewReader(in).ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(response)) != "yes" {
+		return ErrDisclaimerNotAccepted
+	}
+	if _, err := AcceptDisclaimer(path, via); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nThank you for accepting the terms. Remember to use NoiseFS responsibly.")
+	fmt.Fprintln(out, rule)
+	fmt.Fprintln(out)
+	return nil
+}
+
+// EnsureDisclaimerAccepted is the disclaimer gate shared by the NoiseFS
+// commands. It passes if the current version was accepted before, records
+// acceptance when acceptFlag is set, and otherwise prompts on in and out.
+func EnsureDisclaimerAccepted(acceptFlag bool, via string, in io.Reader, out io.Writer) error {
+	path, err := DefaultAcknowledgementPath()
+	if err != nil {
+		return err
+	}
+	if DisclaimerAccepted(path) {
+		return nil
+	}
+	if acceptFlag {
+		_, err := AcceptDisclaimer(path, via)
+		return err
+	}
+	return PromptDisclaimer(path, via, in, out)
+}
@@ -0,0 +1,67 @@
+package compliance
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDisclaimerAcknowledgement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "noisefs", "legal_acknowledgement.json")
+	if DisclaimerAccepted(path) {
+		t.Fatal("Expected the disclaimer not to be accepted yet")
+	}
+
+	var out bytes.Buffer
+	if err := PromptDisclaimer(path, "cli", strings.NewReader("no\n"), &out); !errors.Is(err, ErrDisclaimerNotAccepted) {
+		t.Errorf("Expected declining to fail with ErrDisclaimerNotAccepted, got %v", err)
+	}
+	if !strings.Contains(out.String(), DisclaimerTerms[0]) {
+		t.Error("Expected the prompt to show the terms")
+	}
+	if err := PromptDisclaimer(path, "cli", strings.NewReader(""), &out); !errors.Is(err, ErrDisclaimerNotAccepted) {
+		t.Errorf("Expected no answer to fail, got %v", err)
+	}
+	if DisclaimerAccepted(path) {
+		t.Fatal("Expected declining not to record acceptance")
+	}
+
+	if err := PromptDisclaimer(path, "mount", strings.NewReader("YES\n"), &out); err != nil {
+		t.Fatalf("Expected acceptance, got %v", err)
+	}
+	ack, err := LoadAcknowledgement(path)
+	if err != nil || ack == nil || ack.Version != DisclaimerVersion || ack.Via != "mount" {
+		t.Fatalf("Unexpected acknowledgement %+v, %v", ack, err)
+	}
+	if !DisclaimerAccepted(path) {
+		t.Error("Expected the disclaimer to be accepted")
+	}
+
+	// Acceptance of an older version doesn't count
+	if err := os.WriteFile(path, []byte(`{"version":"0","accepted_at":"2020-01-01T00:00:00Z","via":"cli"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if DisclaimerAccepted(path) {
+		t.Error("Expected an outdated acknowledgement to be rejected")
+	}
+}
+
+func TestEnsureDisclaimerAccepted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := EnsureDisclaimerAccepted(false, "cli", strings.NewReader(""), &bytes.Buffer{}); !errors.Is(err, ErrDisclaimerNotAccepted) {
+		t.Fatalf("Expected the gate to fail without acceptance, got %v", err)
+	}
+	if err := EnsureDisclaimerAccepted(true, "webui", strings.NewReader(""), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Expected the flag to accept, got %v", err)
+	}
+
+	// Later runs pass without prompting
+	var out bytes.Buffer
+	if err := EnsureDisclaimerAccepted(false, "cli", strings.NewReader(""), &out); err != nil || out.Len() != 0 {
+		t.Errorf("Expected an earlier acceptance to pass silently, got %v and %q", err, out.String())
+	}
+}
@@ -0,0 +1,305 @@
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// Takedown errors
+var (
+	ErrTakedownNotFound = errors.New("no takedown recorded for this descriptor")
+	ErrAlreadyTakenDown = errors.New("descriptor is already taken down")
+)
+
+// Takedown statuses
+const (
+	TakedownStatusActive     = "active"
+	TakedownStatusReinstated = "reinstated"
+)
+
+// TakedownNotice is a takedown or abuse notice an operator received for a
+// descriptor
+type TakedownNotice struct {
+	DescriptorCID  string `json:"descriptor_cid"`
+	RequestorName  string `json:"requestor_name"`
+	RequestorEmail string `json:"requestor_email,omitempty"`
+	Work           string `json:"work,omitempty"`        // The work or content the notice concerns
+	LegalBasis     string `json:"legal_basis,omitempty"` // Such as "DMCA 512(c)"; defaults to "unspecified"
+	Notice         string `json:"notice,omitempty"`      // Original notice text, kept for the record
+	Notes          string `json:"notes,omitempty"`
+}
+
+// Validate checks that a notice identifies a descriptor and who sent it
+func (n *TakedownNotice) Validate() error {
+	if strings.TrimSpace(n.DescriptorCID) == "" {
+		return errors.New("descriptor CID is required")
+	}
+	if strings.TrimSpace(n.RequestorName) == "" {
+		return errors.New("requestor name is required")
+	}
+	return nil
+}
+
+// TakedownStore is the local compliance store: a ComplianceDatabase kept in
+// a file. Every call re-reads the file, so takedowns recorded with the CLI
+// are enforced by a running web UI.
+type TakedownStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultTakedownStorePath returns ~/.noisefs/compliance.json
+func DefaultTakedownStorePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "compliance.json"), nil
+}
+
+// NewTakedownStore returns the store kept at path
+func NewTakedownStore(path string) *TakedownStore {
+	return &TakedownStore{path: path}
+}
+
+// Record records a notice and blocklists its descriptor
+func (s *TakedownStore) Record(notice *TakedownNotice) (*TakedownRecord, error) {
+	if err := notice.Validate(); err != nil {
+		return nil, err
+	}
+	legalBasis := strings.TrimSpace(notice.LegalBasis)
+	if legalBasis == "" {
+		legalBasis = "unspecified"
+	}
+	record := &TakedownRecord{
+		DescriptorCID:   strings.TrimSpace(notice.DescriptorCID),
+		RequestorName:   strings.TrimSpace(notice.RequestorName),
+		RequestorEmail:  strings.TrimSpace(notice.RequestorEmail),
+		CopyrightWork:   notice.Work,
+		TakedownDate:    time.Now().UTC(),
+		Status:          TakedownStatusActive,
+		OriginalNotice:  notice.Notice,
+		LegalBasis:      legalBasis,
+		ProcessingNotes: notice.Notes,
+	}
+	if notice.Notice != "" {
+		hash := sha256.Sum256([]byte(notice.Notice))
+		record.DMCANoticeHash = hex.EncodeToString(hash[:])
+	}
+
+	err := s.update(func(db *ComplianceDatabase) error {
+		if db.IsDescriptorBlacklisted(record.DescriptorCID) {
+			return ErrAlreadyTakenDown
+		}
+		return db.AddTakedownRecord(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Reinstate lifts the takedown of a descriptor
+func (s *TakedownStore) Reinstate(descriptorCID string, reason string) error {
+	return s.update(func(db *ComplianceDatabase) error {
+		record, ok := db.GetTakedownRecord(descriptorCID)
+		if !ok || record.Status == TakedownStatusReinstated {
+			return ErrTakedownNotFound
+		}
+		return db.ReinstateDescriptor(descriptorCID, reason)
+	})
+}
+
+// IsBlocked reports whether a descriptor has an active takedown
+func (s *TakedownStore) IsBlocked(descriptorCID string) (bool, error) {
+	db, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	return db.IsDescriptorBlacklisted(descriptorCID), nil
+}
+
+// List returns every takedown, oldest first
+func (s *TakedownStore) List() ([]*TakedownRecord, error) {
+	db, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*TakedownRecord, 0, len(db.BlacklistedDescriptors))
+	for _, record := range db.BlacklistedDescriptors {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].TakedownDate.Before(records[j].TakedownDate)
+	})
+	return records, nil
+}
+
+// TransparencyReport summarizes the takedowns of a period for publication.
+// Unlike ComplianceReport it leaves out requestors' contact details and
+// notice texts.
+type TransparencyReport struct {
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	GeneratedAt  time.Time           `json:"generated_at"`
+	Notices      int                 `json:"notices"`    // Takedowns recorded in the period
+	Reinstated   int                 `json:"reinstated"` // Takedowns lifted in the period
+	Active       int                 `json:"active"`     // Takedowns in force at the end of the period
+	ByLegalBasis map[string]int      `json:"by_legal_basis"`
+	Takedowns    []TransparencyEntry `json:"takedowns"`
+}
+
+// TransparencyEntry is one takedown in a transparency report
+type TransparencyEntry struct {
+	ID            string     `json:"id"`
+	DescriptorCID string     `json:"descriptor_cid"`
+	Date          time.Time  `json:"date"`
+	Requestor     string     `json:"requestor"`
+	Work          string     `json:"work,omitempty"`
+	LegalBasis    string     `json:"legal_basis"`
+	Status        string     `json:"status"`
+	Reinstated    *time.Time `json:"reinstated,omitempty"`
+}
+
+// TransparencyReport reports the takedowns recorded from from up to, but
+// not including, to
+func (s *TakedownStore) TransparencyReport(from, to time.Time) (*TransparencyReport, error) {
+	if !to.After(from) {
+		return nil, errors.New("report period must end after it starts")
+	}
+	records, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	inPeriod := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	report := &TransparencyReport{
+		From:         from,
+		To:           to,
+		GeneratedAt:  time.Now().UTC(),
+		ByLegalBasis: make(map[string]int),
+		Takedowns:    make([]TransparencyEntry, 0),
+	}
+	for _, record := range records {
+		reinstated := record.ReinstatementDate
+		if reinstated != nil && inPeriod(*reinstated) {
+			report.Reinstated++
+		}
+		if record.TakedownDate.Before(to) && (reinstated == nil || !reinstated.Before(to)) {
+			report.Active++
+		}
+		if !inPeriod(record.TakedownDate) {
+			continue
+		}
+		report.Notices++
+		report.ByLegalBasis[record.LegalBasis]++
+		report.Takedowns = append(report.Takedowns, TransparencyEntry{
+			ID:            record.TakedownID,
+			DescriptorCID: record.DescriptorCID,
+			Date:          record.TakedownDate,
+			Requestor:     record.RequestorName,
+			Work:          record.CopyrightWork,
+			LegalBasis:    record.LegalBasis,
+			Status:        record.Status,
+			Reinstated:    reinstated,
+		})
+	}
+	return report, nil
+}
+
+// ReportPeriod turns inclusive YYYY-MM-DD days into the half-open period
+// TransparencyReport takes. An empty from means the start of now's year and
+// an empty to means now's day.
+func ReportPeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	start := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var err error
+	if from != "" {
+		if start, err = time.ParseInLocation("2006-01-02", from, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: %w", from, err)
+		}
+	}
+	if to != "" {
+		if end, err = time.ParseInLocation("2006-01-02", to, now.Location()); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: %w", to, err)
+		}
+	}
+	end = end.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("report period ends before it starts")
+	}
+	return start, end, nil
+}
+
+func (s *TakedownStore) load() (*ComplianceDatabase, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db := NewComplianceDatabase()
+	if err := db.LoadFromFile(s.path); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// update applies fn to the database and saves it if fn succeeds
+func (s *TakedownStore) update(fn func(db *ComplianceDatabase) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db := NewComplianceDatabase()
+	if err := db.LoadFromFile(s.path); err != nil {
+		return err
+	}
+	if err := fn(db); err != nil {
+		return err
+	}
+	return db.SaveToFile(s.path)
+}
+
+// BlockStore reads and unpins blocks; *storage.Manager satisfies it
+type BlockStore interface {
+	Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error)
+	Unpin(ctx context.Context, address *storage.BlockAddress) error
+}
+
+// UnpinDescriptor unpins a taken-down descriptor and, when it is a plain
+// file descriptor, its data blocks, returning the CIDs unpinned. Randomizers
+// stay pinned because other files reuse them, and the blocks of encrypted
+// descriptors can't be listed. The node's garbage collection then removes
+// the unpinned blocks.
+func UnpinDescriptor(ctx context.Context, store BlockStore, descriptorCID string) ([]string, error) {
+	cids := []string{descriptorCID}
+	if block, err := store.Get(ctx, &storage.BlockAddress{ID: descriptorCID}); err == nil {
+		if descriptor, err := descriptors.FromJSON(block.Data); err == nil {
+			for _, triple := range descriptor.Blocks {
+				cids = append(cids, triple.DataCID)
+			}
+		}
+	}
+
+	unpinned := make([]string, 0, len(cids))
+	var failures []string
+	for _, cid := range cids {
+		if err := store.Unpin(ctx, &storage.BlockAddress{ID: cid}); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", cid, err))
+			continue
+		}
+		unpinned = append(unpinned, cid)
+	}
+	if len(failures) > 0 {
+		return unpinned, fmt.Errorf("failed to unpin %d blocks: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return unpinned, nil
+}
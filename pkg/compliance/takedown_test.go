@@ -0,0 +1,184 @@
+package compliance
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+func TestTakedownStoreRecordAndReinstate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compliance.json")
+	store := NewTakedownStore(path)
+
+	if _, err := store.Record(&TakedownNotice{DescriptorCID: "QmDesc"}); err == nil {
+		t.Error("Expected a notice without a requestor to be rejected")
+	}
+
+	record, err := store.Record(&TakedownNotice{
+		DescriptorCID:  "QmDesc",
+		RequestorName:  "Example Studios",
+		RequestorEmail: "legal@example.com",
+		Work:           "Example Film",
+		LegalBasis:     "DMCA 512(c)",
+		Notice:         "Please remove QmDesc",
+	})
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if record.TakedownID == "" || record.DMCANoticeHash == "" || record.Status != TakedownStatusActive {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if _, err := store.Record(&TakedownNotice{DescriptorCID: "QmDesc", RequestorName: "Someone"}); !errors.Is(err, ErrAlreadyTakenDown) {
+		t.Errorf("Expected a second takedown to fail with ErrAlreadyTakenDown, got %v", err)
+	}
+
+	// A second store on the same file sees the takedown, as the web UI does
+	// for takedowns recorded with the CLI
+	other := NewTakedownStore(path)
+	if blocked, err := other.IsBlocked("QmDesc"); err != nil || !blocked {
+		t.Fatalf("Expected QmDesc to be blocked, got %v, %v", blocked, err)
+	}
+	if blocked, _ := other.IsBlocked("QmOther"); blocked {
+		t.Error("Expected QmOther not to be blocked")
+	}
+
+	if err := other.Reinstate("QmDesc", "counter-notice accepted"); err != nil {
+		t.Fatalf("Reinstate failed: %v", err)
+	}
+	if blocked, _ := store.IsBlocked("QmDesc"); blocked {
+		t.Error("Expected QmDesc to be unblocked after reinstatement")
+	}
+	if err := store.Reinstate("QmDesc", "again"); !errors.Is(err, ErrTakedownNotFound) {
+		t.Errorf("Expected reinstating twice to fail with ErrTakedownNotFound, got %v", err)
+	}
+	if err := store.Reinstate("QmMissing", ""); !errors.Is(err, ErrTakedownNotFound) {
+		t.Errorf("Expected ErrTakedownNotFound, got %v", err)
+	}
+
+	records, err := store.List()
+	if err != nil || len(records) != 1 || records[0].Status != TakedownStatusReinstated {
+		t.Errorf("Expected one reinstated takedown, got %v, %v", records, err)
+	}
+}
+
+func TestTransparencyReport(t *testing.T) {
+	store := NewTakedownStore(filepath.Join(t.TempDir(), "compliance.json"))
+	for _, cid := range []string{"QmA", "QmB", "QmC"} {
+		if _, err := store.Record(&TakedownNotice{DescriptorCID: cid, RequestorName: "Rights Holder", RequestorEmail: "private@example.com", LegalBasis: "DMCA 512(c)"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Record(&TakedownNotice{DescriptorCID: "QmD", RequestorName: "Police"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reinstate("QmB", "counter-notice"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	report, err := store.TransparencyReport(now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Notices != 4 || report.Reinstated != 1 || report.Active != 3 {
+		t.Errorf("Expected 4 notices, 1 reinstated and 3 active, got %d, %d and %d", report.Notices, report.Reinstated, report.Active)
+	}
+	if report.ByLegalBasis["DMCA 512(c)"] != 3 || report.ByLegalBasis["unspecified"] != 1 {
+		t.Errorf("Unexpected legal basis counts: %v", report.ByLegalBasis)
+	}
+	for _, entry := range report.Takedowns {
+		if strings.Contains(entry.Requestor, "@") {
+			t.Errorf("Transparency report leaks a requestor email: %+v", entry)
+		}
+	}
+
+	earlier, err := store.TransparencyReport(now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if earlier.Notices != 0 || earlier.Active != 0 || len(earlier.Takedowns) != 0 {
+		t.Errorf("Expected an empty report for an earlier period, got %+v", earlier)
+	}
+	if _, err := store.TransparencyReport(now, now); err == nil {
+		t.Error("Expected an empty period to be rejected")
+	}
+}
+
+// fakeBlockStore records unpinned blocks
+type fakeBlockStore struct {
+	blocks   map[string][]byte
+	unpinned []string
+}
+
+func (f *fakeBlockStore) Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	data, ok := f.blocks[address.ID]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return blocks.NewBlock(data)
+}
+
+func (f *fakeBlockStore) Unpin(ctx context.Context, address *storage.BlockAddress) error {
+	f.unpinned = append(f.unpinned, address.ID)
+	return nil
+}
+
+func TestUnpinDescriptor(t *testing.T) {
+	descriptor := descriptors.NewDescriptor("file.txt", 100, 256, 128)
+	descriptor.AddBlockTriple("QmData1", "QmRand1", "QmRand2")
+	descriptor.AddBlockTriple("QmData2", "QmRand2", "QmRand3")
+	data, err := descriptor.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &fakeBlockStore{blocks: map[string][]byte{"QmDesc": data}}
+	unpinned, err := UnpinDescriptor(context.Background(), store, "QmDesc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(unpinned)
+	if strings.Join(unpinned, ",") != "QmData1,QmData2,QmDesc" {
+		t.Errorf("Expected the descriptor and its data blocks but no randomizers unpinned, got %v", unpinned)
+	}
+
+	// A descriptor that can't be read is still unpinned itself
+	store = &fakeBlockStore{blocks: map[string][]byte{}}
+	unpinned, err = UnpinDescriptor(context.Background(), store, "QmEncrypted")
+	if err != nil || len(unpinned) != 1 || unpinned[0] != "QmEncrypted" {
+		t.Errorf("Expected only the descriptor unpinned, got %v, %v", unpinned, err)
+	}
+}
+
+func TestReportPeriod(t *testing.T) {
+	now := time.Date(2025, 6, 15, 13, 0, 0, 0, time.UTC)
+	start, end, err := ReportPeriod("", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the year to date, got %s to %s", start, end)
+	}
+
+	start, end, err = ReportPeriod("2025-03-01", "2025-03-01", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end.Sub(start) != 24*time.Hour {
+		t.Errorf("Expected a one-day period, got %s to %s", start, end)
+	}
+
+	for _, period := range [][2]string{{"2025-03-02", "2025-03-01"}, {"March", ""}, {"", "2025-13-01"}} {
+		if _, _, err := ReportPeriod(period[0], period[1], now); err == nil {
+			t.Errorf("Expected %v to be rejected", period)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package anonymize runs the NoiseFS anonymization pipeline without any
+// storage: it splits content into padded blocks, XORs each with two
+// randomizers and, once the blocks have been stored, builds the file's
+// descriptor. Browsers run it through the js/wasm build in cmd/noisefs-wasm
+// so that only anonymized blocks leave them.
+package anonymize
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+)
+
+// Randomizer is a block XORed with file blocks
+type Randomizer struct {
+	CID  string `json:"cid,omitempty"` // Empty for a randomizer made by Anonymize, which must be stored
+	Data []byte `json:"-"`
+}
+
+// Tuple is one anonymized file block and the randomizers it was XORed with
+type Tuple struct {
+	Data        []byte `json:"-"`           // File block XOR both randomizers
+	Randomizers [2]int `json:"randomizers"` // Indexes into File.Randomizers
+}
+
+// File is anonymized content ready to be stored
+type File struct {
+	Filename    string       `json:"filename"`
+	FileSize    int64        `json:"file_size"`
+	BlockSize   int          `json:"block_size"`
+	Tuples      []Tuple      `json:"tuples"`
+	Randomizers []Randomizer `json:"randomizers"` // Pool randomizers used, then new ones
+}
+
+// Anonymize splits content into blocks of blockSize and XORs each with two
+// randomizers, as the client does: two different randomizers are picked at
+// random from the stored ones of the right size in pool, and when there are
+// fewer than two, new ones are made and reused for later blocks.
+func Anonymize(content []byte, filename string, blockSize int, pool []Randomizer) (*File, error) {
+	if filename == "" {
+		return nil, errors.New("filename cannot be empty")
+	}
+	if err := blocks.ValidateBlockSize(blockSize); err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, errors.New("content cannot be empty")
+	}
+
+	// Only stored randomizers of the block size can be reused
+	var candidates []Randomizer
+	seen := make(map[string]bool)
+	for _, r := range pool {
+		if r.CID != "" && len(r.Data) == blockSize && !seen[r.CID] {
+			seen[r.CID] = true
+			candidates = append(candidates, r)
+		}
+	}
+
+	file := &File{Filename: filename, FileSize: int64(len(content)), BlockSize: blockSize}
+	used := make(map[int]int) // candidates index -> File.Randomizers index
+	pick := func(i int) int {
+		if index, ok := used[i]; ok {
+			return index
+		}
+		file.Randomizers = append(file.Randomizers, candidates[i])
+		used[i] = len(file.Randomizers) - 1
+		return used[i]
+	}
+
+	splitter, err := blocks.NewSplitter(blockSize)
+	if err != nil {
+		return nil, err
+	}
+	fileBlocks, err := splitter.SplitBytes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, block := range fileBlocks {
+		for len(candidates) < 2 {
+			randomizer, err := blocks.NewRandomBlock(blockSize)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, Randomizer{Data: randomizer.Data})
+		}
+
+		a, err := randomIndex(len(candidates))
+		if err != nil {
+			return nil, err
+		}
+		b, err := randomIndex(len(candidates) - 1)
+		if err != nil {
+			return nil, err
+		}
+		if b >= a {
+			b++
+		}
+		pair := [2]int{pick(a), pick(b)}
+
+		data := make([]byte, blockSize)
+		blocks.XOR3(data, block.Data, file.Randomizers[pair[0]].Data, file.Randomizers[pair[1]].Data)
+		file.Tuples = append(file.Tuples, Tuple{Data: data, Randomizers: pair})
+	}
+
+	return file, nil
+}
+
+// Descriptor returns the file's descriptor. dataCIDs holds the CID each
+// tuple's data block was stored under, in order, and randomizerCIDs the
+// CIDs of the randomizers; entries for pool randomizers may be empty, as
+// their CID is already known.
+func (f *File) Descriptor(dataCIDs, randomizerCIDs []string) (*descriptors.Descriptor, error) {
+	if len(dataCIDs) != len(f.Tuples) {
+		return nil, fmt.Errorf("got %d data block CIDs for %d blocks", len(dataCIDs), len(f.Tuples))
+	}
+	if len(randomizerCIDs) > len(f.Randomizers) {
+		return nil, fmt.Errorf("got %d randomizer CIDs for %d randomizers", len(randomizerCIDs), len(f.Randomizers))
+	}
+
+	cids := make([]string, len(f.Randomizers))
+	for i, r := range f.Randomizers {
+		cids[i] = r.CID
+		if i < len(randomizerCIDs) && randomizerCIDs[i] != "" {
+			cids[i] = randomizerCIDs[i]
+		}
+		if cids[i] == "" {
+			return nil, fmt.Errorf("randomizer %d has no CID; store it first", i)
+		}
+	}
+
+	descriptor := descriptors.NewDescriptor(f.Filename, f.FileSize, int64(len(f.Tuples)*f.BlockSize), f.BlockSize)
+	for i, tuple := range f.Tuples {
+		if tuple.Randomizers[0] >= len(cids) || tuple.Randomizers[1] >= len(cids) || tuple.Randomizers[0] < 0 || tuple.Randomizers[1] < 0 {
+			return nil, fmt.Errorf("block %d refers to a missing randomizer", i)
+		}
+		if err := descriptor.AddBlockTriple(dataCIDs[i], cids[tuple.Randomizers[0]], cids[tuple.Randomizers[1]]); err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+	}
+	return descriptor, nil
+}
+
+// Reconstruct XORs a stored data block with its two randomizers, giving
+// back the file block
+func Reconstruct(data, randomizer1, randomizer2 []byte) ([]byte, error) {
+	if len(data) != len(randomizer1) || len(data) != len(randomizer2) {
+		return nil, errors.New("data block and randomizers must have the same size")
+	}
+	block := make([]byte, len(data))
+	blocks.XOR3(block, data, randomizer1, randomizer2)
+	return block, nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to pick a randomizer: %w", err)
+	}
+	return int(i.Int64()), nil
+}
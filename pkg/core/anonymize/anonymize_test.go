@@ -0,0 +1,166 @@
+package anonymize
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+const testBlockSize = blocks.MinBlockSize
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// reassemble reverses Anonymize using the file's own randomizers
+func reassemble(t *testing.T, file *File) []byte {
+	t.Helper()
+	var out []byte
+	for i, tuple := range file.Tuples {
+		block, err := Reconstruct(tuple.Data, file.Randomizers[tuple.Randomizers[0]].Data, file.Randomizers[tuple.Randomizers[1]].Data)
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		out = append(out, block...)
+	}
+	return out[:file.FileSize]
+}
+
+func TestAnonymizeRoundTrip(t *testing.T) {
+	content := randomBytes(t, 3*testBlockSize+100)
+
+	file, err := Anonymize(content, "data.bin", testBlockSize, nil)
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	if len(file.Tuples) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(file.Tuples))
+	}
+	for i, tuple := range file.Tuples {
+		if len(tuple.Data) != testBlockSize {
+			t.Errorf("block %d is %d bytes, want %d", i, len(tuple.Data), testBlockSize)
+		}
+		if bytes.Contains(content, tuple.Data[:64]) {
+			t.Errorf("block %d was not anonymized", i)
+		}
+	}
+	for i, r := range file.Randomizers {
+		if r.CID != "" {
+			t.Errorf("randomizer %d has a CID without a pool", i)
+		}
+	}
+	if !bytes.Equal(reassemble(t, file), content) {
+		t.Error("reconstructed content differs")
+	}
+}
+
+func TestAnonymizeReusesPool(t *testing.T) {
+	var pool []Randomizer
+	for i := 0; i < 4; i++ {
+		pool = append(pool, Randomizer{CID: fmt.Sprintf("pool-%d", i), Data: randomBytes(t, testBlockSize)})
+	}
+	// Randomizers of another size can't be used
+	pool = append(pool, Randomizer{CID: "small", Data: randomBytes(t, testBlockSize/2)})
+
+	content := randomBytes(t, 20*testBlockSize)
+	file, err := Anonymize(content, "data.bin", testBlockSize, pool)
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+
+	if len(file.Randomizers) == 0 || len(file.Randomizers) > 4 {
+		t.Errorf("used %d randomizers, want only pool ones", len(file.Randomizers))
+	}
+	for i, r := range file.Randomizers {
+		if r.CID == "" || r.CID == "small" {
+			t.Errorf("randomizer %d (%q) is not a usable pool randomizer", i, r.CID)
+		}
+	}
+	for i, tuple := range file.Tuples {
+		if tuple.Randomizers[0] == tuple.Randomizers[1] {
+			t.Errorf("block %d uses one randomizer twice", i)
+		}
+	}
+	if !bytes.Equal(reassemble(t, file), content) {
+		t.Error("reconstructed content differs")
+	}
+}
+
+func TestDescriptor(t *testing.T) {
+	content := randomBytes(t, 2*testBlockSize)
+	file, err := Anonymize(content, "data.bin", testBlockSize, nil)
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+
+	dataCIDs := make([]string, len(file.Tuples))
+	for i := range dataCIDs {
+		dataCIDs[i] = fmt.Sprintf("data-%d", i)
+	}
+	randomizerCIDs := make([]string, len(file.Randomizers))
+	for i := range randomizerCIDs {
+		randomizerCIDs[i] = fmt.Sprintf("new-%d", i)
+	}
+
+	if _, err := file.Descriptor(dataCIDs, nil); err == nil {
+		t.Error("Descriptor accepted new randomizers without CIDs")
+	}
+	if _, err := file.Descriptor(dataCIDs[:1], randomizerCIDs); err == nil {
+		t.Error("Descriptor accepted too few data CIDs")
+	}
+
+	descriptor, err := file.Descriptor(dataCIDs, randomizerCIDs)
+	if err != nil {
+		t.Fatalf("Descriptor failed: %v", err)
+	}
+	if err := descriptor.Validate(); err != nil {
+		t.Fatalf("invalid descriptor: %v", err)
+	}
+	if descriptor.Filename != "data.bin" || descriptor.FileSize != int64(len(content)) || descriptor.PaddedFileSize != int64(2*testBlockSize) {
+		t.Errorf("unexpected descriptor %+v", descriptor)
+	}
+	for i, pair := range descriptor.Blocks {
+		if pair.DataCID != dataCIDs[i] || pair.RandomizerCID1 == "" || pair.RandomizerCID2 == "" {
+			t.Errorf("block %d: unexpected triple %+v", i, pair)
+		}
+	}
+}
+
+func TestDescriptorKeepsPoolCIDs(t *testing.T) {
+	pool := []Randomizer{
+		{CID: "pool-a", Data: randomBytes(t, testBlockSize)},
+		{CID: "pool-b", Data: randomBytes(t, testBlockSize)},
+	}
+	file, err := Anonymize(randomBytes(t, testBlockSize), "data.bin", testBlockSize, pool)
+	if err != nil {
+		t.Fatalf("Anonymize failed: %v", err)
+	}
+	descriptor, err := file.Descriptor([]string{"data-0"}, nil)
+	if err != nil {
+		t.Fatalf("Descriptor failed: %v", err)
+	}
+	pair := descriptor.Blocks[0]
+	if !(pair.RandomizerCID1 == "pool-a" && pair.RandomizerCID2 == "pool-b") && !(pair.RandomizerCID1 == "pool-b" && pair.RandomizerCID2 == "pool-a") {
+		t.Errorf("unexpected randomizers %+v", pair)
+	}
+}
+
+func TestAnonymizeRejectsBadInput(t *testing.T) {
+	if _, err := Anonymize(nil, "empty", testBlockSize, nil); err == nil {
+		t.Error("empty content was accepted")
+	}
+	if _, err := Anonymize([]byte("x"), "", testBlockSize, nil); err == nil {
+		t.Error("empty filename was accepted")
+	}
+	if _, err := Anonymize([]byte("x"), "x", testBlockSize+1, nil); err == nil {
+		t.Error("invalid block size was accepted")
+	}
+}
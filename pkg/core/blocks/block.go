@@ -75,9 +75,7 @@ func (b *Block) XOR(randomizer1, randomizer2 *Block) (*Block, error) {
 	}
 
 	result := make([]byte, len(b.Data))
-	for i := range b.Data {
-		result[i] = b.Data[i] ^ randomizer1.Data[i] ^ randomizer2.Data[i]
-	}
+	XOR3(result, b.Data, randomizer1.Data, randomizer2.Data)
 
 	return NewBlock(result)
 }
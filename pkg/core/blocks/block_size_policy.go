@@ -0,0 +1,121 @@
+package blocks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SmallFileBlockSize is the block size the default policy uses for small files (32 KiB)
+	SmallFileBlockSize = 32 * 1024
+
+	// MediaBlockSize is the block size the default policy uses for audio and video (256 KiB)
+	MediaBlockSize = 256 * 1024
+
+	// DefaultSmallFileThreshold is the largest file the default policy treats as small
+	DefaultSmallFileThreshold = 256 * 1024
+
+	// MinBlockSize and MaxBlockSize bound the block sizes a policy may choose
+	MinBlockSize = 4 * 1024
+	MaxBlockSize = 4 * 1024 * 1024
+)
+
+// DefaultMediaExtensions are the audio and video types the default policy
+// stores in larger blocks
+var DefaultMediaExtensions = []string{
+	".mp4", ".mkv", ".avi", ".mov", ".webm", ".m4v", ".mpg", ".mpeg",
+	".mp3", ".flac", ".wav", ".ogg", ".m4a", ".aac", ".opus",
+}
+
+// BlockSizePolicy chooses the block size for a file. The chosen size is
+// recorded in the file's descriptor, so files uploaded with different
+// sizes can be downloaded by any client. Randomizers are only shared
+// between blocks of the same size, so a policy should use few sizes.
+type BlockSizePolicy interface {
+	// BlockSize returns the block size for a file; size is -1 if unknown
+	BlockSize(filename string, size int64) int
+}
+
+// FixedBlockSize uses the same block size for every file
+type FixedBlockSize int
+
+// BlockSize returns the fixed size
+func (f FixedBlockSize) BlockSize(filename string, size int64) int {
+	return int(f)
+}
+
+// FileTypeBlockSizePolicy uses small blocks for small files, so they waste
+// less padding, and large blocks for media, so large files need fewer
+// blocks and descriptor entries. Everything else uses the default size.
+type FileTypeBlockSizePolicy struct {
+	DefaultSize        int
+	SmallFileSize      int
+	SmallFileThreshold int64 // Files up to this size use SmallFileSize
+	MediaSize          int
+	mediaExtensions    map[string]bool
+}
+
+// NewFileTypeBlockSizePolicy creates a policy, validating its sizes.
+// Extensions are matched case-insensitively, with or without the dot.
+func NewFileTypeBlockSizePolicy(defaultSize, smallFileSize int, smallFileThreshold int64, mediaSize int, mediaExtensions []string) (*FileTypeBlockSizePolicy, error) {
+	for name, size := range map[string]int{"default": defaultSize, "small file": smallFileSize, "media": mediaSize} {
+		if err := ValidateBlockSize(size); err != nil {
+			return nil, fmt.Errorf("invalid %s block size: %w", name, err)
+		}
+	}
+	if smallFileThreshold < 0 {
+		return nil, fmt.Errorf("small file threshold cannot be negative")
+	}
+
+	extensions := make(map[string]bool, len(mediaExtensions))
+	for _, ext := range mediaExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions[ext] = true
+	}
+
+	return &FileTypeBlockSizePolicy{
+		DefaultSize:        defaultSize,
+		SmallFileSize:      smallFileSize,
+		SmallFileThreshold: smallFileThreshold,
+		MediaSize:          mediaSize,
+		mediaExtensions:    extensions,
+	}, nil
+}
+
+// DefaultBlockSizePolicy returns the policy with 32 KiB blocks for files up
+// to 256 KiB, 256 KiB blocks for audio and video and 128 KiB otherwise
+func DefaultBlockSizePolicy() *FileTypeBlockSizePolicy {
+	policy, _ := NewFileTypeBlockSizePolicy(DefaultBlockSize, SmallFileBlockSize, DefaultSmallFileThreshold, MediaBlockSize, DefaultMediaExtensions)
+	return policy
+}
+
+// BlockSize picks the block size for a file. Size takes precedence over
+// type, so a short audio clip still uses small blocks.
+func (p *FileTypeBlockSizePolicy) BlockSize(filename string, size int64) int {
+	if size >= 0 && size <= p.SmallFileThreshold {
+		return p.SmallFileSize
+	}
+	if p.mediaExtensions[strings.ToLower(filepath.Ext(filename))] {
+		return p.MediaSize
+	}
+	return p.DefaultSize
+}
+
+// ValidateBlockSize checks that a block size is a power of two within
+// MinBlockSize and MaxBlockSize
+func ValidateBlockSize(size int) error {
+	if size < MinBlockSize || size > MaxBlockSize {
+		return fmt.Errorf("block size %d is outside %d-%d bytes", size, MinBlockSize, MaxBlockSize)
+	}
+	if size&(size-1) != 0 {
+		return fmt.Errorf("block size %d is not a power of two", size)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package blocks
+
+import "testing"
+
+func TestFileTypeBlockSizePolicy(t *testing.T) {
+	policy, err := NewFileTypeBlockSizePolicy(DefaultBlockSize, SmallFileBlockSize, 1024*1024, MediaBlockSize, []string{"MKV", ".mp4", ""})
+	if err != nil {
+		t.Fatalf("NewFileTypeBlockSizePolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		filename string
+		size     int64
+		want     int
+	}{
+		{"notes.txt", 100, SmallFileBlockSize},
+		{"short.mp4", 1024 * 1024, SmallFileBlockSize},
+		{"film.MKV", 50 * 1024 * 1024, MediaBlockSize},
+		{"film.mp4", -1, MediaBlockSize},
+		{"backup.tar", 50 * 1024 * 1024, DefaultBlockSize},
+		{"stream", -1, DefaultBlockSize},
+	}
+	for _, tt := range tests {
+		if got := policy.BlockSize(tt.filename, tt.size); got != tt.want {
+			t.Errorf("BlockSize(%q, %d) = %d, want %d", tt.filename, tt.size, got, tt.want)
+		}
+	}
+
+	if got := FixedBlockSize(65536).BlockSize("film.mp4", 100); got != 65536 {
+		t.Errorf("FixedBlockSize = %d, want 65536", got)
+	}
+}
+
+func TestValidateBlockSize(t *testing.T) {
+	for _, size := range []int{MinBlockSize, SmallFileBlockSize, DefaultBlockSize, MaxBlockSize} {
+		if err := ValidateBlockSize(size); err != nil {
+			t.Errorf("ValidateBlockSize(%d) = %v, want nil", size, err)
+		}
+	}
+	for _, size := range []int{0, 1024, 100000, 2 * MaxBlockSize} {
+		if err := ValidateBlockSize(size); err == nil {
+			t.Errorf("ValidateBlockSize(%d) succeeded, want error", size)
+		}
+	}
+	if _, err := NewFileTypeBlockSizePolicy(DefaultBlockSize, 1000, 0, MediaBlockSize, nil); err == nil {
+		t.Error("Expected an invalid small file size to be rejected")
+	}
+}
@@ -23,6 +23,7 @@ type DirectoryProcessor struct {
 	encryptionKey *crypto.EncryptionKey
 	progressFn    DirectoryProgressCallback
 	errorHandler  DirectoryErrorHandler
+	fileFilter    DirectoryFileFilter
 	cancelFunc    context.CancelFunc
 	ctx           context.Context
 
@@ -327,6 +328,9 @@ type DirectoryProgressCallback func(processed, total int64, currentFile string)
 // DirectoryErrorHandler handles errors during directory processing
 type DirectoryErrorHandler func(path string, err error) bool // Return true to continue, false to stop
 
+// DirectoryFileFilter decides whether a file is processed. Return false to skip it.
+type DirectoryFileFilter func(path string) bool
+
 // ProcessorConfig holds configuration for the directory processor
 type ProcessorConfig struct {
 	BlockSize         int
@@ -339,6 +343,7 @@ type ProcessorConfig struct {
 	MaxFileSize       int64
 	AllowedExtensions []string
 	BlockedExtensions []string
+	FileFilter        DirectoryFileFilter
 }
 
 // NewDirectoryProcessor creates a new directory processor
@@ -373,6 +378,7 @@ func NewDirectoryProcessor(config *ProcessorConfig) (*DirectoryProcessor, error)
 		encryptionKey: config.EncryptionKey,
 		progressFn:    config.ProgressCallback,
 		errorHandler:  config.ErrorHandler,
+		fileFilter:    config.FileFilter,
 		cancelFunc:    cancel,
 		ctx:           ctx,
 		workerPool:    make(chan struct{}, config.MaxWorkers),
@@ -470,6 +476,11 @@ func (dp *DirectoryProcessor) processDirectoryRecursive(dirPath string, processo
 				}
 			}
 		} else {
+			// Skip files rejected by the filter
+			if dp.fileFilter != nil && !dp.fileFilter(entryPath) {
+				continue
+			}
+
 			// Process file
 			if err := dp.processFileEntry(entryPath, entry, manifest, processor); err != nil {
 				if !dp.handleError(entryPath, err) {
@@ -0,0 +1,25 @@
+package blocks
+
+import "crypto/subtle"
+
+// XOR3 sets dst to a XOR b XOR c, the 3-tuple operation applied to every
+// block on upload and download. All four slices must have the same length;
+// dst may alias any of the inputs. On amd64 with AVX2 the three inputs are
+// combined in one vectorized pass, elsewhere the standard library's
+// vectorized XOR (SSE2, NEON and others) is used twice.
+func XOR3(dst, a, b, c []byte) {
+	n := len(dst)
+	if len(a) != n || len(b) != n || len(c) != n {
+		panic("blocks: XOR3 length mismatch")
+	}
+	if n == 0 {
+		return
+	}
+	xor3(dst, a, b, c)
+}
+
+// xor3Generic combines the inputs with two vectorized passes
+func xor3Generic(dst, a, b, c []byte) {
+	subtle.XORBytes(dst, a, b)
+	subtle.XORBytes(dst, dst, c)
+}
@@ -0,0 +1,25 @@
+//go:build !purego
+
+package blocks
+
+import "golang.org/x/sys/cpu"
+
+// useAVX2 is detected once at startup; without AVX2 the generic path is used
+var useAVX2 = cpu.X86.HasAVX2
+
+//go:noescape
+func xor3AVX2(dst, a, b, c *byte, n int)
+
+func xor3(dst, a, b, c []byte) {
+	if !useAVX2 || len(dst) < 32 {
+		xor3Generic(dst, a, b, c)
+		return
+	}
+
+	// The assembly handles whole 32-byte words; the remainder is small
+	n := len(dst) &^ 31
+	xor3AVX2(&dst[0], &a[0], &b[0], &c[0], n)
+	if n < len(dst) {
+		xor3Generic(dst[n:], a[n:], b[n:], c[n:])
+	}
+}
@@ -0,0 +1,56 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func xor3AVX2(dst, a, b, c *byte, n int)
+// n must be a positive multiple of 32
+TEXT ·xor3AVX2(SB), NOSPLIT, $0-40
+	MOVQ dst+0(FP), DI
+	MOVQ a+8(FP), SI
+	MOVQ b+16(FP), BX
+	MOVQ c+24(FP), DX
+	MOVQ n+32(FP), CX
+
+loop128:
+	CMPQ CX, $128
+	JB   loop32
+	VMOVDQU 0(SI), Y0
+	VMOVDQU 32(SI), Y1
+	VMOVDQU 64(SI), Y2
+	VMOVDQU 96(SI), Y3
+	VPXOR   0(BX), Y0, Y0
+	VPXOR   32(BX), Y1, Y1
+	VPXOR   64(BX), Y2, Y2
+	VPXOR   96(BX), Y3, Y3
+	VPXOR   0(DX), Y0, Y0
+	VPXOR   32(DX), Y1, Y1
+	VPXOR   64(DX), Y2, Y2
+	VPXOR   96(DX), Y3, Y3
+	VMOVDQU Y0, 0(DI)
+	VMOVDQU Y1, 32(DI)
+	VMOVDQU Y2, 64(DI)
+	VMOVDQU Y3, 96(DI)
+	ADDQ    $128, SI
+	ADDQ    $128, BX
+	ADDQ    $128, DX
+	ADDQ    $128, DI
+	SUBQ    $128, CX
+	JMP     loop128
+
+loop32:
+	CMPQ CX, $32
+	JB   done
+	VMOVDQU 0(SI), Y0
+	VPXOR   0(BX), Y0, Y0
+	VPXOR   0(DX), Y0, Y0
+	VMOVDQU Y0, 0(DI)
+	ADDQ    $32, SI
+	ADDQ    $32, BX
+	ADDQ    $32, DX
+	ADDQ    $32, DI
+	SUBQ    $32, CX
+	JMP     loop32
+
+done:
+	VZEROUPPER
+	RET
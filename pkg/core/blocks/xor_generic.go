@@ -0,0 +1,7 @@
+//go:build !amd64 || purego
+
+package blocks
+
+func xor3(dst, a, b, c []byte) {
+	xor3Generic(dst, a, b, c)
+}
@@ -0,0 +1,93 @@
+package blocks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+func xor3Bytewise(dst, a, b, c []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i] ^ c[i]
+	}
+}
+
+func randomBytes(t testing.TB, n int) []byte {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestXOR3(t *testing.T) {
+	// Lengths around the 32 and 128 byte vector widths, at odd offsets
+	lengths := []int{0, 1, 15, 31, 32, 33, 127, 128, 129, 255, 1000, 4096, DefaultBlockSize + 7}
+	for _, n := range lengths {
+		for _, offset := range []int{0, 1, 3} {
+			a := randomBytes(t, n+offset)[offset:]
+			b := randomBytes(t, n+offset)[offset:]
+			c := randomBytes(t, n+offset)[offset:]
+
+			want := make([]byte, n)
+			xor3Bytewise(want, a, b, c)
+
+			got := make([]byte, n)
+			XOR3(got, a, b, c)
+			if !bytes.Equal(got, want) {
+				t.Errorf("XOR3 mismatch for length %d offset %d", n, offset)
+			}
+
+			generic := make([]byte, n)
+			if n > 0 {
+				xor3Generic(generic, a, b, c)
+			}
+			if !bytes.Equal(generic, want) {
+				t.Errorf("generic XOR3 mismatch for length %d offset %d", n, offset)
+			}
+
+			// dst may alias an input
+			aliased := append([]byte(nil), a...)
+			XOR3(aliased, aliased, b, c)
+			if !bytes.Equal(aliased, want) {
+				t.Errorf("aliased XOR3 mismatch for length %d offset %d", n, offset)
+			}
+		}
+	}
+}
+
+func TestXOR3LengthMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected XOR3 to panic on mismatched lengths")
+		}
+	}()
+	XOR3(make([]byte, 4), make([]byte, 4), make([]byte, 3), make([]byte, 4))
+}
+
+func BenchmarkXOR3(b *testing.B) {
+	for _, size := range []int{SmallFileBlockSize, DefaultBlockSize, MediaBlockSize} {
+		x, y, z := randomBytes(b, size), randomBytes(b, size), randomBytes(b, size)
+		dst := make([]byte, size)
+
+		b.Run(fmt.Sprintf("%dKB", size/1024), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				XOR3(dst, x, y, z)
+			}
+		})
+		b.Run(fmt.Sprintf("%dKB/generic", size/1024), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				xor3Generic(dst, x, y, z)
+			}
+		})
+		b.Run(fmt.Sprintf("%dKB/bytewise", size/1024), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				xor3Bytewise(dst, x, y, z)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package noisefs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Block index operations
+const (
+	blockIndexPut    = "put"
+	blockIndexRemove = "remove"
+)
+
+// BlockTriple is an anonymized block as stored: the data block and the two
+// randomizers it was XORed with
+type BlockTriple struct {
+	DataCID        string `json:"data"`
+	Randomizer1CID string `json:"r1"`
+	Randomizer2CID string `json:"r2"`
+}
+
+// blockIndexRecord is one line of the block index
+type blockIndexRecord struct {
+	Op     string       `json:"op"`
+	Hash   string       `json:"hash"` // Hex SHA-256 of the plaintext block
+	Triple *BlockTriple `json:"triple,omitempty"`
+}
+
+// BlockIndex remembers the stored triple of each plaintext block uploaded,
+// so identical blocks of other files reuse it instead of being anonymized
+// and stored again. It is a file of JSON lines that is read when opened
+// and only appended to afterwards; superseded lines are dropped when it is
+// opened again.
+type BlockIndex struct {
+	mu      sync.Mutex
+	path    string
+	entries map[[sha256.Size]byte]BlockTriple
+}
+
+// OpenBlockIndex opens the block index at path. A missing index is created
+// by the first upload.
+func OpenBlockIndex(path string) (*BlockIndex, error) {
+	idx := &BlockIndex{
+		path:    path,
+		entries: make(map[[sha256.Size]byte]BlockTriple),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block index: %w", err)
+	}
+
+	// A line cut short by a crash ends the index; what it recorded is
+	// simply not reused
+	lines, torn := 0, false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		lines++
+		var record blockIndexRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			torn = true
+			break
+		}
+		var hash [sha256.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(record.Hash)); err != nil || n != sha256.Size {
+			continue
+		}
+		switch record.Op {
+		case blockIndexPut:
+			if record.Triple != nil {
+				idx.entries[hash] = *record.Triple
+			}
+		case blockIndexRemove:
+			delete(idx.entries, hash)
+		}
+	}
+
+	// Rewrite the index without superseded lines, and so new lines aren't
+	// appended after a torn one
+	if torn || lines > 2*len(idx.entries) {
+		if err := idx.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// Lookup returns the stored triple of a plaintext block
+func (idx *BlockIndex) Lookup(hash [sha256.Size]byte) (BlockTriple, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	triple, ok := idx.entries[hash]
+	return triple, ok
+}
+
+// Record remembers the stored triple of a plaintext block
+func (idx *BlockIndex) Record(hash [sha256.Size]byte, triple BlockTriple) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries[hash] = triple
+	return idx.append(blockIndexRecord{Op: blockIndexPut, Hash: hex.EncodeToString(hash[:]), Triple: &triple})
+}
+
+// Forget removes a block whose stored triple is no longer available
+func (idx *BlockIndex) Forget(hash [sha256.Size]byte) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.entries[hash]; !ok {
+		return nil
+	}
+	delete(idx.entries, hash)
+	return idx.append(blockIndexRecord{Op: blockIndexRemove, Hash: hex.EncodeToString(hash[:])})
+}
+
+// Len returns the number of blocks in the index
+func (idx *BlockIndex) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.entries)
+}
+
+// append writes a line to the index. Lines aren't synced: one lost in a
+// crash only means its block isn't reused. The caller must hold mu.
+func (idx *BlockIndex) append(record blockIndexRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(idx.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open block index: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write block index: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the index with one line per block
+func (idx *BlockIndex) compact() error {
+	var buf bytes.Buffer
+	for hash, triple := range idx.entries {
+		line, err := json.Marshal(blockIndexRecord{Op: blockIndexPut, Hash: hex.EncodeToString(hash[:]), Triple: &triple})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(idx.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compact block index: %w", err)
+	}
+	return nil
+}
+
+// SetBlockIndex makes uploads reuse the stored triples of identical blocks
+// of earlier uploads, instead of anonymizing and storing each block again.
+// nil disables block reuse.
+func (c *Client) SetBlockIndex(index *BlockIndex) {
+	c.blockIndex = index
+}
+
+// ReuseBlock returns the stored triple of a plaintext block uploaded
+// before, if its data block is still stored. Blocks whose data block is
+// gone are forgotten.
+func (c *Client) ReuseBlock(ctx context.Context, data []byte) (BlockTriple, bool) {
+	if c.blockIndex == nil {
+		return BlockTriple{}, false
+	}
+	hash := sha256.Sum256(data)
+	triple, ok := c.blockIndex.Lookup(hash)
+	if !ok {
+		return BlockTriple{}, false
+	}
+	if exists, err := c.hasBlock(ctx, triple.DataCID); err != nil || !exists {
+		if err == nil {
+			c.blockIndex.Forget(hash)
+		}
+		return BlockTriple{}, false
+	}
+	c.metrics.RecordDeduplicatedBlock()
+	return triple, true
+}
+
+// RememberBlock records the stored triple of a plaintext block for
+// ReuseBlock
+func (c *Client) RememberBlock(data []byte, triple BlockTriple) error {
+	if c.blockIndex == nil {
+		return nil
+	}
+	return c.blockIndex.Record(sha256.Sum256(data), triple)
+}
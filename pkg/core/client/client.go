@@ -1,12 +1,16 @@
 package noisefs
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -14,9 +18,14 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/TheEntropyCollective/noisefs/pkg/privacy/mixing"
 	"github.com/TheEntropyCollective/noisefs/pkg/privacy/p2p"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // Input validation constants
@@ -80,10 +89,17 @@ type Client struct {
 	adaptiveCache *cache.AdaptiveCache
 	peerManager   *p2p.PeerManager
 	metrics       *Metrics
+	history       *MetricsHistory
 	
 	// Configuration for intelligent operations
 	preferRandomizerPeers bool
 	adaptiveCacheEnabled  bool
+	blockSizePolicy       blocks.BlockSizePolicy
+	retrievalMixer        *mixing.Scheduler // Mixes download block fetches when set
+	processors            *processors.Pipeline // Content processors, nil for none
+	uploads               *UploadIndex         // Earlier uploads, nil to upload everything
+	blockIndex            *BlockIndex          // Stored blocks of earlier uploads, nil to store every block
+	speculativeDelay      time.Duration        // Ask a second backend for blocks slower than this, 0 to never
 }
 
 // ClientConfig holds configuration for NoiseFS client
@@ -91,6 +107,7 @@ type ClientConfig struct {
 	EnableAdaptiveCache   bool
 	PreferRandomizerPeers bool
 	AdaptiveCacheConfig   *cache.AdaptiveCacheConfig
+	BlockSizePolicy       blocks.BlockSizePolicy // Default: fixed DefaultBlockSize
 }
 
 // NewClient creates a new NoiseFS client using storage manager
@@ -128,8 +145,13 @@ func NewClientWithConfig(storageManager *storage.Manager, blockCache cache.Cache
 		storageManager:        storageManager,
 		cache:                 blockCache,
 		metrics:               NewMetrics(),
+		history:               NewMetricsHistory(DefaultHistoryWindow, HistoryInterval),
 		preferRandomizerPeers: config.PreferRandomizerPeers,
 		adaptiveCacheEnabled:  config.EnableAdaptiveCache,
+		blockSizePolicy:       config.BlockSizePolicy,
+	}
+	if client.blockSizePolicy == nil {
+		client.blockSizePolicy = blocks.FixedBlockSize(blocks.DefaultBlockSize)
 	}
 	
 	// Initialize adaptive cache if enabled
@@ -204,11 +226,14 @@ func (c *Client) storeBlockWithTracking(ctx context.Context, block *blocks.Block
 	return address.ID, bytesStored, nil
 }
 
-// retrieveBlock retrieves a block using the storage manager
+// retrieveBlock retrieves a block through the storage manager, racing a
+// second backend when speculative fetching is on
 func (c *Client) retrieveBlock(ctx context.Context, cid string) (*blocks.Block, error) {
-	// Use storage manager
 	address := &storage.BlockAddress{ID: cid}
-	return c.storageManager.Get(ctx, address)
+	if c.speculativeDelay <= 0 {
+		return c.storageManager.Get(ctx, address)
+	}
+	return c.retrieveSpeculatively(ctx, address)
 }
 
 // hasBlock checks if a block exists using the storage manager
@@ -302,17 +327,10 @@ func (c *Client) selectStandardRandomizer(ctx context.Context, blockSize int) (*
 func (c *Client) SelectRandomizers(ctx context.Context, blockSize int) (*blocks.Block, string, *blocks.Block, string, int64, error) {
 	var totalNewStorage int64 = 0
 
-	// Try to get popular blocks from cache first
-	randomizers, err := c.cache.GetRandomizers(20) // Get more blocks for better selection
-	if err == nil && len(randomizers) > 0 {
-		// Filter by matching size
-		suitableBlocks := make([]*cache.BlockInfo, 0)
-		for _, info := range randomizers {
-			if info.Size == blockSize {
-				suitableBlocks = append(suitableBlocks, info)
-			}
-		}
-		
+	// Try to get popular blocks from cache first. Randomizers must match the
+	// block size, so each size has its own pool.
+	suitableBlocks, err := cache.GetRandomizersOfSize(c.cache, blockSize, 20) // Get more blocks for better selection
+	if err == nil {
 		// If we have at least 2 suitable cached blocks, use them
 		if len(suitableBlocks) >= 2 {
 			// Select first randomizer
@@ -431,7 +449,11 @@ func (c *Client) StoreBlockWithCache(ctx context.Context, block *blocks.Block) (
 
 // storeBlockWithStrategy stores a block using the specified peer selection strategy
 func (c *Client) storeBlockWithStrategy(ctx context.Context, block *blocks.Block, strategy string) (string, error) {
-	
+	// Let backends decide how widely to announce randomizers
+	if strategy == "randomizer" {
+		ctx = storage.WithBlockRole(ctx, storage.BlockRoleRandomizer)
+	}
+
 	// Use storage manager (strategy is handled at backend level)
 	cid, err := c.storeBlock(ctx, block)
 	if err != nil {
@@ -565,6 +587,32 @@ func (c *Client) RecordDownload() {
 	c.metrics.RecordDownload()
 }
 
+// RecordUploadDuration records how long an upload took, for uploads made
+// without the client's Upload methods
+func (c *Client) RecordUploadDuration(d time.Duration) {
+	c.metrics.RecordUploadDuration(d)
+}
+
+// RecordDownloadDuration records how long a download took, for downloads
+// made without the client's Download methods
+func (c *Client) RecordDownloadDuration(d time.Duration) {
+	c.metrics.RecordDownloadDuration(d)
+}
+
+// GetCacheStats returns statistics of the block cache
+func (c *Client) GetCacheStats() *cache.Stats {
+	return c.cache.GetStats()
+}
+
+// EvictFromCache removes a block from the block cache and the adaptive
+// cache, so the next retrieval goes to storage
+func (c *Client) EvictFromCache(cid string) {
+	c.cache.Remove(cid)
+	if c.adaptiveCache != nil {
+		c.adaptiveCache.Remove(cid)
+	}
+}
+
 // GetAdaptiveCacheStats returns adaptive cache statistics if enabled
 func (c *Client) GetAdaptiveCacheStats() *cache.AdaptiveCacheStatsSnapshot {
 	if c.adaptiveCacheEnabled && c.adaptiveCache != nil {
@@ -651,17 +699,74 @@ func (c *Client) GetConnectedPeers() []peer.ID {
 
 // Upload uploads a file and returns descriptor CID
 // This is a simplified implementation for testing
-// ProgressCallback is called during operations to report progress
-type ProgressCallback func(stage string, current, total int)
+// SetBlockSizePolicy sets the policy Upload and UploadWithProgress use to
+// choose each file's block size
+func (c *Client) SetBlockSizePolicy(policy blocks.BlockSizePolicy) {
+	if policy == nil {
+		policy = blocks.FixedBlockSize(blocks.DefaultBlockSize)
+	}
+	c.blockSizePolicy = policy
+}
+
+// SetRetrievalMixing fetches the blocks of downloads through a mixing
+// scheduler, which randomizes their order, delays each fetch and batches
+// them with those of parallel downloads. A nil config fetches blocks
+// directly, in order.
+func (c *Client) SetRetrievalMixing(config *mixing.Config) error {
+	if config == nil {
+		c.retrievalMixer = nil
+		return nil
+	}
+	scheduler, err := mixing.NewScheduler(c.retrieveBlock, *config)
+	if err != nil {
+		return err
+	}
+	c.retrievalMixer = scheduler
+	return nil
+}
+
+// SetProcessors runs the content processors of a pipeline on files as
+// they are uploaded and downloaded; nil disables processing
+func (c *Client) SetProcessors(pipeline *processors.Pipeline) {
+	c.processors = pipeline
+}
+
+// Processors returns the client's content processor pipeline, which may be
+// nil, for callers that upload or download files without the client
+func (c *Client) Processors() *processors.Pipeline {
+	return c.processors
+}
+
+// BlockSizeFor returns the block size the client's policy picks for a file;
+// size is -1 if unknown
+func (c *Client) BlockSizeFor(filename string, size int64) int {
+	return c.blockSizePolicy.BlockSize(filename, size)
+}
 
 // Upload uploads a file to NoiseFS with full protocol implementation
 func (c *Client) Upload(ctx context.Context, reader io.Reader, filename string) (string, error) {
-	return c.UploadWithBlockSize(ctx, reader, filename, blocks.DefaultBlockSize)
+	return c.UploadWithBlockSize(ctx, reader, filename, c.BlockSizeFor(filename, readerSize(reader)))
 }
 
 // UploadWithProgress uploads a file with progress reporting
-func (c *Client) UploadWithProgress(ctx context.Context, reader io.Reader, filename string, progress ProgressCallback) (string, error) {
-	return c.UploadWithBlockSizeAndProgress(ctx, reader, filename, blocks.DefaultBlockSize, progress)
+func (c *Client) UploadWithProgress(ctx context.Context, reader io.Reader, filename string, progress util.ProgressReporter) (string, error) {
+	return c.UploadWithBlockSizeAndProgress(ctx, reader, filename, c.BlockSizeFor(filename, readerSize(reader)), progress)
+}
+
+// readerSize returns the remaining length of readers that know it, such as
+// files and in-memory buffers, or -1
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (os.FileInfo, error) }:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return info.Size()
+		}
+	case interface{ Size() int64 }:
+		return r.Size()
+	}
+	return -1
 }
 
 // UploadWithBlockSize uploads a file with a specific block size
@@ -670,7 +775,9 @@ func (c *Client) UploadWithBlockSize(ctx context.Context, reader io.Reader, file
 }
 
 // UploadWithBlockSizeAndProgress uploads a file with a specific block size and progress reporting
-func (c *Client) UploadWithBlockSizeAndProgress(ctx context.Context, reader io.Reader, filename string, blockSize int, progress ProgressCallback) (string, error) {
+func (c *Client) UploadWithBlockSizeAndProgress(ctx context.Context, reader io.Reader, filename string, blockSize int, progress util.ProgressReporter) (descriptorCID string, err error) {
+	defer func() { logTransfer(ctx, "Upload", descriptorCID, err) }()
+
 	// Validate inputs
 	if reader == nil {
 		return "", errors.New("reader cannot be nil")
@@ -684,24 +791,80 @@ func (c *Client) UploadWithBlockSizeAndProgress(ctx context.Context, reader io.R
 		return "", errors.New("block size must be positive")
 	}
 	
+	// Upload what the content processors make of the file
+	if c.processors.Applies(processors.StageUpload, filename) {
+		output, err := c.processors.ProcessReader(ctx, processors.StageUpload, filename, &io.LimitedReader{R: reader, N: MaxFileSize + 1})
+		if err != nil {
+			return "", err
+		}
+		defer output.Close()
+		processed, err := os.Open(output.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to open processed file: %w", err)
+		}
+		defer processed.Close()
+		reader = processed
+	}
+	
+	// Content uploaded before isn't split and stored again. It is
+	// recognised by what the processors made of it, so every upload is
+	// still checked by them and a changed pipeline stores the content anew.
+	var key UploadKey
+	dedup := false
+	if seeker, ok := reader.(io.ReadSeeker); ok && c.uploads != nil {
+		if k, err := NewUploadKey(seeker, filename, blockSize); err == nil {
+			if descriptorCID, found := c.FindUpload(k); found {
+				util.ReportProgress(progress, "Already uploaded", 1, 1, k.Size)
+				return descriptorCID, nil
+			}
+			key, dedup = k, true
+		}
+	}
+	
 	// Use streaming upload to avoid memory exhaustion
-	return c.streamingUploadImpl(ctx, reader, filename, blockSize, progress)
+	descriptorCID, err = c.streamingUploadImpl(ctx, reader, filename, blockSize, progress)
+	if err != nil {
+		return "", err
+	}
+	if dedup {
+		// A failure only means the next upload of the file isn't skipped
+		c.RememberUpload(key, descriptorCID)
+	}
+	return descriptorCID, nil
 }
 
 // streamingUploadImpl implements fully memory-efficient streaming upload
-func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, filename string, blockSize int, progress ProgressCallback) (string, error) {
-	if progress != nil {
-		progress("Starting streaming upload", 0, 100)
+func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, filename string, blockSize int, progress util.ProgressReporter) (string, error) {
+	// Create descriptor - we'll update file size later when we know it
+	descriptor := descriptors.NewDescriptor(filename, 0, 0, blockSize)
+	
+	return c.uploadToDescriptor(ctx, reader, descriptor, progress)
+}
+
+// uploadToDescriptor anonymizes the reader's data in blocks of the
+// descriptor's block size, adds the block triples and sizes to the
+// descriptor and stores it
+func (c *Client) uploadToDescriptor(ctx context.Context, reader io.Reader, descriptor *descriptors.Descriptor, progress util.ProgressReporter) (string, error) {
+	started := time.Now()
+	blockSize := descriptor.BlockSize
+	
+	// Block count for progress, when the size is known up front
+	expectedSize := readerSize(reader)
+	if expectedSize < 0 && descriptor.FileSize > 0 {
+		expectedSize = descriptor.FileSize
+	}
+	var totalBlocks int64
+	if expectedSize > 0 {
+		totalBlocks = (expectedSize + int64(blockSize) - 1) / int64(blockSize)
 	}
+	util.ReportProgress(progress, "Uploading blocks", 0, totalBlocks, 0)
 	
 	// Create a limited reader to enforce MaxFileSize limit and track size as we read
 	limitedReader := &io.LimitedReader{R: reader, N: MaxFileSize + 1}
 	
-	// Create descriptor - we'll update file size later when we know it
-	descriptor := descriptors.NewDescriptor(filename, 0, 0, blockSize)
-	
 	// Process file in fully streaming fashion - no block collection in memory
 	buffer := make([]byte, blockSize)
+	contentHash := sha256.New()
 	var totalBytesRead int64
 	var totalStorageUsed int64
 	blockIndex := 0
@@ -714,10 +877,15 @@ func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, file
 		default:
 		}
 		
-		// Read one block worth of data
-		n, err := limitedReader.Read(buffer)
+		// Read one block worth of data; a short read only ends the file
+		// at EOF, otherwise blocks would be padded mid-file
+		n, err := io.ReadFull(limitedReader, buffer)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
 		if n > 0 {
 			totalBytesRead += int64(n)
+			contentHash.Write(buffer[:n])
 			
 			// Check if we've exceeded the maximum file size
 			if totalBytesRead > MaxFileSize {
@@ -729,50 +897,22 @@ func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, file
 			copy(blockData, buffer[:n])
 			// Remaining bytes are zero-padded automatically
 			
-			fileBlock, blockErr := blocks.NewBlock(blockData)
-			if blockErr != nil {
-				return "", fmt.Errorf("failed to create block: %w", blockErr)
-			}
-			
-			if progress != nil {
-				// Estimate progress based on data read vs max file size
-				progressPct := int((totalBytesRead * 80) / MaxFileSize) // Reserve 20% for final processing
-				if progressPct > 80 {
-					progressPct = 80
-				}
-				progress("Processing block", progressPct, 100)
-			}
-			
 			// Process block immediately to minimize memory usage
-			// Select two randomizer blocks (3-tuple XOR) and track NEW randomizer storage
-			randBlock1, cid1, randBlock2, cid2, randomizerBytesStored, randErr := c.SelectRandomizers(ctx, fileBlock.Size())
-			if randErr != nil {
-				return "", fmt.Errorf("failed to select randomizers for block %d: %w", blockIndex, randErr)
-			}
-			
-			// XOR the blocks (3-tuple: data XOR randomizer1 XOR randomizer2)
-			xorBlock, xorErr := fileBlock.XOR(randBlock1, randBlock2)
-			if xorErr != nil {
-				return "", fmt.Errorf("failed to XOR blocks for block %d: %w", blockIndex, xorErr)
-			}
-			
-			// Store anonymized block with tracking
-			dataCID, dataBytesStored, storeErr := c.storeBlockWithTracking(ctx, xorBlock)
+			triple, bytesStored, storeErr := c.storeAnonymized(ctx, blockData, blockIndex)
 			if storeErr != nil {
-				return "", fmt.Errorf("failed to store data block %d: %w", blockIndex, storeErr)
+				return "", storeErr
 			}
-			
-			// Count both data and NEW randomizer storage
-			totalStorageUsed += dataBytesStored + randomizerBytesStored
+			totalStorageUsed += bytesStored
 			
 			// Add block triple to descriptor immediately
-			if addErr := descriptor.AddBlockTriple(dataCID, cid1, cid2); addErr != nil {
+			if addErr := descriptor.AddBlockTriple(triple.DataCID, triple.Randomizer1CID, triple.Randomizer2CID); addErr != nil {
 				return "", fmt.Errorf("failed to add block triple %d: %w", blockIndex, addErr)
 			}
 			
 			blockIndex++
+			util.ReportProgress(progress, "Uploading blocks", int64(blockIndex), max(totalBlocks, int64(blockIndex)), totalBytesRead)
 			
-			// fileBlock, xorBlock, randBlock1, randBlock2 will be garbage collected here
+			// The block and its randomizers will be garbage collected here
 			// This keeps memory usage constant regardless of file size
 		}
 		
@@ -790,19 +930,14 @@ func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, file
 		return "", fmt.Errorf("file size validation failed: %w", err)
 	}
 	
-	if progress != nil {
-		progress("Finalizing upload", 85, 100)
-	}
-	
 	// Calculate padded file size and update descriptor
 	paddedFileSize := int64(blockIndex * blockSize)
 	descriptor.FileSize = totalBytesRead
 	descriptor.PaddedFileSize = paddedFileSize
+	descriptor.ContentHash = hex.EncodeToString(contentHash.Sum(nil))
 	
 	// Store descriptor in IPFS
-	if progress != nil {
-		progress("Saving file descriptor", 90, 100)
-	}
+	util.ReportProgress(progress, "Saving file descriptor", 0, 1, totalBytesRead)
 	
 	// Create descriptor store with storage manager
 	descriptorStore, err := descriptors.NewStoreWithManager(c.storageManager)
@@ -810,21 +945,73 @@ func (c *Client) streamingUploadImpl(ctx context.Context, reader io.Reader, file
 		return "", fmt.Errorf("failed to create descriptor store: %w", err)
 	}
 	
-	descriptorCID, err := descriptorStore.Save(descriptor)
+	descriptorCID, err := descriptorStore.SaveContext(ctx, descriptor)
 	if err != nil {
 		return "", fmt.Errorf("failed to save descriptor: %w", err)
 	}
 	
-	if progress != nil {
-		progress("Upload complete", 100, 100)
-	}
+	util.ReportProgress(progress, "Saving file descriptor", 1, 1, totalBytesRead)
 	
 	// Record metrics with actual storage used
 	c.RecordUpload(totalBytesRead, totalStorageUsed)
+	c.RecordUploadDuration(time.Since(started))
 	
 	return descriptorCID, nil
 }
 
+// logTransfer logs how an upload or download ended, with the request ID of
+// ctx, so a failure can be traced through the logs of the request
+func logTransfer(ctx context.Context, operation string, descriptorCID string, err error) {
+	logger := logging.GetGlobalLogger().WithComponent("client").WithContext(ctx)
+	if descriptorCID != "" {
+		logger = logger.WithField("descriptor", descriptorCID)
+	}
+	if err != nil {
+		logger.Warnf("%s failed: %v", operation, err)
+		return
+	}
+	logger.Debugf("%s finished", operation)
+}
+
+// storeAnonymized anonymizes a padded plaintext block and stores it,
+// returning its triple and the bytes newly stored. A block uploaded before
+// reuses its stored triple.
+func (c *Client) storeAnonymized(ctx context.Context, blockData []byte, blockIndex int) (BlockTriple, int64, error) {
+	if triple, ok := c.ReuseBlock(ctx, blockData); ok {
+		return triple, 0, nil
+	}
+	
+	fileBlock, err := blocks.NewBlock(blockData)
+	if err != nil {
+		return BlockTriple{}, 0, fmt.Errorf("failed to create block: %w", err)
+	}
+	
+	// Select two randomizer blocks (3-tuple XOR) and track NEW randomizer storage
+	randBlock1, cid1, randBlock2, cid2, randomizerBytesStored, err := c.SelectRandomizers(ctx, fileBlock.Size())
+	if err != nil {
+		return BlockTriple{}, 0, fmt.Errorf("failed to select randomizers for block %d: %w", blockIndex, err)
+	}
+	
+	// XOR the blocks (3-tuple: data XOR randomizer1 XOR randomizer2)
+	xorBlock, err := fileBlock.XOR(randBlock1, randBlock2)
+	if err != nil {
+		return BlockTriple{}, 0, fmt.Errorf("failed to XOR blocks for block %d: %w", blockIndex, err)
+	}
+	
+	// Store anonymized block with tracking
+	dataCID, dataBytesStored, err := c.storeBlockWithTracking(ctx, xorBlock)
+	if err != nil {
+		return BlockTriple{}, 0, fmt.Errorf("failed to store data block %d: %w", blockIndex, err)
+	}
+	
+	triple := BlockTriple{DataCID: dataCID, Randomizer1CID: cid1, Randomizer2CID: cid2}
+	// A failure only means the block isn't reused later
+	c.RememberBlock(blockData, triple)
+	
+	// Count both data and NEW randomizer storage
+	return triple, dataBytesStored + randomizerBytesStored, nil
+}
+
 // Download downloads a file by descriptor CID and returns data
 func (c *Client) Download(ctx context.Context, descriptorCID string) ([]byte, error) {
 	data, _, err := c.DownloadWithMetadata(ctx, descriptorCID)
@@ -832,7 +1019,7 @@ func (c *Client) Download(ctx context.Context, descriptorCID string) ([]byte, er
 }
 
 // DownloadWithProgress downloads a file with progress reporting
-func (c *Client) DownloadWithProgress(ctx context.Context, descriptorCID string, progress ProgressCallback) ([]byte, error) {
+func (c *Client) DownloadWithProgress(ctx context.Context, descriptorCID string, progress util.ProgressReporter) ([]byte, error) {
 	data, _, err := c.DownloadWithMetadataAndProgress(ctx, descriptorCID, progress)
 	return data, err
 }
@@ -842,16 +1029,51 @@ func (c *Client) DownloadWithMetadata(ctx context.Context, descriptorCID string)
 	return c.DownloadWithMetadataAndProgress(ctx, descriptorCID, nil)
 }
 
+// reconstructBlock retrieves a block triple and XORs it back into the
+// original block. The data block and both randomizers are fetched in
+// parallel, so a triple takes as long as its slowest block. Blocks already
+// fetched by retrieval mixing are taken from fetched.
+func (c *Client) reconstructBlock(ctx context.Context, blockInfo descriptors.BlockPair, fetched map[string]*blocks.Block) (*blocks.Block, error) {
+	addresses := []*storage.BlockAddress{
+		{ID: blockInfo.DataCID},
+		{ID: blockInfo.RandomizerCID1},
+		{ID: blockInfo.RandomizerCID2},
+	}
+	triple, err := workers.NewSimpleWorkerPool(len(addresses)).ParallelRetrieval(ctx, addresses, tripleRetriever{client: c, fetched: fetched})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve block triple: %w", err)
+	}
+
+	origBlock, err := triple[0].XOR(triple[1], triple[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to XOR blocks: %w", err)
+	}
+	return origBlock, nil
+}
+
+// tripleRetriever retrieves the blocks of a triple for a worker pool
+type tripleRetriever struct {
+	client  *Client
+	fetched map[string]*blocks.Block
+}
+
+func (r tripleRetriever) Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	if block, ok := r.fetched[address.ID]; ok {
+		return block, nil
+	}
+	return r.client.retrieveBlock(ctx, address.ID)
+}
+
 // DownloadWithMetadataAndProgress downloads a file with progress reporting
-func (c *Client) DownloadWithMetadataAndProgress(ctx context.Context, descriptorCID string, progress ProgressCallback) ([]byte, string, error) {
+func (c *Client) DownloadWithMetadataAndProgress(ctx context.Context, descriptorCID string, progress util.ProgressReporter) (data []byte, filename string, err error) {
+	defer func() { logTransfer(ctx, "Download", descriptorCID, err) }()
+
 	// Validate input CID
 	if err := validateCID(descriptorCID); err != nil {
 		return nil, "", fmt.Errorf("invalid descriptor CID: %w", err)
 	}
 	
-	if progress != nil {
-		progress("Loading file descriptor", 0, 100)
-	}
+	util.ReportProgress(progress, "Loading file descriptor", 0, 1, 0)
 	
 	// Create descriptor store with storage manager
 	descriptorStore, err := descriptors.NewStoreWithManager(c.storageManager)
@@ -860,67 +1082,61 @@ func (c *Client) DownloadWithMetadataAndProgress(ctx context.Context, descriptor
 	}
 	
 	// Load descriptor
-	descriptor, err := descriptorStore.Load(descriptorCID)
+	descriptor, err := descriptorStore.LoadContext(ctx, descriptorCID)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to load descriptor: %w", err)
 	}
 	
-	if progress != nil {
-		progress("Loading file descriptor", 100, 100)
+	util.ReportProgress(progress, "Loading file descriptor", 1, 1, 0)
+	
+	data, err = c.downloadDescriptor(ctx, descriptor, progress)
+	if err != nil {
+		return nil, "", err
 	}
 	
+	return data, descriptor.Filename, nil
+}
+
+// downloadDescriptor retrieves and assembles the blocks of a loaded
+// descriptor, trimming the padding
+func (c *Client) downloadDescriptor(ctx context.Context, descriptor *descriptors.Descriptor, progress util.ProgressReporter) ([]byte, error) {
+	started := time.Now()
+
 	// Retrieve and reconstruct blocks
 	var originalBlocks []*blocks.Block
-	totalBlocks := len(descriptor.Blocks)
+	totalBlocks := int64(len(descriptor.Blocks))
+	var bytesRetrieved int64
+	var fetched map[string]*blocks.Block
 	
 	for i, blockInfo := range descriptor.Blocks {
-		if progress != nil {
-			progress("Downloading blocks", i, totalBlocks)
-		}
-		// Retrieve anonymized data block
-		dataBlock, err := c.retrieveBlock(ctx, blockInfo.DataCID)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to retrieve data block: %w", err)
-		}
-		
-		// Retrieve randomizer blocks
-		randBlock1, err := c.retrieveBlock(ctx, blockInfo.RandomizerCID1)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to retrieve randomizer1 block: %w", err)
-		}
-		
-		// Retrieve second randomizer block (3-tuple XOR)
-		randBlock2, err := c.retrieveBlock(ctx, blockInfo.RandomizerCID2)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to retrieve randomizer2 block: %w", err)
+		util.ReportProgress(progress, "Downloading blocks", int64(i), totalBlocks, bytesRetrieved)
+		if c.retrievalMixer != nil && i%mixingWindow == 0 {
+			var err error
+			if fetched, err = c.fetchMixed(ctx, descriptor.Blocks[i:min(i+mixingWindow, len(descriptor.Blocks))]); err != nil {
+				return nil, err
+			}
 		}
-		
-		origBlock, err := dataBlock.XOR(randBlock1, randBlock2)
+		origBlock, err := c.reconstructBlock(ctx, blockInfo, fetched)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to XOR blocks: %w", err)
+			return nil, err
 		}
 		
 		originalBlocks = append(originalBlocks, origBlock)
+		bytesRetrieved += int64(origBlock.Size())
 	}
 	
-	if progress != nil {
-		progress("Downloading blocks", totalBlocks, totalBlocks)
-	}
+	util.ReportProgress(progress, "Downloading blocks", totalBlocks, totalBlocks, bytesRetrieved)
 	
 	// Assemble file
-	if progress != nil {
-		progress("Assembling file", 0, 100)
-	}
+	util.ReportProgress(progress, "Assembling file", 0, 1, bytesRetrieved)
 	
 	assembler := blocks.NewAssembler()
 	var buf strings.Builder
 	if err := assembler.AssembleToWriter(originalBlocks, &buf); err != nil {
-		return nil, "", fmt.Errorf("failed to assemble file: %w", err)
+		return nil, fmt.Errorf("failed to assemble file: %w", err)
 	}
 	
-	if progress != nil {
-		progress("Assembling file", 100, 100)
-	}
+	util.ReportProgress(progress, "Assembling file", 1, 1, bytesRetrieved)
 	
 	// Handle padding removal (all files are padded)
 	assembledData := []byte(buf.String())
@@ -931,10 +1147,35 @@ func (c *Client) DownloadWithMetadataAndProgress(ctx context.Context, descriptor
 		assembledData = assembledData[:originalSize]
 	}
 	
+	// Never hand out corrupted content
+	if err := descriptor.VerifyContent(bytes.NewReader(assembledData)); err != nil {
+		return nil, err
+	}
+	
+	assembledData, err := c.processors.ProcessBytes(ctx, processors.StageDownload, descriptor.Filename, assembledData)
+	if err != nil {
+		return nil, err
+	}
+	
 	// Record download
 	c.RecordDownload()
+	c.RecordDownloadDuration(time.Since(started))
 	
-	return assembledData, descriptor.Filename, nil
+	return assembledData, nil
+}
+
+// mixingWindow is how many block triples of a download are fetched through
+// the mixing scheduler at once, bounding the blocks held before assembly
+const mixingWindow = 32
+
+// fetchMixed retrieves every block of the triples through the mixing
+// scheduler
+func (c *Client) fetchMixed(ctx context.Context, triples []descriptors.BlockPair) (map[string]*blocks.Block, error) {
+	cids := make([]string, 0, 3*len(triples))
+	for _, triple := range triples {
+		cids = append(cids, triple.DataCID, triple.RandomizerCID1, triple.RandomizerCID2)
+	}
+	return c.retrievalMixer.Fetch(ctx, cids)
 }
 
 
@@ -3,12 +3,25 @@ package noisefs
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/privacy/mixing"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends" // Register mock backend
 )
 
@@ -239,6 +252,346 @@ func TestClient_UploadAndDownload(t *testing.T) {
 	}
 }
 
+func TestClient_DownloadWithRetrievalMixing(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024*1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.SetRetrievalMixing(&mixing.Config{BatchSize: 16, Parallelism: 4}); err != nil {
+		t.Fatalf("Failed to enable retrieval mixing: %v", err)
+	}
+
+	// More blocks than one mixing window, downloaded in parallel
+	ctx := context.Background()
+	var descriptorCIDs []string
+	var contents [][]byte
+	for i := 0; i < 2; i++ {
+		data := []byte(strings.Repeat(fmt.Sprintf("file %d mixed retrieval ", i), 2500))
+		descriptorCID, err := client.UploadWithBlockSize(ctx, bytes.NewReader(data), fmt.Sprintf("mixed%d.txt", i), 1024)
+		if err != nil {
+			t.Fatalf("Failed to upload file: %v", err)
+		}
+		descriptorCIDs = append(descriptorCIDs, descriptorCID)
+		contents = append(contents, data)
+	}
+
+	errs := make(chan error, len(descriptorCIDs))
+	for i := range descriptorCIDs {
+		go func(i int) {
+			data, err := client.Download(ctx, descriptorCIDs[i])
+			if err == nil && !bytes.Equal(data, contents[i]) {
+				err = fmt.Errorf("downloaded data of file %d does not match", i)
+			}
+			errs <- err
+		}(i)
+	}
+	for range descriptorCIDs {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := client.SetRetrievalMixing(&mixing.Config{}); err == nil {
+		t.Error("Expected invalid mixing settings to be rejected")
+	}
+}
+
+func TestClient_Processors(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024*1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Uploads of text are upper-cased, downloads of text get a trailer,
+	// and executables are refused
+	transform := func(change func([]byte) []byte) processors.ProcessorFunc {
+		return func(ctx context.Context, file processors.File) (processors.Result, error) {
+			data, err := os.ReadFile(file.Path)
+			if err != nil {
+				return processors.Result{}, err
+			}
+			if err := os.WriteFile(file.OutputPath, change(data), 0600); err != nil {
+				return processors.Result{}, err
+			}
+			return processors.Result{Replaced: true}, nil
+		}
+	}
+	client.SetProcessors(processors.NewPipeline(
+		processors.Step{Name: "upper", Processor: transform(bytes.ToUpper), Extensions: []string{".txt"}},
+		processors.Step{Name: "trailer", Processor: transform(func(data []byte) []byte { return append(data, "-- checked"...) }), Stages: []processors.Stage{processors.StageDownload}, Extensions: []string{".txt"}},
+		processors.Step{Name: "no-exe", Processor: processors.ProcessorFunc(func(ctx context.Context, file processors.File) (processors.Result, error) {
+			return processors.Result{Reject: "executables are not allowed"}, nil
+		}), Extensions: []string{".exe"}},
+	))
+
+	ctx := context.Background()
+	descriptorCID, err := client.Upload(ctx, strings.NewReader("hello noisefs"), "notes.txt")
+	if err != nil {
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+	data, err := client.Download(ctx, descriptorCID)
+	if err != nil {
+		t.Fatalf("Failed to download file: %v", err)
+	}
+	if string(data) != "HELLO NOISEFS-- checked" {
+		t.Errorf("Expected processed content, got %q", data)
+	}
+
+	// Files the steps don't apply to pass through unchanged
+	descriptorCID, err = client.Upload(ctx, strings.NewReader("raw bytes"), "data.bin")
+	if err != nil {
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+	if data, _ := client.Download(ctx, descriptorCID); string(data) != "raw bytes" {
+		t.Errorf("Expected unprocessed content, got %q", data)
+	}
+
+	var rejected *processors.RejectedError
+	if _, err := client.Upload(ctx, strings.NewReader("MZ"), "setup.exe"); !errors.As(err, &rejected) || rejected.Step != "no-exe" {
+		t.Errorf("Expected the upload to be rejected, got %v", err)
+	}
+	if _, err := client.UploadPack(ctx, "files.pack", []PackFile{{Name: "a.txt", Data: []byte("a")}, {Name: "setup.exe", Data: []byte("MZ")}}); !errors.As(err, &rejected) {
+		t.Errorf("Expected the pack to be rejected, got %v", err)
+	}
+
+	packCID, err := client.UploadPack(ctx, "files.pack", []PackFile{{Name: "a.txt", Data: []byte("abc")}, {Name: "b.bin", Data: []byte("def")}})
+	if err != nil {
+		t.Fatalf("Failed to upload pack: %v", err)
+	}
+	if data, err := client.DownloadPackedFile(ctx, packCID, "a.txt"); err != nil || string(data) != "ABC-- checked" {
+		t.Errorf("Expected processed packed file, got %q, %v", data, err)
+	}
+}
+
+func TestClient_UploadWithBlockSizePolicy(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetBlockSizePolicy(blocks.DefaultBlockSizePolicy())
+
+	store, err := descriptors.NewStoreWithManager(storageManager)
+	if err != nil {
+		t.Fatalf("Failed to create descriptor store: %v", err)
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		filename  string
+		size      int
+		blockSize int
+	}{
+		{"notes.txt", 10 * 1024, blocks.SmallFileBlockSize},
+		{"clip.mp3", 20 * 1024, blocks.SmallFileBlockSize},
+		{"movie.mp4", 600 * 1024, blocks.MediaBlockSize},
+		{"archive.tar", 600 * 1024, blocks.DefaultBlockSize},
+	}
+	for _, tt := range tests {
+		data := bytes.Repeat([]byte(tt.filename), tt.size/len(tt.filename))
+		descriptorCID, err := client.Upload(ctx, bytes.NewReader(data), tt.filename)
+		if err != nil {
+			t.Fatalf("Upload(%s) failed: %v", tt.filename, err)
+		}
+
+		descriptor, err := store.Load(descriptorCID)
+		if err != nil {
+			t.Fatalf("Failed to load descriptor of %s: %v", tt.filename, err)
+		}
+		if descriptor.BlockSize != tt.blockSize {
+			t.Errorf("%s uploaded with block size %d, want %d", tt.filename, descriptor.BlockSize, tt.blockSize)
+		}
+
+		retrieved, err := client.Download(ctx, descriptorCID)
+		if err != nil {
+			t.Fatalf("Download(%s) failed: %v", tt.filename, err)
+		}
+		if !bytes.Equal(data, retrieved) {
+			t.Errorf("Downloaded %s does not match original", tt.filename)
+		}
+	}
+
+	// Readers of unknown size get the default size, and short reads don't
+	// split blocks
+	data := bytes.Repeat([]byte("x"), 10*1024)
+	descriptorCID, err := client.Upload(ctx, iotest.OneByteReader(bytes.NewReader(data)), "stream.bin")
+	if err != nil {
+		t.Fatalf("Upload of stream failed: %v", err)
+	}
+	descriptor, err := store.Load(descriptorCID)
+	if err != nil {
+		t.Fatalf("Failed to load stream descriptor: %v", err)
+	}
+	if descriptor.BlockSize != blocks.DefaultBlockSize || len(descriptor.Blocks) != 1 {
+		t.Errorf("Stream uploaded as %d blocks of %d bytes, want 1 of %d", len(descriptor.Blocks), descriptor.BlockSize, blocks.DefaultBlockSize)
+	}
+}
+
+func TestClient_UploadDeduplication(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	indexPath := filepath.Join(t.TempDir(), "uploads.json")
+	index, err := OpenUploadIndex(indexPath, true)
+	if err != nil {
+		t.Fatalf("OpenUploadIndex failed: %v", err)
+	}
+	client.SetUploadIndex(index)
+
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("deduplicate me "), 1000)
+	first, err := client.Upload(ctx, bytes.NewReader(data), "notes.txt")
+	if err != nil {
+		t.Fatalf("First upload failed: %v", err)
+	}
+	stored := client.GetMetrics().BytesStoredIPFS
+
+	// The same file again is answered from the index without storing blocks
+	second, err := client.Upload(ctx, bytes.NewReader(data), "notes.txt")
+	if err != nil {
+		t.Fatalf("Second upload failed: %v", err)
+	}
+	metrics := client.GetMetrics()
+	if second != first {
+		t.Errorf("Second upload returned %s, want %s", second, first)
+	}
+	if metrics.DeduplicatedUploads != 1 || metrics.TotalUploads != 1 || metrics.BytesStoredIPFS != stored {
+		t.Errorf("Second upload was not deduplicated: %+v", metrics)
+	}
+
+	// A reopened index, as in another process, knows the upload
+	reopened, err := OpenUploadIndex(indexPath, true)
+	if err != nil {
+		t.Fatalf("Reopening the index failed: %v", err)
+	}
+	key, err := NewUploadKey(bytes.NewReader(data), "notes.txt", blocks.DefaultBlockSize)
+	if err != nil {
+		t.Fatalf("NewUploadKey failed: %v", err)
+	}
+	if record, ok, err := reopened.Lookup(key); err != nil || !ok || record.DescriptorCID != first {
+		t.Errorf("Reopened index Lookup = %+v, %v, %v; want %s", record, ok, err, first)
+	}
+
+	// Another name, other content or a reader that can't be rewound is a
+	// new upload
+	for _, tt := range []struct {
+		name     string
+		reader   func() io.Reader
+		filename string
+	}{
+		{"renamed", func() io.Reader { return bytes.NewReader(data) }, "copy.txt"},
+		{"changed", func() io.Reader { return bytes.NewReader(append([]byte("!"), data...)) }, "notes.txt"},
+		{"stream", func() io.Reader { return iotest.OneByteReader(bytes.NewReader(data)) }, "notes.txt"},
+	} {
+		descriptorCID, err := client.Upload(ctx, tt.reader(), tt.filename)
+		if err != nil {
+			t.Fatalf("%s: upload failed: %v", tt.name, err)
+		}
+		if descriptorCID == first {
+			t.Errorf("%s: upload was deduplicated", tt.name)
+		}
+	}
+	if got := client.GetMetrics().DeduplicatedUploads; got != 1 {
+		t.Errorf("DeduplicatedUploads = %d, want 1", got)
+	}
+
+	// Uploads seen before still pass through the processors, and content
+	// they change is stored anew
+	client.SetProcessors(processors.NewPipeline(processors.Step{Name: "upper", Processor: processors.ProcessorFunc(func(ctx context.Context, file processors.File) (processors.Result, error) {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return processors.Result{}, err
+		}
+		return processors.Result{Replaced: true}, os.WriteFile(file.OutputPath, bytes.ToUpper(data), 0600)
+	})}))
+	upper, err := client.Upload(ctx, bytes.NewReader(data), "notes.txt")
+	if err != nil {
+		t.Fatalf("Processed upload failed: %v", err)
+	}
+	if upper == first {
+		t.Error("Processed upload returned the descriptor of the unprocessed one")
+	}
+
+	var rejected *processors.RejectedError
+	client.SetProcessors(processors.NewPipeline(processors.Step{Name: "reject", Processor: processors.ProcessorFunc(func(ctx context.Context, file processors.File) (processors.Result, error) {
+		return processors.Result{Reject: "not today"}, nil
+	})}))
+	if _, err := client.Upload(ctx, bytes.NewReader(data), "notes.txt"); !errors.As(err, &rejected) {
+		t.Errorf("Expected the known upload to be rejected, got %v", err)
+	}
+}
+
+func TestClient_BlockReuse(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	indexPath := filepath.Join(t.TempDir(), "blocks.jsonl")
+	index, err := OpenBlockIndex(indexPath)
+	if err != nil {
+		t.Fatalf("OpenBlockIndex failed: %v", err)
+	}
+	client.SetBlockIndex(index)
+
+	store, err := descriptors.NewStoreWithManager(storageManager)
+	if err != nil {
+		t.Fatalf("Failed to create descriptor store: %v", err)
+	}
+	upload := func(data []byte, filename string) *descriptors.Descriptor {
+		t.Helper()
+		descriptorCID, err := client.UploadWithBlockSize(context.Background(), bytes.NewReader(data), filename, blocks.SmallFileBlockSize)
+		if err != nil {
+			t.Fatalf("Upload(%s) failed: %v", filename, err)
+		}
+		descriptor, err := store.Load(descriptorCID)
+		if err != nil {
+			t.Fatalf("Failed to load descriptor of %s: %v", filename, err)
+		}
+		retrieved, err := client.Download(context.Background(), descriptorCID)
+		if err != nil || !bytes.Equal(retrieved, data) {
+			t.Fatalf("Download(%s) does not match the upload: %v", filename, err)
+		}
+		return descriptor
+	}
+
+	// Two files starting with the same block share its stored triple
+	shared := bytes.Repeat([]byte("shared block "), blocks.SmallFileBlockSize/13+1)[:blocks.SmallFileBlockSize]
+	first := upload(append(append([]byte(nil), shared...), "first tail"...), "first.txt")
+	second := upload(append(append([]byte(nil), shared...), "second tail"...), "second.txt")
+	if first.Blocks[0] != second.Blocks[0] {
+		t.Errorf("Shared block stored twice: %+v and %+v", first.Blocks[0], second.Blocks[0])
+	}
+	if first.Blocks[1].DataCID == second.Blocks[1].DataCID {
+		t.Error("Different blocks share a data block")
+	}
+	if got := client.GetMetrics().DeduplicatedBlocks; got != 1 {
+		t.Errorf("DeduplicatedBlocks = %d, want 1", got)
+	}
+
+	// The index survives reopening, even after a line cut short by a crash
+	file, err := os.OpenFile(indexPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open block index: %v", err)
+	}
+	file.WriteString(`{"op":"put","hash":"ab`)
+	file.Close()
+	reopened, err := OpenBlockIndex(indexPath)
+	if err != nil {
+		t.Fatalf("Reopening the block index failed: %v", err)
+	}
+	if reopened.Len() != 3 {
+		t.Errorf("Reopened index has %d blocks, want 3", reopened.Len())
+	}
+	if triple, ok := reopened.Lookup(sha256.Sum256(shared)); !ok || triple.DataCID != first.Blocks[0].DataCID {
+		t.Errorf("Reopened index Lookup = %+v, %v", triple, ok)
+	}
+}
+
 func TestClient_CacheIntegration(t *testing.T) {
 	storageManager := createTestStorageManager(t)
 	blockCache := cache.NewMemoryCache(1024 * 1024)
@@ -279,6 +632,49 @@ func TestClient_CacheIntegration(t *testing.T) {
 	}
 }
 
+func TestClient_EvictFromCache(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	blockCache := cache.NewMemoryCache(1024 * 1024)
+
+	client, err := NewClient(storageManager, blockCache)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	block, err := blocks.NewBlock([]byte("Eviction test data"))
+	if err != nil {
+		t.Fatalf("Failed to create block: %v", err)
+	}
+
+	ctx := context.Background()
+	cid, err := client.StoreBlockWithCache(ctx, block)
+	if err != nil {
+		t.Fatalf("Failed to store block: %v", err)
+	}
+
+	client.EvictFromCache(cid)
+	if blockCache.Has(cid) {
+		t.Error("Block should not be in cache after eviction")
+	}
+
+	// The first read misses and refills the cache, the second hits
+	before := client.GetMetrics()
+	for i := 0; i < 2; i++ {
+		if _, err := client.RetrieveBlockWithCache(ctx, cid); err != nil {
+			t.Fatalf("Failed to retrieve block: %v", err)
+		}
+	}
+	after := client.GetMetrics()
+	if after.CacheMisses-before.CacheMisses != 1 || after.CacheHits-before.CacheHits != 1 {
+		t.Errorf("Expected one miss and one hit, got %d misses and %d hits",
+			after.CacheMisses-before.CacheMisses, after.CacheHits-before.CacheHits)
+	}
+
+	if stats := client.GetCacheStats(); stats.Size != 1 {
+		t.Errorf("Expected one cached block, got %d", stats.Size)
+	}
+}
+
 func TestClient_ErrorHandling(t *testing.T) {
 	storageManager := createTestStorageManager(t)
 	blockCache := cache.NewMemoryCache(1024 * 1024)
@@ -358,6 +754,63 @@ func TestClient_Metrics(t *testing.T) {
 	}
 }
 
+func TestMetrics_DurationHistograms(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.RecordUploadDuration(300 * time.Millisecond)
+	metrics.RecordUploadDuration(3 * time.Second)
+	metrics.RecordUploadDuration(time.Hour)
+
+	uploads := metrics.GetStats().UploadSeconds
+	if uploads.Count != 3 || uploads.Sum < 3603 {
+		t.Errorf("Count %d and sum %v, want 3 and over 3603", uploads.Count, uploads.Sum)
+	}
+	want := map[float64]uint64{0.25: 0, 0.5: 1, 2.5: 1, 5: 2, 600: 2}
+	for i, bound := range uploads.Buckets {
+		if count, ok := want[bound]; ok && uploads.Counts[i] != count {
+			t.Errorf("Bucket %v counts %d, want %d", bound, uploads.Counts[i], count)
+		}
+	}
+
+	downloads := metrics.GetStats().DownloadSeconds
+	if downloads.Count != 0 || len(downloads.Counts) != len(DurationBuckets) {
+		t.Errorf("Unexpected empty download histogram %+v", downloads)
+	}
+}
+
+func TestMetricsHistory(t *testing.T) {
+	metrics := NewMetrics()
+	history := NewMetricsHistory(30*time.Second, 10*time.Second)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// The first record only sets the starting totals
+	history.Record(start, metrics.GetStats())
+	for i := 1; i <= 4; i++ {
+		for j := 0; j < i; j++ {
+			metrics.RecordUpload(100, 300)
+			metrics.RecordUploadDuration(time.Duration(i) * time.Second)
+		}
+		history.Record(start.Add(time.Duration(i)*10*time.Second), metrics.GetStats())
+	}
+
+	// Only the last three intervals fit the window
+	samples := history.Samples(time.Time{})
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 samples, got %d", len(samples))
+	}
+	for i, sample := range samples {
+		uploads := int64(i + 2)
+		if sample.Uploads != uploads || sample.BytesStored != 300*uploads || sample.UploadSeconds != float64(i+2) {
+			t.Errorf("Sample %d = %+v, want %d uploads taking %ds each", i, sample, uploads, i+2)
+		}
+	}
+	if recent := history.Samples(start.Add(30 * time.Second)); len(recent) != 1 || recent[0].Uploads != 4 {
+		t.Errorf("Expected only the last sample after the cutoff, got %+v", recent)
+	}
+	if history.Window() != 30*time.Second {
+		t.Errorf("Window() = %s", history.Window())
+	}
+}
+
 func TestClient_PeerManagement(t *testing.T) {
 	storageManager := createTestStorageManager(t)
 	blockCache := cache.NewMemoryCache(1024 * 1024)
@@ -436,4 +889,345 @@ func TestClient_InputValidation(t *testing.T) {
 	if err == nil {
 		t.Error("Should fail with file size exceeding maximum")
 	}
-}
\ No newline at end of file
+}
+
+func TestClient_UploadPack(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetBlockSizePolicy(blocks.FixedBlockSize(4096))
+
+	// 20 files of 1000 bytes fill 5 blocks instead of 20, and some of
+	// them straddle block boundaries
+	files := make([]PackFile, 0, 21)
+	for i := 0; i < 20; i++ {
+		files = append(files, PackFile{
+			Name: fmt.Sprintf("file%02d.txt", i),
+			Data: bytes.Repeat([]byte{byte('a' + i)}, 1000),
+		})
+	}
+	files = append(files, PackFile{Name: "empty.txt"})
+
+	ctx := context.Background()
+	packCID, err := client.UploadPack(ctx, "small-files.pack", files)
+	if err != nil {
+		t.Fatalf("UploadPack failed: %v", err)
+	}
+
+	pack, err := client.LoadPack(ctx, packCID)
+	if err != nil {
+		t.Fatalf("LoadPack failed: %v", err)
+	}
+	if len(pack.Blocks) != 5 || len(pack.Entries) != 21 || pack.FileSize != 20000 {
+		t.Errorf("Unexpected pack: %d blocks, %d entries, %d bytes", len(pack.Blocks), len(pack.Entries), pack.FileSize)
+	}
+
+	for _, file := range files {
+		data, err := client.DownloadPackedFile(ctx, packCID, file.Name)
+		if err != nil {
+			t.Fatalf("DownloadPackedFile(%s) failed: %v", file.Name, err)
+		}
+		if !bytes.Equal(data, file.Data) {
+			t.Errorf("Packed file %s does not match original", file.Name)
+		}
+	}
+
+	if _, err := client.DownloadPackedFile(ctx, packCID, "missing.txt"); err == nil {
+		t.Error("Expected an error for a file not in the pack")
+	}
+
+	filter, _ := descriptors.NewPathFilter([]string{"file1?.txt"})
+	selected, err := client.DownloadPackedFiles(ctx, packCID, filter)
+	if err != nil {
+		t.Fatalf("DownloadPackedFiles failed: %v", err)
+	}
+	if len(selected) != 10 || selected[0].Name != "file10.txt" || !bytes.Equal(selected[9].Data, files[19].Data) {
+		t.Errorf("DownloadPackedFiles(file1?.txt) returned %d files", len(selected))
+	}
+	none, _ := descriptors.NewPathFilter([]string{"docs/**"})
+	if _, err := client.DownloadPackedFiles(ctx, packCID, none); err == nil {
+		t.Error("Expected an error when no packed file matches")
+	}
+	if _, err := client.UploadPack(ctx, "dup.pack", []PackFile{{Name: "a", Data: []byte("1")}, {Name: "a", Data: []byte("2")}}); err == nil {
+		t.Error("Expected an error for duplicate packed names")
+	}
+
+	fileCID, err := client.Upload(ctx, bytes.NewReader([]byte("not a pack")), "plain.txt")
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if _, err := client.LoadPack(ctx, fileCID); err == nil {
+		t.Error("Expected LoadPack of a file descriptor to fail")
+	}
+}
+
+func TestClient_DownloadFile(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := context.Background()
+	testData := []byte(strings.Repeat("private data ", 500))
+	plainCID, err := client.UploadWithBlockSize(ctx, bytes.NewReader(testData), "private.txt", 4096)
+	if err != nil {
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+
+	// Store the same descriptor encrypted
+	descriptor, _, err := client.LoadDescriptor(plainCID, "")
+	if err != nil {
+		t.Fatalf("LoadDescriptor failed: %v", err)
+	}
+	encryptedStore, err := descriptors.NewEncryptedStoreWithPassword(storageManager, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedCID, err := encryptedStore.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Failed to save encrypted descriptor: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		cid       string
+		password  string
+		encrypted bool
+	}{
+		{"plain", plainCID, "", false},
+		{"plain ignores password", plainCID, "secret", false},
+		{"encrypted", encryptedCID, "secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := client.DownloadFile(ctx, tt.cid, tt.password, nil)
+			if err != nil {
+				t.Fatalf("DownloadFile failed: %v", err)
+			}
+			if file.Filename != "private.txt" || file.Size != int64(len(testData)) || file.Encrypted != tt.encrypted {
+				t.Errorf("Unexpected metadata: %s, %d bytes, encrypted %v", file.Filename, file.Size, file.Encrypted)
+			}
+			var buf bytes.Buffer
+			if _, err := buf.ReadFrom(file.Reader); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), testData) {
+				t.Error("Downloaded data should match original")
+			}
+		})
+	}
+
+	if _, err := client.DownloadFile(ctx, encryptedCID, "", nil); !errors.Is(err, descriptors.ErrPasswordRequired) {
+		t.Errorf("Expected ErrPasswordRequired, got %v", err)
+	}
+	_, encrypted, err := client.LoadDescriptor(encryptedCID, "wrong")
+	if err == nil || !encrypted {
+		t.Errorf("Expected wrong password to fail for an encrypted descriptor, got encrypted=%v err=%v", encrypted, err)
+	}
+}
+
+func TestClient_DownloadVerification(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := context.Background()
+	testData := []byte(strings.Repeat("checked data ", 500))
+	descriptorCID, err := client.UploadWithBlockSize(ctx, bytes.NewReader(testData), "checked.txt", 4096)
+	if err != nil {
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+	descriptor, _, err := client.LoadDescriptor(descriptorCID, "")
+	if err != nil {
+		t.Fatalf("LoadDescriptor failed: %v", err)
+	}
+	sum := sha256.Sum256(testData)
+	if descriptor.ContentHash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Expected the content hash of the file, got %q", descriptor.ContentHash)
+	}
+	if file, err := client.DownloadFile(ctx, descriptorCID, "", nil); err != nil || !file.Verified {
+		t.Fatalf("Expected a verified download, got %+v, %v", file, err)
+	}
+
+	store, err := descriptors.NewStoreWithManager(storageManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Content that doesn't match its hash is refused
+	other := sha256.Sum256([]byte("other data"))
+	descriptor.ContentHash = hex.EncodeToString(other[:])
+	mismatchCID, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Failed to save descriptor: %v", err)
+	}
+	if _, err := client.DownloadFile(ctx, mismatchCID, "", nil); !errors.Is(err, descriptors.ErrContentMismatch) {
+		t.Errorf("Expected ErrContentMismatch, got %v", err)
+	}
+	if _, err := client.Download(ctx, mismatchCID); !errors.Is(err, descriptors.ErrContentMismatch) {
+		t.Errorf("Expected ErrContentMismatch from Download, got %v", err)
+	}
+
+	// Older descriptors without a hash are served unverified
+	descriptor.ContentHash = ""
+	legacyCID, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Failed to save descriptor: %v", err)
+	}
+	file, err := client.DownloadFile(ctx, legacyCID, "", nil)
+	if err != nil || file.Verified || file.Size != int64(len(testData)) {
+		t.Errorf("Expected an unverified download, got %+v, %v", file, err)
+	}
+}
+
+func TestClient_ProgressReporting(t *testing.T) {
+	storageManager := createTestStorageManager(t)
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetBlockSizePolicy(blocks.FixedBlockSize(4096))
+
+	var updates []util.Progress
+	reporter := util.ProgressFunc(func(progress util.Progress) {
+		updates = append(updates, progress)
+	})
+	lastUpdate := func(stage string) util.Progress {
+		t.Helper()
+		for i := len(updates) - 1; i >= 0; i-- {
+			if updates[i].Stage == stage {
+				return updates[i]
+			}
+		}
+		t.Fatalf("No %q progress reported", stage)
+		return util.Progress{}
+	}
+
+	ctx := context.Background()
+	testData := bytes.Repeat([]byte("x"), 10000)
+	descriptorCID, err := client.UploadWithProgress(ctx, bytes.NewReader(testData), "progress.bin", reporter)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got := lastUpdate("Uploading blocks"); got.Current != 3 || got.Total != 3 || got.Bytes != 10000 {
+		t.Errorf("Unexpected upload progress: %+v", got)
+	}
+	if got := lastUpdate("Saving file descriptor"); got.Percent() != 100 {
+		t.Errorf("Upload did not finish: %+v", got)
+	}
+
+	updates = nil
+	if _, err := client.DownloadWithProgress(ctx, descriptorCID, reporter); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if got := lastUpdate("Downloading blocks"); got.Current != 3 || got.Total != 3 || got.Bytes != 3*4096 {
+		t.Errorf("Unexpected download progress: %+v", got)
+	}
+	if got := lastUpdate("Assembling file"); got.Percent() != 100 {
+		t.Errorf("Download did not finish: %+v", got)
+	}
+}
+
+func TestClient_SpeculativeFetch(t *testing.T) {
+	config := storage.DefaultConfig()
+	config.DefaultBackend = "primary"
+	config.Backends = map[string]*storage.BackendConfig{
+		"primary":   {Type: "mock", Enabled: true, Priority: 100, Connection: &storage.ConnectionConfig{Endpoint: "memory://primary"}},
+		"secondary": {Type: "mock", Enabled: true, Priority: 50, Connection: &storage.ConnectionConfig{Endpoint: "memory://secondary"}},
+	}
+	storageManager, err := storage.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage manager: %v", err)
+	}
+	ctx := context.Background()
+	if err := storageManager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer storageManager.Stop(ctx)
+
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	testData := []byte(strings.Repeat("raced ", 1000))
+	descriptorCID, err := client.UploadWithBlockSize(ctx, bytes.NewReader(testData), "raced.txt", 4096)
+	if err != nil {
+		t.Fatalf("Failed to upload file: %v", err)
+	}
+
+	// Mirror the file's blocks on the second backend
+	descriptor, _, err := client.LoadDescriptor(descriptorCID, "")
+	if err != nil {
+		t.Fatalf("LoadDescriptor failed: %v", err)
+	}
+	secondary, _ := storageManager.GetBackend("secondary")
+	for _, triple := range descriptor.Blocks {
+		for _, cid := range []string{triple.DataCID, triple.RandomizerCID1, triple.RandomizerCID2} {
+			block, err := storageManager.Get(ctx, &storage.BlockAddress{ID: cid})
+			if err != nil {
+				t.Fatalf("Failed to get block %s: %v", cid, err)
+			}
+			if _, err := secondary.Put(ctx, block); err != nil {
+				t.Fatalf("Failed to mirror block %s: %v", cid, err)
+			}
+		}
+	}
+
+	if _, err := storageManager.InjectFaults("primary", storage.FaultConfig{Latency: 500 * time.Millisecond, Operations: []string{storage.FaultOpGet}}); err != nil {
+		t.Fatalf("InjectFaults failed: %v", err)
+	}
+	client.SetSpeculativeFetch(10 * time.Millisecond)
+
+	data, err := client.Download(ctx, descriptorCID)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if !bytes.Equal(data, testData) {
+		t.Error("Downloaded data does not match the upload")
+	}
+
+	// Every block came from the second backend
+	blocksFetched := int64(3 * len(descriptor.Blocks))
+	metrics := client.GetMetrics()
+	if metrics.SpeculativeFetches != blocksFetched || metrics.SpeculativeWins != blocksFetched {
+		t.Errorf("Speculative fetches = %d, wins = %d; want %d of each", metrics.SpeculativeFetches, metrics.SpeculativeWins, blocksFetched)
+	}
+}
+
+func TestClient_SpeculativeBackendSkipsPrimary(t *testing.T) {
+	config := storage.DefaultConfig()
+	config.DefaultBackend = "first"
+	config.Backends = map[string]*storage.BackendConfig{
+		"first":  {Type: "mock", Enabled: true, Priority: 100, Connection: &storage.ConnectionConfig{Endpoint: "memory://first"}},
+		"second": {Type: "mock", Enabled: true, Priority: 50, Connection: &storage.ConnectionConfig{Endpoint: "memory://second"}},
+	}
+	storageManager, err := storage.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create storage manager: %v", err)
+	}
+	ctx := context.Background()
+	if err := storageManager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer storageManager.Stop(ctx)
+
+	client, err := NewClient(storageManager, cache.NewMemoryCache(1024))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	first, _ := storageManager.GetBackend("first")
+	second, _ := storageManager.GetBackend("second")
+
+	// Whichever backend the first request went to, the race uses the other
+	if got := client.speculativeBackend(first); got != second {
+		t.Errorf("Expected to race the second backend, got %v", got)
+	}
+	if got := client.speculativeBackend(second); got != first {
+		t.Errorf("Expected to race the first backend, got %v", got)
+	}
+}
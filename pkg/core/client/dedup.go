@@ -0,0 +1,263 @@
+package noisefs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// The bloom filter is sized for this many uploads and grows with the index
+const (
+	uploadBloomCapacity       = 10000
+	uploadBloomFalsePositives = 0.01
+)
+
+// UploadKey identifies an upload by what determines its descriptor: the
+// plaintext as the upload processors left it, the file name and the block
+// size
+type UploadKey struct {
+	ContentHash string // Hex SHA-256 of the processed plaintext
+	Size        int64
+	Filename    string
+	BlockSize   int
+}
+
+// NewUploadKey hashes the rest of a reader's content and rewinds it, so
+// the reader can be uploaded afterwards
+func NewUploadKey(reader io.ReadSeeker, filename string, blockSize int) (UploadKey, error) {
+	start, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return UploadKey{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, &io.LimitedReader{R: reader, N: MaxFileSize + 1})
+	if err != nil {
+		return UploadKey{}, fmt.Errorf("failed to hash content: %w", err)
+	}
+	if _, err := reader.Seek(start, io.SeekStart); err != nil {
+		return UploadKey{}, err
+	}
+	return UploadKey{
+		ContentHash: hex.EncodeToString(hash.Sum(nil)),
+		Size:        size,
+		Filename:    filename,
+		BlockSize:   blockSize,
+	}, nil
+}
+
+func (k UploadKey) String() string {
+	return fmt.Sprintf("%s:%d:%d:%s", k.ContentHash, k.Size, k.BlockSize, k.Filename)
+}
+
+// UploadRecord is an upload remembered by an UploadIndex
+type UploadRecord struct {
+	DescriptorCID string    `json:"descriptor_cid"`
+	UploadedAt    time.Time `json:"uploaded_at"`
+}
+
+// UploadIndex remembers the descriptors of uploaded files, so uploading
+// the same file again returns the descriptor instead of storing the file
+// a second time. It is a JSON file that is only read when needed: with the
+// optional bloom filter beside it, content that was never uploaded is
+// recognised without reading the index.
+type UploadIndex struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]UploadRecord // nil until loaded
+	bloom   *bloom.BloomFilter      // nil when disabled
+}
+
+// OpenUploadIndex opens the upload index at path, with a bloom filter in
+// path + ".bloom" if useBloom is set. A missing index is created by the
+// first upload.
+func OpenUploadIndex(path string, useBloom bool) (*UploadIndex, error) {
+	idx := &UploadIndex{path: path}
+	if !useBloom {
+		return idx, nil
+	}
+
+	data, err := os.ReadFile(idx.bloomPath())
+	if err == nil {
+		filter := &bloom.BloomFilter{}
+		if err := filter.UnmarshalBinary(data); err == nil {
+			idx.bloom = filter
+			return idx, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read upload bloom filter: %w", err)
+	}
+
+	// Missing or unreadable: rebuild it from the index
+	if err := idx.load(); err != nil {
+		return nil, err
+	}
+	idx.bloom = newUploadBloom(idx.entries)
+	if len(idx.entries) > 0 {
+		if err := idx.saveBloom(); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// Lookup returns the descriptor of an earlier upload with the key
+func (idx *UploadIndex) Lookup(key UploadKey) (UploadRecord, bool, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.bloom != nil && !idx.bloom.TestString(key.String()) {
+		return UploadRecord{}, false, nil
+	}
+	if err := idx.load(); err != nil {
+		return UploadRecord{}, false, err
+	}
+	record, ok := idx.entries[key.String()]
+	return record, ok, nil
+}
+
+// Record remembers an upload. The index is read again first, so uploads
+// recorded meanwhile by other processes are kept.
+func (idx *UploadIndex) Record(key UploadKey, descriptorCID string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = nil
+	if err := idx.load(); err != nil {
+		return err
+	}
+	idx.entries[key.String()] = UploadRecord{DescriptorCID: descriptorCID, UploadedAt: time.Now().UTC()}
+	return idx.save()
+}
+
+// Forget removes an upload whose descriptor is no longer available
+func (idx *UploadIndex) Forget(key UploadKey) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.entries = nil
+	if err := idx.load(); err != nil {
+		return err
+	}
+	if _, ok := idx.entries[key.String()]; !ok {
+		return nil
+	}
+	delete(idx.entries, key.String())
+	return idx.save()
+}
+
+// load reads the index unless it is loaded. The caller must hold mu.
+func (idx *UploadIndex) load() error {
+	if idx.entries != nil {
+		return nil
+	}
+	idx.entries = make(map[string]UploadRecord)
+	data, err := os.ReadFile(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read upload index: %w", err)
+	}
+	if err := json.Unmarshal(data, &idx.entries); err != nil {
+		return fmt.Errorf("failed to parse upload index %s: %w", idx.path, err)
+	}
+	return nil
+}
+
+// save writes the index and rebuilds the bloom filter. The caller must
+// hold mu.
+func (idx *UploadIndex) save() error {
+	data, err := json.Marshal(idx.entries)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(idx.path, data); err != nil {
+		return fmt.Errorf("failed to save upload index: %w", err)
+	}
+	if idx.bloom == nil {
+		return nil
+	}
+	idx.bloom = newUploadBloom(idx.entries)
+	return idx.saveBloom()
+}
+
+func (idx *UploadIndex) saveBloom() error {
+	data, err := idx.bloom.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode upload bloom filter: %w", err)
+	}
+	if err := writeFileAtomic(idx.bloomPath(), data); err != nil {
+		return fmt.Errorf("failed to save upload bloom filter: %w", err)
+	}
+	return nil
+}
+
+func (idx *UploadIndex) bloomPath() string {
+	return idx.path + ".bloom"
+}
+
+// newUploadBloom returns a bloom filter of the keys of an index
+func newUploadBloom(entries map[string]UploadRecord) *bloom.BloomFilter {
+	filter := bloom.NewWithEstimates(uint(max(uploadBloomCapacity, 2*len(entries))), uploadBloomFalsePositives)
+	for key := range entries {
+		filter.AddString(key)
+	}
+	return filter
+}
+
+// writeFileAtomic replaces a file, so readers never see it half written.
+// The index reveals what was uploaded, so only the owner may read it.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SetUploadIndex makes uploads of content uploaded before return the
+// earlier descriptor instead of splitting and storing the content again.
+// Only content that can be read twice, such as files, is deduplicated.
+// nil disables deduplication.
+func (c *Client) SetUploadIndex(index *UploadIndex) {
+	c.uploads = index
+}
+
+// FindUpload returns the descriptor of an earlier upload with the key, if
+// there is one and it can still be loaded. Descriptors that can't are
+// forgotten.
+func (c *Client) FindUpload(key UploadKey) (string, bool) {
+	if c.uploads == nil {
+		return "", false
+	}
+	record, ok, err := c.uploads.Lookup(key)
+	if err != nil || !ok {
+		return "", false
+	}
+	descriptor, _, err := c.LoadDescriptor(record.DescriptorCID, "")
+	if err != nil || descriptor.Filename != key.Filename || descriptor.FileSize != key.Size {
+		c.uploads.Forget(key)
+		return "", false
+	}
+	c.metrics.RecordDeduplicatedUpload()
+	return record.DescriptorCID, true
+}
+
+// RememberUpload records an upload for FindUpload
+func (c *Client) RememberUpload(key UploadKey, descriptorCID string) error {
+	if c.uploads == nil {
+		return nil
+	}
+	return c.uploads.Record(key, descriptorCID)
+}
@@ -0,0 +1,73 @@
+package noisefs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// DownloadedFile is a file retrieved with DownloadFile
+type DownloadedFile struct {
+	Filename  string
+	Size      int64
+	Encrypted bool          // The descriptor was password protected
+	Verified  bool          // The contents matched the descriptor's hash
+	Reader    io.ReadSeeker // File contents
+}
+
+// LoadDescriptor loads a descriptor stored in any format. Encrypted
+// descriptors are decrypted with password, which plain descriptors ignore,
+// so callers don't need to know how a descriptor was saved. The returned
+// flag reports whether it was encrypted; without a password an encrypted
+// descriptor fails with descriptors.ErrPasswordRequired.
+func (c *Client) LoadDescriptor(descriptorCID string, password string) (*descriptors.Descriptor, bool, error) {
+	if err := validateCID(descriptorCID); err != nil {
+		return nil, false, fmt.Errorf("invalid descriptor CID: %w", err)
+	}
+
+	store, err := descriptors.NewEncryptedStoreWithPassword(c.storageManager, password)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create descriptor store: %w", err)
+	}
+	descriptor, encrypted, err := store.LoadWithEncryption(descriptorCID)
+	if err != nil {
+		return nil, encrypted, fmt.Errorf("failed to load descriptor: %w", err)
+	}
+	return descriptor, encrypted, nil
+}
+
+// DownloadFile downloads a file whose descriptor may be encrypted or plain,
+// returning its contents along with the descriptor's metadata. password
+// may be empty for plain descriptors.
+func (c *Client) DownloadFile(ctx context.Context, descriptorCID string, password string, progress util.ProgressReporter) (file *DownloadedFile, err error) {
+	defer func() { logTransfer(ctx, "Download", descriptorCID, err) }()
+
+	util.ReportProgress(progress, "Loading file descriptor", 0, 1, 0)
+
+	descriptor, encrypted, err := c.LoadDescriptor(descriptorCID, password)
+	if err != nil {
+		return nil, err
+	}
+	if descriptor.IsDirectory() || descriptor.IsPack() {
+		return nil, fmt.Errorf("%s is a %s descriptor, not a file", descriptorCID, descriptor.Type)
+	}
+
+	util.ReportProgress(progress, "Loading file descriptor", 1, 1, 0)
+
+	data, err := c.downloadDescriptor(ctx, descriptor, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadedFile{
+		Filename:  descriptor.Filename,
+		Size:      int64(len(data)),
+		Encrypted: encrypted,
+		Verified:  descriptor.Verifiable(),
+		Reader:    bytes.NewReader(data),
+	}, nil
+}
@@ -0,0 +1,168 @@
+package noisefs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// HistoryInterval is how often RecordMetricsHistory samples metrics
+	HistoryInterval = 10 * time.Second
+
+	// DefaultHistoryWindow is how much metrics history a client keeps
+	DefaultHistoryWindow = time.Hour
+)
+
+// MetricsSample is a client's activity over one history interval, so a
+// history shows when things happened rather than running totals
+type MetricsSample struct {
+	Time               time.Time `json:"time"` // End of the interval
+	Uploads            int64     `json:"uploads"`
+	Downloads          int64     `json:"downloads"`
+	BytesUploaded      int64     `json:"bytes_uploaded"` // Original bytes
+	BytesStored        int64     `json:"bytes_stored"`
+	BlocksReused       int64     `json:"blocks_reused"`
+	BlocksGenerated    int64     `json:"blocks_generated"`
+	CacheHits          int64     `json:"cache_hits"`
+	CacheMisses        int64     `json:"cache_misses"`
+	SpeculativeFetches int64     `json:"speculative_fetches"`
+	UploadSeconds      float64   `json:"upload_seconds"`   // Mean upload time, 0 without uploads
+	DownloadSeconds    float64   `json:"download_seconds"` // Mean download time, 0 without downloads
+}
+
+// MetricsHistory keeps the last window of metrics samples in a ring buffer,
+// for looking back at an incident without external monitoring
+type MetricsHistory struct {
+	interval time.Duration
+
+	mu      sync.RWMutex
+	samples []MetricsSample // Oldest at next once full
+	next    int
+	full    bool
+	last    *MetricsSnapshot // Totals at the previous sample
+}
+
+// NewMetricsHistory creates a history of window length, sampled every
+// interval
+func NewMetricsHistory(window, interval time.Duration) *MetricsHistory {
+	size := int(window / interval)
+	if size < 1 {
+		size = 1
+	}
+	return &MetricsHistory{interval: interval, samples: make([]MetricsSample, size)}
+}
+
+// Interval returns the time between samples
+func (h *MetricsHistory) Interval() time.Duration {
+	return h.interval
+}
+
+// Window returns how far back the history reaches once full
+func (h *MetricsHistory) Window() time.Duration {
+	return time.Duration(len(h.samples)) * h.interval
+}
+
+// Record adds the activity since the previous call. The first call only
+// sets the starting totals.
+func (h *MetricsHistory) Record(now time.Time, totals MetricsSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last := h.last
+	h.last = &totals
+	if last == nil {
+		return
+	}
+
+	h.samples[h.next] = MetricsSample{
+		Time:               now,
+		Uploads:            totals.TotalUploads - last.TotalUploads,
+		Downloads:          totals.TotalDownloads - last.TotalDownloads,
+		BytesUploaded:      totals.BytesUploadedOriginal - last.BytesUploadedOriginal,
+		BytesStored:        totals.BytesStoredIPFS - last.BytesStoredIPFS,
+		BlocksReused:       totals.BlocksReused - last.BlocksReused,
+		BlocksGenerated:    totals.BlocksGenerated - last.BlocksGenerated,
+		CacheHits:          totals.CacheHits - last.CacheHits,
+		CacheMisses:        totals.CacheMisses - last.CacheMisses,
+		SpeculativeFetches: totals.SpeculativeFetches - last.SpeculativeFetches,
+		UploadSeconds:      meanSeconds(totals.UploadSeconds, last.UploadSeconds),
+		DownloadSeconds:    meanSeconds(totals.DownloadSeconds, last.DownloadSeconds),
+	}
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// meanSeconds returns the mean duration of the observations between two
+// snapshots of a histogram
+func meanSeconds(now, before HistogramSnapshot) float64 {
+	if now.Count <= before.Count {
+		return 0
+	}
+	return (now.Sum - before.Sum) / float64(now.Count-before.Count)
+}
+
+// Samples returns the samples taken after since, oldest first
+func (h *MetricsHistory) Samples(since time.Time) []MetricsSample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ordered := h.samples[:h.next]
+	if h.full {
+		ordered = append(append([]MetricsSample(nil), h.samples[h.next:]...), h.samples[:h.next]...)
+	}
+	samples := make([]MetricsSample, 0, len(ordered))
+	for _, sample := range ordered {
+		if sample.Time.After(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// SetMetricsHistory replaces the client's metrics history, such as with
+// one of a configured window. Call it before RecordMetricsHistory.
+func (c *Client) SetMetricsHistory(history *MetricsHistory) {
+	c.history = history
+}
+
+// MetricsHistory returns the client's metrics history, which is empty
+// unless RecordMetricsHistory runs
+func (c *Client) MetricsHistory() *MetricsHistory {
+	return c.history
+}
+
+// RecordMetricsHistory samples the client's metrics into its history every
+// interval until ctx is done. Long-running processes run it in the
+// background.
+func (c *Client) RecordMetricsHistory(ctx context.Context) {
+	c.history.Record(time.Now(), c.metrics.GetStats())
+	ticker := time.NewTicker(c.history.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.history.Record(now, c.metrics.GetStats())
+		}
+	}
+}
+
+// HistoryReport is a metrics history as served by daemons
+type HistoryReport struct {
+	IntervalSeconds int             `json:"interval_seconds"`
+	WindowMinutes   int             `json:"window_minutes"`
+	Samples         []MetricsSample `json:"samples"`
+}
+
+// Report returns the samples taken after since with the history's shape
+func (h *MetricsHistory) Report(since time.Time) HistoryReport {
+	return HistoryReport{
+		IntervalSeconds: int(h.interval.Seconds()),
+		WindowMinutes:   int(h.Window().Minutes()),
+		Samples:         h.Samples(since),
+	}
+}
@@ -1,9 +1,16 @@
 package noisefs
 
 import (
+	"sort"
 	"sync"
+	"time"
 )
 
+// DurationBuckets are the upper bounds, in seconds, of the upload and
+// download duration histograms. Dashboards query the buckets by bound, so
+// keep existing bounds when changing them.
+var DurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
 // Metrics tracks NoiseFS performance and efficiency metrics
 type Metrics struct {
 	mu                    sync.RWMutex
@@ -15,6 +22,55 @@ type Metrics struct {
 	TotalDownloads        int64 // Total files downloaded
 	BytesUploadedOriginal int64 // Original bytes uploaded
 	BytesStoredIPFS       int64 // Actual bytes stored in IPFS
+	DeduplicatedUploads   int64 // Uploads answered with an earlier upload's descriptor
+	DeduplicatedBlocks    int64 // Blocks that reused the stored triple of an earlier upload
+	SpeculativeFetches    int64 // Blocks also requested from a second backend for being slow
+	SpeculativeWins       int64 // Speculative fetches that returned before the first backend
+
+	uploadSeconds   histogram
+	downloadSeconds histogram
+}
+
+// histogram counts durations by DurationBuckets; the zero value is empty
+type histogram struct {
+	counts []uint64 // Per bucket, with a last bucket for slower observations
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(DurationBuckets)+1)
+	}
+	seconds := d.Seconds()
+	h.counts[sort.SearchFloat64s(DurationBuckets, seconds)]++
+	h.count++
+	h.sum += seconds
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	snapshot := HistogramSnapshot{
+		Buckets: DurationBuckets,
+		Counts:  make([]uint64, len(DurationBuckets)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+	var cumulative uint64
+	for i := range snapshot.Counts {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		snapshot.Counts[i] = cumulative
+	}
+	return snapshot
+}
+
+// HistogramSnapshot is a point-in-time view of a duration histogram
+type HistogramSnapshot struct {
+	Buckets []float64 `json:"buckets"` // Upper bounds in seconds
+	Counts  []uint64  `json:"counts"`  // Observations at or below each bound
+	Count   uint64    `json:"count"`
+	Sum     float64   `json:"sum"` // Seconds
 }
 
 // NewMetrics creates a new metrics tracker
@@ -59,6 +115,32 @@ func (m *Metrics) RecordUpload(originalBytes, storedBytes int64) {
 	m.BytesStoredIPFS += storedBytes
 }
 
+// RecordDeduplicatedUpload records an upload skipped because the content
+// was uploaded before
+func (m *Metrics) RecordDeduplicatedUpload() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.DeduplicatedUploads++
+}
+
+// RecordDeduplicatedBlock records a block that reused a stored triple
+func (m *Metrics) RecordDeduplicatedBlock() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.DeduplicatedBlocks++
+}
+
+// RecordSpeculativeFetch records a block requested from a second backend,
+// and whether that backend answered first
+func (m *Metrics) RecordSpeculativeFetch(won bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SpeculativeFetches++
+	if won {
+		m.SpeculativeWins++
+	}
+}
+
 // RecordDownload increments the download counter
 func (m *Metrics) RecordDownload() {
 	m.mu.Lock()
@@ -66,6 +148,20 @@ func (m *Metrics) RecordDownload() {
 	m.TotalDownloads++
 }
 
+// RecordUploadDuration records how long a file upload took
+func (m *Metrics) RecordUploadDuration(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploadSeconds.observe(d)
+}
+
+// RecordDownloadDuration records how long a file download took
+func (m *Metrics) RecordDownloadDuration(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloadSeconds.observe(d)
+}
+
 // GetStats returns a snapshot of current metrics
 func (m *Metrics) GetStats() MetricsSnapshot {
 	m.mu.RLock()
@@ -80,9 +176,15 @@ func (m *Metrics) GetStats() MetricsSnapshot {
 		TotalDownloads:        m.TotalDownloads,
 		BytesUploadedOriginal: m.BytesUploadedOriginal,
 		BytesStoredIPFS:       m.BytesStoredIPFS,
+		DeduplicatedUploads:   m.DeduplicatedUploads,
+		DeduplicatedBlocks:    m.DeduplicatedBlocks,
+		SpeculativeFetches:    m.SpeculativeFetches,
+		SpeculativeWins:       m.SpeculativeWins,
 		BlockReuseRate:        m.calculateBlockReuseRate(),
 		CacheHitRate:          m.calculateCacheHitRate(),
 		StorageEfficiency:     m.calculateStorageEfficiency(),
+		UploadSeconds:         m.uploadSeconds.snapshot(),
+		DownloadSeconds:       m.downloadSeconds.snapshot(),
 	}
 }
 
@@ -96,9 +198,16 @@ type MetricsSnapshot struct {
 	TotalDownloads        int64   `json:"total_downloads"`
 	BytesUploadedOriginal int64   `json:"bytes_uploaded_original"`
 	BytesStoredIPFS       int64   `json:"bytes_stored_ipfs"`
+	DeduplicatedUploads   int64   `json:"deduplicated_uploads"`
+	DeduplicatedBlocks    int64   `json:"deduplicated_blocks"`
+	SpeculativeFetches    int64   `json:"speculative_fetches"`
+	SpeculativeWins       int64   `json:"speculative_wins"`
 	BlockReuseRate        float64 `json:"block_reuse_rate"`
 	CacheHitRate          float64 `json:"cache_hit_rate"`
 	StorageEfficiency     float64 `json:"storage_efficiency"`
+
+	UploadSeconds   HistogramSnapshot `json:"upload_seconds"`
+	DownloadSeconds HistogramSnapshot `json:"download_seconds"`
 }
 
 // calculateBlockReuseRate returns the percentage of blocks that were reused
@@ -0,0 +1,185 @@
+package noisefs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+)
+
+// PackFile is a small file to be stored in a pack
+type PackFile struct {
+	Name string
+	Data []byte
+}
+
+// UploadPack stores small files back to back in shared blocks and returns
+// the CID of a pack descriptor recording where each file starts. Every
+// file uploaded on its own costs at least one block triple, so packing
+// tiny files cuts their storage by up to the number of files per block.
+// The pack's block size comes from the client's block size policy.
+func (c *Client) UploadPack(ctx context.Context, name string, files []PackFile) (string, error) {
+	if err := validateFilename(name); err != nil {
+		return "", fmt.Errorf("invalid pack name: %w", err)
+	}
+	if len(files) == 0 {
+		return "", errors.New("pack must contain at least one file")
+	}
+
+	// Pack what the content processors make of each file
+	if c.processors != nil {
+		processed := make([]PackFile, len(files))
+		for i, file := range files {
+			data, err := c.processors.ProcessBytes(ctx, processors.StageUpload, file.Name, file.Data)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", file.Name, err)
+			}
+			processed[i] = PackFile{Name: file.Name, Data: data}
+		}
+		files = processed
+	}
+
+	var total int64
+	for _, file := range files {
+		total += int64(len(file.Data))
+	}
+	if total == 0 {
+		return "", errors.New("pack must contain some data")
+	}
+	if total > MaxFileSize {
+		return "", fmt.Errorf("pack size %d exceeds maximum allowed size %d", total, MaxFileSize)
+	}
+
+	descriptor := descriptors.NewPackDescriptor(name, c.BlockSizeFor(name, total))
+	readers := make([]io.Reader, 0, len(files))
+	for _, file := range files {
+		if err := validateFilename(file.Name); err != nil {
+			return "", fmt.Errorf("invalid packed filename %q: %w", file.Name, err)
+		}
+		if _, err := descriptor.AddPackEntry(file.Name, int64(len(file.Data))); err != nil {
+			return "", err
+		}
+		readers = append(readers, bytes.NewReader(file.Data))
+	}
+
+	return c.uploadToDescriptor(ctx, io.MultiReader(readers...), descriptor, nil)
+}
+
+// LoadPack returns the descriptor of a pack, whose Entries list its files
+func (c *Client) LoadPack(ctx context.Context, packCID string) (*descriptors.Descriptor, error) {
+	if err := validateCID(packCID); err != nil {
+		return nil, fmt.Errorf("invalid pack CID: %w", err)
+	}
+
+	descriptorStore, err := descriptors.NewStoreWithManager(c.storageManager)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create descriptor store: %w", err)
+	}
+	descriptor, err := descriptorStore.LoadContext(ctx, packCID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pack descriptor: %w", err)
+	}
+	if !descriptor.IsPack() {
+		return nil, fmt.Errorf("%s is a %s descriptor, not a pack", packCID, descriptor.Type)
+	}
+	return descriptor, nil
+}
+
+// DownloadPackedFile returns one file of a pack, retrieving only the
+// blocks that hold its data
+func (c *Client) DownloadPackedFile(ctx context.Context, packCID string, filename string) ([]byte, error) {
+	started := time.Now()
+	descriptor, err := c.LoadPack(ctx, packCID)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := descriptor.FindPackEntry(filename)
+	if !ok {
+		return nil, fmt.Errorf("pack %s does not contain %s", packCID, filename)
+	}
+
+	data, err := c.readPackEntry(ctx, packCID, descriptor, entry, make(map[int][]byte))
+	if err != nil {
+		return nil, err
+	}
+
+	c.RecordDownload()
+	c.RecordDownloadDuration(time.Since(started))
+	return c.processors.ProcessBytes(ctx, processors.StageDownload, filename, data)
+}
+
+// DownloadPackedFiles returns the files of a pack that filter selects, in
+// pack order. Only the blocks holding them are retrieved, each once even
+// when neighbouring files share it; a nil filter returns every file.
+func (c *Client) DownloadPackedFiles(ctx context.Context, packCID string, filter *descriptors.PathFilter) ([]PackFile, error) {
+	started := time.Now()
+	descriptor, err := c.LoadPack(ctx, packCID)
+	if err != nil {
+		return nil, err
+	}
+	entries := descriptor.MatchingEntries(filter)
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no files in pack %s match the path filter", packCID)
+	}
+
+	reconstructed := make(map[int][]byte)
+	files := make([]PackFile, 0, len(entries))
+	for _, entry := range entries {
+		data, err := c.readPackEntry(ctx, packCID, descriptor, entry, reconstructed)
+		if err != nil {
+			return nil, err
+		}
+		data, err = c.processors.ProcessBytes(ctx, processors.StageDownload, entry.Filename, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Filename, err)
+		}
+		files = append(files, PackFile{Name: entry.Filename, Data: data})
+	}
+
+	c.RecordDownload()
+	c.RecordDownloadDuration(time.Since(started))
+	return files, nil
+}
+
+// readPackEntry returns the data of one packed file, reconstructing the
+// blocks that hold it. Blocks are kept in reconstructed by index for
+// the entries that follow.
+func (c *Client) readPackEntry(ctx context.Context, packCID string, descriptor *descriptors.Descriptor, entry descriptors.PackEntry, reconstructed map[int][]byte) ([]byte, error) {
+	if entry.Size == 0 {
+		return []byte{}, nil
+	}
+
+	first, last := descriptor.PackEntryBlocks(entry)
+	if last > len(descriptor.Blocks) {
+		return nil, fmt.Errorf("pack %s is missing blocks for %s", packCID, entry.Filename)
+	}
+
+	data := make([]byte, 0, (last-first)*descriptor.BlockSize)
+	for i := first; i < last; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if _, ok := reconstructed[i]; !ok {
+			block, err := c.reconstructBlock(ctx, descriptor.Blocks[i], nil)
+			if err != nil {
+				return nil, err
+			}
+			reconstructed[i] = block.Data
+		}
+		data = append(data, reconstructed[i]...)
+	}
+
+	start := entry.Offset - int64(first*descriptor.BlockSize)
+	if start+entry.Size > int64(len(data)) {
+		return nil, fmt.Errorf("pack %s has short blocks for %s", packCID, entry.Filename)
+	}
+	return data[start : start+entry.Size], nil
+}
@@ -0,0 +1,108 @@
+package noisefs
+
+import (
+	"context"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// SetSpeculativeFetch asks a second backend for any block the first hasn't
+// returned within delay, and uses whichever answers first. This cuts the
+// tail latency of downloads on lossy networks at the cost of fetching slow
+// blocks twice. It needs two connected backends; zero disables it.
+func (c *Client) SetSpeculativeFetch(delay time.Duration) {
+	c.speculativeDelay = delay
+}
+
+// fetchResult is the outcome of one request for a block
+type fetchResult struct {
+	block       *blocks.Block
+	err         error
+	speculative bool
+}
+
+// retrieveSpeculatively asks the first backend by priority for a block,
+// falling back to the storage manager's routing if it fails, and races the
+// highest-priority healthy backend other than that one once
+// speculativeDelay passes without an answer. The first block returned wins
+// and the other request is cancelled.
+func (c *Client) retrieveSpeculatively(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	backends := c.storageManager.GetBackendsByPriority()
+	if len(backends) == 0 {
+		return c.storageManager.Get(ctx, address)
+	}
+	primary := backends[0]
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan fetchResult, 2)
+	go func() {
+		block, err := c.storageManager.GetFrom(ctx, primary, address)
+		if err != nil && ctx.Err() == nil {
+			block, err = c.storageManager.Get(ctx, address)
+		}
+		results <- fetchResult{block: block, err: err}
+	}()
+
+	timer := time.NewTimer(c.speculativeDelay)
+	defer timer.Stop()
+
+	pending, speculating := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			second := c.speculativeBackend(primary)
+			if second == nil {
+				continue
+			}
+			speculating = true
+			pending++
+			go func() {
+				block, err := c.storageManager.GetFrom(ctx, second, address)
+				results <- fetchResult{block: block, err: err, speculative: true}
+			}()
+
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if speculating {
+					c.metrics.RecordSpeculativeFetch(result.speculative)
+				}
+				return result.block, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if pending == 0 {
+				if speculating {
+					c.metrics.RecordSpeculativeFetch(false)
+				}
+				return nil, firstErr
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// speculativeBackend returns the highest-priority healthy backend other
+// than primary, or nil if there is none
+func (c *Client) speculativeBackend(primary storage.Backend) storage.Backend {
+	healthy := c.storageManager.GetHealthyBackends()
+	for _, backend := range c.storageManager.GetBackendsByPriority() {
+		if backend == primary {
+			continue
+		}
+		for _, candidate := range healthy {
+			if candidate == backend {
+				return backend
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// GroupKeyEnvelopeVersion is the current format version of GroupKeyEnvelope
+const GroupKeyEnvelopeVersion = 1
+
+// ErrNotGroupMember is returned when an identity has no wrapped key in an envelope
+var ErrNotGroupMember = errors.New("identity is not a member of this group")
+
+// GroupIdentity is an X25519 key pair used to receive group keys.
+// The public key is shared with group owners; the private key never leaves the device.
+type GroupIdentity struct {
+	PublicKey  []byte `json:"public_key"`
+	PrivateKey []byte `json:"private_key"`
+}
+
+// WrappedGroupKey is a group key encrypted for a single member's public key
+type WrappedGroupKey struct {
+	MemberID     string `json:"member_id"`
+	EphemeralKey []byte `json:"ephemeral_key"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// GroupKeyEnvelope carries the key of an encrypted directory snapshot wrapped
+// for every member of a private group. Each membership change produces a new
+// envelope with a fresh key and an incremented epoch, linked to the previous one.
+type GroupKeyEnvelope struct {
+	Version          int               `json:"version"`
+	SnapshotCID      string            `json:"snapshot_cid"`
+	Epoch            uint64            `json:"epoch"`
+	PreviousEnvelope string            `json:"previous_envelope,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	Members          []WrappedGroupKey `json:"members"`
+}
+
+// GenerateGroupIdentity generates a new X25519 identity key pair
+func GenerateGroupIdentity() (*GroupIdentity, error) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+
+	return &GroupIdentity{
+		PublicKey:  privateKey.PublicKey().Bytes(),
+		PrivateKey: privateKey.Bytes(),
+	}, nil
+}
+
+// PublicKeyString returns the base64-encoded public key to share with group owners
+func (id *GroupIdentity) PublicKeyString() string {
+	return base64.StdEncoding.EncodeToString(id.PublicKey)
+}
+
+// MemberID returns the identifier of this identity within group envelopes
+func (id *GroupIdentity) MemberID() string {
+	return GroupMemberID(id.PublicKey)
+}
+
+// ParseGroupPublicKey parses and validates a base64-encoded member public key
+func ParseGroupPublicKey(keyStr string) ([]byte, error) {
+	if keyStr == "" {
+		return nil, fmt.Errorf("public key cannot be empty")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(keyStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+
+	if _, err := ecdh.X25519().NewPublicKey(data); err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	return data, nil
+}
+
+// GroupMemberID derives a short stable identifier from a member public key
+func GroupMemberID(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateGroupKey generates a random key for encrypting a group snapshot
+func GenerateGroupKey() (*EncryptionKey, error) {
+	key, err := SecureRandom(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate group key: %w", err)
+	}
+
+	salt, err := SecureRandom(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate group key salt: %w", err)
+	}
+
+	return &EncryptionKey{
+		Key:  key,
+		Salt: salt,
+	}, nil
+}
+
+// WrapGroupKey encrypts key for the holder of memberPublicKey using an
+// ephemeral X25519 exchange and AES-256-GCM
+func WrapGroupKey(key *EncryptionKey, memberPublicKey []byte) (*WrappedGroupKey, error) {
+	if key == nil {
+		return nil, fmt.Errorf("group key cannot be nil")
+	}
+
+	memberKey, err := ecdh.X25519().NewPublicKey(memberPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid member public key: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	shared, err := ephemeral.ECDH(memberKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer SecureZero(shared)
+
+	wrappingKey, err := deriveWrappingKey(shared, ephemeral.PublicKey().Bytes(), memberPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	defer SecureZero(wrappingKey.Key)
+
+	ciphertext, err := Encrypt([]byte(key.String()), wrappingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap group key: %w", err)
+	}
+
+	return &WrappedGroupKey{
+		MemberID:     GroupMemberID(memberPublicKey),
+		EphemeralKey: ephemeral.PublicKey().Bytes(),
+		Ciphertext:   ciphertext,
+	}, nil
+}
+
+// UnwrapGroupKey decrypts a wrapped group key with the member's identity
+func UnwrapGroupKey(wrapped *WrappedGroupKey, identity *GroupIdentity) (*EncryptionKey, error) {
+	if wrapped == nil || identity == nil {
+		return nil, fmt.Errorf("wrapped key and identity are required")
+	}
+
+	privateKey, err := ecdh.X25519().NewPrivateKey(identity.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity private key: %w", err)
+	}
+
+	ephemeralKey, err := ecdh.X25519().NewPublicKey(wrapped.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+
+	shared, err := privateKey.ECDH(ephemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", err)
+	}
+	defer SecureZero(shared)
+
+	wrappingKey, err := deriveWrappingKey(shared, wrapped.EphemeralKey, privateKey.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer SecureZero(wrappingKey.Key)
+
+	plaintext, err := Decrypt(wrapped.Ciphertext, wrappingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap group key: %w", err)
+	}
+
+	return ParseKeyFromString(string(plaintext))
+}
+
+// deriveWrappingKey derives the AES key that wraps a group key for one member
+func deriveWrappingKey(shared, ephemeralPublicKey, memberPublicKey []byte) (*EncryptionKey, error) {
+	info := make([]byte, 0, len("noisefs-group-key:")+len(ephemeralPublicKey)+len(memberPublicKey))
+	info = append(info, "noisefs-group-key:"...)
+	info = append(info, ephemeralPublicKey...)
+	info = append(info, memberPublicKey...)
+
+	reader := hkdf.New(sha256.New, shared, nil, info)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return nil, fmt.Errorf("failed to derive wrapping key: %w", err)
+	}
+
+	return &EncryptionKey{Key: key}, nil
+}
+
+// NewGroupKeyEnvelope wraps key for each member public key. Duplicate members are ignored.
+func NewGroupKeyEnvelope(key *EncryptionKey, memberPublicKeys [][]byte, epoch uint64) (*GroupKeyEnvelope, error) {
+	if len(memberPublicKeys) == 0 {
+		return nil, fmt.Errorf("group must have at least one member")
+	}
+
+	envelope := &GroupKeyEnvelope{
+		Version:   GroupKeyEnvelopeVersion,
+		Epoch:     epoch,
+		CreatedAt: time.Now(),
+		Members:   make([]WrappedGroupKey, 0, len(memberPublicKeys)),
+	}
+
+	seen := make(map[string]bool, len(memberPublicKeys))
+	for _, publicKey := range memberPublicKeys {
+		memberID := GroupMemberID(publicKey)
+		if seen[memberID] {
+			continue
+		}
+		seen[memberID] = true
+
+		wrapped, err := WrapGroupKey(key, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap key for member %s: %w", memberID, err)
+		}
+		envelope.Members = append(envelope.Members, *wrapped)
+	}
+
+	// Sort so that envelope layout does not reveal the order members were added
+	sort.Slice(envelope.Members, func(i, j int) bool {
+		return envelope.Members[i].MemberID < envelope.Members[j].MemberID
+	})
+
+	return envelope, nil
+}
+
+// Unwrap returns the group key for identity, or ErrNotGroupMember
+func (e *GroupKeyEnvelope) Unwrap(identity *GroupIdentity) (*EncryptionKey, error) {
+	if identity == nil {
+		return nil, fmt.Errorf("identity is required")
+	}
+
+	memberID := identity.MemberID()
+	for i := range e.Members {
+		if e.Members[i].MemberID == memberID {
+			return UnwrapGroupKey(&e.Members[i], identity)
+		}
+	}
+
+	return nil, ErrNotGroupMember
+}
+
+// MemberIDs returns the identifiers of all members in the envelope
+func (e *GroupKeyEnvelope) MemberIDs() []string {
+	ids := make([]string, 0, len(e.Members))
+	for _, member := range e.Members {
+		ids = append(ids, member.MemberID)
+	}
+	return ids
+}
+
+// HasMember reports whether the envelope contains a wrapped key for memberID
+func (e *GroupKeyEnvelope) HasMember(memberID string) bool {
+	for _, member := range e.Members {
+		if member.MemberID == memberID {
+			return true
+		}
+	}
+	return false
+}
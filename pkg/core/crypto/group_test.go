@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGroupKeyWrapUnwrap(t *testing.T) {
+	member, err := GenerateGroupIdentity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+
+	groupKey, err := GenerateGroupKey()
+	if err != nil {
+		t.Fatalf("Failed to generate group key: %v", err)
+	}
+
+	wrapped, err := WrapGroupKey(groupKey, member.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to wrap group key: %v", err)
+	}
+	if wrapped.MemberID != member.MemberID() {
+		t.Errorf("Expected member ID %s, got %s", member.MemberID(), wrapped.MemberID)
+	}
+
+	unwrapped, err := UnwrapGroupKey(wrapped, member)
+	if err != nil {
+		t.Fatalf("Failed to unwrap group key: %v", err)
+	}
+	if !bytes.Equal(unwrapped.Key, groupKey.Key) || !bytes.Equal(unwrapped.Salt, groupKey.Salt) {
+		t.Error("Unwrapped key does not match original")
+	}
+
+	// A different identity cannot unwrap the key
+	other, err := GenerateGroupIdentity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	if _, err := UnwrapGroupKey(wrapped, other); err == nil {
+		t.Error("Expected unwrap with wrong identity to fail")
+	}
+}
+
+func TestGroupKeyEnvelope(t *testing.T) {
+	alice, _ := GenerateGroupIdentity()
+	bob, _ := GenerateGroupIdentity()
+	mallory, _ := GenerateGroupIdentity()
+
+	groupKey, err := GenerateGroupKey()
+	if err != nil {
+		t.Fatalf("Failed to generate group key: %v", err)
+	}
+
+	envelope, err := NewGroupKeyEnvelope(groupKey, [][]byte{alice.PublicKey, bob.PublicKey, alice.PublicKey}, 3)
+	if err != nil {
+		t.Fatalf("Failed to create envelope: %v", err)
+	}
+
+	if len(envelope.Members) != 2 {
+		t.Errorf("Expected duplicate members to be ignored, got %d members", len(envelope.Members))
+	}
+	if envelope.Epoch != 3 {
+		t.Errorf("Expected epoch 3, got %d", envelope.Epoch)
+	}
+	if !envelope.HasMember(bob.MemberID()) || envelope.HasMember(mallory.MemberID()) {
+		t.Error("Unexpected envelope membership")
+	}
+
+	for _, member := range []*GroupIdentity{alice, bob} {
+		key, err := envelope.Unwrap(member)
+		if err != nil {
+			t.Fatalf("Member failed to unwrap key: %v", err)
+		}
+		if !bytes.Equal(key.Key, groupKey.Key) {
+			t.Error("Member unwrapped wrong key")
+		}
+	}
+
+	if _, err := envelope.Unwrap(mallory); !errors.Is(err, ErrNotGroupMember) {
+		t.Errorf("Expected ErrNotGroupMember, got %v", err)
+	}
+
+	if _, err := NewGroupKeyEnvelope(groupKey, nil, 1); err == nil {
+		t.Error("Expected error for empty group")
+	}
+}
+
+func TestParseGroupPublicKey(t *testing.T) {
+	identity, err := GenerateGroupIdentity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+
+	parsed, err := ParseGroupPublicKey(identity.PublicKeyString())
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	if !bytes.Equal(parsed, identity.PublicKey) {
+		t.Error("Parsed public key does not match")
+	}
+
+	for _, invalid := range []string{"", "not-base64!", "c2hvcnQ="} {
+		if _, err := ParseGroupPublicKey(invalid); err == nil {
+			t.Errorf("Expected error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package descriptors
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// BundleVersion is the format version written to bundle manifests
+const BundleVersion = "1.0"
+
+const (
+	bundleManifestName = "manifest.json"
+	bundleBlockDir     = "blocks/"
+
+	// maxBundleBlockSize bounds how much of one archive entry is read, so a
+	// corrupt or hostile bundle can't exhaust memory
+	maxBundleBlockSize = 16 * 1024 * 1024
+)
+
+// BundleManifest lists the contents of a bundle. It is stored as
+// manifest.json next to a blocks/ directory holding each block's raw data
+// under its CID, so bundles can be inspected with any zip tool.
+type BundleManifest struct {
+	Version     string             `json:"version"`
+	Created     time.Time          `json:"created"`
+	Descriptors []BundleDescriptor `json:"descriptors"`
+	BlockCount  int                `json:"block_count"`
+	TotalBytes  int64              `json:"total_bytes"`
+}
+
+// BundleDescriptor describes one exported descriptor
+type BundleDescriptor struct {
+	CID       string         `json:"cid"`
+	Filename  string         `json:"filename"`
+	Type      DescriptorType `json:"type"`
+	FileSize  int64          `json:"file_size"`
+	Encrypted bool           `json:"encrypted"`
+	Blocks    int            `json:"blocks"` // Unique blocks referenced, including randomizers
+}
+
+// BundleImportResult summarizes an import
+type BundleImportResult struct {
+	Descriptors []BundleDescriptor `json:"descriptors"`
+	Imported    int                `json:"imported"` // Blocks stored
+	Existing    int                `json:"existing"` // Blocks the backend already had
+	Bytes       int64              `json:"bytes"`    // Bytes stored
+}
+
+// ExportBundle writes the given descriptors and every block they reference
+// to w as a zip bundle. Descriptors are copied byte for byte so their CIDs
+// stay valid; password is only used to read the block lists of encrypted
+// descriptors. Directory descriptors include their manifest block but not
+// the descriptors of their entries, which must be exported separately.
+func ExportBundle(ctx context.Context, storageManager *storage.Manager, w io.Writer, cids []string, password string) (*BundleManifest, error) {
+	if len(cids) == 0 {
+		return nil, errors.New("at least one descriptor CID is required")
+	}
+
+	store, err := NewEncryptedStoreWithPassword(storageManager, password)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BundleManifest{Version: BundleVersion, Created: time.Now()}
+	archive := zip.NewWriter(w)
+	written := make(map[string]bool)
+
+	writeBlock := func(cid string, data []byte) error {
+		if written[cid] {
+			return nil
+		}
+		// Block data is already random, so compressing it is wasted effort
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     bundleBlockDir + cid,
+			Method:   zip.Store,
+			Modified: manifest.Created,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+		written[cid] = true
+		manifest.BlockCount++
+		manifest.TotalBytes += int64(len(data))
+		return nil
+	}
+
+	for _, cid := range cids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		raw, err := storageManager.Get(ctx, &storage.BlockAddress{ID: cid})
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve descriptor %s: %w", cid, err)
+		}
+		descriptor, err := store.parse(raw.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read descriptor %s: %w", cid, err)
+		}
+		if err := writeBlock(cid, raw.Data); err != nil {
+			return nil, fmt.Errorf("failed to write descriptor %s: %w", cid, err)
+		}
+
+		referenced := descriptorBlockCIDs(descriptor)
+		for _, blockCID := range referenced {
+			if written[blockCID] {
+				continue
+			}
+			block, err := storageManager.Get(ctx, &storage.BlockAddress{ID: blockCID})
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve block %s of %s: %w", blockCID, cid, err)
+			}
+			if err := writeBlock(blockCID, block.Data); err != nil {
+				return nil, fmt.Errorf("failed to write block %s: %w", blockCID, err)
+			}
+		}
+
+		manifest.Descriptors = append(manifest.Descriptors, BundleDescriptor{
+			CID:       cid,
+			Filename:  descriptor.Filename,
+			Type:      descriptor.Type,
+			FileSize:  descriptor.FileSize,
+			Encrypted: isEncryptedDescriptor(raw.Data),
+			Blocks:    len(referenced),
+		})
+	}
+
+	entry, err := archive.Create(bundleManifestName)
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return manifest, nil
+}
+
+// ImportBundle stores every block of a bundle in the local backend,
+// checking that each one is stored under the CID it was exported with.
+// Blocks the backend already has are skipped. With pin set, all blocks are
+// pinned so the imported files stay available.
+func ImportBundle(ctx context.Context, storageManager *storage.Manager, r io.ReaderAt, size int64, pin bool) (*BundleImportResult, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	manifest, err := readBundleManifest(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool)
+	result := &BundleImportResult{Descriptors: manifest.Descriptors}
+	for _, file := range archive.File {
+		if !strings.HasPrefix(file.Name, bundleBlockDir) || file.FileInfo().IsDir() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		cid := path.Base(file.Name)
+		address := &storage.BlockAddress{ID: cid}
+		present[cid] = true
+
+		exists, err := storageManager.Has(ctx, address)
+		if err == nil && exists {
+			result.Existing++
+		} else {
+			data, err := readBundleEntry(file)
+			if err != nil {
+				return result, fmt.Errorf("failed to read block %s: %w", cid, err)
+			}
+			block, err := blocks.NewBlock(data)
+			if err != nil {
+				return result, fmt.Errorf("invalid block %s: %w", cid, err)
+			}
+			stored, err := storageManager.Put(ctx, block)
+			if err != nil {
+				return result, fmt.Errorf("failed to store block %s: %w", cid, err)
+			}
+			if stored.ID != cid {
+				return result, fmt.Errorf("block %s was stored as %s; the backend must use the same CID settings as the exporting node", cid, stored.ID)
+			}
+			result.Imported++
+			result.Bytes += int64(len(data))
+		}
+
+		if pin {
+			if err := storageManager.Pin(ctx, address); err != nil {
+				return result, fmt.Errorf("failed to pin block %s: %w", cid, err)
+			}
+		}
+	}
+
+	for _, descriptor := range manifest.Descriptors {
+		if !present[descriptor.CID] {
+			return result, fmt.Errorf("bundle is missing descriptor block %s", descriptor.CID)
+		}
+	}
+	return result, nil
+}
+
+// ReadBundleManifest returns the manifest of a bundle without importing it
+func ReadBundleManifest(r io.ReaderAt, size int64) (*BundleManifest, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	return readBundleManifest(archive)
+}
+
+func readBundleManifest(archive *zip.Reader) (*BundleManifest, error) {
+	for _, file := range archive.File {
+		if file.Name != bundleManifestName {
+			continue
+		}
+		data, err := readBundleEntry(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+		}
+		var manifest BundleManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+		}
+		if manifest.Version != BundleVersion {
+			return nil, fmt.Errorf("unsupported bundle version %q", manifest.Version)
+		}
+		return &manifest, nil
+	}
+	return nil, errors.New("bundle has no manifest.json")
+}
+
+func readBundleEntry(file *zip.File) ([]byte, error) {
+	if file.UncompressedSize64 > maxBundleBlockSize {
+		return nil, fmt.Errorf("entry is %d bytes, over the %d byte limit", file.UncompressedSize64, maxBundleBlockSize)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxBundleBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxBundleBlockSize {
+		return nil, fmt.Errorf("entry is over the %d byte limit", maxBundleBlockSize)
+	}
+	return data, nil
+}
+
+// descriptorBlockCIDs returns the unique CIDs a descriptor references, in
+// a stable order
+func descriptorBlockCIDs(descriptor *Descriptor) []string {
+	seen := make(map[string]bool)
+	if descriptor.ManifestCID != "" {
+		seen[descriptor.ManifestCID] = true
+	}
+	for _, triple := range descriptor.Blocks {
+		seen[triple.DataCID] = true
+		seen[triple.RandomizerCID1] = true
+		seen[triple.RandomizerCID2] = true
+	}
+
+	cids := make([]string, 0, len(seen))
+	for cid := range seen {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+	return cids
+}
+
+// Roles a block plays in a descriptor. The descriptor block itself isn't
+// among its references but is reported as such where it is listed with them.
+const (
+	BlockRoleDescriptor = "descriptor"
+	BlockRoleManifest   = "manifest"
+	BlockRoleData       = "data"
+	BlockRoleRandomizer = "randomizer"
+)
+
+// BlockReference is a block a descriptor references and the role it plays
+type BlockReference struct {
+	CID  string `json:"cid"`
+	Role string `json:"role"`
+}
+
+// BlockReferences returns the unique blocks a descriptor references, in a
+// stable order. A block that is the data of one triple and a randomizer of
+// another is reported as data, since losing it loses the file either way.
+func (d *Descriptor) BlockReferences() []BlockReference {
+	roles := make(map[string]string)
+	if d.ManifestCID != "" {
+		roles[d.ManifestCID] = BlockRoleManifest
+	}
+	for _, triple := range d.Blocks {
+		roles[triple.DataCID] = BlockRoleData
+	}
+	for _, triple := range d.Blocks {
+		for _, cid := range []string{triple.RandomizerCID1, triple.RandomizerCID2} {
+			if _, ok := roles[cid]; !ok {
+				roles[cid] = BlockRoleRandomizer
+			}
+		}
+	}
+
+	references := make([]BlockReference, 0, len(roles))
+	for _, cid := range descriptorBlockCIDs(d) {
+		references = append(references, BlockReference{CID: cid, Role: roles[cid]})
+	}
+	return references
+}
+
+// isEncryptedDescriptor reports whether stored descriptor data is a
+// password protected envelope
+func isEncryptedDescriptor(data []byte) bool {
+	var envelope EncryptedDescriptor
+	return json.Unmarshal(data, &envelope) == nil && envelope.Version == "3.0" && envelope.IsEncrypted
+}
+
+// isDescriptorEnvelope reports whether stored descriptor data uses the
+// EncryptedStore format, encrypted or not
+func isDescriptorEnvelope(data []byte) bool {
+	var envelope EncryptedDescriptor
+	return json.Unmarshal(data, &envelope) == nil && envelope.Version == "3.0"
+}
@@ -0,0 +1,158 @@
+package descriptors
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	_ "github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+)
+
+func newBundleTestManager(t *testing.T) *storage.Manager {
+	t.Helper()
+	config := storage.DefaultConfig()
+	config.DefaultBackend = "mock"
+	config.Backends = map[string]*storage.BackendConfig{
+		"mock": {
+			Type:       "mock",
+			Enabled:    true,
+			Priority:   100,
+			Connection: &storage.ConnectionConfig{Endpoint: "mock://test"},
+		},
+	}
+	manager, err := storage.NewManager(config)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { manager.Stop(context.Background()) })
+	return manager
+}
+
+func putTestBlock(t *testing.T, manager *storage.Manager, fill byte) string {
+	t.Helper()
+	block, err := blocks.NewBlock(bytes.Repeat([]byte{fill}, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := manager.Put(context.Background(), block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	return address.ID
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newBundleTestManager(t)
+
+	// Two files sharing a randomizer, one with an encrypted descriptor
+	shared := putTestBlock(t, source, 1)
+	public := NewDescriptor("public.txt", 100, 128, 64)
+	public.AddBlockTriple(putTestBlock(t, source, 2), shared, putTestBlock(t, source, 3))
+	public.AddBlockTriple(putTestBlock(t, source, 4), shared, putTestBlock(t, source, 5))
+	private := NewDescriptor("private.txt", 50, 64, 64)
+	private.AddBlockTriple(putTestBlock(t, source, 6), shared, putTestBlock(t, source, 7))
+
+	store, err := NewEncryptedStoreWithPassword(source, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicCID, err := store.SaveUnencrypted(public)
+	if err != nil {
+		t.Fatalf("SaveUnencrypted failed: %v", err)
+	}
+	privateCID, err := store.Save(private)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := ExportBundle(ctx, source, &bytes.Buffer{}, []string{privateCID}, ""); err == nil {
+		t.Error("Expected export of an encrypted descriptor without a password to fail")
+	}
+
+	var bundle bytes.Buffer
+	manifest, err := ExportBundle(ctx, source, &bundle, []string{publicCID, privateCID}, "hunter2")
+	if err != nil {
+		t.Fatalf("ExportBundle failed: %v", err)
+	}
+	// 7 unique data and randomizer blocks plus the 2 descriptors
+	if manifest.BlockCount != 9 || len(manifest.Descriptors) != 2 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if manifest.Descriptors[0].Encrypted || !manifest.Descriptors[1].Encrypted {
+		t.Errorf("Unexpected encryption flags: %+v", manifest.Descriptors)
+	}
+
+	reader := bytes.NewReader(bundle.Bytes())
+	listed, err := ReadBundleManifest(reader, reader.Size())
+	if err != nil || len(listed.Descriptors) != 2 {
+		t.Fatalf("ReadBundleManifest failed: %v, %+v", err, listed)
+	}
+
+	target := newBundleTestManager(t)
+	result, err := ImportBundle(ctx, target, reader, reader.Size(), true)
+	if err != nil {
+		t.Fatalf("ImportBundle failed: %v", err)
+	}
+	if result.Imported != 9 || result.Existing != 0 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	// The imported descriptors load and their blocks are present
+	targetStore, err := NewEncryptedStoreWithPassword(target, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := targetStore.Load(privateCID)
+	if err != nil {
+		t.Fatalf("Load after import failed: %v", err)
+	}
+	if loaded.Filename != "private.txt" {
+		t.Errorf("Unexpected descriptor after import: %+v", loaded)
+	}
+	for _, cid := range descriptorBlockCIDs(public) {
+		if has, _ := target.Has(ctx, &storage.BlockAddress{ID: cid}); !has {
+			t.Errorf("Block %s missing after import", cid)
+		}
+	}
+
+	// Importing again stores nothing new
+	result, err = ImportBundle(ctx, target, reader, reader.Size(), false)
+	if err != nil {
+		t.Fatalf("Second ImportBundle failed: %v", err)
+	}
+	if result.Imported != 0 || result.Existing != 9 {
+		t.Errorf("Unexpected second import result: %+v", result)
+	}
+
+	if _, err := ImportBundle(ctx, target, bytes.NewReader([]byte("not a zip")), 9, false); err == nil {
+		t.Error("Expected an error for a corrupt bundle")
+	}
+}
+
+func TestBlockReferences(t *testing.T) {
+	descriptor := NewDescriptor("file.txt", 100, 128, 64)
+	descriptor.AddBlockTriple("data-1", "rand-a", "rand-b")
+	descriptor.AddBlockTriple("data-2", "rand-a", "data-1")
+
+	want := []BlockReference{
+		{CID: "data-1", Role: BlockRoleData},
+		{CID: "data-2", Role: BlockRoleData},
+		{CID: "rand-a", Role: BlockRoleRandomizer},
+		{CID: "rand-b", Role: BlockRoleRandomizer},
+	}
+	got := descriptor.BlockReferences()
+	if len(got) != len(want) {
+		t.Fatalf("BlockReferences() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("reference %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package descriptors
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+const (
+	// DefaultCacheSize is the number of descriptors DefaultCache holds
+	DefaultCacheSize = 1024
+
+	// DefaultNegativeTTL is how long DefaultCache remembers failed lookups
+	DefaultNegativeTTL = 10 * time.Second
+)
+
+// DefaultCache is shared by every Store and EncryptedStore, so a file's
+// descriptor is fetched once even though callers such as the web UI create
+// a new store per request. Use SetCache on a store to change or disable it.
+var DefaultCache = NewCache(DefaultCacheSize, DefaultNegativeTTL)
+
+// Cache is an LRU of loaded descriptors keyed by CID. Descriptors are
+// content addressed, so an entry never goes stale. Failed lookups are
+// remembered for a short time as negative entries, so a missing
+// descriptor isn't requested from the network on every call; those are
+// kept per storage manager because another node may have the block.
+// Encrypted descriptors are cached in their stored form and decrypted on
+// each load, so no plaintext is kept for them.
+type Cache struct {
+	mu          sync.Mutex
+	capacity    int
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	order       *list.List
+}
+
+type cacheEntry struct {
+	key        string
+	data       []byte      // Stored form of the descriptor
+	descriptor *Descriptor // Parsed descriptor, nil when encrypted
+	envelope   bool        // Stored in the EncryptedStore format
+	err        error       // Set for negative entries
+	expires    time.Time   // Expiry of negative entries
+}
+
+// NewCache creates a descriptor cache holding up to capacity entries.
+// A negativeTTL of zero disables negative entries.
+func NewCache(capacity int, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		capacity:    capacity,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// Len returns the number of cached entries, including negative ones
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge removes all entries
+func (c *Cache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// get returns the entry for a CID, checking the manager's negative
+// entries when there is no descriptor
+func (c *Cache) get(manager *storage.Manager, cid string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[cid]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*cacheEntry), true
+	}
+
+	key := negativeKey(manager, cid)
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	return entry, true
+}
+
+// put caches a descriptor's stored form and, for plaintext descriptors,
+// its parsed form. Negative entries for the CID are dropped.
+func (c *Cache) put(manager *storage.Manager, cid string, data []byte, descriptor *Descriptor, envelope bool) {
+	if c == nil || c.capacity <= 0 {
+		return
+	}
+	if descriptor != nil {
+		descriptor = descriptor.clone()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[negativeKey(manager, cid)]; ok {
+		c.remove(element)
+	}
+	c.add(&cacheEntry{key: cid, data: data, descriptor: descriptor, envelope: envelope})
+}
+
+// putMissing records that a CID couldn't be retrieved from a manager
+func (c *Cache) putMissing(manager *storage.Manager, cid string, err error) {
+	if c == nil || c.capacity <= 0 || c.negativeTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(&cacheEntry{key: negativeKey(manager, cid), err: err, expires: time.Now().Add(c.negativeTTL)})
+}
+
+func (c *Cache) add(entry *cacheEntry) {
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+func negativeKey(manager *storage.Manager, cid string) string {
+	return fmt.Sprintf("missing:%p:%s", manager, cid)
+}
+
+// clone returns a copy that shares nothing mutable with the original, so
+// callers can't change cached descriptors
+func (d *Descriptor) clone() *Descriptor {
+	copied := *d
+	copied.Blocks = append([]BlockPair(nil), d.Blocks...)
+	copied.Entries = append([]PackEntry(nil), d.Entries...)
+	return &copied
+}
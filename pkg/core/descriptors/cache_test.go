@@ -0,0 +1,215 @@
+package descriptors
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+func TestCacheEviction(t *testing.T) {
+	cache := NewCache(2, time.Minute)
+	cache.put(nil, "a", []byte("a"), nil, false)
+	cache.put(nil, "b", []byte("b"), nil, false)
+
+	// Touching a makes b the least recently used
+	if _, ok := cache.get(nil, "a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.put(nil, "c", []byte("c"), nil, false)
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+	if _, ok := cache.get(nil, "b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, cid := range []string{"a", "c"} {
+		if _, ok := cache.get(nil, cid); !ok {
+			t.Errorf("expected %s to be cached", cid)
+		}
+	}
+
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache after Purge, got %d entries", cache.Len())
+	}
+}
+
+func TestStoreLoadUsesCache(t *testing.T) {
+	ctx := context.Background()
+	manager := newBundleTestManager(t)
+	store, err := NewStoreWithManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetCache(NewCache(16, time.Minute))
+
+	descriptor := NewDescriptor("cached.txt", 100, 128, 64)
+	descriptor.AddBlockTriple(putTestBlock(t, manager, 1), putTestBlock(t, manager, 2), putTestBlock(t, manager, 3))
+	cid, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A second store sharing the cache loads without the backend
+	if err := manager.Delete(ctx, &storage.BlockAddress{ID: cid}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	other, err := NewStoreWithManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetCache(store.cache)
+
+	loaded, err := other.Load(cid)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Filename != "cached.txt" || len(loaded.Blocks) != 1 {
+		t.Fatalf("unexpected descriptor: %+v", loaded)
+	}
+
+	// Changes by callers don't reach the cached copy
+	loaded.Filename = "changed.txt"
+	loaded.Blocks[0].DataCID = "changed"
+	again, err := other.Load(cid)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if again.Filename != "cached.txt" || again.Blocks[0].DataCID == "changed" {
+		t.Error("cached descriptor was modified through a loaded copy")
+	}
+
+	// Without a cache the deleted descriptor is gone
+	other.SetCache(nil)
+	if _, err := other.Load(cid); err == nil {
+		t.Error("expected Load without cache to fail")
+	}
+}
+
+func TestStoreNegativeEntries(t *testing.T) {
+	manager := newBundleTestManager(t)
+	store, err := NewStoreWithManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetCache(NewCache(16, 50*time.Millisecond))
+
+	descriptor := NewDescriptor("late.txt", 100, 128, 64)
+	descriptor.AddBlockTriple(putTestBlock(t, manager, 1), putTestBlock(t, manager, 2), putTestBlock(t, manager, 3))
+	data, err := descriptor.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := computeTestCID(t, data)
+
+	if _, err := store.Load(cid); err == nil {
+		t.Fatal("expected Load of a missing descriptor to fail")
+	}
+	if _, ok := store.cache.get(manager, cid); !ok {
+		t.Fatal("expected a negative entry for the missing descriptor")
+	}
+
+	// Negative entries are per manager
+	otherManager := newBundleTestManager(t)
+	if _, ok := store.cache.get(otherManager, cid); ok {
+		t.Error("negative entry should not apply to another manager")
+	}
+
+	// Saving the descriptor replaces the negative entry
+	saved, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved != cid {
+		t.Fatalf("expected CID %s, got %s", cid, saved)
+	}
+	if _, err := store.Load(cid); err != nil {
+		t.Errorf("Load after Save failed: %v", err)
+	}
+
+	// Negative entries expire
+	missing := computeTestCID(t, []byte("never stored"))
+	if _, err := store.Load(missing); err == nil {
+		t.Fatal("expected Load of a missing descriptor to fail")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := store.cache.get(manager, missing); ok {
+		t.Error("expected negative entry to expire")
+	}
+}
+
+func TestEncryptedStoreCachesStoredForm(t *testing.T) {
+	ctx := context.Background()
+	manager := newBundleTestManager(t)
+	cache := NewCache(16, time.Minute)
+
+	store, err := NewEncryptedStoreWithPassword(manager, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetCache(cache)
+
+	descriptor := NewDescriptor("private.txt", 100, 128, 64)
+	descriptor.AddBlockTriple(putTestBlock(t, manager, 1), putTestBlock(t, manager, 2), putTestBlock(t, manager, 3))
+	cid, err := store.Save(descriptor)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	entry, ok := cache.get(manager, cid)
+	if !ok {
+		t.Fatal("expected encrypted descriptor to be cached")
+	}
+	if entry.descriptor != nil {
+		t.Error("encrypted descriptor should not be cached in plaintext")
+	}
+
+	// Loads decrypt the cached data without the backend
+	if err := manager.Delete(ctx, &storage.BlockAddress{ID: cid}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	loaded, err := store.Load(cid)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Filename != "private.txt" {
+		t.Errorf("expected private.txt, got %s", loaded.Filename)
+	}
+
+	wrong, err := NewEncryptedStoreWithPassword(manager, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong.SetCache(cache)
+	if _, err := wrong.Load(cid); err == nil {
+		t.Error("expected Load with the wrong password to fail")
+	}
+
+	// The plain store still can't read the envelope
+	plain, err := NewStoreWithManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.SetCache(cache)
+	if _, err := plain.Load(cid); err == nil {
+		t.Error("expected plain store to reject an encrypted descriptor")
+	}
+}
+
+func computeTestCID(t *testing.T, data []byte) string {
+	t.Helper()
+	manager := newBundleTestManager(t)
+	block, err := blocks.NewBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := manager.Put(context.Background(), block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address.ID
+}
@@ -1,11 +1,29 @@
 package descriptors
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
 )
 
+// DescriptorVersion is the descriptor format written by this build.
+// Version 4.0 always includes padding.
+const DescriptorVersion = "4.0"
+
+// ErrUnsupportedVersion is returned for descriptors written in a format
+// newer than this build reads
+var ErrUnsupportedVersion = errors.New("descriptor was created by a newer version of NoiseFS; upgrade NoiseFS to read it")
+
+// ErrContentMismatch is returned for reassembled content that doesn't match
+// its descriptor's content hash, such as after a corrupted block was served
+var ErrContentMismatch = errors.New("content does not match the descriptor's hash")
+
 // BlockPair represents a data block and its corresponding randomizers (3-tuple)
 type BlockPair struct {
 	DataCID        string `json:"data_cid"`
@@ -21,6 +39,8 @@ const (
 	FileType DescriptorType = "file"
 	// DirectoryType represents a directory descriptor
 	DirectoryType DescriptorType = "directory"
+	// PackType represents many small files stored in shared blocks
+	PackType DescriptorType = "pack"
 )
 
 // Descriptor contains metadata needed to reconstruct a file or directory
@@ -30,16 +50,18 @@ type Descriptor struct {
 	Filename       string         `json:"filename"`
 	FileSize       int64          `json:"file_size"`        // Original file size (before padding)
 	PaddedFileSize int64          `json:"padded_file_size"` // Total size including padding
+	ContentHash    string         `json:"content_hash,omitempty"` // Hex SHA-256 of the file before padding; empty in older descriptors
 	BlockSize      int            `json:"block_size"`
 	Blocks         []BlockPair    `json:"blocks,omitempty"` // Empty for directories
 	ManifestCID    string         `json:"manifest_cid,omitempty"` // Only for directories
+	Entries        []PackEntry    `json:"entries,omitempty"`      // Only for packs
 	CreatedAt      time.Time      `json:"created_at"`
 }
 
 // NewDescriptor creates a new file descriptor with padding information
 func NewDescriptor(filename string, originalFileSize int64, paddedFileSize int64, blockSize int) *Descriptor {
 	return &Descriptor{
-		Version:        "4.0", // Version 4.0 - padding always included
+		Version:        DescriptorVersion,
 		Type:           FileType,
 		Filename:       filename,
 		FileSize:       originalFileSize,
@@ -53,7 +75,7 @@ func NewDescriptor(filename string, originalFileSize int64, paddedFileSize int64
 // NewDirectoryDescriptor creates a new directory descriptor
 func NewDirectoryDescriptor(dirname string, manifestCID string) *Descriptor {
 	return &Descriptor{
-		Version:        "4.0",
+		Version:        DescriptorVersion,
 		Type:           DirectoryType,
 		Filename:       dirname,
 		FileSize:       0,              // Directories don't have a fixed size
@@ -84,11 +106,37 @@ func (d *Descriptor) AddBlockTriple(dataCID, randomizerCID1, randomizerCID2 stri
 	return nil
 }
 
+// Verifiable reports whether the descriptor records a content hash that
+// reassembled content can be checked against
+func (d *Descriptor) Verifiable() bool {
+	return d.ContentHash != ""
+}
+
+// VerifyContent checks reassembled content, with the padding removed,
+// against the descriptor's content hash. Content of descriptors without a
+// hash can't be checked and passes.
+func (d *Descriptor) VerifyContent(content io.Reader) error {
+	if !d.Verifiable() {
+		return nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return fmt.Errorf("failed to read content to verify: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != d.ContentHash {
+		return fmt.Errorf("%w: expected SHA-256 %s, got %s", ErrContentMismatch, d.ContentHash, actual)
+	}
+	return nil
+}
+
 // Validate checks if the descriptor is valid
 func (d *Descriptor) Validate() error {
 	if d.Version == "" {
 		return errors.New("descriptor version is required")
 	}
+	if version.Newer(d.Version, DescriptorVersion) {
+		return fmt.Errorf("%w (descriptor version %s, this build reads %s)", ErrUnsupportedVersion, d.Version, DescriptorVersion)
+	}
 	
 	if d.Filename == "" {
 		return errors.New("filename is required")
@@ -100,6 +148,8 @@ func (d *Descriptor) Validate() error {
 		return d.validateFile()
 	case DirectoryType:
 		return d.validateDirectory()
+	case PackType:
+		return d.validatePack()
 	default:
 		return errors.New("unknown descriptor type")
 	}
@@ -135,8 +185,8 @@ func (d *Descriptor) validateFile() error {
 
 // validateDirectory validates directory-specific fields
 func (d *Descriptor) validateDirectory() error {
-	if d.Version != "4.0" {
-		return errors.New("directory descriptors require version 4.0")
+	if d.Version != DescriptorVersion {
+		return fmt.Errorf("directory descriptors require version %s", DescriptorVersion)
 	}
 	
 	if d.ManifestCID == "" {
@@ -172,6 +222,14 @@ func FromJSON(data []byte) (*Descriptor, error) {
 	
 	var desc Descriptor
 	if err := json.Unmarshal(data, &desc); err != nil {
+		// A newer format may change field types; say so rather than
+		// reporting the parse error
+		var header struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(data, &header) == nil && version.Newer(header.Version, DescriptorVersion) {
+			return nil, fmt.Errorf("%w (descriptor version %s, this build reads %s)", ErrUnsupportedVersion, header.Version, DescriptorVersion)
+		}
 		return nil, err
 	}
 	
@@ -203,6 +261,11 @@ func (d *Descriptor) IsDirectory() bool {
 	return d.Type == DirectoryType
 }
 
+// IsPack returns true if this is a pack descriptor
+func (d *Descriptor) IsPack() bool {
+	return d.Type == PackType
+}
+
 // IsPadded returns true if this descriptor uses padding
 func (d *Descriptor) IsPadded() bool {
 	return d.PaddedFileSize > d.FileSize
@@ -2,6 +2,7 @@ package descriptors
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -351,6 +352,17 @@ func TestDescriptorFromJSON(t *testing.T) {
 	if err == nil {
 		t.Error("FromJSON() with invalid descriptor should return error")
 	}
+
+	// Test descriptors from a newer format, including ones whose fields
+	// no longer parse
+	for _, newer := range []string{
+		strings.Replace(validJSON, `"4.0"`, `"5.0"`, 1),
+		`{"version": "5.0", "type": "file", "filename": "test.txt", "blocks": {"v5": true}}`,
+	} {
+		if _, err := FromJSON([]byte(newer)); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("FromJSON() of a newer descriptor error = %v, want ErrUnsupportedVersion", err)
+		}
+	}
 }
 
 func TestDescriptorRoundTrip(t *testing.T) {
@@ -19,6 +19,10 @@ type EncryptedDescriptor struct {
 	IsEncrypted bool   `json:"is_encrypted"`
 }
 
+// ErrPasswordRequired is returned when loading an encrypted descriptor
+// without a password
+var ErrPasswordRequired = errors.New("password required to decrypt descriptor")
+
 // PasswordProvider is a function that provides the password when needed
 // The returned password should be cleared from memory after use
 // 
@@ -35,6 +39,7 @@ type PasswordProvider func() (string, error)
 type EncryptedStore struct {
 	storageManager   *storage.Manager
 	passwordProvider PasswordProvider
+	cache            *Cache
 }
 
 // NewEncryptedStore creates a new encrypted descriptor store
@@ -46,9 +51,15 @@ func NewEncryptedStore(storageManager *storage.Manager, passwordProvider Passwor
 	return &EncryptedStore{
 		storageManager:   storageManager,
 		passwordProvider: passwordProvider,
+		cache:            DefaultCache,
 	}, nil
 }
 
+// SetCache replaces the store's descriptor cache; nil disables caching
+func (s *EncryptedStore) SetCache(cache *Cache) {
+	s.cache = cache
+}
+
 // NewEncryptedStoreWithPassword creates a new encrypted descriptor store with a static password
 // WARNING: This is a convenience function. For better security, use NewEncryptedStore with a custom PasswordProvider
 func NewEncryptedStoreWithPassword(storageManager *storage.Manager, password string) (*EncryptedStore, error) {
@@ -126,24 +137,68 @@ func (s *EncryptedStore) Save(descriptor *Descriptor) (string, error) {
 	
 	cid := address.ID
 
+	// Only public descriptors are cached in parsed form
+	if password != "" {
+		descriptor = nil
+	}
+	s.cache.put(s.storageManager, cid, data, descriptor, true)
+
 	return cid, nil
 }
 
 // Load retrieves and decrypts a descriptor from IPFS by its CID
 func (s *EncryptedStore) Load(cid string) (*Descriptor, error) {
+	descriptor, _, err := s.LoadWithEncryption(cid)
+	return descriptor, err
+}
+
+// LoadWithEncryption is like Load but also reports whether the stored
+// descriptor was password protected
+func (s *EncryptedStore) LoadWithEncryption(cid string) (*Descriptor, bool, error) {
 	if cid == "" {
-		return nil, errors.New("CID cannot be empty")
+		return nil, false, errors.New("CID cannot be empty")
 	}
 
-	// Retrieve from storage manager
-	address := &storage.BlockAddress{ID: cid}
-	block, err := s.storageManager.Get(context.Background(), address)
+	var data []byte
+	if entry, ok := s.cache.get(s.storageManager, cid); ok {
+		if entry.err != nil {
+			return nil, false, fmt.Errorf("failed to retrieve descriptor: %w", entry.err)
+		}
+		if entry.descriptor != nil {
+			return entry.descriptor.clone(), false, nil
+		}
+		data = entry.data
+	}
+
+	if data == nil {
+		// Retrieve from storage manager
+		address := &storage.BlockAddress{ID: cid}
+		block, err := s.storageManager.Get(context.Background(), address)
+		if err != nil {
+			s.cache.putMissing(s.storageManager, cid, err)
+			return nil, false, fmt.Errorf("failed to retrieve descriptor: %w", err)
+		}
+		data = block.Data
+	}
+
+	encrypted := isEncryptedDescriptor(data)
+	descriptor, err := s.parse(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve descriptor: %w", err)
+		return nil, encrypted, err
 	}
 
-	data := block.Data
+	// Decrypted descriptors aren't cached, only their stored form
+	if encrypted {
+		s.cache.put(s.storageManager, cid, data, nil, true)
+	} else {
+		s.cache.put(s.storageManager, cid, data, descriptor, isDescriptorEnvelope(data))
+	}
+	return descriptor, encrypted, nil
+}
 
+// parse decodes a stored descriptor in any of the supported formats,
+// decrypting it if necessary
+func (s *EncryptedStore) parse(data []byte) (*Descriptor, error) {
 	// Try to parse as encrypted descriptor first
 	var encDesc EncryptedDescriptor
 	if err := json.Unmarshal(data, &encDesc); err == nil {
@@ -232,7 +287,7 @@ func (s *EncryptedStore) decryptDescriptor(encDesc *EncryptedDescriptor) (*Descr
 	defer crypto.SecureZero(passwordBytes)
 	
 	if password == "" {
-		return nil, errors.New("password required to decrypt descriptor")
+		return nil, ErrPasswordRequired
 	}
 
 	// Derive encryption key from password and salt
@@ -0,0 +1,137 @@
+package descriptors
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathFilter selects files of a pack or directory by slash-separated glob
+// patterns, so a download can reconstruct only the files it needs. Each
+// segment is matched with path.Match and "**" matches any number of
+// segments. A pattern naming a directory selects everything under it, so
+// "docs" and "docs/**" are the same filter.
+type PathFilter struct {
+	patterns [][]string
+}
+
+// NewPathFilter returns a filter matching any of patterns. It returns nil
+// when there are no patterns; a nil filter matches every path.
+func NewPathFilter(patterns []string) (*PathFilter, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	filter := &PathFilter{}
+	for _, pattern := range patterns {
+		cleaned := strings.Trim(path.Clean("/"+strings.TrimSpace(pattern)), "/")
+		if cleaned == "" {
+			return nil, fmt.Errorf("empty path pattern %q", pattern)
+		}
+		segments := strings.Split(cleaned, "/")
+		for _, segment := range segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+		}
+		if segments[len(segments)-1] != "**" {
+			segments = append(segments, "**")
+		}
+		filter.patterns = append(filter.patterns, segments)
+	}
+	return filter, nil
+}
+
+// Match reports whether the file at name, relative to the root of the pack
+// or directory, is selected
+func (f *PathFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	segments := splitPath(name)
+	for _, pattern := range f.patterns {
+		if matchPathSegments(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// MayContain reports whether the directory at dir can hold selected files,
+// so downloads can skip subdirectories the filter rules out
+func (f *PathFilter) MayContain(dir string) bool {
+	if f == nil {
+		return true
+	}
+	segments := splitPath(dir)
+	for _, pattern := range f.patterns {
+		if matchPathPrefix(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchingEntries returns the entries of a pack selected by filter, in pack
+// order
+func (d *Descriptor) MatchingEntries(filter *PathFilter) []PackEntry {
+	matching := make([]PackEntry, 0, len(d.Entries))
+	for _, entry := range d.Entries {
+		if filter.Match(entry.Filename) {
+			matching = append(matching, entry)
+		}
+	}
+	return matching
+}
+
+// splitPath returns the segments of a slash-separated relative path
+func splitPath(name string) []string {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// matchPathSegments matches path segments where "**" consumes zero or more
+// segments
+func matchPathSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchPathSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchPathPrefix reports whether some path starting with the segments of
+// dir can match pattern
+func matchPathPrefix(pattern, dir []string) bool {
+	for len(dir) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if matched, _ := path.Match(pattern[0], dir[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		dir = dir[1:]
+	}
+	return len(pattern) > 0
+}
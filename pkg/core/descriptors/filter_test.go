@@ -0,0 +1,74 @@
+package descriptors
+
+import "testing"
+
+func TestPathFilter(t *testing.T) {
+	filter, err := NewPathFilter([]string{"docs/**", "src/*.go", "README.md"})
+	if err != nil {
+		t.Fatalf("NewPathFilter failed: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"docs/guide.md":         true,
+		"docs/api/v1/index.md":  true,
+		"src/main.go":           true,
+		"src/pkg/util.go":       false,
+		"src/main.c":            false,
+		"README.md":             true,
+		"other/README.md":       false,
+		"/docs/leading-slash":   true,
+		"documentation/foo.txt": false,
+	} {
+		if got := filter.Match(name); got != want {
+			t.Errorf("Match(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	for dir, want := range map[string]bool{
+		"docs":     true,
+		"docs/api": true,
+		"src":      true,
+		"src/pkg":  false,
+		"other":    false,
+	} {
+		if got := filter.MayContain(dir); got != want {
+			t.Errorf("MayContain(%q) = %v, want %v", dir, got, want)
+		}
+	}
+
+	// Naming a directory selects everything under it
+	dirFilter, _ := NewPathFilter([]string{"docs"})
+	if !dirFilter.Match("docs/guide.md") || dirFilter.Match("src/main.go") {
+		t.Error("Expected a directory pattern to select the files under it")
+	}
+
+	var all *PathFilter
+	if !all.Match("anything/at/all") || !all.MayContain("anywhere") {
+		t.Error("Expected a nil filter to match everything")
+	}
+	if filter, err := NewPathFilter(nil); err != nil || filter != nil {
+		t.Errorf("NewPathFilter(nil) = %v, %v; want a nil filter", filter, err)
+	}
+	if _, err := NewPathFilter([]string{"docs/[a-"}); err == nil {
+		t.Error("Expected a malformed pattern to be rejected")
+	}
+	if _, err := NewPathFilter([]string{"/"}); err == nil {
+		t.Error("Expected an empty pattern to be rejected")
+	}
+}
+
+func TestMatchingEntries(t *testing.T) {
+	pack := NewPackDescriptor("site.pack", 4096)
+	for _, name := range []string{"index.html", "style.css", "notes.md", "todo.md"} {
+		pack.AddPackEntry(name, 100)
+	}
+
+	filter, _ := NewPathFilter([]string{"*.md"})
+	entries := pack.MatchingEntries(filter)
+	if len(entries) != 2 || entries[0].Filename != "notes.md" || entries[1].Filename != "todo.md" {
+		t.Errorf("MatchingEntries(*.md) = %+v, want notes.md and todo.md", entries)
+	}
+	if all := pack.MatchingEntries(nil); len(all) != 4 {
+		t.Errorf("MatchingEntries(nil) returned %d entries, want 4", len(all))
+	}
+}
@@ -0,0 +1,99 @@
+package descriptors
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PackEntry locates one file inside the data of a pack. A pack stores many
+// small files back to back as a single padded stream, so a 1KB file shares
+// its blocks (and their randomizers) with its neighbours instead of taking
+// a whole block triple of its own.
+type PackEntry struct {
+	Filename string `json:"filename"`
+	Offset   int64  `json:"offset"` // Byte offset in the pack data
+	Size     int64  `json:"size"`
+}
+
+// NewPackDescriptor creates an empty pack descriptor. Entries are added
+// with AddPackEntry in the order their data is uploaded.
+func NewPackDescriptor(name string, blockSize int) *Descriptor {
+	return &Descriptor{
+		Version:   DescriptorVersion,
+		Type:      PackType,
+		Filename:  name,
+		BlockSize: blockSize,
+		Blocks:    make([]BlockPair, 0),
+		Entries:   make([]PackEntry, 0),
+		CreatedAt: time.Now(),
+	}
+}
+
+// AddPackEntry appends a file of the given size to the end of the pack
+func (d *Descriptor) AddPackEntry(filename string, size int64) (PackEntry, error) {
+	if filename == "" {
+		return PackEntry{}, errors.New("packed filename cannot be empty")
+	}
+	if size < 0 {
+		return PackEntry{}, errors.New("packed file size cannot be negative")
+	}
+	if _, exists := d.FindPackEntry(filename); exists {
+		return PackEntry{}, fmt.Errorf("pack already contains %s", filename)
+	}
+
+	entry := PackEntry{Filename: filename, Offset: d.FileSize, Size: size}
+	d.Entries = append(d.Entries, entry)
+	d.FileSize += size
+	return entry, nil
+}
+
+// FindPackEntry returns the entry for a packed file
+func (d *Descriptor) FindPackEntry(filename string) (PackEntry, bool) {
+	for _, entry := range d.Entries {
+		if entry.Filename == filename {
+			return entry, true
+		}
+	}
+	return PackEntry{}, false
+}
+
+// PackEntryBlocks returns the range [first, last) of block indexes holding
+// an entry's data, so a packed file can be read without the whole pack
+func (d *Descriptor) PackEntryBlocks(entry PackEntry) (int, int) {
+	if entry.Size == 0 || d.BlockSize <= 0 {
+		return 0, 0
+	}
+	blockSize := int64(d.BlockSize)
+	first := entry.Offset / blockSize
+	last := (entry.Offset + entry.Size + blockSize - 1) / blockSize
+	return int(first), int(last)
+}
+
+// validatePack validates pack-specific fields
+func (d *Descriptor) validatePack() error {
+	if err := d.validateFile(); err != nil {
+		return err
+	}
+
+	if len(d.Entries) == 0 {
+		return errors.New("pack must contain at least one file")
+	}
+
+	names := make(map[string]bool, len(d.Entries))
+	for _, entry := range d.Entries {
+		if entry.Filename == "" {
+			return errors.New("packed filename is required")
+		}
+		if names[entry.Filename] {
+			return fmt.Errorf("duplicate packed file %s", entry.Filename)
+		}
+		names[entry.Filename] = true
+
+		if entry.Offset < 0 || entry.Size < 0 || entry.Offset+entry.Size > d.FileSize {
+			return fmt.Errorf("packed file %s lies outside the pack data", entry.Filename)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package descriptors
+
+import "testing"
+
+func TestPackDescriptor(t *testing.T) {
+	pack := NewPackDescriptor("notes.pack", 4096)
+	for _, entry := range []struct {
+		name string
+		size int64
+	}{{"a.txt", 3000}, {"b.txt", 2000}, {"empty.txt", 0}, {"c.txt", 5000}} {
+		if _, err := pack.AddPackEntry(entry.name, entry.size); err != nil {
+			t.Fatalf("AddPackEntry(%s) failed: %v", entry.name, err)
+		}
+	}
+	if _, err := pack.AddPackEntry("a.txt", 10); err == nil {
+		t.Error("Expected a duplicate entry to be rejected")
+	}
+	if pack.FileSize != 10000 {
+		t.Errorf("Pack size = %d, want 10000", pack.FileSize)
+	}
+
+	b, _ := pack.FindPackEntry("b.txt")
+	if first, last := pack.PackEntryBlocks(b); first != 0 || last != 2 {
+		t.Errorf("b.txt spans blocks [%d, %d), want [0, 2)", first, last)
+	}
+	c, _ := pack.FindPackEntry("c.txt")
+	if first, last := pack.PackEntryBlocks(c); first != 1 || last != 3 {
+		t.Errorf("c.txt spans blocks [%d, %d), want [1, 3)", first, last)
+	}
+
+	// A pack needs its blocks before it validates
+	if err := pack.Validate(); err == nil {
+		t.Error("Expected a pack without blocks to fail validation")
+	}
+	for i := 0; i < 3; i++ {
+		pack.AddBlockTriple(string(rune('a'+i))+"data", string(rune('a'+i))+"rand1", string(rune('a'+i))+"rand2")
+	}
+	pack.PaddedFileSize = 3 * 4096
+
+	data, err := pack.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	loaded, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	if !loaded.IsPack() || len(loaded.Entries) != 4 || loaded.Entries[3] != c {
+		t.Errorf("Pack not preserved: %+v", loaded)
+	}
+
+	loaded.Entries[3].Size = 6000
+	if err := loaded.Validate(); err == nil {
+		t.Error("Expected an entry past the end of the pack to fail validation")
+	}
+}
@@ -0,0 +1,428 @@
+package descriptors
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// ShareVersion is the format version of share descriptors
+const ShareVersion = "share-1.0"
+
+// Errors returned when a share can no longer be redeemed. Once a share
+// ends its key is destroyed, so the descriptor it protects can't be
+// decrypted by anyone, the key service included.
+var (
+	ErrShareNotFound  = errors.New("share not found")
+	ErrShareExpired   = errors.New("share has expired")
+	ErrShareExhausted = errors.New("share has reached its download limit")
+	ErrShareRevoked   = errors.New("share was revoked")
+)
+
+// ShareDescriptor is a limited share of a file, stored in IPFS in place of
+// its descriptor. The file's descriptor is re-encrypted under a random key
+// held by a key service, which releases the key, wrapped for the
+// recipient, only while the share is active. Expiry and download limit are
+// repeated here for display; the key service enforces them.
+type ShareDescriptor struct {
+	Version       string    `json:"version"`
+	ShareID       string    `json:"share_id"`
+	KeyService    string    `json:"key_service"`    // Base URL of the key service
+	DescriptorCID string    `json:"descriptor_cid"` // Encrypted with the share key
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	MaxDownloads  int       `json:"max_downloads,omitempty"`
+}
+
+// ShareOptions limits a new share. Zero values mean no limit.
+type ShareOptions struct {
+	KeyService   string
+	ExpiresAt    time.Time
+	MaxDownloads int
+}
+
+// ShareRecord is what the key service keeps of a share
+type ShareRecord struct {
+	ID            string    `json:"id"`
+	ShareCID      string    `json:"share_cid"`
+	DescriptorCID string    `json:"descriptor_cid"`
+	Filename      string    `json:"filename"`
+	Key           string    `json:"key,omitempty"` // Destroyed when the share ends
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	MaxDownloads  int       `json:"max_downloads,omitempty"`
+	Downloads     int       `json:"downloads"`
+	Revoked       bool      `json:"revoked,omitempty"`
+}
+
+// Status returns why the share can't be redeemed at now, or nil if it can
+func (r *ShareRecord) Status(now time.Time) error {
+	switch {
+	case r.Revoked:
+		return ErrShareRevoked
+	case !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt):
+		return ErrShareExpired
+	case r.MaxDownloads > 0 && r.Downloads >= r.MaxDownloads:
+		return ErrShareExhausted
+	case r.Key == "":
+		return ErrShareRevoked
+	}
+	return nil
+}
+
+// CreateShare re-encrypts descriptor under a new random key and stores it
+// with a share descriptor pointing at it. It returns the key service's
+// record of the share, which holds the key; add it to a ShareStore.
+func CreateShare(storageManager *storage.Manager, descriptor *Descriptor, options ShareOptions) (*ShareRecord, error) {
+	if descriptor == nil {
+		return nil, errors.New("descriptor cannot be nil")
+	}
+	if _, err := url.ParseRequestURI(options.KeyService); err != nil || !strings.HasPrefix(options.KeyService, "http") {
+		return nil, fmt.Errorf("invalid key service URL %q", options.KeyService)
+	}
+	if options.MaxDownloads < 0 {
+		return nil, errors.New("download limit cannot be negative")
+	}
+
+	key, err := crypto.GenerateGroupKey()
+	if err != nil {
+		return nil, err
+	}
+	password := key.String()
+	crypto.SecureZero(key.Key)
+
+	store, err := NewEncryptedStoreWithPassword(storageManager, password)
+	if err != nil {
+		return nil, err
+	}
+	descriptorCID, err := store.Save(descriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate share ID: %w", err)
+	}
+	share := &ShareDescriptor{
+		Version:       ShareVersion,
+		ShareID:       hex.EncodeToString(id),
+		KeyService:    strings.TrimSuffix(options.KeyService, "/"),
+		DescriptorCID: descriptorCID,
+		CreatedAt:     time.Now().UTC(),
+		ExpiresAt:     options.ExpiresAt,
+		MaxDownloads:  options.MaxDownloads,
+	}
+	data, err := json.MarshalIndent(share, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize share descriptor: %w", err)
+	}
+	block, err := blocks.NewBlock(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create block: %w", err)
+	}
+	address, err := storageManager.Put(context.Background(), block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store share descriptor: %w", err)
+	}
+
+	return &ShareRecord{
+		ID:            share.ShareID,
+		ShareCID:      address.ID,
+		DescriptorCID: descriptorCID,
+		Filename:      descriptor.Filename,
+		Key:           password,
+		CreatedAt:     share.CreatedAt,
+		ExpiresAt:     share.ExpiresAt,
+		MaxDownloads:  share.MaxDownloads,
+	}, nil
+}
+
+// ParseShareDescriptor decodes a share descriptor. It reports false for
+// data that isn't one, such as an ordinary descriptor.
+func ParseShareDescriptor(data []byte) (*ShareDescriptor, bool) {
+	var share ShareDescriptor
+	if err := json.Unmarshal(data, &share); err != nil || share.Version != ShareVersion || share.ShareID == "" {
+		return nil, false
+	}
+	return &share, true
+}
+
+// LoadShareDescriptor retrieves a share descriptor by CID
+func LoadShareDescriptor(ctx context.Context, storageManager *storage.Manager, cid string) (*ShareDescriptor, error) {
+	block, err := storageManager.Get(ctx, &storage.BlockAddress{ID: cid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve share descriptor: %w", err)
+	}
+	share, ok := ParseShareDescriptor(block.Data)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a share descriptor", cid)
+	}
+	return share, nil
+}
+
+// ShareRedeemRequest asks a key service for a share's key
+type ShareRedeemRequest struct {
+	PublicKey string `json:"public_key"` // Recipient's ephemeral X25519 key, base64
+}
+
+// ShareRedeemResponse carries a share's key wrapped for the recipient
+type ShareRedeemResponse struct {
+	DescriptorCID string                  `json:"descriptor_cid"`
+	WrappedKey    *crypto.WrappedGroupKey `json:"wrapped_key"`
+}
+
+// WrapShareKey answers a redeem request for a record returned by
+// ShareStore.Redeem, wrapping its key for the recipient's public key
+func WrapShareKey(record *ShareRecord, request ShareRedeemRequest) (*ShareRedeemResponse, error) {
+	publicKey, err := crypto.ParseGroupPublicKey(request.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ParseKeyFromString(record.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid share key: %w", err)
+	}
+	defer crypto.SecureZero(key.Key)
+
+	wrapped, err := crypto.WrapGroupKey(key, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ShareRedeemResponse{DescriptorCID: record.DescriptorCID, WrappedKey: wrapped}, nil
+}
+
+// RedeemShare asks the share's key service for its key, counting one
+// download, and returns the password of the share's descriptor. The key is
+// wrapped for a one-time key pair, so it isn't exposed to anything between
+// the recipient and the service.
+func RedeemShare(ctx context.Context, client *http.Client, share *ShareDescriptor) (string, error) {
+	identity, err := crypto.GenerateGroupIdentity()
+	if err != nil {
+		return "", err
+	}
+	defer crypto.SecureZero(identity.PrivateKey)
+
+	body, err := json.Marshal(ShareRedeemRequest{PublicKey: identity.PublicKeyString()})
+	if err != nil {
+		return "", err
+	}
+	endpoint := fmt.Sprintf("%s/api/shares/%s/redeem", share.KeyService, url.PathEscape(share.ShareID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach key service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The key service answers in the web UI's API format
+	var result struct {
+		Success bool                `json:"success"`
+		Data    ShareRedeemResponse `json:"data"`
+		Error   string              `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid key service response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if !result.Success {
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return "", ErrShareNotFound
+		case http.StatusGone:
+			return "", fmt.Errorf("share is no longer available: %s", result.Error)
+		}
+		return "", fmt.Errorf("key service refused the share: %s", result.Error)
+	}
+	if result.Data.WrappedKey == nil {
+		return "", errors.New("key service returned no key")
+	}
+
+	key, err := crypto.UnwrapGroupKey(result.Data.WrappedKey, identity)
+	if err != nil {
+		return "", err
+	}
+	password := key.String()
+	crypto.SecureZero(key.Key)
+	return password, nil
+}
+
+// ShareStore is the key service's file of shares. Every call re-reads the
+// file, so shares created by the CLI are seen by a running web UI, and
+// updates hold a lock on the file beside it, so neither loses the other's
+// writes.
+type ShareStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultShareStorePath returns ~/.noisefs/shares.json
+func DefaultShareStorePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "shares.json"), nil
+}
+
+// NewShareStore returns the store kept at path
+func NewShareStore(path string) *ShareStore {
+	return &ShareStore{path: path}
+}
+
+// Add records a share created with CreateShare
+func (s *ShareStore) Add(record *ShareRecord) error {
+	return s.update(func(records map[string]*ShareRecord) error {
+		if _, exists := records[record.ID]; exists {
+			return fmt.Errorf("share %s already exists", record.ID)
+		}
+		recordCopy := *record
+		records[record.ID] = &recordCopy
+		return nil
+	})
+}
+
+// Get returns a share without its key
+func (s *ShareStore) Get(id string) (*ShareRecord, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[id]
+	if !ok {
+		return nil, ErrShareNotFound
+	}
+	record.Key = ""
+	return record, nil
+}
+
+// Redeem counts a download of an active share and returns the record with
+// its key. The key is destroyed when this was the last download allowed.
+func (s *ShareStore) Redeem(id string) (*ShareRecord, error) {
+	var redeemed *ShareRecord
+	err := s.update(func(records map[string]*ShareRecord) error {
+		record, ok := records[id]
+		if !ok {
+			return ErrShareNotFound
+		}
+		if err := record.Status(time.Now()); err != nil {
+			return err
+		}
+		record.Downloads++
+		recordCopy := *record
+		redeemed = &recordCopy
+		if record.Status(time.Now()) != nil {
+			record.Key = ""
+		}
+		return nil
+	})
+	return redeemed, err
+}
+
+// Revoke ends a share and destroys its key
+func (s *ShareStore) Revoke(id string) error {
+	return s.update(func(records map[string]*ShareRecord) error {
+		record, ok := records[id]
+		if !ok {
+			return ErrShareNotFound
+		}
+		record.Revoked = true
+		record.Key = ""
+		return nil
+	})
+}
+
+// List returns every share without keys, newest first
+func (s *ShareStore) List() ([]*ShareRecord, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*ShareRecord, 0, len(records))
+	for _, record := range records {
+		record.Key = ""
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// update loads the shares, applies fn and saves them, holding the lock
+// for the whole read-modify-write. Keys of shares that have ended are
+// destroyed on every update.
+func (s *ShareStore) update(fn func(records map[string]*ShareRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	unlock, err := lockShareFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn(records)
+
+	now := time.Now()
+	for _, record := range records {
+		if record.Key != "" && record.Status(now) != nil {
+			record.Key = ""
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize shares: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write shares: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write shares: %w", err)
+	}
+	return fnErr
+}
+
+// load reads the shares by ID. Updates replace the file with a rename, so
+// it is read whole without the lock.
+func (s *ShareStore) load() (map[string]*ShareRecord, error) {
+	records := make(map[string]*ShareRecord)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shares: %w", err)
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse shares: %w", err)
+	}
+	return records, nil
+}
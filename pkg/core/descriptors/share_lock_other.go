@@ -0,0 +1,40 @@
+//go:build !unix
+
+package descriptors
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Lock files older than this were left by a process that died holding them
+const (
+	shareLockStale   = 30 * time.Second
+	shareLockTimeout = 10 * time.Second
+)
+
+// lockShareFile takes a lock on path shared by every process using the
+// store and returns its release. Without flock, the lock is a file that
+// only one process can create.
+func lockShareFile(path string) (func(), error) {
+	deadline := time.Now().Add(shareLockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock shares: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > shareLockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock shares: %s is held by another process", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package descriptors
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockShareFile takes an advisory lock on path shared by every process
+// using the store, such as the CLI and a running web UI, and returns its
+// release
+func lockShareFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open share lock: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock shares: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
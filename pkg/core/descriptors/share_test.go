@@ -0,0 +1,230 @@
+package descriptors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+func newTestShare(t *testing.T, options ShareOptions) (*ShareRecord, *Descriptor) {
+	t.Helper()
+	manager := newBundleTestManager(t)
+	descriptor := NewDescriptor("shared.txt", 100, 128, 64)
+	descriptor.AddBlockTriple(putTestBlock(t, manager, 1), putTestBlock(t, manager, 2), putTestBlock(t, manager, 3))
+
+	if options.KeyService == "" {
+		options.KeyService = "http://localhost:8080"
+	}
+	record, err := CreateShare(manager, descriptor, options)
+	if err != nil {
+		t.Fatalf("CreateShare failed: %v", err)
+	}
+
+	store, err := NewEncryptedStoreWithPassword(manager, record.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, encrypted, err := store.LoadWithEncryption(record.DescriptorCID)
+	if err != nil || !encrypted {
+		t.Fatalf("Expected the share key to open an encrypted descriptor, got %v (encrypted %v)", err, encrypted)
+	}
+	if loaded.Filename != descriptor.Filename {
+		t.Errorf("Expected %s, got %s", descriptor.Filename, loaded.Filename)
+	}
+
+	block, err := manager.Get(context.Background(), &storage.BlockAddress{ID: record.ShareCID})
+	if err != nil {
+		t.Fatalf("Failed to get share descriptor: %v", err)
+	}
+	share, ok := ParseShareDescriptor(block.Data)
+	if !ok || share.ShareID != record.ID || share.DescriptorCID != record.DescriptorCID {
+		t.Fatalf("Unexpected share descriptor %+v", share)
+	}
+	if strings.Contains(string(block.Data), record.Key) {
+		t.Error("Share descriptor must not contain the key")
+	}
+	return record, descriptor
+}
+
+func TestShareDownloadLimit(t *testing.T) {
+	record, _ := newTestShare(t, ShareOptions{MaxDownloads: 2})
+	store := NewShareStore(filepath.Join(t.TempDir(), "shares.json"))
+	if err := store.Add(record); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		redeemed, err := store.Redeem(record.ID)
+		if err != nil {
+			t.Fatalf("Redeem %d failed: %v", i+1, err)
+		}
+		if redeemed.Key != record.Key {
+			t.Errorf("Redeem %d returned the wrong key", i+1)
+		}
+	}
+	if _, err := store.Redeem(record.ID); !errors.Is(err, ErrShareExhausted) {
+		t.Errorf("Expected ErrShareExhausted, got %v", err)
+	}
+
+	// The key is gone from the file, not just refused
+	reopened := NewShareStore(store.path)
+	list, err := reopened.List()
+	if err != nil || len(list) != 1 || list[0].Downloads != 2 {
+		t.Fatalf("Unexpected shares %v: %v", list, err)
+	}
+	if err := reopened.update(func(records map[string]*ShareRecord) error {
+		if records[record.ID].Key != "" {
+			t.Error("Expected the key to be destroyed after the last download")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShareStoreWaitsForLock(t *testing.T) {
+	// Another process, such as the web UI, holds the lock
+	store := NewShareStore(filepath.Join(t.TempDir(), "shares.json"))
+	unlock, err := lockShareFile(store.path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- store.Add(&ShareRecord{ID: "waiting", Key: "key", CreatedAt: time.Now()})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Add to wait for the lock, it returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := store.Get("waiting"); err != nil {
+		t.Errorf("Expected the share to be added: %v", err)
+	}
+}
+
+func TestShareStoreReadsDontWrite(t *testing.T) {
+	store := NewShareStore(filepath.Join(t.TempDir(), "shares.json"))
+	if _, err := store.List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("missing"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("Expected ErrShareNotFound, got %v", err)
+	}
+	if _, err := os.Stat(store.path); !os.IsNotExist(err) {
+		t.Errorf("Expected reads to leave the file alone, got %v", err)
+	}
+}
+
+func TestShareExpiryAndRevocation(t *testing.T) {
+	store := NewShareStore(filepath.Join(t.TempDir(), "shares.json"))
+
+	expired := &ShareRecord{ID: "expired", Key: "key", ExpiresAt: time.Now().Add(-time.Minute)}
+	revoked := &ShareRecord{ID: "revoked", Key: "key", ExpiresAt: time.Now().Add(time.Hour)}
+	for _, record := range []*ShareRecord{expired, revoked} {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Revoke("revoked"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"expired", ErrShareExpired},
+		{"revoked", ErrShareRevoked},
+		{"missing", ErrShareNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := store.Redeem(tt.id); !errors.Is(err, tt.want) {
+			t.Errorf("Redeem(%s): expected %v, got %v", tt.id, tt.want, err)
+		}
+	}
+	if record, _ := store.Get("revoked"); record == nil || !record.Revoked {
+		t.Errorf("Expected revoked share to be kept, got %+v", record)
+	}
+}
+
+func TestRedeemShare(t *testing.T) {
+	record, _ := newTestShare(t, ShareOptions{MaxDownloads: 1})
+	store := NewShareStore(filepath.Join(t.TempDir(), "shares.json"))
+	if err := store.Add(record); err != nil {
+		t.Fatal(err)
+	}
+
+	// A minimal key service answering like the web UI
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/shares/"), "/redeem")
+		var request ShareRedeemRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		redeemed, err := store.Redeem(id)
+		if err != nil {
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		response, err := WrapShareKey(redeemed, request)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": response})
+	}))
+	defer server.Close()
+
+	share := &ShareDescriptor{Version: ShareVersion, ShareID: record.ID, KeyService: server.URL, DescriptorCID: record.DescriptorCID}
+	password, err := RedeemShare(context.Background(), server.Client(), share)
+	if err != nil {
+		t.Fatalf("RedeemShare failed: %v", err)
+	}
+	if password != record.Key {
+		t.Error("Expected the redeemed password to be the share key")
+	}
+
+	if _, err := RedeemShare(context.Background(), server.Client(), share); err == nil {
+		t.Error("Expected a second download to be refused")
+	}
+}
+
+func TestParseShareDescriptorRejectsDescriptors(t *testing.T) {
+	descriptor := NewDescriptor("file.txt", 10, 16, 16)
+	descriptor.AddBlockTriple("QmData", "QmRand1", "QmRand2")
+	data, err := descriptor.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ParseShareDescriptor(data); ok {
+		t.Error("Expected an ordinary descriptor not to parse as a share")
+	}
+}
+
+func TestCreateShareValidation(t *testing.T) {
+	manager := newBundleTestManager(t)
+	descriptor := NewDescriptor("file.txt", 10, 16, 16)
+	if _, err := CreateShare(manager, descriptor, ShareOptions{KeyService: "not a url"}); err == nil {
+		t.Error("Expected an invalid key service URL to be rejected")
+	}
+	if _, err := CreateShare(manager, descriptor, ShareOptions{KeyService: "http://localhost", MaxDownloads: -1}); err == nil {
+		t.Error("Expected a negative download limit to be rejected")
+	}
+}
@@ -12,6 +12,7 @@ import (
 // Store handles descriptor storage and retrieval
 type Store struct {
 	storageManager *storage.Manager
+	cache          *Cache
 }
 
 // NewStore creates a new descriptor store using storage manager
@@ -28,11 +29,22 @@ func NewStoreWithManager(storageManager *storage.Manager) (*Store, error) {
 	
 	return &Store{
 		storageManager: storageManager,
+		cache:          DefaultCache,
 	}, nil
 }
 
+// SetCache replaces the store's descriptor cache; nil disables caching
+func (s *Store) SetCache(cache *Cache) {
+	s.cache = cache
+}
+
 // Save stores a descriptor in IPFS and returns its CID
 func (s *Store) Save(descriptor *Descriptor) (string, error) {
+	return s.SaveContext(context.Background(), descriptor)
+}
+
+// SaveContext is Save within a request's context
+func (s *Store) SaveContext(ctx context.Context, descriptor *Descriptor) (string, error) {
 	if descriptor == nil {
 		return "", errors.New("descriptor cannot be nil")
 	}
@@ -49,28 +61,47 @@ func (s *Store) Save(descriptor *Descriptor) (string, error) {
 		return "", fmt.Errorf("failed to create block: %w", err)
 	}
 	
-	address, err := s.storageManager.Put(context.Background(), block)
+	address, err := s.storageManager.Put(ctx, block)
 	if err != nil {
 		return "", fmt.Errorf("failed to store descriptor: %w", err)
 	}
 	
+	s.cache.put(s.storageManager, address.ID, data, descriptor, false)
 	return address.ID, nil
 }
 
 // Load retrieves a descriptor from IPFS by its CID
 func (s *Store) Load(cid string) (*Descriptor, error) {
+	return s.LoadContext(context.Background(), cid)
+}
+
+// LoadContext is Load within a request's context
+func (s *Store) LoadContext(ctx context.Context, cid string) (*Descriptor, error) {
 	if cid == "" {
 		return nil, errors.New("CID cannot be empty")
 	}
 	
-	// Retrieve from storage manager
-	address := &storage.BlockAddress{ID: cid}
-	block, err := s.storageManager.Get(context.Background(), address)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve descriptor: %w", err)
+	var data []byte
+	if entry, ok := s.cache.get(s.storageManager, cid); ok {
+		if entry.err != nil {
+			return nil, fmt.Errorf("failed to retrieve descriptor: %w", entry.err)
+		}
+		if entry.descriptor != nil && !entry.envelope {
+			return entry.descriptor.clone(), nil
+		}
+		data = entry.data
 	}
 	
-	data := block.Data
+	if data == nil {
+		// Retrieve from storage manager
+		address := &storage.BlockAddress{ID: cid}
+		block, err := s.storageManager.Get(ctx, address)
+		if err != nil {
+			s.cache.putMissing(s.storageManager, cid, err)
+			return nil, fmt.Errorf("failed to retrieve descriptor: %w", err)
+		}
+		data = block.Data
+	}
 	
 	// Deserialize descriptor
 	descriptor, err := FromJSON(data)
@@ -78,5 +109,6 @@ func (s *Store) Load(cid string) (*Descriptor, error) {
 		return nil, fmt.Errorf("failed to deserialize descriptor: %w", err)
 	}
 	
+	s.cache.put(s.storageManager, cid, data, descriptor, false)
 	return descriptor, nil
 }
\ No newline at end of file
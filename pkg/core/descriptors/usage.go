@@ -0,0 +1,188 @@
+package descriptors
+
+import (
+	"context"
+	"sort"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// StorageUsage is the storage a set of descriptors takes up. Referenced
+// bytes count every block each time a descriptor uses it, as if nothing
+// were shared; stored bytes count each block once. Overheads are
+// percentages over the logical file sizes, before and after sharing.
+type StorageUsage struct {
+	Descriptors       int               `json:"descriptors"`
+	Unreadable        int               `json:"unreadable,omitempty"` // Descriptors that couldn't be loaded and aren't counted
+	UniqueBlocks      int               `json:"unique_blocks"`
+	SharedBlocks      int               `json:"shared_blocks"` // Blocks used more than once
+	LogicalBytes      int64             `json:"logical_bytes"`
+	ReferencedBytes   int64             `json:"referenced_bytes"`
+	StoredBytes       int64             `json:"stored_bytes"`
+	Overhead          float64           `json:"overhead_percent"`
+	EffectiveOverhead float64           `json:"effective_overhead_percent"`
+	Files             []DescriptorUsage `json:"files,omitempty"`
+}
+
+// DescriptorUsage is the storage one descriptor takes up on its own, and
+// how many of its blocks other descriptors in the report also use
+type DescriptorUsage struct {
+	CID               string  `json:"cid"`
+	Filename          string  `json:"filename"`
+	UniqueBlocks      int     `json:"unique_blocks"`
+	SharedBlocks      int     `json:"shared_blocks"` // Blocks used more than once within the descriptor
+	SharedWithOthers  int     `json:"shared_with_others"`
+	LogicalBytes      int64   `json:"logical_bytes"`
+	ReferencedBytes   int64   `json:"referenced_bytes"`
+	StoredBytes       int64   `json:"stored_bytes"`
+	Overhead          float64 `json:"overhead_percent"`
+	EffectiveOverhead float64 `json:"effective_overhead_percent"`
+}
+
+// UsageCounter accumulates the storage usage of descriptors
+type UsageCounter struct {
+	usage StorageUsage
+	// References and sizes of every block, and the blocks of each file
+	references map[string]int
+	sizes      map[string]int64
+	owners     map[string]int
+	fileBlocks [][]string
+}
+
+// NewUsageCounter returns an empty usage counter
+func NewUsageCounter() *UsageCounter {
+	return &UsageCounter{
+		references: make(map[string]int),
+		sizes:      make(map[string]int64),
+		owners:     make(map[string]int),
+	}
+}
+
+// Add counts a descriptor stored as descriptorSize bytes at descriptorCID.
+// Data and randomizer blocks are taken to be the descriptor's block size;
+// manifestSize is the size of a directory's manifest block, if any.
+func (c *UsageCounter) Add(descriptorCID string, descriptorSize int64, descriptor *Descriptor, manifestSize int64) {
+	file := DescriptorUsage{CID: descriptorCID, Filename: descriptor.Filename, LogicalBytes: descriptor.FileSize}
+	local := make(map[string]int)
+	sizes := map[string]int64{descriptorCID: descriptorSize}
+	local[descriptorCID]++
+	if descriptor.ManifestCID != "" {
+		local[descriptor.ManifestCID]++
+		sizes[descriptor.ManifestCID] = manifestSize
+	}
+	for _, triple := range descriptor.Blocks {
+		for _, cid := range []string{triple.DataCID, triple.RandomizerCID1, triple.RandomizerCID2} {
+			local[cid]++
+			sizes[cid] = int64(descriptor.BlockSize)
+		}
+	}
+
+	cids := make([]string, 0, len(local))
+	for cid, count := range local {
+		cids = append(cids, cid)
+		file.UniqueBlocks++
+		if count > 1 {
+			file.SharedBlocks++
+		}
+		file.ReferencedBytes += int64(count) * sizes[cid]
+		file.StoredBytes += sizes[cid]
+
+		c.references[cid] += count
+		c.sizes[cid] = sizes[cid]
+		c.owners[cid]++
+		c.usage.ReferencedBytes += int64(count) * sizes[cid]
+	}
+	sort.Strings(cids)
+	file.Overhead = overheadPercent(file.ReferencedBytes, file.LogicalBytes)
+	file.EffectiveOverhead = overheadPercent(file.StoredBytes, file.LogicalBytes)
+
+	c.usage.Descriptors++
+	c.usage.LogicalBytes += descriptor.FileSize
+	c.usage.Files = append(c.usage.Files, file)
+	c.fileBlocks = append(c.fileBlocks, cids)
+}
+
+// Usage returns the usage of the descriptors counted so far
+func (c *UsageCounter) Usage() *StorageUsage {
+	usage := c.usage
+	usage.UniqueBlocks = len(c.references)
+	usage.StoredBytes = 0
+	for cid, count := range c.references {
+		usage.StoredBytes += c.sizes[cid]
+		if count > 1 {
+			usage.SharedBlocks++
+		}
+	}
+	usage.Overhead = overheadPercent(usage.ReferencedBytes, usage.LogicalBytes)
+	usage.EffectiveOverhead = overheadPercent(usage.StoredBytes, usage.LogicalBytes)
+
+	usage.Files = make([]DescriptorUsage, len(c.usage.Files))
+	for i, file := range c.usage.Files {
+		for _, cid := range c.fileBlocks[i] {
+			if c.owners[cid] > 1 {
+				file.SharedWithOthers++
+			}
+		}
+		usage.Files[i] = file
+	}
+	return &usage
+}
+
+// overheadPercent returns how much larger stored is than logical, in percent
+func overheadPercent(stored, logical int64) float64 {
+	if logical <= 0 {
+		return 0
+	}
+	return float64(stored)/float64(logical)*100 - 100
+}
+
+// MeasureUsage reports the storage the descriptors at cids take up,
+// counting blocks they share once. Only descriptors and directory
+// manifests are fetched; password reads encrypted descriptors, and
+// descriptors that can't be loaded, or whose manifest can't be, are
+// counted as unreadable.
+func MeasureUsage(ctx context.Context, storageManager *storage.Manager, cids []string, password string) (*StorageUsage, error) {
+	store, err := NewEncryptedStoreWithPassword(storageManager, password)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := NewUsageCounter()
+	unreadable := 0
+	seen := make(map[string]bool, len(cids))
+	for _, cid := range cids {
+		if seen[cid] {
+			continue
+		}
+		seen[cid] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		raw, err := storageManager.Get(ctx, &storage.BlockAddress{ID: cid})
+		if err != nil {
+			unreadable++
+			continue
+		}
+		descriptor, err := store.parse(raw.Data)
+		if err != nil {
+			unreadable++
+			continue
+		}
+
+		var manifestSize int64
+		if descriptor.ManifestCID != "" {
+			manifest, err := storageManager.Get(ctx, &storage.BlockAddress{ID: descriptor.ManifestCID})
+			if err != nil {
+				unreadable++
+				continue
+			}
+			manifestSize = int64(len(manifest.Data))
+		}
+		counter.Add(cid, int64(len(raw.Data)), descriptor, manifestSize)
+	}
+
+	usage := counter.Usage()
+	usage.Unreadable = unreadable
+	return usage, nil
+}
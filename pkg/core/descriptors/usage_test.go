@@ -0,0 +1,65 @@
+package descriptors
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMeasureUsage(t *testing.T) {
+	ctx := context.Background()
+	manager := newBundleTestManager(t)
+	store, err := NewStoreWithManager(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two files of two 64-byte blocks share a randomizer
+	shared := putTestBlock(t, manager, 1)
+	first := NewDescriptor("first.txt", 100, 128, 64)
+	first.AddBlockTriple(putTestBlock(t, manager, 2), shared, putTestBlock(t, manager, 3))
+	first.AddBlockTriple(putTestBlock(t, manager, 4), shared, putTestBlock(t, manager, 5))
+	second := NewDescriptor("second.txt", 64, 64, 64)
+	second.AddBlockTriple(putTestBlock(t, manager, 6), shared, putTestBlock(t, manager, 7))
+
+	firstCID, err := store.Save(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondCID, err := store.Save(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single, err := MeasureUsage(ctx, manager, []string{firstCID}, "")
+	if err != nil {
+		t.Fatalf("MeasureUsage failed: %v", err)
+	}
+	file := single.Files[0]
+	if file.UniqueBlocks != 6 || file.SharedBlocks != 1 || file.SharedWithOthers != 0 {
+		t.Errorf("Unexpected block counts for one file: %+v", file)
+	}
+	if file.ReferencedBytes-file.StoredBytes != 64 {
+		t.Errorf("Expected the reused randomizer to be stored once: %+v", file)
+	}
+
+	usage, err := MeasureUsage(ctx, manager, []string{firstCID, secondCID, firstCID, "missing"}, "")
+	if err != nil {
+		t.Fatalf("MeasureUsage failed: %v", err)
+	}
+	if usage.Descriptors != 2 || usage.Unreadable != 1 {
+		t.Errorf("Expected 2 descriptors and 1 unreadable, got %+v", usage)
+	}
+	// 7 content blocks and 2 descriptor blocks; the randomizer is used 3 times
+	if usage.UniqueBlocks != 9 || usage.SharedBlocks != 1 {
+		t.Errorf("Expected 9 unique and 1 shared block, got %+v", usage)
+	}
+	if usage.LogicalBytes != 164 || usage.ReferencedBytes-usage.StoredBytes != 2*64 {
+		t.Errorf("Unexpected byte counts: %+v", usage)
+	}
+	if usage.EffectiveOverhead >= usage.Overhead {
+		t.Errorf("Expected sharing to lower the overhead: %+v", usage)
+	}
+	if usage.Files[1].SharedWithOthers != 1 {
+		t.Errorf("Expected the second file to share one block with the first: %+v", usage.Files[1])
+	}
+}
@@ -0,0 +1,53 @@
+package processors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// checksum is the built-in "checksum" processor. It records the SHA-256 of
+// files as the "sha256" metadata and, with the "deny" setting, a list of
+// hex digests separated by commas or spaces, rejects known files.
+type checksum struct {
+	deny map[string]bool
+}
+
+func newChecksum(settings map[string]string) (Processor, error) {
+	c := &checksum{deny: make(map[string]bool)}
+	for key, value := range settings {
+		if key != "deny" {
+			return nil, fmt.Errorf("checksum processor has no setting %q", key)
+		}
+		for _, digest := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+			if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("checksum processor: %q is not a SHA-256 digest", digest)
+			}
+			c.deny[strings.ToLower(digest)] = true
+		}
+	}
+	return c, nil
+}
+
+// Process implements Processor
+func (c *checksum) Process(ctx context.Context, file File) (Result, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return Result{}, err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if c.deny[digest] {
+		return Result{Reject: "content is on the deny list"}, nil
+	}
+	return Result{Metadata: map[string]string{"sha256": digest}}, nil
+}
@@ -0,0 +1,238 @@
+package processors
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clamdChunkSize is how much content is sent per INSTREAM chunk
+	clamdChunkSize = 64 * 1024
+
+	defaultClamdCacheSize = 10000
+	defaultClamdCacheTTL  = time.Hour
+)
+
+// clamd is the built-in "clamd" processor, which scans files with a ClamAV
+// daemon and rejects those it finds malware in. Verdicts are cached by
+// content hash, so files served again aren't rescanned until the cache
+// entry expires, which picks up new signatures.
+//
+// Settings: "address" of the daemon's socket, such as
+// "/var/run/clamav/clamd.ctl", "unix:///run/clamd.sock" or
+// "tcp://127.0.0.1:3310" (required); "cache_size" in verdicts (10000,
+// 0 disables caching) and "cache_ttl_seconds" (3600).
+type clamd struct {
+	network  string
+	address  string
+	verdicts *verdictCache
+}
+
+func newClamd(settings map[string]string) (Processor, error) {
+	c := &clamd{}
+	size, ttl := defaultClamdCacheSize, defaultClamdCacheTTL
+	for key, value := range settings {
+		switch key {
+		case "address":
+			var err error
+			if c.network, c.address, err = ParseClamdAddress(value); err != nil {
+				return nil, err
+			}
+		case "cache_size":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("clamd processor: invalid cache_size %q", value)
+			}
+			size = n
+		case "cache_ttl_seconds":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("clamd processor: invalid cache_ttl_seconds %q", value)
+			}
+			ttl = time.Duration(n) * time.Second
+		default:
+			return nil, fmt.Errorf("clamd processor has no setting %q", key)
+		}
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("clamd processor needs an address")
+	}
+	if size > 0 {
+		c.verdicts = newVerdictCache(size, ttl)
+	}
+	return c, nil
+}
+
+// ParseClamdAddress splits the address of a ClamAV daemon into the network
+// and address to dial. Paths and unix:// URLs are unix sockets; tcp://
+// URLs and host:port pairs are TCP.
+func ParseClamdAddress(address string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		address = strings.TrimPrefix(address, "unix://")
+		if address == "" {
+			return "", "", fmt.Errorf("clamd address %q has no socket path", "unix://")
+		}
+		return "unix", address, nil
+	case strings.HasPrefix(address, "/"):
+		return "unix", address, nil
+	}
+	address = strings.TrimPrefix(address, "tcp://")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", "", fmt.Errorf("invalid clamd address %q: use a socket path or host:port", address)
+	}
+	return "tcp", address, nil
+}
+
+// Process implements Processor
+func (c *clamd) Process(ctx context.Context, file File) (Result, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return Result{}, err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if signature, ok := c.verdicts.get(digest); ok {
+		return verdictResult(signature, true), nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Result{}, err
+	}
+	signature, err := c.scan(ctx, f)
+	if err != nil {
+		return Result{}, err
+	}
+	c.verdicts.put(digest, signature)
+	return verdictResult(signature, false), nil
+}
+
+// verdictResult turns a scan verdict, the signature found or "" for clean
+// content, into a result
+func verdictResult(signature string, cached bool) Result {
+	if signature != "" {
+		return Result{Reject: "malware found: " + signature}
+	}
+	return Result{Metadata: map[string]string{"clamav": "clean", "clamav_cached": strconv.FormatBool(cached)}}
+}
+
+// scan streams content to the daemon with the INSTREAM command, returning
+// the signature it found or "" if the content is clean
+func (c *clamd) scan(ctx context.Context, content io.Reader) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("failed to send to clamd: %w", err)
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := content.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection when the stream exceeds
+				// its StreamMaxLength; its reply says so
+				break
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", readErr
+		}
+	}
+	conn.Write([]byte{0, 0, 0, 0})
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("no reply from clamd: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply reads a reply such as "stream: OK" or
+// "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (string, error) {
+	_, verdict, _ := strings.Cut(reply, ": ")
+	switch {
+	case verdict == "OK":
+		return "", nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return strings.TrimSuffix(verdict, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// verdictCache remembers scan verdicts by content hash, evicting the
+// oldest once full. A nil cache remembers nothing.
+type verdictCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]verdict
+	order   []string // Hashes in insertion order, oldest first
+}
+
+type verdict struct {
+	signature string
+	expires   time.Time
+}
+
+func newVerdictCache(size int, ttl time.Duration) *verdictCache {
+	return &verdictCache{size: size, ttl: ttl, entries: make(map[string]verdict)}
+}
+
+func (v *verdictCache) get(digest string) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	entry, ok := v.entries[digest]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.signature, true
+}
+
+func (v *verdictCache) put(digest, signature string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.entries[digest]; !ok {
+		for len(v.order) >= v.size {
+			delete(v.entries, v.order[0])
+			v.order = v.order[1:]
+		}
+		v.order = append(v.order, digest)
+	}
+	v.entries[digest] = verdict{signature: signature, expires: time.Now().Add(v.ttl)}
+}
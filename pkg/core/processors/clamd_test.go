@@ -0,0 +1,167 @@
+package processors
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClamd answers INSTREAM scans like clamd, finding "EICAR" in content
+// containing it, and counts the scans it did
+func fakeClamd(t *testing.T) (string, *int32) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var scans int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if command, err := reader.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+					return
+				}
+				var content []byte
+				for {
+					var size uint32
+					if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					chunk := make([]byte, size)
+					if _, err := io.ReadFull(reader, chunk); err != nil {
+						return
+					}
+					content = append(content, chunk...)
+				}
+				atomic.AddInt32(&scans, 1)
+				if strings.Contains(string(content), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}()
+		}
+	}()
+	return "tcp://" + listener.Addr().String(), &scans
+}
+
+func TestClamd(t *testing.T) {
+	address, scans := fakeClamd(t)
+	processor, err := New("clamd", map[string]string{"address": address})
+	if err != nil {
+		t.Fatalf("Failed to create clamd processor: %v", err)
+	}
+	pipeline := NewPipeline(Step{Name: "clamav", Processor: processor, Stages: []Stage{StageDownload}})
+
+	content := []byte(strings.Repeat("clean ", clamdChunkSize/3))
+	for i, cached := range []string{"false", "true"} {
+		output, err := pipeline.ProcessReader(context.Background(), StageDownload, "f", strings.NewReader(string(content)))
+		if err != nil {
+			t.Fatalf("Scan %d failed: %v", i, err)
+		}
+		output.Close()
+		if output.Metadata["clamav"] != "clean" || output.Metadata["clamav_cached"] != cached {
+			t.Errorf("Scan %d: unexpected metadata %+v", i, output.Metadata)
+		}
+	}
+	if n := atomic.LoadInt32(scans); n != 1 {
+		t.Errorf("Expected the second verdict to come from the cache, clamd scanned %d times", n)
+	}
+
+	var rejected *RejectedError
+	for i := 0; i < 2; i++ {
+		_, err := pipeline.ProcessBytes(context.Background(), StageDownload, "f", []byte("xEICARx"))
+		if !errors.As(err, &rejected) || rejected.Reason != "malware found: Eicar-Signature" {
+			t.Errorf("Expected infected content to be rejected, got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(scans); n != 2 {
+		t.Errorf("Infected verdicts should be cached too, clamd scanned %d times", n)
+	}
+}
+
+func TestClamd_Settings(t *testing.T) {
+	address, scans := fakeClamd(t)
+	processor, err := New("clamd", map[string]string{"address": address, "cache_size": "0"})
+	if err != nil {
+		t.Fatalf("Failed to create clamd processor: %v", err)
+	}
+	pipeline := NewPipeline(Step{Name: "clamav", Processor: processor})
+	for i := 0; i < 2; i++ {
+		if _, err := pipeline.ProcessBytes(context.Background(), StageUpload, "f", []byte("data")); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(scans); n != 2 {
+		t.Errorf("A zero cache_size should disable caching, clamd scanned %d times", n)
+	}
+
+	for _, settings := range []map[string]string{
+		{},
+		{"address": "clamd"},
+		{"address": "unix://"},
+		{"address": address, "cache_size": "-1"},
+		{"address": address, "cache_ttl_seconds": "0"},
+		{"address": address, "socket": "x"},
+	} {
+		if _, err := New("clamd", settings); err == nil {
+			t.Errorf("Expected settings %v to be refused", settings)
+		}
+	}
+
+	for address, want := range map[string]string{
+		"/run/clamd.sock":        "unix /run/clamd.sock",
+		"unix:///run/clamd.sock": "unix /run/clamd.sock",
+		"tcp://127.0.0.1:3310":   "tcp 127.0.0.1:3310",
+		"clamav:3310":            "tcp clamav:3310",
+	} {
+		network, addr, err := ParseClamdAddress(address)
+		if err != nil || network+" "+addr != want {
+			t.Errorf("ParseClamdAddress(%q) = %s %s, %v; want %s", address, network, addr, err, want)
+		}
+	}
+
+	unreachable := NewPipeline(Step{Name: "clamav", Processor: &clamd{network: "unix", address: "/nonexistent/clamd.sock"}})
+	if _, err := unreachable.ProcessBytes(context.Background(), StageUpload, "f", []byte("data")); err == nil || errors.As(err, new(*RejectedError)) {
+		t.Errorf("An unreachable daemon should fail the step, got %v", err)
+	}
+}
+
+func TestVerdictCache(t *testing.T) {
+	cache := newVerdictCache(2, time.Hour)
+	cache.put("a", "")
+	cache.put("b", "Eicar")
+	cache.put("a", "")
+	cache.put("c", "")
+	if _, ok := cache.get("a"); ok {
+		t.Error("The oldest verdict should be evicted")
+	}
+	if signature, ok := cache.get("b"); !ok || signature != "Eicar" {
+		t.Errorf("Expected the cached signature, got %q, %v", signature, ok)
+	}
+
+	expired := newVerdictCache(2, time.Nanosecond)
+	expired.put("a", "")
+	time.Sleep(time.Millisecond)
+	if _, ok := expired.get("a"); ok {
+		t.Error("Expired verdicts should not be returned")
+	}
+}
@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ProtocolVersion is the version of the command protocol, passed to
+// commands as NOISEFS_PROCESSOR_PROTOCOL
+const ProtocolVersion = "1"
+
+// Command runs an external program as a processor. The program reads the
+// content from standard input or from the path in NOISEFS_INPUT, and is
+// also given NOISEFS_PROCESSOR_PROTOCOL, NOISEFS_STAGE, NOISEFS_FILENAME
+// and NOISEFS_OUTPUT, the path to write replacement content to.
+//
+// Exit status 0 accepts the file, with the content of NOISEFS_OUTPUT if
+// the program created it; standard output may be a JSON object with
+// "reject" and "metadata" fields. Exit status 1 rejects the file, as virus
+// scanners such as clamscan do, giving the first line of standard error
+// or output as the reason. Any other status is a failure.
+type Command struct {
+	Args []string
+}
+
+// commandOutput is the JSON a command may print
+type commandOutput struct {
+	Reject   string            `json:"reject"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Process implements Processor
+func (c Command) Process(ctx context.Context, file File) (Result, error) {
+	if len(c.Args) == 0 {
+		return Result{}, errors.New("no command")
+	}
+	input, err := os.Open(file.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer input.Close()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"NOISEFS_PROCESSOR_PROTOCOL="+ProtocolVersion,
+		"NOISEFS_STAGE="+string(file.Stage),
+		"NOISEFS_FILENAME="+file.Name,
+		"NOISEFS_INPUT="+file.Path,
+		"NOISEFS_OUTPUT="+file.OutputPath,
+	)
+	cmd.Stdin = input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && ctx.Err() == nil {
+			reason := firstLine(stderr.String())
+			if reason == "" {
+				reason = firstLine(stdout.String())
+			}
+			if reason == "" {
+				reason = "refused by " + c.Args[0]
+			}
+			return Result{Reject: reason}, nil
+		}
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		if msg := firstLine(stderr.String()); msg != "" {
+			return Result{}, fmt.Errorf("%s: %w: %s", c.Args[0], err, msg)
+		}
+		return Result{}, fmt.Errorf("%s: %w", c.Args[0], err)
+	}
+
+	var result Result
+	if _, err := os.Stat(file.OutputPath); err == nil {
+		result.Replaced = true
+	}
+	if out := strings.TrimSpace(stdout.String()); strings.HasPrefix(out, "{") {
+		var parsed commandOutput
+		if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+			return Result{}, fmt.Errorf("%s printed invalid JSON: %w", c.Args[0], err)
+		}
+		result.Reject = parsed.Reject
+		result.Metadata = parsed.Metadata
+	}
+	return result, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
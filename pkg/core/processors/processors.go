@@ -0,0 +1,357 @@
+// Package processors runs content processors, such as virus scanners,
+// transcoders and metadata extractors, on files as they are uploaded or
+// downloaded, so deployments can enforce their policies without patching
+// the client. Processors are either Go code registered with Register or
+// external commands speaking the protocol Command implements.
+package processors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// Stage is when a processor runs
+type Stage string
+
+const (
+	StageUpload   Stage = "upload"   // Before a file is split into blocks
+	StageDownload Stage = "download" // After a file has been reassembled
+)
+
+// DefaultTimeout bounds a processor run when its step sets no timeout
+const DefaultTimeout = time.Minute
+
+// File is the content handed to a processor
+type File struct {
+	Name  string // Name the file is uploaded or downloaded under
+	Stage Stage
+	Path  string // The content, which the processor must not modify
+
+	// OutputPath is where a processor replacing the content writes the
+	// new content; it doesn't exist beforehand
+	OutputPath string
+}
+
+// Result is what a processor made of a file
+type Result struct {
+	// Reject refuses the file, giving the reason; empty accepts it
+	Reject string
+
+	// Replaced reports that the processor wrote new content to the
+	// file's OutputPath, which later steps and the upload or download use
+	Replaced bool
+
+	// Metadata the processor extracted, such as a checksum or tags
+	Metadata map[string]string
+}
+
+// Processor inspects and possibly replaces the content of files
+type Processor interface {
+	Process(ctx context.Context, file File) (Result, error)
+}
+
+// ProcessorFunc adapts a function to Processor
+type ProcessorFunc func(ctx context.Context, file File) (Result, error)
+
+// Process calls f(ctx, file)
+func (f ProcessorFunc) Process(ctx context.Context, file File) (Result, error) {
+	return f(ctx, file)
+}
+
+// Factory creates a processor from the settings of its pipeline step
+type Factory func(settings map[string]string) (Processor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"checksum": newChecksum,
+		"clamd":    newClamd,
+	}
+)
+
+// Register makes a processor type available to pipeline steps, replacing
+// any type already registered under that name
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// New creates a processor of a registered type
+func New(name string, settings map[string]string) (Processor, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor type %q (registered: %s)", name, strings.Join(Registered(), ", "))
+	}
+	return factory(settings)
+}
+
+// Registered returns the names of the registered processor types
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RejectedError reports that a processor refused a file
+type RejectedError struct {
+	Step   string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by processor %s: %s", e.Step, e.Reason)
+}
+
+// Step is a processor in a pipeline, with the files it applies to
+type Step struct {
+	Name      string
+	Processor Processor
+	Stages    []Stage // Stages the step runs at; upload if empty
+
+	// Extensions limits the step to files with these extensions, such as
+	// ".mp3"; empty for every file
+	Extensions []string
+
+	Timeout time.Duration // DefaultTimeout if 0
+
+	// FailOpen accepts files when the processor itself fails, rather than
+	// refusing them; rejections are always enforced
+	FailOpen bool
+}
+
+// applies reports whether the step runs on a file at a stage
+func (s Step) applies(stage Stage, name string) bool {
+	if len(s.Stages) == 0 {
+		if stage != StageUpload {
+			return false
+		}
+	} else if !containsStage(s.Stages, stage) {
+		return false
+	}
+	if len(s.Extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, candidate := range s.Extensions {
+		if strings.ToLower(candidate) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStage(stages []Stage, stage Stage) bool {
+	for _, candidate := range stages {
+		if candidate == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Pipeline runs steps in order, each on the content the previous one
+// produced. A nil pipeline has no steps.
+type Pipeline struct {
+	steps  []Step
+	logger *logging.Logger
+}
+
+// NewPipeline returns a pipeline running steps in order
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{
+		steps:  steps,
+		logger: logging.GetGlobalLogger().WithComponent("processors"),
+	}
+}
+
+// Applies reports whether any step runs on a file at a stage, so callers
+// can skip copying content the pipeline won't look at
+func (p *Pipeline) Applies(stage Stage, name string) bool {
+	if p == nil {
+		return false
+	}
+	for _, step := range p.steps {
+		if step.applies(stage, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Output is the content a pipeline produced. Close removes the temporary
+// files holding it.
+type Output struct {
+	Path     string            // The content; the input path if no step replaced it
+	Replaced bool              // Whether a step replaced the content
+	Metadata map[string]string // Metadata the steps extracted; later steps win
+
+	dir string
+}
+
+// Close removes the output's temporary files
+func (o *Output) Close() error {
+	if o.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(o.dir)
+}
+
+// Process runs the steps that apply to the file at path, which is named
+// name at stage, and returns the content they produced. A file a step
+// refuses fails with a *RejectedError.
+func (p *Pipeline) Process(ctx context.Context, stage Stage, name, path string) (*Output, error) {
+	output := &Output{Path: path, Metadata: make(map[string]string)}
+	if !p.Applies(stage, name) {
+		return output, nil
+	}
+
+	dir, err := os.MkdirTemp("", "noisefs-processors-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processor directory: %w", err)
+	}
+	output.dir = dir
+	if err := p.run(ctx, stage, name, output); err != nil {
+		output.Close()
+		return nil, err
+	}
+	return output, nil
+}
+
+// ProcessReader is Process for content that isn't in a file yet
+func (p *Pipeline) ProcessReader(ctx context.Context, stage Stage, name string, content io.Reader) (*Output, error) {
+	dir, err := os.MkdirTemp("", "noisefs-processors-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create processor directory: %w", err)
+	}
+	output := &Output{Path: filepath.Join(dir, "input"), Metadata: make(map[string]string), dir: dir}
+	if err := writeFile(output.Path, content); err != nil {
+		output.Close()
+		return nil, err
+	}
+	if err := p.run(ctx, stage, name, output); err != nil {
+		output.Close()
+		return nil, err
+	}
+	return output, nil
+}
+
+func (p *Pipeline) run(ctx context.Context, stage Stage, name string, output *Output) error {
+	if p == nil {
+		return nil
+	}
+	for i, step := range p.steps {
+		if !step.applies(stage, name) {
+			continue
+		}
+
+		file := File{
+			Name:       name,
+			Stage:      stage,
+			Path:       output.Path,
+			OutputPath: filepath.Join(output.dir, fmt.Sprintf("step-%d", i)),
+		}
+		result, err := p.runStep(ctx, step, file)
+		if err != nil {
+			if !step.FailOpen {
+				return fmt.Errorf("processor %s failed: %w", step.Name, err)
+			}
+			p.logger.Warn("Processor failed, accepting file", map[string]interface{}{
+				"processor": step.Name,
+				"stage":     string(stage),
+				"error":     err.Error(),
+			})
+			continue
+		}
+		if result.Reject != "" {
+			p.logger.Info("Processor rejected file", map[string]interface{}{
+				"processor": step.Name,
+				"stage":     string(stage),
+				"reason":    result.Reject,
+			})
+			return &RejectedError{Step: step.Name, Reason: result.Reject}
+		}
+		if result.Replaced {
+			if _, err := os.Stat(file.OutputPath); err != nil {
+				return fmt.Errorf("processor %s replaced the content but wrote no output: %w", step.Name, err)
+			}
+			output.Path = file.OutputPath
+			output.Replaced = true
+		}
+		for key, value := range result.Metadata {
+			output.Metadata[key] = value
+		}
+	}
+	if len(output.Metadata) > 0 {
+		p.logger.Debug("Processors extracted metadata", map[string]interface{}{
+			"file":     name,
+			"stage":    string(stage),
+			"metadata": output.Metadata,
+		})
+	}
+	return nil
+}
+
+func (p *Pipeline) runStep(ctx context.Context, step Step, file File) (Result, error) {
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result, err := step.Processor.Process(ctx, file)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return result, err
+}
+
+// ProcessBytes is Process for content held in memory
+func (p *Pipeline) ProcessBytes(ctx context.Context, stage Stage, name string, data []byte) ([]byte, error) {
+	if !p.Applies(stage, name) {
+		return data, nil
+	}
+	output, err := p.ProcessReader(ctx, stage, name, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+	if !output.Replaced {
+		return data, nil
+	}
+	return os.ReadFile(output.Path)
+}
+
+func writeFile(path string, content io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create processor input: %w", err)
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write processor input: %w", err)
+	}
+	return file.Close()
+}
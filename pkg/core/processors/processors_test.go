@@ -0,0 +1,236 @@
+package processors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeInput(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// appendProcessor replaces the content with itself followed by suffix
+func appendProcessor(suffix string) Processor {
+	return ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return Result{}, err
+		}
+		if err := os.WriteFile(file.OutputPath, append(data, suffix...), 0600); err != nil {
+			return Result{}, err
+		}
+		return Result{Replaced: true, Metadata: map[string]string{"last": suffix}}, nil
+	})
+}
+
+func TestPipeline_Process(t *testing.T) {
+	pipeline := NewPipeline(
+		Step{Name: "a", Processor: appendProcessor("-a")},
+		Step{Name: "b", Processor: appendProcessor("-b"), Stages: []Stage{StageUpload, StageDownload}},
+		Step{Name: "mp3", Processor: appendProcessor("-mp3"), Extensions: []string{".MP3"}},
+	)
+	input := writeInput(t, "data")
+
+	output, err := pipeline.Process(context.Background(), StageUpload, "notes.txt", input)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	data, _ := os.ReadFile(output.Path)
+	if string(data) != "data-a-b" || !output.Replaced || output.Metadata["last"] != "-b" {
+		t.Errorf("Unexpected output %q, %+v", data, output)
+	}
+	dir := filepath.Dir(output.Path)
+	output.Close()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Close should remove the temporary files")
+	}
+	if original, _ := os.ReadFile(input); string(original) != "data" {
+		t.Error("The input must not be modified")
+	}
+
+	// Extensions match case-insensitively; steps without stages only run on upload
+	output, err = pipeline.Process(context.Background(), StageDownload, "song.mp3", input)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	defer output.Close()
+	if data, _ := os.ReadFile(output.Path); string(data) != "data-b" {
+		t.Errorf("Expected only the download step, got %q", data)
+	}
+	if !pipeline.Applies(StageUpload, "song.mp3") || NewPipeline(Step{Name: "a", Processor: appendProcessor("")}).Applies(StageDownload, "x") {
+		t.Error("Applies disagrees with the steps")
+	}
+}
+
+func TestPipeline_Reject(t *testing.T) {
+	ran := false
+	pipeline := NewPipeline(
+		Step{Name: "scanner", Processor: ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+			return Result{Reject: "infected"}, nil
+		})},
+		Step{Name: "after", Processor: ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+			ran = true
+			return Result{}, nil
+		})},
+	)
+	_, err := pipeline.ProcessReader(context.Background(), StageUpload, "file", strings.NewReader("data"))
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Step != "scanner" || rejected.Reason != "infected" {
+		t.Fatalf("Expected a rejection, got %v", err)
+	}
+	if ran {
+		t.Error("Steps after a rejection should not run")
+	}
+}
+
+func TestPipeline_Failures(t *testing.T) {
+	failing := ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+		return Result{}, errors.New("scanner unavailable")
+	})
+	slow := ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+		<-ctx.Done()
+		return Result{}, ctx.Err()
+	})
+
+	if _, err := NewPipeline(Step{Name: "scan", Processor: failing}).ProcessBytes(context.Background(), StageUpload, "f", []byte("x")); err == nil {
+		t.Error("A failing processor should refuse the file")
+	}
+	data, err := NewPipeline(Step{Name: "scan", Processor: failing, FailOpen: true}).ProcessBytes(context.Background(), StageUpload, "f", []byte("x"))
+	if err != nil || string(data) != "x" {
+		t.Errorf("A fail-open processor should accept the file, got %q, %v", data, err)
+	}
+	_, err = NewPipeline(Step{Name: "slow", Processor: slow, Timeout: 10 * time.Millisecond}).ProcessBytes(context.Background(), StageUpload, "f", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+	if _, err := NewPipeline(Step{Name: "liar", Processor: ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+		return Result{Replaced: true}, nil
+	})}).ProcessBytes(context.Background(), StageUpload, "f", []byte("x")); err == nil {
+		t.Error("Claiming a replacement without output should fail")
+	}
+}
+
+func TestPipeline_Nil(t *testing.T) {
+	var pipeline *Pipeline
+	input := writeInput(t, "data")
+	output, err := pipeline.Process(context.Background(), StageUpload, "f", input)
+	if err != nil || output.Path != input || output.Replaced {
+		t.Errorf("A nil pipeline should pass files through, got %+v, %v", output, err)
+	}
+	output.Close()
+	if _, err := os.Stat(input); err != nil {
+		t.Error("Closing an unprocessed output must keep the input")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	script := func(body string) Processor {
+		return Command{Args: []string{"sh", "-c", body}}
+	}
+	run := func(processor Processor) ([]byte, *Output, error) {
+		output, err := NewPipeline(Step{Name: "cmd", Processor: processor}).ProcessReader(context.Background(), StageUpload, "song.mp3", strings.NewReader("content"))
+		if err != nil {
+			return nil, nil, err
+		}
+		t.Cleanup(func() { output.Close() })
+		data, _ := os.ReadFile(output.Path)
+		return data, output, nil
+	}
+
+	// The content comes on stdin and as NOISEFS_INPUT
+	data, output, err := run(script(`[ "$NOISEFS_STAGE" = upload ] && [ "$NOISEFS_FILENAME" = song.mp3 ] || exit 2
+		{ cat; echo " and"; cat "$NOISEFS_INPUT"; } > "$NOISEFS_OUTPUT"
+		echo '{"metadata": {"protocol": "'$NOISEFS_PROCESSOR_PROTOCOL'"}}'`))
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if string(data) != "content and\ncontent" || output.Metadata["protocol"] != ProtocolVersion {
+		t.Errorf("Unexpected output %q, %+v", data, output.Metadata)
+	}
+
+	// Text output is ignored and the content kept
+	if data, _, err := run(script(`echo "OK"`)); err != nil || string(data) != "content" {
+		t.Errorf("Expected the content unchanged, got %q, %v", data, err)
+	}
+
+	var rejected *RejectedError
+	if _, _, err := run(script(`echo "stdin: Eicar-Signature FOUND"; exit 1`)); !errors.As(err, &rejected) || rejected.Reason != "stdin: Eicar-Signature FOUND" {
+		t.Errorf("Exit status 1 should reject with the output, got %v", err)
+	}
+	if _, _, err := run(script(`echo '{"reject": "too long"}'`)); !errors.As(err, &rejected) || rejected.Reason != "too long" {
+		t.Errorf("A JSON rejection should reject, got %v", err)
+	}
+	if _, _, err := run(script(`echo "database missing" >&2; exit 2`)); err == nil || errors.As(err, &rejected) || !strings.Contains(err.Error(), "database missing") {
+		t.Errorf("Other exit statuses should fail, got %v", err)
+	}
+	if _, _, err := run(script(`echo '{broken'`)); err == nil {
+		t.Error("Invalid JSON should fail")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	sum := sha256.Sum256([]byte("bad file"))
+	digest := hex.EncodeToString(sum[:])
+	processor, err := New("checksum", map[string]string{"deny": "  " + digest + ",\n"})
+	if err != nil {
+		t.Fatalf("Failed to create checksum processor: %v", err)
+	}
+	pipeline := NewPipeline(Step{Name: "checksum", Processor: processor})
+
+	output, err := pipeline.ProcessReader(context.Background(), StageUpload, "f", strings.NewReader("good file"))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	defer output.Close()
+	goodSum := sha256.Sum256([]byte("good file"))
+	if output.Metadata["sha256"] != hex.EncodeToString(goodSum[:]) {
+		t.Errorf("Unexpected checksum %q", output.Metadata["sha256"])
+	}
+
+	var rejected *RejectedError
+	if _, err := pipeline.ProcessBytes(context.Background(), StageUpload, "f", []byte("bad file")); !errors.As(err, &rejected) {
+		t.Errorf("Expected denied content to be rejected, got %v", err)
+	}
+
+	if _, err := New("checksum", map[string]string{"deny": "abc"}); err == nil {
+		t.Error("Invalid digests should be refused")
+	}
+	if _, err := New("checksum", map[string]string{"allow": digest}); err == nil {
+		t.Error("Unknown settings should be refused")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-noop", func(settings map[string]string) (Processor, error) {
+		return ProcessorFunc(func(ctx context.Context, file File) (Result, error) {
+			return Result{Metadata: settings}, nil
+		}), nil
+	})
+	processor, err := New("test-noop", map[string]string{"key": "value"})
+	if err != nil {
+		t.Fatalf("Failed to create registered processor: %v", err)
+	}
+	result, _ := processor.Process(context.Background(), File{})
+	if result.Metadata["key"] != "value" {
+		t.Error("Settings should reach the factory")
+	}
+	if _, err := New("missing", nil); err == nil || !strings.Contains(err.Error(), "test-noop") {
+		t.Errorf("Expected an error listing registered types, got %v", err)
+	}
+}
@@ -191,7 +191,7 @@ func (s *streamerImpl) StreamUpload(ctx context.Context, reader io.Reader, opts
 
 	// Create file descriptor
 	descriptor := &descriptors.Descriptor{
-		Version:        "4.0",
+		Version:        descriptors.DescriptorVersion,
 		Type:           descriptors.FileType,
 		Filename:       opts.Filename,
 		FileSize:       totalBytes,
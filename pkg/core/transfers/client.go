@@ -0,0 +1,79 @@
+package transfers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// ClientRunner performs transfers with a NoiseFS client, so they go through
+// its content processors like any other upload or download
+type ClientRunner struct {
+	Client *noisefs.Client
+}
+
+// Download retrieves a file into path, through a temporary file that is
+// only renamed into place once the whole file has been written
+func (c *ClientRunner) Download(ctx context.Context, descriptorCID, password, path string, progress util.ProgressReporter) (Result, error) {
+	file, err := c.Client.DownloadFile(ctx, descriptorCID, password, progress)
+	if err != nil {
+		return Result{}, err
+	}
+
+	target := path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		name := filepath.Base(file.Filename)
+		if file.Filename == "" || name != file.Filename || name == "." || name == ".." {
+			return Result{}, fmt.Errorf("refusing unsafe filename %q", file.Filename)
+		}
+		target = filepath.Join(path, name)
+	}
+
+	partial, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".part-")
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create %s: %w", target, err)
+	}
+	partial.Chmod(0644)
+	if _, err := io.Copy(partial, file.Reader); err != nil {
+		partial.Close()
+		os.Remove(partial.Name())
+		return Result{}, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := partial.Close(); err != nil {
+		os.Remove(partial.Name())
+		return Result{}, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := ctx.Err(); err != nil {
+		os.Remove(partial.Name())
+		return Result{}, err
+	}
+	if err := os.Rename(partial.Name(), target); err != nil {
+		os.Remove(partial.Name())
+		return Result{}, fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return Result{Name: file.Filename, DescriptorCID: descriptorCID, Output: target, Size: file.Size}, nil
+}
+
+// Upload stores the file at path under name
+func (c *ClientRunner) Upload(ctx context.Context, path, name string, progress util.ProgressReporter) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Result{}, err
+	}
+
+	descriptorCID, err := c.Client.UploadWithProgress(ctx, file, name, progress)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Name: name, DescriptorCID: descriptorCID, Size: info.Size()}, nil
+}
@@ -0,0 +1,871 @@
+// Package transfers queues uploads and downloads and runs them in the
+// background, a few at a time and highest priority first. Transfers can be
+// paused, resumed and canceled, capped in bandwidth, individually and
+// together, and scheduled to start later, such as off-peak. The queue is
+// saved to a file so it survives restarts. The web UI runs a manager,
+// which the CLI reaches through the control socket.
+package transfers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+const (
+	// DefaultConcurrency is how many transfers run at once when the
+	// configuration doesn't say
+	DefaultConcurrency = 2
+
+	// historySize is the number of finished transfers kept
+	historySize = 100
+
+	// progressInterval limits how often progress is reported to OnChange
+	progressInterval = 500 * time.Millisecond
+)
+
+// Kind is what a transfer does
+type Kind string
+
+const (
+	KindUpload   Kind = "upload"
+	KindDownload Kind = "download"
+)
+
+// State is where a transfer is in its life
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StatePaused    State = "paused"
+	StateCompleted State = "completed"
+	StateFailed    State = "failed"
+	StateCanceled  State = "canceled"
+)
+
+// Finished reports whether a transfer in this state will not run again
+// without being resumed
+func (s State) Finished() bool {
+	return s == StateCompleted || s == StateFailed || s == StateCanceled
+}
+
+var (
+	// ErrNotFound is returned for unknown transfer IDs
+	ErrNotFound = errors.New("transfer not found")
+
+	// ErrInvalidState is returned for actions the transfer's state doesn't
+	// allow, such as pausing a completed transfer
+	ErrInvalidState = errors.New("invalid transfer state")
+)
+
+// Transfer is an upload or download in the queue
+type Transfer struct {
+	ID       string `json:"id"`
+	Kind     Kind   `json:"kind"`
+	State    State  `json:"state"`
+	Priority int    `json:"priority"` // Higher runs first
+
+	// BandwidthLimit caps the transfer in bytes per second, 0 for no cap
+	// beyond the manager's
+	BandwidthLimit int64 `json:"bandwidth_limit,omitempty"`
+
+	// StartAt holds a queued transfer back until then; nil runs it as
+	// soon as there is room
+	StartAt *time.Time `json:"start_at,omitempty"`
+
+	Name          string `json:"name,omitempty"`           // Filename, for downloads once known
+	DescriptorCID string `json:"descriptor_cid,omitempty"` // Downloaded, or created by the upload
+	Path          string `json:"path"`                     // Upload source, or download destination
+	Output        string `json:"output,omitempty"`         // File a completed download was written to
+	Size          int64  `json:"size,omitempty"`
+
+	// Progress of the current run: the stage, its units done out of
+	// total, and the bytes transferred
+	Stage   string `json:"stage,omitempty"`
+	Current int64  `json:"current,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+	Bytes   int64  `json:"bytes"`
+
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Due reports whether a queued transfer may start at now
+func (t Transfer) Due(now time.Time) bool {
+	return t.StartAt == nil || !t.StartAt.After(now)
+}
+
+// Request describes a transfer to add
+type Request struct {
+	Kind Kind
+
+	// Downloads: the descriptor, and its password if it is protected
+	DescriptorCID string
+	Password      string
+
+	// Path is the file to upload, or where to download to: a directory to
+	// save the file in under its own name, or the file to write. Empty
+	// downloads go to a directory of their own under Config.DownloadDir.
+	Path string
+
+	// Name stores an upload under a name other than the base name of Path
+	Name string
+
+	Priority int
+
+	BandwidthLimit int64     // Bytes per second, 0 for no cap
+	StartAt        time.Time // Earliest start, zero for now
+
+	// RemoveSource deletes an upload's Path once the transfer is done with
+	// it, for files staged only to be uploaded
+	RemoveSource bool
+}
+
+// Result is what a finished run produced
+type Result struct {
+	Name          string
+	DescriptorCID string
+	Output        string // Downloads: the file written
+	Size          int64
+}
+
+// Runner performs transfers; the NoiseFS client satisfies it through
+// ClientRunner. Runs are canceled through ctx when transfers are paused or
+// canceled, and must leave no partial download behind. Bandwidth caps are
+// applied by holding back progress reports, so runners report progress
+// synchronously as they go, after every block.
+type Runner interface {
+	Download(ctx context.Context, descriptorCID, password, path string, progress util.ProgressReporter) (Result, error)
+	Upload(ctx context.Context, path, name string, progress util.ProgressReporter) (Result, error)
+}
+
+// Config holds the settings of a manager
+type Config struct {
+	// StatePath is the file the queue is saved in; empty keeps it in
+	// memory only
+	StatePath string
+
+	// DownloadDir holds downloads added without a path, each in a
+	// directory named after its transfer
+	DownloadDir string
+
+	Concurrency int // DefaultConcurrency if 0
+
+	// Bandwidth caps all transfers together in bytes per second, 0 for
+	// no cap
+	Bandwidth int64
+}
+
+// record is a transfer with the details the API doesn't show
+type record struct {
+	Transfer
+	Password     string `json:"password,omitempty"`
+	RemoveSource bool   `json:"remove_source,omitempty"`
+	OwnsPath     bool   `json:"owns_path,omitempty"` // Path is a download directory the manager created
+
+	notified time.Time // Last progress report to OnChange
+}
+
+// activeRun is a run in progress
+type activeRun struct {
+	cancel  context.CancelFunc
+	limiter *workers.BandwidthLimiter // The transfer's cap, nil for none
+	bytes   int64                     // Bytes paced so far
+}
+
+// Manager runs queued transfers
+type Manager struct {
+	runner    Runner
+	config    Config
+	logger    *logging.Logger
+	bandwidth *workers.BandwidthLimiter // Shared by all runs, nil for no cap
+
+	mu       sync.Mutex
+	records  map[string]*record
+	running  map[string]*activeRun // Runs in progress, including ones winding down
+	started  bool
+	stopping bool
+	wakeup   *time.Timer // Fires when the next scheduled transfer is due
+	onChange []func(Transfer)
+	wg       sync.WaitGroup
+}
+
+// NewManager creates a manager, loading the queue saved at
+// cfg.StatePath. Transfers that were running when it was saved are queued
+// again; they start over, though blocks already retrieved usually come
+// from the block cache.
+func NewManager(runner Runner, cfg Config) (*Manager, error) {
+	if runner == nil {
+		return nil, errors.New("runner cannot be nil")
+	}
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("concurrency cannot be negative (current: %d)", cfg.Concurrency)
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
+	if cfg.Bandwidth < 0 {
+		return nil, fmt.Errorf("bandwidth cannot be negative (current: %d)", cfg.Bandwidth)
+	}
+	m := &Manager{
+		runner:    runner,
+		config:    cfg,
+		logger:    logging.GetGlobalLogger().WithComponent("transfers"),
+		bandwidth: workers.NewBandwidthLimiter(cfg.Bandwidth),
+		records:   make(map[string]*record),
+		running:   make(map[string]*activeRun),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// OnChange registers a function called with every change to a transfer,
+// including progress at most every half second. It is called without the
+// manager's lock held and must return quickly.
+func (m *Manager) OnChange(fn func(Transfer)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// Start starts running queued transfers
+func (m *Manager) Start() {
+	m.mu.Lock()
+	m.started = true
+	m.stopping = false
+	started := m.schedule()
+	m.mu.Unlock()
+	m.notify(started...)
+}
+
+// Stop interrupts running transfers, queues them to run again and saves
+// the queue
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	m.stopping = true
+	if m.wakeup != nil {
+		m.wakeup.Stop()
+		m.wakeup = nil
+	}
+	for _, run := range m.running {
+		run.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = false
+	return m.save()
+}
+
+// Add queues a transfer
+func (m *Manager) Add(req Request) (Transfer, error) {
+	if req.BandwidthLimit < 0 {
+		return Transfer{}, fmt.Errorf("bandwidth limit cannot be negative (current: %d)", req.BandwidthLimit)
+	}
+	now := time.Now()
+	r := &record{
+		Transfer: Transfer{
+			Kind:           req.Kind,
+			State:          StateQueued,
+			Priority:       req.Priority,
+			BandwidthLimit: req.BandwidthLimit,
+			StartAt:        startAt(req.StartAt),
+			DescriptorCID:  req.DescriptorCID,
+			Path:           req.Path,
+			Name:           req.Name,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		},
+		Password:     req.Password,
+		RemoveSource: req.RemoveSource,
+	}
+	id, err := newID()
+	if err != nil {
+		return Transfer{}, err
+	}
+	r.ID = id
+
+	switch req.Kind {
+	case KindDownload:
+		if req.DescriptorCID == "" {
+			return Transfer{}, errors.New("a download needs a descriptor CID")
+		}
+		if r.Path == "" {
+			if m.config.DownloadDir == "" {
+				return Transfer{}, errors.New("a download needs a destination path")
+			}
+			r.Path = filepath.Join(m.config.DownloadDir, id)
+			r.OwnsPath = true
+		}
+	case KindUpload:
+		info, err := os.Stat(req.Path)
+		if err != nil {
+			return Transfer{}, fmt.Errorf("cannot upload %s: %w", req.Path, err)
+		}
+		if info.IsDir() {
+			return Transfer{}, fmt.Errorf("cannot upload %s: it is a directory", req.Path)
+		}
+		if r.Name == "" {
+			r.Name = filepath.Base(req.Path)
+		}
+		r.Size = info.Size()
+	default:
+		return Transfer{}, fmt.Errorf("unknown transfer kind %q. Valid kinds: upload, download", req.Kind)
+	}
+
+	m.mu.Lock()
+	m.records[id] = r
+	err = m.save()
+	started := m.schedule()
+	transfer := r.Transfer
+	m.mu.Unlock()
+	m.notify(append([]Transfer{transfer}, started...)...)
+	return transfer, err
+}
+
+// Get returns a transfer
+func (m *Manager) Get(id string) (Transfer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[id]
+	if !ok {
+		return Transfer{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return r.Transfer, nil
+}
+
+// List returns the transfers in queue order: running ones, queued ones by
+// priority, with those scheduled for later after those that are due,
+// paused ones, then finished ones, newest first
+func (m *Manager) List() []Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	transfers := make([]Transfer, 0, len(m.records))
+	for _, r := range m.records {
+		transfers = append(transfers, r.Transfer)
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return before(transfers[i], transfers[j])
+	})
+	return transfers
+}
+
+// stateOrder is where each state sorts in List
+var stateOrder = map[State]int{StateRunning: 0, StateQueued: 1, StatePaused: 2}
+
+// before orders transfers for List and for picking the next to run
+func before(a, b Transfer) bool {
+	orderA, activeA := stateOrder[a.State]
+	orderB, activeB := stateOrder[b.State]
+	if !activeA {
+		orderA = len(stateOrder)
+	}
+	if !activeB {
+		orderB = len(stateOrder)
+	}
+	switch {
+	case orderA != orderB:
+		return orderA < orderB
+	case !activeA:
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}
+	if a.State == StateQueued {
+		// Transfers scheduled for later come after those that are due,
+		// earliest first
+		now := time.Now()
+		dueA, dueB := a.Due(now), b.Due(now)
+		if dueA != dueB {
+			return dueA
+		}
+		if !dueA && !a.StartAt.Equal(*b.StartAt) {
+			return a.StartAt.Before(*b.StartAt)
+		}
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// Pause stops a queued or running transfer until it is resumed. A running
+// transfer is interrupted and starts over when resumed.
+func (m *Manager) Pause(id string) (Transfer, error) {
+	return m.change(id, func(r *record) error {
+		if r.State != StateQueued && r.State != StateRunning {
+			return fmt.Errorf("%w: cannot pause a %s transfer", ErrInvalidState, r.State)
+		}
+		r.State = StatePaused
+		return nil
+	})
+}
+
+// Resume queues a paused or failed transfer again
+func (m *Manager) Resume(id string) (Transfer, error) {
+	return m.change(id, func(r *record) error {
+		if r.State != StatePaused && r.State != StateFailed {
+			return fmt.Errorf("%w: cannot resume a %s transfer", ErrInvalidState, r.State)
+		}
+		r.State = StateQueued
+		r.Error = ""
+		return nil
+	})
+}
+
+// Cancel abandons a transfer that hasn't completed
+func (m *Manager) Cancel(id string) (Transfer, error) {
+	return m.change(id, func(r *record) error {
+		if r.State == StateCompleted || r.State == StateCanceled {
+			return fmt.Errorf("%w: cannot cancel a %s transfer", ErrInvalidState, r.State)
+		}
+		r.State = StateCanceled
+		r.Error = ""
+		m.cleanUp(r)
+		return nil
+	})
+}
+
+// SetPriority changes a transfer's priority, which decides the order
+// queued transfers run in; it doesn't interrupt running ones
+func (m *Manager) SetPriority(id string, priority int) (Transfer, error) {
+	return m.change(id, func(r *record) error {
+		r.Priority = priority
+		return nil
+	})
+}
+
+// SetBandwidthLimit caps a transfer at bytesPerSecond, 0 for no cap
+// beyond the manager's. Running transfers adjust without being
+// interrupted.
+func (m *Manager) SetBandwidthLimit(id string, bytesPerSecond int64) (Transfer, error) {
+	if bytesPerSecond < 0 {
+		return Transfer{}, fmt.Errorf("bandwidth limit cannot be negative (current: %d)", bytesPerSecond)
+	}
+	return m.change(id, func(r *record) error {
+		if r.State.Finished() {
+			return fmt.Errorf("%w: cannot limit a %s transfer", ErrInvalidState, r.State)
+		}
+		r.BandwidthLimit = bytesPerSecond
+		if run, running := m.running[id]; running {
+			run.setLimit(bytesPerSecond)
+		}
+		return nil
+	})
+}
+
+// Schedule holds a transfer back until at, or runs it as soon as there is
+// room if at is zero. Paused transfers are queued again, and running ones
+// are interrupted and start over when their time comes.
+func (m *Manager) Schedule(id string, at time.Time) (Transfer, error) {
+	return m.change(id, func(r *record) error {
+		if r.State != StateQueued && r.State != StateRunning && r.State != StatePaused {
+			return fmt.Errorf("%w: cannot schedule a %s transfer", ErrInvalidState, r.State)
+		}
+		r.State = StateQueued
+		r.StartAt = startAt(at)
+		return nil
+	})
+}
+
+// Remove deletes a finished transfer from the queue, along with the file
+// of a completed download the manager chose the path of
+func (m *Manager) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if !r.State.Finished() {
+		return fmt.Errorf("%w: cancel the %s transfer first", ErrInvalidState, r.State)
+	}
+	m.discard(r)
+	return m.save()
+}
+
+// change applies fn to a transfer, interrupts it if it no longer runs and
+// saves the queue
+func (m *Manager) change(id string, fn func(r *record) error) (Transfer, error) {
+	m.mu.Lock()
+	r, ok := m.records[id]
+	if !ok {
+		m.mu.Unlock()
+		return Transfer{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err := fn(r); err != nil {
+		m.mu.Unlock()
+		return Transfer{}, err
+	}
+	r.UpdatedAt = time.Now()
+	if r.State != StateRunning {
+		if run, running := m.running[id]; running {
+			run.cancel()
+			r.Stage, r.Current, r.Total, r.Bytes = "", 0, 0, 0
+		}
+	}
+	err := m.save()
+	started := m.schedule()
+	transfer := r.Transfer
+	m.mu.Unlock()
+	m.notify(append([]Transfer{transfer}, started...)...)
+	return transfer, err
+}
+
+// schedule starts due transfers while there is room and sets a timer for
+// the next scheduled one, returning the transfers started for the caller
+// to notify about once it releases m.mu, which it must hold
+func (m *Manager) schedule() []Transfer {
+	if !m.started || m.stopping {
+		return nil
+	}
+	now := time.Now()
+	var started []Transfer
+	for len(m.running) < m.config.Concurrency {
+		next := m.next(now)
+		if next == nil {
+			break
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.running[next.ID] = &activeRun{
+			cancel:  cancel,
+			limiter: workers.NewBandwidthLimiter(next.BandwidthLimit),
+		}
+		next.State = StateRunning
+		next.Error = ""
+		next.Stage, next.Current, next.Total, next.Bytes = "", 0, 0, 0
+		next.UpdatedAt = time.Now()
+		m.wg.Add(1)
+		go m.run(ctx, *next)
+		started = append(started, next.Transfer)
+	}
+	m.armWakeup(now)
+	return started
+}
+
+// next returns the due transfer to run next, skipping transfers whose
+// previous run is still winding down. The caller must hold m.mu.
+func (m *Manager) next(now time.Time) *record {
+	var best *record
+	for id, r := range m.records {
+		if r.State != StateQueued || !r.Due(now) {
+			continue
+		}
+		if _, running := m.running[id]; running {
+			continue
+		}
+		if best == nil || before(r.Transfer, best.Transfer) {
+			best = r
+		}
+	}
+	return best
+}
+
+// armWakeup sets a timer for the earliest transfer scheduled after now.
+// The caller must hold m.mu.
+func (m *Manager) armWakeup(now time.Time) {
+	var due time.Time
+	for _, r := range m.records {
+		if r.State == StateQueued && !r.Due(now) && (due.IsZero() || r.StartAt.Before(due)) {
+			due = *r.StartAt
+		}
+	}
+	if m.wakeup != nil {
+		m.wakeup.Stop()
+		m.wakeup = nil
+	}
+	if !due.IsZero() {
+		m.wakeup = time.AfterFunc(due.Sub(now), m.wake)
+	}
+}
+
+// wake starts the transfers whose scheduled time has come
+func (m *Manager) wake() {
+	m.mu.Lock()
+	started := m.schedule()
+	m.mu.Unlock()
+	m.notify(started...)
+}
+
+// run performs a transfer and records the outcome, unless the transfer
+// was paused or canceled meanwhile
+func (m *Manager) run(ctx context.Context, transfer record) {
+	defer m.wg.Done()
+	id := transfer.ID
+	progress := util.ProgressFunc(func(p util.Progress) {
+		m.progress(id, p)
+		m.pace(ctx, id, p.Bytes)
+	})
+
+	var result Result
+	var err error
+	switch transfer.Kind {
+	case KindDownload:
+		if transfer.OwnsPath {
+			err = os.MkdirAll(transfer.Path, 0700)
+		}
+		if err == nil {
+			result, err = m.runner.Download(ctx, transfer.DescriptorCID, transfer.Password, transfer.Path, progress)
+		}
+	case KindUpload:
+		result, err = m.runner.Upload(ctx, transfer.Path, transfer.Name, progress)
+	}
+
+	m.mu.Lock()
+	delete(m.running, id)
+	r, ok := m.records[id]
+	if !ok || r.State != StateRunning {
+		// Paused, canceled or removed while running
+		started := m.schedule()
+		m.mu.Unlock()
+		m.notify(started...)
+		return
+	}
+	r.UpdatedAt = time.Now()
+	switch {
+	case m.stopping && err != nil:
+		// Interrupted by Stop; runs again after a restart
+		r.State = StateQueued
+		r.Stage, r.Current, r.Total, r.Bytes = "", 0, 0, 0
+	case err != nil:
+		r.State = StateFailed
+		r.Error = err.Error()
+		m.logger.Warn("Transfer failed", map[string]interface{}{
+			"id":    id,
+			"kind":  string(r.Kind),
+			"error": err.Error(),
+		})
+	default:
+		r.State = StateCompleted
+		if result.Name != "" {
+			r.Name = result.Name
+		}
+		if result.DescriptorCID != "" {
+			r.DescriptorCID = result.DescriptorCID
+		}
+		r.Output = result.Output
+		r.Size = result.Size
+		m.cleanUp(r)
+		m.logger.Info("Transfer completed", map[string]interface{}{
+			"id":         id,
+			"kind":       string(r.Kind),
+			"descriptor": r.DescriptorCID,
+		})
+	}
+	m.prune()
+	if saveErr := m.save(); saveErr != nil {
+		m.logger.Warn("Failed to save transfer queue", map[string]interface{}{
+			"error": saveErr.Error(),
+		})
+	}
+	started := m.schedule()
+	finished := r.Transfer
+	m.mu.Unlock()
+	m.notify(append([]Transfer{finished}, started...)...)
+}
+
+// progress records a progress update of a running transfer
+func (m *Manager) progress(id string, p util.Progress) {
+	m.mu.Lock()
+	r, ok := m.records[id]
+	if !ok || r.State != StateRunning {
+		m.mu.Unlock()
+		return
+	}
+	r.Stage, r.Current, r.Total, r.Bytes = p.Stage, p.Current, p.Total, p.Bytes
+	now := time.Now()
+	if now.Sub(r.notified) < progressInterval {
+		m.mu.Unlock()
+		return
+	}
+	r.notified = now
+	transfer := r.Transfer
+	m.mu.Unlock()
+	m.notify(transfer)
+}
+
+// pace holds a run back until the bytes it has transferred fit within
+// its own and the manager's bandwidth caps
+func (m *Manager) pace(ctx context.Context, id string, bytes int64) {
+	m.mu.Lock()
+	run, ok := m.running[id]
+	if !ok || bytes <= run.bytes {
+		m.mu.Unlock()
+		return
+	}
+	n := int(bytes - run.bytes)
+	run.bytes = bytes
+	limiter := run.limiter
+	m.mu.Unlock()
+
+	// Waits only fail once ctx is canceled, which ends the run anyway
+	if limiter.WaitN(ctx, n) == nil {
+		m.bandwidth.WaitN(ctx, n)
+	}
+}
+
+// setLimit changes the cap of a run. The caller must hold the manager's
+// lock.
+func (a *activeRun) setLimit(bytesPerSecond int64) {
+	switch {
+	case bytesPerSecond <= 0:
+		a.limiter = nil
+	case a.limiter == nil:
+		a.limiter = workers.NewBandwidthLimiter(bytesPerSecond)
+	default:
+		a.limiter.SetRate(bytesPerSecond)
+	}
+}
+
+// notify calls the OnChange functions; the caller must not hold m.mu
+func (m *Manager) notify(transfers ...Transfer) {
+	if len(transfers) == 0 {
+		return
+	}
+	m.mu.Lock()
+	callbacks := append([]func(Transfer){}, m.onChange...)
+	m.mu.Unlock()
+	for _, transfer := range transfers {
+		for _, fn := range callbacks {
+			fn(transfer)
+		}
+	}
+}
+
+// cleanUp removes what a transfer that won't run again no longer needs:
+// its password, a staged upload and a canceled download's directory. The
+// caller must hold m.mu.
+func (m *Manager) cleanUp(r *record) {
+	if r.Kind == KindUpload && r.RemoveSource {
+		os.Remove(r.Path)
+	}
+	r.Password = ""
+	if r.Kind == KindDownload && r.State == StateCanceled && r.OwnsPath {
+		os.RemoveAll(r.Path)
+	}
+}
+
+// prune drops the oldest finished transfers beyond historySize. The caller
+// must hold m.mu.
+func (m *Manager) prune() {
+	var finished []*record
+	for _, r := range m.records {
+		if r.State.Finished() {
+			finished = append(finished, r)
+		}
+	}
+	if len(finished) <= historySize {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].UpdatedAt.Before(finished[j].UpdatedAt)
+	})
+	for _, r := range finished[:len(finished)-historySize] {
+		m.discard(r)
+	}
+}
+
+// discard deletes a finished transfer and the files the manager kept for
+// it. The caller must hold m.mu.
+func (m *Manager) discard(r *record) {
+	m.cleanUp(r)
+	if r.OwnsPath {
+		os.RemoveAll(r.Path)
+	}
+	delete(m.records, r.ID)
+}
+
+// stateFile is the layout of the saved queue
+type stateFile struct {
+	Transfers []*record `json:"transfers"`
+}
+
+// load reads the saved queue, queuing transfers that were running again
+func (m *Manager) load() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.config.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read transfer queue: %w", err)
+	}
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse transfer queue: %w", err)
+	}
+	for _, r := range state.Transfers {
+		if r.State == StateRunning {
+			r.State = StateQueued
+			r.Stage, r.Current, r.Total, r.Bytes = "", 0, 0, 0
+		}
+		m.records[r.ID] = r
+	}
+	return nil
+}
+
+// save writes the queue, readable only by its owner since it holds
+// descriptor passwords. The caller must hold m.mu.
+func (m *Manager) save() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+	state := stateFile{Transfers: make([]*record, 0, len(m.records))}
+	for _, r := range m.records {
+		state.Transfers = append(state.Transfers, r)
+	}
+	sort.Slice(state.Transfers, func(i, j int) bool {
+		return state.Transfers[i].CreatedAt.Before(state.Transfers[j].CreatedAt)
+	})
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize transfer queue: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.config.StatePath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpPath := m.config.StatePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write transfer queue: %w", err)
+	}
+	if err := os.Rename(tmpPath, m.config.StatePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write transfer queue: %w", err)
+	}
+	return nil
+}
+
+// startAt returns the StartAt of a transfer held back until at, nil for
+// one that may start now
+func startAt(at time.Time) *time.Time {
+	if at.IsZero() {
+		return nil
+	}
+	return &at
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate transfer ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
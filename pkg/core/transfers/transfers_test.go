@@ -0,0 +1,387 @@
+package transfers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// fakeRunner holds each run until the test finishes it or it is canceled
+type fakeRunner struct {
+	mu      sync.Mutex
+	started []string // Descriptor CIDs or upload names, in start order
+	finish  map[string]chan error
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{finish: make(map[string]chan error)}
+}
+
+func (f *fakeRunner) channel(key string) chan error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.finish[key] == nil {
+		f.finish[key] = make(chan error, 1)
+	}
+	return f.finish[key]
+}
+
+func (f *fakeRunner) wait(ctx context.Context, key string, progress util.ProgressReporter) error {
+	f.mu.Lock()
+	f.started = append(f.started, key)
+	f.mu.Unlock()
+	util.ReportProgress(progress, "Downloading blocks", 1, 4, 128)
+	select {
+	case err := <-f.channel(key):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *fakeRunner) Download(ctx context.Context, descriptorCID, password, path string, progress util.ProgressReporter) (Result, error) {
+	if err := f.wait(ctx, descriptorCID, progress); err != nil {
+		return Result{}, err
+	}
+	return Result{Name: "file-" + password, Output: filepath.Join(path, "file"), Size: 4}, nil
+}
+
+func (f *fakeRunner) Upload(ctx context.Context, path, name string, progress util.ProgressReporter) (Result, error) {
+	if err := f.wait(ctx, name, progress); err != nil {
+		return Result{}, err
+	}
+	return Result{Name: name, DescriptorCID: "cid-" + name, Size: 4}, nil
+}
+
+func (f *fakeRunner) startedRuns() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...)
+}
+
+// waitFor polls until the transfer reaches state
+func waitFor(t *testing.T, m *Manager, id string, state State) Transfer {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		transfer, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transfer.State == state {
+			return transfer
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Transfer %s is %s, expected %s", id, transfer.State, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManager_PriorityAndConcurrency(t *testing.T) {
+	runner := newFakeRunner()
+	m, err := NewManager(runner, Config{Concurrency: 1, DownloadDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "first"})
+	if _, err := m.Get(first.ID); err != nil || len(runner.startedRuns()) != 0 {
+		t.Fatal("Nothing should run before Start")
+	}
+	m.Start()
+	defer m.Stop()
+	waitFor(t, m, first.ID, StateRunning)
+
+	// Later transfers wait for the running one, then run by priority
+	low, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "low"})
+	high, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "high", Priority: 5, Password: "secret"})
+	if list := m.List(); list[0].ID != first.ID || list[1].ID != high.ID || list[2].ID != low.ID {
+		t.Errorf("List not in queue order: %+v", list)
+	}
+
+	runner.channel("first") <- nil
+	waitFor(t, m, first.ID, StateCompleted)
+	waitFor(t, m, high.ID, StateRunning)
+	if _, err := m.SetPriority(low.ID, 10); err != nil {
+		t.Fatal(err)
+	}
+	runner.channel("high") <- nil
+	done := waitFor(t, m, high.ID, StateCompleted)
+	if done.Name != "file-secret" || done.Output == "" || done.Size != 4 {
+		t.Errorf("Result not recorded: %+v", done)
+	}
+	runner.channel("low") <- errors.New("block unavailable")
+	failed := waitFor(t, m, low.ID, StateFailed)
+	if failed.Error != "block unavailable" {
+		t.Errorf("Expected the error to be recorded, got %q", failed.Error)
+	}
+
+	if got := runner.startedRuns(); len(got) != 3 || got[1] != "high" {
+		t.Errorf("Expected the high priority download second, got %v", got)
+	}
+}
+
+func TestManager_PauseResumeCancel(t *testing.T) {
+	runner := newFakeRunner()
+	m, err := NewManager(runner, Config{DownloadDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	changes := make(map[State]int)
+	m.OnChange(func(transfer Transfer) {
+		mu.Lock()
+		changes[transfer.State]++
+		mu.Unlock()
+	})
+	m.Start()
+	defer m.Stop()
+
+	download, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "cid"})
+	running := waitFor(t, m, download.ID, StateRunning)
+	if _, err := m.Resume(download.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Resuming a running transfer should fail, got %v", err)
+	}
+
+	if _, err := m.Pause(download.ID); err != nil {
+		t.Fatal(err)
+	}
+	paused := waitFor(t, m, download.ID, StatePaused)
+	if paused.Stage != "" {
+		t.Errorf("A paused transfer should have no progress, got %+v", paused)
+	}
+	if _, err := m.Resume(download.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, download.ID, StateRunning)
+	if got := runner.startedRuns(); len(got) != 2 {
+		t.Errorf("Resuming should run the transfer again, got %v", got)
+	}
+
+	if _, err := m.Cancel(download.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, download.ID, StateCanceled)
+	if _, err := os.Stat(running.Path); !os.IsNotExist(err) {
+		t.Error("A canceled download's directory should be removed")
+	}
+	if _, err := m.Pause(download.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Pausing a canceled transfer should fail, got %v", err)
+	}
+	if err := m.Remove(download.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(download.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a removed transfer to be gone, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if changes[StateRunning] < 2 || changes[StatePaused] != 1 || changes[StateCanceled] != 1 {
+		t.Errorf("Unexpected changes reported: %v", changes)
+	}
+}
+
+func TestManager_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "transfers.json")
+	staged := filepath.Join(dir, "staged")
+	if err := os.WriteFile(staged, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := newFakeRunner()
+	m, err := NewManager(runner, Config{StatePath: statePath, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	upload, err := m.Add(Request{Kind: KindUpload, Path: staged, Name: "song.mp3", RemoveSource: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paused, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "cid", Path: dir, Password: "secret"})
+	if _, err := m.Pause(paused.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, upload.ID, StateRunning)
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(statePath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("The queue should be saved readable by its owner only: %v, %v", info, err)
+	}
+
+	runner = newFakeRunner()
+	restored, err := NewManager(runner, Config{StatePath: statePath, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer, _ := restored.Get(upload.ID); transfer.State != StateQueued || transfer.Name != "song.mp3" {
+		t.Errorf("An interrupted transfer should be queued again, got %+v", transfer)
+	}
+	if transfer, _ := restored.Get(paused.ID); transfer.State != StatePaused {
+		t.Errorf("A paused transfer should stay paused, got %+v", transfer)
+	}
+
+	restored.Start()
+	defer restored.Stop()
+	runner.channel("song.mp3") <- nil
+	done := waitFor(t, restored, upload.ID, StateCompleted)
+	if done.DescriptorCID != "cid-song.mp3" {
+		t.Errorf("Expected the upload's descriptor, got %+v", done)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Error("A staged upload should be removed once uploaded")
+	}
+
+	// The password survives the restart
+	restored.Resume(paused.ID)
+	runner.channel("cid") <- nil
+	if done := waitFor(t, restored, paused.ID, StateCompleted); done.Name != "file-secret" {
+		t.Errorf("Expected the saved password to be used, got %+v", done)
+	}
+}
+
+func TestManager_Add(t *testing.T) {
+	m, err := NewManager(newFakeRunner(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []Request{
+		{Kind: "copy", Path: "x"},
+		{Kind: KindDownload, Path: t.TempDir()},
+		{Kind: KindDownload, DescriptorCID: "cid"}, // No path and no download directory
+		{Kind: KindUpload, Path: filepath.Join(t.TempDir(), "missing")},
+		{Kind: KindUpload, Path: t.TempDir()},
+	} {
+		if _, err := m.Add(req); err == nil {
+			t.Errorf("Expected %+v to be refused", req)
+		}
+	}
+	if _, err := NewManager(nil, Config{}); err == nil {
+		t.Error("A manager needs a runner")
+	}
+}
+
+func TestManager_Schedule(t *testing.T) {
+	runner := newFakeRunner()
+	m, err := NewManager(runner, Config{Concurrency: 1, DownloadDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	defer m.Stop()
+
+	later, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "later", Priority: 10, StartAt: time.Now().Add(time.Hour)})
+	soon, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "soon", StartAt: time.Now().Add(50 * time.Millisecond)})
+	if list := m.List(); list[0].ID != soon.ID || list[1].ID != later.ID {
+		t.Errorf("Expected the earlier transfer first despite its priority: %+v", list)
+	}
+	if got := runner.startedRuns(); len(got) != 0 {
+		t.Fatalf("Nothing is due yet, but %v started", got)
+	}
+	waitFor(t, m, soon.ID, StateRunning)
+
+	// Deferring a running transfer interrupts it
+	if _, err := m.Schedule(soon.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	deferred := waitFor(t, m, soon.ID, StateQueued)
+	if deferred.Stage != "" {
+		t.Errorf("A deferred transfer should have no progress, got %+v", deferred)
+	}
+
+	// A zero time runs a transfer as soon as there is room
+	if _, err := m.Schedule(later.ID, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, later.ID, StateRunning)
+	runner.channel("later") <- nil
+	waitFor(t, m, later.ID, StateCompleted)
+	if _, err := m.Schedule(later.ID, time.Time{}); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Scheduling a completed transfer should fail, got %v", err)
+	}
+	if transfer, _ := m.Get(soon.ID); transfer.State != StateQueued {
+		t.Errorf("The deferred transfer should wait for its time, got %+v", transfer)
+	}
+}
+
+// steppedRunner reports progress in steps of stepBytes as if it transferred
+// them, without waiting
+type steppedRunner struct {
+	steps     int
+	stepBytes int64
+}
+
+func (s steppedRunner) Download(ctx context.Context, descriptorCID, password, path string, progress util.ProgressReporter) (Result, error) {
+	for i := 1; i <= s.steps; i++ {
+		util.ReportProgress(progress, "Downloading blocks", int64(i), int64(s.steps), int64(i)*s.stepBytes)
+	}
+	return Result{Name: descriptorCID, Output: filepath.Join(path, descriptorCID)}, ctx.Err()
+}
+
+func (s steppedRunner) Upload(ctx context.Context, path, name string, progress util.ProgressReporter) (Result, error) {
+	return Result{}, errors.New("not supported")
+}
+
+func TestManager_Bandwidth(t *testing.T) {
+	// 3000 bytes at 2000 per second: the first 2000 pass at once, the
+	// rest waits half a second
+	runner := steppedRunner{steps: 3, stepBytes: 1000}
+	for _, tt := range []struct {
+		name    string
+		config  Config
+		request Request
+		paced   bool
+	}{
+		{"unlimited", Config{}, Request{}, false},
+		{"per transfer", Config{}, Request{BandwidthLimit: 2000}, true},
+		{"global", Config{Bandwidth: 2000}, Request{}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.DownloadDir = t.TempDir()
+			m, err := NewManager(runner, tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Start()
+			defer m.Stop()
+
+			began := time.Now()
+			tt.request.Kind, tt.request.DescriptorCID = KindDownload, "cid"
+			transfer, err := m.Add(tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, m, transfer.ID, StateCompleted)
+			if elapsed := time.Since(began); (elapsed >= 400*time.Millisecond) != tt.paced {
+				t.Errorf("Took %s, paced: %v", elapsed, tt.paced)
+			}
+		})
+	}
+
+	m, err := NewManager(runner, Config{DownloadDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Add(Request{Kind: KindDownload, DescriptorCID: "cid", BandwidthLimit: -1}); err == nil {
+		t.Error("A negative bandwidth limit should be refused")
+	}
+	queued, _ := m.Add(Request{Kind: KindDownload, DescriptorCID: "cid"})
+	if transfer, err := m.SetBandwidthLimit(queued.ID, 4096); err != nil || transfer.BandwidthLimit != 4096 {
+		t.Errorf("Failed to set the limit: %+v, %v", transfer, err)
+	}
+	if _, err := NewManager(runner, Config{Bandwidth: -1}); err == nil {
+		t.Error("A negative bandwidth should be refused")
+	}
+}
@@ -88,6 +88,11 @@ type MountConfig struct {
 	// Volume settings
 	DefaultVolumeName       string        `json:"default_volume_name"`       // Default volume name
 	FilesSubdirectory       string        `json:"files_subdirectory"`        // Name of files subdirectory (default: "files")
+	
+	// macOS (macFUSE) settings, ignored on other platforms
+	VolumeIcon              string        `json:"volume_icon,omitempty"`     // .icns file Finder shows for the volume
+	ShowInSidebar           bool          `json:"show_in_sidebar"`           // List the volume under Locations in the Finder sidebar
+	SpotlightIndexing       bool          `json:"spotlight_indexing"`        // Let Spotlight index the volume (default: false)
 }
 
 // IndexConfig holds index management configuration
@@ -376,6 +381,15 @@ func LoadConfigFromEnv() *FuseConfig {
 	if val := os.Getenv("NOISEFS_VOLUME_NAME"); val != "" {
 		config.Mount.DefaultVolumeName = val
 	}
+	if val := os.Getenv("NOISEFS_VOLUME_ICON"); val != "" {
+		config.Mount.VolumeIcon = val
+	}
+	if val := os.Getenv("NOISEFS_SHOW_IN_SIDEBAR"); val != "" {
+		config.Mount.ShowInSidebar = val == "true" || val == "1"
+	}
+	if val := os.Getenv("NOISEFS_SPOTLIGHT_INDEXING"); val != "" {
+		config.Mount.SpotlightIndexing = val == "true" || val == "1"
+	}
 	
 	return config
 }
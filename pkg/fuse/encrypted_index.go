@@ -1,14 +1,13 @@
 package fuse
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
-	"syscall"
-	"unsafe"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
 )
@@ -19,6 +18,16 @@ type EncryptedFileIndex struct {
 	password     string
 	encryptionKey *crypto.EncryptionKey
 	encrypted    bool
+	
+	// Key derived for journal records written under an earlier salt
+	journalKey *crypto.EncryptionKey
+}
+
+// sealedJournalRecord is the on-disk form of a journal record of an
+// encrypted index. The salt is kept because each start derives a new key.
+type sealedJournalRecord struct {
+	Salt []byte `json:"salt"`
+	Data []byte `json:"data"`
 }
 
 // NewEncryptedFileIndex creates a new encrypted file index
@@ -39,19 +48,79 @@ func NewEncryptedFileIndex(indexPath, password string) (*EncryptedFileIndex, err
 		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
 	}
 	
-	return &EncryptedFileIndex{
+	eidx := &EncryptedFileIndex{
 		FileIndex:     baseIndex,
 		password:      password,
 		encryptionKey: encKey,
 		encrypted:     true,
-	}, nil
+	}
+	
+	// Journal records hold file paths, so they are encrypted like the index
+	baseIndex.sealRecord = eidx.sealJournalRecord
+	baseIndex.openRecord = eidx.openJournalRecord
+	return eidx, nil
+}
+
+// sealJournalRecord encrypts a journal record with the index key
+func (eidx *EncryptedFileIndex) sealJournalRecord(record []byte) ([]byte, error) {
+	data, err := crypto.Encrypt(record, eidx.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealedJournalRecord{Salt: eidx.encryptionKey.Salt, Data: data})
 }
 
-// LoadIndex loads the index from disk, trying encrypted format first, then fallback to unencrypted
+// openJournalRecord decrypts a journal record, deriving the key again if
+// it was written before the last start
+func (eidx *EncryptedFileIndex) openJournalRecord(line []byte) ([]byte, error) {
+	var sealed sealedJournalRecord
+	if err := json.Unmarshal(line, &sealed); err != nil {
+		return nil, err
+	}
+	
+	key := eidx.encryptionKey
+	if !bytes.Equal(sealed.Salt, key.Salt) {
+		if eidx.journalKey == nil || !bytes.Equal(sealed.Salt, eidx.journalKey.Salt) {
+			derived, err := crypto.DeriveKey(eidx.password, sealed.Salt)
+			if err != nil {
+				return nil, err
+			}
+			eidx.journalKey = derived
+		}
+		key = eidx.journalKey
+	}
+	return crypto.Decrypt(sealed.Data, key)
+}
+
+// LoadIndex loads the index from disk, trying encrypted format first, then fallback to unencrypted,
+// and replays the journal of changes made since the last save
 func (eidx *EncryptedFileIndex) LoadIndex() error {
 	eidx.mu.Lock()
 	defer eidx.mu.Unlock()
 	
+	// A leftover temporary file is a save that never completed
+	os.Remove(eidx.filePath + ".tmp")
+	
+	if err := eidx.loadSnapshot(); err != nil {
+		return err
+	}
+	
+	recovered, err := eidx.replayJournal()
+	if err != nil {
+		return err
+	}
+	if recovered {
+		eidx.dirty = true
+		if err := eidx.saveLocked(); err != nil {
+			return fmt.Errorf("failed to save recovered index: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// loadSnapshot reads the index file. Callers hold mu.
+func (eidx *EncryptedFileIndex) loadSnapshot() error {
 	// If file doesn't exist, start with empty index
 	if _, err := os.Stat(eidx.filePath); os.IsNotExist(err) {
 		return nil
@@ -134,24 +203,23 @@ func (eidx *EncryptedFileIndex) parseIndexData(data []byte, wasEncrypted bool) e
 	eidx.Version = loadedIndex.Version
 	eidx.dirty = false
 	
-	return nil
+	return eidx.migrate()
 }
 
 // SaveIndex saves the index to disk with encryption if enabled
 func (eidx *EncryptedFileIndex) SaveIndex() error {
-	eidx.mu.RLock()
-	defer eidx.mu.RUnlock()
-	
+	eidx.mu.Lock()
+	defer eidx.mu.Unlock()
+	return eidx.saveLocked()
+}
+
+// saveLocked writes an encrypted snapshot of the index and clears the
+// journal. Callers hold mu.
+func (eidx *EncryptedFileIndex) saveLocked() error {
 	if !eidx.dirty {
 		return nil // No changes to save
 	}
 	
-	// Ensure directory exists
-	dir := filepath.Dir(eidx.filePath)
-	if err := os.MkdirAll(dir, 0700); err != nil { // TODO: Use config.Security.IndexDirMode
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-	
 	// Serialize the index data
 	indexData, err := json.MarshalIndent(eidx.FileIndex, "", "  ")
 	if err != nil {
@@ -190,15 +258,10 @@ func (eidx *EncryptedFileIndex) SaveIndex() error {
 	}
 	
 	// Write atomically
-	tmpPath := eidx.filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, finalData, 0600); err != nil { // TODO: Use config.Security.IndexFileMode
-		return fmt.Errorf("failed to write index file: %w", err)
-	}
-	
-	if err := os.Rename(tmpPath, eidx.filePath); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to rename index file: %w", err)
+	if err := eidx.writeSnapshot(finalData); err != nil {
+		return err
 	}
+	eidx.dirty = false
 	
 	return nil
 }
@@ -233,9 +296,7 @@ func secureZeroUnix(data []byte) {
 	}
 	
 	// Try to use mlock to prevent swapping during clearing
-	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		syscall.Syscall(syscall.SYS_MLOCK, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0)
-	}
+	mlock(data)
 	
 	// Clear memory
 	for i := range data {
@@ -243,9 +304,7 @@ func secureZeroUnix(data []byte) {
 	}
 	
 	// Unlock memory if we locked it
-	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		syscall.Syscall(syscall.SYS_MUNLOCK, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0)
-	}
+	munlock(data)
 }
 
 // secureZeroWindows implements secure memory clearing for Windows
@@ -262,15 +321,10 @@ func (eidx *EncryptedFileIndex) LockMemory() error {
 	}
 	
 	// This is a basic implementation - in production, you'd want more sophisticated memory protection
-	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		// Lock the encryption key in memory
-		if eidx.encryptionKey != nil && len(eidx.encryptionKey.Key) > 0 {
-			_, _, errno := syscall.Syscall(syscall.SYS_MLOCK, 
-				uintptr(unsafe.Pointer(&eidx.encryptionKey.Key[0])), 
-				uintptr(len(eidx.encryptionKey.Key)), 0)
-			if errno != 0 {
-				return fmt.Errorf("failed to lock memory: %v", errno)
-			}
+	// Lock the encryption key in memory
+	if eidx.encryptionKey != nil && len(eidx.encryptionKey.Key) > 0 {
+		if err := mlock(eidx.encryptionKey.Key); err != nil {
+			return fmt.Errorf("failed to lock memory: %v", err)
 		}
 	}
 	
@@ -283,12 +337,8 @@ func (eidx *EncryptedFileIndex) UnlockMemory() {
 		return
 	}
 	
-	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		if eidx.encryptionKey != nil && len(eidx.encryptionKey.Key) > 0 {
-			syscall.Syscall(syscall.SYS_MUNLOCK, 
-				uintptr(unsafe.Pointer(&eidx.encryptionKey.Key[0])), 
-				uintptr(len(eidx.encryptionKey.Key)), 0)
-		}
+	if eidx.encryptionKey != nil && len(eidx.encryptionKey.Key) > 0 {
+		munlock(eidx.encryptionKey.Key)
 	}
 }
 
@@ -298,6 +348,9 @@ func (eidx *EncryptedFileIndex) Cleanup() {
 		SecureZeroMemory(eidx.encryptionKey.Key)
 		SecureZeroMemory(eidx.encryptionKey.Salt)
 	}
+	if eidx.journalKey != nil {
+		SecureZeroMemory(eidx.journalKey.Key)
+	}
 	
 	if eidx.password != "" {
 		// Clear password from memory (best effort)
@@ -355,6 +408,9 @@ func MigrateToEncrypted(indexPath, password string) error {
 	// Copy data to encrypted index
 	encIndex.Entries = oldIndex.Entries
 	encIndex.Version = oldIndex.Version
+	if err := encIndex.migrate(); err != nil {
+		return err
+	}
 	encIndex.dirty = true
 	
 	// Create backup of old index
@@ -0,0 +1,416 @@
+//go:build fuse
+
+package fuse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// errReadOnly is returned when writing to a file opened read-only
+var errReadOnly = errors.New("file is read-only")
+
+// fileContent holds the content of an open NoiseFS file. It downloads the
+// file on first access and uploads it again when a write is flushed. The
+// go-fuse and WinFsp file handles both wrap it.
+type fileContent struct {
+	// NoiseFS components
+	client         *noisefs.Client
+	storageManager *storage.Manager
+	descriptorCID  string
+	descriptor     *descriptors.Descriptor
+
+	// File metadata
+	path     string
+	readOnly bool
+
+	// Content caching
+	mu      sync.RWMutex
+	content []byte
+	loaded  bool
+
+	// Write support
+	writeBuffer []byte
+	dirty       bool
+
+	// Index management
+	index *FileIndex
+}
+
+// loadDescriptor loads the file descriptor from storage
+func (f *fileContent) loadDescriptor() error {
+	if f.descriptor != nil {
+		return nil
+	}
+
+	store, err := descriptors.NewStore(f.storageManager)
+	if err != nil {
+		return fmt.Errorf("failed to create descriptor store: %w", err)
+	}
+
+	descriptor, err := store.Load(f.descriptorCID)
+	if err != nil {
+		return fmt.Errorf("failed to load descriptor: %w", err)
+	}
+
+	f.descriptor = descriptor
+	return nil
+}
+
+// downloadContent downloads and decrypts the file content
+func (f *fileContent) downloadContent() ([]byte, error) {
+	started := time.Now()
+	if err := f.loadDescriptor(); err != nil {
+		return nil, err
+	}
+
+	// Retrieve all blocks
+	dataBlocks := make([]*blocks.Block, len(f.descriptor.Blocks))
+	randomizer1Blocks := make([]*blocks.Block, len(f.descriptor.Blocks))
+	randomizer2Blocks := make([]*blocks.Block, len(f.descriptor.Blocks))
+
+	for i, blockPair := range f.descriptor.Blocks {
+		// Get data block
+		dataBlock, err := f.storageManager.RetrieveBlock(blockPair.DataCID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve data block %d: %w", i, err)
+		}
+		dataBlocks[i] = dataBlock
+
+		// Get first randomizer block
+		randomizer1Block, err := f.storageManager.RetrieveBlock(blockPair.RandomizerCID1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve randomizer1 block %d: %w", i, err)
+		}
+		randomizer1Blocks[i] = randomizer1Block
+
+		// Get second randomizer block (3-tuple format)
+		randomizer2Block, err := f.storageManager.RetrieveBlock(blockPair.RandomizerCID2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve randomizer2 block %d: %w", i, err)
+		}
+		randomizer2Blocks[i] = randomizer2Block
+	}
+
+	// XOR to reconstruct original blocks
+	originalBlocks := make([]*blocks.Block, len(dataBlocks))
+	for i := range dataBlocks {
+		// Use 3-tuple XOR
+		originalBlock, err := dataBlocks[i].XOR(randomizer1Blocks[i], randomizer2Blocks[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to XOR blocks: %w", err)
+		}
+		originalBlocks[i] = originalBlock
+	}
+
+	// Assemble file
+	assembler := blocks.NewAssembler()
+	data, err := assembler.Assemble(originalBlocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble file: %w", err)
+	}
+
+	// Record download
+	f.client.RecordDownload()
+	f.client.RecordDownloadDuration(time.Since(started))
+
+	return data, nil
+}
+
+// uploadFile uploads the write buffer to NoiseFS
+func (f *fileContent) uploadFile() error {
+	if f.writeBuffer == nil {
+		return fmt.Errorf("no write buffer to upload")
+	}
+	started := time.Now()
+	ctx := context.Background()
+
+	// Create a reader from the write buffer
+	reader := bytes.NewReader(f.writeBuffer)
+
+	// Create splitter with default block size
+	splitter, err := blocks.NewSplitter(blocks.DefaultBlockSize)
+	if err != nil {
+		return fmt.Errorf("failed to create splitter: %w", err)
+	}
+
+	// Split file into blocks
+	fileBlocks, err := splitter.Split(reader)
+	if err != nil {
+		return fmt.Errorf("failed to split file: %w", err)
+	}
+
+	// Create descriptor
+	descriptor := descriptors.NewDescriptor(
+		f.path,
+		int64(len(f.writeBuffer)),
+		int64(len(f.writeBuffer)),
+		blocks.DefaultBlockSize,
+	)
+
+	// Generate or select randomizer blocks (using 3-tuple format)
+	randomizer1Blocks := make([]*blocks.Block, len(fileBlocks))
+	randomizer1CIDs := make([]string, len(fileBlocks))
+	randomizer2Blocks := make([]*blocks.Block, len(fileBlocks))
+	randomizer2CIDs := make([]string, len(fileBlocks))
+
+	for i := range fileBlocks {
+		randBlock1, cid1, randBlock2, cid2, _, err := f.client.SelectRandomizers(ctx, fileBlocks[i].Size())
+		if err != nil {
+			return fmt.Errorf("failed to select randomizer blocks: %w", err)
+		}
+		randomizer1Blocks[i] = randBlock1
+		randomizer1CIDs[i] = cid1
+		randomizer2Blocks[i] = randBlock2
+		randomizer2CIDs[i] = cid2
+	}
+
+	// XOR blocks with randomizers (3-tuple: data XOR randomizer1 XOR randomizer2)
+	anonymizedBlocks := make([]*blocks.Block, len(fileBlocks))
+	for i := range fileBlocks {
+		xorBlock, err := fileBlocks[i].XOR(randomizer1Blocks[i], randomizer2Blocks[i])
+		if err != nil {
+			return fmt.Errorf("failed to XOR blocks: %w", err)
+		}
+		anonymizedBlocks[i] = xorBlock
+	}
+
+	// Store anonymized blocks in IPFS with caching
+	dataCIDs := make([]string, len(anonymizedBlocks))
+	for i, block := range anonymizedBlocks {
+		cid, err := f.client.StoreBlockWithCache(ctx, block)
+		if err != nil {
+			return fmt.Errorf("failed to store data block %d: %w", i, err)
+		}
+		dataCIDs[i] = cid
+	}
+
+	// Add block triples to descriptor (3-tuple format)
+	for i := range dataCIDs {
+		if err := descriptor.AddBlockTriple(dataCIDs[i], randomizer1CIDs[i], randomizer2CIDs[i]); err != nil {
+			return fmt.Errorf("failed to add block triple to descriptor: %w", err)
+		}
+	}
+
+	// Store descriptor in storage
+	store, err := descriptors.NewStore(f.storageManager)
+	if err != nil {
+		return fmt.Errorf("failed to create descriptor store: %w", err)
+	}
+
+	descriptorCID, err := store.Save(descriptor)
+	if err != nil {
+		return fmt.Errorf("failed to store descriptor: %w", err)
+	}
+
+	// Update descriptor CID and cache
+	f.descriptorCID = descriptorCID
+	f.descriptor = descriptor
+	f.content = make([]byte, len(f.writeBuffer))
+	copy(f.content, f.writeBuffer)
+
+	// Record upload metrics
+	totalStoredBytes := int64(0)
+	for _, block := range anonymizedBlocks {
+		totalStoredBytes += int64(len(block.Data))
+	}
+	f.client.RecordUpload(int64(len(f.writeBuffer)), totalStoredBytes*3)
+	f.client.RecordUploadDuration(time.Since(started))
+
+	// Update index if available
+	if f.index != nil {
+		f.index.AddFileWithMetadata(f.path, descriptorCID, int64(len(f.writeBuffer)), NewFileMetadata(f.path, f.writeBuffer))
+		f.index.SaveIndex()
+	}
+
+	return nil
+}
+
+// readAt returns up to n bytes of the file from off, downloading the file
+// on first access
+func (f *fileContent) readAt(n int, off int64) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Load content if not already loaded
+	if !f.loaded {
+		if f.descriptorCID != "" {
+			content, err := f.downloadContent()
+			if err != nil {
+				return nil, err
+			}
+			f.content = content
+		} else {
+			f.content = make([]byte, 0)
+		}
+		f.loaded = true
+	}
+
+	// Use write buffer if file has been modified
+	readFrom := f.content
+	if f.dirty && f.writeBuffer != nil {
+		readFrom = f.writeBuffer
+	}
+
+	// Handle offset beyond file size
+	if off >= int64(len(readFrom)) {
+		return []byte{}, nil
+	}
+
+	// Calculate read range
+	end := int(off) + n
+	if end > len(readFrom) {
+		end = len(readFrom)
+	}
+
+	return readFrom[off:end], nil
+}
+
+// writeAt writes data to the write buffer at off, starting the buffer
+// from the current content on the first write
+func (f *fileContent) writeAt(data []byte, off int64) (int, error) {
+	if f.readOnly {
+		return 0, errReadOnly
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.startWriteLocked(); err != nil {
+		return 0, err
+	}
+
+	// Calculate required buffer size
+	requiredSize := int(off) + len(data)
+	if requiredSize > len(f.writeBuffer) {
+		// Expand buffer
+		newBuffer := make([]byte, requiredSize)
+		copy(newBuffer, f.writeBuffer)
+		f.writeBuffer = newBuffer
+	}
+
+	// Write data to buffer
+	copy(f.writeBuffer[off:], data)
+	f.dirty = true
+
+	return len(data), nil
+}
+
+// truncate cuts or extends the file to size
+func (f *fileContent) truncate(size int64) error {
+	if f.readOnly {
+		return errReadOnly
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.startWriteLocked(); err != nil {
+		return err
+	}
+
+	resized := make([]byte, size)
+	copy(resized, f.writeBuffer)
+	f.writeBuffer = resized
+	f.dirty = true
+	return nil
+}
+
+// startWriteLocked makes sure the write buffer exists, holding the
+// existing content if there is any. Callers hold mu.
+func (f *fileContent) startWriteLocked() error {
+	if f.writeBuffer != nil {
+		return nil
+	}
+
+	if f.descriptorCID == "" {
+		// New file - initialize empty buffer
+		f.writeBuffer = make([]byte, 0)
+		f.loaded = true
+		return nil
+	}
+
+	// Load existing file content first
+	if !f.loaded {
+		content, err := f.downloadContent()
+		if err != nil {
+			return err
+		}
+		f.content = content
+		f.loaded = true
+	}
+	f.writeBuffer = make([]byte, len(f.content))
+	copy(f.writeBuffer, f.content)
+	return nil
+}
+
+// stat returns the current size and modification time of the file
+func (f *fileContent) stat() (int64, time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Use write buffer size if dirty, otherwise use descriptor size
+	var size int64
+	if f.dirty && f.writeBuffer != nil {
+		size = int64(len(f.writeBuffer))
+	} else if f.descriptorCID != "" {
+		if err := f.loadDescriptor(); err != nil {
+			return 0, time.Time{}, err
+		}
+		size = f.descriptor.FileSize
+	}
+
+	if f.descriptor != nil {
+		return size, f.descriptor.CreatedAt, nil
+	}
+	// Use current time for new files
+	return size, time.Now(), nil
+}
+
+// flush uploads the file if it was written since the last flush
+func (f *fileContent) flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// If not dirty, nothing to flush
+	if !f.dirty || f.writeBuffer == nil {
+		return nil
+	}
+
+	// Upload file to NoiseFS
+	if err := f.uploadFile(); err != nil {
+		return err
+	}
+
+	// Clear dirty flag
+	f.dirty = false
+	return nil
+}
+
+// release uploads any unflushed writes and drops the cached content
+func (f *fileContent) release() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Auto-flush dirty files on close
+	var err error
+	if f.dirty && f.writeBuffer != nil {
+		err = f.uploadFile()
+		f.dirty = false
+	}
+
+	// Clear cached content to free memory
+	f.content = nil
+	f.writeBuffer = nil
+	f.loaded = false
+	return err
+}
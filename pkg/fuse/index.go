@@ -1,15 +1,24 @@
 package fuse
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
 )
 
+// IndexVersion is the schema version of indexes written by this package.
+// Older indexes are migrated when loaded.
+const IndexVersion = "2.0"
+
 // EntryType represents the type of entry (file or directory)
 type EntryType string
 
@@ -31,6 +40,32 @@ type IndexEntry struct {
 	Type                  EntryType `json:"type,omitempty"`                    // Entry type (file or directory)
 	DirectoryDescriptorCID string    `json:"directory_descriptor_cid,omitempty"` // For directories
 	EncryptionKeyID       string    `json:"encryption_key_id,omitempty"`        // Key identifier for directory encryption
+	
+	// Content metadata (schema 2.0), so listings don't need the descriptor
+	Tags        []string `json:"tags,omitempty"`         // User-assigned tags
+	ContentHash string   `json:"content_hash,omitempty"` // Hex SHA-256 of the plaintext, empty if unknown
+	Encrypted   bool     `json:"encrypted,omitempty"`    // Descriptor is password-encrypted
+	MimeType    string   `json:"mime_type,omitempty"`    // Content type of the file
+}
+
+// FileMetadata is the optional content metadata recorded with a file
+type FileMetadata struct {
+	Tags        []string
+	ContentHash string
+	Encrypted   bool
+	MimeType    string
+}
+
+// NewFileMetadata describes a file whose plaintext is at hand: it hashes
+// the content and takes the MIME type from the name, or from the content
+// if the name doesn't tell
+func NewFileMetadata(name string, content []byte) FileMetadata {
+	sum := sha256.Sum256(content)
+	mimeType := validation.ContentTypeForFilename(name)
+	if mimeType == "application/octet-stream" {
+		mimeType = validation.DetectContentType(content)
+	}
+	return FileMetadata{ContentHash: hex.EncodeToString(sum[:]), MimeType: mimeType}
 }
 
 // FileIndex manages the persistent mapping of files to descriptor CIDs
@@ -42,12 +77,16 @@ type FileIndex struct {
 	mu       sync.RWMutex
 	filePath string
 	dirty    bool
+	
+	// Journal record encryption, set by EncryptedFileIndex
+	sealRecord func([]byte) ([]byte, error)
+	openRecord func([]byte) ([]byte, error)
 }
 
 // NewFileIndex creates a new file index
 func NewFileIndex(indexPath string) *FileIndex {
 	return &FileIndex{
-		Version:  "1.0",
+		Version:  IndexVersion,
 		Entries:  make(map[string]*IndexEntry),
 		filePath: indexPath,
 	}
@@ -68,151 +107,158 @@ func GetDefaultIndexPath() (string, error) {
 	return filepath.Join(noisefsDir, "index.json"), nil
 }
 
-// LoadIndex loads the index from disk
+// LoadIndex loads the index from disk and replays the journal of changes
+// made since the last save, so nothing acknowledged before a crash is lost
 func (idx *FileIndex) LoadIndex() error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
-	// If file doesn't exist, start with empty index
-	if _, err := os.Stat(idx.filePath); os.IsNotExist(err) {
-		return nil
-	}
+	// A leftover temporary file is a save that never completed
+	os.Remove(idx.filePath + ".tmp")
 	
 	data, err := os.ReadFile(idx.filePath)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read index file: %w", err)
 	}
 	
-	var loadedIndex FileIndex
-	if err := json.Unmarshal(data, &loadedIndex); err != nil {
-		return fmt.Errorf("failed to parse index file: %w", err)
+	// If file doesn't exist, start with empty index
+	if err == nil {
+		var loadedIndex FileIndex
+		if err := json.Unmarshal(data, &loadedIndex); err != nil {
+			return fmt.Errorf("failed to parse index file: %w", err)
+		}
+		
+		// Merge loaded entries
+		if loadedIndex.Entries != nil {
+			idx.Entries = loadedIndex.Entries
+		}
+		idx.Version = loadedIndex.Version
+		idx.dirty = false
+		
+		if err := idx.migrate(); err != nil {
+			return err
+		}
+		idx.normalizeKeys()
 	}
 	
-	// Merge loaded entries
-	if loadedIndex.Entries != nil {
-		idx.Entries = loadedIndex.Entries
-		
-		// Ensure backward compatibility - set type for entries without it
-		for path, entry := range idx.Entries {
-			if entry.Type == "" {
-				// Default to file type for backward compatibility
-				entry.Type = FileEntryType
-				idx.Entries[path] = entry
-			}
+	recovered, err := idx.replayJournal()
+	if err != nil {
+		return err
+	}
+	if recovered {
+		idx.dirty = true
+		if err := idx.saveLocked(); err != nil {
+			return fmt.Errorf("failed to save recovered index: %w", err)
 		}
 	}
-	idx.Version = loadedIndex.Version
-	idx.dirty = false
 	
 	return nil
 }
 
 // SaveIndex saves the index to disk
 func (idx *FileIndex) SaveIndex() error {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
-	
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.saveLocked()
+}
+
+// saveLocked writes a snapshot of the index and clears the journal.
+// Callers hold mu.
+func (idx *FileIndex) saveLocked() error {
 	if !idx.dirty {
 		return nil // No changes to save
 	}
 	
-	// Ensure directory exists
-	dir := filepath.Dir(idx.filePath)
-	if err := os.MkdirAll(dir, 0700); err != nil { // TODO: Use config.Security.IndexDirMode
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-	
 	// Marshal to JSON
 	data, err := json.MarshalIndent(idx, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal index: %w", err)
 	}
 	
-	// Write to temporary file first
-	tmpPath := idx.filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0600); err != nil { // TODO: Use config.Security.IndexFileMode
-		return fmt.Errorf("failed to write index file: %w", err)
-	}
-	
-	// Atomic rename
-	if err := os.Rename(tmpPath, idx.filePath); err != nil {
-		os.Remove(tmpPath) // Clean up on failure
-		return fmt.Errorf("failed to rename index file: %w", err)
+	if err := idx.writeSnapshot(data); err != nil {
+		return err
 	}
-	
-	// Update dirty flag (need to upgrade lock)
-	idx.mu.RUnlock()
-	idx.mu.Lock()
 	idx.dirty = false
-	idx.mu.Unlock()
-	idx.mu.RLock()
 	
 	return nil
 }
 
-// AddFile adds a file to the index
+// AddFile adds a file to the index, guessing its MIME type from the name
 func (idx *FileIndex) AddFile(path, descriptorCID string, fileSize int64) {
+	idx.AddFileWithMetadata(path, descriptorCID, fileSize, FileMetadata{})
+}
+
+// AddFileWithMetadata adds a file to the index with content metadata. An
+// empty MIME type is guessed from the name.
+func (idx *FileIndex) AddFileWithMetadata(filePath, descriptorCID string, fileSize int64, meta FileMetadata) {
+	if meta.MimeType == "" {
+		meta.MimeType = validation.ContentTypeForFilename(filePath)
+	}
+	
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
 	now := time.Now()
-	
-	// Determine directory from path
-	dir := filepath.Dir(path)
-	if dir == "." {
-		dir = ""
-	}
+	key := indexKey(filePath)
 	
 	entry := &IndexEntry{
-		Filename:      filepath.Base(path),
+		Filename:      path.Base(key),
 		DescriptorCID: descriptorCID,
 		FileSize:      fileSize,
 		CreatedAt:     now,
 		ModifiedAt:    now,
-		Directory:     dir,
+		Directory:     parentDir(key),
 		Type:          FileEntryType, // Default to file type
+		Tags:          append([]string(nil), meta.Tags...),
+		ContentHash:   meta.ContentHash,
+		Encrypted:     meta.Encrypted,
+		MimeType:      meta.MimeType,
 	}
 	
-	idx.Entries[path] = entry
+	// Rewriting a file keeps the tags given to it
+	if existing, ok := idx.Entries[key]; ok && meta.Tags == nil {
+		entry.Tags = existing.Tags
+	}
+	
+	idx.Entries[key] = entry
 	idx.dirty = true
+	idx.journalPut(key, entry)
 }
 
 // AddDirectory adds a directory to the index
-func (idx *FileIndex) AddDirectory(path, descriptorCID, encryptionKeyID string) {
+func (idx *FileIndex) AddDirectory(dirPath, descriptorCID, encryptionKeyID string) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
 	now := time.Now()
-	
-	// Determine parent directory from path
-	dir := filepath.Dir(path)
-	if dir == "." {
-		dir = ""
-	}
+	key := indexKey(dirPath)
 	
 	entry := &IndexEntry{
-		Filename:               filepath.Base(path),
+		Filename:               path.Base(key),
 		DirectoryDescriptorCID: descriptorCID,
 		FileSize:               0, // Directories have no size
 		CreatedAt:              now,
 		ModifiedAt:             now,
-		Directory:              dir,
+		Directory:              parentDir(key),
 		Type:                   DirectoryEntryType,
 		EncryptionKeyID:        encryptionKeyID,
 	}
 	
-	idx.Entries[path] = entry
+	idx.Entries[key] = entry
 	idx.dirty = true
+	idx.journalPut(key, entry)
 }
 
 // RemoveFile removes a file from the index
 func (idx *FileIndex) RemoveFile(path string) bool {
+	path = indexKey(path)
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
 	if _, exists := idx.Entries[path]; exists {
 		delete(idx.Entries, path)
 		idx.dirty = true
+		idx.journalRemove(path)
 		return true
 	}
 	return false
@@ -220,6 +266,7 @@ func (idx *FileIndex) RemoveFile(path string) bool {
 
 // GetFile gets a file entry from the index
 func (idx *FileIndex) GetFile(path string) (*IndexEntry, bool) {
+	path = indexKey(path)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -249,6 +296,7 @@ func (idx *FileIndex) ListFiles() map[string]*IndexEntry {
 
 // GetFilesInDirectory returns all files in a specific directory
 func (idx *FileIndex) GetFilesInDirectory(dir string) map[string]*IndexEntry {
+	dir = indexKey(dir)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -264,6 +312,7 @@ func (idx *FileIndex) GetFilesInDirectory(dir string) map[string]*IndexEntry {
 
 // UpdateFile updates an existing file entry
 func (idx *FileIndex) UpdateFile(path, descriptorCID string, fileSize int64) bool {
+	path = indexKey(path)
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	
@@ -274,8 +323,10 @@ func (idx *FileIndex) UpdateFile(path, descriptorCID string, fileSize int64) boo
 	
 	entry.DescriptorCID = descriptorCID
 	entry.FileSize = fileSize
+	entry.ContentHash = "" // The content changed; the old hash no longer applies
 	entry.ModifiedAt = time.Now()
 	idx.dirty = true
+	idx.journalPut(path, entry)
 	return true
 }
 
@@ -295,6 +346,7 @@ func (idx *FileIndex) IsDirty() bool {
 
 // IsDirectory checks if a path represents a directory
 func (idx *FileIndex) IsDirectory(path string) bool {
+	path = indexKey(path)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -318,6 +370,7 @@ func (idx *FileIndex) IsDirectory(path string) bool {
 
 // GetDirectory retrieves a directory entry from the index
 func (idx *FileIndex) GetDirectory(path string) (*IndexEntry, bool) {
+	path = indexKey(path)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -333,6 +386,7 @@ func (idx *FileIndex) GetDirectory(path string) (*IndexEntry, bool) {
 
 // GetDirectoriesInDirectory returns all directories in a specific directory
 func (idx *FileIndex) GetDirectoriesInDirectory(dir string) map[string]*IndexEntry {
+	dir = indexKey(dir)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -348,6 +402,7 @@ func (idx *FileIndex) GetDirectoriesInDirectory(dir string) map[string]*IndexEnt
 
 // HasDirectoryDescriptor checks if an entry has a directory descriptor
 func (idx *FileIndex) HasDirectoryDescriptor(path string) bool {
+	path = indexKey(path)
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 	
@@ -359,7 +414,74 @@ func (idx *FileIndex) HasDirectoryDescriptor(path string) bool {
 	return entry.Type == DirectoryEntryType && entry.DirectoryDescriptorCID != ""
 }
 
+// indexKey turns a path within files/ into its index key. Keys always use
+// forward slashes, so an index works on every platform.
+func indexKey(p string) string {
+	return filepath.ToSlash(p)
+}
+
+// parentDir returns the directory of an index key, "" at the top level
+func parentDir(key string) string {
+	dir := path.Dir(key)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
 // GetIndexPath returns the file path of the index
 func (idx *FileIndex) GetIndexPath() string {
 	return idx.filePath
-}
\ No newline at end of file
+}
+
+// SetTags replaces the tags of a file or directory
+func (idx *FileIndex) SetTags(path string, tags []string) bool {
+	path = indexKey(path)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	
+	entry, exists := idx.Entries[path]
+	if !exists {
+		return false
+	}
+	
+	entry.Tags = append([]string(nil), tags...)
+	entry.ModifiedAt = time.Now()
+	idx.dirty = true
+	idx.journalPut(path, entry)
+	return true
+}
+
+// SetModTime sets the modification time of a file or directory, so sync
+// tools can keep the times of the files they copy
+func (idx *FileIndex) SetModTime(path string, modTime time.Time) bool {
+	path = indexKey(path)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	
+	entry, exists := idx.Entries[path]
+	if !exists {
+		return false
+	}
+	
+	entry.ModifiedAt = modTime
+	idx.dirty = true
+	idx.journalPut(path, entry)
+	return true
+}
+
+// FindByDescriptor returns the entries whose file or directory descriptor
+// is descriptorCID
+func (idx *FileIndex) FindByDescriptor(descriptorCID string) map[string]*IndexEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	
+	result := make(map[string]*IndexEntry)
+	for path, entry := range idx.Entries {
+		if entry.DescriptorCID == descriptorCID || entry.DirectoryDescriptorCID == descriptorCID {
+			entryCopy := *entry
+			result[path] = &entryCopy
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package fuse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+)
+
+// IndexBackupFormat identifies index backup files
+const IndexBackupFormat = "noisefs-index-backup"
+
+// ErrBackupPassword is returned when an encrypted backup can't be opened
+// with the password given
+var ErrBackupPassword = errors.New("wrong password or corrupted backup")
+
+// IndexBackup is a portable copy of the file index, independent of the
+// index file's own location and encryption. Data holds the index JSON,
+// sealed with a key derived from the backup password and Salt when
+// Encrypted is set, so the backup can be stored anywhere, including IPFS.
+type IndexBackup struct {
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+	Salt      []byte    `json:"salt,omitempty"`
+	Data      []byte    `json:"data"`
+}
+
+// IndexRestoreResult reports what RestoreBackup changed
+type IndexRestoreResult struct {
+	Added    int `json:"added"`
+	Replaced int `json:"replaced"`
+	Skipped  int `json:"skipped"` // Paths already in the index, kept as they were
+}
+
+// ExportBackup returns a backup of every entry in the index. The backup is
+// encrypted with password unless it is empty.
+func (idx *FileIndex) ExportBackup(password string) ([]byte, error) {
+	idx.mu.RLock()
+	data, err := json.Marshal(idx)
+	idx.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	backup := IndexBackup{
+		Format:    IndexBackupFormat,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	if password != "" {
+		key, err := crypto.GenerateKey(password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate backup key: %w", err)
+		}
+		defer crypto.SecureZero(key.Key)
+
+		sealed, err := crypto.Encrypt(data, key)
+		crypto.SecureZero(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+		backup.Encrypted = true
+		backup.Salt = key.Salt
+		backup.Data = sealed
+	}
+
+	return json.MarshalIndent(backup, "", "  ")
+}
+
+// ReadIndexBackup opens a backup written by ExportBackup and returns its
+// entries as an index without a file, migrated to the current schema
+func ReadIndexBackup(data []byte, password string) (*FileIndex, error) {
+	var backup IndexBackup
+	if err := json.Unmarshal(data, &backup); err != nil || backup.Format != IndexBackupFormat {
+		return nil, fmt.Errorf("not a NoiseFS index backup")
+	}
+
+	indexData := backup.Data
+	if backup.Encrypted {
+		if password == "" {
+			return nil, fmt.Errorf("backup is encrypted and needs a password")
+		}
+		key, err := crypto.DeriveKey(password, backup.Salt)
+		if err != nil {
+			return nil, ErrBackupPassword
+		}
+		defer crypto.SecureZero(key.Key)
+
+		if indexData, err = crypto.Decrypt(backup.Data, key); err != nil {
+			return nil, ErrBackupPassword
+		}
+		defer crypto.SecureZero(indexData)
+	}
+
+	restored := NewFileIndex("")
+	if err := json.Unmarshal(indexData, restored); err != nil {
+		return nil, fmt.Errorf("failed to parse backed up index: %w", err)
+	}
+	if restored.Entries == nil {
+		restored.Entries = make(map[string]*IndexEntry)
+	}
+
+	restored.mu.Lock()
+	defer restored.mu.Unlock()
+	if err := restored.migrate(); err != nil {
+		return nil, err
+	}
+	restored.dirty = false
+	return restored, nil
+}
+
+// RestoreBackup adds the entries of a backup opened with ReadIndexBackup.
+// Paths already in the index keep their entry unless overwrite is set.
+// Changes are journaled like any other; call SaveIndex to write them.
+func (idx *FileIndex) RestoreBackup(backup *FileIndex, overwrite bool) IndexRestoreResult {
+	backup.mu.RLock()
+	defer backup.mu.RUnlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var result IndexRestoreResult
+	for path, entry := range backup.Entries {
+		if _, exists := idx.Entries[path]; exists {
+			if !overwrite {
+				result.Skipped++
+				continue
+			}
+			result.Replaced++
+		} else {
+			result.Added++
+		}
+
+		entryCopy := *entry
+		idx.Entries[path] = &entryCopy
+		idx.journalPut(path, &entryCopy)
+		idx.dirty = true
+	}
+	return result
+}
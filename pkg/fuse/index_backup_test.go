@@ -0,0 +1,125 @@
+package fuse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexBackupRoundTrip(t *testing.T) {
+	index := NewFileIndex("")
+	index.AddFileWithMetadata("docs/plans.txt", "QmPlans", 42, FileMetadata{Tags: []string{"work"}})
+	index.AddDirectory("docs", "QmDocs", "key-docs")
+
+	data, err := index.ExportBackup("backup secret")
+	if err != nil {
+		t.Fatalf("Failed to export backup: %v", err)
+	}
+	if bytes.Contains(data, []byte("plans.txt")) || bytes.Contains(data, []byte("QmPlans")) {
+		t.Error("Expected encrypted backup not to contain paths or CIDs")
+	}
+
+	if _, err := ReadIndexBackup(data, "wrong"); !errors.Is(err, ErrBackupPassword) {
+		t.Errorf("Expected ErrBackupPassword for a wrong password, got %v", err)
+	}
+	if _, err := ReadIndexBackup(data, ""); err == nil {
+		t.Error("Expected an error opening an encrypted backup without a password")
+	}
+
+	backup, err := ReadIndexBackup(data, "backup secret")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	entry, ok := backup.GetFile("docs/plans.txt")
+	if !ok || entry.DescriptorCID != "QmPlans" || len(entry.Tags) != 1 || entry.Tags[0] != "work" {
+		t.Errorf("Expected backed up file with its tags, got %+v", entry)
+	}
+	if _, ok := backup.GetDirectory("docs"); !ok {
+		t.Error("Expected backed up directory")
+	}
+}
+
+func TestIndexBackupUnencrypted(t *testing.T) {
+	index := NewFileIndex("")
+	index.AddFile("notes.txt", "QmNotes", 10)
+
+	data, err := index.ExportBackup("")
+	if err != nil {
+		t.Fatalf("Failed to export backup: %v", err)
+	}
+	backup, err := ReadIndexBackup(data, "")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if _, ok := backup.GetFile("notes.txt"); !ok {
+		t.Error("Expected backed up file")
+	}
+
+	if _, err := ReadIndexBackup([]byte(`{"version":"2.0","entries":{}}`), ""); err == nil {
+		t.Error("Expected an index file to be rejected as a backup")
+	}
+}
+
+func TestIndexBackupMigratesOldIndex(t *testing.T) {
+	old, _ := json.Marshal(map[string]interface{}{
+		"version": "1.0",
+		"entries": map[string]interface{}{
+			"photo.jpg": map[string]interface{}{"filename": "photo.jpg", "descriptor_cid": "QmPhoto"},
+		},
+	})
+	data, _ := json.Marshal(IndexBackup{Format: IndexBackupFormat, Data: old})
+
+	backup, err := ReadIndexBackup(data, "")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	entry, ok := backup.GetFile("photo.jpg")
+	if !ok || entry.MimeType != "image/jpeg" || backup.Version != IndexVersion {
+		t.Errorf("Expected migrated entry, got %+v (version %s)", entry, backup.Version)
+	}
+}
+
+func TestIndexRestoreBackup(t *testing.T) {
+	source := NewFileIndex("")
+	source.AddFile("a.txt", "QmA2", 2)
+	source.AddFile("b.txt", "QmB", 3)
+	data, err := source.ExportBackup("secret")
+	if err != nil {
+		t.Fatalf("Failed to export backup: %v", err)
+	}
+	backup, err := ReadIndexBackup(data, "secret")
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	index := NewFileIndex(indexPath)
+	index.AddFile("a.txt", "QmA1", 1)
+
+	result := index.RestoreBackup(backup, false)
+	if result.Added != 1 || result.Skipped != 1 || result.Replaced != 0 {
+		t.Errorf("Expected 1 added and 1 skipped, got %+v", result)
+	}
+	if entry, _ := index.GetFile("a.txt"); entry.DescriptorCID != "QmA1" {
+		t.Errorf("Expected existing entry to be kept, got %s", entry.DescriptorCID)
+	}
+
+	result = index.RestoreBackup(backup, true)
+	if result.Replaced != 2 || result.Added != 0 {
+		t.Errorf("Expected 2 replaced, got %+v", result)
+	}
+	if entry, _ := index.GetFile("a.txt"); entry.DescriptorCID != "QmA2" {
+		t.Errorf("Expected entry to be overwritten, got %s", entry.DescriptorCID)
+	}
+
+	// Restored entries are journaled before the index is saved
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if recovered.GetSize() != 2 {
+		t.Errorf("Expected 2 entries recovered from the journal, got %d", recovered.GetSize())
+	}
+}
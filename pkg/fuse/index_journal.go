@@ -0,0 +1,197 @@
+package fuse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// Journal operations
+const (
+	journalPut    = "put"
+	journalRemove = "remove"
+)
+
+// journalRecord is one index mutation made since the last save. Puts carry
+// the whole entry, so replaying a record that is already part of the
+// snapshot changes nothing.
+type journalRecord struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Entry *IndexEntry `json:"entry,omitempty"`
+}
+
+// journalPath returns the journal file kept next to the index
+func (idx *FileIndex) journalPath() string {
+	return idx.filePath + ".journal"
+}
+
+// journalPut records that path now has entry. Callers hold mu.
+func (idx *FileIndex) journalPut(path string, entry *IndexEntry) {
+	entryCopy := *entry
+	idx.journal(journalRecord{Op: journalPut, Path: path, Entry: &entryCopy})
+}
+
+// journalRemove records that path was removed. Callers hold mu.
+func (idx *FileIndex) journalRemove(path string) {
+	idx.journal(journalRecord{Op: journalRemove, Path: path})
+}
+
+// journal makes a mutation durable before it returns. If the record can't
+// be appended, the whole index is saved instead, so the mutation is never
+// acknowledged while only in memory. Callers hold mu.
+func (idx *FileIndex) journal(record journalRecord) {
+	if idx.filePath == "" {
+		return
+	}
+	err := idx.appendJournal(record)
+	if err == nil {
+		return
+	}
+
+	logger := logging.GetGlobalLogger().WithComponent("fuse")
+	logger.Warn("Failed to journal index change, saving the index instead", map[string]interface{}{
+		"path":  record.Path,
+		"error": err.Error(),
+	})
+	if err := idx.saveLocked(); err != nil {
+		logger.Error("Failed to save index, change is only in memory", map[string]interface{}{
+			"path":  record.Path,
+			"error": err.Error(),
+		})
+	}
+}
+
+// appendJournal writes a record and syncs it to disk, so it survives a
+// crash before the next SaveIndex. Callers hold mu.
+func (idx *FileIndex) appendJournal(record journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal record: %w", err)
+	}
+	if idx.sealRecord != nil {
+		if line, err = idx.sealRecord(line); err != nil {
+			return fmt.Errorf("failed to seal journal record: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.filePath), 0700); err != nil { // TODO: Use config.Security.IndexDirMode
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(idx.journalPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600) // TODO: Use config.Security.IndexFileMode
+	if err != nil {
+		return fmt.Errorf("failed to open index journal: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write index journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync index journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write index journal: %w", err)
+	}
+	return nil
+}
+
+// replayJournal applies the mutations recorded since the last save to the
+// loaded entries. A record cut short by a crash ends the replay; it was
+// never acknowledged, so nothing after it exists. It returns whether a
+// journal was found, in which case the caller saves a new snapshot so new
+// records aren't appended after a torn one. Callers hold mu.
+func (idx *FileIndex) replayJournal() (bool, error) {
+	data, err := os.ReadFile(idx.journalPath())
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read index journal: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if idx.openRecord != nil {
+			if line, err = idx.openRecord(line); err != nil {
+				break
+			}
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			break
+		}
+		switch record.Op {
+		case journalPut:
+			if record.Entry == nil {
+				continue
+			}
+			if record.Entry.Type == "" {
+				record.Entry.Type = FileEntryType
+			}
+			idx.Entries[record.Path] = record.Entry
+		case journalRemove:
+			delete(idx.Entries, record.Path)
+		}
+	}
+	return true, nil
+}
+
+// writeSnapshot replaces the index file with data. The data is synced
+// before the rename and the directory after it, so a crash leaves either
+// the old or the new index, never a partial one. The journal is removed
+// once the snapshot holds its records. Callers hold mu.
+func (idx *FileIndex) writeSnapshot(data []byte) error {
+	dir := filepath.Dir(idx.filePath)
+	if err := os.MkdirAll(dir, 0700); err != nil { // TODO: Use config.Security.IndexDirMode
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpPath := idx.filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600) // TODO: Use config.Security.IndexFileMode
+	if err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync index file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, idx.filePath); err != nil {
+		os.Remove(tmpPath) // Clean up on failure
+		return fmt.Errorf("failed to rename index file: %w", err)
+	}
+	syncDir(dir)
+
+	if err := os.Remove(idx.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove index journal: %w", err)
+	}
+	return nil
+}
+
+// syncDir makes a rename in dir durable. Not every platform can sync a
+// directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,155 @@
+package fuse
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileIndexJournalRecovery(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	index := NewFileIndex(indexPath)
+	index.AddFile("docs/saved.txt", "QmSaved", 100)
+	if err := index.SaveIndex(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+	if _, err := os.Stat(index.journalPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected journal to be removed after save, got %v", err)
+	}
+
+	// Changes after the save only reach the journal before the "crash"
+	index.AddFile("docs/new.txt", "QmNew", 200)
+	index.UpdateFile("docs/saved.txt", "QmSavedV2", 150)
+	index.AddDirectory("docs", "QmDocs", "key-docs")
+	index.RemoveFile("docs/new.txt")
+	index.AddFile("docs/later.txt", "QmLater", 300)
+
+	// A torn record and an unfinished save are what a crash leaves behind
+	journal, err := os.OpenFile(index.journalPath(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Expected journal to exist: %v", err)
+	}
+	journal.WriteString(`{"op":"put","path":"docs/torn.txt","entry":{"filen`)
+	journal.Close()
+	if err := os.WriteFile(indexPath+".tmp", []byte(`{"version":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+
+	if entry, ok := recovered.GetFile("docs/saved.txt"); !ok || entry.DescriptorCID != "QmSavedV2" || entry.FileSize != 150 {
+		t.Errorf("Expected updated entry for docs/saved.txt, got %+v", entry)
+	}
+	if _, ok := recovered.GetFile("docs/new.txt"); ok {
+		t.Error("Expected removed file to stay removed")
+	}
+	if _, ok := recovered.GetFile("docs/later.txt"); !ok {
+		t.Error("Expected docs/later.txt to be recovered from the journal")
+	}
+	if _, ok := recovered.GetDirectory("docs"); !ok {
+		t.Error("Expected directory docs to be recovered from the journal")
+	}
+	if _, ok := recovered.GetFile("docs/torn.txt"); ok {
+		t.Error("Expected torn journal record to be ignored")
+	}
+
+	// Recovery compacts the journal into the index
+	if recovered.IsDirty() {
+		t.Error("Expected recovered index to be saved")
+	}
+	for _, path := range []string{recovered.journalPath(), indexPath + ".tmp"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+	reloaded := NewFileIndex(indexPath)
+	if err := reloaded.LoadIndex(); err != nil {
+		t.Fatalf("Failed to reload index: %v", err)
+	}
+	if reloaded.GetSize() != 3 {
+		t.Errorf("Expected 3 entries after reload, got %d", reloaded.GetSize())
+	}
+}
+
+func TestFileIndexJournalWithoutSnapshot(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	index := NewFileIndex(indexPath)
+	index.AddFile("only.txt", "QmOnly", 10)
+
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if _, ok := recovered.GetFile("only.txt"); !ok {
+		t.Error("Expected file to be recovered when the index was never saved")
+	}
+	if _, err := os.Stat(indexPath); err != nil {
+		t.Errorf("Expected recovery to write the index: %v", err)
+	}
+}
+
+func TestFileIndexJournalFailureSavesIndex(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	// A directory where the journal goes makes every append fail
+	index := NewFileIndex(indexPath)
+	if err := os.Mkdir(index.journalPath(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	index.AddFile("kept.txt", "QmKept", 10)
+
+	if index.IsDirty() {
+		t.Error("Expected the index to be saved when the journal can't be written")
+	}
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if _, ok := recovered.GetFile("kept.txt"); !ok {
+		t.Error("Expected the change to be in the saved index")
+	}
+}
+
+func TestEncryptedFileIndexJournal(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+
+	index, err := NewEncryptedFileIndex(indexPath, "secret")
+	if err != nil {
+		t.Fatalf("Failed to create encrypted index: %v", err)
+	}
+	index.AddFile("private/plans.txt", "QmPlans", 42)
+
+	data, err := os.ReadFile(index.journalPath())
+	if err != nil {
+		t.Fatalf("Expected journal to exist: %v", err)
+	}
+	if bytes.Contains(data, []byte("plans.txt")) || bytes.Contains(data, []byte("QmPlans")) {
+		t.Error("Expected journal records of an encrypted index to be encrypted")
+	}
+
+	// A new start derives a new key, so the journal key comes from its salt
+	recovered, err := NewEncryptedFileIndex(indexPath, "secret")
+	if err != nil {
+		t.Fatalf("Failed to create encrypted index: %v", err)
+	}
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	if entry, ok := recovered.GetFile("private/plans.txt"); !ok || entry.DescriptorCID != "QmPlans" {
+		t.Errorf("Expected file to be recovered from the encrypted journal, got %+v", entry)
+	}
+
+	data, err = os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatalf("Expected recovery to write the index: %v", err)
+	}
+	if bytes.Contains(data, []byte("plans.txt")) {
+		t.Error("Expected recovered index to be saved encrypted")
+	}
+}
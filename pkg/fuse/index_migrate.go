@@ -0,0 +1,66 @@
+package fuse
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/validation"
+)
+
+// indexMigrations upgrade a loaded index by one schema version, keyed by
+// the version they upgrade from. Each sets the version it produces.
+var indexMigrations = map[string]func(idx *FileIndex){
+	"1.0": migrateIndexV1,
+}
+
+// migrate brings an index loaded from disk up to IndexVersion and marks it
+// dirty if anything changed, so the next save writes the new schema.
+// Callers hold mu.
+func (idx *FileIndex) migrate() error {
+	if idx.Version == "" {
+		idx.Version = "1.0" // Indexes written before versioning
+	}
+
+	for idx.Version != IndexVersion {
+		migration, ok := indexMigrations[idx.Version]
+		if !ok {
+			return fmt.Errorf("unsupported index version %q (this version of NoiseFS writes %s)", idx.Version, IndexVersion)
+		}
+		migration(idx)
+		idx.dirty = true
+	}
+	return nil
+}
+
+// migrateIndexV1 adds the 2.0 content metadata. Entry types, added to 1.0
+// indexes later, default to file, and MIME types are guessed from names;
+// hashes stay empty until a file is written again.
+func migrateIndexV1(idx *FileIndex) {
+	for _, entry := range idx.Entries {
+		if entry.Type == "" {
+			entry.Type = FileEntryType
+		}
+		if entry.Type == FileEntryType && entry.MimeType == "" {
+			entry.MimeType = validation.ContentTypeForFilename(entry.Filename)
+		}
+	}
+	idx.Version = "2.0"
+}
+
+// normalizeKeys rewrites keys and directories saved with backslashes by
+// Windows builds that didn't yet use forward slashes throughout. It changes
+// nothing on other platforms, where a backslash is part of a file name.
+// Callers hold mu.
+func (idx *FileIndex) normalizeKeys() {
+	for key, entry := range idx.Entries {
+		normalized := indexKey(key)
+		if normalized == key && indexKey(entry.Directory) == entry.Directory {
+			continue
+		}
+		delete(idx.Entries, key)
+		entry.Filename = path.Base(normalized)
+		entry.Directory = parentDir(normalized)
+		idx.Entries[normalized] = entry
+		idx.dirty = true
+	}
+}
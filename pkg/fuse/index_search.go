@@ -0,0 +1,182 @@
+package fuse
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort orders for index searches
+const (
+	SortByPath     = "path"
+	SortByName     = "name"
+	SortBySize     = "size"
+	SortByModified = "modified"
+)
+
+// IndexQuery selects entries of the file index. Zero fields match every
+// entry.
+type IndexQuery struct {
+	Text           string    // Words that must all appear in the path, tags or MIME type
+	Name           string    // Filename glob such as "*.pdf", or a substring without wildcards
+	Extensions     []string  // Filename extensions, without the dot, of which one must match
+	Tags           []string  // Tags the entry must all have
+	Directory      string    // Only entries at or below this directory
+	Type           EntryType // Only files or only directories
+	MinSize        int64
+	MaxSize        int64 // 0 for no limit
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	SortBy     string // SortByPath if empty
+	Descending bool
+	Offset     int
+	Limit      int // 0 for no limit
+}
+
+// IndexMatch is an entry found by Search
+type IndexMatch struct {
+	Path string `json:"path"`
+	IndexEntry
+}
+
+// Search returns the entries matching query, sorted and paginated as it
+// asks, and the number of matches before pagination. Matching ignores
+// case.
+func (idx *FileIndex) Search(query IndexQuery) ([]IndexMatch, int, error) {
+	if query.Name != "" {
+		if _, err := filepath.Match(query.Name, ""); err != nil {
+			return nil, 0, fmt.Errorf("invalid name pattern %q: %w", query.Name, err)
+		}
+	}
+	var less func(a, b IndexMatch) bool
+	switch query.SortBy {
+	case "", SortByPath:
+		less = func(a, b IndexMatch) bool { return a.Path < b.Path }
+	case SortByName:
+		less = func(a, b IndexMatch) bool { return a.Filename < b.Filename }
+	case SortBySize:
+		less = func(a, b IndexMatch) bool { return a.FileSize < b.FileSize }
+	case SortByModified:
+		less = func(a, b IndexMatch) bool { return a.ModifiedAt.Before(b.ModifiedAt) }
+	default:
+		return nil, 0, fmt.Errorf("unknown sort order %q", query.SortBy)
+	}
+
+	terms := strings.Fields(strings.ToLower(query.Text))
+	name := strings.ToLower(query.Name)
+	directory := strings.Trim(query.Directory, "/")
+
+	idx.mu.RLock()
+	var matches []IndexMatch
+	for path, entry := range idx.Entries {
+		if query.Type != "" && entry.Type != query.Type {
+			continue
+		}
+		if directory != "" && path != directory && !strings.HasPrefix(path, directory+"/") {
+			continue
+		}
+		if entry.FileSize < query.MinSize || (query.MaxSize > 0 && entry.FileSize > query.MaxSize) {
+			continue
+		}
+		if !query.ModifiedAfter.IsZero() && entry.ModifiedAt.Before(query.ModifiedAfter) {
+			continue
+		}
+		if !query.ModifiedBefore.IsZero() && !entry.ModifiedAt.Before(query.ModifiedBefore) {
+			continue
+		}
+		if name != "" && !matchName(name, strings.ToLower(entry.Filename)) {
+			continue
+		}
+		if len(query.Extensions) > 0 && !hasExtension(entry.Filename, query.Extensions) {
+			continue
+		}
+		if !hasTags(entry.Tags, query.Tags) {
+			continue
+		}
+		if len(terms) > 0 {
+			text := strings.ToLower(path + " " + strings.Join(entry.Tags, " ") + " " + entry.MimeType)
+			if !containsAll(text, terms) {
+				continue
+			}
+		}
+		matches = append(matches, IndexMatch{Path: path, IndexEntry: *entry})
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if query.Descending {
+			a, b = b, a
+		}
+		if less(a, b) != less(b, a) {
+			return less(a, b)
+		}
+		return matches[i].Path < matches[j].Path
+	})
+
+	total := len(matches)
+	if query.Offset > 0 {
+		if query.Offset >= len(matches) {
+			return []IndexMatch{}, total, nil
+		}
+		matches = matches[query.Offset:]
+	}
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	if matches == nil {
+		matches = []IndexMatch{}
+	}
+	return matches, total, nil
+}
+
+// matchName matches a lowercased filename against a glob, or a substring
+// if the pattern has no wildcards
+func matchName(pattern, filename string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.Contains(filename, pattern)
+	}
+	matched, _ := filepath.Match(pattern, filename)
+	return matched
+}
+
+// hasExtension reports whether filename ends in one of the extensions
+func hasExtension(filename string, extensions []string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(filename), ".")
+	for _, want := range extensions {
+		if strings.EqualFold(ext, strings.TrimPrefix(want, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags reports whether tags include every wanted tag
+func hasTags(tags, wanted []string) bool {
+	for _, want := range wanted {
+		found := false
+		for _, tag := range tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAll reports whether text contains every term
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package fuse
+
+import (
+	"testing"
+	"time"
+)
+
+func newSearchTestIndex(t *testing.T) *FileIndex {
+	t.Helper()
+	index := NewFileIndex("")
+	index.AddFileWithMetadata("docs/report-2024.pdf", "QmReport", 2_000_000, FileMetadata{Tags: []string{"work", "Finance"}})
+	index.AddFileWithMetadata("docs/notes.txt", "QmNotes", 500, FileMetadata{Tags: []string{"work"}})
+	index.AddFileWithMetadata("photos/beach.jpg", "QmBeach", 4_000_000, FileMetadata{Tags: []string{"holiday"}})
+	index.AddFile("photos/archive/report.jpg", "QmOld", 100)
+	index.AddDirectory("docs", "QmDocs", "key-docs")
+
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, path := range []string{"docs/report-2024.pdf", "docs/notes.txt", "photos/beach.jpg", "photos/archive/report.jpg"} {
+		index.Entries[path].ModifiedAt = base.AddDate(0, 0, i)
+	}
+	return index
+}
+
+func searchPaths(t *testing.T, index *FileIndex, query IndexQuery) []string {
+	t.Helper()
+	matches, _, err := index.Search(query)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	paths := make([]string, len(matches))
+	for i, match := range matches {
+		paths[i] = match.Path
+	}
+	return paths
+}
+
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFileIndexSearch(t *testing.T) {
+	index := newSearchTestIndex(t)
+
+	tests := []struct {
+		name  string
+		query IndexQuery
+		want  []string
+	}{
+		{"all files", IndexQuery{Type: FileEntryType}, []string{"docs/notes.txt", "docs/report-2024.pdf", "photos/archive/report.jpg", "photos/beach.jpg"}},
+		{"text terms", IndexQuery{Text: "REPORT pdf"}, []string{"docs/report-2024.pdf"}},
+		{"text matches tags", IndexQuery{Text: "holiday"}, []string{"photos/beach.jpg"}},
+		{"name substring", IndexQuery{Name: "report"}, []string{"docs/report-2024.pdf", "photos/archive/report.jpg"}},
+		{"name glob", IndexQuery{Name: "*.JPG"}, []string{"photos/archive/report.jpg", "photos/beach.jpg"}},
+		{"extensions", IndexQuery{Extensions: []string{".pdf", "txt"}}, []string{"docs/notes.txt", "docs/report-2024.pdf"}},
+		{"tags all required", IndexQuery{Tags: []string{"work", "finance"}}, []string{"docs/report-2024.pdf"}},
+		{"directory", IndexQuery{Directory: "/photos/", Type: FileEntryType}, []string{"photos/archive/report.jpg", "photos/beach.jpg"}},
+		{"directory includes itself", IndexQuery{Directory: "docs", Type: DirectoryEntryType}, []string{"docs"}},
+		{"size range", IndexQuery{MinSize: 1000, MaxSize: 3_000_000}, []string{"docs/report-2024.pdf"}},
+		{"date range", IndexQuery{
+			ModifiedAfter:  time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC),
+			ModifiedBefore: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+		}, []string{"docs/notes.txt"}},
+		{"sort by size descending", IndexQuery{Type: FileEntryType, SortBy: SortBySize, Descending: true}, []string{"photos/beach.jpg", "docs/report-2024.pdf", "docs/notes.txt", "photos/archive/report.jpg"}},
+		{"sort by name", IndexQuery{Type: FileEntryType, SortBy: SortByName}, []string{"photos/beach.jpg", "docs/notes.txt", "docs/report-2024.pdf", "photos/archive/report.jpg"}},
+		{"sort by modified", IndexQuery{Type: FileEntryType, SortBy: SortByModified, Descending: true}, []string{"photos/archive/report.jpg", "photos/beach.jpg", "docs/notes.txt", "docs/report-2024.pdf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchPaths(t, index, tt.query); !equalPaths(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFileIndexSearchPagination(t *testing.T) {
+	index := newSearchTestIndex(t)
+
+	matches, total, err := index.Search(IndexQuery{Type: FileEntryType, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("Expected total of 4, got %d", total)
+	}
+	if len(matches) != 2 || matches[0].Path != "docs/report-2024.pdf" || matches[1].Path != "photos/archive/report.jpg" {
+		t.Errorf("Expected second and third files, got %+v", matches)
+	}
+
+	matches, total, err = index.Search(IndexQuery{Offset: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if matches == nil || len(matches) != 0 || total != 5 {
+		t.Errorf("Expected an empty page of 5 matches, got %d of %d", len(matches), total)
+	}
+}
+
+func TestFileIndexSearchInvalidQuery(t *testing.T) {
+	index := newSearchTestIndex(t)
+
+	if _, _, err := index.Search(IndexQuery{Name: "[report"}); err == nil {
+		t.Error("Expected an error for an invalid name pattern")
+	}
+	if _, _, err := index.Search(IndexQuery{SortBy: "score"}); err == nil {
+		t.Error("Expected an error for an unknown sort order")
+	}
+}
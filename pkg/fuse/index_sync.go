@@ -0,0 +1,603 @@
+package fuse
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+)
+
+const (
+	// maxSyncHistory is how many earlier versions of an entry are kept to
+	// tell stale changes from concurrent ones. A device that missed more
+	// updates than this sees the latest one as a conflict.
+	maxSyncHistory = 16
+
+	// maxSyncOpsPerMessage keeps messages well below PubSub size limits
+	maxSyncOpsPerMessage = 100
+)
+
+// Sync message types
+const (
+	syncMsgDelta = "delta" // Changes made since the last message
+	syncMsgHello = "hello" // A device came online and asks for every entry
+	syncMsgState = "state" // Every entry, in answer to a hello
+)
+
+// IndexSyncTransport carries encrypted sync messages between the devices
+// sharing an index, such as a private PubSub topic
+type IndexSyncTransport interface {
+	Publish(data []byte) error
+	Subscribe(ctx context.Context) (<-chan []byte, error)
+}
+
+// SyncVersion identifies one version of an entry: the Lamport clock of the
+// change and the device that made it. Versions are ordered by clock, then
+// device, so every device picks the same winner of a conflict.
+type SyncVersion struct {
+	Clock  uint64 `json:"clock"`
+	Device string `json:"device"`
+}
+
+// newer reports whether v orders after other
+func (v SyncVersion) newer(other SyncVersion) bool {
+	if v.Clock != other.Clock {
+		return v.Clock > other.Clock
+	}
+	return v.Device > other.Device
+}
+
+// syncOp is a change to one path. A nil entry removes the path. History
+// lists the versions the change was made on top of, oldest first.
+type syncOp struct {
+	Path    string        `json:"path"`
+	Entry   *IndexEntry   `json:"entry,omitempty"`
+	Version SyncVersion   `json:"version"`
+	History []SyncVersion `json:"history,omitempty"`
+}
+
+type syncMessage struct {
+	Type   string   `json:"type"`
+	Device string   `json:"device"`
+	Ops    []syncOp `json:"ops,omitempty"`
+}
+
+// syncedEntry is what the syncer last saw of a path. Removed paths are
+// kept with an empty fingerprint so removals reach devices that were
+// offline.
+type syncedEntry struct {
+	Version     SyncVersion   `json:"version"`
+	History     []SyncVersion `json:"history,omitempty"`
+	Fingerprint string        `json:"fingerprint,omitempty"`
+}
+
+// syncState is persisted, encrypted with the sync key, next to the index
+type syncState struct {
+	Device  string                  `json:"device"`
+	Clock   uint64                  `json:"clock"`
+	Entries map[string]*syncedEntry `json:"entries"`
+}
+
+// SyncOptions configures an IndexSyncer
+type SyncOptions struct {
+	Passphrase string // Shared by every device of the user; derives the topic and key
+	StatePath  string // Where the sync state is kept, the index path + ".sync" if empty
+
+	Reload func() error // Reads changes other processes saved, if set
+	Save   func() error // Persists the index after remote changes, if set
+
+	OnConflict func(path, conflictPath string) // Called when concurrent changes are both kept
+	OnError    func(err error)                 // Called for errors Run recovers from
+}
+
+// IndexSyncer keeps the file indexes of several devices owned by one user
+// converged. Local changes are found by comparing the index with what was
+// last synced and published as encrypted deltas; a device coming online
+// asks the others for their full state. Changes to different paths merge.
+// When two devices change the same path concurrently, the newer version
+// wins and the other is kept beside it as "name (conflict device).ext",
+// except that a change always wins over a removal.
+type IndexSyncer struct {
+	index     *FileIndex
+	transport IndexSyncTransport
+	options   SyncOptions
+	key       *crypto.EncryptionKey
+	topic     string
+
+	mu    sync.Mutex
+	state syncState
+}
+
+// NewIndexSyncer creates a syncer for index and loads its sync state
+func NewIndexSyncer(index *FileIndex, transport IndexSyncTransport, options SyncOptions) (*IndexSyncer, error) {
+	if options.Passphrase == "" {
+		return nil, errors.New("a sync passphrase is required")
+	}
+	if options.StatePath == "" {
+		if index.filePath == "" {
+			return nil, errors.New("a state path is required for an index without a file")
+		}
+		options.StatePath = index.filePath + ".sync"
+	}
+
+	key, topic, err := deriveSyncKey(options.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s := &IndexSyncer{
+		index:     index,
+		transport: transport,
+		options:   options,
+		key:       key,
+		topic:     topic,
+	}
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SyncTopic returns the PubSub topic for a passphrase. It is derived from
+// the key, so it tells nothing about the passphrase.
+func SyncTopic(passphrase string) (string, error) {
+	_, topic, err := deriveSyncKey(passphrase)
+	return topic, err
+}
+
+// deriveSyncKey derives the message key from the passphrase. The salt
+// comes from the passphrase too, since every device must derive the same
+// key without exchanging anything.
+func deriveSyncKey(passphrase string) (*crypto.EncryptionKey, string, error) {
+	salt := sha256.Sum256([]byte("noisefs-index-sync:" + passphrase))
+	key, err := crypto.DeriveKey(passphrase, salt[:])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to derive sync key: %w", err)
+	}
+	id := sha256.Sum256(key.Key)
+	return key, "noisefs-index-sync-" + hex.EncodeToString(id[:16]), nil
+}
+
+// Device returns this device's sync identifier
+func (s *IndexSyncer) Device() string {
+	return s.state.Device
+}
+
+// Topic returns the topic the devices share
+func (s *IndexSyncer) Topic() string {
+	return s.topic
+}
+
+// Run syncs until ctx is done: it asks the other devices for their state,
+// then applies their messages as they arrive and publishes local changes
+// every interval.
+func (s *IndexSyncer) Run(ctx context.Context, interval time.Duration) error {
+	messages, err := s.transport.Subscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to sync topic: %w", err)
+	}
+	if err := s.publish(syncMessage{Type: syncMsgHello}); err != nil {
+		return err
+	}
+	if _, err := s.Sync(); err != nil {
+		s.reportError(err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case data, ok := <-messages:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("sync subscription closed")
+			}
+			if _, err := s.Receive(data); err != nil {
+				s.reportError(err)
+			}
+		case <-ticker.C:
+			if _, err := s.Sync(); err != nil {
+				s.reportError(err)
+			}
+		}
+	}
+}
+
+// Sync publishes the changes made to the index since it was last synced
+// and returns how many paths changed
+func (s *IndexSyncer) Sync() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops, err := s.scanLocked()
+	if err != nil || len(ops) == 0 {
+		return 0, err
+	}
+	if err := s.saveState(); err != nil {
+		return 0, err
+	}
+	return len(ops), s.publishOps(syncMsgDelta, ops)
+}
+
+// Receive applies a message from another device and returns how many
+// paths it changed. Messages that don't decrypt are ignored.
+func (s *IndexSyncer) Receive(data []byte) (int, error) {
+	plain, err := crypto.Decrypt(data, s.key)
+	if err != nil {
+		return 0, nil // Not for us, or corrupted in transit
+	}
+	var message syncMessage
+	if err := json.Unmarshal(plain, &message); err != nil {
+		return 0, nil
+	}
+	if message.Device == s.state.Device {
+		return 0, nil // Our own message
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Record local changes first, so remote ones can't overwrite them
+	// unnoticed
+	localOps, err := s.scanLocked()
+	if err != nil {
+		return 0, err
+	}
+	if len(localOps) > 0 {
+		if err := s.publishOps(syncMsgDelta, localOps); err != nil {
+			s.reportError(err)
+		}
+	}
+
+	if message.Type == syncMsgHello {
+		if err := s.saveState(); err != nil {
+			return 0, err
+		}
+		return 0, s.publishOps(syncMsgState, s.allOpsLocked())
+	}
+
+	var conflicts [][2]string
+	changed := 0
+	s.index.mu.Lock()
+	for _, op := range message.Ops {
+		if op.Path == "" {
+			continue
+		}
+		applied, conflictPath := s.applyLocked(op)
+		if applied {
+			changed++
+		}
+		if conflictPath != "" {
+			conflicts = append(conflicts, [2]string{op.Path, conflictPath})
+		}
+	}
+	s.index.mu.Unlock()
+
+	if err := s.saveState(); err != nil {
+		return changed, err
+	}
+	if changed > 0 && s.options.Save != nil {
+		if err := s.options.Save(); err != nil {
+			return changed, fmt.Errorf("failed to save index: %w", err)
+		}
+	}
+	if s.options.OnConflict != nil {
+		for _, conflict := range conflicts {
+			s.options.OnConflict(conflict[0], conflict[1])
+		}
+	}
+	return changed, nil
+}
+
+// scanLocked compares the index with the sync state and records a new
+// version for every path that changed. Callers hold s.mu.
+func (s *IndexSyncer) scanLocked() ([]syncOp, error) {
+	if s.options.Reload != nil {
+		if err := s.options.Reload(); err != nil {
+			return nil, fmt.Errorf("failed to reload index: %w", err)
+		}
+	}
+
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+
+	var ops []syncOp
+	for path, entry := range s.index.Entries {
+		fingerprint := entryFingerprint(entry)
+		synced := s.state.Entries[path]
+		if synced != nil && synced.Fingerprint == fingerprint {
+			continue
+		}
+		entryCopy := *entry
+		ops = append(ops, s.recordLocalLocked(path, &entryCopy, fingerprint))
+	}
+	for path, synced := range s.state.Entries {
+		if _, exists := s.index.Entries[path]; !exists && synced.Fingerprint != "" {
+			ops = append(ops, s.recordLocalLocked(path, nil, ""))
+		}
+	}
+	return ops, nil
+}
+
+// recordLocalLocked gives a local change a new version. Callers hold s.mu.
+func (s *IndexSyncer) recordLocalLocked(path string, entry *IndexEntry, fingerprint string) syncOp {
+	s.state.Clock++
+	version := SyncVersion{Clock: s.state.Clock, Device: s.state.Device}
+
+	var history []SyncVersion
+	if synced := s.state.Entries[path]; synced != nil {
+		history = mergeHistory(synced.History, synced.Version)
+	}
+	s.state.Entries[path] = &syncedEntry{Version: version, History: history, Fingerprint: fingerprint}
+	return syncOp{Path: path, Entry: entry, Version: version, History: history}
+}
+
+// allOpsLocked returns every synced path, removals included. Callers hold
+// s.mu.
+func (s *IndexSyncer) allOpsLocked() []syncOp {
+	s.index.mu.RLock()
+	defer s.index.mu.RUnlock()
+
+	ops := make([]syncOp, 0, len(s.state.Entries))
+	for path, synced := range s.state.Entries {
+		op := syncOp{Path: path, Version: synced.Version, History: synced.History}
+		if entry, exists := s.index.Entries[path]; exists && synced.Fingerprint != "" {
+			entryCopy := *entry
+			op.Entry = &entryCopy
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Path < ops[j].Path })
+	return ops
+}
+
+// applyLocked merges a remote change into the index. It returns whether
+// the index changed and, if the change conflicted with a local one, the
+// path the losing entry was kept at. Callers hold s.mu and index.mu.
+func (s *IndexSyncer) applyLocked(op syncOp) (bool, string) {
+	if op.Version.Clock > s.state.Clock {
+		s.state.Clock = op.Version.Clock
+	}
+
+	local := s.state.Entries[op.Path]
+	switch {
+	case local == nil:
+		return s.putLocked(op.Path, op.Entry, op.Version, op.History), ""
+	case local.Version == op.Version || containsVersion(local.History, op.Version):
+		return false, "" // Already applied, or superseded here
+	case containsVersion(op.History, local.Version):
+		// The change was made on top of what we have
+		return s.putLocked(op.Path, op.Entry, op.Version, mergeHistory(op.History, local.Version)), ""
+	}
+
+	// Concurrent changes
+	history := mergeHistory(mergeHistory(local.History, op.History...), local.Version, op.Version)
+	localEntry := s.index.Entries[op.Path]
+	switch {
+	case op.Entry == nil && localEntry == nil:
+		winner := local.Version
+		if op.Version.newer(winner) {
+			winner = op.Version
+		}
+		local.Version, local.History = winner, withoutVersion(history, winner)
+		return false, ""
+	case op.Entry == nil:
+		// Keep the local entry; the other device applies it over its removal
+		local.History = withoutVersion(history, local.Version)
+		return false, ""
+	case localEntry == nil:
+		return s.putLocked(op.Path, op.Entry, op.Version, withoutVersion(history, op.Version)), ""
+	}
+
+	if entryFingerprint(op.Entry) == local.Fingerprint {
+		// Both made the same change
+		if op.Version.newer(local.Version) {
+			local.Version = op.Version
+		}
+		local.History = withoutVersion(history, local.Version)
+		return false, ""
+	}
+
+	loser, loserVersion := localEntry, local.Version
+	if local.Version.newer(op.Version) {
+		loser, loserVersion = op.Entry, op.Version
+		local.History = withoutVersion(history, local.Version)
+	} else {
+		s.putLocked(op.Path, op.Entry, op.Version, withoutVersion(history, op.Version))
+	}
+
+	conflictPath := syncConflictPath(op.Path, loserVersion.Device)
+	loserCopy := *loser
+	loserCopy.Filename = filepath.Base(conflictPath)
+	s.putLocked(conflictPath, &loserCopy, loserVersion, nil)
+	return true, conflictPath
+}
+
+// putLocked sets a path to a remote entry, or removes it for a nil entry,
+// and records its version. Callers hold s.mu and index.mu.
+func (s *IndexSyncer) putLocked(path string, entry *IndexEntry, version SyncVersion, history []SyncVersion) bool {
+	synced := &syncedEntry{Version: version, History: history}
+	s.state.Entries[path] = synced
+
+	if entry == nil {
+		if _, exists := s.index.Entries[path]; !exists {
+			return false
+		}
+		delete(s.index.Entries, path)
+		s.index.journalRemove(path)
+		s.index.dirty = true
+		return true
+	}
+
+	entryCopy := *entry
+	if entryCopy.Type == "" {
+		entryCopy.Type = FileEntryType
+	}
+	synced.Fingerprint = entryFingerprint(&entryCopy)
+	if current, exists := s.index.Entries[path]; exists && entryFingerprint(current) == synced.Fingerprint {
+		return false
+	}
+	s.index.Entries[path] = &entryCopy
+	s.index.journalPut(path, &entryCopy)
+	s.index.dirty = true
+	return true
+}
+
+// publishOps publishes ops in messages of bounded size
+func (s *IndexSyncer) publishOps(messageType string, ops []syncOp) error {
+	for len(ops) > 0 {
+		n := len(ops)
+		if n > maxSyncOpsPerMessage {
+			n = maxSyncOpsPerMessage
+		}
+		if err := s.publish(syncMessage{Type: messageType, Ops: ops[:n]}); err != nil {
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
+
+// publish encrypts and sends a message
+func (s *IndexSyncer) publish(message syncMessage) error {
+	message.Device = s.state.Device
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync message: %w", err)
+	}
+	sealed, err := crypto.Encrypt(data, s.key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt sync message: %w", err)
+	}
+	if err := s.transport.Publish(sealed); err != nil {
+		return fmt.Errorf("failed to publish sync message: %w", err)
+	}
+	return nil
+}
+
+// loadState reads the sync state, or starts a new one with a fresh device
+// identifier
+func (s *IndexSyncer) loadState() error {
+	data, err := os.ReadFile(s.options.StatePath)
+	if os.IsNotExist(err) {
+		device := make([]byte, 8)
+		if _, err := rand.Read(device); err != nil {
+			return fmt.Errorf("failed to generate device identifier: %w", err)
+		}
+		s.state = syncState{Device: hex.EncodeToString(device), Entries: make(map[string]*syncedEntry)}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	plain, err := crypto.Decrypt(data, s.key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt sync state (was the passphrase changed?): %w", err)
+	}
+	if err := json.Unmarshal(plain, &s.state); err != nil {
+		return fmt.Errorf("failed to parse sync state: %w", err)
+	}
+	if s.state.Entries == nil {
+		s.state.Entries = make(map[string]*syncedEntry)
+	}
+	return nil
+}
+
+// saveState writes the sync state. It names every path, so it is encrypted
+// like the messages. Callers hold s.mu.
+func (s *IndexSyncer) saveState() error {
+	data, err := json.Marshal(s.state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	sealed, err := crypto.Encrypt(data, s.key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt sync state: %w", err)
+	}
+
+	tmpPath := s.options.StatePath + ".tmp"
+	if err := os.WriteFile(tmpPath, sealed, 0600); err != nil { // TODO: Use config.Security.IndexFileMode
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.options.StatePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+func (s *IndexSyncer) reportError(err error) {
+	if s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+// entryFingerprint identifies the content of an entry
+func entryFingerprint(entry *IndexEntry) string {
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// syncConflictPath names the copy kept of an entry that lost a conflict,
+// the same on every device
+func syncConflictPath(path, device string) string {
+	if len(device) > 8 {
+		device = device[:8]
+	}
+	ext := filepath.Ext(path)
+	if strings.Contains(ext, "/") {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(path, ext), device, ext)
+}
+
+// mergeHistory adds versions to a history, keeping it ordered and bounded
+func mergeHistory(history []SyncVersion, versions ...SyncVersion) []SyncVersion {
+	merged := make([]SyncVersion, 0, len(history)+len(versions))
+	for _, version := range append(append([]SyncVersion{}, history...), versions...) {
+		if version != (SyncVersion{}) && !containsVersion(merged, version) {
+			merged = append(merged, version)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[j].newer(merged[i]) })
+	if len(merged) > maxSyncHistory {
+		merged = merged[len(merged)-maxSyncHistory:]
+	}
+	return merged
+}
+
+// withoutVersion returns history without version
+func withoutVersion(history []SyncVersion, version SyncVersion) []SyncVersion {
+	result := history[:0:0]
+	for _, v := range history {
+		if v != version {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func containsVersion(history []SyncVersion, version SyncVersion) bool {
+	for _, v := range history {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
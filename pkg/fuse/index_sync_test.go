@@ -0,0 +1,235 @@
+package fuse
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// queueTransport collects published messages for the test to deliver
+type queueTransport struct {
+	published [][]byte
+}
+
+func (q *queueTransport) Publish(data []byte) error {
+	q.published = append(q.published, data)
+	return nil
+}
+
+func (q *queueTransport) Subscribe(ctx context.Context) (<-chan []byte, error) {
+	return make(chan []byte), nil
+}
+
+// syncDevice is one device with its own index and syncer
+type syncDevice struct {
+	index     *FileIndex
+	syncer    *IndexSyncer
+	transport *queueTransport
+	conflicts []string
+}
+
+func newSyncDevice(t *testing.T, passphrase string) *syncDevice {
+	t.Helper()
+	d := &syncDevice{
+		index:     NewFileIndex(filepath.Join(t.TempDir(), "index.json")),
+		transport: &queueTransport{},
+	}
+	syncer, err := NewIndexSyncer(d.index, d.transport, SyncOptions{
+		Passphrase: passphrase,
+		OnConflict: func(path, conflictPath string) { d.conflicts = append(d.conflicts, conflictPath) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create syncer: %v", err)
+	}
+	d.syncer = syncer
+	return d
+}
+
+// deliver passes the messages from one device to another until neither
+// has anything left to send
+func deliver(t *testing.T, devices ...*syncDevice) {
+	t.Helper()
+	for round := 0; round < 10; round++ {
+		sent := false
+		for _, from := range devices {
+			messages := from.transport.published
+			from.transport.published = nil
+			for _, message := range messages {
+				sent = true
+				for _, to := range devices {
+					if to == from {
+						continue
+					}
+					if _, err := to.syncer.Receive(message); err != nil {
+						t.Fatalf("Receive failed: %v", err)
+					}
+				}
+			}
+		}
+		if !sent {
+			return
+		}
+	}
+	t.Fatal("Devices did not stop sending messages")
+}
+
+func syncAll(t *testing.T, devices ...*syncDevice) {
+	t.Helper()
+	for _, d := range devices {
+		if _, err := d.syncer.Sync(); err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+	}
+	deliver(t, devices...)
+}
+
+func assertConverged(t *testing.T, a, b *syncDevice) {
+	t.Helper()
+	// Times lose their monotonic reading in transit, so compare encodings
+	aJSON, _ := json.Marshal(a.index.ListFiles())
+	bJSON, _ := json.Marshal(b.index.ListFiles())
+	if string(aJSON) != string(bJSON) {
+		t.Errorf("Indexes differ:\n%s\n%s", aJSON, bJSON)
+	}
+}
+
+func TestIndexSyncPropagatesChanges(t *testing.T) {
+	a, b := newSyncDevice(t, "shared secret"), newSyncDevice(t, "shared secret")
+
+	a.index.AddFile("docs/report.pdf", "QmReport", 100)
+	a.index.AddFile("docs/old.txt", "QmOld", 10)
+	syncAll(t, a, b)
+	if entry, ok := b.index.GetFile("docs/report.pdf"); !ok || entry.DescriptorCID != "QmReport" {
+		t.Fatalf("Expected file added on a to reach b, got %+v", entry)
+	}
+
+	b.index.UpdateFile("docs/report.pdf", "QmReportV2", 120)
+	b.index.RemoveFile("docs/old.txt")
+	syncAll(t, a, b)
+	if entry, _ := a.index.GetFile("docs/report.pdf"); entry.DescriptorCID != "QmReportV2" {
+		t.Errorf("Expected update from b, got %s", entry.DescriptorCID)
+	}
+	if _, ok := a.index.GetFile("docs/old.txt"); ok {
+		t.Error("Expected removal from b to reach a")
+	}
+	assertConverged(t, a, b)
+	if len(a.conflicts)+len(b.conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v %v", a.conflicts, b.conflicts)
+	}
+}
+
+func TestIndexSyncConcurrentAdditions(t *testing.T) {
+	a, b := newSyncDevice(t, "shared secret"), newSyncDevice(t, "shared secret")
+
+	a.index.AddFile("notes.txt", "QmFromA", 1)
+	b.index.AddFile("notes.txt", "QmFromB", 2)
+	a.index.AddFile("same.txt", "QmSame", 3)
+	b.index.AddFile("same.txt", "QmSame", 3)
+	b.index.Entries["same.txt"].CreatedAt = a.index.Entries["same.txt"].CreatedAt
+	b.index.Entries["same.txt"].ModifiedAt = a.index.Entries["same.txt"].ModifiedAt
+	syncAll(t, a, b)
+
+	assertConverged(t, a, b)
+	if a.index.GetSize() != 3 {
+		t.Fatalf("Expected both versions of notes.txt and one same.txt, got %v", a.index.ListFiles())
+	}
+	if len(a.conflicts) != 1 || len(b.conflicts) != 1 || a.conflicts[0] != b.conflicts[0] {
+		t.Fatalf("Expected one conflict on each device, got %v %v", a.conflicts, b.conflicts)
+	}
+
+	winner, _ := a.index.GetFile("notes.txt")
+	loser, ok := a.index.GetFile(a.conflicts[0])
+	if !ok || winner.DescriptorCID == loser.DescriptorCID {
+		t.Errorf("Expected the other version at %s, got %+v", a.conflicts[0], loser)
+	}
+	if loser.Filename != filepath.Base(a.conflicts[0]) {
+		t.Errorf("Expected conflict copy to be named %s, got %s", filepath.Base(a.conflicts[0]), loser.Filename)
+	}
+
+	// Resolved conflicts don't bounce back and forth
+	syncAll(t, a, b)
+	if len(a.conflicts) != 1 || len(b.conflicts) != 1 {
+		t.Errorf("Expected no new conflicts, got %v %v", a.conflicts, b.conflicts)
+	}
+}
+
+func TestIndexSyncChangeWinsOverRemoval(t *testing.T) {
+	a, b := newSyncDevice(t, "shared secret"), newSyncDevice(t, "shared secret")
+	a.index.AddFile("plan.txt", "QmPlan", 1)
+	syncAll(t, a, b)
+
+	a.index.RemoveFile("plan.txt")
+	b.index.UpdateFile("plan.txt", "QmPlanV2", 2)
+	syncAll(t, a, b)
+
+	assertConverged(t, a, b)
+	if entry, ok := a.index.GetFile("plan.txt"); !ok || entry.DescriptorCID != "QmPlanV2" {
+		t.Errorf("Expected the change to win over the removal, got %+v", entry)
+	}
+}
+
+func TestIndexSyncCatchUp(t *testing.T) {
+	a, b := newSyncDevice(t, "shared secret"), newSyncDevice(t, "shared secret")
+	a.index.AddFile("photo.jpg", "QmV1", 1)
+	syncAll(t, a, b)
+
+	// b is offline while a changes the file several times
+	for _, cid := range []string{"QmV2", "QmV3", "QmV4"} {
+		a.index.UpdateFile("photo.jpg", cid, 1)
+		a.syncer.Sync()
+	}
+	a.index.AddFile("new.jpg", "QmNew", 1)
+	a.syncer.Sync()
+	a.transport.published = nil
+
+	// Coming back online, b asks for the state
+	if err := b.syncer.publish(syncMessage{Type: syncMsgHello}); err != nil {
+		t.Fatal(err)
+	}
+	deliver(t, a, b)
+
+	assertConverged(t, a, b)
+	if entry, _ := b.index.GetFile("photo.jpg"); entry.DescriptorCID != "QmV4" {
+		t.Errorf("Expected latest version after catching up, got %s", entry.DescriptorCID)
+	}
+	if len(b.conflicts) != 0 {
+		t.Errorf("Expected catching up not to conflict, got %v", b.conflicts)
+	}
+}
+
+func TestIndexSyncIgnoresOtherPassphrases(t *testing.T) {
+	a, other := newSyncDevice(t, "shared secret"), newSyncDevice(t, "someone else")
+	a.index.AddFile("private.txt", "QmPrivate", 1)
+	syncAll(t, a, other)
+
+	if other.index.GetSize() != 0 {
+		t.Error("Expected messages under another passphrase to be ignored")
+	}
+	if a.syncer.Topic() == other.syncer.Topic() {
+		t.Error("Expected different passphrases to use different topics")
+	}
+}
+
+func TestIndexSyncStatePersists(t *testing.T) {
+	a := newSyncDevice(t, "shared secret")
+	a.index.AddFile("kept.txt", "QmKept", 1)
+	if _, err := a.syncer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewIndexSyncer(a.index, a.transport, SyncOptions{Passphrase: "shared secret"})
+	if err != nil {
+		t.Fatalf("Failed to reload syncer: %v", err)
+	}
+	if restarted.Device() != a.syncer.Device() {
+		t.Error("Expected the device identifier to persist")
+	}
+	if n, _ := restarted.Sync(); n != 0 {
+		t.Errorf("Expected nothing to publish after a restart, got %d changes", n)
+	}
+
+	if _, err := NewIndexSyncer(a.index, a.transport, SyncOptions{Passphrase: "changed"}); err == nil {
+		t.Error("Expected an error opening the state with another passphrase")
+	}
+}
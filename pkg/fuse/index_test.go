@@ -193,4 +193,147 @@ func TestFileIndexConcurrency(t *testing.T) {
 	if size != 100 {
 		t.Errorf("Expected 100 entries after concurrent operations, got %d", size)
 	}
-}
\ No newline at end of file
+}
+
+func TestFileIndexMigrationFromV1(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	oldIndexData := `{
+		"version": "1.0",
+		"entries": {
+			"papers/report.pdf": {
+				"filename": "report.pdf",
+				"descriptor_cid": "QmReport",
+				"file_size": 2048,
+				"directory": "papers"
+			}
+		}
+	}`
+	if err := os.WriteFile(indexPath, []byte(oldIndexData), 0600); err != nil {
+		t.Fatalf("Failed to write old index: %v", err)
+	}
+
+	index := NewFileIndex(indexPath)
+	if err := index.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load old index: %v", err)
+	}
+	if index.Version != IndexVersion {
+		t.Errorf("Expected version %s after migration, got %s", IndexVersion, index.Version)
+	}
+	if !index.IsDirty() {
+		t.Error("Expected migrated index to need saving")
+	}
+	entry, _ := index.GetFile("papers/report.pdf")
+	if entry.Type != FileEntryType || entry.MimeType != "application/pdf" {
+		t.Errorf("Expected file entry with MIME type application/pdf, got %s %q", entry.Type, entry.MimeType)
+	}
+
+	if err := index.SaveIndex(); err != nil {
+		t.Fatalf("Failed to save index: %v", err)
+	}
+	reloaded := NewFileIndex(indexPath)
+	if err := reloaded.LoadIndex(); err != nil {
+		t.Fatalf("Failed to reload index: %v", err)
+	}
+	if reloaded.IsDirty() {
+		t.Error("Expected current index to load without migration")
+	}
+}
+
+func TestFileIndexKeysUseForwardSlashes(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	index := NewFileIndex(indexPath)
+
+	// Paths built with the platform separator, as filepath.Rel returns them
+	index.AddFile(filepath.Join("papers", "2024", "report.pdf"), "QmReport", 2048)
+	index.AddDirectory(filepath.Join("papers", "archive"), "QmArchive", "")
+
+	entry, ok := index.GetFile("papers/2024/report.pdf")
+	if !ok || entry.Directory != "papers/2024" || entry.Filename != "report.pdf" {
+		t.Fatalf("Expected the file under papers/2024, got %+v", entry)
+	}
+	if !index.IsDirectory(filepath.Join("papers", "2024")) || !index.HasDirectoryDescriptor("papers/archive") {
+		t.Error("Expected lookups with either separator to find directories")
+	}
+	if len(index.GetFilesInDirectory("papers")) != 1 {
+		t.Error("Expected the archive directory directly under papers")
+	}
+}
+
+func TestFileIndexRejectsNewerVersion(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	if err := os.WriteFile(indexPath, []byte(`{"version": "3.0", "entries": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFileIndex(indexPath).LoadIndex(); err == nil {
+		t.Error("Expected an index from a newer version to be refused")
+	}
+}
+
+func TestFileIndexMetadata(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	index := NewFileIndex(indexPath)
+
+	content := []byte("hello world\n")
+	meta := NewFileMetadata("notes", content)
+	if meta.ContentHash != "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447" {
+		t.Errorf("Unexpected content hash %s", meta.ContentHash)
+	}
+	if meta.MimeType != "text/plain" {
+		t.Errorf("Expected sniffed text MIME type, got %q", meta.MimeType)
+	}
+	meta.Encrypted = true
+	meta.Tags = []string{"personal"}
+	index.AddFileWithMetadata("notes", "QmNotes", int64(len(content)), meta)
+
+	if !index.SetTags("notes", []string{"personal", "todo"}) {
+		t.Fatal("Expected SetTags to find the file")
+	}
+	if index.SetTags("missing", []string{"x"}) {
+		t.Error("Expected SetTags to fail for a missing file")
+	}
+
+	// Rewriting keeps tags, a content change drops the stale hash
+	index.AddFile("notes", "QmNotesV2", 20)
+	index.UpdateFile("notes", "QmNotesV3", 30)
+
+	// Metadata survives journal recovery
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	entries := recovered.FindByDescriptor("QmNotesV3")
+	entry, ok := entries["notes"]
+	if !ok {
+		t.Fatalf("Expected to find notes by descriptor, got %v", entries)
+	}
+	if len(entry.Tags) != 2 || entry.Tags[1] != "todo" {
+		t.Errorf("Expected tags to survive a rewrite, got %v", entry.Tags)
+	}
+	if entry.ContentHash != "" {
+		t.Errorf("Expected content hash to be cleared on update, got %s", entry.ContentHash)
+	}
+}
+
+func TestFileIndexSetModTime(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	index := NewFileIndex(indexPath)
+	index.AddFile("photos/beach.jpg", "QmBeach", 1024)
+
+	modTime := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	if !index.SetModTime("photos/beach.jpg", modTime) {
+		t.Fatal("Expected SetModTime to find the file")
+	}
+	if index.SetModTime("missing.jpg", modTime) {
+		t.Error("Expected SetModTime to fail for a missing file")
+	}
+
+	// The time survives journal recovery
+	recovered := NewFileIndex(indexPath)
+	if err := recovered.LoadIndex(); err != nil {
+		t.Fatalf("Failed to load index: %v", err)
+	}
+	entry, ok := recovered.GetFile("photos/beach.jpg")
+	if !ok || !entry.ModifiedAt.Equal(modTime) {
+		t.Errorf("Expected modification time %v, got %+v", modTime, entry)
+	}
+}
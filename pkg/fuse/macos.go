@@ -0,0 +1,52 @@
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// spotlightMarker is the file whose presence at the root of a volume keeps
+// Spotlight from indexing it
+const spotlightMarker = ".metadata_never_index"
+
+// macFUSEOptions returns the macFUSE -o options for the macOS features in
+// opts: the Finder volume name and icon, and "local", which lists the
+// volume under Locations in the Finder sidebar
+func macFUSEOptions(opts MountOptions) ([]string, error) {
+	var options []string
+
+	if opts.VolumeName != "" {
+		// Options are joined with commas, which macFUSE can't escape
+		if strings.Contains(opts.VolumeName, ",") {
+			return nil, fmt.Errorf("volume name %q must not contain a comma", opts.VolumeName)
+		}
+		options = append(options, "volname="+opts.VolumeName)
+	}
+
+	if opts.VolumeIcon != "" {
+		icon, err := filepath.Abs(opts.VolumeIcon)
+		if err != nil {
+			return nil, fmt.Errorf("invalid volume icon path: %w", err)
+		}
+		if !strings.EqualFold(filepath.Ext(icon), ".icns") {
+			return nil, fmt.Errorf("volume icon %s must be an .icns file", icon)
+		}
+		if strings.Contains(icon, ",") {
+			return nil, fmt.Errorf("volume icon path %s must not contain a comma", icon)
+		}
+		if info, err := os.Stat(icon); err != nil {
+			return nil, fmt.Errorf("volume icon: %w", err)
+		} else if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("volume icon %s is not a file", icon)
+		}
+		options = append(options, "volicon="+icon)
+	}
+
+	if opts.ShowInSidebar {
+		options = append(options, "local")
+	}
+
+	return options, nil
+}
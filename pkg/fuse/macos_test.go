@@ -0,0 +1,45 @@
+package fuse
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMacFUSEOptions(t *testing.T) {
+	options, err := macFUSEOptions(MountOptions{VolumeName: "NoiseFS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(options, ",") != "volname=NoiseFS" {
+		t.Errorf("Expected only the volume name, got %v", options)
+	}
+
+	icon := filepath.Join(t.TempDir(), "noisefs.icns")
+	if err := os.WriteFile(icon, []byte("icns"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options, err = macFUSEOptions(MountOptions{VolumeName: "NoiseFS", VolumeIcon: icon, ShowInSidebar: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(options, ",") != "volname=NoiseFS,volicon="+icon+",local" {
+		t.Errorf("Unexpected options %v", options)
+	}
+
+	iconDir := filepath.Join(t.TempDir(), "folder.icns")
+	if err := os.Mkdir(iconDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []MountOptions{
+		{VolumeName: "Noise,FS"},
+		{VolumeIcon: filepath.Join(t.TempDir(), "missing.icns")},
+		{VolumeIcon: iconDir},
+		{VolumeIcon: strings.TrimSuffix(icon, ".icns") + ".png"},
+	} {
+		if _, err := macFUSEOptions(opts); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package fuse
+
+// mlock is not supported on this platform; memory may be swapped
+func mlock(data []byte) error {
+	return nil
+}
+
+// munlock undoes mlock
+func munlock(data []byte) {}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package fuse
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// mlock keeps data out of swap
+func mlock(data []byte) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MLOCK, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// munlock undoes mlock
+func munlock(data []byte) {
+	syscall.Syscall(syscall.SYS_MUNLOCK, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0)
+}
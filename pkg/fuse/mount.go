@@ -1,170 +1,44 @@
-// +build fuse
+//go:build fuse && !windows
 
 package fuse
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"runtime"
 	"strings"
-	"sync"
 	"syscall"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/client"
-	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
-	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/security"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
 	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
 )
 
-// MountOptions contains options for mounting the filesystem
-type MountOptions struct {
-	MountPath      string
-	VolumeName     string
-	ReadOnly       bool
-	AllowOther     bool
-	Debug          bool
-	Security       *security.SecurityManager
-	IndexPassword  string
-	
-	// Directory mounting options
-	DirectoryDescriptor string // Directory descriptor CID to mount
-	DirectoryKey       string // Encryption key for directory
-	Subdir             string // Subdirectory to mount
-	MultiDirs          []DirectoryMount // Multiple directories to mount
-	
-	// Configuration override
-	Config             *FuseConfig // Optional configuration override
-}
-
-// DirectoryMount represents a directory to mount
-type DirectoryMount struct {
-	Name          string // Mount name/path
-	DescriptorCID string // Directory descriptor CID
-	EncryptionKey string // Encryption key
-}
-
-// MountInfo contains information about mounted filesystems
-type MountInfo struct {
-	MountPath  string
-	VolumeName string
-	ReadOnly   bool
-	PID        int
-}
-
-// Mount mounts the NoiseFS FUSE filesystem using go-fuse
-func Mount(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions) error {
-	return MountWithIndex(client, storageManager, opts, "")
-}
-
 // MountWithIndex mounts the NoiseFS FUSE filesystem with a custom index path
 func MountWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, indexPath string) error {
-	// Load configuration
-	config := opts.Config
-	if config == nil {
-		var err error
-		config, err = LoadConfig()
-		if err != nil {
-			return fmt.Errorf("failed to load configuration: %w", err)
-		}
+	state, cleanup, err := newFSState(client, storageManager, &opts, indexPath)
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 	
 	// Ensure mount point exists using configured permissions
-	if err := os.MkdirAll(opts.MountPath, config.Security.MountDirMode); err != nil {
+	if err := os.MkdirAll(opts.MountPath, state.config.Security.MountDirMode); err != nil {
 		return fmt.Errorf("failed to create mount point: %w", err)
 	}
-
-	// Determine index path
-	if indexPath == "" {
-		var err error
-		indexPath, err = GetDefaultIndexPath()
-		if err != nil {
-			return fmt.Errorf("failed to get default index path: %w", err)
-		}
-	}
-
-	// Create and load file index (encrypted if password provided)
-	var index *FileIndex
-	if opts.IndexPassword != "" {
-		encIndex, err := NewEncryptedFileIndex(indexPath, opts.IndexPassword)
-		if err != nil {
-			return fmt.Errorf("failed to create encrypted index: %w", err)
-		}
-		defer encIndex.Cleanup()
-		
-		if err := encIndex.LoadIndex(); err != nil {
-			return fmt.Errorf("failed to load encrypted index: %w", err)
-		}
-		
-		// Lock memory if security manager is available
-		if opts.Security != nil && opts.Security.MemoryProtection != nil {
-			encIndex.LockMemory()
-		}
-		
-		index = encIndex.FileIndex
-	} else {
-		// Use standard unencrypted index
-		index = NewFileIndex(indexPath)
-		if err := index.LoadIndex(); err != nil {
-			return fmt.Errorf("failed to load file index: %w", err)
-		}
-	}
-
-	// Create directory cache using configuration
-	dirCache, err := NewDirectoryCacheFromFuseConfig(config, storageManager)
-	if err != nil {
-		return fmt.Errorf("failed to create directory cache: %w", err)
-	}
 	
 	// Create NoiseFS filesystem
 	nfs := &NoiseFS{
-		FileSystem:     pathfs.NewDefaultFileSystem(),
-		client:         client,
-		storageManager: storageManager,
-		mountPath:      opts.MountPath,
-		readOnly:       opts.ReadOnly,
-		index:          index,
-		dirCache:       dirCache,
-		config:         config,
-		encryptionKeys: make(map[string]*crypto.EncryptionKey),
-	}
-	
-	// Handle directory mounting
-	if opts.DirectoryDescriptor != "" {
-		// Add single directory to mount
-		if err := nfs.mountDirectory("", opts.DirectoryDescriptor, opts.DirectoryKey, opts.Subdir); err != nil {
-			return fmt.Errorf("failed to mount directory: %w", err)
-		}
-	}
-	
-	// Handle multiple directory mounts
-	for _, dir := range opts.MultiDirs {
-		if err := nfs.mountDirectory(dir.Name, dir.DescriptorCID, dir.EncryptionKey, ""); err != nil {
-			return fmt.Errorf("failed to mount directory %s: %w", dir.Name, err)
-		}
+		FileSystem: pathfs.NewDefaultFileSystem(),
+		fsState:    state,
 	}
 
-	// Create path filesystem
-	pathFs := pathfs.NewPathNodeFs(nfs, nil)
+	// Create path filesystem, counting operations for metrics
+	pathFs := pathfs.NewPathNodeFs(&countingFS{FileSystem: nfs}, nil)
 
-	// Apply configuration overrides to mount options
-	if opts.VolumeName == "" && config.Mount.DefaultVolumeName != "" {
-		opts.VolumeName = config.Mount.DefaultVolumeName
-	}
-	if !opts.AllowOther && config.Mount.AllowOther {
-		opts.AllowOther = config.Mount.AllowOther
-	}
-	if !opts.Debug && config.Mount.Debug {
-		opts.Debug = config.Mount.Debug
-	}
-	if !opts.ReadOnly && config.Mount.ReadOnly {
-		opts.ReadOnly = config.Mount.ReadOnly
-	}
-	
 	// Create FUSE mount options
 	fuseOpts := &fuse.MountOptions{
 		Name:       "noisefs",
@@ -173,6 +47,16 @@ func MountWithIndex(client *noisefs.Client, storageManager *storage.Manager, opt
 		Debug:      opts.Debug,
 	}
 	
+	// Finder integration and Spotlight exclusion on macOS
+	if runtime.GOOS == "darwin" {
+		macOptions, err := macFUSEOptions(opts)
+		if err != nil {
+			return err
+		}
+		fuseOpts.Options = append(fuseOpts.Options, macOptions...)
+		nfs.hideFromSpotlight = !opts.SpotlightIndexing
+	}
+	
 	// Create raw filesystem
 	conn := nodefs.NewFileSystemConnector(pathFs.Root(), &nodefs.Options{
 		Debug: opts.Debug,
@@ -228,72 +112,10 @@ func Unmount(mountPath string) error {
 // NoiseFS implements pathfs.FileSystem
 type NoiseFS struct {
 	pathfs.FileSystem
-	client         *noisefs.Client
-	storageManager *storage.Manager
-	mountPath      string
-	readOnly       bool
-	
-	// Persistent file index
-	index *FileIndex
+	*fsState
 	
-	// Directory manifest cache
-	dirCache *DirectoryCache
-	
-	// Configuration
-	config *FuseConfig
-	
-	// Encryption keys for directories
-	encryptionKeys map[string]*crypto.EncryptionKey
-	keyMutex       sync.RWMutex
-}
-
-// mountDirectory adds a directory descriptor to the filesystem
-func (fs *NoiseFS) mountDirectory(name, descriptorCID, encryptionKey, subdir string) error {
-	// Validate descriptor CID
-	if descriptorCID == "" {
-		return fmt.Errorf("directory descriptor CID is required")
-	}
-	
-	// Parse encryption key if provided
-	var key *crypto.EncryptionKey
-	if encryptionKey != "" {
-		// Decode base64 key
-		keyBytes, err := base64.StdEncoding.DecodeString(encryptionKey)
-		if err != nil {
-			return fmt.Errorf("failed to decode encryption key: %w", err)
-		}
-		if len(keyBytes) != 32 {
-			return fmt.Errorf("encryption key must be 32 bytes")
-		}
-		key = &crypto.EncryptionKey{
-			Key: keyBytes,
-		}
-	}
-	
-	// Store encryption key
-	fs.keyMutex.Lock()
-	fs.encryptionKeys[descriptorCID] = key
-	fs.keyMutex.Unlock()
-	
-	// Add directory to index
-	mountPath := name
-	if mountPath == "" {
-		mountPath = "mounted-dir"
-	}
-	
-	// If subdir is specified, we'll need to load the manifest and navigate to it
-	if subdir != "" {
-		// This will be handled in GetAttr/OpenDir when accessing the directory
-		mountPath = filepath.Join(mountPath, subdir)
-	}
-	
-	// Add directory entry to index
-	fs.index.AddDirectory(mountPath, descriptorCID, encryptionKey)
-	
-	// The directory will be loaded on first access
-	// Cache warming happens automatically when directories are accessed
-	
-	return nil
+	// Serve the Spotlight marker file at the root
+	hideFromSpotlight bool
 }
 
 // GetAttr implements pathfs.FileSystem
@@ -308,6 +130,10 @@ func (fs *NoiseFS) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse
 			Mode: fuse.S_IFDIR | dirMode,
 		}, fuse.OK
 	}
+	
+	if name == spotlightMarker && fs.hideFromSpotlight {
+		return &fuse.Attr{Mode: fuse.S_IFREG | 0444}, fuse.OK
+	}
 
 	// Check if it's a directory
 	filesSubdir := fs.config.Mount.FilesSubdirectory
@@ -373,9 +199,13 @@ func (fs *NoiseFS) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry,
 	
 	if name == "" {
 		// Root directory
-		return []fuse.DirEntry{
+		entries := []fuse.DirEntry{
 			{Name: filesSubdir, Mode: fuse.S_IFDIR},
-		}, fuse.OK
+		}
+		if fs.hideFromSpotlight {
+			entries = append(entries, fuse.DirEntry{Name: spotlightMarker, Mode: fuse.S_IFREG})
+		}
+		return entries, fuse.OK
 	}
 
 	if strings.HasPrefix(name, filesSubdir) {
@@ -442,6 +272,11 @@ func (fs *NoiseFS) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry,
 
 // Open implements pathfs.FileSystem
 func (fs *NoiseFS) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	// The Spotlight marker is empty; only its presence matters
+	if name == spotlightMarker && fs.hideFromSpotlight {
+		return nodefs.NewDataFile(nil), fuse.OK
+	}
+	
 	// Only handle files under the files directory
 	if !strings.HasPrefix(name, "files/") {
 		return nil, fuse.EINVAL
@@ -759,67 +594,11 @@ func (fs *NoiseFS) Link(oldName string, newName string, context *fuse.Context) f
 	return fuse.OK
 }
 
-// AddFile adds a file to the index and saves it
-func (fs *NoiseFS) AddFile(filename, descriptorCID string, fileSize int64) error {
-	fs.index.AddFile(filename, descriptorCID, fileSize)
-	return fs.index.SaveIndex()
-}
-
-// RemoveFile removes a file from the index and saves it
-func (fs *NoiseFS) RemoveFile(filename string) error {
-	if fs.index.RemoveFile(filename) {
-		return fs.index.SaveIndex()
-	}
-	return nil
-}
-
-// ListFiles returns all files in the index
-func (fs *NoiseFS) ListFiles() map[string]*IndexEntry {
-	return fs.index.ListFiles()
-}
-
-// GetIndex returns the file index for direct access
-func (fs *NoiseFS) GetIndex() *FileIndex {
-	return fs.index
-}
-
-// Daemon runs the FUSE filesystem as a background daemon
-func Daemon(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile string) error {
-	return DaemonWithIndex(client, storageManager, opts, pidFile, "")
-}
-
-// DaemonWithIndex runs the FUSE filesystem as a background daemon with a custom index
-func DaemonWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile, indexPath string) error {
-	if pidFile != "" {
-		if err := writePIDFile(pidFile); err != nil {
-			return fmt.Errorf("failed to write PID file: %w", err)
-		}
-		defer os.Remove(pidFile)
-	}
-	
-	return MountWithIndex(client, storageManager, opts, indexPath)
-}
-
-func writePIDFile(pidFile string) error {
-	file, err := os.Create(pidFile)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-	
-	_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
-	return err
-}
-
+// StopDaemon asks the daemon recorded in pidFile to unmount and exit
 func StopDaemon(pidFile string) error {
-	data, err := os.ReadFile(pidFile)
+	pid, err := readPIDFile(pidFile)
 	if err != nil {
-		return fmt.Errorf("failed to read PID file: %w", err)
-	}
-	
-	var pid int
-	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
-		return fmt.Errorf("invalid PID file format: %w", err)
+		return err
 	}
 	
 	process, err := os.FindProcess(pid)
@@ -0,0 +1,310 @@
+//go:build fuse
+
+package fuse
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/security"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// MountOptions contains options for mounting the filesystem
+type MountOptions struct {
+	MountPath     string // Mount point; on Windows also a drive letter such as "N:"
+	VolumeName    string
+	ReadOnly      bool
+	AllowOther    bool
+	Debug         bool
+	Security      *security.SecurityManager
+	IndexPassword string
+
+	// Directory mounting options
+	DirectoryDescriptor string           // Directory descriptor CID to mount
+	DirectoryKey        string           // Encryption key for directory
+	Subdir              string           // Subdirectory to mount
+	MultiDirs           []DirectoryMount // Multiple directories to mount
+
+	// macOS (macFUSE) options, ignored on other platforms
+	VolumeIcon        string // .icns file Finder shows for the volume
+	ShowInSidebar     bool   // List the volume under Locations in the Finder sidebar
+	SpotlightIndexing bool   // Let Spotlight index the volume; it is excluded by default
+
+	// Configuration override
+	Config *FuseConfig // Optional configuration override
+}
+
+// DirectoryMount represents a directory to mount
+type DirectoryMount struct {
+	Name          string // Mount name/path
+	DescriptorCID string // Directory descriptor CID
+	EncryptionKey string // Encryption key
+}
+
+// MountInfo contains information about mounted filesystems
+type MountInfo struct {
+	MountPath  string
+	VolumeName string
+	ReadOnly   bool
+	PID        int
+}
+
+// Mount mounts the NoiseFS FUSE filesystem
+func Mount(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions) error {
+	return MountWithIndex(client, storageManager, opts, "")
+}
+
+// fsState is the part of a mounted NoiseFS shared by the go-fuse and
+// WinFsp filesystems
+type fsState struct {
+	client         *noisefs.Client
+	storageManager *storage.Manager
+	mountPath      string
+	readOnly       bool
+
+	// Persistent file index
+	index *FileIndex
+
+	// Directory manifest cache
+	dirCache *DirectoryCache
+
+	// Configuration
+	config *FuseConfig
+
+	// Encryption keys for directories
+	encryptionKeys map[string]*crypto.EncryptionKey
+	keyMutex       sync.RWMutex
+}
+
+// newFSState loads the configuration and index for a mount and applies the
+// configuration to opts. The returned cleanup releases the index.
+func newFSState(client *noisefs.Client, storageManager *storage.Manager, opts *MountOptions, indexPath string) (*fsState, func(), error) {
+	// Load configuration
+	config := opts.Config
+	if config == nil {
+		var err error
+		config, err = LoadConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
+
+	// Apply configuration overrides to mount options
+	if opts.VolumeName == "" && config.Mount.DefaultVolumeName != "" {
+		opts.VolumeName = config.Mount.DefaultVolumeName
+	}
+	if !opts.AllowOther && config.Mount.AllowOther {
+		opts.AllowOther = config.Mount.AllowOther
+	}
+	if !opts.Debug && config.Mount.Debug {
+		opts.Debug = config.Mount.Debug
+	}
+	if !opts.ReadOnly && config.Mount.ReadOnly {
+		opts.ReadOnly = config.Mount.ReadOnly
+	}
+	if opts.VolumeIcon == "" {
+		opts.VolumeIcon = config.Mount.VolumeIcon
+	}
+	if !opts.ShowInSidebar && config.Mount.ShowInSidebar {
+		opts.ShowInSidebar = config.Mount.ShowInSidebar
+	}
+	if !opts.SpotlightIndexing && config.Mount.SpotlightIndexing {
+		opts.SpotlightIndexing = config.Mount.SpotlightIndexing
+	}
+
+	// Determine index path
+	if indexPath == "" {
+		var err error
+		indexPath, err = GetDefaultIndexPath()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get default index path: %w", err)
+		}
+	}
+
+	// Create and load file index (encrypted if password provided)
+	var index *FileIndex
+	cleanup := func() {}
+	if opts.IndexPassword != "" {
+		encIndex, err := NewEncryptedFileIndex(indexPath, opts.IndexPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create encrypted index: %w", err)
+		}
+
+		if err := encIndex.LoadIndex(); err != nil {
+			encIndex.Cleanup()
+			return nil, nil, fmt.Errorf("failed to load encrypted index: %w", err)
+		}
+
+		// Lock memory if security manager is available
+		if opts.Security != nil && opts.Security.MemoryProtection != nil {
+			encIndex.LockMemory()
+		}
+
+		index = encIndex.FileIndex
+		cleanup = encIndex.Cleanup
+	} else {
+		// Use standard unencrypted index
+		index = NewFileIndex(indexPath)
+		if err := index.LoadIndex(); err != nil {
+			return nil, nil, fmt.Errorf("failed to load file index: %w", err)
+		}
+	}
+
+	// Create directory cache using configuration
+	dirCache, err := NewDirectoryCacheFromFuseConfig(config, storageManager)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to create directory cache: %w", err)
+	}
+
+	fs := &fsState{
+		client:         client,
+		storageManager: storageManager,
+		mountPath:      opts.MountPath,
+		readOnly:       opts.ReadOnly,
+		index:          index,
+		dirCache:       dirCache,
+		config:         config,
+		encryptionKeys: make(map[string]*crypto.EncryptionKey),
+	}
+
+	// Handle directory mounting
+	if opts.DirectoryDescriptor != "" {
+		// Add single directory to mount
+		if err := fs.mountDirectory("", opts.DirectoryDescriptor, opts.DirectoryKey, opts.Subdir); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to mount directory: %w", err)
+		}
+	}
+
+	// Handle multiple directory mounts
+	for _, dir := range opts.MultiDirs {
+		if err := fs.mountDirectory(dir.Name, dir.DescriptorCID, dir.EncryptionKey, ""); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to mount directory %s: %w", dir.Name, err)
+		}
+	}
+
+	return fs, cleanup, nil
+}
+
+// mountDirectory adds a directory descriptor to the filesystem
+func (fs *fsState) mountDirectory(name, descriptorCID, encryptionKey, subdir string) error {
+	// Validate descriptor CID
+	if descriptorCID == "" {
+		return fmt.Errorf("directory descriptor CID is required")
+	}
+
+	// Parse encryption key if provided
+	var key *crypto.EncryptionKey
+	if encryptionKey != "" {
+		// Decode base64 key
+		keyBytes, err := base64.StdEncoding.DecodeString(encryptionKey)
+		if err != nil {
+			return fmt.Errorf("failed to decode encryption key: %w", err)
+		}
+		if len(keyBytes) != 32 {
+			return fmt.Errorf("encryption key must be 32 bytes")
+		}
+		key = &crypto.EncryptionKey{
+			Key: keyBytes,
+		}
+	}
+
+	// Store encryption key
+	fs.keyMutex.Lock()
+	fs.encryptionKeys[descriptorCID] = key
+	fs.keyMutex.Unlock()
+
+	// Add directory to index
+	mountPath := name
+	if mountPath == "" {
+		mountPath = "mounted-dir"
+	}
+
+	// If subdir is specified, we'll need to load the manifest and navigate to it
+	if subdir != "" {
+		// This will be handled in GetAttr/OpenDir when accessing the directory
+		mountPath = path.Join(mountPath, subdir)
+	}
+
+	// Add directory entry to index
+	fs.index.AddDirectory(mountPath, descriptorCID, encryptionKey)
+
+	// The directory will be loaded on first access
+	// Cache warming happens automatically when directories are accessed
+
+	return nil
+}
+
+// AddFile adds a file to the index and saves it
+func (fs *fsState) AddFile(filename, descriptorCID string, fileSize int64) error {
+	fs.index.AddFile(filename, descriptorCID, fileSize)
+	return fs.index.SaveIndex()
+}
+
+// RemoveFile removes a file from the index and saves it
+func (fs *fsState) RemoveFile(filename string) error {
+	if fs.index.RemoveFile(filename) {
+		return fs.index.SaveIndex()
+	}
+	return nil
+}
+
+// ListFiles returns all files in the index
+func (fs *fsState) ListFiles() map[string]*IndexEntry {
+	return fs.index.ListFiles()
+}
+
+// GetIndex returns the file index for direct access
+func (fs *fsState) GetIndex() *FileIndex {
+	return fs.index
+}
+
+// Daemon runs the FUSE filesystem as a background daemon
+func Daemon(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile string) error {
+	return DaemonWithIndex(client, storageManager, opts, pidFile, "")
+}
+
+// DaemonWithIndex runs the FUSE filesystem as a background daemon with a custom index
+func DaemonWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile, indexPath string) error {
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			return fmt.Errorf("failed to write PID file: %w", err)
+		}
+		defer os.Remove(pidFile)
+	}
+
+	return MountWithIndex(client, storageManager, opts, indexPath)
+}
+
+func writePIDFile(pidFile string) error {
+	file, err := os.Create(pidFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%d\n", os.Getpid())
+	return err
+}
+
+// readPIDFile returns the process ID recorded in pidFile
+func readPIDFile(pidFile string) (int, error) {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PID file: %w", err)
+	}
+
+	var pid int
+	if _, err := fmt.Sscanf(string(data), "%d", &pid); err != nil {
+		return 0, fmt.Errorf("invalid PID file format: %w", err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,720 @@
+//go:build fuse && windows
+
+package fuse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/winfsp/cgofuse/fuse"
+)
+
+// stopPollInterval is how often a mount checks for a stop request
+const stopPollInterval = time.Second
+
+// MountWithIndex mounts NoiseFS through WinFsp with a custom index path.
+// The mount point must not exist yet; a drive letter such as "N:" works too.
+func MountWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, indexPath string) error {
+	state, cleanup, err := newFSState(client, storageManager, &opts, indexPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// WinFsp creates the mount point directory itself and refuses one
+	// that exists, so only its parent is created here
+	if !isDriveLetter(opts.MountPath) {
+		if _, err := os.Stat(opts.MountPath); err == nil {
+			return fmt.Errorf("mount point %s already exists; WinFsp needs a path that doesn't exist or a drive letter", opts.MountPath)
+		}
+		if err := os.MkdirAll(filepath.Dir(opts.MountPath), state.config.Security.MountDirMode); err != nil {
+			return fmt.Errorf("failed to create mount point parent: %w", err)
+		}
+	}
+
+	nfs := &NoiseFS{
+		fsState: state,
+		handles: make(map[uint64]*fileContent),
+	}
+
+	// Count operations for metrics
+	host := fuse.NewFileSystemHost(&countingFS{NoiseFS: nfs})
+	host.SetCapCaseInsensitive(false)
+
+	mountOpts := []string{"-o", "uid=-1,gid=-1"}
+	if opts.VolumeName != "" {
+		mountOpts = append(mountOpts, "-o", "volname="+opts.VolumeName)
+	}
+	if opts.Debug {
+		mountOpts = append(mountOpts, "-d")
+	}
+
+	// Register the mount so Unmount, StopDaemon and ListMounts can find it
+	recordPath, stopPath, err := registerMount(MountInfo{
+		MountPath:  opts.MountPath,
+		VolumeName: opts.VolumeName,
+		ReadOnly:   opts.ReadOnly,
+		PID:        os.Getpid(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register mount: %w", err)
+	}
+	defer os.Remove(recordPath)
+	defer os.Remove(stopPath)
+
+	// Unmount when another process asks us to
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(stopPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := os.Stat(stopPath); err == nil {
+					host.Unmount()
+					return
+				}
+			}
+		}
+	}()
+
+	fmt.Printf("NoiseFS mounted at: %s\n", opts.MountPath)
+	fmt.Printf("Volume name: %s\n", opts.VolumeName)
+	fmt.Println("Press Ctrl+C to unmount")
+
+	// Mount blocks until the filesystem is unmounted, which WinFsp also
+	// does on Ctrl+C
+	if !host.Mount(opts.MountPath, mountOpts) {
+		return fmt.Errorf("mount failed: is WinFsp installed and %s free?", opts.MountPath)
+	}
+	fmt.Println("\nShutting down...")
+
+	// Save index after unmounting
+	if err := nfs.index.SaveIndex(); err != nil {
+		fmt.Printf("Warning: Failed to save index: %v\n", err)
+	}
+
+	return nil
+}
+
+// Unmount asks the NoiseFS process serving mountPath to unmount
+func Unmount(mountPath string) error {
+	mounts, err := ListMounts()
+	if err != nil {
+		return err
+	}
+	for _, mount := range mounts {
+		if strings.EqualFold(filepath.Clean(mount.MountPath), filepath.Clean(mountPath)) {
+			return requestStop(mount.PID)
+		}
+	}
+	return fmt.Errorf("no NoiseFS filesystem is mounted at %s", mountPath)
+}
+
+// StopDaemon asks the daemon recorded in pidFile to unmount and exit.
+// Windows has no SIGTERM, so the request goes through the mount registry.
+func StopDaemon(pidFile string) error {
+	pid, err := readPIDFile(pidFile)
+	if err != nil {
+		return err
+	}
+	if err := requestStop(pid); err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent stop request to PID %d\n", pid)
+	return nil
+}
+
+// ListMounts returns information about mounted NoiseFS filesystems. Records
+// left behind by processes that no longer run are removed.
+func ListMounts() ([]MountInfo, error) {
+	dir, err := mountRegistryDir()
+	if err != nil {
+		return nil, err
+	}
+	recordPaths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := []MountInfo{}
+	for _, recordPath := range recordPaths {
+		data, err := os.ReadFile(recordPath)
+		if err != nil {
+			continue
+		}
+		var info MountInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			continue
+		}
+		// On Windows FindProcess fails for processes that have exited
+		if process, err := os.FindProcess(info.PID); err != nil {
+			os.Remove(recordPath)
+			continue
+		} else {
+			process.Release()
+		}
+		mounts = append(mounts, info)
+	}
+	return mounts, nil
+}
+
+// mountRegistryDir returns ~/.noisefs/mounts, where running mounts record
+// themselves
+func mountRegistryDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "mounts"), nil
+}
+
+// registerMount records a running mount and returns the paths of its
+// record and of the file that asks it to stop
+func registerMount(info MountInfo) (string, string, error) {
+	dir, err := mountRegistryDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	recordPath := filepath.Join(dir, fmt.Sprintf("%d.json", info.PID))
+	stopPath := filepath.Join(dir, fmt.Sprintf("%d.stop", info.PID))
+	os.Remove(stopPath) // A stale request for an earlier process with this PID
+	if err := os.WriteFile(recordPath, data, 0600); err != nil {
+		return "", "", err
+	}
+	return recordPath, stopPath, nil
+}
+
+// requestStop asks the mount run by pid to unmount
+func requestStop(pid int) error {
+	dir, err := mountRegistryDir()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%d.json", pid))); err != nil {
+		return fmt.Errorf("process %d is not serving a NoiseFS mount", pid)
+	}
+	if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.stop", pid)), nil, 0600); err != nil {
+		return fmt.Errorf("failed to request unmount: %w", err)
+	}
+	return nil
+}
+
+// isDriveLetter reports whether mountPath is a bare drive such as "N:"
+func isDriveLetter(mountPath string) bool {
+	return len(mountPath) == 2 && mountPath[1] == ':' &&
+		(mountPath[0] >= 'A' && mountPath[0] <= 'Z' || mountPath[0] >= 'a' && mountPath[0] <= 'z')
+}
+
+// NoiseFS implements the WinFsp file system interface
+type NoiseFS struct {
+	fuse.FileSystemBase
+	*fsState
+
+	// Open files by handle
+	handleMu   sync.Mutex
+	handles    map[uint64]*fileContent
+	nextHandle uint64
+}
+
+// relativePath turns a WinFsp path such as "/files/a.txt" into the path
+// below the files directory, reporting false for paths outside it
+func (fs *NoiseFS) relativePath(p string) (string, bool) {
+	filesSubdir := "/" + fs.config.Mount.FilesSubdirectory + "/"
+	if !strings.HasPrefix(p, filesSubdir) {
+		return "", false
+	}
+	return strings.TrimPrefix(p, filesSubdir), true
+}
+
+// openHandle registers an open file and returns its handle
+func (fs *NoiseFS) openHandle(file *fileContent) uint64 {
+	fs.handleMu.Lock()
+	defer fs.handleMu.Unlock()
+	fs.nextHandle++
+	fs.handles[fs.nextHandle] = file
+	return fs.nextHandle
+}
+
+// handle returns the open file for fh
+func (fs *NoiseFS) handle(fh uint64) (*fileContent, bool) {
+	fs.handleMu.Lock()
+	defer fs.handleMu.Unlock()
+	file, ok := fs.handles[fh]
+	return file, ok
+}
+
+// newFile creates the content of an open file, empty if descriptorCID is
+func (fs *NoiseFS) newFile(descriptorCID, relativePath string, readOnly bool) *fileContent {
+	return &fileContent{
+		client:         fs.client,
+		storageManager: fs.storageManager,
+		descriptorCID:  descriptorCID,
+		path:           relativePath,
+		readOnly:       readOnly,
+		index:          fs.index,
+	}
+}
+
+// Getattr implements fuse.FileSystemInterface
+func (fs *NoiseFS) Getattr(p string, stat *fuse.Stat_t, fh uint64) int {
+	dirMode := uint32(fs.config.Security.DefaultDirMode)
+	fileMode := uint32(fs.config.Security.DefaultFileMode)
+	filesSubdir := "/" + fs.config.Mount.FilesSubdirectory
+
+	if p == "/" || p == filesSubdir {
+		stat.Mode = fuse.S_IFDIR | dirMode
+		return 0
+	}
+
+	// Open files may have unflushed writes the index doesn't know about
+	if file, ok := fs.handle(fh); ok {
+		size, modTime, err := file.stat()
+		if err != nil {
+			return -fuse.EIO
+		}
+		stat.Mode = fuse.S_IFREG | fileMode
+		stat.Size = size
+		setTimes(stat, modTime, modTime)
+		return 0
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.ENOENT
+	}
+
+	// First check if it's a registered directory with descriptor
+	if dirEntry, exists := fs.index.GetDirectory(relativePath); exists {
+		stat.Mode = fuse.S_IFDIR | dirMode
+		setTimes(stat, dirEntry.ModifiedAt, dirEntry.CreatedAt)
+		return 0
+	}
+
+	// Check if it's a known file
+	if entry, exists := fs.index.GetFile(relativePath); exists {
+		if entry.Type == DirectoryEntryType {
+			stat.Mode = fuse.S_IFDIR | dirMode
+		} else {
+			stat.Mode = fuse.S_IFREG | fileMode
+			stat.Size = entry.FileSize
+		}
+		setTimes(stat, entry.ModifiedAt, entry.CreatedAt)
+		return 0
+	}
+
+	// Check if it's a directory by looking for files in subdirectories
+	if fs.index.IsDirectory(relativePath) {
+		stat.Mode = fuse.S_IFDIR | dirMode
+		return 0
+	}
+
+	return -fuse.ENOENT
+}
+
+// setTimes sets the timestamps of stat
+func setTimes(stat *fuse.Stat_t, modified, created time.Time) {
+	stat.Mtim = fuse.NewTimespec(modified)
+	stat.Atim = stat.Mtim
+	stat.Ctim = fuse.NewTimespec(created)
+	stat.Birthtim = stat.Ctim
+}
+
+// Opendir implements fuse.FileSystemInterface
+func (fs *NoiseFS) Opendir(p string) (int, uint64) {
+	var stat fuse.Stat_t
+	if errc := fs.Getattr(p, &stat, ^uint64(0)); errc != 0 {
+		return errc, ^uint64(0)
+	}
+	if stat.Mode&fuse.S_IFMT != fuse.S_IFDIR {
+		return -fuse.ENOTDIR, ^uint64(0)
+	}
+	return 0, 0
+}
+
+// Releasedir implements fuse.FileSystemInterface
+func (fs *NoiseFS) Releasedir(p string, fh uint64) int {
+	return 0
+}
+
+// Readdir implements fuse.FileSystemInterface
+func (fs *NoiseFS) Readdir(p string, fill func(name string, stat *fuse.Stat_t, ofst int64) bool, ofst int64, fh uint64) int {
+	fill(".", nil, 0)
+	fill("..", nil, 0)
+
+	filesSubdir := fs.config.Mount.FilesSubdirectory
+	if p == "/" {
+		fill(filesSubdir, &fuse.Stat_t{Mode: fuse.S_IFDIR}, 0)
+		return 0
+	}
+
+	// Get relative directory path
+	var dirPath string
+	if p != "/"+filesSubdir {
+		var ok bool
+		if dirPath, ok = fs.relativePath(p); !ok {
+			return -fuse.ENOENT
+		}
+	}
+
+	// Find subdirectories by examining file paths
+	subdirs := make(map[string]bool)
+	for _, entry := range fs.index.ListFiles() {
+		relDir := entry.Directory
+		if dirPath != "" {
+			if !strings.HasPrefix(relDir, dirPath+"/") {
+				continue
+			}
+			relDir = strings.TrimPrefix(relDir, dirPath+"/")
+		}
+		if first := strings.Split(relDir, "/")[0]; first != "" {
+			subdirs[first] = true
+		}
+	}
+
+	for subdir := range subdirs {
+		if !fill(subdir, &fuse.Stat_t{Mode: fuse.S_IFDIR}, 0) {
+			return 0
+		}
+	}
+	for _, entry := range fs.index.GetFilesInDirectory(dirPath) {
+		mode := uint32(fuse.S_IFREG)
+		if entry.Type == DirectoryEntryType {
+			mode = fuse.S_IFDIR
+		}
+		if !fill(entry.Filename, &fuse.Stat_t{Mode: mode, Size: entry.FileSize}, 0) {
+			return 0
+		}
+	}
+	return 0
+}
+
+// Open implements fuse.FileSystemInterface
+func (fs *NoiseFS) Open(p string, flags int) (int, uint64) {
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL, ^uint64(0)
+	}
+
+	// Look up descriptor CID
+	entry, exists := fs.index.GetFile(relativePath)
+	if !exists {
+		return -fuse.ENOENT, ^uint64(0)
+	}
+
+	readOnly := flags&fuse.O_ACCMODE == fuse.O_RDONLY
+	if !readOnly && fs.readOnly {
+		return -fuse.EROFS, ^uint64(0)
+	}
+	return 0, fs.openHandle(fs.newFile(entry.DescriptorCID, relativePath, readOnly))
+}
+
+// Create implements fuse.FileSystemInterface
+func (fs *NoiseFS) Create(p string, flags int, mode uint32) (int, uint64) {
+	if fs.readOnly {
+		return -fuse.EROFS, ^uint64(0)
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL, ^uint64(0)
+	}
+
+	// New file with an empty descriptor CID
+	return 0, fs.openHandle(fs.newFile("", relativePath, false))
+}
+
+// Read implements fuse.FileSystemInterface
+func (fs *NoiseFS) Read(p string, buff []byte, ofst int64, fh uint64) int {
+	file, ok := fs.handle(fh)
+	if !ok {
+		return -fuse.EBADF
+	}
+	data, err := file.readAt(len(buff), ofst)
+	if err != nil {
+		return -fuse.EIO
+	}
+	return copy(buff, data)
+}
+
+// Write implements fuse.FileSystemInterface
+func (fs *NoiseFS) Write(p string, buff []byte, ofst int64, fh uint64) int {
+	file, ok := fs.handle(fh)
+	if !ok {
+		return -fuse.EBADF
+	}
+	n, err := file.writeAt(buff, ofst)
+	if errors.Is(err, errReadOnly) {
+		return -fuse.EROFS
+	}
+	if err != nil {
+		return -fuse.EIO
+	}
+	return n
+}
+
+// Truncate implements fuse.FileSystemInterface. WinFsp calls it when a
+// file is overwritten, so it also works on files that aren't open.
+func (fs *NoiseFS) Truncate(p string, size int64, fh uint64) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+
+	if file, ok := fs.handle(fh); ok {
+		if err := file.truncate(size); err != nil {
+			return -fuse.EIO
+		}
+		return 0
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL
+	}
+	entry, exists := fs.index.GetFile(relativePath)
+	if !exists {
+		return -fuse.ENOENT
+	}
+	file := fs.newFile(entry.DescriptorCID, relativePath, false)
+	if err := file.truncate(size); err != nil {
+		return -fuse.EIO
+	}
+	if err := file.release(); err != nil {
+		return -fuse.EIO
+	}
+	return 0
+}
+
+// Flush implements fuse.FileSystemInterface
+func (fs *NoiseFS) Flush(p string, fh uint64) int {
+	file, ok := fs.handle(fh)
+	if !ok {
+		return -fuse.EBADF
+	}
+	if err := file.flush(); err != nil {
+		return -fuse.EIO
+	}
+	return 0
+}
+
+// Fsync implements fuse.FileSystemInterface
+func (fs *NoiseFS) Fsync(p string, datasync bool, fh uint64) int {
+	return fs.Flush(p, fh)
+}
+
+// Release implements fuse.FileSystemInterface
+func (fs *NoiseFS) Release(p string, fh uint64) int {
+	fs.handleMu.Lock()
+	file, ok := fs.handles[fh]
+	delete(fs.handles, fh)
+	fs.handleMu.Unlock()
+
+	if !ok {
+		return -fuse.EBADF
+	}
+	if err := file.release(); err != nil {
+		return -fuse.EIO
+	}
+	return 0
+}
+
+// Mkdir implements fuse.FileSystemInterface
+func (fs *NoiseFS) Mkdir(p string, mode uint32) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL
+	}
+	if fs.index.IsDirectory(relativePath) {
+		return -fuse.EEXIST
+	}
+
+	// Directories are created implicitly when files are added to them
+	return 0
+}
+
+// Unlink implements fuse.FileSystemInterface
+func (fs *NoiseFS) Unlink(p string) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL
+	}
+	if !fs.index.RemoveFile(relativePath) {
+		return -fuse.ENOENT
+	}
+	if err := fs.index.SaveIndex(); err != nil {
+		return -fuse.EIO
+	}
+	return 0
+}
+
+// Rmdir implements fuse.FileSystemInterface
+func (fs *NoiseFS) Rmdir(p string) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.EINVAL
+	}
+	if !fs.index.IsDirectory(relativePath) {
+		return -fuse.ENOENT
+	}
+	if len(fs.index.GetFilesInDirectory(relativePath)) > 0 {
+		return -fuse.ENOTEMPTY
+	}
+	for _, entry := range fs.index.ListFiles() {
+		if strings.HasPrefix(entry.Directory, relativePath+"/") {
+			return -fuse.ENOTEMPTY
+		}
+	}
+
+	// Removal is implicit since empty directories aren't stored
+	return 0
+}
+
+// Rename implements fuse.FileSystemInterface
+func (fs *NoiseFS) Rename(oldpath string, newpath string) int {
+	return fs.relink(oldpath, newpath, true)
+}
+
+// Link implements fuse.FileSystemInterface by adding another index entry
+// for the same descriptor
+func (fs *NoiseFS) Link(oldpath string, newpath string) int {
+	return fs.relink(oldpath, newpath, false)
+}
+
+// relink adds an index entry at newpath for the file at oldpath, removing
+// the old entry if move is set
+func (fs *NoiseFS) relink(oldpath, newpath string, move bool) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+
+	oldPath, okOld := fs.relativePath(oldpath)
+	newPath, okNew := fs.relativePath(newpath)
+	if !okOld || !okNew {
+		return -fuse.EINVAL
+	}
+
+	entry, exists := fs.index.GetFile(oldPath)
+	if !exists {
+		return -fuse.ENOENT
+	}
+	if _, exists := fs.index.GetFile(newPath); exists {
+		return -fuse.EEXIST
+	}
+
+	if move {
+		fs.index.RemoveFile(oldPath)
+	}
+	fs.index.AddFile(newPath, entry.DescriptorCID, entry.FileSize)
+	if err := fs.index.SaveIndex(); err != nil {
+		return -fuse.EIO
+	}
+	return 0
+}
+
+// noisefsXattrs are the read-only extended attributes of every file
+var noisefsXattrs = []string{
+	"user.noisefs.descriptor_cid",
+	"user.noisefs.created_at",
+	"user.noisefs.modified_at",
+	"user.noisefs.file_size",
+	"user.noisefs.directory",
+}
+
+// Getxattr implements fuse.FileSystemInterface
+func (fs *NoiseFS) Getxattr(p string, name string) (int, []byte) {
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return -fuse.ENOATTR, nil
+	}
+	entry, exists := fs.index.GetFile(relativePath)
+	if !exists {
+		return -fuse.ENOENT, nil
+	}
+
+	switch name {
+	case "user.noisefs.descriptor_cid":
+		return 0, []byte(entry.DescriptorCID)
+	case "user.noisefs.created_at":
+		return 0, []byte(entry.CreatedAt.Format(time.RFC3339))
+	case "user.noisefs.modified_at":
+		return 0, []byte(entry.ModifiedAt.Format(time.RFC3339))
+	case "user.noisefs.file_size":
+		return 0, []byte(fmt.Sprintf("%d", entry.FileSize))
+	case "user.noisefs.directory":
+		return 0, []byte(entry.Directory)
+	default:
+		return -fuse.ENOATTR, nil
+	}
+}
+
+// Listxattr implements fuse.FileSystemInterface
+func (fs *NoiseFS) Listxattr(p string, fill func(name string) bool) int {
+	relativePath, ok := fs.relativePath(p)
+	if !ok {
+		return 0
+	}
+	if _, exists := fs.index.GetFile(relativePath); !exists {
+		return -fuse.ENOENT
+	}
+	for _, name := range noisefsXattrs {
+		if !fill(name) {
+			return -fuse.ERANGE
+		}
+	}
+	return 0
+}
+
+// Setxattr implements fuse.FileSystemInterface; NoiseFS metadata is
+// read-only and other attributes aren't supported
+func (fs *NoiseFS) Setxattr(p string, name string, value []byte, flags int) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+	if strings.HasPrefix(name, "user.noisefs.") {
+		return -fuse.EPERM
+	}
+	return -fuse.ENOTSUP
+}
+
+// Removexattr implements fuse.FileSystemInterface
+func (fs *NoiseFS) Removexattr(p string, name string) int {
+	if fs.readOnly {
+		return -fuse.EROFS
+	}
+	if strings.HasPrefix(name, "user.noisefs.") {
+		return -fuse.EPERM
+	}
+	return -fuse.ENOTSUP
+}
@@ -1,15 +1,10 @@
-// +build fuse
+//go:build fuse && !windows
 
 package fuse
 
 import (
-	"bytes"
-	"fmt"
-	"sync"
-	"time"
+	"errors"
 
-	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
-	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -19,28 +14,7 @@ import (
 // NoiseFile implements nodefs.File for NoiseFS files
 type NoiseFile struct {
 	nodefs.File
-	
-	// NoiseFS components
-	client         *noisefs.Client
-	storageManager *storage.Manager
-	descriptorCID  string
-	descriptor     *descriptors.Descriptor
-	
-	// File metadata
-	path     string
-	readOnly bool
-	
-	// Content caching
-	mu      sync.RWMutex
-	content []byte
-	loaded  bool
-	
-	// Write support
-	writeBuffer []byte
-	dirty       bool
-	
-	// Index management
-	index *FileIndex
+	*fileContent
 	
 	// File locking
 	lockType int32
@@ -50,364 +24,70 @@ type NoiseFile struct {
 // NewNoiseFile creates a new NoiseFS file handle
 func NewNoiseFile(client *noisefs.Client, storageManager *storage.Manager, descriptorCID string, path string, readOnly bool, index *FileIndex) *NoiseFile {
 	return &NoiseFile{
-		File:           nodefs.NewDefaultFile(),
-		client:         client,
-		storageManager: storageManager,
-		descriptorCID:  descriptorCID,
-		path:           path,
-		readOnly:       readOnly,
-		index:          index,
-	}
-}
-
-// loadDescriptor loads the file descriptor from storage
-func (f *NoiseFile) loadDescriptor() error {
-	if f.descriptor != nil {
-		return nil
-	}
-	
-	store, err := descriptors.NewStore(f.storageManager)
-	if err != nil {
-		return fmt.Errorf("failed to create descriptor store: %w", err)
-	}
-	
-	descriptor, err := store.Load(f.descriptorCID)
-	if err != nil {
-		return fmt.Errorf("failed to load descriptor: %w", err)
-	}
-	
-	f.descriptor = descriptor
-	return nil
-}
-
-// downloadContent downloads and decrypts the file content
-func (f *NoiseFile) downloadContent() ([]byte, error) {
-	if err := f.loadDescriptor(); err != nil {
-		return nil, err
-	}
-	
-	// Retrieve all blocks
-	dataBlocks := make([]*blocks.Block, len(f.descriptor.Blocks))
-	randomizer1Blocks := make([]*blocks.Block, len(f.descriptor.Blocks))
-	randomizer2Blocks := make([]*blocks.Block, len(f.descriptor.Blocks))
-	
-	for i, blockPair := range f.descriptor.Blocks {
-		// Get data block
-		dataBlock, err := f.storageManager.RetrieveBlock(blockPair.DataCID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve data block %d: %w", i, err)
-		}
-		dataBlocks[i] = dataBlock
-		
-		// Get first randomizer block
-		randomizer1Block, err := f.storageManager.RetrieveBlock(blockPair.RandomizerCID1)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve randomizer1 block %d: %w", i, err)
-		}
-		randomizer1Blocks[i] = randomizer1Block
-		
-		// Get second randomizer block (3-tuple format)
-		randomizer2Block, err := f.storageManager.RetrieveBlock(blockPair.RandomizerCID2)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve randomizer2 block %d: %w", i, err)
-		}
-		randomizer2Blocks[i] = randomizer2Block
-	}
-	
-	// XOR to reconstruct original blocks
-	originalBlocks := make([]*blocks.Block, len(dataBlocks))
-	for i := range dataBlocks {
-		// Use 3-tuple XOR
-		originalBlock, err := dataBlocks[i].XOR(randomizer1Blocks[i], randomizer2Blocks[i])
-		if err != nil {
-			return nil, fmt.Errorf("failed to XOR blocks: %w", err)
-		}
-		originalBlocks[i] = originalBlock
-	}
-	
-	// Assemble file
-	assembler := blocks.NewAssembler()
-	data, err := assembler.Assemble(originalBlocks)
-	if err != nil {
-		return nil, fmt.Errorf("failed to assemble file: %w", err)
-	}
-	
-	// Record download
-	f.client.RecordDownload()
-	
-	return data, nil
-}
-
-// uploadFile uploads the write buffer to NoiseFS
-func (f *NoiseFile) uploadFile() error {
-	if f.writeBuffer == nil {
-		return fmt.Errorf("no write buffer to upload")
-	}
-	
-	// Create a reader from the write buffer
-	reader := bytes.NewReader(f.writeBuffer)
-	
-	// Create splitter with default block size
-	splitter, err := blocks.NewSplitter(blocks.DefaultBlockSize)
-	if err != nil {
-		return fmt.Errorf("failed to create splitter: %w", err)
-	}
-	
-	// Split file into blocks
-	fileBlocks, err := splitter.Split(reader)
-	if err != nil {
-		return fmt.Errorf("failed to split file: %w", err)
-	}
-	
-	// Create descriptor
-	descriptor := descriptors.NewDescriptor(
-		f.path,
-		int64(len(f.writeBuffer)),
-		int64(len(f.writeBuffer)),
-		blocks.DefaultBlockSize,
-	)
-	
-	// Generate or select randomizer blocks (using 3-tuple format)
-	randomizer1Blocks := make([]*blocks.Block, len(fileBlocks))
-	randomizer1CIDs := make([]string, len(fileBlocks))
-	randomizer2Blocks := make([]*blocks.Block, len(fileBlocks))
-	randomizer2CIDs := make([]string, len(fileBlocks))
-	
-	for i := range fileBlocks {
-		randBlock1, cid1, randBlock2, cid2, _, err := f.client.SelectRandomizers(fileBlocks[i].Size())
-		if err != nil {
-			return fmt.Errorf("failed to select randomizer blocks: %w", err)
-		}
-		randomizer1Blocks[i] = randBlock1
-		randomizer1CIDs[i] = cid1
-		randomizer2Blocks[i] = randBlock2
-		randomizer2CIDs[i] = cid2
-	}
-	
-	// XOR blocks with randomizers (3-tuple: data XOR randomizer1 XOR randomizer2)
-	anonymizedBlocks := make([]*blocks.Block, len(fileBlocks))
-	for i := range fileBlocks {
-		xorBlock, err := fileBlocks[i].XOR(randomizer1Blocks[i], randomizer2Blocks[i])
-		if err != nil {
-			return fmt.Errorf("failed to XOR blocks: %w", err)
-		}
-		anonymizedBlocks[i] = xorBlock
-	}
-	
-	// Store anonymized blocks in IPFS with caching
-	dataCIDs := make([]string, len(anonymizedBlocks))
-	for i, block := range anonymizedBlocks {
-		cid, err := f.client.StoreBlockWithCache(block)
-		if err != nil {
-			return fmt.Errorf("failed to store data block %d: %w", i, err)
-		}
-		dataCIDs[i] = cid
-	}
-	
-	// Add block triples to descriptor (3-tuple format)
-	for i := range dataCIDs {
-		if err := descriptor.AddBlockTriple(dataCIDs[i], randomizer1CIDs[i], randomizer2CIDs[i]); err != nil {
-			return fmt.Errorf("failed to add block triple to descriptor: %w", err)
-		}
-	}
-	
-	// Store descriptor in storage
-	store, err := descriptors.NewStore(f.storageManager)
-	if err != nil {
-		return fmt.Errorf("failed to create descriptor store: %w", err)
-	}
-	
-	descriptorCID, err := store.Save(descriptor)
-	if err != nil {
-		return fmt.Errorf("failed to store descriptor: %w", err)
-	}
-	
-	// Update descriptor CID and cache
-	f.descriptorCID = descriptorCID
-	f.descriptor = descriptor
-	f.content = make([]byte, len(f.writeBuffer))
-	copy(f.content, f.writeBuffer)
-	
-	// Record upload metrics
-	totalStoredBytes := int64(0)
-	for _, block := range anonymizedBlocks {
-		totalStoredBytes += int64(len(block.Data))
+		File: nodefs.NewDefaultFile(),
+		fileContent: &fileContent{
+			client:         client,
+			storageManager: storageManager,
+			descriptorCID:  descriptorCID,
+			path:           path,
+			readOnly:       readOnly,
+			index:          index,
+		},
 	}
-	f.client.RecordUpload(int64(len(f.writeBuffer)), totalStoredBytes*3)
-	
-	// Update index if available
-	if f.index != nil {
-		f.index.AddFile(f.path, descriptorCID, int64(len(f.writeBuffer)))
-		f.index.SaveIndex()
-	}
-	
-	return nil
 }
 
 // Read implements nodefs.File
 func (f *NoiseFile) Read(buf []byte, off int64) (fuse.ReadResult, fuse.Status) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	
-	// Load content if not already loaded
-	if !f.loaded {
-		if f.descriptorCID != "" {
-			content, err := f.downloadContent()
-			if err != nil {
-				return nil, fuse.EIO
-			}
-			f.content = content
-		} else {
-			f.content = make([]byte, 0)
-		}
-		f.loaded = true
-	}
-	
-	// Use write buffer if file has been modified
-	var readFrom []byte
-	if f.dirty && f.writeBuffer != nil {
-		readFrom = f.writeBuffer
-	} else {
-		readFrom = f.content
-	}
-	
-	// Handle offset beyond file size
-	if off >= int64(len(readFrom)) {
-		return fuse.ReadResultData([]byte{}), fuse.OK
-	}
-	
-	// Calculate read range
-	end := int(off) + len(buf)
-	if end > len(readFrom) {
-		end = len(readFrom)
+	data, err := f.readAt(len(buf), off)
+	if err != nil {
+		return nil, fuse.EIO
 	}
-	
-	// Return requested portion
-	return fuse.ReadResultData(readFrom[off:end]), fuse.OK
+	return fuse.ReadResultData(data), fuse.OK
 }
 
 // GetAttr implements nodefs.File
 func (f *NoiseFile) GetAttr(out *fuse.Attr) fuse.Status {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
-	
-	out.Mode = fuse.S_IFREG | 0644 // TODO: Use config.Security.DefaultFileMode
-	
-	// Use write buffer size if dirty, otherwise use descriptor size
-	if f.dirty && f.writeBuffer != nil {
-		out.Size = uint64(len(f.writeBuffer))
-	} else if f.descriptor != nil {
-		out.Size = uint64(f.descriptor.FileSize)
-	} else if f.descriptorCID != "" {
-		// Load descriptor to get file size
-		if err := f.loadDescriptor(); err != nil {
-			return fuse.EIO
-		}
-		out.Size = uint64(f.descriptor.FileSize)
-	} else {
-		// New file
-		out.Size = 0
-	}
-	
-	// Set timestamps
-	if f.descriptor != nil {
-		out.Mtime = uint64(f.descriptor.CreatedAt.Unix())
-		out.Atime = out.Mtime
-		out.Ctime = out.Mtime
-	} else {
-		// Use current time for new files
-		now := uint64(time.Now().Unix())
-		out.Mtime = now
-		out.Atime = now
-		out.Ctime = now
+	size, modTime, err := f.stat()
+	if err != nil {
+		return fuse.EIO
 	}
 	
+	out.Mode = fuse.S_IFREG | 0644 // TODO: Use config.Security.DefaultFileMode
+	out.Size = uint64(size)
+	out.Mtime = uint64(modTime.Unix())
+	out.Atime = out.Mtime
+	out.Ctime = out.Mtime
 	return fuse.OK
 }
 
 // Write implements nodefs.File
 func (f *NoiseFile) Write(data []byte, off int64) (written uint32, code fuse.Status) {
-	if f.readOnly {
+	n, err := f.writeAt(data, off)
+	if errors.Is(err, errReadOnly) {
 		return 0, fuse.EROFS
 	}
-	
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	
-	// Ensure we have content loaded or initialize empty buffer
-	if !f.loaded {
-		if f.descriptorCID != "" {
-			// Load existing file content first
-			if f.content == nil {
-				content, err := f.downloadContent()
-				if err != nil {
-					return 0, fuse.EIO
-				}
-				f.content = content
-			}
-			f.writeBuffer = make([]byte, len(f.content))
-			copy(f.writeBuffer, f.content)
-		} else {
-			// New file - initialize empty buffer
-			f.writeBuffer = make([]byte, 0)
-		}
-		f.loaded = true
-	}
-	
-	// Calculate required buffer size
-	requiredSize := int(off) + len(data)
-	if requiredSize > len(f.writeBuffer) {
-		// Expand buffer
-		newBuffer := make([]byte, requiredSize)
-		copy(newBuffer, f.writeBuffer)
-		f.writeBuffer = newBuffer
+	if err != nil {
+		return 0, fuse.EIO
 	}
-	
-	// Write data to buffer
-	copy(f.writeBuffer[off:], data)
-	f.dirty = true
-	
-	return uint32(len(data)), fuse.OK
+	return uint32(n), fuse.OK
 }
 
 // Flush implements nodefs.File
 func (f *NoiseFile) Flush() fuse.Status {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	
-	// If not dirty, nothing to flush
-	if !f.dirty || f.writeBuffer == nil {
-		return fuse.OK
-	}
-	
-	// Upload file to NoiseFS
-	if err := f.uploadFile(); err != nil {
+	if err := f.flush(); err != nil {
 		return fuse.EIO
 	}
-	
-	// Clear dirty flag
-	f.dirty = false
-	
 	return fuse.OK
 }
 
 // Release implements nodefs.File
 func (f *NoiseFile) Release() {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	f.release()
 	
-	// Auto-flush dirty files on close
-	if f.dirty && f.writeBuffer != nil {
-		f.uploadFile()
-		f.dirty = false
-	}
-	
-	// Clear cached content to free memory
-	f.content = nil
-	f.writeBuffer = nil
-	f.loaded = false
+	f.mu.Lock()
 	f.lockType = 0
 	f.lockOwner = 0
+	f.mu.Unlock()
 }
 
 // Flock implements nodefs.File for file locking
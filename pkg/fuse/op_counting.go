@@ -0,0 +1,145 @@
+//go:build fuse && !windows
+
+package fuse
+
+import (
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"github.com/hanwen/go-fuse/v2/fuse/nodefs"
+	"github.com/hanwen/go-fuse/v2/fuse/pathfs"
+)
+
+// countingFS counts the operations of a filesystem for OperationStats
+type countingFS struct {
+	pathfs.FileSystem
+}
+
+func (fs *countingFS) GetAttr(name string, context *fuse.Context) (*fuse.Attr, fuse.Status) {
+	attr, status := fs.FileSystem.GetAttr(name, context)
+	recordOp(OpGetAttr, !status.Ok())
+	return attr, status
+}
+
+func (fs *countingFS) OpenDir(name string, context *fuse.Context) ([]fuse.DirEntry, fuse.Status) {
+	entries, status := fs.FileSystem.OpenDir(name, context)
+	recordOp(OpReadDir, !status.Ok())
+	return entries, status
+}
+
+func (fs *countingFS) Open(name string, flags uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	file, status := fs.FileSystem.Open(name, flags, context)
+	recordOp(OpOpen, !status.Ok())
+	return countFile(file), status
+}
+
+func (fs *countingFS) Create(name string, flags uint32, mode uint32, context *fuse.Context) (nodefs.File, fuse.Status) {
+	file, status := fs.FileSystem.Create(name, flags, mode, context)
+	recordOp(OpCreate, !status.Ok())
+	return countFile(file), status
+}
+
+func (fs *countingFS) Truncate(name string, size uint64, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Truncate(name, size, context)
+	recordOp(OpTruncate, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) Mkdir(name string, mode uint32, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Mkdir(name, mode, context)
+	recordOp(OpMkdir, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) Unlink(name string, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Unlink(name, context)
+	recordOp(OpUnlink, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) Rmdir(name string, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Rmdir(name, context)
+	recordOp(OpRmdir, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) Rename(oldName string, newName string, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Rename(oldName, newName, context)
+	recordOp(OpRename, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) Link(oldName string, newName string, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.Link(oldName, newName, context)
+	recordOp(OpLink, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) GetXAttr(name string, attribute string, context *fuse.Context) ([]byte, fuse.Status) {
+	data, status := fs.FileSystem.GetXAttr(name, attribute, context)
+	// A missing attribute is the usual answer, not a failure
+	recordOp(OpGetXAttr, !status.Ok() && status != fuse.ENODATA && status != fuse.ENOATTR)
+	return data, status
+}
+
+func (fs *countingFS) ListXAttr(name string, context *fuse.Context) ([]string, fuse.Status) {
+	attributes, status := fs.FileSystem.ListXAttr(name, context)
+	recordOp(OpListXAttr, !status.Ok())
+	return attributes, status
+}
+
+func (fs *countingFS) SetXAttr(name string, attr string, data []byte, flags int, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.SetXAttr(name, attr, data, flags, context)
+	recordOp(OpSetXAttr, !status.Ok())
+	return status
+}
+
+func (fs *countingFS) RemoveXAttr(name string, attr string, context *fuse.Context) fuse.Status {
+	status := fs.FileSystem.RemoveXAttr(name, attr, context)
+	recordOp(OpRemoveXAttr, !status.Ok())
+	return status
+}
+
+// countingFile counts the operations on an open file
+type countingFile struct {
+	nodefs.File
+}
+
+func countFile(file nodefs.File) nodefs.File {
+	if file == nil {
+		return nil
+	}
+	return &countingFile{File: file}
+}
+
+func (f *countingFile) InnerFile() nodefs.File {
+	return f.File
+}
+
+func (f *countingFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status) {
+	result, status := f.File.Read(dest, off)
+	recordOp(OpRead, !status.Ok())
+	return result, status
+}
+
+func (f *countingFile) Write(data []byte, off int64) (uint32, fuse.Status) {
+	written, status := f.File.Write(data, off)
+	recordOp(OpWrite, !status.Ok())
+	return written, status
+}
+
+func (f *countingFile) Flush() fuse.Status {
+	status := f.File.Flush()
+	recordOp(OpFlush, !status.Ok())
+	return status
+}
+
+func (f *countingFile) Fsync(flags int) fuse.Status {
+	status := f.File.Fsync(flags)
+	recordOp(OpFsync, !status.Ok())
+	return status
+}
+
+func (f *countingFile) Truncate(size uint64) fuse.Status {
+	status := f.File.Truncate(size)
+	recordOp(OpTruncate, !status.Ok())
+	return status
+}
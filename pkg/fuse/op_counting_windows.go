@@ -0,0 +1,120 @@
+//go:build fuse && windows
+
+package fuse
+
+import "github.com/winfsp/cgofuse/fuse"
+
+// countingFS counts the operations of a filesystem for OperationStats.
+// Operations fail with a negative error code.
+type countingFS struct {
+	*NoiseFS
+}
+
+func (fs *countingFS) Getattr(p string, stat *fuse.Stat_t, fh uint64) int {
+	errc := fs.NoiseFS.Getattr(p, stat, fh)
+	recordOp(OpGetAttr, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Readdir(p string, fill func(name string, stat *fuse.Stat_t, ofst int64) bool, ofst int64, fh uint64) int {
+	errc := fs.NoiseFS.Readdir(p, fill, ofst, fh)
+	recordOp(OpReadDir, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Open(p string, flags int) (int, uint64) {
+	errc, fh := fs.NoiseFS.Open(p, flags)
+	recordOp(OpOpen, errc < 0)
+	return errc, fh
+}
+
+func (fs *countingFS) Create(p string, flags int, mode uint32) (int, uint64) {
+	errc, fh := fs.NoiseFS.Create(p, flags, mode)
+	recordOp(OpCreate, errc < 0)
+	return errc, fh
+}
+
+func (fs *countingFS) Read(p string, buff []byte, ofst int64, fh uint64) int {
+	n := fs.NoiseFS.Read(p, buff, ofst, fh)
+	recordOp(OpRead, n < 0)
+	return n
+}
+
+func (fs *countingFS) Write(p string, buff []byte, ofst int64, fh uint64) int {
+	n := fs.NoiseFS.Write(p, buff, ofst, fh)
+	recordOp(OpWrite, n < 0)
+	return n
+}
+
+func (fs *countingFS) Truncate(p string, size int64, fh uint64) int {
+	errc := fs.NoiseFS.Truncate(p, size, fh)
+	recordOp(OpTruncate, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Flush(p string, fh uint64) int {
+	errc := fs.NoiseFS.Flush(p, fh)
+	recordOp(OpFlush, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Fsync(p string, datasync bool, fh uint64) int {
+	errc := fs.NoiseFS.Fsync(p, datasync, fh)
+	recordOp(OpFsync, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Mkdir(p string, mode uint32) int {
+	errc := fs.NoiseFS.Mkdir(p, mode)
+	recordOp(OpMkdir, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Unlink(p string) int {
+	errc := fs.NoiseFS.Unlink(p)
+	recordOp(OpUnlink, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Rmdir(p string) int {
+	errc := fs.NoiseFS.Rmdir(p)
+	recordOp(OpRmdir, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Rename(oldpath string, newpath string) int {
+	errc := fs.NoiseFS.Rename(oldpath, newpath)
+	recordOp(OpRename, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Link(oldpath string, newpath string) int {
+	errc := fs.NoiseFS.Link(oldpath, newpath)
+	recordOp(OpLink, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Getxattr(p string, name string) (int, []byte) {
+	errc, data := fs.NoiseFS.Getxattr(p, name)
+	// A missing attribute is the usual answer, not a failure
+	recordOp(OpGetXAttr, errc < 0 && errc != -fuse.ENOATTR)
+	return errc, data
+}
+
+func (fs *countingFS) Listxattr(p string, fill func(name string) bool) int {
+	errc := fs.NoiseFS.Listxattr(p, fill)
+	recordOp(OpListXAttr, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Setxattr(p string, name string, value []byte, flags int) int {
+	errc := fs.NoiseFS.Setxattr(p, name, value, flags)
+	recordOp(OpSetXAttr, errc < 0)
+	return errc
+}
+
+func (fs *countingFS) Removexattr(p string, name string) int {
+	errc := fs.NoiseFS.Removexattr(p, name)
+	recordOp(OpRemoveXAttr, errc < 0)
+	return errc
+}
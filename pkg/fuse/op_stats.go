@@ -0,0 +1,66 @@
+package fuse
+
+import "sync"
+
+// FUSE operations counted by OperationStats, named after the system calls
+// that cause them so mounts on every platform report the same names
+const (
+	OpGetAttr     = "getattr"
+	OpReadDir     = "readdir"
+	OpOpen        = "open"
+	OpCreate      = "create"
+	OpRead        = "read"
+	OpWrite       = "write"
+	OpTruncate    = "truncate"
+	OpFlush       = "flush"
+	OpFsync       = "fsync"
+	OpMkdir       = "mkdir"
+	OpUnlink      = "unlink"
+	OpRmdir       = "rmdir"
+	OpRename      = "rename"
+	OpLink        = "link"
+	OpGetXAttr    = "getxattr"
+	OpListXAttr   = "listxattr"
+	OpSetXAttr    = "setxattr"
+	OpRemoveXAttr = "removexattr"
+)
+
+// OpStats counts the calls of one FUSE operation
+type OpStats struct {
+	Calls  int64 `json:"calls"`
+	Errors int64 `json:"errors"` // Calls answered with an error status, such as ENOENT
+}
+
+var (
+	opStatsMu sync.Mutex
+	opStats   = make(map[string]*OpStats)
+)
+
+// recordOp counts a call of op by the mounts of this process
+func recordOp(op string, failed bool) {
+	opStatsMu.Lock()
+	defer opStatsMu.Unlock()
+
+	stats, ok := opStats[op]
+	if !ok {
+		stats = &OpStats{}
+		opStats[op] = stats
+	}
+	stats.Calls++
+	if failed {
+		stats.Errors++
+	}
+}
+
+// OperationStats returns the FUSE operations the mounts of this process
+// have served, keyed by operation name
+func OperationStats() map[string]OpStats {
+	opStatsMu.Lock()
+	defer opStatsMu.Unlock()
+
+	stats := make(map[string]OpStats, len(opStats))
+	for op, s := range opStats {
+		stats[op] = *s
+	}
+	return stats
+}
@@ -0,0 +1,18 @@
+package fuse
+
+import "testing"
+
+func TestOperationStats(t *testing.T) {
+	before := OperationStats()[OpRename]
+
+	recordOp(OpRename, false)
+	recordOp(OpRename, true)
+
+	after := OperationStats()[OpRename]
+	if after.Calls-before.Calls != 2 {
+		t.Errorf("Calls grew by %d, want 2", after.Calls-before.Calls)
+	}
+	if after.Errors-before.Errors != 1 {
+		t.Errorf("Errors grew by %d, want 1", after.Errors-before.Errors)
+	}
+}
@@ -24,12 +24,18 @@ type MountOptions struct {
 	ReadOnly    bool
 	AllowOther  bool
 	Debug       bool
+	IndexPassword string
 	
 	// Directory mounting options (stub versions)
 	DirectoryDescriptor string // Directory descriptor CID to mount
 	DirectoryKey       string // Encryption key for directory
 	Subdir             string // Subdirectory to mount
 	MultiDirs          []DirectoryMount // Multiple directories to mount
+	
+	// macOS options (stub versions)
+	VolumeIcon        string
+	ShowInSidebar     bool
+	SpotlightIndexing bool
 }
 
 // MountInfo contains information about mounted filesystems
@@ -42,35 +48,35 @@ type MountInfo struct {
 
 // Mount is a stub implementation when FUSE is not available
 func Mount(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // Unmount is a stub implementation when FUSE is not available
 func Unmount(mountPath string) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // ListMounts is a stub implementation when FUSE is not available
 func ListMounts() ([]MountInfo, error) {
-	return nil, errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return nil, errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // Daemon is a stub implementation when FUSE is not available
 func Daemon(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile string) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // MountWithIndex is a stub implementation when FUSE is not available
 func MountWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, indexPath string) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // DaemonWithIndex is a stub implementation when FUSE is not available
 func DaemonWithIndex(client *noisefs.Client, storageManager *storage.Manager, opts MountOptions, pidFile, indexPath string) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
 
 // StopDaemon is a stub implementation when FUSE is not available
 func StopDaemon(pidFile string) error {
-	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure macFUSE/FUSE is installed")
+	return errors.New("FUSE support not available - build with 'go build -tags fuse' and ensure FUSE (macFUSE on macOS, WinFsp on Windows) is installed")
 }
\ No newline at end of file
@@ -0,0 +1,209 @@
+// Package remote serves the NoiseFS file index over a small, versioned
+// HTTP/JSON protocol for sync tools. It is what an rclone backend, or a
+// backend of a similar tool, talks to: it lists directories, stats,
+// reads, writes, copies, moves and deletes files, and keeps the
+// modification times clients set, so incremental backups only send what
+// changed.
+//
+// The protocol is described by Endpoints and Options, from which OpenAPI
+// and Markdown generate its specification. Version 1 only grows: new
+// endpoints and fields may be added, but existing ones keep their meaning.
+package remote
+
+import (
+	"net/http"
+	"time"
+)
+
+// ProtocolVersion is the version of the protocol served under /v1
+const ProtocolVersion = 1
+
+// Features the server reports in Info
+const (
+	FeatureRange         = "range"          // GET /v1/file honors Range
+	FeatureCopy          = "copy"           // Server-side copies
+	FeatureMove          = "move"           // Server-side moves
+	FeatureModTime       = "mod_time"       // Modification times can be set
+	FeatureRecursiveList = "recursive_list" // GET /v1/list?recursive=true
+	FeatureImplicitDirs  = "implicit_dirs"  // Directories exist while they hold files; there is no mkdir
+)
+
+// HashSHA256 is the hash type of Entry.SHA256
+const HashSHA256 = "sha256"
+
+// Response wraps every JSON response
+type Response struct {
+	Success bool        `json:"success" doc:"Whether the request succeeded"`
+	Data    interface{} `json:"data,omitempty" doc:"Result of a successful request"`
+	Error   string      `json:"error,omitempty" doc:"Why the request failed"`
+}
+
+// Info describes the server
+type Info struct {
+	Server   string   `json:"server" doc:"Always \"noisefs\""`
+	Protocol int      `json:"protocol" doc:"Protocol version"`
+	Hashes   []string `json:"hashes" doc:"Hash types entries may carry"`
+	Features []string `json:"features" doc:"Optional capabilities the server supports"`
+}
+
+// Entry is a file or directory
+type Entry struct {
+	Path     string    `json:"path" doc:"Slash-separated path from the root, without leading slash"`
+	Name     string    `json:"name" doc:"Last element of the path"`
+	IsDir    bool      `json:"is_dir" doc:"Whether the entry is a directory"`
+	Size     int64     `json:"size" doc:"Size in bytes; 0 for directories"`
+	ModTime  time.Time `json:"mod_time" doc:"Modification time (RFC 3339, nanosecond precision); for directories the latest of their contents"`
+	SHA256   string    `json:"sha256,omitempty" doc:"Hex SHA-256 of the content, when known"`
+	MimeType string    `json:"mime_type,omitempty" doc:"Content type"`
+}
+
+// ListResult is the result of GET /v1/list
+type ListResult struct {
+	Entries []Entry `json:"entries" doc:"Entries sorted by path"`
+}
+
+// TransferRequest is the body of POST /v1/copy and POST /v1/move
+type TransferRequest struct {
+	From string `json:"from" doc:"Path of the source file"`
+	To   string `json:"to" doc:"Path of the destination, replaced if it exists"`
+}
+
+// ModTimeRequest is the body of POST /v1/mod-time
+type ModTimeRequest struct {
+	Path    string    `json:"path" doc:"Path of the file"`
+	ModTime time.Time `json:"mod_time" doc:"New modification time (RFC 3339)"`
+}
+
+// Param is a query parameter of an endpoint
+type Param struct {
+	Name        string
+	Type        string // string, boolean or date-time
+	Required    bool
+	Description string
+}
+
+// Endpoint describes a request of the protocol
+type Endpoint struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Params      []Param
+	Body        interface{} // JSON request body, nil for none
+	RawBody     bool        // The request body is file content
+	Result      interface{} // Data of a successful response, nil for none
+	RawResult   bool        // A successful response is file content
+	Errors      []int       // Statuses of expected failures
+}
+
+// Option is a setting of a remote that points a client at a server, as
+// declared by rclone backends
+type Option struct {
+	Name      string
+	Help      string
+	Default   string
+	Required  bool
+	Sensitive bool // Hide the value, e.g. in rclone config show
+}
+
+// Options are the settings a client backend asks users for
+var Options = []Option{
+	{Name: "url", Help: "URL of the noisefs remote server", Default: "http://127.0.0.1:9001", Required: true},
+	{Name: "token", Help: "Bearer token, the remote.token of the server's configuration; empty for a server on this host without one", Sensitive: true},
+}
+
+var pathParam = Param{Name: "path", Type: "string", Required: true, Description: "Slash-separated path of the file"}
+
+// Endpoints are the requests of protocol version 1. Every request must
+// carry "Authorization: Bearer <token>" when the server has a token.
+var Endpoints = []Endpoint{
+	{
+		Method:  http.MethodGet,
+		Path:    "/v1/info",
+		Summary: "Describe the server",
+		Description: "Returns the protocol version, hash types and features. Clients should call it " +
+			"when connecting and refuse servers whose protocol is newer than they know.",
+		Result: Info{},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/v1/list",
+		Summary: "List a directory",
+		Description: "Returns the files and directories in a directory, or with recursive=true " +
+			"everything below it.",
+		Params: []Param{
+			{Name: "path", Type: "string", Description: "Directory to list; empty for the root"},
+			{Name: "recursive", Type: "boolean", Description: "List everything below the directory"},
+		},
+		Result: ListResult{},
+		Errors: []int{http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/v1/stat",
+		Summary:     "Describe a file or directory",
+		Description: "Returns the entry at a path.",
+		Params:      []Param{{Name: "path", Type: "string", Required: true, Description: "Path of the file or directory"}},
+		Result:      Entry{},
+		Errors:      []int{http.StatusNotFound},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/v1/file",
+		Summary: "Read a file",
+		Description: "Returns the content of a file. Range requests are answered with 206 Partial " +
+			"Content; Last-Modified and ETag (the quoted SHA-256) are set.",
+		Params:    []Param{pathParam},
+		RawResult: true,
+		Errors:    []int{http.StatusNotFound, http.StatusRequestedRangeNotSatisfiable},
+	},
+	{
+		Method:  http.MethodPut,
+		Path:    "/v1/file",
+		Summary: "Write a file",
+		Description: "Stores the request body as the file at path, replacing any file there, and " +
+			"returns its entry. Directories are created as needed.",
+		Params: []Param{
+			pathParam,
+			{Name: "mod_time", Type: "date-time", Description: "Modification time to record (RFC 3339); the upload time when omitted"},
+		},
+		RawBody: true,
+		Result:  Entry{},
+		Errors:  []int{http.StatusBadRequest},
+	},
+	{
+		Method:      http.MethodDelete,
+		Path:        "/v1/file",
+		Summary:     "Delete a file",
+		Description: "Removes a file. Directories disappear with their last file.",
+		Params:      []Param{pathParam},
+		Errors:      []int{http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/copy",
+		Summary:     "Copy a file",
+		Description: "Copies a file without transferring its content, keeping its modification time.",
+		Body:        TransferRequest{},
+		Result:      Entry{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/move",
+		Summary:     "Move a file",
+		Description: "Renames a file, keeping its modification time.",
+		Body:        TransferRequest{},
+		Result:      Entry{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/v1/mod-time",
+		Summary:     "Set a modification time",
+		Description: "Sets the modification time of a file.",
+		Body:        ModTimeRequest{},
+		Result:      Entry{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+	},
+}
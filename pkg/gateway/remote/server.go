@@ -0,0 +1,464 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+)
+
+// maxRequestBodySize bounds JSON request bodies
+const maxRequestBodySize = 1 << 20
+
+// Index is the file index the server serves; both fuse.FileIndex and
+// fuse.EncryptedFileIndex implement it
+type Index interface {
+	AddFileWithMetadata(path, descriptorCID string, fileSize int64, meta fuse.FileMetadata)
+	RemoveFile(path string) bool
+	GetFile(path string) (*fuse.IndexEntry, bool)
+	ListFiles() map[string]*fuse.IndexEntry
+	SetModTime(path string, modTime time.Time) bool
+	SaveIndex() error
+}
+
+// Server is an http.Handler serving the remote protocol
+type Server struct {
+	client *noisefs.Client
+	index  Index
+	token  string
+	mux    *http.ServeMux
+}
+
+var (
+	errNotFound = errors.New("not found")
+	errIsDir    = errors.New("path is a directory")
+)
+
+// statusError is an error answered with a specific status
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func badRequest(format string, args ...interface{}) error {
+	return &statusError{status: http.StatusBadRequest, err: fmt.Errorf(format, args...)}
+}
+
+// New returns a server storing files with client and recording them in
+// index. Requests must carry token as a bearer token unless it is empty.
+func New(client *noisefs.Client, index Index, token string) (*Server, error) {
+	if client == nil || index == nil {
+		return nil, errors.New("client and index are required")
+	}
+	s := &Server{client: client, index: index, token: token, mux: http.NewServeMux()}
+	handlers := s.handlers()
+	for _, endpoint := range Endpoints {
+		handler, ok := handlers[endpoint.Method+" "+endpoint.Path]
+		if !ok {
+			return nil, fmt.Errorf("no handler for %s %s", endpoint.Method, endpoint.Path)
+		}
+		s.mux.HandleFunc(endpoint.Method+" "+endpoint.Path, handler)
+	}
+	return s, nil
+}
+
+// handlers returns the handler of each endpoint, keyed by method and path
+func (s *Server) handlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /v1/info":      s.handle(s.info),
+		"GET /v1/list":      s.handle(s.list),
+		"GET /v1/stat":      s.handle(s.stat),
+		"GET /v1/file":      s.getFile,
+		"PUT /v1/file":      s.handle(s.putFile),
+		"DELETE /v1/file":   s.handle(s.deleteFile),
+		"POST /v1/copy":     s.handle(s.copyFile),
+		"POST /v1/move":     s.handle(s.moveFile),
+		"POST /v1/mod-time": s.handle(s.setModTime),
+	}
+}
+
+// ServeHTTP checks the bearer token and routes the request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			sendError(w, &statusError{status: http.StatusUnauthorized, err: errors.New("missing or invalid bearer token")})
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle adapts a function returning a result or error to a handler that
+// answers with a JSON Response
+func (s *Server) handle(fn func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := fn(r)
+		if err != nil {
+			sendError(w, err)
+			return
+		}
+		sendJSON(w, http.StatusOK, Response{Success: true, Data: data})
+	}
+}
+
+func sendJSON(w http.ResponseWriter, status int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &statusErr):
+		status = statusErr.status
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errIsDir):
+		status = http.StatusBadRequest
+	}
+	sendJSON(w, status, Response{Success: false, Error: err.Error()})
+}
+
+func (s *Server) info(r *http.Request) (interface{}, error) {
+	return Info{
+		Server:   "noisefs",
+		Protocol: ProtocolVersion,
+		Hashes:   []string{HashSHA256},
+		Features: []string{FeatureRange, FeatureCopy, FeatureMove, FeatureModTime, FeatureRecursiveList, FeatureImplicitDirs},
+	}, nil
+}
+
+func (s *Server) list(r *http.Request) (interface{}, error) {
+	dir, err := cleanPath(r.URL.Query().Get("path"), true)
+	if err != nil {
+		return nil, err
+	}
+	recursive := false
+	if value := r.URL.Query().Get("recursive"); value != "" {
+		if recursive, err = strconv.ParseBool(value); err != nil {
+			return nil, badRequest("recursive must be true or false")
+		}
+	}
+
+	entries, found := s.listDir(dir, recursive)
+	if !found {
+		return nil, fmt.Errorf("directory %q: %w", dir, errNotFound)
+	}
+	return ListResult{Entries: entries}, nil
+}
+
+// listDir returns the entries in dir, or below it when recursive, and
+// whether the directory exists. Directories are those of the mount's
+// directory entries and of the files' paths.
+func (s *Server) listDir(dir string, recursive bool) ([]Entry, bool) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	found := dir == ""
+	var entries []Entry
+	dirs := make(map[string]time.Time)
+	addDir := func(p string, modTime time.Time) {
+		if latest, ok := dirs[p]; !ok || modTime.After(latest) {
+			dirs[p] = modTime
+		}
+	}
+
+	for key, entry := range s.index.ListFiles() {
+		if key == dir && entry.Type == fuse.DirectoryEntryType {
+			found = true
+		}
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		found = true
+
+		rel := key[len(prefix):]
+		for i := 0; i < len(rel); i++ {
+			if rel[i] == '/' {
+				addDir(prefix+rel[:i], entry.ModifiedAt)
+				if !recursive {
+					break
+				}
+			}
+		}
+		if !recursive && strings.Contains(rel, "/") {
+			continue
+		}
+		if entry.Type == fuse.DirectoryEntryType {
+			addDir(key, entry.ModifiedAt)
+		} else {
+			entries = append(entries, fileEntry(key, entry))
+		}
+	}
+
+	for p, modTime := range dirs {
+		entries = append(entries, Entry{Path: p, Name: path.Base(p), IsDir: true, ModTime: modTime})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, found
+}
+
+func (s *Server) stat(r *http.Request) (interface{}, error) {
+	p, err := cleanPath(r.URL.Query().Get("path"), true)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return Entry{IsDir: true}, nil
+	}
+	if entry, ok := s.index.GetFile(p); ok && entry.Type != fuse.DirectoryEntryType {
+		return fileEntry(p, entry), nil
+	}
+
+	entries, found := s.listDir(p, false)
+	if !found {
+		return nil, fmt.Errorf("%q: %w", p, errNotFound)
+	}
+	dir := Entry{Path: p, Name: path.Base(p), IsDir: true}
+	for _, entry := range entries {
+		if entry.ModTime.After(dir.ModTime) {
+			dir.ModTime = entry.ModTime
+		}
+	}
+	return dir, nil
+}
+
+// getFile answers with a file's content, honoring Range and conditional
+// requests
+func (s *Server) getFile(w http.ResponseWriter, r *http.Request) {
+	p, entry, err := s.lookupFile(r.URL.Query().Get("path"))
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+
+	var content io.ReadSeeker = bytes.NewReader(nil)
+	if entry.DescriptorCID != "" {
+		file, err := s.client.DownloadFile(r.Context(), entry.DescriptorCID, "", nil)
+		if err != nil {
+			sendError(w, fmt.Errorf("failed to retrieve %s: %w", p, err))
+			return
+		}
+		content = file.Reader
+	}
+
+	if entry.ContentHash != "" {
+		w.Header().Set("ETag", `"`+entry.ContentHash+`"`)
+	}
+	contentType := entry.MimeType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", entry.ModifiedAt, content)
+}
+
+func (s *Server) putFile(r *http.Request) (interface{}, error) {
+	p, err := cleanPath(r.URL.Query().Get("path"), false)
+	if err != nil {
+		return nil, err
+	}
+	var modTime time.Time
+	if value := r.URL.Query().Get("mod_time"); value != "" {
+		if modTime, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, badRequest("mod_time must be an RFC 3339 time")
+		}
+	}
+	if entry, ok := s.index.GetFile(p); ok && entry.Type == fuse.DirectoryEntryType {
+		return nil, fmt.Errorf("%q: %w", p, errIsDir)
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	body := bufio.NewReader(io.TeeReader(r.Body, io.MultiWriter(hash, counter)))
+
+	// Descriptors can't describe empty files, which the index records
+	// without one, as the FUSE mount does
+	descriptorCID := ""
+	if _, err := body.Peek(1); err == nil {
+		if descriptorCID, err = s.client.Upload(r.Context(), body, path.Base(p)); err != nil {
+			return nil, fmt.Errorf("failed to store %s: %w", p, err)
+		}
+	} else if err != io.EOF {
+		return nil, err
+	}
+
+	meta := fuse.FileMetadata{ContentHash: hex.EncodeToString(hash.Sum(nil)), MimeType: r.Header.Get("Content-Type")}
+	s.index.AddFileWithMetadata(p, descriptorCID, counter.n, meta)
+	if !modTime.IsZero() {
+		s.index.SetModTime(p, modTime)
+	}
+	return s.saved(p)
+}
+
+func (s *Server) deleteFile(r *http.Request) (interface{}, error) {
+	p, _, err := s.lookupFile(r.URL.Query().Get("path"))
+	if err != nil {
+		return nil, err
+	}
+	s.index.RemoveFile(p)
+	return nil, s.index.SaveIndex()
+}
+
+func (s *Server) copyFile(r *http.Request) (interface{}, error) {
+	return s.transfer(r, false)
+}
+
+func (s *Server) moveFile(r *http.Request) (interface{}, error) {
+	return s.transfer(r, true)
+}
+
+// transfer records the source's descriptor under the destination too, and
+// removes the source when moving; no content is transferred
+func (s *Server) transfer(r *http.Request, move bool) (interface{}, error) {
+	var request TransferRequest
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	from, src, err := s.lookupFile(request.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := cleanPath(request.To, false)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := s.index.GetFile(to); ok && entry.Type == fuse.DirectoryEntryType {
+		return nil, fmt.Errorf("%q: %w", to, errIsDir)
+	}
+	if to == from {
+		return fileEntry(from, src), nil
+	}
+
+	s.index.AddFileWithMetadata(to, src.DescriptorCID, src.FileSize, fuse.FileMetadata{
+		Tags:        src.Tags,
+		ContentHash: src.ContentHash,
+		Encrypted:   src.Encrypted,
+		MimeType:    src.MimeType,
+	})
+	s.index.SetModTime(to, src.ModifiedAt)
+	if move {
+		s.index.RemoveFile(from)
+	}
+	return s.saved(to)
+}
+
+func (s *Server) setModTime(r *http.Request) (interface{}, error) {
+	var request ModTimeRequest
+	if err := decodeBody(r, &request); err != nil {
+		return nil, err
+	}
+	if request.ModTime.IsZero() {
+		return nil, badRequest("mod_time is required")
+	}
+	p, _, err := s.lookupFile(request.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.index.SetModTime(p, request.ModTime)
+	return s.saved(p)
+}
+
+// saved saves the index and returns the entry of the file at p
+func (s *Server) saved(p string) (interface{}, error) {
+	if err := s.index.SaveIndex(); err != nil {
+		return nil, err
+	}
+	entry, ok := s.index.GetFile(p)
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", p, errNotFound)
+	}
+	return fileEntry(p, entry), nil
+}
+
+// lookupFile returns the cleaned path and index entry of a file
+func (s *Server) lookupFile(p string) (string, *fuse.IndexEntry, error) {
+	p, err := cleanPath(p, false)
+	if err != nil {
+		return "", nil, err
+	}
+	entry, ok := s.index.GetFile(p)
+	if !ok {
+		return "", nil, fmt.Errorf("%q: %w", p, errNotFound)
+	}
+	if entry.Type == fuse.DirectoryEntryType {
+		return "", nil, fmt.Errorf("%q: %w", p, errIsDir)
+	}
+	if entry.Encrypted {
+		return "", nil, &statusError{status: http.StatusForbidden, err: fmt.Errorf("%q has a password-protected descriptor", p)}
+	}
+	return p, entry, nil
+}
+
+// cleanPath trims surrounding slashes and rejects paths the index can't
+// hold; the root, "", is only allowed when root is set
+func cleanPath(p string, root bool) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		if root {
+			return "", nil
+		}
+		return "", badRequest("path is required")
+	}
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", badRequest("invalid path %q: backslashes and NUL characters are not allowed", p)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", badRequest("invalid path %q: empty, '.' and '..' elements are not allowed", p)
+		}
+	}
+	if strings.Contains(path.Base(p), "..") {
+		return "", badRequest("invalid path %q: names cannot contain '..'", p)
+	}
+	return p, nil
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestBodySize)).Decode(v); err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func fileEntry(p string, entry *fuse.IndexEntry) Entry {
+	return Entry{
+		Path:     p,
+		Name:     path.Base(p),
+		Size:     entry.FileSize,
+		ModTime:  entry.ModifiedAt,
+		SHA256:   entry.ContentHash,
+		MimeType: entry.MimeType,
+	}
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
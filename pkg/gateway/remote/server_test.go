@@ -0,0 +1,284 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+// newTestServer returns a server over a mock storage backend and a new
+// index
+func newTestServer(t *testing.T, token string) (*Server, *fuse.FileIndex) {
+	t.Helper()
+	backend, err := backends.NewMockBackend("remote-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageConfig := storage.DefaultConfig()
+	storageConfig.Backends = map[string]*storage.BackendConfig{
+		storage.BackendTypeCustom: storage.CustomBackendConfig(backend),
+	}
+	storageConfig.DefaultBackend = storage.BackendTypeCustom
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storageManager.Stop(context.Background()) })
+
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := fuse.NewFileIndex(filepath.Join(t.TempDir(), "index.json"))
+	s, err := New(client, index, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, index
+}
+
+func do(s *Server, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// call makes a request expecting a JSON response with status, and decodes
+// its data into data unless nil
+func call(t *testing.T, s *Server, method, target string, body interface{}, status int, data interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	w := do(s, method, target, reader, nil)
+	if w.Code != status {
+		t.Fatalf("%s %s: %d %s, want %d", method, target, w.Code, w.Body, status)
+	}
+	var response struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%s %s: invalid response %q: %v", method, target, w.Body, err)
+	}
+	if response.Success != (status == http.StatusOK) {
+		t.Fatalf("%s %s: success = %v in %s", method, target, response.Success, w.Body)
+	}
+	if data != nil {
+		if err := json.Unmarshal(response.Data, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func put(t *testing.T, s *Server, p, content string, modTime time.Time) Entry {
+	t.Helper()
+	query := url.Values{"path": {p}}
+	if !modTime.IsZero() {
+		query.Set("mod_time", modTime.Format(time.RFC3339Nano))
+	}
+	w := do(s, http.MethodPut, "/v1/file?"+query.Encode(), strings.NewReader(content), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT %s: %d %s", p, w.Code, w.Body)
+	}
+	var response struct {
+		Data Entry `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func get(t *testing.T, s *Server, p string) string {
+	t.Helper()
+	w := do(s, http.MethodGet, "/v1/file?path="+url.QueryEscape(p), nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", p, w.Code, w.Body)
+	}
+	return w.Body.String()
+}
+
+func TestPutGetFile(t *testing.T) {
+	s, index := newTestServer(t, "")
+	content := strings.Repeat("0123456789", 10000)
+	modTime := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+
+	entry := put(t, s, "docs/my numbers.txt", content, modTime)
+	if entry.Path != "docs/my numbers.txt" || entry.Name != "my numbers.txt" || entry.Size != int64(len(content)) {
+		t.Errorf("entry = %+v", entry)
+	}
+	if !entry.ModTime.Equal(modTime) {
+		t.Errorf("mod time = %v, want %v", entry.ModTime, modTime)
+	}
+	if len(entry.SHA256) != 64 {
+		t.Errorf("sha256 = %q", entry.SHA256)
+	}
+	if _, ok := index.GetFile("docs/my numbers.txt"); !ok {
+		t.Fatal("file not in index")
+	}
+
+	if got := get(t, s, "docs/my numbers.txt"); got != content {
+		t.Errorf("GET returned %d bytes, want %d", len(got), len(content))
+	}
+
+	w := do(s, http.MethodGet, "/v1/file?path=docs/my%20numbers.txt", nil, map[string]string{"Range": "bytes=10-14"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "01234" {
+		t.Errorf("range GET: %d %q", w.Code, w.Body)
+	}
+	if got := w.Header().Get("ETag"); got != `"`+entry.SHA256+`"` {
+		t.Errorf("ETag = %s", got)
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	s, _ := newTestServer(t, "")
+	entry := put(t, s, "empty", "", time.Time{})
+	if entry.Size != 0 {
+		t.Errorf("size = %d", entry.Size)
+	}
+	if got := get(t, s, "empty"); got != "" {
+		t.Errorf("GET = %q", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	s, _ := newTestServer(t, "")
+	older := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	put(t, s, "top.txt", "top", older)
+	put(t, s, "a/one.txt", "one", older)
+	put(t, s, "a/b/two.txt", "two", newer)
+
+	paths := func(result ListResult) []string {
+		var paths []string
+		for _, entry := range result.Entries {
+			if entry.IsDir {
+				paths = append(paths, entry.Path+"/")
+			} else {
+				paths = append(paths, entry.Path)
+			}
+		}
+		return paths
+	}
+
+	var result ListResult
+	call(t, s, http.MethodGet, "/v1/list", nil, http.StatusOK, &result)
+	if got := strings.Join(paths(result), ","); got != "a/,top.txt" {
+		t.Errorf("root = %s", got)
+	}
+
+	call(t, s, http.MethodGet, "/v1/list?path=a", nil, http.StatusOK, &result)
+	if got := strings.Join(paths(result), ","); got != "a/b/,a/one.txt" {
+		t.Errorf("a = %s", got)
+	}
+
+	call(t, s, http.MethodGet, "/v1/list?path=a&recursive=true", nil, http.StatusOK, &result)
+	if got := strings.Join(paths(result), ","); got != "a/b/,a/b/two.txt,a/one.txt" {
+		t.Errorf("a recursive = %s", got)
+	}
+
+	call(t, s, http.MethodGet, "/v1/list?path=missing", nil, http.StatusNotFound, nil)
+
+	var dir Entry
+	call(t, s, http.MethodGet, "/v1/stat?path=a", nil, http.StatusOK, &dir)
+	if !dir.IsDir || !dir.ModTime.Equal(newer) {
+		t.Errorf("stat a = %+v", dir)
+	}
+	call(t, s, http.MethodGet, "/v1/stat?path=a/missing", nil, http.StatusNotFound, nil)
+}
+
+func TestCopyMoveKeepModTime(t *testing.T) {
+	s, index := newTestServer(t, "")
+	modTime := time.Date(2022, 6, 15, 8, 0, 0, 0, time.UTC)
+	put(t, s, "src.txt", "content", modTime)
+
+	var entry Entry
+	call(t, s, http.MethodPost, "/v1/copy", TransferRequest{From: "src.txt", To: "copy/dst.txt"}, http.StatusOK, &entry)
+	if !entry.ModTime.Equal(modTime) || entry.Size != 7 {
+		t.Errorf("copy = %+v", entry)
+	}
+	if got := get(t, s, "copy/dst.txt"); got != "content" {
+		t.Errorf("copy content = %q", got)
+	}
+
+	call(t, s, http.MethodPost, "/v1/move", TransferRequest{From: "src.txt", To: "moved.txt"}, http.StatusOK, &entry)
+	if !entry.ModTime.Equal(modTime) || entry.Path != "moved.txt" {
+		t.Errorf("move = %+v", entry)
+	}
+	if _, ok := index.GetFile("src.txt"); ok {
+		t.Error("source still in index after move")
+	}
+
+	call(t, s, http.MethodPost, "/v1/move", TransferRequest{From: "src.txt", To: "x"}, http.StatusNotFound, nil)
+	call(t, s, http.MethodPost, "/v1/copy", TransferRequest{From: "moved.txt", To: "../x"}, http.StatusBadRequest, nil)
+}
+
+func TestSetModTimeAndDelete(t *testing.T) {
+	s, index := newTestServer(t, "")
+	put(t, s, "file.txt", "content", time.Time{})
+
+	modTime := time.Date(2020, 2, 2, 2, 2, 2, 0, time.UTC)
+	var entry Entry
+	call(t, s, http.MethodPost, "/v1/mod-time", ModTimeRequest{Path: "file.txt", ModTime: modTime}, http.StatusOK, &entry)
+	if !entry.ModTime.Equal(modTime) {
+		t.Errorf("mod time = %v", entry.ModTime)
+	}
+	call(t, s, http.MethodPost, "/v1/mod-time", ModTimeRequest{Path: "file.txt"}, http.StatusBadRequest, nil)
+
+	call(t, s, http.MethodDelete, "/v1/file?path=file.txt", nil, http.StatusOK, nil)
+	if _, ok := index.GetFile("file.txt"); ok {
+		t.Error("file still in index after delete")
+	}
+	call(t, s, http.MethodDelete, "/v1/file?path=file.txt", nil, http.StatusNotFound, nil)
+}
+
+func TestToken(t *testing.T) {
+	s, _ := newTestServer(t, "s3cret")
+
+	call(t, s, http.MethodGet, "/v1/info", nil, http.StatusUnauthorized, nil)
+	if w := do(s, http.MethodGet, "/v1/info", nil, map[string]string{"Authorization": "Bearer wrong"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", w.Code)
+	}
+
+	w := do(s, http.MethodGet, "/v1/info", nil, map[string]string{"Authorization": "Bearer s3cret"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("info: %d %s", w.Code, w.Body)
+	}
+	var response struct {
+		Data Info `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.Server != "noisefs" || response.Data.Protocol != ProtocolVersion {
+		t.Errorf("info = %+v", response.Data)
+	}
+}
@@ -0,0 +1,325 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// OpenAPI returns the OpenAPI 3 specification of the protocol, with the
+// remote options under x-remote-options
+func OpenAPI() ([]byte, error) {
+	paths := make(map[string]map[string]interface{})
+	for _, endpoint := range Endpoints {
+		operation := map[string]interface{}{
+			"summary":     endpoint.Summary,
+			"description": endpoint.Description,
+			"operationId": operationID(endpoint),
+		}
+
+		var params []interface{}
+		for _, param := range endpoint.Params {
+			params = append(params, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"required":    param.Required,
+				"description": param.Description,
+				"schema":      paramSchema(param.Type),
+			})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+
+		switch {
+		case endpoint.RawBody:
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/octet-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+				},
+			}
+		case endpoint.Body != nil:
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(endpoint.Body))},
+				},
+			}
+		}
+
+		responses := map[string]interface{}{}
+		if endpoint.RawResult {
+			responses["200"] = map[string]interface{}{
+				"description": "File content",
+				"content": map[string]interface{}{
+					"application/octet-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+				},
+			}
+		} else {
+			responses["200"] = map[string]interface{}{
+				"description": "Success",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": responseSchema(endpoint.Result)},
+				},
+			}
+		}
+		for _, status := range append(append([]int(nil), endpoint.Errors...), http.StatusUnauthorized) {
+			responses[strconv.Itoa(status)] = map[string]interface{}{
+				"description": http.StatusText(status),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": responseSchema(nil)},
+				},
+			}
+		}
+		operation["responses"] = responses
+
+		if paths[endpoint.Path] == nil {
+			paths[endpoint.Path] = make(map[string]interface{})
+		}
+		paths[endpoint.Path][strings.ToLower(endpoint.Method)] = operation
+	}
+
+	options := make([]interface{}, len(Options))
+	for i, option := range Options {
+		options[i] = map[string]interface{}{
+			"name":      option.Name,
+			"help":      option.Help,
+			"default":   option.Default,
+			"required":  option.Required,
+			"sensitive": option.Sensitive,
+		}
+	}
+
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "NoiseFS remote protocol",
+			"version":     strconv.Itoa(ProtocolVersion),
+			"description": "Serves the NoiseFS file index to sync tool backends such as rclone.",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": Options[0].Default}},
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security":         []interface{}{map[string]interface{}{"bearer": []string{}}},
+		"paths":            paths,
+		"x-remote-options": options,
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// operationID names an endpoint, e.g. "putFile" for PUT /v1/file
+func operationID(endpoint Endpoint) string {
+	name := strings.ToLower(endpoint.Method)
+	for _, word := range strings.FieldsFunc(strings.TrimPrefix(endpoint.Path, "/v1/"), func(r rune) bool { return r == '-' || r == '/' }) {
+		name += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return name
+}
+
+func paramSchema(paramType string) map[string]interface{} {
+	if paramType == "date-time" {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	return map[string]interface{}{"type": paramType}
+}
+
+// responseSchema is the schema of a Response whose data is result
+func responseSchema(result interface{}) map[string]interface{} {
+	schema := schemaFor(reflect.TypeOf(Response{}))
+	if result != nil {
+		schema["properties"].(map[string]interface{})["data"] = schemaFor(reflect.TypeOf(result))
+	} else {
+		delete(schema["properties"].(map[string]interface{}), "data")
+	}
+	return schema
+}
+
+// schemaFor returns the JSON schema of a Go type, taking descriptions from
+// doc tags
+func schemaFor(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		var required []string
+		for _, field := range jsonFields(t) {
+			schema := schemaFor(field.Type)
+			if field.Doc != "" {
+				schema["description"] = field.Doc
+			}
+			properties[field.Name] = schema
+			if !field.OmitEmpty {
+				required = append(required, field.Name)
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if required != nil {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// jsonField is a struct field as it appears in JSON
+type jsonField struct {
+	Name      string
+	Type      reflect.Type
+	Doc       string
+	OmitEmpty bool
+}
+
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, jsonField{
+			Name:      name,
+			Type:      field.Type,
+			Doc:       field.Tag.Get("doc"),
+			OmitEmpty: options == "omitempty",
+		})
+	}
+	return fields
+}
+
+// Markdown returns the protocol documentation for authors of client
+// backends
+func Markdown() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# NoiseFS Remote Protocol (version %d)\n\n", ProtocolVersion)
+	b.WriteString("<!-- Generated by `noisefs remote spec -format markdown`; do not edit. -->\n\n")
+	b.WriteString("`noisefs remote serve` serves the file index over this HTTP/JSON protocol,\n")
+	b.WriteString("which rclone backends and similar sync tools can be built against. Paths are\n")
+	b.WriteString("slash-separated and relative to the root. When the server has a token,\n")
+	b.WriteString("every request must carry `Authorization: Bearer <token>`; requests without\n")
+	b.WriteString("it fail with 401. Version 1 only grows: new endpoints and fields may be\n")
+	b.WriteString("added, but existing ones keep their meaning.\n\n")
+
+	b.WriteString("## Remote Configuration\n\n")
+	b.WriteString("A client backend asks users for these options:\n\n")
+	b.WriteString("| Option | Default | Required | Description |\n")
+	b.WriteString("|--------|---------|----------|-------------|\n")
+	for _, option := range Options {
+		description := option.Help
+		if option.Sensitive {
+			description += " (sensitive)"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", option.Name, codeOrEmpty(option.Default), yesNo(option.Required), description)
+	}
+
+	b.WriteString("\n## Responses\n\n")
+	b.WriteString("Except for file content, responses are JSON objects:\n\n")
+	writeFieldTable(&b, reflect.TypeOf(Response{}))
+	b.WriteString("\nFailures use the status listed for each endpoint, or 500 for server errors.\n")
+
+	b.WriteString("\n## Types\n")
+	for _, t := range []interface{}{Info{}, Entry{}, ListResult{}, TransferRequest{}, ModTimeRequest{}} {
+		fmt.Fprintf(&b, "\n### %s\n\n", reflect.TypeOf(t).Name())
+		writeFieldTable(&b, reflect.TypeOf(t))
+	}
+
+	b.WriteString("\n## Endpoints\n")
+	for _, endpoint := range Endpoints {
+		fmt.Fprintf(&b, "\n### %s %s\n\n%s. %s\n", endpoint.Method, endpoint.Path, endpoint.Summary, endpoint.Description)
+		if len(endpoint.Params) > 0 {
+			b.WriteString("\n| Parameter | Type | Required | Description |\n")
+			b.WriteString("|-----------|------|----------|-------------|\n")
+			for _, param := range endpoint.Params {
+				fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", param.Name, param.Type, yesNo(param.Required), param.Description)
+			}
+		}
+		switch {
+		case endpoint.RawBody:
+			b.WriteString("\nRequest body: the file content.\n")
+		case endpoint.Body != nil:
+			fmt.Fprintf(&b, "\nRequest body: %s.\n", typeName(reflect.TypeOf(endpoint.Body)))
+		}
+		switch {
+		case endpoint.RawResult:
+			b.WriteString("\nResponse: the file content.\n")
+		case endpoint.Result != nil:
+			fmt.Fprintf(&b, "\nResponse data: %s.\n", typeName(reflect.TypeOf(endpoint.Result)))
+		default:
+			b.WriteString("\nResponse: `{\"success\": true}`.\n")
+		}
+		if len(endpoint.Errors) > 0 {
+			statuses := make([]string, len(endpoint.Errors))
+			for i, status := range endpoint.Errors {
+				statuses[i] = fmt.Sprintf("%d %s", status, http.StatusText(status))
+			}
+			fmt.Fprintf(&b, "\nErrors: %s.\n", strings.Join(statuses, ", "))
+		}
+	}
+	return []byte(b.String())
+}
+
+func writeFieldTable(b *strings.Builder, t reflect.Type) {
+	b.WriteString("| Field | Type | Description |\n")
+	b.WriteString("|-------|------|-------------|\n")
+	for _, field := range jsonFields(t) {
+		doc := field.Doc
+		if field.OmitEmpty {
+			doc += " (omitted when empty)"
+		}
+		fmt.Fprintf(b, "| `%s` | %s | %s |\n", field.Name, typeName(field.Type), doc)
+	}
+}
+
+// typeName describes a field type in the documentation
+func typeName(t reflect.Type) string {
+	if t == timeType {
+		return "time"
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return typeName(t.Elem()) + "[]"
+	case reflect.Struct:
+		return "[" + t.Name() + "](#" + strings.ToLower(t.Name()) + ")"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Interface:
+		return "any"
+	default:
+		return t.Kind().String()
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func codeOrEmpty(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEveryEndpointHasHandler(t *testing.T) {
+	s, _ := newTestServer(t, "")
+	handlers := s.handlers()
+	if len(handlers) != len(Endpoints) {
+		t.Errorf("%d handlers for %d endpoints", len(handlers), len(Endpoints))
+	}
+	for _, endpoint := range Endpoints {
+		if _, ok := handlers[endpoint.Method+" "+endpoint.Path]; !ok {
+			t.Errorf("no handler for %s %s", endpoint.Method, endpoint.Path)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	data, err := OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+		Options []Option                              `json:"x-remote-options"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q", spec.OpenAPI)
+	}
+	for _, endpoint := range Endpoints {
+		if _, ok := spec.Paths[endpoint.Path][strings.ToLower(endpoint.Method)]; !ok {
+			t.Errorf("%s %s missing", endpoint.Method, endpoint.Path)
+		}
+	}
+	if len(spec.Options) != len(Options) {
+		t.Errorf("%d options, want %d", len(spec.Options), len(Options))
+	}
+	if !strings.Contains(string(data), `"format": "date-time"`) {
+		t.Error("times are not described as date-time strings")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	doc := string(Markdown())
+	for _, endpoint := range Endpoints {
+		if !strings.Contains(doc, "### "+endpoint.Method+" "+endpoint.Path+"\n") {
+			t.Errorf("%s %s not documented", endpoint.Method, endpoint.Path)
+		}
+	}
+	for _, option := range Options {
+		if !strings.Contains(doc, "| `"+option.Name+"` |") {
+			t.Errorf("option %s not documented", option.Name)
+		}
+	}
+	if !strings.Contains(doc, "| `mod_time` | time |") {
+		t.Error("entry fields not documented")
+	}
+}
@@ -0,0 +1,405 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature Version 4 constants
+const (
+	signAlgorithm      = "AWS4-HMAC-SHA256"
+	amzDateFormat      = "20060102T150405Z"
+	maxClockSkew       = 15 * time.Minute
+	emptySHA256        = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload    = "UNSIGNED-PAYLOAD"
+	streamingPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingTrailer   = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	chunkSignAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+
+	// Bounds the memory a signed chunk is buffered in while it is verified
+	maxChunkSize = 16 << 20
+)
+
+// credential is the parsed Authorization header of a signed request
+type credential struct {
+	accessKey     string
+	date          string // YYYYMMDD
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+// scope returns the credential scope the request was signed for
+func (c *credential) scope() string {
+	return c.date + "/" + c.region + "/" + c.service + "/aws4_request"
+}
+
+// parseAuthorization parses an "AWS4-HMAC-SHA256 Credential=...,
+// SignedHeaders=..., Signature=..." header
+func parseAuthorization(header string) (*credential, error) {
+	algorithm, rest, _ := strings.Cut(header, " ")
+	if algorithm != signAlgorithm {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	cred := &credential{}
+	for _, part := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errAuthorizationHeaderMalformed
+		}
+		switch name {
+		case "Credential":
+			fields := strings.Split(value, "/")
+			if len(fields) != 5 || fields[4] != "aws4_request" {
+				return nil, errAuthorizationHeaderMalformed
+			}
+			cred.accessKey, cred.date, cred.region, cred.service = fields[0], fields[1], fields[2], fields[3]
+		case "SignedHeaders":
+			cred.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			cred.signature = value
+		}
+	}
+	if cred.accessKey == "" || len(cred.signedHeaders) == 0 || cred.signature == "" {
+		return nil, errAuthorizationHeaderMalformed
+	}
+	return cred, nil
+}
+
+// authenticate checks the request's signature when the gateway has
+// credentials, and replaces its body with one that verifies the payload
+// hash or chunk signatures as it is read
+func (g *Gateway) authenticate(r *http.Request) error {
+	if g.config.AccessKey == "" {
+		return nil
+	}
+	if r.URL.Query().Has("X-Amz-Signature") {
+		return newError(http.StatusForbidden, "AccessDenied", "Presigned URLs are not supported")
+	}
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return newError(http.StatusForbidden, "AccessDenied", "Requests must be signed")
+	}
+	cred, err := parseAuthorization(header)
+	if err != nil {
+		return err
+	}
+	if cred.accessKey != g.config.AccessKey {
+		return errInvalidAccessKeyID
+	}
+	if cred.service != "s3" {
+		return errAuthorizationHeaderMalformed
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, cred.date) {
+		return newError(http.StatusForbidden, "AccessDenied", "X-Amz-Date is missing or does not match the credential scope")
+	}
+	if skew := g.now().Sub(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return newError(http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return newError(http.StatusBadRequest, "InvalidRequest", "Missing required header x-amz-content-sha256")
+	}
+
+	if missing := unsignedHeaders(r, cred.signedHeaders); len(missing) > 0 {
+		return newError(http.StatusForbidden, "AccessDenied", "There were headers present in the request which were not signed: "+strings.Join(missing, ", "))
+	}
+
+	key := signingKey(g.config.SecretKey, cred.date, cred.region, cred.service)
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		cred.scope(),
+		sha256Hex([]byte(canonicalRequest(r, cred.signedHeaders, payloadHash))),
+	}, "\n")
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, stringToSign))), []byte(cred.signature)) {
+		return errSignatureDoesNotMatch
+	}
+
+	switch {
+	case payloadHash == unsignedPayload:
+	case payloadHash == streamingPayload || payloadHash == streamingTrailer:
+		decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || decoded < 0 {
+			return newError(http.StatusLengthRequired, "MissingContentLength", "Missing x-amz-decoded-content-length")
+		}
+		reader := &chunkedReader{r: bufio.NewReader(r.Body), body: r.Body, trailer: payloadHash == streamingTrailer}
+		if payloadHash == streamingPayload {
+			reader.key, reader.amzDate, reader.scope, reader.prevSignature = key, amzDate, cred.scope(), cred.signature
+		}
+		r.Body = reader
+		r.ContentLength = decoded
+	case len(payloadHash) == sha256.Size*2:
+		r.Body = &hashingReader{r: r.Body, hash: sha256.New(), want: strings.ToLower(payloadHash)}
+	default:
+		return newError(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("Payload type %s is not supported", payloadHash))
+	}
+	return nil
+}
+
+// unsignedHeaders returns the headers a signature has to cover but
+// doesn't: host, and every x-amz-* header the request carries, so none of
+// them can be changed without breaking it
+func unsignedHeaders(r *http.Request, signedHeaders []string) []string {
+	signed := make(map[string]bool, len(signedHeaders))
+	for _, name := range signedHeaders {
+		signed[name] = true
+	}
+
+	var missing []string
+	if !signed["host"] {
+		missing = append(missing, "host")
+	}
+	for name := range r.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") && !signed[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// canonicalRequest builds the canonical form of a request that is signed
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = r.Header.Get("Content-Length")
+			if value == "" {
+				value = strconv.FormatInt(r.ContentLength, 10)
+			}
+		default:
+			values := r.Header.Values(name)
+			for i, v := range values {
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery sorts and encodes query parameters as they are signed
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes unless encodeSlash is set, as SigV4 requires
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signingKey derives the key requests for a day, region and service are
+// signed with
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyingBody is a request body that checks what it reads against the
+// request's signature
+type verifyingBody interface {
+	io.ReadCloser
+	// verifyErr returns the error that ended reading if the body didn't
+	// match the signature
+	verifyErr() error
+}
+
+// hashingReader fails at the end of a body whose SHA-256 differs from the
+// signed payload hash
+type hashingReader struct {
+	r    io.ReadCloser
+	hash hash.Hash
+	want string
+	err  error
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	if h.err != nil {
+		return 0, h.err
+	}
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.want {
+		h.err = errContentSHA256Mismatch
+		return n, h.err
+	}
+	return n, err
+}
+
+func (h *hashingReader) Close() error     { return h.r.Close() }
+func (h *hashingReader) verifyErr() error { return h.err }
+
+// chunkedReader decodes an aws-chunked body. With a key, each chunk's
+// signature is checked, chaining from the request signature, before any of
+// it is returned.
+type chunkedReader struct {
+	r       *bufio.Reader
+	body    io.Closer
+	trailer bool // Trailing headers follow the last chunk
+
+	key           []byte
+	amzDate       string
+	scope         string
+	prevSignature string
+
+	chunk []byte
+	done  bool
+	err   error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// nextChunk reads and verifies the next chunk
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, params, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeField, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errIncompleteBody
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return errIncompleteBody
+	}
+	if size > 0 || !c.trailer {
+		if crlf, err := c.readLine(); err != nil || crlf != "" {
+			return errIncompleteBody
+		}
+	}
+
+	if c.key != nil {
+		signature := strings.TrimPrefix(params, "chunk-signature=")
+		stringToSign := strings.Join([]string{
+			chunkSignAlgorithm,
+			c.amzDate,
+			c.scope,
+			c.prevSignature,
+			emptySHA256,
+			sha256Hex(data),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(c.key, stringToSign))
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return errSignatureDoesNotMatch
+		}
+		c.prevSignature = signature
+	}
+
+	if size == 0 {
+		// Trailing checksums end with an empty line; TLS and the chunk
+		// signatures already protect the content
+		for c.trailer {
+			line, err := c.readLine()
+			if err != nil {
+				return errIncompleteBody
+			}
+			if line == "" {
+				break
+			}
+		}
+		c.done = true
+	}
+	c.chunk = data
+	return nil
+}
+
+// readLine reads a CRLF-terminated line without its terminator
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return "", errIncompleteBody
+	}
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
+
+func (c *chunkedReader) Close() error { return c.body.Close() }
+
+func (c *chunkedReader) verifyErr() error {
+	if c.err == errSignatureDoesNotMatch || c.err == errIncompleteBody {
+		return c.err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// apiError is an error response in the form S3 clients expect
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func newError(status int, code, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+var (
+	errNoSuchBucket                 = newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	errNoSuchKey                    = newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+	errMethodNotAllowed             = newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource")
+	errMultipartNotImplemented      = newError(http.StatusNotImplemented, "NotImplemented", "Multipart uploads are not supported; upload objects in a single PUT")
+	errInvalidAccessKeyID           = newError(http.StatusForbidden, "InvalidAccessKeyId", "The access key ID you provided does not exist in our records")
+	errSignatureDoesNotMatch        = newError(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+	errAuthorizationHeaderMalformed = newError(http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed")
+	errContentSHA256Mismatch        = newError(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+	errBadDigest                    = newError(http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received")
+	errIncompleteBody               = newError(http.StatusBadRequest, "IncompleteBody", "The request body is malformed or ended early")
+	errMalformedXML                 = newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed")
+)
+
+// errorResponse is the XML body of an error response
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// writeError sends err to the client, as InternalError unless it is an
+// apiError
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = newError(http.StatusInternalServerError, "InternalError", err.Error())
+	}
+	if r.Method == http.MethodHead {
+		// HEAD responses have no body to carry the error
+		w.WriteHeader(e.status)
+		return
+	}
+	writeXML(w, e.status, errorResponse{
+		Code:      e.code,
+		Message:   e.message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+// writeXML sends v as an XML response body
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
@@ -0,0 +1,693 @@
+// Package s3 serves the NoiseFS file index as an S3-compatible bucket, so
+// tools such as rclone and backup software can store files in NoiseFS
+// without a custom integration. Object keys are index paths; PUT uploads
+// the object through the client and records it in the index, GET
+// retrieves it, with Range support, and DELETE removes it from the index.
+//
+// Only path-style requests (http://host/bucket/key) are served, and
+// multipart uploads are not supported. Requests are authenticated with
+// AWS Signature Version 4 when the gateway has credentials.
+package s3
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+)
+
+// s3Namespace is the XML namespace of S3 responses
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// maxListKeys is the most keys returned by one listing
+const maxListKeys = 1000
+
+// maxDeleteRequestSize bounds the body of a multi-object delete
+const maxDeleteRequestSize = 2 << 20
+
+// Index is the file index objects are stored in; both fuse.FileIndex and
+// fuse.EncryptedFileIndex implement it
+type Index interface {
+	AddFileWithMetadata(path, descriptorCID string, fileSize int64, meta fuse.FileMetadata)
+	RemoveFile(path string) bool
+	GetFile(path string) (*fuse.IndexEntry, bool)
+	ListFiles() map[string]*fuse.IndexEntry
+	SaveIndex() error
+}
+
+// Config configures a Gateway
+type Config struct {
+	Bucket string // Name the index is served as
+	Region string // Reported by GetBucketLocation
+
+	// Credentials requests must be signed with. Empty accepts unsigned
+	// requests from anyone who can reach the gateway.
+	AccessKey string
+	SecretKey string
+}
+
+// Gateway is an http.Handler serving the S3 API
+type Gateway struct {
+	client  *noisefs.Client
+	index   Index
+	config  Config
+	started time.Time
+	now     func() time.Time
+}
+
+// New returns a gateway storing objects with client and recording them in
+// index
+func New(client *noisefs.Client, index Index, config Config) (*Gateway, error) {
+	if client == nil || index == nil {
+		return nil, errors.New("client and index are required")
+	}
+	if config.Bucket == "" {
+		return nil, errors.New("bucket name cannot be empty")
+	}
+	if (config.AccessKey == "") != (config.SecretKey == "") {
+		return nil, errors.New("access key and secret key must be set together")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &Gateway{
+		client:  client,
+		index:   index,
+		config:  config,
+		started: time.Now(),
+		now:     time.Now,
+	}, nil
+}
+
+// ServeHTTP routes a request to the bucket or object it addresses
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "NoiseFS")
+	w.Header().Set("X-Amz-Request-Id", newRequestID())
+
+	if err := g.authenticate(r); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	var err error
+	switch {
+	case bucket == "":
+		err = g.serveService(w, r)
+	case bucket != g.config.Bucket:
+		err = errNoSuchBucket
+	case key == "":
+		err = g.serveBucket(w, r)
+	default:
+		err = g.serveObject(w, r, key)
+	}
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// serveService handles requests without a bucket, i.e. ListBuckets
+func (g *Gateway) serveService(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return errMethodNotAllowed
+	}
+	type bucket struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	type owner struct {
+		ID          string `xml:"ID"`
+		DisplayName string `xml:"DisplayName"`
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Owner   owner    `xml:"Owner"`
+		Buckets []bucket `xml:"Buckets>Bucket"`
+	}{
+		Xmlns:   s3Namespace,
+		Owner:   owner{ID: "noisefs", DisplayName: "noisefs"},
+		Buckets: []bucket{{Name: g.config.Bucket, CreationDate: formatTime(g.started)}},
+	})
+	return nil
+}
+
+// listParams are the query parameters of bucket listings; any other names
+// a subresource such as ?versioning
+var listParams = map[string]bool{
+	"list-type": true, "prefix": true, "delimiter": true, "marker": true,
+	"max-keys": true, "continuation-token": true, "start-after": true,
+	"encoding-type": true, "fetch-owner": true, "x-id": true,
+}
+
+// serveBucket handles requests for the bucket itself
+func (g *Gateway) serveBucket(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case http.MethodPut:
+		// The bucket always exists; creating it again succeeds, as in us-east-1
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case http.MethodPost:
+		if query.Has("delete") {
+			return g.deleteObjects(w, r)
+		}
+		return errMethodNotAllowed
+	case http.MethodGet:
+	default:
+		return errMethodNotAllowed
+	}
+
+	switch {
+	case query.Has("location"):
+		location := g.config.Region
+		if location == "us-east-1" {
+			location = "" // How S3 reports its default region
+		}
+		writeXML(w, http.StatusOK, struct {
+			XMLName  xml.Name `xml:"LocationConstraint"`
+			Xmlns    string   `xml:"xmlns,attr"`
+			Location string   `xml:",chardata"`
+		}{Xmlns: s3Namespace, Location: location})
+		return nil
+	case query.Has("versioning"):
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+			Xmlns   string   `xml:"xmlns,attr"`
+		}{Xmlns: s3Namespace})
+		return nil
+	case query.Has("uploads"):
+		return errMultipartNotImplemented
+	}
+	for name := range query {
+		if !listParams[name] {
+			return newError(http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("The %s subresource is not supported", name))
+		}
+	}
+	return g.listObjects(w, query)
+}
+
+// listing is one page of a bucket listing
+type listing struct {
+	keys      []string
+	entries   []*fuse.IndexEntry
+	prefixes  []string
+	truncated bool
+	next      string // Last key or common prefix returned
+}
+
+// list returns up to maxKeys keys and common prefixes that start with
+// prefix and sort after after. Keys containing delimiter past the prefix
+// are rolled up into a common prefix.
+func (g *Gateway) list(prefix, delimiter, after string, maxKeys int) listing {
+	files := g.index.ListFiles()
+	keys := make([]string, 0, len(files))
+	for key, entry := range files {
+		if entry.Type != fuse.DirectoryEntryType && strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result listing
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if common <= after || (len(result.prefixes) > 0 && result.prefixes[len(result.prefixes)-1] == common) {
+					continue // Returned already
+				}
+				if len(result.keys)+len(result.prefixes) == maxKeys {
+					result.truncated = true
+					break
+				}
+				result.prefixes = append(result.prefixes, common)
+				result.next = common
+				continue
+			}
+		}
+		if len(result.keys)+len(result.prefixes) == maxKeys {
+			result.truncated = true
+			break
+		}
+		result.keys = append(result.keys, key)
+		result.entries = append(result.entries, files[key])
+		result.next = key
+	}
+	return result
+}
+
+// listObject is an object in a listing
+type listObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listObjects answers ListObjects and, with list-type=2, ListObjectsV2
+func (g *Gateway) listObjects(w http.ResponseWriter, query url.Values) error {
+	maxKeys := maxListKeys
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return newError(http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Invalid Encoding Method specified in Request")
+	}
+	encode := func(s string) string {
+		if encodingType == "url" {
+			return url.QueryEscape(s)
+		}
+		return s
+	}
+
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	v2 := query.Get("list-type") == "2"
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return newError(http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect")
+			}
+			after = string(decoded)
+		}
+	}
+
+	page := g.list(prefix, delimiter, after, maxKeys)
+	contents := make([]listObject, len(page.keys))
+	for i, key := range page.keys {
+		entry := page.entries[i]
+		contents[i] = listObject{
+			Key:          encode(key),
+			LastModified: formatTime(entry.ModifiedAt),
+			ETag:         etag(entry),
+			Size:         entry.FileSize,
+			StorageClass: "STANDARD",
+		}
+	}
+	prefixes := make([]commonPrefix, len(page.prefixes))
+	for i, p := range page.prefixes {
+		prefixes[i] = commonPrefix{Prefix: encode(p)}
+	}
+
+	if v2 {
+		result := struct {
+			XMLName               xml.Name       `xml:"ListBucketResult"`
+			Xmlns                 string         `xml:"xmlns,attr"`
+			Name                  string         `xml:"Name"`
+			Prefix                string         `xml:"Prefix"`
+			Delimiter             string         `xml:"Delimiter,omitempty"`
+			StartAfter            string         `xml:"StartAfter,omitempty"`
+			ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+			NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+			KeyCount              int            `xml:"KeyCount"`
+			MaxKeys               int            `xml:"MaxKeys"`
+			EncodingType          string         `xml:"EncodingType,omitempty"`
+			IsTruncated           bool           `xml:"IsTruncated"`
+			Contents              []listObject   `xml:"Contents"`
+			CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+		}{
+			Xmlns:             s3Namespace,
+			Name:              g.config.Bucket,
+			Prefix:            encode(prefix),
+			Delimiter:         encode(delimiter),
+			StartAfter:        encode(query.Get("start-after")),
+			ContinuationToken: query.Get("continuation-token"),
+			KeyCount:          len(contents) + len(prefixes),
+			MaxKeys:           maxKeys,
+			EncodingType:      encodingType,
+			IsTruncated:       page.truncated,
+			Contents:          contents,
+			CommonPrefixes:    prefixes,
+		}
+		if page.truncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(page.next))
+		}
+		writeXML(w, http.StatusOK, result)
+		return nil
+	}
+
+	result := struct {
+		XMLName        xml.Name       `xml:"ListBucketResult"`
+		Xmlns          string         `xml:"xmlns,attr"`
+		Name           string         `xml:"Name"`
+		Prefix         string         `xml:"Prefix"`
+		Marker         string         `xml:"Marker"`
+		NextMarker     string         `xml:"NextMarker,omitempty"`
+		Delimiter      string         `xml:"Delimiter,omitempty"`
+		MaxKeys        int            `xml:"MaxKeys"`
+		EncodingType   string         `xml:"EncodingType,omitempty"`
+		IsTruncated    bool           `xml:"IsTruncated"`
+		Contents       []listObject   `xml:"Contents"`
+		CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+	}{
+		Xmlns:          s3Namespace,
+		Name:           g.config.Bucket,
+		Prefix:         encode(prefix),
+		Marker:         encode(after),
+		Delimiter:      encode(delimiter),
+		MaxKeys:        maxKeys,
+		EncodingType:   encodingType,
+		IsTruncated:    page.truncated,
+		Contents:       contents,
+		CommonPrefixes: prefixes,
+	}
+	if page.truncated {
+		result.NextMarker = encode(page.next)
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// serveObject handles requests for an object
+func (g *Gateway) serveObject(w http.ResponseWriter, r *http.Request, key string) error {
+	query := r.URL.Query()
+	if query.Has("uploads") || query.Has("uploadId") {
+		return errMultipartNotImplemented
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return g.getObject(w, r, key)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return g.copyObject(w, r, key)
+		}
+		return g.putObject(w, r, key)
+	case http.MethodDelete:
+		if g.removeObject(key) {
+			if err := g.index.SaveIndex(); err != nil {
+				return err
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return errMethodNotAllowed
+	}
+}
+
+// getObject answers GetObject and HeadObject, including Range and
+// conditional requests
+func (g *Gateway) getObject(w http.ResponseWriter, r *http.Request, key string) error {
+	entry, ok := g.index.GetFile(key)
+	if !ok || entry.Type == fuse.DirectoryEntryType {
+		return errNoSuchKey
+	}
+	if entry.Encrypted {
+		return newError(http.StatusForbidden, "AccessDenied", "The object's descriptor is password-protected")
+	}
+
+	header := w.Header()
+	header.Set("ETag", etag(entry))
+	header.Set("Content-Type", entry.MimeType)
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+	}
+	header.Set("Accept-Ranges", "bytes")
+
+	// HEAD only needs the index entry; ServeContent seeks but never reads
+	// its contents
+	var content io.ReadSeeker = &sizedSeeker{size: entry.FileSize}
+	if r.Method == http.MethodGet && entry.DescriptorCID != "" {
+		file, err := g.client.DownloadFile(r.Context(), entry.DescriptorCID, "", nil)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve %s: %w", key, err)
+		}
+		content = file.Reader
+	}
+	http.ServeContent(w, r, "", entry.ModifiedAt, content)
+	return nil
+}
+
+// putObject uploads the request body and records it under key
+func (g *Gateway) putObject(w http.ResponseWriter, r *http.Request, key string) error {
+	if strings.HasSuffix(key, "/") && r.ContentLength == 0 {
+		// Folder markers some tools create; the index has no empty folders
+		w.Header().Set("ETag", `"`+hex.EncodeToString(md5.New().Sum(nil))+`"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	sha := sha256.New()
+	sum := md5.New()
+	counter := &countingWriter{}
+	body := bufio.NewReader(io.TeeReader(r.Body, io.MultiWriter(sha, sum, counter)))
+
+	// Descriptors can't describe empty files, which the index records
+	// without one, as the FUSE mount does
+	descriptorCID := ""
+	_, err := body.Peek(1)
+	if err == nil {
+		descriptorCID, err = g.client.Upload(r.Context(), body, path.Base(key))
+	} else if err == io.EOF {
+		err = nil
+	}
+	if v, ok := r.Body.(verifyingBody); ok && v.verifyErr() != nil {
+		return v.verifyErr()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	if want := r.Header.Get("Content-Md5"); want != "" && want != base64.StdEncoding.EncodeToString(sum.Sum(nil)) {
+		return errBadDigest
+	}
+
+	meta := fuse.FileMetadata{ContentHash: hex.EncodeToString(sha.Sum(nil)), MimeType: requestContentType(r)}
+	g.index.AddFileWithMetadata(key, descriptorCID, counter.n, meta)
+	if err := g.index.SaveIndex(); err != nil {
+		return err
+	}
+
+	entry, _ := g.index.GetFile(key)
+	w.Header().Set("ETag", etag(entry))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyObject records the descriptor of the copy source under key too; the
+// content is already stored, so nothing is uploaded
+func (g *Gateway) copyObject(w http.ResponseWriter, r *http.Request, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key")
+	}
+	source, _, _ = strings.Cut(source, "?versionId=")
+	bucket, sourceKey, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok || sourceKey == "" {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key")
+	}
+	if bucket != g.config.Bucket {
+		return errNoSuchBucket
+	}
+	src, ok := g.index.GetFile(sourceKey)
+	if !ok || src.Type == fuse.DirectoryEntryType {
+		return errNoSuchKey
+	}
+
+	meta := fuse.FileMetadata{
+		Tags:        src.Tags,
+		ContentHash: src.ContentHash,
+		Encrypted:   src.Encrypted,
+		MimeType:    src.MimeType,
+	}
+	if strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		meta.MimeType = requestContentType(r)
+	}
+	g.index.AddFileWithMetadata(key, src.DescriptorCID, src.FileSize, meta)
+	if err := g.index.SaveIndex(); err != nil {
+		return err
+	}
+
+	entry, _ := g.index.GetFile(key)
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		Xmlns        string   `xml:"xmlns,attr"`
+		LastModified string   `xml:"LastModified"`
+		ETag         string   `xml:"ETag"`
+	}{Xmlns: s3Namespace, LastModified: formatTime(entry.ModifiedAt), ETag: etag(entry)})
+	return nil
+}
+
+// deleteObjects answers DeleteObjects, which removes many keys at once
+func (g *Gateway) deleteObjects(w http.ResponseWriter, r *http.Request) error {
+	var request struct {
+		Quiet   bool `xml:"Quiet"`
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxDeleteRequestSize))
+	if err != nil {
+		if v, ok := r.Body.(verifyingBody); ok && v.verifyErr() != nil {
+			return v.verifyErr()
+		}
+		return err
+	}
+	if err := xml.Unmarshal(data, &request); err != nil || len(request.Objects) > maxListKeys {
+		return errMalformedXML
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		Xmlns   string    `xml:"xmlns,attr"`
+		Deleted []deleted `xml:"Deleted"`
+	}{Xmlns: s3Namespace}
+
+	changed := false
+	for _, object := range request.Objects {
+		if g.removeObject(object.Key) {
+			changed = true
+		}
+		// Deleting a missing key succeeds, as in S3
+		if !request.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+		}
+	}
+	if changed {
+		if err := g.index.SaveIndex(); err != nil {
+			return err
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// removeObject removes key from the index, leaving directory entries of
+// the FUSE mount alone, and reports whether it was there
+func (g *Gateway) removeObject(key string) bool {
+	entry, ok := g.index.GetFile(key)
+	if !ok || entry.Type == fuse.DirectoryEntryType {
+		return false
+	}
+	return g.index.RemoveFile(key)
+}
+
+// validateKey rejects keys that can't be index paths, which the FUSE mount
+// shows as files
+func validateKey(key string) error {
+	invalid := func(reason string) error {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Invalid object key: "+reason)
+	}
+	if strings.ContainsAny(key, "\\\x00") {
+		return invalid("backslashes and NUL characters are not allowed")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return invalid("keys cannot have empty, '.' or '..' path segments")
+		}
+	}
+	if strings.Contains(path.Base(key), "..") {
+		return invalid("object names cannot contain '..'")
+	}
+	return nil
+}
+
+// requestContentType returns the content type the client gave an object,
+// or "" to have the index guess it from the name
+func requestContentType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "binary/octet-stream" {
+		return "" // The default of AWS SDKs, not a real type
+	}
+	return contentType
+}
+
+// etag returns an entry's entity tag: its content hash or, for files
+// recorded without one, its descriptor CID
+func etag(entry *fuse.IndexEntry) string {
+	if entry.ContentHash != "" {
+		return `"` + entry.ContentHash + `"`
+	}
+	return `"` + entry.DescriptorCID + `"`
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return strings.ToUpper(hex.EncodeToString(id))
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// sizedSeeker stands in for an object's contents in HEAD responses
+type sizedSeeker struct {
+	size   int64
+	offset int64
+}
+
+func (s *sizedSeeker) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (s *sizedSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	s.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,289 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+// newTestGateway returns a gateway over a mock storage backend and a new
+// index
+func newTestGateway(t *testing.T) (*Gateway, *fuse.FileIndex) {
+	t.Helper()
+	backend, err := backends.NewMockBackend("s3-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageConfig := storage.DefaultConfig()
+	storageConfig.Backends = map[string]*storage.BackendConfig{
+		storage.BackendTypeCustom: storage.CustomBackendConfig(backend),
+	}
+	storageConfig.DefaultBackend = storage.BackendTypeCustom
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storageManager.Stop(context.Background()) })
+
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := fuse.NewFileIndex(filepath.Join(t.TempDir(), "index.json"))
+	g, err := New(client, index, Config{Bucket: "noisefs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, index
+}
+
+func do(g *Gateway, method, target string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	for name, value := range header {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+func put(t *testing.T, g *Gateway, key, content string) {
+	t.Helper()
+	if w := do(g, http.MethodPut, "/noisefs/"+(&url.URL{Path: key}).EscapedPath(), strings.NewReader(content), nil); w.Code != http.StatusOK {
+		t.Fatalf("PUT %s: %d %s", key, w.Code, w.Body)
+	}
+}
+
+func TestPutGetObject(t *testing.T) {
+	g, index := newTestGateway(t)
+	content := strings.Repeat("0123456789", 10000)
+
+	w := do(g, http.MethodPut, "/noisefs/docs/numbers.txt", strings.NewReader(content), map[string]string{"Content-Type": "text/plain"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: %d %s", w.Code, w.Body)
+	}
+	entry, ok := index.GetFile("docs/numbers.txt")
+	if !ok || entry.FileSize != int64(len(content)) || entry.MimeType != "text/plain" {
+		t.Fatalf("unexpected index entry %+v", entry)
+	}
+	if w.Header().Get("ETag") != `"`+entry.ContentHash+`"` {
+		t.Errorf("ETag %s, want the content hash", w.Header().Get("ETag"))
+	}
+
+	w = do(g, http.MethodGet, "/noisefs/docs/numbers.txt", nil, nil)
+	if w.Code != http.StatusOK || w.Body.String() != content {
+		t.Fatalf("GET: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Content-Type %q, want text/plain", w.Header().Get("Content-Type"))
+	}
+
+	w = do(g, http.MethodGet, "/noisefs/docs/numbers.txt", nil, map[string]string{"Range": "bytes=5-14"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "5678901234" {
+		t.Errorf("ranged GET: %d %q", w.Code, w.Body)
+	}
+
+	w = do(g, http.MethodHead, "/noisefs/docs/numbers.txt", nil, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Length") != "100000" || w.Body.Len() != 0 {
+		t.Errorf("HEAD: %d, Content-Length %s", w.Code, w.Header().Get("Content-Length"))
+	}
+
+	// Empty objects are indexed without a descriptor
+	put(t, g, "empty.txt", "")
+	if w = do(g, http.MethodGet, "/noisefs/empty.txt", nil, nil); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("GET of an empty object: %d, %d bytes", w.Code, w.Body.Len())
+	}
+
+	if w = do(g, http.MethodGet, "/noisefs/missing.txt", nil, nil); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchKey") {
+		t.Errorf("missing key: %d %s", w.Code, w.Body)
+	}
+	if w = do(g, http.MethodGet, "/other/docs/numbers.txt", nil, nil); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "NoSuchBucket") {
+		t.Errorf("other bucket: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPutRejects(t *testing.T) {
+	g, index := newTestGateway(t)
+
+	for _, key := range []string{"a//b.txt", "a/../b.txt", "a/./b.txt", `a\b.txt`} {
+		if w := do(g, http.MethodPut, "/noisefs/"+key, strings.NewReader("x"), nil); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: %d, want 400", key, w.Code)
+		}
+	}
+	if w := do(g, http.MethodPut, "/noisefs/x.txt", strings.NewReader("x"), map[string]string{"Content-MD5": "AAAAAAAAAAAAAAAAAAAAAA=="}); !strings.Contains(w.Body.String(), "BadDigest") {
+		t.Errorf("wrong Content-MD5: %d %s", w.Code, w.Body)
+	}
+	if w := do(g, http.MethodPost, "/noisefs/big.bin?uploads", nil, nil); w.Code != http.StatusNotImplemented {
+		t.Errorf("multipart upload: %d, want 501", w.Code)
+	}
+	if index.GetSize() != 0 {
+		t.Errorf("rejected uploads were indexed: %v", index.ListFiles())
+	}
+
+	// Folder markers are accepted but not indexed
+	if w := do(g, http.MethodPut, "/noisefs/photos/", strings.NewReader(""), nil); w.Code != http.StatusOK || index.GetSize() != 0 {
+		t.Errorf("folder marker: %d, %d entries", w.Code, index.GetSize())
+	}
+}
+
+type listResult struct {
+	Keys                  []string `xml:"Contents>Key"`
+	Prefixes              []string `xml:"CommonPrefixes>Prefix"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+	NextMarker            string   `xml:"NextMarker"`
+}
+
+func list(t *testing.T, g *Gateway, query string) listResult {
+	t.Helper()
+	w := do(g, http.MethodGet, "/noisefs?"+query, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list %s: %d %s", query, w.Code, w.Body)
+	}
+	var result listResult
+	if err := xml.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestListObjects(t *testing.T) {
+	g, index := newTestGateway(t)
+	for _, key := range []string{"a.txt", "b/1.txt", "b/2.txt", "b/c/3.txt", "d e.txt"} {
+		put(t, g, key, key)
+	}
+	// Directories of the FUSE mount aren't objects
+	index.AddDirectory("b/c", "dir-cid", "")
+
+	all := list(t, g, "list-type=2")
+	if strings.Join(all.Keys, ",") != "a.txt,b/1.txt,b/2.txt,b/c/3.txt,d e.txt" || all.IsTruncated {
+		t.Errorf("unexpected full listing %+v", all)
+	}
+
+	top := list(t, g, "list-type=2&delimiter=/")
+	if strings.Join(top.Keys, ",") != "a.txt,d e.txt" || strings.Join(top.Prefixes, ",") != "b/" {
+		t.Errorf("unexpected top-level listing %+v", top)
+	}
+	sub := list(t, g, "list-type=2&delimiter=/&prefix=b/")
+	if strings.Join(sub.Keys, ",") != "b/1.txt,b/2.txt" || strings.Join(sub.Prefixes, ",") != "b/c/" {
+		t.Errorf("unexpected listing of b/ %+v", sub)
+	}
+
+	// Paging visits every key once, with common prefixes counted as keys
+	var seen []string
+	query := "list-type=2&delimiter=/&max-keys=1"
+	for i := 0; i < 5; i++ {
+		page := list(t, g, query)
+		seen = append(seen, page.Keys...)
+		seen = append(seen, page.Prefixes...)
+		if !page.IsTruncated {
+			break
+		}
+		query = "list-type=2&delimiter=/&max-keys=1&continuation-token=" + page.NextContinuationToken
+	}
+	if strings.Join(seen, ",") != "a.txt,b/,d e.txt" {
+		t.Errorf("paged V2 listing returned %v", seen)
+	}
+
+	v1 := list(t, g, "max-keys=2")
+	if strings.Join(v1.Keys, ",") != "a.txt,b/1.txt" || !v1.IsTruncated || v1.NextMarker != "b/1.txt" {
+		t.Errorf("unexpected V1 page %+v", v1)
+	}
+	v1 = list(t, g, "marker=b/1.txt&max-keys=2")
+	if strings.Join(v1.Keys, ",") != "b/2.txt,b/c/3.txt" {
+		t.Errorf("unexpected V1 page after marker %+v", v1)
+	}
+
+	encoded := list(t, g, "list-type=2&prefix=d&encoding-type=url")
+	if strings.Join(encoded.Keys, ",") != "d+e.txt" {
+		t.Errorf("URL-encoded listing returned %v", encoded.Keys)
+	}
+
+	if w := do(g, http.MethodGet, "/noisefs?acl", nil, nil); w.Code != http.StatusNotImplemented {
+		t.Errorf("unsupported subresource: %d, want 501", w.Code)
+	}
+}
+
+func TestCopyAndDeleteObjects(t *testing.T) {
+	g, index := newTestGateway(t)
+	put(t, g, "a.txt", "alpha")
+	put(t, g, "b.txt", "bravo")
+	index.AddDirectory("photos", "dir-cid", "")
+
+	w := do(g, http.MethodPut, "/noisefs/copies/a.txt", nil, map[string]string{"X-Amz-Copy-Source": "/noisefs/a.txt"})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "CopyObjectResult") {
+		t.Fatalf("copy: %d %s", w.Code, w.Body)
+	}
+	original, _ := index.GetFile("a.txt")
+	copied, ok := index.GetFile("copies/a.txt")
+	if !ok || copied.DescriptorCID != original.DescriptorCID {
+		t.Errorf("copy doesn't share the original's descriptor: %+v", copied)
+	}
+	if w = do(g, http.MethodGet, "/noisefs/copies/a.txt", nil, nil); w.Body.String() != "alpha" {
+		t.Errorf("GET copy returned %q", w.Body)
+	}
+
+	if w = do(g, http.MethodDelete, "/noisefs/a.txt", nil, nil); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: %d", w.Code)
+	}
+	if _, ok := index.GetFile("a.txt"); ok {
+		t.Error("deleted object still indexed")
+	}
+	if w = do(g, http.MethodDelete, "/noisefs/a.txt", nil, nil); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE of a missing key: %d, want 204", w.Code)
+	}
+
+	request := `<Delete><Object><Key>b.txt</Key></Object><Object><Key>copies/a.txt</Key></Object><Object><Key>photos</Key></Object></Delete>`
+	if w = do(g, http.MethodPost, "/noisefs?delete", strings.NewReader(request), nil); w.Code != http.StatusOK || strings.Count(w.Body.String(), "<Deleted>") != 3 {
+		t.Errorf("multi-object delete: %d %s", w.Code, w.Body)
+	}
+	if files := index.ListFiles(); len(files) != 1 || files["photos"] == nil {
+		t.Errorf("want only the directory entry left, got %v", files)
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	g, index := newTestGateway(t)
+	g.config.AccessKey, g.config.SecretKey = exampleAccessKey, exampleSecretKey
+
+	if w := do(g, http.MethodPut, "/noisefs/a.txt", strings.NewReader("alpha"), nil); w.Code != http.StatusForbidden {
+		t.Errorf("unsigned PUT: %d, want 403", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodPut, "/noisefs/a.txt", strings.NewReader("alpha"))
+	r.Header.Set("X-Amz-Content-Sha256", sha256Hex([]byte("tampered")))
+	signRequest(r, exampleAccessKey, exampleSecretKey, g.now().UTC().Format(amzDateFormat))
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "XAmzContentSHA256Mismatch") {
+		t.Errorf("PUT with a mismatched payload hash: %d %s", w.Code, w.Body)
+	}
+	if index.GetSize() != 0 {
+		t.Error("tampered upload was indexed")
+	}
+
+	r = httptest.NewRequest(http.MethodPut, "/noisefs/a.txt", bytes.NewReader([]byte("alpha")))
+	r.Header.Set("X-Amz-Content-Sha256", sha256Hex([]byte("alpha")))
+	signRequest(r, exampleAccessKey, exampleSecretKey, g.now().UTC().Format(amzDateFormat))
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("signed PUT: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,274 @@
+// Package auth manages the API tokens of NoiseFS servers and the roles
+// they grant.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token errors
+var (
+	ErrInvalidToken  = errors.New("invalid or expired API token")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// Role is what a token may do. Each role may do everything the ones before
+// it may.
+type Role string
+
+const (
+	// RoleViewer browses, searches and downloads
+	RoleViewer Role = "viewer"
+	// RoleUploader also uploads, announces, shares and queues transfers
+	RoleUploader Role = "uploader"
+	// RoleAdmin also changes what the node follows and manages tokens,
+	// subscriptions and takedowns
+	RoleAdmin Role = "admin"
+)
+
+// Roles lists the roles from least to most capable
+var Roles = []Role{RoleViewer, RoleUploader, RoleAdmin}
+
+// ParseRole returns the role named s
+func ParseRole(s string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == strings.ToLower(strings.TrimSpace(s)) {
+			return role, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q (use viewer, uploader or admin)", s)
+}
+
+// Allows reports whether the role may do what required may
+func (r Role) Allows(required Role) bool {
+	return r.rank() >= required.rank() && r.rank() > 0
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// tokenPrefix marks NoiseFS API tokens, so they are recognised in
+// configuration files and by secret scanners
+const tokenPrefix = "nfs_"
+
+// Token is an API token. Only a hash of its secret is stored; the secret
+// is shown once, when the token is created.
+type Token struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Role      Role       `json:"role"`
+	Hash      string     `json:"hash,omitempty"` // SHA-256 of the secret
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the token has expired at now
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// TokenStore keeps API tokens in a file. Every call re-reads the file, so
+// tokens created or revoked with the CLI apply to a running web UI.
+type TokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultTokenStorePath returns ~/.noisefs/tokens.json
+func DefaultTokenStorePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "tokens.json"), nil
+}
+
+// NewTokenStore returns the store kept at path
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Create makes a token with a role, valid for ttl or forever when ttl is 0.
+// It returns the token and its secret, which can't be recovered later.
+func (s *TokenStore) Create(name string, role Role, ttl time.Duration) (*Token, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("token name is required")
+	}
+	if role.rank() == 0 {
+		return nil, "", fmt.Errorf("unknown role %q (use viewer, uploader or admin)", role)
+	}
+	if ttl < 0 {
+		return nil, "", errors.New("token lifetime cannot be negative")
+	}
+
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := &Token{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Role:      role,
+		CreatedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expiresAt := token.CreatedAt.Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+	// The ID is part of the secret so Authenticate finds the token
+	// without comparing every hash
+	plain := tokenPrefix + token.ID + "_" + hex.EncodeToString(secret)
+	token.Hash = hashSecret(plain)
+
+	err := s.update(func(tokens map[string]*Token) error {
+		if _, exists := tokens[token.ID]; exists {
+			return fmt.Errorf("token %s already exists", token.ID)
+		}
+		tokenCopy := *token
+		tokens[token.ID] = &tokenCopy
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	token.Hash = ""
+	return token, plain, nil
+}
+
+// Authenticate returns the unexpired token a secret belongs to
+func (s *TokenStore) Authenticate(secret string) (*Token, error) {
+	id, ok := tokenID(secret)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[id]
+	if !ok || token.Expired(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.Hash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	token.Hash = ""
+	return token, nil
+}
+
+// Revoke deletes a token
+func (s *TokenStore) Revoke(id string) error {
+	return s.update(func(tokens map[string]*Token) error {
+		if _, ok := tokens[id]; !ok {
+			return ErrTokenNotFound
+		}
+		delete(tokens, id)
+		return nil
+	})
+}
+
+// List returns every token without its hash, oldest first
+func (s *TokenStore) List() ([]*Token, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Token, 0, len(tokens))
+	for _, token := range tokens {
+		token.Hash = ""
+		list = append(list, token)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, nil
+}
+
+// load reads the tokens, keyed by ID
+func (s *TokenStore) load() (map[string]*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *TokenStore) read() (map[string]*Token, error) {
+	tokens := make(map[string]*Token)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokens: %w", err)
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// update loads the tokens, applies fn and saves them if fn succeeds
+func (s *TokenStore) update(fn func(tokens map[string]*Token) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(tokens); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write tokens: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write tokens: %w", err)
+	}
+	return nil
+}
+
+// tokenID extracts the token ID from a secret
+func tokenID(secret string) (string, bool) {
+	rest, ok := strings.CutPrefix(secret, tokenPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && id != ""
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
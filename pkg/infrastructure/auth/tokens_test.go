@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleUploader, false},
+		{RoleUploader, RoleViewer, true},
+		{RoleUploader, RoleAdmin, false},
+		{RoleAdmin, RoleUploader, true},
+		{Role("root"), RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+
+	if role, err := ParseRole(" Uploader "); err != nil || role != RoleUploader {
+		t.Errorf("ParseRole returned %q, %v", role, err)
+	}
+	if _, err := ParseRole("root"); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store := NewTokenStore(path)
+
+	if _, _, err := store.Create("", RoleViewer, 0); err == nil {
+		t.Error("Expected a token without a name to be rejected")
+	}
+	if _, _, err := store.Create("ci", Role("root"), 0); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+
+	token, secret, err := store.Create("ci", RoleUploader, 0)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, "nfs_"+token.ID+"_") || token.Hash != "" {
+		t.Errorf("Unexpected token %+v with secret %q", token, secret)
+	}
+
+	// A second store on the same file sees the token, as the web UI does
+	// for tokens created with the CLI
+	other := NewTokenStore(path)
+	found, err := other.Authenticate(secret)
+	if err != nil || found.ID != token.ID || found.Role != RoleUploader {
+		t.Fatalf("Authenticate returned %+v, %v", found, err)
+	}
+	for _, wrong := range []string{"", "nfs_", secret + "0", strings.Replace(secret, token.ID, "000000000000", 1)} {
+		if _, err := other.Authenticate(wrong); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Expected %q to be rejected, got %v", wrong, err)
+		}
+	}
+
+	expiring, expiringSecret, err := store.Create("short", RoleViewer, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := store.Authenticate(expiringSecret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected an expired token to be rejected, got %v", err)
+	}
+
+	tokens, err := store.List()
+	if err != nil || len(tokens) != 2 || tokens[0].ID != token.ID || tokens[1].ID != expiring.ID {
+		t.Fatalf("List returned %+v, %v", tokens, err)
+	}
+	for _, listed := range tokens {
+		if listed.Hash != "" {
+			t.Error("Expected List to leave out hashes")
+		}
+	}
+
+	if err := store.Revoke(token.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := other.Authenticate(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected a revoked token to be rejected, got %v", err)
+	}
+	if err := store.Revoke(token.ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("Expected revoking twice to fail with ErrTokenNotFound, got %v", err)
+	}
+}
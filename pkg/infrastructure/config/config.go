@@ -1,12 +1,25 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/network/proxy"
+	"github.com/TheEntropyCollective/noisefs/pkg/privacy/cover"
+	"github.com/TheEntropyCollective/noisefs/pkg/privacy/mixing"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // Config holds all NoiseFS configuration
@@ -28,15 +41,180 @@ type Config struct {
 	
 	// Network anonymization
 	Network NetworkConfig `json:"network"`
+
+	// Privacy preset and announcement privacy
+	Privacy PrivacyConfig `json:"privacy"`
+
+	// Background fetching of popular blocks to mask real transfers
+	CoverTraffic CoverTrafficConfig `json:"cover_traffic"`
+
+	// Randomized ordering and timing of download block fetches
+	RetrievalMixing RetrievalMixingConfig `json:"retrieval_mixing"`
+
+	// Directory snapshot retention
+	Snapshots SnapshotConfig `json:"snapshots"`
+
+	// Web interface
+	WebUI WebUIConfig `json:"webui"`
+
+	// S3-compatible gateway
+	S3Gateway S3GatewayConfig `json:"s3_gateway"`
+
+	// HTTP/JSON protocol for sync tool backends
+	Remote RemoteConfig `json:"remote"`
+
+	// Long-running process control
+	Daemon DaemonConfig `json:"daemon"`
+
+	// Endpoints notified of uploads, announcements and syncs
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"`
+
+	// Content processors run on uploads and downloads, in order
+	Processors []ProcessorConfig `json:"processors,omitempty"`
+
+	// Block size selection for uploads
+	Blocks BlockConfig `json:"blocks"`
+
+	// Skipping uploads of files uploaded before
+	Dedup DedupConfig `json:"dedup"`
+
+	// Offline spooling of uploads while storage is unreachable
+	Offline OfflineConfig `json:"offline"`
+
+	// Anonymous usage reports, off unless enabled
+	Telemetry TelemetryConfig `json:"telemetry"`
+
+	// Release channel and signing key for noisefs selfupdate
+	Update UpdateConfig `json:"update"`
 	
 	// Backward compatibility: computed performance config
 	Performance PerformanceConfig `json:"-"` // Not serialized, computed on demand
+
+	// Secret references resolved at load time, keyed by "section.key"
+	secretRefs map[string]string
 }
 
 // IPFSConfig holds IPFS connection settings
 type IPFSConfig struct {
 	APIEndpoint string `json:"api_endpoint"`
 	Timeout     int    `json:"timeout_seconds"`
+
+	// Authentication for remote API endpoints: a Bearer token, or a
+	// username and password for Basic auth. Headers are sent with every
+	// request and can carry gateway-specific keys.
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	// TLS for https endpoints: a CA bundle for self-signed servers and a
+	// client certificate for mutual TLS
+	TLSCAFile             string `json:"tls_ca_file,omitempty"`
+	TLSCertFile           string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile            string `json:"tls_key_file,omitempty"`
+	TLSInsecureSkipVerify bool   `json:"tls_insecure_skip_verify,omitempty"`
+
+	// DisablePeerSelection fetches blocks without first connecting the
+	// daemon to the providers that served earlier requests best
+	DisablePeerSelection bool `json:"disable_peer_selection,omitempty"`
+
+	// Mode selects how NoiseFS reaches IPFS: "daemon" (the default) uses
+	// the API endpoint above, "embedded" runs an IPFS node in-process
+	Mode     string             `json:"mode,omitempty"`
+	Embedded EmbeddedIPFSConfig `json:"embedded"`
+}
+
+// IPFS modes
+const (
+	IPFSModeDaemon   = "daemon"
+	IPFSModeEmbedded = "embedded"
+)
+
+// EmbeddedIPFSConfig holds settings for the in-process IPFS node
+type EmbeddedIPFSConfig struct {
+	RepoPath    string   `json:"repo_path,omitempty"` // Defaults to ~/.noisefs/embedded
+	ListenAddrs []string `json:"listen_addrs,omitempty"`
+	// Peers to join the network through. Unset uses the public IPFS
+	// bootstrap nodes; an empty list starts without any.
+	BootstrapPeers []string `json:"bootstrap_peers"`
+
+	// Provider announcements on the DHT: which blocks to announce ("all",
+	// "randomizers" or "none"), at most ProvideRate per minute (0 for no
+	// limit), repeated every ReprovideIntervalHours (0 to announce once)
+	ProvideStrategy        string `json:"provide_strategy,omitempty"`
+	ProvideRate            int    `json:"provide_rate,omitempty"`
+	ReprovideIntervalHours int    `json:"reprovide_interval_hours"`
+}
+
+// Connection returns the storage connection settings for the IPFS API,
+// starting from the storage defaults
+func (c *IPFSConfig) Connection() *storage.ConnectionConfig {
+	conn := *storage.DefaultConfig().Backends["ipfs"].Connection
+	conn.Endpoint = c.APIEndpoint
+
+	switch {
+	case c.Token != "":
+		conn.Auth = &storage.AuthConfig{Type: "bearer", Token: c.Token, Headers: c.Headers}
+	case c.Username != "":
+		conn.Auth = &storage.AuthConfig{Type: "basic", Username: c.Username, Password: c.Password, Headers: c.Headers}
+	case len(c.Headers) > 0:
+		conn.Auth = &storage.AuthConfig{Type: "none", Headers: c.Headers}
+	}
+
+	if strings.HasPrefix(c.APIEndpoint, "https://") || c.TLSCAFile != "" || c.TLSCertFile != "" || c.TLSInsecureSkipVerify {
+		conn.TLS = &storage.TLSConfig{
+			Enabled:            true,
+			CAFile:             c.TLSCAFile,
+			CertFile:           c.TLSCertFile,
+			KeyFile:            c.TLSKeyFile,
+			InsecureSkipVerify: c.TLSInsecureSkipVerify,
+		}
+	}
+	return &conn
+}
+
+// IPFSConnection returns the IPFS API connection settings, routed through
+// the network proxy when one is configured
+func (c *Config) IPFSConnection() *storage.ConnectionConfig {
+	conn := c.IPFS.Connection()
+	conn.Proxy = c.Network.Proxy
+	return conn
+}
+
+// StorageConfig returns the storage settings for the IPFS mode: the IPFS
+// backend talking to the daemon, or an embedded node
+func (c *Config) StorageConfig() *storage.Config {
+	storageConfig := storage.DefaultConfig()
+	if c.Offline.Enabled {
+		storageConfig.Offline = &storage.OfflineConfig{
+			Enabled:       true,
+			SpoolPath:     c.Offline.SpoolPath,
+			MaxSize:       int64(c.Offline.MaxSizeMB) * 1024 * 1024,
+			RetryInterval: time.Duration(c.Offline.RetryIntervalSeconds) * time.Second,
+		}
+	}
+	if c.IPFS.Mode != IPFSModeEmbedded {
+		ipfs := storageConfig.Backends[storage.BackendTypeIPFS]
+		ipfs.Connection = c.IPFSConnection()
+		if c.IPFS.DisablePeerSelection {
+			ipfs.Settings[storage.IPFSSettingPeerSelection] = false
+		}
+		return storageConfig
+	}
+
+	embedded := storage.DefaultEmbeddedBackendConfig(c.IPFS.Embedded.RepoPath)
+	if c.IPFS.Embedded.ListenAddrs != nil {
+		embedded.Settings[storage.EmbeddedSettingListenAddrs] = c.IPFS.Embedded.ListenAddrs
+	}
+	if c.IPFS.Embedded.BootstrapPeers != nil {
+		embedded.Settings[storage.EmbeddedSettingBootstrapPeers] = c.IPFS.Embedded.BootstrapPeers
+	}
+	embedded.Settings[storage.EmbeddedSettingProvideStrategy] = c.IPFS.Embedded.ProvideStrategy
+	embedded.Settings[storage.EmbeddedSettingProvideRate] = c.IPFS.Embedded.ProvideRate
+	embedded.Settings[storage.EmbeddedSettingReprovideInterval] = time.Duration(c.IPFS.Embedded.ReprovideIntervalHours) * time.Hour
+	storageConfig.Backends = map[string]*storage.BackendConfig{storage.BackendTypeEmbedded: embedded}
+	storageConfig.DefaultBackend = storage.BackendTypeEmbedded
+	return storageConfig
 }
 
 // CacheConfig holds cache and memory settings
@@ -54,6 +232,10 @@ type FUSEConfig struct {
 	MountPath string `json:"mount_path"`
 	IndexPath string `json:"index_path"`
 	ReadOnly  bool   `json:"read_only"`
+
+	// Address noisefs-mount serves Prometheus metrics at, under /metrics,
+	// e.g. "127.0.0.1:9464". Empty disables it.
+	MetricsAddress string `json:"metrics_address,omitempty"`
 	
 	// Computed fields for backward compatibility
 	Debug bool `json:"-"` // Computed: true when logging level is "debug"
@@ -65,6 +247,14 @@ type LoggingConfig struct {
 	Output string `json:"output"` // console, file
 	File   string `json:"file,omitempty"`
 	Format string `json:"-"`      // Computed: always "text" for simplicity
+
+	// Per-component level overrides, e.g. {"storage": "debug", "fuse": "warn"}
+	Components map[string]string `json:"components,omitempty"`
+
+	// Sampling of repeated messages: log the first SampleInitial identical
+	// messages each second, then every SampleThereafter-th. 0 disables sampling.
+	SampleInitial    int `json:"sample_initial,omitempty"`
+	SampleThereafter int `json:"sample_thereafter,omitempty"`
 }
 
 // SecurityConfig holds security settings
@@ -74,6 +264,31 @@ type SecurityConfig struct {
 	
 	// Password protection
 	RequirePassword bool `json:"require_password"`
+
+	// Upload content policy (JSON file with allowed types, size limits and forbidden extensions)
+	ContentPolicyFile string `json:"content_policy_file,omitempty"`
+
+	// Web UI rate limit counter store: "memory" (default), "redis://host:6379/0" or
+	// "bbolt:///path/ratelimit.db". Replicas sharing a Redis store enforce the same
+	// limits; a bbolt file keeps one process's counters across restarts.
+	RateLimitStore string `json:"rate_limit_store,omitempty"`
+
+	// Count requests authenticated with an API token per token instead of
+	// per client IP. Needs webui.require_tokens.
+	RateLimitByToken bool `json:"rate_limit_by_token,omitempty"`
+
+	// Audit log of security-relevant events (hash-chained JSONL, rotated by size)
+	AuditLog          bool   `json:"audit_log"`
+	AuditLogFile      string `json:"audit_log_file,omitempty"`
+	AuditLogMaxSizeMB int    `json:"audit_log_max_size_mb,omitempty"`
+	AuditLogMaxFiles  int    `json:"audit_log_max_files,omitempty"`
+
+	// Password for the encrypted local index. Use a secret reference such as
+	// "secret://keyring/index-password" rather than plaintext.
+	IndexPassword string `json:"index_password,omitempty"`
+
+	// Command run for secret://command/<name> references, with the name appended
+	SecretCommand string `json:"secret_command,omitempty"`
 	
 	// Computed fields for backward compatibility
 	DefaultEncrypted   bool `json:"-"` // Computed: follows EnableEncryption
@@ -87,11 +302,552 @@ type NetworkConfig struct {
 	// Tor anonymization
 	TorEnabled     bool   `json:"tor_enabled"`
 	TorSOCKSProxy  string `json:"tor_socks_proxy"`
+
+	// Proxy for outbound connections to the IPFS API and key services, e.g.
+	// "socks5h://127.0.0.1:9050" for Tor. Loopback addresses go direct.
+	Proxy string `json:"proxy,omitempty"`
 	
 	// Performance settings
-	MaxConcurrentOps int `json:"max_concurrent_ops"`
+	MaxConcurrentOps int    `json:"max_concurrent_ops"`
+	MaxBandwidth     string `json:"max_bandwidth,omitempty"` // Per-second transfer limit (e.g. "2MB"), empty for unlimited
+
+	// Ask a second storage backend for download blocks the first hasn't
+	// returned within this long (e.g. "750ms"), empty to only ask one
+	SpeculativeFetchDelay string `json:"speculative_fetch_delay,omitempty"`
+}
+
+// MaxBandwidthBytes returns the transfer limit in bytes per second (0 when unlimited)
+func (n NetworkConfig) MaxBandwidthBytes() (int64, error) {
+	if strings.TrimSpace(n.MaxBandwidth) == "" {
+		return 0, nil
+	}
+	return util.ParseSize(n.MaxBandwidth)
+}
+
+// SpeculativeFetchDelayDuration returns how long a block may take before a
+// second backend is asked for it (0 when never)
+func (n NetworkConfig) SpeculativeFetchDelayDuration() (time.Duration, error) {
+	if strings.TrimSpace(n.SpeculativeFetchDelay) == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(n.SpeculativeFetchDelay)
+	if err != nil {
+		return 0, err
+	}
+	if delay <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return delay, nil
+}
+
+// PrivacyConfig selects a privacy preset and holds the announcement
+// settings presets control
+type PrivacyConfig struct {
+	// Preset sets every privacy-related setting for a threat model:
+	// "performance", "balanced" or "paranoid". Empty leaves each setting
+	// as configured.
+	Preset string `json:"preset,omitempty"`
+
+	AnnounceAutoTags bool `json:"announce_auto_tags"` // Add tags derived from the file to announcements
+	AnnounceRealtime bool `json:"announce_realtime"`  // Also publish announcements on PubSub, which carries this node's peer ID
+}
+
+// Privacy presets
+const (
+	PrivacyPresetPerformance = "performance"
+	PrivacyPresetBalanced    = "balanced"
+	PrivacyPresetParanoid    = "paranoid"
+)
+
+// PrivacyPresets lists the available privacy presets, least private first
+var PrivacyPresets = []string{PrivacyPresetPerformance, PrivacyPresetBalanced, PrivacyPresetParanoid}
+
+// defaultTorProxy is the proxy the paranoid preset uses when none is set
+const defaultTorProxy = "socks5h://127.0.0.1:9050"
+
+// applyPrivacyPreset overwrites the settings the configured privacy preset
+// controls. Unknown presets are left for Validate to reject.
+func (c *Config) applyPrivacyPreset() {
+	switch c.Privacy.Preset {
+	case PrivacyPresetPerformance:
+		c.CoverTraffic.Enabled = false
+		c.RetrievalMixing.Enabled = false
+		c.IPFS.DisablePeerSelection = false
+		c.IPFS.Embedded.ProvideStrategy = storage.ProvideStrategyAll
+		c.Privacy.AnnounceAutoTags = true
+		c.Privacy.AnnounceRealtime = true
+		c.Dedup.Blocks = true
+	case PrivacyPresetBalanced:
+		c.CoverTraffic.Enabled = true
+		c.CoverTraffic.Bandwidth = "64KB"
+		c.RetrievalMixing.Enabled = false
+		c.IPFS.DisablePeerSelection = false
+		c.IPFS.Embedded.ProvideStrategy = storage.ProvideStrategyRandomizers
+		c.Privacy.AnnounceAutoTags = true
+		c.Privacy.AnnounceRealtime = false
+		c.Dedup.Blocks = true
+	case PrivacyPresetParanoid:
+		c.CoverTraffic.Enabled = true
+		c.CoverTraffic.Bandwidth = "256KB"
+		c.RetrievalMixing.Enabled = true
+		// Connecting to chosen providers makes fetches easier to link
+		c.IPFS.DisablePeerSelection = true
+		c.IPFS.Embedded.ProvideStrategy = storage.ProvideStrategyNone
+		c.Privacy.AnnounceAutoTags = false
+		c.Privacy.AnnounceRealtime = false
+		// Shared data blocks link the files they occur in
+		c.Dedup.Blocks = false
+		// The embedded node's peer connections can't be proxied
+		if c.Network.Proxy == "" && c.IPFS.Mode != IPFSModeEmbedded {
+			c.Network.Proxy = defaultTorProxy
+		}
+	}
+}
+
+// CoverTrafficConfig controls cover traffic, random popular blocks fetched
+// in the background so a node's transfers correlate less with its user's
+// own uploads and downloads. It costs bandwidth and, with StoreBlocks, disk.
+type CoverTrafficConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Bandwidth      string `json:"bandwidth"`              // Per-second limit such as "64KB", empty for unlimited
+	Interval       int    `json:"interval_seconds"`       // Average time between rounds
+	BlocksPerRound int    `json:"blocks_per_round"`       // Blocks fetched per round
+	ActiveHours    string `json:"active_hours,omitempty"` // Local time window such as "08:00-23:00", empty for always
+	StoreBlocks    bool   `json:"store_blocks"`           // Keep fetched blocks, so the node also serves them
+}
+
+// GeneratorConfig returns the cover traffic generator settings described by
+// the configuration
+func (c CoverTrafficConfig) GeneratorConfig() (cover.Config, error) {
+	var bandwidth int64
+	if strings.TrimSpace(c.Bandwidth) != "" {
+		var err error
+		if bandwidth, err = util.ParseSize(c.Bandwidth); err != nil {
+			return cover.Config{}, fmt.Errorf("invalid bandwidth '%s': %w", c.Bandwidth, err)
+		}
+	}
+	activeHours, err := cover.ParseActiveHours(c.ActiveHours)
+	if err != nil {
+		return cover.Config{}, err
+	}
+	generatorConfig := cover.Config{
+		Interval:       time.Duration(c.Interval) * time.Second,
+		BlocksPerRound: c.BlocksPerRound,
+		Bandwidth:      bandwidth,
+		ActiveHours:    activeHours,
+		StoreBlocks:    c.StoreBlocks,
+	}
+	return generatorConfig, generatorConfig.Validate()
+}
+
+// RetrievalMixingConfig controls how download block fetches are mixed: the
+// blocks of parallel downloads are batched together, fetched in random order
+// and delayed by random jitter, which slows downloads
+type RetrievalMixingConfig struct {
+	Enabled       bool `json:"enabled"`
+	MinDelayMs    int  `json:"min_delay_ms"`    // Shortest pause before each fetch
+	MaxDelayMs    int  `json:"max_delay_ms"`    // Longest pause before each fetch
+	BatchSize     int  `json:"batch_size"`      // Requests that send a batch without waiting
+	BatchWindowMs int  `json:"batch_window_ms"` // How long a batch waits for other downloads
+	Parallelism   int  `json:"parallelism"`     // Fetches in flight at once
+}
+
+// SchedulerConfig returns the mixing scheduler settings described by the
+// configuration
+func (c RetrievalMixingConfig) SchedulerConfig() (mixing.Config, error) {
+	schedulerConfig := mixing.Config{
+		MinDelay:    time.Duration(c.MinDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(c.MaxDelayMs) * time.Millisecond,
+		BatchSize:   c.BatchSize,
+		BatchWindow: time.Duration(c.BatchWindowMs) * time.Millisecond,
+		Parallelism: c.Parallelism,
+	}
+	return schedulerConfig, schedulerConfig.Validate()
+}
+
+// SnapshotConfig holds the retention policy applied by list-snapshots and prune-snapshots.
+// A value of zero disables that rule; when every rule is zero all snapshots are kept.
+type SnapshotConfig struct {
+	KeepLast   int `json:"keep_last"`   // Always keep the N most recent snapshots
+	KeepDaily  int `json:"keep_daily"`  // Keep the newest snapshot for each of the last N days with snapshots
+	KeepWeekly int `json:"keep_weekly"` // Keep the newest snapshot for each of the last N weeks with snapshots
+}
+
+// WebUIConfig holds settings for the noisefs-webui server
+type WebUIConfig struct {
+	Address      string `json:"address"`               // HTTP listen address
+	DataDir      string `json:"data_dir"`              // Announcement store directory
+	PollInterval int    `json:"poll_interval_seconds"` // DHT announcement poll interval
+	TLS          bool   `json:"tls"`                   // Serve HTTPS (self-signed unless cert and key are set)
+	CertFile     string `json:"cert_file,omitempty"`
+	KeyFile      string `json:"key_file,omitempty"`
+
+	// Certificates from an ACME CA such as Let's Encrypt, renewed automatically.
+	// Implies TLS; challenges are answered via TLS-ALPN on the web UI address
+	// and, when ACMEHTTPAddress is set (usually ":80"), via HTTP-01.
+	ACME             bool     `json:"acme,omitempty"`
+	ACMEDomains      []string `json:"acme_domains,omitempty"`
+	ACMEEmail        string   `json:"acme_email,omitempty"`
+	ACMECacheDir     string   `json:"acme_cache_dir,omitempty"`    // Default: ~/.noisefs/acme
+	ACMEHTTPAddress  string   `json:"acme_http_address,omitempty"` // HTTP-01 listener, also redirects to HTTPS
+	ACMEDirectoryURL string   `json:"acme_directory_url,omitempty"` // Default: Let's Encrypt production
+
+	// Bearer token for the /api/admin endpoints, which otherwise need an
+	// API token with the admin role. Use a secret reference such as
+	// "secret://env/NOISEFS_ADMIN_TOKEN".
+	AdminToken string `json:"admin_token,omitempty"`
+
+	// Require an API token, created with "noisefs tokens", for the whole
+	// API. Its role decides which endpoints it may use; the admin token
+	// counts as an admin.
+	RequireTokens bool `json:"require_tokens,omitempty"`
+
+	// Serve Prometheus metrics at /metrics
+	Metrics bool `json:"metrics"`
+
+	// Serve net/http/pprof profiles at /debug/pprof and runtime statistics
+	// at /debug/runtime, to the admin token or admin API tokens only
+	Profiling bool `json:"profiling,omitempty"`
+
+	// Scan files with a ClamAV daemon before the web UI serves them; the
+	// address of its socket, such as "/var/run/clamav/clamd.ctl" or
+	// "tcp://127.0.0.1:3310". Scanning is disabled when unset.
+	ClamdAddress  string `json:"clamd_address,omitempty"`
+	ClamdFailOpen bool   `json:"clamd_fail_open,omitempty"` // Serve files when clamd can't be reached
+
+	// Transfers the queue at /api/transfers runs at once; 2 if 0. The queue
+	// is kept in DataDir.
+	TransferConcurrency int `json:"transfer_concurrency,omitempty"`
+
+	// Per-second limit shared by all queued transfers (e.g. "2MB"), empty
+	// for unlimited. Transfers can also be capped individually.
+	TransferBandwidth string `json:"transfer_bandwidth,omitempty"`
+
+	// HTTP/2 is served over TLS unless HTTP2 is off. H2C serves cleartext
+	// HTTP/2 without TLS, for reverse proxies that speak it to the web UI.
+	HTTP2 bool `json:"http2"`
+	H2C   bool `json:"h2c,omitempty"`
+
+	// Idle keep-alive connections are closed after IdleTimeout seconds;
+	// 120 if 0
+	IdleTimeout int `json:"idle_timeout_seconds,omitempty"`
+
+	// Socket send buffer of each connection (e.g. "1MB"), empty for the
+	// operating system's default. Larger buffers help streaming media over
+	// high latency links.
+	WriteBufferSize string `json:"write_buffer_size,omitempty"`
+
+	// Public download-only mirror mode
+	Gateway GatewayConfig `json:"gateway"`
+}
+
+// GatewayConfig turns the web UI into a public, download-only mirror:
+// uploads, announcing, transfers, shares, saved searches, auto-fetch and
+// the admin API are off, and each client IP may download at most
+// DownloadQuotaMB (0 for no limit) every QuotaWindowHours
+type GatewayConfig struct {
+	Enabled          bool `json:"enabled"`
+	DownloadQuotaMB  int  `json:"download_quota_mb"`
+	QuotaWindowHours int  `json:"quota_window_hours"`
+
+	// Reverse proxies, as addresses or CIDRs, whose X-Forwarded-For and
+	// X-Real-IP headers name the client the quota is kept for. Headers
+	// from anyone else are ignored, since clients could pick their own.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+}
+
+// TransferBandwidthBytes returns the transfer queue's limit in bytes per
+// second (0 when unlimited)
+func (w WebUIConfig) TransferBandwidthBytes() (int64, error) {
+	if strings.TrimSpace(w.TransferBandwidth) == "" {
+		return 0, nil
+	}
+	return util.ParseSize(w.TransferBandwidth)
+}
+
+// WriteBufferBytes returns the socket send buffer size in bytes (0 for the
+// operating system's default)
+func (w WebUIConfig) WriteBufferBytes() (int64, error) {
+	if strings.TrimSpace(w.WriteBufferSize) == "" {
+		return 0, nil
+	}
+	return util.ParseSize(w.WriteBufferSize)
+}
+
+// S3GatewayConfig holds settings for the noisefs s3-gateway server, which
+// serves the file index as a single S3 bucket
+type S3GatewayConfig struct {
+	Address  string `json:"address"` // HTTP listen address
+	Bucket   string `json:"bucket"`  // Name of the bucket the index is served as
+	Region   string `json:"region"`  // Region reported to clients
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// Credentials clients sign requests with (AWS Signature Version 4).
+	// Without them the gateway only listens on loopback addresses. Use a
+	// secret reference such as "secret://env/NOISEFS_S3_SECRET_KEY".
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+// RemoteConfig holds settings for the noisefs remote server, which serves
+// the file index over the remote protocol for sync tools such as rclone
+type RemoteConfig struct {
+	Address  string `json:"address"` // HTTP listen address
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// Bearer token clients must send. Without it the server only listens on
+	// loopback addresses. Use a secret reference such as
+	// "secret://env/NOISEFS_REMOTE_TOKEN".
+	Token string `json:"token,omitempty"`
+}
+
+// DaemonConfig holds settings for long-running processes
+type DaemonConfig struct {
+	// Unix socket for runtime control (e.g. noisefs log-level). Empty disables it.
+	ControlSocket string `json:"control_socket,omitempty"`
+
+	// Renew the pins and provider records of descriptors pinned with
+	// "noisefs pin add" this often (e.g. "6h"), empty to leave them alone
+	PinMaintenanceInterval string `json:"pin_maintenance_interval,omitempty"`
+
+	// Blocks of each pin checked per round, 0 for 32
+	PinSampleSize int `json:"pin_sample_size,omitempty"`
+
+	// Fraction of sampled block copies below which a pin is reported as
+	// degraded, 0 for 0.9
+	PinAvailabilityThreshold float64 `json:"pin_availability_threshold,omitempty"`
+
+	// Minutes of client metrics kept in memory for /debug/history and
+	// "noisefs -stats -history", 0 for 60
+	MetricsHistoryMinutes int `json:"metrics_history_minutes,omitempty"`
+}
+
+// MetricsHistoryWindow returns how much metrics history daemons keep
+func (d DaemonConfig) MetricsHistoryWindow() time.Duration {
+	if d.MetricsHistoryMinutes == 0 {
+		return time.Hour
+	}
+	return time.Duration(d.MetricsHistoryMinutes) * time.Minute
 }
 
+// PinMaintenanceIntervalDuration returns how often pins are maintained
+// (0 when never)
+func (d DaemonConfig) PinMaintenanceIntervalDuration() (time.Duration, error) {
+	if strings.TrimSpace(d.PinMaintenanceInterval) == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(d.PinMaintenanceInterval)
+	if err != nil {
+		return 0, err
+	}
+	if interval <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return interval, nil
+}
+
+// WebhookConfig is an endpoint that events are POSTed to as JSON. Every
+// announcement filter that is set must match; other events aren't
+// filtered.
+type WebhookConfig struct {
+	// http or https URL. URLs carrying a token, such as Discord's, can be
+	// secret references.
+	URL string `json:"url"`
+
+	// Key the request body is signed with, sent as the hex HMAC-SHA256 in
+	// the X-NoiseFS-Signature header. Use a secret reference such as
+	// "secret://env/NOISEFS_WEBHOOK_SECRET".
+	Secret string `json:"secret,omitempty"`
+
+	// Events sent: upload.completed, announcement.received,
+	// sync.completed, search.matched and pin.degraded. Empty sends all
+	// of them.
+	Events []string `json:"events,omitempty"`
+
+	Topics     []string `json:"topics,omitempty"`     // Announced under any of these topics
+	Tags       []string `json:"tags,omitempty"`       // Tagged with all of these tags
+	Categories []string `json:"categories,omitempty"` // In any of these categories
+}
+
+// webhookEvents are the event types webhooks can subscribe to
+var webhookEvents = map[string]bool{
+	"upload.completed":      true,
+	"announcement.received": true,
+	"sync.completed":        true,
+	"search.matched":        true,
+	"pin.degraded":          true,
+}
+
+// ProcessorConfig is a step of the content processor pipeline: an external
+// command speaking the processor protocol, or a processor type built in or
+// registered by an embedding program
+type ProcessorConfig struct {
+	Name string `json:"name"` // Names the step in logs and errors
+
+	Command  []string          `json:"command,omitempty"`  // Program and arguments
+	Type     string            `json:"type,omitempty"`     // Processor type, when there is no command
+	Settings map[string]string `json:"settings,omitempty"` // Settings of the processor type
+
+	Stages     []string `json:"stages,omitempty"`     // upload, download; upload if empty
+	Extensions []string `json:"extensions,omitempty"` // Only files with these extensions, e.g. ".mp3"
+
+	Timeout  int  `json:"timeout_seconds,omitempty"` // 60 if 0
+	FailOpen bool `json:"fail_open,omitempty"`       // Accept files when the processor fails
+}
+
+// ProcessorPipeline returns the configured content processor pipeline, or
+// nil if no processors are configured
+func (c *Config) ProcessorPipeline() (*processors.Pipeline, error) {
+	if len(c.Processors) == 0 {
+		return nil, nil
+	}
+	steps, err := c.processorSteps()
+	if err != nil {
+		return nil, err
+	}
+	return processors.NewPipeline(steps...), nil
+}
+
+// WebUIProcessorPipeline returns the pipeline the web UI runs: the
+// configured processors, preceded on downloads by the ClamAV scan when
+// WebUI.ClamdAddress is set. It is nil when there is nothing to run.
+func (c *Config) WebUIProcessorPipeline() (*processors.Pipeline, error) {
+	if c.WebUI.ClamdAddress == "" {
+		return c.ProcessorPipeline()
+	}
+	scanner, err := processors.New("clamd", map[string]string{"address": c.WebUI.ClamdAddress})
+	if err != nil {
+		return nil, err
+	}
+	steps, err := c.processorSteps()
+	if err != nil {
+		return nil, err
+	}
+	scan := processors.Step{
+		Name:      "clamav",
+		Processor: scanner,
+		Stages:    []processors.Stage{processors.StageDownload},
+		FailOpen:  c.WebUI.ClamdFailOpen,
+	}
+	return processors.NewPipeline(append([]processors.Step{scan}, steps...)...), nil
+}
+
+func (c *Config) processorSteps() ([]processors.Step, error) {
+	steps := make([]processors.Step, 0, len(c.Processors))
+	for _, processorConfig := range c.Processors {
+		var processor processors.Processor = processors.Command{Args: processorConfig.Command}
+		if len(processorConfig.Command) == 0 {
+			var err error
+			if processor, err = processors.New(processorConfig.Type, processorConfig.Settings); err != nil {
+				return nil, fmt.Errorf("processor %s: %w", processorConfig.Name, err)
+			}
+		}
+		step := processors.Step{
+			Name:       processorConfig.Name,
+			Processor:  processor,
+			Extensions: processorConfig.Extensions,
+			Timeout:    time.Duration(processorConfig.Timeout) * time.Second,
+			FailOpen:   processorConfig.FailOpen,
+		}
+		for _, stage := range processorConfig.Stages {
+			step.Stages = append(step.Stages, processors.Stage(stage))
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// BlockConfig selects the block size of each uploaded file. The "fixed"
+// policy uses DefaultSize for everything; "auto" uses SmallFileSize for
+// files up to SmallFileThreshold bytes and MediaSize for audio and video.
+// The size is recorded in each descriptor, so changing it doesn't affect
+// existing files, but randomizers are only reused between blocks of the
+// same size.
+type BlockConfig struct {
+	Policy             string   `json:"policy"` // fixed, auto
+	DefaultSize        int      `json:"default_size"`
+	SmallFileSize      int      `json:"small_file_size,omitempty"`
+	SmallFileThreshold int64    `json:"small_file_threshold,omitempty"`
+	MediaSize          int      `json:"media_size,omitempty"`
+	MediaExtensions    []string `json:"media_extensions,omitempty"`
+}
+
+// SizePolicy returns the block size policy described by the configuration
+func (b BlockConfig) SizePolicy() (blocks.BlockSizePolicy, error) {
+	switch b.Policy {
+	case "fixed":
+		if err := blocks.ValidateBlockSize(b.DefaultSize); err != nil {
+			return nil, err
+		}
+		return blocks.FixedBlockSize(b.DefaultSize), nil
+	case "auto":
+		return blocks.NewFileTypeBlockSizePolicy(b.DefaultSize, b.SmallFileSize, b.SmallFileThreshold, b.MediaSize, b.MediaExtensions)
+	default:
+		return nil, fmt.Errorf("unknown block size policy '%s'", b.Policy)
+	}
+}
+
+// DedupConfig makes uploads of a file that was uploaded before, with the
+// same name and block size, return the earlier descriptor. Uploads are
+// remembered by the SHA-256 of their content in the index at IndexPath; the
+// bloom filter next to it answers for new content without reading the
+// index. With Blocks, identical blocks of different files also reuse one
+// stored data block and randomizers, remembered in the index at
+// BlockIndexPath. Shared data blocks tell anyone holding both descriptors
+// that the files have content in common. The indexes tell which files were
+// uploaded, so they are only readable by their owner.
+type DedupConfig struct {
+	Enabled        bool   `json:"enabled"`
+	IndexPath      string `json:"index_path"`
+	Bloom          bool   `json:"bloom"`
+	Blocks         bool   `json:"blocks"`
+	BlockIndexPath string `json:"block_index_path"`
+}
+
+// OfflineConfig lets uploads go ahead while no storage backend is
+// reachable. Their blocks are spooled to SpoolPath, at most MaxSizeMB of
+// them, and stored when the backend is reachable again; until then the
+// files can be downloaded only on this machine. Unreachable backends are
+// retried every RetryIntervalSeconds.
+type OfflineConfig struct {
+	Enabled              bool   `json:"enabled"`
+	SpoolPath            string `json:"spool_path"`
+	MaxSizeMB            int    `json:"max_size_mb"`
+	RetryIntervalSeconds int    `json:"retry_interval_seconds"`
+}
+
+// TelemetryConfig controls anonymous usage reports, which are only sent
+// when Enabled. A report holds the NoiseFS version, the operating system
+// and how often each feature was used, in coarse buckets, and is sent to
+// Endpoint at most every IntervalHours. Counts are kept in StatePath until
+// then; `noisefs telemetry preview` shows the next report.
+type TelemetryConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Endpoint      string `json:"endpoint"`
+	IntervalHours int    `json:"interval_hours"`
+	StatePath     string `json:"state_path"`
+}
+
+// MinTelemetryIntervalHours is the shortest time between usage reports
+const MinTelemetryIntervalHours = 24
+
+// UpdateConfig controls noisefs selfupdate. Releases of a Channel are
+// listed in a manifest under URL, which must be signed with the minisign
+// PublicKey before any binary it lists is installed.
+type UpdateConfig struct {
+	Channel   string `json:"channel"`    // stable or beta
+	URL       string `json:"url"`        // Base URL of the release manifests
+	PublicKey string `json:"public_key"` // minisign public key releases are signed with
+}
+
+// Update channels
+const (
+	UpdateChannelStable = "stable"
+	UpdateChannelBeta   = "beta"
+)
+
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	homeDir, _ := os.UserHomeDir()
@@ -101,6 +857,9 @@ func DefaultConfig() *Config {
 		IPFS: IPFSConfig{
 			APIEndpoint: "127.0.0.1:5001",
 			Timeout:     30,
+			Embedded: EmbeddedIPFSConfig{
+				ReprovideIntervalHours: 22,
+			},
 		},
 		Cache: CacheConfig{
 			BlockCacheSize: 1000,
@@ -117,14 +876,90 @@ func DefaultConfig() *Config {
 			File:   "",
 		},
 		Security: SecurityConfig{
-			EnableEncryption: true,
-			RequirePassword:  true,
+			EnableEncryption:  true,
+			RequirePassword:   true,
+			AuditLogFile:      filepath.Join(homeDir, ".noisefs", "audit.jsonl"),
+			AuditLogMaxSizeMB: 10,
+			AuditLogMaxFiles:  5,
 		},
 		Network: NetworkConfig{
 			TorEnabled:       true,
 			TorSOCKSProxy:    "127.0.0.1:9050",
 			MaxConcurrentOps: 10,
 		},
+		CoverTraffic: CoverTrafficConfig{
+			Enabled:        false,
+			Bandwidth:      "64KB",
+			Interval:       300,
+			BlocksPerRound: 4,
+			StoreBlocks:    true,
+		},
+		Privacy: PrivacyConfig{
+			AnnounceAutoTags: true,
+			AnnounceRealtime: true,
+		},
+		RetrievalMixing: RetrievalMixingConfig{
+			Enabled:       false,
+			MaxDelayMs:    100,
+			BatchSize:     32,
+			BatchWindowMs: 50,
+			Parallelism:   4,
+		},
+		Snapshots: SnapshotConfig{
+			KeepLast:   10,
+			KeepDaily:  7,
+			KeepWeekly: 4,
+		},
+		WebUI: WebUIConfig{
+			Address:      ":8080",
+			DataDir:      "./webui-data",
+			PollInterval: 30,
+			Metrics:      true,
+			HTTP2:        true,
+			Gateway: GatewayConfig{
+				DownloadQuotaMB:  1024,
+				QuotaWindowHours: 24,
+			},
+		},
+		S3Gateway: S3GatewayConfig{
+			Address: "127.0.0.1:9000",
+			Bucket:  "noisefs",
+			Region:  "us-east-1",
+		},
+		Remote: RemoteConfig{
+			Address: "127.0.0.1:9001",
+		},
+		Blocks: BlockConfig{
+			Policy:             "auto",
+			DefaultSize:        blocks.DefaultBlockSize,
+			SmallFileSize:      blocks.SmallFileBlockSize,
+			SmallFileThreshold: blocks.DefaultSmallFileThreshold,
+			MediaSize:          blocks.MediaBlockSize,
+			MediaExtensions:    append([]string(nil), blocks.DefaultMediaExtensions...),
+		},
+		Dedup: DedupConfig{
+			Enabled:        true,
+			IndexPath:      filepath.Join(homeDir, ".noisefs", "uploads.json"),
+			Bloom:          true,
+			Blocks:         true,
+			BlockIndexPath: filepath.Join(homeDir, ".noisefs", "blocks.jsonl"),
+		},
+		Offline: OfflineConfig{
+			Enabled:              false,
+			SpoolPath:            filepath.Join(homeDir, ".noisefs", "spool"),
+			MaxSizeMB:            1024,
+			RetryIntervalSeconds: 30,
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:       false,
+			Endpoint:      "https://telemetry.noisefs.org/v1/report",
+			IntervalHours: 7 * 24,
+			StatePath:     filepath.Join(homeDir, ".noisefs", "telemetry.json"),
+		},
+		Update: UpdateConfig{
+			Channel: UpdateChannelStable,
+			URL:     "https://releases.noisefs.org",
+		},
 	}
 	
 	// Populate computed fields
@@ -158,6 +993,11 @@ func LoadConfig(configPath string) (*Config, error) {
 	// Apply environment variable overrides
 	config.applyEnvironmentOverrides()
 
+	// Replace secret references with their values
+	if err := config.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
 	// Update computed fields after overrides
 	config.updateComputedFields()
 
@@ -172,6 +1012,68 @@ func LoadConfig(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// ValidateFile checks a configuration file for errors before it is deployed.
+// Unlike LoadConfig it requires the file to exist, ignores environment
+// overrides and rejects unknown keys, which LoadConfig silently drops.
+func ValidateFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := DefaultConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, fmt.Errorf("failed to parse config file at line %d: %w", lineAt(data, syntaxErr.Offset), err)
+		case errors.As(err, &typeErr):
+			return nil, fmt.Errorf("failed to parse config file at line %d: %w", lineAt(data, typeErr.Offset), err)
+		}
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	config.applyPrivacyPreset()
+	if err := config.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
+	config.updateComputedFields()
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// lineAt returns the 1-based line number of a byte offset
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// ResolveConfigPath returns configPath, or the default path when it is empty
+func ResolveConfigPath(configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+	if defaultPath, err := GetDefaultConfigPath(); err == nil {
+		return defaultPath
+	}
+	return ""
+}
+
+// Load loads the shared configuration used by every NoiseFS binary from
+// configPath, or from the default path when it is empty
+func Load(configPath string) (*Config, error) {
+	return LoadConfig(ResolveConfigPath(configPath))
+}
+
 // LoadConfigWithMigration loads configuration and handles legacy format migration
 func LoadConfigWithMigration(configPath string) (*Config, error) {
 	if configPath == "" {
@@ -208,6 +1110,11 @@ func LoadConfigWithMigration(configPath string) (*Config, error) {
 	// Apply environment variable overrides
 	config.applyEnvironmentOverrides()
 
+	// Replace secret references with their values
+	if err := config.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
 	// Update computed fields
 	config.updateComputedFields()
 
@@ -269,7 +1176,7 @@ func (c *Config) updateComputedFields() {
 	}
 	
 	c.Performance = PerformanceConfig{
-		BlockSize:              131072, // 128KB - NoiseFS standard
+		BlockSize:              c.Blocks.DefaultSize,
 		MaxConcurrentOps:       c.Network.MaxConcurrentOps,
 		MemoryLimit:            c.Cache.MemoryLimit,
 		StreamBufferSize:       bufferSize,
@@ -281,6 +1188,12 @@ func (c *Config) updateComputedFields() {
 
 // applyEnvironmentOverrides applies environment variable overrides
 func (c *Config) applyEnvironmentOverrides() {
+	// Privacy preset first, so the overrides below can adjust what it sets
+	if val := os.Getenv("NOISEFS_PRIVACY_PRESET"); val != "" {
+		c.Privacy.Preset = val
+	}
+	c.applyPrivacyPreset()
+
 	// IPFS overrides
 	if val := os.Getenv("NOISEFS_IPFS_API"); val != "" {
 		c.IPFS.APIEndpoint = val
@@ -290,6 +1203,18 @@ func (c *Config) applyEnvironmentOverrides() {
 			c.IPFS.Timeout = timeout
 		}
 	}
+	if val := os.Getenv("NOISEFS_IPFS_MODE"); val != "" {
+		c.IPFS.Mode = val
+	}
+	if val := os.Getenv("NOISEFS_IPFS_TOKEN"); val != "" {
+		c.IPFS.Token = val
+	}
+	if val := os.Getenv("NOISEFS_IPFS_USERNAME"); val != "" {
+		c.IPFS.Username = val
+	}
+	if val := os.Getenv("NOISEFS_IPFS_PASSWORD"); val != "" {
+		c.IPFS.Password = val
+	}
 
 	// Cache overrides
 	if val := os.Getenv("NOISEFS_CACHE_SIZE"); val != "" {
@@ -316,6 +1241,9 @@ func (c *Config) applyEnvironmentOverrides() {
 	if val := os.Getenv("NOISEFS_DEBUG"); val != "" {
 		c.FUSE.Debug = strings.ToLower(val) == "true"
 	}
+	if val := os.Getenv("NOISEFS_FUSE_METRICS_ADDRESS"); val != "" {
+		c.FUSE.MetricsAddress = val
+	}
 
 	// Logging overrides
 	if val := os.Getenv("NOISEFS_LOG_LEVEL"); val != "" {
@@ -327,6 +1255,20 @@ func (c *Config) applyEnvironmentOverrides() {
 	if val := os.Getenv("NOISEFS_LOG_FILE"); val != "" {
 		c.Logging.File = val
 	}
+	if val := os.Getenv("NOISEFS_LOG_COMPONENTS"); val != "" {
+		c.Logging.Components = make(map[string]string)
+		for _, part := range strings.Split(val, ",") {
+			if component, level, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+				c.Logging.Components[strings.TrimSpace(component)] = strings.TrimSpace(level)
+			}
+		}
+	}
+	if val := os.Getenv("NOISEFS_CONTROL_SOCKET"); val != "" {
+		c.Daemon.ControlSocket = val
+	}
+	if val := os.Getenv("NOISEFS_PIN_MAINTENANCE_INTERVAL"); val != "" {
+		c.Daemon.PinMaintenanceInterval = val
+	}
 
 	// Security overrides
 	if val := os.Getenv("NOISEFS_ENABLE_ENCRYPTION"); val != "" {
@@ -347,6 +1289,15 @@ func (c *Config) applyEnvironmentOverrides() {
 	if val := os.Getenv("NOISEFS_ENCRYPT_LOCAL_INDEX"); val != "" {
 		c.Security.EncryptLocalIndex = strings.ToLower(val) == "true"
 	}
+	if val := os.Getenv("NOISEFS_RATE_LIMIT_STORE"); val != "" {
+		c.Security.RateLimitStore = val
+	}
+	if val := os.Getenv("NOISEFS_SECRET_COMMAND"); val != "" {
+		c.Security.SecretCommand = val
+	}
+	if val := os.Getenv("NOISEFS_AUDIT_LOG"); val != "" {
+		c.Security.AuditLog = strings.ToLower(val) == "true"
+	}
 
 	// Network overrides
 	if val := os.Getenv("NOISEFS_TOR_ENABLED"); val != "" {
@@ -355,17 +1306,226 @@ func (c *Config) applyEnvironmentOverrides() {
 	if val := os.Getenv("NOISEFS_TOR_SOCKS_PROXY"); val != "" {
 		c.Network.TorSOCKSProxy = val
 	}
+	if val := os.Getenv("NOISEFS_PROXY"); val != "" {
+		c.Network.Proxy = val
+	}
 	if val := os.Getenv("NOISEFS_MAX_CONCURRENT_OPS"); val != "" {
 		if ops, err := strconv.Atoi(val); err == nil {
 			c.Network.MaxConcurrentOps = ops
 		}
 	}
+	if val := os.Getenv("NOISEFS_MAX_BANDWIDTH"); val != "" {
+		c.Network.MaxBandwidth = val
+	}
+	if val := os.Getenv("NOISEFS_SPECULATIVE_FETCH_DELAY"); val != "" {
+		c.Network.SpeculativeFetchDelay = val
+	}
+
+	// Cover traffic overrides
+	if val := os.Getenv("NOISEFS_COVER_TRAFFIC"); val != "" {
+		c.CoverTraffic.Enabled = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_COVER_TRAFFIC_BANDWIDTH"); val != "" {
+		c.CoverTraffic.Bandwidth = val
+	}
+
+	// Retrieval mixing overrides
+	if val := os.Getenv("NOISEFS_RETRIEVAL_MIXING"); val != "" {
+		c.RetrievalMixing.Enabled = strings.ToLower(val) == "true"
+	}
+
+	// Web UI overrides
+	if val := os.Getenv("NOISEFS_WEBUI_ADDRESS"); val != "" {
+		c.WebUI.Address = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_DATA_DIR"); val != "" {
+		c.WebUI.DataDir = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_POLL_INTERVAL"); val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil {
+			c.WebUI.PollInterval = seconds
+		}
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_TLS"); val != "" {
+		c.WebUI.TLS = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_METRICS"); val != "" {
+		c.WebUI.Metrics = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_PROFILING"); val != "" {
+		c.WebUI.Profiling = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_CERT_FILE"); val != "" {
+		c.WebUI.CertFile = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_KEY_FILE"); val != "" {
+		c.WebUI.KeyFile = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME"); val != "" {
+		c.WebUI.ACME = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME_DOMAINS"); val != "" {
+		c.WebUI.ACMEDomains = nil
+		for _, domain := range strings.Split(val, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				c.WebUI.ACMEDomains = append(c.WebUI.ACMEDomains, domain)
+			}
+		}
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME_EMAIL"); val != "" {
+		c.WebUI.ACMEEmail = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME_CACHE_DIR"); val != "" {
+		c.WebUI.ACMECacheDir = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME_HTTP_ADDRESS"); val != "" {
+		c.WebUI.ACMEHTTPAddress = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ACME_DIRECTORY_URL"); val != "" {
+		c.WebUI.ACMEDirectoryURL = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_ADMIN_TOKEN"); val != "" {
+		c.WebUI.AdminToken = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_CLAMD_ADDRESS"); val != "" {
+		c.WebUI.ClamdAddress = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_CLAMD_FAIL_OPEN"); val != "" {
+		c.WebUI.ClamdFailOpen = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_TRANSFER_CONCURRENCY"); val != "" {
+		if concurrency, err := strconv.Atoi(val); err == nil {
+			c.WebUI.TransferConcurrency = concurrency
+		}
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_TRANSFER_BANDWIDTH"); val != "" {
+		c.WebUI.TransferBandwidth = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_HTTP2"); val != "" {
+		c.WebUI.HTTP2 = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_H2C"); val != "" {
+		c.WebUI.H2C = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_IDLE_TIMEOUT"); val != "" {
+		if timeout, err := strconv.Atoi(val); err == nil {
+			c.WebUI.IdleTimeout = timeout
+		}
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_WRITE_BUFFER_SIZE"); val != "" {
+		c.WebUI.WriteBufferSize = val
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_REQUIRE_TOKENS"); val != "" {
+		c.WebUI.RequireTokens = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_GATEWAY"); val != "" {
+		c.WebUI.Gateway.Enabled = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_GATEWAY_QUOTA_MB"); val != "" {
+		if quota, err := strconv.Atoi(val); err == nil {
+			c.WebUI.Gateway.DownloadQuotaMB = quota
+		}
+	}
+	if val := os.Getenv("NOISEFS_WEBUI_GATEWAY_TRUSTED_PROXIES"); val != "" {
+		c.WebUI.Gateway.TrustedProxies = nil
+		for _, entry := range strings.Split(val, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				c.WebUI.Gateway.TrustedProxies = append(c.WebUI.Gateway.TrustedProxies, entry)
+			}
+		}
+	}
+
+	// S3 gateway overrides
+	if val := os.Getenv("NOISEFS_S3_ADDRESS"); val != "" {
+		c.S3Gateway.Address = val
+	}
+	if val := os.Getenv("NOISEFS_S3_BUCKET"); val != "" {
+		c.S3Gateway.Bucket = val
+	}
+	if val := os.Getenv("NOISEFS_S3_ACCESS_KEY"); val != "" {
+		c.S3Gateway.AccessKey = val
+	}
+	if val := os.Getenv("NOISEFS_S3_SECRET_KEY"); val != "" {
+		c.S3Gateway.SecretKey = val
+	}
+
+	// Remote server overrides
+	if val := os.Getenv("NOISEFS_REMOTE_ADDRESS"); val != "" {
+		c.Remote.Address = val
+	}
+	if val := os.Getenv("NOISEFS_REMOTE_TOKEN"); val != "" {
+		c.Remote.Token = val
+	}
+
+	// Block size overrides
+	if val := os.Getenv("NOISEFS_BLOCK_POLICY"); val != "" {
+		c.Blocks.Policy = val
+	}
+	if val := os.Getenv("NOISEFS_BLOCK_SIZE"); val != "" {
+		if size, err := strconv.Atoi(val); err == nil {
+			c.Blocks.DefaultSize = size
+		}
+	}
+
+	// Upload deduplication overrides
+	if val := os.Getenv("NOISEFS_DEDUP_ENABLED"); val != "" {
+		c.Dedup.Enabled = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_DEDUP_INDEX_PATH"); val != "" {
+		c.Dedup.IndexPath = val
+	}
+	if val := os.Getenv("NOISEFS_DEDUP_BLOOM"); val != "" {
+		c.Dedup.Bloom = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_DEDUP_BLOCKS"); val != "" {
+		c.Dedup.Blocks = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_DEDUP_BLOCK_INDEX_PATH"); val != "" {
+		c.Dedup.BlockIndexPath = val
+	}
+
+	// Offline spooling overrides
+	if val := os.Getenv("NOISEFS_OFFLINE_ENABLED"); val != "" {
+		c.Offline.Enabled = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_OFFLINE_SPOOL_PATH"); val != "" {
+		c.Offline.SpoolPath = val
+	}
+	if val := os.Getenv("NOISEFS_OFFLINE_MAX_SIZE_MB"); val != "" {
+		if size, err := strconv.Atoi(val); err == nil {
+			c.Offline.MaxSizeMB = size
+		}
+	}
+
+	// Telemetry overrides
+	if val := os.Getenv("NOISEFS_TELEMETRY_ENABLED"); val != "" {
+		c.Telemetry.Enabled = strings.ToLower(val) == "true"
+	}
+	if val := os.Getenv("NOISEFS_TELEMETRY_ENDPOINT"); val != "" {
+		c.Telemetry.Endpoint = val
+	}
+
+	// Update overrides
+	if val := os.Getenv("NOISEFS_UPDATE_CHANNEL"); val != "" {
+		c.Update.Channel = strings.ToLower(val)
+	}
 }
 
 // Validate validates the configuration and provides helpful suggestions
 func (c *Config) Validate() error {
 	// Validate IPFS configuration
-	if c.IPFS.APIEndpoint == "" {
+	switch c.IPFS.Mode {
+	case "", IPFSModeDaemon, IPFSModeEmbedded:
+	default:
+		return fmt.Errorf("invalid IPFS mode '%s'. Use 'daemon' for an IPFS daemon or 'embedded' for a built-in node", c.IPFS.Mode)
+	}
+	if !storage.ValidProvideStrategy(c.IPFS.Embedded.ProvideStrategy) {
+		return fmt.Errorf("invalid provide strategy '%s'. Use 'all', 'randomizers' to announce only randomizer blocks, or 'none'", c.IPFS.Embedded.ProvideStrategy)
+	}
+	if c.IPFS.Embedded.ProvideRate < 0 || c.IPFS.Embedded.ReprovideIntervalHours < 0 {
+		return fmt.Errorf("provide rate and reprovide interval cannot be negative. Use 0 for no rate limit or to announce blocks only once")
+	}
+	if c.IPFS.APIEndpoint == "" && c.IPFS.Mode != IPFSModeEmbedded {
 		return fmt.Errorf("IPFS API endpoint cannot be empty. Set it to '127.0.0.1:5001' for local IPFS node")
 	}
 	if c.IPFS.Timeout <= 0 {
@@ -374,6 +1534,15 @@ func (c *Config) Validate() error {
 	if c.IPFS.Timeout > 300 {
 		return fmt.Errorf("IPFS timeout is very high (%d seconds). Consider using 30-60 seconds", c.IPFS.Timeout)
 	}
+	if c.IPFS.Token != "" && c.IPFS.Username != "" {
+		return fmt.Errorf("IPFS token and username are mutually exclusive. Use a token for Bearer auth or a username and password for Basic auth")
+	}
+	if (c.IPFS.Username == "") != (c.IPFS.Password == "") {
+		return fmt.Errorf("IPFS username and password must be set together for Basic auth")
+	}
+	if (c.IPFS.TLSCertFile == "") != (c.IPFS.TLSKeyFile == "") {
+		return fmt.Errorf("IPFS tls_cert_file and tls_key_file must be set together for mutual TLS")
+	}
 
 	// Validate cache configuration
 	if c.Cache.BlockCacheSize <= 0 {
@@ -390,6 +1559,14 @@ func (c *Config) Validate() error {
 	if !validLevels[c.Logging.Level] {
 		return fmt.Errorf("invalid log level '%s'. Valid options: debug, info, warn, error", c.Logging.Level)
 	}
+	for component, level := range c.Logging.Components {
+		if !validLevels[level] {
+			return fmt.Errorf("invalid log level '%s' for component '%s'. Valid options: debug, info, warn, error", level, component)
+		}
+	}
+	if c.Logging.SampleInitial < 0 || c.Logging.SampleThereafter < 0 {
+		return fmt.Errorf("log sampling values cannot be negative (sample_initial: %d, sample_thereafter: %d)", c.Logging.SampleInitial, c.Logging.SampleThereafter)
+	}
 
 	validOutputs := map[string]bool{
 		"console": true, "file": true,
@@ -410,7 +1587,227 @@ func (c *Config) Validate() error {
 	if c.Network.MaxConcurrentOps > 100 {
 		return fmt.Errorf("max concurrent operations is very high (%d). Consider using 10-50", c.Network.MaxConcurrentOps)
 	}
+	if _, err := c.Network.MaxBandwidthBytes(); err != nil {
+		return fmt.Errorf("invalid max bandwidth '%s': %v. Use a size such as '2MB', or leave empty for unlimited", c.Network.MaxBandwidth, err)
+	}
+	if _, err := c.Network.SpeculativeFetchDelayDuration(); err != nil {
+		return fmt.Errorf("invalid speculative fetch delay '%s': %v. Use a duration such as '750ms', or leave empty to ask one backend", c.Network.SpeculativeFetchDelay, err)
+	}
+
+	// Validate privacy preset
+	switch c.Privacy.Preset {
+	case "", PrivacyPresetPerformance, PrivacyPresetBalanced, PrivacyPresetParanoid:
+	default:
+		return fmt.Errorf("invalid privacy preset '%s'. Use 'performance', 'balanced' or 'paranoid', or leave empty to configure each setting", c.Privacy.Preset)
+	}
+
+	// Validate cover traffic, even when disabled, so enabling it later works
+	if _, err := c.CoverTraffic.GeneratorConfig(); err != nil {
+		return fmt.Errorf("invalid cover traffic settings: %v. Use a bandwidth such as '64KB', a positive interval and blocks per round, and active hours such as '08:00-23:00'", err)
+	}
+
+	// Validate retrieval mixing, even when disabled, so enabling it later works
+	if _, err := c.RetrievalMixing.SchedulerConfig(); err != nil {
+		return fmt.Errorf("invalid retrieval mixing settings: %v. Use delays with min_delay_ms <= max_delay_ms and a positive batch size and parallelism", err)
+	}
+
+	// Validate snapshot retention
+	if c.Snapshots.KeepLast < 0 || c.Snapshots.KeepDaily < 0 || c.Snapshots.KeepWeekly < 0 {
+		return fmt.Errorf("snapshot retention values cannot be negative (keep_last: %d, keep_daily: %d, keep_weekly: %d)", c.Snapshots.KeepLast, c.Snapshots.KeepDaily, c.Snapshots.KeepWeekly)
+	}
+
+	// Validate audit log
+	if c.Security.AuditLogMaxSizeMB < 0 || c.Security.AuditLogMaxFiles < 0 {
+		return fmt.Errorf("audit log rotation values cannot be negative (max_size_mb: %d, max_files: %d)", c.Security.AuditLogMaxSizeMB, c.Security.AuditLogMaxFiles)
+	}
+
+	// Tokens are only verified when the web UI requires them, and an
+	// unverified one would pick its own rate limit bucket
+	if c.Security.RateLimitByToken && !c.WebUI.RequireTokens {
+		return fmt.Errorf("security rate_limit_by_token needs webui.require_tokens, since tokens are only verified then. Use require_tokens, or turn rate_limit_by_token off to limit per client IP")
+	}
+
+	// Validate web UI configuration
+	if c.WebUI.Address == "" {
+		return fmt.Errorf("web UI address cannot be empty. Use ':8080' to listen on all interfaces")
+	}
+	if c.WebUI.PollInterval <= 0 {
+		return fmt.Errorf("web UI poll interval must be positive (current: %d seconds). Use 30 for default", c.WebUI.PollInterval)
+	}
+	if (c.WebUI.CertFile == "") != (c.WebUI.KeyFile == "") {
+		return fmt.Errorf("web UI cert_file and key_file must be set together")
+	}
+	if c.WebUI.ACME {
+		if len(c.WebUI.ACMEDomains) == 0 {
+			return fmt.Errorf("web UI acme requires acme_domains, the public names the certificate is issued for")
+		}
+		if c.WebUI.CertFile != "" {
+			return fmt.Errorf("web UI acme and cert_file/key_file cannot be used together")
+		}
+		for _, domain := range c.WebUI.ACMEDomains {
+			if domain == "localhost" || net.ParseIP(domain) != nil || !strings.Contains(domain, ".") {
+				return fmt.Errorf("invalid acme domain '%s': ACME certificates need a public DNS name", domain)
+			}
+		}
+	}
+	if c.WebUI.TransferConcurrency < 0 {
+		return fmt.Errorf("web UI transfer_concurrency cannot be negative (current: %d). Use 0 for the default of 2", c.WebUI.TransferConcurrency)
+	}
+	if _, err := c.WebUI.TransferBandwidthBytes(); err != nil {
+		return fmt.Errorf("invalid web UI transfer bandwidth '%s': %v. Use a size such as '2MB', or leave empty for unlimited", c.WebUI.TransferBandwidth, err)
+	}
+	if c.WebUI.H2C && (c.WebUI.TLS || c.WebUI.ACME) {
+		return fmt.Errorf("web UI h2c serves HTTP/2 without TLS and cannot be used with tls or acme; HTTP/2 over TLS is enabled with http2")
+	}
+	if c.WebUI.IdleTimeout < 0 {
+		return fmt.Errorf("web UI idle_timeout_seconds cannot be negative (current: %d). Use 0 for the default of 120", c.WebUI.IdleTimeout)
+	}
+	if _, err := c.WebUI.WriteBufferBytes(); err != nil {
+		return fmt.Errorf("invalid web UI write buffer size '%s': %v. Use a size such as '1MB', or leave empty for the system default", c.WebUI.WriteBufferSize, err)
+	}
+	if c.WebUI.Gateway.Enabled {
+		if c.WebUI.Gateway.DownloadQuotaMB < 0 {
+			return fmt.Errorf("web UI gateway download_quota_mb cannot be negative (current: %d). Use 0 for no quota", c.WebUI.Gateway.DownloadQuotaMB)
+		}
+		if c.WebUI.Gateway.DownloadQuotaMB > 0 && c.WebUI.Gateway.QuotaWindowHours <= 0 {
+			return fmt.Errorf("web UI gateway quota_window_hours must be positive (current: %d). Use 24 for a daily quota", c.WebUI.Gateway.QuotaWindowHours)
+		}
+		for _, entry := range c.WebUI.Gateway.TrustedProxies {
+			if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+				return fmt.Errorf("invalid web UI gateway trusted proxy '%s'. Use an address such as '127.0.0.1' or a CIDR such as '10.0.0.0/8'", entry)
+			}
+		}
+	}
+	if c.WebUI.ClamdAddress != "" {
+		if _, _, err := processors.ParseClamdAddress(c.WebUI.ClamdAddress); err != nil {
+			return fmt.Errorf("web UI clamd_address: %v. Use a socket path such as '/var/run/clamav/clamd.ctl' or 'tcp://127.0.0.1:3310'", err)
+		}
+	}
+
+	// Validate S3 gateway configuration
+	if c.S3Gateway.Address == "" {
+		return fmt.Errorf("S3 gateway address cannot be empty. Use '127.0.0.1:9000' for default")
+	}
+	if !validBucketName(c.S3Gateway.Bucket) {
+		return fmt.Errorf("invalid S3 gateway bucket '%s': use 3-63 lowercase letters, digits, dots and hyphens", c.S3Gateway.Bucket)
+	}
+	if (c.S3Gateway.AccessKey == "") != (c.S3Gateway.SecretKey == "") {
+		return fmt.Errorf("S3 gateway access_key and secret_key must be set together")
+	}
+	if (c.S3Gateway.CertFile == "") != (c.S3Gateway.KeyFile == "") {
+		return fmt.Errorf("S3 gateway cert_file and key_file must be set together")
+	}
+
+	// Validate remote server configuration
+	if c.Remote.Address == "" {
+		return fmt.Errorf("remote address cannot be empty. Use '127.0.0.1:9001' for default")
+	}
+	if (c.Remote.CertFile == "") != (c.Remote.KeyFile == "") {
+		return fmt.Errorf("remote cert_file and key_file must be set together")
+	}
+
+	// Validate pin maintenance
+	if _, err := c.Daemon.PinMaintenanceIntervalDuration(); err != nil {
+		return fmt.Errorf("invalid pin maintenance interval '%s': %v. Use a duration such as '6h', or leave empty to disable", c.Daemon.PinMaintenanceInterval, err)
+	}
+	if c.Daemon.PinSampleSize < 0 {
+		return fmt.Errorf("pin sample size cannot be negative (current: %d). Use 0 for the default of 32", c.Daemon.PinSampleSize)
+	}
+	if c.Daemon.PinAvailabilityThreshold < 0 || c.Daemon.PinAvailabilityThreshold > 1 {
+		return fmt.Errorf("pin availability threshold must be between 0 and 1 (current: %g). Use 0 for the default of 0.9", c.Daemon.PinAvailabilityThreshold)
+	}
+	if c.Daemon.MetricsHistoryMinutes < 0 || c.Daemon.MetricsHistoryMinutes > 24*60 {
+		return fmt.Errorf("metrics history of %d minutes is out of range. Use up to 1440 minutes, or 0 for the default of 60", c.Daemon.MetricsHistoryMinutes)
+	}
+
+	// Validate webhooks
+	for i, webhook := range c.Webhooks {
+		endpoint, err := url.Parse(webhook.URL)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("webhook %d: url must be an http or https URL", i+1)
+		}
+		for _, event := range webhook.Events {
+			if !webhookEvents[event] {
+				return fmt.Errorf("webhook %d: unknown event '%s'. Valid events: upload.completed, announcement.received, sync.completed, search.matched, pin.degraded", i+1, event)
+			}
+		}
+	}
+
+	// Validate content processors
+	processorNames := make(map[string]bool)
+	for i, processor := range c.Processors {
+		if processor.Name == "" {
+			return fmt.Errorf("processor %d: name cannot be empty", i+1)
+		}
+		if processorNames[processor.Name] {
+			return fmt.Errorf("processor %d: name '%s' is already used", i+1, processor.Name)
+		}
+		processorNames[processor.Name] = true
+		if (len(processor.Command) == 0) == (processor.Type == "") {
+			return fmt.Errorf("processor %s: set either command or type", processor.Name)
+		}
+		for _, stage := range processor.Stages {
+			if stage != string(processors.StageUpload) && stage != string(processors.StageDownload) {
+				return fmt.Errorf("processor %s: invalid stage '%s'. Valid stages: upload, download", processor.Name, stage)
+			}
+		}
+		if processor.Timeout < 0 {
+			return fmt.Errorf("processor %s: timeout cannot be negative", processor.Name)
+		}
+	}
+	if _, err := c.ProcessorPipeline(); err != nil {
+		return err
+	}
+
+	// Validate block size policy
+	if c.Blocks.Policy != "fixed" && c.Blocks.Policy != "auto" {
+		return fmt.Errorf("invalid block policy '%s'. Valid options: fixed, auto", c.Blocks.Policy)
+	}
+	if _, err := c.Blocks.SizePolicy(); err != nil {
+		return fmt.Errorf("invalid block size configuration: %v. Block sizes must be powers of two such as 32768, 131072 or 262144", err)
+	}
+
+	// Validate upload deduplication
+	if c.Dedup.Enabled && c.Dedup.IndexPath == "" {
+		return fmt.Errorf("dedup index path cannot be empty. Set dedup.index_path or disable deduplication with dedup.enabled: false")
+	}
+	if c.Dedup.Blocks && c.Dedup.BlockIndexPath == "" {
+		return fmt.Errorf("dedup block index path cannot be empty. Set dedup.block_index_path or disable block reuse with dedup.blocks: false")
+	}
 
+	// Validate offline spooling
+	if c.Offline.Enabled {
+		if c.Offline.SpoolPath == "" {
+			return fmt.Errorf("offline spool path cannot be empty. Set offline.spool_path or disable offline spooling with offline.enabled: false")
+		}
+		if c.Offline.MaxSizeMB < 0 {
+			return fmt.Errorf("invalid offline spool size %d MB. Use 0 for no limit or a positive size such as 1024", c.Offline.MaxSizeMB)
+		}
+		if c.Offline.RetryIntervalSeconds < 0 {
+			return fmt.Errorf("invalid offline retry interval %d seconds. Use a positive interval such as 30", c.Offline.RetryIntervalSeconds)
+		}
+	}
+
+	// Validate telemetry
+	if c.Telemetry.Enabled {
+		if endpoint, err := url.Parse(c.Telemetry.Endpoint); err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+			return fmt.Errorf("invalid telemetry endpoint %q. Use an https URL or disable telemetry with telemetry.enabled: false", c.Telemetry.Endpoint)
+		}
+		if c.Telemetry.IntervalHours < MinTelemetryIntervalHours {
+			return fmt.Errorf("telemetry interval %d hours is too short. Use at least %d hours, such as 168 for weekly reports", c.Telemetry.IntervalHours, MinTelemetryIntervalHours)
+		}
+		if c.Telemetry.StatePath == "" {
+			return fmt.Errorf("telemetry state path cannot be empty. Set telemetry.state_path or disable telemetry with telemetry.enabled: false")
+		}
+	}
+
+	// Validate updates
+	if c.Update.Channel != UpdateChannelStable && c.Update.Channel != UpdateChannelBeta {
+		return fmt.Errorf("invalid update channel %q. Use %s or %s", c.Update.Channel, UpdateChannelStable, UpdateChannelBeta)
+	}
+	if releases, err := url.Parse(c.Update.URL); err != nil || releases.Scheme != "https" || releases.Host == "" {
+		return fmt.Errorf("invalid update URL %q. Use the https URL release manifests are published under", c.Update.URL)
+	}
 
 	// Validate security configuration
 	if !c.Security.EnableEncryption {
@@ -425,6 +1822,14 @@ func (c *Config) Validate() error {
 	if c.Network.TorEnabled && c.Network.TorSOCKSProxy == "" {
 		return fmt.Errorf("Tor is enabled but SOCKS proxy is not configured. Set tor_socks_proxy to '127.0.0.1:9050'")
 	}
+	if c.Network.Proxy != "" {
+		if _, err := proxy.Parse(c.Network.Proxy); err != nil {
+			return fmt.Errorf("invalid network proxy: %w. Use 'socks5h://127.0.0.1:9050' for Tor", err)
+		}
+		if c.IPFS.Mode == IPFSModeEmbedded {
+			return fmt.Errorf("network proxy cannot be used with the embedded IPFS node, whose peer connections can't be proxied. Use daemon mode with an IPFS daemon configured for Tor")
+		}
+	}
 
 	// Log security warnings
 	c.logSecurityWarnings()
@@ -432,6 +1837,21 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validBucketName reports whether name follows the S3 bucket naming rules,
+// which clients check before sending requests
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 || net.ParseIP(name) != nil {
+		return false
+	}
+	for i, r := range name {
+		alnum := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		if !alnum && ((r != '.' && r != '-') || i == 0 || i == len(name)-1) {
+			return false
+		}
+	}
+	return !strings.Contains(name, "..")
+}
+
 // SaveToFile saves the configuration to a JSON file
 func (c *Config) SaveToFile(path string) error {
 	// Ensure directory exists
@@ -440,8 +1860,8 @@ func (c *Config) SaveToFile(path string) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	// Marshal to JSON with proper formatting
-	data, err := json.MarshalIndent(c, "", "  ")
+	// Marshal to JSON with proper formatting, keeping secrets as references
+	data, err := json.MarshalIndent(c.Redacted(), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -565,7 +1985,23 @@ func MigrateFromLegacy(legacyData []byte) (*Config, error) {
 		}
 	}
 
-	// WebUI is no longer supported in simplified config
+	// Migrate web UI settings
+	if legacy.WebUI != nil {
+		if address, ok := legacy.WebUI["address"].(string); ok && address != "" {
+			config.WebUI.Address = address
+		}
+		if tls, ok := legacy.WebUI["tls"].(map[string]interface{}); ok {
+			if enabled, ok := tls["enabled"].(bool); ok {
+				config.WebUI.TLS = enabled
+			}
+			if cert, ok := tls["cert_file"].(string); ok {
+				config.WebUI.CertFile = cert
+			}
+			if key, ok := tls["key_file"].(string); ok {
+				config.WebUI.KeyFile = key
+			}
+		}
+	}
 
 	if legacy.Tor != nil {
 		if enabled, ok := legacy.Tor["enabled"].(bool); ok {
@@ -598,10 +2034,43 @@ func (c *Config) logSecurityWarnings() {
 		warnings = append(warnings, "WARNING: Local index encryption is disabled - file listings may be exposed")
 	}
 	
+	if c.Security.IndexPassword != "" && c.secretRefs["security.index_password"] == "" {
+		warnings = append(warnings, "WARNING: index_password is stored in plaintext - use a secret:// reference instead")
+	}
+	if c.WebUI.AdminToken != "" && c.secretRefs["webui.admin_token"] == "" {
+		warnings = append(warnings, "WARNING: admin_token is stored in plaintext - use a secret:// reference instead")
+	}
+	if c.S3Gateway.SecretKey != "" && c.secretRefs["s3_gateway.secret_key"] == "" {
+		warnings = append(warnings, "WARNING: S3 gateway secret_key is stored in plaintext - use a secret:// reference instead")
+	}
+	if c.Remote.Token != "" && c.secretRefs["remote.token"] == "" {
+		warnings = append(warnings, "WARNING: remote token is stored in plaintext - use a secret:// reference instead")
+	}
+	for i, webhook := range c.Webhooks {
+		if webhook.Secret != "" && c.secretRefs[fmt.Sprintf("webhooks[%d].secret", i)] == "" {
+			warnings = append(warnings, fmt.Sprintf("WARNING: webhook %d secret is stored in plaintext - use a secret:// reference instead", i+1))
+		}
+	}
+	if c.IPFS.Token != "" && c.secretRefs["ipfs.token"] == "" {
+		warnings = append(warnings, "WARNING: IPFS token is stored in plaintext - use a secret:// reference instead")
+	}
+	if c.IPFS.Password != "" && c.secretRefs["ipfs.password"] == "" {
+		warnings = append(warnings, "WARNING: IPFS password is stored in plaintext - use a secret:// reference instead")
+	}
+	if (c.IPFS.Token != "" || c.IPFS.Password != "") && strings.HasPrefix(c.IPFS.APIEndpoint, "http://") {
+		warnings = append(warnings, "WARNING: IPFS credentials are sent over plain HTTP - use an https:// endpoint")
+	}
+	if c.IPFS.TLSInsecureSkipVerify {
+		warnings = append(warnings, "WARNING: IPFS TLS certificate verification is disabled")
+	}
+	
 	// Check network security
 	if !c.Network.TorEnabled {
 		warnings = append(warnings, "INFO: Tor is disabled - network traffic is not anonymized")
 	}
+	if c.Privacy.Preset == PrivacyPresetParanoid && c.Network.Proxy == "" {
+		warnings = append(warnings, "WARNING: paranoid privacy preset without a proxy - the embedded IPFS node connects to peers directly")
+	}
 	
 	// Log all warnings
 	for _, warning := range warnings {
@@ -611,7 +2080,7 @@ func (c *Config) logSecurityWarnings() {
 
 // PerformanceConfig holds performance-related configuration for backward compatibility
 type PerformanceConfig struct {
-	BlockSize              int  // Computed: Always 128KB (NoiseFS standard)
+	BlockSize              int  // From Blocks.DefaultSize
 	MaxConcurrentOps       int  // From Network.MaxConcurrentOps 
 	MemoryLimit            int  // From Cache.MemoryLimit
 	StreamBufferSize       int  // Computed: MemoryLimit/50, min 5, max 50
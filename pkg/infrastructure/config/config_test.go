@@ -1,9 +1,15 @@
 package config
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/processors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -127,3 +133,831 @@ func TestLoadNonexistentConfig(t *testing.T) {
 		t.Errorf("Non-existent config should use defaults, got %s", config.IPFS.APIEndpoint)
 	}
 }
+
+func TestReloader(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	config := DefaultConfig()
+	if err := config.SaveToFile(configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	current, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	reloader := NewReloader(configPath, current)
+	var applied *Config
+	reloader.OnReload(func(cfg *Config) error {
+		applied = cfg
+		return nil
+	})
+
+	// Mix reloadable and restart-only changes
+	config.Logging.Level = "debug"
+	config.Network.MaxBandwidth = "2MB"
+	config.IPFS.APIEndpoint = "other.example.com:5001"
+	if err := config.SaveToFile(configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	result, err := reloader.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if strings.Join(result.Applied, ",") != "logging.level,network.max_bandwidth" {
+		t.Errorf("Unexpected applied keys: %v", result.Applied)
+	}
+	if strings.Join(result.RestartRequired, ",") != "ipfs.api_endpoint" {
+		t.Errorf("Unexpected restart-required keys: %v", result.RestartRequired)
+	}
+	if applied == nil || applied.Logging.Level != "debug" {
+		t.Fatal("Reload handler was not called with the new log level")
+	}
+	if bandwidth, _ := applied.Network.MaxBandwidthBytes(); bandwidth != 2*1024*1024 {
+		t.Errorf("Expected 2MB bandwidth limit, got %d", bandwidth)
+	}
+	if reloader.Current().IPFS.APIEndpoint != "127.0.0.1:5001" {
+		t.Error("Restart-only settings should not change on reload")
+	}
+
+	// An invalid file keeps the current configuration
+	os.WriteFile(configPath, []byte(`{"logging": {"level": "loud"}}`), 0644)
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("Expected invalid configuration to be rejected")
+	}
+	if reloader.Current().Logging.Level != "debug" {
+		t.Error("Failed reload should keep the current configuration")
+	}
+
+	for _, key := range ReloadableKeys {
+		if _, ok := reloadSetters[key]; !ok {
+			t.Errorf("Reloadable key %s has no setter", key)
+		}
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	validPath := filepath.Join(tmpDir, "valid.json")
+	if err := DefaultConfig().SaveToFile(validPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if _, err := ValidateFile(validPath); err != nil {
+		t.Errorf("Valid config failed validation: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		contains string
+	}{
+		{"unknown key", `{"cache": {"block_cache_sise": 10}}`, "block_cache_sise"},
+		{"syntax error", "{\n  \"logging\": {\n    \"level\": \"debug\",\n  }\n}", "line 4"},
+		{"wrong type", `{"cache": {"block_cache_size": "big"}}`, "line 1"},
+		{"invalid value", `{"network": {"max_bandwidth": "fast"}}`, "max bandwidth"},
+		{"negative delay", `{"network": {"speculative_fetch_delay": "-1s"}}`, "speculative fetch delay"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(tmpDir, "invalid.json")
+		os.WriteFile(path, []byte(tt.content), 0644)
+
+		_, err := ValidateFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.contains) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.contains, err)
+		}
+	}
+
+	if _, err := ValidateFile(filepath.Join(tmpDir, "missing.json")); err == nil {
+		t.Error("Expected missing file to fail validation")
+	}
+}
+
+func TestWebUIConfig(t *testing.T) {
+	os.Setenv("NOISEFS_WEBUI_ADDRESS", "127.0.0.1:9090")
+	os.Setenv("NOISEFS_WEBUI_TLS", "true")
+	defer func() {
+		os.Unsetenv("NOISEFS_WEBUI_ADDRESS")
+		os.Unsetenv("NOISEFS_WEBUI_TLS")
+	}()
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if config.WebUI.Address != "127.0.0.1:9090" || !config.WebUI.TLS {
+		t.Errorf("Environment override failed for web UI, got %+v", config.WebUI)
+	}
+
+	config.WebUI.CertFile = "cert.pem"
+	if err := config.Validate(); err == nil {
+		t.Error("A certificate without a key should fail validation")
+	}
+
+	// Legacy nested TLS settings are migrated
+	migrated, err := MigrateFromLegacy([]byte(`{"webui": {"address": ":8443", "tls": {"enabled": true, "cert_file": "c.pem", "key_file": "k.pem"}}}`))
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if migrated.WebUI.Address != ":8443" || !migrated.WebUI.TLS || migrated.WebUI.KeyFile != "k.pem" {
+		t.Errorf("Legacy web UI settings not migrated, got %+v", migrated.WebUI)
+	}
+}
+
+func TestS3GatewayConfig(t *testing.T) {
+	os.Setenv("NOISEFS_S3_BUCKET", "backups")
+	defer os.Unsetenv("NOISEFS_S3_BUCKET")
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if config.S3Gateway.Bucket != "backups" || config.S3Gateway.Address != "127.0.0.1:9000" {
+		t.Errorf("Unexpected S3 gateway settings %+v", config.S3Gateway)
+	}
+
+	for _, bucket := range []string{"ab", "Backups", "-backups", "back..ups", "192.168.1.1", "back_ups"} {
+		config.S3Gateway.Bucket = bucket
+		if err := config.Validate(); err == nil {
+			t.Errorf("Bucket name %q should fail validation", bucket)
+		}
+	}
+	config.S3Gateway.Bucket = "my.backups-2"
+	config.S3Gateway.AccessKey = "noisefs"
+	if err := config.Validate(); err == nil {
+		t.Error("An access key without a secret key should fail validation")
+	}
+}
+
+func TestRemoteConfig(t *testing.T) {
+	t.Setenv("NOISEFS_REMOTE_ADDRESS", ":9101")
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if config.Remote.Address != ":9101" {
+		t.Errorf("Environment override failed for remote address, got %q", config.Remote.Address)
+	}
+
+	config.Remote.CertFile = "/etc/noisefs/remote.crt"
+	if err := config.Validate(); err == nil {
+		t.Error("A certificate without a key should fail validation")
+	}
+}
+
+func TestMetricsConfig(t *testing.T) {
+	config := DefaultConfig()
+	if !config.WebUI.Metrics || config.FUSE.MetricsAddress != "" {
+		t.Errorf("Expected web UI metrics on and mount metrics off by default, got %v and %q",
+			config.WebUI.Metrics, config.FUSE.MetricsAddress)
+	}
+
+	t.Setenv("NOISEFS_WEBUI_METRICS", "false")
+	t.Setenv("NOISEFS_FUSE_METRICS_ADDRESS", "127.0.0.1:9464")
+	config.applyEnvironmentOverrides()
+	if config.WebUI.Metrics || config.FUSE.MetricsAddress != "127.0.0.1:9464" {
+		t.Errorf("Environment overrides failed, got %v and %q",
+			config.WebUI.Metrics, config.FUSE.MetricsAddress)
+	}
+}
+
+func TestExplicitFlags(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.String("ipfs", "", "")
+	flagSet.Bool("readonly", false, "")
+	if err := flagSet.Parse([]string{"-readonly=false"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	set := ExplicitFlags(flagSet)
+	if !set["readonly"] || set["ipfs"] {
+		t.Errorf("Expected only readonly to be set, got %v", set)
+	}
+}
+
+func TestLoggingComponents(t *testing.T) {
+	t.Setenv("NOISEFS_LOG_COMPONENTS", "storage=debug, fuse=warn")
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if config.Logging.Components["storage"] != "debug" || config.Logging.Components["fuse"] != "warn" {
+		t.Errorf("Environment override failed for components, got %v", config.Logging.Components)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Valid component levels rejected: %v", err)
+	}
+
+	config.Logging.Components["fuse"] = "loud"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "fuse") {
+		t.Errorf("Expected invalid component level to fail, got %v", err)
+	}
+
+	config.Logging.Components = nil
+	config.Logging.SampleInitial = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Negative sampling should fail validation")
+	}
+}
+
+func TestWebUIACMEConfig(t *testing.T) {
+	t.Setenv("NOISEFS_WEBUI_ACME", "true")
+	t.Setenv("NOISEFS_WEBUI_ACME_DOMAINS", "noisefs.example.com, www.noisefs.example.com")
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if !config.WebUI.ACME || len(config.WebUI.ACMEDomains) != 2 || config.WebUI.ACMEDomains[1] != "www.noisefs.example.com" {
+		t.Errorf("Environment override failed for ACME, got %+v", config.WebUI)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Valid ACME settings rejected: %v", err)
+	}
+
+	invalid := []func(c *WebUIConfig){
+		func(c *WebUIConfig) { c.ACMEDomains = nil },
+		func(c *WebUIConfig) { c.ACMEDomains = []string{"localhost"} },
+		func(c *WebUIConfig) { c.ACMEDomains = []string{"203.0.113.7"} },
+		func(c *WebUIConfig) { c.CertFile, c.KeyFile = "cert.pem", "key.pem" },
+	}
+	for i, modify := range invalid {
+		c := DefaultConfig()
+		c.WebUI.ACME = true
+		c.WebUI.ACMEDomains = []string{"noisefs.example.com"}
+		modify(&c.WebUI)
+		if err := c.Validate(); err == nil {
+			t.Errorf("Invalid ACME config %d should fail validation", i)
+		}
+	}
+}
+
+func TestBlockConfig(t *testing.T) {
+	config := DefaultConfig()
+	policy, err := config.Blocks.SizePolicy()
+	if err != nil {
+		t.Fatalf("Default block policy rejected: %v", err)
+	}
+	if size := policy.BlockSize("movie.mkv", 10*1024*1024); size != 256*1024 {
+		t.Errorf("Media block size = %d, want %d", size, 256*1024)
+	}
+
+	t.Setenv("NOISEFS_BLOCK_POLICY", "fixed")
+	t.Setenv("NOISEFS_BLOCK_SIZE", "65536")
+	config.applyEnvironmentOverrides()
+	config.updateComputedFields()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Fixed block policy rejected: %v", err)
+	}
+	policy, _ = config.Blocks.SizePolicy()
+	if size := policy.BlockSize("movie.mkv", 10*1024*1024); size != 65536 || config.Performance.BlockSize != 65536 {
+		t.Errorf("Fixed block size = %d (performance %d), want 65536", size, config.Performance.BlockSize)
+	}
+
+	config.Blocks.DefaultSize = 100000
+	if err := config.Validate(); err == nil {
+		t.Error("A block size that isn't a power of two should fail validation")
+	}
+	config.Blocks.DefaultSize = 65536
+	config.Blocks.Policy = "random"
+	if err := config.Validate(); err == nil {
+		t.Error("An unknown block policy should fail validation")
+	}
+}
+
+func TestDedupConfig(t *testing.T) {
+	config := DefaultConfig()
+	if !config.Dedup.Enabled || !config.Dedup.Bloom || filepath.Base(config.Dedup.IndexPath) != "uploads.json" {
+		t.Errorf("Unexpected dedup defaults %+v", config.Dedup)
+	}
+	if !config.Dedup.Blocks || filepath.Base(config.Dedup.BlockIndexPath) != "blocks.jsonl" {
+		t.Errorf("Unexpected block dedup defaults %+v", config.Dedup)
+	}
+
+	t.Setenv("NOISEFS_DEDUP_ENABLED", "false")
+	t.Setenv("NOISEFS_DEDUP_INDEX_PATH", "/tmp/uploads.json")
+	t.Setenv("NOISEFS_DEDUP_BLOOM", "false")
+	t.Setenv("NOISEFS_DEDUP_BLOCKS", "false")
+	t.Setenv("NOISEFS_DEDUP_BLOCK_INDEX_PATH", "/tmp/blocks.jsonl")
+	config.applyEnvironmentOverrides()
+	if config.Dedup.Enabled || config.Dedup.Bloom || config.Dedup.IndexPath != "/tmp/uploads.json" {
+		t.Errorf("Environment overrides not applied: %+v", config.Dedup)
+	}
+	if config.Dedup.Blocks || config.Dedup.BlockIndexPath != "/tmp/blocks.jsonl" {
+		t.Errorf("Block environment overrides not applied: %+v", config.Dedup)
+	}
+
+	config.Dedup.Enabled = true
+	config.Dedup.IndexPath = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Deduplication without an index path should fail validation")
+	}
+	config.Dedup.IndexPath = "/tmp/uploads.json"
+	config.Dedup.Blocks = true
+	config.Dedup.BlockIndexPath = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Block reuse without an index path should fail validation")
+	}
+}
+
+func TestOfflineConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.Offline.Enabled || config.StorageConfig().Offline != nil {
+		t.Error("Offline spooling should be off by default")
+	}
+
+	t.Setenv("NOISEFS_OFFLINE_ENABLED", "true")
+	t.Setenv("NOISEFS_OFFLINE_SPOOL_PATH", "/tmp/spool")
+	t.Setenv("NOISEFS_OFFLINE_MAX_SIZE_MB", "64")
+	config.applyEnvironmentOverrides()
+	offline := config.StorageConfig().Offline
+	if offline == nil || offline.SpoolPath != "/tmp/spool" || offline.MaxSize != 64*1024*1024 || offline.RetryInterval != 30*time.Second {
+		t.Errorf("Unexpected storage offline config %+v", offline)
+	}
+
+	config.Offline.SpoolPath = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Offline spooling without a spool path should fail validation")
+	}
+}
+
+func TestTelemetryConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.Telemetry.Enabled {
+		t.Error("Telemetry should be off by default")
+	}
+
+	t.Setenv("NOISEFS_TELEMETRY_ENABLED", "true")
+	config.applyEnvironmentOverrides()
+	if !config.Telemetry.Enabled {
+		t.Error("NOISEFS_TELEMETRY_ENABLED should enable telemetry")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Default telemetry settings should be valid: %v", err)
+	}
+
+	config.Telemetry.IntervalHours = 1
+	if err := config.Validate(); err == nil {
+		t.Error("Reports more often than daily should fail validation")
+	}
+	config.Telemetry.IntervalHours = 168
+	config.Telemetry.Endpoint = "telemetry.example.com"
+	if err := config.Validate(); err == nil {
+		t.Error("An endpoint that isn't a URL should fail validation")
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.Update.Channel != UpdateChannelStable {
+		t.Errorf("Expected the stable channel by default, got %q", config.Update.Channel)
+	}
+
+	t.Setenv("NOISEFS_UPDATE_CHANNEL", "Beta")
+	config.applyEnvironmentOverrides()
+	if config.Update.Channel != UpdateChannelBeta {
+		t.Errorf("NOISEFS_UPDATE_CHANNEL should select the beta channel, got %q", config.Update.Channel)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Default update settings should be valid: %v", err)
+	}
+
+	config.Update.Channel = "nightly"
+	if err := config.Validate(); err == nil {
+		t.Error("An unknown channel should fail validation")
+	}
+	config.Update.Channel = UpdateChannelStable
+	config.Update.URL = "http://releases.example.com"
+	if err := config.Validate(); err == nil {
+		t.Error("Release manifests over plain HTTP should fail validation")
+	}
+}
+
+func TestCoverTrafficConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.CoverTraffic.Enabled {
+		t.Error("Cover traffic should be off by default")
+	}
+	generatorConfig, err := config.CoverTraffic.GeneratorConfig()
+	if err != nil {
+		t.Fatalf("Default cover traffic settings rejected: %v", err)
+	}
+	if generatorConfig.Bandwidth != 64*1024 || generatorConfig.Interval != 5*time.Minute || generatorConfig.ActiveHours != nil {
+		t.Errorf("Unexpected generator settings %+v", generatorConfig)
+	}
+
+	t.Setenv("NOISEFS_COVER_TRAFFIC", "true")
+	t.Setenv("NOISEFS_COVER_TRAFFIC_BANDWIDTH", "16KB")
+	config.applyEnvironmentOverrides()
+	if !config.CoverTraffic.Enabled || config.CoverTraffic.Bandwidth != "16KB" {
+		t.Errorf("Environment overrides not applied: %+v", config.CoverTraffic)
+	}
+
+	config.CoverTraffic.ActiveHours = "23:00"
+	if err := config.Validate(); err == nil {
+		t.Error("Invalid active hours should fail validation")
+	}
+	config.CoverTraffic.ActiveHours = "22:00-06:00"
+	config.CoverTraffic.Interval = 0
+	if err := config.Validate(); err == nil {
+		t.Error("A zero cover traffic interval should fail validation")
+	}
+}
+
+func TestPrivacyPresets(t *testing.T) {
+	for _, preset := range PrivacyPresets {
+		config := DefaultConfig()
+		config.Privacy.Preset = preset
+		config.applyPrivacyPreset()
+		if err := config.Validate(); err != nil {
+			t.Errorf("Preset %s does not validate: %v", preset, err)
+		}
+	}
+
+	paranoid := DefaultConfig()
+	paranoid.Privacy.Preset = PrivacyPresetParanoid
+	paranoid.applyPrivacyPreset()
+	if !paranoid.CoverTraffic.Enabled || !paranoid.RetrievalMixing.Enabled || !paranoid.IPFS.DisablePeerSelection {
+		t.Error("Paranoid preset should enable cover traffic and retrieval mixing and disable peer selection")
+	}
+	if paranoid.Network.Proxy != "socks5h://127.0.0.1:9050" || paranoid.IPFS.Embedded.ProvideStrategy != "none" {
+		t.Errorf("Unexpected paranoid proxy %q and provide strategy %q", paranoid.Network.Proxy, paranoid.IPFS.Embedded.ProvideStrategy)
+	}
+	if paranoid.Privacy.AnnounceAutoTags || paranoid.Privacy.AnnounceRealtime {
+		t.Error("Paranoid preset should announce without auto tags or PubSub")
+	}
+	if paranoid.Dedup.Blocks {
+		t.Error("Paranoid preset should not share data blocks between files")
+	}
+
+	// The embedded node can't use a proxy, so the preset leaves it unset
+	embedded := DefaultConfig()
+	embedded.IPFS.Mode = IPFSModeEmbedded
+	embedded.Privacy.Preset = PrivacyPresetParanoid
+	embedded.applyPrivacyPreset()
+	if embedded.Network.Proxy != "" {
+		t.Errorf("Expected no proxy in embedded mode, got %q", embedded.Network.Proxy)
+	}
+	if err := embedded.Validate(); err != nil {
+		t.Errorf("Paranoid preset with the embedded node rejected: %v", err)
+	}
+
+	// A preset overrides the file, and environment overrides apply on top
+	t.Setenv("NOISEFS_PRIVACY_PRESET", "performance")
+	t.Setenv("NOISEFS_COVER_TRAFFIC", "true")
+	config := DefaultConfig()
+	config.RetrievalMixing.Enabled = true
+	config.applyEnvironmentOverrides()
+	if config.Privacy.Preset != PrivacyPresetPerformance || config.RetrievalMixing.Enabled || !config.CoverTraffic.Enabled {
+		t.Errorf("Unexpected settings after preset and overrides: %+v %+v", config.RetrievalMixing, config.CoverTraffic)
+	}
+
+	config.Privacy.Preset = "stealth"
+	if err := config.Validate(); err == nil {
+		t.Error("An unknown preset should fail validation")
+	}
+}
+
+func TestRetrievalMixingConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.RetrievalMixing.Enabled {
+		t.Error("Retrieval mixing should be off by default")
+	}
+	schedulerConfig, err := config.RetrievalMixing.SchedulerConfig()
+	if err != nil {
+		t.Fatalf("Default retrieval mixing settings rejected: %v", err)
+	}
+	if schedulerConfig.MaxDelay != 100*time.Millisecond || schedulerConfig.BatchWindow != 50*time.Millisecond || schedulerConfig.Parallelism != 4 {
+		t.Errorf("Unexpected scheduler settings %+v", schedulerConfig)
+	}
+
+	t.Setenv("NOISEFS_RETRIEVAL_MIXING", "true")
+	config.applyEnvironmentOverrides()
+	if !config.RetrievalMixing.Enabled {
+		t.Error("Environment override not applied")
+	}
+
+	config.RetrievalMixing.MinDelayMs = 500
+	if err := config.Validate(); err == nil {
+		t.Error("A minimum delay above the maximum should fail validation")
+	}
+}
+
+func TestNetworkProxy(t *testing.T) {
+	t.Setenv("NOISEFS_PROXY", "socks5h://127.0.0.1:9050")
+
+	config := DefaultConfig()
+	config.applyEnvironmentOverrides()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid proxy rejected: %v", err)
+	}
+	if conn := config.IPFSConnection(); conn.Proxy != "socks5h://127.0.0.1:9050" {
+		t.Errorf("Expected the IPFS connection to use the proxy, got %q", conn.Proxy)
+	}
+	if conn := config.StorageConfig().Backends["ipfs"].Connection; conn.Proxy != config.Network.Proxy {
+		t.Errorf("Expected the storage config to use the proxy, got %q", conn.Proxy)
+	}
+
+	config.IPFS.Mode = IPFSModeEmbedded
+	if err := config.Validate(); err == nil {
+		t.Error("A proxy with the embedded IPFS node should fail validation")
+	}
+	config.IPFS.Mode = IPFSModeDaemon
+	config.Network.Proxy = "127.0.0.1:9050"
+	if err := config.Validate(); err == nil {
+		t.Error("A proxy without a scheme should fail validation")
+	}
+}
+
+func TestIPFSConnection(t *testing.T) {
+	t.Setenv("NOISEFS_IPFS_TOKEN", "s3cret")
+
+	config := DefaultConfig()
+	config.IPFS.APIEndpoint = "https://ipfs.example.com:5001"
+	config.IPFS.Headers = map[string]string{"X-Project": "noisefs"}
+	config.applyEnvironmentOverrides()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid IPFS auth settings rejected: %v", err)
+	}
+
+	conn := config.IPFS.Connection()
+	if conn.Endpoint != "https://ipfs.example.com:5001" || conn.MaxConnections == 0 {
+		t.Errorf("expected endpoint with default pool settings, got %+v", conn)
+	}
+	if conn.Auth == nil || conn.Auth.Type != "bearer" || conn.Auth.Token != "s3cret" || conn.Auth.Headers["X-Project"] != "noisefs" {
+		t.Errorf("expected bearer auth with headers, got %+v", conn.Auth)
+	}
+	if conn.TLS == nil || !conn.TLS.Enabled {
+		t.Error("expected TLS for an https endpoint")
+	}
+
+	basic := IPFSConfig{APIEndpoint: "127.0.0.1:5001", Username: "alice", Password: "pw"}
+	if conn := basic.Connection(); conn.Auth == nil || conn.Auth.Type != "basic" || conn.TLS != nil {
+		t.Errorf("expected basic auth without TLS, got %+v", conn)
+	}
+
+	invalid := []func(c *IPFSConfig){
+		func(c *IPFSConfig) { c.Username = "alice" },
+		func(c *IPFSConfig) { c.Username, c.Password = "alice", "pw" },
+		func(c *IPFSConfig) { c.Token, c.TLSCertFile = "", "client.pem" },
+	}
+	for i, modify := range invalid {
+		c := DefaultConfig()
+		c.IPFS.Token = "s3cret"
+		modify(&c.IPFS)
+		if err := c.Validate(); err == nil {
+			t.Errorf("Invalid IPFS config %d should fail validation", i)
+		}
+	}
+}
+
+func TestStorageConfigEmbeddedMode(t *testing.T) {
+	t.Setenv("NOISEFS_IPFS_MODE", "embedded")
+
+	config := DefaultConfig()
+	config.IPFS.APIEndpoint = ""
+	config.IPFS.Embedded.RepoPath = "/tmp/noisefs-ipfs"
+	config.IPFS.Embedded.BootstrapPeers = []string{}
+	config.applyEnvironmentOverrides()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Embedded mode without an API endpoint rejected: %v", err)
+	}
+
+	storageConfig := config.StorageConfig()
+	if err := storageConfig.Validate(); err != nil {
+		t.Fatalf("Embedded storage config is invalid: %v", err)
+	}
+	backend := storageConfig.Backends[storageConfig.DefaultBackend]
+	if backend == nil || backend.Type != storage.BackendTypeEmbedded {
+		t.Fatalf("expected the embedded backend by default, got %+v", storageConfig.Backends)
+	}
+	if backend.Settings[storage.EmbeddedSettingRepoPath] != "/tmp/noisefs-ipfs" {
+		t.Errorf("unexpected repo path %v", backend.Settings[storage.EmbeddedSettingRepoPath])
+	}
+	if peers, ok := backend.Settings[storage.EmbeddedSettingBootstrapPeers].([]string); !ok || len(peers) != 0 {
+		t.Errorf("expected an empty bootstrap list, got %v", backend.Settings[storage.EmbeddedSettingBootstrapPeers])
+	}
+	if backend.Settings[storage.EmbeddedSettingReprovideInterval] != 22*time.Hour {
+		t.Errorf("unexpected reprovide interval %v", backend.Settings[storage.EmbeddedSettingReprovideInterval])
+	}
+
+	config.IPFS.Embedded.ProvideStrategy = "popular"
+	if err := config.Validate(); err == nil {
+		t.Error("Unknown provide strategy should fail validation")
+	}
+	config.IPFS.Embedded.ProvideStrategy = storage.ProvideStrategyRandomizers
+
+	config.IPFS.Mode = "kubo"
+	if err := config.Validate(); err == nil {
+		t.Error("Unknown IPFS mode should fail validation")
+	}
+}
+
+func TestWebhookConfig(t *testing.T) {
+	config := DefaultConfig()
+	if len(config.Webhooks) != 0 {
+		t.Error("No webhooks should be configured by default")
+	}
+
+	config.Webhooks = []WebhookConfig{{URL: "https://example.com/hook", Events: []string{"upload.completed", "sync.completed"}}}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid webhook rejected: %v", err)
+	}
+	for _, invalid := range []WebhookConfig{
+		{URL: ""},
+		{URL: "ftp://example.com/hook"},
+		{URL: "https:///hook"},
+		{URL: "https://example.com/hook", Events: []string{"upload.started"}},
+	} {
+		config.Webhooks = []WebhookConfig{invalid}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %+v to fail validation", invalid)
+		}
+	}
+}
+
+func TestPinMaintenanceConfig(t *testing.T) {
+	config := DefaultConfig()
+	if interval, err := config.Daemon.PinMaintenanceIntervalDuration(); err != nil || interval != 0 {
+		t.Errorf("Pin maintenance should be disabled by default, got %v, %v", interval, err)
+	}
+
+	config.Daemon.PinMaintenanceInterval = "6h"
+	config.Daemon.PinSampleSize = 16
+	config.Daemon.PinAvailabilityThreshold = 0.5
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid pin maintenance settings rejected: %v", err)
+	}
+	if interval, _ := config.Daemon.PinMaintenanceIntervalDuration(); interval != 6*time.Hour {
+		t.Errorf("Expected a 6h interval, got %v", interval)
+	}
+
+	for _, invalid := range []DaemonConfig{
+		{PinMaintenanceInterval: "often"},
+		{PinMaintenanceInterval: "-1h"},
+		{PinSampleSize: -1},
+		{PinAvailabilityThreshold: 1.5},
+	} {
+		config.Daemon = invalid
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %+v to fail validation", invalid)
+		}
+	}
+}
+
+func TestProcessorConfig(t *testing.T) {
+	config := DefaultConfig()
+	if pipeline, err := config.ProcessorPipeline(); err != nil || pipeline != nil {
+		t.Errorf("No processors should be configured by default, got %v, %v", pipeline, err)
+	}
+
+	config.Processors = []ProcessorConfig{
+		{Name: "scan", Command: []string{"clamscan", "--no-summary", "-"}, FailOpen: true},
+		{Name: "checksum", Type: "checksum", Stages: []string{"upload", "download"}, Extensions: []string{".iso"}},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid processors rejected: %v", err)
+	}
+	pipeline, err := config.ProcessorPipeline()
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if !pipeline.Applies(processors.StageUpload, "a.txt") || !pipeline.Applies(processors.StageDownload, "disk.iso") || pipeline.Applies(processors.StageDownload, "a.txt") {
+		t.Error("Pipeline steps don't match the configuration")
+	}
+
+	for _, invalid := range []ProcessorConfig{
+		{Command: []string{"scan"}},
+		{Name: "both", Command: []string{"scan"}, Type: "checksum"},
+		{Name: "neither"},
+		{Name: "stage", Type: "checksum", Stages: []string{"delete"}},
+		{Name: "timeout", Type: "checksum", Timeout: -1},
+		{Name: "unknown", Type: "transcode"},
+		{Name: "settings", Type: "checksum", Settings: map[string]string{"deny": "not-a-digest"}},
+	} {
+		config.Processors = []ProcessorConfig{invalid}
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %+v to fail validation", invalid)
+		}
+	}
+	config.Processors = []ProcessorConfig{{Name: "twice", Type: "checksum"}, {Name: "twice", Type: "checksum"}}
+	if err := config.Validate(); err == nil {
+		t.Error("Duplicate processor names should fail validation")
+	}
+}
+
+func TestWebUIClamdConfig(t *testing.T) {
+	config := DefaultConfig()
+	if pipeline, err := config.WebUIProcessorPipeline(); err != nil || pipeline != nil {
+		t.Errorf("The web UI should not scan by default, got %v, %v", pipeline, err)
+	}
+
+	config.WebUI.ClamdAddress = "tcp://127.0.0.1:3310"
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Valid clamd address rejected: %v", err)
+	}
+	pipeline, err := config.WebUIProcessorPipeline()
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	if !pipeline.Applies(processors.StageDownload, "a.txt") || pipeline.Applies(processors.StageUpload, "a.txt") {
+		t.Error("The clamd scan should only run on downloads")
+	}
+
+	config.WebUI.ClamdAddress = "clamd"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an invalid clamd address to fail validation")
+	}
+
+	t.Setenv("NOISEFS_WEBUI_CLAMD_ADDRESS", "/var/run/clamav/clamd.ctl")
+	t.Setenv("NOISEFS_WEBUI_CLAMD_FAIL_OPEN", "true")
+	config.applyEnvironmentOverrides()
+	if config.WebUI.ClamdAddress != "/var/run/clamav/clamd.ctl" || !config.WebUI.ClamdFailOpen {
+		t.Errorf("Environment overrides not applied: %+v", config.WebUI)
+	}
+}
+
+func TestWebUITransferConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.WebUI.TransferConcurrency = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative transfer concurrency to fail validation")
+	}
+
+	config.WebUI.TransferConcurrency = 0
+	config.WebUI.TransferBandwidth = "fast"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an invalid transfer bandwidth to fail validation")
+	}
+
+	t.Setenv("NOISEFS_WEBUI_TRANSFER_CONCURRENCY", "4")
+	t.Setenv("NOISEFS_WEBUI_TRANSFER_BANDWIDTH", "2MB")
+	config.applyEnvironmentOverrides()
+	if config.WebUI.TransferConcurrency != 4 {
+		t.Errorf("Expected transfer concurrency 4, got %d", config.WebUI.TransferConcurrency)
+	}
+	if bandwidth, err := config.WebUI.TransferBandwidthBytes(); err != nil || bandwidth != 2*1024*1024 {
+		t.Errorf("Expected a transfer bandwidth of 2MB, got %d, %v", bandwidth, err)
+	}
+}
+
+func TestWebUIHTTPConfig(t *testing.T) {
+	config := DefaultConfig()
+	if !config.WebUI.HTTP2 || config.WebUI.H2C {
+		t.Errorf("Expected HTTP/2 over TLS only by default, got %+v", config.WebUI)
+	}
+
+	config.WebUI.H2C = true
+	config.WebUI.TLS = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected h2c with TLS to fail validation")
+	}
+
+	config.WebUI.TLS = false
+	config.WebUI.WriteBufferSize = "big"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an invalid write buffer size to fail validation")
+	}
+
+	t.Setenv("NOISEFS_WEBUI_HTTP2", "false")
+	t.Setenv("NOISEFS_WEBUI_IDLE_TIMEOUT", "300")
+	t.Setenv("NOISEFS_WEBUI_WRITE_BUFFER_SIZE", "1MB")
+	config.applyEnvironmentOverrides()
+	if config.WebUI.HTTP2 || config.WebUI.IdleTimeout != 300 {
+		t.Errorf("Environment overrides not applied: %+v", config.WebUI)
+	}
+	if size, err := config.WebUI.WriteBufferBytes(); err != nil || size != 1024*1024 {
+		t.Errorf("Expected a 1MB write buffer, got %d, %v", size, err)
+	}
+}
+
+func TestWebUIGatewayConfig(t *testing.T) {
+	config := DefaultConfig()
+	if config.WebUI.Gateway.Enabled || config.WebUI.Gateway.DownloadQuotaMB != 1024 || config.WebUI.Gateway.QuotaWindowHours != 24 {
+		t.Errorf("Unexpected gateway defaults %+v", config.WebUI.Gateway)
+	}
+
+	t.Setenv("NOISEFS_WEBUI_GATEWAY", "true")
+	t.Setenv("NOISEFS_WEBUI_GATEWAY_QUOTA_MB", "256")
+	config.applyEnvironmentOverrides()
+	if !config.WebUI.Gateway.Enabled || config.WebUI.Gateway.DownloadQuotaMB != 256 {
+		t.Errorf("Environment overrides not applied: %+v", config.WebUI.Gateway)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected gateway mode to validate: %v", err)
+	}
+
+	config.WebUI.Gateway.QuotaWindowHours = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a quota without a window to fail validation")
+	}
+	config.WebUI.Gateway.DownloadQuotaMB = 0
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a gateway without a quota to validate: %v", err)
+	}
+}
+
+func TestRateLimitByTokenNeedsTokens(t *testing.T) {
+	config := DefaultConfig()
+	config.Security.RateLimitByToken = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected rate_limit_by_token without require_tokens to fail validation")
+	}
+	config.WebUI.RequireTokens = true
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected rate_limit_by_token with require_tokens to validate: %v", err)
+	}
+}
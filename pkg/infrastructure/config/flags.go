@@ -0,0 +1,14 @@
+package config
+
+import "flag"
+
+// ExplicitFlags returns the names of the flags given on the command line.
+// Binaries let a flag override the configuration only when it was set, so
+// the config file and NOISEFS_* variables apply otherwise.
+func ExplicitFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ReloadableKeys lists the settings a running process applies on reload
+// (SIGHUP or a change to its configuration file). Changes to any other key
+// are reported but only take effect after a restart.
+var ReloadableKeys = []string{
+	"logging.level",
+	"logging.components",
+	"logging.sample_initial",
+	"logging.sample_thereafter",
+	"cache.block_cache_size",
+	"network.max_bandwidth",
+}
+
+// reloadSetters copy each reloadable key from a freshly loaded configuration
+var reloadSetters = map[string]func(dst, src *Config){
+	"logging.level":             func(dst, src *Config) { dst.Logging.Level = src.Logging.Level },
+	"logging.components":        func(dst, src *Config) { dst.Logging.Components = src.Logging.Components },
+	"logging.sample_initial":    func(dst, src *Config) { dst.Logging.SampleInitial = src.Logging.SampleInitial },
+	"logging.sample_thereafter": func(dst, src *Config) { dst.Logging.SampleThereafter = src.Logging.SampleThereafter },
+	"cache.block_cache_size":    func(dst, src *Config) { dst.Cache.BlockCacheSize = src.Cache.BlockCacheSize },
+	"network.max_bandwidth":     func(dst, src *Config) { dst.Network.MaxBandwidth = src.Network.MaxBandwidth },
+}
+
+// reloadDebounce coalesces the burst of events editors produce when saving
+const reloadDebounce = 500 * time.Millisecond
+
+// ReloadFunc applies the reloadable settings of an updated configuration
+type ReloadFunc func(cfg *Config) error
+
+// ReloadResult describes the outcome of a reload
+type ReloadResult struct {
+	Applied         []string // Reloadable keys that changed and were applied
+	RestartRequired []string // Keys that changed but need a restart
+}
+
+// Reloader re-reads a configuration file and applies safe-to-change
+// settings to a running process
+type Reloader struct {
+	path     string
+	mu       sync.Mutex
+	current  *Config
+	handlers []ReloadFunc
+}
+
+// NewReloader creates a reloader for the configuration loaded from path
+func NewReloader(path string, current *Config) *Reloader {
+	return &Reloader{
+		path:    path,
+		current: current,
+	}
+}
+
+// OnReload registers a handler called with the updated configuration
+// whenever a reload changes at least one reloadable key
+func (r *Reloader) OnReload(fn ReloadFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, fn)
+}
+
+// Current returns the configuration in effect
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the configuration file again. An invalid file leaves the
+// current configuration untouched.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	loaded, err := LoadConfig(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &ReloadResult{}
+	next := *r.current
+	for _, key := range changedKeys(r.current, loaded) {
+		if setter, ok := reloadSetters[key]; ok {
+			setter(&next, loaded)
+			result.Applied = append(result.Applied, key)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+	next.updateComputedFields()
+
+	var errs []error
+	for _, handler := range r.handlers {
+		if err := handler(&next); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.current = &next
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("failed to apply reloaded configuration: %w", errors.Join(errs...))
+	}
+	return result, nil
+}
+
+// Watch reloads on SIGHUP and whenever the configuration file changes,
+// reporting each attempt to report. It blocks until ctx is cancelled.
+func (r *Reloader) Watch(ctx context.Context, report func(*ReloadResult, error)) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// Watch the directory rather than the file so replacements made by
+	// editors and deployment tools (write to temp, rename) are seen
+	var fileEvents chan fsnotify.Event
+	var fileErrors chan error
+	if r.path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create config watcher: %w", err)
+		}
+		defer watcher.Close()
+
+		if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+			return fmt.Errorf("failed to watch config directory: %w", err)
+		}
+		fileEvents = watcher.Events
+		fileErrors = watcher.Errors
+	}
+
+	target := filepath.Clean(r.path)
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hangup:
+			report(r.Reload())
+		case event := <-fileEvents:
+			if filepath.Clean(event.Name) == target && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce.Reset(reloadDebounce)
+			}
+		case err := <-fileErrors:
+			report(nil, fmt.Errorf("config watcher error: %w", err))
+		case <-debounce.C:
+			report(r.Reload())
+		}
+	}
+}
+
+// changedKeys returns the "section.key" names whose values differ
+func changedKeys(a, b *Config) []string {
+	flatA, flatB := flattenConfig(a), flattenConfig(b)
+
+	var changed []string
+	for key, value := range flatA {
+		if other, ok := flatB[key]; !ok || !reflect.DeepEqual(value, other) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range flatB {
+		if _, ok := flatA[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// flattenConfig maps the serialized configuration to "section.key" values
+func flattenConfig(c *Config) map[string]interface{} {
+	flat := make(map[string]interface{})
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return flat
+	}
+	var sections map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return flat
+	}
+
+	for section, values := range sections {
+		for key, value := range values {
+			flat[section+"."+key] = value
+		}
+	}
+	return flat
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretScheme prefixes configuration values that reference a secret rather
+// than contain it, e.g. "secret://keyring/webui-token"
+const SecretScheme = "secret://"
+
+// SecretKeyringService is the service name secrets are stored under in the
+// operating system keyring
+const SecretKeyringService = "noisefs"
+
+// secretCommandTimeout bounds how long an external secret command may run
+const secretCommandTimeout = 10 * time.Second
+
+// SecretProvider looks up a secret by name
+type SecretProvider interface {
+	Lookup(name string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider
+type SecretProviderFunc func(name string) (string, error)
+
+// Lookup calls f(name)
+func (f SecretProviderFunc) Lookup(name string) (string, error) {
+	return f(name)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":     SecretProviderFunc(lookupEnvSecret),
+		"file":    SecretProviderFunc(lookupFileSecret),
+		"keyring": SecretProviderFunc(lookupKeyringSecret),
+	}
+)
+
+// RegisterSecretProvider makes a provider available as secret://<name>/...,
+// replacing any provider already registered under that name
+func RegisterSecretProvider(name string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[name] = provider
+}
+
+// IsSecretReference reports whether a configuration value references a secret
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, SecretScheme)
+}
+
+// ResolveSecret returns the secret a reference points to. Values that are
+// not references are returned unchanged. secretCommand is the external
+// command used by secret://command/<name> references.
+func ResolveSecret(value, secretCommand string) (string, error) {
+	if !IsSecretReference(value) {
+		return value, nil
+	}
+
+	providerName, name, ok := strings.Cut(strings.TrimPrefix(value, SecretScheme), "/")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference %q: expected secret://<provider>/<name>", value)
+	}
+
+	secretProvidersMu.RLock()
+	provider, exists := secretProviders[providerName]
+	secretProvidersMu.RUnlock()
+
+	if !exists && providerName == "command" {
+		provider, exists = newCommandSecretProvider(secretCommand), true
+	}
+	if !exists {
+		return "", fmt.Errorf("unknown secret provider %q in %s", providerName, value)
+	}
+
+	secret, err := provider.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+	return secret, nil
+}
+
+// resolveSecrets replaces every secret reference in the configuration with
+// its value, remembering the references so they are written back on save
+func (c *Config) resolveSecrets() error {
+	c.secretRefs = nil
+	return c.walkStrings(func(key string, field reflect.Value) error {
+		ref := field.String()
+		if !IsSecretReference(ref) {
+			return nil
+		}
+
+		secret, err := ResolveSecret(ref, c.Security.SecretCommand)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if c.secretRefs == nil {
+			c.secretRefs = make(map[string]string)
+		}
+		c.secretRefs[key] = ref
+		field.SetString(secret)
+		return nil
+	})
+}
+
+// Redacted returns a copy of the configuration with resolved secrets
+// replaced by the references they were loaded from
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if len(c.secretRefs) == 0 {
+		return &redacted
+	}
+	// Slices are shared with c, so copy them before restoring references
+	redacted.Webhooks = append([]WebhookConfig(nil), c.Webhooks...)
+	redacted.Processors = append([]ProcessorConfig(nil), c.Processors...)
+
+	redacted.walkStrings(func(key string, field reflect.Value) error {
+		if ref, ok := c.secretRefs[key]; ok {
+			field.SetString(ref)
+		}
+		return nil
+	})
+	return &redacted
+}
+
+// walkStrings calls fn for every serialized string field, keyed by
+// "section.key" as in the configuration file
+func (c *Config) walkStrings(fn func(key string, field reflect.Value) error) error {
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section, ok := jsonName(root.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		switch values := root.Field(i); {
+		case values.Kind() == reflect.Struct:
+			if err := walkSection(section, values, fn); err != nil {
+				return err
+			}
+		case values.Kind() == reflect.Slice && values.Type().Elem().Kind() == reflect.Struct:
+			// Lists of sections, such as webhooks, are keyed "section[i].key"
+			for j := 0; j < values.Len(); j++ {
+				if err := walkSection(fmt.Sprintf("%s[%d]", section, j), values.Index(j), fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// walkSection calls fn for the string fields of one section
+func walkSection(section string, values reflect.Value, fn func(key string, field reflect.Value) error) error {
+	for j := 0; j < values.NumField(); j++ {
+		key, ok := jsonName(values.Type().Field(j))
+		if !ok || values.Field(j).Kind() != reflect.String {
+			continue
+		}
+		if err := fn(section+"."+key, values.Field(j)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonName returns the serialized name of a struct field
+func jsonName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// lookupEnvSecret reads secret://env/<VARIABLE>
+func lookupEnvSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// lookupFileSecret reads secret://file/<path>; use secret://file//abs/path
+// for absolute paths. A single trailing newline is ignored.
+func lookupFileSecret(name string) (string, error) {
+	path := name
+	if strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(homeDir, path[2:])
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// lookupKeyringSecret reads secret://keyring/<account> from the operating
+// system keyring under the "noisefs" service
+func lookupKeyringSecret(name string) (string, error) {
+	var args []string
+	switch runtime.GOOS {
+	case "darwin":
+		args = []string{"security", "find-generic-password", "-s", SecretKeyringService, "-a", name, "-w"}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		args = []string{"secret-tool", "lookup", "service", SecretKeyringService, "account", name}
+	default:
+		return "", fmt.Errorf("keyring secrets are not supported on %s; use env, file or command", runtime.GOOS)
+	}
+
+	return runSecretCommand(args)
+}
+
+// newCommandSecretProvider resolves secret://command/<name> by running
+// command with the name appended as its last argument
+func newCommandSecretProvider(command string) SecretProvider {
+	return SecretProviderFunc(func(name string) (string, error) {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return "", fmt.Errorf("no secret command configured; set security.secret_command or NOISEFS_SECRET_COMMAND")
+		}
+		return runSecretCommand(append(args, name))
+	})
+}
+
+// runSecretCommand runs args and returns its trimmed standard output
+func runSecretCommand(args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", args[0], err)
+	}
+
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
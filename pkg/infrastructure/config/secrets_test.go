@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("file-token\n"), 0600)
+	t.Setenv("NOISEFS_TEST_SECRET", "env-token")
+
+	RegisterSecretProvider("test", SecretProviderFunc(func(name string) (string, error) {
+		return "test-" + name, nil
+	}))
+
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{"plain value", "plain value"},
+		{"secret://env/NOISEFS_TEST_SECRET", "env-token"},
+		{"secret://file/" + tokenFile, "file-token"},
+		{"secret://command/webui-token", "lookup webui-token"},
+		{"secret://test/custom", "test-custom"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveSecret(tt.ref, "echo lookup")
+		if err != nil {
+			t.Errorf("ResolveSecret(%s) failed: %v", tt.ref, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ResolveSecret(%s) = %q, want %q", tt.ref, got, tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"secret://env/NOISEFS_TEST_UNSET", "secret://vault/token", "secret://env", "secret://command/token"} {
+		if _, err := ResolveSecret(invalid, ""); err == nil {
+			t.Errorf("Expected %s to fail", invalid)
+		}
+	}
+}
+
+func TestLoadConfig_Secrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+	t.Setenv("NOISEFS_TEST_INDEX_PASSWORD", "hunter2")
+
+	os.WriteFile(configPath, []byte(`{
+  "security": {
+    "enable_encryption": true,
+    "require_password": true,
+    "index_password": "secret://env/NOISEFS_TEST_INDEX_PASSWORD"
+  },
+  "webui": {"address": ":8080", "poll_interval_seconds": 30, "key_file": "secret://command/tls-key", "cert_file": "cert.pem"}
+}`), 0644)
+	t.Setenv("NOISEFS_SECRET_COMMAND", "echo PEM")
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Security.IndexPassword != "hunter2" {
+		t.Errorf("Expected resolved index password, got %q", config.Security.IndexPassword)
+	}
+	if config.WebUI.KeyFile != "PEM tls-key" {
+		t.Errorf("Expected resolved key, got %q", config.WebUI.KeyFile)
+	}
+
+	// Saving writes the references back, never the secrets
+	savedPath := filepath.Join(tmpDir, "saved.json")
+	if err := config.SaveToFile(savedPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	saved, _ := os.ReadFile(savedPath)
+	if strings.Contains(string(saved), "hunter2") || !strings.Contains(string(saved), "secret://env/NOISEFS_TEST_INDEX_PASSWORD") {
+		t.Errorf("Saved config leaked a secret:\n%s", saved)
+	}
+
+	redacted, _ := json.Marshal(config.Redacted())
+	if strings.Contains(string(redacted), "hunter2") {
+		t.Error("Redacted config leaked a secret")
+	}
+	if config.Security.IndexPassword != "hunter2" {
+		t.Error("Redacted should not modify the original config")
+	}
+
+	// An unresolvable reference fails the load
+	os.WriteFile(configPath, []byte(`{"security": {"index_password": "secret://env/NOISEFS_TEST_MISSING"}}`), 0644)
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "security.index_password") {
+		t.Errorf("Expected error naming the key, got %v", err)
+	}
+}
+
+func TestLoadConfig_WebhookSecrets(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("NOISEFS_TEST_WEBHOOK_SECRET", "s3cret")
+
+	os.WriteFile(configPath, []byte(`{
+  "webhooks": [
+    {"url": "https://example.com/a", "secret": "secret://env/NOISEFS_TEST_WEBHOOK_SECRET"},
+    {"url": "https://example.com/b"}
+  ]
+}`), 0644)
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Webhooks[0].Secret != "s3cret" {
+		t.Errorf("Expected resolved webhook secret, got %q", config.Webhooks[0].Secret)
+	}
+
+	redacted, _ := json.Marshal(config.Redacted())
+	if strings.Contains(string(redacted), "s3cret") {
+		t.Error("Redacted config leaked a webhook secret")
+	}
+	if config.Webhooks[0].Secret != "s3cret" {
+		t.Error("Redacted should not modify the original config")
+	}
+
+	os.WriteFile(configPath, []byte(`{"webhooks": [{"url": "https://example.com/a", "secret": "secret://env/NOISEFS_TEST_MISSING"}]}`), 0644)
+	if _, err := LoadConfig(configPath); err == nil || !strings.Contains(err.Error(), "webhooks[0].secret") {
+		t.Errorf("Expected error naming the key, got %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package control
+
+import (
+	"context"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/health"
+)
+
+// RegisterHealthHandlers adds the "healthz" and "readyz" commands, which
+// return the liveness and readiness reports of checker
+func RegisterHealthHandlers(s *Server, checker *health.Checker) {
+	s.Handle("healthz", func(args []string) (interface{}, error) {
+		return checker.Live(context.Background()), nil
+	})
+	s.Handle("readyz", func(args []string) (interface{}, error) {
+		return checker.Ready(context.Background()), nil
+	})
+}
@@ -0,0 +1,26 @@
+package control
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+)
+
+// RegisterHistoryHandlers adds the "history" command, which returns the
+// metrics history of a client. An optional argument limits it to the last
+// that many minutes.
+func RegisterHistoryHandlers(s *Server, history *noisefs.MetricsHistory) {
+	s.Handle("history", func(args []string) (interface{}, error) {
+		var since time.Time
+		if len(args) > 0 {
+			minutes, err := strconv.Atoi(args[0])
+			if err != nil || minutes <= 0 {
+				return nil, fmt.Errorf("invalid number of minutes %q", args[0])
+			}
+			since = time.Now().Add(-time.Duration(minutes) * time.Minute)
+		}
+		return history.Report(since), nil
+	})
+}
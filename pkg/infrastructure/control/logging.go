@@ -0,0 +1,85 @@
+package control
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// LogLevels reports the levels of a running process
+type LogLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+	Sampled    uint64            `json:"sampled"`
+}
+
+// RegisterLogLevelHandlers adds the "log-level" command, which changes the
+// levels of logger and returns the levels in effect. Each argument is either
+// a base level ("info") or a component override ("storage=debug"); an
+// override of "reset" removes it. No arguments only reports the levels.
+func RegisterLogLevelHandlers(s *Server, logger *logging.Logger) {
+	s.Handle("log-level", func(args []string) (interface{}, error) {
+		if err := applyLogLevels(logger, args); err != nil {
+			return nil, err
+		}
+		return currentLogLevels(logger), nil
+	})
+}
+
+// applyLogLevels validates every argument before changing anything
+func applyLogLevels(logger *logging.Logger, args []string) error {
+	type change struct {
+		component string
+		level     logging.LogLevel
+		reset     bool
+	}
+
+	var changes []change
+	for _, arg := range args {
+		component, levelName, isComponent := strings.Cut(arg, "=")
+		if !isComponent {
+			component, levelName = "", arg
+		} else if component == "" {
+			return fmt.Errorf("invalid argument %q: expected level or component=level", arg)
+		}
+
+		if isComponent && levelName == "reset" {
+			changes = append(changes, change{component: component, reset: true})
+			continue
+		}
+		level, err := logging.ParseLogLevel(levelName)
+		if err != nil {
+			return fmt.Errorf("invalid argument %q: %w", arg, err)
+		}
+		changes = append(changes, change{component: component, level: level})
+	}
+
+	for _, c := range changes {
+		switch {
+		case c.reset:
+			logger.ResetComponentLevel(c.component)
+		case c.component == "":
+			logger.SetLevel(c.level)
+		default:
+			logger.SetComponentLevel(c.component, c.level)
+		}
+	}
+	return nil
+}
+
+// currentLogLevels snapshots the levels of logger
+func currentLogLevels(logger *logging.Logger) LogLevels {
+	base, overrides := logger.Levels()
+
+	components := make(map[string]string, len(overrides))
+	for component, level := range overrides {
+		components[component] = strings.ToLower(level.String())
+	}
+
+	return LogLevels{
+		Level:      strings.ToLower(base.String()),
+		Components: components,
+		Sampled:    logger.SampledCount(),
+	}
+}
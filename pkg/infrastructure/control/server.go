@@ -0,0 +1,248 @@
+// Package control provides a local Unix socket through which a running
+// NoiseFS process can be inspected and adjusted, e.g. by noisefs log-level.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+)
+
+// requestTimeout bounds how long a client may take to send its request
+const requestTimeout = 10 * time.Second
+
+// Request is a single command sent over the control socket. The version
+// fields are empty in requests from clients older than version checks.
+type Request struct {
+	Command     string   `json:"command"`
+	Args        []string `json:"args,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Protocol    int      `json:"protocol,omitempty"`
+	MinProtocol int      `json:"min_protocol,omitempty"`
+}
+
+// Response is the reply to a Request, carrying the version of the server
+// so clients can tell an incompatible daemon from a failing one
+type Response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Version     string          `json:"version,omitempty"`
+	Protocol    int             `json:"protocol,omitempty"`
+	MinProtocol int             `json:"min_protocol,omitempty"`
+}
+
+// HandlerFunc handles a command and returns a JSON-encodable result
+type HandlerFunc func(args []string) (interface{}, error)
+
+// Server answers control requests on a Unix socket. Each connection carries
+// one JSON request line and receives one JSON response line.
+type Server struct {
+	path     string
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a control server for the socket at path
+func NewServer(path string) *Server {
+	s := &Server{
+		path:     path,
+		handlers: make(map[string]HandlerFunc),
+	}
+	s.Handle("commands", func(args []string) (interface{}, error) {
+		return s.commands(), nil
+	})
+	s.Handle("version", func(args []string) (interface{}, error) {
+		return version.Current(), nil
+	})
+	return s
+}
+
+// Handle registers the handler for a command, replacing any existing one
+func (s *Server) Handle(command string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[command] = handler
+}
+
+// Start listens on the socket and serves requests in the background. A stale
+// socket left by a process that exited is replaced; one still in use is not.
+func (s *Server) Start() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.DialTimeout("unix", s.path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket %s is already in use", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(s.path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+
+	s.listener = listener
+	s.wg.Add(1)
+	go s.serve()
+	return nil
+}
+
+// Close stops the server and removes the socket
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.wg.Wait()
+	os.Remove(s.path)
+	return err
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+// handleConn answers the single request on conn
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req Request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else {
+		resp = s.dispatch(req)
+	}
+	current := version.Current()
+	resp.Version, resp.Protocol, resp.MinProtocol = current.Version, current.Protocol, current.MinProtocol
+
+	data, _ := json.Marshal(resp)
+	conn.Write(append(data, '\n'))
+}
+
+// dispatch runs the handler for a request from a compatible client
+func (s *Server) dispatch(req Request) Response {
+	client := version.Info{Version: req.Version, Protocol: req.Protocol, MinProtocol: req.MinProtocol}
+	if err := version.Check("client", client); err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	s.mu.RLock()
+	handler, ok := s.handlers[req.Command]
+	s.mu.RUnlock()
+
+	if !ok {
+		return Response{Error: fmt.Sprintf("unknown command %q", req.Command)}
+	}
+
+	result, err := handler(req.Args)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{Error: fmt.Sprintf("failed to encode result: %v", err)}
+	}
+	return Response{OK: true, Result: data}
+}
+
+// commands lists the registered command names
+func (s *Server) commands() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.handlers))
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call sends a command to the control socket at path and decodes the result
+// into result, which may be nil. A daemon speaking an incompatible protocol
+// returns a *version.IncompatibleError.
+func Call(path, command string, args []string, result interface{}) error {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to control socket %s: %w", path, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	current := version.Current()
+	data, err := json.Marshal(Request{
+		Command:     command,
+		Args:        args,
+		Version:     current.Version,
+		Protocol:    current.Protocol,
+		MinProtocol: current.MinProtocol,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	daemon := version.Info{Version: resp.Version, Protocol: resp.Protocol, MinProtocol: resp.MinProtocol}
+	if err := version.Check("daemon", daemon); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+	if result != nil && len(resp.Result) > 0 {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/transfers"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+func TestServerCall(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	server := NewServer(socketPath)
+	server.Handle("echo", func(args []string) (interface{}, error) {
+		return args, nil
+	})
+	server.Handle("fail", func(args []string) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Socket not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode 0600, got %v", info.Mode().Perm())
+	}
+
+	var echoed []string
+	if err := Call(socketPath, "echo", []string{"a", "b"}, &echoed); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(echoed) != 2 || echoed[1] != "b" {
+		t.Errorf("Unexpected result: %v", echoed)
+	}
+
+	if err := Call(socketPath, "fail", nil, nil); err == nil || err.Error() != "boom" {
+		t.Errorf("Expected handler error, got %v", err)
+	}
+	if err := Call(socketPath, "missing", nil, nil); err == nil {
+		t.Error("Expected unknown command to fail")
+	}
+
+	// A second server must not take over a socket in use
+	if err := NewServer(socketPath).Start(); err == nil {
+		t.Error("Expected starting on a live socket to fail")
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	server := NewServer(socketPath)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	var info version.Info
+	if err := Call(socketPath, "version", nil, &info); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if info.Protocol != version.Protocol {
+		t.Errorf("Expected protocol %d, got %d", version.Protocol, info.Protocol)
+	}
+
+	// Clients speaking only a newer protocol are refused
+	resp := server.dispatch(Request{Command: "commands", Protocol: version.Protocol + 1, MinProtocol: version.Protocol + 1})
+	if resp.OK {
+		t.Error("Expected a request from an incompatible client to be refused")
+	}
+}
+
+func TestCallIncompatibleDaemon(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Read(make([]byte, 4096))
+		conn.Write([]byte(`{"ok":true,"result":{"layout":"v9"},"version":"v9.0.0","protocol":9,"min_protocol":9}` + "\n"))
+	}()
+
+	var incompatible *version.IncompatibleError
+	if err := Call(socketPath, "commands", nil, &[]string{}); !errors.As(err, &incompatible) {
+		t.Errorf("Expected an IncompatibleError instead of a decode error, got %v", err)
+	}
+}
+
+func TestServerReplacesStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	os.WriteFile(socketPath, nil, 0600)
+
+	server := NewServer(socketPath)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start over stale socket: %v", err)
+	}
+	server.Close()
+
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Error("Close should remove the socket")
+	}
+}
+
+func TestLogLevelHandlers(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	logger := logging.NewLogger(&logging.Config{Level: logging.InfoLevel, Format: logging.TextFormat, Output: &bytes.Buffer{}})
+
+	server := NewServer(socketPath)
+	RegisterLogLevelHandlers(server, logger)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	var levels LogLevels
+	if err := Call(socketPath, "log-level", []string{"warn", "storage=debug", "fuse=error"}, &levels); err != nil {
+		t.Fatalf("Failed to set levels: %v", err)
+	}
+	if levels.Level != "warn" || levels.Components["storage"] != "debug" || levels.Components["fuse"] != "error" {
+		t.Errorf("Unexpected levels: %+v", levels)
+	}
+	if !logger.WithComponent("storage").IsEnabled(logging.DebugLevel) {
+		t.Error("Storage debug logging should be enabled")
+	}
+
+	var reset LogLevels
+	if err := Call(socketPath, "log-level", []string{"fuse=reset"}, &reset); err != nil {
+		t.Fatalf("Failed to reset level: %v", err)
+	}
+	if _, ok := reset.Components["fuse"]; ok {
+		t.Error("Expected fuse override to be removed")
+	}
+
+	// An invalid argument changes nothing
+	if err := Call(socketPath, "log-level", []string{"error", "storage=loud"}, nil); err == nil {
+		t.Error("Expected invalid level to fail")
+	}
+	if base, _ := logger.Levels(); base != logging.WarnLevel {
+		t.Errorf("Invalid request should not change the base level, got %v", base)
+	}
+}
+
+func TestHistoryHandlers(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	history := noisefs.NewMetricsHistory(time.Hour, time.Minute)
+	now := time.Now()
+	history.Record(now.Add(-30*time.Minute), noisefs.MetricsSnapshot{})
+	history.Record(now.Add(-20*time.Minute), noisefs.MetricsSnapshot{TotalUploads: 2})
+	history.Record(now.Add(-time.Minute), noisefs.MetricsSnapshot{TotalUploads: 3})
+
+	server := NewServer(socketPath)
+	RegisterHistoryHandlers(server, history)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	var report noisefs.HistoryReport
+	if err := Call(socketPath, "history", nil, &report); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(report.Samples) != 2 || report.IntervalSeconds != 60 || report.WindowMinutes != 60 {
+		t.Errorf("Unexpected history %+v", report)
+	}
+
+	if err := Call(socketPath, "history", []string{"10"}, &report); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(report.Samples) != 1 || report.Samples[0].Uploads != 1 {
+		t.Errorf("Expected the last 10 minutes to hold one upload, got %+v", report.Samples)
+	}
+	if err := Call(socketPath, "history", []string{"soon"}, nil); err == nil {
+		t.Error("Expected an invalid number of minutes to fail")
+	}
+}
+
+// idleRunner fails every transfer; the test's manager never starts them
+type idleRunner struct{}
+
+func (idleRunner) Download(ctx context.Context, descriptorCID, password, path string, progress util.ProgressReporter) (transfers.Result, error) {
+	return transfers.Result{}, errors.New("not implemented")
+}
+
+func (idleRunner) Upload(ctx context.Context, path, name string, progress util.ProgressReporter) (transfers.Result, error) {
+	return transfers.Result{}, errors.New("not implemented")
+}
+
+func TestTransferHandlers(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	manager, err := transfers.NewManager(idleRunner{}, transfers.Config{DownloadDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(socketPath)
+	RegisterTransferHandlers(server, manager)
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	var added transfers.Transfer
+	if err := Call(socketPath, "transfers", []string{"add", "kind=download", "cid=QmTest", "priority=3"}, &added); err != nil {
+		t.Fatalf("Failed to add transfer: %v", err)
+	}
+	if added.ID == "" || added.State != transfers.StateQueued || added.Priority != 3 {
+		t.Errorf("Unexpected transfer: %+v", added)
+	}
+
+	var paused transfers.Transfer
+	if err := Call(socketPath, "transfers", []string{"pause", added.ID}, &paused); err != nil || paused.State != transfers.StatePaused {
+		t.Errorf("Failed to pause: %+v, %v", paused, err)
+	}
+	if err := Call(socketPath, "transfers", []string{"priority", added.ID, "9"}, nil); err != nil {
+		t.Errorf("Failed to set priority: %v", err)
+	}
+	var limited transfers.Transfer
+	if err := Call(socketPath, "transfers", []string{"bandwidth", added.ID, "1MB"}, &limited); err != nil || limited.BandwidthLimit != 1<<20 {
+		t.Errorf("Failed to limit bandwidth: %+v, %v", limited, err)
+	}
+	var scheduled transfers.Transfer
+	if err := Call(socketPath, "transfers", []string{"schedule", added.ID, "2030-01-02T03:04:05Z"}, &scheduled); err != nil ||
+		scheduled.State != transfers.StateQueued || scheduled.StartAt == nil || scheduled.StartAt.Year() != 2030 {
+		t.Errorf("Failed to schedule: %+v, %v", scheduled, err)
+	}
+	var list []transfers.Transfer
+	if err := Call(socketPath, "transfers", nil, &list); err != nil || len(list) != 1 || list[0].Priority != 9 {
+		t.Errorf("Unexpected list: %+v, %v", list, err)
+	}
+
+	for _, args := range [][]string{
+		{"add", "kind=download"},
+		{"add", "cid"},
+		{"pause"},
+		{"remove", added.ID}, // Not finished
+		{"resume", "missing"},
+		{"priority", added.ID, "high"},
+		{"bandwidth", added.ID, "fast"},
+		{"schedule", added.ID, "tonight"},
+		{"add", "kind=download", "cid=QmTest", "start=02:00"},
+		{"restart", added.ID},
+	} {
+		if err := Call(socketPath, "transfers", args, nil); err == nil {
+			t.Errorf("Expected %v to fail", args)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package control
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/transfers"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
+)
+
+// RegisterTransferHandlers adds the "transfers" command, which manages the
+// transfer queue of manager. The first argument is the action:
+//
+//	list                             the transfers, in queue order
+//	add kind=download cid=... [path=... password=... priority=N]
+//	add kind=upload path=... [name=... priority=N]
+//	pause|resume|cancel|remove <id>
+//	priority <id> <N>
+//	bandwidth <id> <size per second, 0 for no cap>
+//	schedule <id> <RFC 3339 time|now>
+//
+// Both forms of add also take bandwidth=<size> and start=<RFC 3339 time>.
+// Paths are used as given by the process owning the socket, so clients
+// should send absolute ones. Actions other than list and remove return the
+// transfer.
+func RegisterTransferHandlers(s *Server, manager *transfers.Manager) {
+	s.Handle("transfers", func(args []string) (interface{}, error) {
+		if len(args) == 0 || args[0] == "list" {
+			return manager.List(), nil
+		}
+		action, args := args[0], args[1:]
+
+		switch action {
+		case "add":
+			req, err := parseTransferRequest(args)
+			if err != nil {
+				return nil, err
+			}
+			return manager.Add(req)
+		case "priority":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("usage: priority <id> <priority>")
+			}
+			priority, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority %q", args[1])
+			}
+			return manager.SetPriority(args[0], priority)
+		case "bandwidth":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("usage: bandwidth <id> <size per second>")
+			}
+			bandwidth, err := util.ParseSize(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid bandwidth %q: %w", args[1], err)
+			}
+			return manager.SetBandwidthLimit(args[0], bandwidth)
+		case "schedule":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("usage: schedule <id> <time|now>")
+			}
+			var at time.Time
+			if args[1] != "now" {
+				var err error
+				if at, err = time.Parse(time.RFC3339, args[1]); err != nil {
+					return nil, fmt.Errorf("invalid start time %q: use an RFC 3339 time or now", args[1])
+				}
+			}
+			return manager.Schedule(args[0], at)
+		}
+
+		if len(args) != 1 {
+			return nil, fmt.Errorf("usage: %s <id>", action)
+		}
+		switch action {
+		case "pause":
+			return manager.Pause(args[0])
+		case "resume":
+			return manager.Resume(args[0])
+		case "cancel":
+			return manager.Cancel(args[0])
+		case "remove":
+			return nil, manager.Remove(args[0])
+		}
+		return nil, fmt.Errorf("unknown transfers action %q. Valid actions: list, add, pause, resume, cancel, remove, priority, bandwidth, schedule", action)
+	})
+}
+
+// parseTransferRequest reads the key=value arguments of "transfers add"
+func parseTransferRequest(args []string) (transfers.Request, error) {
+	var req transfers.Request
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return req, fmt.Errorf("invalid argument %q: expected key=value", arg)
+		}
+		switch key {
+		case "kind":
+			req.Kind = transfers.Kind(value)
+		case "cid":
+			req.DescriptorCID = value
+		case "path":
+			req.Path = value
+		case "name":
+			req.Name = value
+		case "password":
+			req.Password = value
+		case "priority":
+			priority, err := strconv.Atoi(value)
+			if err != nil {
+				return req, fmt.Errorf("invalid priority %q", value)
+			}
+			req.Priority = priority
+		case "bandwidth":
+			bandwidth, err := util.ParseSize(value)
+			if err != nil {
+				return req, fmt.Errorf("invalid bandwidth %q: %w", value, err)
+			}
+			req.BandwidthLimit = bandwidth
+		case "start":
+			start, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return req, fmt.Errorf("invalid start time %q: use an RFC 3339 time", value)
+			}
+			req.StartAt = start
+		default:
+			return req, fmt.Errorf("unknown argument %q. Valid arguments: kind, cid, path, name, password, priority, bandwidth, start", key)
+		}
+	}
+	return req, nil
+}
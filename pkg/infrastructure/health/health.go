@@ -0,0 +1,194 @@
+// Package health provides liveness and readiness checks for NoiseFS servers,
+// served as /healthz and /readyz for Kubernetes probes and service watchdogs.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCheckTimeout bounds how long a single check may take
+const DefaultCheckTimeout = 5 * time.Second
+
+// Report statuses
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc returns nil when the checked component is healthy
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of a set of checks
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Healthy reports whether every check passed
+func (r *Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker holds the liveness and readiness checks of a process. Liveness
+// checks detect a process that should be restarted; readiness checks detect
+// one that is running but cannot serve requests yet, e.g. while its storage
+// backend is unreachable. Readiness includes the liveness checks.
+type Checker struct {
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+	timeout   time.Duration
+}
+
+// NewChecker creates a checker with no checks; an empty checker is healthy
+func NewChecker() *Checker {
+	return &Checker{timeout: DefaultCheckTimeout}
+}
+
+// SetTimeout changes the per-check timeout
+func (c *Checker) SetTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeout = timeout
+}
+
+// AddLivenessCheck registers a check that fails only when the process is broken
+func (c *Checker) AddLivenessCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.liveness = append(c.liveness, namedCheck{name: name, fn: fn})
+}
+
+// AddReadinessCheck registers a check that must pass before traffic is served
+func (c *Checker) AddReadinessCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readiness = append(c.readiness, namedCheck{name: name, fn: fn})
+}
+
+// Live runs the liveness checks
+func (c *Checker) Live(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.liveness...)
+	timeout := c.timeout
+	c.mu.RUnlock()
+
+	return runChecks(ctx, checks, timeout)
+}
+
+// Ready runs the liveness and readiness checks
+func (c *Checker) Ready(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := append(append([]namedCheck(nil), c.liveness...), c.readiness...)
+	timeout := c.timeout
+	c.mu.RUnlock()
+
+	return runChecks(ctx, checks, timeout)
+}
+
+// runChecks runs checks concurrently, each with its own timeout
+func runChecks(ctx context.Context, checks []namedCheck, timeout time.Duration) *Report {
+	report := &Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check namedCheck) {
+			defer wg.Done()
+			result := runCheck(ctx, check.fn, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// runCheck runs one check, failing it if it panics or exceeds timeout
+func runCheck(ctx context.Context, fn CheckFunc, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("check panicked: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", timeout)
+	}
+
+	result := CheckResult{
+		Status:     StatusOK,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler serves the liveness report: 200 when healthy, 503 otherwise
+func (c *Checker) LivenessHandler() http.Handler {
+	return reportHandler(c.Live)
+}
+
+// ReadinessHandler serves the readiness report: 200 when ready, 503 otherwise
+func (c *Checker) ReadinessHandler() http.Handler {
+	return reportHandler(c.Ready)
+}
+
+// reportHandler writes a report as JSON with a status code probes understand
+func reportHandler(run func(ctx context.Context) *Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Healthy() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if r.Method != http.MethodHead {
+			json.NewEncoder(w).Encode(report)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	checker := NewChecker()
+	checker.SetTimeout(50 * time.Millisecond)
+
+	ready := false
+	checker.AddLivenessCheck("cache", func(ctx context.Context) error { return nil })
+	checker.AddReadinessCheck("storage", func(ctx context.Context) error {
+		if !ready {
+			return errors.New("backend unreachable")
+		}
+		return nil
+	})
+
+	if report := checker.Live(context.Background()); !report.Healthy() || len(report.Checks) != 1 {
+		t.Errorf("Expected healthy liveness report with one check, got %+v", report)
+	}
+
+	report := checker.Ready(context.Background())
+	if report.Healthy() {
+		t.Error("Expected readiness to fail while storage is unreachable")
+	}
+	if report.Checks["storage"].Error != "backend unreachable" || report.Checks["cache"].Status != StatusOK {
+		t.Errorf("Unexpected check results: %+v", report.Checks)
+	}
+
+	ready = true
+	if report := checker.Ready(context.Background()); !report.Healthy() {
+		t.Errorf("Expected ready, got %+v", report)
+	}
+
+	// Slow and panicking checks fail instead of blocking the probe
+	checker.AddReadinessCheck("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	checker.AddReadinessCheck("panics", func(ctx context.Context) error {
+		panic("boom")
+	})
+	start := time.Now()
+	report = checker.Ready(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Check timeout was not enforced")
+	}
+	if report.Checks["slow"].Status != StatusFail || report.Checks["panics"].Status != StatusFail {
+		t.Errorf("Expected slow and panicking checks to fail, got %+v", report.Checks)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	checker := NewChecker()
+	checker.AddReadinessCheck("storage", func(ctx context.Context) error {
+		return errors.New("offline")
+	})
+
+	rec := httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /healthz, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 from /readyz, got %d", rec.Code)
+	}
+
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if report.Status != StatusFail || report.Checks["storage"].Error != "offline" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/readyz", nil))
+	if rec.Body.Len() != 0 {
+		t.Error("HEAD response should have no body")
+	}
+}
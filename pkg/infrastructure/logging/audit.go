@@ -0,0 +1,497 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditEventType identifies a security-relevant event
+type AuditEventType string
+
+const (
+	AuditUpload              AuditEventType = "upload"
+	AuditDownload            AuditEventType = "download"
+	AuditAnnounce            AuditEventType = "announce"
+	AuditSubscribe           AuditEventType = "subscribe"
+	AuditUnsubscribe         AuditEventType = "unsubscribe"
+	AuditAuthFailure         AuditEventType = "auth_failure"
+	AuditAccessDenied        AuditEventType = "access_denied"
+	AuditConfigurationChange AuditEventType = "config_change"
+	AuditShare               AuditEventType = "share"
+	AuditShareRevoke         AuditEventType = "share_revoke"
+	AuditTakedown            AuditEventType = "takedown"
+	AuditReinstate           AuditEventType = "takedown_reinstate"
+	AuditDisclaimerAccept    AuditEventType = "disclaimer_accept"
+	AuditTokenCreate         AuditEventType = "token_create"
+	AuditTokenRevoke         AuditEventType = "token_revoke"
+	AuditPin                 AuditEventType = "pin"
+	AuditUnpin               AuditEventType = "unpin"
+)
+
+// Audit outcomes
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditKeyEnv names the environment variable holding the optional HMAC key
+// for the audit hash chain. Without a key the chain detects edits but can be
+// recomputed by anyone with write access to the file.
+const AuditKeyEnv = "NOISEFS_AUDIT_KEY"
+
+// AuditEvent is a single audit log record. Each record carries the hash of
+// its predecessor, so editing, removing or reordering records breaks the chain.
+type AuditEvent struct {
+	Seq       uint64            `json:"seq"`
+	Timestamp time.Time         `json:"timestamp"`
+	Type      AuditEventType    `json:"type"`
+	Actor     string            `json:"actor,omitempty"`
+	Resource  string            `json:"resource,omitempty"`
+	Outcome   string            `json:"outcome"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash,omitempty"`
+}
+
+// AuditConfig holds audit log configuration
+type AuditConfig struct {
+	Path     string // JSONL file; rotated files get .1, .2, ... suffixes
+	MaxSize  int64  // Rotate when the file would exceed this many bytes (0 = never)
+	MaxFiles int    // Rotated files to keep
+	Key      []byte // Optional HMAC key for the hash chain
+}
+
+// AuditLogger appends events to a rotated, hash-chained JSONL file. All
+// methods are safe on a nil logger, which records nothing.
+type AuditLogger struct {
+	mu       sync.Mutex
+	config   AuditConfig
+	file     *os.File
+	size     int64
+	seq      uint64
+	lastHash string
+}
+
+// NewAuditLogger opens (or creates) the audit log and resumes its hash chain
+func NewAuditLogger(config AuditConfig) (*AuditLogger, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("audit log path is required")
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = 5
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	al := &AuditLogger{config: config}
+	if err := al.open(); err != nil {
+		return nil, err
+	}
+	return al, nil
+}
+
+// open opens the current file and loads the chain head from disk
+func (al *AuditLogger) open() error {
+	file, err := os.OpenFile(al.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+
+	al.file = file
+	al.size = info.Size()
+	return al.loadHead()
+}
+
+// loadHead reads the last event so new events continue the chain. After a
+// rotation the current file is empty and the head is in the .1 file.
+func (al *AuditLogger) loadHead() error {
+	for _, path := range []string{al.config.Path, rotatedAuditPath(al.config.Path, 1)} {
+		last, err := lastAuditEvent(path)
+		if err != nil {
+			return err
+		}
+		if last != nil {
+			al.seq = last.Seq
+			al.lastHash = last.Hash
+			return nil
+		}
+	}
+	al.seq = 0
+	al.lastHash = ""
+	return nil
+}
+
+// Record appends an event, filling in its sequence number, timestamp and hashes
+func (al *AuditLogger) Record(event AuditEvent) error {
+	if al == nil {
+		return nil
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+
+	// Another process may have appended since our last write; continue from
+	// its head rather than forking the chain
+	if info, err := os.Stat(al.config.Path); err == nil && info.Size() != al.size {
+		al.size = info.Size()
+		if err := al.loadHead(); err != nil {
+			return err
+		}
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Timestamp = event.Timestamp.UTC()
+	if event.Outcome == "" {
+		event.Outcome = AuditSuccess
+	}
+	event.Seq = al.seq + 1
+	event.PrevHash = al.lastHash
+
+	hash, err := auditHash(event, al.config.Key)
+	if err != nil {
+		return err
+	}
+	event.Hash = hash
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	if al.config.MaxSize > 0 && al.size > 0 && al.size+int64(len(line)) > al.config.MaxSize {
+		if err := al.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := al.file.Write(line)
+	al.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+
+	al.seq = event.Seq
+	al.lastHash = event.Hash
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and reopens path
+func (al *AuditLogger) rotate() error {
+	if err := al.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	al.file = nil
+
+	os.Remove(rotatedAuditPath(al.config.Path, al.config.MaxFiles))
+	for i := al.config.MaxFiles - 1; i >= 1; i-- {
+		from := rotatedAuditPath(al.config.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, rotatedAuditPath(al.config.Path, i+1)); err != nil {
+				return fmt.Errorf("failed to rotate audit log: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(al.config.Path, rotatedAuditPath(al.config.Path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(al.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	al.file = file
+	al.size = 0
+	return nil
+}
+
+// Close closes the audit log
+func (al *AuditLogger) Close() error {
+	if al == nil {
+		return nil
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
+
+// auditHash computes the chain hash of an event (with its Hash field cleared)
+func auditHash(event AuditEvent, key []byte) (string, error) {
+	event.Hash = ""
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+
+	if len(key) > 0 {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// rotatedAuditPath returns the name of the n-th rotated file
+func rotatedAuditPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// auditFiles lists the existing audit files for path, oldest first
+func auditFiles(path string) []string {
+	var rotated []string
+	for i := 1; ; i++ {
+		candidate := rotatedAuditPath(path, i)
+		if _, err := os.Stat(candidate); err != nil {
+			break
+		}
+		rotated = append(rotated, candidate)
+	}
+
+	files := make([]string, 0, len(rotated)+1)
+	for i := len(rotated) - 1; i >= 0; i-- {
+		files = append(files, rotated[i])
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// readAuditFile calls fn for each event in a file
+func readAuditFile(path string, fn func(event AuditEvent, line int) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var event AuditEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("%s:%d: malformed audit event: %w", path, line, err)
+		}
+		if err := fn(event, line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+// lastAuditEvent returns the last event in a file, or nil if it has none
+func lastAuditEvent(path string) (*AuditEvent, error) {
+	var last *AuditEvent
+	err := readAuditFile(path, func(event AuditEvent, _ int) error {
+		last = &event
+		return nil
+	})
+	return last, err
+}
+
+// AuditQuery filters audit events. Zero values match everything.
+type AuditQuery struct {
+	Types    []AuditEventType
+	Actor    string
+	Resource string // Substring match
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	Limit    int // Return only the most recent N matches
+}
+
+// matches reports whether an event satisfies the query
+func (q AuditQuery) matches(event AuditEvent) bool {
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Actor != "" && q.Actor != event.Actor {
+		return false
+	}
+	if q.Resource != "" && !strings.Contains(event.Resource, q.Resource) {
+		return false
+	}
+	if q.Outcome != "" && q.Outcome != event.Outcome {
+		return false
+	}
+	if !q.Since.IsZero() && event.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && event.Timestamp.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// QueryAuditLog returns the events in the audit log at path (including rotated
+// files) that match the query, oldest first
+func QueryAuditLog(path string, query AuditQuery) ([]AuditEvent, error) {
+	var events []AuditEvent
+	for _, file := range auditFiles(path) {
+		err := readAuditFile(file, func(event AuditEvent, _ int) error {
+			if query.matches(event) {
+				events = append(events, event)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[len(events)-query.Limit:]
+	}
+	return events, nil
+}
+
+// AuditVerification is the result of checking an audit log's hash chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	Files    int    `json:"files"`
+	FirstSeq uint64 `json:"first_seq,omitempty"`
+	LastSeq  uint64 `json:"last_seq,omitempty"`
+	Problem  string `json:"problem,omitempty"`
+}
+
+// VerifyAuditLog walks the audit log at path (including rotated files) and
+// checks that every event's hash is correct and links to its predecessor.
+// The oldest retained event anchors the chain, since older files may have
+// been rotated away.
+func VerifyAuditLog(path string, key []byte) (*AuditVerification, error) {
+	files := auditFiles(path)
+	result := &AuditVerification{Valid: true, Files: len(files)}
+
+	var prev *AuditEvent
+	for _, file := range files {
+		err := readAuditFile(file, func(event AuditEvent, line int) error {
+			if !result.Valid {
+				return nil
+			}
+
+			location := fmt.Sprintf("%s:%d (seq %d)", filepath.Base(file), line, event.Seq)
+			expected, err := auditHash(event, key)
+			if err != nil {
+				return err
+			}
+			if expected != event.Hash {
+				result.Valid = false
+				result.Problem = fmt.Sprintf("%s: hash mismatch, event was modified", location)
+				return nil
+			}
+			if prev != nil {
+				if event.PrevHash != prev.Hash {
+					result.Valid = false
+					result.Problem = fmt.Sprintf("%s: chain broken, previous event was removed or replaced", location)
+					return nil
+				}
+				if event.Seq != prev.Seq+1 {
+					result.Valid = false
+					result.Problem = fmt.Sprintf("%s: sequence gap after seq %d", location, prev.Seq)
+					return nil
+				}
+			} else {
+				result.FirstSeq = event.Seq
+			}
+
+			result.Entries++
+			result.LastSeq = event.Seq
+			prev = &event
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Global audit logger instance
+var defaultAudit *AuditLogger
+var defaultAuditMu sync.RWMutex
+
+// InitGlobalAuditLogger opens the global audit log, closing any previous one
+func InitGlobalAuditLogger(config AuditConfig) error {
+	audit, err := NewAuditLogger(config)
+	if err != nil {
+		return err
+	}
+
+	defaultAuditMu.Lock()
+	defer defaultAuditMu.Unlock()
+	defaultAudit.Close()
+	defaultAudit = audit
+	return nil
+}
+
+// CloseGlobalAuditLogger closes the global audit log
+func CloseGlobalAuditLogger() error {
+	defaultAuditMu.Lock()
+	defer defaultAuditMu.Unlock()
+	err := defaultAudit.Close()
+	defaultAudit = nil
+	return err
+}
+
+// Audit records an event in the global audit log. It is a no-op when audit
+// logging has not been initialized.
+func Audit(event AuditEvent) error {
+	defaultAuditMu.RLock()
+	defer defaultAuditMu.RUnlock()
+	return defaultAudit.Record(event)
+}
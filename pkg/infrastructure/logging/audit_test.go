@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLogger_RecordAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	audit, err := NewAuditLogger(AuditConfig{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+
+	audit.Record(AuditEvent{Type: AuditUpload, Actor: "cli", Resource: "QmUpload"})
+	audit.Record(AuditEvent{Type: AuditDownload, Actor: "10.0.0.1", Resource: "QmDownload"})
+	audit.Record(AuditEvent{Type: AuditAuthFailure, Actor: "10.0.0.1", Outcome: AuditFailure})
+	audit.Close()
+
+	// Reopening continues the chain
+	audit, err = NewAuditLogger(AuditConfig{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to reopen audit logger: %v", err)
+	}
+	audit.Record(AuditEvent{Type: AuditSubscribe, Actor: "cli", Resource: "movies"})
+	audit.Close()
+
+	all, err := QueryAuditLog(path, AuditQuery{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(all) != 4 || all[3].Seq != 4 || all[3].PrevHash != all[2].Hash {
+		t.Fatalf("Expected 4 chained events, got %+v", all)
+	}
+
+	byActor, _ := QueryAuditLog(path, AuditQuery{Actor: "10.0.0.1"})
+	if len(byActor) != 2 {
+		t.Errorf("Expected 2 events for actor, got %d", len(byActor))
+	}
+
+	failures, _ := QueryAuditLog(path, AuditQuery{Types: []AuditEventType{AuditAuthFailure}, Outcome: AuditFailure})
+	if len(failures) != 1 {
+		t.Errorf("Expected 1 auth failure, got %d", len(failures))
+	}
+
+	recent, _ := QueryAuditLog(path, AuditQuery{Limit: 1, Since: time.Now().Add(-time.Minute)})
+	if len(recent) != 1 || recent[0].Type != AuditSubscribe {
+		t.Errorf("Expected most recent event, got %+v", recent)
+	}
+
+	verification, err := VerifyAuditLog(path, nil)
+	if err != nil || !verification.Valid || verification.Entries != 4 {
+		t.Errorf("Expected valid chain of 4 entries, got %+v, %v", verification, err)
+	}
+}
+
+func TestAuditLogger_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	key := []byte("audit-secret")
+
+	audit, err := NewAuditLogger(AuditConfig{Path: path, MaxSize: 600, MaxFiles: 2, Key: key})
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := audit.Record(AuditEvent{Type: AuditDownload, Resource: "QmRotate"}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	audit.Close()
+
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Errorf("Expected rotated file .2: %v", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("Rotated files beyond MaxFiles should be removed")
+	}
+
+	events, err := QueryAuditLog(path, AuditQuery{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(events) == 0 || len(events) >= 20 || events[len(events)-1].Seq != 20 {
+		t.Errorf("Expected the newest events to survive rotation, got %d events", len(events))
+	}
+
+	// The chain spans rotated files, anchored at the oldest retained event
+	verification, err := VerifyAuditLog(path, key)
+	if err != nil || !verification.Valid || verification.LastSeq != 20 {
+		t.Errorf("Expected valid chain across files, got %+v, %v", verification, err)
+	}
+
+	// Verifying with the wrong key fails
+	if verification, _ := VerifyAuditLog(path, []byte("wrong")); verification.Valid {
+		t.Error("Expected verification with the wrong key to fail")
+	}
+}
+
+func TestVerifyAuditLog_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	audit, err := NewAuditLogger(AuditConfig{Path: path})
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+	for _, resource := range []string{"QmOne", "QmTwo", "QmThree"} {
+		audit.Record(AuditEvent{Type: AuditUpload, Resource: resource})
+	}
+	audit.Close()
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := strings.SplitAfter(string(original), "\n")
+
+	// Editing an event invalidates its hash
+	edited := strings.Replace(string(original), "QmTwo", "QmEvil", 1)
+	os.WriteFile(path, []byte(edited), 0600)
+	if verification, _ := VerifyAuditLog(path, nil); verification.Valid {
+		t.Error("Expected edited event to be detected")
+	}
+
+	// Removing an event breaks the chain
+	os.WriteFile(path, []byte(lines[0]+lines[2]), 0600)
+	verification, _ := VerifyAuditLog(path, nil)
+	if verification.Valid || !strings.Contains(verification.Problem, "chain broken") {
+		t.Errorf("Expected removed event to be detected, got %+v", verification)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // ConfigureFromSettings configures a logger from settings
@@ -71,7 +72,7 @@ func InitFromConfig(level, format, output, filename string) error {
 	}
 
 	InitGlobalLogger(&Config{
-		Level:      logger.level,
+		Level:      logger.Level(),
 		Format:     logger.format,
 		Output:     logger.output,
 		ShowCaller: logger.showCaller,
@@ -79,4 +80,47 @@ func InitFromConfig(level, format, output, filename string) error {
 	})
 
 	return nil
-}
\ No newline at end of file
+}
+// InitAuditFromConfig initializes the global audit log from configuration
+// settings. The HMAC key, if any, is read from the NOISEFS_AUDIT_KEY environment
+// variable so it never lives in the configuration file.
+func InitAuditFromConfig(enabled bool, filename string, maxSizeMB, maxFiles int) error {
+	if !enabled {
+		return nil
+	}
+	if filename == "" {
+		return fmt.Errorf("audit log file path required when audit logging is enabled")
+	}
+
+	return InitGlobalAuditLogger(AuditConfig{
+		Path:     filename,
+		MaxSize:  int64(maxSizeMB) * 1024 * 1024,
+		MaxFiles: maxFiles,
+		Key:      []byte(os.Getenv(AuditKeyEnv)),
+	})
+}
+
+// InitComponentLevelsFromConfig applies per-component level overrides (e.g.
+// {"storage": "debug", "fuse": "warn"}) and message sampling to the global
+// logger, replacing any earlier overrides. Sampling logs the first
+// sampleInitial identical messages each second, then every sampleThereafter-th.
+func InitComponentLevelsFromConfig(components map[string]string, sampleInitial, sampleThereafter int) error {
+	levels := make(map[string]LogLevel, len(components))
+	for component, name := range components {
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return fmt.Errorf("invalid level for component %s: %w", component, err)
+		}
+		levels[component] = level
+	}
+
+	logger := GetGlobalLogger()
+	logger.SetComponentLevels(levels)
+	logger.SetSampling(SamplingConfig{
+		Initial:    sampleInitial,
+		Thereafter: sampleThereafter,
+		Interval:   time.Second,
+	})
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// requestIDKey holds the request ID in a context
+type requestIDKey struct{}
+
+// NewRequestID returns a random request ID of 12 hex digits, a length log
+// sanitizing never mistakes for a secret or card number
+func NewRequestID() string {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// WithRequestID returns a context carrying a request ID, so the layers a
+// request passes through can log it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID of a context, or "" if it
+// has none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithContext returns a logger that adds the request ID of ctx, if any, to
+// every entry
+func (l *Logger) WithContext(ctx context.Context) *FieldLogger {
+	fields := make(map[string]interface{})
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	return &FieldLogger{
+		logger: l,
+		fields: fields,
+	}
+}
@@ -0,0 +1,200 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SamplingConfig limits how often an identical message is written. In each
+// Interval the first Initial occurrences are logged, then every Thereafter-th
+// one. Errors are never sampled. A zero Initial disables sampling.
+type SamplingConfig struct {
+	Initial    int
+	Thereafter int
+	Interval   time.Duration
+}
+
+// levelControl holds the levels and sampling shared by a logger and every
+// component logger derived from it, so runtime changes reach all of them
+type levelControl struct {
+	mu         sync.RWMutex
+	base       LogLevel
+	components map[string]LogLevel
+	sampler    *sampler
+}
+
+func newLevelControl(base LogLevel) *levelControl {
+	return &levelControl{
+		base:       base,
+		components: make(map[string]LogLevel),
+	}
+}
+
+// enabled reports whether a message at level from component should be logged
+func (lc *levelControl) enabled(component string, level LogLevel) bool {
+	lc.mu.RLock()
+	defer lc.mu.RUnlock()
+
+	if componentLevel, ok := lc.components[component]; ok {
+		return level >= componentLevel
+	}
+	return level >= lc.base
+}
+
+// sample reports whether a message passes sampling
+func (lc *levelControl) sample(component string, level LogLevel, message string) bool {
+	lc.mu.RLock()
+	s := lc.sampler
+	lc.mu.RUnlock()
+
+	if s == nil || level >= ErrorLevel {
+		return true
+	}
+	return s.allow(component + "\x00" + level.String() + "\x00" + message)
+}
+
+// sampler counts identical messages within a time window
+type sampler struct {
+	config  SamplingConfig
+	mu      sync.Mutex
+	window  time.Time
+	counts  map[string]int
+	dropped uint64
+}
+
+func (s *sampler) allow(key string) bool {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.window) >= s.config.Interval {
+		s.window = now
+		s.counts = make(map[string]int)
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.config.Initial {
+		return true
+	}
+	if s.config.Thereafter > 0 && (n-s.config.Initial)%s.config.Thereafter == 0 {
+		return true
+	}
+
+	s.dropped++
+	return false
+}
+
+// Level returns the effective level of the logger's component
+func (l *Logger) Level() LogLevel {
+	l.levels.mu.RLock()
+	defer l.levels.mu.RUnlock()
+
+	if level, ok := l.levels.components[l.component]; ok {
+		return level
+	}
+	return l.levels.base
+}
+
+// SetComponentLevel overrides the level of one component, e.g. "storage"
+func (l *Logger) SetComponentLevel(component string, level LogLevel) {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+	l.levels.components[component] = level
+}
+
+// ResetComponentLevel removes a component override so the base level applies
+func (l *Logger) ResetComponentLevel(component string) {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+	delete(l.levels.components, component)
+}
+
+// SetComponentLevels replaces all component overrides
+func (l *Logger) SetComponentLevels(levels map[string]LogLevel) {
+	components := make(map[string]LogLevel, len(levels))
+	for component, level := range levels {
+		components[component] = level
+	}
+
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+	l.levels.components = components
+}
+
+// Levels returns the base level and a copy of the component overrides
+func (l *Logger) Levels() (LogLevel, map[string]LogLevel) {
+	l.levels.mu.RLock()
+	defer l.levels.mu.RUnlock()
+
+	components := make(map[string]LogLevel, len(l.levels.components))
+	for component, level := range l.levels.components {
+		components[component] = level
+	}
+	return l.levels.base, components
+}
+
+// SetSampling enables message sampling, or disables it when Initial is zero
+func (l *Logger) SetSampling(config SamplingConfig) {
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+
+	if config.Initial <= 0 {
+		l.levels.sampler = nil
+		return
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	l.levels.sampler = &sampler{config: config}
+}
+
+// SampledCount returns how many messages sampling has dropped
+func (l *Logger) SampledCount() uint64 {
+	l.levels.mu.RLock()
+	s := l.levels.sampler
+	l.levels.mu.RUnlock()
+
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// ParseComponentLevels parses overrides such as "storage=debug,fuse=warn"
+func ParseComponentLevels(spec string) (map[string]LogLevel, error) {
+	levels := make(map[string]LogLevel)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		component, levelName, ok := strings.Cut(part, "=")
+		if !ok || strings.TrimSpace(component) == "" {
+			return nil, fmt.Errorf("invalid component level %q: expected component=level", part)
+		}
+		level, err := ParseLogLevel(strings.TrimSpace(levelName))
+		if err != nil {
+			return nil, fmt.Errorf("invalid level for component %s: %w", component, err)
+		}
+		levels[strings.TrimSpace(component)] = level
+	}
+	return levels, nil
+}
+
+// FormatComponentLevels renders overrides in the form ParseComponentLevels accepts
+func FormatComponentLevels(levels map[string]LogLevel) string {
+	parts := make([]string, 0, len(levels))
+	for component, level := range levels {
+		parts = append(parts, component+"="+strings.ToLower(level.String()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
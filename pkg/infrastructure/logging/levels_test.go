@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComponentLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(&Config{Level: InfoLevel, Format: TextFormat, Output: buf})
+	storage := logger.WithComponent("storage")
+	fuse := logger.WithComponent("fuse")
+
+	logger.SetComponentLevel("storage", DebugLevel)
+	logger.SetComponentLevel("fuse", WarnLevel)
+
+	storage.Debug("storage debug")
+	fuse.Info("fuse info")
+	logger.Debug("base debug")
+
+	output := buf.String()
+	if !strings.Contains(output, "storage debug") {
+		t.Error("Storage debug message should appear with a debug override")
+	}
+	if strings.Contains(output, "fuse info") {
+		t.Error("Fuse info message should not appear with a warn override")
+	}
+	if strings.Contains(output, "base debug") {
+		t.Error("Base debug message should not appear at info level")
+	}
+
+	// SetLevel on a component logger only affects that component
+	fuse.SetLevel(ErrorLevel)
+	if base, components := logger.Levels(); base != InfoLevel || components["fuse"] != ErrorLevel {
+		t.Errorf("Unexpected levels: base %v, components %v", base, components)
+	}
+
+	logger.ResetComponentLevel("fuse")
+	if fuse.Level() != InfoLevel {
+		t.Errorf("Expected fuse to fall back to the base level, got %v", fuse.Level())
+	}
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels("storage=debug, fuse=warn")
+	if err != nil {
+		t.Fatalf("Failed to parse component levels: %v", err)
+	}
+	if levels["storage"] != DebugLevel || levels["fuse"] != WarnLevel {
+		t.Errorf("Unexpected levels: %v", levels)
+	}
+	if got := FormatComponentLevels(levels); got != "fuse=warn,storage=debug" {
+		t.Errorf("Unexpected formatted levels: %s", got)
+	}
+
+	for _, invalid := range []string{"storage", "=debug", "storage=loud"} {
+		if _, err := ParseComponentLevels(invalid); err == nil {
+			t.Errorf("Expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewLogger(&Config{Level: InfoLevel, Format: TextFormat, Output: buf})
+	logger.SetSampling(SamplingConfig{Initial: 2, Thereafter: 5, Interval: time.Hour})
+
+	for i := 0; i < 12; i++ {
+		logger.Info("noisy")
+	}
+	logger.Info("quiet")
+	for i := 0; i < 3; i++ {
+		logger.Error("failure")
+	}
+
+	output := buf.String()
+	// 2 initial, then the 7th and 12th
+	if n := strings.Count(output, "noisy"); n != 4 {
+		t.Errorf("Expected 4 sampled messages, got %d", n)
+	}
+	if !strings.Contains(output, "quiet") {
+		t.Error("Distinct messages should be sampled separately")
+	}
+	if n := strings.Count(output, "failure"); n != 3 {
+		t.Errorf("Errors should never be sampled, got %d of 3", n)
+	}
+	if dropped := logger.SampledCount(); dropped != 8 {
+		t.Errorf("Expected 8 dropped messages, got %d", dropped)
+	}
+
+	logger.SetSampling(SamplingConfig{})
+	if logger.SampledCount() != 0 {
+		t.Error("Disabling sampling should reset the dropped count")
+	}
+}
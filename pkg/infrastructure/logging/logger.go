@@ -75,7 +75,7 @@ type LogEntry struct {
 // Logger provides structured logging functionality
 type Logger struct {
 	mu               sync.RWMutex
-	level            LogLevel
+	levels           *levelControl
 	format           LogFormat
 	output           io.Writer
 	showCaller       bool
@@ -134,7 +134,7 @@ func NewLogger(config *Config) *Logger {
 	}
 
 	logger := &Logger{
-		level:            config.Level,
+		levels:           newLevelControl(config.Level),
 		format:           config.Format,
 		output:           config.Output,
 		showCaller:       config.ShowCaller,
@@ -158,7 +158,7 @@ func (l *Logger) WithComponent(component string) *Logger {
 	defer l.mu.RUnlock()
 
 	return &Logger{
-		level:             l.level,
+		levels:            l.levels,
 		format:            l.format,
 		output:            l.output,
 		showCaller:        l.showCaller,
@@ -168,11 +168,17 @@ func (l *Logger) WithComponent(component string) *Logger {
 	}
 }
 
-// SetLevel sets the logging level
+// SetLevel sets the logging level. On a component logger it overrides the
+// level of that component only.
 func (l *Logger) SetLevel(level LogLevel) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	if l.component != "" {
+		l.SetComponentLevel(l.component, level)
+		return
+	}
+
+	l.levels.mu.Lock()
+	defer l.levels.mu.Unlock()
+	l.levels.base = level
 }
 
 // SetOutput sets the output writer
@@ -184,9 +190,7 @@ func (l *Logger) SetOutput(output io.Writer) {
 
 // IsEnabled checks if a log level is enabled
 func (l *Logger) IsEnabled(level LogLevel) bool {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-	return level >= l.level
+	return l.levels.enabled(l.component, level)
 }
 
 // SanitizeLogEntry sanitizes sensitive data from a log entry
@@ -301,7 +305,7 @@ func (l *Logger) SetSanitizing(enabled bool) {
 
 // log writes a log entry
 func (l *Logger) log(level LogLevel, message string, fields map[string]interface{}) {
-	if !l.IsEnabled(level) {
+	if !l.IsEnabled(level) || !l.levels.sample(l.component, level, message) {
 		return
 	}
 
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -205,4 +206,41 @@ func TestConfigureFromSettings(t *testing.T) {
 	if !strings.Contains(string(content), "info message") {
 		t.Error("Log file should contain info message")
 	}
-}
\ No newline at end of file
+}
+
+func TestWithContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	config := &Config{
+		Level:            InfoLevel,
+		Format:           JSONFormat,
+		Output:           buf,
+		EnableSanitizing: true,
+	}
+	logger := NewLogger(config)
+
+	id := NewRequestID()
+	if len(id) != 12 || NewRequestID() == id {
+		t.Fatalf("Unexpected request ID %q", id)
+	}
+	ctx := WithRequestID(context.Background(), id)
+	if got := RequestIDFromContext(ctx); got != id {
+		t.Errorf("Expected request ID %q, got %q", id, got)
+	}
+
+	logger.WithContext(ctx).WithField("cid", "QmTest").Info("test message")
+
+	var entry LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if entry.Fields["request_id"] != id || entry.Fields["cid"] != "QmTest" {
+		t.Errorf("Expected the request ID and cid fields, got %v", entry.Fields)
+	}
+
+	// Without a request ID nothing is added
+	buf.Reset()
+	logger.WithContext(context.Background()).Info("test message")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("Expected no request ID field, got %s", buf.String())
+	}
+}
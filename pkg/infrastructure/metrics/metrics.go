@@ -0,0 +1,271 @@
+// Package metrics exports NoiseFS statistics in the Prometheus format, so
+// the web UI and mounts can be scraped at /metrics. Every process reports
+// the same metric names and labels; Prometheus adds the job and instance
+// labels that tell them apart.
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/fuse"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// AnnouncementStats reports the announcements a node holds;
+// store.Store implements it
+type AnnouncementStats interface {
+	GetStats() (total int, byTopic map[string]int, expired int)
+}
+
+// WebSocketStats reports the web UI's live update clients and the
+// messages sent to them
+type WebSocketStats interface {
+	WebSocketStats() (clients int, sent, dropped, evicted int64)
+}
+
+// Sources are the components whose statistics are exported. Nil sources
+// are skipped, so each process sets the ones it runs.
+type Sources struct {
+	Client  *noisefs.Client  // Uploads, downloads, blocks and the block cache
+	Storage *storage.Manager // Backend health and transfers in flight
+
+	Announcements         AnnouncementStats
+	AnnouncementsReceived func() int64 // Announcements received since start
+
+	WebSockets WebSocketStats
+
+	// FUSE exports the operations served by mounts of this process
+	FUSE bool
+}
+
+// Handler serves the metrics of sources, together with the Go runtime and
+// process metrics
+func Handler(sources Sources) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		NewCollector(sources),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Metric names follow noisefs_<subsystem>_<name>_<unit>, with _total on
+// counters and base units (seconds, bytes). Dashboards depend on them, so
+// names, labels and histogram buckets are only ever added to: rename a
+// metric by exporting it under both names for a release.
+const namespace = "noisefs"
+
+// Subsystems, the second part of every metric name
+const (
+	subsystemClient        = "client"
+	subsystemCache         = "cache"
+	subsystemStorage       = "storage"
+	subsystemAnnouncements = "announcements"
+	subsystemWorkerPool    = "worker_pool"
+	subsystemWebSocket     = "websocket"
+	subsystemFUSE          = "fuse"
+)
+
+func newDesc(subsystem, name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+}
+
+var (
+	uploadsDesc         = newDesc(subsystemClient, "uploads_total", "Files uploaded.")
+	downloadsDesc       = newDesc(subsystemClient, "downloads_total", "Files downloaded.")
+	uploadSecondsDesc   = newDesc(subsystemClient, "upload_seconds", "Time taken to upload a file.")
+	downloadSecondsDesc = newDesc(subsystemClient, "download_seconds", "Time taken to download a file.")
+	blocksDesc          = newDesc(subsystemClient, "randomizer_blocks_total", "Randomizer blocks used by uploads, by whether they were reused or generated.", "source")
+	bytesUploadedDesc   = newDesc(subsystemClient, "uploaded_bytes_total", "Bytes of file content uploaded.")
+	bytesStoredDesc     = newDesc(subsystemClient, "stored_bytes_total", "Bytes stored for uploads, including randomizers.")
+	dedupedUploadsDesc  = newDesc(subsystemClient, "deduplicated_uploads_total", "Uploads skipped because the file was uploaded before.")
+	dedupedBlocksDesc   = newDesc(subsystemClient, "deduplicated_blocks_total", "Blocks that reused the stored data block and randomizers of an earlier upload.")
+	speculativeDesc     = newDesc(subsystemClient, "speculative_fetches_total", "Download blocks also requested from a second backend for being slow.")
+	speculativeWinsDesc = newDesc(subsystemClient, "speculative_wins_total", "Speculative fetches the second backend answered first.")
+
+	cacheHitsDesc      = newDesc(subsystemCache, "hits_total", "Block cache lookups that found the block.")
+	cacheMissesDesc    = newDesc(subsystemCache, "misses_total", "Block cache lookups that missed.")
+	cacheEvictionsDesc = newDesc(subsystemCache, "evictions_total", "Blocks evicted from the block cache.")
+	cacheBlocksDesc    = newDesc(subsystemCache, "blocks", "Blocks in the block cache.")
+
+	backendUpDesc        = newDesc(subsystemStorage, "backend_up", "Whether a storage backend is healthy.", "backend", "type")
+	backendConnectedDesc = newDesc(subsystemStorage, "backend_connected", "Whether a storage backend is connected.", "backend", "type")
+	backendLatencyDesc   = newDesc(subsystemStorage, "backend_latency_seconds", "Latency of a storage backend's last health check.", "backend", "type")
+	backendErrorDesc     = newDesc(subsystemStorage, "backend_error_ratio", "Share of a storage backend's operations that failed.", "backend", "type")
+	transfersDesc        = newDesc(subsystemStorage, "transfers_in_flight", "Block transfers in progress.")
+	maxTransfersDesc     = newDesc(subsystemStorage, "transfers_max_parallel", "Maximum concurrent block transfers; 0 when unlimited.")
+
+	announcementsDesc         = newDesc(subsystemAnnouncements, "held", "Announcements held, by whether they have expired.", "state")
+	announcementTopicsDesc    = newDesc(subsystemAnnouncements, "topics", "Topics with announcements held.")
+	announcementsReceivedDesc = newDesc(subsystemAnnouncements, "received_total", "Announcements received from the DHT and pubsub.")
+
+	poolWorkersDesc = newDesc(subsystemWorkerPool, "workers", "Workers of a worker pool.", "pool")
+	poolPendingDesc = newDesc(subsystemWorkerPool, "pending_tasks", "Tasks queued in a worker pool.", "pool")
+	poolTasksDesc   = newDesc(subsystemWorkerPool, "tasks_total", "Tasks a worker pool has finished, by result.", "pool", "result")
+
+	wsClientsDesc   = newDesc(subsystemWebSocket, "clients", "Connected WebSocket clients.")
+	wsMessagesDesc  = newDesc(subsystemWebSocket, "messages_total", "Messages for WebSocket clients, by whether they were sent or dropped because the client was too slow.", "result")
+	wsEvictionsDesc = newDesc(subsystemWebSocket, "evictions_total", "WebSocket clients disconnected for being persistently too slow.")
+
+	fuseOpsDesc    = newDesc(subsystemFUSE, "operations_total", "FUSE operations served, by operation.", "op")
+	fuseErrorsDesc = newDesc(subsystemFUSE, "operation_errors_total", "FUSE operations answered with an error, by operation.", "op")
+)
+
+// Collector is a prometheus.Collector reading the statistics of its
+// sources at each scrape
+type Collector struct {
+	sources Sources
+}
+
+// NewCollector returns a collector for sources
+func NewCollector(sources Sources) *Collector {
+	return &Collector{sources: sources}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		uploadsDesc, downloadsDesc, uploadSecondsDesc, downloadSecondsDesc,
+		blocksDesc, bytesUploadedDesc, bytesStoredDesc, dedupedUploadsDesc, dedupedBlocksDesc,
+		speculativeDesc, speculativeWinsDesc,
+		cacheHitsDesc, cacheMissesDesc, cacheEvictionsDesc, cacheBlocksDesc,
+		backendUpDesc, backendConnectedDesc, backendLatencyDesc, backendErrorDesc,
+		transfersDesc, maxTransfersDesc,
+		announcementsDesc, announcementTopicsDesc, announcementsReceivedDesc,
+		poolWorkersDesc, poolPendingDesc, poolTasksDesc,
+		wsClientsDesc, wsMessagesDesc, wsEvictionsDesc,
+		fuseOpsDesc, fuseErrorsDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if c.sources.Client != nil {
+		c.collectClient(ch)
+	}
+	if c.sources.Storage != nil {
+		c.collectStorage(ch)
+	}
+	if c.sources.Announcements != nil {
+		total, byTopic, expired := c.sources.Announcements.GetStats()
+		gauge(ch, announcementsDesc, float64(total-expired), "active")
+		gauge(ch, announcementsDesc, float64(expired), "expired")
+		gauge(ch, announcementTopicsDesc, float64(len(byTopic)))
+	}
+	if c.sources.AnnouncementsReceived != nil {
+		counter(ch, announcementsReceivedDesc, float64(c.sources.AnnouncementsReceived()))
+	}
+
+	if c.sources.WebSockets != nil {
+		clients, sent, dropped, evicted := c.sources.WebSockets.WebSocketStats()
+		gauge(ch, wsClientsDesc, float64(clients))
+		counter(ch, wsMessagesDesc, float64(sent), "sent")
+		counter(ch, wsMessagesDesc, float64(dropped), "dropped")
+		counter(ch, wsEvictionsDesc, float64(evicted))
+	}
+
+	for name, stats := range workers.RunningPools() {
+		gauge(ch, poolWorkersDesc, float64(stats.WorkerCount), name)
+		gauge(ch, poolPendingDesc, float64(stats.Pending), name)
+		counter(ch, poolTasksDesc, float64(stats.Completed), name, "completed")
+		counter(ch, poolTasksDesc, float64(stats.Failed), name, "failed")
+	}
+	if c.sources.FUSE {
+		for op, stats := range fuse.OperationStats() {
+			counter(ch, fuseOpsDesc, float64(stats.Calls), op)
+			counter(ch, fuseErrorsDesc, float64(stats.Errors), op)
+		}
+	}
+}
+
+func (c *Collector) collectClient(ch chan<- prometheus.Metric) {
+	stats := c.sources.Client.GetMetrics()
+	counter(ch, uploadsDesc, float64(stats.TotalUploads))
+	counter(ch, downloadsDesc, float64(stats.TotalDownloads))
+	histogram(ch, uploadSecondsDesc, stats.UploadSeconds)
+	histogram(ch, downloadSecondsDesc, stats.DownloadSeconds)
+	counter(ch, blocksDesc, float64(stats.BlocksReused), "reused")
+	counter(ch, blocksDesc, float64(stats.BlocksGenerated), "generated")
+	counter(ch, bytesUploadedDesc, float64(stats.BytesUploadedOriginal))
+	counter(ch, bytesStoredDesc, float64(stats.BytesStoredIPFS))
+	counter(ch, dedupedUploadsDesc, float64(stats.DeduplicatedUploads))
+	counter(ch, dedupedBlocksDesc, float64(stats.DeduplicatedBlocks))
+	counter(ch, speculativeDesc, float64(stats.SpeculativeFetches))
+	counter(ch, speculativeWinsDesc, float64(stats.SpeculativeWins))
+
+	if cacheStats := c.sources.Client.GetCacheStats(); cacheStats != nil {
+		counter(ch, cacheHitsDesc, float64(cacheStats.Hits))
+		counter(ch, cacheMissesDesc, float64(cacheStats.Misses))
+		counter(ch, cacheEvictionsDesc, float64(cacheStats.Evictions))
+		gauge(ch, cacheBlocksDesc, float64(cacheStats.Size))
+	}
+}
+
+func (c *Collector) collectStorage(ch chan<- prometheus.Metric) {
+	status := c.sources.Storage.GetManagerStatus()
+	for name, backend := range status.BackendStatus {
+		gauge(ch, backendUpDesc, boolValue(backend.Healthy), name, backend.Type)
+		gauge(ch, backendConnectedDesc, boolValue(backend.Connected), name, backend.Type)
+		gauge(ch, backendLatencyDesc, backend.Latency.Seconds(), name, backend.Type)
+		gauge(ch, backendErrorDesc, backend.ErrorRate, name, backend.Type)
+	}
+	// Bandwidth limits from the configuration install a TransferLimiter
+	if limiter, ok := c.sources.Storage.TransferLimiter().(*workers.TransferLimiter); ok {
+		gauge(ch, transfersDesc, float64(limiter.InFlight()))
+		gauge(ch, maxTransfersDesc, float64(limiter.MaxParallel()))
+	}
+}
+
+func counter(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...)
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+// histogram exports a client duration histogram, whose buckets are
+// noisefs.DurationBuckets
+func histogram(ch chan<- prometheus.Metric, desc *prometheus.Desc, snapshot noisefs.HistogramSnapshot) {
+	buckets := make(map[float64]uint64, len(snapshot.Buckets))
+	for i, bound := range snapshot.Buckets {
+		buckets[bound] = snapshot.Counts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(desc, snapshot.Count, snapshot.Sum, buckets)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Serve serves the metrics of sources at /metrics on address until the
+// server is closed. It returns once the address is listened on, with the
+// server's Addr set to the address bound.
+func Serve(address string, sources Sources) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(sources))
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	return server, nil
+}
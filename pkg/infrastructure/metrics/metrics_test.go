@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type fakeAnnouncements struct{}
+
+func (fakeAnnouncements) GetStats() (int, map[string]int, int) {
+	return 5, map[string]int{"music": 3, "books": 2}, 1
+}
+
+type fakeWebSockets struct{}
+
+func (fakeWebSockets) WebSocketStats() (int, int64, int64, int64) {
+	return 2, 40, 5, 1
+}
+
+// newTestSources returns a client over a mock storage backend
+func newTestSources(t *testing.T) Sources {
+	t.Helper()
+	backend, err := backends.NewMockBackend("metrics-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageConfig := storage.DefaultConfig()
+	storageConfig.Backends = map[string]*storage.BackendConfig{
+		storage.BackendTypeCustom: storage.CustomBackendConfig(backend),
+	}
+	storageConfig.DefaultBackend = storage.BackendTypeCustom
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storageManager.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storageManager.Stop(context.Background()) })
+	storageManager.SetTransferLimiter(workers.NewTransferLimiter(0, 4))
+
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Sources{Client: client, Storage: storageManager}
+}
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status %d: %s", w.Code, w.Body.String())
+	}
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestHandler(t *testing.T) {
+	sources := newTestSources(t)
+	sources.Announcements = fakeAnnouncements{}
+	sources.AnnouncementsReceived = func() int64 { return 7 }
+	sources.WebSockets = fakeWebSockets{}
+
+	pool := workers.NewPool(workers.Config{WorkerCount: 3, Name: "metrics-test"})
+	if err := pool.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Shutdown()
+
+	sources.Client.RecordUploadDuration(3 * time.Second)
+
+	body := scrape(t, Handler(sources))
+	for _, want := range []string{
+		"noisefs_client_uploads_total 0",
+		`noisefs_client_randomizer_blocks_total{source="reused"} 0`,
+		`noisefs_client_upload_seconds_bucket{le="2.5"} 0`,
+		`noisefs_client_upload_seconds_bucket{le="5"} 1`,
+		`noisefs_client_upload_seconds_bucket{le="+Inf"} 1`,
+		"noisefs_client_upload_seconds_sum 3",
+		"noisefs_client_download_seconds_count 0",
+		"noisefs_cache_misses_total 0",
+		`noisefs_storage_backend_up{backend="custom",type="mock"} 1`,
+		"noisefs_storage_transfers_max_parallel 4",
+		`noisefs_announcements_held{state="active"} 4`,
+		`noisefs_announcements_held{state="expired"} 1`,
+		"noisefs_announcements_topics 2",
+		"noisefs_announcements_received_total 7",
+		`noisefs_worker_pool_workers{pool="metrics-test"} 3`,
+		"noisefs_websocket_clients 2",
+		`noisefs_websocket_messages_total{result="dropped"} 5`,
+		"noisefs_websocket_evictions_total 1",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics missing %q", want)
+		}
+	}
+}
+
+// TestMetricNames pins the exported names, which dashboards depend on
+func TestMetricNames(t *testing.T) {
+	want := []string{
+		"noisefs_announcements_held",
+		"noisefs_announcements_received_total",
+		"noisefs_announcements_topics",
+		"noisefs_cache_blocks",
+		"noisefs_cache_evictions_total",
+		"noisefs_cache_hits_total",
+		"noisefs_cache_misses_total",
+		"noisefs_client_deduplicated_blocks_total",
+		"noisefs_client_deduplicated_uploads_total",
+		"noisefs_client_download_seconds",
+		"noisefs_client_downloads_total",
+		"noisefs_client_randomizer_blocks_total",
+		"noisefs_client_speculative_fetches_total",
+		"noisefs_client_speculative_wins_total",
+		"noisefs_client_stored_bytes_total",
+		"noisefs_client_upload_seconds",
+		"noisefs_client_uploaded_bytes_total",
+		"noisefs_client_uploads_total",
+		"noisefs_fuse_operation_errors_total",
+		"noisefs_fuse_operations_total",
+		"noisefs_storage_backend_connected",
+		"noisefs_storage_backend_error_ratio",
+		"noisefs_storage_backend_latency_seconds",
+		"noisefs_storage_backend_up",
+		"noisefs_storage_transfers_in_flight",
+		"noisefs_storage_transfers_max_parallel",
+		"noisefs_websocket_clients",
+		"noisefs_websocket_evictions_total",
+		"noisefs_websocket_messages_total",
+		"noisefs_worker_pool_pending_tasks",
+		"noisefs_worker_pool_tasks_total",
+		"noisefs_worker_pool_workers",
+	}
+
+	descs := make(chan *prometheus.Desc, 100)
+	NewCollector(Sources{}).Describe(descs)
+	close(descs)
+	fqName := regexp.MustCompile(`fqName: "([^"]+)"`)
+	var got []string
+	for desc := range descs {
+		got = append(got, fqName.FindStringSubmatch(desc.String())[1])
+	}
+	sort.Strings(got)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Metric names changed:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestHandlerSkipsUnsetSources(t *testing.T) {
+	body := scrape(t, Handler(Sources{}))
+	for _, unwanted := range []string{"noisefs_client_", "noisefs_storage_", "noisefs_announcements_", "noisefs_websocket_"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("Metrics include %q without its source", unwanted)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	server, err := Serve("127.0.0.1:0", Sources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// renderSystemd returns a systemd unit that restarts the process when it
+// fails
+func renderSystemd(spec Spec, system bool) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", spec.Description)
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=simple\n")
+	if len(spec.Cleanup) > 0 {
+		fmt.Fprintf(&b, "ExecStartPre=-%s\n", systemdCommand(spec.Cleanup))
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommand(append([]string{spec.Program}, spec.Args...)))
+	for _, key := range sortedKeys(spec.Env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(key+"="+spec.Env[key]))
+	}
+	if system && spec.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", spec.User)
+	}
+	b.WriteString("Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=%d\n", restartDelay)
+	b.WriteString("\n[Install]\n")
+	if system {
+		b.WriteString("WantedBy=multi-user.target\n")
+	} else {
+		b.WriteString("WantedBy=default.target\n")
+	}
+	return b.String()
+}
+
+// systemdCommand joins a command line for ExecStart, where variables are
+// expanded too
+func systemdCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = systemdQuote(strings.ReplaceAll(arg, "$", "$$"))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// systemdQuote escapes a word for a unit file: specifiers are doubled, and
+// words with spaces or quotes are double-quoted
+func systemdQuote(word string) string {
+	word = strings.ReplaceAll(word, "%", "%%")
+	if word != "" && !strings.ContainsAny(word, " \t\"'\\") {
+		return word
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word) + `"`
+}
+
+// renderLaunchd returns a launchd job that starts at load and restarts the
+// process when it exits with an error
+func renderLaunchd(spec Spec, label string, system bool, logDir string) string {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	plistKey(&b, "Label")
+	plistString(&b, "\t", label)
+	plistKey(&b, "ProgramArguments")
+	b.WriteString("\t<array>\n")
+	for _, arg := range append([]string{spec.Program}, spec.Args...) {
+		plistString(&b, "\t\t", arg)
+	}
+	b.WriteString("\t</array>\n")
+	if len(spec.Env) > 0 {
+		plistKey(&b, "EnvironmentVariables")
+		b.WriteString("\t<dict>\n")
+		for _, key := range sortedKeys(spec.Env) {
+			b.WriteString("\t\t<key>")
+			xml.EscapeText(&b, []byte(key))
+			b.WriteString("</key>\n")
+			plistString(&b, "\t\t", spec.Env[key])
+		}
+		b.WriteString("\t</dict>\n")
+	}
+	if system && spec.User != "" {
+		plistKey(&b, "UserName")
+		plistString(&b, "\t", spec.User)
+	}
+	plistKey(&b, "RunAtLoad")
+	b.WriteString("\t<true/>\n")
+	plistKey(&b, "KeepAlive")
+	b.WriteString("\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	plistKey(&b, "ThrottleInterval")
+	fmt.Fprintf(&b, "\t<integer>%d</integer>\n", restartDelay)
+	if logDir != "" {
+		logFile := filepath.Join(logDir, spec.Name+".log")
+		plistKey(&b, "StandardOutPath")
+		plistString(&b, "\t", logFile)
+		plistKey(&b, "StandardErrorPath")
+		plistString(&b, "\t", logFile)
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+func plistKey(b *bytes.Buffer, key string) {
+	fmt.Fprintf(b, "\t<key>%s</key>\n", key)
+}
+
+func plistString(b *bytes.Buffer, indent, value string) {
+	b.WriteString(indent + "<string>")
+	xml.EscapeText(b, []byte(value))
+	b.WriteString("</string>\n")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
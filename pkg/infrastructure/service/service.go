@@ -0,0 +1,266 @@
+// Package service installs and supervises the long-running NoiseFS
+// processes, the web UI daemon and mounts, as systemd units on Linux and
+// launchd jobs on macOS.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Init systems
+const (
+	Systemd = "systemd"
+	Launchd = "launchd"
+)
+
+// restartDelay is how long the init system waits before restarting a
+// process that failed, in seconds
+const restartDelay = 5
+
+// ErrUnsupported is returned on platforms without a supported init system
+var ErrUnsupported = errors.New("service management needs systemd (Linux) or launchd (macOS)")
+
+// Spec describes a NoiseFS process to keep running
+type Spec struct {
+	Name        string            // Short name such as "daemon"; the unit is noisefs-<name>
+	Description string            // Shown by the init system
+	Program     string            // Absolute path of the executable
+	Args        []string          // Arguments after the program
+	Env         map[string]string // Extra environment variables
+	User        string            // Account a system service runs as; empty for root
+	Cleanup     []string          // Command run before each start, failures ignored (systemd only)
+}
+
+// Status is the state of an installed service
+type Status struct {
+	Name      string `json:"name"`
+	Unit      string `json:"unit"` // systemd unit name or launchd label
+	Path      string `json:"path"`
+	Installed bool   `json:"installed"`
+	State     string `json:"state"` // As the init system reports it, e.g. active, failed, running
+}
+
+// Runner runs an init system command and returns its combined output
+type Runner func(name string, args ...string) ([]byte, error)
+
+// Manager installs and controls services with one init system
+type Manager struct {
+	Init   string // Systemd or Launchd
+	System bool   // System-wide services rather than the current user's
+	Dir    string // Where unit files are written
+	LogDir string // Where launchd jobs log
+	Run    Runner
+	uid    int
+}
+
+// NewManager returns a manager for this platform's init system, for the
+// current user's services or, if system is set, system-wide ones
+func NewManager(system bool) (*Manager, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil && !system {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	m := &Manager{System: system, Run: runCommand, uid: os.Getuid()}
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("systemctl"); err != nil {
+			return nil, ErrUnsupported
+		}
+		m.Init = Systemd
+		m.Dir = filepath.Join(homeDir, ".config", "systemd", "user")
+		if system {
+			m.Dir = "/etc/systemd/system"
+		}
+	case "darwin":
+		m.Init = Launchd
+		m.Dir = filepath.Join(homeDir, "Library", "LaunchAgents")
+		m.LogDir = filepath.Join(homeDir, "Library", "Logs", "noisefs")
+		if system {
+			m.Dir = "/Library/LaunchDaemons"
+			m.LogDir = "/Library/Logs/noisefs"
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+	return m, nil
+}
+
+// runCommand is the Runner that executes commands
+func runCommand(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// Unit returns the systemd unit name or launchd label of a service
+func (m *Manager) Unit(name string) string {
+	if m.Init == Launchd {
+		return "org.noisefs." + name
+	}
+	return "noisefs-" + name + ".service"
+}
+
+// Path returns where the unit file of a service is written
+func (m *Manager) Path(name string) string {
+	if m.Init == Launchd {
+		return filepath.Join(m.Dir, m.Unit(name)+".plist")
+	}
+	return filepath.Join(m.Dir, m.Unit(name))
+}
+
+// Render returns the unit file for spec
+func (m *Manager) Render(spec Spec) string {
+	if m.Init == Launchd {
+		return renderLaunchd(spec, m.Unit(spec.Name), m.System, m.LogDir)
+	}
+	return renderSystemd(spec, m.System)
+}
+
+// Install writes the unit file for spec, then enables and (re)starts the
+// service. It returns the path of the unit file.
+func (m *Manager) Install(spec Spec) (string, error) {
+	if !filepath.IsAbs(spec.Program) {
+		return "", fmt.Errorf("program %q must be an absolute path", spec.Program)
+	}
+
+	path := m.Path(spec.Name)
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", m.Dir, err)
+	}
+	if m.LogDir != "" {
+		if err := os.MkdirAll(m.LogDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", m.LogDir, err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(m.Render(spec)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if m.Init == Launchd {
+		// Replace a loaded job with the new definition
+		m.Run("launchctl", "bootout", m.target(spec.Name))
+		if err := m.launchctl("bootstrap", m.domain(), path); err != nil {
+			return path, err
+		}
+		return path, nil
+	}
+
+	unit := m.Unit(spec.Name)
+	for _, args := range [][]string{{"daemon-reload"}, {"enable", unit}, {"restart", unit}} {
+		if err := m.systemctl(args...); err != nil {
+			return path, err
+		}
+	}
+	return path, nil
+}
+
+// Uninstall stops and disables a service and removes its unit file
+func (m *Manager) Uninstall(name string) error {
+	path := m.Path(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("%s is not installed", m.Unit(name))
+	}
+
+	if m.Init == Launchd {
+		m.Run("launchctl", "bootout", m.target(name))
+		return os.Remove(path)
+	}
+
+	if err := m.systemctl("disable", "--now", m.Unit(name)); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return m.systemctl("daemon-reload")
+}
+
+// Restart restarts an installed service
+func (m *Manager) Restart(name string) error {
+	if _, err := os.Stat(m.Path(name)); os.IsNotExist(err) {
+		return fmt.Errorf("%s is not installed", m.Unit(name))
+	}
+	if m.Init == Launchd {
+		return m.launchctl("kickstart", "-k", m.target(name))
+	}
+	return m.systemctl("restart", m.Unit(name))
+}
+
+// Status reports whether a service is installed and what state it is in
+func (m *Manager) Status(name string) Status {
+	status := Status{Name: name, Unit: m.Unit(name), Path: m.Path(name)}
+	if _, err := os.Stat(status.Path); err != nil {
+		status.State = "not installed"
+		return status
+	}
+	status.Installed = true
+
+	if m.Init == Launchd {
+		output, err := m.Run("launchctl", "print", m.target(name))
+		status.State = "not loaded"
+		if err == nil {
+			status.State = launchdState(string(output))
+		}
+		return status
+	}
+
+	// is-active exits non-zero for inactive units but still prints the state
+	output, _ := m.Run("systemctl", m.systemctlArgs("is-active", status.Unit)...)
+	status.State = strings.TrimSpace(string(output))
+	if status.State == "" {
+		status.State = "unknown"
+	}
+	return status
+}
+
+// systemctlArgs adds --user for the current user's services
+func (m *Manager) systemctlArgs(args ...string) []string {
+	if m.System {
+		return args
+	}
+	return append([]string{"--user"}, args...)
+}
+
+func (m *Manager) systemctl(args ...string) error {
+	args = m.systemctlArgs(args...)
+	if output, err := m.Run("systemctl", args...); err != nil {
+		return fmt.Errorf("systemctl %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (m *Manager) launchctl(args ...string) error {
+	if output, err := m.Run("launchctl", args...); err != nil {
+		return fmt.Errorf("launchctl %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// domain is the launchd domain services are loaded into
+func (m *Manager) domain() string {
+	if m.System {
+		return "system"
+	}
+	return fmt.Sprintf("gui/%d", m.uid)
+}
+
+// target is the launchd service target of a service
+func (m *Manager) target(name string) string {
+	return m.domain() + "/" + m.Unit(name)
+}
+
+// launchdState picks the state out of launchctl print output
+func launchdState(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "state = ") {
+			return strings.TrimPrefix(line, "state = ")
+		}
+	}
+	return "loaded"
+}
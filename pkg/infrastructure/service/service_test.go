@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands and answers from a table of outputs
+type fakeRunner struct {
+	calls   []string
+	outputs map[string]string
+	fail    map[string]bool
+}
+
+func (f *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	call := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, call)
+	if f.fail[call] {
+		return []byte(f.outputs[call]), errors.New("exit status 3")
+	}
+	return []byte(f.outputs[call]), nil
+}
+
+var testSpec = Spec{
+	Name:        "mount",
+	Description: "NoiseFS mount",
+	Program:     "/usr/local/bin/noisefs-mount",
+	Args:        []string{"-config", "/home/me/My Config/config.json", "-mount", "/mnt/noisefs"},
+	Env:         map[string]string{"NOISEFS_LOG": "50%"},
+	User:        "me",
+	Cleanup:     []string{"/usr/bin/fusermount", "-uz", "/mnt/noisefs"},
+}
+
+func TestRenderSystemd(t *testing.T) {
+	unit := renderSystemd(testSpec, false)
+	for _, want := range []string{
+		`ExecStartPre=-/usr/bin/fusermount -uz /mnt/noisefs`,
+		`ExecStart=/usr/local/bin/noisefs-mount -config "/home/me/My Config/config.json" -mount /mnt/noisefs`,
+		`Environment=NOISEFS_LOG=50%%`,
+		`Restart=on-failure`,
+		`WantedBy=default.target`,
+	} {
+		if !strings.Contains(unit, want+"\n") {
+			t.Errorf("Expected %q in unit:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, "User=") {
+		t.Error("Expected user units not to set User=")
+	}
+
+	unit = renderSystemd(testSpec, true)
+	if !strings.Contains(unit, "User=me\n") || !strings.Contains(unit, "WantedBy=multi-user.target\n") {
+		t.Errorf("Expected a system unit running as me:\n%s", unit)
+	}
+
+	if got := systemdCommand([]string{"/bin/echo", "$HOME", `a"b`}); got != `/bin/echo $$HOME "a\"b"` {
+		t.Errorf("Unexpected quoting %s", got)
+	}
+}
+
+func TestRenderLaunchd(t *testing.T) {
+	spec := testSpec
+	spec.Args = append(spec.Args, "-volicon", "a&b.icns")
+	plist := renderLaunchd(spec, "org.noisefs.mount", false, "/Users/me/Library/Logs/noisefs")
+	for _, want := range []string{
+		"<string>org.noisefs.mount</string>",
+		"<string>/home/me/My Config/config.json</string>",
+		"<string>a&amp;b.icns</string>",
+		"<key>SuccessfulExit</key>",
+		"<string>/Users/me/Library/Logs/noisefs/mount.log</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("Expected %q in plist:\n%s", want, plist)
+		}
+	}
+	if strings.Contains(plist, "UserName") {
+		t.Error("Expected agents not to set UserName")
+	}
+}
+
+func TestSystemdManager(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{"systemctl --user is-active noisefs-mount.service": "failed\n"},
+		fail:    map[string]bool{"systemctl --user is-active noisefs-mount.service": true},
+	}
+	m := &Manager{Init: Systemd, Dir: t.TempDir(), Run: runner.run}
+
+	if status := m.Status("mount"); status.Installed || status.State != "not installed" {
+		t.Errorf("Expected the service not to be installed, got %+v", status)
+	}
+	if err := m.Restart("mount"); err == nil {
+		t.Error("Expected restarting a service that isn't installed to fail")
+	}
+
+	path, err := m.Install(testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(m.Dir, "noisefs-mount.service") {
+		t.Errorf("Unexpected unit path %s", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != renderSystemd(testSpec, false) {
+		t.Errorf("Expected the rendered unit at %s, got %v", path, err)
+	}
+	wantCalls := "systemctl --user daemon-reload|systemctl --user enable noisefs-mount.service|systemctl --user restart noisefs-mount.service"
+	if got := strings.Join(runner.calls, "|"); got != wantCalls {
+		t.Errorf("Unexpected install commands %s", got)
+	}
+
+	if status := m.Status("mount"); !status.Installed || status.State != "failed" {
+		t.Errorf("Expected an installed, failed service, got %+v", status)
+	}
+
+	if err := m.Uninstall("mount"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected the unit file to be removed")
+	}
+
+	spec := testSpec
+	spec.Program = "noisefs-mount"
+	if _, err := m.Install(spec); err == nil {
+		t.Error("Expected a relative program path to be rejected")
+	}
+}
+
+func TestLaunchdManager(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"launchctl print gui/501/org.noisefs.daemon": "org.noisefs.daemon = {\n\tstate = running\n\tpid = 42\n}\n",
+	}}
+	dir := t.TempDir()
+	m := &Manager{Init: Launchd, Dir: dir, LogDir: filepath.Join(dir, "logs"), Run: runner.run, uid: 501}
+
+	spec := Spec{Name: "daemon", Description: "NoiseFS daemon", Program: "/usr/local/bin/noisefs-webui"}
+	path, err := m.Install(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "org.noisefs.daemon.plist") {
+		t.Errorf("Unexpected plist path %s", path)
+	}
+	if status := m.Status("daemon"); status.State != "running" {
+		t.Errorf("Expected a running job, got %+v", status)
+	}
+	if err := m.Restart("daemon"); err != nil {
+		t.Fatal(err)
+	}
+	wantCalls := "launchctl bootout gui/501/org.noisefs.daemon|launchctl bootstrap gui/501 " + path +
+		"|launchctl print gui/501/org.noisefs.daemon|launchctl kickstart -k gui/501/org.noisefs.daemon"
+	if got := strings.Join(runner.calls, "|"); got != wantCalls {
+		t.Errorf("Unexpected commands %s", got)
+	}
+}
@@ -0,0 +1,334 @@
+// Package telemetry counts which NoiseFS features are used and, only when
+// the user opts in, reports the counts so maintainers can see what is used
+// in practice. Reports are anonymous: they carry no identifier, file
+// names, CIDs, topics or addresses, only the NoiseFS version, the
+// operating system and a usage bucket per feature.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+)
+
+// SchemaVersion is the version of the report format
+const SchemaVersion = 1
+
+// requestTimeout bounds sending a report
+const requestTimeout = 5 * time.Second
+
+// checkInterval is how often long-running processes check whether a
+// report is due
+const checkInterval = time.Hour
+
+// Features are the names usage is counted under. Anything else is refused,
+// so a report can't carry more than this list.
+var Features = []string{
+	"announce",
+	"bundle",
+	"directory_share",
+	"download",
+	"index",
+	"mount",
+	"pack",
+	"pin",
+	"remote",
+	"s3_gateway",
+	"search",
+	"service",
+	"share",
+	"snapshots",
+	"stats",
+	"subscribe",
+	"sync",
+	"takedown",
+	"upload",
+	"webui",
+}
+
+var knownFeatures = func() map[string]bool {
+	known := make(map[string]bool, len(Features))
+	for _, feature := range Features {
+		known[feature] = true
+	}
+	return known
+}()
+
+// IsFeature reports whether usage is counted under a name
+func IsFeature(name string) bool {
+	return knownFeatures[name]
+}
+
+// Report is what is sent to the telemetry endpoint
+type Report struct {
+	Schema   int               `json:"schema"`
+	Version  string            `json:"version"`
+	OS       string            `json:"os"`
+	Arch     string            `json:"arch"`
+	Features map[string]string `json:"features"` // Usage bucket of each feature used
+}
+
+// Bucket reduces a count to the range reports carry
+func Bucket(count int) string {
+	switch {
+	case count <= 0:
+		return "0"
+	case count == 1:
+		return "1"
+	case count <= 5:
+		return "2-5"
+	case count <= 20:
+		return "6-20"
+	case count <= 100:
+		return "21-100"
+	default:
+		return "100+"
+	}
+}
+
+// state is what is kept between reports
+type state struct {
+	Counts   map[string]int `json:"counts"`
+	Since    time.Time      `json:"since"` // When counting for the next report started
+	LastSent time.Time      `json:"last_sent,omitempty"`
+}
+
+// Telemetry counts feature usage and sends reports
+type Telemetry struct {
+	cfg    config.TelemetryConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// New creates telemetry for a configuration
+func New(cfg config.TelemetryConfig) *Telemetry {
+	return &Telemetry{
+		cfg:    cfg,
+		client: &http.Client{Timeout: requestTimeout},
+		now:    time.Now,
+	}
+}
+
+// Enabled reports whether the user opted in
+func (t *Telemetry) Enabled() bool {
+	return t.cfg.Enabled
+}
+
+// Record counts a use of a feature. Nothing is counted unless telemetry is
+// enabled. Concurrent processes may lose a count, which reports are too
+// coarse to show.
+func (t *Telemetry) Record(feature string) error {
+	if !knownFeatures[feature] {
+		return fmt.Errorf("unknown telemetry feature %q", feature)
+	}
+	if !t.cfg.Enabled {
+		return nil
+	}
+	st, err := t.load()
+	if err != nil {
+		return err
+	}
+	st.Counts[feature]++
+	return t.save(st)
+}
+
+// Use counts a use of a feature and sends the report if one is due, when
+// telemetry is enabled. Failures are logged; telemetry never fails the
+// feature it counts.
+func Use(cfg config.TelemetryConfig, feature string) {
+	if !cfg.Enabled {
+		return
+	}
+	logger := logging.GetGlobalLogger().WithComponent("telemetry")
+	t := New(cfg)
+	if err := t.Record(feature); err != nil {
+		logger.Warn("Failed to count feature usage", map[string]interface{}{
+			"feature": feature,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if _, err := t.SendIfDue(context.Background()); err != nil {
+		logger.Warn("Failed to send usage report", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// Run sends reports as they become due until ctx is done, for daemons
+// that run longer than the report interval
+func Run(ctx context.Context, cfg config.TelemetryConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	logger := logging.GetGlobalLogger().WithComponent("telemetry")
+	t := New(cfg)
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := t.SendIfDue(ctx); err != nil {
+				logger.Warn("Failed to send usage report", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// Preview returns the report that would be sent next
+func (t *Telemetry) Preview() (*Report, error) {
+	st, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	return newReport(st), nil
+}
+
+// NextSend returns when the next report is due
+func (t *Telemetry) NextSend() (time.Time, error) {
+	st, err := t.load()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.nextSend(st), nil
+}
+
+func (t *Telemetry) nextSend(st *state) time.Time {
+	return st.Since.Add(time.Duration(t.cfg.IntervalHours) * time.Hour)
+}
+
+// SendIfDue sends the report when telemetry is enabled and a report is
+// due, and returns the report sent, or nil if none was
+func (t *Telemetry) SendIfDue(ctx context.Context) (*Report, error) {
+	if !t.cfg.Enabled {
+		return nil, nil
+	}
+	st, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	if t.now().Before(t.nextSend(st)) {
+		return nil, nil
+	}
+	return t.send(ctx, st)
+}
+
+// Send sends the report now, without waiting for it to be due. It still
+// refuses to send when telemetry is disabled.
+func (t *Telemetry) Send(ctx context.Context) (*Report, error) {
+	if !t.cfg.Enabled {
+		return nil, fmt.Errorf("telemetry is disabled")
+	}
+	st, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	return t.send(ctx, st)
+}
+
+// send posts the report and starts counting for the next one. The counts
+// are reset even if the report couldn't be sent, so a failing endpoint
+// isn't retried until the next interval.
+func (t *Telemetry) send(ctx context.Context, st *state) (*Report, error) {
+	report := newReport(st)
+	now := t.now()
+	if err := t.save(&state{Counts: map[string]int{}, Since: now, LastSent: now}); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send telemetry: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return report, nil
+}
+
+// newReport builds the report of the counts kept so far
+func newReport(st *state) *Report {
+	report := &Report{
+		Schema:   SchemaVersion,
+		Version:  version.Build(),
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Features: make(map[string]string),
+	}
+	names := make([]string, 0, len(st.Counts))
+	for feature := range st.Counts {
+		names = append(names, feature)
+	}
+	sort.Strings(names)
+	for _, feature := range names {
+		if knownFeatures[feature] && st.Counts[feature] > 0 {
+			report.Features[feature] = Bucket(st.Counts[feature])
+		}
+	}
+	return report
+}
+
+// load reads the kept counts, starting a new period if there are none
+func (t *Telemetry) load() (*state, error) {
+	st := &state{}
+	data, err := os.ReadFile(t.cfg.StatePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", t.cfg.StatePath, err)
+		}
+	}
+	if st.Counts == nil {
+		st.Counts = make(map[string]int)
+	}
+	if st.Since.IsZero() {
+		st.Since = t.now()
+	}
+	return st, nil
+}
+
+// save writes the counts atomically
+func (t *Telemetry) save(st *state) error {
+	if err := os.MkdirAll(filepath.Dir(t.cfg.StatePath), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.cfg.StatePath)
+}
@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+)
+
+func TestBucket(t *testing.T) {
+	for count, want := range map[int]string{0: "0", 1: "1", 3: "2-5", 20: "6-20", 21: "21-100", 5000: "100+"} {
+		if got := Bucket(count); got != want {
+			t.Errorf("Bucket(%d) = %s, want %s", count, got, want)
+		}
+	}
+}
+
+func TestTelemetryDisabledCountsNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telemetry.json")
+	tel := New(config.TelemetryConfig{Endpoint: "http://127.0.0.1:1", IntervalHours: 24, StatePath: path})
+	if err := tel.Record("upload"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be kept while telemetry is disabled")
+	}
+	if report, err := tel.SendIfDue(context.Background()); report != nil || err != nil {
+		t.Errorf("SendIfDue() = %v, %v", report, err)
+	}
+	if _, err := tel.Send(context.Background()); err == nil {
+		t.Error("Expected sending to be refused while telemetry is disabled")
+	}
+}
+
+func TestTelemetrySendsBucketedCounts(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	tel := New(config.TelemetryConfig{Enabled: true, Endpoint: server.URL, IntervalHours: 168, StatePath: filepath.Join(t.TempDir(), "telemetry.json")})
+	tel.now = func() time.Time { return now }
+
+	for i := 0; i < 7; i++ {
+		if err := tel.Record("upload"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tel.Record("sync"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tel.Record("/home/alice/secret.pdf"); err == nil {
+		t.Error("Expected an unknown feature to be refused")
+	}
+
+	preview, err := tel.Preview()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"upload": "6-20", "sync": "1"}; !reflect.DeepEqual(preview.Features, want) {
+		t.Errorf("Preview features = %v, want %v", preview.Features, want)
+	}
+
+	// Nothing is sent before the interval has passed
+	now = now.Add(24 * time.Hour)
+	if report, err := tel.SendIfDue(context.Background()); report != nil || err != nil {
+		t.Fatalf("SendIfDue() before the interval = %v, %v", report, err)
+	}
+
+	now = now.Add(7 * 24 * time.Hour)
+	report, err := tel.SendIfDue(context.Background())
+	if err != nil || report == nil {
+		t.Fatalf("SendIfDue() = %v, %v", report, err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one report, got %d", len(bodies))
+	}
+	var sent Report
+	if err := json.Unmarshal(bodies[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&sent, preview) {
+		t.Errorf("Sent %+v, previewed %+v", sent, preview)
+	}
+
+	// The counts start over, and the next report waits another interval
+	if report, err := tel.SendIfDue(context.Background()); report != nil || err != nil {
+		t.Errorf("Expected no second report in the same interval, got %v, %v", report, err)
+	}
+	if preview, _ := tel.Preview(); len(preview.Features) != 0 {
+		t.Errorf("Expected the counts to start over, got %v", preview.Features)
+	}
+	if next, _ := tel.NextSend(); !next.Equal(now.Add(168 * time.Hour)) {
+		t.Errorf("NextSend() = %s", next)
+	}
+}
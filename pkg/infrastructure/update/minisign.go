@@ -0,0 +1,91 @@
+package update
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign algorithm identifiers: signatures of the data itself, and of
+// its BLAKE2b-512 hash, which minisign writes by default
+const (
+	algorithmPure   = "Ed"
+	algorithmHashed = "ED"
+)
+
+// ErrBadSignature is returned for data that isn't signed by the release key
+var ErrBadSignature = errors.New("signature verification failed")
+
+// PublicKey is a minisign public key
+type PublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// ParsePublicKey parses a minisign public key, either the base64 line or
+// the whole .pub file with its comment
+func ParsePublicKey(text string) (*PublicKey, error) {
+	line := ""
+	for _, l := range strings.Split(strings.TrimSpace(text), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			line = l
+			break
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(data) != 2+8+ed25519.PublicKeySize || string(data[:2]) != algorithmPure {
+		return nil, errors.New("invalid minisign public key")
+	}
+	pub := &PublicKey{key: ed25519.PublicKey(data[10:])}
+	copy(pub.keyID[:], data[2:10])
+	return pub, nil
+}
+
+// Verify checks a minisign signature file over data and returns its trusted
+// comment
+func (p *PublicKey) Verify(data, signature []byte) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(signature)), "\n") {
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errors.New("invalid minisign signature file")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return "", errors.New("invalid minisign signature")
+	}
+	if !bytes.Equal(sig[2:10], p.keyID[:]) {
+		return "", fmt.Errorf("%w: signed with key %X, not the release key %X", ErrBadSignature, sig[2:10], p.keyID[:])
+	}
+	signed := data
+	switch string(sig[:2]) {
+	case algorithmPure:
+	case algorithmHashed:
+		hash := blake2b.Sum512(data)
+		signed = hash[:]
+	default:
+		return "", fmt.Errorf("unsupported minisign algorithm %q", sig[:2])
+	}
+	if !ed25519.Verify(p.key, signed, sig[10:]) {
+		return "", ErrBadSignature
+	}
+
+	// The global signature covers the trusted comment, so it can't be
+	// swapped for another release's
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return "", errors.New("invalid minisign trusted comment signature")
+	}
+	if !ed25519.Verify(p.key, append(append([]byte{}, sig[10:]...), comment...), global) {
+		return "", fmt.Errorf("%w: trusted comment was altered", ErrBadSignature)
+	}
+	return comment, nil
+}
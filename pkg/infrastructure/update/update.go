@@ -0,0 +1,226 @@
+// Package update installs NoiseFS releases in place of the running binary.
+// Each channel has a manifest listing its latest release and the SHA-256
+// of every binary. The manifest is signed with the minisign release key, so
+// a binary is only installed when the key vouches for its hash.
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/version"
+)
+
+// maxManifestSize bounds the manifest and its signature
+const maxManifestSize = 1 << 20
+
+// ErrUpToDate is returned when the running build is the channel's latest
+// release or newer
+var ErrUpToDate = errors.New("already up to date")
+
+// Manifest lists the latest release of a channel
+type Manifest struct {
+	Channel   string           `json:"channel"`
+	Version   string           `json:"version"`
+	Published time.Time        `json:"published"`
+	Notes     string           `json:"notes,omitempty"` // URL of the release notes
+	Assets    map[string]Asset `json:"assets"`          // Keyed by AssetName
+}
+
+// Asset is one binary of a release
+type Asset struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// AssetName returns the manifest key of a binary for this platform, such
+// as noisefs-linux-amd64
+func AssetName(binary string) string {
+	return fmt.Sprintf("%s-%s-%s", strings.TrimSuffix(binary, ".exe"), runtime.GOOS, runtime.GOARCH)
+}
+
+// Updater fetches a channel's releases and installs them
+type Updater struct {
+	channel string
+	baseURL string
+	key     *PublicKey
+	client  *http.Client
+}
+
+// New creates an updater for the configured channel. It fails without a
+// release key, since nothing could be verified.
+func New(cfg config.UpdateConfig) (*Updater, error) {
+	if strings.TrimSpace(cfg.PublicKey) == "" {
+		return nil, errors.New("no release key configured; set update.public_key to the minisign key published with NoiseFS releases")
+	}
+	key, err := ParsePublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("update.public_key: %w", err)
+	}
+	return &Updater{
+		channel: cfg.Channel,
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+		key:     key,
+		client:  &http.Client{Timeout: 10 * time.Minute},
+	}, nil
+}
+
+// Latest fetches the channel's manifest and checks its signature
+func (u *Updater) Latest(ctx context.Context) (*Manifest, error) {
+	manifestURL := fmt.Sprintf("%s/%s.json", u.baseURL, u.channel)
+	data, err := u.fetch(ctx, manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := u.fetch(ctx, manifestURL+".minisig")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := u.key.Verify(data, signature); err != nil {
+		return nil, fmt.Errorf("release manifest %s: %w", manifestURL, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse release manifest: %w", err)
+	}
+	// A signed manifest of another channel must not pass for this one
+	if manifest.Channel != u.channel {
+		return nil, fmt.Errorf("release manifest is for the %q channel, not %q", manifest.Channel, u.channel)
+	}
+	if !version.ValidRelease(manifest.Version) {
+		return nil, fmt.Errorf("release manifest has an invalid version %q", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// Check reports whether a manifest's release is newer than the running
+// build. Development builds have no version to compare and are always
+// offered the release.
+func Check(manifest *Manifest, current string) error {
+	cmp, err := version.Compare(manifest.Version, current)
+	if err == nil && cmp <= 0 {
+		return fmt.Errorf("%w: %s is the latest %s release", ErrUpToDate, current, manifest.Channel)
+	}
+	return nil
+}
+
+// Install downloads a manifest's binary for this platform and atomically
+// replaces the executable at path with it, keeping its permissions. The
+// executable is left untouched unless the download matches the signed hash.
+func (u *Updater) Install(ctx context.Context, manifest *Manifest, path string) error {
+	name := AssetName(filepath.Base(path))
+	asset, ok := manifest.Assets[name]
+	if !ok {
+		return fmt.Errorf("release %s has no %s binary", manifest.Version, name)
+	}
+	expected, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("release manifest has an invalid hash for %s", name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	// Download next to the executable so the rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write next to %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := u.download(ctx, asset, tmp, expected); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return replace(tmp.Name(), path)
+}
+
+// download writes an asset to w and checks its size and hash
+func (u *Updater) download(ctx context.Context, asset Asset, w io.Writer, expected []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", asset.URL, resp.Status)
+	}
+
+	hash := sha256.New()
+	body := io.Reader(resp.Body)
+	if asset.Size > 0 {
+		body = io.LimitReader(resp.Body, asset.Size+1)
+	}
+	n, err := io.Copy(io.MultiWriter(w, hash), body)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", asset.URL, err)
+	}
+	if asset.Size > 0 && n != asset.Size {
+		return fmt.Errorf("downloaded %d bytes of %s, expected %d", n, asset.URL, asset.Size)
+	}
+	if !bytes.Equal(hash.Sum(nil), expected) {
+		return fmt.Errorf("%s does not match the hash in the signed manifest", asset.URL)
+	}
+	return nil
+}
+
+// replace moves the new binary over the old one. Windows can't replace a
+// running executable, but can rename it, so the old one is moved aside.
+func replace(newPath, path string) error {
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+		if err := os.Rename(newPath, path); err != nil {
+			os.Rename(old, path)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(newPath, path)
+}
+
+// fetch reads a small file from the release server
+func (u *Updater) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+}
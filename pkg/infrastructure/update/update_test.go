@@ -0,0 +1,185 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+)
+
+// testKey is a minisign key pair made in the format the minisign tool
+// writes
+type testKey struct {
+	id   [8]byte
+	priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T) *testKey {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &testKey{priv: priv}
+	rand.Read(k.id[:])
+	return k
+}
+
+func (k *testKey) public() string {
+	data := append([]byte(algorithmPure), k.id[:]...)
+	data = append(data, k.priv.Public().(ed25519.PublicKey)...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(data) + "\n"
+}
+
+func (k *testKey) sign(data []byte, algorithm, comment string) []byte {
+	signed := data
+	if algorithm == algorithmHashed {
+		hash := blake2b.Sum512(data)
+		signed = hash[:]
+	}
+	sig := ed25519.Sign(k.priv, signed)
+	global := ed25519.Sign(k.priv, append(append([]byte{}, sig...), comment...))
+	line := append(append([]byte(algorithm), k.id[:]...), sig...)
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(line) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+func TestVerify(t *testing.T) {
+	key := newTestKey(t)
+	pub, err := ParsePublicKey(key.public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("release manifest")
+
+	for _, algorithm := range []string{algorithmPure, algorithmHashed} {
+		comment, err := pub.Verify(data, key.sign(data, algorithm, "timestamp:1 file:stable.json"))
+		if err != nil || comment != "timestamp:1 file:stable.json" {
+			t.Errorf("Verify(%s) = %q, %v", algorithm, comment, err)
+		}
+	}
+
+	if _, err := pub.Verify([]byte("tampered"), key.sign(data, algorithmHashed, "c")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected tampered data to fail, got %v", err)
+	}
+	altered := strings.Replace(string(key.sign(data, algorithmPure, "file:stable.json")), "file:stable.json", "file:beta.json", 1)
+	if _, err := pub.Verify(data, []byte(altered)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected an altered trusted comment to fail, got %v", err)
+	}
+	if _, err := pub.Verify(data, newTestKey(t).sign(data, algorithmPure, "c")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected another key's signature to fail, got %v", err)
+	}
+}
+
+// releaseServer serves a signed manifest of one binary
+func releaseServer(t *testing.T, key *testKey, channel string, binary []byte) (*httptest.Server, *[]byte) {
+	served := append([]byte{}, binary...)
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	hash := sha256.Sum256(binary)
+	manifest, err := json.Marshal(Manifest{
+		Channel: channel,
+		Version: "v9.9.9",
+		Assets: map[string]Asset{
+			AssetName("noisefs"): {URL: server.URL + "/noisefs", SHA256: hex.EncodeToString(hash[:]), Size: int64(len(binary))},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/stable.json", func(w http.ResponseWriter, r *http.Request) { w.Write(manifest) })
+	mux.HandleFunc("/stable.json.minisig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(key.sign(manifest, algorithmHashed, "file:stable.json"))
+	})
+	mux.HandleFunc("/noisefs", func(w http.ResponseWriter, r *http.Request) { w.Write(served) })
+	t.Cleanup(server.Close)
+	return server, &served
+}
+
+func TestUpdaterInstall(t *testing.T) {
+	key := newTestKey(t)
+	server, served := releaseServer(t, key, config.UpdateChannelStable, []byte("new binary"))
+	updater, err := New(config.UpdateConfig{Channel: config.UpdateChannelStable, URL: server.URL, PublicKey: key.public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := updater.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(manifest, "v9.9.9"); !errors.Is(err, ErrUpToDate) {
+		t.Errorf("Expected the same version to be up to date, got %v", err)
+	}
+	if err := Check(manifest, "v1.0.0"); err != nil {
+		t.Errorf("Expected an older version to be offered the release, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "noisefs")
+	if err := os.WriteFile(path, []byte("old binary"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	// A download that doesn't match the signed hash is never installed
+	*served = []byte("evil binary")
+	if err := updater.Install(context.Background(), manifest, path); err == nil {
+		t.Error("Expected a binary not matching the manifest to be refused")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old binary" {
+		t.Errorf("Expected the old binary to be kept, got %q", data)
+	}
+
+	*served = []byte("new binary")
+	if err := updater.Install(context.Background(), manifest, path); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new binary" {
+		t.Errorf("Expected the new binary, got %q", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0750 {
+		t.Errorf("Expected the permissions to be kept, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected no temporary files to be left, got %d entries", len(entries))
+	}
+}
+
+func TestUpdaterRejectsUntrustedManifests(t *testing.T) {
+	key := newTestKey(t)
+
+	// Signed with another key
+	server, _ := releaseServer(t, newTestKey(t), config.UpdateChannelStable, []byte("binary"))
+	updater, err := New(config.UpdateConfig{Channel: config.UpdateChannelStable, URL: server.URL, PublicKey: key.public()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := updater.Latest(context.Background()); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected a manifest signed with another key to be refused, got %v", err)
+	}
+
+	// A beta manifest served as the stable one
+	server, _ = releaseServer(t, key, config.UpdateChannelBeta, []byte("binary"))
+	updater, _ = New(config.UpdateConfig{Channel: config.UpdateChannelStable, URL: server.URL, PublicKey: key.public()})
+	if _, err := updater.Latest(context.Background()); err == nil {
+		t.Error("Expected a manifest of another channel to be refused")
+	}
+
+	if _, err := New(config.UpdateConfig{Channel: config.UpdateChannelStable, URL: server.URL}); err == nil {
+		t.Error("Expected an updater without a release key to be refused")
+	}
+}
@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gocid "github.com/ipfs/go-cid"
+)
+
+// libp2pKeyCodec is the multicodec of CIDs that encode an IPNS key name
+const libp2pKeyCodec = 0x72
+
+// NameResolver resolves IPNS names to CIDs. *storage.Manager satisfies it.
+type NameResolver interface {
+	ResolveName(ctx context.Context, name string) (string, error)
+}
+
+// SetNameResolver enables IPNS names in ResolveCID
+func (v *Validator) SetNameResolver(resolver NameResolver) {
+	v.nameResolver = resolver
+}
+
+// NormalizeCID validates a CID given in any multibase encoding, optionally
+// prefixed with /ipfs/ or ipfs://, and returns its canonical form: CIDv0
+// stays base58btc ("Qm..."), CIDv1 is rendered in lowercase base32 ("bafy...").
+func (v *Validator) NormalizeCID(input string) (string, error) {
+	value := strings.TrimSpace(input)
+	for _, prefix := range []string{"/ipfs/", "ipfs://"} {
+		value = strings.TrimPrefix(value, prefix)
+	}
+	value = strings.TrimSuffix(value, "/")
+
+	if value == "" {
+		return "", ValidationError{
+			Field:   "cid",
+			Message: "CID cannot be empty",
+			Value:   input,
+		}
+	}
+
+	// Basic length check before decoding
+	if len(value) < 10 || len(value) > 128 {
+		return "", ValidationError{
+			Field:   "cid",
+			Message: "CID length invalid",
+			Value:   input,
+		}
+	}
+
+	c, err := gocid.Decode(value)
+	if err != nil {
+		return "", ValidationError{
+			Field:   "cid",
+			Message: fmt.Sprintf("CID format invalid: %v", err),
+			Value:   input,
+		}
+	}
+
+	return c.String(), nil
+}
+
+// ResolveCID is like NormalizeCID but also accepts IPNS names (/ipns/<name>,
+// ipns://<name>, ipns:<name> or a bare libp2p-key CID) when a name resolver
+// is set, returning the canonical CID the name currently points to.
+func (v *Validator) ResolveCID(ctx context.Context, input string) (string, error) {
+	name, isName := ipnsName(input)
+	if !isName {
+		return v.NormalizeCID(input)
+	}
+
+	if v.nameResolver == nil {
+		return "", ValidationError{
+			Field:   "cid",
+			Message: "IPNS names are not supported",
+			Value:   input,
+		}
+	}
+
+	if name == "" || len(name) > 253 || strings.ContainsAny(name, "/?#\\ ") {
+		return "", ValidationError{
+			Field:   "cid",
+			Message: "IPNS name invalid",
+			Value:   input,
+		}
+	}
+
+	resolved, err := v.nameResolver.ResolveName(ctx, "/ipns/"+name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve IPNS name %s: %w", name, err)
+	}
+
+	// Names may point into a directory; only the root CID is used
+	resolved = strings.TrimPrefix(resolved, "/ipfs/")
+	if i := strings.Index(resolved, "/"); i != -1 {
+		resolved = resolved[:i]
+	}
+
+	return v.NormalizeCID(resolved)
+}
+
+// ipnsName extracts the name from an IPNS reference, reporting whether the
+// input refers to IPNS at all
+func ipnsName(input string) (string, bool) {
+	value := strings.TrimSpace(input)
+	for _, prefix := range []string{"/ipns/", "ipns://", "ipns:"} {
+		if strings.HasPrefix(value, prefix) {
+			return strings.TrimSuffix(strings.TrimPrefix(value, prefix), "/"), true
+		}
+	}
+
+	// A CID with the libp2p-key codec is an IPNS key rather than content
+	if c, err := gocid.Decode(value); err == nil && c.Type() == libp2pKeyCodec {
+		return value, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	gocid "github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multibase"
+)
+
+const testCIDv0 = "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+
+type fakeNameResolver map[string]string
+
+func (f fakeNameResolver) ResolveName(ctx context.Context, name string) (string, error) {
+	if resolved, ok := f[name]; ok {
+		return resolved, nil
+	}
+	return "", fmt.Errorf("name not found")
+}
+
+func TestNormalizeCID(t *testing.T) {
+	validator := NewValidator()
+
+	v0, err := gocid.Decode(testCIDv0)
+	if err != nil {
+		t.Fatalf("Failed to decode test CID: %v", err)
+	}
+	v1 := gocid.NewCidV1(gocid.DagProtobuf, v0.Hash())
+	canonicalV1 := v1.String()
+	if !strings.HasPrefix(canonicalV1, "bafy") {
+		t.Fatalf("Expected base32 CIDv1, got %s", canonicalV1)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{testCIDv0, testCIDv0},
+		{"/ipfs/" + testCIDv0, testCIDv0},
+		{canonicalV1, canonicalV1},
+		{"ipfs://" + canonicalV1 + "/", canonicalV1},
+		{strings.ToUpper(canonicalV1), canonicalV1},
+	}
+	for _, base := range []multibase.Encoding{multibase.Base36, multibase.Base58BTC, multibase.Base64url} {
+		encoded, err := v1.StringOfBase(base)
+		if err != nil {
+			t.Fatalf("Failed to encode CID: %v", err)
+		}
+		tests = append(tests, struct {
+			input    string
+			expected string
+		}{encoded, canonicalV1})
+	}
+
+	for _, tt := range tests {
+		got, err := validator.NormalizeCID(tt.input)
+		if err != nil {
+			t.Errorf("NormalizeCID(%s) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("NormalizeCID(%s) = %s, want %s", tt.input, got, tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"", "not-a-cid", "Qm" + strings.Repeat("0", 44), "zzzzzzzzzzzzzzzz"} {
+		if err := validator.ValidateCID(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestResolveCID_IPNS(t *testing.T) {
+	validator := NewValidator()
+
+	v0, _ := gocid.Decode(testCIDv0)
+	keyName := gocid.NewCidV1(libp2pKeyCodec, v0.Hash()).String()
+
+	// IPNS names are rejected until a resolver is configured
+	if _, err := validator.ResolveCID(context.Background(), "/ipns/"+keyName); err == nil {
+		t.Fatal("Expected IPNS name to be rejected without a resolver")
+	}
+
+	validator.SetNameResolver(fakeNameResolver{
+		"/ipns/" + keyName:       "/ipfs/" + testCIDv0,
+		"/ipns/docs.example.org": "/ipfs/" + testCIDv0 + "/index.html",
+	})
+
+	for _, input := range []string{"/ipns/" + keyName, "ipns://" + keyName, "ipns:" + keyName, keyName, "/ipns/docs.example.org"} {
+		got, err := validator.ResolveCID(context.Background(), input)
+		if err != nil {
+			t.Errorf("ResolveCID(%s) failed: %v", input, err)
+			continue
+		}
+		if got != testCIDv0 {
+			t.Errorf("ResolveCID(%s) = %s, want %s", input, got, testCIDv0)
+		}
+	}
+
+	// Plain CIDs pass through without resolution
+	if got, err := validator.ResolveCID(context.Background(), testCIDv0); err != nil || got != testCIDv0 {
+		t.Errorf("ResolveCID(%s) = %s, %v", testCIDv0, got, err)
+	}
+
+	if _, err := validator.ResolveCID(context.Background(), "/ipns/unknown.example.org"); err == nil {
+		t.Error("Expected unresolvable name to fail")
+	}
+}
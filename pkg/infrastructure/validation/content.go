@@ -0,0 +1,173 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLen is the number of leading bytes inspected by DetectContentType
+const SniffLen = 512
+
+// magicSignature identifies a content type by bytes at a fixed offset
+type magicSignature struct {
+	offset      int
+	magic       []byte
+	contentType string
+}
+
+// magicSignatures covers formats that http.DetectContentType misses or
+// reports generically, most specific first
+var magicSignatures = []magicSignature{
+	// Executables
+	{0, []byte("\x7fELF"), "application/x-executable"},
+	{0, []byte("MZ"), "application/x-msdownload"},
+	{0, []byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{0, []byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{0, []byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+	{0, []byte("#!"), "text/x-shellscript"},
+
+	// Packages and archives
+	{0, []byte("!<arch>\ndebian-binary"), "application/vnd.debian.binary-package"},
+	{0, []byte("\xed\xab\xee\xdb"), "application/x-rpm"},
+	{0, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "application/x-ole-storage"},
+	{0, []byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{0, []byte("Rar!\x1a\x07"), "application/vnd.rar"},
+	{0, []byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\x28\xb5\x2f\xfd"), "application/zstd"},
+	{257, []byte("ustar"), "application/x-tar"},
+
+	// Media
+	{4, []byte("ftypqt"), "video/quicktime"},
+	{4, []byte("ftypM4A"), "audio/mp4"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftyp"), "video/mp4"},
+	{0, []byte("\x1a\x45\xdf\xa3"), "video/x-matroska"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("OggS"), "audio/ogg"},
+	{0, []byte("\x00\x00\x00\x0cjP  "), "image/jp2"},
+}
+
+// riffSubtypes maps RIFF container form types to content types
+var riffSubtypes = map[string]string{
+	"WAVE": "audio/wav",
+	"AVI ": "video/x-msvideo",
+	"WEBP": "image/webp",
+}
+
+// DetectContentType determines the MIME type of data from its leading bytes.
+// It never trusts the file name; at most SniffLen bytes are considered.
+func DetectContentType(data []byte) string {
+	if len(data) > SniffLen {
+		data = data[:SniffLen]
+	}
+
+	// RIFF containers share a prefix and differ by form type
+	if len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) {
+		if contentType, ok := riffSubtypes[string(data[8:12])]; ok {
+			return contentType
+		}
+	}
+
+	for _, sig := range magicSignatures {
+		end := sig.offset + len(sig.magic)
+		if len(data) >= end && bytes.Equal(data[sig.offset:end], sig.magic) {
+			return sig.contentType
+		}
+	}
+
+	// Strip parameters such as "; charset=utf-8" so policies match on the bare type
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	return contentType
+}
+
+// SniffReader detects the content type of r and returns a reader that
+// still yields the complete stream, including the sniffed bytes
+func SniffReader(r io.Reader) (string, io.Reader, error) {
+	header := make([]byte, SniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	header = header[:n]
+
+	return DetectContentType(header), io.MultiReader(bytes.NewReader(header), r), nil
+}
+
+// contentTypeExtensions maps detected content types to a conventional file extension
+var contentTypeExtensions = map[string]string{
+	"video/mp4":                             ".mp4",
+	"video/quicktime":                       ".mov",
+	"video/x-matroska":                      ".mkv",
+	"video/webm":                            ".webm",
+	"video/x-msvideo":                       ".avi",
+	"audio/mpeg":                            ".mp3",
+	"audio/mp4":                             ".m4a",
+	"audio/flac":                            ".flac",
+	"audio/ogg":                             ".ogg",
+	"audio/wav":                             ".wav",
+	"image/jpeg":                            ".jpg",
+	"image/png":                             ".png",
+	"image/gif":                             ".gif",
+	"image/webp":                            ".webp",
+	"image/bmp":                             ".bmp",
+	"image/heic":                            ".heic",
+	"application/pdf":                       ".pdf",
+	"application/zip":                       ".zip",
+	"application/x-gzip":                    ".gz",
+	"application/x-7z-compressed":           ".7z",
+	"application/vnd.rar":                   ".rar",
+	"application/x-xz":                      ".xz",
+	"application/x-bzip2":                   ".bz2",
+	"application/x-tar":                     ".tar",
+	"application/vnd.debian.binary-package": ".deb",
+	"application/x-rpm":                     ".rpm",
+	"text/plain":                            ".txt",
+	"text/html":                             ".html",
+	"text/xml":                              ".xml",
+	"application/json":                      ".json",
+}
+
+// ExtensionForContentType returns a conventional extension (with leading dot)
+// for a content type, or "" when none is known
+func ExtensionForContentType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	return contentTypeExtensions[contentType]
+}
+
+// ContentTypeForFilename guesses a content type from a file name when the
+// content itself is not available. Returns application/octet-stream if unknown.
+func ContentTypeForFilename(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return "application/octet-stream"
+	}
+
+	for contentType, known := range contentTypeExtensions {
+		if known == ext {
+			return contentType
+		}
+	}
+	if ext == ".jpeg" {
+		return "image/jpeg"
+	}
+
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		if i := strings.Index(contentType, ";"); i != -1 {
+			contentType = strings.TrimSpace(contentType[:i])
+		}
+		return contentType
+	}
+	return "application/octet-stream"
+}
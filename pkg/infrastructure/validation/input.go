@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"path/filepath"
 	"regexp"
@@ -14,19 +15,17 @@ type Validator struct {
 	maxFileSize    int64
 	maxFilenameLen int
 	allowedExts    map[string]bool
-	cidPattern     *regexp.Regexp
+	contentPolicy  *ContentPolicy
+	nameResolver   NameResolver
 }
 
 // NewValidator creates a new input validator
 func NewValidator() *Validator {
-	// IPFS CID pattern (simplified for basic validation)
-	cidPattern := regexp.MustCompile(`^[Qm][1-9A-HJ-NP-Za-km-z]{44,58}$|^[a-z0-9]{59}$|^[A-Za-z0-9]{46}$`)
-	
 	return &Validator{
 		maxFileSize:    100 * 1024 * 1024, // 100MB default
 		maxFilenameLen: 255,
 		allowedExts:    make(map[string]bool),
-		cidPattern:     cidPattern,
+		contentPolicy:  DefaultContentPolicy(),
 	}
 }
 
@@ -43,6 +42,25 @@ func (v *Validator) SetAllowedExtensions(extensions []string) {
 	}
 }
 
+// SetContentPolicy sets the policy applied by ValidateUploadContent
+func (v *Validator) SetContentPolicy(policy *ContentPolicy) {
+	if policy == nil {
+		policy = DefaultContentPolicy()
+	}
+	v.contentPolicy = policy
+}
+
+// ContentPolicy returns the content policy in use
+func (v *Validator) ContentPolicy() *ContentPolicy {
+	return v.contentPolicy
+}
+
+// ValidateUploadContent sniffs the upload's content type from its bytes and
+// applies the content policy. The returned reader replays the full stream.
+func (v *Validator) ValidateUploadContent(filename string, size int64, r io.Reader) (string, io.Reader, error) {
+	return v.contentPolicy.CheckReader(filename, size, r)
+}
+
 // ValidationError represents a validation error
 type ValidationError struct {
 	Field   string
@@ -150,35 +168,10 @@ func (v *Validator) ValidateFileSize(size int64) error {
 	return nil
 }
 
-// ValidateCID validates an IPFS Content Identifier
+// ValidateCID validates an IPFS Content Identifier in any multibase encoding
 func (v *Validator) ValidateCID(cid string) error {
-	if cid == "" {
-		return ValidationError{
-			Field:   "cid",
-			Message: "CID cannot be empty",
-			Value:   cid,
-		}
-	}
-	
-	// Basic length check
-	if len(cid) < 10 || len(cid) > 100 {
-		return ValidationError{
-			Field:   "cid",
-			Message: "CID length invalid",
-			Value:   cid,
-		}
-	}
-	
-	// Pattern validation
-	if !v.cidPattern.MatchString(cid) {
-		return ValidationError{
-			Field:   "cid",
-			Message: "CID format invalid",
-			Value:   cid,
-		}
-	}
-	
-	return nil
+	_, err := v.NormalizeCID(cid)
+	return err
 }
 
 // ValidatePassword validates password strength with comprehensive checks
@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentPolicy decides which files may be uploaded based on their sniffed
+// content type, size and extension. Content types may use a "type/*" wildcard.
+type ContentPolicy struct {
+	// AllowedTypes lists accepted content types; empty allows every type
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+
+	// ForbiddenTypes lists rejected content types, checked before AllowedTypes
+	ForbiddenTypes []string `json:"forbidden_types,omitempty"`
+
+	// MaxSizes limits the size per content type; the most specific match wins
+	MaxSizes map[string]int64 `json:"max_sizes,omitempty"`
+
+	// DefaultMaxSize applies when no MaxSizes entry matches (0 = unlimited)
+	DefaultMaxSize int64 `json:"default_max_size,omitempty"`
+
+	// ForbiddenExtensions lists rejected file extensions, e.g. ".exe"
+	ForbiddenExtensions []string `json:"forbidden_extensions,omitempty"`
+}
+
+// DefaultContentPolicy returns a permissive policy that accepts all content
+func DefaultContentPolicy() *ContentPolicy {
+	return &ContentPolicy{
+		MaxSizes: make(map[string]int64),
+	}
+}
+
+// LoadContentPolicy reads a policy from a JSON file
+func LoadContentPolicy(path string) (*ContentPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content policy: %w", err)
+	}
+
+	policy := DefaultContentPolicy()
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse content policy: %w", err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Validate checks the policy for malformed entries
+func (p *ContentPolicy) Validate() error {
+	for _, list := range [][]string{p.AllowedTypes, p.ForbiddenTypes} {
+		for _, pattern := range list {
+			if !strings.Contains(pattern, "/") {
+				return fmt.Errorf("invalid content type pattern %q: expected type/subtype", pattern)
+			}
+		}
+	}
+	for pattern, size := range p.MaxSizes {
+		if !strings.Contains(pattern, "/") {
+			return fmt.Errorf("invalid content type pattern %q: expected type/subtype", pattern)
+		}
+		if size < 0 {
+			return fmt.Errorf("max size for %s cannot be negative", pattern)
+		}
+	}
+	if p.DefaultMaxSize < 0 {
+		return fmt.Errorf("default max size cannot be negative")
+	}
+	return nil
+}
+
+// Check validates a file against the policy. contentType should come from
+// DetectContentType rather than from the client or the file name.
+func (p *ContentPolicy) Check(filename string, size int64, contentType string) error {
+	if p == nil {
+		return nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, forbidden := range p.ForbiddenExtensions {
+		if ext != "" && ext == normalizeExtension(forbidden) {
+			return ValidationError{
+				Field:   "filename",
+				Message: fmt.Sprintf("file extension %s is not allowed", ext),
+				Value:   filename,
+			}
+		}
+	}
+
+	if matchContentTypes(p.ForbiddenTypes, contentType) {
+		return ValidationError{
+			Field:   "content_type",
+			Message: fmt.Sprintf("content type %s is not allowed", contentType),
+			Value:   contentType,
+		}
+	}
+
+	if len(p.AllowedTypes) > 0 && !matchContentTypes(p.AllowedTypes, contentType) {
+		return ValidationError{
+			Field:   "content_type",
+			Message: fmt.Sprintf("content type %s is not in the allowed list", contentType),
+			Value:   contentType,
+		}
+	}
+
+	if maxSize := p.MaxSizeFor(contentType); maxSize > 0 && size > maxSize {
+		return ValidationError{
+			Field:   "file_size",
+			Message: fmt.Sprintf("file too large for %s (max %d bytes)", contentType, maxSize),
+			Value:   size,
+		}
+	}
+
+	return nil
+}
+
+// CheckReader sniffs r and validates it against the policy. The returned
+// reader yields the full stream and should be used in place of r.
+func (p *ContentPolicy) CheckReader(filename string, size int64, r io.Reader) (string, io.Reader, error) {
+	contentType, reader, err := SniffReader(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read content for type detection: %w", err)
+	}
+
+	if err := p.Check(filename, size, contentType); err != nil {
+		return contentType, nil, err
+	}
+
+	return contentType, reader, nil
+}
+
+// CheckFile sniffs a file on disk and validates it against the policy
+func (p *ContentPolicy) CheckFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	contentType, _, err := p.CheckReader(filepath.Base(path), info.Size(), file)
+	return contentType, err
+}
+
+// MaxSizeFor returns the size limit for a content type (0 = unlimited).
+// An exact entry beats a "type/*" wildcard, which beats DefaultMaxSize.
+func (p *ContentPolicy) MaxSizeFor(contentType string) int64 {
+	if size, ok := p.MaxSizes[contentType]; ok {
+		return size
+	}
+	if i := strings.Index(contentType, "/"); i != -1 {
+		if size, ok := p.MaxSizes[contentType[:i]+"/*"]; ok {
+			return size
+		}
+	}
+	return p.DefaultMaxSize
+}
+
+// matchContentTypes reports whether contentType matches any pattern
+func matchContentTypes(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*/*" || pattern == contentType {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeExtension lowercases an extension and ensures a leading dot
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
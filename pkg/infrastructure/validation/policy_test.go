@@ -0,0 +1,111 @@
+package validation
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"elf", []byte("\x7fELF\x02\x01\x01"), "application/x-executable"},
+		{"pe", []byte("MZ\x90\x00\x03"), "application/x-msdownload"},
+		{"mp4", []byte("\x00\x00\x00\x18ftypmp42\x00\x00"), "video/mp4"},
+		{"quicktime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00"), "video/quicktime"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "image/png"},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"text", []byte("hello world\n"), "text/plain"},
+	}
+
+	for _, tt := range tests {
+		if got := DetectContentType(tt.data); got != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestSniffReaderPreservesStream(t *testing.T) {
+	data := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("x"), 2048)...)
+
+	contentType, reader, err := SniffReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("SniffReader failed: %v", err)
+	}
+	if contentType != "application/pdf" {
+		t.Errorf("Expected application/pdf, got %s", contentType)
+	}
+
+	replayed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read replayed stream: %v", err)
+	}
+	if !bytes.Equal(replayed, data) {
+		t.Error("Replayed stream does not match original data")
+	}
+}
+
+func TestContentPolicy_Check(t *testing.T) {
+	policy := &ContentPolicy{
+		AllowedTypes:        []string{"video/*", "image/png", "text/plain"},
+		ForbiddenTypes:      []string{"video/x-matroska"},
+		MaxSizes:            map[string]int64{"video/*": 1000, "video/mp4": 2000},
+		DefaultMaxSize:      100,
+		ForbiddenExtensions: []string{"exe", ".SCR"},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("Policy should be valid: %v", err)
+	}
+
+	tests := []struct {
+		filename    string
+		size        int64
+		contentType string
+		allowed     bool
+	}{
+		{"clip.mp4", 1500, "video/mp4", true},        // exact size entry beats wildcard
+		{"clip.mov", 1500, "video/quicktime", false}, // wildcard limit
+		{"clip.mkv", 10, "video/x-matroska", false},  // forbidden type
+		{"image.png", 50, "image/png", true},         // default size limit
+		{"image.png", 500, "image/png", false},       // over default size limit
+		{"notes.txt", 10, "application/pdf", false},  // sniffed type not allowed
+		{"setup.exe", 10, "text/plain", false},       // forbidden extension
+		{"SCREEN.scr", 10, "text/plain", false},      // extension match is case-insensitive
+		{"notes.txt", 10, "application/x-msdownload", false},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(tt.filename, tt.size, tt.contentType)
+		if (err == nil) != tt.allowed {
+			t.Errorf("Check(%s, %d, %s) = %v, want allowed=%v", tt.filename, tt.size, tt.contentType, err, tt.allowed)
+		}
+	}
+
+	// The default policy allows everything
+	if err := DefaultContentPolicy().Check("anything.exe", 1<<40, "application/x-msdownload"); err != nil {
+		t.Errorf("Default policy should allow all content: %v", err)
+	}
+}
+
+func TestValidator_ValidateUploadContent(t *testing.T) {
+	validator := NewValidator()
+	validator.SetContentPolicy(&ContentPolicy{ForbiddenTypes: []string{"application/x-executable"}})
+
+	// A renamed executable is caught by its magic bytes
+	if _, _, err := validator.ValidateUploadContent("holiday.jpg", 8, bytes.NewReader([]byte("\x7fELF\x02\x01\x01\x00"))); err == nil {
+		t.Error("Expected renamed executable to be rejected")
+	}
+
+	contentType, reader, err := validator.ValidateUploadContent("notes.txt", 5, bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatalf("Expected text upload to be allowed: %v", err)
+	}
+	if contentType != "text/plain" || reader == nil {
+		t.Errorf("Unexpected result: %s, %v", contentType, reader)
+	}
+}
@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the reverse proxies whose forwarding headers are
+// believed. Any client can send X-Forwarded-For, so the headers only name
+// the client when the request came from one of these.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses addresses and CIDRs such as "10.0.0.0/8"
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			proxies = append(proxies, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the address of a request's client. That is RemoteAddr,
+// unless RemoteAddr is a trusted proxy: then X-Forwarded-For is walked
+// back from the nearest hop to the first address that isn't one, or
+// X-Real-IP is used when there is no X-Forwarded-For.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !p.contains(host) {
+		return host
+	}
+
+	forwarded := parseXForwardedFor(strings.Join(r.Header.Values("X-Forwarded-For"), ","))
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if i == 0 || !p.contains(forwarded[i]) {
+			return forwarded[i]
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return host
+}
+
+// contains reports whether an address is a trusted proxy
+func (p TrustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
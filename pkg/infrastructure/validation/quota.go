@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by Reserve for clients that used up their
+// download quota
+var ErrQuotaExceeded = errors.New("download quota used up")
+
+// DownloadQuota limits the bytes each client IP may download per window.
+// Like the RateLimiter's, its counters live in a RateLimitBackend, so
+// replicas sharing a backend enforce one quota.
+type DownloadQuota struct {
+	backend RateLimitBackend
+	limit   int64
+	window  time.Duration
+	proxies TrustedProxies
+}
+
+// QuotaStatus is a client's use of its download quota in the current window
+type QuotaStatus struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// NewDownloadQuota creates a quota of limit bytes per client IP and window.
+// Clients are told apart by their address, or by the forwarding headers of
+// the trusted proxies.
+func NewDownloadQuota(backend RateLimitBackend, limit int64, window time.Duration, proxies TrustedProxies) *DownloadQuota {
+	return &DownloadQuota{
+		backend: backend,
+		limit:   limit,
+		window:  window,
+		proxies: proxies,
+	}
+}
+
+// Status returns the quota use of a request's client
+func (q *DownloadQuota) Status(r *http.Request) (QuotaStatus, error) {
+	key, resetsAt := q.key(r, time.Now())
+	used, err := q.backend.Get(key)
+	if err != nil {
+		return QuotaStatus{}, fmt.Errorf("failed to check download quota: %w", err)
+	}
+	return q.status(used, resetsAt), nil
+}
+
+func (q *DownloadQuota) status(used int64, resetsAt time.Time) QuotaStatus {
+	return QuotaStatus{
+		Limit:     q.limit,
+		Used:      used,
+		Remaining: max(q.limit-used, 0),
+		ResetsAt:  resetsAt,
+	}
+}
+
+// Charge counts n downloaded bytes against a request's client
+func (q *DownloadQuota) Charge(r *http.Request, n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	key, _ := q.key(r, time.Now())
+	if _, err := q.backend.Incr(key, n, 2*q.window); err != nil {
+		return fmt.Errorf("failed to charge download quota: %w", err)
+	}
+	return nil
+}
+
+// Reservation is quota held for a download before it is sent
+type Reservation struct {
+	quota *DownloadQuota
+	key   string
+	bytes int64
+}
+
+// Reserve counts the n bytes a download is about to send against a
+// request's client, so parallel downloads can't all pass while some quota
+// is left. The download that crosses the limit is allowed; once the quota
+// is used up, ErrQuotaExceeded is returned with the client's status and
+// nothing is reserved.
+func (q *DownloadQuota) Reserve(r *http.Request, n int64) (*Reservation, QuotaStatus, error) {
+	key, resetsAt := q.key(r, time.Now())
+	used, err := q.backend.Incr(key, n, 2*q.window)
+	if err != nil {
+		return nil, QuotaStatus{}, fmt.Errorf("failed to reserve download quota: %w", err)
+	}
+	if used-n >= q.limit {
+		if n != 0 {
+			if _, err := q.backend.Incr(key, -n, 2*q.window); err != nil {
+				return nil, QuotaStatus{}, fmt.Errorf("failed to release download quota: %w", err)
+			}
+		}
+		return nil, q.status(used-n, resetsAt), ErrQuotaExceeded
+	}
+	return &Reservation{quota: q, key: key, bytes: n}, q.status(used, resetsAt), nil
+}
+
+// Settle charges the bytes actually sent instead of the reserved ones,
+// refunding what a cut-short download didn't use. The reservation's window
+// is settled even when a new one has started.
+func (res *Reservation) Settle(sent int64) error {
+	if diff := sent - res.bytes; diff != 0 {
+		if _, err := res.quota.backend.Incr(res.key, diff, 2*res.quota.window); err != nil {
+			return fmt.Errorf("failed to settle download quota: %w", err)
+		}
+	}
+	return nil
+}
+
+// key returns the backend key of a client's counter in the window at now,
+// and the window's end. Windows are aligned to the clock, so all replicas
+// share the same counter.
+func (q *DownloadQuota) key(r *http.Request, now time.Time) (string, time.Time) {
+	start := now.Truncate(q.window)
+	return fmt.Sprintf("quota:ip:%s:%d", q.proxies.ClientIP(r), start.Unix()), start.Add(q.window)
+}
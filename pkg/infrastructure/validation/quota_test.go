@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDownloadQuota(t *testing.T) {
+	quota := NewDownloadQuota(NewMemoryRateLimitBackend(), 100, time.Hour, nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if err := quota.Charge(req, 60); err != nil {
+		t.Fatal(err)
+	}
+	status, err := quota.Status(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Used != 60 || status.Remaining != 40 || !status.ResetsAt.After(time.Now()) {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	// Overdrawing leaves nothing, not a negative remainder
+	quota.Charge(req, 60)
+	if status, _ := quota.Status(req); status.Remaining != 0 || status.Used != 120 {
+		t.Errorf("Expected an exhausted quota, got %+v", status)
+	}
+
+	// Another client has its own quota
+	other := httptest.NewRequest("GET", "/", nil)
+	other.RemoteAddr = "10.0.0.2:1234"
+	if status, _ := quota.Status(other); status.Remaining != 100 {
+		t.Errorf("Expected a fresh quota for another client, got %+v", status)
+	}
+}
+
+func TestDownloadQuotaReserve(t *testing.T) {
+	quota := NewDownloadQuota(NewMemoryRateLimitBackend(), 100, time.Hour, nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	// Parallel downloads of 80 bytes: the first crosses nothing, the second
+	// crosses the limit and is allowed, the third finds it used up
+	first, _, err := quota.Reserve(req, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, status, err := quota.Reserve(req, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Used != 160 || status.Remaining != 0 {
+		t.Errorf("Unexpected status after two reservations %+v", status)
+	}
+	if _, status, err := quota.Reserve(req, 80); !errors.Is(err, ErrQuotaExceeded) || status.Used != 160 {
+		t.Errorf("Expected the third reservation to be refused, got %+v, %v", status, err)
+	}
+
+	// A download cut short is refunded what it didn't send
+	if err := second.Settle(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Settle(80); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := quota.Status(req); status.Used != 90 || status.Remaining != 10 {
+		t.Errorf("Expected 90 bytes charged after settling, got %+v", status)
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.example"}); err == nil {
+		t.Error("Expected a host name to be refused")
+	}
+
+	for _, tc := range []struct {
+		name, remote, forwarded, realIP, want string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "", "203.0.113.5"},
+		{"spoofed header", "203.0.113.5:1234", "198.51.100.1", "198.51.100.2", "203.0.113.5"},
+		{"trusted proxy", "10.0.0.2:1234", "198.51.100.1", "", "198.51.100.1"},
+		{"client spoofing through a proxy", "10.0.0.2:1234", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of proxies", "192.168.1.1:1234", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"real IP header", "10.0.0.2:1234", "", "198.51.100.7", "198.51.100.7"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := proxies.ClientIP(req); got != tc.want {
+			t.Errorf("%s: ClientIP() = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	// Without trusted proxies the headers are never believed
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.5:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := TrustedProxies(nil).ClientIP(req); got != "203.0.113.5" {
+		t.Errorf("ClientIP() without proxies = %s", got)
+	}
+}
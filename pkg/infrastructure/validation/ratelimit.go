@@ -1,18 +1,20 @@
 package validation
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 )
 
-// RateLimiter provides rate limiting functionality
+// RateLimiter provides rate limiting functionality. Counters live in a
+// RateLimitBackend so replicas sharing a backend enforce the same limits.
 type RateLimiter struct {
-	clients  map[string]*ClientLimiter
-	mu       sync.RWMutex
+	backend  RateLimitBackend
 	cleanup  *time.Ticker
 	done     chan bool
 	config   RateLimitConfig
@@ -26,24 +28,27 @@ type RateLimitConfig struct {
 	CleanupInterval    time.Duration
 	BanDuration        time.Duration
 	MaxConcurrent      int
-}
 
-// ClientLimiter tracks rate limiting for a single client
-type ClientLimiter struct {
-	IP                string
-	RequestsThisMinute int
-	RequestsThisHour   int
-	LastRequest        time.Time
-	LastMinute         time.Time
-	LastHour           time.Time
-	BannedUntil        time.Time
-	ConcurrentRequests int
+	// KeyByToken limits requests authenticated with an API token per token
+	// rather than per client IP, so clients behind a shared NAT do not
+	// throttle each other. Requests are only counted per token once
+	// WithAuthenticatedToken marked them; any other is counted per IP.
+	KeyByToken bool
 }
 
-// NewRateLimiter creates a new rate limiter
+// concurrentTTL bounds how long a leaked concurrent slot (e.g. from a crashed
+// replica) can hold a client's concurrency counter
+const concurrentTTL = 10 * time.Minute
+
+// NewRateLimiter creates a new rate limiter with an in-memory backend
 func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return NewRateLimiterWithBackend(config, NewMemoryRateLimitBackend())
+}
+
+// NewRateLimiterWithBackend creates a rate limiter that keeps its counters in backend
+func NewRateLimiterWithBackend(config RateLimitConfig, backend RateLimitBackend) *RateLimiter {
 	rl := &RateLimiter{
-		clients: make(map[string]*ClientLimiter),
+		backend: backend,
 		done:    make(chan bool),
 		config:  config,
 	}
@@ -67,80 +72,132 @@ func DefaultRateLimitConfig() RateLimitConfig {
 	}
 }
 
-// CheckLimit checks if a request should be allowed
+// CheckLimit checks if a request should be allowed. Every allowed request
+// must be followed by ReleaseRequest.
 func (rl *RateLimiter) CheckLimit(r *http.Request) error {
-	ip := getClientIP(r)
-	
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	client, exists := rl.clients[ip]
-	if !exists {
-		client = &ClientLimiter{
-			IP:         ip,
-			LastMinute: time.Now(),
-			LastHour:   time.Now(),
-		}
-		rl.clients[ip] = client
-	}
-	
+	client := rl.clientKey(r)
 	now := time.Now()
 	
 	// Check if client is banned
-	if now.Before(client.BannedUntil) {
-		return fmt.Errorf("IP %s is temporarily banned", ip)
+	bannedUntil, err := rl.backend.Get(banKey(client))
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
 	}
-	
-	// Reset counters if needed
-	if now.Sub(client.LastMinute) >= time.Minute {
-		client.RequestsThisMinute = 0
-		client.LastMinute = now
+	if now.Unix() < bannedUntil {
+		return fmt.Errorf("client %s is temporarily banned", client)
 	}
 	
-	if now.Sub(client.LastHour) >= time.Hour {
-		client.RequestsThisHour = 0
-		client.LastHour = now
+	// Claim a concurrent slot first; counters are incremented before they are
+	// checked so that replicas sharing the backend cannot race past a limit
+	concurrent, err := rl.backend.Incr(concurrentKey(client), 1, concurrentTTL)
+	if err != nil {
+		return fmt.Errorf("failed to check rate limit: %w", err)
 	}
-	
-	// Check concurrent requests
-	if client.ConcurrentRequests >= rl.config.MaxConcurrent {
-		return fmt.Errorf("too many concurrent requests from IP %s", ip)
+	if concurrent > int64(rl.config.MaxConcurrent) {
+		rl.release(client)
+		return fmt.Errorf("too many concurrent requests from %s", client)
 	}
 	
-	// Check rate limits
-	if client.RequestsThisMinute >= rl.config.RequestsPerMinute {
+	// Fixed windows aligned to the clock, so all replicas share the same counter
+	minuteCount, err := rl.backend.Incr(fmt.Sprintf("min:%s:%d", client, now.Unix()/60), 1, 2*time.Minute)
+	if err != nil {
+		rl.release(client)
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if minuteCount > int64(rl.config.RequestsPerMinute) {
+		rl.release(client)
 		// Ban for repeated violations
-		if client.RequestsThisMinute > rl.config.RequestsPerMinute*2 {
-			client.BannedUntil = now.Add(rl.config.BanDuration)
+		if minuteCount > int64(rl.config.RequestsPerMinute*2) {
+			rl.backend.Set(banKey(client), now.Add(rl.config.BanDuration).Unix(), rl.config.BanDuration)
 		}
-		return fmt.Errorf("rate limit exceeded for IP %s (requests per minute)", ip)
+		return fmt.Errorf("rate limit exceeded for %s (requests per minute)", client)
 	}
 	
-	if client.RequestsThisHour >= rl.config.RequestsPerHour {
-		return fmt.Errorf("rate limit exceeded for IP %s (requests per hour)", ip)
+	hourCount, err := rl.backend.Incr(fmt.Sprintf("hour:%s:%d", client, now.Unix()/3600), 1, 2*time.Hour)
+	if err != nil {
+		rl.release(client)
+		return fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	if hourCount > int64(rl.config.RequestsPerHour) {
+		rl.release(client)
+		return fmt.Errorf("rate limit exceeded for %s (requests per hour)", client)
 	}
-	
-	// Allow request - increment counters
-	client.RequestsThisMinute++
-	client.RequestsThisHour++
-	client.LastRequest = now
-	client.ConcurrentRequests++
 	
 	return nil
 }
 
 // ReleaseRequest releases a concurrent request slot
 func (rl *RateLimiter) ReleaseRequest(r *http.Request) {
-	ip := getClientIP(r)
-	
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	if client, exists := rl.clients[ip]; exists {
-		if client.ConcurrentRequests > 0 {
-			client.ConcurrentRequests--
+	rl.release(rl.clientKey(r))
+}
+
+// release gives back a client's concurrent slot, never dropping below zero
+func (rl *RateLimiter) release(client string) {
+	remaining, err := rl.backend.Incr(concurrentKey(client), -1, concurrentTTL)
+	if err == nil && remaining < 0 {
+		rl.backend.Set(concurrentKey(client), 0, concurrentTTL)
+	}
+}
+
+// clientKey identifies the client a request is counted against
+func (rl *RateLimiter) clientKey(r *http.Request) string {
+	if rl.config.KeyByToken {
+		if id := authenticatedToken(r); id != "" {
+			// Hash the ID so it never appears in backend keys or error messages
+			sum := sha256.Sum256([]byte(id))
+			return "token:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return "ip:" + getClientIP(r)
+}
+
+// authenticatedTokenKey holds the ID of the token a request was
+// authenticated with
+type authenticatedTokenKey struct{}
+
+// WithAuthenticatedToken marks a request as authenticated with the API
+// token of the given ID. Only marked requests are counted per token, since
+// any client can send a token header of its choosing.
+func WithAuthenticatedToken(r *http.Request, tokenID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authenticatedTokenKey{}, tokenID))
+}
+
+// authenticatedToken returns the ID WithAuthenticatedToken marked a
+// request with, or "" if it wasn't
+func authenticatedToken(r *http.Request) string {
+	id, _ := r.Context().Value(authenticatedTokenKey{}).(string)
+	return id
+}
+
+// requestToken returns the API token from the Authorization or X-API-Key header
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+			return strings.TrimSpace(auth[len("bearer "):])
 		}
 	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// concurrentKey is the backend key holding a client's in-flight request count
+func concurrentKey(client string) string {
+	return "conc:" + client
+}
+
+// banKey is the backend key holding the Unix time a client's ban ends
+func banKey(client string) string {
+	return "ban:" + client
+}
+
+// ClientIP returns the client IP of a request, honouring proxy headers
+func ClientIP(r *http.Request) string {
+	return getClientIP(r)
+}
+
+// RequestToken returns the API token of a request from its Authorization
+// or X-API-Key header
+func RequestToken(r *http.Request) string {
+	return requestToken(r)
 }
 
 // getClientIP extracts the real client IP from the request
@@ -196,21 +253,15 @@ func (rl *RateLimiter) cleanupLoop() {
 	}
 }
 
-// cleanupOldClients removes old client entries to prevent memory leaks
+// cleanupOldClients removes expired counters from backends that do not
+// expire keys themselves
 func (rl *RateLimiter) cleanupOldClients() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	cutoff := time.Now().Add(-2 * time.Hour)
-	
-	for ip, client := range rl.clients {
-		if client.LastRequest.Before(cutoff) && client.ConcurrentRequests == 0 {
-			delete(rl.clients, ip)
-		}
+	if cleaner, ok := rl.backend.(rateLimitCleaner); ok {
+		cleaner.Cleanup(time.Now())
 	}
 }
 
-// Shutdown stops the rate limiter cleanup
+// Shutdown stops the rate limiter cleanup and closes the backend
 func (rl *RateLimiter) Shutdown() {
 	if rl.cleanup != nil {
 		rl.cleanup.Stop()
@@ -220,34 +271,50 @@ func (rl *RateLimiter) Shutdown() {
 	case rl.done <- true:
 	default:
 	}
+
+	rl.backend.Close()
 }
 
-// GetStats returns rate limiter statistics
+// GetStats returns rate limiter statistics. Client counts are only reported
+// by backends that can enumerate their keys.
 func (rl *RateLimiter) GetStats() map[string]interface{} {
-	rl.mu.RLock()
-	defer rl.mu.RUnlock()
+	stats := map[string]interface{}{
+		"backend":           rl.backend.Name(),
+		"requests_per_min":  rl.config.RequestsPerMinute,
+		"requests_per_hour": rl.config.RequestsPerHour,
+	}
 	
-	activeClients := 0
-	bannedClients := 0
-	totalConcurrent := 0
+	scanner, ok := rl.backend.(rateLimitScanner)
+	if !ok {
+		return stats
+	}
 	
-	now := time.Now()
-	for _, client := range rl.clients {
-		activeClients++
-		totalConcurrent += client.ConcurrentRequests
-		
-		if now.Before(client.BannedUntil) {
+	concurrent, err := scanner.Scan("conc:")
+	if err != nil {
+		return stats
+	}
+	bans, err := scanner.Scan("ban:")
+	if err != nil {
+		return stats
+	}
+	
+	totalConcurrent := int64(0)
+	for _, count := range concurrent {
+		totalConcurrent += count
+	}
+	
+	bannedClients := 0
+	now := time.Now().Unix()
+	for _, until := range bans {
+		if now < until {
 			bannedClients++
 		}
 	}
 	
-	return map[string]interface{}{
-		"active_clients":    activeClients,
-		"banned_clients":    bannedClients,
-		"total_concurrent":  totalConcurrent,
-		"requests_per_min":  rl.config.RequestsPerMinute,
-		"requests_per_hour": rl.config.RequestsPerHour,
-	}
+	stats["active_clients"] = len(concurrent)
+	stats["banned_clients"] = bannedClients
+	stats["total_concurrent"] = totalConcurrent
+	return stats
 }
 
 // Middleware creates an HTTP middleware for rate limiting
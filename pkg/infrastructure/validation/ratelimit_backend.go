@@ -0,0 +1,575 @@
+package validation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RateLimitBackend stores the counters behind a RateLimiter. Implementations
+// must apply Incr atomically so that several limiters can share one backend.
+type RateLimitBackend interface {
+	// Name identifies the backend in statistics
+	Name() string
+
+	// Incr adds delta to the counter at key, creating it at zero if missing or
+	// expired, resets its expiry to ttl and returns the new value
+	Incr(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Get returns the counter at key, or zero if missing or expired
+	Get(key string) (int64, error)
+
+	// Set stores value at key with the given expiry
+	Set(key string, value int64, ttl time.Duration) error
+
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// rateLimitCleaner is implemented by backends that must drop expired keys themselves
+type rateLimitCleaner interface {
+	Cleanup(now time.Time)
+}
+
+// rateLimitScanner is implemented by backends that can enumerate their keys
+type rateLimitScanner interface {
+	Scan(prefix string) (map[string]int64, error)
+}
+
+// NewRateLimitBackend creates a backend from a store URL:
+//
+//	""  or "memory"                      in-process counters (default)
+//	"redis://[:password@]host:port[/db]" Redis, shared across hosts
+//	"bbolt:///path/to/ratelimit.db"      bbolt file, kept across restarts of one process
+func NewRateLimitBackend(store string) (RateLimitBackend, error) {
+	if store == "" || store == "memory" {
+		return NewMemoryRateLimitBackend(), nil
+	}
+
+	u, err := url.Parse(store)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit store %q: %w", store, err)
+	}
+
+	switch u.Scheme {
+	case "redis":
+		password, _ := u.User.Password()
+		db := 0
+		if path := strings.Trim(u.Path, "/"); path != "" {
+			db, err = strconv.Atoi(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redis database %q", path)
+			}
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "6379")
+		}
+		return NewRedisRateLimitBackend(host, password, db), nil
+	case "bbolt", "bolt":
+		path := u.Path
+		if u.Host != "" {
+			path = u.Host + u.Path
+		}
+		if path == "" {
+			return nil, fmt.Errorf("rate limit store %q is missing a file path", store)
+		}
+		return NewBoltRateLimitBackend(path)
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store %q (use memory, redis:// or bbolt://)", store)
+	}
+}
+
+// memoryEntry is a counter with an expiry
+type memoryEntry struct {
+	value   int64
+	expires time.Time
+}
+
+// MemoryRateLimitBackend keeps counters in process memory. Limits are only
+// consistent within a single instance.
+type MemoryRateLimitBackend struct {
+	entries map[string]memoryEntry
+	mu      sync.Mutex
+}
+
+// NewMemoryRateLimitBackend creates an empty in-memory backend
+func NewMemoryRateLimitBackend() *MemoryRateLimitBackend {
+	return &MemoryRateLimitBackend{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+// Name returns "memory"
+func (m *MemoryRateLimitBackend) Name() string {
+	return "memory"
+}
+
+// Incr adds delta to the counter at key
+func (m *MemoryRateLimitBackend) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entry := m.entries[key]
+	if now.After(entry.expires) {
+		entry.value = 0
+	}
+	entry.value += delta
+	entry.expires = now.Add(ttl)
+	m.entries[key] = entry
+
+	return entry.value, nil
+}
+
+// Get returns the counter at key
+func (m *MemoryRateLimitBackend) Get(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.entries[key]
+	if !exists || time.Now().After(entry.expires) {
+		return 0, nil
+	}
+	return entry.value, nil
+}
+
+// Set stores value at key
+func (m *MemoryRateLimitBackend) Set(key string, value int64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Cleanup removes expired counters to prevent memory leaks
+func (m *MemoryRateLimitBackend) Cleanup(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// Scan returns the live counters whose keys start with prefix
+func (m *MemoryRateLimitBackend) Scan(prefix string) (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	result := make(map[string]int64)
+	for key, entry := range m.entries {
+		if strings.HasPrefix(key, prefix) && !now.After(entry.expires) {
+			result[key] = entry.value
+		}
+	}
+	return result, nil
+}
+
+// Close is a no-op for the memory backend
+func (m *MemoryRateLimitBackend) Close() error {
+	return nil
+}
+
+// rateLimitBucket is the bbolt bucket holding the counters
+var rateLimitBucket = []byte("ratelimit")
+
+// BoltRateLimitBackend keeps counters in a bbolt file, so they survive
+// restarts. bbolt locks the file exclusively, so it is held open by one
+// process; replicas that share counters use Redis.
+type BoltRateLimitBackend struct {
+	db *bolt.DB
+}
+
+// NewBoltRateLimitBackend opens the counters at path, waiting up to a
+// second for another process to let go of the file
+func NewBoltRateLimitBackend(path string) (*BoltRateLimitBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate limit store: %w", err)
+	}
+	b := &BoltRateLimitBackend{db: db}
+
+	// Create the bucket up front so configuration errors surface early
+	if err := b.update(func(bucket *bolt.Bucket) error { return nil }); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Name returns "bbolt"
+func (b *BoltRateLimitBackend) Name() string {
+	return "bbolt"
+}
+
+// update runs fn in a write transaction on the counters bucket
+func (b *BoltRateLimitBackend) update(fn func(bucket *bolt.Bucket) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(rateLimitBucket)
+		if err != nil {
+			return fmt.Errorf("failed to create rate limit bucket: %w", err)
+		}
+		return fn(bucket)
+	})
+}
+
+// view runs fn in a read transaction on the counters bucket, which the
+// constructor created
+func (b *BoltRateLimitBackend) view(fn func(bucket *bolt.Bucket) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(rateLimitBucket))
+	})
+}
+
+// encodeBoltEntry packs a counter and its expiry into a bbolt value
+func encodeBoltEntry(value int64, expires time.Time) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], uint64(value))
+	binary.BigEndian.PutUint64(buf[8:], uint64(expires.UnixNano()))
+	return buf
+}
+
+// decodeBoltEntry unpacks a bbolt value, reporting zero for missing or expired entries
+func decodeBoltEntry(data []byte, now time.Time) int64 {
+	if len(data) != 16 {
+		return 0
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(data[8:])))
+	if now.After(expires) {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(data[:8]))
+}
+
+// Incr adds delta to the counter at key
+func (b *BoltRateLimitBackend) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	var value int64
+	err := b.update(func(bucket *bolt.Bucket) error {
+		now := time.Now()
+		value = decodeBoltEntry(bucket.Get([]byte(key)), now) + delta
+		return bucket.Put([]byte(key), encodeBoltEntry(value, now.Add(ttl)))
+	})
+	return value, err
+}
+
+// Get returns the counter at key
+func (b *BoltRateLimitBackend) Get(key string) (int64, error) {
+	var value int64
+	err := b.view(func(bucket *bolt.Bucket) error {
+		value = decodeBoltEntry(bucket.Get([]byte(key)), time.Now())
+		return nil
+	})
+	return value, err
+}
+
+// Set stores value at key
+func (b *BoltRateLimitBackend) Set(key string, value int64, ttl time.Duration) error {
+	return b.update(func(bucket *bolt.Bucket) error {
+		return bucket.Put([]byte(key), encodeBoltEntry(value, time.Now().Add(ttl)))
+	})
+}
+
+// Cleanup removes expired counters from the file
+func (b *BoltRateLimitBackend) Cleanup(now time.Time) {
+	b.update(func(bucket *bolt.Bucket) error {
+		var expired [][]byte
+		bucket.ForEach(func(k, v []byte) error {
+			if len(v) != 16 || now.After(time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan returns the live counters whose keys start with prefix
+func (b *BoltRateLimitBackend) Scan(prefix string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	err := b.view(func(bucket *bolt.Bucket) error {
+		now := time.Now()
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, v = cursor.Next() {
+			if len(v) == 16 && !now.After(time.Unix(0, int64(binary.BigEndian.Uint64(v[8:])))) {
+				result[string(k)] = decodeBoltEntry(v, now)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Close releases the file
+func (b *BoltRateLimitBackend) Close() error {
+	return b.db.Close()
+}
+
+// RedisRateLimitBackend keeps counters in Redis so that every replica behind
+// a load balancer enforces the same limits. It speaks the Redis protocol
+// directly over a single lazily-dialled connection.
+type RedisRateLimitBackend struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// NewRedisRateLimitBackend creates a backend for the Redis server at addr.
+// The connection is established on first use.
+func NewRedisRateLimitBackend(addr, password string, db int) *RedisRateLimitBackend {
+	return &RedisRateLimitBackend{
+		addr:     addr,
+		password: password,
+		db:       db,
+		prefix:   "noisefs:ratelimit:",
+		timeout:  2 * time.Second,
+	}
+}
+
+// Name returns "redis"
+func (r *RedisRateLimitBackend) Name() string {
+	return "redis"
+}
+
+// Incr adds delta to the counter at key using INCRBY and PEXPIRE in one transaction
+func (r *RedisRateLimitBackend) Incr(key string, delta int64, ttl time.Duration) (int64, error) {
+	key = r.prefix + key
+	replies, err := r.do(
+		[]string{"MULTI"},
+		[]string{"INCRBY", key, strconv.FormatInt(delta, 10)},
+		[]string{"PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10)},
+		[]string{"EXEC"},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	results, ok := replies[3].([]interface{})
+	if !ok || len(results) < 1 {
+		return 0, fmt.Errorf("unexpected redis reply to EXEC: %v", replies[3])
+	}
+	value, ok := results[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected redis reply to INCRBY: %v", results[0])
+	}
+	return value, nil
+}
+
+// Get returns the counter at key
+func (r *RedisRateLimitBackend) Get(key string) (int64, error) {
+	replies, err := r.do([]string{"GET", r.prefix + key})
+	if err != nil {
+		return 0, err
+	}
+
+	switch reply := replies[0].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		value, err := strconv.ParseInt(reply, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid counter value %q: %w", reply, err)
+		}
+		return value, nil
+	default:
+		return 0, fmt.Errorf("unexpected redis reply to GET: %v", reply)
+	}
+}
+
+// Set stores value at key
+func (r *RedisRateLimitBackend) Set(key string, value int64, ttl time.Duration) error {
+	_, err := r.do([]string{"SET", r.prefix + key, strconv.FormatInt(value, 10), "PX", strconv.FormatInt(ttl.Milliseconds(), 10)})
+	return err
+}
+
+// Close closes the connection to Redis
+func (r *RedisRateLimitBackend) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	r.reader = nil
+	return err
+}
+
+// do pipelines commands and returns one reply per command. Connection
+// failures drop the connection so the next call redials.
+func (r *RedisRateLimitBackend) do(commands ...[]string) ([]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	replies, err := r.roundTrip(commands)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			r.conn.Close()
+			r.conn = nil
+			r.reader = nil
+		}
+		return nil, fmt.Errorf("redis request failed: %w", err)
+	}
+	return replies, nil
+}
+
+// connect dials Redis and authenticates. Must be called with r.mu held.
+func (r *RedisRateLimitBackend) connect() error {
+	conn, err := net.DialTimeout("tcp", r.addr, r.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis at %s: %w", r.addr, err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := r.roundTrip(setup); err != nil {
+			conn.Close()
+			r.conn = nil
+			r.reader = nil
+			return fmt.Errorf("failed to initialise redis connection: %w", err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes commands and reads their replies. Must be called with r.mu held.
+func (r *RedisRateLimitBackend) roundTrip(commands [][]string) ([]interface{}, error) {
+	r.conn.SetDeadline(time.Now().Add(r.timeout))
+
+	var buf strings.Builder
+	for _, command := range commands {
+		fmt.Fprintf(&buf, "*%d\r\n", len(command))
+		for _, arg := range command {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(r.conn, buf.String()); err != nil {
+		return nil, err
+	}
+
+	// Read every reply before reporting errors to keep the stream in sync
+	replies := make([]interface{}, len(commands))
+	var firstErr error
+	for i := range commands {
+		reply, err := readRedisReply(r.reader)
+		if err != nil {
+			var redisErr redisError
+			if !errors.As(err, &redisErr) {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// readRedisReply parses one RESP reply: strings, integers, bulk strings
+// (nil when absent) and arrays
+func readRedisReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", line)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := readRedisReply(reader)
+			if err != nil {
+				// Errors inside a transaction reply are values; keep reading
+				var redisErr redisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				item = redisErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+}
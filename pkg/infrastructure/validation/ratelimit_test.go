@@ -0,0 +1,284 @@
+package validation
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		RequestsPerMinute: 3,
+		RequestsPerHour:   100,
+		CleanupInterval:   time.Minute,
+		BanDuration:       time.Minute,
+		MaxConcurrent:     2,
+	}
+}
+
+func TestRateLimiter_PerMinuteLimit(t *testing.T) {
+	rl := NewRateLimiter(testRateLimitConfig())
+	defer rl.Shutdown()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	for i := 0; i < 3; i++ {
+		if err := rl.CheckLimit(req); err != nil {
+			t.Fatalf("Request %d should be allowed: %v", i, err)
+		}
+		rl.ReleaseRequest(req)
+	}
+	if err := rl.CheckLimit(req); err == nil {
+		t.Error("Fourth request should exceed the per-minute limit")
+	}
+
+	// Another client is unaffected
+	other := httptest.NewRequest("GET", "/", nil)
+	other.RemoteAddr = "10.0.0.2:1234"
+	if err := rl.CheckLimit(other); err != nil {
+		t.Errorf("Other client should be allowed: %v", err)
+	}
+}
+
+func TestRateLimiter_Concurrency(t *testing.T) {
+	rl := NewRateLimiter(testRateLimitConfig())
+	defer rl.Shutdown()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 2; i++ {
+		if err := rl.CheckLimit(req); err != nil {
+			t.Fatalf("Request %d should be allowed: %v", i, err)
+		}
+	}
+	if err := rl.CheckLimit(req); err == nil {
+		t.Fatal("Third concurrent request should be rejected")
+	}
+
+	rl.ReleaseRequest(req)
+	if err := rl.CheckLimit(req); err != nil {
+		t.Errorf("Request should be allowed after a slot is released: %v", err)
+	}
+
+	if stats := rl.GetStats(); stats["total_concurrent"] != int64(2) {
+		t.Errorf("Expected 2 concurrent requests, got %v", stats["total_concurrent"])
+	}
+}
+
+func TestRateLimiter_KeyByToken(t *testing.T) {
+	config := testRateLimitConfig()
+	config.KeyByToken = true
+	rl := NewRateLimiter(config)
+	defer rl.Shutdown()
+
+	// Two tokens behind the same IP get separate budgets
+	for _, token := range []string{"alpha", "beta"} {
+		req := WithAuthenticatedToken(httptest.NewRequest("GET", "/", nil), token)
+		for i := 0; i < 3; i++ {
+			if err := rl.CheckLimit(req); err != nil {
+				t.Fatalf("Token %s request %d should be allowed: %v", token, i, err)
+			}
+			rl.ReleaseRequest(req)
+		}
+		err := rl.CheckLimit(req)
+		if err == nil {
+			t.Fatalf("Token %s should be limited", token)
+		}
+		if strings.Contains(err.Error(), token) {
+			t.Errorf("Error should not reveal the token: %v", err)
+		}
+	}
+
+	// Unverified token headers share their IP's budget, so a new random
+	// token per request gets no fresh one
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.9:1234"
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer random-%d", i))
+		err := rl.CheckLimit(req)
+		if i < 3 && err != nil {
+			t.Fatalf("Request %d should be allowed: %v", i, err)
+		}
+		if i == 3 && err == nil {
+			t.Fatal("Unverified tokens should be limited per IP")
+		}
+		if err == nil {
+			rl.ReleaseRequest(req)
+		}
+	}
+}
+
+// testSharedBackend checks that two limiters on one backend share their limits
+func testSharedBackend(t *testing.T, first, second RateLimitBackend) {
+	replicaA := NewRateLimiterWithBackend(testRateLimitConfig(), first)
+	replicaB := NewRateLimiterWithBackend(testRateLimitConfig(), second)
+	defer replicaA.Shutdown()
+	defer replicaB.Shutdown()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for i, rl := range []*RateLimiter{replicaA, replicaB, replicaA} {
+		if err := rl.CheckLimit(req); err != nil {
+			t.Fatalf("Request %d should be allowed: %v", i, err)
+		}
+		rl.ReleaseRequest(req)
+	}
+	if err := replicaB.CheckLimit(req); err == nil {
+		t.Error("Limit should be enforced across replicas")
+	}
+}
+
+func TestRateLimiter_SharedMemoryBackend(t *testing.T) {
+	backend := NewMemoryRateLimitBackend()
+	testSharedBackend(t, backend, backend)
+}
+
+func TestRateLimiter_BoltBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.db")
+
+	backend, err := NewRateLimitBackend("bbolt://" + path)
+	if err != nil {
+		t.Fatalf("Failed to create bbolt backend: %v", err)
+	}
+	rl := NewRateLimiterWithBackend(testRateLimitConfig(), backend)
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 3; i++ {
+		if err := rl.CheckLimit(req); err != nil {
+			t.Fatalf("Request %d should be allowed: %v", i, err)
+		}
+		rl.ReleaseRequest(req)
+	}
+	rl.Shutdown()
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Counters survive a restart
+	reopened, err := NewBoltRateLimitBackend(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen bbolt backend: %v", err)
+	}
+	defer reopened.Close()
+	rl = NewRateLimiterWithBackend(testRateLimitConfig(), reopened)
+	defer rl.Shutdown()
+	if err := rl.CheckLimit(req); err == nil {
+		t.Error("Limit should be enforced after reopening the store")
+	}
+}
+
+func TestRateLimiter_SharedRedisBackend(t *testing.T) {
+	addr := startFakeRedis(t)
+
+	first, err := NewRateLimitBackend("redis://" + addr + "/1")
+	if err != nil {
+		t.Fatalf("Failed to create redis backend: %v", err)
+	}
+	testSharedBackend(t, first, NewRedisRateLimitBackend(addr, "", 1))
+}
+
+func TestNewRateLimitBackend_Invalid(t *testing.T) {
+	for _, store := range []string{"memcached://localhost", "redis://localhost/notadb", "bbolt://"} {
+		if _, err := NewRateLimitBackend(store); err == nil {
+			t.Errorf("Expected error for store %q", store)
+		}
+	}
+}
+
+// startFakeRedis serves the subset of the Redis protocol used by the backend
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	data := make(map[string]int64)
+
+	execute := func(args []string) string {
+		switch strings.ToUpper(args[0]) {
+		case "INCRBY":
+			delta, _ := strconv.ParseInt(args[2], 10, 64)
+			data[args[1]] += delta
+			return fmt.Sprintf(":%d\r\n", data[args[1]])
+		case "PEXPIRE":
+			return ":1\r\n"
+		case "GET":
+			value, ok := data[args[1]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			s := strconv.FormatInt(value, 10)
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+		case "SET":
+			data[args[1]], _ = strconv.ParseInt(args[2], 10, 64)
+			return "+OK\r\n"
+		case "SELECT", "AUTH":
+			return "+OK\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var queued []string
+		inMulti := false
+		for {
+			reply, err := readRedisReply(reader)
+			if err != nil {
+				return
+			}
+			items := reply.([]interface{})
+			args := make([]string, len(items))
+			for i, item := range items {
+				args[i] = item.(string)
+			}
+
+			mu.Lock()
+			var response string
+			switch strings.ToUpper(args[0]) {
+			case "MULTI":
+				inMulti = true
+				response = "+OK\r\n"
+			case "EXEC":
+				response = fmt.Sprintf("*%d\r\n", len(queued))
+				for _, result := range queued {
+					response += result
+				}
+				queued, inMulti = nil, false
+			default:
+				if inMulti {
+					queued = append(queued, execute(args))
+					response = "+QUEUED\r\n"
+				} else {
+					response = execute(args)
+				}
+			}
+			mu.Unlock()
+
+			if _, err := conn.Write([]byte(response)); err != nil {
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
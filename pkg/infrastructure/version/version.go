@@ -0,0 +1,150 @@
+// Package version describes which NoiseFS build and protocol a component
+// speaks, so the CLI, the daemon and the web UI can tell an incompatible
+// peer apart from a broken one instead of failing with parse errors.
+package version
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Protocol is the version of the local API spoken between the CLI, the
+// daemon and the web UI. It changes when a request or response changes
+// in a way older components can't handle.
+const Protocol = 1
+
+// MinProtocol is the oldest protocol this build still talks to
+const MinProtocol = 1
+
+// Info identifies a component's build and the protocols it speaks
+type Info struct {
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	MinProtocol int    `json:"min_protocol"`
+}
+
+// Current returns the Info of this build
+func Current() Info {
+	return Info{Version: Build(), Protocol: Protocol, MinProtocol: MinProtocol}
+}
+
+// Build returns the NoiseFS module version the binary was built from
+func Build() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// IncompatibleError is returned when a component speaks a protocol this
+// build can't talk to
+type IncompatibleError struct {
+	Component string // What was contacted, such as "daemon"
+	Remote    Info
+}
+
+func (e *IncompatibleError) Error() string {
+	if e.Remote.Protocol > Protocol {
+		return fmt.Sprintf("the %s (NoiseFS %s, protocol %d) is newer than this client (NoiseFS %s, protocol %d); upgrade this client",
+			e.Component, e.Remote.Version, e.Remote.Protocol, Build(), Protocol)
+	}
+	return fmt.Sprintf("the %s (NoiseFS %s, protocol %d) is older than this client (NoiseFS %s, protocol %d) supports; restart it with the current version",
+		e.Component, e.Remote.Version, e.Remote.Protocol, Build(), Protocol)
+}
+
+// Check returns an IncompatibleError when neither side supports the
+// other's protocol. A zero protocol is a component from before versions
+// were exchanged, which speaks protocol 1.
+func Check(component string, remote Info) error {
+	if remote.Protocol == 0 {
+		remote.Protocol, remote.MinProtocol = 1, 1
+	}
+	if remote.MinProtocol == 0 {
+		remote.MinProtocol = remote.Protocol
+	}
+	if remote.Protocol < MinProtocol || remote.MinProtocol > Protocol {
+		return &IncompatibleError{Component: component, Remote: remote}
+	}
+	return nil
+}
+
+// Newer reports whether a format version such as "4.0" has a higher
+// major version than the supported one, meaning it was written by a newer
+// NoiseFS rather than being malformed
+func Newer(v, supported string) bool {
+	major, ok := majorVersion(v)
+	if !ok {
+		return false
+	}
+	supportedMajor, ok := majorVersion(supported)
+	return ok && major > supportedMajor
+}
+
+func majorVersion(v string) (int, bool) {
+	major, _, _ := strings.Cut(strings.TrimPrefix(v, "v"), ".")
+	n, err := strconv.Atoi(major)
+	return n, err == nil
+}
+
+// Compare orders two release versions such as v1.2.3 and v1.3.0-beta.1,
+// returning -1, 0 or 1. A pre-release sorts before its release.
+func Compare(a, b string) (int, error) {
+	aCore, aPre, err := parseRelease(a)
+	if err != nil {
+		return 0, err
+	}
+	bCore, bPre, err := parseRelease(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range aCore {
+		if aCore[i] != bCore[i] {
+			if aCore[i] < bCore[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0, nil
+	case aPre == "":
+		return 1, nil
+	case bPre == "":
+		return -1, nil
+	case aPre < bPre:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// ValidRelease reports whether v is a release version Compare can order
+func ValidRelease(v string) bool {
+	_, _, err := parseRelease(v)
+	return err == nil
+}
+
+// parseRelease splits a release version into its numbers and pre-release
+func parseRelease(v string) ([3]int, string, error) {
+	var core [3]int
+	rest := strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	rest, pre, _ := strings.Cut(rest, "-")
+	fields := strings.Split(rest, ".")
+	if len(fields) != 3 {
+		return core, "", fmt.Errorf("invalid release version %q", v)
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return core, "", fmt.Errorf("invalid release version %q", v)
+		}
+		core[i] = n
+	}
+	return core, pre, nil
+}
@@ -0,0 +1,70 @@
+package version
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	if err := Check("daemon", Current()); err != nil {
+		t.Errorf("Expected this build to be compatible with itself, got %v", err)
+	}
+	if err := Check("daemon", Info{Version: "v0.1.0"}); err != nil {
+		t.Errorf("Expected a component without a protocol to be compatible, got %v", err)
+	}
+
+	err := Check("daemon", Info{Version: "v9.0.0", Protocol: Protocol + 1, MinProtocol: Protocol + 1})
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("Expected an IncompatibleError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "newer than this client") {
+		t.Errorf("Expected the error to say the daemon is newer, got %q", err)
+	}
+
+	// A newer component that still speaks this protocol is compatible
+	if err := Check("web UI", Info{Protocol: Protocol + 1, MinProtocol: Protocol}); err != nil {
+		t.Errorf("Expected a backwards compatible component to be accepted, got %v", err)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	for _, tc := range []struct {
+		v, supported string
+		want         bool
+	}{
+		{"4.0", "4.0", false},
+		{"4.1", "4.0", false},
+		{"5.0", "4.0", true},
+		{"3.0", "4.0", false},
+		{"garbage", "4.0", false},
+		{"2", "1.0", true},
+	} {
+		if got := Newer(tc.v, tc.supported); got != tc.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tc.v, tc.supported, got, tc.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "v1.10.0", -1},
+		{"1.3.0", "v1.2.9", 1},
+		{"v1.3.0-beta.1", "v1.3.0", -1},
+		{"v1.3.0-beta.2", "v1.3.0-beta.1", 1},
+		{"v1.3.0+linux", "v1.3.0", 0},
+	} {
+		got, err := Compare(tc.a, tc.b)
+		if err != nil || got != tc.want {
+			t.Errorf("Compare(%q, %q) = %d, %v; want %d", tc.a, tc.b, got, err, tc.want)
+		}
+	}
+	if _, err := Compare("dev", "v1.0.0"); err == nil {
+		t.Error("Expected a development build to have no comparable version")
+	}
+}
@@ -0,0 +1,418 @@
+// Package webhooks notifies external systems of NoiseFS events, such as
+// completed uploads and received announcements, with signed HTTP POSTs so
+// bots and indexers can integrate without polling.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// Event types
+const (
+	EventUploadCompleted      = "upload.completed"
+	EventAnnouncementReceived = "announcement.received"
+	EventSyncCompleted        = "sync.completed"
+	EventSearchMatched        = "search.matched"
+	EventPinDegraded          = "pin.degraded"
+)
+
+// Request headers
+const (
+	HeaderEvent     = "X-NoiseFS-Event"
+	HeaderDelivery  = "X-NoiseFS-Delivery"
+	HeaderSignature = "X-NoiseFS-Signature" // "sha256=" and the hex HMAC of the body
+)
+
+const (
+	// queueSize bounds deliveries waiting to be sent; more are dropped
+	queueSize = 256
+
+	// maxAttempts is how often a delivery is tried before it is dropped
+	maxAttempts = 3
+
+	// requestTimeout bounds each attempt
+	requestTimeout = 10 * time.Second
+)
+
+// Event is the JSON body of a webhook request
+type Event struct {
+	ID   string      `json:"id"` // Also sent as X-NoiseFS-Delivery
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Upload is the data of an upload.completed event
+type Upload struct {
+	DescriptorCID string `json:"descriptor_cid"`
+	Filename      string `json:"filename"`
+	Size          int64  `json:"size"`
+	Source        string `json:"source"` // Where the upload was made: cli, webui
+}
+
+// Announcement is the data of an announcement.received event
+type Announcement struct {
+	DescriptorCID string `json:"descriptor_cid"`
+	TopicHash     string `json:"topic_hash"`
+	Topic         string `json:"topic,omitempty"` // Set when the endpoint filters on it
+	Category      string `json:"category"`
+	SizeClass     string `json:"size_class"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+// Sync is the data of a sync.completed event, sent when a sync session
+// has no more operations queued
+type Sync struct {
+	SyncID              string  `json:"sync_id"`
+	LocalPath           string  `json:"local_path"`
+	RemotePath          string  `json:"remote_path"`
+	CompletedOperations int     `json:"completed_operations"`
+	FailedOperations    int     `json:"failed_operations"`
+	BytesTransferred    int64   `json:"bytes_transferred"`
+	DurationSeconds     float64 `json:"duration_seconds"`
+}
+
+// SearchMatch is the data of a search.matched event, sent when an incoming
+// announcement matches a saved search
+type SearchMatch struct {
+	SearchID      string   `json:"search_id"`
+	SearchName    string   `json:"search_name"`
+	DescriptorCID string   `json:"descriptor_cid"`
+	TopicHash     string   `json:"topic_hash"`
+	Category      string   `json:"category"`
+	SizeClass     string   `json:"size_class"`
+	Timestamp     int64    `json:"timestamp"`
+	Score         float64  `json:"score"`
+	Reasons       []string `json:"reasons,omitempty"` // Why the announcement scored as it did
+}
+
+// PinAvailability is the data of a pin.degraded event, sent when fewer
+// copies of a pinned descriptor's blocks are available than the configured
+// threshold
+type PinAvailability struct {
+	DescriptorCID string   `json:"descriptor_cid"`
+	Name          string   `json:"name,omitempty"`
+	Backends      []string `json:"backends"`
+	Availability  float64  `json:"availability"` // Fraction of sampled block copies found
+	Threshold     float64  `json:"threshold"`
+	Sampled       int      `json:"sampled"`           // Blocks checked
+	Missing       []string `json:"missing,omitempty"` // Sampled blocks no backend holds
+}
+
+// delivery is an event on its way to one endpoint
+type delivery struct {
+	endpoint config.WebhookConfig
+	event    Event
+	body     []byte
+}
+
+// Dispatcher sends events to the endpoints subscribed to them, in the
+// background and in order. Failed requests are retried; events that
+// can't be queued or delivered are logged and dropped.
+type Dispatcher struct {
+	endpoints []config.WebhookConfig
+	client    *http.Client
+	logger    *logging.Logger
+
+	// retryDelay is the wait before the first retry, doubling after
+	retryDelay time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan delivery
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New starts a dispatcher for the configured endpoints. It returns nil if
+// there are none; a nil dispatcher ignores events.
+func New(endpoints []config.WebhookConfig) *Dispatcher {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		endpoints:  append([]config.WebhookConfig(nil), endpoints...),
+		client:     &http.Client{Timeout: requestTimeout},
+		logger:     logging.GetGlobalLogger().WithComponent("webhooks"),
+		retryDelay: time.Second,
+		queue:      make(chan delivery, queueSize),
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Close delivers the queued events, waiting at most timeout, and stops the
+// dispatcher. Short-lived commands call it so their events aren't lost.
+func (d *Dispatcher) Close(timeout time.Duration) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+		d.cancel()
+		<-d.done
+	}
+}
+
+// UploadCompleted sends an upload.completed event
+func (d *Dispatcher) UploadCompleted(upload Upload) {
+	d.send(EventUploadCompleted, upload, nil)
+}
+
+// AnnouncementReceived sends an announcement.received event to the
+// endpoints whose filters match the announcement
+func (d *Dispatcher) AnnouncementReceived(ann *announce.Announcement) {
+	d.send(EventAnnouncementReceived, nil, func(endpoint config.WebhookConfig) (interface{}, bool) {
+		topic, ok := matchAnnouncement(endpoint, ann)
+		if !ok {
+			return nil, false
+		}
+		return Announcement{
+			DescriptorCID: ann.Descriptor,
+			TopicHash:     ann.TopicHash,
+			Topic:         topic,
+			Category:      ann.Category,
+			SizeClass:     ann.SizeClass,
+			Timestamp:     ann.Timestamp,
+		}, true
+	})
+}
+
+// SyncCompleted sends a sync.completed event
+func (d *Dispatcher) SyncCompleted(sync Sync) {
+	d.send(EventSyncCompleted, sync, nil)
+}
+
+// SearchMatched sends a search.matched event. The saved search is the
+// filter, so endpoints' announcement filters don't apply.
+func (d *Dispatcher) SearchMatched(searchID, searchName string, result *announce.SearchResult) {
+	ann := result.Announcement
+	match := SearchMatch{
+		SearchID:      searchID,
+		SearchName:    searchName,
+		DescriptorCID: ann.Descriptor,
+		TopicHash:     ann.TopicHash,
+		Category:      ann.Category,
+		SizeClass:     ann.SizeClass,
+		Timestamp:     ann.Timestamp,
+		Score:         result.Score,
+	}
+	if result.Explanation != nil {
+		match.Reasons = result.Explanation.Reasons
+	}
+	d.send(EventSearchMatched, match, nil)
+}
+
+// PinDegraded sends a pin.degraded event
+func (d *Dispatcher) PinDegraded(pin PinAvailability) {
+	d.send(EventPinDegraded, pin, nil)
+}
+
+// send queues an event for each endpoint subscribed to its type. dataFor,
+// if set, filters endpoints and returns the data sent to each.
+func (d *Dispatcher) send(eventType string, data interface{}, dataFor func(config.WebhookConfig) (interface{}, bool)) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, endpoint := range d.endpoints {
+		if !subscribed(endpoint, eventType) {
+			continue
+		}
+		eventData := data
+		if dataFor != nil {
+			var ok bool
+			if eventData, ok = dataFor(endpoint); !ok {
+				continue
+			}
+		}
+
+		event := Event{ID: newDeliveryID(), Type: eventType, Time: now, Data: eventData}
+		body, err := json.Marshal(event)
+		if err != nil {
+			d.logger.Warn("Failed to encode webhook event", map[string]interface{}{
+				"event": eventType,
+				"error": err.Error(),
+			})
+			continue
+		}
+		select {
+		case d.queue <- delivery{endpoint: endpoint, event: event, body: body}:
+		default:
+			d.logger.Warn("Webhook queue full, dropping event", map[string]interface{}{
+				"event": eventType,
+				"host":  endpointHost(endpoint.URL),
+			})
+		}
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for delivery := range d.queue {
+		if err := d.deliver(delivery); err != nil {
+			d.logger.Warn("Webhook delivery failed", map[string]interface{}{
+				"event": delivery.event.Type,
+				"host":  endpointHost(delivery.endpoint.URL),
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// deliver posts an event, retrying network errors and 5xx and 429
+// responses with a growing delay
+func (d *Dispatcher) deliver(delivery delivery) error {
+	delay := d.retryDelay
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var retry bool
+		if retry, err = d.post(delivery); err == nil || !retry {
+			return err
+		}
+		if attempt < maxAttempts {
+			select {
+			case <-time.After(delay):
+			case <-d.ctx.Done():
+				return d.ctx.Err()
+			}
+			delay *= 2
+		}
+	}
+	return err
+}
+
+// post makes one attempt at a delivery, reporting whether a failure is
+// worth retrying
+func (d *Dispatcher) post(delivery delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, delivery.endpoint.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "NoiseFS-Webhooks")
+	req.Header.Set(HeaderEvent, delivery.event.Type)
+	req.Header.Set(HeaderDelivery, delivery.event.ID)
+	if delivery.endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(delivery.endpoint.Secret, delivery.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return d.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("endpoint answered %s", resp.Status)
+}
+
+// Sign returns the X-NoiseFS-Signature header for a request body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body, for
+// receivers written in Go
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func subscribed(endpoint config.WebhookConfig, eventType string) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+	for _, event := range endpoint.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnnouncement applies an endpoint's announcement filters, returning
+// the topic that matched if the endpoint filters on topics
+func matchAnnouncement(endpoint config.WebhookConfig, ann *announce.Announcement) (string, bool) {
+	var topic string
+	if len(endpoint.Topics) > 0 {
+		for _, candidate := range endpoint.Topics {
+			if announce.HashTopic(candidate) == ann.TopicHash {
+				topic = candidate
+				break
+			}
+		}
+		if topic == "" {
+			return "", false
+		}
+	}
+	if len(endpoint.Categories) > 0 {
+		found := false
+		for _, category := range endpoint.Categories {
+			if category == ann.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	if len(endpoint.Tags) > 0 && !announce.HasAllTags(ann.TagBloom, endpoint.Tags) {
+		return "", false
+	}
+	return topic, true
+}
+
+// endpointHost names an endpoint in logs. URLs such as Discord's carry a
+// token, so only the host is logged.
+func endpointHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	return parsed.Host
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
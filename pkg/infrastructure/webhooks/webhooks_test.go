@@ -0,0 +1,206 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+)
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the requests it is sent, answering with the given
+// status codes in turn and 200 once they run out
+type receiver struct {
+	mu       sync.Mutex
+	requests []received
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, received{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+func TestDispatcher_UploadCompleted(t *testing.T) {
+	r, server := newReceiver(t)
+	d := New([]config.WebhookConfig{{URL: server.URL, Secret: "s3cret"}})
+	d.UploadCompleted(Upload{DescriptorCID: "QmDescriptor", Filename: "song.mp3", Size: 1024, Source: "cli"})
+	d.Close(5 * time.Second)
+
+	requests := r.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	req := requests[0]
+	if req.header.Get(HeaderEvent) != EventUploadCompleted {
+		t.Errorf("Unexpected event header %q", req.header.Get(HeaderEvent))
+	}
+	if !Verify("s3cret", req.body, req.header.Get(HeaderSignature)) {
+		t.Error("Signature did not verify")
+	}
+	if Verify("other", req.body, req.header.Get(HeaderSignature)) {
+		t.Error("Signature verified with the wrong secret")
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data Upload `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("Invalid body: %v", err)
+	}
+	if event.Type != EventUploadCompleted || event.ID != req.header.Get(HeaderDelivery) {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if event.Data.DescriptorCID != "QmDescriptor" || event.Data.Size != 1024 {
+		t.Errorf("Unexpected data %+v", event.Data)
+	}
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	uploads, uploadServer := newReceiver(t)
+	music, musicServer := newReceiver(t)
+	d := New([]config.WebhookConfig{
+		{URL: uploadServer.URL, Events: []string{EventUploadCompleted}},
+		{URL: musicServer.URL, Events: []string{EventAnnouncementReceived}, Topics: []string{"music"}, Categories: []string{"audio"}},
+	})
+
+	d.UploadCompleted(Upload{DescriptorCID: "QmUpload"})
+	d.SyncCompleted(Sync{SyncID: "sync-1"})
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmSong", TopicHash: announce.HashTopic("music"), Category: "audio"})
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmBook", TopicHash: announce.HashTopic("books"), Category: "audio"})
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmVideo", TopicHash: announce.HashTopic("music"), Category: "video"})
+	d.Close(5 * time.Second)
+
+	if got := uploads.received(); len(got) != 1 || got[0].header.Get(HeaderEvent) != EventUploadCompleted {
+		t.Errorf("Upload endpoint got %d requests, want only the upload", len(got))
+	}
+	got := music.received()
+	if len(got) != 1 {
+		t.Fatalf("Announcement endpoint got %d requests, want 1", len(got))
+	}
+	var event struct {
+		Data Announcement `json:"data"`
+	}
+	json.Unmarshal(got[0].body, &event)
+	if event.Data.DescriptorCID != "QmSong" || event.Data.Topic != "music" {
+		t.Errorf("Unexpected announcement %+v", event.Data)
+	}
+}
+
+func TestDispatcher_TagFilter(t *testing.T) {
+	r, server := newReceiver(t)
+	d := New([]config.WebhookConfig{{URL: server.URL, Tags: []string{"jazz"}}})
+
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmJazz", TagBloom: announce.CreateTagBloom([]string{"jazz", "live"}).Encode()})
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmUntagged"})
+	d.Close(5 * time.Second)
+
+	if got := r.received(); len(got) != 1 {
+		t.Errorf("Expected only the tagged announcement, got %d requests", len(got))
+	}
+}
+
+func TestDispatcher_SearchMatched(t *testing.T) {
+	r, server := newReceiver(t)
+	// Announcement filters don't hold back saved search matches
+	d := New([]config.WebhookConfig{{URL: server.URL, Events: []string{EventSearchMatched}, Topics: []string{"books"}}})
+
+	d.AnnouncementReceived(&announce.Announcement{Descriptor: "QmSong", TopicHash: announce.HashTopic("music")})
+	d.SearchMatched("abc", "jazz", &announce.SearchResult{
+		Announcement: &announce.Announcement{Descriptor: "QmSong"},
+		Score:        2.5,
+		Explanation:  &announce.ScoreExplanation{Reasons: []string{"matched tag jazz"}},
+	})
+	d.Close(5 * time.Second)
+
+	got := r.received()
+	if len(got) != 1 || got[0].header.Get(HeaderEvent) != EventSearchMatched {
+		t.Fatalf("Expected only the search match, got %d requests", len(got))
+	}
+	var event struct {
+		Data SearchMatch `json:"data"`
+	}
+	json.Unmarshal(got[0].body, &event)
+	if event.Data.SearchName != "jazz" || event.Data.DescriptorCID != "QmSong" || len(event.Data.Reasons) != 1 {
+		t.Errorf("Unexpected match %+v", event.Data)
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	r, server := newReceiver(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+	d := New([]config.WebhookConfig{{URL: server.URL}})
+	d.retryDelay = time.Millisecond
+	d.UploadCompleted(Upload{DescriptorCID: "QmRetry"})
+	d.Close(5 * time.Second)
+
+	requests := r.received()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(requests))
+	}
+	if requests[0].header.Get(HeaderDelivery) != requests[2].header.Get(HeaderDelivery) {
+		t.Error("Retries should keep the delivery ID")
+	}
+
+	// Client errors aren't retried
+	r, server = newReceiver(t, http.StatusBadRequest)
+	d = New([]config.WebhookConfig{{URL: server.URL}})
+	d.retryDelay = time.Millisecond
+	d.UploadCompleted(Upload{DescriptorCID: "QmRejected"})
+	d.Close(5 * time.Second)
+	if got := r.received(); len(got) != 1 {
+		t.Errorf("Expected 1 attempt for a 400, got %d", len(got))
+	}
+}
+
+func TestDispatcher_Nil(t *testing.T) {
+	d := New(nil)
+	if d != nil {
+		t.Fatal("Expected no dispatcher without endpoints")
+	}
+	d.UploadCompleted(Upload{})
+	d.AnnouncementReceived(&announce.Announcement{})
+	d.SyncCompleted(Sync{})
+	d.Close(time.Second)
+}
+
+func TestDispatcher_SendAfterClose(t *testing.T) {
+	r, server := newReceiver(t)
+	d := New([]config.WebhookConfig{{URL: server.URL}})
+	d.Close(5 * time.Second)
+	d.Close(5 * time.Second)
+	d.UploadCompleted(Upload{DescriptorCID: "QmLate"})
+	if got := r.received(); len(got) != 0 {
+		t.Errorf("Expected no requests after Close, got %d", len(got))
+	}
+}
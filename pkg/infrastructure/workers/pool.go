@@ -7,6 +7,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // Task represents a unit of work that can be executed by a worker
@@ -26,9 +28,6 @@ type Result struct {
 	Duration time.Duration
 }
 
-// ProgressReporter is called when a task completes
-type ProgressReporter func(completed, total int64)
-
 // Config holds configuration for the worker pool
 type Config struct {
 	// WorkerCount is the number of workers to spawn
@@ -42,8 +41,12 @@ type Config struct {
 	// ShutdownTimeout is how long to wait for graceful shutdown
 	ShutdownTimeout time.Duration
 	
-	// ProgressReporter is called when tasks complete (optional)
-	ProgressReporter ProgressReporter
+	// ProgressReporter receives the completed and submitted task counts (optional)
+	ProgressReporter util.ProgressReporter
+
+	// Name labels the pool in RunningPools, and so in metrics; unnamed
+	// pools aren't listed (optional)
+	Name string
 }
 
 // Pool manages a pool of workers for parallel task execution
@@ -54,6 +57,7 @@ type Pool struct {
 	ctx      context.Context
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
+	workerWg sync.WaitGroup
 	
 	// Statistics
 	submitted   int64
@@ -105,6 +109,7 @@ func (p *Pool) Start() error {
 	// Start workers
 	for i := 0; i < p.config.WorkerCount; i++ {
 		p.wg.Add(1)
+		p.workerWg.Add(1)
 		go p.worker(i)
 	}
 	
@@ -113,6 +118,7 @@ func (p *Pool) Start() error {
 	go p.resultProcessor()
 	
 	p.started = true
+	registerPool(p)
 	return nil
 }
 
@@ -216,6 +222,7 @@ func (p *Pool) Shutdown() error {
 	}
 	
 	p.shutdown = true
+	unregisterPool(p)
 	
 	// Close task channel to signal workers to stop
 	close(p.tasks)
@@ -223,7 +230,7 @@ func (p *Pool) Shutdown() error {
 	// Wait for workers to finish with timeout
 	done := make(chan struct{})
 	go func() {
-		p.wg.Wait()
+		p.workerWg.Wait()
 		close(done)
 	}()
 	
@@ -231,17 +238,25 @@ func (p *Pool) Shutdown() error {
 	case <-done:
 		// Graceful shutdown completed
 	case <-time.After(p.config.ShutdownTimeout):
-		// Force shutdown by cancelling context
-		p.cancel()
-		p.wg.Wait()
+		// Force shutdown of remaining workers below
 	}
 	
+	// Cancel context to stop the result processor and any remaining workers
+	p.cancel()
+	p.wg.Wait()
+	
 	// Close results channel
 	close(p.results)
 	
 	return nil
 }
 
+// Results returns the channel on which task results are delivered. Callers
+// that submit tasks with Submit or SubmitBlocking must drain this channel.
+func (p *Pool) Results() <-chan Result {
+	return p.results
+}
+
 // Stats returns current pool statistics
 func (p *Pool) Stats() PoolStats {
 	return PoolStats{
@@ -265,6 +280,7 @@ type PoolStats struct {
 // worker is the main worker goroutine
 func (p *Pool) worker(id int) {
 	defer p.wg.Done()
+	defer p.workerWg.Done()
 	
 	for task := range p.tasks {
 		start := time.Now()
@@ -311,7 +327,7 @@ func (p *Pool) resultProcessor() {
 			if p.config.ProgressReporter != nil {
 				completed := atomic.LoadInt64(&p.completed)
 				total := atomic.LoadInt64(&p.submitted)
-				p.config.ProgressReporter(completed, total)
+				util.ReportProgress(p.config.ProgressReporter, "Running tasks", completed, total, 0)
 			}
 		case <-p.ctx.Done():
 			return
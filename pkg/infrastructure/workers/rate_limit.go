@@ -0,0 +1,176 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter is a token bucket that limits throughput in bytes per second.
+// A nil *BandwidthLimiter imposes no limit.
+type BandwidthLimiter struct {
+	mu         sync.Mutex
+	rate       float64 // bytes per second
+	burst      float64 // maximum accumulated tokens
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewBandwidthLimiter creates a limiter allowing bytesPerSecond of throughput.
+// Returns nil (unlimited) when bytesPerSecond <= 0.
+func NewBandwidthLimiter(bytesPerSecond int64) *BandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+
+	return &BandwidthLimiter{
+		rate:       float64(bytesPerSecond),
+		burst:      float64(bytesPerSecond),
+		tokens:     float64(bytesPerSecond),
+		lastRefill: time.Now(),
+	}
+}
+
+// WaitN blocks until n bytes may be transferred or the context is cancelled.
+// Requests larger than one second of bandwidth are allowed to borrow against
+// future tokens so that large blocks are delayed rather than rejected.
+func (bl *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	if bl == nil || n <= 0 {
+		return nil
+	}
+
+	bl.mu.Lock()
+	bl.refill()
+	bl.tokens -= float64(n)
+	var wait time.Duration
+	if bl.tokens < 0 {
+		wait = time.Duration(-bl.tokens / bl.rate * float64(time.Second))
+	}
+	bl.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the unused reservation
+		bl.mu.Lock()
+		bl.tokens += float64(n)
+		bl.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// SetRate changes the limit; values <= 0 are ignored
+func (bl *BandwidthLimiter) SetRate(bytesPerSecond int64) {
+	if bl == nil || bytesPerSecond <= 0 {
+		return
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	bl.refill()
+	bl.rate = float64(bytesPerSecond)
+	bl.burst = float64(bytesPerSecond)
+	if bl.tokens > bl.burst {
+		bl.tokens = bl.burst
+	}
+}
+
+// Rate returns the configured limit in bytes per second (0 when unlimited)
+func (bl *BandwidthLimiter) Rate() int64 {
+	if bl == nil {
+		return 0
+	}
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return int64(bl.rate)
+}
+
+// refill adds tokens accumulated since the last refill. Caller must hold mu.
+func (bl *BandwidthLimiter) refill() {
+	now := time.Now()
+	elapsed := now.Sub(bl.lastRefill).Seconds()
+	bl.lastRefill = now
+
+	bl.tokens += elapsed * bl.rate
+	if bl.tokens > bl.burst {
+		bl.tokens = bl.burst
+	}
+}
+
+// TransferLimiter bounds block transfers by bandwidth and by the number of
+// blocks in flight. It satisfies storage.TransferLimiter so it can be
+// installed on a storage manager to throttle every block it moves.
+type TransferLimiter struct {
+	bandwidth *BandwidthLimiter
+	slots     chan struct{}
+}
+
+// NewTransferLimiter creates a limiter for the given bandwidth (bytes per
+// second) and maximum number of concurrent block transfers. Zero values
+// disable the corresponding limit.
+func NewTransferLimiter(maxBandwidth int64, maxParallelBlocks int) *TransferLimiter {
+	tl := &TransferLimiter{
+		bandwidth: NewBandwidthLimiter(maxBandwidth),
+	}
+	if maxParallelBlocks > 0 {
+		tl.slots = make(chan struct{}, maxParallelBlocks)
+	}
+	return tl
+}
+
+// Acquire reserves a transfer slot, blocking until one is available
+func (tl *TransferLimiter) Acquire(ctx context.Context) error {
+	if tl.slots == nil {
+		return nil
+	}
+
+	select {
+	case tl.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot obtained with Acquire
+func (tl *TransferLimiter) Release() {
+	if tl.slots == nil {
+		return
+	}
+
+	select {
+	case <-tl.slots:
+	default:
+	}
+}
+
+// WaitN blocks until n bytes may be transferred
+func (tl *TransferLimiter) WaitN(ctx context.Context, n int) error {
+	return tl.bandwidth.WaitN(ctx, n)
+}
+
+// InFlight returns the number of block transfers holding a slot
+func (tl *TransferLimiter) InFlight() int {
+	return len(tl.slots)
+}
+
+// MaxParallel returns the maximum number of concurrent block transfers, 0
+// when unlimited
+func (tl *TransferLimiter) MaxParallel() int {
+	return cap(tl.slots)
+}
+
+// Bandwidth returns the underlying bandwidth limiter (nil when unlimited)
+func (tl *TransferLimiter) Bandwidth() *BandwidthLimiter {
+	return tl.bandwidth
+}
@@ -0,0 +1,99 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// Compile-time check that TransferLimiter can be installed on a storage manager
+var _ storage.TransferLimiter = (*TransferLimiter)(nil)
+
+func TestBandwidthLimiter_Unlimited(t *testing.T) {
+	limiter := NewBandwidthLimiter(0)
+	if limiter != nil {
+		t.Fatal("Expected nil limiter for zero rate")
+	}
+
+	start := time.Now()
+	if err := limiter.WaitN(context.Background(), 1<<30); err != nil {
+		t.Fatalf("WaitN on nil limiter failed: %v", err)
+	}
+	if time.Since(start) > 10*time.Millisecond {
+		t.Error("Nil limiter should not block")
+	}
+}
+
+func TestBandwidthLimiter_Throttles(t *testing.T) {
+	// 100KB/s with a full initial bucket: the first 100KB is free,
+	// the next 20KB must wait roughly 200ms.
+	limiter := NewBandwidthLimiter(100 * 1024)
+	ctx := context.Background()
+
+	if err := limiter.WaitN(ctx, 100*1024); err != nil {
+		t.Fatalf("WaitN failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := limiter.WaitN(ctx, 20*1024); err != nil {
+		t.Fatalf("WaitN failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("Expected throttling delay of ~200ms, got %v", elapsed)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Throttling delay too long: %v", elapsed)
+	}
+}
+
+func TestBandwidthLimiter_ContextCancel(t *testing.T) {
+	limiter := NewBandwidthLimiter(1024)
+	limiter.WaitN(context.Background(), 1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.WaitN(ctx, 10*1024); err == nil {
+		t.Error("Expected context error while waiting for bandwidth")
+	}
+}
+
+func TestTransferLimiter_MaxParallel(t *testing.T) {
+	limiter := NewTransferLimiter(0, 2)
+	ctx := context.Background()
+
+	var inFlight, maxInFlight int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limiter.Acquire(ctx); err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			defer limiter.Release()
+
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				observed := atomic.LoadInt32(&maxInFlight)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("Expected at most 2 concurrent transfers, observed %d", maxInFlight)
+	}
+}
@@ -0,0 +1,44 @@
+package workers
+
+import "sync"
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[*Pool]struct{})
+)
+
+// registerPool lists a started pool in RunningPools if it has a name
+func registerPool(p *Pool) {
+	if p.config.Name == "" {
+		return
+	}
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	pools[p] = struct{}{}
+}
+
+func unregisterPool(p *Pool) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	delete(pools, p)
+}
+
+// RunningPools returns the statistics of the named pools that are started
+// and not shut down, summed by name
+func RunningPools() map[string]PoolStats {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	running := make(map[string]PoolStats)
+	for p := range pools {
+		stats := p.Stats()
+		total := running[p.config.Name]
+		total.WorkerCount += stats.WorkerCount
+		total.Submitted += stats.Submitted
+		total.Completed += stats.Completed
+		total.Failed += stats.Failed
+		total.Pending += stats.Pending
+		running[p.config.Name] = total
+	}
+	return running
+}
@@ -0,0 +1,33 @@
+package workers
+
+import "testing"
+
+func TestRunningPoolsListsNamedPools(t *testing.T) {
+	named := NewPool(Config{WorkerCount: 2, Name: "registry-test"})
+	unnamed := NewPool(Config{WorkerCount: 1})
+	if err := named.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := unnamed.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer unnamed.Shutdown()
+
+	stats, ok := RunningPools()["registry-test"]
+	if !ok {
+		t.Fatal("Started named pool not listed")
+	}
+	if stats.WorkerCount != 2 {
+		t.Errorf("WorkerCount = %d, want 2", stats.WorkerCount)
+	}
+	if _, ok := RunningPools()[""]; ok {
+		t.Error("Unnamed pool listed")
+	}
+
+	if err := named.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := RunningPools()["registry-test"]; ok {
+		t.Error("Pool still listed after shutdown")
+	}
+}
@@ -48,10 +48,7 @@ func (s *StorageSubsystem) GetAdaptiveCache() *cache.AdaptiveCache {
 // initializeStorage sets up the storage manager
 func (s *StorageSubsystem) initializeStorage(cfg *config.Config) error {
 	// Create storage manager with IPFS backend
-	storageConfig := storage.DefaultConfig()
-	if ipfsBackend, exists := storageConfig.Backends["ipfs"]; exists {
-		ipfsBackend.Connection.Endpoint = cfg.IPFS.APIEndpoint
-	}
+	storageConfig := cfg.StorageConfig()
 
 	manager, err := storage.NewManager(storageConfig)
 	if err != nil {
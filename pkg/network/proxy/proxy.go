@@ -0,0 +1,72 @@
+// Package proxy routes NoiseFS's outbound HTTP connections through a
+// SOCKS5 or HTTP CONNECT proxy, such as a local Tor client.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parse validates a proxy URL. Supported schemes are socks5, socks5h
+// (host names resolved by the proxy, which Tor needs to avoid DNS leaks),
+// http and https (HTTP CONNECT).
+func Parse(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q in %q; use socks5h://, socks5:// or http://", u.Scheme, raw)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("proxy URL %q needs a host and port", raw)
+	}
+	return u, nil
+}
+
+// Func returns an http.Transport Proxy function sending requests through
+// the proxy at raw. Requests to loopback addresses go direct, since a local
+// IPFS daemon can't be reached through Tor. An empty raw uses the
+// environment's HTTP_PROXY settings, as Go does by default.
+func Func(raw string) (func(*http.Request) (*url.URL, error), error) {
+	if strings.TrimSpace(raw) == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if IsLoopback(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// IsLoopback reports whether host names this machine
+func IsLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// NewHTTPClient returns an HTTP client that connects through the proxy at
+// raw, or directly (subject to HTTP_PROXY) when raw is empty
+func NewHTTPClient(raw string, timeout time.Duration) (*http.Client, error) {
+	proxyFunc, err := Func(raw)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
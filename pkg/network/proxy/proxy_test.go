@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{"socks5h://127.0.0.1:9050", "socks5://tor:9050", "http://proxy.example.com:3128", "https://proxy:443"}
+	for _, raw := range valid {
+		if _, err := Parse(raw); err != nil {
+			t.Errorf("Parse(%q) failed: %v", raw, err)
+		}
+	}
+	invalid := []string{"127.0.0.1:9050", "ftp://proxy:21", "socks5h://127.0.0.1", "socks4://tor:9050"}
+	for _, raw := range invalid {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", raw)
+		}
+	}
+}
+
+func TestHTTPClientUsesProxy(t *testing.T) {
+	// An HTTP proxy receives plain HTTP requests with the full target URL
+	var proxied []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxyServer.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer direct.Close()
+
+	client, err := NewHTTPClient(proxyServer.URL, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://ipfs.example.com:5001/api/v0/version")
+	if err != nil {
+		t.Fatalf("Request through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" || len(proxied) != 1 || proxied[0] != "ipfs.example.com:5001" {
+		t.Errorf("Expected the request to go through the proxy, got %q (proxied %v)", body, proxied)
+	}
+
+	// The test servers listen on loopback, which is never proxied
+	resp, err = client.Get(direct.URL)
+	if err != nil {
+		t.Fatalf("Loopback request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "direct" || len(proxied) != 1 {
+		t.Errorf("Expected loopback requests to bypass the proxy, got %q", body)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"::1":         true,
+		"10.0.0.5":    false,
+		"example.com": false,
+	}
+	for host, want := range tests {
+		if got := IsLoopback(host); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
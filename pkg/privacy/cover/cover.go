@@ -0,0 +1,383 @@
+// Package cover generates cover traffic: a node fetches, and optionally
+// stores, random popular blocks in the background at a low rate, so the
+// blocks it moves reveal less about the files its user actually uploads and
+// downloads.
+package cover
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+// candidatesPerBlock is how many candidates are requested from the sources
+// for each block fetched, so rounds pick from a wider set than they fetch
+const candidatesPerBlock = 4
+
+// BlockStore moves blocks; *storage.Manager satisfies it
+type BlockStore interface {
+	Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error)
+	Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error)
+}
+
+// Source suggests blocks worth fetching as cover, most popular first
+type Source interface {
+	Candidates(ctx context.Context, n int) ([]string, error)
+}
+
+// SourceFunc adapts a function to a Source
+type SourceFunc func(ctx context.Context, n int) ([]string, error)
+
+// Candidates calls f
+func (f SourceFunc) Candidates(ctx context.Context, n int) ([]string, error) {
+	return f(ctx, n)
+}
+
+// CacheSource suggests the most popular blocks in a block cache. These are
+// mostly randomizers, which every upload reuses, so fetching them looks like
+// ordinary use of the network.
+func CacheSource(c cache.Cache) Source {
+	return SourceFunc(func(ctx context.Context, n int) ([]string, error) {
+		infos, err := c.GetRandomizers(n)
+		if err != nil {
+			return nil, err
+		}
+		cids := make([]string, 0, len(infos))
+		for _, info := range infos {
+			cids = append(cids, info.CID)
+		}
+		return cids, nil
+	})
+}
+
+// maxKnownDescriptors bounds the block lists AnnouncedSource remembers
+const maxKnownDescriptors = 256
+
+// AnnouncedSource suggests blocks of files other users have announced, so
+// cover fetches look like downloads of public files. announced returns
+// descriptor CIDs, most popular or recent first; descriptors are read
+// through store, and encrypted ones are skipped.
+func AnnouncedSource(store BlockStore, announced func(n int) ([]string, error)) Source {
+	var mu sync.Mutex
+	known := make(map[string][]string) // Descriptor CID -> block CIDs
+
+	return SourceFunc(func(ctx context.Context, n int) ([]string, error) {
+		descriptorCIDs, err := announced(n)
+		if err != nil {
+			return nil, err
+		}
+
+		var cids []string
+		for _, descriptorCID := range descriptorCIDs {
+			mu.Lock()
+			blockCIDs, ok := known[descriptorCID]
+			mu.Unlock()
+			if !ok {
+				blockCIDs = descriptorBlocks(ctx, store, descriptorCID)
+				mu.Lock()
+				if len(known) >= maxKnownDescriptors {
+					known = make(map[string][]string)
+				}
+				known[descriptorCID] = blockCIDs
+				mu.Unlock()
+			}
+			cids = append(cids, blockCIDs...)
+		}
+		return cids, nil
+	})
+}
+
+// descriptorBlocks lists the blocks of a plain descriptor, including the
+// descriptor itself, or none if it can't be read
+func descriptorBlocks(ctx context.Context, store BlockStore, descriptorCID string) []string {
+	block, err := store.Get(ctx, &storage.BlockAddress{ID: descriptorCID})
+	if err != nil {
+		return nil
+	}
+	descriptor, err := descriptors.FromJSON(block.Data)
+	if err != nil {
+		return nil
+	}
+	cids := []string{descriptorCID}
+	for _, pair := range descriptor.Blocks {
+		cids = append(cids, pair.DataCID, pair.RandomizerCID1, pair.RandomizerCID2)
+	}
+	return cids
+}
+
+// Config sets how much cover traffic is generated and when
+type Config struct {
+	Interval       time.Duration // Average time between rounds
+	BlocksPerRound int           // Blocks fetched per round
+	Bandwidth      int64         // Bytes per second; 0 for unlimited
+	ActiveHours    *ActiveHours  // Only generate traffic in these hours; nil for always
+	StoreBlocks    bool          // Keep fetched blocks, so the node also serves them
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("cover traffic interval must be positive (current: %s)", c.Interval)
+	}
+	if c.BlocksPerRound <= 0 {
+		return fmt.Errorf("cover traffic blocks per round must be positive (current: %d)", c.BlocksPerRound)
+	}
+	if c.Bandwidth < 0 {
+		return fmt.Errorf("cover traffic bandwidth cannot be negative (current: %d)", c.Bandwidth)
+	}
+	return nil
+}
+
+// ActiveHours is a daily window of local time, which may wrap past midnight
+type ActiveHours struct {
+	Start time.Duration // Since midnight
+	End   time.Duration
+}
+
+// ParseActiveHours parses a window such as "08:00-23:00" or "22:00-06:00".
+// An empty string means always active and returns nil.
+func ParseActiveHours(value string) (*ActiveHours, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	startText, endText, ok := strings.Cut(value, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid active hours %q: use HH:MM-HH:MM", value)
+	}
+	start, err := parseClock(startText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid active hours %q: %w", value, err)
+	}
+	end, err := parseClock(endText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid active hours %q: %w", value, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid active hours %q: start and end are the same", value)
+	}
+	return &ActiveHours{Start: start, End: end}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window
+func (h *ActiveHours) Contains(t time.Time) bool {
+	if h == nil {
+		return true
+	}
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if h.Start < h.End {
+		return sinceMidnight >= h.Start && sinceMidnight < h.End
+	}
+	return sinceMidnight >= h.Start || sinceMidnight < h.End
+}
+
+// Stats describes the cover traffic generated so far
+type Stats struct {
+	Rounds    int64     `json:"rounds"`
+	Fetched   int64     `json:"blocks_fetched"`
+	Stored    int64     `json:"blocks_stored"`
+	Bytes     int64     `json:"bytes"`
+	Errors    int64     `json:"errors"`
+	LastRound time.Time `json:"last_round"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Generator fetches random popular blocks in rounds. Rounds are spaced at
+// random around the configured interval so they don't form a pattern of
+// their own, and blocks are paced to the configured bandwidth.
+type Generator struct {
+	store   BlockStore
+	sources []Source
+	config  Config
+	limiter *workers.BandwidthLimiter
+	now     func() time.Time
+
+	mu    sync.Mutex
+	stats Stats
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewGenerator creates a generator drawing blocks from sources
+func NewGenerator(store BlockStore, config Config, sources ...Source) (*Generator, error) {
+	if store == nil {
+		return nil, errors.New("block store cannot be nil")
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("at least one block source is required")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Generator{
+		store:   store,
+		sources: sources,
+		config:  config,
+		limiter: workers.NewBandwidthLimiter(config.Bandwidth),
+		now:     time.Now,
+	}, nil
+}
+
+// Start starts generating cover traffic in the background
+func (g *Generator) Start() {
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	g.wg.Add(1)
+	go g.loop()
+}
+
+// Stop stops generating cover traffic, abandoning the current round
+func (g *Generator) Stop() {
+	if g.cancel != nil {
+		g.cancel()
+	}
+	g.wg.Wait()
+}
+
+// Stats returns what the generator has done so far
+func (g *Generator) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stats
+}
+
+func (g *Generator) loop() {
+	defer g.wg.Done()
+	for {
+		timer := time.NewTimer(g.nextDelay())
+		select {
+		case <-g.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !g.config.ActiveHours.Contains(g.now()) {
+			continue
+		}
+		g.Round(g.ctx)
+	}
+}
+
+// nextDelay picks a delay uniformly between half and one and a half times
+// the interval
+func (g *Generator) nextDelay() time.Duration {
+	interval := int64(g.config.Interval)
+	n, err := rand.Int(rand.Reader, big.NewInt(interval))
+	if err != nil {
+		return g.config.Interval
+	}
+	return time.Duration(interval/2 + n.Int64())
+}
+
+// Round fetches one round of cover blocks and returns how many were fetched
+func (g *Generator) Round(ctx context.Context) int {
+	cids, err := g.pick(ctx, g.config.BlocksPerRound)
+	g.mu.Lock()
+	g.stats.Rounds++
+	g.stats.LastRound = g.now()
+	g.mu.Unlock()
+	if err != nil {
+		g.recordError(err)
+		return 0
+	}
+
+	fetched := 0
+	for _, cid := range cids {
+		if ctx.Err() != nil {
+			break
+		}
+		block, err := g.store.Get(ctx, &storage.BlockAddress{ID: cid})
+		if err != nil {
+			g.recordError(fmt.Errorf("failed to fetch %s: %w", cid, err))
+			continue
+		}
+		fetched++
+		g.mu.Lock()
+		g.stats.Fetched++
+		g.stats.Bytes += int64(len(block.Data))
+		g.mu.Unlock()
+
+		if g.config.StoreBlocks {
+			if _, err := g.store.Put(ctx, block); err != nil {
+				g.recordError(fmt.Errorf("failed to store %s: %w", cid, err))
+			} else {
+				g.mu.Lock()
+				g.stats.Stored++
+				g.mu.Unlock()
+			}
+		}
+
+		// Sizes are only known after fetching, so pace the next block instead
+		if err := g.limiter.WaitN(ctx, len(block.Data)); err != nil {
+			break
+		}
+	}
+	return fetched
+}
+
+// pick chooses n distinct blocks at random from the sources' candidates
+func (g *Generator) pick(ctx context.Context, n int) ([]string, error) {
+	seen := make(map[string]bool)
+	var candidates []string
+	var lastErr error
+	for _, source := range g.sources {
+		cids, err := source.Candidates(ctx, n*candidatesPerBlock)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, cid := range cids {
+			if cid != "" && !seen[cid] {
+				seen[cid] = true
+				candidates = append(candidates, cid)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, nil
+	}
+
+	// Partial Fisher-Yates shuffle
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	for i := 0; i < n; i++ {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(candidates)-i)))
+		if err != nil {
+			return nil, err
+		}
+		k := i + int(j.Int64())
+		candidates[i], candidates[k] = candidates[k], candidates[i]
+	}
+	return candidates[:n], nil
+}
+
+func (g *Generator) recordError(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats.Errors++
+	g.stats.LastError = err.Error()
+}
@@ -0,0 +1,184 @@
+package cover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// memoryStore is a BlockStore holding blocks in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+	gets   []string
+	puts   int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{blocks: make(map[string][]byte)}
+}
+
+func (m *memoryStore) add(cid string, data []byte) {
+	m.blocks[cid] = data
+}
+
+func (m *memoryStore) Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gets = append(m.gets, address.ID)
+	data, ok := m.blocks[address.ID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return blocks.NewBlock(data)
+}
+
+func (m *memoryStore) Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.puts++
+	return &storage.BlockAddress{ID: block.ID}, nil
+}
+
+func staticSource(cids ...string) Source {
+	return SourceFunc(func(ctx context.Context, n int) ([]string, error) {
+		return cids, nil
+	})
+}
+
+func TestRoundFetchesDistinctBlocks(t *testing.T) {
+	store := newMemoryStore()
+	var cids []string
+	for i := 0; i < 10; i++ {
+		cid := fmt.Sprintf("block%d", i)
+		store.add(cid, []byte(cid))
+		cids = append(cids, cid)
+	}
+
+	generator, err := NewGenerator(store, Config{Interval: time.Minute, BlocksPerRound: 4, StoreBlocks: true},
+		staticSource(cids[:6]...), staticSource(cids[4:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched := generator.Round(context.Background()); fetched != 4 {
+		t.Fatalf("Expected 4 blocks fetched, got %d", fetched)
+	}
+
+	seen := make(map[string]bool)
+	for _, cid := range store.gets {
+		if seen[cid] {
+			t.Errorf("Block %s fetched twice in one round", cid)
+		}
+		seen[cid] = true
+	}
+	stats := generator.Stats()
+	if stats.Rounds != 1 || stats.Fetched != 4 || stats.Stored != 4 || store.puts != 4 {
+		t.Errorf("Unexpected stats %+v with %d puts", stats, store.puts)
+	}
+}
+
+func TestRoundWithoutStoring(t *testing.T) {
+	store := newMemoryStore()
+	store.add("a", []byte("a"))
+	store.add("b", []byte("b"))
+
+	generator, err := NewGenerator(store, Config{Interval: time.Minute, BlocksPerRound: 5}, staticSource("a", "b", "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched := generator.Round(context.Background()); fetched != 2 {
+		t.Errorf("Expected the 2 available blocks, got %d", fetched)
+	}
+	stats := generator.Stats()
+	if store.puts != 0 || stats.Stored != 0 {
+		t.Error("Expected blocks not to be stored")
+	}
+	if stats.Errors != 1 || stats.LastError == "" {
+		t.Errorf("Expected the missing block to be recorded as an error, got %+v", stats)
+	}
+}
+
+func TestAnnouncedSource(t *testing.T) {
+	store := newMemoryStore()
+	descriptor := descriptors.NewDescriptor("public.txt", 10, 16, 16)
+	descriptor.AddBlockTriple("data", "rand1", "rand2")
+	data, err := descriptor.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.add("public", data)
+	store.add("private", []byte(`{"version":"3.0","encrypted":true}`))
+
+	source := AnnouncedSource(store, func(n int) ([]string, error) {
+		return []string{"public", "private"}, nil
+	})
+	for i := 0; i < 2; i++ {
+		cids, err := source.Candidates(context.Background(), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(cids) != "[public data rand1 rand2]" {
+			t.Errorf("Unexpected candidates %v", cids)
+		}
+	}
+	if len(store.gets) != 2 {
+		t.Errorf("Expected each descriptor to be read once, got %v", store.gets)
+	}
+}
+
+func TestActiveHours(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		window string
+		at     time.Time
+		want   bool
+	}{
+		{"", day(3, 0), true},
+		{"08:00-23:00", day(8, 0), true},
+		{"08:00-23:00", day(22, 59), true},
+		{"08:00-23:00", day(23, 0), false},
+		{"08:00-23:00", day(7, 59), false},
+		{"22:00-06:00", day(23, 30), true},
+		{"22:00-06:00", day(5, 0), true},
+		{"22:00-06:00", day(12, 0), false},
+	}
+	for _, tt := range tests {
+		hours, err := ParseActiveHours(tt.window)
+		if err != nil {
+			t.Fatalf("ParseActiveHours(%q) failed: %v", tt.window, err)
+		}
+		if got := hours.Contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.window, tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"8-23", "08:00", "25:00-26:00", "09:00-09:00"} {
+		if _, err := ParseActiveHours(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestNewGeneratorValidation(t *testing.T) {
+	store := newMemoryStore()
+	source := staticSource("a")
+	if _, err := NewGenerator(store, Config{BlocksPerRound: 1}, source); err == nil {
+		t.Error("Expected a zero interval to be rejected")
+	}
+	if _, err := NewGenerator(store, Config{Interval: time.Minute}, source); err == nil {
+		t.Error("Expected zero blocks per round to be rejected")
+	}
+	if _, err := NewGenerator(store, Config{Interval: time.Minute, BlocksPerRound: 1}); err == nil {
+		t.Error("Expected a generator without sources to be rejected")
+	}
+}
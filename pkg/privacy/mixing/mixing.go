@@ -0,0 +1,252 @@
+// Package mixing schedules block retrievals so the network sees less of
+// which file a node is reconstructing. Requests from parallel downloads are
+// pooled into batches, each batch is fetched in random order, and fetches
+// start after jittered delays, so an observer sees one interleaved stream
+// instead of each descriptor's blocks in sequence.
+package mixing
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// Fetcher retrieves one block
+type Fetcher func(ctx context.Context, cid string) (*blocks.Block, error)
+
+// Config sets how retrievals are mixed
+type Config struct {
+	MinDelay    time.Duration // Shortest pause before each fetch starts
+	MaxDelay    time.Duration // Longest pause before each fetch starts
+	BatchSize   int           // Requests that send a batch without waiting for the window
+	BatchWindow time.Duration // How long a batch waits for requests from other downloads
+	Parallelism int           // Fetches in flight at once
+}
+
+// DefaultConfig returns settings that hide ordering at a modest cost in
+// throughput
+func DefaultConfig() Config {
+	return Config{
+		MaxDelay:    100 * time.Millisecond,
+		BatchSize:   32,
+		BatchWindow: 50 * time.Millisecond,
+		Parallelism: 4,
+	}
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	if c.MinDelay < 0 || c.MaxDelay < c.MinDelay {
+		return fmt.Errorf("retrieval delays must satisfy 0 <= min <= max (current: %s-%s)", c.MinDelay, c.MaxDelay)
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("retrieval batch size must be positive (current: %d)", c.BatchSize)
+	}
+	if c.BatchWindow < 0 {
+		return fmt.Errorf("retrieval batch window cannot be negative (current: %s)", c.BatchWindow)
+	}
+	if c.Parallelism <= 0 {
+		return fmt.Errorf("retrieval parallelism must be positive (current: %d)", c.Parallelism)
+	}
+	return nil
+}
+
+// request is one block wanted by a Fetch call
+type request struct {
+	ctx   context.Context
+	cid   string
+	done  chan struct{}
+	block *blocks.Block
+	err   error
+}
+
+func (r *request) finish(block *blocks.Block, err error) {
+	r.block, r.err = block, err
+	close(r.done)
+}
+
+// Scheduler mixes the block requests of concurrent Fetch calls. It needs no
+// starting or stopping: a dispatcher runs only while requests are pending.
+type Scheduler struct {
+	fetch  Fetcher
+	config Config
+	slots  chan struct{}
+
+	mu      sync.Mutex
+	pending []*request
+	running bool
+	wake    chan struct{}
+}
+
+// NewScheduler creates a scheduler retrieving blocks with fetch
+func NewScheduler(fetch Fetcher, config Config) (*Scheduler, error) {
+	if fetch == nil {
+		return nil, errors.New("fetcher cannot be nil")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		fetch:  fetch,
+		config: config,
+		slots:  make(chan struct{}, config.Parallelism),
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch retrieves blocks and returns them by CID, fetching duplicates once.
+// It fails with the first block that can't be retrieved.
+func (s *Scheduler) Fetch(ctx context.Context, cids []string) (map[string]*blocks.Block, error) {
+	// Requests still queued are dropped once the caller has its answer
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seen := make(map[string]bool, len(cids))
+	requests := make([]*request, 0, len(cids))
+	for _, cid := range cids {
+		if !seen[cid] {
+			seen[cid] = true
+			requests = append(requests, &request{ctx: ctx, cid: cid, done: make(chan struct{})})
+		}
+	}
+	s.enqueue(requests)
+
+	fetched := make(map[string]*blocks.Block, len(requests))
+	for _, req := range requests {
+		select {
+		case <-req.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if req.err != nil {
+			return nil, fmt.Errorf("failed to retrieve block %s: %w", req.cid, req.err)
+		}
+		fetched[req.cid] = req.block
+	}
+	return fetched, nil
+}
+
+func (s *Scheduler) enqueue(requests []*request) {
+	if len(requests) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, requests...)
+	if !s.running {
+		s.running = true
+		go s.run()
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run dispatches batches until no requests are left
+func (s *Scheduler) run() {
+	for {
+		batch := s.nextBatch()
+		if batch == nil {
+			return
+		}
+		if err := shuffle(batch); err != nil {
+			for _, req := range batch {
+				req.finish(nil, err)
+			}
+			continue
+		}
+		for _, req := range batch {
+			if err := sleep(req.ctx, s.delay()); err != nil {
+				req.finish(nil, err)
+				continue
+			}
+			s.slots <- struct{}{}
+			go func(req *request) {
+				defer func() { <-s.slots }()
+				req.finish(s.fetch(req.ctx, req.cid))
+			}(req)
+		}
+	}
+}
+
+// nextBatch waits until BatchSize requests are pending or the batch window
+// has passed, then takes every pending request. It returns nil, marking the
+// dispatcher stopped, when nothing is pending.
+func (s *Scheduler) nextBatch() []*request {
+	window := time.NewTimer(s.config.BatchWindow)
+	defer window.Stop()
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return nil
+		}
+		full := len(s.pending) >= s.config.BatchSize
+		s.mu.Unlock()
+		if full {
+			return s.take()
+		}
+		select {
+		case <-s.wake:
+		case <-window.C:
+			return s.take()
+		}
+	}
+}
+
+func (s *Scheduler) take() []*request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := s.pending
+	s.pending = nil
+	return batch
+}
+
+// delay picks a pause uniformly between the minimum and maximum delays
+func (s *Scheduler) delay() time.Duration {
+	spread := int64(s.config.MaxDelay - s.config.MinDelay)
+	if spread <= 0 {
+		return s.config.MinDelay
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(spread+1))
+	if err != nil {
+		return s.config.MaxDelay
+	}
+	return s.config.MinDelay + time.Duration(n.Int64())
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// shuffle puts requests in a uniformly random order (Fisher-Yates)
+func shuffle(requests []*request) error {
+	for i := len(requests) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		k := int(j.Int64())
+		requests[i], requests[k] = requests[k], requests[i]
+	}
+	return nil
+}
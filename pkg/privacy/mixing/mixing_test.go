@@ -0,0 +1,160 @@
+package mixing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// recorder is a Fetcher remembering the order blocks were requested in
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+	fail  string
+}
+
+func (r *recorder) fetch(ctx context.Context, cid string) (*blocks.Block, error) {
+	r.mu.Lock()
+	r.order = append(r.order, cid)
+	r.mu.Unlock()
+	if cid == r.fail {
+		return nil, errors.New("not found")
+	}
+	return blocks.NewBlock([]byte(cid))
+}
+
+func cidList(prefix string, n int) []string {
+	cids := make([]string, n)
+	for i := range cids {
+		cids[i] = fmt.Sprintf("%s%02d", prefix, i)
+	}
+	return cids
+}
+
+func TestFetchReturnsEveryBlockOnce(t *testing.T) {
+	rec := &recorder{}
+	scheduler, err := NewScheduler(rec.fetch, Config{BatchSize: 8, Parallelism: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cids := append(cidList("a", 20), "a00", "a05")
+	fetched, err := scheduler.Fetch(context.Background(), cids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 20 || len(rec.order) != 20 {
+		t.Fatalf("Expected 20 distinct blocks fetched once each, got %d blocks and %d fetches", len(fetched), len(rec.order))
+	}
+	for _, cid := range cids {
+		if block := fetched[cid]; block == nil || string(block.Data) != cid {
+			t.Errorf("Wrong block for %s", cid)
+		}
+	}
+}
+
+func TestFetchRandomizesOrder(t *testing.T) {
+	rec := &recorder{}
+	scheduler, err := NewScheduler(rec.fetch, Config{BatchSize: 64, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cids := cidList("a", 64)
+	if _, err := scheduler.Fetch(context.Background(), cids); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rec.order, ",") == strings.Join(cids, ",") {
+		t.Error("Expected blocks to be fetched in a random order")
+	}
+}
+
+func TestFetchMixesParallelDownloads(t *testing.T) {
+	rec := &recorder{}
+	scheduler, err := NewScheduler(rec.fetch, Config{BatchSize: 1000, BatchWindow: 100 * time.Millisecond, Parallelism: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(prefix string) {
+			defer wg.Done()
+			if _, err := scheduler.Fetch(context.Background(), cidList(prefix, 20)); err != nil {
+				t.Error(err)
+			}
+		}(prefix)
+	}
+	wg.Wait()
+
+	// Both downloads land in one batch, so their blocks interleave
+	switches := 0
+	for i := 1; i < len(rec.order); i++ {
+		if rec.order[i][0] != rec.order[i-1][0] {
+			switches++
+		}
+	}
+	if len(rec.order) != 40 || switches < 2 {
+		t.Errorf("Expected the downloads to be interleaved, got %v", rec.order)
+	}
+}
+
+func TestFetchAppliesDelays(t *testing.T) {
+	rec := &recorder{}
+	scheduler, err := NewScheduler(rec.fetch, Config{MinDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond, BatchSize: 1, Parallelism: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := scheduler.Fetch(context.Background(), cidList("a", 5)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 10ms before each of 5 fetches, took %s", elapsed)
+	}
+}
+
+func TestFetchFailsOnMissingBlock(t *testing.T) {
+	rec := &recorder{fail: "a03"}
+	scheduler, err := NewScheduler(rec.fetch, Config{BatchSize: 4, Parallelism: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.Fetch(context.Background(), cidList("a", 8)); err == nil || !strings.Contains(err.Error(), "a03") {
+		t.Errorf("Expected the missing block to fail the fetch, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := scheduler.Fetch(ctx, cidList("b", 4)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled fetch to fail, got %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	defaults := DefaultConfig()
+	if err := defaults.Validate(); err != nil {
+		t.Fatalf("Default config rejected: %v", err)
+	}
+	invalid := []Config{
+		{MinDelay: 2 * time.Second, MaxDelay: time.Second, BatchSize: 1, Parallelism: 1},
+		{MinDelay: -time.Second, BatchSize: 1, Parallelism: 1},
+		{BatchSize: 0, Parallelism: 1},
+		{BatchSize: 1, Parallelism: 0},
+		{BatchSize: 1, BatchWindow: -time.Second, Parallelism: 1},
+	}
+	for _, config := range invalid {
+		if err := config.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+	if _, err := NewScheduler(nil, defaults); err == nil {
+		t.Error("Expected a nil fetcher to be rejected")
+	}
+}
@@ -215,9 +215,7 @@ func (client *ReuseAwareClient) DownloadFile(descriptorCID string) ([]byte, erro
 		}
 		
 		originalData := make([]byte, fileSize)
-		for i := 0; i < fileSize; i++ {
-			originalData[i] = dataBlock.Data[i] ^ rand1Data[i] ^ rand2Data[i]
-		}
+		blocks.XOR3(originalData, dataBlock.Data, rand1Data, rand2Data)
 		
 		fileData = append(fileData, originalData...)
 	}
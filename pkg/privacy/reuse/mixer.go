@@ -386,9 +386,7 @@ func (mixer *PublicDomainMixer) storeAnonymizedBlock(fileBlock, randomizer1, ran
 
 	// Perform XOR operations: anonymized = fileBlock XOR randomizer1 XOR randomizer2
 	anonymizedData := make([]byte, fileSize)
-	for i := 0; i < fileSize; i++ {
-		anonymizedData[i] = fileBlock.Data[i] ^ rand1Data[i] ^ rand2Data[i]
-	}
+	blocks.XOR3(anonymizedData, fileBlock.Data, rand1Data, rand2Data)
 
 	// Create anonymized block with proper ID
 	anonymizedBlock, err := blocks.NewBlock(anonymizedData)
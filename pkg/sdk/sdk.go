@@ -0,0 +1,258 @@
+// Package sdk embeds NoiseFS in other Go programs. It wraps the storage
+// manager, client, share and announcement packages behind a small API that
+// is kept stable across releases:
+//
+//	client, err := sdk.Open(ctx, sdk.Config{})
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//
+//	cid, err := client.Put(ctx, file, "report.pdf")
+//	...
+//	f, err := client.Get(ctx, cid)
+//
+// Blocks are stored through the IPFS daemon or embedded node of the NoiseFS
+// configuration, or through any storage.Backend the application supplies
+// in Config.Backend.
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/announce"
+	"github.com/TheEntropyCollective/noisefs/pkg/announce/pubsub"
+	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/descriptors"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/config"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+// ErrSubscribeUnsupported is returned by Subscribe when blocks are not
+// stored through an IPFS daemon, whose PubSub carries announcements
+var ErrSubscribeUnsupported = errors.New("subscribing needs the IPFS daemon backend")
+
+// ErrClosed is returned by methods of a closed Client
+var ErrClosed = errors.New("client is closed")
+
+// Config configures a Client
+type Config struct {
+	// NoiseFS settings: IPFS connection, cache, block sizes and retrieval
+	// mixing. Nil uses config.DefaultConfig().
+	Node *config.Config
+
+	// Backend stores blocks instead of the IPFS backend in Node. It must
+	// report the storage.CapabilityContentAddress capability. The client
+	// connects it on Open and disconnects it on Close.
+	Backend storage.Backend
+}
+
+// File is a file read with Get
+type File struct {
+	Name string
+	Size int64
+	io.ReadSeeker
+}
+
+// Share is a limited share created with Share. The key service must be
+// given Key, e.g. with descriptors.ShareStore.Add for the local web UI,
+// before recipients can open the share.
+type Share = descriptors.ShareRecord
+
+// ShareOptions limits a share; see descriptors.ShareOptions
+type ShareOptions = descriptors.ShareOptions
+
+// Announcement is a file announced on a topic; see announce.Announcement
+type Announcement = announce.Announcement
+
+// Client stores and retrieves files. It is safe for concurrent use.
+type Client struct {
+	node           *config.Config
+	storageManager *storage.Manager
+	client         *noisefs.Client
+	ipfs           bool // Blocks go through an IPFS daemon, so PubSub is available
+
+	mu         sync.Mutex
+	subscriber *pubsub.RealtimeSubscriber
+	closed     chan struct{}
+}
+
+// Open connects to the storage backend and returns a client. Close it when
+// done.
+func Open(ctx context.Context, cfg Config) (*Client, error) {
+	node := cfg.Node
+	if node == nil {
+		node = config.DefaultConfig()
+	}
+
+	storageConfig := node.StorageConfig()
+	if cfg.Backend != nil {
+		storageConfig.Backends = map[string]*storage.BackendConfig{
+			storage.BackendTypeCustom: storage.CustomBackendConfig(cfg.Backend),
+		}
+		storageConfig.DefaultBackend = storage.BackendTypeCustom
+	}
+
+	storageManager, err := storage.NewManager(storageConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	if err := storageManager.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start storage manager: %w", err)
+	}
+
+	client, err := newClient(storageManager, node)
+	if err != nil {
+		storageManager.Stop(context.Background())
+		return nil, err
+	}
+
+	return &Client{
+		node:           node,
+		storageManager: storageManager,
+		client:         client,
+		ipfs:           cfg.Backend == nil && node.IPFS.Mode != config.IPFSModeEmbedded,
+		closed:         make(chan struct{}),
+	}, nil
+}
+
+// newClient creates a NoiseFS client with the block sizes, retrieval
+// mixing and content processors of the configuration
+func newClient(storageManager *storage.Manager, node *config.Config) (*noisefs.Client, error) {
+	client, err := noisefs.NewClient(storageManager, cache.NewMemoryCache(node.Cache.BlockCacheSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+	policy, err := node.Blocks.SizePolicy()
+	if err != nil {
+		return nil, err
+	}
+	client.SetBlockSizePolicy(policy)
+	if node.RetrievalMixing.Enabled {
+		schedulerConfig, err := node.RetrievalMixing.SchedulerConfig()
+		if err != nil {
+			return nil, err
+		}
+		if err := client.SetRetrievalMixing(&schedulerConfig); err != nil {
+			return nil, err
+		}
+	}
+	pipeline, err := node.ProcessorPipeline()
+	if err != nil {
+		return nil, err
+	}
+	client.SetProcessors(pipeline)
+	return client, nil
+}
+
+// Put stores the contents of r as a file named name and returns the CID
+// of its descriptor, which Get and Share take
+func (c *Client) Put(ctx context.Context, r io.Reader, name string) (string, error) {
+	if c.isClosed() {
+		return "", ErrClosed
+	}
+	return c.client.Upload(ctx, r, name)
+}
+
+// Get retrieves the file whose descriptor CID is cid
+func (c *Client) Get(ctx context.Context, cid string) (*File, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	file, err := c.client.DownloadFile(ctx, cid, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Name: file.Filename, Size: file.Size, ReadSeeker: file.Reader}, nil
+}
+
+// Share creates a limited share of the file whose descriptor CID is cid.
+// Recipients open it with its ShareCID while the key service holds the key.
+func (c *Client) Share(ctx context.Context, cid string, options ShareOptions) (*Share, error) {
+	if c.isClosed() {
+		return nil, ErrClosed
+	}
+	descriptor, _, err := c.client.LoadDescriptor(cid, "")
+	if err != nil {
+		return nil, err
+	}
+	return descriptors.CreateShare(c.storageManager, descriptor, options)
+}
+
+// Subscribe calls handler with each valid announcement published on topic
+// until ctx is done or the client is closed. Handlers run on one goroutine
+// per topic and should return quickly.
+func (c *Client) Subscribe(ctx context.Context, topic string, handler func(*Announcement)) error {
+	if !c.ipfs {
+		return ErrSubscribeUnsupported
+	}
+
+	c.mu.Lock()
+	if c.isClosed() {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.subscriber == nil {
+		shell, err := backends.NewIPFSShell(c.node.IPFSConnection())
+		if err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to create IPFS client: %w", err)
+		}
+		if c.subscriber, err = pubsub.NewRealtimeSubscriber(shell); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	subscriber := c.subscriber
+	c.mu.Unlock()
+
+	topicHash := announce.HashTopic(topic)
+	err := subscriber.SubscribeHash(topicHash, func(ann *announce.Announcement) error {
+		handler(ann)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			subscriber.UnsubscribeHash(topicHash)
+		case <-c.closed:
+		}
+	}()
+	return nil
+}
+
+// Close ends subscriptions and disconnects from the storage backend
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.isClosed() {
+		c.mu.Unlock()
+		return nil
+	}
+	close(c.closed)
+	subscriber := c.subscriber
+	c.mu.Unlock()
+
+	if subscriber != nil {
+		subscriber.Stop()
+	}
+	return c.storageManager.Stop(context.Background())
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
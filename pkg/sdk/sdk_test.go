@@ -0,0 +1,94 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/backends"
+)
+
+func openTestClient(t *testing.T) *Client {
+	t.Helper()
+	backend, err := backends.NewMockBackend("sdk-test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := Open(context.Background(), Config{Backend: backend})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestPutGet(t *testing.T) {
+	client := openTestClient(t)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("noisefs sdk "), 1000)
+	cid, err := client.Put(ctx, bytes.NewReader(data), "notes.txt")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	file, err := client.Get(ctx, cid)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if file.Name != "notes.txt" || file.Size != int64(len(data)) {
+		t.Errorf("got %s (%d bytes), want notes.txt (%d bytes)", file.Name, file.Size, len(data))
+	}
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("retrieved data differs from stored data")
+	}
+}
+
+func TestShare(t *testing.T) {
+	client := openTestClient(t)
+	ctx := context.Background()
+
+	cid, err := client.Put(ctx, bytes.NewReader([]byte("shared")), "shared.txt")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	share, err := client.Share(ctx, cid, ShareOptions{
+		KeyService:   "https://noisefs.example.com",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		MaxDownloads: 3,
+	})
+	if err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	if share.ShareCID == "" || share.Key == "" || share.Filename != "shared.txt" {
+		t.Errorf("incomplete share: %+v", share)
+	}
+}
+
+func TestSubscribeNeedsIPFS(t *testing.T) {
+	client := openTestClient(t)
+	err := client.Subscribe(context.Background(), "test/topic", func(*Announcement) {})
+	if !errors.Is(err, ErrSubscribeUnsupported) {
+		t.Errorf("Subscribe with a custom backend returned %v, want ErrSubscribeUnsupported", err)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	client := openTestClient(t)
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+	if _, err := client.Put(context.Background(), bytes.NewReader(nil), "x"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Put after Close returned %v, want ErrClosed", err)
+	}
+}
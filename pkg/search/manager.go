@@ -255,6 +255,26 @@ func (sm *SearchManager) createIndexMapping() mapping.IndexMapping {
 	dirField.Analyzer = "keyword"
 	fileMapping.AddFieldMappingsAt("directory", dirField)
 	
+	// Tags field - keyword, one term per tag
+	tagsField := bleve.NewTextFieldMapping()
+	tagsField.Store = true
+	tagsField.Index = true
+	tagsField.Analyzer = "keyword"
+	fileMapping.AddFieldMappingsAt("tags", tagsField)
+	
+	// Content hash field - keyword, finds copies of the same content
+	hashField := bleve.NewTextFieldMapping()
+	hashField.Store = true
+	hashField.Index = true
+	hashField.Analyzer = "keyword"
+	fileMapping.AddFieldMappingsAt("content_hash", hashField)
+	
+	// Encrypted flag - boolean, filterable
+	encryptedField := bleve.NewBooleanFieldMapping()
+	encryptedField.Store = true
+	encryptedField.Index = true
+	fileMapping.AddFieldMappingsAt("is_encrypted", encryptedField)
+	
 	// Add the file mapping to the index
 	indexMapping.AddDocumentMapping("file", fileMapping)
 	
@@ -351,6 +371,21 @@ func (sm *SearchManager) indexDocument(req IndexRequest) error {
 		doc[k] = v
 	}
 	
+	// Content metadata recorded in the file index knows the content, so it
+	// wins over guesses from the path
+	if mimeType, ok := entryMap["MimeType"].(string); ok && mimeType != "" {
+		doc["mime_type"] = mimeType
+	}
+	if tags, ok := entryMap["Tags"].([]string); ok && len(tags) > 0 {
+		doc["tags"] = tags
+	}
+	if hash, ok := entryMap["ContentHash"].(string); ok && hash != "" {
+		doc["content_hash"] = hash
+	}
+	if encrypted, ok := entryMap["Encrypted"].(bool); ok {
+		doc["is_encrypted"] = encrypted
+	}
+	
 	// Index the document
 	if err := sm.bleveIndex.Index(req.Path, doc); err != nil {
 		return fmt.Errorf("failed to index document: %w", err)
@@ -220,6 +220,23 @@ func (sm *SearchManager) Search(queryStr string, options SearchOptions) (*Search
 		if dir, ok := hit.Fields["directory"].(string); ok {
 			result.Directory = dir
 		}
+		// A single tag comes back as a string, several as a list
+		switch tags := hit.Fields["tags"].(type) {
+		case string:
+			result.Tags = []string{tags}
+		case []interface{}:
+			for _, tag := range tags {
+				if s, ok := tag.(string); ok {
+					result.Tags = append(result.Tags, s)
+				}
+			}
+		}
+		if hash, ok := hit.Fields["content_hash"].(string); ok {
+			result.ContentHash = hash
+		}
+		if encrypted, ok := hit.Fields["is_encrypted"].(bool); ok {
+			result.IsEncrypted = encrypted
+		}
 		if preview, ok := hit.Fields["preview"].(string); ok {
 			result.Preview = preview
 		}
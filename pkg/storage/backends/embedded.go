@@ -0,0 +1,762 @@
+package backends
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/boxo/bitswap"
+	"github.com/ipfs/boxo/bitswap/network/bsnet"
+	"github.com/ipfs/boxo/blockservice"
+	"github.com/ipfs/boxo/blockstore"
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/exchange/offline"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer"
+	unixfsio "github.com/ipfs/boxo/ipld/unixfs/io"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// DefaultEmbeddedListenAddrs avoids port 4001 so the embedded node can run
+// next to an IPFS daemon
+var DefaultEmbeddedListenAddrs = []string{
+	"/ip4/0.0.0.0/tcp/4002",
+	"/ip4/0.0.0.0/udp/4002/quic-v1",
+}
+
+// EmbeddedBackend implements storage.Backend with an IPFS node running
+// in-process: a libp2p host, the IPFS DHT for content routing and Bitswap
+// for block exchange. Blocks are encoded like `ipfs add` with default
+// settings, so their CIDs match those of the IPFS backend and descriptors
+// work with either.
+type EmbeddedBackend struct {
+	config        *storage.BackendConfig
+	errorReporter storage.ErrorReporter
+	provider      providerConfig
+	announcements atomic.Int64 // Successful provider announcements
+	dhtStats      dhtTracker
+
+	// Node components, set while connected
+	mu          sync.RWMutex
+	host        host.Host
+	dht         *dht.IpfsDHT
+	bitswap     *bitswap.Bitswap
+	blockstore  blockstore.Blockstore
+	datastore   ds.Batching
+	dag         ipld.DAGService // Fetches missing blocks from the network
+	localDAG    ipld.DAGService // Only reads the local blockstore
+	providing   *providerState
+	cancel      context.CancelFunc
+	connectedAt time.Time
+
+	events *storage.EventBus
+	name   string
+}
+
+// NewEmbeddedBackend creates an embedded IPFS backend. The node starts on
+// Connect.
+func NewEmbeddedBackend(config *storage.BackendConfig) (*EmbeddedBackend, error) {
+	if config.Type != storage.BackendTypeEmbedded {
+		return nil, fmt.Errorf("invalid backend type: expected %s, got %s", storage.BackendTypeEmbedded, config.Type)
+	}
+	if _, err := settingStrings(config.Settings, storage.EmbeddedSettingListenAddrs); err != nil {
+		return nil, err
+	}
+	if _, err := settingStrings(config.Settings, storage.EmbeddedSettingBootstrapPeers); err != nil {
+		return nil, err
+	}
+	provider, err := parseProviderConfig(config.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmbeddedBackend{
+		config:        config,
+		errorReporter: storage.NewDefaultErrorReporter(),
+		provider:      provider,
+		name:          storage.BackendTypeEmbedded,
+	}, nil
+}
+
+// Connect starts the node and dials the bootstrap peers. It succeeds even
+// if no peer answers; blocks stored locally stay available.
+func (e *EmbeddedBackend) Connect(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.host != nil {
+		return nil
+	}
+
+	if err := e.start(); err != nil {
+		storageErr := storage.NewConnectionError(storage.BackendTypeEmbedded, err)
+		e.errorReporter.ReportError(storageErr)
+		return storageErr
+	}
+	e.connectedAt = time.Now()
+
+	e.bootstrap(ctx)
+	e.publish(storage.BackendStateConnected, nil)
+	return nil
+}
+
+// start creates the datastore, host, DHT and Bitswap, and starts
+// announcing blocks. Callers hold mu.
+func (e *EmbeddedBackend) start() error {
+	store, key, repoPath, err := e.openRepo()
+	if err != nil {
+		return err
+	}
+
+	listenAddrs := DefaultEmbeddedListenAddrs
+	if addrs, _ := settingStrings(e.config.Settings, storage.EmbeddedSettingListenAddrs); addrs != nil {
+		listenAddrs = addrs
+	}
+	h, err := libp2p.New(libp2p.Identity(key), libp2p.ListenAddrStrings(listenAddrs...))
+	if err != nil {
+		store.Close()
+		return fmt.Errorf("failed to start libp2p host: %w", err)
+	}
+
+	bootstrapPeers, err := e.bootstrapPeers()
+	if err != nil {
+		h.Close()
+		store.Close()
+		return err
+	}
+
+	nodeCtx, cancel := context.WithCancel(context.Background())
+	kad, err := dht.New(nodeCtx, h, dht.Mode(dht.ModeAuto), dht.BootstrapPeers(bootstrapPeers...))
+	if err != nil {
+		cancel()
+		h.Close()
+		store.Close()
+		return fmt.Errorf("failed to start DHT: %w", err)
+	}
+
+	bs := blockstore.NewBlockstoreNoPrefix(store)
+	logPath := ""
+	if repoPath != "" {
+		logPath = filepath.Join(repoPath, "provided")
+	}
+	log, err := openProvideLog(nodeCtx, logPath, bs)
+	if err != nil {
+		cancel()
+		kad.Close()
+		h.Close()
+		store.Close()
+		return err
+	}
+	exchange := bitswap.New(nodeCtx, bsnet.NewFromIpfsHost(h), kad, bs)
+
+	e.host = h
+	e.dht = kad
+	e.bitswap = exchange
+	e.blockstore = bs
+	e.datastore = store
+	e.dag = merkledag.NewDAGService(blockservice.New(bs, exchange))
+	e.localDAG = merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	e.providing = &providerState{
+		dht:        kad,
+		blockstore: bs,
+		log:        log,
+		queue:      make(chan cid.Cid, provideQueueSize),
+	}
+	e.cancel = cancel
+
+	if e.provider.strategy != storage.ProvideStrategyNone {
+		go e.runProvider(nodeCtx, e.providing)
+	}
+	return nil
+}
+
+// openRepo opens the block datastore and loads the node key, creating both
+// on first use. It returns the repo directory, which is empty for in-memory
+// nodes.
+func (e *EmbeddedBackend) openRepo() (ds.Batching, crypto.PrivKey, string, error) {
+	if inMemory, _ := e.config.Settings[storage.EmbeddedSettingInMemory].(bool); inMemory {
+		key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return dssync.MutexWrap(ds.NewMapDatastore()), key, "", nil
+	}
+
+	repoPath, _ := e.config.Settings[storage.EmbeddedSettingRepoPath].(string)
+	if repoPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("no repo_path set and no home directory: %w", err)
+		}
+		repoPath = filepath.Join(home, ".noisefs", "embedded")
+	}
+	if err := os.MkdirAll(repoPath, 0700); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create repo: %w", err)
+	}
+
+	key, err := loadOrCreateNodeKey(filepath.Join(repoPath, "identity.key"))
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Same layout as the IPFS daemon's blocks directory
+	store, err := flatfs.CreateOrOpen(filepath.Join(repoPath, "blocks"), flatfs.NextToLast(2), false)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to open block store: %w", err)
+	}
+	return store, key, repoPath, nil
+}
+
+// loadOrCreateNodeKey keeps the node's peer ID stable across restarts
+func loadOrCreateNodeKey(path string) (crypto.PrivKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node key %s: %w", path, err)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read node key: %w", err)
+	}
+
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err = crypto.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save node key: %w", err)
+	}
+	return key, nil
+}
+
+// bootstrapPeers returns the configured peers, or the public IPFS bootstrap
+// nodes when none are set. An empty list keeps the node to itself until
+// peers connect to it.
+func (e *EmbeddedBackend) bootstrapPeers() ([]peer.AddrInfo, error) {
+	addrs, _ := settingStrings(e.config.Settings, storage.EmbeddedSettingBootstrapPeers)
+	if addrs == nil {
+		return dht.GetDefaultBootstrapPeerAddrInfos(), nil
+	}
+
+	peers := make([]peer.AddrInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap peer %s: %w", addr, err)
+		}
+		peers = append(peers, *info)
+	}
+	return peers, nil
+}
+
+// bootstrap dials the bootstrap peers in parallel, waiting at most the
+// connect timeout, then lets the DHT fill its routing table in the
+// background. Callers hold mu.
+func (e *EmbeddedBackend) bootstrap(ctx context.Context) {
+	peers, _ := e.bootstrapPeers()
+
+	timeout := 10 * time.Second
+	if e.config.Timeouts != nil && e.config.Timeouts.Connect > 0 {
+		timeout = e.config.Timeouts.Connect
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, info := range peers {
+		wg.Add(1)
+		go func(info peer.AddrInfo) {
+			defer wg.Done()
+			e.host.Connect(dialCtx, info)
+		}(info)
+	}
+	wg.Wait()
+
+	go e.dht.Bootstrap(context.Background())
+}
+
+// Disconnect stops the node and closes the block store
+func (e *EmbeddedBackend) Disconnect(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.host == nil {
+		return nil
+	}
+
+	e.cancel()
+	e.bitswap.Close()
+	e.dht.Close()
+	e.host.Close()
+	err := e.datastore.Close()
+
+	e.host = nil
+	e.dht = nil
+	e.bitswap = nil
+	e.blockstore = nil
+	e.datastore = nil
+	e.dag = nil
+	e.localDAG = nil
+	e.providing = nil
+
+	e.publish(storage.BackendStateDisconnected, nil)
+	return err
+}
+
+// IsConnected returns true while the node is running
+func (e *EmbeddedBackend) IsConnected() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.host != nil
+}
+
+// SetEventBus sets the bus that node start and stop are published on
+func (e *EmbeddedBackend) SetEventBus(bus *storage.EventBus, name string) {
+	e.events = bus
+	e.name = name
+}
+
+// Put stores a block and, if the provide strategy covers its role,
+// announces it on the DHT in the background. The role comes from the
+// context; see storage.WithBlockRole.
+func (e *EmbeddedBackend) Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return nil, e.notRunning()
+	}
+
+	root, err := importer.BuildDagFromReader(e.dag, chunker.DefaultSplitter(bytes.NewReader(block.Data)))
+	if err != nil {
+		storageErr := storage.NewStorageError(storage.ErrCodeInvalidRequest, "failed to store block", storage.BackendTypeEmbedded, err)
+		e.errorReporter.ReportError(storageErr)
+		return nil, storageErr
+	}
+
+	role := storage.BlockRoleFromContext(ctx)
+	if err := e.providing.log.add(root.Cid(), role); err != nil {
+		storageErr := storage.NewStorageError(storage.ErrCodeInvalidRequest, "failed to record stored block", storage.BackendTypeEmbedded, err)
+		e.errorReporter.ReportError(storageErr)
+		return nil, storageErr
+	}
+	e.enqueueProvide(e.providing, root.Cid(), role)
+
+	return &storage.BlockAddress{
+		ID:          root.Cid().String(),
+		BackendType: storage.BackendTypeEmbedded,
+		Size:        int64(len(block.Data)),
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+// Get retrieves a block, fetching it from peers if it is not stored locally
+func (e *EmbeddedBackend) Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return nil, e.notRunning()
+	}
+	c, err := e.parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.config.Timeouts != nil && e.config.Timeouts.Read > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.Timeouts.Read)
+		defer cancel()
+	}
+
+	data, err := readDAG(ctx, e.dag, c)
+	if err != nil {
+		code := storage.ErrCodeNotFound
+		if ctx.Err() == context.DeadlineExceeded {
+			code = storage.ErrCodeTimeout
+		}
+		storageErr := storage.NewStorageError(code, "failed to get block", storage.BackendTypeEmbedded, err)
+		storageErr.Address = address
+		e.errorReporter.ReportError(storageErr)
+		return nil, storageErr
+	}
+
+	return blocks.NewBlock(data)
+}
+
+// readDAG reads the file data of a UnixFS DAG
+func readDAG(ctx context.Context, dag ipld.DAGService, c cid.Cid) ([]byte, error) {
+	node, err := dag.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := unixfsio.NewDagReader(ctx, node, dag)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
+
+// Has checks if a block is stored locally, without asking peers
+func (e *EmbeddedBackend) Has(ctx context.Context, address *storage.BlockAddress) (bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return false, e.notRunning()
+	}
+	c, err := e.parseAddress(address)
+	if err != nil {
+		return false, err
+	}
+	return e.blockstore.Has(ctx, c)
+}
+
+// Delete removes a block and the chunks it links to from the local store.
+// Copies on other peers are unaffected.
+func (e *EmbeddedBackend) Delete(ctx context.Context, address *storage.BlockAddress) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return e.notRunning()
+	}
+	c, err := e.parseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	var cids []cid.Cid
+	err = merkledag.Walk(ctx, merkledag.GetLinksDirect(e.localDAG), c, func(c cid.Cid) bool {
+		cids = append(cids, c)
+		return true
+	})
+	if ipld.IsNotFound(err) {
+		return storage.NewNotFoundError(storage.BackendTypeEmbedded, address)
+	}
+	if err == nil {
+		err = e.localDAG.RemoveMany(ctx, cids)
+	}
+	if err == nil {
+		e.providing.log.remove(c)
+	}
+	if err != nil {
+		storageErr := storage.NewStorageError(storage.ErrCodeInvalidRequest, "failed to delete block", storage.BackendTypeEmbedded, err)
+		storageErr.Address = address
+		e.errorReporter.ReportError(storageErr)
+		return storageErr
+	}
+	return nil
+}
+
+// PutMany stores multiple blocks
+func (e *EmbeddedBackend) PutMany(ctx context.Context, blocks []*blocks.Block) ([]*storage.BlockAddress, error) {
+	addresses := make([]*storage.BlockAddress, len(blocks))
+	for i, block := range blocks {
+		address, err := e.Put(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store block %d: %w", i, err)
+		}
+		addresses[i] = address
+	}
+	return addresses, nil
+}
+
+// GetMany retrieves multiple blocks
+func (e *EmbeddedBackend) GetMany(ctx context.Context, addresses []*storage.BlockAddress) ([]*blocks.Block, error) {
+	result := make([]*blocks.Block, len(addresses))
+	for i, address := range addresses {
+		block, err := e.Get(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve block %d: %w", i, err)
+		}
+		result[i] = block
+	}
+	return result, nil
+}
+
+// Pin makes sure a block is stored locally, fetching it from peers if
+// needed. The embedded node never garbage collects, so local blocks stay
+// until they are deleted.
+func (e *EmbeddedBackend) Pin(ctx context.Context, address *storage.BlockAddress) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return e.notRunning()
+	}
+	c, err := e.parseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	if err := merkledag.FetchGraph(ctx, c, e.dag); err != nil {
+		storageErr := storage.NewStorageError(storage.ErrCodeNotFound, "failed to pin block", storage.BackendTypeEmbedded, err)
+		storageErr.Address = address
+		e.errorReporter.ReportError(storageErr)
+		return storageErr
+	}
+	return nil
+}
+
+// Unpin is a no-op: without garbage collection there is nothing to release.
+// Use Delete to remove a block.
+func (e *EmbeddedBackend) Unpin(ctx context.Context, address *storage.BlockAddress) error {
+	if !e.IsConnected() {
+		return e.notRunning()
+	}
+	_, err := e.parseAddress(address)
+	return err
+}
+
+// Provide schedules an announcement for a locally stored block, if the
+// provide strategy covers its role, and records it so that reprovides keep
+// announcing it. Blocks pinned from peers only get reprovided this way.
+func (e *EmbeddedBackend) Provide(ctx context.Context, address *storage.BlockAddress) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return e.notRunning()
+	}
+	c, err := e.parseAddress(address)
+	if err != nil {
+		return err
+	}
+	if has, err := e.blockstore.Has(ctx, c); err != nil || !has {
+		return storage.NewNotFoundError(storage.BackendTypeEmbedded, address)
+	}
+
+	role := storage.BlockRoleFromContext(ctx)
+	if err := e.providing.log.add(c, role); err != nil {
+		storageErr := storage.NewStorageError(storage.ErrCodeInvalidRequest, "failed to record provided block", storage.BackendTypeEmbedded, err)
+		e.errorReporter.ReportError(storageErr)
+		return storageErr
+	}
+	e.enqueueProvide(e.providing, c, role)
+	return nil
+}
+
+// GetBackendInfo returns information about the embedded node
+func (e *EmbeddedBackend) GetBackendInfo() *storage.BackendInfo {
+	info := &storage.BackendInfo{
+		Name:    "Embedded IPFS",
+		Type:    storage.BackendTypeEmbedded,
+		Version: "boxo",
+		Capabilities: []string{
+			storage.CapabilityContentAddress,
+			storage.CapabilityDistributed,
+			storage.CapabilityPinning,
+			storage.CapabilityDeduplication,
+		},
+		Config: map[string]interface{}{
+			"enabled":                e.config.Enabled,
+			"priority":               e.config.Priority,
+			"provide_strategy":       e.provider.strategy,
+			"provider_announcements": e.announcements.Load(),
+		},
+	}
+	for _, key := range []string{storage.EmbeddedSettingRepoPath, storage.EmbeddedSettingInMemory, storage.EmbeddedSettingListenAddrs} {
+		if value, ok := e.config.Settings[key]; ok {
+			info.Config[key] = value
+		}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.host != nil {
+		info.NetworkID = e.host.ID().String()
+		for _, p := range e.host.Network().Peers() {
+			info.Peers = append(info.Peers, p.String())
+		}
+	}
+	return info
+}
+
+// HealthCheck reports the node healthy while it runs. Without peers it is
+// degraded: local blocks work, but nothing can be fetched or shared.
+func (e *EmbeddedBackend) HealthCheck(ctx context.Context) *storage.HealthStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	now := time.Now()
+	if e.host == nil {
+		return &storage.HealthStatus{
+			Healthy:   false,
+			Status:    "offline",
+			LastCheck: now,
+		}
+	}
+
+	peers := len(e.host.Network().Peers())
+	status := &storage.HealthStatus{
+		Healthy:        true,
+		Status:         "healthy",
+		ConnectedPeers: peers,
+		NetworkHealth:  "connected",
+		LastCheck:      now,
+	}
+	if peers == 0 {
+		status.Status = "degraded"
+		status.NetworkHealth = "isolated"
+		status.Issues = append(status.Issues, storage.HealthIssue{
+			Severity:    "warning",
+			Code:        "NO_PEERS",
+			Description: "embedded node has no connected peers",
+			Timestamp:   now,
+		})
+	}
+	return status
+}
+
+// NetworkStats reports the node's peers, its Bitswap counters and the
+// latency of its DHT queries. Announcements count as queries; if there were
+// none yet, it looks up a well-known block to measure the DHT.
+func (e *EmbeddedBackend) NetworkStats(ctx context.Context) (*storage.NetworkStats, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.host == nil {
+		return nil, e.notRunning()
+	}
+	bitswap, err := e.bitswap.Stat()
+	if err != nil {
+		return nil, storage.NewStorageError(storage.ErrCodeInvalidRequest, "failed to read Bitswap stats", storage.BackendTypeEmbedded, err)
+	}
+
+	if e.dhtStats.snapshot().Queries == 0 {
+		e.probeDHT(ctx)
+	}
+
+	return &storage.NetworkStats{
+		Peers: len(e.host.Network().Peers()),
+		Bitswap: storage.BitswapStats{
+			BlocksReceived:    bitswap.BlocksReceived,
+			BlocksSent:        bitswap.BlocksSent,
+			DataReceived:      bitswap.DataReceived,
+			DataSent:          bitswap.DataSent,
+			DupBlocksReceived: bitswap.DupBlksReceived,
+			WantlistSize:      len(bitswap.Wantlist),
+		},
+		DHT: e.dhtStats.snapshot(),
+	}, nil
+}
+
+// probeDHT times the lookup of a well-known block's first provider.
+// Callers hold mu.
+func (e *EmbeddedBackend) probeDHT(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, providerSearchTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if _, found := <-e.dht.FindProvidersAsync(ctx, cid.MustParse(probeCID), 1); found {
+		e.dhtStats.record(time.Since(start), nil)
+	} else {
+		e.dhtStats.record(time.Since(start), errNoProviders)
+	}
+}
+
+// parseAddress decodes a block address. IPFS addresses are accepted too,
+// since both backends use the same CIDs.
+func (e *EmbeddedBackend) parseAddress(address *storage.BlockAddress) (cid.Cid, error) {
+	switch address.BackendType {
+	case "", storage.BackendTypeEmbedded, storage.BackendTypeIPFS:
+	default:
+		err := storage.NewInvalidRequestError(storage.BackendTypeEmbedded, "address is not for embedded backend", nil)
+		err.Address = address
+		return cid.Undef, err
+	}
+
+	c, err := cid.Decode(address.ID)
+	if err != nil {
+		storageErr := storage.NewInvalidRequestError(storage.BackendTypeEmbedded, "invalid CID", err)
+		storageErr.Address = address
+		return cid.Undef, storageErr
+	}
+	return c, nil
+}
+
+func (e *EmbeddedBackend) notRunning() error {
+	err := storage.NewStorageError(storage.ErrCodeBackendOffline, "embedded node is not running", storage.BackendTypeEmbedded, nil)
+	e.errorReporter.ReportError(err)
+	return err
+}
+
+// publish reports a state change on the event bus, if any
+func (e *EmbeddedBackend) publish(state storage.BackendState, err error) {
+	if e.events == nil {
+		return
+	}
+	event := storage.BackendEvent{
+		Backend: e.name,
+		Type:    storage.BackendTypeEmbedded,
+		State:   state,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	e.events.Publish(event)
+}
+
+// settingStrings reads a list of strings from backend settings, which may
+// hold []string or, when decoded from JSON, []interface{}. It returns nil
+// if the setting is absent.
+func settingStrings(settings map[string]interface{}, key string) ([]string, error) {
+	value, ok := settings[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("setting %s must be a list of strings", key)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("setting %s must be a list of strings", key)
+	}
+}
+
+// Ensure EmbeddedBackend implements all required interfaces
+var _ storage.Backend = (*EmbeddedBackend)(nil)
+var _ storage.EventEmitter = (*EmbeddedBackend)(nil)
+var _ storage.NetworkStatsReporter = (*EmbeddedBackend)(nil)
+
+// init registers the embedded backend constructor
+func init() {
+	storage.RegisterBackend(storage.BackendTypeEmbedded, func(config *storage.BackendConfig) (storage.Backend, error) {
+		return NewEmbeddedBackend(config)
+	})
+}
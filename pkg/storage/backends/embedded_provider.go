@@ -0,0 +1,299 @@
+package backends
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/boxo/blockstore"
+	"github.com/ipfs/go-cid"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// DefaultReprovideInterval repeats announcements before the DHT drops
+// provider records, which expire after 48 hours
+const DefaultReprovideInterval = 22 * time.Hour
+
+// provideQueueSize bounds announcements waiting for the rate limit. Blocks
+// that don't fit are announced with the next reprovide.
+const provideQueueSize = 1024
+
+// providerConfig controls which blocks the embedded node announces on the
+// DHT and how often
+type providerConfig struct {
+	strategy string
+	rate     int           // Announcements per minute, 0 for no limit
+	interval time.Duration // Between reprovides, 0 to announce only once
+}
+
+// parseProviderConfig reads the provide settings, which may come from Go
+// values or decoded JSON
+func parseProviderConfig(settings map[string]interface{}) (providerConfig, error) {
+	config := providerConfig{strategy: storage.ProvideStrategyAll, interval: DefaultReprovideInterval}
+
+	if value, ok := settings[storage.EmbeddedSettingProvideStrategy]; ok && value != nil {
+		strategy, ok := value.(string)
+		if !ok || !storage.ValidProvideStrategy(strategy) {
+			return config, fmt.Errorf("setting %s must be one of all, randomizers or none", storage.EmbeddedSettingProvideStrategy)
+		}
+		if strategy != "" {
+			config.strategy = strategy
+		}
+	}
+
+	switch value := settings[storage.EmbeddedSettingProvideRate].(type) {
+	case nil:
+	case int:
+		config.rate = value
+	case float64:
+		config.rate = int(value)
+	default:
+		return config, fmt.Errorf("setting %s must be a number", storage.EmbeddedSettingProvideRate)
+	}
+	if config.rate < 0 {
+		return config, fmt.Errorf("setting %s cannot be negative", storage.EmbeddedSettingProvideRate)
+	}
+
+	switch value := settings[storage.EmbeddedSettingReprovideInterval].(type) {
+	case nil:
+	case time.Duration:
+		config.interval = value
+	case string:
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("setting %s: %w", storage.EmbeddedSettingReprovideInterval, err)
+		}
+		config.interval = interval
+	default:
+		return config, fmt.Errorf("setting %s must be a duration", storage.EmbeddedSettingReprovideInterval)
+	}
+	if config.interval < 0 {
+		return config, fmt.Errorf("setting %s cannot be negative", storage.EmbeddedSettingReprovideInterval)
+	}
+
+	return config, nil
+}
+
+// provideLog remembers the role of every block stored through Put, so
+// reprovides follow the current strategy even after it changes. On disk
+// it is the repo's "provided" file with one "<cid> <role>" line per block.
+type provideLog struct {
+	mu    sync.Mutex
+	path  string // Empty for in-memory nodes
+	roles map[cid.Cid]string
+}
+
+// openProvideLog loads the log, dropping blocks that are no longer stored,
+// and rewrites it without them
+func openProvideLog(ctx context.Context, path string, bs blockstore.Blockstore) (*provideLog, error) {
+	log := &provideLog{path: path, roles: make(map[cid.Cid]string)}
+	if path == "" {
+		return log, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return log, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open provide log: %w", err)
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		c, err := cid.Decode(fields[0])
+		if err != nil {
+			continue
+		}
+		log.roles[c] = fields[1]
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read provide log: %w", err)
+	}
+
+	for c := range log.roles {
+		if has, err := bs.Has(ctx, c); err == nil && !has {
+			delete(log.roles, c)
+		}
+	}
+	if err := log.rewrite(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// rewrite replaces the file with the current entries
+func (l *provideLog) rewrite() error {
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write provide log: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	for c, role := range l.roles {
+		fmt.Fprintf(w, "%s %s\n", c, role)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write provide log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write provide log: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// add records a stored block. Blocks already recorded with the same role
+// are not written again.
+func (l *provideLog) add(c cid.Cid, role string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.roles[c] == role {
+		return nil
+	}
+	l.roles[c] = role
+	if l.path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(file, "%s %s\n", c, role)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// remove forgets a deleted block. The file keeps its line until the next
+// start, which drops blocks that are no longer stored.
+func (l *provideLog) remove(c cid.Cid) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.roles, c)
+}
+
+// snapshot returns a copy of the recorded blocks and their roles
+func (l *provideLog) snapshot() map[cid.Cid]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	roles := make(map[cid.Cid]string, len(l.roles))
+	for c, role := range l.roles {
+		roles[c] = role
+	}
+	return roles
+}
+
+// providerState is what the announcement loop works with. It is created
+// when the node starts and outlives Disconnect for the loop that uses it.
+type providerState struct {
+	dht        *dht.IpfsDHT
+	blockstore blockstore.Blockstore
+	log        *provideLog
+	queue      chan cid.Cid
+}
+
+// enqueueProvide schedules an announcement for a block stored under role,
+// if the strategy provides it. A full queue leaves the block to the next
+// reprovide.
+func (e *EmbeddedBackend) enqueueProvide(state *providerState, c cid.Cid, role string) {
+	if !storage.ShouldProvide(e.provider.strategy, role) {
+		return
+	}
+	select {
+	case state.queue <- c:
+	default:
+	}
+}
+
+// runProvider announces queued blocks and reprovides all recorded blocks
+// that match the strategy, first when the node starts and then every
+// reprovide interval, keeping to the rate limit throughout
+func (e *EmbeddedBackend) runProvider(ctx context.Context, state *providerState) {
+	var limit <-chan time.Time
+	if e.provider.rate > 0 {
+		ticker := time.NewTicker(time.Minute / time.Duration(e.provider.rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+	announce := func(c cid.Cid) bool {
+		if limit != nil {
+			select {
+			case <-limit:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		e.provide(ctx, state.dht, c)
+		return ctx.Err() == nil
+	}
+
+	var reprovide <-chan time.Time
+	if e.provider.interval > 0 {
+		ticker := time.NewTicker(e.provider.interval)
+		defer ticker.Stop()
+		reprovide = ticker.C
+	}
+
+	reprovideAll := func() bool {
+		for c, role := range state.log.snapshot() {
+			if !storage.ShouldProvide(e.provider.strategy, role) {
+				continue
+			}
+			if has, err := state.blockstore.Has(ctx, c); err == nil && !has {
+				state.log.remove(c)
+				continue
+			}
+			if !announce(c) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !reprovideAll() {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-state.queue:
+			if !announce(c) {
+				return
+			}
+		case <-reprovide:
+			if !reprovideAll() {
+				return
+			}
+		}
+	}
+}
+
+// provide announces that this node has a block. Failures are expected while
+// the DHT is still bootstrapping, and the block can still be found by peers
+// we are connected to.
+func (e *EmbeddedBackend) provide(ctx context.Context, kad *dht.IpfsDHT, c cid.Cid) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	start := time.Now()
+	err := kad.Provide(ctx, c, true)
+	e.dhtStats.record(time.Since(start), err)
+	if err == nil {
+		e.announcements.Add(1)
+	}
+}
@@ -0,0 +1,209 @@
+package backends
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// startEmbeddedNode starts an in-memory node on loopback that only joins
+// the given peers
+func startEmbeddedNode(t *testing.T, bootstrap ...string) *EmbeddedBackend {
+	t.Helper()
+	cfg := storage.DefaultEmbeddedBackendConfig("")
+	cfg.Settings[storage.EmbeddedSettingInMemory] = true
+	cfg.Settings[storage.EmbeddedSettingListenAddrs] = []string{"/ip4/127.0.0.1/tcp/0"}
+	cfg.Settings[storage.EmbeddedSettingBootstrapPeers] = append([]string{}, bootstrap...)
+
+	backend, err := NewEmbeddedBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewEmbeddedBackend failed: %v", err)
+	}
+	if err := backend.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { backend.Disconnect(context.Background()) })
+	return backend
+}
+
+func TestEmbeddedBackendExchangesBlocks(t *testing.T) {
+	ctx := context.Background()
+	first := startEmbeddedNode(t)
+	addr := first.host.Addrs()[0].String() + "/p2p/" + first.host.ID().String()
+	second := startEmbeddedNode(t, addr)
+
+	block, err := blocks.NewBlock([]byte("hello world\n"))
+	if err != nil {
+		t.Fatalf("NewBlock failed: %v", err)
+	}
+	address, err := first.Put(ctx, block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	// Same CID as `ipfs add` with default settings
+	if address.ID != "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o" {
+		t.Errorf("unexpected CID %s", address.ID)
+	}
+
+	if has, _ := second.Has(ctx, address); has {
+		t.Fatal("second node should not have the block before fetching it")
+	}
+	got, err := second.Get(ctx, address)
+	if err != nil {
+		t.Fatalf("Get from peer failed: %v", err)
+	}
+	if string(got.Data) != "hello world\n" {
+		t.Errorf("unexpected data %q", got.Data)
+	}
+	if has, _ := second.Has(ctx, address); !has {
+		t.Error("fetched block should be stored locally")
+	}
+	if stats, err := second.NetworkStats(ctx); err != nil || stats.Bitswap.BlocksReceived != 1 || stats.Peers == 0 {
+		t.Errorf("expected one block received from a peer, got %+v (%v)", stats, err)
+	}
+	// The sender counts a block once the message has gone out, which can
+	// be after it arrived
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, err := first.NetworkStats(ctx)
+		if err == nil && stats.Bitswap.BlocksSent == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("expected one block sent, got %+v (%v)", stats, err)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := second.Delete(ctx, address); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if has, _ := second.Has(ctx, address); has {
+		t.Error("deleted block is still stored")
+	}
+
+	if status := second.HealthCheck(ctx); !status.Healthy || status.ConnectedPeers == 0 {
+		t.Errorf("expected healthy node with peers, got %+v", status)
+	}
+}
+
+func TestEmbeddedBackendRejectsInvalidSettings(t *testing.T) {
+	cfg := storage.DefaultEmbeddedBackendConfig("")
+	cfg.Settings[storage.EmbeddedSettingBootstrapPeers] = "not-a-list"
+	if _, err := NewEmbeddedBackend(cfg); err == nil {
+		t.Error("expected an error for a bootstrap_peers string")
+	}
+}
+
+func TestEmbeddedBackendRecordsBlockRoles(t *testing.T) {
+	ctx := context.Background()
+	repo := t.TempDir()
+	cfg := storage.DefaultEmbeddedBackendConfig(repo)
+	cfg.Settings[storage.EmbeddedSettingListenAddrs] = []string{"/ip4/127.0.0.1/tcp/0"}
+	cfg.Settings[storage.EmbeddedSettingBootstrapPeers] = []string{}
+	cfg.Settings[storage.EmbeddedSettingProvideStrategy] = storage.ProvideStrategyRandomizers
+
+	backend, err := NewEmbeddedBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewEmbeddedBackend failed: %v", err)
+	}
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer backend.Disconnect(ctx)
+
+	data, _ := blocks.NewBlock([]byte("anonymized data"))
+	randomizer, _ := blocks.NewBlock([]byte("randomizer"))
+	dataAddress, err := backend.Put(ctx, data)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	randomizerAddress, err := backend.Put(storage.WithBlockRole(ctx, storage.BlockRoleRandomizer), randomizer)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	readLog := func() string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(repo, "provided"))
+		if err != nil {
+			t.Fatalf("failed to read provide log: %v", err)
+		}
+		return string(content)
+	}
+	content := readLog()
+	if !strings.Contains(content, dataAddress.ID+" data\n") || !strings.Contains(content, randomizerAddress.ID+" randomizer\n") {
+		t.Fatalf("expected both blocks with their roles, got %q", content)
+	}
+
+	// Deleted blocks are dropped from the log when the node restarts
+	if err := backend.Delete(ctx, dataAddress); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	backend.Disconnect(ctx)
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if content := readLog(); content != randomizerAddress.ID+" randomizer\n" {
+		t.Errorf("expected only the randomizer after restart, got %q", content)
+	}
+}
+
+func TestEmbeddedBackendProvideStrategy(t *testing.T) {
+	c := cid.MustParse("QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o")
+	tests := []struct {
+		strategy string
+		queued   int
+	}{
+		{storage.ProvideStrategyAll, 2},
+		{storage.ProvideStrategyRandomizers, 1},
+		{storage.ProvideStrategyNone, 0},
+	}
+	for _, tt := range tests {
+		backend := &EmbeddedBackend{provider: providerConfig{strategy: tt.strategy}}
+		state := &providerState{queue: make(chan cid.Cid, 2)}
+		backend.enqueueProvide(state, c, storage.BlockRoleData)
+		backend.enqueueProvide(state, c, storage.BlockRoleRandomizer)
+		if len(state.queue) != tt.queued {
+			t.Errorf("strategy %s: expected %d queued announcements, got %d", tt.strategy, tt.queued, len(state.queue))
+		}
+	}
+}
+
+func TestParseProviderConfig(t *testing.T) {
+	// Settings decoded from JSON
+	config, err := parseProviderConfig(map[string]interface{}{
+		storage.EmbeddedSettingProvideStrategy:   "randomizers",
+		storage.EmbeddedSettingProvideRate:       float64(30),
+		storage.EmbeddedSettingReprovideInterval: "12h",
+	})
+	if err != nil {
+		t.Fatalf("parseProviderConfig failed: %v", err)
+	}
+	if config.strategy != storage.ProvideStrategyRandomizers || config.rate != 30 || config.interval != 12*time.Hour {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	if config, _ := parseProviderConfig(nil); config.strategy != storage.ProvideStrategyAll || config.interval != DefaultReprovideInterval {
+		t.Errorf("unexpected defaults %+v", config)
+	}
+
+	for _, settings := range []map[string]interface{}{
+		{storage.EmbeddedSettingProvideStrategy: "popular"},
+		{storage.EmbeddedSettingProvideRate: -1},
+		{storage.EmbeddedSettingReprovideInterval: "soon"},
+	} {
+		if _, err := parseProviderConfig(settings); err == nil {
+			t.Errorf("expected an error for %v", settings)
+		}
+	}
+}
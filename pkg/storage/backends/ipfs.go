@@ -5,7 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	shell "github.com/ipfs/go-ipfs-api"
@@ -25,12 +28,21 @@ type IPFSBackend struct {
 	errorReporter   storage.ErrorReporter
 
 	// Connection state
-	connected   bool
+	connected   atomic.Bool
 	connectedAt time.Time
+	transport   *http.Transport // Pools connections to the daemon
+
+	// Keep-alive checks and reconnection
+	events        *storage.EventBus
+	name          string
+	wake          chan struct{} // Triggers an early check after connection errors
+	stopKeepAlive chan struct{}
+	keepAliveDone chan struct{}
 
 	// Performance tracking
 	requestMetrics map[peer.ID]*RequestMetrics
 	metricsLock    sync.RWMutex
+	dhtStats       dhtTracker
 
 	// Health monitoring
 	lastHealthCheck time.Time
@@ -59,6 +71,8 @@ func NewIPFSBackend(config *storage.BackendConfig) (*IPFSBackend, error) {
 		errorClassifier: storage.NewErrorClassifier(storage.BackendTypeIPFS),
 		errorReporter:   storage.NewDefaultErrorReporter(),
 		requestMetrics:  make(map[peer.ID]*RequestMetrics),
+		name:            storage.BackendTypeIPFS,
+		wake:            make(chan struct{}, 1),
 		healthStatus: &storage.HealthStatus{
 			Healthy:   false,
 			Status:    "disconnected",
@@ -71,32 +85,44 @@ func NewIPFSBackend(config *storage.BackendConfig) (*IPFSBackend, error) {
 
 // Connect establishes connection to IPFS node
 func (ipfs *IPFSBackend) Connect(ctx context.Context) error {
-	endpoint := ipfs.config.Connection.Endpoint
-	if endpoint == "" {
-		endpoint = "127.0.0.1:5001"
-	}
+	ipfs.stopKeepAliveLoop()
 
-	ipfs.shell = shell.NewShell(endpoint)
+	// Reuse connections to the daemon instead of dialing per request
+	client, transport, err := newIPFSHTTPClient(ipfs.config.Connection)
+	if err != nil {
+		storageErr := storage.NewInvalidRequestError(storage.BackendTypeIPFS, "invalid connection settings", err)
+		ipfs.reportError(storageErr)
+		return storageErr
+	}
+	ipfs.transport = transport
+	ipfs.shell = shell.NewShellWithClient(ipfsAPIURL(ipfs.config.Connection), client)
 
 	// Test connection
-	if _, err := ipfs.shell.ID(); err != nil {
+	if err := ipfs.ping(ctx); err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "connect", nil)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return storageErr
 	}
 
-	ipfs.connected = true
+	ipfs.connected.Store(true)
 	ipfs.connectedAt = time.Now()
 
 	// Update health status
 	ipfs.updateHealthStatus()
+	ipfs.publish(storage.BackendStateConnected, 0, nil)
 
+	ipfs.startKeepAliveLoop()
 	return nil
 }
 
 // Disconnect closes connection to IPFS node
 func (ipfs *IPFSBackend) Disconnect(ctx context.Context) error {
-	ipfs.connected = false
+	ipfs.stopKeepAliveLoop()
+
+	wasConnected := ipfs.connected.Swap(false)
+	if ipfs.transport != nil {
+		ipfs.transport.CloseIdleConnections()
+	}
 	ipfs.shell = nil
 
 	ipfs.healthLock.Lock()
@@ -104,19 +130,29 @@ func (ipfs *IPFSBackend) Disconnect(ctx context.Context) error {
 	ipfs.healthStatus.Status = "disconnected"
 	ipfs.healthLock.Unlock()
 
+	if wasConnected {
+		ipfs.publish(storage.BackendStateDisconnected, 0, nil)
+	}
 	return nil
 }
 
-// IsConnected returns true if connected to IPFS
+// IsConnected returns true if connected to IPFS. It is false while the
+// backend is reconnecting after losing the daemon.
 func (ipfs *IPFSBackend) IsConnected() bool {
-	return ipfs.connected && ipfs.shell != nil
+	return ipfs.connected.Load() && ipfs.shell != nil
+}
+
+// SetEventBus sets the bus that connection state changes are published on
+func (ipfs *IPFSBackend) SetEventBus(bus *storage.EventBus, name string) {
+	ipfs.events = bus
+	ipfs.name = name
 }
 
 // Put stores a block in IPFS and returns its address
 func (ipfs *IPFSBackend) Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error) {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return nil, err
 	}
 
@@ -125,7 +161,7 @@ func (ipfs *IPFSBackend) Put(ctx context.Context, block *blocks.Block) (*storage
 	cid, err := ipfs.shell.Add(reader)
 	if err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "put", nil)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return nil, storageErr
 	}
 
@@ -144,7 +180,7 @@ func (ipfs *IPFSBackend) Put(ctx context.Context, block *blocks.Block) (*storage
 func (ipfs *IPFSBackend) Get(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return nil, err
 	}
 
@@ -152,23 +188,22 @@ func (ipfs *IPFSBackend) Get(ctx context.Context, address *storage.BlockAddress)
 		err := storage.NewInvalidRequestError(storage.BackendTypeIPFS,
 			"address is not for IPFS backend", nil)
 		err.Address = address
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return nil, err
 	}
 
 
-	// Try intelligent peer selection if available
-	if ipfs.peerManager != nil {
-		if block, err := ipfs.getWithPeerSelection(ctx, address); err == nil {
-			return block, nil
-		}
+	// Prefer providers that served us well before
+	var block *blocks.Block
+	var err error
+	if ipfs.peerSelectionEnabled() {
+		block, err = ipfs.getWithPeerSelection(ctx, address)
+	} else {
+		block, err = ipfs.getStandard(address.ID)
 	}
-
-	// Fallback to standard IPFS retrieval
-	block, err := ipfs.getStandard(address.ID)
 	if err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "get", address)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return nil, storageErr
 	}
 
@@ -180,7 +215,7 @@ func (ipfs *IPFSBackend) Get(ctx context.Context, address *storage.BlockAddress)
 func (ipfs *IPFSBackend) Has(ctx context.Context, address *storage.BlockAddress) (bool, error) {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return false, err
 	}
 
@@ -196,7 +231,7 @@ func (ipfs *IPFSBackend) Has(ctx context.Context, address *storage.BlockAddress)
 			return false, nil
 		}
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "has", address)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return false, storageErr
 	}
 
@@ -207,7 +242,7 @@ func (ipfs *IPFSBackend) Has(ctx context.Context, address *storage.BlockAddress)
 func (ipfs *IPFSBackend) Delete(ctx context.Context, address *storage.BlockAddress) error {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
@@ -215,7 +250,7 @@ func (ipfs *IPFSBackend) Delete(ctx context.Context, address *storage.BlockAddre
 		err := storage.NewInvalidRequestError(storage.BackendTypeIPFS,
 			"address is not for IPFS backend", nil)
 		err.Address = address
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
@@ -223,7 +258,7 @@ func (ipfs *IPFSBackend) Delete(ctx context.Context, address *storage.BlockAddre
 	err := ipfs.shell.Unpin(address.ID)
 	if err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "delete", address)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return storageErr
 	}
 
@@ -272,7 +307,7 @@ func (ipfs *IPFSBackend) GetMany(ctx context.Context, addresses []*storage.Block
 func (ipfs *IPFSBackend) Pin(ctx context.Context, address *storage.BlockAddress) error {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
@@ -280,14 +315,14 @@ func (ipfs *IPFSBackend) Pin(ctx context.Context, address *storage.BlockAddress)
 		err := storage.NewInvalidRequestError(storage.BackendTypeIPFS,
 			"address is not for IPFS backend", nil)
 		err.Address = address
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
 	err := ipfs.shell.Pin(address.ID)
 	if err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "pin", address)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return storageErr
 	}
 
@@ -298,7 +333,7 @@ func (ipfs *IPFSBackend) Pin(ctx context.Context, address *storage.BlockAddress)
 func (ipfs *IPFSBackend) Unpin(ctx context.Context, address *storage.BlockAddress) error {
 	if !ipfs.IsConnected() {
 		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
@@ -306,20 +341,59 @@ func (ipfs *IPFSBackend) Unpin(ctx context.Context, address *storage.BlockAddres
 		err := storage.NewInvalidRequestError(storage.BackendTypeIPFS,
 			"address is not for IPFS backend", nil)
 		err.Address = address
-		ipfs.errorReporter.ReportError(err)
+		ipfs.reportError(err)
 		return err
 	}
 
 	err := ipfs.shell.Unpin(address.ID)
 	if err != nil {
 		storageErr := ipfs.errorClassifier.ClassifyError(err, "unpin", address)
-		ipfs.errorReporter.ReportError(storageErr)
+		ipfs.reportError(storageErr)
 		return storageErr
 	}
 
 	return nil
 }
 
+// ResolveName resolves an IPNS name or DNSLink domain to the CID it points to
+func (ipfs *IPFSBackend) ResolveName(ctx context.Context, name string) (string, error) {
+	if !ipfs.IsConnected() {
+		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
+		ipfs.reportError(err)
+		return "", err
+	}
+
+	var out struct{ Path string }
+	if err := ipfs.shell.Request("name/resolve", name).Exec(ctx, &out); err != nil {
+		storageErr := ipfs.errorClassifier.ClassifyError(err, "resolve", nil)
+		ipfs.reportError(storageErr)
+		return "", storageErr
+	}
+
+	resolved := strings.TrimPrefix(out.Path, "/ipfs/")
+	if i := strings.Index(resolved, "/"); i != -1 {
+		resolved = resolved[:i]
+	}
+	return resolved, nil
+}
+
+// Provide announces a block the IPFS node stores on the DHT. The node's
+// own Reprovider settings decide which blocks it keeps announcing.
+func (ipfs *IPFSBackend) Provide(ctx context.Context, address *storage.BlockAddress) error {
+	if !ipfs.IsConnected() {
+		err := storage.NewConnectionError(storage.BackendTypeIPFS, fmt.Errorf("not connected to IPFS"))
+		ipfs.reportError(err)
+		return err
+	}
+
+	if err := ipfs.shell.Request("routing/provide", address.ID).Exec(ctx, nil); err != nil {
+		storageErr := ipfs.errorClassifier.ClassifyError(err, "provide", address)
+		ipfs.reportError(storageErr)
+		return storageErr
+	}
+	return nil
+}
+
 // GetBackendInfo returns information about the IPFS backend
 func (ipfs *IPFSBackend) GetBackendInfo() *storage.BackendInfo {
 	info := &storage.BackendInfo{
@@ -334,9 +408,10 @@ func (ipfs *IPFSBackend) GetBackendInfo() *storage.BackendInfo {
 			storage.CapabilityDeduplication,
 		},
 		Config: map[string]interface{}{
-			"endpoint": ipfs.config.Connection.Endpoint,
-			"enabled":  ipfs.config.Enabled,
-			"priority": ipfs.config.Priority,
+			"endpoint":        ipfs.config.Connection.Endpoint,
+			"enabled":         ipfs.config.Enabled,
+			"priority":        ipfs.config.Priority,
+			"max_connections": ipfs.config.Connection.MaxConnections,
 		},
 	}
 
@@ -482,47 +557,6 @@ func (ipfs *IPFSBackend) getStandard(cid string) (*blocks.Block, error) {
 	return blocks.NewBlock(data)
 }
 
-func (ipfs *IPFSBackend) getWithPeerSelection(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
-	criteria := p2p.SelectionCriteria{
-		Count:          3,
-		RequiredBlocks: []string{address.ID},
-	}
-
-	selectedPeers, err := ipfs.peerManager.SelectPeers(ctx, "performance", criteria)
-	if err != nil {
-		return nil, err
-	}
-
-	// Try to retrieve from selected peers in parallel
-	type result struct {
-		block *blocks.Block
-		err   error
-	}
-
-	resultChan := make(chan result, len(selectedPeers))
-
-	for _, peerID := range selectedPeers {
-		go func(pid peer.ID) {
-			block, err := ipfs.requestFromPeer(ctx, address.ID, pid)
-			resultChan <- result{block: block, err: err}
-		}(peerID)
-	}
-
-	// Return the first successful result
-	for i := 0; i < len(selectedPeers); i++ {
-		select {
-		case res := <-resultChan:
-			if res.err == nil {
-				return res.block, nil
-			}
-		case <-time.After(5 * time.Second):
-			break
-		}
-	}
-
-	return nil, fmt.Errorf("failed to retrieve from all selected peers")
-}
-
 func (ipfs *IPFSBackend) requestFromPeer(ctx context.Context, cid string, peerID peer.ID) (*blocks.Block, error) {
 	start := time.Now()
 	
@@ -569,6 +603,7 @@ func (ipfs *IPFSBackend) updateRequestMetrics(peerID peer.ID, latency time.Durat
 
 	metrics, exists := ipfs.requestMetrics[peerID]
 	if !exists {
+		ipfs.prunePeerMetrics()
 		metrics = &RequestMetrics{}
 		ipfs.requestMetrics[peerID] = metrics
 	}
@@ -614,9 +649,133 @@ func (ipfs *IPFSBackend) updateHealthStatus() {
 	ipfs.healthStatus.LastCheck = time.Now()
 }
 
+// ping checks that the daemon answers within the connect timeout
+func (ipfs *IPFSBackend) ping(ctx context.Context) error {
+	if timeout := ipfs.config.Connection.ConnectTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var out struct{ ID string }
+	return ipfs.shell.Request("id").Exec(ctx, &out)
+}
+
+// reportError records an error and, when the daemon looks unreachable,
+// triggers an early keep-alive check instead of waiting for the next one
+func (ipfs *IPFSBackend) reportError(err *storage.StorageError) {
+	ipfs.errorReporter.ReportError(err)
+	if err.Code == storage.ErrCodeConnectionFailed && ipfs.connected.Load() {
+		select {
+		case ipfs.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ipfs *IPFSBackend) startKeepAliveLoop() {
+	if ipfs.config.Connection.KeepAliveInterval <= 0 {
+		return
+	}
+	ipfs.stopKeepAlive = make(chan struct{})
+	ipfs.keepAliveDone = make(chan struct{})
+	go ipfs.keepAliveLoop(ipfs.stopKeepAlive, ipfs.keepAliveDone)
+}
+
+func (ipfs *IPFSBackend) stopKeepAliveLoop() {
+	if ipfs.stopKeepAlive == nil {
+		return
+	}
+	close(ipfs.stopKeepAlive)
+	<-ipfs.keepAliveDone
+	ipfs.stopKeepAlive = nil
+	ipfs.keepAliveDone = nil
+}
+
+// keepAliveLoop checks the daemon periodically and reconnects when it
+// stops answering, for example because it was restarted
+func (ipfs *IPFSBackend) keepAliveLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(ipfs.config.Connection.KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-ipfs.wake:
+		}
+
+		err := ipfs.ping(context.Background())
+		if err == nil {
+			continue
+		}
+		if !ipfs.reconnect(stop, err) {
+			return
+		}
+	}
+}
+
+// reconnect marks the backend disconnected and retries with exponential
+// backoff until the daemon answers again. It returns false if stopped first.
+func (ipfs *IPFSBackend) reconnect(stop <-chan struct{}, cause error) bool {
+	ipfs.connected.Store(false)
+	ipfs.transport.CloseIdleConnections()
+	ipfs.updateHealthStatus()
+	ipfs.publish(storage.BackendStateDisconnected, 0, cause)
+
+	retry := ipfs.config.Retry
+	if retry == nil {
+		retry = &storage.RetryConfig{BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, Multiplier: 2, Jitter: true}
+	}
+
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(retry.Delay(attempt))
+		select {
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+
+		err := ipfs.ping(context.Background())
+		if err != nil {
+			ipfs.publish(storage.BackendStateReconnecting, attempt, err)
+			continue
+		}
+
+		ipfs.connected.Store(true)
+		ipfs.connectedAt = time.Now()
+		ipfs.updateHealthStatus()
+		ipfs.publish(storage.BackendStateConnected, attempt, nil)
+		return true
+	}
+}
+
+// publish reports a connection state change on the event bus, if any
+func (ipfs *IPFSBackend) publish(state storage.BackendState, attempt int, err error) {
+	if ipfs.events == nil {
+		return
+	}
+	event := storage.BackendEvent{
+		Backend: ipfs.name,
+		Type:    storage.BackendTypeIPFS,
+		State:   state,
+		Attempt: attempt,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	ipfs.events.Publish(event)
+}
+
 // Ensure IPFSBackend implements all required interfaces
 var _ storage.Backend = (*IPFSBackend)(nil)
 var _ storage.PeerAwareBackend = (*IPFSBackend)(nil)
+var _ storage.EventEmitter = (*IPFSBackend)(nil)
+var _ storage.PeerStatsReporter = (*IPFSBackend)(nil)
+var _ storage.NetworkStatsReporter = (*IPFSBackend)(nil)
 
 // init registers the IPFS backend constructor
 func init() {
@@ -0,0 +1,164 @@
+package backends
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	shell "github.com/ipfs/go-ipfs-api"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/network/proxy"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// NewIPFSShell creates an IPFS API client with the same connection pooling,
+// authentication and TLS settings as the storage backend. Use it for API
+// calls the backend doesn't cover, such as PubSub and the DHT.
+func NewIPFSShell(conn *storage.ConnectionConfig) (*shell.Shell, error) {
+	client, _, err := newIPFSHTTPClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	return shell.NewShellWithClient(ipfsAPIURL(conn), client), nil
+}
+
+// NewIPFSHTTPClient returns an HTTP client with the TLS and authentication
+// settings of an IPFS API connection, and the API endpoint, for requests
+// that need more than the shell offers, such as response headers
+func NewIPFSHTTPClient(conn *storage.ConnectionConfig) (*http.Client, string, error) {
+	client, _, err := newIPFSHTTPClient(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	return client, ipfsAPIURL(conn), nil
+}
+
+// ipfsAPIURL returns the API endpoint, defaulting to the local daemon.
+// Host:port endpoints use https when TLS is enabled.
+func ipfsAPIURL(conn *storage.ConnectionConfig) string {
+	endpoint := conn.Endpoint
+	if endpoint == "" {
+		endpoint = "127.0.0.1:5001"
+	}
+	isMultiaddr := strings.HasPrefix(endpoint, "/")
+	if conn.TLS != nil && conn.TLS.Enabled && !isMultiaddr && !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return endpoint
+}
+
+// newIPFSHTTPClient creates the HTTP client for an IPFS API endpoint. The
+// transport keeps up to MaxConnections idle connections for reuse and is
+// returned so callers can close them.
+func newIPFSHTTPClient(conn *storage.ConnectionConfig) (*http.Client, *http.Transport, error) {
+	if conn.Proxy != "" && strings.HasPrefix(conn.Endpoint, "/unix/") {
+		return nil, nil, fmt.Errorf("a proxy can't be used with the unix socket endpoint %s", conn.Endpoint)
+	}
+	proxyFunc, err := proxy.Func(conn.Proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// DialContext stays unset so the shell can dial unix socket endpoints
+	transport := &http.Transport{
+		Proxy:               proxyFunc,
+		MaxIdleConns:        conn.MaxConnections,
+		MaxIdleConnsPerHost: conn.MaxConnections,
+		IdleConnTimeout:     conn.IdleTimeout,
+	}
+
+	if conn.TLS != nil && conn.TLS.Enabled {
+		tlsConfig, err := newIPFSTLSConfig(conn.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	if conn.Auth == nil {
+		return &http.Client{Transport: transport}, transport, nil
+	}
+	headers, err := authHeaders(conn.Auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(headers) == 0 {
+		return &http.Client{Transport: transport}, transport, nil
+	}
+	return &http.Client{Transport: &headerTransport{base: transport, headers: headers}}, transport, nil
+}
+
+// newIPFSTLSConfig builds the client TLS settings: an optional CA bundle for
+// self-signed servers and an optional client certificate for mutual TLS
+func newIPFSTLSConfig(tc *storage.TLSConfig) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Explicit opt-in for test setups; never the default
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+
+	if tc.CAFile != "" {
+		caPEM, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", tc.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if tc.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// authHeaders returns the headers sent with every API request. Custom
+// headers are applied last, so they can override the defaults.
+func authHeaders(auth *storage.AuthConfig) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	switch auth.Type {
+	case "", "none":
+	case "basic":
+		credentials := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+		headers["Authorization"] = "Basic " + credentials
+	case "bearer", "oauth":
+		headers["Authorization"] = "Bearer " + auth.Token
+	case "api_key":
+		headers["X-API-Key"] = auth.APIKey
+	default:
+		return nil, fmt.Errorf("unsupported auth type '%s'", auth.Type)
+	}
+
+	for name, value := range auth.Headers {
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// headerTransport adds fixed headers to every request
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,365 @@
+package backends
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// fakeDaemon answers the IPFS id call unless it is down
+func fakeDaemon(down *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "daemon restarting", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ID": "12D3KooWTest"}`))
+	}))
+}
+
+func nextEvent(t *testing.T, events <-chan storage.BackendEvent, state storage.BackendState) storage.BackendEvent {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.State == state {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s event", state)
+		}
+	}
+}
+
+func TestIPFSBackendReconnects(t *testing.T) {
+	var down atomic.Bool
+	daemon := fakeDaemon(&down)
+	defer daemon.Close()
+
+	cfg := storage.DefaultConfig().Backends["ipfs"]
+	cfg.Connection.Endpoint = daemon.Listener.Addr().String()
+	cfg.Connection.KeepAliveInterval = 10 * time.Millisecond
+	cfg.Retry = &storage.RetryConfig{BaseDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond, Multiplier: 2}
+
+	backend, err := NewIPFSBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewIPFSBackend failed: %v", err)
+	}
+	bus := storage.NewEventBus()
+	events, unsubscribe := bus.Subscribe(64)
+	defer unsubscribe()
+	backend.SetEventBus(bus, "primary")
+
+	ctx := context.Background()
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer backend.Disconnect(ctx)
+	if event := nextEvent(t, events, storage.BackendStateConnected); event.Backend != "primary" {
+		t.Errorf("expected event for primary backend, got %+v", event)
+	}
+
+	// The daemon goes away: the backend notices and retries
+	down.Store(true)
+	event := nextEvent(t, events, storage.BackendStateDisconnected)
+	if event.Error == "" {
+		t.Error("expected disconnect event to carry the error")
+	}
+	nextEvent(t, events, storage.BackendStateReconnecting)
+	if backend.IsConnected() {
+		t.Error("expected backend to report disconnected while reconnecting")
+	}
+
+	// The daemon comes back
+	down.Store(false)
+	if event := nextEvent(t, events, storage.BackendStateConnected); event.Attempt == 0 {
+		t.Errorf("expected reconnect attempt to be reported, got %+v", event)
+	}
+	if !backend.IsConnected() {
+		t.Error("expected backend to be connected again")
+	}
+}
+
+func TestIPFSBackendAuthenticatedHTTPS(t *testing.T) {
+	daemon := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Project") != "noisefs" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ID": "12D3KooWTest"}`))
+	}))
+	defer daemon.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: daemon.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	newBackend := func(token string) *IPFSBackend {
+		cfg := storage.DefaultConfig().Backends["ipfs"]
+		cfg.Connection.Endpoint = daemon.Listener.Addr().String()
+		cfg.Connection.KeepAliveInterval = 0
+		cfg.Connection.TLS = &storage.TLSConfig{Enabled: true, CAFile: caFile}
+		cfg.Connection.Auth = &storage.AuthConfig{
+			Type:    "bearer",
+			Token:   token,
+			Headers: map[string]string{"X-Project": "noisefs"},
+		}
+		backend, err := NewIPFSBackend(cfg)
+		if err != nil {
+			t.Fatalf("NewIPFSBackend failed: %v", err)
+		}
+		return backend
+	}
+
+	ctx := context.Background()
+	backend := newBackend("s3cret")
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	backend.Disconnect(ctx)
+
+	if err := newBackend("wrong").Connect(ctx); err == nil {
+		t.Error("expected Connect with a wrong token to fail")
+	}
+
+	// Without the CA the self-signed certificate is rejected
+	backend = newBackend("s3cret")
+	backend.config.Connection.TLS.CAFile = ""
+	if err := backend.Connect(ctx); err == nil {
+		t.Error("expected Connect to reject an untrusted certificate")
+	}
+}
+
+func TestIPFSBackendConnectsThroughProxy(t *testing.T) {
+	// A plain HTTP proxy in front of a daemon only reachable by name
+	var proxied atomic.Int32
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "ipfs.example.com:5001" {
+			http.Error(w, "unknown host", http.StatusBadGateway)
+			return
+		}
+		proxied.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ID": "12D3KooWTest"}`))
+	}))
+	defer proxyServer.Close()
+
+	cfg := storage.DefaultConfig().Backends["ipfs"]
+	cfg.Connection.Endpoint = "ipfs.example.com:5001"
+	cfg.Connection.KeepAliveInterval = 0
+	cfg.Connection.Proxy = proxyServer.URL
+	backend, err := NewIPFSBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewIPFSBackend failed: %v", err)
+	}
+	ctx := context.Background()
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect through proxy failed: %v", err)
+	}
+	backend.Disconnect(ctx)
+	if proxied.Load() == 0 {
+		t.Error("expected the daemon to be reached through the proxy")
+	}
+
+	cfg.Connection.Endpoint = "/unix/run/ipfs.sock"
+	if _, err := NewIPFSShell(cfg.Connection); err == nil {
+		t.Error("expected a proxy with a unix socket endpoint to be rejected")
+	}
+}
+
+func newTestPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to derive peer ID: %v", err)
+	}
+	return id
+}
+
+func TestIPFSBackendPrefersReliableProviders(t *testing.T) {
+	flaky, reliable, fresh, unreachable := newTestPeerID(t), newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)
+
+	var mu sync.Mutex
+	var dialed []string
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rpcError := func(msg string) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"Message": "` + msg + `", "Code": 0, "Type": "error"}`))
+		}
+		switch r.URL.Path {
+		case "/api/v0/block/stat":
+			rpcError("block was not found locally (offline)")
+		case "/api/v0/routing/findprovs":
+			w.Header().Set("Content-Type", "application/json")
+			for _, id := range []peer.ID{flaky, reliable, fresh, unreachable} {
+				w.Write([]byte(`{"Type": 4, "Responses": [{"ID": "` + id.String() + `"}]}` + "\n"))
+			}
+		case "/api/v0/swarm/connect":
+			addr := r.URL.Query().Get("arg")
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+			if addr == "/p2p/"+unreachable.String() {
+				rpcError("failure: dial backoff")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Strings": ["connect ` + addr + ` success"]}`))
+		case "/api/v0/cat":
+			w.Write([]byte("block data"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ID": "12D3KooWTest"}`))
+		}
+	}))
+	defer daemon.Close()
+
+	cfg := storage.DefaultConfig().Backends["ipfs"]
+	cfg.Connection.Endpoint = daemon.Listener.Addr().String()
+	cfg.Connection.KeepAliveInterval = 0
+	backend, err := NewIPFSBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewIPFSBackend failed: %v", err)
+	}
+	ctx := context.Background()
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer backend.Disconnect(ctx)
+
+	for i := 0; i < 3; i++ {
+		backend.updateRequestMetrics(flaky, 100*time.Millisecond, false)
+	}
+	backend.updateRequestMetrics(reliable, 100*time.Millisecond, true)
+
+	block, err := backend.Get(ctx, &storage.BlockAddress{ID: "QmTest", BackendType: storage.BackendTypeIPFS})
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(block.Data) != "block data" {
+		t.Errorf("unexpected data %q", block.Data)
+	}
+
+	// Only the three best providers are dialed; the flaky one is skipped
+	if len(dialed) != preferredProviders {
+		t.Fatalf("expected %d dials, got %v", preferredProviders, dialed)
+	}
+	for _, addr := range dialed {
+		if addr == "/p2p/"+flaky.String() {
+			t.Error("flaky provider should rank below untried ones")
+		}
+	}
+
+	stats := make(map[string]storage.PeerStats)
+	for _, s := range backend.PeerStats() {
+		stats[s.PeerID] = s
+	}
+	if s := stats[reliable.String()]; s.Successes != 2 || s.Requests != 2 {
+		t.Errorf("expected two successes for reliable provider, got %+v", s)
+	}
+	if s := stats[fresh.String()]; s.Successes != 1 {
+		t.Errorf("expected fresh provider to be credited, got %+v", s)
+	}
+	if s := stats[unreachable.String()]; s.Failures != 1 || s.Successes != 0 {
+		t.Errorf("expected unreachable provider to be penalized, got %+v", s)
+	}
+	if last := backend.PeerStats()[len(stats)-1]; last.PeerID != flaky.String() {
+		t.Errorf("expected flaky provider to rank last, got %+v", last)
+	}
+}
+
+func TestRequestMetricsScore(t *testing.T) {
+	var untried *RequestMetrics
+	fast := &RequestMetrics{TotalRequests: 10, SuccessfulRequests: 10, AverageLatency: 50 * time.Millisecond}
+	slow := &RequestMetrics{TotalRequests: 10, SuccessfulRequests: 10, AverageLatency: 2 * time.Second}
+	failing := &RequestMetrics{TotalRequests: 10, FailedRequests: 10}
+
+	if !(fast.Score() > slow.Score()) {
+		t.Errorf("fast peer should outscore slow peer: %f <= %f", fast.Score(), slow.Score())
+	}
+	if !(slow.Score() > failing.Score()) {
+		t.Errorf("slow peer should outscore failing peer: %f <= %f", slow.Score(), failing.Score())
+	}
+	if !(untried.Score() > failing.Score()) {
+		t.Errorf("untried peer should outscore failing peer: %f <= %f", untried.Score(), failing.Score())
+	}
+}
+
+func TestIPFSBackendNetworkStats(t *testing.T) {
+	provider := newTestPeerID(t)
+	var probes atomic.Int32
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v0/stats/bitswap":
+			w.Write([]byte(`{"BlocksReceived": 12, "BlocksSent": 3, "DataReceived": 4096, "DataSent": 1024,
+				"DupBlksReceived": 2, "Wantlist": [{"/": "QmTest"}], "Peers": []}`))
+		case "/api/v0/swarm/peers":
+			w.Write([]byte(`{"Peers": [{"Addr": "/ip4/10.0.0.1/tcp/4001", "Peer": "` + provider.String() + `"}]}`))
+		case "/api/v0/routing/findprovs":
+			probes.Add(1)
+			w.Write([]byte(`{"Type": 4, "Responses": [{"ID": "` + provider.String() + `"}]}` + "\n"))
+		default:
+			w.Write([]byte(`{"ID": "12D3KooWTest"}`))
+		}
+	}))
+	defer daemon.Close()
+
+	cfg := storage.DefaultConfig().Backends["ipfs"]
+	cfg.Connection.Endpoint = daemon.Listener.Addr().String()
+	cfg.Connection.KeepAliveInterval = 0
+	backend, err := NewIPFSBackend(cfg)
+	if err != nil {
+		t.Fatalf("NewIPFSBackend failed: %v", err)
+	}
+	ctx := context.Background()
+	if err := backend.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer backend.Disconnect(ctx)
+
+	stats, err := backend.NetworkStats(ctx)
+	if err != nil {
+		t.Fatalf("NetworkStats failed: %v", err)
+	}
+	if stats.Peers != 1 {
+		t.Errorf("expected 1 peer, got %d", stats.Peers)
+	}
+	want := storage.BitswapStats{BlocksReceived: 12, BlocksSent: 3, DataReceived: 4096, DataSent: 1024, DupBlocksReceived: 2, WantlistSize: 1}
+	if stats.Bitswap != want {
+		t.Errorf("expected %+v, got %+v", want, stats.Bitswap)
+	}
+	if stats.DHT.Queries != 1 || stats.DHT.Failures != 0 || stats.DHT.AverageLatency == 0 {
+		t.Errorf("expected one timed DHT probe, got %+v", stats.DHT)
+	}
+
+	// Once the DHT has been queried, stats don't probe again
+	if _, err := backend.NetworkStats(ctx); err != nil {
+		t.Fatalf("NetworkStats failed: %v", err)
+	}
+	if probes.Load() != 1 {
+		t.Errorf("expected a single probe, got %d", probes.Load())
+	}
+}
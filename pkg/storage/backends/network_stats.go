@@ -0,0 +1,91 @@
+package backends
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// probeCID is the empty UnixFS directory, which many IPFS nodes provide.
+// Looking it up measures DHT latency when a backend hasn't queried the DHT
+// yet, for example in a short-lived CLI process.
+const probeCID = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+
+// errNoProviders is recorded for DHT queries that found nobody
+var errNoProviders = errors.New("no providers found")
+
+// dhtTracker records the latency of the DHT queries a backend makes
+type dhtTracker struct {
+	mu    sync.Mutex
+	stats storage.DHTStats
+}
+
+// record adds a query. Failed queries count but don't affect the latency.
+func (t *dhtTracker) record(latency time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Queries++
+	if err != nil {
+		t.stats.Failures++
+		return
+	}
+	t.stats.LastLatency = latency
+	if t.stats.AverageLatency == 0 {
+		t.stats.AverageLatency = latency
+	} else {
+		// Exponential moving average, like the per-peer request metrics
+		alpha := 0.1
+		t.stats.AverageLatency = time.Duration(
+			float64(t.stats.AverageLatency)*(1-alpha) + float64(latency)*alpha,
+		)
+	}
+}
+
+// snapshot returns the statistics so far
+func (t *dhtTracker) snapshot() storage.DHTStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// NetworkStats reports the daemon's peers and Bitswap counters, and the
+// latency of the provider lookups made through it. If there were none yet,
+// it looks up a well-known block to measure the DHT.
+func (ipfs *IPFSBackend) NetworkStats(ctx context.Context) (*storage.NetworkStats, error) {
+	if !ipfs.IsConnected() {
+		return nil, storage.NewConnectionError(storage.BackendTypeIPFS, errors.New("not connected to IPFS"))
+	}
+
+	var bitswap struct {
+		BlocksReceived  uint64
+		BlocksSent      uint64
+		DataReceived    uint64
+		DataSent        uint64
+		DupBlksReceived uint64
+		Wantlist        []interface{}
+	}
+	if err := ipfs.shell.Request("stats/bitswap").Exec(ctx, &bitswap); err != nil {
+		return nil, ipfs.errorClassifier.ClassifyError(err, "network_stats", nil)
+	}
+
+	if ipfs.dhtStats.snapshot().Queries == 0 {
+		ipfs.findProviders(ctx, probeCID)
+	}
+
+	return &storage.NetworkStats{
+		Peers: len(ipfs.getConnectedPeers()),
+		Bitswap: storage.BitswapStats{
+			BlocksReceived:    bitswap.BlocksReceived,
+			BlocksSent:        bitswap.BlocksSent,
+			DataReceived:      bitswap.DataReceived,
+			DataSent:          bitswap.DataSent,
+			DupBlocksReceived: bitswap.DupBlksReceived,
+			WantlistSize:      len(bitswap.Wantlist),
+		},
+		DHT: ipfs.dhtStats.snapshot(),
+	}, nil
+}
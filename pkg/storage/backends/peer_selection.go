@@ -0,0 +1,246 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+const (
+	// preferredProviders is how many of the best-ranked providers the
+	// daemon is connected to before fetching a block
+	preferredProviders = 3
+
+	// maxProviders bounds the provider search
+	maxProviders = 20
+
+	// providerSearchTimeout bounds the time spent finding providers. The
+	// daemon keeps searching on its own if the hinted peers don't help.
+	providerSearchTimeout = 2 * time.Second
+
+	// referenceLatency is the latency at which a peer's speed halves its
+	// score
+	referenceLatency = 500 * time.Millisecond
+
+	// maxTrackedPeers caps the metrics table; the least recently used
+	// peers are dropped first
+	maxTrackedPeers = 1000
+)
+
+// routingQueryProvider is the routing/findprovs message type that carries
+// provider records
+const routingQueryProvider = 4
+
+// peerSelectionEnabled reports whether Get should rank and hint providers.
+// It is on unless the peer_selection setting is false.
+func (ipfs *IPFSBackend) peerSelectionEnabled() bool {
+	enabled, ok := ipfs.config.Settings[storage.IPFSSettingPeerSelection].(bool)
+	return !ok || enabled
+}
+
+// getWithPeerSelection fetches a block that is not stored locally after
+// connecting the daemon to the providers that served us best before.
+// Bitswap asks connected peers first, so this steers retrieval towards
+// them without bypassing the daemon. The API doesn't tell which peer sent
+// the block, so the outcome counts for every hinted peer.
+func (ipfs *IPFSBackend) getWithPeerSelection(ctx context.Context, address *storage.BlockAddress) (*blocks.Block, error) {
+	if ipfs.hasLocal(ctx, address.ID) {
+		return ipfs.getStandard(address.ID)
+	}
+
+	preferred := ipfs.rankPeers(ipfs.findProviders(ctx, address.ID))
+	if len(preferred) > preferredProviders {
+		preferred = preferred[:preferredProviders]
+	}
+	hinted := ipfs.connectPeers(ctx, preferred)
+
+	start := time.Now()
+	block, err := ipfs.getStandard(address.ID)
+	for _, peerID := range hinted {
+		ipfs.updateRequestMetrics(peerID, time.Since(start), err == nil)
+	}
+	return block, err
+}
+
+// hasLocal checks whether the daemon has a block without searching the
+// network
+func (ipfs *IPFSBackend) hasLocal(ctx context.Context, cid string) bool {
+	var out struct{ Key string }
+	err := ipfs.shell.Request("block/stat", cid).Option("offline", true).Exec(ctx, &out)
+	return err == nil
+}
+
+// findProviders asks the daemon's content routing for peers that have a
+// block, stopping after maxProviders or providerSearchTimeout. The time to
+// the first provider is recorded as the DHT query latency.
+func (ipfs *IPFSBackend) findProviders(ctx context.Context, cid string) []peer.ID {
+	ctx, cancel := context.WithTimeout(ctx, providerSearchTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := ipfs.shell.Request("routing/findprovs", cid).
+		Option("num-providers", maxProviders).
+		Send(ctx)
+	if err == nil && resp.Error != nil {
+		resp.Close()
+		err = resp.Error
+	}
+	if err != nil {
+		ipfs.dhtStats.record(time.Since(start), err)
+		return nil
+	}
+	defer resp.Close()
+
+	var providers []peer.ID
+	seen := make(map[peer.ID]bool)
+	decoder := json.NewDecoder(resp.Output)
+	for len(providers) < maxProviders {
+		var event struct {
+			Type      int
+			Responses []struct{ ID string }
+		}
+		if err := decoder.Decode(&event); err != nil {
+			// The search ended or timed out; keep what we found
+			break
+		}
+		if event.Type != routingQueryProvider {
+			continue
+		}
+		for _, response := range event.Responses {
+			peerID, err := peer.Decode(response.ID)
+			if err != nil || seen[peerID] {
+				continue
+			}
+			if len(providers) == 0 {
+				ipfs.dhtStats.record(time.Since(start), nil)
+			}
+			seen[peerID] = true
+			providers = append(providers, peerID)
+		}
+	}
+	if len(providers) == 0 {
+		ipfs.dhtStats.record(time.Since(start), errNoProviders)
+	}
+	return providers
+}
+
+// connectPeers connects the daemon to peers in parallel and returns the
+// ones it reached. Failed connections count against the peer.
+func (ipfs *IPFSBackend) connectPeers(ctx context.Context, peers []peer.ID) []peer.ID {
+	if timeout := ipfs.config.Connection.ConnectTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	reached := make([]bool, len(peers))
+	var wg sync.WaitGroup
+	for i, peerID := range peers {
+		wg.Add(1)
+		go func(i int, peerID peer.ID) {
+			defer wg.Done()
+			start := time.Now()
+			if err := ipfs.shell.SwarmConnect(ctx, "/p2p/"+peerID.String()); err != nil {
+				ipfs.updateRequestMetrics(peerID, time.Since(start), false)
+				return
+			}
+			reached[i] = true
+		}(i, peerID)
+	}
+	wg.Wait()
+
+	var connected []peer.ID
+	for i, ok := range reached {
+		if ok {
+			connected = append(connected, peers[i])
+		}
+	}
+	return connected
+}
+
+// rankPeers orders peers by score, best first. Peers with equal scores
+// keep their order, so untried providers stay in routing order.
+func (ipfs *IPFSBackend) rankPeers(peers []peer.ID) []peer.ID {
+	ipfs.metricsLock.RLock()
+	scores := make(map[peer.ID]float64, len(peers))
+	for _, peerID := range peers {
+		scores[peerID] = ipfs.requestMetrics[peerID].Score()
+	}
+	ipfs.metricsLock.RUnlock()
+
+	ranked := append([]peer.ID(nil), peers...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	return ranked
+}
+
+// Score rates a peer from 0 to 1 by its success rate and latency. The
+// success rate starts at one half for untried peers and moves towards the
+// observed rate as requests complete; latency halves the score at
+// referenceLatency. A nil receiver is an untried peer.
+func (m *RequestMetrics) Score() float64 {
+	if m == nil {
+		return 0.25
+	}
+	successRate := float64(m.SuccessfulRequests+1) / float64(m.TotalRequests+2)
+	latency := m.AverageLatency
+	if latency == 0 {
+		latency = referenceLatency
+	}
+	return successRate / (1 + float64(latency)/float64(referenceLatency))
+}
+
+// PeerStats returns the request history of every tracked peer, best
+// scored first
+func (ipfs *IPFSBackend) PeerStats() []storage.PeerStats {
+	ipfs.metricsLock.RLock()
+	defer ipfs.metricsLock.RUnlock()
+
+	stats := make([]storage.PeerStats, 0, len(ipfs.requestMetrics))
+	for peerID, m := range ipfs.requestMetrics {
+		var successRate float64
+		if m.TotalRequests > 0 {
+			successRate = float64(m.SuccessfulRequests) / float64(m.TotalRequests)
+		}
+		stats = append(stats, storage.PeerStats{
+			PeerID:         peerID.String(),
+			Requests:       m.TotalRequests,
+			Successes:      m.SuccessfulRequests,
+			Failures:       m.FailedRequests,
+			SuccessRate:    successRate,
+			AverageLatency: m.AverageLatency,
+			LastRequest:    m.LastRequest,
+			Score:          m.Score(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Score != stats[j].Score {
+			return stats[i].Score > stats[j].Score
+		}
+		return stats[i].PeerID < stats[j].PeerID
+	})
+	return stats
+}
+
+// prunePeerMetrics makes room for a new peer by dropping the least
+// recently used ones once the table is full. Callers hold metricsLock.
+func (ipfs *IPFSBackend) prunePeerMetrics() {
+	for len(ipfs.requestMetrics) >= maxTrackedPeers {
+		var oldest peer.ID
+		var oldestTime time.Time
+		for peerID, m := range ipfs.requestMetrics {
+			if oldest == "" || m.LastRequest.Before(oldestTime) {
+				oldest, oldestTime = peerID, m.LastRequest
+			}
+		}
+		delete(ipfs.requestMetrics, oldest)
+	}
+}
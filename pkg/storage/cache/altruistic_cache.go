@@ -426,6 +426,11 @@ func (ac *AltruisticCache) GetRandomizers(count int) ([]*BlockInfo, error) {
 	return ac.baseCache.GetRandomizers(count)
 }
 
+// GetRandomizersOfSize returns popular blocks of one size suitable as randomizers
+func (ac *AltruisticCache) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	return GetRandomizersOfSize(ac.baseCache, size, count)
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (ac *AltruisticCache) IncrementPopularity(cid string) error {
 	return ac.baseCache.IncrementPopularity(cid)
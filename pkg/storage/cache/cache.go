@@ -36,6 +36,38 @@ type Cache interface {
 	GetStats() *Stats
 }
 
+// SizedRandomizerProvider is implemented by caches that can select
+// randomizers of one block size directly. Randomizers must match the size
+// of the block they anonymize, so each block size forms its own pool.
+type SizedRandomizerProvider interface {
+	// GetRandomizersOfSize returns up to count popular blocks of the given size
+	GetRandomizersOfSize(size, count int) ([]*BlockInfo, error)
+}
+
+// GetRandomizersOfSize returns up to count popular blocks of the given size
+// from any cache, filtering all of its randomizers when it doesn't
+// implement SizedRandomizerProvider
+func GetRandomizersOfSize(c Cache, size, count int) ([]*BlockInfo, error) {
+	if sized, ok := c.(SizedRandomizerProvider); ok {
+		return sized.GetRandomizersOfSize(size, count)
+	}
+
+	candidates, err := c.GetRandomizers(c.Size())
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]*BlockInfo, 0, count)
+	for _, info := range candidates {
+		if info.Size == size {
+			matching = append(matching, info)
+			if len(matching) == count {
+				break
+			}
+		}
+	}
+	return matching, nil
+}
+
 // BlockInfo contains block metadata for cache management
 type BlockInfo struct {
 	CID        string
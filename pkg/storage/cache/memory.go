@@ -2,6 +2,7 @@ package cache
 
 import (
 	"container/list"
+	"sort"
 	"sync"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
@@ -143,6 +144,34 @@ func (c *MemoryCache) GetRandomizers(count int) ([]*BlockInfo, error) {
 	return blockInfos[:count], nil
 }
 
+// GetRandomizersOfSize returns the most popular blocks of one size
+func (c *MemoryCache) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	blockInfos := make([]*BlockInfo, 0)
+	for cid, entry := range c.blocks {
+		if entry.block.Size() != size {
+			continue
+		}
+		blockInfos = append(blockInfos, &BlockInfo{
+			CID:        cid,
+			Block:      entry.block,
+			Size:       size,
+			Popularity: c.popularityMap[cid],
+		})
+	}
+
+	sort.Slice(blockInfos, func(i, j int) bool {
+		return blockInfos[i].Popularity > blockInfos[j].Popularity
+	})
+
+	if count > len(blockInfos) {
+		count = len(blockInfos)
+	}
+	return blockInfos[:count], nil
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (c *MemoryCache) IncrementPopularity(cid string) error {
 	c.mu.Lock()
@@ -164,6 +193,18 @@ func (c *MemoryCache) Size() int {
 	return len(c.blocks)
 }
 
+// SetCapacity changes the maximum number of cached blocks, evicting the
+// least recently used blocks when the cache shrinks
+func (c *MemoryCache) SetCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.capacity = capacity
+	for capacity > 0 && len(c.blocks) > capacity {
+		c.evictOldest()
+	}
+}
+
 // Clear removes all blocks from the cache
 func (c *MemoryCache) Clear() {
 	c.mu.Lock()
@@ -370,6 +370,44 @@ func TestMemoryCacheGetRandomizers(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheGetRandomizersOfSize(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	for i, size := range []int{32, 64, 32, 64, 64} {
+		block, err := blocks.NewBlock(make([]byte, size))
+		if err != nil {
+			t.Fatalf("Failed to create block %d: %v", i, err)
+		}
+		if err := cache.Store(fmt.Sprintf("cid%d", i), block); err != nil {
+			t.Fatalf("Failed to store block %d: %v", i, err)
+		}
+	}
+	cache.IncrementPopularity("cid4")
+
+	randomizers, err := cache.GetRandomizersOfSize(64, 10)
+	if err != nil {
+		t.Fatalf("GetRandomizersOfSize() error = %v, want nil", err)
+	}
+	if len(randomizers) != 3 {
+		t.Fatalf("GetRandomizersOfSize() returned %d blocks, want 3", len(randomizers))
+	}
+	if randomizers[0].CID != "cid4" {
+		t.Errorf("GetRandomizersOfSize() first block = %s, want most popular cid4", randomizers[0].CID)
+	}
+	for _, info := range randomizers {
+		if info.Size != 64 {
+			t.Errorf("GetRandomizersOfSize() returned block of size %d, want 64", info.Size)
+		}
+	}
+
+	// Caches without sized pools are filtered
+	plain := struct{ Cache }{cache}
+	randomizers, err = GetRandomizersOfSize(plain, 32, 1)
+	if err != nil || len(randomizers) != 1 || randomizers[0].Size != 32 {
+		t.Errorf("GetRandomizersOfSize() on plain cache = %v, %v", randomizers, err)
+	}
+}
+
 func TestMemoryCacheLRUOrdering(t *testing.T) {
 	cache := NewMemoryCache(3)
 
@@ -436,3 +474,32 @@ func TestMemoryCacheZeroCapacity(t *testing.T) {
 		t.Errorf("Zero capacity cache size = %v, want 100", cache.Size())
 	}
 }
+
+func TestMemoryCacheSetCapacity(t *testing.T) {
+	cache := NewMemoryCache(5)
+
+	block, err := blocks.NewBlock([]byte("test data"))
+	if err != nil {
+		t.Fatalf("Failed to create block: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		cache.Store(fmt.Sprintf("cid%d", i), block)
+	}
+
+	// Shrinking evicts the least recently used blocks
+	cache.SetCapacity(2)
+	if cache.Size() != 2 {
+		t.Errorf("Size after shrinking = %v, want 2", cache.Size())
+	}
+	if !cache.Has("cid3") || !cache.Has("cid4") {
+		t.Error("Most recently used blocks should survive shrinking")
+	}
+
+	// Growing allows more blocks without evicting
+	cache.SetCapacity(4)
+	cache.Store("cid5", block)
+	cache.Store("cid6", block)
+	if cache.Size() != 4 {
+		t.Errorf("Size after growing = %v, want 4", cache.Size())
+	}
+}
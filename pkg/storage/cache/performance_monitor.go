@@ -141,6 +141,11 @@ func (pm *PerformanceMonitor) GetRandomizers(count int) ([]*BlockInfo, error) {
 	return pm.underlying.GetRandomizers(count)
 }
 
+// GetRandomizersOfSize returns popular blocks of one size suitable as randomizers
+func (pm *PerformanceMonitor) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	return GetRandomizersOfSize(pm.underlying, size, count)
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (pm *PerformanceMonitor) IncrementPopularity(cid string) error {
 	return pm.underlying.IncrementPopularity(cid)
@@ -439,6 +439,11 @@ func (c *SampledStatisticsCache) GetRandomizers(count int) ([]*BlockInfo, error)
 	return c.underlying.GetRandomizers(count)
 }
 
+// GetRandomizersOfSize returns popular blocks of one size suitable as randomizers
+func (c *SampledStatisticsCache) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	return GetRandomizersOfSize(c.underlying, size, count)
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (c *SampledStatisticsCache) IncrementPopularity(cid string) error {
 	return c.underlying.IncrementPopularity(cid)
@@ -392,6 +392,11 @@ func (c *StatisticsCache) GetRandomizers(count int) ([]*BlockInfo, error) {
 	return c.underlying.GetRandomizers(count)
 }
 
+// GetRandomizersOfSize returns popular blocks of one size suitable as randomizers
+func (c *StatisticsCache) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	return GetRandomizersOfSize(c.underlying, size, count)
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (c *StatisticsCache) IncrementPopularity(cid string) error {
 	return c.underlying.IncrementPopularity(cid)
@@ -200,6 +200,11 @@ func (c *WriteBackCache) GetRandomizers(count int) ([]*BlockInfo, error) {
 	return c.underlying.GetRandomizers(count)
 }
 
+// GetRandomizersOfSize returns popular blocks of one size suitable as randomizers
+func (c *WriteBackCache) GetRandomizersOfSize(size, count int) ([]*BlockInfo, error) {
+	return GetRandomizersOfSize(c.underlying, size, count)
+}
+
 // IncrementPopularity increases the popularity score of a block
 func (c *WriteBackCache) IncrementPopularity(cid string) error {
 	return c.underlying.IncrementPopularity(cid)
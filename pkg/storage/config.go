@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"math/rand"
 	"time"
 )
 
@@ -21,6 +22,9 @@ type Config struct {
 
 	// Performance tuning
 	Performance *PerformanceConfig `json:"performance" yaml:"performance"`
+
+	// Spooling of blocks stored while no backend is reachable
+	Offline *OfflineConfig `json:"offline,omitempty" yaml:"offline,omitempty"`
 }
 
 // BackendConfig represents configuration for a specific storage backend
@@ -60,8 +64,17 @@ type ConnectionConfig struct {
 	IdleTimeout    time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
 	ConnectTimeout time.Duration `json:"connect_timeout" yaml:"connect_timeout"`
 
+	// How often the backend checks it can still reach the service; a failed
+	// check starts reconnecting with backoff from the retry settings. Zero
+	// disables the checks.
+	KeepAliveInterval time.Duration `json:"keepalive_interval" yaml:"keepalive_interval"`
+
 	// TLS/Security settings
 	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+
+	// SOCKS5 or HTTP CONNECT proxy URL for connections to remote endpoints,
+	// such as socks5h://127.0.0.1:9050 for Tor
+	Proxy string `json:"proxy,omitempty" yaml:"proxy,omitempty"`
 }
 
 // AuthConfig represents authentication configuration
@@ -156,6 +169,22 @@ type HealthCheckConfig struct {
 	Timeout time.Duration `json:"timeout" yaml:"timeout"`
 }
 
+// OfflineConfig represents offline spooling configuration
+type OfflineConfig struct {
+	// Spool blocks to disk while no backend is reachable
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Directory the blocks are spooled to
+	SpoolPath string `json:"spool_path" yaml:"spool_path"`
+
+	// Most bytes spooled at once; 0 for no limit
+	MaxSize int64 `json:"max_size" yaml:"max_size"`
+
+	// How often unreachable backends are retried and the spool flushed;
+	// default 30s
+	RetryInterval time.Duration `json:"retry_interval" yaml:"retry_interval"`
+}
+
 // PerformanceConfig represents performance tuning configuration
 type PerformanceConfig struct {
 	// Concurrency limits
@@ -195,6 +224,70 @@ type CompressionConfig struct {
 	Level     int    `json:"level" yaml:"level"`
 }
 
+// Settings for the IPFS backend, in BackendConfig.Settings
+const (
+	IPFSSettingPeerSelection = "peer_selection" // Rank and connect providers before fetching, default true
+)
+
+// Settings for the embedded backend, in BackendConfig.Settings
+const (
+	EmbeddedSettingRepoPath       = "repo_path"       // Directory for blocks and the node key
+	EmbeddedSettingInMemory       = "in_memory"       // Keep blocks and key in memory only
+	EmbeddedSettingListenAddrs    = "listen_addrs"    // libp2p listen multiaddrs
+	EmbeddedSettingBootstrapPeers = "bootstrap_peers" // Peer multiaddrs, public bootstrap nodes if unset
+
+	EmbeddedSettingProvideStrategy   = "provide_strategy"   // Blocks to announce: all, randomizers or none
+	EmbeddedSettingProvideRate       = "provide_rate"       // Announcements per minute, 0 for no limit
+	EmbeddedSettingReprovideInterval = "reprovide_interval" // Duration between reprovides, 0 to announce once
+)
+
+// DefaultEmbeddedBackendConfig returns the configuration for an embedded
+// IPFS node storing its blocks under repoPath. An empty path uses
+// ~/.noisefs/embedded.
+func DefaultEmbeddedBackendConfig(repoPath string) *BackendConfig {
+	return &BackendConfig{
+		Type:     BackendTypeEmbedded,
+		Enabled:  true,
+		Priority: 100,
+		Settings: map[string]interface{}{
+			EmbeddedSettingRepoPath: repoPath,
+		},
+		Retry: &RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   100 * time.Millisecond,
+			MaxDelay:    5 * time.Second,
+			Multiplier:  2.0,
+			Jitter:      true,
+		},
+		Timeouts: &TimeoutConfig{
+			Connect:   10 * time.Second,
+			Read:      30 * time.Second,
+			Write:     30 * time.Second,
+			Operation: 60 * time.Second,
+		},
+	}
+}
+
+// Settings for the custom backend, in BackendConfig.Settings
+const (
+	CustomSettingBackend = "backend" // The Backend instance to use
+)
+
+// CustomBackendConfig returns the configuration for a backend the
+// application created itself. The backend must report the
+// CapabilityContentAddress capability, as NoiseFS addresses blocks by
+// their content.
+func CustomBackendConfig(backend Backend) *BackendConfig {
+	return &BackendConfig{
+		Type:     BackendTypeCustom,
+		Enabled:  true,
+		Priority: 100,
+		Settings: map[string]interface{}{
+			CustomSettingBackend: backend,
+		},
+	}
+}
+
 // DefaultConfig returns a default storage configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -205,10 +298,11 @@ func DefaultConfig() *Config {
 				Enabled:  true,
 				Priority: 100,
 				Connection: &ConnectionConfig{
-					Endpoint:       "127.0.0.1:5001",
-					MaxConnections: 10,
-					IdleTimeout:    30 * time.Second,
-					ConnectTimeout: 10 * time.Second,
+					Endpoint:          "127.0.0.1:5001",
+					MaxConnections:    10,
+					IdleTimeout:       30 * time.Second,
+					ConnectTimeout:    10 * time.Second,
+					KeepAliveInterval: 10 * time.Second,
 				},
 				Settings: map[string]interface{}{
 					"api_version": "v0",
@@ -320,6 +414,13 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate offline configuration
+	if c.Offline != nil {
+		if err := c.Offline.Validate(); err != nil {
+			return NewInvalidRequestError("storage", "offline configuration invalid", err)
+		}
+	}
+
 	// Validate each backend configuration
 	for name, backend := range c.Backends {
 		if err := backend.Validate(); err != nil {
@@ -338,18 +439,22 @@ func (bc *BackendConfig) Validate() error {
 
 	// Validate supported backend types
 	validTypes := map[string]bool{
-		"ipfs": true, "mock": true,
+		"ipfs": true, "embedded": true, "mock": true, "custom": true,
 	}
 	if !validTypes[bc.Type] {
 		return NewInvalidRequestError(bc.Type, fmt.Sprintf("unsupported backend type '%s'", bc.Type), nil)
 	}
 
-	if bc.Connection == nil {
+	// The embedded node runs in-process and has no endpoint to connect to,
+	// and a custom backend connects however it likes
+	if bc.Connection == nil && bc.Type != BackendTypeEmbedded && bc.Type != BackendTypeCustom {
 		return NewInvalidRequestError(bc.Type, "connection configuration is required", nil)
 	}
 
-	if err := bc.Connection.Validate(); err != nil {
-		return NewInvalidRequestError(bc.Type, "connection configuration invalid", err)
+	if bc.Connection != nil {
+		if err := bc.Connection.Validate(); err != nil {
+			return NewInvalidRequestError(bc.Type, "connection configuration invalid", err)
+		}
 	}
 
 	if bc.Priority < 0 {
@@ -432,6 +537,10 @@ func (cc *ConnectionConfig) Validate() error {
 		return NewInvalidRequestError("connection", "connect_timeout cannot be negative", nil)
 	}
 
+	if cc.KeepAliveInterval < 0 {
+		return NewInvalidRequestError("connection", "keepalive_interval cannot be negative", nil)
+	}
+
 	// Validate auth configuration if present
 	if cc.Auth != nil {
 		if err := cc.Auth.Validate(); err != nil {
@@ -524,6 +633,26 @@ func (rc *RetryConfig) Validate() error {
 	return nil
 }
 
+// Delay returns the backoff before retry attempt n (from 1): the base delay
+// grown by the multiplier for each earlier attempt, capped at the max delay.
+// With jitter the delay is randomized between half and the full value.
+func (rc *RetryConfig) Delay(attempt int) time.Duration {
+	delay := float64(rc.BaseDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= rc.Multiplier
+		if rc.MaxDelay > 0 && delay >= float64(rc.MaxDelay) {
+			break
+		}
+	}
+	if rc.MaxDelay > 0 && delay > float64(rc.MaxDelay) {
+		delay = float64(rc.MaxDelay)
+	}
+	if rc.Jitter {
+		delay = delay/2 + rand.Float64()*delay/2
+	}
+	return time.Duration(delay)
+}
+
 // Validate validates timeout configuration
 func (tc *TimeoutConfig) Validate() error {
 	if tc.Connect < 0 {
@@ -639,6 +768,27 @@ func (hcc *HealthCheckConfig) Validate() error {
 	return nil
 }
 
+// Validate validates offline configuration
+func (oc *OfflineConfig) Validate() error {
+	if !oc.Enabled {
+		return nil
+	}
+
+	if oc.SpoolPath == "" {
+		return NewInvalidRequestError("offline", "spool_path cannot be empty", nil)
+	}
+
+	if oc.MaxSize < 0 {
+		return NewInvalidRequestError("offline", "max_size cannot be negative", nil)
+	}
+
+	if oc.RetryInterval < 0 {
+		return NewInvalidRequestError("offline", "retry_interval cannot be negative", nil)
+	}
+
+	return nil
+}
+
 // Validate validates performance configuration
 func (pc *PerformanceConfig) Validate() error {
 	if pc.MaxConcurrentOperations < 0 {
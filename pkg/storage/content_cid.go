@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	"github.com/ipfs/go-cid"
+)
+
+// UnixFS layout of `ipfs add` with its default settings
+const (
+	unixfsChunkSize = 256 * 1024
+	unixfsMaxLinks  = 174
+	unixfsTypeFile  = 2
+)
+
+// cidV0Prefix hashes dag-pb nodes into CIDv0s
+var cidV0Prefix = cid.Prefix{Version: 0, Codec: cid.DagProtobuf, MhType: 0x12, MhLength: -1}
+
+// unixfsNode is an encoded node of a UnixFS file DAG
+type unixfsNode struct {
+	cid      cid.Cid
+	fileSize uint64 // Bytes of file content below the node
+	dagSize  uint64 // Encoded bytes of the node and everything below it
+}
+
+// contentCID returns the CID IPFS gives data added with its default
+// settings: CIDv0 UnixFS with 256KiB chunks in a balanced DAG
+func contentCID(data []byte) (string, error) {
+	// An empty file is a single empty leaf
+	var level []unixfsNode
+	for offset := 0; offset == 0 || offset < len(data); offset += unixfsChunkSize {
+		chunk := data[offset:min(offset+unixfsChunkSize, len(data))]
+		leaf, err := newUnixfsNode(nil, unixfsData(chunk, uint64(len(chunk)), nil), uint64(len(chunk)))
+		if err != nil {
+			return "", err
+		}
+		level = append(level, leaf)
+	}
+
+	// Group each level under parents until one root is left
+	for len(level) > 1 {
+		var parents []unixfsNode
+		for start := 0; start < len(level); start += unixfsMaxLinks {
+			children := level[start:min(start+unixfsMaxLinks, len(level))]
+			var fileSize uint64
+			blockSizes := make([]uint64, len(children))
+			for i, child := range children {
+				fileSize += child.fileSize
+				blockSizes[i] = child.fileSize
+			}
+			parent, err := newUnixfsNode(children, unixfsData(nil, fileSize, blockSizes), fileSize)
+			if err != nil {
+				return "", err
+			}
+			parents = append(parents, parent)
+		}
+		level = parents
+	}
+	return level[0].cid.String(), nil
+}
+
+// newUnixfsNode encodes a dag-pb node, links first as dag-pb requires
+func newUnixfsNode(children []unixfsNode, data []byte, fileSize uint64) (unixfsNode, error) {
+	var encoded []byte
+	node := unixfsNode{fileSize: fileSize}
+	for _, child := range children {
+		var link []byte
+		link = appendBytesField(link, 1, child.cid.Bytes())
+		link = appendBytesField(link, 2, nil) // Name
+		link = appendVarintField(link, 3, child.dagSize)
+		encoded = appendBytesField(encoded, 2, link)
+		node.dagSize += child.dagSize
+	}
+	encoded = appendBytesField(encoded, 1, data)
+
+	id, err := cidV0Prefix.Sum(encoded)
+	if err != nil {
+		return unixfsNode{}, err
+	}
+	node.cid = id
+	node.dagSize += uint64(len(encoded))
+	return node, nil
+}
+
+// unixfsData encodes the UnixFS Data message of a file node
+func unixfsData(content []byte, fileSize uint64, blockSizes []uint64) []byte {
+	var data []byte
+	data = appendVarintField(data, 1, unixfsTypeFile)
+	if len(content) > 0 {
+		data = appendBytesField(data, 2, content)
+	}
+	data = appendVarintField(data, 3, fileSize)
+	for _, size := range blockSizes {
+		data = appendVarintField(data, 4, size)
+	}
+	return data
+}
+
+func appendVarintField(b []byte, field int, value uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, value)
+}
+
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
@@ -535,30 +535,40 @@ func (dm *DirectoryManager) CreateDirectorySnapshot(ctx context.Context, origina
 		return "", nil, fmt.Errorf("failed to generate snapshot encryption key: %w", err)
 	}
 
+	snapshotCID, err := dm.storeSnapshotManifest(ctx, snapshotManifest, snapshotKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return snapshotCID, snapshotKey, nil
+}
+
+// storeSnapshotManifest encrypts a snapshot manifest with key and stores it as a single block
+func (dm *DirectoryManager) storeSnapshotManifest(ctx context.Context, manifest *blocks.DirectoryManifest, key *crypto.EncryptionKey) (string, error) {
 	// Encrypt the snapshot manifest
-	encryptedManifest, err := blocks.EncryptManifest(snapshotManifest, snapshotKey)
+	encryptedManifest, err := blocks.EncryptManifest(manifest, key)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to encrypt snapshot manifest: %w", err)
+		return "", fmt.Errorf("failed to encrypt snapshot manifest: %w", err)
 	}
 
 	// Check manifest size
 	if int64(len(encryptedManifest)) > dm.config.MaxManifestSize {
-		return "", nil, fmt.Errorf("snapshot manifest too large: %d bytes (max: %d)", len(encryptedManifest), dm.config.MaxManifestSize)
+		return "", fmt.Errorf("snapshot manifest too large: %d bytes (max: %d)", len(encryptedManifest), dm.config.MaxManifestSize)
 	}
 
 	// Create block from encrypted manifest
 	manifestBlock, err := blocks.NewBlock(encryptedManifest)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create snapshot manifest block: %w", err)
+		return "", fmt.Errorf("failed to create snapshot manifest block: %w", err)
 	}
 
 	// Store the snapshot block using the storage manager
 	address, err := dm.storageManager.Put(ctx, manifestBlock)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to store snapshot manifest block: %w", err)
+		return "", fmt.Errorf("failed to store snapshot manifest block: %w", err)
 	}
 
-	return address.ID, snapshotKey, nil
+	return address.ID, nil
 }
 
 // RetrieveDirectoryManifestWithKey retrieves a directory manifest using a specific encryption key
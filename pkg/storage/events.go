@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// BackendState is the connection state of a backend
+type BackendState string
+
+const (
+	BackendStateConnected    BackendState = "connected"
+	BackendStateDisconnected BackendState = "disconnected"
+	BackendStateReconnecting BackendState = "reconnecting"
+)
+
+// BackendEvent reports a change in a backend's connection state
+type BackendEvent struct {
+	Backend string       `json:"backend"` // Name the backend is registered under
+	Type    string       `json:"type"`    // Backend type, e.g. ipfs
+	State   BackendState `json:"state"`
+	Attempt int          `json:"attempt,omitempty"` // Reconnect attempt, from 1
+	Error   string       `json:"error,omitempty"`
+	Time    time.Time    `json:"time"`
+}
+
+// EventBus delivers backend events to subscribers. Publishing never blocks:
+// a subscriber that falls behind misses events rather than stalling the
+// backend.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]chan BackendEvent
+	nextID      int
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[int]chan BackendEvent)}
+}
+
+// Subscribe returns a channel of events and a function that unsubscribes
+// and closes the channel
+func (b *EventBus) Subscribe(buffer int) (<-chan BackendEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan BackendEvent, buffer)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+}
+
+// Publish sends an event to every subscriber with room for it
+func (b *EventBus) Publish(event BackendEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// EventEmitter is implemented by backends that report connection state
+// changes. The manager hands each one its bus and registered name.
+type EventEmitter interface {
+	SetEventBus(bus *EventBus, name string)
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe(1)
+
+	bus.Publish(BackendEvent{Backend: "ipfs", State: BackendStateDisconnected})
+	// The subscriber's buffer is full, so this event is dropped
+	bus.Publish(BackendEvent{Backend: "ipfs", State: BackendStateReconnecting})
+
+	event := <-events
+	if event.State != BackendStateDisconnected || event.Time.IsZero() {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("expected dropped event, got %+v", event)
+	default:
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("expected channel to be closed after unsubscribing")
+	}
+	bus.Publish(BackendEvent{Backend: "ipfs", State: BackendStateConnected})
+}
+
+func TestRetryConfigDelay(t *testing.T) {
+	retry := &RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, want := range expected {
+		if got := retry.Delay(i + 1); got != want {
+			t.Errorf("attempt %d: got %v, want %v", i+1, got, want)
+		}
+	}
+	if got := retry.Delay(1000); got != time.Second {
+		t.Errorf("expected delay to stay capped, got %v", got)
+	}
+
+	retry.Jitter = true
+	for i := 0; i < 100; i++ {
+		if got := retry.Delay(3); got < 200*time.Millisecond || got > 400*time.Millisecond {
+			t.Fatalf("jittered delay %v outside [200ms, 400ms]", got)
+		}
+	}
+}
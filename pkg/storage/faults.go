@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// Operations that faults can be limited to
+const (
+	FaultOpPut    = "put"
+	FaultOpGet    = "get"
+	FaultOpHas    = "has"
+	FaultOpDelete = "delete"
+	FaultOpPin    = "pin"
+)
+
+// FaultConfig describes the failures injected into a backend. Each operation
+// draws from a generator seeded with Seed, so a sequential test sees the same
+// failures on every run.
+type FaultConfig struct {
+	Seed int64 // 0 picks a seed from the clock
+
+	Offline     bool    // Every operation fails and the backend reports itself disconnected
+	ErrorRate   float64 // Chance an operation fails with a connection error
+	LossRate    float64 // Chance a get or has reports a stored block as missing
+	TimeoutRate float64 // Chance an operation hangs until Timeout or the context ends
+
+	Timeout       time.Duration // How long a timed-out operation blocks; default 30s
+	Latency       time.Duration // Added to every operation, simulating a slow backend
+	LatencyJitter time.Duration // Random extra latency up to this much
+
+	Operations []string // Operations to affect, e.g. FaultOpGet; empty means all
+}
+
+// FaultStats counts the faults injected so far
+type FaultStats struct {
+	Operations int64
+	Errors     int64
+	Losses     int64
+	Timeouts   int64
+	Delayed    time.Duration // Total injected latency
+}
+
+// FaultInjector wraps a backend and injects failures into its operations so
+// retry, repair and failover logic can be exercised. Batch operations go
+// through the per-block operations, so losses apply to individual blocks.
+type FaultInjector struct {
+	Backend
+
+	mu     sync.Mutex
+	config FaultConfig
+	rng    *rand.Rand
+	stats  FaultStats
+}
+
+// NewFaultInjector wraps backend with the given faults
+func NewFaultInjector(backend Backend, config FaultConfig) *FaultInjector {
+	f := &FaultInjector{Backend: backend}
+	f.SetConfig(config)
+	return f
+}
+
+// SetConfig replaces the injected faults and reseeds the generator
+func (f *FaultInjector) SetConfig(config FaultConfig) {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+	f.rng = rand.New(rand.NewSource(seed))
+}
+
+// Stats returns the faults injected so far
+func (f *FaultInjector) Stats() FaultStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// Unwrap returns the wrapped backend
+func (f *FaultInjector) Unwrap() Backend {
+	return f.Backend
+}
+
+// fault is the outcome drawn for one operation
+type fault int
+
+const (
+	faultNone fault = iota
+	faultError
+	faultLoss
+	faultTimeout
+)
+
+// draw decides the outcome and delay of one operation
+func (f *FaultInjector) draw(op string) (fault, time.Duration, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.affects(op) {
+		return faultNone, 0, 0
+	}
+	f.stats.Operations++
+
+	delay := f.config.Latency
+	if f.config.LatencyJitter > 0 {
+		delay += time.Duration(f.rng.Int63n(int64(f.config.LatencyJitter)))
+	}
+	f.stats.Delayed += delay
+
+	// Draw every chance on each call so one rate doesn't shift the others' sequence
+	errorRoll, lossRoll, timeoutRoll := f.rng.Float64(), f.rng.Float64(), f.rng.Float64()
+	switch {
+	case f.config.Offline || errorRoll < f.config.ErrorRate:
+		f.stats.Errors++
+		return faultError, delay, 0
+	case timeoutRoll < f.config.TimeoutRate:
+		f.stats.Timeouts++
+		return faultTimeout, delay, f.config.Timeout
+	case (op == FaultOpGet || op == FaultOpHas) && lossRoll < f.config.LossRate:
+		f.stats.Losses++
+		return faultLoss, delay, 0
+	}
+	return faultNone, delay, 0
+}
+
+func (f *FaultInjector) affects(op string) bool {
+	if len(f.config.Operations) == 0 {
+		return true
+	}
+	for _, o := range f.config.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// inject applies the drawn fault to an operation, returning a non-nil error
+// for failures; faultLoss is returned for the caller to handle
+func (f *FaultInjector) inject(ctx context.Context, op string, address *BlockAddress) (fault, error) {
+	outcome, delay, timeout := f.draw(op)
+	backendType := f.Backend.GetBackendInfo().Type
+
+	if delay > 0 {
+		if err := sleepContext(ctx, delay); err != nil {
+			return outcome, err
+		}
+	}
+
+	switch outcome {
+	case faultError:
+		return outcome, NewConnectionError(backendType, fmt.Errorf("injected %s failure", op))
+	case faultTimeout:
+		if err := sleepContext(ctx, timeout); err != nil {
+			return outcome, err
+		}
+		return outcome, &StorageError{
+			Code:        ErrCodeTimeout,
+			Message:     fmt.Sprintf("%s: operation timed out", op),
+			BackendType: backendType,
+			Address:     address,
+			Cause:       fmt.Errorf("injected timeout after %s", timeout),
+		}
+	}
+	return outcome, nil
+}
+
+// sleepContext waits for d or until ctx ends
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Put implements Backend
+func (f *FaultInjector) Put(ctx context.Context, block *blocks.Block) (*BlockAddress, error) {
+	if _, err := f.inject(ctx, FaultOpPut, nil); err != nil {
+		return nil, err
+	}
+	return f.Backend.Put(ctx, block)
+}
+
+// Get implements Backend
+func (f *FaultInjector) Get(ctx context.Context, address *BlockAddress) (*blocks.Block, error) {
+	outcome, err := f.inject(ctx, FaultOpGet, address)
+	if err != nil {
+		return nil, err
+	}
+	if outcome == faultLoss {
+		return nil, NewNotFoundError(f.Backend.GetBackendInfo().Type, address)
+	}
+	return f.Backend.Get(ctx, address)
+}
+
+// Has implements Backend
+func (f *FaultInjector) Has(ctx context.Context, address *BlockAddress) (bool, error) {
+	outcome, err := f.inject(ctx, FaultOpHas, address)
+	if err != nil {
+		return false, err
+	}
+	if outcome == faultLoss {
+		return false, nil
+	}
+	return f.Backend.Has(ctx, address)
+}
+
+// Delete implements Backend
+func (f *FaultInjector) Delete(ctx context.Context, address *BlockAddress) error {
+	if _, err := f.inject(ctx, FaultOpDelete, address); err != nil {
+		return err
+	}
+	return f.Backend.Delete(ctx, address)
+}
+
+// PutMany implements Backend
+func (f *FaultInjector) PutMany(ctx context.Context, blks []*blocks.Block) ([]*BlockAddress, error) {
+	addresses := make([]*BlockAddress, len(blks))
+	for i, block := range blks {
+		address, err := f.Put(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to put block %d: %w", i, err)
+		}
+		addresses[i] = address
+	}
+	return addresses, nil
+}
+
+// GetMany implements Backend
+func (f *FaultInjector) GetMany(ctx context.Context, addresses []*BlockAddress) ([]*blocks.Block, error) {
+	result := make([]*blocks.Block, len(addresses))
+	for i, address := range addresses {
+		block, err := f.Get(ctx, address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block %d: %w", i, err)
+		}
+		result[i] = block
+	}
+	return result, nil
+}
+
+// Pin implements Backend
+func (f *FaultInjector) Pin(ctx context.Context, address *BlockAddress) error {
+	if _, err := f.inject(ctx, FaultOpPin, address); err != nil {
+		return err
+	}
+	return f.Backend.Pin(ctx, address)
+}
+
+// Unpin implements Backend
+func (f *FaultInjector) Unpin(ctx context.Context, address *BlockAddress) error {
+	if _, err := f.inject(ctx, FaultOpPin, address); err != nil {
+		return err
+	}
+	return f.Backend.Unpin(ctx, address)
+}
+
+// IsConnected implements Backend; an offline backend reports itself disconnected
+func (f *FaultInjector) IsConnected() bool {
+	f.mu.Lock()
+	offline := f.config.Offline
+	f.mu.Unlock()
+	return !offline && f.Backend.IsConnected()
+}
+
+// HealthCheck implements Backend; an offline backend reports itself unhealthy
+func (f *FaultInjector) HealthCheck(ctx context.Context) *HealthStatus {
+	f.mu.Lock()
+	offline := f.config.Offline
+	f.mu.Unlock()
+
+	if offline {
+		return &HealthStatus{
+			Healthy:   false,
+			Status:    "offline",
+			LastCheck: time.Now(),
+			Issues: []HealthIssue{{
+				Severity:    "critical",
+				Code:        ErrCodeBackendOffline,
+				Description: "backend taken offline by fault injection",
+				Timestamp:   time.Now(),
+			}},
+		}
+	}
+	return f.Backend.HealthCheck(ctx)
+}
+
+// InjectFaults wraps a running backend with a FaultInjector, replacing any
+// injector already installed. The manager must be started; the wrapper is
+// removed when the manager stops or with RemoveFaults.
+func (m *Manager) InjectFaults(backendName string, config FaultConfig) (*FaultInjector, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.started {
+		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
+	}
+	backend, exists := m.registry.GetBackend(backendName)
+	if !exists {
+		return nil, NewInvalidRequestError("manager", fmt.Sprintf("backend %s not found", backendName), nil)
+	}
+
+	if injector, ok := backend.(*FaultInjector); ok {
+		injector.SetConfig(config)
+		return injector, nil
+	}
+
+	injector := NewFaultInjector(backend, config)
+	m.registry.AddBackend(backendName, injector)
+	return injector, nil
+}
+
+// RemoveFaults restores the backend wrapped by InjectFaults
+func (m *Manager) RemoveFaults(backendName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if backend, exists := m.registry.GetBackend(backendName); exists {
+		if injector, ok := backend.(*FaultInjector); ok {
+			m.registry.AddBackend(backendName, injector.Unwrap())
+		}
+	}
+}
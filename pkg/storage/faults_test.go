@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+func TestFaultInjectorLoss(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMockBackend("faulty")
+	backend.latency = 0
+	backend.Connect(ctx)
+
+	block, _ := blocks.NewBlock([]byte("fault injection"))
+	address, err := backend.Put(ctx, block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	countLosses := func() int {
+		injector := NewFaultInjector(backend, FaultConfig{Seed: 7, LossRate: 0.05})
+		losses := 0
+		for i := 0; i < 1000; i++ {
+			_, err := injector.Get(ctx, address)
+			var storageErr *StorageError
+			if errors.As(err, &storageErr) && storageErr.Code == ErrCodeNotFound {
+				losses++
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if stats := injector.Stats(); stats.Losses != int64(losses) || stats.Operations != 1000 {
+			t.Errorf("Stats don't match observed losses: %+v vs %d", stats, losses)
+		}
+		return losses
+	}
+
+	first := countLosses()
+	if first < 25 || first > 80 {
+		t.Errorf("Expected about 5%% block loss, got %d/1000", first)
+	}
+	if second := countLosses(); second != first {
+		t.Errorf("Same seed should inject the same faults, got %d and %d", first, second)
+	}
+}
+
+func TestFaultInjectorTimeoutsAndLatency(t *testing.T) {
+	backend := NewMockBackend("slow")
+	backend.Connect(context.Background())
+	block, _ := blocks.NewBlock([]byte("slow"))
+
+	// A timeout is cut short by the caller's deadline
+	injector := NewFaultInjector(backend, FaultConfig{Seed: 1, TimeoutRate: 1, Timeout: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := injector.Put(ctx, block); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Timeout ignored the context deadline")
+	}
+
+	// Latency applies only to the selected operations
+	injector.SetConfig(FaultConfig{Seed: 1, Latency: 30 * time.Millisecond, Operations: []string{FaultOpGet}})
+	start = time.Now()
+	address, err := injector.Put(context.Background(), block)
+	if err != nil || time.Since(start) >= 30*time.Millisecond {
+		t.Errorf("Put should not be delayed: %v after %v", err, time.Since(start))
+	}
+	start = time.Now()
+	if _, err := injector.Get(context.Background(), address); err != nil || time.Since(start) < 30*time.Millisecond {
+		t.Errorf("Get should be delayed: %v after %v", err, time.Since(start))
+	}
+}
+
+func TestManagerInjectFaults(t *testing.T) {
+	manager := createMockManager(t)
+	ctx := context.Background()
+
+	if _, err := manager.InjectFaults("mock1", FaultConfig{}); err == nil {
+		t.Error("Expected an error before the manager is started")
+	}
+
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	block, _ := blocks.NewBlock([]byte("failover"))
+	address, err := manager.Put(ctx, block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if _, err := manager.InjectFaults("missing", FaultConfig{}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+	for _, name := range []string{"mock1", "mock2"} {
+		if _, err := manager.InjectFaults(name, FaultConfig{Offline: true}); err != nil {
+			t.Fatalf("Failed to inject faults: %v", err)
+		}
+	}
+
+	if manager.IsConnected() {
+		t.Error("Manager should report no connected backends while all are offline")
+	}
+	if _, err := manager.Get(ctx, address); err == nil {
+		t.Error("Expected Get to fail with every backend offline")
+	}
+
+	manager.RemoveFaults("mock1")
+	manager.RemoveFaults("mock2")
+	if _, err := manager.Get(ctx, address); err != nil {
+		t.Errorf("Expected Get to succeed after removing faults: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+)
+
+// GroupSnapshot describes a directory snapshot shared with a private group
+type GroupSnapshot struct {
+	EnvelopeCID string                   `json:"envelope_cid"`
+	SnapshotCID string                   `json:"snapshot_cid"`
+	OriginalCID string                   `json:"original_cid"`
+	Name        string                   `json:"name"`
+	Epoch       uint64                   `json:"epoch"`
+	Members     []string                 `json:"members"`
+	References  []string                 `json:"-"`
+	Envelope    *crypto.GroupKeyEnvelope `json:"-"`
+}
+
+// CreateGroupDirectorySnapshot creates a snapshot encrypted with a fresh key and
+// stores a key envelope wrapping that key for each member public key. When
+// previousEnvelopeCID is set the new envelope continues that group's epochs.
+func (dm *DirectoryManager) CreateGroupDirectorySnapshot(ctx context.Context, originalCID string, originalKey *crypto.EncryptionKey, snapshotName, description string, memberPublicKeys [][]byte, previousEnvelopeCID string) (*GroupSnapshot, error) {
+	originalManifest, err := dm.RetrieveDirectoryManifestWithKey(ctx, originalCID, originalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve original directory manifest: %w", err)
+	}
+
+	epoch := uint64(1)
+	if previousEnvelopeCID != "" {
+		previous, err := dm.RetrieveGroupKeyEnvelope(ctx, previousEnvelopeCID)
+		if err != nil {
+			return nil, err
+		}
+		epoch = previous.Epoch + 1
+	}
+
+	snapshotManifest := blocks.NewSnapshotManifest(originalManifest, originalCID, snapshotName, description)
+	return dm.storeGroupSnapshot(ctx, snapshotManifest, memberPublicKeys, epoch, previousEnvelopeCID)
+}
+
+// RotateGroupSnapshot re-encrypts a group snapshot under a fresh key for a new
+// member list. The caller's identity must be a member of the current envelope.
+// Only the manifest is re-encrypted: its entries keep their descriptor CIDs,
+// so removed members cannot open the new manifest but can still download
+// the files of earlier epochs whose CIDs they kept.
+func (dm *DirectoryManager) RotateGroupSnapshot(ctx context.Context, envelopeCID string, identity *crypto.GroupIdentity, memberPublicKeys [][]byte) (*GroupSnapshot, error) {
+	current, err := dm.OpenGroupSnapshot(ctx, envelopeCID, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := dm.RetrieveDirectoryManifestWithKey(ctx, current.SnapshotCID, current.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve group snapshot manifest: %w", err)
+	}
+
+	return dm.storeGroupSnapshot(ctx, manifest, memberPublicKeys, current.Envelope.Epoch+1, envelopeCID)
+}
+
+// OpenedGroupSnapshot is a group snapshot whose key has been unwrapped
+type OpenedGroupSnapshot struct {
+	SnapshotCID string
+	Envelope    *crypto.GroupKeyEnvelope
+	Key         *crypto.EncryptionKey
+}
+
+// OpenGroupSnapshot retrieves a key envelope and unwraps the snapshot key with identity
+func (dm *DirectoryManager) OpenGroupSnapshot(ctx context.Context, envelopeCID string, identity *crypto.GroupIdentity) (*OpenedGroupSnapshot, error) {
+	envelope, err := dm.RetrieveGroupKeyEnvelope(ctx, envelopeCID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := envelope.Unwrap(identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap group snapshot key: %w", err)
+	}
+
+	return &OpenedGroupSnapshot{
+		SnapshotCID: envelope.SnapshotCID,
+		Envelope:    envelope,
+		Key:         key,
+	}, nil
+}
+
+// RetrieveGroupKeyEnvelope retrieves and decodes a group key envelope
+func (dm *DirectoryManager) RetrieveGroupKeyEnvelope(ctx context.Context, envelopeCID string) (*crypto.GroupKeyEnvelope, error) {
+	address := &BlockAddress{
+		ID:          envelopeCID,
+		BackendType: dm.storageManager.config.DefaultBackend,
+	}
+
+	envelopeBlock, err := dm.storageManager.Get(ctx, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve group key envelope: %w", err)
+	}
+
+	var envelope crypto.GroupKeyEnvelope
+	if err := json.Unmarshal(envelopeBlock.Data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode group key envelope: %w", err)
+	}
+
+	if envelope.Version != crypto.GroupKeyEnvelopeVersion {
+		return nil, fmt.Errorf("unsupported group key envelope version: %d", envelope.Version)
+	}
+
+	return &envelope, nil
+}
+
+// storeGroupSnapshot encrypts manifest with a fresh group key and stores it with its envelope
+func (dm *DirectoryManager) storeGroupSnapshot(ctx context.Context, manifest *blocks.DirectoryManifest, memberPublicKeys [][]byte, epoch uint64, previousEnvelopeCID string) (*GroupSnapshot, error) {
+	groupKey, err := crypto.GenerateGroupKey()
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.SecureZero(groupKey.Key)
+
+	envelope, err := crypto.NewGroupKeyEnvelope(groupKey, memberPublicKeys, epoch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group key envelope: %w", err)
+	}
+
+	snapshotCID, err := dm.storeSnapshotManifest(ctx, manifest, groupKey)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope.SnapshotCID = snapshotCID
+	envelope.PreviousEnvelope = previousEnvelopeCID
+
+	envelopeData, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode group key envelope: %w", err)
+	}
+
+	envelopeBlock, err := blocks.NewBlock(envelopeData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group key envelope block: %w", err)
+	}
+
+	address, err := dm.storageManager.Put(ctx, envelopeBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store group key envelope: %w", err)
+	}
+
+	shared := &GroupSnapshot{
+		EnvelopeCID: address.ID,
+		SnapshotCID: snapshotCID,
+		Epoch:       epoch,
+		Members:     envelope.MemberIDs(),
+		References:  ManifestReferences(manifest),
+		Envelope:    envelope,
+	}
+	if info := manifest.GetSnapshotInfo(); info != nil {
+		shared.OriginalCID = info.OriginalCID
+		shared.Name = info.SnapshotName
+	}
+
+	return shared, nil
+}
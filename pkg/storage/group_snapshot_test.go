@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+)
+
+func TestGroupDirectorySnapshot_Rotation(t *testing.T) {
+	manager := createTestStorageManager(t)
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer manager.Stop(context.Background())
+
+	encryptionKey := createTestEncryptionKey(t)
+	dirManager, err := NewDirectoryManager(manager, encryptionKey, DefaultDirectoryManagerConfig())
+	if err != nil {
+		t.Fatalf("Failed to create directory manager: %v", err)
+	}
+
+	originalManifest := createTestDirectoryManifest()
+	originalCID, err := dirManager.StoreDirectoryManifest(context.Background(), "/test/group", originalManifest)
+	if err != nil {
+		t.Fatalf("Failed to store directory manifest: %v", err)
+	}
+
+	owner, _ := crypto.GenerateGroupIdentity()
+	alice, _ := crypto.GenerateGroupIdentity()
+	bob, _ := crypto.GenerateGroupIdentity()
+
+	// Epoch 1: owner, alice and bob
+	shared, err := dirManager.CreateGroupDirectorySnapshot(
+		context.Background(),
+		originalCID,
+		encryptionKey,
+		"team",
+		"private group snapshot",
+		[][]byte{owner.PublicKey, alice.PublicKey, bob.PublicKey},
+		"",
+	)
+	if err != nil {
+		t.Fatalf("Failed to create group snapshot: %v", err)
+	}
+	if shared.Epoch != 1 || len(shared.Members) != 3 {
+		t.Errorf("Unexpected group snapshot: epoch %d, %d members", shared.Epoch, len(shared.Members))
+	}
+
+	opened, err := dirManager.OpenGroupSnapshot(context.Background(), shared.EnvelopeCID, bob)
+	if err != nil {
+		t.Fatalf("Member failed to open group snapshot: %v", err)
+	}
+	manifest, err := dirManager.RetrieveDirectoryManifestWithKey(context.Background(), opened.SnapshotCID, opened.Key)
+	if err != nil {
+		t.Fatalf("Failed to retrieve group snapshot manifest: %v", err)
+	}
+	if !manifest.IsSnapshot() || len(manifest.Entries) != len(originalManifest.Entries) {
+		t.Error("Group snapshot manifest does not match original directory")
+	}
+
+	// Epoch 2: bob is removed
+	rotated, err := dirManager.RotateGroupSnapshot(context.Background(), shared.EnvelopeCID, owner, [][]byte{owner.PublicKey, alice.PublicKey})
+	if err != nil {
+		t.Fatalf("Failed to rotate group snapshot: %v", err)
+	}
+	if rotated.Epoch != 2 {
+		t.Errorf("Expected epoch 2, got %d", rotated.Epoch)
+	}
+	if rotated.Envelope.PreviousEnvelope != shared.EnvelopeCID {
+		t.Error("Rotated envelope should link to the previous envelope")
+	}
+	if rotated.SnapshotCID == shared.SnapshotCID {
+		t.Error("Rotation should re-encrypt the snapshot under a new key")
+	}
+
+	if _, err := dirManager.OpenGroupSnapshot(context.Background(), rotated.EnvelopeCID, bob); err == nil {
+		t.Error("Removed member should not open the rotated snapshot")
+	}
+
+	opened, err = dirManager.OpenGroupSnapshot(context.Background(), rotated.EnvelopeCID, alice)
+	if err != nil {
+		t.Fatalf("Remaining member failed to open rotated snapshot: %v", err)
+	}
+	manifest, err = dirManager.RetrieveDirectoryManifestWithKey(context.Background(), opened.SnapshotCID, opened.Key)
+	if err != nil {
+		t.Fatalf("Failed to retrieve rotated snapshot manifest: %v", err)
+	}
+	if info := manifest.GetSnapshotInfo(); info == nil || info.SnapshotName != "team" {
+		t.Error("Rotated snapshot should keep its snapshot metadata")
+	}
+
+	// Non-members cannot rotate
+	if _, err := dirManager.RotateGroupSnapshot(context.Background(), rotated.EnvelopeCID, bob, [][]byte{bob.PublicKey}); err == nil {
+		t.Error("Expected rotation by non-member to fail")
+	}
+}
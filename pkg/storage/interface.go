@@ -53,6 +53,68 @@ type PeerAwareBackend interface {
 	SetPeerManager(manager interface{}) error
 }
 
+// NameResolver is implemented by backends that can resolve mutable names,
+// such as IPNS names, to the CID they currently point to.
+type NameResolver interface {
+	ResolveName(ctx context.Context, name string) (string, error)
+}
+
+// BlockProvider is implemented by backends that can announce a stored
+// block to the network as a provider. The block's role, set with
+// WithBlockRole, lets them follow their provide strategy.
+type BlockProvider interface {
+	Provide(ctx context.Context, address *BlockAddress) error
+}
+
+// PeerStatsReporter is implemented by backends that track how well
+// individual peers serve block requests
+type PeerStatsReporter interface {
+	PeerStats() []PeerStats
+}
+
+// PeerStats summarizes the requests a backend has made to one peer
+type PeerStats struct {
+	PeerID         string        `json:"peer_id"`
+	Requests       int64         `json:"requests"`
+	Successes      int64         `json:"successes"`
+	Failures       int64         `json:"failures"`
+	SuccessRate    float64       `json:"success_rate"`
+	AverageLatency time.Duration `json:"average_latency"`
+	LastRequest    time.Time     `json:"last_request"`
+	Score          float64       `json:"score"`
+}
+
+// NetworkStatsReporter is implemented by backends connected to a
+// peer-to-peer network that can report on how it is performing
+type NetworkStatsReporter interface {
+	NetworkStats(ctx context.Context) (*NetworkStats, error)
+}
+
+// NetworkStats is a backend's view of the IPFS network
+type NetworkStats struct {
+	Peers   int          `json:"peers"`
+	Bitswap BitswapStats `json:"bitswap"`
+	DHT     DHTStats     `json:"dht"`
+}
+
+// BitswapStats counts blocks exchanged with peers since the node started
+type BitswapStats struct {
+	BlocksReceived    uint64 `json:"blocks_received"`
+	BlocksSent        uint64 `json:"blocks_sent"`
+	DataReceived      uint64 `json:"data_received"`
+	DataSent          uint64 `json:"data_sent"`
+	DupBlocksReceived uint64 `json:"dup_blocks_received"`
+	WantlistSize      int    `json:"wantlist_size"`
+}
+
+// DHTStats describes the DHT queries a backend made
+type DHTStats struct {
+	Queries        int64         `json:"queries"`
+	Failures       int64         `json:"failures"`
+	AverageLatency time.Duration `json:"average_latency"`
+	LastLatency    time.Duration `json:"last_latency"`
+}
+
 // BlockAddress represents a provider-agnostic block address.
 // This simplified structure contains only the essential fields needed
 // for block identification, routing, and validation across storage backends.
@@ -217,8 +279,10 @@ const (
 
 // Backend type constants
 const (
-	BackendTypeIPFS = "ipfs"
-	BackendTypeMock = "mock"
+	BackendTypeIPFS     = "ipfs"
+	BackendTypeEmbedded = "embedded" // In-process IPFS node, no daemon needed
+	BackendTypeMock     = "mock"
+	BackendTypeCustom   = "custom" // Supplied by the application, see CustomBackendConfig
 )
 
 // Status types
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
 )
 
 // Manager orchestrates operations across multiple storage backends using focused services
@@ -25,6 +26,32 @@ type Manager struct {
 	mutex         sync.RWMutex
 	started       bool
 	errorReporter ErrorReporter
+
+	// Optional throttling of block transfers
+	transferLimiter TransferLimiter
+
+	// Backend connection state changes
+	events *EventBus
+
+	// Optional spool for blocks stored while no backend is reachable, and
+	// the backends still to be connected
+	offline      *OfflineQueue
+	offlineMutex sync.Mutex
+	unconnected  map[string]Backend
+	stopOffline  chan struct{}
+	offlineDone  chan struct{}
+}
+
+// TransferLimiter throttles block transfers made through the manager
+type TransferLimiter interface {
+	// Acquire reserves a transfer slot, blocking until one is available
+	Acquire(ctx context.Context) error
+
+	// Release frees a slot obtained with Acquire
+	Release()
+
+	// WaitN blocks until n bytes may be transferred
+	WaitN(ctx context.Context, n int) error
 }
 
 // NewManager creates a new storage manager with decomposed services
@@ -49,6 +76,15 @@ func NewManager(config *Config) (*Manager, error) {
 		selector:      selector,
 		status:        statusAggregator,
 		errorReporter: NewDefaultErrorReporter(),
+		events:        NewEventBus(),
+	}
+
+	if config.Offline != nil && config.Offline.Enabled {
+		queue, err := OpenOfflineQueue(config.Offline.SpoolPath, config.Offline.MaxSize)
+		if err != nil {
+			return nil, NewInvalidRequestError("manager", "failed to open offline spool", err)
+		}
+		manager.offline = queue
 	}
 
 	// Initialize router with the manager facade
@@ -77,24 +113,32 @@ func (m *Manager) Start(ctx context.Context) error {
 
 	// Add backends to registry
 	for name, backend := range backends {
+		m.attachEvents(name, backend)
 		m.registry.AddBackend(name, backend)
 	}
 
 	// Connect to all backends using lifecycle service
 	if err := m.lifecycle.ConnectAllBackends(ctx, backends); err != nil {
-		// Remove unconnected backends from registry
+		// Remove unconnected backends from registry. With an offline
+		// spool they stay and are connected when they become reachable.
 		connectedBackends := make(map[string]Backend)
+		unconnected := make(map[string]Backend)
 		for name, backend := range backends {
 			if backend.IsConnected() {
 				connectedBackends[name] = backend
+			} else if m.offline != nil {
+				unconnected[name] = backend
 			} else {
 				m.registry.RemoveBackend(name)
 			}
 		}
 
-		if len(connectedBackends) == 0 {
+		if len(connectedBackends) == 0 && m.offline == nil {
 			return NewNoBackendsError()
 		}
+		m.offlineMutex.Lock()
+		m.unconnected = unconnected
+		m.offlineMutex.Unlock()
 
 		// Report connection errors but continue if some backends connected
 		connectionErrors := m.lifecycle.GetConnectionErrors()
@@ -112,12 +156,28 @@ func (m *Manager) Start(ctx context.Context) error {
 		}
 	}
 
+	if m.offline != nil {
+		m.stopOffline = make(chan struct{})
+		m.offlineDone = make(chan struct{})
+		go m.runOffline(m.stopOffline, m.offlineDone)
+	}
+
 	m.started = true
 	return nil
 }
 
 // Stop gracefully shuts down the manager
 func (m *Manager) Stop(ctx context.Context) error {
+	// Stop flushing the offline spool first: flushes take the lock
+	m.mutex.Lock()
+	stopOffline, offlineDone := m.stopOffline, m.offlineDone
+	m.stopOffline = nil
+	m.mutex.Unlock()
+	if stopOffline != nil {
+		close(stopOffline)
+		<-offlineDone
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -150,12 +210,138 @@ func (m *Manager) Stop(ctx context.Context) error {
 	return nil
 }
 
-// Put stores a block across selected backends
+// CheckReady returns nil when the manager is started and its default
+// backend is connected and reports itself healthy
+func (m *Manager) CheckReady(ctx context.Context) error {
+	m.mutex.RLock()
+	started := m.started
+	m.mutex.RUnlock()
+	if !started {
+		return NewInvalidRequestError("manager", "storage manager not started", nil)
+	}
+
+	backend, err := m.GetDefaultBackend()
+	if err != nil {
+		return err
+	}
+	if !backend.IsConnected() {
+		return fmt.Errorf("backend %s is not connected", backend.GetBackendInfo().Name)
+	}
+	if status := backend.HealthCheck(ctx); status == nil || !status.Healthy {
+		state := "unknown"
+		if status != nil {
+			state = status.Status
+		}
+		return fmt.Errorf("backend %s is %s", backend.GetBackendInfo().Name, state)
+	}
+	return nil
+}
+
+// SetTransferLimiter installs a limiter applied to every Put and Get.
+// Passing nil removes any limit.
+func (m *Manager) SetTransferLimiter(limiter TransferLimiter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.transferLimiter = limiter
+}
+
+// TransferLimiter returns the installed transfer limiter, if any
+func (m *Manager) TransferLimiter() TransferLimiter {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.transferLimiter
+}
+
+type transferLimiterKey struct{}
+
+// WithTransferLimiter returns a context whose Puts and Gets are also
+// charged to limiter, so one user of a shared manager, such as a sync
+// engine, can be throttled without slowing the others
+func WithTransferLimiter(ctx context.Context, limiter TransferLimiter) context.Context {
+	return context.WithValue(ctx, transferLimiterKey{}, limiter)
+}
+
+// transferLimiters returns the installed limiter and the one set on ctx
+// with WithTransferLimiter, whichever are present
+func (m *Manager) transferLimiters(ctx context.Context) []TransferLimiter {
+	var limiters []TransferLimiter
+	if limiter := m.TransferLimiter(); limiter != nil {
+		limiters = append(limiters, limiter)
+	}
+	if limiter, ok := ctx.Value(transferLimiterKey{}).(TransferLimiter); ok && limiter != nil {
+		limiters = append(limiters, limiter)
+	}
+	return limiters
+}
+
+// acquireTransfer reserves a slot on each limiter, returning a function
+// that frees them
+func acquireTransfer(ctx context.Context, limiters []TransferLimiter) (func(), error) {
+	release := func() {
+		for _, limiter := range limiters {
+			limiter.Release()
+		}
+	}
+	for i, limiter := range limiters {
+		if err := limiter.Acquire(ctx); err != nil {
+			for _, acquired := range limiters[:i] {
+				acquired.Release()
+			}
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// waitTransfer blocks until each limiter allows n bytes
+func waitTransfer(ctx context.Context, limiters []TransferLimiter, n int) error {
+	for _, limiter := range limiters {
+		if err := limiter.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put stores a block across selected backends. With an offline spool,
+// blocks stored while no backend is reachable are spooled instead.
 func (m *Manager) Put(ctx context.Context, block *blocks.Block) (*BlockAddress, error) {
 	if !m.started {
 		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
 	}
 
+	if m.isOffline() {
+		return m.offline.Add(block)
+	}
+	address, err := m.put(ctx, block)
+	if err != nil && m.isOffline() {
+		return m.offline.Add(block)
+	}
+	if err != nil {
+		requestLogger(ctx).WithField("block", block.ID).Warnf("Failed to store block: %v", err)
+	}
+	return address, err
+}
+
+// requestLogger returns the storage logger with the request ID of ctx, so
+// block failures can be traced back to the request that caused them
+func requestLogger(ctx context.Context) *logging.FieldLogger {
+	return logging.GetGlobalLogger().WithComponent("storage").WithContext(ctx)
+}
+
+// put stores a block through the router, within the transfer limits
+func (m *Manager) put(ctx context.Context, block *blocks.Block) (*BlockAddress, error) {
+	limiters := m.transferLimiters(ctx)
+	release, err := acquireTransfer(ctx, limiters)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := waitTransfer(ctx, limiters, block.Size()); err != nil {
+		return nil, err
+	}
+
 	return m.router.Put(ctx, block)
 }
 
@@ -165,7 +351,53 @@ func (m *Manager) Get(ctx context.Context, address *BlockAddress) (*blocks.Block
 		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
 	}
 
-	return m.router.Get(ctx, address)
+	if m.offline != nil {
+		if block, ok := m.offline.Get(address.ID); ok {
+			return block, nil
+		}
+	}
+
+	return m.limitedGet(ctx, address, m.router.Get)
+}
+
+// GetFrom retrieves a block from one backend only, under the transfer
+// limiter like Get. Downloads use it to ask a second backend for a block
+// the first is slow to return.
+func (m *Manager) GetFrom(ctx context.Context, backend Backend, address *BlockAddress) (*blocks.Block, error) {
+	if !m.started {
+		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
+	}
+	if !backend.IsConnected() {
+		return nil, NewConnectionError(backend.GetBackendInfo().Type, nil)
+	}
+
+	backendAddress := *address
+	backendAddress.BackendType = backend.GetBackendInfo().Type
+	return m.limitedGet(ctx, &backendAddress, backend.Get)
+}
+
+// limitedGet retrieves a block with get, charging it to the transfer
+// limiters that are set
+func (m *Manager) limitedGet(ctx context.Context, address *BlockAddress, get func(context.Context, *BlockAddress) (*blocks.Block, error)) (*blocks.Block, error) {
+	limiters := m.transferLimiters(ctx)
+	release, err := acquireTransfer(ctx, limiters)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	block, err := get(ctx, address)
+	if err != nil {
+		requestLogger(ctx).WithField("block", address.ID).Debugf("Failed to retrieve block: %v", err)
+		return nil, err
+	}
+
+	// Size is only known after retrieval, so charge the bandwidth afterwards
+	if err := waitTransfer(ctx, limiters, block.Size()); err != nil {
+		return nil, err
+	}
+
+	return block, nil
 }
 
 // Has checks if a block exists in any backend
@@ -174,6 +406,10 @@ func (m *Manager) Has(ctx context.Context, address *BlockAddress) (bool, error)
 		return false, NewInvalidRequestError("manager", "storage manager not started", nil)
 	}
 
+	if m.offline != nil && m.offline.Has(address.ID) {
+		return true, nil
+	}
+
 	return m.router.Has(ctx, address)
 }
 
@@ -192,6 +428,18 @@ func (m *Manager) PutMany(ctx context.Context, blocks []*blocks.Block) ([]*Block
 		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
 	}
 
+	if m.isOffline() {
+		addresses := make([]*BlockAddress, 0, len(blocks))
+		for _, block := range blocks {
+			address, err := m.offline.Add(block)
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, address)
+		}
+		return addresses, nil
+	}
+
 	return m.router.PutMany(ctx, blocks)
 }
 
@@ -222,6 +470,51 @@ func (m *Manager) Unpin(ctx context.Context, address *BlockAddress) error {
 	return m.router.Unpin(ctx, address)
 }
 
+// ResolveName resolves a mutable name (e.g. an IPNS name) to a CID using the
+// highest priority backend that supports name resolution
+func (m *Manager) ResolveName(ctx context.Context, name string) (string, error) {
+	if !m.started {
+		return "", NewInvalidRequestError("manager", "storage manager not started", nil)
+	}
+
+	for _, backend := range m.GetBackendsByPriority() {
+		if resolver, ok := backend.(NameResolver); ok && backend.IsConnected() {
+			return resolver.ResolveName(ctx, name)
+		}
+	}
+
+	return "", NewInvalidRequestError("manager", "no backend supports name resolution", nil)
+}
+
+// PeerStats returns per-peer request metrics for each backend that
+// tracks them, keyed by backend name
+func (m *Manager) PeerStats() map[string][]PeerStats {
+	stats := make(map[string][]PeerStats)
+	for name, backend := range m.GetAvailableBackends() {
+		if reporter, ok := backend.(PeerStatsReporter); ok {
+			stats[name] = reporter.PeerStats()
+		}
+	}
+	return stats
+}
+
+// NetworkStats returns peer, Bitswap and DHT statistics for each backend
+// that reports them, keyed by backend name. Backends that fail to answer
+// are left out.
+func (m *Manager) NetworkStats(ctx context.Context) map[string]*NetworkStats {
+	stats := make(map[string]*NetworkStats)
+	for name, backend := range m.GetAvailableBackends() {
+		reporter, ok := backend.(NetworkStatsReporter)
+		if !ok || !backend.IsConnected() {
+			continue
+		}
+		if backendStats, err := reporter.NetworkStats(ctx); err == nil {
+			stats[name] = backendStats
+		}
+	}
+	return stats
+}
+
 // Backend registry delegation
 func (m *Manager) GetBackend(name string) (Backend, bool) {
 	return m.registry.GetBackend(name)
@@ -278,6 +571,19 @@ func (m *Manager) GetErrorMetrics() *ErrorMetrics {
 	return m.errorReporter.GetErrorMetrics()
 }
 
+// Events returns the bus on which backends report connection state
+// changes, such as losing and regaining an IPFS daemon
+func (m *Manager) Events() *EventBus {
+	return m.events
+}
+
+// attachEvents connects a backend that reports state changes to the bus
+func (m *Manager) attachEvents(name string, backend Backend) {
+	if emitter, ok := backend.(EventEmitter); ok {
+		emitter.SetEventBus(m.events, name)
+	}
+}
+
 // GetRegistry returns the backend registry (for testing)
 func (m *Manager) GetRegistry() BackendRegistry {
 	return m.registry
@@ -321,6 +627,7 @@ func (m *Manager) ReconfigureBackend(name string, newConfig *BackendConfig) erro
 		if err != nil {
 			return NewInvalidRequestError(name, "failed to create backend", err)
 		}
+		m.attachEvents(name, newBackend)
 
 		// Connect new backend
 		if err := m.lifecycle.ConnectBackend(ctx, name, newBackend); err != nil {
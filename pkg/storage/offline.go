@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// BackendTypeOffline is the backend type of addresses of spooled blocks
+const BackendTypeOffline = "offline"
+
+// defaultOfflineRetryInterval is used when OfflineConfig.RetryInterval is 0
+const defaultOfflineRetryInterval = 30 * time.Second
+
+// OfflineQueue spools blocks to disk while no backend is reachable and
+// stores them once one is. Spooled blocks are addressed by the CID IPFS
+// gives them, so descriptors written offline stay valid after the flush;
+// backends that address blocks differently can't take a flush.
+type OfflineQueue struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	blocks  map[string]int64 // CID -> size
+	size    int64
+
+	flushing sync.Mutex // One flush at a time
+}
+
+// OfflineStatus summarizes the blocks waiting in an offline queue
+type OfflineStatus struct {
+	Pending int   `json:"pending"`
+	Size    int64 `json:"size"`
+}
+
+// OpenOfflineQueue opens the spool in dir, picking up blocks left by
+// earlier runs. maxSize bounds the bytes spooled at once; 0 means no limit.
+func OpenOfflineQueue(dir string, maxSize int64) (*OfflineQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	q := &OfflineQueue{
+		dir:     dir,
+		maxSize: maxSize,
+		blocks:  make(map[string]int64),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			// Left by a crash while spooling
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if _, err := cid.Decode(name); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spooled block: %w", err)
+		}
+		q.blocks[name] = info.Size()
+		q.size += info.Size()
+	}
+	return q, nil
+}
+
+// Add spools a block and returns its address
+func (q *OfflineQueue) Add(block *blocks.Block) (*BlockAddress, error) {
+	id, err := contentCID(block.Data)
+	if err != nil {
+		return nil, NewInvalidRequestError(BackendTypeOffline, "failed to address block", err)
+	}
+	address := &BlockAddress{
+		ID:          id,
+		BackendType: BackendTypeOffline,
+		Size:        int64(len(block.Data)),
+		CreatedAt:   time.Now(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.blocks[id]; ok {
+		return address, nil
+	}
+	if q.maxSize > 0 && q.size+address.Size > q.maxSize {
+		return nil, NewStorageError(ErrCodeBackendOffline, "no backend reachable and the offline spool is full", BackendTypeOffline, nil)
+	}
+
+	path := filepath.Join(q.dir, id)
+	if err := os.WriteFile(path+".tmp", block.Data, 0600); err != nil {
+		return nil, NewStorageError(ErrCodeBackendOffline, "failed to spool block", BackendTypeOffline, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, NewStorageError(ErrCodeBackendOffline, "failed to spool block", BackendTypeOffline, err)
+	}
+	q.blocks[id] = address.Size
+	q.size += address.Size
+	return address, nil
+}
+
+// Get returns a spooled block
+func (q *OfflineQueue) Get(id string) (*blocks.Block, bool) {
+	if !q.Has(id) {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(q.dir, id))
+	if err != nil {
+		return nil, false
+	}
+	block, err := blocks.NewBlock(data)
+	if err != nil {
+		return nil, false
+	}
+	return block, true
+}
+
+// Has reports whether a block is spooled
+func (q *OfflineQueue) Has(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.blocks[id]
+	return ok
+}
+
+// Status returns the number and total size of the spooled blocks
+func (q *OfflineQueue) Status() OfflineStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return OfflineStatus{Pending: len(q.blocks), Size: q.size}
+}
+
+// Flush stores the spooled blocks with put and removes them from the spool.
+// It stops at the first failed put, as the backend has most likely gone
+// again, and returns the number of blocks stored. Blocks the backend
+// addresses differently stay spooled and are reported.
+func (q *OfflineQueue) Flush(ctx context.Context, put func(context.Context, *blocks.Block) (*BlockAddress, error)) (int, error) {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	q.mu.Lock()
+	ids := make([]string, 0, len(q.blocks))
+	for id := range q.blocks {
+		ids = append(ids, id)
+	}
+	q.mu.Unlock()
+	sort.Strings(ids)
+
+	flushed := 0
+	var mismatched []string
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return flushed, err
+		}
+		block, ok := q.Get(id)
+		if !ok {
+			continue
+		}
+		address, err := put(ctx, block)
+		if err != nil {
+			return flushed, err
+		}
+		if address.ID != id {
+			mismatched = append(mismatched, id)
+			continue
+		}
+		q.remove(id)
+		flushed++
+	}
+
+	if len(mismatched) > 0 {
+		return flushed, NewStorageError(ErrCodeIntegrityFailure, fmt.Sprintf("backend stored %d spooled blocks under different addresses; they stay spooled", len(mismatched)), BackendTypeOffline, nil)
+	}
+	return flushed, nil
+}
+
+// remove deletes a flushed block from the spool
+func (q *OfflineQueue) remove(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	size, ok := q.blocks[id]
+	if !ok {
+		return
+	}
+	os.Remove(filepath.Join(q.dir, id))
+	delete(q.blocks, id)
+	q.size -= size
+}
+
+// isOffline reports whether blocks are being spooled: the manager has a
+// spool and no backend is reachable
+func (m *Manager) isOffline() bool {
+	return m.offline != nil && len(m.registry.GetAvailableBackends()) == 0
+}
+
+// OfflineStatus returns the blocks waiting in the offline spool, and false
+// when the manager has none
+func (m *Manager) OfflineStatus() (OfflineStatus, bool) {
+	if m.offline == nil {
+		return OfflineStatus{}, false
+	}
+	return m.offline.Status(), true
+}
+
+// FlushOffline stores the spooled blocks in the reachable backends. It is
+// also done automatically whenever a backend connects.
+func (m *Manager) FlushOffline(ctx context.Context) (int, error) {
+	if m.offline == nil {
+		return 0, nil
+	}
+	if m.isOffline() {
+		return 0, NewNoBackendsError()
+	}
+	return m.offline.Flush(ctx, m.put)
+}
+
+// runOffline connects the backends that were unreachable at start and
+// flushes the spool while a backend is reachable, until stop is closed
+func (m *Manager) runOffline(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	events, unsubscribe := m.events.Subscribe(16)
+	defer unsubscribe()
+
+	interval := m.config.Offline.RetryInterval
+	if interval <= 0 {
+		interval = defaultOfflineRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Flush right away, in case an earlier run left blocks spooled, and
+	// again after every connection change
+	for {
+		if m.offline.Status().Pending > 0 && !m.isOffline() {
+			if _, err := m.FlushOffline(ctx); err != nil {
+				if storageErr, ok := err.(*StorageError); ok {
+					m.errorReporter.ReportError(storageErr)
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-events:
+		case <-ticker.C:
+			m.connectUnconnected(ctx)
+		}
+	}
+}
+
+// connectUnconnected tries again to connect the backends that were
+// unreachable at start
+func (m *Manager) connectUnconnected(ctx context.Context) {
+	m.offlineMutex.Lock()
+	defer m.offlineMutex.Unlock()
+
+	for name, backend := range m.unconnected {
+		if err := m.lifecycle.ConnectBackend(ctx, name, backend); err == nil {
+			delete(m.unconnected, name)
+		}
+	}
+}
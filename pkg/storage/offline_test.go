@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// cidBackend addresses blocks the way IPFS does and can be made unreachable
+type cidBackend struct {
+	*MockBackend
+
+	mu        sync.Mutex
+	reachable bool
+	stored    map[string][]byte
+}
+
+func newCIDBackend() *cidBackend {
+	return &cidBackend{MockBackend: NewMockBackend("cid"), stored: make(map[string][]byte)}
+}
+
+func (b *cidBackend) setReachable(reachable bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reachable = reachable
+}
+
+func (b *cidBackend) Connect(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.reachable {
+		return NewConnectionError("cid", fmt.Errorf("unreachable"))
+	}
+	b.connected = true
+	return nil
+}
+
+func (b *cidBackend) IsConnected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.connected && b.reachable
+}
+
+func (b *cidBackend) Put(ctx context.Context, block *blocks.Block) (*BlockAddress, error) {
+	if !b.IsConnected() {
+		return nil, NewConnectionError("cid", fmt.Errorf("not connected"))
+	}
+	id, err := contentCID(block.Data)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stored[id] = block.Data
+	return &BlockAddress{ID: id, BackendType: "cid", Size: int64(len(block.Data))}, nil
+}
+
+func (b *cidBackend) storedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.stored)
+}
+
+func TestContentCID(t *testing.T) {
+	// What `ipfs add` prints for the same content
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{"one chunk", []byte("hello world\n"), "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"},
+		{"several chunks", bytes.Repeat([]byte("noisefs\n"), 100000), "QmdpAruEGsgnuKR7L1mZun1yz573jkGv2Y6wkUAeFxPq9p"},
+	}
+	for _, tt := range tests {
+		id, err := contentCID(tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != tt.want {
+			t.Errorf("%s: unexpected CID %s", tt.name, id)
+		}
+	}
+}
+
+func TestManagerOfflineSpool(t *testing.T) {
+	backend := newCIDBackend()
+	spool := t.TempDir()
+
+	config := DefaultConfig()
+	config.Backends = map[string]*BackendConfig{BackendTypeCustom: CustomBackendConfig(backend)}
+	config.DefaultBackend = BackendTypeCustom
+	config.HealthCheck.Enabled = false
+	config.Offline = &OfflineConfig{Enabled: true, SpoolPath: spool, RetryInterval: 10 * time.Millisecond}
+
+	manager, err := NewManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("expected start without a reachable backend to succeed: %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	block, _ := blocks.NewBlock([]byte("written on a plane"))
+	address, err := manager.Put(ctx, block)
+	if err != nil {
+		t.Fatalf("put while offline: %v", err)
+	}
+	if address.BackendType != BackendTypeOffline {
+		t.Errorf("expected a spooled address, got %+v", address)
+	}
+	if got, err := manager.Get(ctx, address); err != nil || string(got.Data) != "written on a plane" {
+		t.Errorf("expected spooled block to be readable, got %v", err)
+	}
+	if status, _ := manager.OfflineStatus(); status.Pending != 1 {
+		t.Errorf("expected 1 spooled block, got %+v", status)
+	}
+
+	// Reopening the spool finds the block
+	reopened, err := OpenOfflineQueue(spool, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Has(address.ID) {
+		t.Error("expected reopened spool to hold the block")
+	}
+
+	backend.setReachable(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, _ := manager.OfflineStatus()
+		if status.Pending == 0 && backend.storedCount() == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("spool not flushed after reconnecting: %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Stored under the address handed out while offline
+	if _, ok := backend.stored[address.ID]; !ok {
+		t.Errorf("expected block stored as %s", address.ID)
+	}
+	online, err := manager.Put(ctx, block)
+	if err != nil || online.ID != address.ID || online.BackendType == BackendTypeOffline {
+		t.Errorf("expected online put under the same CID, got %+v, %v", online, err)
+	}
+}
+
+func TestOfflineQueueMaxSize(t *testing.T) {
+	queue, err := OpenOfflineQueue(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, _ := blocks.NewBlock([]byte("12345678"))
+	large, _ := blocks.NewBlock([]byte("123456789"))
+	if _, err := queue.Add(small); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queue.Add(small); err != nil {
+		t.Errorf("expected re-adding a spooled block to succeed: %v", err)
+	}
+	if _, err := queue.Add(large); err == nil {
+		t.Error("expected a full spool to refuse blocks")
+	}
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Pin maintenance defaults
+const (
+	DefaultPinSampleSize            = 32
+	DefaultPinAvailabilityThreshold = 0.9
+)
+
+// PinMaintenanceConfig controls the pin maintainer
+type PinMaintenanceConfig struct {
+	Interval time.Duration // Between rounds
+	// SampleSize is the number of blocks of each pin checked for
+	// availability per round, 0 for all of them
+	SampleSize int
+	// Threshold is the fraction of expected copies below which a pin is
+	// reported as degraded
+	Threshold float64
+}
+
+// PinAvailability is the outcome of maintaining one pin
+type PinAvailability struct {
+	DescriptorCID string    `json:"descriptor_cid"`
+	Name          string    `json:"name,omitempty"`
+	Backends      []string  `json:"backends"`
+	Repinned      int       `json:"repinned"`          // Block pins renewed
+	Provided      int       `json:"provided"`          // Block announcements made
+	Failures      int       `json:"failures"`          // Pins and announcements that failed
+	Sampled       int       `json:"sampled"`           // Blocks checked for availability
+	Copies        int       `json:"copies"`            // Sampled blocks found, once per backend holding them
+	Expected      int       `json:"expected"`          // Sampled blocks times the pin's backends
+	Availability  float64   `json:"availability"`      // Copies over expected copies
+	Missing       []string  `json:"missing,omitempty"` // Sampled blocks none of the pin's backends hold
+	Degraded      bool      `json:"degraded"`
+	CheckedAt     time.Time `json:"checked_at"`
+}
+
+// PinMaintainer keeps pinned descriptors available. Every round it renews
+// the pins of each descriptor in the pin registry, announces its blocks
+// again before their provider records expire, and samples which backends
+// still hold them. Pins whose availability falls below the threshold are
+// logged and passed to the degraded callback.
+type PinMaintainer struct {
+	manager      *Manager
+	registryPath string
+	config       PinMaintenanceConfig
+	onDegraded   func(PinAvailability)
+
+	// State management
+	running  bool
+	stopChan chan struct{}
+	mutex    sync.Mutex
+}
+
+// NewPinMaintainer creates a maintainer for the pins recorded at
+// registryPath. onDegraded may be nil.
+func NewPinMaintainer(manager *Manager, registryPath string, config PinMaintenanceConfig, onDegraded func(PinAvailability)) *PinMaintainer {
+	if config.SampleSize < 0 {
+		config.SampleSize = 0
+	}
+	return &PinMaintainer{
+		manager:      manager,
+		registryPath: registryPath,
+		config:       config,
+		onDegraded:   onDegraded,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start runs maintenance rounds every interval, the first one right away
+func (pm *PinMaintainer) Start(ctx context.Context) error {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if pm.running {
+		return fmt.Errorf("pin maintainer already running")
+	}
+	if pm.config.Interval <= 0 {
+		return fmt.Errorf("pin maintenance interval must be positive")
+	}
+
+	pm.running = true
+	go pm.maintenanceLoop(ctx)
+	return nil
+}
+
+// Stop stops the maintenance rounds
+func (pm *PinMaintainer) Stop() {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+
+	if !pm.running {
+		return
+	}
+
+	pm.running = false
+	close(pm.stopChan)
+}
+
+func (pm *PinMaintainer) maintenanceLoop(ctx context.Context) {
+	ticker := time.NewTicker(pm.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := pm.MaintainAll(ctx); err != nil && ctx.Err() == nil {
+			requestLogger(ctx).Warnf("Pin maintenance failed: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-pm.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// MaintainAll runs one maintenance round over every pin. The registry is
+// read again each round, so pins added or removed by the CLI are picked up.
+func (pm *PinMaintainer) MaintainAll(ctx context.Context) ([]PinAvailability, error) {
+	registry, err := NewPinRegistry(pm.registryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PinAvailability
+	for _, record := range registry.All() {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result := pm.Maintain(ctx, record)
+		if result.Degraded {
+			requestLogger(ctx).WithField("descriptor", record.DescriptorCID).Warnf(
+				"Pinned descriptor %q is degraded: %d of %d sampled copies available (%.0f%%), %d blocks missing everywhere",
+				record.Name, result.Copies, result.Expected, result.Availability*100, len(result.Missing))
+			if pm.onDegraded != nil {
+				pm.onDegraded(result)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Maintain renews and checks one pin. Availability is sampled after the
+// pins are renewed, so it reflects what renewing couldn't recover. Backends
+// that aren't connected count as holding none of the blocks.
+func (pm *PinMaintainer) Maintain(ctx context.Context, record PinRecord) PinAvailability {
+	result := PinAvailability{
+		DescriptorCID: record.DescriptorCID,
+		Name:          record.Name,
+		Backends:      record.Backends,
+	}
+
+	blocks := make([]string, 0, len(record.Blocks)+len(record.Randomizers))
+	roles := make(map[string]string, cap(blocks))
+	for _, cid := range record.Blocks {
+		blocks = append(blocks, cid)
+		roles[cid] = BlockRoleData
+	}
+	for _, cid := range record.Randomizers {
+		blocks = append(blocks, cid)
+		roles[cid] = BlockRoleRandomizer
+	}
+
+	for _, backend := range record.Backends {
+		for _, cid := range blocks {
+			if ctx.Err() != nil {
+				break
+			}
+			address := &BlockAddress{ID: cid}
+			if err := pm.manager.PinIn(ctx, backend, address); err != nil {
+				result.Failures++
+				continue
+			}
+			result.Repinned++
+
+			provided, err := pm.manager.ProvideIn(WithBlockRole(ctx, roles[cid]), backend, address)
+			if err != nil {
+				result.Failures++
+			} else if provided {
+				result.Provided++
+			}
+		}
+	}
+
+	sample := blocks
+	if pm.config.SampleSize > 0 && len(blocks) > pm.config.SampleSize {
+		sample = make([]string, len(blocks))
+		copy(sample, blocks)
+		rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		sample = sample[:pm.config.SampleSize]
+	}
+
+	for _, cid := range sample {
+		held := 0
+		for _, backend := range record.Backends {
+			if pm.holds(ctx, backend, cid) {
+				held++
+			}
+		}
+		if held == 0 {
+			result.Missing = append(result.Missing, cid)
+		}
+		result.Copies += held
+	}
+	result.Sampled = len(sample)
+	result.Expected = len(sample) * len(record.Backends)
+	if result.Expected > 0 {
+		result.Availability = float64(result.Copies) / float64(result.Expected)
+	}
+	result.Degraded = result.Expected > 0 && result.Availability < pm.config.Threshold
+	result.CheckedAt = time.Now().UTC()
+	return result
+}
+
+// holds reports whether the named backend is connected and has a block
+func (pm *PinMaintainer) holds(ctx context.Context, name, cid string) bool {
+	backend, err := pm.manager.connectedBackend(name)
+	if err != nil {
+		return false
+	}
+	address := &BlockAddress{ID: cid, BackendType: backend.GetBackendInfo().Type}
+	exists, err := backend.Has(ctx, address)
+	return err == nil && exists
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+func TestPinMaintainer(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewMockBackend("primary"), NewMockBackend("secondary")
+	primary.latency, secondary.latency = 0, 0
+
+	config := DefaultConfig()
+	config.Backends = map[string]*BackendConfig{"primary": CustomBackendConfig(primary), "secondary": CustomBackendConfig(secondary)}
+	config.DefaultBackend = "primary"
+	config.HealthCheck.Enabled = false
+
+	manager, err := NewManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Stop(ctx)
+
+	first, _ := blocks.NewBlock([]byte("first"))
+	second, _ := blocks.NewBlock([]byte("second"))
+	a, _ := primary.Put(ctx, first)
+	b, _ := primary.Put(ctx, second)
+	secondary.Put(ctx, first) // Only the first block has a second copy
+
+	registry, err := NewPinRegistry(filepath.Join(t.TempDir(), "pins.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := PinRecord{
+		DescriptorCID: a.ID,
+		Name:          "file.txt",
+		Backends:      []string{"primary", "secondary"},
+		Blocks:        []string{a.ID, b.ID},
+		PinnedAt:      time.Now(),
+	}
+	if err := registry.Add(record); err != nil {
+		t.Fatal(err)
+	}
+
+	var degraded []PinAvailability
+	maintainer := NewPinMaintainer(manager, registry.path, PinMaintenanceConfig{Interval: time.Hour, Threshold: 0.9}, func(pin PinAvailability) {
+		degraded = append(degraded, pin)
+	})
+	results, err := maintainer.MaintainAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected one pin maintained, got %d", len(results))
+	}
+	result := results[0]
+	if result.Repinned != 3 || result.Failures != 1 || result.Provided != 0 {
+		t.Errorf("Expected 3 pins renewed and 1 failure, got %+v", result)
+	}
+	if result.Sampled != 2 || result.Copies != 3 || result.Expected != 4 || result.Availability != 0.75 || len(result.Missing) != 0 {
+		t.Errorf("Unexpected availability: %+v", result)
+	}
+	if !result.Degraded || len(degraded) != 1 || degraded[0].DescriptorCID != a.ID {
+		t.Errorf("Expected the pin to be reported as degraded, got %+v", degraded)
+	}
+
+	// A lower threshold accepts the pin, and sampling checks fewer blocks
+	maintainer = NewPinMaintainer(manager, registry.path, PinMaintenanceConfig{SampleSize: 1, Threshold: 0.5}, nil)
+	if result := maintainer.Maintain(ctx, record); result.Sampled != 1 || result.Expected != 2 || result.Degraded {
+		t.Errorf("Expected one healthy sampled block, got %+v", result)
+	}
+
+	// Blocks gone from every backend are listed as missing
+	delete(primary.blocks, b.ID)
+	if result := maintainer.Maintain(ctx, PinRecord{DescriptorCID: b.ID, Backends: record.Backends, Blocks: []string{b.ID}}); len(result.Missing) != 1 || result.Availability != 0 || !result.Degraded {
+		t.Errorf("Expected the lost block to be missing, got %+v", result)
+	}
+
+	if err := maintainer.Start(ctx); err == nil {
+		t.Error("Expected starting without an interval to fail")
+	}
+}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PinPolicy decides where, and which of, the blocks of a descriptor are
+// pinned
+type PinPolicy struct {
+	// Replicas is the number of backends to pin on, highest priority
+	// first. Zero pins on every connected backend.
+	Replicas int `json:"replicas"`
+	// Backends restricts pinning to the named backends
+	Backends []string `json:"backends,omitempty"`
+	// Randomizers also pins the randomizer blocks, which other files
+	// usually keep available already
+	Randomizers bool `json:"randomizers"`
+}
+
+// PinRecord is a descriptor pinned from this device
+type PinRecord struct {
+	DescriptorCID string    `json:"descriptor_cid"`
+	Name          string    `json:"name,omitempty"`
+	Policy        PinPolicy `json:"policy"`
+	Backends      []string  `json:"backends"`              // Backends the blocks were pinned on
+	Blocks        []string  `json:"blocks"`                // The descriptor, its manifest and data blocks
+	Randomizers   []string  `json:"randomizers,omitempty"` // Randomizer blocks, when the policy pins them
+	PinnedAt      time.Time `json:"pinned_at"`
+}
+
+// PinRegistry persists the descriptors pinned on this device, so that
+// removing one pin leaves the blocks other pins share in place
+type PinRegistry struct {
+	path    string
+	records []PinRecord
+	mu      sync.Mutex
+}
+
+// DefaultPinRegistryPath returns ~/.noisefs/pins.json
+func DefaultPinRegistryPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".noisefs", "pins.json"), nil
+}
+
+// NewPinRegistry loads the registry at path. A missing file yields an empty registry.
+func NewPinRegistry(path string) (*PinRegistry, error) {
+	registry := &PinRegistry{
+		path:    path,
+		records: make([]PinRecord, 0),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pin registry: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &registry.records); err != nil {
+		return nil, fmt.Errorf("failed to parse pin registry: %w", err)
+	}
+
+	return registry, nil
+}
+
+// Add records a pin, replacing any existing record for the same descriptor
+func (r *PinRegistry) Add(record PinRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.records {
+		if r.records[i].DescriptorCID == record.DescriptorCID {
+			r.records[i] = record
+			return r.save()
+		}
+	}
+
+	r.records = append(r.records, record)
+	return r.save()
+}
+
+// Get returns the pin record of a descriptor
+func (r *PinRegistry) Get(descriptorCID string) (PinRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, record := range r.records {
+		if record.DescriptorCID == descriptorCID {
+			return record, true
+		}
+	}
+	return PinRecord{}, false
+}
+
+// All returns every pin, oldest first
+func (r *PinRegistry) All() []PinRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]PinRecord, len(r.records))
+	copy(records, r.records)
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].PinnedAt.Before(records[j].PinnedAt)
+	})
+	return records
+}
+
+// Releasable returns the blocks and randomizers a pin holds that no other
+// pin references, which are safe to unpin when it is removed
+func (r *PinRegistry) Releasable(descriptorCID string) (blocks, randomizers []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pin *PinRecord
+	referenced := make(map[string]bool)
+	for i, record := range r.records {
+		if record.DescriptorCID == descriptorCID {
+			pin = &r.records[i]
+			continue
+		}
+		for _, cid := range record.Blocks {
+			referenced[cid] = true
+		}
+		for _, cid := range record.Randomizers {
+			referenced[cid] = true
+		}
+	}
+	if pin == nil {
+		return nil, nil, fmt.Errorf("descriptor %s is not pinned", descriptorCID)
+	}
+
+	for _, cid := range pin.Blocks {
+		if !referenced[cid] {
+			blocks = append(blocks, cid)
+		}
+	}
+	for _, cid := range pin.Randomizers {
+		if !referenced[cid] {
+			randomizers = append(randomizers, cid)
+		}
+	}
+	return blocks, randomizers, nil
+}
+
+// Remove deletes the pin record of a descriptor
+func (r *PinRegistry) Remove(descriptorCID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, record := range r.records {
+		if record.DescriptorCID == descriptorCID {
+			r.records = append(r.records[:i], r.records[i+1:]...)
+			return r.save()
+		}
+	}
+	return fmt.Errorf("descriptor %s is not pinned", descriptorCID)
+}
+
+// save writes the registry atomically. Caller must hold mu.
+func (r *PinRegistry) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create pin registry directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pin registry: %w", err)
+	}
+
+	tempFile := r.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pin registry: %w", err)
+	}
+	if err := os.Rename(tempFile, r.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save pin registry: %w", err)
+	}
+
+	return nil
+}
+
+// PinTargets returns the names of the connected backends a pin policy
+// places replicas on, highest priority first
+func (m *Manager) PinTargets(policy PinPolicy) ([]string, error) {
+	if policy.Replicas < 0 {
+		return nil, NewInvalidRequestError("manager", "replica count cannot be negative", nil)
+	}
+
+	available := m.GetAvailableBackends()
+	var names []string
+	if len(policy.Backends) > 0 {
+		for _, name := range policy.Backends {
+			if _, ok := available[name]; !ok {
+				return nil, NewInvalidRequestError("manager", fmt.Sprintf("backend %q is not connected", name), nil)
+			}
+			names = append(names, name)
+		}
+	} else {
+		for name := range available {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			pi, pj := m.backendPriority(names[i]), m.backendPriority(names[j])
+			if pi != pj {
+				return pi > pj
+			}
+			return names[i] < names[j]
+		})
+	}
+
+	if len(names) == 0 {
+		return nil, NewInvalidRequestError("manager", "no connected backends to pin on", nil)
+	}
+	if policy.Replicas > 0 && policy.Replicas < len(names) {
+		names = names[:policy.Replicas]
+	}
+	return names, nil
+}
+
+// backendPriority returns the configured priority of a backend
+func (m *Manager) backendPriority(name string) int {
+	if config, ok := m.config.Backends[name]; ok {
+		return config.Priority
+	}
+	return 0
+}
+
+// PinIn pins a block on the named backend only
+func (m *Manager) PinIn(ctx context.Context, name string, address *BlockAddress) error {
+	backend, err := m.connectedBackend(name)
+	if err != nil {
+		return err
+	}
+
+	backendAddress := *address
+	backendAddress.BackendType = backend.GetBackendInfo().Type
+	return backend.Pin(ctx, &backendAddress)
+}
+
+// UnpinIn unpins a block from the named backend only
+func (m *Manager) UnpinIn(ctx context.Context, name string, address *BlockAddress) error {
+	backend, err := m.connectedBackend(name)
+	if err != nil {
+		return err
+	}
+
+	backendAddress := *address
+	backendAddress.BackendType = backend.GetBackendInfo().Type
+	return backend.Unpin(ctx, &backendAddress)
+}
+
+// ProvideIn announces a block from the named backend only. It reports
+// false, without an error, when the backend can't announce blocks.
+func (m *Manager) ProvideIn(ctx context.Context, name string, address *BlockAddress) (bool, error) {
+	backend, err := m.connectedBackend(name)
+	if err != nil {
+		return false, err
+	}
+	provider, ok := backend.(BlockProvider)
+	if !ok {
+		return false, nil
+	}
+
+	backendAddress := *address
+	backendAddress.BackendType = backend.GetBackendInfo().Type
+	return true, provider.Provide(ctx, &backendAddress)
+}
+
+// Replicas returns the names of the connected backends holding a block,
+// sorted by name
+func (m *Manager) Replicas(ctx context.Context, address *BlockAddress) []string {
+	var names []string
+	for name, backend := range m.GetAvailableBackends() {
+		backendAddress := *address
+		backendAddress.BackendType = backend.GetBackendInfo().Type
+		if exists, err := backend.Has(ctx, &backendAddress); err == nil && exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// connectedBackend returns the named backend if it is connected
+func (m *Manager) connectedBackend(name string) (Backend, error) {
+	if !m.started {
+		return nil, NewInvalidRequestError("manager", "storage manager not started", nil)
+	}
+	backend, ok := m.GetBackend(name)
+	if !ok {
+		return nil, NewInvalidRequestError("manager", fmt.Sprintf("unknown backend %q", name), nil)
+	}
+	if !backend.IsConnected() {
+		return nil, NewConnectionError(backend.GetBackendInfo().Type, nil)
+	}
+	return backend, nil
+}
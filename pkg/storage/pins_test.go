@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+func TestPinRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins.json")
+	registry, err := NewPinRegistry(path)
+	if err != nil {
+		t.Fatalf("NewPinRegistry failed: %v", err)
+	}
+
+	now := time.Now()
+	first := PinRecord{DescriptorCID: "desc-1", Blocks: []string{"desc-1", "data-1", "shared"}, Randomizers: []string{"rand-1", "rand-shared"}, PinnedAt: now}
+	second := PinRecord{DescriptorCID: "desc-2", Blocks: []string{"desc-2", "shared"}, Randomizers: []string{"rand-shared"}, PinnedAt: now.Add(time.Second)}
+	if err := registry.Add(first); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := registry.Add(second); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	reopened, err := NewPinRegistry(path)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	if all := reopened.All(); len(all) != 2 || all[0].DescriptorCID != "desc-1" {
+		t.Fatalf("Expected both pins oldest first, got %+v", all)
+	}
+
+	// Blocks the other pin shares stay pinned
+	blocks, randomizers, err := reopened.Releasable("desc-1")
+	if err != nil {
+		t.Fatalf("Releasable failed: %v", err)
+	}
+	if !reflect.DeepEqual(blocks, []string{"desc-1", "data-1"}) || !reflect.DeepEqual(randomizers, []string{"rand-1"}) {
+		t.Errorf("Releasable returned %v and %v", blocks, randomizers)
+	}
+	if err := reopened.Remove("desc-1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, ok := reopened.Get("desc-1"); ok {
+		t.Error("Expected the removed pin to be gone")
+	}
+	if err := reopened.Remove("desc-1"); err == nil {
+		t.Error("Expected removing an unknown pin to fail")
+	}
+	if _, _, err := reopened.Releasable("desc-1"); err == nil {
+		t.Error("Expected an unknown pin to have nothing releasable")
+	}
+
+	blocks, _, _ = reopened.Releasable("desc-2")
+	if !reflect.DeepEqual(blocks, []string{"desc-2", "shared"}) {
+		t.Errorf("The last pin released %v", blocks)
+	}
+}
+
+func TestManagerPinTargetsAndReplicas(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewMockBackend("primary"), NewMockBackend("secondary")
+	primary.latency, secondary.latency = 0, 0
+
+	config := DefaultConfig()
+	primaryConfig, secondaryConfig := CustomBackendConfig(primary), CustomBackendConfig(secondary)
+	secondaryConfig.Priority = 50
+	config.Backends = map[string]*BackendConfig{"primary": primaryConfig, "secondary": secondaryConfig}
+	config.DefaultBackend = "primary"
+	config.HealthCheck.Enabled = false
+
+	manager, err := NewManager(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Stop(ctx)
+
+	if targets, err := manager.PinTargets(PinPolicy{}); err != nil || !reflect.DeepEqual(targets, []string{"primary", "secondary"}) {
+		t.Errorf("PinTargets(all) = %v, %v", targets, err)
+	}
+	if targets, _ := manager.PinTargets(PinPolicy{Replicas: 1}); !reflect.DeepEqual(targets, []string{"primary"}) {
+		t.Errorf("Expected one replica on the highest priority backend, got %v", targets)
+	}
+	if targets, _ := manager.PinTargets(PinPolicy{Backends: []string{"secondary"}}); !reflect.DeepEqual(targets, []string{"secondary"}) {
+		t.Errorf("Expected only the named backend, got %v", targets)
+	}
+	if _, err := manager.PinTargets(PinPolicy{Backends: []string{"missing"}}); err == nil {
+		t.Error("Expected an unknown backend to be rejected")
+	}
+
+	block, _ := blocks.NewBlock([]byte("replicated"))
+	address, err := primary.Put(ctx, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas := manager.Replicas(ctx, address); !reflect.DeepEqual(replicas, []string{"primary"}) {
+		t.Errorf("Replicas = %v, want [primary]", replicas)
+	}
+	if err := manager.PinIn(ctx, "primary", address); err != nil {
+		t.Errorf("PinIn failed: %v", err)
+	}
+	if err := manager.PinIn(ctx, "secondary", address); err == nil {
+		t.Error("Expected pinning a block the backend can't find to fail")
+	}
+
+	secondary.Put(ctx, block)
+	if replicas := manager.Replicas(ctx, address); len(replicas) != 2 {
+		t.Errorf("Expected two replicas, got %v", replicas)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import "context"
+
+// Block roles describe what a stored block is used for, so backends that
+// announce blocks to the network can treat them differently
+const (
+	BlockRoleData       = "data"       // Anonymized file data and descriptors
+	BlockRoleRandomizer = "randomizer" // Randomizers, reused across many files
+)
+
+// Provide strategies select which stored blocks a node announces as a
+// provider
+const (
+	ProvideStrategyAll         = "all"         // Every block
+	ProvideStrategyRandomizers = "randomizers" // Only randomizer blocks
+	ProvideStrategyNone        = "none"        // Nothing; peers find blocks through other providers or Bitswap
+)
+
+type blockRoleKey struct{}
+
+// WithBlockRole returns a context that tells Put what role the block plays
+func WithBlockRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, blockRoleKey{}, role)
+}
+
+// BlockRoleFromContext returns the role set with WithBlockRole, or
+// BlockRoleData if none was set
+func BlockRoleFromContext(ctx context.Context) string {
+	if role, ok := ctx.Value(blockRoleKey{}).(string); ok && role != "" {
+		return role
+	}
+	return BlockRoleData
+}
+
+// ShouldProvide reports whether a block with the given role is announced
+// under a provide strategy. An empty strategy provides everything.
+func ShouldProvide(strategy, role string) bool {
+	switch strategy {
+	case "", ProvideStrategyAll:
+		return true
+	case ProvideStrategyRandomizers:
+		return role == BlockRoleRandomizer
+	default:
+		return false
+	}
+}
+
+// ValidProvideStrategy reports whether strategy is a known provide strategy
+func ValidProvideStrategy(strategy string) bool {
+	switch strategy {
+	case "", ProvideStrategyAll, ProvideStrategyRandomizers, ProvideStrategyNone:
+		return true
+	}
+	return false
+}
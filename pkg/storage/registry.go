@@ -16,6 +16,16 @@ var backendRegistry = struct {
 	constructors: make(map[string]BackendConstructor),
 }
 
+func init() {
+	RegisterBackend(BackendTypeCustom, func(config *BackendConfig) (Backend, error) {
+		backend, ok := config.Settings[CustomSettingBackend].(Backend)
+		if !ok || backend == nil {
+			return nil, fmt.Errorf("custom backend configuration has no backend")
+		}
+		return backend, nil
+	})
+}
+
 // RegisterBackend registers a backend constructor
 func RegisterBackend(backendType string, constructor BackendConstructor) {
 	backendRegistry.Lock()
@@ -0,0 +1,406 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+)
+
+// SnapshotRecord is a locally tracked directory snapshot
+type SnapshotRecord struct {
+	SnapshotCID    string    `json:"snapshot_cid"`
+	OriginalCID    string    `json:"original_cid"`
+	SnapshotName   string    `json:"snapshot_name"`
+	Description    string    `json:"description,omitempty"`
+	KeyEnvelopeCID string    `json:"key_envelope_cid,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	References     []string  `json:"references"` // Entry CIDs referenced by the snapshot manifest
+}
+
+// SnapshotRegistry persists the snapshots created on this device so that
+// they can be listed and pruned per directory
+type SnapshotRegistry struct {
+	path    string
+	records []SnapshotRecord
+	mu      sync.Mutex
+}
+
+// NewSnapshotRegistry loads the registry at path. A missing file yields an empty registry.
+func NewSnapshotRegistry(path string) (*SnapshotRegistry, error) {
+	registry := &SnapshotRegistry{
+		path:    path,
+		records: make([]SnapshotRecord, 0),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot registry: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &registry.records); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot registry: %w", err)
+	}
+
+	return registry, nil
+}
+
+// Add records a snapshot, replacing any existing record with the same CID
+func (r *SnapshotRegistry) Add(record SnapshotRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.records {
+		if r.records[i].SnapshotCID == record.SnapshotCID {
+			r.records[i] = record
+			return r.save()
+		}
+	}
+
+	r.records = append(r.records, record)
+	return r.save()
+}
+
+// ForDirectory returns the snapshots of a directory, newest first
+func (r *SnapshotRegistry) ForDirectory(originalCID string) []SnapshotRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]SnapshotRecord, 0)
+	for _, record := range r.records {
+		if record.OriginalCID == originalCID {
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	return records
+}
+
+// All returns every registered snapshot
+func (r *SnapshotRegistry) All() []SnapshotRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := make([]SnapshotRecord, len(r.records))
+	copy(records, r.records)
+	return records
+}
+
+// Remove deletes the records for the given snapshot CIDs
+func (r *SnapshotRegistry) Remove(snapshotCIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remove := make(map[string]bool, len(snapshotCIDs))
+	for _, cid := range snapshotCIDs {
+		remove[cid] = true
+	}
+
+	kept := r.records[:0]
+	for _, record := range r.records {
+		if !remove[record.SnapshotCID] {
+			kept = append(kept, record)
+		}
+	}
+	r.records = kept
+
+	return r.save()
+}
+
+// save writes the registry atomically. Caller must hold mu.
+func (r *SnapshotRegistry) save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create snapshot registry directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot registry: %w", err)
+	}
+
+	tempFile := r.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot registry: %w", err)
+	}
+	if err := os.Rename(tempFile, r.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save snapshot registry: %w", err)
+	}
+
+	return nil
+}
+
+// ManifestReferences returns the entry CIDs referenced by a directory manifest
+func ManifestReferences(manifest *blocks.DirectoryManifest) []string {
+	entries := manifest.GetSnapshot().Entries
+	references := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		references = append(references, entry.CID)
+	}
+	return references
+}
+
+// RetentionPolicy decides which snapshots of a directory are kept.
+// A snapshot is kept if any rule selects it; zero disables a rule.
+type RetentionPolicy struct {
+	KeepLast   int `json:"keep_last"`
+	KeepDaily  int `json:"keep_daily"`
+	KeepWeekly int `json:"keep_weekly"`
+}
+
+// IsEmpty reports whether the policy has no rules, in which case everything is kept
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0
+}
+
+// RetentionDecision is the outcome of a retention policy for one snapshot
+type RetentionDecision struct {
+	Record  SnapshotRecord `json:"record"`
+	Keep    bool           `json:"keep"`
+	Reasons []string       `json:"reasons,omitempty"`
+}
+
+// Apply evaluates the policy against records and returns a decision for each, newest first
+func (p RetentionPolicy) Apply(records []SnapshotRecord) []RetentionDecision {
+	decisions := make([]RetentionDecision, len(records))
+	for i, record := range records {
+		decisions[i] = RetentionDecision{Record: record}
+	}
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].Record.CreatedAt.After(decisions[j].Record.CreatedAt)
+	})
+
+	if p.IsEmpty() {
+		for i := range decisions {
+			decisions[i].Keep = true
+			decisions[i].Reasons = []string{"no policy"}
+		}
+		return decisions
+	}
+
+	for i := range decisions {
+		if i < p.KeepLast {
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, "last")
+		}
+	}
+
+	p.keepBuckets(decisions, p.KeepDaily, "daily", func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	p.keepBuckets(decisions, p.KeepWeekly, "weekly", func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	return decisions
+}
+
+// keepBuckets keeps the newest snapshot in each of the newest limit buckets
+func (p RetentionPolicy) keepBuckets(decisions []RetentionDecision, limit int, reason string, bucket func(time.Time) string) {
+	if limit <= 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i := range decisions {
+		key := bucket(decisions[i].Record.CreatedAt)
+		if seen[key] {
+			continue
+		}
+		if len(seen) >= limit {
+			return
+		}
+		seen[key] = true
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, reason)
+	}
+}
+
+// PruneOptions controls PruneSnapshots
+type PruneOptions struct {
+	Policy RetentionPolicy
+	// LiveReferences are CIDs still referenced by the current directory. When
+	// nil the live directory is unknown and entry CIDs are never unpinned.
+	LiveReferences map[string]bool
+	// Retained are descriptor CIDs used outside snapshots, such as files in
+	// the index and pins. They and their blocks stay pinned.
+	Retained map[string]bool
+	// DescriptorBlocks returns the data and randomizer block CIDs of a
+	// descriptor. Without it only descriptors are unpinned, never blocks.
+	DescriptorBlocks func(ctx context.Context, descriptorCID string) ([]string, error)
+	DryRun           bool
+}
+
+// PruneResult reports what PruneSnapshots removed
+type PruneResult struct {
+	Kept     []SnapshotRecord `json:"kept"`
+	Pruned   []SnapshotRecord `json:"pruned"`
+	Unpinned []string         `json:"unpinned"`
+	Errors   []string         `json:"errors,omitempty"`
+	DryRun   bool             `json:"dry_run"`
+}
+
+// PruneSnapshots applies a retention policy to the registered snapshots of a
+// directory. Expired snapshots are removed from the registry and their
+// manifest and key envelope blocks are unpinned, together with entry
+// descriptors that no kept snapshot, the live directory or opts.Retained
+// still references, and the data and randomizer blocks of those
+// descriptors that no retained descriptor uses. Randomizers are shared
+// between files, so blocks are only unpinned when the blocks of every
+// retained descriptor are known.
+func (dm *DirectoryManager) PruneSnapshots(ctx context.Context, registry *SnapshotRegistry, originalCID string, opts PruneOptions) (*PruneResult, error) {
+	result := &PruneResult{
+		Kept:     make([]SnapshotRecord, 0),
+		Pruned:   make([]SnapshotRecord, 0),
+		Unpinned: make([]string, 0),
+		DryRun:   opts.DryRun,
+	}
+
+	// Collect references still held by the live directory, other uses,
+	// snapshots of other directories and kept snapshots
+	retained := make(map[string]bool)
+	for cid := range opts.LiveReferences {
+		retained[cid] = true
+	}
+	for cid := range opts.Retained {
+		retained[cid] = true
+	}
+	for _, record := range registry.All() {
+		if record.OriginalCID != originalCID {
+			for _, cid := range record.References {
+				retained[cid] = true
+			}
+		}
+	}
+
+	for _, decision := range opts.Policy.Apply(registry.ForDirectory(originalCID)) {
+		if decision.Keep {
+			result.Kept = append(result.Kept, decision.Record)
+			for _, cid := range decision.Record.References {
+				retained[cid] = true
+			}
+		} else {
+			result.Pruned = append(result.Pruned, decision.Record)
+		}
+	}
+
+	if len(result.Pruned) == 0 {
+		return result, nil
+	}
+
+	// Determine descriptors only referenced by pruned snapshots
+	unpin := make([]string, 0)
+	seen := make(map[string]bool)
+	addUnpin := func(cid string) {
+		if cid == "" || seen[cid] || retained[cid] {
+			return
+		}
+		seen[cid] = true
+		unpin = append(unpin, cid)
+	}
+
+	prunedCIDs := make([]string, 0, len(result.Pruned))
+	var expired []string
+	for _, record := range result.Pruned {
+		prunedCIDs = append(prunedCIDs, record.SnapshotCID)
+		addUnpin(record.SnapshotCID)
+		addUnpin(record.KeyEnvelopeCID)
+		if opts.LiveReferences != nil {
+			for _, cid := range record.References {
+				if !seen[cid] && !retained[cid] {
+					expired = append(expired, cid)
+				}
+				addUnpin(cid)
+			}
+		}
+	}
+
+	// Then the blocks of those descriptors that nothing retained uses
+	if opts.DescriptorBlocks != nil && len(expired) > 0 {
+		// The directory's own entries are the live references
+		walk := make(map[string]bool, len(retained))
+		for cid := range retained {
+			if cid != originalCID {
+				walk[cid] = true
+			}
+		}
+		blocks, err := expiredBlocks(ctx, opts.DescriptorBlocks, walk, expired)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("blocks kept: %v", err))
+		}
+		for _, cid := range blocks {
+			addUnpin(cid)
+		}
+	}
+
+	if opts.DryRun {
+		result.Unpinned = unpin
+		return result, nil
+	}
+
+	for _, cid := range unpin {
+		address := &BlockAddress{
+			ID:          cid,
+			BackendType: dm.storageManager.config.DefaultBackend,
+		}
+		if err := dm.storageManager.Unpin(ctx, address); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", cid, err))
+			continue
+		}
+		result.Unpinned = append(result.Unpinned, cid)
+	}
+
+	if err := registry.Remove(prunedCIDs); err != nil {
+		return result, fmt.Errorf("failed to update snapshot registry: %w", err)
+	}
+
+	return result, nil
+}
+
+// expiredBlocks returns the blocks of expired descriptors that no retained
+// descriptor uses. If any retained descriptor can't be read its blocks are
+// unknown, so nothing is returned.
+func expiredBlocks(ctx context.Context, descriptorBlocks func(context.Context, string) ([]string, error), retained map[string]bool, expired []string) ([]string, error) {
+	inUse := make(map[string]bool)
+	for cid := range retained {
+		blocks, err := descriptorBlocks(ctx, cid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read retained descriptor %s: %w", cid, err)
+		}
+		for _, block := range blocks {
+			inUse[block] = true
+		}
+	}
+
+	var unused []string
+	var failed []string
+	for _, cid := range expired {
+		blocks, err := descriptorBlocks(ctx, cid)
+		if err != nil {
+			failed = append(failed, cid)
+			continue
+		}
+		for _, block := range blocks {
+			if !inUse[block] {
+				unused = append(unused, block)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return unused, fmt.Errorf("failed to read descriptors %v", failed)
+	}
+	return unused, nil
+}
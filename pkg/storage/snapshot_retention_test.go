@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func snapshotRecordsAt(originalCID string, times ...time.Time) []SnapshotRecord {
+	records := make([]SnapshotRecord, 0, len(times))
+	for i, created := range times {
+		records = append(records, SnapshotRecord{
+			SnapshotCID:  fmt.Sprintf("snap-%d", i),
+			OriginalCID:  originalCID,
+			SnapshotName: fmt.Sprintf("snapshot-%d", i),
+			CreatedAt:    created,
+		})
+	}
+	return records
+}
+
+func TestRetentionPolicy_Apply(t *testing.T) {
+	base := time.Date(2026, 3, 20, 18, 0, 0, 0, time.UTC) // Friday
+	records := snapshotRecordsAt("dir",
+		base,                                 // snap-0: today, newest
+		base.Add(-2*time.Hour),               // snap-1: today
+		base.Add(-24*time.Hour),              // snap-2: yesterday
+		base.Add(-26*time.Hour),              // snap-3: yesterday
+		base.Add(-3*24*time.Hour),            // snap-4: Tuesday
+		base.Add(-8*24*time.Hour),            // snap-5: previous week
+		base.Add(-15*24*time.Hour),           // snap-6: two weeks ago
+		base.Add(-15*24*time.Hour-time.Hour), // snap-7: two weeks ago
+	)
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		kept   []string
+	}{
+		{"empty policy keeps everything", RetentionPolicy{}, []string{"snap-0", "snap-1", "snap-2", "snap-3", "snap-4", "snap-5", "snap-6", "snap-7"}},
+		{"keep last", RetentionPolicy{KeepLast: 2}, []string{"snap-0", "snap-1"}},
+		{"keep daily", RetentionPolicy{KeepDaily: 3}, []string{"snap-0", "snap-2", "snap-4"}},
+		{"keep weekly", RetentionPolicy{KeepWeekly: 3}, []string{"snap-0", "snap-5", "snap-6"}},
+		{"combined", RetentionPolicy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 2}, []string{"snap-0", "snap-2", "snap-5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := make([]string, 0)
+			for _, decision := range tt.policy.Apply(records) {
+				if decision.Keep {
+					kept = append(kept, decision.Record.SnapshotCID)
+				}
+			}
+			if fmt.Sprint(kept) != fmt.Sprint(tt.kept) {
+				t.Errorf("Expected kept %v, got %v", tt.kept, kept)
+			}
+		})
+	}
+}
+
+func TestSnapshotRegistry_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.json")
+
+	registry, err := NewSnapshotRegistry(path)
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+
+	now := time.Now()
+	for _, record := range append(snapshotRecordsAt("dir-a", now.Add(-time.Hour), now), SnapshotRecord{SnapshotCID: "other", OriginalCID: "dir-b", CreatedAt: now}) {
+		if err := registry.Add(record); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	reloaded, err := NewSnapshotRegistry(path)
+	if err != nil {
+		t.Fatalf("Failed to reload registry: %v", err)
+	}
+
+	records := reloaded.ForDirectory("dir-a")
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records for dir-a, got %d", len(records))
+	}
+	if records[0].SnapshotCID != "snap-1" {
+		t.Errorf("Expected newest snapshot first, got %s", records[0].SnapshotCID)
+	}
+
+	if err := reloaded.Remove([]string{"snap-0"}); err != nil {
+		t.Fatalf("Failed to remove record: %v", err)
+	}
+	if len(reloaded.All()) != 2 {
+		t.Errorf("Expected 2 records after removal, got %d", len(reloaded.All()))
+	}
+}
+
+func TestDirectoryManager_PruneSnapshots(t *testing.T) {
+	manager := createTestStorageManager(t)
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer manager.Stop(context.Background())
+
+	dirManager, err := NewDirectoryManager(manager, createTestEncryptionKey(t), DefaultDirectoryManagerConfig())
+	if err != nil {
+		t.Fatalf("Failed to create directory manager: %v", err)
+	}
+
+	registry, err := NewSnapshotRegistry(filepath.Join(t.TempDir(), "snapshots.json"))
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+
+	now := time.Now()
+	records := snapshotRecordsAt("dir", now, now.Add(-time.Hour), now.Add(-2*time.Hour))
+	records[0].References = []string{"shared", "new"}
+	records[1].References = []string{"shared", "old-only", "live"}
+	records[2].References = []string{"other-dir"}
+	records[2].KeyEnvelopeCID = "envelope-2"
+	for _, record := range append(records, SnapshotRecord{SnapshotCID: "b", OriginalCID: "dir-b", CreatedAt: now, References: []string{"other-dir"}}) {
+		if err := registry.Add(record); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	opts := PruneOptions{
+		Policy:         RetentionPolicy{KeepLast: 1},
+		LiveReferences: map[string]bool{"live": true},
+		DryRun:         true,
+	}
+
+	result, err := dirManager.PruneSnapshots(context.Background(), registry, "dir", opts)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(result.Kept) != 1 || len(result.Pruned) != 2 {
+		t.Fatalf("Expected 1 kept and 2 pruned, got %d and %d", len(result.Kept), len(result.Pruned))
+	}
+
+	// Shared, live and other-directory references stay pinned
+	expected := map[string]bool{"snap-1": true, "old-only": true, "snap-2": true, "envelope-2": true}
+	if len(result.Unpinned) != len(expected) {
+		t.Errorf("Expected unpinned %v, got %v", expected, result.Unpinned)
+	}
+	for _, cid := range result.Unpinned {
+		if !expected[cid] {
+			t.Errorf("Unexpected unpin of %s", cid)
+		}
+	}
+	if len(registry.ForDirectory("dir")) != 3 {
+		t.Error("Dry run should not modify the registry")
+	}
+
+	// Without live references entry CIDs are never unpinned
+	opts.LiveReferences = nil
+	opts.DryRun = false
+	result, err = dirManager.PruneSnapshots(context.Background(), registry, "dir", opts)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	for _, cid := range result.Unpinned {
+		if cid == "old-only" {
+			t.Error("Entry CIDs should not be unpinned when the live directory is unknown")
+		}
+	}
+	if remaining := registry.ForDirectory("dir"); len(remaining) != 1 || remaining[0].SnapshotCID != "snap-0" {
+		t.Errorf("Expected only snap-0 to remain, got %v", remaining)
+	}
+	if len(registry.ForDirectory("dir-b")) != 1 {
+		t.Error("Snapshots of other directories should not be pruned")
+	}
+}
+
+func TestDirectoryManager_PruneSnapshotsBlocks(t *testing.T) {
+	manager := createTestStorageManager(t)
+	if err := manager.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start storage manager: %v", err)
+	}
+	defer manager.Stop(context.Background())
+
+	dirManager, err := NewDirectoryManager(manager, createTestEncryptionKey(t), DefaultDirectoryManagerConfig())
+	if err != nil {
+		t.Fatalf("Failed to create directory manager: %v", err)
+	}
+
+	registry, err := NewSnapshotRegistry(filepath.Join(t.TempDir(), "snapshots.json"))
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+	now := time.Now()
+	records := snapshotRecordsAt("dir", now, now.Add(-time.Hour))
+	records[0].References = []string{"kept-file"}
+	records[1].References = []string{"old-file", "indexed-file"}
+	for _, record := range records {
+		if err := registry.Add(record); err != nil {
+			t.Fatalf("Failed to add record: %v", err)
+		}
+	}
+
+	// Randomizers are shared between files, and so is a data block here
+	blocks := map[string][]string{
+		"kept-file":    {"data-kept", "rand-shared-a", "rand-kept"},
+		"old-file":     {"data-old", "rand-shared-a", "rand-shared-b", "rand-old", "data-indexed"},
+		"indexed-file": {"data-indexed", "rand-shared-b"},
+	}
+	descriptorBlocks := func(ctx context.Context, cid string) ([]string, error) {
+		if list, ok := blocks[cid]; ok {
+			return list, nil
+		}
+		return nil, fmt.Errorf("not a file descriptor")
+	}
+
+	opts := PruneOptions{
+		Policy:           RetentionPolicy{KeepLast: 1},
+		LiveReferences:   map[string]bool{},
+		Retained:         map[string]bool{"indexed-file": true, "dir": true},
+		DescriptorBlocks: descriptorBlocks,
+		DryRun:           true,
+	}
+	result, err := dirManager.PruneSnapshots(context.Background(), registry, "dir", opts)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+
+	// The indexed file is still in use, and so are blocks of kept files
+	expected := map[string]bool{"snap-1": true, "old-file": true, "data-old": true, "rand-old": true}
+	if len(result.Unpinned) != len(expected) || len(result.Errors) != 0 {
+		t.Errorf("Expected unpinned %v, got %v (errors %v)", expected, result.Unpinned, result.Errors)
+	}
+	for _, cid := range result.Unpinned {
+		if !expected[cid] {
+			t.Errorf("Unexpected unpin of %s", cid)
+		}
+	}
+
+	// When a retained descriptor can't be read its blocks are unknown, so
+	// no block is unpinned
+	opts.Retained["unreadable"] = true
+	result, err = dirManager.PruneSnapshots(context.Background(), registry, "dir", opts)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected the unreadable descriptor to be reported, got %v", result.Errors)
+	}
+	for _, cid := range result.Unpinned {
+		if cid == "data-old" || cid == "rand-old" {
+			t.Errorf("Expected blocks to stay pinned, got %s unpinned", cid)
+		}
+	}
+}
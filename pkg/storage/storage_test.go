@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -672,4 +673,60 @@ func BenchmarkManagerOperations(b *testing.B) {
 			b.Fatalf("Get failed: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+// countingLimiter counts the transfers and bytes charged to it
+type countingLimiter struct {
+	mu        sync.Mutex
+	transfers int
+	bytes     int
+}
+
+func (l *countingLimiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transfers++
+	return nil
+}
+
+func (l *countingLimiter) Release() {}
+
+func (l *countingLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bytes += n
+	return nil
+}
+
+func TestManagerContextTransferLimiter(t *testing.T) {
+	manager := createMockManager(t)
+	ctx := context.Background()
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Failed to start manager: %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	installed := &countingLimiter{}
+	manager.SetTransferLimiter(installed)
+	scoped := &countingLimiter{}
+	limitedCtx := WithTransferLimiter(ctx, scoped)
+
+	block, _ := blocks.NewBlock([]byte("limited"))
+	address, err := manager.Put(limitedCtx, block)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := manager.Get(limitedCtx, address); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, err := manager.Get(ctx, address); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// The scoped limiter only sees transfers made with its context
+	if scoped.transfers != 2 || scoped.bytes != 2*block.Size() {
+		t.Errorf("Scoped limiter charged %d transfers and %d bytes, want 2 and %d", scoped.transfers, scoped.bytes, 2*block.Size())
+	}
+	if installed.transfers != 3 || installed.bytes != 3*block.Size() {
+		t.Errorf("Installed limiter charged %d transfers and %d bytes, want 3 and %d", installed.transfers, installed.bytes, 3*block.Size())
+	}
+}
@@ -16,6 +16,7 @@ import (
 type DirectoryScanner struct {
 	directoryManager *storage.DirectoryManager
 	stateComparator  *StateComparator
+	rules            *SyncRules
 }
 
 // NewDirectoryScanner creates a new directory scanner
@@ -26,6 +27,11 @@ func NewDirectoryScanner(directoryManager *storage.DirectoryManager) *DirectoryS
 	}
 }
 
+// SetRules sets the include/exclude rules applied to local and remote scans
+func (ds *DirectoryScanner) SetRules(rules *SyncRules) {
+	ds.rules = rules
+}
+
 // ScanResult contains the results of a directory scan
 type ScanResult struct {
 	LocalSnapshot  map[string]FileMetadata   `json:"local_snapshot"`
@@ -85,6 +91,10 @@ func (ds *DirectoryScanner) PerformInitialScan(ctx context.Context, localPath, r
 		result.Changes = ds.generateInitialChanges(result.LocalSnapshot, result.RemoteSnapshot)
 	}
 
+	// A previous state may hold paths excluded since, which would otherwise
+	// look deleted
+	result.Changes = ds.withoutExcluded(result.Changes)
+
 	result.ScanDuration = time.Since(startTime)
 	return result, nil
 }
@@ -111,6 +121,14 @@ func (ds *DirectoryScanner) ScanLocalDirectory(rootPath string) (map[string]File
 			return nil
 		}
 
+		// Skip paths excluded by the sync pair's rules
+		if ds.rules.IsExcluded(relativePath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Create file metadata
 		metadata := FileMetadata{
 			Path:        relativePath,
@@ -175,12 +193,39 @@ func (ds *DirectoryScanner) ScanRemoteDirectory(ctx context.Context, remotePath,
 			Version:       1, // TODO: Implement proper versioning
 		}
 
+		// Excluded remote paths are never downloaded
+		if ds.rules.IsExcluded(filename, metadata.IsDir) {
+			continue
+		}
+
 		snapshot[filename] = metadata
 	}
 
 	return snapshot, nil
 }
 
+// withoutExcluded drops changes to paths the sync pair's rules exclude
+func (ds *DirectoryScanner) withoutExcluded(changes []DetectedChange) []DetectedChange {
+	kept := changes[:0]
+	for _, change := range changes {
+		isDir := false
+		switch metadata := change.Metadata.(type) {
+		case FileMetadata:
+			isDir = metadata.IsDir
+		case RemoteMetadata:
+			isDir = metadata.IsDir
+		}
+		if ds.rules.IsExcluded(change.Path, isDir) {
+			continue
+		}
+		if change.OldPath != "" && ds.rules.IsExcluded(change.OldPath, isDir) {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept
+}
+
 // generateInitialChanges generates changes for initial sync when no previous state exists
 func (ds *DirectoryScanner) generateInitialChanges(localSnapshot map[string]FileMetadata, remoteSnapshot map[string]RemoteMetadata) []DetectedChange {
 	var changes []DetectedChange
@@ -215,7 +215,10 @@ func (m *ManifestUpdateManager) worker() {
 		select {
 		case <-m.ctx.Done():
 			return
-		case request := <-m.updateQueue:
+		case request, ok := <-m.updateQueue:
+			if !ok {
+				return
+			}
 			m.processUpdateRequest(request)
 		}
 	}
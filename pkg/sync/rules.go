@@ -0,0 +1,260 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SyncRule is a single gitignore-style pattern from a sync pair's rules file
+type SyncRule struct {
+	Pattern  string `json:"pattern"`
+	Negate   bool   `json:"negate"`
+	DirOnly  bool   `json:"dir_only"`
+	Anchored bool   `json:"anchored"`
+}
+
+// SyncRules holds the ordered include/exclude rules for a sync pair.
+// Rules are evaluated in order and the last matching rule wins, so a
+// later "!pattern" re-includes paths excluded by an earlier pattern.
+type SyncRules struct {
+	Rules []SyncRule `json:"rules"`
+}
+
+// ParseSyncRules parses gitignore-style rules from a reader.
+// Blank lines and lines starting with '#' are ignored.
+func ParseSyncRules(r io.Reader) (*SyncRules, error) {
+	rules := &SyncRules{Rules: make([]SyncRule, 0)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := rules.Add(scanner.Text()); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sync rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// LoadSyncRules loads rules from a file. A missing file yields an empty rule set.
+func LoadSyncRules(rulesFile string) (*SyncRules, error) {
+	file, err := os.Open(rulesFile)
+	if os.IsNotExist(err) {
+		return &SyncRules{Rules: make([]SyncRule, 0)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sync rules file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseSyncRules(file)
+}
+
+// Add parses a single rule line and appends it. Comments and blank lines are ignored.
+func (sr *SyncRules) Add(line string) error {
+	rule, ok, err := parseSyncRule(line)
+	if err != nil || !ok {
+		return err
+	}
+	sr.Rules = append(sr.Rules, rule)
+	return nil
+}
+
+// Remove deletes every rule equivalent to line and reports whether any were removed
+func (sr *SyncRules) Remove(line string) bool {
+	target, ok, err := parseSyncRule(line)
+	if err != nil || !ok {
+		return false
+	}
+
+	removed := false
+	kept := sr.Rules[:0]
+	for _, rule := range sr.Rules {
+		if rule == target {
+			removed = true
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	sr.Rules = kept
+	return removed
+}
+
+// parseSyncRule parses a rule line, returning ok=false for comments and blank lines
+func parseSyncRule(line string) (SyncRule, bool, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return SyncRule{}, false, nil
+	}
+
+	rule := SyncRule{}
+	if strings.HasPrefix(line, "!") {
+		rule.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// Escaped leading '!' or '#'
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash anywhere but the end anchors the pattern to the sync root
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return SyncRule{}, false, fmt.Errorf("invalid sync rule: empty pattern")
+	}
+	if _, err := path.Match(strings.ReplaceAll(line, "**", "*"), ""); err != nil {
+		return SyncRule{}, false, fmt.Errorf("invalid sync rule %q: %w", line, err)
+	}
+
+	rule.Pattern = line
+	return rule, true, nil
+}
+
+// IsExcluded reports whether a path relative to the sync root is excluded.
+// A path is also excluded when any of its parent directories is excluded.
+func (sr *SyncRules) IsExcluded(relPath string, isDir bool) bool {
+	if sr == nil || len(sr.Rules) == 0 {
+		return false
+	}
+
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	if relPath == "." || relPath == "" {
+		return false
+	}
+
+	// Check parent directories first; an excluded parent excludes its children
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if sr.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return sr.match(relPath, isDir)
+}
+
+// match evaluates the rules for a single path, last match wins
+func (sr *SyncRules) match(relPath string, isDir bool) bool {
+	excluded := false
+	for _, rule := range sr.Rules {
+		if rule.DirOnly && !isDir {
+			continue
+		}
+		if rule.matches(relPath) {
+			excluded = !rule.Negate
+		}
+	}
+	return excluded
+}
+
+// matches reports whether the rule's pattern matches the slash-separated path
+func (r SyncRule) matches(relPath string) bool {
+	if r.Anchored {
+		return matchGlob(r.Pattern, relPath)
+	}
+
+	// Unanchored patterns match against the basename or any trailing path
+	if matchGlob(r.Pattern, path.Base(relPath)) {
+		return true
+	}
+	parts := strings.Split(relPath, "/")
+	for i := range parts {
+		if matchGlob(r.Pattern, strings.Join(parts[i:], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a glob pattern with support for "**" spanning directories
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	return matchSegments(patternParts, nameParts)
+}
+
+// matchSegments matches path segments where "**" consumes zero or more segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// String returns the rule in its rules-file form
+func (r SyncRule) String() string {
+	var sb strings.Builder
+	if r.Negate {
+		sb.WriteString("!")
+	}
+	if r.Anchored && !strings.Contains(r.Pattern, "/") {
+		sb.WriteString("/")
+	}
+	sb.WriteString(r.Pattern)
+	if r.DirOnly {
+		sb.WriteString("/")
+	}
+	return sb.String()
+}
+
+// Lines returns the rules in rules-file form
+func (sr *SyncRules) Lines() []string {
+	lines := make([]string, 0, len(sr.Rules))
+	for _, rule := range sr.Rules {
+		lines = append(lines, rule.String())
+	}
+	return lines
+}
+
+// WriteTo writes the rules in rules-file form
+func (sr *SyncRules) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	n, err := fmt.Fprintln(w, "# NoiseFS sync rules (gitignore syntax)")
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+	for _, line := range sr.Lines() {
+		n, err := fmt.Fprintln(w, line)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
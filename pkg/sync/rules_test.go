@@ -0,0 +1,214 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncRules_IsExcluded(t *testing.T) {
+	rules, err := ParseSyncRules(strings.NewReader(`
+# caches and build output
+node_modules/
+/build
+*.mp4
+!keep.mp4
+docs/**/*.tmp
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"node_modules", true, true},
+		{"src/node_modules", true, true},
+		{"src/node_modules/pkg/index.js", false, true},
+		{"node_modules", false, false}, // dir-only rule does not match files
+		{"build", true, true},
+		{"build/out.o", false, true},
+		{"src/build", true, false}, // anchored to the sync root
+		{"video.mp4", false, true},
+		{"media/video.mp4", false, true},
+		{"keep.mp4", false, false},
+		{"docs/a/b/c.tmp", false, true},
+		{"docs/c.tmp", false, true},
+		{"other/c.tmp", false, false},
+		{"README.md", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.IsExcluded(tt.path, tt.isDir); got != tt.excluded {
+			t.Errorf("IsExcluded(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.excluded)
+		}
+	}
+}
+
+func TestSyncRules_AddRemove(t *testing.T) {
+	rules := &SyncRules{}
+
+	if err := rules.Add("/cache/"); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+	if err := rules.Add("[invalid"); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+
+	if got := rules.Lines(); len(got) != 1 || got[0] != "/cache/" {
+		t.Errorf("Unexpected rule lines: %v", got)
+	}
+
+	if !rules.Remove("/cache/") {
+		t.Error("Expected rule to be removed")
+	}
+	if rules.Remove("/cache/") {
+		t.Error("Expected no rule to be removed the second time")
+	}
+	if len(rules.Rules) != 0 {
+		t.Errorf("Expected no rules, got %d", len(rules.Rules))
+	}
+}
+
+func TestSyncStateStore_Rules(t *testing.T) {
+	store, err := NewSyncStateStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create state store: %v", err)
+	}
+
+	// Missing rules file yields empty rules
+	rules, err := store.LoadRules("pair-1")
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if len(rules.Rules) != 0 {
+		t.Errorf("Expected no rules, got %d", len(rules.Rules))
+	}
+
+	rules.Add("*.log")
+	rules.Add("!important.log")
+	if err := store.SaveRules("pair-1", rules); err != nil {
+		t.Fatalf("Failed to save rules: %v", err)
+	}
+
+	loaded, err := store.LoadRules("pair-1")
+	if err != nil {
+		t.Fatalf("Failed to reload rules: %v", err)
+	}
+	if len(loaded.Rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(loaded.Rules))
+	}
+
+	// Rules are per pair
+	other, err := store.LoadRules("pair-2")
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if len(other.Rules) != 0 {
+		t.Errorf("Expected rules to be isolated per pair, got %d", len(other.Rules))
+	}
+
+	// Rules file must not be listed as a sync state
+	ids, err := store.ListStates()
+	if err != nil {
+		t.Fatalf("Failed to list states: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("Expected no states, got %v", ids)
+	}
+}
+
+func TestDirectoryScanner_AppliesRules(t *testing.T) {
+	root := t.TempDir()
+	for _, p := range []string{"keep.txt", "cache/blob.bin", "src/main.go", "src/debug.log"} {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(p), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	rules, err := ParseSyncRules(strings.NewReader("cache/\n*.log\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	scanner := NewDirectoryScanner(nil)
+	scanner.SetRules(rules)
+
+	snapshot, err := scanner.ScanLocalDirectory(root)
+	if err != nil {
+		t.Fatalf("Failed to scan directory: %v", err)
+	}
+
+	for _, excluded := range []string{"cache", "cache/blob.bin", "src/debug.log"} {
+		if _, ok := snapshot[excluded]; ok {
+			t.Errorf("Expected %s to be excluded", excluded)
+		}
+	}
+	for _, included := range []string{"keep.txt", "src", "src/main.go"} {
+		if _, ok := snapshot[included]; !ok {
+			t.Errorf("Expected %s to be included", included)
+		}
+	}
+}
+
+func TestRulesApplyToRemotePaths(t *testing.T) {
+	rules, err := ParseSyncRules(strings.NewReader("node_modules/\n*.mkv\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	// Excluded remote files aren't downloaded on a scan
+	scanner := NewDirectoryScanner(nil)
+	scanner.SetRules(rules)
+	remote := map[string]RemoteMetadata{
+		"notes.txt":                {Path: "notes.txt"},
+		"movie.mkv":                {Path: "movie.mkv"},
+		"node_modules":             {Path: "node_modules", IsDir: true},
+		"node_modules/left-pad.js": {Path: "node_modules/left-pad.js"},
+	}
+	changes := scanner.withoutExcluded(scanner.generateInitialChanges(map[string]FileMetadata{}, remote))
+	if len(changes) != 1 || changes[0].Path != "notes.txt" {
+		t.Errorf("Expected only notes.txt to be downloaded, got %+v", changes)
+	}
+
+	// Nor are excluded remote events
+	stateStore, err := NewSyncStateStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPath := t.TempDir()
+	if err := stateStore.CreateInitialState("test-sync", localPath, "/remote"); err != nil {
+		t.Fatal(err)
+	}
+	session := &SyncSession{
+		SyncID:     "test-sync",
+		LocalPath:  localPath,
+		RemotePath: "/remote",
+		Rules:      rules,
+		Progress:   &SyncProgress{StartTime: time.Now()},
+	}
+	engine := &SyncEngine{
+		stateStore:  stateStore,
+		syncOpChan:  make(chan SyncOperation, 10),
+		activeSyncs: map[string]*SyncSession{"test-sync": session},
+		stats:       &SyncEngineStats{},
+		config:      &SyncConfig{},
+	}
+	for _, path := range []string{"/remote/node_modules/left-pad.js", "/remote/movie.mkv", "/remote/notes.txt"} {
+		engine.handleRemoteEvent(SyncEvent{Type: EventTypeFileCreated, Path: path, Timestamp: time.Now()})
+	}
+	if len(engine.syncOpChan) != 1 {
+		t.Fatalf("Expected one queued operation, got %d", len(engine.syncOpChan))
+	}
+	if op := <-engine.syncOpChan; op.RemotePath != "/remote/notes.txt" {
+		t.Errorf("Expected notes.txt to be queued, got %s", op.RemotePath)
+	}
+}
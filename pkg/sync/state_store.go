@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -110,6 +111,37 @@ func (s *SyncStateStore) DeleteState(syncID string) error {
 		return fmt.Errorf("failed to delete sync state file: %w", err)
 	}
 
+	// Remove rules file
+	if err := os.Remove(s.getRulesFile(syncID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete sync rules file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadRules loads the include/exclude rules for a sync pair.
+// A sync pair without a rules file has no rules.
+func (s *SyncStateStore) LoadRules(syncID string) (*SyncRules, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return LoadSyncRules(s.getRulesFile(syncID))
+}
+
+// SaveRules persists the include/exclude rules for a sync pair alongside its state
+func (s *SyncStateStore) SaveRules(syncID string, rules *SyncRules) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	if _, err := rules.WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to serialize sync rules: %w", err)
+	}
+
+	if err := os.WriteFile(s.getRulesFile(syncID), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write sync rules file: %w", err)
+	}
+
 	return nil
 }
 
@@ -243,6 +275,11 @@ func (s *SyncStateStore) getStateFile(syncID string) string {
 	return filepath.Join(s.stateDir, syncID+".json")
 }
 
+// getRulesFile returns the rules file path for a sync pair
+func (s *SyncStateStore) getRulesFile(syncID string) string {
+	return filepath.Join(s.stateDir, syncID+".syncignore")
+}
+
 // AtomicUpdateSnapshot atomically updates either local or remote snapshot
 func (s *SyncStateStore) AtomicUpdateSnapshot(syncID string, isLocal bool, updates map[string]interface{}) error {
 	tx, err := s.txManager.BeginTransaction(syncID)
@@ -11,8 +11,10 @@ import (
 
 	noisefs "github.com/TheEntropyCollective/noisefs/pkg/core/client"
 	"github.com/TheEntropyCollective/noisefs/pkg/core/crypto"
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/workers"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage"
 	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // SyncEngine coordinates bi-directional synchronization between local and remote directories
@@ -31,6 +33,12 @@ type SyncEngine struct {
 	remoteEventChan chan SyncEvent
 	syncOpChan      chan SyncOperation
 
+	// Worker pool bounding concurrent file operations
+	opPool *workers.Pool
+
+	// Limits on the block transfers of uploads and downloads (optional)
+	transferLimiter *workers.TransferLimiter
+
 	// Control and state
 	ctx         context.Context
 	cancel      context.CancelFunc
@@ -49,10 +57,23 @@ type SyncSession struct {
 	RemotePath  string
 	ManifestCID string
 	State       *SyncState
+	Rules       *SyncRules
 	LastSync    time.Time
 	Status      SyncStatus
 	Progress    *SyncProgress
 	mu          sync.RWMutex
+
+	// Operations queued and not yet finished, guarded by mu
+	queued int
+}
+
+// SyncSummary describes a session whose queued operations have all
+// finished, for SyncConfig.OnComplete
+type SyncSummary struct {
+	SyncID     string
+	LocalPath  string
+	RemotePath string
+	Progress   SyncProgress
 }
 
 // SyncStatus represents the current status of a sync session
@@ -72,6 +93,7 @@ type SyncProgress struct {
 	CompletedOperations int           `json:"completed_operations"`
 	FailedOperations    int           `json:"failed_operations"`
 	CurrentOperation    string        `json:"current_operation"`
+	BytesTransferred    int64         `json:"bytes_transferred"`
 	StartTime           time.Time     `json:"start_time"`
 	EstimatedCompletion time.Duration `json:"estimated_completion"`
 }
@@ -159,6 +181,32 @@ func NewSyncEngine(
 	}
 	engine.conflictResolver = conflictResolver
 
+	// Throttle the engine's own block transfers; the storage manager may
+	// be shared with other users that have limits of their own
+	if config.MaxBandwidth > 0 || config.MaxParallelBlocks > 0 {
+		engine.transferLimiter = workers.NewTransferLimiter(config.MaxBandwidth, config.MaxParallelBlocks)
+	}
+
+	// Create worker pool for file operations
+	parallelFiles := config.MaxParallelFiles
+	if parallelFiles <= 0 {
+		parallelFiles = 1
+	}
+	engine.opPool = workers.NewPool(workers.Config{
+		WorkerCount:     parallelFiles,
+		BufferSize:      parallelFiles * 2,
+		ShutdownTimeout: 30 * time.Second,
+	})
+	if err := engine.opPool.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start sync worker pool: %w", err)
+	}
+
+	// Operations report their own errors, so results only need draining
+	go func() {
+		for range engine.opPool.Results() {
+		}
+	}()
+
 	// Start event processing
 	go engine.processEvents()
 	go engine.processSyncOperations()
@@ -189,6 +237,12 @@ func (se *SyncEngine) StartSync(syncID, localPath, remotePath, manifestCID strin
 		}
 	}
 
+	// Load the pair's include/exclude rules
+	rules, err := se.stateStore.LoadRules(syncID)
+	if err != nil {
+		return fmt.Errorf("failed to load sync rules: %w", err)
+	}
+
 	// Create sync session
 	session := &SyncSession{
 		SyncID:      syncID,
@@ -196,6 +250,7 @@ func (se *SyncEngine) StartSync(syncID, localPath, remotePath, manifestCID strin
 		RemotePath:  remotePath,
 		ManifestCID: manifestCID,
 		State:       state,
+		Rules:       rules,
 		LastSync:    time.Now(),
 		Status:      StatusIdle,
 		Progress: &SyncProgress{
@@ -342,21 +397,14 @@ func (se *SyncEngine) handleLocalEvent(event SyncEvent) {
 			continue
 		}
 
-		// Create sync operation
-		op := se.createSyncOperation(session, event, true)
-		if op != nil {
-			// Add to pending operations
-			if err := se.stateStore.AddPendingOperation(session.SyncID, *op); err != nil {
-				fmt.Printf("Error adding pending operation: %v\n", err)
-				continue
-			}
+		// Skip paths excluded by the session's rules
+		if se.isExcludedBySession(session, event, true) {
+			continue
+		}
 
-			// Queue for processing
-			select {
-			case se.syncOpChan <- *op:
-			default:
-				fmt.Printf("Sync operation queue full, dropping operation\n")
-			}
+		// Create sync operation
+		if op := se.createSyncOperation(session, event, true); op != nil {
+			se.queueOperation(session, *op)
 		}
 	}
 }
@@ -375,22 +423,13 @@ func (se *SyncEngine) handleRemoteEvent(event SyncEvent) {
 		if session.Status == StatusPaused {
 			continue
 		}
+		if se.isExcludedBySession(session, event, false) {
+			continue
+		}
 
 		// Create sync operation
-		op := se.createSyncOperation(session, event, false)
-		if op != nil {
-			// Add to pending operations
-			if err := se.stateStore.AddPendingOperation(session.SyncID, *op); err != nil {
-				fmt.Printf("Error adding pending operation: %v\n", err)
-				continue
-			}
-
-			// Queue for processing
-			select {
-			case se.syncOpChan <- *op:
-			default:
-				fmt.Printf("Sync operation queue full, dropping operation\n")
-			}
+		if op := se.createSyncOperation(session, event, false); op != nil {
+			se.queueOperation(session, *op)
 		}
 	}
 }
@@ -418,6 +457,26 @@ func (se *SyncEngine) findAffectedSessions(path string, isLocal bool) []*SyncSes
 	return affected
 }
 
+// isExcludedBySession checks a local or remote event against the session's
+// include/exclude rules, which apply to paths relative to either root
+func (se *SyncEngine) isExcludedBySession(session *SyncSession, event SyncEvent, isLocal bool) bool {
+	if session.Rules == nil {
+		return false
+	}
+
+	root := session.LocalPath
+	if !isLocal {
+		root = session.RemotePath
+	}
+	relativePath, err := filepath.Rel(root, event.Path)
+	if err != nil {
+		return false
+	}
+
+	isDir := event.Type == EventTypeDirCreated || event.Type == EventTypeDirDeleted
+	return session.Rules.IsExcluded(relativePath, isDir)
+}
+
 // createSyncOperation creates a sync operation from an event
 func (se *SyncEngine) createSyncOperation(session *SyncSession, event SyncEvent, isLocal bool) *SyncOperation {
 	var opType OperationType
@@ -502,6 +561,7 @@ func (se *SyncEngine) GetSyncStatus(syncID string) (*SyncSession, error) {
 		RemotePath:  session.RemotePath,
 		ManifestCID: session.ManifestCID,
 		State:       session.State,
+		Rules:       session.Rules,
 		LastSync:    session.LastSync,
 		Status:      session.Status,
 		Progress:    session.Progress,
@@ -522,6 +582,7 @@ func (se *SyncEngine) ListActiveSyncs() []*SyncSession {
 			RemotePath:  session.RemotePath,
 			ManifestCID: session.ManifestCID,
 			State:       session.State,
+			Rules:       session.Rules,
 			LastSync:    session.LastSync,
 			Status:      session.Status,
 			Progress:    session.Progress,
@@ -562,6 +623,7 @@ func (se *SyncEngine) performInitialSync(session *SyncSession) {
 
 	// Create directory scanner
 	scanner := NewDirectoryScanner(se.directoryManager)
+	scanner.SetRules(session.Rules)
 
 	// Perform initial scan
 	ctx := context.Background()
@@ -591,18 +653,7 @@ func (se *SyncEngine) performInitialSync(session *SyncSession) {
 
 	// Queue operations for processing
 	for _, op := range operations {
-		// Add to pending operations
-		if err := se.stateStore.AddPendingOperation(session.SyncID, op); err != nil {
-			fmt.Printf("Failed to add pending operation: %v\n", err)
-			continue
-		}
-
-		// Queue for processing
-		select {
-		case se.syncOpChan <- op:
-		default:
-			fmt.Printf("Sync operation queue full, dropping operation %s\n", op.ID)
-		}
+		se.queueOperation(session, op)
 	}
 
 	// Update session progress
@@ -611,25 +662,89 @@ func (se *SyncEngine) performInitialSync(session *SyncSession) {
 	session.LastSync = time.Now()
 	session.Progress.TotalOperations = len(operations)
 	session.Progress.CompletedOperations = 0
+	session.Progress.FailedOperations = 0
 	session.Progress.CurrentOperation = "Initial sync completed"
+	se.reportProgress(session)
 	session.mu.Unlock()
 
 	fmt.Printf("Initial sync completed for session %s: found %d changes, generated %d operations\n", 
 		session.SyncID, len(scanResult.Changes), len(operations))
+
+	// With nothing to do the session is already in sync; otherwise the
+	// worker finishing the last operation reports it
+	if len(operations) == 0 {
+		se.notifyComplete(session)
+	}
 }
 
-// processSyncOperations processes queued sync operations
+// queueOperation records an operation as pending and queues it for the
+// workers
+func (se *SyncEngine) queueOperation(session *SyncSession, op SyncOperation) {
+	if err := se.stateStore.AddPendingOperation(session.SyncID, op); err != nil {
+		fmt.Printf("Failed to add pending operation: %v\n", err)
+		return
+	}
+
+	// Counted before sending, as a worker may finish it straight away
+	session.mu.Lock()
+	session.queued++
+	session.mu.Unlock()
+	select {
+	case se.syncOpChan <- op:
+	default:
+		fmt.Printf("Sync operation queue full, dropping operation %s\n", op.ID)
+		session.mu.Lock()
+		session.queued--
+		session.mu.Unlock()
+	}
+}
+
+// notifyComplete passes a session's progress to the OnComplete hook
+func (se *SyncEngine) notifyComplete(session *SyncSession) {
+	if se.config.OnComplete == nil {
+		return
+	}
+	session.mu.RLock()
+	summary := SyncSummary{
+		SyncID:     session.SyncID,
+		LocalPath:  session.LocalPath,
+		RemotePath: session.RemotePath,
+		Progress:   *session.Progress,
+	}
+	session.mu.RUnlock()
+	se.config.OnComplete(summary)
+}
+
+// processSyncOperations dispatches queued sync operations to the worker pool
 func (se *SyncEngine) processSyncOperations() {
 	for {
 		select {
 		case <-se.ctx.Done():
 			return
 		case op := <-se.syncOpChan:
-			se.executeSyncOperation(op)
+			task := &syncOperationTask{engine: se, op: op}
+			if err := se.opPool.SubmitBlocking(se.ctx, task); err != nil {
+				return
+			}
 		}
 	}
 }
 
+// syncOperationTask adapts a sync operation to the worker pool
+type syncOperationTask struct {
+	engine *SyncEngine
+	op     SyncOperation
+}
+
+func (t *syncOperationTask) ID() string {
+	return t.op.ID
+}
+
+func (t *syncOperationTask) Execute(ctx context.Context) (interface{}, error) {
+	t.engine.executeSyncOperation(t.op)
+	return nil, nil
+}
+
 // executeSyncOperation executes a single sync operation
 func (se *SyncEngine) executeSyncOperation(op SyncOperation) {
 	// Find the session for this operation
@@ -687,9 +802,48 @@ func (se *SyncEngine) executeSyncOperation(op SyncOperation) {
 		op.Status = OpStatusCompleted
 	}
 
+	// Operations being retried aren't finished yet
+	idle := false
+	if op.Status != OpStatusPending {
+		session.mu.Lock()
+		if op.Status == OpStatusCompleted {
+			session.Progress.CompletedOperations++
+		} else {
+			session.Progress.FailedOperations++
+		}
+		session.Progress.CurrentOperation = fmt.Sprintf("%s %s", op.Type, op.LocalPath)
+		se.reportProgress(session)
+		session.queued--
+		idle = session.queued == 0
+		session.mu.Unlock()
+	}
+
 	// Update state store
 	se.stateStore.RemovePendingOperation(session.SyncID, op.ID)
 	se.stateStore.AddToHistory(session.SyncID, op)
+
+	if idle {
+		se.notifyComplete(session)
+	}
+}
+
+// reportProgress sends a session's progress to the configured reporter.
+// Operations queued by file events aren't part of the initial total, so
+// the total grows to cover them. The caller must hold session.mu.
+func (se *SyncEngine) reportProgress(session *SyncSession) {
+	progress := session.Progress
+	finished := progress.CompletedOperations + progress.FailedOperations
+	if finished > progress.TotalOperations {
+		progress.TotalOperations = finished
+	}
+	util.ReportProgress(se.config.Progress, "Syncing "+session.SyncID, int64(finished), int64(progress.TotalOperations), progress.BytesTransferred)
+}
+
+// addTransferredBytes records file data moved for a session
+func (se *SyncEngine) addTransferredBytes(session *SyncSession, n int64) {
+	session.mu.Lock()
+	session.Progress.BytesTransferred += n
+	session.mu.Unlock()
 }
 
 // findSessionForOperation finds the session responsible for an operation
@@ -707,6 +861,16 @@ func (se *SyncEngine) findSessionForOperation(op SyncOperation) *SyncSession {
 	return nil
 }
 
+// transferContext returns the context of the engine's uploads and
+// downloads, which charges their blocks to the engine's transfer limits
+func (se *SyncEngine) transferContext() context.Context {
+	ctx := context.Background()
+	if se.transferLimiter != nil {
+		ctx = storage.WithTransferLimiter(ctx, se.transferLimiter)
+	}
+	return ctx
+}
+
 // executeUpload executes an upload operation
 func (se *SyncEngine) executeUpload(session *SyncSession, op SyncOperation) error {
 	// Check if local file exists
@@ -734,13 +898,13 @@ func (se *SyncEngine) executeUpload(session *SyncSession, op SyncOperation) erro
 	filename := filepath.Base(op.LocalPath)
 
 	// Upload file using NoiseFS client
-	ctx := context.Background()
-	descriptorCID, err := se.noisefsClient.Upload(ctx, file, filename)
+	descriptorCID, err := se.noisefsClient.Upload(se.transferContext(), file, filename)
 	if err != nil {
 		return fmt.Errorf("failed to upload file %s to NoiseFS: %w", op.LocalPath, err)
 	}
 
 	fmt.Printf("Successfully uploaded: %s -> %s (CID: %s)\n", op.LocalPath, op.RemotePath, descriptorCID)
+	se.addTransferredBytes(session, fileInfo.Size())
 
 	// Update parent directory manifest with the new file
 	result, err := se.manifestUpdateMgr.UpdateAfterFileOperation(
@@ -794,8 +958,7 @@ func (se *SyncEngine) executeDownload(session *SyncSession, op SyncOperation) er
 	}
 
 	// Download file using NoiseFS client
-	ctx := context.Background()
-	data, filename, err := se.noisefsClient.DownloadWithMetadata(ctx, descriptorCID)
+	data, filename, err := se.noisefsClient.DownloadWithMetadata(se.transferContext(), descriptorCID)
 	if err != nil {
 		return fmt.Errorf("failed to download file with CID %s: %w", descriptorCID, err)
 	}
@@ -813,6 +976,7 @@ func (se *SyncEngine) executeDownload(session *SyncSession, op SyncOperation) er
 	}
 
 	fmt.Printf("Successfully downloaded: %s -> %s (filename: %s)\n", op.RemotePath, targetPath, filename)
+	se.addTransferredBytes(session, int64(len(data)))
 
 	return nil
 }
@@ -902,6 +1066,13 @@ func (se *SyncEngine) Stop() error {
 		}
 	}
 
+	// Stop the worker pool, letting in-flight operations finish
+	if se.opPool != nil {
+		if err := se.opPool.Shutdown(); err != nil {
+			fmt.Printf("Warning: failed to stop sync worker pool: %v\n", err)
+		}
+	}
+
 	// Close channels
 	close(se.localEventChan)
 	close(se.remoteEventChan)
@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -388,3 +389,53 @@ func TestSyncEngine_OperationTypeMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestSyncEngine_OnComplete(t *testing.T) {
+	stateStore, err := NewSyncStateStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPath := t.TempDir()
+	if err := stateStore.CreateInitialState("test-sync", localPath, "/remote"); err != nil {
+		t.Fatal(err)
+	}
+
+	var summaries []SyncSummary
+	session := &SyncSession{
+		SyncID:     "test-sync",
+		LocalPath:  localPath,
+		RemotePath: "/remote",
+		Progress:   &SyncProgress{StartTime: time.Now()},
+	}
+	engine := &SyncEngine{
+		stateStore:  stateStore,
+		syncOpChan:  make(chan SyncOperation, 10),
+		activeSyncs: map[string]*SyncSession{"test-sync": session},
+		stats:       &SyncEngineStats{},
+		config: &SyncConfig{
+			MaxRetries: 1,
+			OnComplete: func(summary SyncSummary) { summaries = append(summaries, summary) },
+		},
+	}
+
+	for _, name := range []string{"a", "b"} {
+		engine.queueOperation(session, SyncOperation{
+			ID:        name,
+			Type:      OpTypeCreateDir,
+			LocalPath: filepath.Join(localPath, name),
+			Status:    OpStatusPending,
+		})
+	}
+
+	engine.executeSyncOperation(<-engine.syncOpChan)
+	if len(summaries) != 0 {
+		t.Fatal("OnComplete called with an operation still queued")
+	}
+	engine.executeSyncOperation(<-engine.syncOpChan)
+	if len(summaries) != 1 {
+		t.Fatalf("OnComplete called %d times, want 1", len(summaries))
+	}
+	if summaries[0].SyncID != "test-sync" || summaries[0].Progress.CompletedOperations != 2 {
+		t.Errorf("Unexpected summary %+v", summaries[0])
+	}
+}
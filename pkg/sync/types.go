@@ -2,6 +2,8 @@ package sync
 
 import (
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // EventType represents the type of sync event
@@ -138,6 +140,17 @@ type SyncConfig struct {
 	SyncInterval       time.Duration      `json:"sync_interval"`
 	MaxRetries         int                `json:"max_retries"`
 	WatchMode          bool               `json:"watch_mode"`
+	// Transfer limits (zero means unlimited, except MaxParallelFiles which defaults to 1)
+	MaxBandwidth      int64 `json:"max_bandwidth,omitempty"`       // bytes per second
+	MaxParallelFiles  int   `json:"max_parallel_files,omitempty"`  // concurrent file operations
+	MaxParallelBlocks int   `json:"max_parallel_blocks,omitempty"` // concurrent block transfers
+
+	// Progress receives each session's operation counts as operations finish (optional)
+	Progress util.ProgressReporter `json:"-"`
+
+	// OnComplete is called whenever a session has no operations left: after
+	// the initial sync and after the operations later changes queue (optional)
+	OnComplete func(SyncSummary) `json:"-"`
 }
 
 // ChangeType represents the type of change detected
@@ -2,6 +2,8 @@ package bootstrap
 
 import (
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // Profile represents a seeding profile
@@ -36,6 +38,9 @@ type SeedConfig struct {
 	
 	// Logging
 	Verbose      bool
+	
+	// Progress receives per-file download progress (optional)
+	Progress util.ProgressReporter
 }
 
 // ContentSource represents a source of public domain content
@@ -9,7 +9,10 @@ import (
 	"path/filepath"
 	"sync"
 	"sync/atomic"
+	"strings"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // ContentDownloader handles downloading public domain content
@@ -145,10 +148,14 @@ func (d *ContentDownloader) downloadFile(url, destPath string) (int64, error) {
 	}
 
 	// Writer with progress
+	reporter := d.config.Progress
+	if reporter == nil {
+		reporter = util.ProgressFunc(d.reportProgress)
+	}
 	counter := &progressWriter{
-		total:      resp.ContentLength,
-		downloaded: 0,
-		onProgress: d.reportProgress,
+		stage:    "Downloading " + filepath.Base(destPath),
+		total:    max(resp.ContentLength, 0),
+		reporter: reporter,
 	}
 
 	// Write the body to file with progress
@@ -160,19 +167,18 @@ func (d *ContentDownloader) downloadFile(url, destPath string) (int64, error) {
 	return written, nil
 }
 
-// progressWriter tracks download progress
+// progressWriter reports the progress of a single file download
 type progressWriter struct {
-	total      int64
+	stage      string
+	total      int64 // Zero if the server didn't send a length
 	downloaded int64
-	onProgress func(downloaded, total int64)
+	reporter   util.ProgressReporter
 }
 
 func (pw *progressWriter) Write(p []byte) (int, error) {
 	n := len(p)
 	pw.downloaded += int64(n)
-	if pw.onProgress != nil {
-		pw.onProgress(pw.downloaded, pw.total)
-	}
+	util.ReportProgress(pw.reporter, pw.stage, pw.downloaded, pw.total, pw.downloaded)
 	return n, nil
 }
 
@@ -206,11 +212,10 @@ func (d *ContentDownloader) addError(err error) {
 	d.progress.Errors = append(d.progress.Errors, err)
 }
 
-func (d *ContentDownloader) reportProgress(downloaded, total int64) {
-	// In production, update progress bar
-	// For now, periodic prints
-	if downloaded == total {
-		fmt.Printf("✓ Downloaded %s\n", d.progress.CurrentFile)
+// reportProgress is the default reporter, printing each finished file
+func (d *ContentDownloader) reportProgress(progress util.Progress) {
+	if progress.Current == progress.Total {
+		fmt.Printf("✓ %s\n", strings.Replace(progress.Stage, "Downloading", "Downloaded", 1))
 	}
 }
 
@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/util"
 )
 
 // Config holds configuration for the bootstrap data generator
@@ -18,6 +20,9 @@ type Config struct {
 	MaxSize           int64
 	Verbose           bool
 	ParallelDownloads int
+
+	// Progress receives the number of files downloaded (optional)
+	Progress util.ProgressReporter
 }
 
 // DatasetGenerator handles downloading and organizing public domain content
@@ -82,6 +87,13 @@ func (g *DatasetGenerator) GenerateDataset() error {
 	var errors []error
 	var errorMutex sync.Mutex
 
+	total := int64(len(dataset.Sources))
+	if dataset.MaxFiles > 0 && total > int64(dataset.MaxFiles) {
+		total = int64(dataset.MaxFiles)
+	}
+	var completed int64
+	util.ReportProgress(g.config.Progress, "Downloading dataset", 0, total, 0)
+
 	for i, source := range dataset.Sources {
 		// Check if we've exceeded max size
 		g.mutex.RLock()
@@ -108,6 +120,11 @@ func (g *DatasetGenerator) GenerateDataset() error {
 				errors = append(errors, fmt.Errorf("failed to download %s: %w", source.URL, err))
 				errorMutex.Unlock()
 			}
+
+			g.mutex.Lock()
+			completed++
+			util.ReportProgress(g.config.Progress, "Downloading dataset", completed, total, g.downloaded)
+			g.mutex.Unlock()
 		}(source, i)
 	}
 
@@ -198,6 +215,15 @@ func (g *DatasetGenerator) downloadSource(source DataSource, outputPath string)
 	return nil
 }
 
+// DatasetDir returns the directory GenerateDataset downloads the dataset to
+func (g *DatasetGenerator) DatasetDir() (string, error) {
+	dataset, exists := g.datasets[g.config.Dataset]
+	if !exists {
+		return "", fmt.Errorf("unknown dataset: %s", g.config.Dataset)
+	}
+	return filepath.Join(g.config.OutputDir, dataset.Directory), nil
+}
+
 // PreviewDataset shows information about the dataset without downloading
 func (g *DatasetGenerator) PreviewDataset() error {
 	dataset, exists := g.datasets[g.config.Dataset]
@@ -0,0 +1,304 @@
+package bootstrap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// PoolManifestVersion is the format version written to pool manifests
+const PoolManifestVersion = "1.0"
+
+// PoolManifest records the randomizer blocks a bootstrap run split out of
+// public domain content and pinned, so nodes can seed their randomizer pool
+// from known CIDs instead of generating fresh random blocks
+type PoolManifest struct {
+	Version   string           `json:"version"`
+	Dataset   string           `json:"dataset"`
+	BlockSize int              `json:"block_size"`
+	Created   time.Time        `json:"created"`
+	Updated   time.Time        `json:"updated"`
+	Sources   map[string]int   `json:"sources"` // Source file -> blocks taken from it
+	Blocks    []PoolBlockEntry `json:"blocks"`
+}
+
+// PoolBlockEntry is one pinned randomizer block in a pool manifest
+type PoolBlockEntry struct {
+	CID         string `json:"cid"`
+	Size        int    `json:"size"`
+	Source      string `json:"source"`       // Source file, relative to the dataset directory
+	ContentType string `json:"content_type"` // Dataset subdirectory, e.g. "text" or "image"
+	License     string `json:"license,omitempty"`
+}
+
+// NewPoolManifest creates an empty manifest for a dataset and block size
+func NewPoolManifest(dataset string, blockSize int) *PoolManifest {
+	now := time.Now()
+	return &PoolManifest{
+		Version:   PoolManifestVersion,
+		Dataset:   dataset,
+		BlockSize: blockSize,
+		Created:   now,
+		Updated:   now,
+		Sources:   make(map[string]int),
+	}
+}
+
+// LoadPoolManifest reads a pool manifest written by Save
+func LoadPoolManifest(path string) (*PoolManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest PoolManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse pool manifest: %w", err)
+	}
+	if manifest.Sources == nil {
+		manifest.Sources = make(map[string]int)
+	}
+	return &manifest, nil
+}
+
+// Save writes the manifest atomically, so an interrupted run never leaves
+// a truncated manifest behind
+func (m *PoolManifest) Save(path string) error {
+	m.Updated = time.Now()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// CIDs returns the CIDs of all blocks in the manifest
+func (m *PoolManifest) CIDs() []string {
+	cids := make([]string, len(m.Blocks))
+	for i, entry := range m.Blocks {
+		cids[i] = entry.CID
+	}
+	return cids
+}
+
+// BlockStore is the part of the storage manager the pool populator needs
+type BlockStore interface {
+	Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error)
+	Pin(ctx context.Context, address *storage.BlockAddress) error
+}
+
+// PoolStats summarizes a pool population run
+type PoolStats struct {
+	Files           int   // Files split into blocks
+	SkippedFiles    int   // Files already recorded in the manifest
+	Blocks          int   // New blocks pinned and recorded
+	DuplicateBlocks int   // Blocks whose CID was already in the pool
+	DiscardedTails  int   // Final partial blocks too short to be useful randomizers
+	Bytes           int64 // Bytes stored
+}
+
+// PoolPopulator splits downloaded public domain files directly into
+// randomizer blocks, pins them and records them in a pool manifest
+type PoolPopulator struct {
+	store        BlockStore
+	manifest     *PoolManifest
+	manifestPath string
+	blockSize    int
+	known        map[string]bool
+	verbose      bool
+}
+
+// NewPoolPopulator creates a populator that extends the manifest at
+// manifestPath, or starts a new one if it doesn't exist yet
+func NewPoolPopulator(store BlockStore, manifestPath, dataset string, blockSize int, verbose bool) (*PoolPopulator, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("block size must be positive")
+	}
+
+	manifest, err := LoadPoolManifest(manifestPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		manifest = NewPoolManifest(dataset, blockSize)
+	case err != nil:
+		return nil, err
+	case manifest.BlockSize != blockSize:
+		return nil, fmt.Errorf("pool manifest %s uses %d byte blocks, not %d", manifestPath, manifest.BlockSize, blockSize)
+	}
+
+	known := make(map[string]bool, len(manifest.Blocks))
+	for _, entry := range manifest.Blocks {
+		known[entry.CID] = true
+	}
+
+	return &PoolPopulator{
+		store:        store,
+		manifest:     manifest,
+		manifestPath: manifestPath,
+		blockSize:    blockSize,
+		known:        known,
+		verbose:      verbose,
+	}, nil
+}
+
+// Manifest returns the manifest being populated
+func (p *PoolPopulator) Manifest() *PoolManifest {
+	return p.manifest
+}
+
+// PopulateFromDir splits every file under dir into randomizer blocks. The
+// manifest is saved after each file, and files it already lists are
+// skipped, so an interrupted run can simply be restarted.
+func (p *PoolPopulator) PopulateFromDir(ctx context.Context, dir string) (*PoolStats, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) != ".meta" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list content: %w", err)
+	}
+	sort.Strings(files)
+
+	stats := &PoolStats{}
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			relPath = path
+		}
+		relPath = filepath.ToSlash(relPath)
+		if _, done := p.manifest.Sources[relPath]; done {
+			stats.SkippedFiles++
+			continue
+		}
+
+		count, err := p.populateFile(ctx, path, relPath, stats)
+		if err != nil {
+			return stats, fmt.Errorf("failed to process %s: %w", relPath, err)
+		}
+		stats.Files++
+
+		p.manifest.Sources[relPath] = count
+		if err := p.manifest.Save(p.manifestPath); err != nil {
+			return stats, fmt.Errorf("failed to save pool manifest: %w", err)
+		}
+		if p.verbose {
+			fmt.Printf("Pooled: %s (%d blocks)\n", relPath, count)
+		}
+	}
+
+	return stats, nil
+}
+
+// populateFile pins the blocks of one file and returns how many were added
+func (p *PoolPopulator) populateFile(ctx context.Context, path, relPath string, stats *PoolStats) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	contentType := strings.Split(relPath, "/")[0]
+	if contentType == relPath {
+		contentType = "other"
+	}
+	license := readLicense(path + ".meta")
+
+	added := 0
+	buffer := make([]byte, p.blockSize)
+	for {
+		n, err := io.ReadFull(file, buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return added, err
+		}
+		// A mostly zero-padded tail would be a poor randomizer
+		if n < p.blockSize/2 {
+			stats.DiscardedTails++
+			break
+		}
+
+		data := make([]byte, p.blockSize)
+		copy(data, buffer[:n])
+		block, blockErr := blocks.NewBlock(data)
+		if blockErr != nil {
+			return added, blockErr
+		}
+
+		address, putErr := p.store.Put(ctx, block)
+		if putErr != nil {
+			return added, fmt.Errorf("failed to store block: %w", putErr)
+		}
+		if p.known[address.ID] {
+			stats.DuplicateBlocks++
+		} else {
+			if pinErr := p.store.Pin(ctx, address); pinErr != nil {
+				return added, fmt.Errorf("failed to pin block %s: %w", address.ID, pinErr)
+			}
+			p.known[address.ID] = true
+			p.manifest.Blocks = append(p.manifest.Blocks, PoolBlockEntry{
+				CID:         address.ID,
+				Size:        p.blockSize,
+				Source:      relPath,
+				ContentType: contentType,
+				License:     license,
+			})
+			added++
+			stats.Blocks++
+			stats.Bytes += int64(p.blockSize)
+		}
+
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	return added, nil
+}
+
+// readLicense returns the License line of a download's .meta file, if any
+func readLicense(metaPath string) string {
+	file, err := os.Open(metaPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, found := strings.CutPrefix(scanner.Text(), "License: "); found {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage"
+)
+
+// memoryStore records stored and pinned blocks
+type memoryStore struct {
+	blocks map[string]*blocks.Block
+	pinned map[string]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{blocks: make(map[string]*blocks.Block), pinned: make(map[string]bool)}
+}
+
+func (m *memoryStore) Put(ctx context.Context, block *blocks.Block) (*storage.BlockAddress, error) {
+	m.blocks[block.ID] = block
+	return &storage.BlockAddress{ID: block.ID, Size: int64(block.Size())}, nil
+}
+
+func (m *memoryStore) Pin(ctx context.Context, address *storage.BlockAddress) error {
+	m.pinned[address.ID] = true
+	return nil
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolPopulator(t *testing.T) {
+	const blockSize = 1024
+	dir := t.TempDir()
+	contentDir := filepath.Join(dir, "books")
+
+	// 2.75 blocks: two full blocks and a tail that is kept and padded
+	book := bytes.Repeat([]byte("It was the best of times, it was the worst of times. "), 54)[:blockSize*11/4]
+	writeFile(t, filepath.Join(contentDir, "text", "tale.txt"), book)
+	writeFile(t, filepath.Join(contentDir, "text", "tale.txt.meta"), []byte("URL: https://example.org\nLicense: Public Domain\n"))
+	// The same first block again plus a tail too short to keep
+	writeFile(t, filepath.Join(contentDir, "text", "tale_excerpt.txt"), book[:blockSize+100])
+
+	store := newMemoryStore()
+	manifestPath := filepath.Join(dir, "pool", "pool_manifest.json")
+	populator, err := NewPoolPopulator(store, manifestPath, "books", blockSize, false)
+	if err != nil {
+		t.Fatalf("NewPoolPopulator failed: %v", err)
+	}
+
+	stats, err := populator.PopulateFromDir(context.Background(), contentDir)
+	if err != nil {
+		t.Fatalf("PopulateFromDir failed: %v", err)
+	}
+	if stats.Files != 2 || stats.Blocks != 3 || stats.DuplicateBlocks != 1 || stats.DiscardedTails != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(store.pinned) != 3 {
+		t.Errorf("Expected 3 pinned blocks, got %d", len(store.pinned))
+	}
+	for _, block := range store.blocks {
+		if block.Size() != blockSize {
+			t.Errorf("Expected %d byte blocks, got %d", blockSize, block.Size())
+		}
+	}
+
+	manifest, err := LoadPoolManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadPoolManifest failed: %v", err)
+	}
+	if len(manifest.Blocks) != 3 || manifest.Sources["text/tale.txt"] != 3 || manifest.Sources["text/tale_excerpt.txt"] != 0 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	for _, entry := range manifest.Blocks {
+		if !store.pinned[entry.CID] {
+			t.Errorf("Manifest block %s was not pinned", entry.CID)
+		}
+		if entry.ContentType != "text" || entry.License != "Public Domain" {
+			t.Errorf("Unexpected block entry: %+v", entry)
+		}
+	}
+
+	// A rerun skips pooled files; new downloads are added
+	writeFile(t, filepath.Join(contentDir, "image", "plate.bmp"), bytes.Repeat([]byte{1, 2, 3, 4}, blockSize/4))
+	populator, err = NewPoolPopulator(newMemoryStore(), manifestPath, "books", blockSize, false)
+	if err != nil {
+		t.Fatalf("NewPoolPopulator failed on existing manifest: %v", err)
+	}
+	stats, err = populator.PopulateFromDir(context.Background(), contentDir)
+	if err != nil {
+		t.Fatalf("PopulateFromDir failed on rerun: %v", err)
+	}
+	if stats.SkippedFiles != 2 || stats.Files != 1 || stats.Blocks != 1 {
+		t.Errorf("Unexpected rerun stats: %+v", stats)
+	}
+	if got := len(populator.Manifest().CIDs()); got != 4 {
+		t.Errorf("Expected 4 pooled blocks, got %d", got)
+	}
+
+	if _, err := NewPoolPopulator(store, manifestPath, "books", 2*blockSize, false); err == nil {
+		t.Error("Expected an error when the block size differs from the manifest")
+	}
+}
@@ -19,6 +19,7 @@ type UploadResult struct {
 	FileSize      int64  `json:"file_size"`
 	BlockCount    int    `json:"block_count"`
 	BlockSize     int    `json:"block_size"`
+	Deduplicated  bool   `json:"deduplicated,omitempty"` // Uploaded before; nothing was stored
 }
 
 // DownloadResult represents the result of a download operation
@@ -54,6 +55,7 @@ type StatsResult struct {
 	Storage    StorageStats       `json:"storage"`
 	Activity   ActivityStats      `json:"activity"`
 	Altruistic *AltruisticStats   `json:"altruistic,omitempty"`
+	Network    []NetworkStats     `json:"network,omitempty"`
 }
 
 // IPFSStats represents IPFS connection information
@@ -62,6 +64,21 @@ type IPFSStats struct {
 	Peers     int  `json:"peer_count"`
 }
 
+// NetworkStats represents the peer, Bitswap and DHT activity of a storage
+// backend
+type NetworkStats struct {
+	Backend           string  `json:"backend"`
+	Peers             int     `json:"peer_count"`
+	BlocksReceived    uint64  `json:"bitswap_blocks_received"`
+	BlocksSent        uint64  `json:"bitswap_blocks_sent"`
+	DataReceived      uint64  `json:"bitswap_data_received"`
+	DataSent          uint64  `json:"bitswap_data_sent"`
+	DupBlocksReceived uint64  `json:"bitswap_dup_blocks_received"`
+	DHTQueries        int64   `json:"dht_queries"`
+	DHTFailures       int64   `json:"dht_failures"`
+	DHTLatencyMs      float64 `json:"dht_latency_ms"`
+}
+
 // CacheStats represents cache performance metrics
 type CacheStats struct {
 	Size      int     `json:"size"`
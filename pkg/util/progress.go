@@ -9,6 +9,49 @@ import (
 	"time"
 )
 
+// Progress is an update on a long running operation. Current and Total
+// count the units of the current stage (blocks, tasks, files), with Total
+// zero when it isn't known. Bytes is the number of bytes processed so far
+// in the whole operation, or zero when the operation doesn't track bytes.
+type Progress struct {
+	Stage   string
+	Current int64
+	Total   int64
+	Bytes   int64
+}
+
+// Percent returns the completion of the current stage, or 0 when the
+// total isn't known
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return float64(p.Current) * 100 / float64(p.Total)
+}
+
+// ProgressReporter receives progress updates from uploads, downloads,
+// worker pools and other long running operations. Reporters may be called
+// from several goroutines and should return quickly.
+type ProgressReporter interface {
+	ReportProgress(progress Progress)
+}
+
+// ProgressFunc adapts a function to a ProgressReporter
+type ProgressFunc func(progress Progress)
+
+// ReportProgress calls f
+func (f ProgressFunc) ReportProgress(progress Progress) {
+	f(progress)
+}
+
+// ReportProgress sends an update to reporter, which may be nil
+func ReportProgress(reporter ProgressReporter, stage string, current, total, bytes int64) {
+	if reporter == nil {
+		return
+	}
+	reporter.ReportProgress(Progress{Stage: stage, Current: current, Total: total, Bytes: bytes})
+}
+
 // ProgressBar provides a simple terminal progress bar
 type ProgressBar struct {
 	mu       sync.Mutex
@@ -82,6 +125,28 @@ func (p *ProgressBar) SetDescription(description string) {
 	p.draw()
 }
 
+// ReportProgress implements ProgressReporter, showing the stage as the
+// bar's description
+func (p *ProgressBar) ReportProgress(progress Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.prefix = progress.Stage
+	p.total = progress.Total
+	p.current = progress.Current
+	if p.current > p.total {
+		p.current = p.total
+	}
+	
+	// Throttle updates like Add, but always draw the end of a stage
+	if time.Since(p.lastDraw) < 100*time.Millisecond && p.current < p.total {
+		return
+	}
+	
+	p.draw()
+	p.lastDraw = time.Now()
+}
+
 // Finish completes the progress bar
 func (p *ProgressBar) Finish() {
 	p.mu.Lock()
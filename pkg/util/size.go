@@ -30,11 +30,11 @@ func ParseSize(sizeStr string) (int64, error) {
 	var numberPart string
 	var unitPart string
 	
+	// Prefer the longest match so "MB" is not mistaken for "B"
 	for unit := range units {
-		if strings.HasSuffix(sizeStr, unit) {
+		if strings.HasSuffix(sizeStr, unit) && len(unit) > len(unitPart) {
 			numberPart = strings.TrimSuffix(sizeStr, unit)
 			unitPart = unit
-			break
 		}
 	}
 	
@@ -0,0 +1,337 @@
+// Package middleware provides the HTTP middleware shared by the NoiseFS web
+// servers: request IDs, security headers, CSRF protection, rate limiting,
+// request size limits, request logging and panic recovery. Each middleware
+// has the mux.MiddlewareFunc signature, so it can be passed to Router.Use or
+// wrapped around a single handler.
+package middleware
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+// DefaultContentSecurityPolicy allows the web UI's own resources, inline
+// scripts and styles in its templates, charts from jsDelivr and WebSocket
+// connections back to the server
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self' ws: wss:; " +
+	"frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+// SecurityHeaders sets browser hardening headers on every response. An empty
+// policy uses DefaultContentSecurityPolicy. Strict-Transport-Security is only
+// sent over TLS.
+func SecurityHeaders(contentSecurityPolicy string) func(http.Handler) http.Handler {
+	if contentSecurityPolicy == "" {
+		contentSecurityPolicy = DefaultContentSecurityPolicy
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("Content-Security-Policy", contentSecurityPolicy)
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=()")
+			if r.TLS != nil {
+				h.Set("Strict-Transport-Security", "max-age=31536000")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRF cookie and header names. Pages read the cookie and send its value in
+// the header with every request that changes something.
+const (
+	CSRFCookie = "noisefs_csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRF protects state-changing requests from cross-site forgery with a
+// double-submit token: every response without one sets a random token in a
+// SameSite=Strict cookie, and POST, PUT, PATCH and DELETE requests from
+// browsers must repeat it in the X-CSRF-Token header, which other sites can
+// neither read nor send. Requests carrying an API token in the
+// Authorization or X-API-Key header, and requests without an Origin header
+// or cookies, don't come from a browser's ambient credentials and aren't
+// checked.
+func CSRF() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token string
+			if cookie, err := r.Cookie(CSRFCookie); err == nil {
+				token = cookie.Value
+			}
+			if token == "" {
+				token = newCSRFToken()
+				http.SetCookie(w, &http.Cookie{
+					Name:     CSRFCookie,
+					Value:    token,
+					Path:     "/",
+					Secure:   IsHTTPS(r),
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+
+			if needsCSRFCheck(r) {
+				sent := r.Header.Get(CSRFHeader)
+				if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+					writeError(w, "missing or invalid CSRF token; reload the page and try again", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// needsCSRFCheck reports whether a request could be forged by another site
+func needsCSRFCheck(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" {
+		return false
+	}
+	return r.Header.Get("Origin") != "" || r.Header.Get("Cookie") != ""
+}
+
+func newCSRFToken() string {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		panic(fmt.Sprintf("failed to generate CSRF token: %v", err))
+	}
+	return hex.EncodeToString(token)
+}
+
+// IsHTTPS reports whether a request reached the server, or the reverse
+// proxy in front of it, over HTTPS, so cookies it sets can be Secure
+func IsHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// Limiter decides whether a request may proceed. Every allowed request is
+// released when its handler returns. validation.RateLimiter implements it.
+type Limiter interface {
+	CheckLimit(r *http.Request) error
+	ReleaseRequest(r *http.Request)
+}
+
+// RateLimit rejects requests refused by the limiter with 429 Too Many
+// Requests. onReject, if not nil, is called for each rejected request, for
+// example to audit it.
+func RateLimit(limiter Limiter, onReject func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := limiter.CheckLimit(r); err != nil {
+				if onReject != nil {
+					onReject(r, err)
+				}
+				writeError(w, err.Error(), http.StatusTooManyRequests)
+				return
+			}
+			defer limiter.ReleaseRequest(r)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxBodySize rejects request bodies larger than maxBytes with 413 Request
+// Entity Too Large. Bodies without a Content-Length are cut off at maxBytes,
+// so handlers see a read error instead of unbounded input.
+func MaxBodySize(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				writeError(w, fmt.Sprintf("request body too large (max %d bytes)", maxBytes), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestIDHeader carries a request's ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an ID, so its log lines can be told apart
+// and an error reported by a user can be found in the logs. An ID set by a
+// reverse proxy in the X-Request-ID header is kept; otherwise one is
+// generated. The ID is stored in the request's context for
+// logging.RequestIDFromContext and returned in the X-Request-ID response
+// header. It should be the outermost middleware.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = logging.NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID reports whether an incoming request ID is safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// Logging logs each request with its status, size and duration. Server
+// errors are logged as warnings, everything else at debug level so routine
+// polling does not flood the log.
+func Logging(logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			fields := logger.WithFields(map[string]interface{}{
+				"request_id":  logging.RequestIDFromContext(r.Context()),
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status":      sw.Status(),
+				"bytes":       sw.bytes,
+				"duration_ms": time.Since(start).Milliseconds(),
+				"remote":      r.RemoteAddr,
+			})
+			if sw.Status() >= http.StatusInternalServerError {
+				fields.Warn("HTTP request failed")
+			} else {
+				fields.Debug("HTTP request")
+			}
+		})
+	}
+}
+
+// Recover turns a panicking handler into a 500 response and logs the panic
+// with its stack, so one bad request cannot take the server down
+func Recover(logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					// Deliberate abort, let net/http handle it
+					panic(rec)
+				}
+				logger.Error("Recovered from panic in HTTP handler", map[string]interface{}{
+					"request_id": logging.RequestIDFromContext(r.Context()),
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"panic":      fmt.Sprint(rec),
+					"stack":      string(debug.Stack()),
+				})
+				if !sw.wroteHeader {
+					writeError(sw, "internal server error", http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// writeError writes a JSON error in the web UI's APIResponse format, with
+// the request ID set by RequestID
+func writeError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		fmt.Fprintf(w, `{"success":false,"error":%q,"request_id":%q}`+"\n", message, id)
+		return
+	}
+	fmt.Fprintf(w, `{"success":false,"error":%q}`+"\n", message)
+}
+
+// statusWriter records the status and size of a response. It passes
+// through flushing for streamed downloads and hijacking for WebSockets.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Status returns the response status, 200 if nothing was written
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/infrastructure/logging"
+)
+
+func testLogger(buf *bytes.Buffer) *logging.Logger {
+	cfg := logging.DefaultConfig()
+	cfg.Level = logging.DebugLevel
+	cfg.Output = buf
+	return logging.NewLogger(cfg)
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "ok")
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := SecurityHeaders("")(http.HandlerFunc(okHandler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Header().Get("Content-Security-Policy"); got != DefaultContentSecurityPolicy {
+		t.Errorf("unexpected CSP: %q", got)
+	}
+	if got := rec.Header().Get("X-Frame-Options"); got != "DENY" {
+		t.Errorf("unexpected X-Frame-Options: %q", got)
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS should only be sent over TLS")
+	}
+}
+
+type fakeLimiter struct {
+	allow    bool
+	released int
+}
+
+func (l *fakeLimiter) CheckLimit(r *http.Request) error {
+	if !l.allow {
+		return errors.New("rate limit exceeded")
+	}
+	return nil
+}
+
+func (l *fakeLimiter) ReleaseRequest(r *http.Request) {
+	l.released++
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := &fakeLimiter{allow: true}
+	rejected := 0
+	handler := RateLimit(limiter, func(r *http.Request, err error) { rejected++ })(http.HandlerFunc(okHandler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/upload", nil))
+	if rec.Code != http.StatusOK || limiter.released != 1 {
+		t.Errorf("expected allowed request to be released, got %d (%d releases)", rec.Code, limiter.released)
+	}
+
+	limiter.allow = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/upload", nil))
+	if rec.Code != http.StatusTooManyRequests || rejected != 1 || limiter.released != 1 {
+		t.Errorf("expected 429 without release, got %d (%d rejected, %d releases)", rec.Code, rejected, limiter.released)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	handler := MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		okHandler(w, r)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("small")))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected small body to pass, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader("much too large")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for declared length, got %d", rec.Code)
+	}
+
+	// Without a Content-Length the body is cut off while reading
+	req := httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader("much too large")))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for streamed body, got %d", rec.Code)
+	}
+}
+
+func TestRecoverAndLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := testLogger(&buf)
+	handler := Recover(logger)(Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusTeapot)
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 after panic, got %d", rec.Code)
+	}
+	if !strings.Contains(buf.String(), "boom") {
+		t.Errorf("expected panic to be logged, got %q", buf.String())
+	}
+
+	buf.Reset()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/tea", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("expected 418, got %d", rec.Code)
+	}
+	if !strings.Contains(buf.String(), "418") || !strings.Contains(buf.String(), "/tea") {
+		t.Errorf("expected request to be logged, got %q", buf.String())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := testLogger(&buf)
+	var seen string
+	handler := RequestID()(Recover(logger)(Logging(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestIDFromContext(r.Context())
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+	}))))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	id := rec.Header().Get(RequestIDHeader)
+	if id == "" || id != seen {
+		t.Fatalf("expected the response header %q to match the context ID %q", id, seen)
+	}
+	if !strings.Contains(buf.String(), id) {
+		t.Errorf("expected the request ID in the log, got %q", buf.String())
+	}
+
+	// An ID from a reverse proxy is kept, an unsafe one replaced
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "proxy-42")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got != "proxy-42" || seen != "proxy-42" {
+		t.Errorf("expected the proxy's request ID to be kept, got %q", got)
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got == "" || strings.Contains(got, " ") {
+		t.Errorf("expected an unsafe request ID to be replaced, got %q", got)
+	}
+
+	// Error responses carry the ID
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if !strings.Contains(rec.Body.String(), `"request_id":"`+rec.Header().Get(RequestIDHeader)+`"`) {
+		t.Errorf("expected the request ID in the error response, got %s", rec.Body.String())
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	handler := CSRF()(http.HandlerFunc(okHandler))
+
+	// A page load hands out the token
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != CSRFCookie {
+		t.Fatalf("expected a CSRF cookie, got %d %v", rec.Code, cookies)
+	}
+	if cookies[0].SameSite != http.SameSiteStrictMode || cookies[0].HttpOnly {
+		t.Errorf("expected a SameSite=Strict cookie readable by pages, got %+v", cookies[0])
+	}
+	token := cookies[0].Value
+
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		want    int
+	}{
+		{"browser with token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: token})
+			r.Header.Set(CSRFHeader, token)
+		}, http.StatusOK},
+		{"browser without token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: token})
+		}, http.StatusForbidden},
+		{"browser with wrong token", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: token})
+			r.Header.Set(CSRFHeader, "guess")
+		}, http.StatusForbidden},
+		{"cross-site form", func(r *http.Request) {
+			r.Header.Set("Origin", "https://evil.example")
+		}, http.StatusForbidden},
+		{"API token", func(r *http.Request) {
+			r.Header.Set("Origin", "https://evil.example")
+			r.Header.Set("Authorization", "Bearer nfs_x")
+		}, http.StatusOK},
+		{"script", func(r *http.Request) {}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/upload", nil)
+		tt.prepare(req)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ChurnModel decides when nodes leave and rejoin the network
+type ChurnModel interface {
+	// Online reports whether a node is online after a step of length dt,
+	// given whether it was online before the step
+	Online(wasOnline bool, dt time.Duration, rng *rand.Rand) bool
+}
+
+// NoChurn keeps every node online for the whole simulation
+type NoChurn struct{}
+
+// Online implements ChurnModel
+func (NoChurn) Online(wasOnline bool, dt time.Duration, rng *rand.Rand) bool {
+	return wasOnline
+}
+
+// PoissonChurn makes nodes leave and join as independent Poisson processes.
+// The mean session length is 1/LeaveRate and the mean downtime 1/JoinRate.
+type PoissonChurn struct {
+	LeaveRate float64 // Departures per online node per second
+	JoinRate  float64 // Arrivals per offline node per second
+}
+
+// Online implements ChurnModel
+func (c PoissonChurn) Online(wasOnline bool, dt time.Duration, rng *rand.Rand) bool {
+	if wasOnline {
+		return rng.Float64() >= eventProbability(c.LeaveRate, dt)
+	}
+	return rng.Float64() < eventProbability(c.JoinRate, dt)
+}
+
+// eventProbability is the chance that a Poisson process with the given rate
+// fires at least once within dt
+func eventProbability(rate float64, dt time.Duration) float64 {
+	if rate <= 0 {
+		return 0
+	}
+	return 1 - math.Exp(-rate*dt.Seconds())
+}
+
+// NodeBehavior describes how a node answers block requests
+type NodeBehavior int
+
+const (
+	Honest        NodeBehavior = iota
+	DropBlocks                 // Pretends not to have requested blocks
+	CorruptBlocks              // Returns modified data, detected by hash verification
+)
+
+// String returns the behavior name
+func (b NodeBehavior) String() string {
+	switch b {
+	case Honest:
+		return "honest"
+	case DropBlocks:
+		return "drop"
+	case CorruptBlocks:
+		return "corrupt"
+	default:
+		return "unknown"
+	}
+}
+
+// AdversaryModel decides which nodes are malicious and when they misbehave
+type AdversaryModel interface {
+	// Behaviors assigns a behavior to each of numNodes nodes
+	Behaviors(numNodes int, rng *rand.Rand) []NodeBehavior
+	// Misbehaves reports whether a malicious node acts on this request;
+	// selective adversaries serve some requests to avoid detection
+	Misbehaves(rng *rand.Rand) bool
+}
+
+// FractionAdversary makes a fixed fraction of randomly chosen nodes malicious
+type FractionAdversary struct {
+	DropFraction    float64 // Fraction of nodes that drop blocks
+	CorruptFraction float64 // Fraction of nodes that corrupt blocks
+	Probability     float64 // Chance a malicious node misbehaves per request; 0 means always
+}
+
+// Behaviors implements AdversaryModel
+func (a FractionAdversary) Behaviors(numNodes int, rng *rand.Rand) []NodeBehavior {
+	behaviors := make([]NodeBehavior, numNodes)
+	droppers := int(math.Round(a.DropFraction * float64(numNodes)))
+	corrupters := int(math.Round(a.CorruptFraction * float64(numNodes)))
+
+	for i, node := range rng.Perm(numNodes) {
+		switch {
+		case i < droppers:
+			behaviors[node] = DropBlocks
+		case i < droppers+corrupters:
+			behaviors[node] = CorruptBlocks
+		}
+	}
+	return behaviors
+}
+
+// Misbehaves implements AdversaryModel
+func (a FractionAdversary) Misbehaves(rng *rand.Rand) bool {
+	return a.Probability <= 0 || rng.Float64() < a.Probability
+}
+
+// LatencyModel draws the network latency of a single block request
+type LatencyModel interface {
+	Sample(rng *rand.Rand) time.Duration
+}
+
+// ConstantLatency is the same latency for every request
+type ConstantLatency time.Duration
+
+// Sample implements LatencyModel
+func (l ConstantLatency) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(l)
+}
+
+// UniformLatency is spread evenly between Min and Max
+type UniformLatency struct {
+	Min, Max time.Duration
+}
+
+// Sample implements LatencyModel
+func (l UniformLatency) Sample(rng *rand.Rand) time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + time.Duration(rng.Int63n(int64(l.Max-l.Min)))
+}
+
+// LogNormalLatency has the long tail typical of wide-area networks. Sigma
+// controls the tail: 0.5 puts the 95th percentile at about 2.3x the median.
+type LogNormalLatency struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+// Sample implements LatencyModel
+func (l LogNormalLatency) Sample(rng *rand.Rand) time.Duration {
+	return time.Duration(float64(l.Median) * math.Exp(l.Sigma*rng.NormFloat64()))
+}
@@ -0,0 +1,132 @@
+package simulation
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func testConfig() *SimulationConfig {
+	return &SimulationConfig{
+		NumNodes:           10,
+		CacheSize:          1000,
+		NumFiles:           20,
+		FileSizeRange:      [2]int{1024, 64 * 1024}, // Single-block files
+		PopularityFactor:   0.8,
+		SimulationDuration: 30 * time.Second,
+		UploadRate:         0.2,
+		DownloadRate:       2.0,
+		Seed:               1,
+	}
+}
+
+func TestSimulationWithoutAdversaries(t *testing.T) {
+	config := testConfig()
+	config.Latency = ConstantLatency(50 * time.Millisecond)
+
+	sim := NewNetworkSimulation(config)
+	if err := sim.RunSimulated(); err != nil {
+		t.Fatalf("Simulation failed: %v", err)
+	}
+
+	metrics := sim.GetGlobalMetrics()
+	if metrics.DownloadAttempts == 0 {
+		t.Fatal("Expected downloads during the simulation")
+	}
+	if metrics.Availability != 100 || metrics.AdversaryExposure != 0 {
+		t.Errorf("Expected full availability and no exposure, got %.1f%% and %.1f%%",
+			metrics.Availability, metrics.AdversaryExposure)
+	}
+	if metrics.BlockRequests > 0 && metrics.LatencyP95 != 50*time.Millisecond {
+		t.Errorf("Expected p95 latency of one round trip, got %v", metrics.LatencyP95)
+	}
+}
+
+func TestSimulationWithDroppingNodes(t *testing.T) {
+	config := testConfig()
+	config.Adversary = FractionAdversary{DropFraction: 1}
+
+	sim := NewNetworkSimulation(config)
+	sim.RunSimulated()
+
+	metrics := sim.GetGlobalMetrics()
+	if metrics.MaliciousNodes != config.NumNodes {
+		t.Errorf("Expected every node to be malicious, got %d", metrics.MaliciousNodes)
+	}
+	if metrics.DroppedBlocks == 0 || metrics.Availability >= 100 {
+		t.Errorf("Expected dropped blocks to fail downloads, got %d dropped and %.1f%% availability",
+			metrics.DroppedBlocks, metrics.Availability)
+	}
+	if metrics.AdversaryExposure != 100 {
+		t.Errorf("Expected every request to be observed, got %.1f%%", metrics.AdversaryExposure)
+	}
+}
+
+func TestSimulationWithChurn(t *testing.T) {
+	config := testConfig()
+	config.Churn = PoissonChurn{LeaveRate: 0.5, JoinRate: 0.5}
+
+	sim := NewNetworkSimulation(config)
+	sim.RunSimulated()
+
+	metrics := sim.GetGlobalMetrics()
+	if metrics.NodeLeaves == 0 || metrics.NodeJoins == 0 {
+		t.Errorf("Expected nodes to leave and join, got %d leaves and %d joins", metrics.NodeLeaves, metrics.NodeJoins)
+	}
+}
+
+func TestFractionAdversary(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	behaviors := FractionAdversary{DropFraction: 0.2, CorruptFraction: 0.1}.Behaviors(100, rng)
+
+	counts := make(map[NodeBehavior]int)
+	for _, b := range behaviors {
+		counts[b]++
+	}
+	if counts[DropBlocks] != 20 || counts[CorruptBlocks] != 10 || counts[Honest] != 70 {
+		t.Errorf("Unexpected behavior counts: %v", counts)
+	}
+
+	selective := FractionAdversary{Probability: 0.25}
+	misbehaved := 0
+	for i := 0; i < 10000; i++ {
+		if selective.Misbehaves(rng) {
+			misbehaved++
+		}
+	}
+	if misbehaved < 2200 || misbehaved > 2800 {
+		t.Errorf("Expected about 25%% misbehaviour, got %d/10000", misbehaved)
+	}
+}
+
+func TestLatencyModels(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	uniform := UniformLatency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}
+	for i := 0; i < 1000; i++ {
+		if l := uniform.Sample(rng); l < uniform.Min || l >= uniform.Max {
+			t.Fatalf("Uniform sample %v out of range", l)
+		}
+	}
+
+	logNormal := LogNormalLatency{Median: 100 * time.Millisecond, Sigma: 0.5}
+	below := 0
+	for i := 0; i < 10000; i++ {
+		if logNormal.Sample(rng) < logNormal.Median {
+			below++
+		}
+	}
+	if below < 4700 || below > 5300 {
+		t.Errorf("Expected half of samples below the median, got %d/10000", below)
+	}
+}
+
+func TestPoissonChurnProbability(t *testing.T) {
+	if p := eventProbability(0, time.Second); p != 0 {
+		t.Errorf("Zero rate should never fire, got %f", p)
+	}
+	// With one event per second, a one second step fires with probability 1 - 1/e
+	if p := eventProbability(1, time.Second); p < 0.63 || p > 0.64 {
+		t.Errorf("Expected ~0.632, got %f", p)
+	}
+}
@@ -3,6 +3,7 @@ package simulation
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,6 +18,22 @@ type NetworkSimulation struct {
 	globalMetrics *GlobalMetrics
 	config       *SimulationConfig
 	mu           sync.RWMutex
+
+	rng       *rand.Rand // Drives churn, adversary and latency models
+	stats     networkStats
+	latencies []time.Duration // Per-download network latency
+}
+
+// networkStats counts block retrievals under churn and adversaries
+type networkStats struct {
+	nodeJoins         int64
+	nodeLeaves        int64
+	downloadAttempts  int64
+	failedDownloads   int64
+	blockRequests     int64
+	droppedBlocks     int64
+	corruptedBlocks   int64
+	adversaryObserved int64
 }
 
 // SimulationConfig defines parameters for network simulation
@@ -29,6 +46,12 @@ type SimulationConfig struct {
 	SimulationDuration time.Duration // How long to run the simulation
 	UploadRate         float64       // Files uploaded per second per node
 	DownloadRate       float64       // Files downloaded per second per node
+
+	// Optional models of adversarial conditions; nil means none
+	Churn     ChurnModel     // Nodes leaving and rejoining
+	Adversary AdversaryModel // Nodes that drop or corrupt blocks
+	Latency   LatencyModel   // Latency of each block request
+	Seed      int64          // Seed for the models; 0 picks one from the clock
 }
 
 // SimulatedNode represents a single node in the NoiseFS network
@@ -37,6 +60,9 @@ type SimulatedNode struct {
 	cache   *cache.MemoryCache
 	metrics *noisefs.Metrics
 	files   map[string]*SimulatedFile // Files stored on this node
+
+	online   bool
+	behavior NodeBehavior
 }
 
 // SimulatedFile represents a file in the simulation
@@ -71,14 +97,39 @@ type GlobalMetrics struct {
 	AverageLatency        time.Duration
 	PeakMemoryUsage       int64
 	NodeMetrics           map[string]*noisefs.MetricsSnapshot
+
+	// Availability and deniability under churn and adversaries
+	OnlineNodes       int
+	MaliciousNodes    int
+	NodeJoins         int64
+	NodeLeaves        int64
+	DownloadAttempts  int64
+	FailedDownloads   int64
+	Availability      float64 // Percentage of downloads whose every block was retrieved
+	BlockRequests     int64   // Requests for blocks missing from the local cache
+	DroppedBlocks     int64
+	CorruptedBlocks   int64   // Detected by hash verification and refetched elsewhere
+	AdversaryExposure float64 // Percentage of block requests seen by malicious nodes
+	LatencyP95        time.Duration
 }
 
 // NewNetworkSimulation creates a new network simulation
 func NewNetworkSimulation(config *SimulationConfig) *NetworkSimulation {
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
 	sim := &NetworkSimulation{
 		nodes:         make([]*SimulatedNode, config.NumNodes),
 		globalMetrics: &GlobalMetrics{NodeMetrics: make(map[string]*noisefs.MetricsSnapshot)},
 		config:        config,
+		rng:           rand.New(rand.NewSource(seed)),
+	}
+
+	var behaviors []NodeBehavior
+	if config.Adversary != nil {
+		behaviors = config.Adversary.Behaviors(config.NumNodes, sim.rng)
 	}
 
 	// Initialize nodes
@@ -88,6 +139,10 @@ func NewNetworkSimulation(config *SimulationConfig) *NetworkSimulation {
 			cache:   cache.NewMemoryCache(config.CacheSize),
 			metrics: noisefs.NewMetrics(),
 			files:   make(map[string]*SimulatedFile),
+			online:  true,
+		}
+		if behaviors != nil {
+			sim.nodes[i].behavior = behaviors[i]
 		}
 	}
 
@@ -109,7 +164,7 @@ func (sim *NetworkSimulation) Run() error {
 	for {
 		select {
 		case <-ticker.C:
-			sim.simulateActivity()
+			sim.step(100 * time.Millisecond)
 			
 			if time.Since(start) >= sim.config.SimulationDuration {
 				fmt.Println("Simulation completed")
@@ -119,6 +174,42 @@ func (sim *NetworkSimulation) Run() error {
 	}
 }
 
+// RunSimulated executes the simulation in simulated time, taking the same
+// 100ms steps as Run without waiting, so long periods of churn can be
+// evaluated quickly and, with a fixed Seed, reproducibly
+func (sim *NetworkSimulation) RunSimulated() error {
+	sim.generateInitialFiles()
+
+	const stepSize = 100 * time.Millisecond
+	for elapsed := time.Duration(0); elapsed < sim.config.SimulationDuration; elapsed += stepSize {
+		sim.step(stepSize)
+	}
+	return nil
+}
+
+// step advances the network by dt: nodes churn, then online nodes act
+func (sim *NetworkSimulation) step(dt time.Duration) {
+	if sim.config.Churn != nil {
+		sim.applyChurn(dt)
+	}
+	sim.simulateActivity()
+}
+
+// applyChurn moves nodes on and offline. Caches survive downtime, so a
+// returning node serves the blocks it held before it left.
+func (sim *NetworkSimulation) applyChurn(dt time.Duration) {
+	for _, node := range sim.nodes {
+		online := sim.config.Churn.Online(node.online, dt, sim.rng)
+		switch {
+		case online && !node.online:
+			sim.stats.nodeJoins++
+		case !online && node.online:
+			sim.stats.nodeLeaves++
+		}
+		node.online = online
+	}
+}
+
 // generateInitialFiles creates initial files following realistic content patterns
 func (sim *NetworkSimulation) generateInitialFiles() {
 	fmt.Printf("Generating %d initial files with realistic content patterns...\n", sim.config.NumFiles)
@@ -238,6 +329,10 @@ func (sim *NetworkSimulation) addBlocksToCache(file *SimulatedFile, originNode i
 func (sim *NetworkSimulation) simulateActivity() {
 	// Simulate uploads and downloads across nodes
 	for _, node := range sim.nodes {
+		if !node.online {
+			continue
+		}
+
 		// Simulate upload activity
 		if rand.Float64() < sim.config.UploadRate/10.0 {
 			sim.simulateUpload(node)
@@ -284,18 +379,73 @@ func (sim *NetworkSimulation) simulateDownload(node *SimulatedNode) {
 	file := sim.selectFileByPopularity(allFiles)
 	file.downloadCount++
 	
-	// Simulate cache hits/misses
+	// Blocks missing from the local cache are fetched from peers. Blocks are
+	// requested in parallel, so the slowest one sets the download latency.
+	sim.stats.downloadAttempts++
+	var latency time.Duration
+	complete := true
 	for _, block := range file.blocks {
 		if _, err := node.cache.Get(block.id); err == nil {
 			node.metrics.RecordCacheHit()
-		} else {
-			node.metrics.RecordCacheMiss()
+			continue
+		}
+		node.metrics.RecordCacheMiss()
+
+		blockLatency, ok := sim.fetchBlock(node, block.id)
+		if blockLatency > latency {
+			latency = blockLatency
+		}
+		if !ok {
+			complete = false
 		}
 	}
-	
+
+	if !complete {
+		sim.stats.failedDownloads++
+	}
+	sim.latencies = append(sim.latencies, latency)
 	node.metrics.RecordDownload()
 }
 
+// fetchBlock requests a block from online peers holding it, in random order,
+// until one returns it intact. Dropped requests and corrupted blocks cost a
+// round trip each before the next peer is tried.
+func (sim *NetworkSimulation) fetchBlock(node *SimulatedNode, blockID string) (time.Duration, bool) {
+	var latency time.Duration
+	for _, i := range sim.rng.Perm(len(sim.nodes)) {
+		peer := sim.nodes[i]
+		if peer == node || !peer.online {
+			continue
+		}
+		block, err := peer.cache.Get(blockID)
+		if err != nil {
+			continue
+		}
+
+		sim.stats.blockRequests++
+		if sim.config.Latency != nil {
+			latency += sim.config.Latency.Sample(sim.rng)
+		}
+
+		if peer.behavior != Honest {
+			// A malicious peer learns who asked for the block whether or not it answers
+			sim.stats.adversaryObserved++
+			if sim.config.Adversary.Misbehaves(sim.rng) {
+				if peer.behavior == DropBlocks {
+					sim.stats.droppedBlocks++
+				} else {
+					sim.stats.corruptedBlocks++
+				}
+				continue
+			}
+		}
+
+		node.cache.Store(blockID, block)
+		return latency, true
+	}
+	return latency, false
+}
+
 // Helper functions
 
 func (sim *NetworkSimulation) getNodeIndex(targetNode *SimulatedNode) int {
@@ -391,6 +541,8 @@ func (sim *NetworkSimulation) GetGlobalMetrics() *GlobalMetrics {
 	if totalBlocks > 0 {
 		metrics.NetworkBlockReuseRate = float64(totalBlocks-uniqueBlocks) / float64(totalBlocks) * 100.0
 	}
+
+	sim.collectAdversarialMetrics(metrics)
 	
 	return metrics
 }
@@ -629,4 +781,45 @@ func (sim *NetworkSimulation) calculateBlockReuseProb(file *SimulatedFile, conte
 	}
 	
 	return totalProb
-}
\ No newline at end of file
+}
+
+// collectAdversarialMetrics fills in availability, exposure and latency
+func (sim *NetworkSimulation) collectAdversarialMetrics(metrics *GlobalMetrics) {
+	for _, node := range sim.nodes {
+		if node.online {
+			metrics.OnlineNodes++
+		}
+		if node.behavior != Honest {
+			metrics.MaliciousNodes++
+		}
+	}
+
+	stats := sim.stats
+	metrics.NodeJoins = stats.nodeJoins
+	metrics.NodeLeaves = stats.nodeLeaves
+	metrics.DownloadAttempts = stats.downloadAttempts
+	metrics.FailedDownloads = stats.failedDownloads
+	metrics.BlockRequests = stats.blockRequests
+	metrics.DroppedBlocks = stats.droppedBlocks
+	metrics.CorruptedBlocks = stats.corruptedBlocks
+
+	metrics.Availability = 100.0
+	if stats.downloadAttempts > 0 {
+		metrics.Availability = float64(stats.downloadAttempts-stats.failedDownloads) / float64(stats.downloadAttempts) * 100.0
+	}
+	if stats.blockRequests > 0 {
+		metrics.AdversaryExposure = float64(stats.adversaryObserved) / float64(stats.blockRequests) * 100.0
+	}
+
+	if len(sim.latencies) > 0 {
+		sorted := append([]time.Duration(nil), sim.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		var total time.Duration
+		for _, l := range sorted {
+			total += l
+		}
+		metrics.AverageLatency = total / time.Duration(len(sorted))
+		metrics.LatencyP95 = sorted[(len(sorted)*95-1)/100]
+	}
+}
@@ -0,0 +1,127 @@
+package testing
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/TheEntropyCollective/noisefs/pkg/core/blocks"
+	"github.com/TheEntropyCollective/noisefs/pkg/storage/cache"
+)
+
+// Cache returns the node's block cache
+func (n *RealIPFSNode) Cache() cache.Cache {
+	return n.cache
+}
+
+// CacheTestResults holds measured cache behaviour of one node
+type CacheTestResults struct {
+	NodeID string
+	Blocks int
+
+	// Cold reads go to IPFS: every block is evicted before it is read
+	ColdReads   int
+	ColdLatency time.Duration // Average
+
+	// Warm reads re-read random blocks and hit the cache unless the working
+	// set is larger than the cache
+	WarmReads   int
+	WarmLatency time.Duration // Average
+	HitLatency  time.Duration // Average of warm reads served from cache
+	MissLatency time.Duration // Average of warm reads that went to IPFS
+	CacheHits   int64
+	CacheMisses int64
+	HitRate     float64 // Percentage of warm reads served from cache
+	Evictions   int64   // Blocks evicted from the cache during the test
+}
+
+// Speedup is how many times faster a warm read is than a cold one
+func (r *CacheTestResults) Speedup() float64 {
+	if r.WarmLatency == 0 {
+		return 0
+	}
+	return float64(r.ColdLatency) / float64(r.WarmLatency)
+}
+
+// TestCacheEfficiency stores the given blocks on a node, reads each once with
+// an empty cache, then performs reads random re-reads and classifies each as
+// a hit or miss from the client's own counters
+func (h *RealIPFSTestHarness) TestCacheEfficiency(nodeIndex int, testData [][]byte, reads int) (*CacheTestResults, error) {
+	node, err := h.GetNode(nodeIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(testData) == 0 {
+		return nil, fmt.Errorf("no test data")
+	}
+
+	ctx := context.Background()
+	client := node.NoiseClient
+	results := &CacheTestResults{NodeID: node.NodeID, Blocks: len(testData)}
+
+	cids := make([]string, len(testData))
+	for i, data := range testData {
+		block, err := blocks.NewBlock(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create block: %w", err)
+		}
+		cids[i], err = client.StoreBlockWithCache(ctx, block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store block: %w", err)
+		}
+	}
+
+	evictionsBefore := node.cache.GetStats().Evictions
+
+	// Cold: evict each block right before reading it
+	var coldTotal time.Duration
+	for _, cid := range cids {
+		client.EvictFromCache(cid)
+		start := time.Now()
+		if _, err := client.RetrieveBlockWithCache(ctx, cid); err != nil {
+			return nil, fmt.Errorf("cold read failed: %w", err)
+		}
+		coldTotal += time.Since(start)
+		results.ColdReads++
+	}
+	results.ColdLatency = coldTotal / time.Duration(results.ColdReads)
+
+	// Warm: random re-reads over the working set
+	rng := rand.New(rand.NewSource(1))
+	var warmTotal, hitTotal, missTotal time.Duration
+	for i := 0; i < reads; i++ {
+		cid := cids[rng.Intn(len(cids))]
+		hitsBefore := client.GetMetrics().CacheHits
+
+		start := time.Now()
+		if _, err := client.RetrieveBlockWithCache(ctx, cid); err != nil {
+			return nil, fmt.Errorf("warm read failed: %w", err)
+		}
+		latency := time.Since(start)
+
+		warmTotal += latency
+		if client.GetMetrics().CacheHits > hitsBefore {
+			results.CacheHits++
+			hitTotal += latency
+		} else {
+			results.CacheMisses++
+			missTotal += latency
+		}
+		results.WarmReads++
+	}
+
+	if results.WarmReads > 0 {
+		results.WarmLatency = warmTotal / time.Duration(results.WarmReads)
+		results.HitRate = float64(results.CacheHits) / float64(results.WarmReads) * 100
+	}
+	if results.CacheHits > 0 {
+		results.HitLatency = hitTotal / time.Duration(results.CacheHits)
+	}
+	if results.CacheMisses > 0 {
+		results.MissLatency = missTotal / time.Duration(results.CacheMisses)
+	}
+	results.Evictions = node.cache.GetStats().Evictions - evictionsBefore
+
+	return results, nil
+}